@@ -0,0 +1,321 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvConfigFile names the optional YAML or JSON config file. The environment overrides any setting of the file.
+const EnvConfigFile = "BBASH_CONFIG_FILE"
+
+// redacted replaces the value of a secret setting when the config is shown
+const redacted = "REDACTED"
+
+// the database drivers. The SQLite driver is for local development and demos, and needs the sqlite build tag. The
+// memory driver keeps nothing once the server stops, for demos.
+const (
+	DBDriverPostgres = "postgres"
+	DBDriverSqlite   = "sqlite"
+	DBDriverMemory   = "memory"
+)
+
+// Duration is a time.Duration read from a string like "10s"
+type Duration time.Duration
+
+var _ encoding.TextUnmarshaler = (*Duration)(nil)
+var _ encoding.TextMarshaler = Duration(0)
+
+// UnmarshalText reads an empty string as a negative (unset) duration
+func (d *Duration) UnmarshalText(text []byte) (err error) {
+	if len(text) == 0 {
+		*d = -1
+		return
+	}
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return
+	}
+	*d = Duration(parsed)
+	return
+}
+
+// MarshalText writes a negative (unset) duration as an empty string
+func (d Duration) MarshalText() ([]byte, error) {
+	if d < 0 {
+		return []byte{}, nil
+	}
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Config is every setting of the service. Each setting can be read from the config file, by its yaml (or json) key, and
+// from the environment, by its env name. Settings tagged secret are redacted when the config is shown.
+type Config struct {
+	AdminUsername string `yaml:"adminUsername" json:"adminUsername" env:"ADMIN_USERNAME"`
+	AdminPassword string `yaml:"adminPassword" json:"adminPassword" env:"ADMIN_PASSWORD" secret:"true"`
+	// SecretsFile holds more admins, with hashed passwords, and the datadog keys. It is read again every
+	// SecretsReloadSeconds, so these can be rotated without a restart.
+	SecretsFile          string `yaml:"secretsFile" json:"secretsFile" env:"SECRETS_FILE"`
+	SecretsReloadSeconds int    `yaml:"secretsReloadSeconds" json:"secretsReloadSeconds" env:"SECRETS_RELOAD_SECONDS"`
+	// the secrets backends, which a secret setting can name a secret of instead of holding it, such as
+	// vault:secret/data/bbash#pgPassword or awssm:bbash/prod#pgPassword
+	VaultAddr          string `yaml:"vaultAddr" json:"vaultAddr" env:"VAULT_ADDR"`
+	VaultToken         string `yaml:"vaultToken" json:"vaultToken" env:"VAULT_TOKEN" secret:"true"`
+	AwsRegion          string `yaml:"awsRegion" json:"awsRegion" env:"AWS_REGION"`
+	AwsAccessKeyId     string `yaml:"awsAccessKeyId" json:"awsAccessKeyId" env:"AWS_ACCESS_KEY_ID"`
+	AwsSecretAccessKey string `yaml:"awsSecretAccessKey" json:"awsSecretAccessKey" env:"AWS_SECRET_ACCESS_KEY" secret:"true"`
+	AwsSessionToken    string `yaml:"awsSessionToken" json:"awsSessionToken" env:"AWS_SESSION_TOKEN" secret:"true"`
+
+	// DBDriver is DBDriverPostgres, DBDriverSqlite or DBDriverMemory. SqlitePath is the SQLite database file.
+	DBDriver   string `yaml:"dbDriver" json:"dbDriver" env:"DB_DRIVER"`
+	SqlitePath string `yaml:"sqlitePath" json:"sqlitePath" env:"SQLITE_PATH"`
+
+	PGHost     string `yaml:"pgHost" json:"pgHost" env:"PG_HOST"`
+	PGPort     int    `yaml:"pgPort" json:"pgPort" env:"PG_PORT"`
+	PGUsername string `yaml:"pgUsername" json:"pgUsername" env:"PG_USERNAME"`
+	PGPassword string `yaml:"pgPassword" json:"pgPassword" env:"PG_PASSWORD" secret:"true"`
+	PGDBName   string `yaml:"pgDbName" json:"pgDbName" env:"PG_DB_NAME"`
+	SSLMode    string `yaml:"sslMode" json:"sslMode" env:"SSL_MODE"`
+	// the connection pool settings, and the statement timeout, are left at their defaults when negative
+	PGMaxOpenConns     int      `yaml:"pgMaxOpenConns" json:"pgMaxOpenConns" env:"PG_MAX_OPEN_CONNS"`
+	PGMaxIdleConns     int      `yaml:"pgMaxIdleConns" json:"pgMaxIdleConns" env:"PG_MAX_IDLE_CONNS"`
+	PGConnMaxLifetime  Duration `yaml:"pgConnMaxLifetime" json:"pgConnMaxLifetime" env:"PG_CONN_MAX_LIFETIME"`
+	PGStatementTimeout Duration `yaml:"pgStatementTimeout" json:"pgStatementTimeout" env:"PG_STATEMENT_TIMEOUT"`
+	PGReadReplicaDSN   string   `yaml:"pgReadReplicaDsn" json:"pgReadReplicaDsn" env:"PG_READ_REPLICA_DSN" secret:"true"`
+	// after PGBreakerFailures calls in a row fail to reach the database, calls fail fast for PGBreakerOpenDuration.
+	// Zero or less turns the circuit breaker off.
+	PGBreakerFailures     int      `yaml:"pgBreakerFailures" json:"pgBreakerFailures" env:"PG_BREAKER_FAILURES"`
+	PGBreakerOpenDuration Duration `yaml:"pgBreakerOpenDuration" json:"pgBreakerOpenDuration" env:"PG_BREAKER_OPEN_DURATION"`
+
+	LogFilterIncludeHostname string `yaml:"logFilterIncludeHostname" json:"logFilterIncludeHostname" env:"LOG_FILTER_INCLUDE_HOSTNAME"`
+	// requests are left out of the access log when their user agent contains one of LogSkipUserAgents, or their path
+	// begins with one of LogSkipPaths
+	LogSkipUserAgents []string `yaml:"logSkipUserAgents" json:"logSkipUserAgents" env:"LOG_SKIP_USER_AGENTS"`
+	LogSkipPaths      []string `yaml:"logSkipPaths" json:"logSkipPaths" env:"LOG_SKIP_PATHS"`
+
+	// unexpected errors, and scoring messages that fail, are reported to the Sentry project of SentryDsn, when set
+	SentryDsn         string `yaml:"sentryDsn" json:"sentryDsn" env:"SENTRY_DSN" secret:"true"`
+	SentryEnvironment string `yaml:"sentryEnvironment" json:"sentryEnvironment" env:"SENTRY_ENVIRONMENT"`
+	// LogLevel is the level logged from at startup, debug when empty. It can be changed while running, by an admin.
+	LogLevel string `yaml:"logLevel" json:"logLevel" env:"LOG_LEVEL"`
+
+	InstanceId                string `yaml:"instanceId" json:"instanceId" env:"INSTANCE_ID"`
+	InstanceRole              string `yaml:"instanceRole" json:"instanceRole" env:"INSTANCE_ROLE"`
+	HeartbeatSeconds          int    `yaml:"heartbeatSeconds" json:"heartbeatSeconds" env:"HEARTBEAT_SECONDS"`
+	LeaderboardRefreshSeconds int    `yaml:"leaderboardRefreshSeconds" json:"leaderboardRefreshSeconds" env:"LEADERBOARD_REFRESH_SECONDS"`
+	CampaignLifecycleSeconds  int    `yaml:"campaignLifecycleSeconds" json:"campaignLifecycleSeconds" env:"CAMPAIGN_LIFECYCLE_SECONDS"`
+	AnomalySeconds            int    `yaml:"anomalySeconds" json:"anomalySeconds" env:"ANOMALY_SECONDS"`
+	// ArchiveAfterDays moves the scoring events of the campaigns ended this many days ago into their archive, when
+	// more than zero.
+	ArchiveAfterDays int `yaml:"archiveAfterDays" json:"archiveAfterDays" env:"ARCHIVE_AFTER_DAYS"`
+
+	TeamSelfService bool `yaml:"teamSelfService" json:"teamSelfService" env:"TEAM_SELF_SERVICE"`
+
+	// ScoringSecrets adds the endpoint accepting pushed scoring messages, each signed with the secret of its
+	// eventSource. It is a comma separated list of eventSource=secret pairs, such as GitHub=s3cret,GitLab=0th3r.
+	ScoringSecrets string `yaml:"scoringSecrets" json:"scoringSecrets" env:"SCORING_SECRETS" secret:"true"`
+	// GrpcPort serves the gRPC scoring service on its own port, alongside the HTTP server. Zero leaves it off.
+	GrpcPort int `yaml:"grpcPort" json:"grpcPort" env:"GRPC_PORT"`
+	// GrpcTokens are the bearer tokens of the gRPC scoring service, eventSource=token pairs like ScoringSecrets, kept
+	// apart from the secrets signing the pushed scoring messages.
+	GrpcTokens string `yaml:"grpcTokens" json:"grpcTokens" env:"GRPC_TOKENS" secret:"true"`
+	// GrpcTlsCertFile and GrpcTlsKeyFile are the certificate the gRPC scoring service is served with, the TlsCertFile
+	// and TlsKeyFile when unset. The service is only served with TLS.
+	GrpcTlsCertFile string `yaml:"grpcTlsCertFile" json:"grpcTlsCertFile" env:"GRPC_TLS_CERT_FILE"`
+	GrpcTlsKeyFile  string `yaml:"grpcTlsKeyFile" json:"grpcTlsKeyFile" env:"GRPC_TLS_KEY_FILE"`
+
+	// AdminSeed adds the admin endpoint loading a seed fixture, for preview environments and end to end tests
+	AdminSeed bool `yaml:"adminSeed" json:"adminSeed" env:"ADMIN_SEED"`
+
+	RateLimitPerSecond float64 `yaml:"rateLimitPerSecond" json:"rateLimitPerSecond" env:"RATE_LIMIT_PER_SECOND"`
+	RateLimitBurst     int     `yaml:"rateLimitBurst" json:"rateLimitBurst" env:"RATE_LIMIT_BURST"`
+	// TrustedProxies are the CIDR ranges of proxies, beyond those on private addresses, trusted to add the client IP
+	// to X-Forwarded-For
+	TrustedProxies []string `yaml:"trustedProxies" json:"trustedProxies" env:"TRUSTED_PROXIES"`
+
+	CorsAllowedOrigins   []string `yaml:"corsAllowedOrigins" json:"corsAllowedOrigins" env:"CORS_ALLOWED_ORIGINS"`
+	CorsAllowedMethods   []string `yaml:"corsAllowedMethods" json:"corsAllowedMethods" env:"CORS_ALLOWED_METHODS"`
+	CorsAllowCredentials bool     `yaml:"corsAllowCredentials" json:"corsAllowCredentials" env:"CORS_ALLOW_CREDENTIALS"`
+
+	SmtpHost     string `yaml:"smtpHost" json:"smtpHost" env:"SMTP_HOST"`
+	SmtpPort     int    `yaml:"smtpPort" json:"smtpPort" env:"SMTP_PORT"`
+	SmtpUsername string `yaml:"smtpUsername" json:"smtpUsername" env:"SMTP_USERNAME"`
+	SmtpPassword string `yaml:"smtpPassword" json:"smtpPassword" env:"SMTP_PASSWORD" secret:"true"`
+	MailFrom     string `yaml:"mailFrom" json:"mailFrom" env:"MAIL_FROM"`
+
+	GithubToken         string `yaml:"githubToken" json:"githubToken" env:"GITHUB_TOKEN" secret:"true"`
+	ProfileRefreshHours int    `yaml:"profileRefreshHours" json:"profileRefreshHours" env:"PROFILE_REFRESH_HOURS"`
+
+	TlsCertFile         string   `yaml:"tlsCertFile" json:"tlsCertFile" env:"TLS_CERT_FILE"`
+	TlsKeyFile          string   `yaml:"tlsKeyFile" json:"tlsKeyFile" env:"TLS_KEY_FILE"`
+	TlsAutocertHosts    []string `yaml:"tlsAutocertHosts" json:"tlsAutocertHosts" env:"TLS_AUTOCERT_HOSTS"`
+	TlsAutocertCacheDir string   `yaml:"tlsAutocertCacheDir" json:"tlsAutocertCacheDir" env:"TLS_AUTOCERT_CACHE_DIR"`
+	TlsAutocertEmail    string   `yaml:"tlsAutocertEmail" json:"tlsAutocertEmail" env:"TLS_AUTOCERT_EMAIL"`
+
+	DisableDatadogPoll  bool   `yaml:"disableDatadogPoll" json:"disableDatadogPoll" env:"DISABLE_DATADOG_POLL"`
+	DDClientPollSeconds int    `yaml:"ddClientPollSeconds" json:"ddClientPollSeconds" env:"DD_CLIENT_POLL_SECONDS"`
+	DDClientApiKey      string `yaml:"ddClientApiKey" json:"ddClientApiKey" env:"DD_CLIENT_API_KEY" secret:"true"`
+	DDClientAppKey      string `yaml:"ddClientAppKey" json:"ddClientAppKey" env:"DD_CLIENT_APP_KEY" secret:"true"`
+}
+
+// Defaults returns the settings used when neither the config file nor the environment sets them.
+func Defaults() *Config {
+	return &Config{
+		DBDriver:              DBDriverPostgres,
+		SqlitePath:            "bbash.db",
+		PGMaxOpenConns:        -1,
+		PGMaxIdleConns:        -1,
+		PGConnMaxLifetime:     -1,
+		PGStatementTimeout:    -1,
+		PGBreakerFailures:     5,
+		PGBreakerOpenDuration: Duration(10 * time.Second),
+		SmtpPort:              587,
+		DDClientPollSeconds:   120,
+		// the AWS ELB health checks
+		LogSkipUserAgents: []string{"ELB-HealthChecker"},
+	}
+}
+
+// Load reads the config file at path, if any, over the defaults, then overrides it with the environment. The file is
+// read as JSON when named *.json, and as YAML otherwise. An empty environment variable is treated as unset.
+func Load(path string) (config *Config, err error) {
+	config = Defaults()
+	if path != "" {
+		if err = config.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err = config.readEnv(os.Getenv); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (c *Config) readFile(path string) (err error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(contents))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(c)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(contents))
+		decoder.KnownFields(true)
+		err = decoder.Decode(c)
+	}
+	if err != nil {
+		err = fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return
+}
+
+func (c *Config) readEnv(getenv func(key string) string) (err error) {
+	value := reflect.ValueOf(c).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		key := value.Type().Field(i).Tag.Get("env")
+		text := strings.TrimSpace(getenv(key))
+		if key == "" || text == "" {
+			continue
+		}
+		if err = setField(value.Field(i), text); err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+	return
+}
+
+// setField parses the text of an environment variable into the setting. Lists are comma separated, ignoring blank
+// entries.
+func setField(field reflect.Value, text string) (err error) {
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(text))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(text)
+	case reflect.Int:
+		var parsed int
+		parsed, err = strconv.Atoi(text)
+		field.SetInt(int64(parsed))
+	case reflect.Float64:
+		var parsed float64
+		parsed, err = strconv.ParseFloat(text, 64)
+		field.SetFloat(parsed)
+	case reflect.Bool:
+		var parsed bool
+		parsed, err = strconv.ParseBool(text)
+		field.SetBool(parsed)
+	case reflect.Slice:
+		var values []string
+		for _, value := range strings.Split(text, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+	default:
+		err = fmt.Errorf("unsupported setting type: %s", field.Type())
+	}
+	return
+}
+
+// ResolveSecrets replaces the value of each secret setting with what resolve returns for it, such as the secret a
+// reference to a secrets backend names.
+func (c *Config) ResolveSecrets(resolve func(value string) (string, error)) (err error) {
+	value := reflect.ValueOf(c).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Tag.Get("secret") != "true" || value.Field(i).String() == "" {
+			continue
+		}
+		var resolved string
+		if resolved, err = resolve(value.Field(i).String()); err != nil {
+			return fmt.Errorf("invalid %s: %w", field.Tag.Get("env"), err)
+		}
+		value.Field(i).SetString(resolved)
+	}
+	return
+}
+
+// Redacted returns a copy of the config that is safe to show, with each secret that is set replaced.
+func (c *Config) Redacted() *Config {
+	copied := *c
+	value := reflect.ValueOf(&copied).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		if value.Type().Field(i).Tag.Get("secret") == "true" && value.Field(i).String() != "" {
+			value.Field(i).SetString(redacted)
+		}
+	}
+	return &copied
+}
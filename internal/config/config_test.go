@@ -0,0 +1,219 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, contents string) (path string) {
+	path = filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+	return
+}
+
+func TestLoadNoFile(t *testing.T) {
+	t.Setenv("PG_HOST", "")
+	t.Setenv("PG_MAX_OPEN_CONNS", "")
+	t.Setenv("SMTP_PORT", "")
+	t.Setenv("DB_DRIVER", "")
+	t.Setenv("LOG_SKIP_USER_AGENTS", "")
+
+	config, err := Load("")
+	assert.NoError(t, err)
+	assert.Equal(t, DBDriverPostgres, config.DBDriver)
+	assert.Equal(t, "", config.PGHost)
+	assert.Equal(t, -1, config.PGMaxOpenConns)
+	assert.Equal(t, 587, config.SmtpPort)
+	assert.Equal(t, []string{"ELB-HealthChecker"}, config.LogSkipUserAgents)
+}
+
+func TestLoadYamlFile(t *testing.T) {
+	t.Setenv("PG_HOST", "")
+	t.Setenv("PG_PORT", "6543")
+	t.Setenv("PG_STATEMENT_TIMEOUT", "")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "")
+	t.Setenv("TEAM_SELF_SERVICE", "")
+	t.Setenv("LOG_SKIP_USER_AGENTS", "")
+	t.Setenv("LOG_SKIP_PATHS", "")
+	path := writeConfigFile(t, "bbash.yaml", `
+pgHost: db.example
+pgPort: 5432
+pgStatementTimeout: 10s
+corsAllowedOrigins:
+  - https://one.example
+  - https://two.example
+teamSelfService: true
+logSkipUserAgents:
+  - kube-probe
+logSkipPaths:
+  - /metrics
+  - /healthz
+`)
+
+	config, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "db.example", config.PGHost)
+	// the environment overrides the file
+	assert.Equal(t, 6543, config.PGPort)
+	assert.Equal(t, Duration(10*time.Second), config.PGStatementTimeout)
+	assert.Equal(t, []string{"https://one.example", "https://two.example"}, config.CorsAllowedOrigins)
+	assert.True(t, config.TeamSelfService)
+	// the file replaces the default list, rather than adding to it
+	assert.Equal(t, []string{"kube-probe"}, config.LogSkipUserAgents)
+	assert.Equal(t, []string{"/metrics", "/healthz"}, config.LogSkipPaths)
+	// unset in the file
+	assert.Equal(t, -1, config.PGMaxIdleConns)
+}
+
+func TestLoadJsonFile(t *testing.T) {
+	t.Setenv("RATE_LIMIT_PER_SECOND", "")
+	t.Setenv("PG_CONN_MAX_LIFETIME", "")
+	path := writeConfigFile(t, "bbash.json", `{"rateLimitPerSecond": 2.5, "pgConnMaxLifetime": "5m"}`)
+
+	config, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 2.5, config.RateLimitPerSecond)
+	assert.Equal(t, Duration(5*time.Minute), config.PGConnMaxLifetime)
+}
+
+func TestLoadUnknownSetting(t *testing.T) {
+	path := writeConfigFile(t, "bbash.yml", "pgHots: db.example\n")
+
+	config, err := Load(path)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid config file "+path)
+	assert.Nil(t, config)
+
+	path = writeConfigFile(t, "bbash.json", `{"pgHots": "db.example"}`)
+	_, err = Load(path)
+	assert.EqualError(t, err, "invalid config file "+path+`: json: unknown field "pgHots"`)
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.yaml")
+	_, err := Load(path)
+	assert.EqualError(t, err, "open "+path+": no such file or directory")
+}
+
+func TestLoadInvalidEnv(t *testing.T) {
+	t.Setenv("PG_MAX_IDLE_CONNS", "lots")
+
+	config, err := Load("")
+	assert.EqualError(t, err, `invalid PG_MAX_IDLE_CONNS: strconv.Atoi: parsing "lots": invalid syntax`)
+	assert.Nil(t, config)
+}
+
+func TestReadEnv(t *testing.T) {
+	env := map[string]string{
+		"ADMIN_USERNAME":         "theAdminUsername",
+		"PG_PORT":                " 5432 ",
+		"PG_CONN_MAX_LIFETIME":   "5m",
+		"RATE_LIMIT_PER_SECOND":  "0.5",
+		"CORS_ALLOW_CREDENTIALS": "true",
+		"CORS_ALLOWED_ORIGINS":   " , https://one.example, ,https://two.example",
+		"DISABLE_DATADOG_POLL":   "1",
+		"DB_DRIVER":              "sqlite",
+	}
+	config := Defaults()
+	assert.NoError(t, config.readEnv(func(key string) string {
+		return env[key]
+	}))
+	assert.Equal(t, "theAdminUsername", config.AdminUsername)
+	assert.Equal(t, 5432, config.PGPort)
+	assert.Equal(t, Duration(5*time.Minute), config.PGConnMaxLifetime)
+	assert.Equal(t, 0.5, config.RateLimitPerSecond)
+	assert.True(t, config.CorsAllowCredentials)
+	assert.Equal(t, []string{"https://one.example", "https://two.example"}, config.CorsAllowedOrigins)
+	assert.True(t, config.DisableDatadogPoll)
+	assert.Equal(t, 120, config.DDClientPollSeconds)
+	assert.Equal(t, DBDriverSqlite, config.DBDriver)
+	assert.Equal(t, "bbash.db", config.SqlitePath)
+
+	env = map[string]string{"CORS_ALLOW_CREDENTIALS": "maybe"}
+	assert.EqualError(t, config.readEnv(func(key string) string {
+		return env[key]
+	}), `invalid CORS_ALLOW_CREDENTIALS: strconv.ParseBool: parsing "maybe": invalid syntax`)
+}
+
+func TestRedacted(t *testing.T) {
+	config := Defaults()
+	config.AdminUsername = "theAdminUsername"
+	config.AdminPassword = "theAdminPassword"
+	config.DDClientApiKey = "theApiKey"
+
+	redactedConfig := config.Redacted()
+	assert.Equal(t, "theAdminUsername", redactedConfig.AdminUsername)
+	assert.Equal(t, "REDACTED", redactedConfig.AdminPassword)
+	assert.Equal(t, "REDACTED", redactedConfig.DDClientApiKey)
+	// an unset secret shows it is unset
+	assert.Equal(t, "", redactedConfig.PGPassword)
+	// the config itself is untouched
+	assert.Equal(t, "theAdminPassword", config.AdminPassword)
+}
+
+func TestResolveSecrets(t *testing.T) {
+	config := Defaults()
+	config.AdminUsername = "vault:kv/bbash#adminUsername"
+	config.AdminPassword = "vault:kv/bbash#adminPassword"
+	config.PGPassword = "thePgPassword"
+
+	var resolved []string
+	assert.NoError(t, config.ResolveSecrets(func(value string) (string, error) {
+		resolved = append(resolved, value)
+		return strings.ToUpper(value), nil
+	}))
+	assert.Equal(t, []string{"vault:kv/bbash#adminPassword", "thePgPassword"}, resolved)
+	assert.Equal(t, "VAULT:KV/BBASH#ADMINPASSWORD", config.AdminPassword)
+	assert.Equal(t, "THEPGPASSWORD", config.PGPassword)
+	// only the secrets
+	assert.Equal(t, "vault:kv/bbash#adminUsername", config.AdminUsername)
+}
+
+func TestResolveSecretsError(t *testing.T) {
+	config := Defaults()
+	config.PGPassword = "vault:kv/bbash#pgPassword"
+
+	assert.EqualError(t, config.ResolveSecrets(func(value string) (string, error) {
+		return "", fmt.Errorf("forced resolve error")
+	}), "invalid PG_PASSWORD: forced resolve error")
+}
+
+func TestDurationJson(t *testing.T) {
+	config := Defaults()
+	config.PGStatementTimeout = Duration(10 * time.Second)
+
+	shown, err := json.Marshal(config)
+	assert.NoError(t, err)
+	assert.Contains(t, string(shown), `"pgConnMaxLifetime":""`)
+	assert.Contains(t, string(shown), `"pgStatementTimeout":"10s"`)
+
+	var read Config
+	assert.NoError(t, json.Unmarshal(shown, &read))
+	assert.Equal(t, Duration(-1), read.PGConnMaxLifetime)
+	assert.Equal(t, Duration(10*time.Second), read.PGStatementTimeout)
+}
@@ -0,0 +1,263 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned, without calling the database, while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("database unavailable")
+
+// BreakerState is closed while the database is reachable, open while calls fail fast, and half open while one call
+// probes whether the database is back.
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "halfOpen"
+)
+
+// Breaker is a circuit breaker around the database. It opens after failureThreshold calls in a row fail to reach the
+// database, so later calls fail fast with ErrCircuitOpen rather than each waiting for a timeout. Once openDuration has
+// passed, it lets a single call through, closing when that call reaches the database and opening again when it does
+// not.
+type Breaker struct {
+	mu               sync.Mutex
+	failureThreshold int
+	openDuration     time.Duration
+	state            BreakerState
+	failures         int
+	openedAt         time.Time
+	probing          bool
+	now              func() time.Time
+	logger           *zap.Logger
+}
+
+// NewBreaker returns a closed breaker.
+func NewBreaker(logger *zap.Logger, failureThreshold int, openDuration time.Duration) *Breaker {
+	return &Breaker{
+		failureThreshold: failureThreshold,
+		openDuration:     openDuration,
+		state:            BreakerClosed,
+		now:              time.Now,
+		logger:           logger,
+	}
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// allow returns ErrCircuitOpen when the call must fail fast. A call allowed while half open is the probe, and must
+// be followed by record.
+func (b *Breaker) allow() (err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.openDuration {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.logger.Info("db circuit half open, probing")
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+	default:
+		return
+	}
+	b.probing = true
+	return
+}
+
+// record counts the outcome of an allowed call.
+func (b *Breaker) record(err error) {
+	failed, known := unreachable(err)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+	}
+	if !known {
+		// the caller gave up, which says nothing about the database, so the probe is left for the next call
+		return
+	}
+
+	if !failed {
+		if b.state != BreakerClosed {
+			b.logger.Info("db circuit closed")
+		}
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.failureThreshold) {
+		b.logger.Error("db circuit open", zap.Int("failures", b.failures), zap.Duration("openDuration", b.openDuration), zap.Error(err))
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// unreachable tells whether the error shows the database could not be reached, or did not answer in time. Other
+// errors, such as a constraint violation, come from a database that is up. known is false when the outcome says
+// nothing either way, such as when the caller cancelled.
+func unreachable(err error) (failed, known bool) {
+	if err == nil {
+		return false, true
+	}
+	if errors.Is(err, context.Canceled) {
+		return false, false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true, true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true, true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// connection exceptions, and the server shutting down or starting up
+		code := string(pqErr.Code)
+		return strings.HasPrefix(code, "08") || strings.HasPrefix(code, "57P"), true
+	}
+	return false, true
+}
+
+// Connector wraps the connector, so the breaker sees the outcome of every call made with its connections.
+func (b *Breaker) Connector(connector driver.Connector) driver.Connector {
+	return &breakerConnector{Connector: connector, breaker: b}
+}
+
+type breakerConnector struct {
+	driver.Connector
+	breaker *Breaker
+}
+
+func (c *breakerConnector) Connect(ctx context.Context) (conn driver.Conn, err error) {
+	if err = c.breaker.allow(); err != nil {
+		return
+	}
+	conn, err = c.Connector.Connect(ctx)
+	c.breaker.record(err)
+	if err != nil {
+		return
+	}
+	return &breakerConn{Conn: conn, breaker: c.breaker}, nil
+}
+
+// breakerConn passes each call through to the connection, unless the breaker is open. It has the methods of the
+// lib/pq connection, so database/sql uses the connection as it would without the breaker.
+type breakerConn struct {
+	driver.Conn
+	breaker *Breaker
+}
+
+var (
+	_ driver.ExecerContext      = (*breakerConn)(nil)
+	_ driver.QueryerContext     = (*breakerConn)(nil)
+	_ driver.ConnPrepareContext = (*breakerConn)(nil)
+	_ driver.ConnBeginTx        = (*breakerConn)(nil)
+	_ driver.Pinger             = (*breakerConn)(nil)
+)
+
+func (c *breakerConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (result driver.Result, err error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err = c.breaker.allow(); err != nil {
+		return
+	}
+	result, err = execer.ExecContext(ctx, query, args)
+	c.breaker.record(err)
+	return
+}
+
+func (c *breakerConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err = c.breaker.allow(); err != nil {
+		return
+	}
+	rows, err = queryer.QueryContext(ctx, query, args)
+	c.breaker.record(err)
+	return
+}
+
+func (c *breakerConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
+	if err = c.breaker.allow(); err != nil {
+		return
+	}
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	c.breaker.record(err)
+	return
+}
+
+func (c *breakerConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.Tx, err error) {
+	if err = c.breaker.allow(); err != nil {
+		return
+	}
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = beginner.BeginTx(ctx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	c.breaker.record(err)
+	return
+}
+
+func (c *breakerConn) Ping(ctx context.Context) (err error) {
+	pinger, ok := c.Conn.(driver.Pinger)
+	if !ok {
+		return
+	}
+	if err = c.breaker.allow(); err != nil {
+		return
+	}
+	err = pinger.Ping(ctx)
+	c.breaker.record(err)
+	return
+}
@@ -0,0 +1,190 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeConnector hands out connections whose calls fail with err, counting the connections and the calls that got
+// through the breaker
+type fakeConnector struct {
+	err      error
+	connects int
+	calls    int
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	c.connects++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &fakeConn{connector: c}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct {
+	connector *fakeConnector
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (c *fakeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	c.connector.calls++
+	if c.connector.err != nil {
+		return nil, c.connector.err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func setupBreaker(t *testing.T, now *time.Time) *Breaker {
+	b := NewBreaker(zaptest.NewLogger(t), 2, time.Minute)
+	b.now = func() time.Time {
+		return *now
+	}
+	return b
+}
+
+func TestBreakerOpens(t *testing.T) {
+	now := time.Now()
+	b := setupBreaker(t, &now)
+	connector := &fakeConnector{}
+	db := sql.OpenDB(b.Connector(connector))
+	defer func() {
+		_ = db.Close()
+	}()
+
+	_, err := db.ExecContext(context.Background(), "UPDATE bug SET points = 1")
+	assert.NoError(t, err)
+	assert.Equal(t, BreakerClosed, b.State())
+
+	connector.err = &net.OpError{Op: "read", Err: fmt.Errorf("connection reset by peer")}
+	_, err = db.ExecContext(context.Background(), "UPDATE bug SET points = 1")
+	assert.Equal(t, connector.err, err)
+	assert.Equal(t, BreakerClosed, b.State())
+	_, err = db.ExecContext(context.Background(), "UPDATE bug SET points = 1")
+	assert.Equal(t, connector.err, err)
+	assert.Equal(t, BreakerOpen, b.State())
+
+	// fails fast, without calling the database
+	calls := connector.calls
+	_, err = db.ExecContext(context.Background(), "UPDATE bug SET points = 1")
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.Equal(t, calls, connector.calls)
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	now := time.Now()
+	b := setupBreaker(t, &now)
+	forcedError := &net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}
+	b.record(forcedError)
+	b.record(forcedError)
+	assert.Equal(t, BreakerOpen, b.State())
+
+	now = now.Add(59 * time.Second)
+	assert.Equal(t, ErrCircuitOpen, b.allow())
+
+	// one call probes, while the others still fail fast
+	now = now.Add(time.Second)
+	assert.NoError(t, b.allow())
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	assert.Equal(t, ErrCircuitOpen, b.allow())
+
+	// a failed probe opens the circuit again, for another openDuration
+	b.record(forcedError)
+	assert.Equal(t, BreakerOpen, b.State())
+	assert.Equal(t, ErrCircuitOpen, b.allow())
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, b.allow())
+	b.record(nil)
+	assert.Equal(t, BreakerClosed, b.State())
+	assert.NoError(t, b.allow())
+	assert.NoError(t, b.allow())
+}
+
+func TestBreakerHalfOpenCancelledProbe(t *testing.T) {
+	now := time.Now()
+	b := setupBreaker(t, &now)
+	b.record(io.EOF)
+	b.record(io.EOF)
+	now = now.Add(time.Minute)
+
+	assert.NoError(t, b.allow())
+	b.record(context.Canceled)
+	assert.Equal(t, BreakerHalfOpen, b.State())
+	// the next call probes instead
+	assert.NoError(t, b.allow())
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	now := time.Now()
+	b := setupBreaker(t, &now)
+	b.record(driver.ErrBadConn)
+	b.record(&pq.Error{Code: "23505"})
+	b.record(driver.ErrBadConn)
+	assert.Equal(t, BreakerClosed, b.State())
+}
+
+func TestUnreachable(t *testing.T) {
+	for _, test := range []struct {
+		err    error
+		failed bool
+		known  bool
+	}{
+		{nil, false, true},
+		{context.Canceled, false, false},
+		{fmt.Errorf("wrapped: %w", context.DeadlineExceeded), true, true},
+		{driver.ErrBadConn, true, true},
+		{io.ErrUnexpectedEOF, true, true},
+		{&net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}, true, true},
+		{&pq.Error{Code: "08006"}, true, true},
+		{&pq.Error{Code: "57P01"}, true, true},
+		{&pq.Error{Code: "23505"}, false, true},
+		{sql.ErrNoRows, false, true},
+	} {
+		failed, known := unreachable(test.err)
+		assert.Equal(t, test.failed, failed, "%v", test.err)
+		assert.Equal(t, test.known, known, "%v", test.err)
+	}
+}
@@ -0,0 +1,199 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package db
+
+import (
+	"context"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"time"
+)
+
+// ScoringEventBatch is an IScoreDB that holds scoring events back, and inserts them together when flushed, rather than
+// with a statement each. A pull request scored again before the flush is scored against its held event, which it
+// replaces. The changes of participant scores are held back with them, so a failed flush changes no score. Everything
+// else goes straight to the wrapped IScoreDB.
+type ScoringEventBatch struct {
+	IScoreDB
+	events      []ScoringEventRow
+	index       map[scoringEventKey]int
+	scoreDeltas []ParticipantScoreDelta
+	scoreIndex  map[string]int
+}
+
+// scoringEventKey is the primary key of scoring_event
+type scoringEventKey struct {
+	campaignName, scpName, repoOwner, repoName string
+	pullRequest                                int
+}
+
+func NewScoringEventBatch(scoreDb IScoreDB) *ScoringEventBatch {
+	return &ScoringEventBatch{IScoreDB: scoreDb, index: make(map[scoringEventKey]int), scoreIndex: make(map[string]int)}
+}
+
+func newScoringEventKey(participant *types.ParticipantStruct, msg *types.ScoringMessage) scoringEventKey {
+	return scoringEventKey{
+		campaignName: participant.CampaignName,
+		scpName:      participant.ScpName,
+		repoOwner:    msg.RepoOwner,
+		repoName:     msg.RepoName,
+		pullRequest:  msg.PullRequest,
+	}
+}
+
+func (b *ScoringEventBatch) SelectPriorScore(ctx context.Context, participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64) {
+	if i, ok := b.index[newScoringEventKey(participantToScore, msg)]; ok {
+		return b.events[i].NewPoints
+	}
+	return b.IScoreDB.SelectPriorScore(ctx, participantToScore, msg)
+}
+
+func (b *ScoringEventBatch) InsertScoringEvent(ctx context.Context, participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64, breakdown *types.ScoreBreakdown) (err error) {
+	event := ScoringEventRow{Participant: *participantToScore, Msg: *msg, NewPoints: newPoints, Breakdown: breakdown}
+	key := newScoringEventKey(participantToScore, msg)
+	if i, ok := b.index[key]; ok {
+		b.events[i] = event
+		return
+	}
+	b.index[key] = len(b.events)
+	b.events = append(b.events, event)
+	return
+}
+
+// SelectScoringEventsSince adds the events held back for the participant, as scored now, to those already inserted.
+func (b *ScoringEventBatch) SelectScoringEventsSince(ctx context.Context, participant *types.ParticipantStruct, since time.Time) (events []ScoringEventRow, err error) {
+	events, err = b.IScoreDB.SelectScoringEventsSince(ctx, participant, since)
+	if err != nil {
+		return
+	}
+	scored := make(map[scoringEventKey]int, len(events))
+	for i := range events {
+		scored[newScoringEventKey(&events[i].Participant, &events[i].Msg)] = i
+	}
+	for _, held := range b.events {
+		if held.Participant.CampaignName != participant.CampaignName || held.Participant.ScpName != participant.ScpName ||
+			held.Participant.LoginName != participant.LoginName {
+			continue
+		}
+		if i, ok := scored[newScoringEventKey(&held.Participant, &held.Msg)]; ok {
+			events[i] = held
+		} else {
+			events = append(events, held)
+		}
+	}
+	return
+}
+
+// SelectDuplicateFingerprints adds the fingerprints fixed by the events of other participants held back, as fixed
+// after any inserted event, to those already inserted. A fingerprint the pull request itself fixed in an event held
+// back before theirs is not a duplicate.
+func (b *ScoringEventBatch) SelectDuplicateFingerprints(ctx context.Context, participant *types.ParticipantStruct, msg *types.ScoringMessage) (duplicates []string, err error) {
+	duplicates, err = b.IScoreDB.SelectDuplicateFingerprints(ctx, participant, msg)
+	if err != nil || len(msg.Fingerprints) == 0 {
+		return
+	}
+
+	duplicated := make(map[string]bool, len(duplicates))
+	for _, fingerprint := range duplicates {
+		duplicated[fingerprint] = true
+	}
+	own, ownHeld := b.index[newScoringEventKey(participant, msg)]
+	for i, held := range b.events {
+		if held.Participant.CampaignName != participant.CampaignName || held.Participant.ScpName != participant.ScpName ||
+			held.Msg.RepoOwner != msg.RepoOwner || held.Msg.RepoName != msg.RepoName ||
+			held.Participant.LoginName == participant.LoginName {
+			continue
+		}
+		for _, fingerprint := range held.Msg.Fingerprints {
+			if duplicated[fingerprint] || !containsString(msg.Fingerprints, fingerprint) {
+				continue
+			}
+			if ownHeld && own < i && containsString(b.events[own].Msg.Fingerprints, fingerprint) {
+				continue
+			}
+			duplicated[fingerprint] = true
+			duplicates = append(duplicates, fingerprint)
+		}
+	}
+	return
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateParticipantScore holds the change back until the flush. The score of the participant, as read before any held
+// change, is set to the score it will have once flushed.
+func (b *ScoringEventBatch) UpdateParticipantScore(ctx context.Context, participant *types.ParticipantStruct, delta float64) (err error) {
+	i, ok := b.scoreIndex[participant.ID]
+	if !ok {
+		i = len(b.scoreDeltas)
+		b.scoreIndex[participant.ID] = i
+		b.scoreDeltas = append(b.scoreDeltas, ParticipantScoreDelta{ParticipantId: participant.ID})
+	}
+	b.scoreDeltas[i].Delta += delta
+	participant.Score += b.scoreDeltas[i].Delta
+	return
+}
+
+// InsertCoAuthorScore flushes the events held back first when the event of the pull request is among them, as the
+// share of a co-author is recorded against the inserted event.
+func (b *ScoringEventBatch) InsertCoAuthorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64) (err error) {
+	if _, ok := b.index[newScoringEventKey(coAuthor, msg)]; ok {
+		if err = b.Flush(ctx); err != nil {
+			return
+		}
+	}
+	return b.IScoreDB.InsertCoAuthorScore(ctx, coAuthor, msg, newPoints)
+}
+
+// DeleteCoAuthorScore flushes the events held back first when the event of the pull request is among them, as is done
+// for InsertCoAuthorScore.
+func (b *ScoringEventBatch) DeleteCoAuthorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage) (err error) {
+	if _, ok := b.index[newScoringEventKey(coAuthor, msg)]; ok {
+		if err = b.Flush(ctx); err != nil {
+			return
+		}
+	}
+	return b.IScoreDB.DeleteCoAuthorScore(ctx, coAuthor, msg)
+}
+
+// Len is the number of events held back
+func (b *ScoringEventBatch) Len() int {
+	return len(b.events)
+}
+
+// Flush inserts the events held back, and applies the held score changes in the same transaction. Both are kept if the
+// insert fails, so it can be retried.
+func (b *ScoringEventBatch) Flush(ctx context.Context) (err error) {
+	err = b.IScoreDB.InsertScoringEvents(ctx, b.events, b.scoreDeltas)
+	if err != nil {
+		return
+	}
+	b.events = nil
+	b.index = make(map[scoringEventKey]int)
+	b.scoreDeltas = nil
+	b.scoreIndex = make(map[string]int)
+	return
+}
@@ -0,0 +1,236 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// mockScoreDB records the calls the batch passes through
+type mockScoreDB struct {
+	IScoreDB
+	priorPoints    float64
+	priorCalls     int
+	inserted       [][]ScoringEventRow
+	insertedDeltas [][]ParticipantScoreDelta
+	insertErr      error
+	scoreDeltas    []float64
+	scored         []ScoringEventRow
+	duplicates     []string
+	coAuthors      []float64
+	deletedCount   int
+}
+
+func (m *mockScoreDB) SelectScoringEventsSince(ctx context.Context, participant *types.ParticipantStruct, since time.Time) (events []ScoringEventRow, err error) {
+	return m.scored, nil
+}
+
+func (m *mockScoreDB) SelectDuplicateFingerprints(ctx context.Context, participant *types.ParticipantStruct, msg *types.ScoringMessage) (duplicates []string, err error) {
+	return m.duplicates, nil
+}
+
+func (m *mockScoreDB) InsertCoAuthorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64) (err error) {
+	m.coAuthors = append(m.coAuthors, newPoints)
+	return
+}
+
+func (m *mockScoreDB) DeleteCoAuthorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage) (err error) {
+	m.deletedCount++
+	return
+}
+
+func (m *mockScoreDB) SelectPriorScore(ctx context.Context, participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64) {
+	m.priorCalls++
+	return m.priorPoints
+}
+
+func (m *mockScoreDB) InsertScoringEvents(ctx context.Context, events []ScoringEventRow, scoreDeltas []ParticipantScoreDelta) (err error) {
+	m.inserted = append(m.inserted, events)
+	m.insertedDeltas = append(m.insertedDeltas, scoreDeltas)
+	return m.insertErr
+}
+
+func (m *mockScoreDB) UpdateParticipantScore(ctx context.Context, participant *types.ParticipantStruct, delta float64) (err error) {
+	m.scoreDeltas = append(m.scoreDeltas, delta)
+	return
+}
+
+var batchParticipant = types.ParticipantStruct{CampaignName: campaignName, ScpName: scpName, LoginName: "loginName"}
+
+func batchMsg(pullRequest int) *types.ScoringMessage {
+	return &types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", PullRequest: pullRequest}
+}
+
+func TestScoringEventBatchPriorScoreNotHeld(t *testing.T) {
+	scoreDb := &mockScoreDB{priorPoints: 3}
+	batch := NewScoringEventBatch(scoreDb)
+
+	assert.Equal(t, float64(3), batch.SelectPriorScore(context.Background(), &batchParticipant, batchMsg(1)))
+	assert.Equal(t, 1, scoreDb.priorCalls)
+}
+
+func TestScoringEventBatchPriorScoreHeld(t *testing.T) {
+	scoreDb := &mockScoreDB{priorPoints: 3}
+	batch := NewScoringEventBatch(scoreDb)
+	assert.NoError(t, batch.InsertScoringEvent(context.Background(), &batchParticipant, batchMsg(1), 7, nil))
+
+	assert.Equal(t, float64(7), batch.SelectPriorScore(context.Background(), &batchParticipant, batchMsg(1)))
+	assert.Equal(t, 0, scoreDb.priorCalls)
+}
+
+func TestScoringEventBatchReplacesHeld(t *testing.T) {
+	scoreDb := &mockScoreDB{}
+	batch := NewScoringEventBatch(scoreDb)
+	assert.NoError(t, batch.InsertScoringEvent(context.Background(), &batchParticipant, batchMsg(1), 7, nil))
+	assert.NoError(t, batch.InsertScoringEvent(context.Background(), &batchParticipant, batchMsg(2), 5, nil))
+	assert.NoError(t, batch.InsertScoringEvent(context.Background(), &batchParticipant, batchMsg(1), 9, nil))
+	assert.Equal(t, 2, batch.Len())
+
+	assert.NoError(t, batch.Flush(context.Background()))
+	assert.Equal(t, [][]ScoringEventRow{{
+		{Participant: batchParticipant, Msg: *batchMsg(1), NewPoints: 9},
+		{Participant: batchParticipant, Msg: *batchMsg(2), NewPoints: 5},
+	}}, scoreDb.inserted)
+	assert.Equal(t, 0, batch.Len())
+}
+
+func TestScoringEventBatchHoldsParticipantScore(t *testing.T) {
+	scoreDb := &mockScoreDB{}
+	batch := NewScoringEventBatch(scoreDb)
+
+	participant := types.ParticipantStruct{ID: "participantId", Score: 10}
+	assert.NoError(t, batch.UpdateParticipantScore(context.Background(), &participant, 4))
+	assert.Equal(t, float64(14), participant.Score)
+	// read again before the flush, so without the held change
+	participant.Score = 10
+	assert.NoError(t, batch.UpdateParticipantScore(context.Background(), &participant, 2))
+	assert.Equal(t, float64(16), participant.Score)
+	otherParticipant := types.ParticipantStruct{ID: "otherParticipantId"}
+	assert.NoError(t, batch.UpdateParticipantScore(context.Background(), &otherParticipant, 1))
+	assert.Nil(t, scoreDb.scoreDeltas)
+
+	assert.NoError(t, batch.Flush(context.Background()))
+	assert.Equal(t, [][]ParticipantScoreDelta{{
+		{ParticipantId: "participantId", Delta: 6},
+		{ParticipantId: "otherParticipantId", Delta: 1},
+	}}, scoreDb.insertedDeltas)
+
+	assert.NoError(t, batch.Flush(context.Background()))
+	assert.Nil(t, scoreDb.insertedDeltas[1])
+}
+
+func TestScoringEventBatchScoringEventsSince(t *testing.T) {
+	otherParticipant := types.ParticipantStruct{CampaignName: campaignName, ScpName: scpName, LoginName: "otherLoginName"}
+	scoreDb := &mockScoreDB{scored: []ScoringEventRow{
+		{Participant: batchParticipant, Msg: *batchMsg(1), NewPoints: 3},
+		{Participant: batchParticipant, Msg: *batchMsg(2), NewPoints: 4},
+	}}
+	batch := NewScoringEventBatch(scoreDb)
+	assert.NoError(t, batch.InsertScoringEvent(context.Background(), &batchParticipant, batchMsg(1), 7, nil))
+	assert.NoError(t, batch.InsertScoringEvent(context.Background(), &batchParticipant, batchMsg(3), 5, nil))
+	assert.NoError(t, batch.InsertScoringEvent(context.Background(), &otherParticipant, batchMsg(4), 6, nil))
+
+	events, err := batch.SelectScoringEventsSince(context.Background(), &batchParticipant, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, []ScoringEventRow{
+		{Participant: batchParticipant, Msg: *batchMsg(1), NewPoints: 7},
+		{Participant: batchParticipant, Msg: *batchMsg(2), NewPoints: 4},
+		{Participant: batchParticipant, Msg: *batchMsg(3), NewPoints: 5},
+	}, events)
+}
+
+func TestScoringEventBatchDuplicateFingerprints(t *testing.T) {
+	otherParticipant := types.ParticipantStruct{CampaignName: campaignName, ScpName: scpName, LoginName: "otherLoginName"}
+	scoreDb := &mockScoreDB{duplicates: []string{"fp1"}}
+	batch := NewScoringEventBatch(scoreDb)
+	otherMsg := batchMsg(2)
+	otherMsg.Fingerprints = []string{"fp2", "fp3"}
+	assert.NoError(t, batch.InsertScoringEvent(context.Background(), &otherParticipant, otherMsg, 6, nil))
+	ownMsg := batchMsg(1)
+	ownMsg.Fingerprints = []string{"fp1", "fp2", "fp4"}
+
+	duplicates, err := batch.SelectDuplicateFingerprints(context.Background(), &batchParticipant, ownMsg)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"fp1", "fp2"}, duplicates)
+
+	// the other participant fixed fp2 after the pull request held before theirs
+	assert.NoError(t, batch.Flush(context.Background()))
+	scoreDb.duplicates = nil
+	assert.NoError(t, batch.InsertScoringEvent(context.Background(), &batchParticipant, ownMsg, 3, nil))
+	assert.NoError(t, batch.InsertScoringEvent(context.Background(), &otherParticipant, otherMsg, 6, nil))
+
+	duplicates, err = batch.SelectDuplicateFingerprints(context.Background(), &batchParticipant, ownMsg)
+	assert.NoError(t, err)
+	assert.Nil(t, duplicates)
+}
+
+func TestScoringEventBatchCoAuthorScore(t *testing.T) {
+	pair := types.ParticipantStruct{CampaignName: campaignName, ScpName: scpName, LoginName: "pairLoginName"}
+	scoreDb := &mockScoreDB{}
+	batch := NewScoringEventBatch(scoreDb)
+	assert.NoError(t, batch.InsertScoringEvent(context.Background(), &batchParticipant, batchMsg(1), 2, nil))
+
+	// another pull request leaves the events held back
+	assert.NoError(t, batch.InsertCoAuthorScore(context.Background(), &pair, batchMsg(2), 1))
+	assert.Equal(t, 1, batch.Len())
+
+	// the event of the pull request is inserted before the share of its co-author
+	assert.NoError(t, batch.InsertCoAuthorScore(context.Background(), &pair, batchMsg(1), 2))
+	assert.Equal(t, 0, batch.Len())
+	assert.Equal(t, 1, len(scoreDb.inserted))
+	assert.Equal(t, []float64{1, 2}, scoreDb.coAuthors)
+}
+
+func TestScoringEventBatchDeleteCoAuthorScore(t *testing.T) {
+	pair := types.ParticipantStruct{CampaignName: campaignName, ScpName: scpName, LoginName: "pairLoginName"}
+	scoreDb := &mockScoreDB{}
+	batch := NewScoringEventBatch(scoreDb)
+	assert.NoError(t, batch.InsertScoringEvent(context.Background(), &batchParticipant, batchMsg(1), 2, nil))
+
+	assert.NoError(t, batch.DeleteCoAuthorScore(context.Background(), &pair, batchMsg(2)))
+	assert.Equal(t, 1, batch.Len())
+
+	assert.NoError(t, batch.DeleteCoAuthorScore(context.Background(), &pair, batchMsg(1)))
+	assert.Equal(t, 0, batch.Len())
+	assert.Equal(t, 1, len(scoreDb.inserted))
+	assert.Equal(t, 2, scoreDb.deletedCount)
+}
+
+func TestScoringEventBatchFlushError(t *testing.T) {
+	forcedError := fmt.Errorf("forced flush error")
+	scoreDb := &mockScoreDB{insertErr: forcedError}
+	batch := NewScoringEventBatch(scoreDb)
+	assert.NoError(t, batch.InsertScoringEvent(context.Background(), &batchParticipant, batchMsg(1), 7, nil))
+	assert.NoError(t, batch.UpdateParticipantScore(context.Background(), &types.ParticipantStruct{ID: "participantId"}, 7))
+
+	assert.EqualError(t, batch.Flush(context.Background()), forcedError.Error())
+	// kept, to retry
+	assert.Equal(t, 1, batch.Len())
+	assert.Equal(t, float64(7), batch.SelectPriorScore(context.Background(), &batchParticipant, batchMsg(1)))
+
+	scoreDb.insertErr = nil
+	assert.NoError(t, batch.Flush(context.Background()))
+	assert.Equal(t, []ParticipantScoreDelta{{ParticipantId: "participantId", Delta: 7}}, scoreDb.insertedDeltas[1])
+}
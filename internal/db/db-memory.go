@@ -0,0 +1,4584 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+)
+
+// MemoryDB keeps the data in memory, for demos and as a test double. Nothing is kept once the process ends. Each call
+// holds one lock, so a call is atomic like a transaction of the postgres implementation.
+//
+// Once Record is called, every call is recorded for tests to inspect with Calls, and FailWith makes a method fail.
+type MemoryDB struct {
+	logger *zap.Logger
+
+	mu        sync.Mutex
+	recording bool
+	calls     []MemoryCall
+	failures  map[string]error
+
+	scps                []types.SourceControlProviderStruct
+	tenants             []memoryTenant
+	campaigns           []*memoryCampaign
+	organizations       []types.OrganizationStruct
+	teams               []*memoryTeam
+	participants        []*memoryParticipant
+	bans                []types.BanStruct
+	bugs                []*memoryBug
+	pointValueOverrides []memoryPointValueOverride
+	bugExclusions       []types.BugCategoryExclusion
+	repositoryFilters   []types.RepositoryFilter
+	scoringEvents       []*memoryScoringEvent
+	scoringFlags        []*types.ScoringFlag
+	teamScoreEvents     []memoryTeamScoreEvent
+	configStages        map[string]types.CampaignConfigStruct
+	slackNotifications  map[string]types.SlackNotificationStruct
+	penalties           map[string]types.CampaignPenaltyStruct
+	caps                map[string]types.CampaignCapsStruct
+	duplicatePolicies   map[string]types.DuplicatePolicyStruct
+	coAuthorPolicies    map[string]types.CoAuthorPolicyStruct
+	privacies           map[string]types.CampaignPrivacyStruct
+	archives            map[string]*memoryArchive
+	scheduledJobRuns    map[string]types.ScheduledJobRun
+	maintenance         types.MaintenanceState
+	jobs                map[string]*types.Job
+	emails              []types.CampaignEmailStruct
+	achievements        []memoryAchievement
+	webhooks            []types.WebhookStruct
+	notifications       []types.NotificationStruct
+	reports             map[string]memoryReport
+	telemetry           []memoryTelemetryEvent
+	auditLog            []types.AuditLogEntry
+	clusterInstances    []types.ClusterInstance
+	// pollLeaseHolder holds the lease on the datadog poll until pollLeaseExpiresOn
+	pollLeaseHolder    string
+	pollLeaseExpiresOn time.Time
+
+	// ranks are the leaderboard as of the last refresh. The leaderboard is stale once changeCount moves past
+	// rankedChangeCount.
+	ranks             []memoryRank
+	refreshedOn       time.Time
+	changeCount       int
+	rankedChangeCount int
+	snapshots         map[string]types.LeaderboardSnapshot
+	// fixedCount orders the fingerprints of the scoring events as they are first fixed
+	fixedCount int
+}
+
+var _ IBBashDB = (*MemoryDB)(nil)
+
+// MemoryCall is a recorded call of a MemoryDB method, with the arguments after the context.
+type MemoryCall struct {
+	Method string
+	Args   []interface{}
+}
+
+type memoryTenant struct {
+	types.TenantStruct
+	passwordHash string
+}
+
+type memoryCampaign struct {
+	types.CampaignStruct
+	tenantName    string
+	updatedOn     time.Time
+	endedNotified bool
+}
+
+type memoryTeam struct {
+	types.TeamStruct
+	updatedOn time.Time
+}
+
+type memoryParticipant struct {
+	types.ParticipantStruct
+	teamId             string
+	updatedOn          time.Time
+	profileRefreshedOn *time.Time
+}
+
+type memoryBug struct {
+	types.BugStruct
+	updatedOn time.Time
+}
+
+type memoryPointValueOverride struct {
+	types.PointValueOverride
+	organizationId string
+}
+
+type memoryScoringEvent struct {
+	types.ScoringEventStruct
+	scoredOn time.Time
+	// fingerprints are the fixed bugs of the event, with the order each was first fixed in across every event
+	fingerprints map[string]int
+	// coAuthors are the points each co-author scored for the pull request, by login
+	coAuthors map[string]float64
+	// revisions are every score the event, and each share of a co-author, has had, as the postgres triggers keep them
+	revisions []memoryRevision
+}
+
+// memoryRevision is the score of a scoring event, or the share of one of its co-authors, from revisedOn on
+type memoryRevision struct {
+	loginName string
+	coAuthor  bool
+	points    float64
+	voided    bool
+	revisedOn time.Time
+}
+
+func (e *memoryScoringEvent) revise(loginName string, coAuthor bool, points float64, voided bool, revisedOn time.Time) {
+	e.revisions = append(e.revisions, memoryRevision{loginName: loginName, coAuthor: coAuthor, points: points,
+		voided: voided, revisedOn: revisedOn})
+}
+
+// reviseInserted revises an event inserted with the score, void and shares it had before, such as an imported one
+func (e *memoryScoringEvent) reviseInserted() {
+	e.revise(e.UserName, false, e.Points, false, e.scoredOn)
+	if e.VoidedOn != nil {
+		e.revise(e.UserName, false, 0, true, e.VoidedOn.UTC())
+	}
+	loginNames := make([]string, 0, len(e.coAuthors))
+	for loginName := range e.coAuthors {
+		loginNames = append(loginNames, loginName)
+	}
+	sort.Strings(loginNames)
+	for _, loginName := range loginNames {
+		e.revise(loginName, true, e.coAuthors[loginName], false, e.scoredOn)
+	}
+}
+
+// memoryArchive is an archive of scoring events, with the events gzipped as in postgres
+type memoryArchive struct {
+	types.ScoringEventArchive
+	events []byte
+}
+
+type memoryTeamScoreEvent struct {
+	types.TeamScoreEvent
+	teamId string
+}
+
+type memoryAchievement struct {
+	types.AchievementStruct
+	participantId string
+}
+
+type memoryReport struct {
+	reportJson []byte
+	html       string
+}
+
+type memoryTelemetryEvent struct {
+	feature   string
+	createdOn time.Time
+}
+
+type memoryRank struct {
+	types.LeaderboardEntry
+	campaignName string
+}
+
+// memoryEpoch is the UpdatedOn of an empty list, as for postgres
+var memoryEpoch = time.Unix(0, 0).UTC()
+
+// NewMemory makes an empty in memory database, with the source control providers the postgres migrations add.
+func NewMemory(logger *zap.Logger) *MemoryDB {
+	return &MemoryDB{
+		logger: logger,
+		scps: []types.SourceControlProviderStruct{
+			{ID: "6f1d8ee2-6a5d-4b5e-9c1a-3e0e4d1f6a01", SCPName: "GitHub", Url: "https://github.com"},
+			{ID: "6f1d8ee2-6a5d-4b5e-9c1a-3e0e4d1f6a02", SCPName: "GitLab", Url: "https://gitlab.com"},
+			{ID: "6f1d8ee2-6a5d-4b5e-9c1a-3e0e4d1f6a03", SCPName: "Bitbucket", Url: "https://bitbucket.org/"},
+		},
+		failures:           map[string]error{},
+		configStages:       map[string]types.CampaignConfigStruct{},
+		slackNotifications: map[string]types.SlackNotificationStruct{},
+		penalties:          map[string]types.CampaignPenaltyStruct{},
+		caps:               map[string]types.CampaignCapsStruct{},
+		duplicatePolicies:  map[string]types.DuplicatePolicyStruct{},
+		coAuthorPolicies:   map[string]types.CoAuthorPolicyStruct{},
+		privacies:          map[string]types.CampaignPrivacyStruct{},
+		archives:           map[string]*memoryArchive{},
+		scheduledJobRuns:   map[string]types.ScheduledJobRun{},
+		jobs:               map[string]*types.Job{},
+		reports:            map[string]memoryReport{},
+		snapshots:          map[string]types.LeaderboardSnapshot{},
+	}
+}
+
+// Record starts recording the calls, forgetting any recorded before.
+func (m *MemoryDB) Record() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.recording = true
+	m.calls = nil
+}
+
+// Calls returns the recorded calls of the method, or of every method when method is empty.
+func (m *MemoryDB) Calls(method string) (calls []MemoryCall) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, call := range m.calls {
+		if method == "" || call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return
+}
+
+// FailWith makes the later calls of the method return err, changing nothing. A nil err lets them succeed again. The
+// methods that return no error are not affected.
+func (m *MemoryDB) FailWith(method string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err == nil {
+		delete(m.failures, method)
+	} else {
+		m.failures[method] = err
+	}
+}
+
+// record notes the call, and returns the error the method is to fail with. The caller holds the lock.
+func (m *MemoryDB) record(method string, args ...interface{}) error {
+	if m.recording {
+		m.calls = append(m.calls, MemoryCall{Method: method, Args: args})
+	}
+	return m.failures[method]
+}
+
+// changed makes the leaderboard stale
+func (m *MemoryDB) changed() {
+	m.changeCount++
+}
+
+func (m *MemoryDB) GetDb() (db *sql.DB) {
+	return
+}
+
+func (m *MemoryDB) MigrateDB() (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.record("MigrateDB")
+}
+
+// SelectMigrationStatus reports version 0, as the memory database has no migrations.
+func (m *MemoryDB) SelectMigrationStatus(ctx context.Context) (status types.MigrationStatus, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err = m.record("SelectMigrationStatus")
+	return
+}
+
+func (m *MemoryDB) RollbackMigrations(steps int) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("RollbackMigrations", steps); err != nil {
+		return
+	}
+	return fmt.Errorf("the memory database has no migrations to roll back")
+}
+
+func (m *MemoryDB) GetSourceControlProviders(ctx context.Context) (scps []types.SourceControlProviderStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("GetSourceControlProviders"); err != nil {
+		return
+	}
+	scps = append(scps, m.scps...)
+	return
+}
+
+// scp finds the source control provider by name, ignoring case when fold is set.
+func (m *MemoryDB) scp(name string, fold bool) (scp types.SourceControlProviderStruct, ok bool) {
+	for _, scp = range m.scps {
+		if scp.SCPName == name || (fold && strings.EqualFold(scp.SCPName, name)) {
+			return scp, true
+		}
+	}
+	return types.SourceControlProviderStruct{}, false
+}
+
+func (m *MemoryDB) campaign(name string) *memoryCampaign {
+	for _, campaign := range m.campaigns {
+		if campaign.Name == name {
+			return campaign
+		}
+	}
+	return nil
+}
+
+// memoryNoCampaign is the error of a write to a campaign that does not exist, where postgres fails on the foreign key.
+func memoryNoCampaign(name string) error {
+	return fmt.Errorf("no campaign: name: %s", name)
+}
+
+// copyCampaign copies the campaign, and the values its pointers point to.
+func copyCampaign(campaign types.CampaignStruct) types.CampaignStruct {
+	if campaign.UnclassifiedBonus != nil {
+		bonus := *campaign.UnclassifiedBonus
+		campaign.UnclassifiedBonus = &bonus
+	}
+	if campaign.FreezeScoring != nil {
+		freeze := *campaign.FreezeScoring
+		campaign.FreezeScoring = &freeze
+	}
+	if campaign.MaxTeamSize != nil {
+		maxTeamSize := *campaign.MaxTeamSize
+		campaign.MaxTeamSize = &maxTeamSize
+	}
+	campaign.RegistrationOpensOn = copyTime(campaign.RegistrationOpensOn)
+	campaign.RegistrationClosesOn = copyTime(campaign.RegistrationClosesOn)
+	return campaign
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	copied := *t
+	return &copied
+}
+
+func (m *MemoryDB) InsertCampaign(ctx context.Context, campaign *types.CampaignStruct) (guid string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertCampaign", campaign); err != nil {
+		return
+	}
+	return m.insertCampaign(campaign)
+}
+
+func (m *MemoryDB) insertCampaign(campaign *types.CampaignStruct) (guid string, err error) {
+	if m.campaign(campaign.Name) != nil {
+		err = &DuplicateError{Entity: "campaign"}
+		return
+	}
+
+	now := time.Now().UTC()
+	inserted := &memoryCampaign{CampaignStruct: copyCampaign(*campaign), updatedOn: now}
+	inserted.ID = newId()
+	inserted.CreatedOn = now
+	inserted.CreatedOrder = len(m.campaigns) + 1
+	inserted.Status = types.CampaignStatusUpcoming
+	inserted.Version = 1
+	inserted.TenantName = ""
+	if inserted.TieBreaker == "" {
+		inserted.TieBreaker = types.TieBreakerReachedScore
+	}
+	if inserted.UnclassifiedBonus == nil {
+		bonus := types.DefaultUnclassifiedBonus
+		inserted.UnclassifiedBonus = &bonus
+	}
+	if inserted.FreezeScoring == nil {
+		freeze := false
+		inserted.FreezeScoring = &freeze
+	}
+	if inserted.MaxTeamSize == nil {
+		maxTeamSize := 0
+		inserted.MaxTeamSize = &maxTeamSize
+	}
+	if inserted.TimeZone == "" {
+		inserted.TimeZone = types.DefaultTimeZone
+	}
+	for _, tenant := range m.tenants {
+		if campaign.TenantName != "" && tenant.Name == campaign.TenantName {
+			inserted.tenantName = tenant.Name
+		}
+	}
+	m.campaigns = append(m.campaigns, inserted)
+	m.changed()
+	guid = inserted.ID
+	return
+}
+
+// UpdateCampaign changes the campaign. sql.ErrNoRows is returned when there is no such campaign.
+func (m *MemoryDB) UpdateCampaign(ctx context.Context, campaign *types.CampaignStruct) (guid string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpdateCampaign", campaign); err != nil {
+		return
+	}
+	existing := m.campaign(campaign.Name)
+	if existing == nil {
+		err = sql.ErrNoRows
+		return
+	}
+	if campaign.Version != existing.Version {
+		err = &StaleError{Entity: "campaign", Version: existing.Version}
+		return
+	}
+
+	updated := copyCampaign(*campaign)
+	existing.StartOn = updated.StartOn
+	existing.EndOn = updated.EndOn
+	if updated.MaxTeamSize != nil {
+		existing.MaxTeamSize = updated.MaxTeamSize
+	}
+	if updated.TieBreaker != "" {
+		existing.TieBreaker = updated.TieBreaker
+	}
+	if updated.UnclassifiedBonus != nil {
+		existing.UnclassifiedBonus = updated.UnclassifiedBonus
+	}
+	if updated.TimeZone != "" {
+		existing.TimeZone = updated.TimeZone
+	}
+	if updated.FreezeScoring != nil {
+		existing.FreezeScoring = updated.FreezeScoring
+	}
+	if updated.RegistrationOpensOn != nil {
+		existing.RegistrationOpensOn = updated.RegistrationOpensOn
+	}
+	if updated.RegistrationClosesOn != nil {
+		existing.RegistrationClosesOn = updated.RegistrationClosesOn
+	}
+	existing.Version++
+	campaign.Version = existing.Version
+	existing.updatedOn = time.Now().UTC()
+	m.changed()
+	guid = existing.ID
+	return
+}
+
+func (m *MemoryDB) GetCampaign(ctx context.Context, campaignName string) (campaign *types.CampaignStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("GetCampaign", campaignName); err != nil {
+		return
+	}
+	existing := m.campaign(campaignName)
+	if existing == nil {
+		err = sql.ErrNoRows
+		return
+	}
+	found := copyCampaign(existing.CampaignStruct)
+	campaign = &found
+	return
+}
+
+// selectCampaigns copies the campaigns that match, in the order they were added.
+func (m *MemoryDB) selectCampaigns(match func(campaign *memoryCampaign) bool) (campaigns []types.CampaignStruct) {
+	for _, campaign := range m.campaigns {
+		if match(campaign) {
+			campaigns = append(campaigns, copyCampaign(campaign.CampaignStruct))
+		}
+	}
+	return
+}
+
+func (m *MemoryDB) GetCampaigns(ctx context.Context) (campaigns []types.CampaignStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("GetCampaigns"); err != nil {
+		return
+	}
+	campaigns = m.selectCampaigns(func(campaign *memoryCampaign) bool {
+		return true
+	})
+	return
+}
+
+func (m *MemoryDB) SelectPublicCampaigns(ctx context.Context) (campaigns []types.CampaignStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectPublicCampaigns"); err != nil {
+		return
+	}
+	campaigns = m.selectCampaigns(func(campaign *memoryCampaign) bool {
+		return campaign.tenantName == ""
+	})
+	sort.Slice(campaigns, func(i, j int) bool {
+		return campaigns[i].CreatedOrder < campaigns[j].CreatedOrder
+	})
+	return
+}
+
+func (m *MemoryDB) SelectCampaignsVersion(ctx context.Context) (version types.ListVersion, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectCampaignsVersion"); err != nil {
+		return
+	}
+	version = types.ListVersion{Count: len(m.campaigns), UpdatedOn: memoryEpoch}
+	for _, campaign := range m.campaigns {
+		version.UpdatedOn = latest(version.UpdatedOn, campaign.updatedOn)
+	}
+	return
+}
+
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+func sortCampaignsByStart(campaigns []types.CampaignStruct) {
+	sort.SliceStable(campaigns, func(i, j int) bool {
+		return campaigns[i].StartOn.Before(campaigns[j].StartOn)
+	})
+}
+
+func (m *MemoryDB) GetActiveCampaigns(ctx context.Context, now time.Time) (activeCampaigns []types.CampaignStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("GetActiveCampaigns", now); err != nil {
+		return
+	}
+	activeCampaigns = m.selectCampaigns(func(campaign *memoryCampaign) bool {
+		return campaign.active(now)
+	})
+	sortCampaignsByStart(activeCampaigns)
+	return
+}
+
+func (c *memoryCampaign) active(now time.Time) bool {
+	return !now.Before(c.StartOn) && now.Before(c.EndOn)
+}
+
+// scoring is whether the campaign scores at now, which it stops doing once it ends, unless scoring is frozen at the
+// end.
+func (c *memoryCampaign) scoring(now time.Time) bool {
+	return c.active(now) && !(*c.FreezeScoring && c.Status == types.CampaignStatusEnded)
+}
+
+func (m *MemoryDB) GetUpcomingCampaigns(ctx context.Context, now time.Time) (upcomingCampaigns []types.CampaignStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("GetUpcomingCampaigns", now); err != nil {
+		return
+	}
+	upcomingCampaigns = m.selectCampaigns(func(campaign *memoryCampaign) bool {
+		return now.Before(campaign.StartOn)
+	})
+	sortCampaignsByStart(upcomingCampaigns)
+	return
+}
+
+func (m *MemoryDB) SelectCampaignTenant(ctx context.Context, campaignName string) (tenantName string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectCampaignTenant", campaignName); err != nil {
+		return
+	}
+	campaign := m.campaign(campaignName)
+	if campaign == nil {
+		err = sql.ErrNoRows
+		return
+	}
+	tenantName = campaign.tenantName
+	return
+}
+
+func (m *MemoryDB) SelectRegistrationWindow(ctx context.Context, campaignName string) (opensOn, closesOn *time.Time, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectRegistrationWindow", campaignName); err != nil {
+		return
+	}
+	campaign := m.campaign(campaignName)
+	if campaign == nil {
+		err = sql.ErrNoRows
+		return
+	}
+	opensOn, closesOn = copyTime(campaign.RegistrationOpensOn), copyTime(campaign.RegistrationClosesOn)
+	return
+}
+
+func (m *MemoryDB) InsertTenant(ctx context.Context, tenant *types.TenantStruct, passwordHash string) (guid string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertTenant", tenant, passwordHash); err != nil {
+		return
+	}
+	for _, existing := range m.tenants {
+		if existing.Name == tenant.Name {
+			err = &DuplicateError{Entity: "tenant"}
+			return
+		}
+	}
+
+	tenant.ID, tenant.CreatedOn = newId(), time.Now().UTC()
+	inserted := memoryTenant{TenantStruct: *tenant, passwordHash: passwordHash}
+	inserted.AdminPassword = ""
+	m.tenants = append(m.tenants, inserted)
+	guid = tenant.ID
+	return
+}
+
+func (m *MemoryDB) SelectTenants(ctx context.Context) (tenants []types.TenantStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectTenants"); err != nil {
+		return
+	}
+	for _, tenant := range m.tenants {
+		tenants = append(tenants, tenant.TenantStruct)
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].Name < tenants[j].Name
+	})
+	return
+}
+
+func (m *MemoryDB) SelectTenantAdmin(ctx context.Context, tenantName string) (username, passwordHash string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectTenantAdmin", tenantName); err != nil {
+		return
+	}
+	for _, tenant := range m.tenants {
+		if tenant.Name == tenantName {
+			return tenant.AdminUsername, tenant.passwordHash, nil
+		}
+	}
+	err = sql.ErrNoRows
+	return
+}
+
+func (m *MemoryDB) SelectTenantCampaigns(ctx context.Context, tenantName string) (campaigns []types.CampaignStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectTenantCampaigns", tenantName); err != nil {
+		return
+	}
+	for _, campaign := range m.campaigns {
+		if campaign.tenantName != "" && campaign.tenantName == tenantName {
+			tenantCampaign := copyCampaign(campaign.CampaignStruct)
+			tenantCampaign.TenantName = tenantName
+			campaigns = append(campaigns, tenantCampaign)
+		}
+	}
+	return
+}
+
+// organizationCampaign is the campaign an organization is limited to. A campaign that does not exist leaves the
+// organization unlimited, as it does for postgres.
+func (m *MemoryDB) organizationCampaign(campaignName string) string {
+	if m.campaign(campaignName) == nil {
+		return ""
+	}
+	return campaignName
+}
+
+func (m *MemoryDB) InsertOrganization(ctx context.Context, organization *types.OrganizationStruct) (guid string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertOrganization", organization); err != nil {
+		return
+	}
+	return m.insertOrganization(organization)
+}
+
+func (m *MemoryDB) insertOrganization(organization *types.OrganizationStruct) (guid string, err error) {
+	scp, ok := m.scp(organization.SCPName, true)
+	if !ok {
+		err = fmt.Errorf("no source control provider: name: %s", organization.SCPName)
+		return
+	}
+	inserted := types.OrganizationStruct{
+		ID:           newId(),
+		SCPName:      scp.SCPName,
+		Organization: strings.ToLower(organization.Organization),
+		CampaignName: m.organizationCampaign(organization.CampaignName),
+	}
+	for _, existing := range m.organizations {
+		if existing.SCPName == inserted.SCPName && existing.Organization == inserted.Organization &&
+			existing.CampaignName == inserted.CampaignName {
+			err = &DuplicateError{Entity: "organization"}
+			return
+		}
+	}
+	m.organizations = append(m.organizations, inserted)
+	guid = inserted.ID
+	return
+}
+
+func (m *MemoryDB) GetOrganizations(ctx context.Context) (organizations []types.OrganizationStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("GetOrganizations"); err != nil {
+		return
+	}
+	organizations = append(organizations, m.organizations...)
+	return
+}
+
+func (m *MemoryDB) DeleteOrganization(ctx context.Context, scpName, orgName, campaignName string) (rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("DeleteOrganization", scpName, orgName, campaignName); err != nil {
+		return
+	}
+	campaignName = m.organizationCampaign(campaignName)
+	kept := m.organizations[:0]
+	for _, organization := range m.organizations {
+		if strings.EqualFold(organization.SCPName, scpName) && strings.EqualFold(organization.Organization, orgName) &&
+			organization.CampaignName == campaignName {
+			m.deletePointValueOverrides(organization.ID)
+			rowsAffected++
+		} else {
+			kept = append(kept, organization)
+		}
+	}
+	m.organizations = kept
+	return
+}
+
+func (m *MemoryDB) deletePointValueOverrides(organizationId string) {
+	kept := m.pointValueOverrides[:0]
+	for _, override := range m.pointValueOverrides {
+		if override.organizationId != organizationId {
+			kept = append(kept, override)
+		}
+	}
+	m.pointValueOverrides = kept
+}
+
+func (m *MemoryDB) UpdateOrganization(ctx context.Context, scpName, orgName string, update *types.OrganizationUpdate) (rowsAffected, eventsRelinked int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpdateOrganization", scpName, orgName, update); err != nil {
+		return
+	}
+	renamed := strings.ToLower(update.Organization)
+	var toRename []int
+	for i, organization := range m.organizations {
+		if strings.EqualFold(organization.SCPName, scpName) && strings.EqualFold(organization.Organization, orgName) {
+			toRename = append(toRename, i)
+		}
+	}
+	for _, i := range toRename {
+		for j, other := range m.organizations {
+			if j != i && other.SCPName == m.organizations[i].SCPName && other.Organization == renamed &&
+				other.CampaignName == m.organizations[i].CampaignName {
+				err = &DuplicateError{Entity: "organization"}
+				return
+			}
+		}
+	}
+
+	var toRelink []*memoryScoringEvent
+	if len(toRename) > 0 && update.RelinkScores {
+		for _, event := range m.scoringEvents {
+			if strings.EqualFold(event.ScpName, scpName) && strings.EqualFold(event.RepoOwner, orgName) {
+				toRelink = append(toRelink, event)
+			}
+		}
+		for _, event := range toRelink {
+			other := m.scoringEvent(event.CampaignName, event.ScpName, update.Organization, event.RepoName, event.PullRequest)
+			if other != nil && other != event {
+				err = &DuplicateError{Entity: "scoring event"}
+				return
+			}
+		}
+	}
+
+	for _, i := range toRename {
+		m.organizations[i].Organization = renamed
+	}
+	for _, event := range toRelink {
+		event.RepoOwner = update.Organization
+	}
+	rowsAffected, eventsRelinked = int64(len(toRename)), int64(len(toRelink))
+	return
+}
+
+// organizationScores is whether an organization of the scp (ignoring case) scores the campaign
+func (m *MemoryDB) organizationScores(scpName, orgName string, campaign *memoryCampaign) bool {
+	for _, organization := range m.organizations {
+		if strings.EqualFold(organization.SCPName, scpName) && strings.EqualFold(organization.Organization, orgName) &&
+			(organization.CampaignName == "" || organization.CampaignName == campaign.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidOrganization checks the organization of the message is scored by any campaign active at now.
+func (m *MemoryDB) ValidOrganization(ctx context.Context, msg *types.ScoringMessage, now time.Time) (orgExists bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("ValidOrganization", msg, now); err != nil {
+		return
+	}
+	for _, organization := range m.organizations {
+		if !strings.EqualFold(organization.SCPName, msg.EventSource) || !strings.EqualFold(organization.Organization, msg.RepoOwner) {
+			continue
+		}
+		if organization.CampaignName == "" {
+			return true, nil
+		}
+		if campaign := m.campaign(organization.CampaignName); campaign != nil && campaign.scoring(now) {
+			return true, nil
+		}
+	}
+	return
+}
+
+func (m *MemoryDB) SelectParticipantsToScore(ctx context.Context, msg *types.ScoringMessage, now time.Time) (participantsToScore []types.ParticipantStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectParticipantsToScore", msg, now); err != nil {
+		return
+	}
+	for _, participant := range m.participants {
+		campaign := m.campaign(participant.CampaignName)
+		if participant.LoginName == msg.TriggerUser && strings.EqualFold(participant.ScpName, msg.EventSource) &&
+			campaign.scoring(now) && m.organizationScores(participant.ScpName, msg.RepoOwner, campaign) {
+			toScore := m.participantStruct(participant)
+			participantsToScore = append(participantsToScore, types.ParticipantStruct{
+				ID:           toScore.ID,
+				CampaignName: toScore.CampaignName,
+				ScpName:      toScore.ScpName,
+				LoginName:    toScore.LoginName,
+				TeamName:     toScore.TeamName,
+			})
+		}
+	}
+	return
+}
+
+// SelectPointValue is the point value of the bug type in the campaign, preferring an override of the organization of
+// the repository. It is 1 when neither is set.
+func (m *MemoryDB) SelectPointValue(ctx context.Context, msg *types.ScoringMessage, campaignName, bugType string) (pointValue float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_ = m.record("SelectPointValue", msg, campaignName, bugType)
+	for _, override := range m.pointValueOverrides {
+		organization := m.organizationById(override.organizationId)
+		if override.CampaignName == campaignName && override.Category == bugType &&
+			strings.EqualFold(organization.SCPName, msg.EventSource) && strings.EqualFold(organization.Organization, msg.RepoOwner) {
+			return override.PointValue
+		}
+	}
+	for _, bug := range m.bugs {
+		if bug.Campaign == campaignName && bug.Category == bugType {
+			return bug.PointValue
+		}
+	}
+	return 1
+}
+
+func (m *MemoryDB) participantById(id string) *memoryParticipant {
+	for _, participant := range m.participants {
+		if participant.ID == id {
+			return participant
+		}
+	}
+	return nil
+}
+
+func (m *MemoryDB) participant(campaignName, scpName, loginName string) *memoryParticipant {
+	for _, participant := range m.participants {
+		if participant.CampaignName == campaignName && participant.ScpName == scpName && participant.LoginName == loginName {
+			return participant
+		}
+	}
+	return nil
+}
+
+// participantStruct copies the participant, with the name of its team.
+func (m *MemoryDB) participantStruct(participant *memoryParticipant) (copied types.ParticipantStruct) {
+	copied = participant.ParticipantStruct
+	copied.TeamName = ""
+	if team := m.teamById(participant.teamId); team != nil {
+		copied.TeamName = team.Name
+	}
+	return
+}
+
+func (m *MemoryDB) UpdateParticipantScore(ctx context.Context, participant *types.ParticipantStruct, delta float64) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpdateParticipantScore", participant, delta); err != nil {
+		return
+	}
+	existing := m.participantById(participant.ID)
+	if existing == nil {
+		err = sql.ErrNoRows
+		return
+	}
+	existing.Score += delta
+	existing.updatedOn = time.Now().UTC()
+	m.changed()
+	participant.Score = existing.Score
+	return
+}
+
+func (m *MemoryDB) scoringEvent(campaignName, scpName, repoOwner, repoName string, pullRequest int) *memoryScoringEvent {
+	for _, event := range m.scoringEvents {
+		if event.CampaignName == campaignName && event.ScpName == scpName && event.RepoOwner == repoOwner &&
+			event.RepoName == repoName && event.PullRequest == pullRequest {
+			return event
+		}
+	}
+	return nil
+}
+
+func (m *MemoryDB) SelectPriorScore(ctx context.Context, participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_ = m.record("SelectPriorScore", participantToScore, msg)
+	event := m.scoringEvent(participantToScore.CampaignName, participantToScore.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest)
+	if event != nil && event.VoidedOn == nil {
+		oldPoints = event.Points
+	}
+	return
+}
+
+func (m *MemoryDB) SelectCoAuthorPriorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_ = m.record("SelectCoAuthorPriorScore", coAuthor, msg)
+	event := m.scoringEvent(coAuthor.CampaignName, coAuthor.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest)
+	if event != nil && event.VoidedOn == nil {
+		oldPoints = event.coAuthors[coAuthor.LoginName]
+	}
+	return
+}
+
+// InsertCoAuthorScore records the points the co-author scored for the pull request, which must already have its
+// scoring event.
+func (m *MemoryDB) InsertCoAuthorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertCoAuthorScore", coAuthor, msg, newPoints); err != nil {
+		return
+	}
+	event := m.scoringEvent(coAuthor.CampaignName, coAuthor.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest)
+	if event == nil {
+		return
+	}
+	if event.coAuthors == nil {
+		event.coAuthors = map[string]float64{}
+	}
+	event.coAuthors[coAuthor.LoginName] = newPoints
+	event.revise(coAuthor.LoginName, true, newPoints, false, event.scoredOn)
+	m.changed()
+	return
+}
+
+// SelectCoAuthorScores reads the shares the co-authors scored by an earlier scoring of the pull request, with the
+// participants they are. A co-author no longer in the campaign is left out, as there is no score to correct.
+func (m *MemoryDB) SelectCoAuthorScores(ctx context.Context, participant *types.ParticipantStruct, msg *types.ScoringMessage) (coAuthors []CoAuthorScoreRow, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectCoAuthorScores", participant, msg); err != nil {
+		return
+	}
+	event := m.scoringEvent(participant.CampaignName, participant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest)
+	if event == nil || event.VoidedOn != nil {
+		return
+	}
+	for loginName, points := range event.coAuthors {
+		if coAuthor := m.participant(participant.CampaignName, participant.ScpName, loginName); coAuthor != nil {
+			copied := m.participantStruct(coAuthor)
+			coAuthors = append(coAuthors, CoAuthorScoreRow{
+				Participant: types.ParticipantStruct{ID: copied.ID, CampaignName: copied.CampaignName, ScpName: copied.ScpName,
+					LoginName: copied.LoginName, TeamName: copied.TeamName},
+				Points: points,
+			})
+		}
+	}
+	sort.Slice(coAuthors, func(i, j int) bool {
+		return coAuthors[i].Participant.LoginName < coAuthors[j].Participant.LoginName
+	})
+	return
+}
+
+// DeleteCoAuthorScore removes the share of a co-author who is no longer one of the pull request.
+func (m *MemoryDB) DeleteCoAuthorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("DeleteCoAuthorScore", coAuthor, msg); err != nil {
+		return
+	}
+	event := m.scoringEvent(coAuthor.CampaignName, coAuthor.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest)
+	if event == nil {
+		return
+	}
+	if _, ok := event.coAuthors[coAuthor.LoginName]; ok {
+		delete(event.coAuthors, coAuthor.LoginName)
+		event.revise(coAuthor.LoginName, true, 0, false, time.Now().UTC())
+		m.changed()
+	}
+	return
+}
+
+// SelectScoringEventsSince reads the scoring events of the participant scored since the time, e.g. the start of a day,
+// with the shares of the pull requests they co-authored as events of their own.
+func (m *MemoryDB) SelectScoringEventsSince(ctx context.Context, participant *types.ParticipantStruct, since time.Time) (events []ScoringEventRow, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectScoringEventsSince", participant, since); err != nil {
+		return
+	}
+	for _, event := range m.scoringEvents {
+		if event.CampaignName == participant.CampaignName && event.ScpName == participant.ScpName &&
+			event.UserName == participant.LoginName && !event.scoredOn.Before(since) && event.VoidedOn == nil {
+			events = append(events, ScoringEventRow{
+				Participant: *participant,
+				Msg: types.ScoringMessage{
+					RepoOwner:   event.RepoOwner,
+					RepoName:    event.RepoName,
+					PullRequest: event.PullRequest,
+					TriggerUser: event.UserName,
+				},
+				NewPoints: event.Points,
+			})
+		}
+		if points, ok := event.coAuthors[participant.LoginName]; ok && event.CampaignName == participant.CampaignName &&
+			event.ScpName == participant.ScpName && !event.scoredOn.Before(since) && event.VoidedOn == nil {
+			events = append(events, ScoringEventRow{
+				Participant: *participant,
+				Msg: types.ScoringMessage{
+					RepoOwner:   event.RepoOwner,
+					RepoName:    event.RepoName,
+					PullRequest: event.PullRequest,
+					TriggerUser: event.UserName,
+				},
+				NewPoints: points,
+			})
+		}
+	}
+	return
+}
+
+func (m *MemoryDB) InsertScoringEvent(ctx context.Context, participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64, breakdown *types.ScoreBreakdown) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertScoringEvent", participantToScore, msg, newPoints, breakdown); err != nil {
+		return
+	}
+	return m.upsertScoringEvents([]ScoringEventRow{
+		{Participant: *participantToScore, Msg: *msg, NewPoints: newPoints, Breakdown: breakdown},
+	})
+}
+
+// InsertScoringEvents inserts the events and applies the score changes, or none of them if any fails.
+func (m *MemoryDB) InsertScoringEvents(ctx context.Context, events []ScoringEventRow, scoreDeltas []ParticipantScoreDelta) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertScoringEvents", events, scoreDeltas); err != nil {
+		return
+	}
+	for _, scoreDelta := range scoreDeltas {
+		if m.participantById(scoreDelta.ParticipantId) == nil {
+			return sql.ErrNoRows
+		}
+	}
+	if err = m.upsertScoringEvents(events); err != nil {
+		return
+	}
+	now := time.Now().UTC()
+	for _, scoreDelta := range scoreDeltas {
+		existing := m.participantById(scoreDelta.ParticipantId)
+		existing.Score += scoreDelta.Delta
+		existing.updatedOn = now
+	}
+	m.changed()
+	return
+}
+
+// upsertScoringEvents scores each pull request again when it was scored before. A voided event that is scored again
+// counts afresh, for the participant of the new score.
+func (m *MemoryDB) upsertScoringEvents(events []ScoringEventRow) (err error) {
+	for _, event := range events {
+		if m.campaign(event.Participant.CampaignName) == nil {
+			return memoryNoCampaign(event.Participant.CampaignName)
+		}
+		if _, ok := m.scp(event.Participant.ScpName, false); !ok {
+			return fmt.Errorf("no source control provider: name: %s", event.Participant.ScpName)
+		}
+	}
+
+	now := time.Now().UTC()
+	for _, event := range events {
+		existing := m.scoringEvent(event.Participant.CampaignName, event.Participant.ScpName, event.Msg.RepoOwner,
+			event.Msg.RepoName, event.Msg.PullRequest)
+		if existing == nil {
+			existing = &memoryScoringEvent{ScoringEventStruct: types.ScoringEventStruct{
+				ID:           newId(),
+				CampaignName: event.Participant.CampaignName,
+				ScpName:      event.Participant.ScpName,
+				RepoOwner:    event.Msg.RepoOwner,
+				RepoName:     event.Msg.RepoName,
+				PullRequest:  event.Msg.PullRequest,
+				UserName:     event.Msg.TriggerUser,
+			}}
+			m.scoringEvents = append(m.scoringEvents, existing)
+		} else if existing.VoidedOn != nil {
+			existing.UserName = event.Msg.TriggerUser
+		}
+		existing.Points = event.NewPoints
+		existing.Breakdown = copyBreakdown(event.Breakdown)
+		existing.VoidedOn = nil
+		existing.scoredOn = now
+		existing.revise(existing.UserName, false, existing.Points, false, now)
+		for _, fingerprint := range event.Msg.Fingerprints {
+			if existing.fingerprints == nil {
+				existing.fingerprints = map[string]int{}
+			}
+			if _, ok := existing.fingerprints[fingerprint]; !ok {
+				m.fixedCount++
+				existing.fingerprints[fingerprint] = m.fixedCount
+			}
+		}
+	}
+	m.changed()
+	return
+}
+
+// SelectDuplicateFingerprints reads the fingerprints of the message that another participant of the campaign fixed
+// first, in the same repository, by a scoring event that still counts.
+func (m *MemoryDB) SelectDuplicateFingerprints(ctx context.Context, participant *types.ParticipantStruct, msg *types.ScoringMessage) (duplicates []string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectDuplicateFingerprints", participant, msg); err != nil {
+		return
+	}
+	own := m.scoringEvent(participant.CampaignName, participant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest)
+	duplicated := map[string]bool{}
+	for _, event := range m.scoringEvents {
+		if event.CampaignName != participant.CampaignName || event.ScpName != participant.ScpName ||
+			event.RepoOwner != msg.RepoOwner || event.RepoName != msg.RepoName ||
+			event.UserName == participant.LoginName || event.VoidedOn != nil {
+			continue
+		}
+		for _, fingerprint := range msg.Fingerprints {
+			fixed, ok := event.fingerprints[fingerprint]
+			if !ok {
+				continue
+			}
+			if own != nil {
+				if ownFixed, ownOk := own.fingerprints[fingerprint]; ownOk && ownFixed <= fixed {
+					continue
+				}
+			}
+			duplicated[fingerprint] = true
+		}
+	}
+	for fingerprint := range duplicated {
+		duplicates = append(duplicates, fingerprint)
+	}
+	sort.Strings(duplicates)
+	return
+}
+
+func copyBreakdown(breakdown *types.ScoreBreakdown) *types.ScoreBreakdown {
+	if breakdown == nil {
+		return nil
+	}
+	copied := *breakdown
+	copied.Categories = append([]types.CategoryScore(nil), breakdown.Categories...)
+	return &copied
+}
+
+func (e *memoryScoringEvent) scoringEventStruct() (copied *types.ScoringEventStruct) {
+	event := e.ScoringEventStruct
+	event.Breakdown = copyBreakdown(event.Breakdown)
+	event.VoidedOn = copyTime(event.VoidedOn)
+	return &event
+}
+
+func (m *MemoryDB) SelectScoringEvent(ctx context.Context, campaignName, scpName, repoOwner, repoName string, pullRequest int) (event *types.ScoringEventStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectScoringEvent", campaignName, scpName, repoOwner, repoName, pullRequest); err != nil {
+		return
+	}
+	existing := m.scoringEvent(campaignName, scpName, repoOwner, repoName, pullRequest)
+	if existing == nil {
+		err = sql.ErrNoRows
+		return
+	}
+	event = existing.scoringEventStruct()
+	return
+}
+
+// ExportScoringEvents passes each scoring event of the campaign to the func, in the order they were scored. The events
+// are copied first, so the func is not called holding the lock.
+func (m *MemoryDB) ExportScoringEvents(ctx context.Context, campaignName string, each func(event *types.ScoringEventStruct) error) (err error) {
+	m.mu.Lock()
+	if err = m.record("ExportScoringEvents", campaignName); err != nil {
+		m.mu.Unlock()
+		return
+	}
+	var events []*types.ScoringEventStruct
+	var existing []*memoryScoringEvent
+	for _, event := range m.scoringEvents {
+		if event.CampaignName == campaignName {
+			existing = append(existing, event)
+		}
+	}
+	sort.SliceStable(existing, func(i, j int) bool {
+		return existing[i].scoredOn.Before(existing[j].scoredOn)
+	})
+	for _, event := range existing {
+		copied := event.scoringEventStruct()
+		scoredOn := event.scoredOn
+		copied.ScoredOn = &scoredOn
+		events = append(events, copied)
+	}
+	m.mu.Unlock()
+
+	for _, event := range events {
+		if err = each(event); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// ImportScoringEvents inserts the events of pull requests not already scored, or none of them if any fails, and
+// recomputes the score of each participant with an imported event from their events that are not voided.
+func (m *MemoryDB) ImportScoringEvents(ctx context.Context, events []types.ScoringEventStruct) (imported, recomputed int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("ImportScoringEvents", events); err != nil {
+		return
+	}
+	for _, event := range events {
+		if m.campaign(event.CampaignName) == nil {
+			err = memoryNoCampaign(event.CampaignName)
+			return
+		}
+		if _, ok := m.scp(event.ScpName, false); !ok {
+			err = fmt.Errorf("no source control provider: name: %s", event.ScpName)
+			return
+		}
+		if archive, ok := m.archives[event.CampaignName]; ok && archive.RestoredOn == nil {
+			err = ErrCampaignArchived
+			return
+		}
+	}
+
+	var affected []importedParticipant
+	seen := map[importedParticipant]bool{}
+	for i := range events {
+		event := &events[i]
+		if m.scoringEvent(event.CampaignName, event.ScpName, event.RepoOwner, event.RepoName, event.PullRequest) != nil {
+			continue
+		}
+		scoredOn := time.Now().UTC()
+		if event.ScoredOn != nil {
+			scoredOn = event.ScoredOn.UTC()
+		}
+		importedEvent := &memoryScoringEvent{
+			ScoringEventStruct: types.ScoringEventStruct{
+				ID:           newId(),
+				CampaignName: event.CampaignName,
+				ScpName:      event.ScpName,
+				RepoOwner:    event.RepoOwner,
+				RepoName:     event.RepoName,
+				PullRequest:  event.PullRequest,
+				UserName:     event.UserName,
+				Points:       event.Points,
+				Breakdown:    copyBreakdown(event.Breakdown),
+				VoidedOn:     copyTime(event.VoidedOn),
+			},
+			scoredOn: scoredOn,
+		}
+		importedEvent.reviseInserted()
+		m.scoringEvents = append(m.scoringEvents, importedEvent)
+		imported++
+		affected = addImportedParticipant(affected, seen, event)
+	}
+
+	for _, imported := range affected {
+		participant := m.participant(imported.CampaignName, imported.ScpName, imported.LoginName)
+		if participant == nil {
+			continue
+		}
+		participant.Score = 0
+		for _, event := range m.scoringEvents {
+			if event.CampaignName != imported.CampaignName || event.ScpName != imported.ScpName || event.VoidedOn != nil {
+				continue
+			}
+			if event.UserName == imported.LoginName {
+				participant.Score += event.Points
+			}
+			participant.Score += event.coAuthors[imported.LoginName]
+		}
+		participant.updatedOn = time.Now().UTC()
+		recomputed++
+	}
+	if imported > 0 {
+		m.changed()
+	}
+	return
+}
+
+// SelectCampaignsToArchive reads the campaigns that ended before the time and were never archived, and have no flag
+// waiting for review, the longest ended first.
+func (m *MemoryDB) SelectCampaignsToArchive(ctx context.Context, endedBefore time.Time) (campaignNames []string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectCampaignsToArchive", endedBefore); err != nil {
+		return
+	}
+	flagged := map[string]bool{}
+	for _, flag := range m.scoringFlags {
+		if flag.ResolvedOn != nil {
+			continue
+		}
+		for _, event := range m.scoringEvents {
+			if event.ID == flag.Event.ID {
+				flagged[event.CampaignName] = true
+			}
+		}
+	}
+	var ended []*memoryCampaign
+	for _, campaign := range m.campaigns {
+		if _, archived := m.archives[campaign.Name]; !archived && !flagged[campaign.Name] && campaign.EndOn.Before(endedBefore) {
+			ended = append(ended, campaign)
+		}
+	}
+	sort.SliceStable(ended, func(i, j int) bool {
+		return ended[i].EndOn.Before(ended[j].EndOn)
+	})
+	for _, campaign := range ended {
+		campaignNames = append(campaignNames, campaign.Name)
+	}
+	return
+}
+
+// ArchiveScoringEvents moves the scoring events of the campaign into a gzipped archive, dropping their flags. The
+// scores of the participants are left as they are. sql.ErrNoRows is returned when there is no such campaign, or its
+// events are already archived.
+func (m *MemoryDB) ArchiveScoringEvents(ctx context.Context, campaignName string, now time.Time) (archive *types.ScoringEventArchive, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("ArchiveScoringEvents", campaignName, now); err != nil {
+		return
+	}
+	if existing, ok := m.archives[campaignName]; m.campaign(campaignName) == nil || (ok && existing.RestoredOn == nil) {
+		err = sql.ErrNoRows
+		return
+	}
+
+	var events []types.ScoringEventStruct
+	var kept []*memoryScoringEvent
+	archivedIds := map[string]bool{}
+	for _, event := range m.scoringEvents {
+		if event.CampaignName != campaignName {
+			kept = append(kept, event)
+			continue
+		}
+		archived := *event.scoringEventStruct()
+		archived.CoAuthors = nil
+		scoredOn := event.scoredOn
+		archived.ScoredOn = &scoredOn
+		loginNames := make([]string, 0, len(event.coAuthors))
+		for loginName := range event.coAuthors {
+			loginNames = append(loginNames, loginName)
+		}
+		sort.Strings(loginNames)
+		for _, loginName := range loginNames {
+			archived.CoAuthors = append(archived.CoAuthors, types.CoAuthorScore{LoginName: loginName, Points: event.coAuthors[loginName]})
+		}
+		events = append(events, archived)
+		archivedIds[event.ID] = true
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].ScoredOn.Before(*events[j].ScoredOn)
+	})
+
+	var archived []byte
+	if archived, err = gzipScoringEvents(events); err != nil {
+		return
+	}
+	var flags []*types.ScoringFlag
+	for _, flag := range m.scoringFlags {
+		if !archivedIds[flag.Event.ID] {
+			flags = append(flags, flag)
+		}
+	}
+	m.scoringEvents, m.scoringFlags = kept, flags
+	m.archives[campaignName] = &memoryArchive{
+		ScoringEventArchive: types.ScoringEventArchive{CampaignName: campaignName, EventCount: int64(len(events)), ArchivedOn: now.UTC()},
+		events:              archived,
+	}
+	archive = &types.ScoringEventArchive{CampaignName: campaignName, EventCount: int64(len(events)), ArchivedOn: now}
+	m.changed()
+	return
+}
+
+// RestoreScoringEvents moves the archived scoring events of the campaign back. An event of a pull request scored again
+// since keeps its new score. The archive is kept, without its events. sql.ErrNoRows is returned when the campaign has
+// no archive to restore.
+func (m *MemoryDB) RestoreScoringEvents(ctx context.Context, campaignName string, now time.Time) (archive *types.ScoringEventArchive, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("RestoreScoringEvents", campaignName, now); err != nil {
+		return
+	}
+	existing, ok := m.archives[campaignName]
+	if !ok || existing.RestoredOn != nil {
+		err = sql.ErrNoRows
+		return
+	}
+	var events []types.ScoringEventStruct
+	if events, err = gunzipScoringEvents(existing.events); err != nil {
+		return
+	}
+
+	restoredOn := now.UTC()
+	restored := &types.ScoringEventArchive{CampaignName: campaignName, ArchivedOn: existing.ArchivedOn, RestoredOn: &now}
+	for _, event := range events {
+		if m.scoringEvent(campaignName, event.ScpName, event.RepoOwner, event.RepoName, event.PullRequest) != nil {
+			continue
+		}
+		memoryEvent := &memoryScoringEvent{ScoringEventStruct: event}
+		memoryEvent.ID = newId()
+		memoryEvent.CampaignName = campaignName
+		memoryEvent.CoAuthors, memoryEvent.ScoredOn = nil, nil
+		if event.ScoredOn != nil {
+			memoryEvent.scoredOn = event.ScoredOn.UTC()
+		}
+		for _, coAuthor := range event.CoAuthors {
+			if memoryEvent.coAuthors == nil {
+				memoryEvent.coAuthors = map[string]float64{}
+			}
+			memoryEvent.coAuthors[coAuthor.LoginName] = coAuthor.Points
+		}
+		memoryEvent.reviseInserted()
+		m.scoringEvents = append(m.scoringEvents, memoryEvent)
+		restored.EventCount++
+	}
+	existing.events = nil
+	existing.RestoredOn = &restoredOn
+	archive = restored
+	m.changed()
+	return
+}
+
+// SelectScoringEventArchives reads the archives of the campaigns, restored ones included, the oldest first.
+func (m *MemoryDB) SelectScoringEventArchives(ctx context.Context) (archives []types.ScoringEventArchive, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectScoringEventArchives"); err != nil {
+		return
+	}
+	for _, archive := range m.archives {
+		copied := archive.ScoringEventArchive
+		copied.RestoredOn = copyTime(archive.RestoredOn)
+		archives = append(archives, copied)
+	}
+	sort.SliceStable(archives, func(i, j int) bool {
+		if !archives[i].ArchivedOn.Equal(archives[j].ArchivedOn) {
+			return archives[i].ArchivedOn.Before(archives[j].ArchivedOn)
+		}
+		return archives[i].CampaignName < archives[j].CampaignName
+	})
+	return
+}
+
+// VoidScoringEvent takes the points of the event off the participant who scored it, and the shares of its co-authors
+// off theirs. sql.ErrNoRows is returned when there is no such event, or it is already voided.
+func (m *MemoryDB) VoidScoringEvent(ctx context.Context, eventId string, now time.Time) (event *types.ScoringEventStruct, teamName string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("VoidScoringEvent", eventId, now); err != nil {
+		return
+	}
+	for _, existing := range m.scoringEvents {
+		if existing.ID != eventId || existing.VoidedOn != nil {
+			continue
+		}
+		voidedOn := now.UTC()
+		existing.VoidedOn = &voidedOn
+		existing.revise(existing.UserName, false, 0, true, voidedOn)
+		// the participant may since have been removed from the campaign, leaving no score to correct
+		if participant := m.participant(existing.CampaignName, existing.ScpName, existing.UserName); participant != nil {
+			participant.Score -= existing.Points
+			participant.updatedOn = time.Now().UTC()
+			teamName = m.participantStruct(participant).TeamName
+		}
+		event = existing.scoringEventStruct()
+		loginNames := make([]string, 0, len(existing.coAuthors))
+		for loginName := range existing.coAuthors {
+			loginNames = append(loginNames, loginName)
+		}
+		sort.Strings(loginNames)
+		for _, loginName := range loginNames {
+			if coAuthor := m.participant(existing.CampaignName, existing.ScpName, loginName); coAuthor != nil {
+				coAuthor.Score -= existing.coAuthors[loginName]
+				coAuthor.updatedOn = time.Now().UTC()
+				event.CoAuthors = append(event.CoAuthors, types.CoAuthorScore{
+					LoginName: loginName,
+					TeamName:  m.participantStruct(coAuthor).TeamName,
+					Points:    existing.coAuthors[loginName],
+				})
+			}
+		}
+		// the shares are taken off once, so a scoring again after the void starts the co-authors afresh
+		for _, loginName := range loginNames {
+			existing.revise(loginName, true, 0, false, time.Now().UTC())
+		}
+		existing.coAuthors = nil
+		m.changed()
+		return
+	}
+	err = sql.ErrNoRows
+	return
+}
+
+func (m *MemoryDB) SelectScoredEvents(ctx context.Context, since time.Time) (events []ScoredEvent, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectScoredEvents", since); err != nil {
+		return
+	}
+	for _, event := range m.scoringEvents {
+		if event.VoidedOn == nil && !event.scoredOn.Before(since) {
+			events = append(events, ScoredEvent{ScoringEventStruct: *event.scoringEventStruct(), ScoredOn: event.scoredOn})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].ScoredOn.Before(events[j].ScoredOn)
+	})
+	return
+}
+
+func (m *MemoryDB) InsertScoringFlags(ctx context.Context, flags []types.ScoringFlag) (flagged int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertScoringFlags", flags); err != nil {
+		return
+	}
+	for _, flag := range flags {
+		if m.scoringFlag(flag.Event.ID, flag.Reason) != nil {
+			continue
+		}
+		m.scoringFlags = append(m.scoringFlags, &types.ScoringFlag{
+			ID:        newId(),
+			Reason:    flag.Reason,
+			FlaggedOn: flag.FlaggedOn.UTC(),
+			Event:     types.ScoringEventStruct{ID: flag.Event.ID},
+		})
+		flagged++
+	}
+	return
+}
+
+func (m *MemoryDB) scoringFlag(eventId, reason string) *types.ScoringFlag {
+	for _, flag := range m.scoringFlags {
+		if flag.Event.ID == eventId && flag.Reason == reason {
+			return flag
+		}
+	}
+	return nil
+}
+
+// scoringFlagStruct is a copy of the flag, with the scoring event it flags. Nil when there is no such event.
+func (m *MemoryDB) scoringFlagStruct(flag *types.ScoringFlag) *types.ScoringFlag {
+	for _, event := range m.scoringEvents {
+		if event.ID == flag.Event.ID {
+			copied := *flag
+			copied.ResolvedOn = copyTime(flag.ResolvedOn)
+			copied.Event = *event.scoringEventStruct()
+			return &copied
+		}
+	}
+	return nil
+}
+
+func (m *MemoryDB) SelectScoringFlags(ctx context.Context) (flags []types.ScoringFlag, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectScoringFlags"); err != nil {
+		return
+	}
+	for _, flag := range m.scoringFlags {
+		if flag.ResolvedOn != nil {
+			continue
+		}
+		if copied := m.scoringFlagStruct(flag); copied != nil && copied.Event.VoidedOn == nil {
+			flags = append(flags, *copied)
+		}
+	}
+	sort.SliceStable(flags, func(i, j int) bool {
+		return flags[i].FlaggedOn.Before(flags[j].FlaggedOn)
+	})
+	return
+}
+
+// ResolveScoringFlag takes the flag out of the review queue. sql.ErrNoRows is returned when there is no such flag, or
+// it is already resolved.
+func (m *MemoryDB) ResolveScoringFlag(ctx context.Context, flagId, resolution string, now time.Time) (flag *types.ScoringFlag, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("ResolveScoringFlag", flagId, resolution, now); err != nil {
+		return
+	}
+	for _, existing := range m.scoringFlags {
+		if existing.ID != flagId || existing.ResolvedOn != nil {
+			continue
+		}
+		if m.scoringFlagStruct(existing) == nil {
+			// the scoring event is gone, taking its flags with it
+			break
+		}
+		resolvedOn := now.UTC()
+		existing.ResolvedOn, existing.Resolution = &resolvedOn, resolution
+		flag = m.scoringFlagStruct(existing)
+		return
+	}
+	err = sql.ErrNoRows
+	return
+}
+
+func (m *MemoryDB) InsertParticipant(ctx context.Context, participant *types.ParticipantStruct) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertParticipant", participant); err != nil {
+		return
+	}
+	err = m.insertParticipant(participant)
+	if err != nil {
+		m.logger.Error("error inserting participant", zap.Any("participant", participant), zap.Error(err))
+	}
+	return
+}
+
+func (m *MemoryDB) insertParticipant(participant *types.ParticipantStruct) (err error) {
+	if m.campaign(participant.CampaignName) == nil {
+		return memoryNoCampaign(participant.CampaignName)
+	}
+	if _, ok := m.scp(participant.ScpName, false); !ok {
+		return fmt.Errorf("no source control provider: name: %s", participant.ScpName)
+	}
+	if m.participant(participant.CampaignName, participant.ScpName, participant.LoginName) != nil {
+		return &DuplicateError{Entity: "participant"}
+	}
+
+	now := time.Now().UTC()
+	participant.ID, participant.Score, participant.JoinedAt = newId(), 0, now
+	m.participants = append(m.participants, &memoryParticipant{
+		ParticipantStruct: types.ParticipantStruct{
+			ID:           participant.ID,
+			CampaignName: participant.CampaignName,
+			ScpName:      participant.ScpName,
+			LoginName:    participant.LoginName,
+			Email:        participant.Email,
+			DisplayName:  participant.DisplayName,
+			JoinedAt:     now,
+			Version:      1,
+		},
+		updatedOn: now,
+	})
+	m.changed()
+	return
+}
+
+// UpsertParticipant adds the participant, or updates the contact details of an existing one. The participant is
+// filled in with the id, score and team it has after the call.
+func (m *MemoryDB) UpsertParticipant(ctx context.Context, participant *types.ParticipantStruct) (inserted bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpsertParticipant", participant); err != nil {
+		return
+	}
+	existing := m.participant(participant.CampaignName, participant.ScpName, participant.LoginName)
+	if existing == nil {
+		err = m.insertParticipant(participant)
+		inserted = err == nil
+		return
+	}
+
+	existing.Email, existing.DisplayName = participant.Email, participant.DisplayName
+	existing.updatedOn = time.Now().UTC()
+	m.changed()
+	updated := m.participantStruct(existing)
+	participant.ID, participant.Score, participant.TeamName, participant.JoinedAt = updated.ID, updated.Score, updated.TeamName, updated.JoinedAt
+	return
+}
+
+func (m *MemoryDB) team(campaignName, teamName string) *memoryTeam {
+	for _, team := range m.teams {
+		if team.CampaignName == campaignName && team.Name == teamName {
+			return team
+		}
+	}
+	return nil
+}
+
+func (m *MemoryDB) teamById(id string) *memoryTeam {
+	for _, team := range m.teams {
+		if team.Id == id {
+			return team
+		}
+	}
+	return nil
+}
+
+func (m *MemoryDB) InsertTeam(ctx context.Context, team *types.TeamStruct) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertTeam", team); err != nil {
+		return
+	}
+	return m.insertTeam(team)
+}
+
+func (m *MemoryDB) insertTeam(team *types.TeamStruct) (err error) {
+	if m.campaign(team.CampaignName) == nil {
+		return memoryNoCampaign(team.CampaignName)
+	}
+	if m.team(team.CampaignName, team.Name) != nil {
+		return &DuplicateError{Entity: "team"}
+	}
+	team.Id = newId()
+	m.teams = append(m.teams, &memoryTeam{
+		TeamStruct: types.TeamStruct{Id: team.Id, CampaignName: team.CampaignName, Name: team.Name},
+		updatedOn:  time.Now().UTC(),
+	})
+	return
+}
+
+func (m *MemoryDB) SelectTeamsInCampaign(ctx context.Context, campaignName string) (teams []types.TeamStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectTeamsInCampaign", campaignName); err != nil {
+		return
+	}
+	for _, team := range m.teams {
+		if team.CampaignName == campaignName {
+			teams = append(teams, team.TeamStruct)
+		}
+	}
+	sort.Slice(teams, func(i, j int) bool {
+		return teams[i].Name < teams[j].Name
+	})
+	return
+}
+
+// teamMembers are the members of the team, ordered by login name.
+func (m *MemoryDB) teamMembers(teamId string) (members []types.ParticipantStruct) {
+	for _, participant := range m.participants {
+		if participant.teamId == teamId {
+			members = append(members, m.participantStruct(participant))
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].LoginName < members[j].LoginName
+	})
+	return
+}
+
+// SelectTeamDetail finds a team by name, with its members. When no campaign name is given, the team from the most
+// recently created campaign wins.
+func (m *MemoryDB) SelectTeamDetail(ctx context.Context, campaignName, teamName string) (team *types.TeamStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectTeamDetail", campaignName, teamName); err != nil {
+		return
+	}
+	var found *memoryTeam
+	for _, candidate := range m.teams {
+		if candidate.Name != teamName || (campaignName != "" && candidate.CampaignName != campaignName) {
+			continue
+		}
+		if found == nil || m.campaign(candidate.CampaignName).CreatedOrder > m.campaign(found.CampaignName).CreatedOrder {
+			found = candidate
+		}
+	}
+	if found == nil {
+		err = sql.ErrNoRows
+		m.logger.Error("selectTeamDetail scan error",
+			zap.String("campaignName", campaignName), zap.String("teamName", teamName), zap.Error(err))
+		return
+	}
+	team = &types.TeamStruct{Id: found.Id, CampaignName: found.CampaignName, Name: found.Name, Members: m.teamMembers(found.Id)}
+	return
+}
+
+func (m *MemoryDB) DeleteTeam(ctx context.Context, campaignName, teamName string) (rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("DeleteTeam", campaignName, teamName); err != nil {
+		return
+	}
+	team := m.team(campaignName, teamName)
+	if team == nil {
+		return
+	}
+	for _, participant := range m.participants {
+		if participant.teamId == team.Id {
+			participant.teamId, participant.Captain = "", false
+			participant.updatedOn = time.Now().UTC()
+		}
+	}
+	kept := m.teams[:0]
+	for _, other := range m.teams {
+		if other != team {
+			kept = append(kept, other)
+		}
+	}
+	m.teams = kept
+	keptEvents := m.teamScoreEvents[:0]
+	for _, event := range m.teamScoreEvents {
+		if event.teamId != team.Id {
+			keptEvents = append(keptEvents, event)
+		}
+	}
+	m.teamScoreEvents = keptEvents
+	m.changed()
+	rowsAffected = 1
+	return
+}
+
+func (m *MemoryDB) UpdateTeamName(ctx context.Context, campaignName, teamName, newTeamName string) (rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpdateTeamName", campaignName, teamName, newTeamName); err != nil {
+		return
+	}
+	team := m.team(campaignName, teamName)
+	if team == nil {
+		return
+	}
+	if other := m.team(campaignName, newTeamName); other != nil && other != team {
+		err = &DuplicateError{Entity: "team"}
+		return
+	}
+	team.Name, team.updatedOn = newTeamName, time.Now().UTC()
+	m.changed()
+	rowsAffected = 1
+	return
+}
+
+// teamMember finds the participant on the team
+func (m *MemoryDB) teamMember(team *memoryTeam, scpName, loginName string) *memoryParticipant {
+	for _, participant := range m.participants {
+		if participant.teamId == team.Id && participant.ScpName == scpName && participant.LoginName == loginName {
+			return participant
+		}
+	}
+	return nil
+}
+
+// UpdateTeamCaptain makes the member the captain of the team, replacing any captain it had. No rows are affected, and
+// the captain is kept, when the participant is not on the team.
+func (m *MemoryDB) UpdateTeamCaptain(ctx context.Context, campaignName, teamName, scpName, loginName string) (rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpdateTeamCaptain", campaignName, teamName, scpName, loginName); err != nil {
+		return
+	}
+	team := m.team(campaignName, teamName)
+	if team == nil {
+		return
+	}
+	captain := m.teamMember(team, scpName, loginName)
+	if captain == nil {
+		return
+	}
+	now := time.Now().UTC()
+	for _, participant := range m.participants {
+		if participant.teamId == team.Id && participant.Captain {
+			participant.Captain, participant.updatedOn = false, now
+		}
+	}
+	captain.Captain, captain.updatedOn = true, now
+	rowsAffected = 1
+	return
+}
+
+func (m *MemoryDB) IsTeamCaptain(ctx context.Context, campaignName, teamName, scpName, loginName string) (isCaptain bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("IsTeamCaptain", campaignName, teamName, scpName, loginName); err != nil {
+		return
+	}
+	if team := m.team(campaignName, teamName); team != nil {
+		member := m.teamMember(team, scpName, loginName)
+		isCaptain = member != nil && member.Captain
+	}
+	return
+}
+
+func (m *MemoryDB) RemoveParticipantFromTeam(ctx context.Context, teamName, campaignName, scpName, loginName string) (rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("RemoveParticipantFromTeam", teamName, campaignName, scpName, loginName); err != nil {
+		return
+	}
+	team := m.team(campaignName, teamName)
+	if team == nil {
+		return
+	}
+	if member := m.teamMember(team, scpName, loginName); member != nil {
+		member.teamId, member.Captain, member.updatedOn = "", false, time.Now().UTC()
+		m.changed()
+		rowsAffected = 1
+	}
+	return
+}
+
+func (m *MemoryDB) InsertTeamScoreAdjustments(ctx context.Context, campaignName string, request *types.TeamScoreAdjustmentRequest, now time.Time) (events []types.TeamScoreEvent, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertTeamScoreAdjustments", campaignName, request, now); err != nil {
+		return
+	}
+	var inserted []memoryTeamScoreEvent
+	for _, adjustment := range request.Adjustments {
+		team := m.team(campaignName, adjustment.TeamName)
+		if team == nil {
+			err = fmt.Errorf("no team: campaignName: %s, name: %s: %w", campaignName, adjustment.TeamName, sql.ErrNoRows)
+			return
+		}
+		inserted = append(inserted, memoryTeamScoreEvent{
+			TeamScoreEvent: types.TeamScoreEvent{
+				Id:        newId(),
+				TeamName:  adjustment.TeamName,
+				Kind:      types.TeamScoreKindJudgedAward,
+				Category:  request.Category,
+				Points:    adjustment.Points,
+				Reason:    adjustment.Reason,
+				CreatedOn: sql.NullTime{Time: now, Valid: true},
+			},
+			teamId: team.Id,
+		})
+	}
+	m.teamScoreEvents = append(m.teamScoreEvents, inserted...)
+	for _, event := range inserted {
+		events = append(events, event.TeamScoreEvent)
+	}
+	return
+}
+
+// SelectTeamScoreHistory lists the pull requests scored by the members of the team and the judged awards of the team,
+// in the order they were earned.
+func (m *MemoryDB) SelectTeamScoreHistory(ctx context.Context, campaignName, teamName string) (events []types.TeamScoreEvent, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectTeamScoreHistory", campaignName, teamName); err != nil {
+		return
+	}
+	team := m.team(campaignName, teamName)
+	if team == nil {
+		return
+	}
+	for _, event := range m.scoringEvents {
+		participant := m.participant(event.CampaignName, event.ScpName, event.UserName)
+		if event.VoidedOn == nil && participant != nil && participant.teamId == team.Id {
+			events = append(events, types.TeamScoreEvent{
+				TeamName:  team.Name,
+				Kind:      types.TeamScoreKindPullRequest,
+				LoginName: participant.LoginName,
+				Points:    event.Points,
+				Reason:    fmt.Sprintf("%s/%s #%d", event.RepoOwner, event.RepoName, event.PullRequest),
+				CreatedOn: sql.NullTime{Time: event.scoredOn, Valid: !event.scoredOn.IsZero()},
+			})
+		}
+	}
+	for _, event := range m.teamScoreEvents {
+		if event.teamId == team.Id {
+			award := event.TeamScoreEvent
+			award.TeamName = team.Name
+			events = append(events, award)
+		}
+	}
+	// those with no time last, as in postgres
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[j].CreatedOn.Valid {
+			return events[i].CreatedOn.Valid
+		}
+		return events[i].CreatedOn.Valid && events[i].CreatedOn.Time.Before(events[j].CreatedOn.Time)
+	})
+	return
+}
+
+func (m *MemoryDB) UpdateParticipantContact(ctx context.Context, scpName, loginName string, request *types.ParticipantProfileRequest) (rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpdateParticipantContact", scpName, loginName, request); err != nil {
+		return
+	}
+	participant := m.participant(request.CampaignName, scpName, loginName)
+	if participant == nil {
+		return
+	}
+	if request.Email != nil {
+		participant.Email = *request.Email
+	}
+	if request.DisplayName != nil {
+		participant.DisplayName = *request.DisplayName
+	}
+	participant.updatedOn = time.Now().UTC()
+	m.changed()
+	rowsAffected = 1
+	return
+}
+
+// UpdateParticipantProfile sets the avatar read from the source control provider, and the display name unless the
+// participant set one.
+func (m *MemoryDB) UpdateParticipantProfile(ctx context.Context, participantId, displayName, avatarUrl string, now time.Time) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpdateParticipantProfile", participantId, displayName, avatarUrl, now); err != nil {
+		return
+	}
+	participant := m.participantById(participantId)
+	if participant == nil {
+		return
+	}
+	if participant.DisplayName == "" {
+		participant.DisplayName = displayName
+	}
+	if avatarUrl != "" {
+		participant.AvatarUrl = avatarUrl
+	}
+	participant.profileRefreshedOn = copyTime(&now)
+	participant.updatedOn = time.Now().UTC()
+	m.changed()
+	return
+}
+
+// SelectParticipantsToRefresh lists the participants whose profile was never read, then those read longest ago.
+func (m *MemoryDB) SelectParticipantsToRefresh(ctx context.Context, refreshedBefore time.Time, limit int) (participants []types.ParticipantStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectParticipantsToRefresh", refreshedBefore, limit); err != nil {
+		return
+	}
+	var toRefresh []*memoryParticipant
+	for _, participant := range m.participants {
+		if participant.profileRefreshedOn == nil || participant.profileRefreshedOn.Before(refreshedBefore) {
+			toRefresh = append(toRefresh, participant)
+		}
+	}
+	sort.SliceStable(toRefresh, func(i, j int) bool {
+		a, b := toRefresh[i].profileRefreshedOn, toRefresh[j].profileRefreshedOn
+		return a == nil && b != nil || a != nil && b != nil && a.Before(*b)
+	})
+	for i := 0; i < len(toRefresh) && i < limit; i++ {
+		participants = append(participants, types.ParticipantStruct{
+			ID:           toRefresh[i].ID,
+			CampaignName: toRefresh[i].CampaignName,
+			ScpName:      toRefresh[i].ScpName,
+			LoginName:    toRefresh[i].LoginName,
+		})
+	}
+	return
+}
+
+func (m *MemoryDB) SelectParticipantDetail(ctx context.Context, campaignName, scpName, loginName string) (participant *types.ParticipantStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectParticipantDetail", campaignName, scpName, loginName); err != nil {
+		return
+	}
+	existing := m.participant(campaignName, scpName, loginName)
+	if existing == nil {
+		err = sql.ErrNoRows
+		m.logger.Error("getParticipantDetail scan error", zap.Error(err))
+		return
+	}
+	detail := m.participantStruct(existing)
+	detail.Captain = false
+	participant = &detail
+	return
+}
+
+func (m *MemoryDB) SelectParticipantsInCampaign(ctx context.Context, campaignName string) (participants []types.ParticipantStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectParticipantsInCampaign", campaignName); err != nil {
+		return
+	}
+	for _, participant := range m.participants {
+		if participant.CampaignName == campaignName {
+			listed := m.participantStruct(participant)
+			listed.Captain = false
+			participants = append(participants, listed)
+		}
+	}
+	return
+}
+
+// SelectParticipantsVersion changes when a participant or team of the campaign is added, removed or updated.
+func (m *MemoryDB) SelectParticipantsVersion(ctx context.Context, campaignName string) (version types.ListVersion, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectParticipantsVersion", campaignName); err != nil {
+		return
+	}
+	if m.campaign(campaignName) == nil {
+		err = sql.ErrNoRows
+		return
+	}
+	version.UpdatedOn = memoryEpoch
+	for _, participant := range m.participants {
+		if participant.CampaignName == campaignName {
+			version.Count++
+			version.UpdatedOn = latest(version.UpdatedOn, participant.updatedOn)
+		}
+	}
+	for _, team := range m.teams {
+		if team.CampaignName == campaignName {
+			version.UpdatedOn = latest(version.UpdatedOn, team.updatedOn)
+		}
+	}
+	return
+}
+
+// SearchParticipants finds the participants of every campaign whose login contains login, ignoring case. An exact
+// match is listed first.
+func (m *MemoryDB) SearchParticipants(ctx context.Context, login string, limit int) (participants []types.ParticipantStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SearchParticipants", login, limit); err != nil {
+		return
+	}
+	var found []types.ParticipantStruct
+	for _, participant := range m.participants {
+		if strings.Contains(strings.ToLower(participant.LoginName), strings.ToLower(login)) {
+			listed := m.participantStruct(participant)
+			listed.Captain = false
+			found = append(found, listed)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if aExact, bExact := strings.EqualFold(a.LoginName, login), strings.EqualFold(b.LoginName, login); aExact != bExact {
+			return aExact
+		}
+		if a.LoginName != b.LoginName {
+			return a.LoginName < b.LoginName
+		}
+		if a.CampaignName != b.CampaignName {
+			return a.CampaignName < b.CampaignName
+		}
+		return a.ScpName < b.ScpName
+	})
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	participants = found
+	return
+}
+
+func (m *MemoryDB) UpdateParticipant(ctx context.Context, participant *types.ParticipantStruct) (rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpdateParticipant", participant); err != nil {
+		return
+	}
+	existing := m.participantById(participant.ID)
+	if existing == nil {
+		return
+	}
+	if existing.Version != participant.Version {
+		err = &StaleError{Entity: "participant", Version: existing.Version}
+		return
+	}
+	if m.campaign(participant.CampaignName) == nil {
+		err = memoryNoCampaign(participant.CampaignName)
+		return
+	}
+	if other := m.participant(participant.CampaignName, participant.ScpName, participant.LoginName); other != nil && other != existing {
+		err = &DuplicateError{Entity: "participant"}
+		return
+	}
+	existing.CampaignName, existing.ScpName, existing.LoginName = participant.CampaignName, participant.ScpName, participant.LoginName
+	existing.Email, existing.DisplayName, existing.Score = participant.Email, participant.DisplayName, participant.Score
+	existing.teamId = ""
+	if team := m.team(participant.CampaignName, participant.TeamName); team != nil {
+		existing.teamId = team.Id
+	}
+	existing.Version++
+	participant.Version = existing.Version
+	existing.updatedOn = time.Now().UTC()
+	m.changed()
+	rowsAffected = 1
+	return
+}
+
+func (m *MemoryDB) PatchParticipant(ctx context.Context, patch *types.ParticipantPatch) (rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("PatchParticipant", patch); err != nil {
+		return
+	}
+	existing := m.participantById(patch.ID)
+	if existing == nil {
+		return
+	}
+	if existing.Version != patch.Version {
+		err = &StaleError{Entity: "participant", Version: existing.Version}
+		return
+	}
+	campaignName, scpName, loginName := existing.CampaignName, existing.ScpName, existing.LoginName
+	if value := patch.CampaignName.Ptr(); value != nil {
+		campaignName = *value
+	}
+	if value := patch.ScpName.Ptr(); value != nil {
+		scpName = *value
+	}
+	if value := patch.LoginName.Ptr(); value != nil {
+		loginName = *value
+	}
+	if m.campaign(campaignName) == nil {
+		err = memoryNoCampaign(campaignName)
+		return
+	}
+	if other := m.participant(campaignName, scpName, loginName); other != nil && other != existing {
+		err = &DuplicateError{Entity: "participant"}
+		return
+	}
+	existing.CampaignName, existing.ScpName, existing.LoginName = campaignName, scpName, loginName
+	if value := clearable(patch.Email); value != nil {
+		existing.Email = *value
+	}
+	if value := clearable(patch.DisplayName); value != nil {
+		existing.DisplayName = *value
+	}
+	if value := patch.Score.Ptr(); value != nil {
+		existing.Score = *value
+	}
+	if patch.TeamName.Set {
+		existing.teamId = ""
+		if team := m.team(campaignName, patch.TeamName.Value); !patch.TeamName.Null && team != nil {
+			existing.teamId = team.Id
+		}
+	}
+	existing.Version++
+	patch.Version = existing.Version
+	existing.updatedOn = time.Now().UTC()
+	m.changed()
+	rowsAffected = 1
+	return
+}
+
+// DeleteParticipant removes the participant, with its achievements and notifications. sql.ErrNoRows is returned when
+// there is no such participant.
+func (m *MemoryDB) DeleteParticipant(ctx context.Context, campaign, scpName, loginName string) (participantId string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("DeleteParticipant", campaign, scpName, loginName); err != nil {
+		return
+	}
+	existing := m.participant(campaign, scpName, loginName)
+	if existing == nil {
+		err = sql.ErrNoRows
+		m.logger.Error("error deleting participant",
+			zap.String("campaign", campaign), zap.String("scpName", scpName),
+			zap.String("loginName", loginName), zap.Error(err))
+		return
+	}
+	kept := m.participants[:0]
+	for _, participant := range m.participants {
+		if participant != existing {
+			kept = append(kept, participant)
+		}
+	}
+	m.participants = kept
+	keptAchievements := m.achievements[:0]
+	for _, achievement := range m.achievements {
+		if achievement.participantId != existing.ID {
+			keptAchievements = append(keptAchievements, achievement)
+		}
+	}
+	m.achievements = keptAchievements
+	keptNotifications := m.notifications[:0]
+	for _, notification := range m.notifications {
+		if notification.ParticipantId != existing.ID {
+			keptNotifications = append(keptNotifications, notification)
+		}
+	}
+	m.notifications = keptNotifications
+	m.changed()
+	participantId = existing.ID
+	return
+}
+
+func (m *MemoryDB) InsertBan(ctx context.Context, ban *types.BanStruct) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertBan", ban); err != nil {
+		return
+	}
+	scp, ok := m.scp(ban.ScpName, true)
+	if !ok {
+		return fmt.Errorf("no source control provider: name: %s", ban.ScpName)
+	}
+	loginName := strings.ToLower(ban.LoginName)
+	for _, existing := range m.bans {
+		if existing.ScpName == scp.SCPName && existing.LoginName == loginName {
+			return &DuplicateError{Entity: "ban"}
+		}
+	}
+	ban.ID, ban.LoginName, ban.BannedOn = newId(), loginName, time.Now().UTC()
+	inserted := *ban
+	inserted.ScpName = scp.SCPName
+	m.bans = append(m.bans, inserted)
+	return
+}
+
+func (m *MemoryDB) SelectBans(ctx context.Context) (bans []types.BanStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectBans"); err != nil {
+		return
+	}
+	bans = append(bans, m.bans...)
+	sort.Slice(bans, func(i, j int) bool {
+		if bans[i].ScpName != bans[j].ScpName {
+			return bans[i].ScpName < bans[j].ScpName
+		}
+		return bans[i].LoginName < bans[j].LoginName
+	})
+	return
+}
+
+func (m *MemoryDB) DeleteBan(ctx context.Context, scpName, loginName string) (rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("DeleteBan", scpName, loginName); err != nil {
+		return
+	}
+	kept := m.bans[:0]
+	for _, ban := range m.bans {
+		if strings.EqualFold(ban.ScpName, scpName) && strings.EqualFold(ban.LoginName, loginName) {
+			rowsAffected++
+		} else {
+			kept = append(kept, ban)
+		}
+	}
+	m.bans = kept
+	return
+}
+
+func (m *MemoryDB) SelectBanned(ctx context.Context, scpName, loginName string) (banned bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectBanned", scpName, loginName); err != nil {
+		return
+	}
+	for _, ban := range m.bans {
+		if strings.EqualFold(ban.ScpName, scpName) && strings.EqualFold(ban.LoginName, loginName) {
+			return true, nil
+		}
+	}
+	return
+}
+
+// updateParticipantTeam puts the participant on the team, unless the team is full. sql.ErrNoRows is returned when
+// there is no such team, and no rows are affected when there is no such participant.
+func (m *MemoryDB) updateParticipantTeam(teamName, campaignName, scpName, loginName string) (rowsAffected int64, err error) {
+	team := m.team(campaignName, teamName)
+	if team == nil {
+		err = sql.ErrNoRows
+		return
+	}
+	if participant := m.participant(campaignName, scpName, loginName); participant != nil && participant.Captain && participant.teamId != team.Id {
+		err = &CaptainMoveError{CampaignName: campaignName, ScpName: scpName, LoginName: loginName}
+		return
+	}
+	if maxTeamSize := m.campaign(campaignName).MaxTeamSize; maxTeamSize != nil && *maxTeamSize > 0 {
+		teamSize := 0
+		for _, participant := range m.participants {
+			if participant.teamId == team.Id && !(participant.ScpName == scpName && participant.LoginName == loginName) {
+				teamSize++
+			}
+		}
+		if teamSize >= *maxTeamSize {
+			err = &TeamFullError{CampaignName: campaignName, TeamName: teamName, MaxTeamSize: *maxTeamSize}
+			return
+		}
+	}
+
+	participant := m.participant(campaignName, scpName, loginName)
+	if participant == nil {
+		return
+	}
+	// the captain keeps the role, as a captain is only ever moved onto the team they are on
+	participant.teamId, participant.updatedOn = team.Id, time.Now().UTC()
+	m.changed()
+	rowsAffected = 1
+	return
+}
+
+func (m *MemoryDB) UpdateParticipantTeam(ctx context.Context, teamName, campaignName, scpName, loginName string) (rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpdateParticipantTeam", teamName, campaignName, scpName, loginName); err != nil {
+		return
+	}
+	return m.updateParticipantTeam(teamName, campaignName, scpName, loginName)
+}
+
+// UpdateParticipantTeams applies every assignment, or none of them when any fails.
+func (m *MemoryDB) UpdateParticipantTeams(ctx context.Context, assignments []types.TeamAssignment) (results []types.TeamAssignmentResult, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpdateParticipantTeams", assignments); err != nil {
+		return
+	}
+	type membership struct {
+		teamId    string
+		captain   bool
+		updatedOn time.Time
+	}
+	before := map[*memoryParticipant]membership{}
+	for _, participant := range m.participants {
+		before[participant] = membership{participant.teamId, participant.Captain, participant.updatedOn}
+	}
+
+	failed := false
+	for _, assignment := range assignments {
+		result := types.TeamAssignmentResult{TeamAssignment: assignment}
+
+		rowsAffected, assignErr := m.updateParticipantTeam(assignment.TeamName, assignment.CampaignName, assignment.ScpName, assignment.LoginName)
+		if assignErr == sql.ErrNoRows {
+			result.Error = fmt.Sprintf("no team: campaignName: %s, name: %s", assignment.CampaignName, assignment.TeamName)
+		} else if assignErr != nil {
+			result.Error = assignErr.Error()
+		} else if rowsAffected == 0 {
+			result.Error = fmt.Sprintf("no participant: campaignName: %s, scpName: %s, loginName: %s",
+				assignment.CampaignName, assignment.ScpName, assignment.LoginName)
+		}
+
+		failed = failed || result.Error != ""
+		results = append(results, result)
+	}
+
+	if failed {
+		for participant, was := range before {
+			participant.teamId, participant.Captain, participant.updatedOn = was.teamId, was.captain, was.updatedOn
+		}
+		return
+	}
+	for i := range results {
+		results[i].Assigned = true
+	}
+	return
+}
+
+// InsertSeedFixture adds all of the fixture, or none of it when any part fails.
+func (m *MemoryDB) InsertSeedFixture(ctx context.Context, fixture *types.SeedFixture) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertSeedFixture", fixture); err != nil {
+		return
+	}
+	// the fixture only adds, so dropping what it added undoes it
+	campaigns, bugs, organizations, teams, participants := len(m.campaigns), len(m.bugs), len(m.organizations), len(m.teams), len(m.participants)
+	defer func() {
+		if err != nil {
+			m.campaigns, m.bugs, m.organizations = m.campaigns[:campaigns], m.bugs[:bugs], m.organizations[:organizations]
+			m.teams, m.participants = m.teams[:teams], m.participants[:participants]
+		}
+	}()
+
+	for i := range fixture.Campaigns {
+		if fixture.Campaigns[i].ID, err = m.insertCampaign(&fixture.Campaigns[i]); err != nil {
+			return
+		}
+	}
+	for i := range fixture.Bugs {
+		if err = m.insertBug(fixture.Bugs[i].Campaign, &fixture.Bugs[i]); err != nil {
+			return
+		}
+	}
+	for i := range fixture.Organizations {
+		if fixture.Organizations[i].ID, err = m.insertOrganization(&fixture.Organizations[i]); err != nil {
+			return
+		}
+	}
+	for i := range fixture.Teams {
+		if err = m.insertTeam(&fixture.Teams[i]); err != nil {
+			return
+		}
+	}
+	for i := range fixture.Participants {
+		participant := &fixture.Participants[i]
+		if err = m.insertParticipant(participant); err != nil {
+			return
+		}
+		if participant.TeamName == "" {
+			continue
+		}
+		_, err = m.updateParticipantTeam(participant.TeamName, participant.CampaignName, participant.ScpName, participant.LoginName)
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("no team: campaignName: %s, name: %s: %w", participant.CampaignName, participant.TeamName, err)
+		}
+		if err != nil {
+			return
+		}
+	}
+	m.changed()
+	return
+}
+
+func (m *MemoryDB) insertBug(campaignName string, bug *types.BugStruct) (err error) {
+	if m.campaign(campaignName) == nil {
+		return memoryNoCampaign(campaignName)
+	}
+	for _, existing := range m.bugs {
+		if existing.Campaign == campaignName && existing.Category == bug.Category {
+			return &DuplicateError{Entity: "bug"}
+		}
+	}
+	bug.Id = newId()
+	m.bugs = append(m.bugs, &memoryBug{
+		BugStruct: types.BugStruct{Id: bug.Id, Campaign: campaignName, Category: bug.Category, PointValue: bug.PointValue, Version: 1},
+		updatedOn: time.Now().UTC(),
+	})
+	return
+}
+
+func (m *MemoryDB) InsertBug(ctx context.Context, bug *types.BugStruct) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertBug", bug); err != nil {
+		return
+	}
+	err = m.insertBug(bug.Campaign, bug)
+	if err != nil {
+		m.logger.Error("error inserting bug", zap.Any("bug", bug), zap.Error(err))
+	}
+	return
+}
+
+func (m *MemoryDB) UpdateBug(ctx context.Context, bug *types.BugStruct) (rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpdateBug", bug); err != nil {
+		return
+	}
+	for _, existing := range m.bugs {
+		if existing.Campaign == bug.Campaign && existing.Category == bug.Category {
+			if existing.Version != bug.Version {
+				err = &StaleError{Entity: "bug", Version: existing.Version}
+				return
+			}
+			existing.PointValue, existing.updatedOn = bug.PointValue, time.Now().UTC()
+			existing.Version++
+			bug.Version = existing.Version
+			rowsAffected++
+		}
+	}
+	return
+}
+
+// UpsertPointValueOverride sets the point value of a bug category for an organization in the campaign. Returns
+// sql.ErrNoRows if there is no such campaign or organization.
+func (m *MemoryDB) UpsertPointValueOverride(ctx context.Context, override *types.PointValueOverride) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpsertPointValueOverride", override); err != nil {
+		return
+	}
+	campaign := m.campaign(override.CampaignName)
+	if campaign == nil {
+		return sql.ErrNoRows
+	}
+	// an organization of the campaign is preferred to one of every campaign
+	var organization *types.OrganizationStruct
+	for i, candidate := range m.organizations {
+		if strings.EqualFold(candidate.SCPName, override.ScpName) && strings.EqualFold(candidate.Organization, override.Organization) &&
+			(candidate.CampaignName == "" || candidate.CampaignName == campaign.Name) &&
+			(organization == nil || organization.CampaignName == "") {
+			organization = &m.organizations[i]
+		}
+	}
+	if organization == nil {
+		return sql.ErrNoRows
+	}
+
+	for i, existing := range m.pointValueOverrides {
+		if existing.CampaignName == campaign.Name && existing.organizationId == organization.ID && existing.Category == override.Category {
+			m.pointValueOverrides[i].PointValue = override.PointValue
+			override.Id = existing.Id
+			return
+		}
+	}
+	override.Id = newId()
+	m.pointValueOverrides = append(m.pointValueOverrides, memoryPointValueOverride{
+		PointValueOverride: types.PointValueOverride{
+			Id:           override.Id,
+			CampaignName: campaign.Name,
+			Category:     override.Category,
+			PointValue:   override.PointValue,
+		},
+		organizationId: organization.ID,
+	})
+	return
+}
+
+// pointValueOverride copies the override, with the names of its organization.
+func (m *MemoryDB) pointValueOverride(override memoryPointValueOverride) (copied types.PointValueOverride) {
+	copied = override.PointValueOverride
+	organization := m.organizationById(override.organizationId)
+	copied.ScpName, copied.Organization = organization.SCPName, organization.Organization
+	return
+}
+
+func (m *MemoryDB) organizationById(id string) (organization types.OrganizationStruct) {
+	for _, organization = range m.organizations {
+		if organization.ID == id {
+			return
+		}
+	}
+	return types.OrganizationStruct{}
+}
+
+func (m *MemoryDB) SelectPointValueOverrides(ctx context.Context, campaignName string) (overrides []types.PointValueOverride, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectPointValueOverrides", campaignName); err != nil {
+		return
+	}
+	for _, override := range m.pointValueOverrides {
+		if override.CampaignName == campaignName {
+			overrides = append(overrides, m.pointValueOverride(override))
+		}
+	}
+	sort.Slice(overrides, func(i, j int) bool {
+		a, b := overrides[i], overrides[j]
+		if a.ScpName != b.ScpName {
+			return a.ScpName < b.ScpName
+		}
+		if a.Organization != b.Organization {
+			return a.Organization < b.Organization
+		}
+		return a.Category < b.Category
+	})
+	return
+}
+
+func (m *MemoryDB) DeletePointValueOverride(ctx context.Context, override *types.PointValueOverride) (rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("DeletePointValueOverride", override); err != nil {
+		return
+	}
+	kept := m.pointValueOverrides[:0]
+	for _, existing := range m.pointValueOverrides {
+		organization := m.organizationById(existing.organizationId)
+		if existing.CampaignName == override.CampaignName && existing.Category == override.Category &&
+			strings.EqualFold(organization.SCPName, override.ScpName) && strings.EqualFold(organization.Organization, override.Organization) {
+			rowsAffected++
+		} else {
+			kept = append(kept, existing)
+		}
+	}
+	m.pointValueOverrides = kept
+	return
+}
+
+// InsertBugCategoryExclusion keeps a bug category from scoring in the campaign. Returns sql.ErrNoRows if there is no
+// such campaign.
+func (m *MemoryDB) InsertBugCategoryExclusion(ctx context.Context, exclusion *types.BugCategoryExclusion) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertBugCategoryExclusion", exclusion); err != nil {
+		return
+	}
+	if m.campaign(exclusion.CampaignName) == nil {
+		return sql.ErrNoRows
+	}
+	for _, existing := range m.bugExclusions {
+		if existing.CampaignName == exclusion.CampaignName && existing.Category == exclusion.Category {
+			return &DuplicateError{Entity: "bug category exclusion"}
+		}
+	}
+	exclusion.Id = newId()
+	m.bugExclusions = append(m.bugExclusions, *exclusion)
+	return
+}
+
+func (m *MemoryDB) SelectBugCategoryExclusions(ctx context.Context, campaignName string) (exclusions []types.BugCategoryExclusion, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectBugCategoryExclusions", campaignName); err != nil {
+		return
+	}
+	for _, exclusion := range m.bugExclusions {
+		if exclusion.CampaignName == campaignName {
+			exclusions = append(exclusions, exclusion)
+		}
+	}
+	sort.Slice(exclusions, func(i, j int) bool {
+		return exclusions[i].Category < exclusions[j].Category
+	})
+	return
+}
+
+func (m *MemoryDB) DeleteBugCategoryExclusion(ctx context.Context, campaignName, category string) (rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("DeleteBugCategoryExclusion", campaignName, category); err != nil {
+		return
+	}
+	kept := m.bugExclusions[:0]
+	for _, exclusion := range m.bugExclusions {
+		if exclusion.CampaignName == campaignName && exclusion.Category == category {
+			rowsAffected++
+		} else {
+			kept = append(kept, exclusion)
+		}
+	}
+	m.bugExclusions = kept
+	return
+}
+
+// InsertRepositoryFilter adds an allow or deny pattern of the repositories scored in the campaign. Returns
+// sql.ErrNoRows if there is no such campaign.
+func (m *MemoryDB) InsertRepositoryFilter(ctx context.Context, filter *types.RepositoryFilter) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertRepositoryFilter", filter); err != nil {
+		return
+	}
+	if m.campaign(filter.CampaignName) == nil {
+		return sql.ErrNoRows
+	}
+	pattern := strings.ToLower(filter.Pattern)
+	for _, existing := range m.repositoryFilters {
+		if existing.CampaignName == filter.CampaignName && existing.Kind == filter.Kind && existing.Pattern == pattern {
+			return &DuplicateError{Entity: "repository filter"}
+		}
+	}
+	filter.Id, filter.Pattern = newId(), pattern
+	m.repositoryFilters = append(m.repositoryFilters, *filter)
+	return
+}
+
+func (m *MemoryDB) SelectRepositoryFilters(ctx context.Context, campaignName string) (filters []types.RepositoryFilter, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectRepositoryFilters", campaignName); err != nil {
+		return
+	}
+	for _, filter := range m.repositoryFilters {
+		if filter.CampaignName == campaignName {
+			filters = append(filters, filter)
+		}
+	}
+	sort.Slice(filters, func(i, j int) bool {
+		a, b := filters[i], filters[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Pattern < b.Pattern
+	})
+	return
+}
+
+func (m *MemoryDB) DeleteRepositoryFilter(ctx context.Context, campaignName, filterId string) (rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("DeleteRepositoryFilter", campaignName, filterId); err != nil {
+		return
+	}
+	kept := m.repositoryFilters[:0]
+	for _, filter := range m.repositoryFilters {
+		if filter.CampaignName == campaignName && filter.Id == filterId {
+			rowsAffected++
+		} else {
+			kept = append(kept, filter)
+		}
+	}
+	m.repositoryFilters = kept
+	return
+}
+
+func (m *MemoryDB) SelectBugs(ctx context.Context) (bugs []types.BugStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectBugs"); err != nil {
+		return
+	}
+	for _, bug := range m.bugs {
+		bugs = append(bugs, bug.BugStruct)
+	}
+	return
+}
+
+func (m *MemoryDB) SelectBugsInCampaign(ctx context.Context, campaignName string) (bugs []types.BugStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectBugsInCampaign", campaignName); err != nil {
+		return
+	}
+	for _, bug := range m.bugs {
+		if bug.Campaign == campaignName {
+			bugs = append(bugs, bug.BugStruct)
+		}
+	}
+	sort.Slice(bugs, func(i, j int) bool {
+		return bugs[i].Category < bugs[j].Category
+	})
+	return
+}
+
+func (m *MemoryDB) SelectBugsVersion(ctx context.Context) (version types.ListVersion, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectBugsVersion"); err != nil {
+		return
+	}
+	version = types.ListVersion{Count: len(m.bugs), UpdatedOn: memoryEpoch}
+	for _, bug := range m.bugs {
+		version.UpdatedOn = latest(version.UpdatedOn, bug.updatedOn)
+	}
+	return
+}
+
+func copyConfig(config types.CampaignConfigStruct) *types.CampaignConfigStruct {
+	config.Bugs = append([]types.BugStruct(nil), config.Bugs...)
+	return &config
+}
+
+func (m *MemoryDB) UpsertCampaignConfigStage(ctx context.Context, config *types.CampaignConfigStruct) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpsertCampaignConfigStage", config); err != nil {
+		return
+	}
+	if m.campaign(config.Campaign) == nil {
+		return memoryNoCampaign(config.Campaign)
+	}
+	config.UpdatedOn = time.Now().UTC()
+	m.configStages[config.Campaign] = *copyConfig(*config)
+	return
+}
+
+func (m *MemoryDB) SelectCampaignConfigStage(ctx context.Context, campaignName string) (config *types.CampaignConfigStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectCampaignConfigStage", campaignName); err != nil {
+		return
+	}
+	staged, ok := m.configStages[campaignName]
+	if !ok {
+		err = sql.ErrNoRows
+		return
+	}
+	config = copyConfig(staged)
+	return
+}
+
+func (m *MemoryDB) DeleteCampaignConfigStage(ctx context.Context, campaignName string) (rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("DeleteCampaignConfigStage", campaignName); err != nil {
+		return
+	}
+	if _, ok := m.configStages[campaignName]; ok {
+		delete(m.configStages, campaignName)
+		rowsAffected = 1
+	}
+	return
+}
+
+// PromoteCampaignConfigStage replaces the bugs of the campaign with the staged ones, and removes the stage.
+func (m *MemoryDB) PromoteCampaignConfigStage(ctx context.Context, campaignName string) (config *types.CampaignConfigStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("PromoteCampaignConfigStage", campaignName); err != nil {
+		return
+	}
+	staged, ok := m.configStages[campaignName]
+	if !ok {
+		err = sql.ErrNoRows
+		return
+	}
+	categories := map[string]bool{}
+	for _, bug := range staged.Bugs {
+		if categories[bug.Category] {
+			err = &DuplicateError{Entity: "bug"}
+			m.logger.Error("error promoting bug", zap.Any("bug", bug), zap.Error(err))
+			return
+		}
+		categories[bug.Category] = true
+	}
+
+	kept := m.bugs[:0]
+	for _, bug := range m.bugs {
+		if bug.Campaign != campaignName {
+			kept = append(kept, bug)
+		}
+	}
+	m.bugs = kept
+	config = copyConfig(staged)
+	for i := range config.Bugs {
+		if err = m.insertBug(campaignName, &config.Bugs[i]); err != nil {
+			return
+		}
+	}
+	delete(m.configStages, campaignName)
+	return
+}
+
+func (m *MemoryDB) UpsertSlackNotification(ctx context.Context, config *types.SlackNotificationStruct) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpsertSlackNotification", config); err != nil {
+		return
+	}
+	if m.campaign(config.CampaignName) == nil {
+		return sql.ErrNoRows
+	}
+	m.slackNotifications[config.CampaignName] = *config
+	return
+}
+
+func (m *MemoryDB) SelectSlackNotification(ctx context.Context, campaignName string) (config *types.SlackNotificationStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectSlackNotification", campaignName); err != nil {
+		return
+	}
+	existing, ok := m.slackNotifications[campaignName]
+	if !ok {
+		err = sql.ErrNoRows
+		return
+	}
+	config = &existing
+	return
+}
+
+func (m *MemoryDB) DeleteSlackNotification(ctx context.Context, campaignName string) (rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("DeleteSlackNotification", campaignName); err != nil {
+		return
+	}
+	if _, ok := m.slackNotifications[campaignName]; ok {
+		delete(m.slackNotifications, campaignName)
+		rowsAffected = 1
+	}
+	return
+}
+
+func (m *MemoryDB) UpsertCampaignPenalty(ctx context.Context, penalty *types.CampaignPenaltyStruct) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpsertCampaignPenalty", penalty); err != nil {
+		return
+	}
+	if m.campaign(penalty.CampaignName) == nil {
+		return sql.ErrNoRows
+	}
+	m.penalties[penalty.CampaignName] = *penalty
+	return
+}
+
+func (m *MemoryDB) UpsertCampaignCaps(ctx context.Context, caps *types.CampaignCapsStruct) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpsertCampaignCaps", caps); err != nil {
+		return
+	}
+	if m.campaign(caps.CampaignName) == nil {
+		return sql.ErrNoRows
+	}
+	m.caps[caps.CampaignName] = *caps
+	return
+}
+
+func (m *MemoryDB) UpsertDuplicatePolicy(ctx context.Context, policy *types.DuplicatePolicyStruct) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpsertDuplicatePolicy", policy); err != nil {
+		return
+	}
+	if m.campaign(policy.CampaignName) == nil {
+		return sql.ErrNoRows
+	}
+	m.duplicatePolicies[policy.CampaignName] = *policy
+	return
+}
+
+func (m *MemoryDB) SelectDuplicatePolicy(ctx context.Context, campaignName string) (policy *types.DuplicatePolicyStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectDuplicatePolicy", campaignName); err != nil {
+		return
+	}
+	existing, ok := m.duplicatePolicies[campaignName]
+	if !ok {
+		err = sql.ErrNoRows
+		return
+	}
+	policy = &existing
+	return
+}
+
+func (m *MemoryDB) UpsertCoAuthorPolicy(ctx context.Context, policy *types.CoAuthorPolicyStruct) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpsertCoAuthorPolicy", policy); err != nil {
+		return
+	}
+	if m.campaign(policy.CampaignName) == nil {
+		return sql.ErrNoRows
+	}
+	m.coAuthorPolicies[policy.CampaignName] = *policy
+	return
+}
+
+func (m *MemoryDB) SelectCoAuthorPolicy(ctx context.Context, campaignName string) (policy *types.CoAuthorPolicyStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectCoAuthorPolicy", campaignName); err != nil {
+		return
+	}
+	existing, ok := m.coAuthorPolicies[campaignName]
+	if !ok {
+		err = sql.ErrNoRows
+		return
+	}
+	policy = &existing
+	return
+}
+
+func (m *MemoryDB) UpsertCampaignPrivacy(ctx context.Context, privacy *types.CampaignPrivacyStruct) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpsertCampaignPrivacy", privacy); err != nil {
+		return
+	}
+	if m.campaign(privacy.CampaignName) == nil {
+		return sql.ErrNoRows
+	}
+	if existing, ok := m.privacies[privacy.CampaignName]; ok {
+		privacy.Salt = existing.Salt
+	} else {
+		privacy.Salt = newId()
+	}
+	m.privacies[privacy.CampaignName] = *privacy
+	return
+}
+
+func (m *MemoryDB) SelectCampaignPrivacy(ctx context.Context, campaignName string) (privacy *types.CampaignPrivacyStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectCampaignPrivacy", campaignName); err != nil {
+		return
+	}
+	existing, ok := m.privacies[campaignName]
+	if !ok {
+		err = sql.ErrNoRows
+		return
+	}
+	privacy = &existing
+	return
+}
+
+func (m *MemoryDB) SelectCampaignCaps(ctx context.Context, campaignName string) (caps *types.CampaignCapsStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectCampaignCaps", campaignName); err != nil {
+		return
+	}
+	existing, ok := m.caps[campaignName]
+	if !ok {
+		err = sql.ErrNoRows
+		return
+	}
+	caps = &existing
+	return
+}
+
+func (m *MemoryDB) SelectCampaignPenalty(ctx context.Context, campaignName string) (penalty *types.CampaignPenaltyStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectCampaignPenalty", campaignName); err != nil {
+		return
+	}
+	existing, ok := m.penalties[campaignName]
+	if !ok {
+		err = sql.ErrNoRows
+		return
+	}
+	penalty = &existing
+	return
+}
+
+func (m *MemoryDB) SelectUnclassifiedBonus(ctx context.Context, campaignName string) (bonus float64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectUnclassifiedBonus", campaignName); err != nil {
+		return
+	}
+	campaign := m.campaign(campaignName)
+	if campaign == nil {
+		err = sql.ErrNoRows
+		return
+	}
+	bonus = *campaign.UnclassifiedBonus
+	return
+}
+
+func (m *MemoryDB) SelectTopParticipants(ctx context.Context, campaignName string, count int) (participants []types.ParticipantStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectTopParticipants", campaignName, count); err != nil {
+		return
+	}
+	var top []*memoryParticipant
+	for _, participant := range m.participants {
+		if participant.CampaignName == campaignName {
+			top = append(top, participant)
+		}
+	}
+	sort.SliceStable(top, func(i, j int) bool {
+		if top[i].Score != top[j].Score {
+			return top[i].Score > top[j].Score
+		}
+		return top[i].JoinedAt.Before(top[j].JoinedAt)
+	})
+	for i := 0; i < len(top) && i < count; i++ {
+		participants = append(participants, types.ParticipantStruct{
+			ID: top[i].ID, CampaignName: campaignName, LoginName: top[i].LoginName, Score: top[i].Score,
+		})
+	}
+	return
+}
+
+// SelectLeaderboardStale is whether a participant, team or campaign changed since the leaderboard was refreshed.
+func (m *MemoryDB) SelectLeaderboardStale(ctx context.Context) (stale bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectLeaderboardStale"); err != nil {
+		return
+	}
+	stale = m.changeCount != m.rankedChangeCount
+	return
+}
+
+// RefreshLeaderboard ranks the participants of every campaign by score, then by the tie breaker of the campaign,
+// then by login and source control provider.
+func (m *MemoryDB) RefreshLeaderboard(ctx context.Context) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("RefreshLeaderboard"); err != nil {
+		return
+	}
+	type activity struct {
+		reachedScoreOn *time.Time
+		categories     map[string]bool
+	}
+	activities := map[*memoryParticipant]*activity{}
+	for _, event := range m.scoringEvents {
+		participant := m.participant(event.CampaignName, event.ScpName, event.UserName)
+		if event.VoidedOn != nil || participant == nil {
+			continue
+		}
+		scored := activities[participant]
+		if scored == nil {
+			scored = &activity{categories: map[string]bool{}}
+			activities[participant] = scored
+		}
+		if scored.reachedScoreOn == nil || event.scoredOn.After(*scored.reachedScoreOn) {
+			scored.reachedScoreOn = copyTime(&event.scoredOn)
+		}
+		if event.Breakdown != nil {
+			for _, category := range event.Breakdown.Categories {
+				scored.categories[category.Category] = true
+			}
+		}
+	}
+
+	m.ranks = nil
+	for _, campaign := range m.campaigns {
+		var ranked []*memoryParticipant
+		for _, participant := range m.participants {
+			if participant.CampaignName == campaign.Name {
+				ranked = append(ranked, participant)
+			}
+		}
+		tieBreaker := campaign.TieBreaker
+		sort.SliceStable(ranked, func(i, j int) bool {
+			a, b := ranked[i], ranked[j]
+			if a.Score != b.Score {
+				return a.Score > b.Score
+			}
+			aActivity, bActivity := activities[a], activities[b]
+			if aActivity == nil {
+				aActivity = &activity{}
+			}
+			if bActivity == nil {
+				bActivity = &activity{}
+			}
+			switch tieBreaker {
+			case types.TieBreakerReachedScore:
+				aOn, bOn := aActivity.reachedScoreOn, bActivity.reachedScoreOn
+				if (aOn == nil) != (bOn == nil) {
+					return aOn != nil
+				}
+				if aOn != nil && !aOn.Equal(*bOn) {
+					return aOn.Before(*bOn)
+				}
+			case types.TieBreakerBugCategories:
+				if len(aActivity.categories) != len(bActivity.categories) {
+					return len(aActivity.categories) > len(bActivity.categories)
+				}
+			case types.TieBreakerJoinedAt:
+				if !a.JoinedAt.Equal(b.JoinedAt) {
+					return a.JoinedAt.Before(b.JoinedAt)
+				}
+			}
+			if a.LoginName != b.LoginName {
+				return a.LoginName < b.LoginName
+			}
+			return a.ScpName < b.ScpName
+		})
+		for i, participant := range ranked {
+			entry := m.participantStruct(participant)
+			m.ranks = append(m.ranks, memoryRank{
+				LeaderboardEntry: types.LeaderboardEntry{
+					Rank:        i + 1,
+					ScpName:     entry.ScpName,
+					LoginName:   entry.LoginName,
+					DisplayName: entry.DisplayName,
+					AvatarUrl:   entry.AvatarUrl,
+					TeamName:    entry.TeamName,
+					Score:       entry.Score,
+				},
+				campaignName: campaign.Name,
+			})
+		}
+	}
+	m.refreshedOn = time.Now().UTC()
+	m.rankedChangeCount = m.changeCount
+	return
+}
+
+// SelectLeaderboard lists the ranks of the campaign as of the last refresh, up to limit. sql.ErrNoRows is returned
+// when there is no such campaign.
+func (m *MemoryDB) SelectLeaderboard(ctx context.Context, campaignName string, limit int) (leaderboard *types.Leaderboard, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectLeaderboard", campaignName, limit); err != nil {
+		return
+	}
+	campaign := m.campaign(campaignName)
+	if campaign == nil {
+		err = sql.ErrNoRows
+		return
+	}
+	leaderboard = &types.Leaderboard{
+		CampaignName:   campaignName,
+		TieBreaker:     campaign.TieBreaker,
+		TieBreakerRule: types.TieBreakerRules[campaign.TieBreaker],
+		Participants:   []types.LeaderboardEntry{},
+	}
+	for _, rank := range m.ranks {
+		if rank.campaignName == campaignName && len(leaderboard.Participants) < limit {
+			leaderboard.Participants = append(leaderboard.Participants, rank.LeaderboardEntry)
+			leaderboard.RefreshedOn = copyTime(&m.refreshedOn)
+		}
+	}
+	return
+}
+
+// SelectTeamLeaderboard ranks the members of the team as of the last refresh, with the points of the team.
+// sql.ErrNoRows is returned when there is no such team.
+func (m *MemoryDB) SelectTeamLeaderboard(ctx context.Context, campaignName, teamName string) (leaderboard *types.TeamLeaderboard, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectTeamLeaderboard", campaignName, teamName); err != nil {
+		return
+	}
+	team := m.team(campaignName, teamName)
+	if team == nil {
+		err = sql.ErrNoRows
+		return
+	}
+	leaderboard = &types.TeamLeaderboard{
+		CampaignName: campaignName,
+		TeamStanding: types.TeamStanding{TeamName: teamName, AwardPoints: m.awardPoints(team.Id)},
+		Participants: []types.LeaderboardEntry{},
+	}
+	for _, rank := range m.ranks {
+		if rank.campaignName == campaignName && rank.TeamName == teamName {
+			entry := rank.LeaderboardEntry
+			entry.Rank = len(leaderboard.Participants) + 1
+			leaderboard.Participants = append(leaderboard.Participants, entry)
+			leaderboard.MemberPoints += entry.Score
+			leaderboard.RefreshedOn = copyTime(&m.refreshedOn)
+		}
+	}
+	leaderboard.Points = leaderboard.MemberPoints + leaderboard.AwardPoints
+	return
+}
+
+func (m *MemoryDB) awardPoints(teamId string) (points float64) {
+	for _, event := range m.teamScoreEvents {
+		if event.teamId == teamId {
+			points += event.Points
+		}
+	}
+	return
+}
+
+// InsertLeaderboardSnapshot keeps the ranks of the campaign as of the last refresh, as its final leaderboard. A
+// campaign is only finalized once, so finalized is false when it already was, or there is no such campaign.
+func (m *MemoryDB) InsertLeaderboardSnapshot(ctx context.Context, campaignName string, now time.Time) (finalized bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertLeaderboardSnapshot", campaignName, now); err != nil {
+		return
+	}
+	campaign := m.campaign(campaignName)
+	if campaign == nil {
+		return
+	}
+	if _, ok := m.snapshots[campaignName]; ok {
+		return
+	}
+	snapshot := types.LeaderboardSnapshot{
+		CampaignName:   campaignName,
+		TieBreaker:     campaign.TieBreaker,
+		TieBreakerRule: types.TieBreakerRules[campaign.TieBreaker],
+		FinalizedOn:    now,
+		Participants:   []types.LeaderboardEntry{},
+	}
+	for _, rank := range m.ranks {
+		if rank.campaignName == campaignName {
+			snapshot.Participants = append(snapshot.Participants, rank.LeaderboardEntry)
+		}
+	}
+	m.snapshots[campaignName] = snapshot
+	finalized = true
+	return
+}
+
+func (m *MemoryDB) SelectLeaderboardSnapshot(ctx context.Context, campaignName string) (snapshot *types.LeaderboardSnapshot, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectLeaderboardSnapshot", campaignName); err != nil {
+		return
+	}
+	existing, ok := m.snapshots[campaignName]
+	if !ok {
+		err = sql.ErrNoRows
+		return
+	}
+	existing.Participants = append([]types.LeaderboardEntry{}, existing.Participants...)
+	snapshot = &existing
+	return
+}
+
+func (m *MemoryDB) UpsertCampaignEmail(ctx context.Context, email *types.CampaignEmailStruct) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpsertCampaignEmail", email); err != nil {
+		return
+	}
+	if m.campaign(email.CampaignName) == nil {
+		return sql.ErrNoRows
+	}
+	for i, existing := range m.emails {
+		if existing.CampaignName == email.CampaignName && existing.Kind == email.Kind {
+			m.emails[i] = *email
+			return
+		}
+	}
+	m.emails = append(m.emails, *email)
+	return
+}
+
+func (m *MemoryDB) SelectCampaignEmails(ctx context.Context, campaignName string) (emails []types.CampaignEmailStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectCampaignEmails", campaignName); err != nil {
+		return
+	}
+	for _, email := range m.emails {
+		if email.CampaignName == campaignName {
+			emails = append(emails, email)
+		}
+	}
+	sort.Slice(emails, func(i, j int) bool {
+		return emails[i].Kind < emails[j].Kind
+	})
+	return
+}
+
+func (m *MemoryDB) SelectCampaignEmail(ctx context.Context, campaignName, kind string) (email *types.CampaignEmailStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectCampaignEmail", campaignName, kind); err != nil {
+		return
+	}
+	for _, existing := range m.emails {
+		if existing.CampaignName == campaignName && existing.Kind == kind {
+			found := existing
+			return &found, nil
+		}
+	}
+	err = sql.ErrNoRows
+	return
+}
+
+// SelectBugsFixed counts the bugs fixed by the participant in pull requests that were not voided.
+func (m *MemoryDB) SelectBugsFixed(ctx context.Context, participant *types.ParticipantStruct) (bugsFixed float64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectBugsFixed", participant); err != nil {
+		return
+	}
+	for _, event := range m.scoringEvents {
+		if event.CampaignName == participant.CampaignName && event.ScpName == participant.ScpName &&
+			event.UserName == participant.LoginName && event.VoidedOn == nil && event.Breakdown != nil {
+			bugsFixed += event.Breakdown.BugsFixed()
+		}
+	}
+	return
+}
+
+// InsertAchievement awards the achievement, unless the participant has it already.
+func (m *MemoryDB) InsertAchievement(ctx context.Context, participantId, kind string, now time.Time) (awarded bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertAchievement", participantId, kind, now); err != nil {
+		return
+	}
+	if m.participantById(participantId) == nil {
+		err = fmt.Errorf("no participant: id: %s", participantId)
+		return
+	}
+	for _, achievement := range m.achievements {
+		if achievement.participantId == participantId && achievement.Kind == kind {
+			return
+		}
+	}
+	m.achievements = append(m.achievements, memoryAchievement{
+		AchievementStruct: types.AchievementStruct{Kind: kind, AwardedOn: now},
+		participantId:     participantId,
+	})
+	awarded = true
+	return
+}
+
+func (m *MemoryDB) SelectAchievements(ctx context.Context, campaignName, scpName, loginName string) (achievements []types.AchievementStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectAchievements", campaignName, scpName, loginName); err != nil {
+		return
+	}
+	participant := m.participant(campaignName, scpName, loginName)
+	if participant == nil {
+		return
+	}
+	for _, achievement := range m.achievements {
+		if achievement.participantId == participant.ID {
+			achievements = append(achievements, achievement.AchievementStruct)
+		}
+	}
+	sort.Slice(achievements, func(i, j int) bool {
+		if !achievements[i].AwardedOn.Equal(achievements[j].AwardedOn) {
+			return achievements[i].AwardedOn.Before(achievements[j].AwardedOn)
+		}
+		return achievements[i].Kind < achievements[j].Kind
+	})
+	return
+}
+
+// SelectParticipantScoreAt rebuilds the score of the participant at a point in time, from the latest revision of each
+// scoring event and share of a co-author by then. sql.ErrNoRows is returned when there is no such participant.
+func (m *MemoryDB) SelectParticipantScoreAt(ctx context.Context, campaignName, scpName, loginName string, at time.Time) (score *types.ParticipantScoreAt, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectParticipantScoreAt", campaignName, scpName, loginName, at); err != nil {
+		return
+	}
+	if m.participant(campaignName, scpName, loginName) == nil {
+		err = sql.ErrNoRows
+		return
+	}
+	score = &types.ParticipantScoreAt{CampaignName: campaignName, ScpName: scpName, LoginName: loginName, At: at}
+	for _, event := range m.scoringEvents {
+		if event.CampaignName != campaignName || event.ScpName != scpName {
+			continue
+		}
+		// the latest revision of the event, and of the share, by then; a later one of the same time wins
+		latest := map[bool]*memoryRevision{}
+		for i := range event.revisions {
+			revision := &event.revisions[i]
+			if revision.loginName != loginName || revision.revisedOn.After(at) {
+				continue
+			}
+			if prior, ok := latest[revision.coAuthor]; !ok || !revision.revisedOn.Before(prior.revisedOn) {
+				latest[revision.coAuthor] = revision
+			}
+		}
+		for _, revision := range latest {
+			score.Score += revision.points
+			if !revision.coAuthor && !revision.voided {
+				score.ScoringEvents++
+			}
+		}
+	}
+	return
+}
+
+func (m *MemoryDB) SelectAchievementStats(ctx context.Context, campaignName string) (stats []types.AchievementStat, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectAchievementStats", campaignName); err != nil {
+		return
+	}
+	counts := map[string]int{}
+	for _, achievement := range m.achievements {
+		if participant := m.participantById(achievement.participantId); participant != nil && participant.CampaignName == campaignName {
+			counts[achievement.Kind]++
+		}
+	}
+	for kind, participants := range counts {
+		stats = append(stats, types.AchievementStat{Kind: kind, Participants: participants})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Kind < stats[j].Kind
+	})
+	return
+}
+
+func (m *MemoryDB) InsertWebhook(ctx context.Context, hook *types.WebhookStruct) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertWebhook", hook); err != nil {
+		return
+	}
+	if hook.Events == nil {
+		hook.Events = []string{}
+	}
+	hook.ID, hook.CreatedOn = newId(), time.Now().UTC()
+	inserted := *hook
+	inserted.Events = append([]string{}, hook.Events...)
+	m.webhooks = append(m.webhooks, inserted)
+	return
+}
+
+func (m *MemoryDB) SelectWebhooks(ctx context.Context) (hooks []types.WebhookStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectWebhooks"); err != nil {
+		return
+	}
+	for _, hook := range m.webhooks {
+		hook.Events = append([]string{}, hook.Events...)
+		hooks = append(hooks, hook)
+	}
+	return
+}
+
+func (m *MemoryDB) DeleteWebhook(ctx context.Context, webhookId string) (rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("DeleteWebhook", webhookId); err != nil {
+		return
+	}
+	kept := m.webhooks[:0]
+	for _, hook := range m.webhooks {
+		if hook.ID == webhookId {
+			rowsAffected++
+		} else {
+			kept = append(kept, hook)
+		}
+	}
+	m.webhooks = kept
+	return
+}
+
+// ClaimCampaignTransitions moves each campaign to the status it has at now, and returns the campaigns that changed
+// status since the last call.
+func (m *MemoryDB) ClaimCampaignTransitions(ctx context.Context, now time.Time) (transitions []types.CampaignTransition, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("ClaimCampaignTransitions", now); err != nil {
+		return
+	}
+	for _, campaign := range m.campaigns {
+		toStatus := types.CampaignStatusEnded
+		if now.Before(campaign.StartOn) {
+			toStatus = types.CampaignStatusUpcoming
+		} else if now.Before(campaign.EndOn) {
+			toStatus = types.CampaignStatusActive
+		}
+		if toStatus == campaign.Status {
+			continue
+		}
+
+		transition := types.CampaignTransition{FromStatus: campaign.Status, FirstEnd: !campaign.endedNotified}
+		campaign.Status = toStatus
+		if toStatus == types.CampaignStatusEnded {
+			campaign.endedNotified = true
+		}
+		campaign.updatedOn = time.Now().UTC()
+		transition.Campaign = copyCampaign(campaign.CampaignStruct)
+		transitions = append(transitions, transition)
+	}
+	if len(transitions) > 0 {
+		m.changed()
+	}
+	return
+}
+
+func (m *MemoryDB) InsertNotification(ctx context.Context, notification *types.NotificationStruct) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertNotification", notification); err != nil {
+		return
+	}
+	if m.participantById(notification.ParticipantId) == nil {
+		err = fmt.Errorf("no participant: id: %s", notification.ParticipantId)
+		m.logger.Error("error inserting notification", zap.Any("notification", notification), zap.Error(err))
+		return
+	}
+	notification.Id = newId()
+	m.notifications = append(m.notifications, *notification)
+	return
+}
+
+func (m *MemoryDB) SelectNotifications(ctx context.Context, campaignName, scpName, loginName string, unreadOnly bool) (notifications []types.NotificationStruct, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectNotifications", campaignName, scpName, loginName, unreadOnly); err != nil {
+		return
+	}
+	participant := m.participant(campaignName, scpName, loginName)
+	if participant == nil {
+		return
+	}
+	for _, notification := range m.notifications {
+		if notification.ParticipantId == participant.ID && !(unreadOnly && notification.ReadOn.Valid) {
+			notifications = append(notifications, notification)
+		}
+	}
+	sort.SliceStable(notifications, func(i, j int) bool {
+		return notifications[i].CreatedOn.After(notifications[j].CreatedOn)
+	})
+	return
+}
+
+// UpdateNotificationsRead marks the unread notifications of the participant read, or only the one with the id when
+// notificationId is set.
+func (m *MemoryDB) UpdateNotificationsRead(ctx context.Context, campaignName, scpName, loginName, notificationId string, now time.Time) (rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpdateNotificationsRead", campaignName, scpName, loginName, notificationId, now); err != nil {
+		return
+	}
+	participant := m.participant(campaignName, scpName, loginName)
+	if participant == nil {
+		return
+	}
+	for i, notification := range m.notifications {
+		if notification.ParticipantId == participant.ID && !notification.ReadOn.Valid &&
+			(notificationId == "" || notification.Id == notificationId) {
+			m.notifications[i].ReadOn = sql.NullTime{Time: now, Valid: true}
+			rowsAffected++
+		}
+	}
+	return
+}
+
+// truncateDay is the start of the UTC day of t
+func truncateDay(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// SelectCampaignReport assembles the end of campaign report. The topCount limits the number of participants and
+// teams listed as winners. sql.ErrNoRows is returned when there is no such campaign.
+func (m *MemoryDB) SelectCampaignReport(ctx context.Context, campaignName string, topCount int, now time.Time) (report *types.CampaignReport, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectCampaignReport", campaignName, topCount, now); err != nil {
+		return
+	}
+	campaign := m.campaign(campaignName)
+	if campaign == nil {
+		err = sql.ErrNoRows
+		return
+	}
+	report = &types.CampaignReport{Campaign: copyCampaign(campaign.CampaignStruct), GeneratedOn: now}
+
+	timeline := map[time.Time]*types.TimelineDay{}
+	timelineDay := func(t time.Time) *types.TimelineDay {
+		day := truncateDay(t)
+		if timeline[day] == nil {
+			timeline[day] = &types.TimelineDay{Day: day}
+		}
+		return timeline[day]
+	}
+
+	var participants []types.ParticipantStruct
+	for _, participant := range m.participants {
+		if participant.CampaignName == campaignName {
+			participants = append(participants, m.participantStruct(participant))
+			timelineDay(participant.JoinedAt).ParticipantsJoined++
+		}
+	}
+	report.Stats.Participants = len(participants)
+	sort.SliceStable(participants, func(i, j int) bool {
+		if participants[i].Score != participants[j].Score {
+			return participants[i].Score > participants[j].Score
+		}
+		return participants[i].LoginName < participants[j].LoginName
+	})
+	for i := 0; i < len(participants) && i < topCount; i++ {
+		top := participants[i]
+		report.TopParticipants = append(report.TopParticipants, types.ParticipantStruct{
+			ID: top.ID, CampaignName: top.CampaignName, ScpName: top.ScpName, LoginName: top.LoginName,
+			DisplayName: top.DisplayName, Score: top.Score, TeamName: top.TeamName, JoinedAt: top.JoinedAt,
+		})
+	}
+
+	var teams []types.TeamStanding
+	for _, team := range m.teams {
+		if team.CampaignName != campaignName {
+			continue
+		}
+		standing := types.TeamStanding{TeamName: team.Name, AwardPoints: m.awardPoints(team.Id)}
+		for _, participant := range m.participants {
+			if participant.teamId == team.Id {
+				standing.MemberPoints += participant.Score
+			}
+		}
+		standing.Points = standing.MemberPoints + standing.AwardPoints
+		report.Stats.JudgedAwardPoints += standing.AwardPoints
+		teams = append(teams, standing)
+	}
+	report.Stats.Teams = len(teams)
+	sort.SliceStable(teams, func(i, j int) bool {
+		if teams[i].Points != teams[j].Points {
+			return teams[i].Points > teams[j].Points
+		}
+		return teams[i].TeamName < teams[j].TeamName
+	})
+	if len(teams) > topCount {
+		teams = teams[:topCount]
+	}
+	report.TopTeams = teams
+
+	categories := map[string]*types.ReportCategory{}
+	repositories := map[[2]string]*types.RepositoryImpact{}
+	for _, event := range m.scoringEvents {
+		if event.CampaignName != campaignName || event.VoidedOn != nil {
+			continue
+		}
+		report.Stats.PullRequests++
+		report.Stats.Points += event.Points
+		if event.Breakdown != nil {
+			for _, categoryScore := range event.Breakdown.Categories {
+				category := categories[categoryScore.Category]
+				if category == nil {
+					category = &types.ReportCategory{Category: categoryScore.Category}
+					categories[categoryScore.Category] = category
+				}
+				category.Count += categoryScore.Count
+				category.Points += categoryScore.Points
+			}
+		}
+		key := [2]string{event.RepoOwner, event.RepoName}
+		repository := repositories[key]
+		if repository == nil {
+			repository = &types.RepositoryImpact{RepoOwner: event.RepoOwner, RepoName: event.RepoName}
+			repositories[key] = repository
+		}
+		repository.PullRequests++
+		repository.Points += event.Points
+		day := timelineDay(event.scoredOn)
+		day.PullRequests++
+		day.Points += event.Points
+	}
+
+	for _, category := range categories {
+		report.Categories = append(report.Categories, *category)
+	}
+	sort.Slice(report.Categories, func(i, j int) bool {
+		a, b := report.Categories[i], report.Categories[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		return a.Category < b.Category
+	})
+	for _, repository := range repositories {
+		report.Repositories = append(report.Repositories, *repository)
+	}
+	sort.Slice(report.Repositories, func(i, j int) bool {
+		a, b := report.Repositories[i], report.Repositories[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.RepoOwner != b.RepoOwner {
+			return a.RepoOwner < b.RepoOwner
+		}
+		return a.RepoName < b.RepoName
+	})
+	for _, day := range timeline {
+		report.Timeline = append(report.Timeline, *day)
+	}
+	sort.Slice(report.Timeline, func(i, j int) bool {
+		return report.Timeline[i].Day.Before(report.Timeline[j].Day)
+	})
+	return
+}
+
+func (m *MemoryDB) UpsertCampaignReport(ctx context.Context, report *types.CampaignReport, html string) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpsertCampaignReport", report, html); err != nil {
+		return
+	}
+	if m.campaign(report.Campaign.Name) == nil {
+		return memoryNoCampaign(report.Campaign.Name)
+	}
+	reportJson, err := json.Marshal(report)
+	if err != nil {
+		return
+	}
+	m.reports[report.Campaign.Name] = memoryReport{reportJson: reportJson, html: html}
+	return
+}
+
+func (m *MemoryDB) SelectStoredCampaignReport(ctx context.Context, campaignName string) (reportJson []byte, html string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectStoredCampaignReport", campaignName); err != nil {
+		return
+	}
+	stored, ok := m.reports[campaignName]
+	if !ok {
+		err = sql.ErrNoRows
+		return
+	}
+	reportJson, html = append([]byte(nil), stored.reportJson...), stored.html
+	return
+}
+
+// memoryTruncations start the interval of a time series holding a time, for each date_trunc field postgres takes. A
+// week starts on Monday, as it does for date_trunc.
+var memoryTruncations = map[string]func(t time.Time) time.Time{
+	"hour": func(t time.Time) time.Time {
+		return t.UTC().Truncate(time.Hour)
+	},
+	"day": truncateDay,
+	"week": func(t time.Time) time.Time {
+		day := truncateDay(t)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	},
+}
+
+// SelectScoreTimeSeries sums the points scored in each interval of the campaign, an interval being one of the
+// memoryTruncations. When byTeam is set there is a point for each team scoring in an interval.
+func (m *MemoryDB) SelectScoreTimeSeries(ctx context.Context, campaignName, interval string, byTeam bool) (points []types.TimeSeriesPoint, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectScoreTimeSeries", campaignName, interval, byTeam); err != nil {
+		return
+	}
+	truncate, ok := memoryTruncations[interval]
+	if !ok {
+		err = fmt.Errorf("invalid interval: %s", interval)
+		return
+	}
+
+	type key struct {
+		start    time.Time
+		teamName string
+	}
+	sums := map[key]float64{}
+	for _, event := range m.scoringEvents {
+		if event.CampaignName != campaignName || event.VoidedOn != nil {
+			continue
+		}
+		point := key{start: truncate(event.scoredOn)}
+		if participant := m.participant(event.CampaignName, event.ScpName, event.UserName); byTeam && participant != nil {
+			point.teamName = m.participantStruct(participant).TeamName
+		}
+		sums[point] += event.Points
+	}
+	for point, sum := range sums {
+		points = append(points, types.TimeSeriesPoint{Start: point.start, TeamName: point.teamName, Points: sum})
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if !points[i].Start.Equal(points[j].Start) {
+			return points[i].Start.Before(points[j].Start)
+		}
+		return points[i].TeamName < points[j].TeamName
+	})
+	return
+}
+
+func (m *MemoryDB) InsertTelemetryEvent(ctx context.Context, feature, call string, now time.Time) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertTelemetryEvent", feature, call, now); err != nil {
+		return
+	}
+	m.telemetry = append(m.telemetry, memoryTelemetryEvent{feature: feature, createdOn: now})
+	return
+}
+
+func (m *MemoryDB) SelectTelemetryUsage(ctx context.Context, from, to time.Time) (usage []types.TelemetryUsage, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectTelemetryUsage", from, to); err != nil {
+		return
+	}
+	calls := map[string]int{}
+	for _, event := range m.telemetry {
+		if !event.createdOn.Before(from) && event.createdOn.Before(to) {
+			calls[event.feature]++
+		}
+	}
+	for feature, count := range calls {
+		usage = append(usage, types.TelemetryUsage{Feature: feature, Calls: count})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Calls != usage[j].Calls {
+			return usage[i].Calls > usage[j].Calls
+		}
+		return usage[i].Feature < usage[j].Feature
+	})
+	return
+}
+
+func (m *MemoryDB) InsertAuditLog(ctx context.Context, entry *types.AuditLogEntry) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertAuditLog", entry); err != nil {
+		return
+	}
+	entry.ID = newId()
+	m.auditLog = append(m.auditLog, *entry)
+	return
+}
+
+func (m *MemoryDB) SelectAuditLog(ctx context.Context, actor string, limit int) (entries []types.AuditLogEntry, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectAuditLog", actor, limit); err != nil {
+		return
+	}
+	for _, entry := range m.auditLog {
+		if actor == "" || entry.Actor == actor {
+			entries = append(entries, entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedOn.After(entries[j].CreatedOn)
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return
+}
+
+func (m *MemoryDB) UpsertClusterInstance(ctx context.Context, instance *types.ClusterInstance) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpsertClusterInstance", instance); err != nil {
+		return
+	}
+	upserted := *instance
+	upserted.Alive = false
+	for i, existing := range m.clusterInstances {
+		if existing.InstanceId == instance.InstanceId {
+			m.clusterInstances[i] = upserted
+			return
+		}
+	}
+	m.clusterInstances = append(m.clusterInstances, upserted)
+	return
+}
+
+func (m *MemoryDB) SelectClusterInstances(ctx context.Context) (instances []types.ClusterInstance, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectClusterInstances"); err != nil {
+		return
+	}
+	instances = append(instances, m.clusterInstances...)
+	sort.SliceStable(instances, func(i, j int) bool {
+		return instances[i].LastHeartbeat.After(instances[j].LastHeartbeat)
+	})
+	return
+}
+
+func (m *MemoryDB) AcquirePollLease(ctx context.Context, instanceId string, now, expiresOn time.Time) (held bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("AcquirePollLease", instanceId, now, expiresOn); err != nil {
+		return
+	}
+	if m.pollLeaseHolder != "" && m.pollLeaseHolder != instanceId && m.pollLeaseExpiresOn.After(now) {
+		return
+	}
+	m.pollLeaseHolder, m.pollLeaseExpiresOn = instanceId, expiresOn
+	return true, nil
+}
+
+func (m *MemoryDB) ReleasePollLease(ctx context.Context, instanceId string) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("ReleasePollLease", instanceId); err != nil {
+		return
+	}
+	if m.pollLeaseHolder == instanceId {
+		m.pollLeaseHolder, m.pollLeaseExpiresOn = "", time.Time{}
+	}
+	return
+}
+
+func (m *MemoryDB) UpsertScheduledJobRun(ctx context.Context, run *types.ScheduledJobRun) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpsertScheduledJobRun", run); err != nil {
+		return
+	}
+	upserted := *run
+	upserted.Runs, upserted.Failures = 1, 0
+	if existing, ok := m.scheduledJobRuns[run.Name]; ok {
+		upserted.Runs, upserted.Failures = existing.Runs+1, existing.Failures
+	}
+	if run.Error != "" {
+		upserted.Failures++
+	}
+	m.scheduledJobRuns[run.Name] = upserted
+	return
+}
+
+func (m *MemoryDB) SelectScheduledJobRuns(ctx context.Context) (runs []types.ScheduledJobRun, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectScheduledJobRuns"); err != nil {
+		return
+	}
+	for _, run := range m.scheduledJobRuns {
+		runs = append(runs, run)
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Name < runs[j].Name
+	})
+	return
+}
+
+func (m *MemoryDB) SelectMaintenance(ctx context.Context) (state types.MaintenanceState, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectMaintenance"); err != nil {
+		return
+	}
+	return m.maintenance, nil
+}
+
+// UpdateMaintenance keeps the time maintenance was turned on while it stays on.
+func (m *MemoryDB) UpdateMaintenance(ctx context.Context, enabled bool, message string, now time.Time) (state types.MaintenanceState, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpdateMaintenance", enabled, message, now); err != nil {
+		return
+	}
+	if !enabled {
+		m.maintenance = types.MaintenanceState{}
+		return m.maintenance, nil
+	}
+	if !m.maintenance.Enabled {
+		since := now.UTC()
+		m.maintenance.Enabled, m.maintenance.Since = true, &since
+	}
+	m.maintenance.Message = message
+	return m.maintenance, nil
+}
+
+func (m *MemoryDB) InsertJob(ctx context.Context, job *types.Job) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("InsertJob", job); err != nil {
+		return
+	}
+	job.ID = newId()
+	inserted := *job
+	m.jobs[job.ID] = &inserted
+	return
+}
+
+func (m *MemoryDB) UpdateJobProgress(ctx context.Context, jobId string, done, total int64) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("UpdateJobProgress", jobId, done, total); err != nil {
+		return
+	}
+	if job, ok := m.jobs[jobId]; ok {
+		job.Done, job.Total = done, total
+	}
+	return
+}
+
+func (m *MemoryDB) FinishJob(ctx context.Context, job *types.Job) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("FinishJob", job); err != nil {
+		return
+	}
+	if existing, ok := m.jobs[job.ID]; ok {
+		existing.Status, existing.Done, existing.Total, existing.Error = job.Status, job.Done, job.Total, job.Error
+		existing.Result = append(json.RawMessage(nil), job.Result...)
+		existing.FinishedOn = copyTime(job.FinishedOn)
+	}
+	return
+}
+
+func (m *MemoryDB) SelectJob(ctx context.Context, jobId string) (job *types.Job, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("SelectJob", jobId); err != nil {
+		return
+	}
+	existing, ok := m.jobs[jobId]
+	if !ok {
+		err = sql.ErrNoRows
+		return
+	}
+	selected := *existing
+	selected.FinishedOn = copyTime(existing.FinishedOn)
+	job = &selected
+	return
+}
+
+func (m *MemoryDB) FailInstanceJobs(ctx context.Context, instanceId, message string, now time.Time) (failed int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("FailInstanceJobs", instanceId, message, now); err != nil {
+		return
+	}
+	for _, job := range m.jobs {
+		if job.InstanceId == instanceId && job.Status == types.JobStatusRunning {
+			m.failJob(job, message, now)
+			failed++
+		}
+	}
+	return
+}
+
+func (m *MemoryDB) FailOrphanedJobs(ctx context.Context, heartbeatBefore time.Time, message string, now time.Time) (failed int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = m.record("FailOrphanedJobs", heartbeatBefore, message, now); err != nil {
+		return
+	}
+	alive := map[string]bool{}
+	for _, instance := range m.clusterInstances {
+		if !instance.LastHeartbeat.Before(heartbeatBefore) {
+			alive[instance.InstanceId] = true
+		}
+	}
+	for _, job := range m.jobs {
+		if job.Status == types.JobStatusRunning && job.CreatedOn.Before(heartbeatBefore) && !alive[job.InstanceId] {
+			m.failJob(job, message, now)
+			failed++
+		}
+	}
+	return
+}
+
+func (m *MemoryDB) failJob(job *types.Job, message string, now time.Time) {
+	finishedOn := now
+	job.Status, job.Error, job.FinishedOn = types.JobStatusFailed, message, &finishedOn
+}
+
+// Backup writes the rows of each of the backupTables as newline delimited JSON, in the format of their types rather
+// than of the columns of postgres. The rows are read under the lock, so are consistent with each other.
+func (m *MemoryDB) Backup(ctx context.Context, open func(table string) (io.Writer, error)) (rowCounts map[string]int64, err error) {
+	m.mu.Lock()
+	if err = m.record("Backup"); err != nil {
+		m.mu.Unlock()
+		return
+	}
+	var rows [][]interface{}
+	for _, table := range backupTables {
+		var tableRows []interface{}
+		switch table {
+		case "source_control_provider":
+			for _, scp := range m.scps {
+				tableRows = append(tableRows, scp)
+			}
+		case "tenant":
+			for _, tenant := range m.tenants {
+				tableRows = append(tableRows, struct {
+					types.TenantStruct
+					PasswordHash string `json:"passwordHash"`
+				}{tenant.TenantStruct, tenant.passwordHash})
+			}
+		case "campaign":
+			for _, campaign := range m.campaigns {
+				tableRows = append(tableRows, copyCampaign(campaign.CampaignStruct))
+			}
+		case "campaign_penalty", "campaign_caps", "campaign_duplicate_policy", "campaign_coauthor_policy",
+			"campaign_privacy", "campaign_config_stage", "campaign_report", "leaderboard_snapshot":
+			tableRows = m.backupCampaignSettings(table)
+		case "campaign_email":
+			for _, email := range m.emails {
+				tableRows = append(tableRows, email)
+			}
+		case "organization":
+			for _, organization := range m.organizations {
+				tableRows = append(tableRows, organization)
+			}
+		case "point_value_override":
+			for _, override := range m.pointValueOverrides {
+				tableRows = append(tableRows, override.PointValueOverride)
+			}
+		case "bug_category_exclusion":
+			for _, exclusion := range m.bugExclusions {
+				tableRows = append(tableRows, exclusion)
+			}
+		case "repository_filter":
+			for _, filter := range m.repositoryFilters {
+				tableRows = append(tableRows, filter)
+			}
+		case "ban":
+			for _, ban := range m.bans {
+				tableRows = append(tableRows, ban)
+			}
+		case "achievement":
+			for _, achievement := range m.achievements {
+				tableRows = append(tableRows, struct {
+					types.AchievementStruct
+					ParticipantId string `json:"participantId"`
+				}{achievement.AchievementStruct, achievement.participantId})
+			}
+		case "notification":
+			for _, notification := range m.notifications {
+				tableRows = append(tableRows, notification)
+			}
+		case "team":
+			for _, team := range m.teams {
+				tableRows = append(tableRows, team.TeamStruct)
+			}
+		case "participant":
+			for _, participant := range m.participants {
+				tableRows = append(tableRows, m.participantStruct(participant))
+			}
+		case "bug":
+			for _, bug := range m.bugs {
+				tableRows = append(tableRows, bug.BugStruct)
+			}
+		case "scoring_event":
+			for _, event := range m.scoringEvents {
+				tableRows = append(tableRows, event.scoringEventStruct())
+			}
+		case "scoring_event_coauthor":
+			for _, event := range m.scoringEvents {
+				loginNames := make([]string, 0, len(event.coAuthors))
+				for loginName := range event.coAuthors {
+					loginNames = append(loginNames, loginName)
+				}
+				sort.Strings(loginNames)
+				for _, loginName := range loginNames {
+					tableRows = append(tableRows, struct {
+						ScoringEventId string `json:"scoringEventId"`
+						types.CoAuthorScore
+					}{event.ID, types.CoAuthorScore{LoginName: loginName, Points: event.coAuthors[loginName]}})
+				}
+			}
+		case "scoring_event_fingerprint":
+			for _, event := range m.scoringEvents {
+				for _, fingerprint := range sortedFingerprints(event.fingerprints) {
+					tableRows = append(tableRows, struct {
+						ScoringEventId string `json:"scoringEventId"`
+						Fingerprint    string `json:"fingerprint"`
+					}{event.ID, fingerprint})
+				}
+			}
+		case "scoring_flag":
+			for _, flag := range m.scoringFlags {
+				tableRows = append(tableRows, *flag)
+			}
+		case "team_score_event":
+			for _, event := range m.teamScoreEvents {
+				tableRows = append(tableRows, event.TeamScoreEvent)
+			}
+		case "scoring_event_archive":
+			for _, archive := range m.archives {
+				tableRows = append(tableRows, struct {
+					types.ScoringEventArchive
+					Events []byte `json:"events,omitempty"`
+				}{archive.ScoringEventArchive, archive.events})
+			}
+		case "leaderboard_snapshot_entry":
+			for _, campaign := range m.campaigns {
+				snapshot, ok := m.snapshots[campaign.Name]
+				if !ok {
+					continue
+				}
+				for _, entry := range snapshot.Participants {
+					tableRows = append(tableRows, struct {
+						CampaignName string `json:"campaignName"`
+						types.LeaderboardEntry
+					}{campaign.Name, entry})
+				}
+			}
+		}
+		rows = append(rows, tableRows)
+	}
+	m.mu.Unlock()
+
+	rowCounts = map[string]int64{}
+	for i, table := range backupTables {
+		var w io.Writer
+		if w, err = open(table); err != nil {
+			return
+		}
+		encoder := json.NewEncoder(w)
+		for _, row := range rows[i] {
+			if err = encoder.Encode(row); err != nil {
+				return
+			}
+			rowCounts[table]++
+		}
+	}
+	return
+}
+
+// backupCampaignSettings lists the rows of the table of settings kept by campaign, in the order of the campaigns. A
+// leaderboard snapshot is listed without its entries, which are a table of their own.
+func (m *MemoryDB) backupCampaignSettings(table string) (tableRows []interface{}) {
+	for _, campaign := range m.campaigns {
+		var row interface{}
+		var ok bool
+		switch table {
+		case "campaign_penalty":
+			row, ok = m.penalties[campaign.Name]
+		case "campaign_caps":
+			row, ok = m.caps[campaign.Name]
+		case "campaign_duplicate_policy":
+			row, ok = m.duplicatePolicies[campaign.Name]
+		case "campaign_coauthor_policy":
+			row, ok = m.coAuthorPolicies[campaign.Name]
+		case "campaign_privacy":
+			row, ok = m.privacies[campaign.Name]
+		case "campaign_config_stage":
+			row, ok = m.configStages[campaign.Name]
+		case "campaign_report":
+			var report memoryReport
+			if report, ok = m.reports[campaign.Name]; ok {
+				row = struct {
+					CampaignName string          `json:"campaignName"`
+					Report       json.RawMessage `json:"report"`
+					Html         string          `json:"html"`
+				}{campaign.Name, report.reportJson, report.html}
+			}
+		case "leaderboard_snapshot":
+			var snapshot types.LeaderboardSnapshot
+			if snapshot, ok = m.snapshots[campaign.Name]; ok {
+				snapshot.Participants = nil
+				row = snapshot
+			}
+		}
+		if ok {
+			tableRows = append(tableRows, row)
+		}
+	}
+	return
+}
+
+// sortedFingerprints lists the fingerprints in the order they were first fixed.
+func sortedFingerprints(fingerprints map[string]int) (sorted []string) {
+	for fingerprint := range fingerprints {
+		sorted = append(sorted, fingerprint)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return fingerprints[sorted[i]] < fingerprints[sorted[j]]
+	})
+	return
+}
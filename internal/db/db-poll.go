@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"github.com/sonatype-nexus-community/bbash/internal/types"
 	"go.uber.org/zap"
+	"time"
 )
 
 type IDBPoll interface {
@@ -31,6 +32,11 @@ type IDBPoll interface {
 	NewPoll() types.Poll
 	UpdatePoll(poll *types.Poll) (err error)
 	SelectPoll(poll *types.Poll) (err error)
+	InsertJournalEntry(logId string, rawMessage []byte, receivedOn time.Time) (guid string, err error)
+	AckJournalEntry(guid string, processedOn time.Time) (err error)
+	FailJournalEntry(guid string, failure string, maxAttempts int, failedOn time.Time) (deadLettered bool, err error)
+	SelectUnackedJournalEntries() (entries []types.JournalEntry, err error)
+	SelectJournalEntryProcessed(logId string) (processed bool, err error)
 }
 
 type PollStruct struct {
@@ -112,3 +118,89 @@ func (p *PollStruct) SelectPoll(poll *types.Poll) (err error) {
 
 	return
 }
+
+const sqlInsertJournalEntry = `INSERT INTO ingestion_journal
+			(log_id, raw_message, received_on)
+			VALUES ($1, $2, $3)
+			RETURNING Id`
+
+// InsertJournalEntry records the raw message before it is processed, so a crash mid-processing does not lose it.
+func (p *PollStruct) InsertJournalEntry(logId string, rawMessage []byte, receivedOn time.Time) (guid string, err error) {
+	err = p.db.QueryRow(sqlInsertJournalEntry, logId, rawMessage, receivedOn).Scan(&guid)
+	if err != nil {
+		p.logger.Error("insert journal entry error", zap.String("logId", logId), zap.Error(err))
+	}
+	return
+}
+
+const sqlAckJournalEntry = `UPDATE ingestion_journal
+		SET processed_on = $1
+		WHERE Id = $2`
+
+func (p *PollStruct) AckJournalEntry(guid string, processedOn time.Time) (err error) {
+	var res sql.Result
+	res, err = p.db.Exec(sqlAckJournalEntry, processedOn, guid)
+	if err != nil {
+		return
+	}
+
+	var rowsAffected int64
+	rowsAffected, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected != 1 {
+		err = fmt.Errorf("ack journal entry updated wrong number of rows: %d, guid: %s", rowsAffected, guid)
+	}
+	return
+}
+
+const sqlFailJournalEntry = `UPDATE ingestion_journal
+		SET attempts = attempts + 1,
+			last_error = $1,
+			dead_lettered_on = CASE WHEN attempts + 1 >= $2 THEN $3::timestamp END
+		WHERE Id = $4
+		RETURNING dead_lettered_on IS NOT NULL`
+
+// FailJournalEntry counts a failed attempt at processing the entry, and dead letters it once it has failed maxAttempts
+// times. A dead lettered entry is kept with its error, but is no longer recovered.
+func (p *PollStruct) FailJournalEntry(guid string, failure string, maxAttempts int, failedOn time.Time) (deadLettered bool, err error) {
+	err = p.db.QueryRow(sqlFailJournalEntry, failure, maxAttempts, failedOn, guid).Scan(&deadLettered)
+	if err != nil {
+		p.logger.Error("fail journal entry error", zap.String("guid", guid), zap.Error(err))
+	}
+	return
+}
+
+const sqlSelectUnackedJournalEntries = `SELECT Id, log_id, received_on, raw_message
+		FROM ingestion_journal
+		WHERE processed_on IS NULL AND dead_lettered_on IS NULL
+		ORDER BY received_on`
+
+func (p *PollStruct) SelectUnackedJournalEntries() (entries []types.JournalEntry, err error) {
+	var rows *sql.Rows
+	rows, err = p.db.Query(sqlSelectUnackedJournalEntries)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		entry := types.JournalEntry{}
+		err = rows.Scan(&entry.Id, &entry.LogId, &entry.ReceivedOn, &entry.RawMessage)
+		if err != nil {
+			return
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
+
+const sqlSelectJournalEntryProcessed = `SELECT EXISTS(
+		SELECT Id FROM ingestion_journal
+		WHERE log_id = $1 AND processed_on IS NOT NULL)`
+
+// SelectJournalEntryProcessed checks whether the log entry was already processed, so it is not replayed again.
+func (p *PollStruct) SelectJournalEntryProcessed(logId string) (processed bool, err error) {
+	err = p.db.QueryRow(sqlSelectJournalEntryProcessed, logId).Scan(&processed)
+	return
+}
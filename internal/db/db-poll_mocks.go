@@ -21,6 +21,7 @@ package db
 
 import (
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap/zaptest"
 	"testing"
@@ -28,7 +29,8 @@ import (
 )
 
 // SetupMockDBPoll should always be followed by a call to the closeDbFunc, like so:
-// 	mock, db, closeDbFunc := SetupMockDBPoll(t)
+//
+//	mock, db, closeDbFunc := SetupMockDBPoll(t)
 //	defer closeDbFunc()
 func SetupMockDBPoll(t *testing.T) (mock sqlmock.Sqlmock, mockDbPoll *PollStruct, closeDbFunc func()) {
 	db, mock, err := sqlmock.New()
@@ -64,16 +66,59 @@ func SetupMockPollSelectAndUpdate(mock sqlmock.Sqlmock, pollId string, now time.
 	SetupMockPollSelect(mock, pollId, now)
 
 	// expect call to UpdatePoll too
-	mock.ExpectExec(PollConvertSqlToDbMockExpect(sqlUpdatePoll)).
-		WithArgs(now, sqlmock.AnyArg(), sqlmock.AnyArg(), pollId).
-		WillReturnResult(sqlmock.NewResult(0, rowsAffected))
+	SetupMockPollUpdate(mock, pollId, now, rowsAffected)
 }
-
 func SetupMockPollSelectAndUpdateAnyUpdateTime(mock sqlmock.Sqlmock, pollId string, now time.Time, rowsAffected int64) {
 	SetupMockPollSelect(mock, pollId, now)
 
 	// expect call to UpdatePoll too
+	SetupMockPollUpdateAnyUpdateTime(mock, pollId, rowsAffected)
+}
+func SetupMockPollUpdate(mock sqlmock.Sqlmock, pollId string, now time.Time, rowsAffected int64) {
+	mock.ExpectExec(PollConvertSqlToDbMockExpect(sqlUpdatePoll)).
+		WithArgs(now, sqlmock.AnyArg(), sqlmock.AnyArg(), pollId).
+		WillReturnResult(sqlmock.NewResult(0, rowsAffected))
+}
+func SetupMockPollUpdateAnyUpdateTime(mock sqlmock.Sqlmock, pollId string, rowsAffected int64) {
 	mock.ExpectExec(PollConvertSqlToDbMockExpect(sqlUpdatePoll)).
 		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), pollId).
 		WillReturnResult(sqlmock.NewResult(0, rowsAffected))
 }
+func SetupMockJournalInsert(mock sqlmock.Sqlmock, logId, journalId string) {
+	mock.ExpectQuery(PollConvertSqlToDbMockExpect(sqlInsertJournalEntry)).
+		WithArgs(logId, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(journalId))
+}
+
+func SetupMockJournalAck(mock sqlmock.Sqlmock, journalId string) {
+	mock.ExpectExec(PollConvertSqlToDbMockExpect(sqlAckJournalEntry)).
+		WithArgs(sqlmock.AnyArg(), journalId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func SetupMockJournalFail(mock sqlmock.Sqlmock, journalId string, maxAttempts int, deadLettered bool) {
+	mock.ExpectQuery(PollConvertSqlToDbMockExpect(sqlFailJournalEntry)).
+		WithArgs(sqlmock.AnyArg(), maxAttempts, sqlmock.AnyArg(), journalId).
+		WillReturnRows(sqlmock.NewRows([]string{"dead_lettered"}).AddRow(deadLettered))
+}
+
+func SetupMockJournalFailForcedError(mock sqlmock.Sqlmock, journalId string, forcedError error) {
+	mock.ExpectQuery(PollConvertSqlToDbMockExpect(sqlFailJournalEntry)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), journalId).
+		WillReturnError(forcedError)
+}
+
+func SetupMockJournalSelectUnacked(mock sqlmock.Sqlmock, entries []types.JournalEntry) {
+	rows := sqlmock.NewRows([]string{"id", "log_id", "received_on", "raw_message"})
+	for _, entry := range entries {
+		rows.AddRow(entry.Id, entry.LogId, entry.ReceivedOn, entry.RawMessage)
+	}
+	mock.ExpectQuery(PollConvertSqlToDbMockExpect(sqlSelectUnackedJournalEntries)).
+		WillReturnRows(rows)
+}
+
+func SetupMockJournalProcessed(mock sqlmock.Sqlmock, logId string, processed bool) {
+	mock.ExpectQuery(PollConvertSqlToDbMockExpect(sqlSelectJournalEntryProcessed)).
+		WithArgs(logId).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(processed))
+}
@@ -148,3 +148,118 @@ func TestSelectPoll(t *testing.T) {
 		LastPollCompleted: now.Add(time.Second * 2),
 	}, poll)
 }
+
+func TestInsertJournalEntryError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	now := time.Now()
+	forcedError := fmt.Errorf("forced insert journal error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertJournalEntry)).
+		WithArgs("myLogId", []byte("{}"), now).
+		WillReturnError(forcedError)
+
+	guid, err := db.InsertJournalEntry("myLogId", []byte("{}"), now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, "", guid)
+}
+
+func TestInsertJournalEntry(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	now := time.Now()
+	SetupMockJournalInsert(mock, "myLogId", "myJournalId")
+
+	guid, err := db.InsertJournalEntry("myLogId", []byte("{}"), now)
+	assert.NoError(t, err)
+	assert.Equal(t, "myJournalId", guid)
+}
+
+func TestAckJournalEntryWrongRowCount(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	now := time.Now()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlAckJournalEntry)).
+		WithArgs(now, "myJournalId").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.EqualError(t, db.AckJournalEntry("myJournalId", now),
+		"ack journal entry updated wrong number of rows: 0, guid: myJournalId")
+}
+
+func TestAckJournalEntry(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	SetupMockJournalAck(mock, "myJournalId")
+
+	assert.NoError(t, db.AckJournalEntry("myJournalId", time.Now()))
+}
+
+func TestFailJournalEntryError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	now := time.Now()
+	forcedError := fmt.Errorf("forced fail journal error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlFailJournalEntry)).
+		WithArgs("myFailure", 3, now, "myJournalId").
+		WillReturnError(forcedError)
+
+	deadLettered, err := db.FailJournalEntry("myJournalId", "myFailure", 3, now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.False(t, deadLettered)
+}
+
+func TestFailJournalEntry(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	SetupMockJournalFail(mock, "myJournalId", 3, true)
+
+	deadLettered, err := db.FailJournalEntry("myJournalId", "myFailure", 3, time.Now())
+	assert.NoError(t, err)
+	assert.True(t, deadLettered)
+}
+
+func TestSelectUnackedJournalEntries(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	now := time.Now()
+	expected := []types.JournalEntry{
+		{Id: "myJournalId", LogId: "myLogId", ReceivedOn: now, RawMessage: []byte("{}")},
+	}
+	SetupMockJournalSelectUnacked(mock, expected)
+
+	entries, err := db.SelectUnackedJournalEntries()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, entries)
+}
+
+func TestSelectJournalEntryProcessedError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced journal processed error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectJournalEntryProcessed)).
+		WithArgs("myLogId").
+		WillReturnError(forcedError)
+
+	processed, err := db.SelectJournalEntryProcessed("myLogId")
+	assert.EqualError(t, err, forcedError.Error())
+	assert.False(t, processed)
+}
+
+func TestSelectJournalEntryProcessed(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	SetupMockJournalProcessed(mock, "myLogId", true)
+
+	processed, err := db.SelectJournalEntryProcessed("myLogId")
+	assert.NoError(t, err)
+	assert.True(t, processed)
+}
@@ -0,0 +1,4676 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build sqlite
+// +build sqlite
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-migrate/migrate/v4"
+	migratesqlite "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/mattn/go-sqlite3"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"go.uber.org/zap"
+	"io"
+	"strings"
+	"time"
+)
+
+// SqliteDB keeps the data in a SQLite database file, so the server runs without a database server for local
+// development and demos. It is only built with the sqlite build tag, as the SQLite driver needs cgo.
+type SqliteDB struct {
+	db     *sql.DB
+	logger *zap.Logger
+	// statementTimeout bounds each call, in addition to any deadline of the caller's context. Zero means no bound.
+	statementTimeout time.Duration
+}
+
+var _ IBBashDB = (*SqliteDB)(nil)
+
+// NewSqlite opens the SQLite database file at path, creating it when missing. A path of ":memory:" keeps the data in
+// memory until the database is closed.
+func NewSqlite(path string, logger *zap.Logger) (bbashDB IBBashDB, err error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000", path))
+	if err != nil {
+		return
+	}
+	// SQLite allows one writer at a time, and an in memory database is only seen by its own connection
+	db.SetMaxOpenConns(1)
+
+	if err = db.Ping(); err != nil {
+		_ = db.Close()
+		return
+	}
+	bbashDB = &SqliteDB{db: db, logger: logger, statementTimeout: DefaultStatementTimeout}
+	return
+}
+
+func (p *SqliteDB) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.statementTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.statementTimeout)
+}
+
+func (p *SqliteDB) GetDb() (db *sql.DB) {
+	return p.db
+}
+
+// sqliteTimeLayout is how times are stored. Every time is written in UTC with the same width, so comparing the text
+// compares the times.
+const sqliteTimeLayout = "2006-01-02 15:04:05.000000000-07:00"
+
+// sqliteNow is the current time in sqliteTimeLayout, for the times the postgres queries take from NOW()
+const sqliteNow = `strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now')`
+
+// sqliteTruncatedLayout is the layout of a time truncated by strftime
+const sqliteTruncatedLayout = "2006-01-02 15:04:05"
+
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+func sqliteNullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return sqliteTime(*t)
+}
+
+// sqliteJson writes the value as json text, and nil as NULL.
+func sqliteJson(value interface{}) (jsonText interface{}, err error) {
+	if value == nil {
+		return
+	}
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	jsonText = string(jsonBytes)
+	return
+}
+
+// sqliteDuplicateError replaces a unique constraint violation with a *DuplicateError, like duplicateError. SQLite does
+// not name the constraint.
+func sqliteDuplicateError(err error, entity string) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) &&
+		(sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey) {
+		return &DuplicateError{Entity: entity}
+	}
+	return err
+}
+
+// sqliteRow is a *sql.Row or *sql.Rows
+type sqliteRow interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanListVersion reads a count, and the latest updated_on as text, as aggregates lose the column type that has
+// the driver read a time.
+func scanListVersion(row sqliteRow, version *types.ListVersion) (err error) {
+	var updatedOn string
+	err = row.Scan(&version.Count, &updatedOn)
+	if err != nil {
+		return
+	}
+	version.UpdatedOn, err = time.Parse(sqliteTimeLayout, updatedOn)
+	version.UpdatedOn = version.UpdatedOn.UTC()
+	return
+}
+
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+const sqliteMigrationsPath = "migrations/sqlite"
+
+// newMigrate reads the SQLite migrations built into the binary. They are kept apart from the postgres migrations, as
+// the schema is adapted to SQLite. Each migration begins and commits its own transaction, so one can turn foreign keys
+// off to copy a table.
+func (p *SqliteDB) newMigrate() (m *migrate.Migrate, err error) {
+	driver, err := migratesqlite.WithInstance(p.db, &migratesqlite.Config{NoTxWrap: true})
+	if err != nil {
+		return
+	}
+
+	source, err := iofs.New(sqliteMigrations, sqliteMigrationsPath)
+	if err != nil {
+		return
+	}
+
+	m, err = migrate.NewWithInstance("iofs", source, "sqlite3", driver)
+	return
+}
+
+func (p *SqliteDB) MigrateDB() (err error) {
+	m, err := p.newMigrate()
+	if err != nil {
+		return
+	}
+
+	if err = m.Up(); err == migrate.ErrNoChange {
+		err = nil
+	}
+	return
+}
+
+func (p *SqliteDB) SelectMigrationStatus(ctx context.Context) (status types.MigrationStatus, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.db.QueryRowContext(ctx, sqlSelectMigrationStatus).Scan(&status.Version, &status.Dirty)
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return
+}
+
+func (p *SqliteDB) RollbackMigrations(steps int) (err error) {
+	m, err := p.newMigrate()
+	if err != nil {
+		return
+	}
+
+	err = m.Steps(-steps)
+	return
+}
+
+const sqliteSelectSourceControlProviders = `SELECT Id, name, url FROM source_control_provider`
+
+func (p *SqliteDB) GetSourceControlProviders(ctx context.Context) (scps []types.SourceControlProviderStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectSourceControlProviders)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		scp := types.SourceControlProviderStruct{}
+		err = rows.Scan(&scp.ID, &scp.SCPName, &scp.Url)
+		if err != nil {
+			return
+		}
+		scps = append(scps, scp)
+	}
+	return
+}
+
+// the timestamps of a campaign are stored in UTC, so unlike postgres no time zone is applied to read them
+const sqliteCampaignColumns = `campaign.Id, campaign.name, campaign.created_on, campaign.create_order, campaign.start_on,
+		campaign.end_on, campaign.note, campaign.max_team_size, campaign.tie_breaker, campaign.unclassified_bonus,
+		campaign.time_zone, campaign.status, campaign.freeze_scoring, campaign.registration_opens_on,
+		campaign.registration_closes_on, campaign.version`
+
+// scanCampaign reads the sqliteCampaignColumns, then any more columns into dest.
+func scanCampaign(row sqliteRow, campaign *types.CampaignStruct, dest ...interface{}) error {
+	return row.Scan(append([]interface{}{&campaign.ID, &campaign.Name, &campaign.CreatedOn, &campaign.CreatedOrder,
+		&campaign.StartOn, &campaign.EndOn, &campaign.Note, &campaign.MaxTeamSize, &campaign.TieBreaker,
+		&campaign.UnclassifiedBonus, &campaign.TimeZone, &campaign.Status, &campaign.FreezeScoring,
+		&campaign.RegistrationOpensOn, &campaign.RegistrationClosesOn, &campaign.Version}, dest...)...)
+}
+
+func (p *SqliteDB) selectCampaigns(ctx context.Context, query string, args ...interface{}) (campaigns []types.CampaignStruct, err error) {
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		campaign := types.CampaignStruct{}
+		err = scanCampaign(rows, &campaign)
+		if err != nil {
+			return
+		}
+		campaigns = append(campaigns, campaign)
+	}
+	return
+}
+
+const sqliteInsertCampaign = `INSERT INTO campaign
+		(Id, name, create_order, start_on, end_on, max_team_size, tie_breaker, unclassified_bonus, fk_tenant, time_zone,
+		 freeze_scoring, registration_opens_on, registration_closes_on)
+		VALUES (?1, ?2, (SELECT COALESCE(MAX(create_order), 0) + 1 FROM campaign), ?3, ?4, COALESCE(?5, 0),
+		        COALESCE(NULLIF(?6, ''), 'reachedScore'), COALESCE(?7, 1), (SELECT Id FROM tenant WHERE name = NULLIF(?8, '')),
+		        COALESCE(NULLIF(?9, ''), 'UTC'), COALESCE(?10, FALSE), ?11, ?12)`
+
+func (p *SqliteDB) InsertCampaign(ctx context.Context, campaign *types.CampaignStruct) (guid string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	id := newId()
+	_, err = p.db.ExecContext(ctx,
+		sqliteInsertCampaign,
+		id,
+		campaign.Name,
+		sqliteTime(campaign.StartOn),
+		sqliteTime(campaign.EndOn),
+		campaign.MaxTeamSize,
+		campaign.TieBreaker,
+		campaign.UnclassifiedBonus,
+		campaign.TenantName,
+		campaign.TimeZone,
+		campaign.FreezeScoring,
+		sqliteNullTime(campaign.RegistrationOpensOn),
+		sqliteNullTime(campaign.RegistrationClosesOn),
+	)
+	err = sqliteDuplicateError(err, "campaign")
+	if err == nil {
+		guid = id
+	}
+	return
+}
+
+const sqliteSelectCampaignId = `SELECT Id FROM campaign WHERE name = ?1`
+
+const sqliteUpdateCampaign = `UPDATE campaign
+		SET start_on = ?1,
+			end_on = ?2,
+			max_team_size = COALESCE(?4, max_team_size),
+			tie_breaker = COALESCE(NULLIF(?5, ''), tie_breaker),
+			unclassified_bonus = COALESCE(?6, unclassified_bonus),
+			time_zone = COALESCE(NULLIF(?7, ''), time_zone),
+			freeze_scoring = COALESCE(?8, freeze_scoring),
+			registration_opens_on = COALESCE(?9, registration_opens_on),
+			registration_closes_on = COALESCE(?10, registration_closes_on),
+			version = version + 1
+		WHERE Id = ?3 AND version = ?11`
+
+const sqliteSelectCampaignVersion = `SELECT version FROM campaign WHERE Id = ?1`
+
+// UpdateCampaign changes the campaign, when its version is the one the update was made from, then sets the version of
+// the update. sql.ErrNoRows is returned when there is no such campaign, and a *StaleError when the version is not
+// current.
+func (p *SqliteDB) UpdateCampaign(ctx context.Context, campaign *types.CampaignStruct) (guid string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var id string
+	err = p.db.QueryRowContext(ctx, sqliteSelectCampaignId, campaign.Name).Scan(&id)
+	if err != nil {
+		return
+	}
+
+	res, err := p.db.ExecContext(ctx,
+		sqliteUpdateCampaign,
+		sqliteTime(campaign.StartOn),
+		sqliteTime(campaign.EndOn),
+		id,
+		campaign.MaxTeamSize,
+		campaign.TieBreaker,
+		campaign.UnclassifiedBonus,
+		campaign.TimeZone,
+		campaign.FreezeScoring,
+		sqliteNullTime(campaign.RegistrationOpensOn),
+		sqliteNullTime(campaign.RegistrationClosesOn),
+		campaign.Version,
+	)
+	if err != nil {
+		return
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		err = staleError(p.db.QueryRowContext(ctx, sqliteSelectCampaignVersion, id), "campaign")
+		return
+	}
+	campaign.Version++
+	guid = id
+	return
+}
+
+const sqliteSelectCampaign = `SELECT ` + sqliteCampaignColumns + ` FROM campaign WHERE name = ?1`
+
+func (p *SqliteDB) GetCampaign(ctx context.Context, campaignName string) (campaign *types.CampaignStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	campaign = &types.CampaignStruct{}
+	err = scanCampaign(p.db.QueryRowContext(ctx, sqliteSelectCampaign, campaignName), campaign)
+	if err != nil {
+		campaign = nil
+	}
+	return
+}
+
+const sqliteSelectCampaigns = `SELECT ` + sqliteCampaignColumns + ` FROM campaign`
+
+func (p *SqliteDB) GetCampaigns(ctx context.Context) (campaigns []types.CampaignStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.selectCampaigns(ctx, sqliteSelectCampaigns)
+}
+
+const sqliteSelectPublicCampaigns = `SELECT ` + sqliteCampaignColumns + ` FROM campaign
+		WHERE fk_tenant IS NULL
+		ORDER BY create_order`
+
+func (p *SqliteDB) SelectPublicCampaigns(ctx context.Context) (campaigns []types.CampaignStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.selectCampaigns(ctx, sqliteSelectPublicCampaigns)
+}
+
+const sqliteSelectCampaignsVersion = `SELECT COUNT(*), COALESCE(MAX(updated_on), '1970-01-01 00:00:00.000000000+00:00')
+		FROM campaign`
+
+func (p *SqliteDB) SelectCampaignsVersion(ctx context.Context) (version types.ListVersion, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = scanListVersion(p.db.QueryRowContext(ctx, sqliteSelectCampaignsVersion), &version)
+	return
+}
+
+const sqliteSelectCurrentCampaigns = `SELECT ` + sqliteCampaignColumns + ` FROM campaign
+		WHERE ?1 >= start_on
+			AND ?1 < end_on
+		ORDER BY start_on`
+
+func (p *SqliteDB) GetActiveCampaigns(ctx context.Context, now time.Time) (activeCampaigns []types.CampaignStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.selectCampaigns(ctx, sqliteSelectCurrentCampaigns, sqliteTime(now))
+}
+
+const sqliteSelectUpcomingCampaigns = `SELECT ` + sqliteCampaignColumns + ` FROM campaign
+		WHERE ?1 < start_on
+		ORDER BY start_on`
+
+func (p *SqliteDB) GetUpcomingCampaigns(ctx context.Context, now time.Time) (upcomingCampaigns []types.CampaignStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.selectCampaigns(ctx, sqliteSelectUpcomingCampaigns, sqliteTime(now))
+}
+
+const sqliteSelectCampaignTenant = `SELECT COALESCE(tenant.name, '')
+		FROM campaign
+		LEFT JOIN tenant ON tenant.Id = campaign.fk_tenant
+		WHERE campaign.name = ?1`
+
+func (p *SqliteDB) SelectCampaignTenant(ctx context.Context, campaignName string) (tenantName string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.db.QueryRowContext(ctx, sqliteSelectCampaignTenant, campaignName).Scan(&tenantName)
+	return
+}
+
+const sqliteSelectRegistrationWindow = `SELECT registration_opens_on, registration_closes_on FROM campaign WHERE name = ?1`
+
+func (p *SqliteDB) SelectRegistrationWindow(ctx context.Context, campaignName string) (opensOn, closesOn *time.Time, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.db.QueryRowContext(ctx, sqliteSelectRegistrationWindow, campaignName).Scan(&opensOn, &closesOn)
+	return
+}
+
+const sqliteInsertTenant = `INSERT INTO tenant
+		(Id, name, admin_username, admin_password_hash, created_on)
+		VALUES (?1, ?2, ?3, ?4, ?5)`
+
+func (p *SqliteDB) InsertTenant(ctx context.Context, tenant *types.TenantStruct, passwordHash string) (guid string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	id, now := newId(), time.Now().UTC()
+	_, err = p.db.ExecContext(ctx, sqliteInsertTenant, id, tenant.Name, tenant.AdminUsername, passwordHash, sqliteTime(now))
+	err = sqliteDuplicateError(err, "tenant")
+	if err != nil {
+		return
+	}
+	tenant.ID, tenant.CreatedOn = id, now
+	guid = id
+	return
+}
+
+func (p *SqliteDB) SelectTenants(ctx context.Context) (tenants []types.TenantStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqlSelectTenants)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tenant := types.TenantStruct{}
+		err = rows.Scan(&tenant.ID, &tenant.Name, &tenant.AdminUsername, &tenant.CreatedOn)
+		if err != nil {
+			return
+		}
+		tenants = append(tenants, tenant)
+	}
+	return
+}
+
+const sqliteSelectTenantAdmin = `SELECT admin_username, admin_password_hash FROM tenant WHERE name = ?1`
+
+func (p *SqliteDB) SelectTenantAdmin(ctx context.Context, tenantName string) (username, passwordHash string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.db.QueryRowContext(ctx, sqliteSelectTenantAdmin, tenantName).Scan(&username, &passwordHash)
+	return
+}
+
+const sqliteSelectTenantCampaigns = `SELECT ` + sqliteCampaignColumns + `, tenant.name
+		FROM campaign
+		JOIN tenant ON tenant.Id = campaign.fk_tenant
+		WHERE tenant.name = ?1
+		ORDER BY campaign.create_order`
+
+func (p *SqliteDB) SelectTenantCampaigns(ctx context.Context, tenantName string) (campaigns []types.CampaignStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectTenantCampaigns, tenantName)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		campaign := types.CampaignStruct{}
+		err = scanCampaign(rows, &campaign, &campaign.TenantName)
+		if err != nil {
+			return
+		}
+		campaigns = append(campaigns, campaign)
+	}
+	return
+}
+
+const sqliteInsertOrganization = `INSERT INTO organization
+		(Id, fk_scp, organization, fk_campaign)
+		VALUES (?1, (SELECT Id FROM source_control_provider WHERE LOWER(name) = LOWER(?2)), LOWER(?3),
+		        (SELECT Id FROM campaign WHERE name = ?4))`
+
+func (p *SqliteDB) InsertOrganization(ctx context.Context, organization *types.OrganizationStruct) (guid string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	id := newId()
+	_, err = p.db.ExecContext(ctx, sqliteInsertOrganization, id, organization.SCPName, organization.Organization, organization.CampaignName)
+	err = sqliteDuplicateError(err, "organization")
+	if err == nil {
+		guid = id
+	}
+	return
+}
+
+func (p *SqliteDB) GetOrganizations(ctx context.Context) (organizations []types.OrganizationStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqlSelectOrganizations)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		org := types.OrganizationStruct{}
+		err = rows.Scan(&org.ID, &org.SCPName, &org.Organization, &org.CampaignName)
+		if err != nil {
+			return
+		}
+		organizations = append(organizations, org)
+	}
+	return
+}
+
+const sqliteDeleteOrganization = `DELETE FROM organization
+		WHERE fk_scp = (SELECT Id FROM source_control_provider WHERE LOWER(name) = LOWER(?1))
+		AND LOWER(Organization) = LOWER(?2)
+		AND fk_campaign IS (SELECT Id FROM campaign WHERE name = ?3)`
+
+func (p *SqliteDB) DeleteOrganization(ctx context.Context, scpName, orgName, campaignName string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqliteDeleteOrganization, scpName, orgName, campaignName)
+	if err != nil {
+		return
+	}
+	rowsAffected, _ = res.RowsAffected()
+	return
+}
+
+const sqliteUpdateOrganization = `UPDATE organization
+		SET Organization = LOWER(?3)
+		WHERE fk_scp = (SELECT Id FROM source_control_provider WHERE LOWER(name) = LOWER(?1))
+		AND LOWER(Organization) = LOWER(?2)`
+
+const sqliteRelinkScoringEvents = `UPDATE scoring_event
+		SET repoOwner = ?3
+		WHERE fk_scp = (SELECT Id FROM source_control_provider WHERE LOWER(name) = LOWER(?1))
+		AND LOWER(repoOwner) = LOWER(?2)`
+
+func (p *SqliteDB) UpdateOrganization(ctx context.Context, scpName, orgName string, update *types.OrganizationUpdate) (rowsAffected, eventsRelinked int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, sqliteUpdateOrganization, scpName, orgName, update.Organization)
+	if err != nil {
+		err = sqliteDuplicateError(err, "organization")
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+
+	if rowsAffected > 0 && update.RelinkScores {
+		res, err = tx.ExecContext(ctx, sqliteRelinkScoringEvents, scpName, orgName, update.Organization)
+		if err != nil {
+			err = sqliteDuplicateError(err, "scoring event")
+			return
+		}
+		eventsRelinked, err = res.RowsAffected()
+		if err != nil {
+			return
+		}
+	}
+
+	err = tx.Commit()
+	return
+}
+
+const sqliteSelectOrganizationExists = `SELECT EXISTS(
+		SELECT Id FROM organization
+		WHERE fk_scp = (SELECT Id FROM source_control_provider WHERE LOWER(name) = LOWER(?1))
+			AND LOWER(Organization) = LOWER(?2)
+			AND (fk_campaign IS NULL
+				OR fk_campaign IN (SELECT Id FROM campaign WHERE ?3 >= start_on AND ?3 < end_on
+					AND NOT (freeze_scoring AND status = 'ended'))))`
+
+func (p *SqliteDB) ValidOrganization(ctx context.Context, msg *types.ScoringMessage, now time.Time) (orgExists bool, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.db.QueryRowContext(ctx, sqliteSelectOrganizationExists, msg.EventSource, msg.RepoOwner, sqliteTime(now)).
+		Scan(&orgExists)
+	if err != nil {
+		p.logger.Error("organization read error", zap.Any("msg", msg), zap.Error(err))
+	}
+	return
+}
+
+const sqliteSelectParticipantsToScore = `SELECT
+		participant.Id,
+		campaign.name,
+		source_control_provider.name,
+		participant.login_name,
+		team.name
+		FROM participant
+		INNER JOIN campaign ON campaign.Id = participant.fk_campaign
+		INNER JOIN source_control_provider ON source_control_provider.Id = participant.fk_scp
+		LEFT JOIN team ON team.Id = participant.fk_team
+		WHERE ?1 >= campaign.start_on
+			AND ?1 < campaign.end_on
+			AND NOT (campaign.freeze_scoring AND campaign.status = 'ended')
+			AND LOWER(source_control_provider.name) = LOWER(?2)
+			AND login_name = ?3
+			AND EXISTS(SELECT organization.Id FROM organization
+				WHERE organization.fk_scp = source_control_provider.Id
+					AND LOWER(organization.Organization) = LOWER(?4)
+					AND (organization.fk_campaign IS NULL OR organization.fk_campaign = campaign.Id))`
+
+func (p *SqliteDB) SelectParticipantsToScore(ctx context.Context, msg *types.ScoringMessage, now time.Time) (participantsToScore []types.ParticipantStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectParticipantsToScore, sqliteTime(now), msg.EventSource, msg.TriggerUser, msg.RepoOwner)
+	if err != nil {
+		p.logger.Error("skip score-error reading participant", zap.Any("msg", msg), zap.Error(err))
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		partier := types.ParticipantStruct{}
+		var nullableTeamName sql.NullString
+		err = rows.Scan(&partier.ID, &partier.CampaignName, &partier.ScpName, &partier.LoginName, &nullableTeamName)
+		if err != nil {
+			p.logger.Error("skip score-error scanning participant", zap.Any("msg", msg), zap.Error(err))
+			return
+		}
+		partier.TeamName = nullableTeamName.String
+		participantsToScore = append(participantsToScore, partier)
+	}
+	return
+}
+
+// sqliteSelectPointValue prefers an override for the organization of the repository to the campaign point value
+const sqliteSelectPointValue = `SELECT pointValue FROM (
+		SELECT point_value_override.point_value AS pointValue, 0 AS precedence
+			FROM point_value_override
+			INNER JOIN organization ON organization.Id = point_value_override.fk_organization
+			INNER JOIN source_control_provider ON source_control_provider.Id = organization.fk_scp
+			WHERE point_value_override.fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+			  AND point_value_override.category = ?2
+			  AND LOWER(source_control_provider.name) = LOWER(?3)
+			  AND LOWER(organization.Organization) = LOWER(?4)
+			  AND (organization.fk_campaign IS NULL OR organization.fk_campaign = point_value_override.fk_campaign)
+		UNION ALL
+		SELECT pointValue, 1 FROM bug
+			WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+			  AND category = ?2) point_values
+		ORDER BY precedence
+		LIMIT 1`
+
+func (p *SqliteDB) SelectPointValue(ctx context.Context, msg *types.ScoringMessage, campaignName, bugType string) (pointValue float64) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	pointValue = 1
+	if err := p.db.QueryRowContext(ctx, sqliteSelectPointValue, campaignName, bugType, msg.EventSource, msg.RepoOwner).
+		Scan(&pointValue); err != nil {
+		// ignore error from scan operation
+		p.logger.Debug("ignoring missing pointValue",
+			zap.String("bugType", bugType), zap.Error(err), zap.Any("msg", msg))
+	}
+	return
+}
+
+const sqliteUpdateParticipantScore = `UPDATE participant SET Score = Score + ?1 WHERE Id = ?2`
+
+const sqliteSelectParticipantScore = `SELECT Score FROM participant WHERE Id = ?1`
+
+func (p *SqliteDB) UpdateParticipantScore(ctx context.Context, participant *types.ParticipantStruct, delta float64) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, sqliteUpdateParticipantScore, delta, participant.ID)
+	if err != nil {
+		return
+	}
+	err = tx.QueryRowContext(ctx, sqliteSelectParticipantScore, participant.ID).Scan(&participant.Score)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+const sqliteSelectPriorScore = `SELECT points
+		FROM scoring_event
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+			AND fk_scp = (SELECT Id FROM source_control_provider WHERE name = ?2)
+			AND repoOwner = ?3
+			AND repoName = ?4
+			AND pr = ?5
+			AND voided_on IS NULL`
+
+func (p *SqliteDB) SelectPriorScore(ctx context.Context, participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err := p.db.QueryRowContext(ctx, sqliteSelectPriorScore, participantToScore.CampaignName, participantToScore.ScpName,
+		msg.RepoOwner, msg.RepoName, msg.PullRequest).Scan(&oldPoints)
+	if err != nil {
+		// ignore error case from scan when no row exists, will occur when this is a new score event
+		p.logger.Debug("ignoring likely new score event", zap.Error(err), zap.Any("ScoringMessage", msg))
+	}
+	return
+}
+
+const sqliteSelectCoAuthorPriorScore = `SELECT coauthor.points
+		FROM scoring_event_coauthor coauthor
+		INNER JOIN scoring_event ON coauthor.fk_scoring_event = scoring_event.Id
+		WHERE scoring_event.fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+			AND scoring_event.fk_scp = (SELECT Id FROM source_control_provider WHERE name = ?2)
+			AND scoring_event.repoOwner = ?3
+			AND scoring_event.repoName = ?4
+			AND scoring_event.pr = ?5
+			AND scoring_event.voided_on IS NULL
+			AND coauthor.username = ?6`
+
+func (p *SqliteDB) SelectCoAuthorPriorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err := p.db.QueryRowContext(ctx, sqliteSelectCoAuthorPriorScore, coAuthor.CampaignName, coAuthor.ScpName,
+		msg.RepoOwner, msg.RepoName, msg.PullRequest, coAuthor.LoginName).Scan(&oldPoints)
+	if err != nil {
+		// ignore error case from scan when no row exists, will occur when this is a new co-author
+		p.logger.Debug("ignoring likely new co-author", zap.Error(err), zap.Any("ScoringMessage", msg))
+	}
+	return
+}
+
+const sqliteInsertCoAuthorScore = `INSERT INTO scoring_event_coauthor
+		(fk_scoring_event, username, points)
+		SELECT Id, ?6, ?7
+			FROM scoring_event
+			WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+			  AND fk_scp = (SELECT Id FROM source_control_provider WHERE name = ?2)
+			  AND repoOwner = ?3
+			  AND repoName = ?4
+			  AND pr = ?5
+		ON CONFLICT (fk_scoring_event, username) DO
+			UPDATE SET points = excluded.points`
+
+func (p *SqliteDB) InsertCoAuthorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err = p.db.ExecContext(ctx, sqliteInsertCoAuthorScore, coAuthor.CampaignName, coAuthor.ScpName,
+		msg.RepoOwner, msg.RepoName, msg.PullRequest, coAuthor.LoginName, newPoints)
+	return
+}
+
+const sqliteSelectCoAuthorScores = `SELECT participant.Id, participant.login_name,
+			COALESCE((SELECT name FROM team WHERE Id = participant.fk_team), ''), coauthor.points
+		FROM scoring_event_coauthor coauthor
+		INNER JOIN scoring_event ON coauthor.fk_scoring_event = scoring_event.Id
+		INNER JOIN participant ON participant.fk_campaign = scoring_event.fk_campaign
+			AND participant.fk_scp = scoring_event.fk_scp
+			AND participant.login_name = coauthor.username
+		WHERE scoring_event.fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+			AND scoring_event.fk_scp = (SELECT Id FROM source_control_provider WHERE name = ?2)
+			AND scoring_event.repoOwner = ?3
+			AND scoring_event.repoName = ?4
+			AND scoring_event.pr = ?5
+			AND scoring_event.voided_on IS NULL`
+
+func (p *SqliteDB) SelectCoAuthorScores(ctx context.Context, participant *types.ParticipantStruct, msg *types.ScoringMessage) (coAuthors []CoAuthorScoreRow, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectCoAuthorScores, participant.CampaignName, participant.ScpName,
+		msg.RepoOwner, msg.RepoName, msg.PullRequest)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		coAuthor := CoAuthorScoreRow{Participant: types.ParticipantStruct{CampaignName: participant.CampaignName, ScpName: participant.ScpName}}
+		err = rows.Scan(&coAuthor.Participant.ID, &coAuthor.Participant.LoginName, &coAuthor.Participant.TeamName, &coAuthor.Points)
+		if err != nil {
+			return
+		}
+		coAuthors = append(coAuthors, coAuthor)
+	}
+	err = rows.Err()
+	return
+}
+
+const sqliteDeleteCoAuthorScore = `DELETE FROM scoring_event_coauthor
+		WHERE username = ?6
+			AND fk_scoring_event IN (SELECT Id
+				FROM scoring_event
+				WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+				  AND fk_scp = (SELECT Id FROM source_control_provider WHERE name = ?2)
+				  AND repoOwner = ?3
+				  AND repoName = ?4
+				  AND pr = ?5)`
+
+func (p *SqliteDB) DeleteCoAuthorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err = p.db.ExecContext(ctx, sqliteDeleteCoAuthorScore, coAuthor.CampaignName, coAuthor.ScpName,
+		msg.RepoOwner, msg.RepoName, msg.PullRequest, coAuthor.LoginName)
+	return
+}
+
+const sqliteSelectScoringEventsSince = `SELECT repoOwner, repoName, pr, username, points
+		FROM scoring_event
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+		  AND fk_scp = (SELECT Id FROM source_control_provider WHERE name = ?2)
+		  AND username = ?3
+		  AND scored_on >= ?4
+		  AND voided_on IS NULL
+		UNION ALL
+		SELECT scoring_event.repoOwner, scoring_event.repoName, scoring_event.pr, scoring_event.username, coauthor.points
+		FROM scoring_event_coauthor coauthor
+		INNER JOIN scoring_event ON coauthor.fk_scoring_event = scoring_event.Id
+		WHERE scoring_event.fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+		  AND scoring_event.fk_scp = (SELECT Id FROM source_control_provider WHERE name = ?2)
+		  AND coauthor.username = ?3
+		  AND scoring_event.scored_on >= ?4
+		  AND scoring_event.voided_on IS NULL`
+
+// SelectScoringEventsSince reads the scoring events of the participant scored since the time, e.g. the start of a day,
+// with the shares of the pull requests they co-authored as events of their own.
+func (p *SqliteDB) SelectScoringEventsSince(ctx context.Context, participant *types.ParticipantStruct, since time.Time) (events []ScoringEventRow, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectScoringEventsSince, participant.CampaignName, participant.ScpName, participant.LoginName, sqliteTime(since))
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event := ScoringEventRow{Participant: *participant}
+		err = rows.Scan(&event.Msg.RepoOwner, &event.Msg.RepoName, &event.Msg.PullRequest, &event.Msg.TriggerUser, &event.NewPoints)
+		if err != nil {
+			return
+		}
+		events = append(events, event)
+	}
+	return
+}
+
+// a voided event that is scored again counts afresh, for the participant of the new score
+const sqliteUpsertScoringEvent = `INSERT INTO scoring_event
+		(Id, fk_campaign, fk_scp, repoOwner, repoName, pr, username, points, breakdown, bugs_fixed)
+		VALUES (?1, (SELECT Id FROM campaign WHERE name = ?2),
+		        (SELECT Id FROM source_control_provider WHERE name = ?3),
+		        ?4, ?5, ?6, ?7, ?8, ?9, ?10)
+		ON CONFLICT (fk_campaign, fk_scp, repoOwner, repoName, pr) DO
+			UPDATE SET points = excluded.points, breakdown = excluded.breakdown, bugs_fixed = excluded.bugs_fixed,
+				scored_on = ` + sqliteNow + `,
+				username = CASE WHEN scoring_event.voided_on IS NULL THEN scoring_event.username ELSE excluded.username END,
+				voided_on = NULL`
+
+const sqliteSelectScoringEventId = `SELECT Id
+		FROM scoring_event
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+			AND fk_scp = (SELECT Id FROM source_control_provider WHERE name = ?2)
+			AND repoOwner = ?3
+			AND repoName = ?4
+			AND pr = ?5`
+
+const sqliteDeleteScoringEventCategories = `DELETE FROM scoring_event_category WHERE fk_scoring_event = ?1`
+
+const sqliteInsertScoringEventCategory = `INSERT INTO scoring_event_category
+		(fk_scoring_event, category, count, points)
+		VALUES (?1, ?2, ?3, ?4)`
+
+// a fingerprint the event fixed before keeps when it was first fixed
+const sqliteInsertScoringEventFingerprint = `INSERT INTO scoring_event_fingerprint
+		(fk_scoring_event, fingerprint, fixed_on)
+		VALUES (?1, ?2, ` + sqliteNow + `)
+		ON CONFLICT (fk_scoring_event, fingerprint) DO NOTHING`
+
+// upsertSqliteScoringEvent writes the scoring event, with the bugs fixed and categories of its breakdown in columns
+// of their own, as SQLite can not read them from the json.
+func upsertSqliteScoringEvent(ctx context.Context, tx *sql.Tx, participant *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64, breakdown *types.ScoreBreakdown) (err error) {
+	var breakdownJson interface{}
+	var bugsFixed float64
+	if breakdown != nil {
+		if breakdownJson, err = sqliteJson(breakdown); err != nil {
+			return
+		}
+		bugsFixed = breakdown.BugsFixed()
+	}
+	_, err = tx.ExecContext(ctx, sqliteUpsertScoringEvent, newId(), participant.CampaignName, participant.ScpName,
+		msg.RepoOwner, msg.RepoName, msg.PullRequest, msg.TriggerUser, newPoints, breakdownJson, bugsFixed)
+	if err != nil {
+		return
+	}
+
+	var eventId string
+	err = tx.QueryRowContext(ctx, sqliteSelectScoringEventId, participant.CampaignName, participant.ScpName,
+		msg.RepoOwner, msg.RepoName, msg.PullRequest).Scan(&eventId)
+	if err != nil {
+		return
+	}
+	for _, fingerprint := range msg.Fingerprints {
+		_, err = tx.ExecContext(ctx, sqliteInsertScoringEventFingerprint, eventId, fingerprint)
+		if err != nil {
+			return
+		}
+	}
+	_, err = tx.ExecContext(ctx, sqliteDeleteScoringEventCategories, eventId)
+	if err != nil || breakdown == nil {
+		return
+	}
+	for _, category := range breakdown.Categories {
+		_, err = tx.ExecContext(ctx, sqliteInsertScoringEventCategory, eventId, category.Category, category.Count, category.Points)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (p *SqliteDB) InsertScoringEvent(ctx context.Context, participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64, breakdown *types.ScoreBreakdown) (err error) {
+	return p.InsertScoringEvents(ctx, []ScoringEventRow{
+		{Participant: *participantToScore, Msg: *msg, NewPoints: newPoints, Breakdown: breakdown},
+	}, nil)
+}
+
+// InsertScoringEvents inserts the events and applies the score changes in a single transaction.
+func (p *SqliteDB) InsertScoringEvents(ctx context.Context, events []ScoringEventRow, scoreDeltas []ParticipantScoreDelta) (err error) {
+	if len(events) == 0 && len(scoreDeltas) == 0 {
+		return
+	}
+
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for i := range events {
+		event := &events[i]
+		err = upsertSqliteScoringEvent(ctx, tx, &event.Participant, &event.Msg, event.NewPoints, event.Breakdown)
+		if err != nil {
+			return
+		}
+	}
+	for _, scoreDelta := range scoreDeltas {
+		_, err = tx.ExecContext(ctx, sqliteUpdateParticipantScore, scoreDelta.Delta, scoreDelta.ParticipantId)
+		if err != nil {
+			return
+		}
+	}
+
+	err = tx.Commit()
+	return
+}
+
+// sqliteSelectDuplicateFingerprints is the query of SelectDuplicateFingerprints for count fingerprints, as SQLite
+// has no arrays to pass them in
+func sqliteSelectDuplicateFingerprints(count int) string {
+	placeholders := make([]string, count)
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("?%d", i+7)
+	}
+	return `SELECT DISTINCT other.fingerprint
+		FROM scoring_event_fingerprint other
+		INNER JOIN scoring_event ON other.fk_scoring_event = scoring_event.Id
+		WHERE scoring_event.fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+		  AND scoring_event.fk_scp = (SELECT Id FROM source_control_provider WHERE name = ?2)
+		  AND scoring_event.repoOwner = ?3
+		  AND scoring_event.repoName = ?4
+		  AND scoring_event.username <> ?6
+		  AND scoring_event.voided_on IS NULL
+		  AND other.fingerprint IN (` + strings.Join(placeholders, ", ") + `)
+		  AND NOT EXISTS (SELECT 1
+				FROM scoring_event_fingerprint own
+				INNER JOIN scoring_event own_event ON own.fk_scoring_event = own_event.Id
+				WHERE own_event.fk_campaign = scoring_event.fk_campaign
+				  AND own_event.fk_scp = scoring_event.fk_scp
+				  AND own_event.repoOwner = ?3
+				  AND own_event.repoName = ?4
+				  AND own_event.pr = ?5
+				  AND own.fingerprint = other.fingerprint
+				  AND own.fixed_on <= other.fixed_on)
+		ORDER BY other.fingerprint`
+}
+
+func (p *SqliteDB) SelectDuplicateFingerprints(ctx context.Context, participant *types.ParticipantStruct, msg *types.ScoringMessage) (duplicates []string, err error) {
+	if len(msg.Fingerprints) == 0 {
+		return
+	}
+
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	args := []interface{}{participant.CampaignName, participant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, participant.LoginName}
+	for _, fingerprint := range msg.Fingerprints {
+		args = append(args, fingerprint)
+	}
+	rows, err := p.db.QueryContext(ctx, sqliteSelectDuplicateFingerprints(len(msg.Fingerprints)), args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fingerprint string
+		if err = rows.Scan(&fingerprint); err != nil {
+			return
+		}
+		duplicates = append(duplicates, fingerprint)
+	}
+	return
+}
+
+const sqliteScoringEventColumns = `scoring_event.Id, campaign.name, source_control_provider.name, repoOwner, repoName, pr,
+		username, points, breakdown, voided_on`
+
+const sqliteSelectScoringEvent = `SELECT ` + sqliteScoringEventColumns + `
+		FROM scoring_event
+		INNER JOIN campaign ON scoring_event.fk_campaign = campaign.Id
+		INNER JOIN source_control_provider ON scoring_event.fk_scp = source_control_provider.Id
+		WHERE campaign.name = ?1
+			AND source_control_provider.name = ?2
+			AND repoOwner = ?3
+			AND repoName = ?4
+			AND pr = ?5`
+
+// scanScoringEvent reads the sqliteScoringEventColumns.
+func scanScoringEvent(row sqliteRow) (event *types.ScoringEventStruct, err error) {
+	scanned := &types.ScoringEventStruct{}
+	var breakdownJson []byte
+	err = row.Scan(&scanned.ID, &scanned.CampaignName, &scanned.ScpName, &scanned.RepoOwner, &scanned.RepoName,
+		&scanned.PullRequest, &scanned.UserName, &scanned.Points, &breakdownJson, &scanned.VoidedOn)
+	if err != nil {
+		return
+	}
+	if breakdownJson != nil {
+		scanned.Breakdown = &types.ScoreBreakdown{}
+		if err = json.Unmarshal(breakdownJson, scanned.Breakdown); err != nil {
+			return
+		}
+	}
+	event = scanned
+	return
+}
+
+func (p *SqliteDB) SelectScoringEvent(ctx context.Context, campaignName, scpName, repoOwner, repoName string, pullRequest int) (event *types.ScoringEventStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return scanScoringEvent(p.db.QueryRowContext(ctx, sqliteSelectScoringEvent, campaignName, scpName, repoOwner, repoName, pullRequest))
+}
+
+const sqliteExportScoringEvents = `SELECT ` + sqliteScoringEventColumns + `, scored_on
+		FROM scoring_event
+		INNER JOIN campaign ON scoring_event.fk_campaign = campaign.Id
+		INNER JOIN source_control_provider ON scoring_event.fk_scp = source_control_provider.Id
+		WHERE campaign.name = ?1
+		ORDER BY scored_on, scoring_event.Id`
+
+func (p *SqliteDB) ExportScoringEvents(ctx context.Context, campaignName string, each func(event *types.ScoringEventStruct) error) (err error) {
+	rows, err := p.db.QueryContext(ctx, sqliteExportScoringEvents, campaignName)
+	if err != nil {
+		return
+	}
+	return sqliteReadExportedEvents(rows, each)
+}
+
+// sqliteReadExportedEvents passes each event read by sqliteExportScoringEvents to the func, closing the rows.
+func sqliteReadExportedEvents(rows *sql.Rows, each func(event *types.ScoringEventStruct) error) (err error) {
+	defer rows.Close()
+
+	for rows.Next() {
+		event := types.ScoringEventStruct{}
+		var breakdownJson []byte
+		var scoredOn sql.NullTime
+		err = rows.Scan(&event.ID, &event.CampaignName, &event.ScpName, &event.RepoOwner, &event.RepoName,
+			&event.PullRequest, &event.UserName, &event.Points, &breakdownJson, &event.VoidedOn, &scoredOn)
+		if err != nil {
+			return
+		}
+		if breakdownJson != nil {
+			event.Breakdown = &types.ScoreBreakdown{}
+			if err = json.Unmarshal(breakdownJson, event.Breakdown); err != nil {
+				return
+			}
+		}
+		if scoredOn.Valid {
+			event.ScoredOn = &scoredOn.Time
+		}
+		if err = each(&event); err != nil {
+			return
+		}
+	}
+	err = rows.Err()
+	return
+}
+
+const sqliteImportScoringEvent = `INSERT INTO scoring_event
+		(Id, fk_campaign, fk_scp, repoOwner, repoName, pr, username, points, breakdown, bugs_fixed, voided_on, scored_on)
+		VALUES (?1, (SELECT Id FROM campaign WHERE name = ?2),
+		        (SELECT Id FROM source_control_provider WHERE name = ?3),
+		        ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, COALESCE(?12, ` + sqliteNow + `))
+		ON CONFLICT (fk_campaign, fk_scp, repoOwner, repoName, pr) DO NOTHING`
+
+const sqliteRecomputeParticipantScore = `UPDATE participant
+		SET Score = COALESCE((SELECT SUM(scoring_event.points)
+				FROM scoring_event
+				WHERE scoring_event.fk_campaign = participant.fk_campaign
+				  AND scoring_event.fk_scp = participant.fk_scp
+				  AND scoring_event.username = participant.login_name
+				  AND scoring_event.voided_on IS NULL), 0)
+			+ COALESCE((SELECT SUM(coauthor.points)
+				FROM scoring_event_coauthor coauthor
+				INNER JOIN scoring_event ON coauthor.fk_scoring_event = scoring_event.Id
+				WHERE scoring_event.fk_campaign = participant.fk_campaign
+				  AND scoring_event.fk_scp = participant.fk_scp
+				  AND coauthor.username = participant.login_name
+				  AND scoring_event.voided_on IS NULL), 0)
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+		  AND fk_scp = (SELECT Id FROM source_control_provider WHERE name = ?2)
+		  AND login_name = ?3`
+
+const sqliteSelectCampaignArchived = `SELECT EXISTS (SELECT 1
+		FROM scoring_event_archive
+		INNER JOIN campaign ON scoring_event_archive.fk_campaign = campaign.Id
+		WHERE campaign.name = ?1
+		  AND scoring_event_archive.restored_on IS NULL)`
+
+func (p *SqliteDB) ImportScoringEvents(ctx context.Context, events []types.ScoringEventStruct) (imported, recomputed int64, err error) {
+	if len(events) == 0 {
+		return
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	checked := map[string]bool{}
+	for i := range events {
+		if checked[events[i].CampaignName] {
+			continue
+		}
+		checked[events[i].CampaignName] = true
+		var archived bool
+		if err = tx.QueryRowContext(ctx, sqliteSelectCampaignArchived, events[i].CampaignName).Scan(&archived); err != nil {
+			return
+		}
+		if archived {
+			err = ErrCampaignArchived
+			return
+		}
+	}
+
+	var affected []importedParticipant
+	seen := map[importedParticipant]bool{}
+	for i := range events {
+		event := &events[i]
+		var eventId string
+		if eventId, err = sqliteInsertImportedEvent(ctx, tx, event); err != nil {
+			return
+		}
+		if eventId == "" {
+			continue
+		}
+		imported++
+		affected = addImportedParticipant(affected, seen, event)
+	}
+
+	for _, participant := range affected {
+		var res sql.Result
+		res, err = tx.ExecContext(ctx, sqliteRecomputeParticipantScore, participant.CampaignName, participant.ScpName, participant.LoginName)
+		if err != nil {
+			return
+		}
+		var rowsAffected int64
+		if rowsAffected, err = res.RowsAffected(); err != nil {
+			return
+		}
+		recomputed += rowsAffected
+	}
+
+	err = tx.Commit()
+	return
+}
+
+// sqliteInsertImportedEvent inserts the event with the categories of its breakdown, returning no id when the pull
+// request already has an event in the campaign.
+func sqliteInsertImportedEvent(ctx context.Context, tx *sql.Tx, event *types.ScoringEventStruct) (eventId string, err error) {
+	var breakdownJson interface{}
+	var bugsFixed float64
+	if event.Breakdown != nil {
+		if breakdownJson, err = sqliteJson(event.Breakdown); err != nil {
+			return
+		}
+		bugsFixed = event.Breakdown.BugsFixed()
+	}
+	id := newId()
+	res, err := tx.ExecContext(ctx, sqliteImportScoringEvent, id, event.CampaignName, event.ScpName,
+		event.RepoOwner, event.RepoName, event.PullRequest, event.UserName, event.Points, breakdownJson, bugsFixed,
+		sqliteNullTime(event.VoidedOn), sqliteNullTime(event.ScoredOn))
+	if err != nil {
+		return
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		return
+	}
+
+	if event.Breakdown != nil {
+		for _, category := range event.Breakdown.Categories {
+			_, err = tx.ExecContext(ctx, sqliteInsertScoringEventCategory, id, category.Category, category.Count, category.Points)
+			if err != nil {
+				return
+			}
+		}
+	}
+	eventId = id
+	return
+}
+
+const sqliteSelectCampaignsToArchive = `SELECT campaign.name
+		FROM campaign
+		WHERE campaign.end_on < ?1
+			AND NOT EXISTS (SELECT 1 FROM scoring_event_archive WHERE fk_campaign = campaign.Id)
+			AND NOT EXISTS (SELECT 1
+				FROM scoring_flag
+				INNER JOIN scoring_event ON scoring_flag.fk_scoring_event = scoring_event.Id
+				WHERE scoring_event.fk_campaign = campaign.Id
+					AND scoring_flag.resolved_on IS NULL)
+		ORDER BY campaign.end_on`
+
+func (p *SqliteDB) SelectCampaignsToArchive(ctx context.Context, endedBefore time.Time) (campaignNames []string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectCampaignsToArchive, sqliteTime(endedBefore))
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var campaignName string
+		if err = rows.Scan(&campaignName); err != nil {
+			return
+		}
+		campaignNames = append(campaignNames, campaignName)
+	}
+	err = rows.Err()
+	return
+}
+
+const sqliteSelectArchiveCampaign = `SELECT campaign.Id,
+			scoring_event_archive.fk_campaign IS NOT NULL AND scoring_event_archive.restored_on IS NULL
+		FROM campaign
+		LEFT JOIN scoring_event_archive ON scoring_event_archive.fk_campaign = campaign.Id
+		WHERE campaign.name = ?1`
+
+const sqliteSelectCampaignCoAuthors = `SELECT coauthor.fk_scoring_event, coauthor.username, coauthor.points
+		FROM scoring_event_coauthor coauthor
+		INNER JOIN scoring_event ON coauthor.fk_scoring_event = scoring_event.Id
+		WHERE scoring_event.fk_campaign = ?1
+		ORDER BY coauthor.username`
+
+const sqliteUpsertScoringEventArchive = `INSERT INTO scoring_event_archive
+		(fk_campaign, events, event_count, archived_on)
+		VALUES (?1, ?2, ?3, ?4)
+		ON CONFLICT (fk_campaign) DO
+			UPDATE SET events = excluded.events, event_count = excluded.event_count,
+				archived_on = excluded.archived_on, restored_on = NULL`
+
+const sqliteDeleteCampaignScoringEvents = `DELETE FROM scoring_event WHERE fk_campaign = ?1`
+
+func (p *SqliteDB) ArchiveScoringEvents(ctx context.Context, campaignName string, now time.Time) (archive *types.ScoringEventArchive, err error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var campaignId string
+	var archived bool
+	if err = tx.QueryRowContext(ctx, sqliteSelectArchiveCampaign, campaignName).Scan(&campaignId, &archived); err != nil {
+		return
+	}
+	if archived {
+		err = sql.ErrNoRows
+		return
+	}
+
+	coAuthors := map[string][]types.CoAuthorScore{}
+	rows, err := tx.QueryContext(ctx, sqliteSelectCampaignCoAuthors, campaignId)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var eventId string
+		var coAuthor types.CoAuthorScore
+		if err = rows.Scan(&eventId, &coAuthor.LoginName, &coAuthor.Points); err != nil {
+			_ = rows.Close()
+			return
+		}
+		coAuthors[eventId] = append(coAuthors[eventId], coAuthor)
+	}
+	if err = rows.Close(); err != nil {
+		return
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	var events []types.ScoringEventStruct
+	if rows, err = tx.QueryContext(ctx, sqliteExportScoringEvents, campaignName); err != nil {
+		return
+	}
+	err = sqliteReadExportedEvents(rows, func(event *types.ScoringEventStruct) error {
+		event.CoAuthors = coAuthors[event.ID]
+		events = append(events, *event)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	var eventsGzip []byte
+	if eventsGzip, err = gzipScoringEvents(events); err != nil {
+		return
+	}
+	if _, err = tx.ExecContext(ctx, sqliteUpsertScoringEventArchive, campaignId, eventsGzip, len(events), sqliteTime(now)); err != nil {
+		return
+	}
+	if _, err = tx.ExecContext(ctx, sqliteDeleteCampaignScoringEvents, campaignId); err != nil {
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		return
+	}
+
+	archive = &types.ScoringEventArchive{CampaignName: campaignName, EventCount: int64(len(events)), ArchivedOn: now}
+	return
+}
+
+const sqliteSelectScoringEventArchiveToRestore = `SELECT scoring_event_archive.fk_campaign, scoring_event_archive.events,
+			scoring_event_archive.archived_on
+		FROM scoring_event_archive
+		INNER JOIN campaign ON scoring_event_archive.fk_campaign = campaign.Id
+		WHERE campaign.name = ?1
+			AND scoring_event_archive.restored_on IS NULL`
+
+const sqliteRestoreCoAuthorScore = `INSERT INTO scoring_event_coauthor
+		(fk_scoring_event, username, points)
+		VALUES (?1, ?2, ?3)`
+
+const sqliteUpdateScoringEventArchiveRestored = `UPDATE scoring_event_archive
+		SET events = NULL, restored_on = ?2
+		WHERE fk_campaign = ?1`
+
+func (p *SqliteDB) RestoreScoringEvents(ctx context.Context, campaignName string, now time.Time) (archive *types.ScoringEventArchive, err error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var campaignId string
+	var archived []byte
+	restored := &types.ScoringEventArchive{CampaignName: campaignName, RestoredOn: &now}
+	err = tx.QueryRowContext(ctx, sqliteSelectScoringEventArchiveToRestore, campaignName).Scan(&campaignId, &archived, &restored.ArchivedOn)
+	if err != nil {
+		return
+	}
+	var events []types.ScoringEventStruct
+	if events, err = gunzipScoringEvents(archived); err != nil {
+		return
+	}
+
+	for i := range events {
+		event := &events[i]
+		event.CampaignName = campaignName
+		var eventId string
+		if eventId, err = sqliteInsertImportedEvent(ctx, tx, event); err != nil {
+			return
+		}
+		if eventId == "" {
+			continue
+		}
+		restored.EventCount++
+		for _, coAuthor := range event.CoAuthors {
+			if _, err = tx.ExecContext(ctx, sqliteRestoreCoAuthorScore, eventId, coAuthor.LoginName, coAuthor.Points); err != nil {
+				return
+			}
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, sqliteUpdateScoringEventArchiveRestored, campaignId, sqliteTime(now)); err != nil {
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		return
+	}
+	archive = restored
+	return
+}
+
+const sqliteSelectScoringEventArchives = `SELECT campaign.name, scoring_event_archive.event_count,
+			scoring_event_archive.archived_on, scoring_event_archive.restored_on
+		FROM scoring_event_archive
+		INNER JOIN campaign ON scoring_event_archive.fk_campaign = campaign.Id
+		ORDER BY scoring_event_archive.archived_on, campaign.name`
+
+func (p *SqliteDB) SelectScoringEventArchives(ctx context.Context) (archives []types.ScoringEventArchive, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectScoringEventArchives)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		archive := types.ScoringEventArchive{}
+		if err = rows.Scan(&archive.CampaignName, &archive.EventCount, &archive.ArchivedOn, &archive.RestoredOn); err != nil {
+			return
+		}
+		archives = append(archives, archive)
+	}
+	err = rows.Err()
+	return
+}
+
+const sqliteSelectScoringEventToVoid = `SELECT ` + sqliteScoringEventColumns + `
+		FROM scoring_event
+		INNER JOIN campaign ON scoring_event.fk_campaign = campaign.Id
+		INNER JOIN source_control_provider ON scoring_event.fk_scp = source_control_provider.Id
+		WHERE scoring_event.Id = ?1
+			AND scoring_event.voided_on IS NULL`
+
+const sqliteVoidScoringEvent = `UPDATE scoring_event SET voided_on = ?2 WHERE Id = ?1`
+
+const sqliteReverseScoringEvent = `UPDATE participant
+		SET Score = Score - (SELECT points FROM scoring_event WHERE Id = ?1)
+		WHERE Id = ?2`
+
+const sqliteSelectScoringEventParticipant = `SELECT participant.Id, COALESCE(team.name, '')
+		FROM scoring_event
+		INNER JOIN participant ON participant.fk_campaign = scoring_event.fk_campaign
+			AND participant.fk_scp = scoring_event.fk_scp
+			AND participant.login_name = scoring_event.username
+		LEFT JOIN team ON participant.fk_team = team.Id
+		WHERE scoring_event.Id = ?1`
+
+const sqliteSelectScoringEventCoAuthors = `SELECT participant.Id, participant.login_name, COALESCE(team.name, ''), coauthor.points
+		FROM scoring_event_coauthor coauthor
+		INNER JOIN scoring_event ON coauthor.fk_scoring_event = scoring_event.Id
+		INNER JOIN participant ON participant.fk_campaign = scoring_event.fk_campaign
+			AND participant.fk_scp = scoring_event.fk_scp
+			AND participant.login_name = coauthor.username
+		LEFT JOIN team ON participant.fk_team = team.Id
+		WHERE coauthor.fk_scoring_event = ?1
+		ORDER BY participant.login_name`
+
+const sqliteReverseCoAuthorScore = `UPDATE participant SET Score = Score - ?2 WHERE Id = ?1`
+
+const sqliteDeleteCoAuthorScores = `DELETE FROM scoring_event_coauthor WHERE fk_scoring_event = ?1`
+
+func (p *SqliteDB) VoidScoringEvent(ctx context.Context, eventId string, now time.Time) (event *types.ScoringEventStruct, teamName string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	voided, err := scanScoringEvent(tx.QueryRowContext(ctx, sqliteSelectScoringEventToVoid, eventId))
+	if err != nil {
+		return
+	}
+	_, err = tx.ExecContext(ctx, sqliteVoidScoringEvent, voided.ID, sqliteTime(now))
+	if err != nil {
+		return
+	}
+	voidedOn := now.UTC()
+	voided.VoidedOn = &voidedOn
+
+	var participantId string
+	err = tx.QueryRowContext(ctx, sqliteSelectScoringEventParticipant, voided.ID).Scan(&participantId, &teamName)
+	if err == sql.ErrNoRows {
+		// the participant has since been removed from the campaign, so there is no score to correct
+		err = nil
+	} else if err != nil {
+		return
+	} else if _, err = tx.ExecContext(ctx, sqliteReverseScoringEvent, voided.ID, participantId); err != nil {
+		return
+	}
+
+	var rows *sql.Rows
+	rows, err = tx.QueryContext(ctx, sqliteSelectScoringEventCoAuthors, voided.ID)
+	if err != nil {
+		return
+	}
+	var coAuthorIds []string
+	for rows.Next() {
+		var coAuthorId string
+		var coAuthor types.CoAuthorScore
+		if err = rows.Scan(&coAuthorId, &coAuthor.LoginName, &coAuthor.TeamName, &coAuthor.Points); err != nil {
+			_ = rows.Close()
+			return
+		}
+		coAuthorIds = append(coAuthorIds, coAuthorId)
+		voided.CoAuthors = append(voided.CoAuthors, coAuthor)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	for i, coAuthorId := range coAuthorIds {
+		if _, err = tx.ExecContext(ctx, sqliteReverseCoAuthorScore, coAuthorId, voided.CoAuthors[i].Points); err != nil {
+			return
+		}
+	}
+	// the shares are taken off once, so a scoring again after the void starts the co-authors afresh
+	if _, err = tx.ExecContext(ctx, sqliteDeleteCoAuthorScores, voided.ID); err != nil {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		return
+	}
+	event = voided
+	return
+}
+
+const sqliteSelectScoredEvents = `SELECT scoring_event.Id, campaign.name, source_control_provider.name, repoOwner, repoName, pr,
+		username, points, breakdown, scored_on
+		FROM scoring_event
+		INNER JOIN campaign ON scoring_event.fk_campaign = campaign.Id
+		INNER JOIN source_control_provider ON scoring_event.fk_scp = source_control_provider.Id
+		WHERE scored_on >= ?1
+			AND voided_on IS NULL
+		ORDER BY scored_on`
+
+func (p *SqliteDB) SelectScoredEvents(ctx context.Context, since time.Time) (events []ScoredEvent, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectScoredEvents, sqliteTime(since))
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event := ScoredEvent{}
+		var breakdownJson []byte
+		err = rows.Scan(&event.ID, &event.CampaignName, &event.ScpName, &event.RepoOwner, &event.RepoName,
+			&event.PullRequest, &event.UserName, &event.Points, &breakdownJson, &event.ScoredOn)
+		if err != nil {
+			return
+		}
+		if breakdownJson != nil {
+			event.Breakdown = &types.ScoreBreakdown{}
+			if err = json.Unmarshal(breakdownJson, event.Breakdown); err != nil {
+				return
+			}
+		}
+		events = append(events, event)
+	}
+	return
+}
+
+const sqliteInsertScoringFlag = `INSERT INTO scoring_flag
+		(Id, fk_scoring_event, reason, flagged_on)
+		VALUES (?1, ?2, ?3, ?4)
+		ON CONFLICT (fk_scoring_event, reason) DO NOTHING`
+
+func (p *SqliteDB) InsertScoringFlags(ctx context.Context, flags []types.ScoringFlag) (flagged int64, err error) {
+	if len(flags) == 0 {
+		return
+	}
+
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var count int64
+	for _, flag := range flags {
+		var res sql.Result
+		res, err = tx.ExecContext(ctx, sqliteInsertScoringFlag, newId(), flag.Event.ID, flag.Reason, sqliteTime(flag.FlaggedOn))
+		if err != nil {
+			return
+		}
+		var rowsAffected int64
+		if rowsAffected, err = res.RowsAffected(); err != nil {
+			return
+		}
+		count += rowsAffected
+	}
+
+	if err = tx.Commit(); err != nil {
+		return
+	}
+	flagged = count
+	return
+}
+
+const sqliteScoringFlagFrom = `FROM scoring_flag
+		INNER JOIN scoring_event ON scoring_flag.fk_scoring_event = scoring_event.Id
+		INNER JOIN campaign ON scoring_event.fk_campaign = campaign.Id
+		INNER JOIN source_control_provider ON scoring_event.fk_scp = source_control_provider.Id`
+
+const sqliteSelectScoringFlags = `SELECT ` + sqlScoringFlagColumns + `
+		` + sqliteScoringFlagFrom + `
+		WHERE scoring_flag.resolved_on IS NULL
+			AND scoring_event.voided_on IS NULL
+		ORDER BY scoring_flag.flagged_on, scoring_flag.Id`
+
+func (p *SqliteDB) SelectScoringFlags(ctx context.Context) (flags []types.ScoringFlag, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectScoringFlags)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var flag *types.ScoringFlag
+		if flag, err = scanScoringFlag(rows); err != nil {
+			return
+		}
+		flags = append(flags, *flag)
+	}
+	return
+}
+
+const sqliteSelectScoringFlagToResolve = `SELECT ` + sqlScoringFlagColumns + `
+		` + sqliteScoringFlagFrom + `
+		WHERE scoring_flag.Id = ?1
+			AND scoring_flag.resolved_on IS NULL`
+
+const sqliteResolveScoringFlag = `UPDATE scoring_flag SET resolved_on = ?3, resolution = ?2 WHERE Id = ?1`
+
+func (p *SqliteDB) ResolveScoringFlag(ctx context.Context, flagId, resolution string, now time.Time) (flag *types.ScoringFlag, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	resolved, err := scanScoringFlag(tx.QueryRowContext(ctx, sqliteSelectScoringFlagToResolve, flagId))
+	if err != nil {
+		return
+	}
+	_, err = tx.ExecContext(ctx, sqliteResolveScoringFlag, resolved.ID, resolution, sqliteTime(now))
+	if err != nil {
+		return
+	}
+	resolvedOn := now.UTC()
+	resolved.ResolvedOn, resolved.Resolution = &resolvedOn, resolution
+
+	if err = tx.Commit(); err != nil {
+		return
+	}
+	flag = resolved
+	return
+}
+
+const sqliteInsertParticipant = `INSERT INTO participant
+		(Id, fk_scp, fk_campaign, login_name, Email, DisplayName, Score, JoinedAt)
+		VALUES (?1, (SELECT Id FROM source_control_provider WHERE name = ?2),
+		        (SELECT Id FROM campaign WHERE name = ?3),
+		        ?4, ?5, ?6, 0, ?7)`
+
+func (p *SqliteDB) InsertParticipant(ctx context.Context, participant *types.ParticipantStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.insertParticipant(ctx, p.db, participant)
+	if err != nil {
+		p.logger.Error("error inserting participant", zap.Any("participant", participant), zap.Error(err))
+	}
+	err = sqliteDuplicateError(err, "participant")
+	return
+}
+
+// sqliteExecer is a *sql.DB or *sql.Tx
+type sqliteExecer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (p *SqliteDB) insertParticipant(ctx context.Context, db sqliteExecer, participant *types.ParticipantStruct) (err error) {
+	id, now := newId(), time.Now().UTC()
+	_, err = db.ExecContext(ctx, sqliteInsertParticipant, id, participant.ScpName, participant.CampaignName,
+		participant.LoginName, participant.Email, participant.DisplayName, sqliteTime(now))
+	if err != nil {
+		return
+	}
+	participant.ID, participant.Score, participant.JoinedAt = id, 0, now
+	return
+}
+
+const sqliteUpdateParticipantContactByName = `UPDATE participant
+		SET Email = ?4,
+			DisplayName = ?5
+		WHERE Id = ` + sqliteSelectParticipantIdByName
+
+const sqliteSelectUpsertedParticipant = `SELECT participant.Id, Score, COALESCE(team.name, ''), JoinedAt
+		FROM participant
+		LEFT JOIN team ON team.Id = participant.fk_team
+		WHERE participant.Id = ` + sqliteSelectParticipantIdByName
+
+func (p *SqliteDB) UpsertParticipant(ctx context.Context, participant *types.ParticipantStruct) (inserted bool, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			p.logger.Error("error upserting participant", zap.Any("participant", participant), zap.Error(err))
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, sqliteUpdateParticipantContactByName, participant.CampaignName, participant.ScpName,
+		participant.LoginName, participant.Email, participant.DisplayName)
+	if err != nil {
+		return
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+
+	if rowsAffected == 0 {
+		err = p.insertParticipant(ctx, tx, participant)
+		inserted = err == nil
+	} else {
+		err = tx.QueryRowContext(ctx, sqliteSelectUpsertedParticipant, participant.CampaignName, participant.ScpName, participant.LoginName).
+			Scan(&participant.ID, &participant.Score, &participant.TeamName, &participant.JoinedAt)
+	}
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+const sqliteInsertTeam = `INSERT INTO team
+		(Id, fk_campaign, name)
+		VALUES (?1, (SELECT Id FROM campaign WHERE name = ?2), ?3)`
+
+func (p *SqliteDB) InsertTeam(ctx context.Context, team *types.TeamStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	id := newId()
+	_, err = p.db.ExecContext(ctx, sqliteInsertTeam, id, team.CampaignName, team.Name)
+	err = sqliteDuplicateError(err, "team")
+	if err == nil {
+		team.Id = id
+	}
+	return
+}
+
+const sqliteSelectTeamsByCampaign = `SELECT team.Id, campaign.name, team.name
+		FROM team
+		INNER JOIN campaign ON team.fk_campaign = campaign.Id
+		WHERE campaign.name = ?1
+		ORDER BY team.name`
+
+func (p *SqliteDB) SelectTeamsInCampaign(ctx context.Context, campaignName string) (teams []types.TeamStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectTeamsByCampaign, campaignName)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		team := types.TeamStruct{}
+		err = rows.Scan(&team.Id, &team.CampaignName, &team.Name)
+		if err != nil {
+			return
+		}
+		teams = append(teams, team)
+	}
+	return
+}
+
+// sqliteSelectTeam finds a team by name. When no campaign name is given, the team from the most recently created
+// campaign wins.
+const sqliteSelectTeam = `SELECT team.Id, campaign.name, team.name
+		FROM team
+		INNER JOIN campaign ON team.fk_campaign = campaign.Id
+		WHERE team.name = ?1
+		  AND (?2 = '' OR campaign.name = ?2)
+		ORDER BY campaign.create_order DESC
+		LIMIT 1`
+
+const sqliteSelectTeamMembers = `SELECT
+		participant.Id, campaign.name, source_control_provider.name, login_name, Email, DisplayName, Score, team.name, captain, JoinedAt
+		FROM participant
+		INNER JOIN team ON participant.fk_team = team.Id
+		INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+		INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+		WHERE team.Id = ?1
+		ORDER BY login_name`
+
+func (p *SqliteDB) SelectTeamDetail(ctx context.Context, campaignName, teamName string) (team *types.TeamStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	team = new(types.TeamStruct)
+	err = p.db.QueryRowContext(ctx, sqliteSelectTeam, teamName, campaignName).Scan(&team.Id, &team.CampaignName, &team.Name)
+	if err != nil {
+		p.logger.Error("selectTeamDetail scan error",
+			zap.String("campaignName", campaignName), zap.String("teamName", teamName), zap.Error(err))
+		return
+	}
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectTeamMembers, team.Id)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		member := types.ParticipantStruct{}
+		err = rows.Scan(&member.ID, &member.CampaignName, &member.ScpName, &member.LoginName, &member.Email,
+			&member.DisplayName, &member.Score, &member.TeamName, &member.Captain, &member.JoinedAt)
+		if err != nil {
+			return
+		}
+		team.Members = append(team.Members, member)
+	}
+	return
+}
+
+const sqliteSelectTeamId = `(SELECT team.Id FROM team
+			WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+			  AND name = ?2)`
+
+const sqliteUnassignTeamMembers = `UPDATE participant
+		SET fk_team = NULL, captain = FALSE
+		WHERE fk_team = ` + sqliteSelectTeamId
+
+const sqliteDeleteTeam = `DELETE FROM team
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+		  AND name = ?2`
+
+func (p *SqliteDB) DeleteTeam(ctx context.Context, campaignName, teamName string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, sqliteUnassignTeamMembers, campaignName, teamName)
+	if err != nil {
+		return
+	}
+
+	res, err := tx.ExecContext(ctx, sqliteDeleteTeam, campaignName, teamName)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+const sqliteUpdateTeamName = `UPDATE team
+		SET name = ?3
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+		  AND name = ?2`
+
+func (p *SqliteDB) UpdateTeamName(ctx context.Context, campaignName, teamName, newTeamName string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqliteUpdateTeamName, campaignName, teamName, newTeamName)
+	if err != nil {
+		err = sqliteDuplicateError(err, "team")
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqliteClearTeamCaptain = `UPDATE participant
+		SET captain = FALSE
+		WHERE fk_team = ` + sqliteSelectTeamId + `
+		  AND captain`
+
+const sqliteSetTeamCaptain = `UPDATE participant
+		SET captain = TRUE
+		WHERE fk_team = ` + sqliteSelectTeamId + `
+		  AND fk_scp = (SELECT Id FROM source_control_provider WHERE name = ?3)
+		  AND login_name = ?4`
+
+func (p *SqliteDB) UpdateTeamCaptain(ctx context.Context, campaignName, teamName, scpName, loginName string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, sqliteClearTeamCaptain, campaignName, teamName)
+	if err != nil {
+		return
+	}
+
+	res, err := tx.ExecContext(ctx, sqliteSetTeamCaptain, campaignName, teamName, scpName, loginName)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		// not a team member, so keep the old captain
+		err = tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+const sqliteSelectIsTeamCaptain = `SELECT EXISTS (SELECT 1 FROM participant
+		INNER JOIN team ON participant.fk_team = team.Id
+		INNER JOIN campaign ON team.fk_campaign = campaign.Id
+		INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+		WHERE campaign.name = ?1
+		  AND team.name = ?2
+		  AND source_control_provider.name = ?3
+		  AND login_name = ?4
+		  AND captain)`
+
+func (p *SqliteDB) IsTeamCaptain(ctx context.Context, campaignName, teamName, scpName, loginName string) (isCaptain bool, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.db.QueryRowContext(ctx, sqliteSelectIsTeamCaptain, campaignName, teamName, scpName, loginName).Scan(&isCaptain)
+	return
+}
+
+const sqliteRemoveParticipantFromTeam = `UPDATE participant
+		SET fk_team = NULL, captain = FALSE
+		WHERE fk_team = ` + sqliteSelectTeamId + `
+		  AND fk_scp = (SELECT Id FROM source_control_provider WHERE name = ?3)
+		  AND login_name = ?4`
+
+func (p *SqliteDB) RemoveParticipantFromTeam(ctx context.Context, teamName, campaignName, scpName, loginName string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqliteRemoveParticipantFromTeam, campaignName, teamName, scpName, loginName)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqliteInsertTeamScoreEvent = `INSERT INTO team_score_event
+		(Id, fk_team, kind, category, points, reason, created_on)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)`
+
+const sqliteSelectTeamIdByName = `SELECT ` + sqliteSelectTeamId
+
+func (p *SqliteDB) InsertTeamScoreAdjustments(ctx context.Context, campaignName string, request *types.TeamScoreAdjustmentRequest, now time.Time) (events []types.TeamScoreEvent, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			events = nil
+		}
+	}()
+
+	for _, adjustment := range request.Adjustments {
+		event := types.TeamScoreEvent{
+			Id:        newId(),
+			TeamName:  adjustment.TeamName,
+			Kind:      types.TeamScoreKindJudgedAward,
+			Category:  request.Category,
+			Points:    adjustment.Points,
+			Reason:    adjustment.Reason,
+			CreatedOn: sql.NullTime{Time: now, Valid: true},
+		}
+		var teamId sql.NullString
+		err = tx.QueryRowContext(ctx, sqliteSelectTeamIdByName, campaignName, event.TeamName).Scan(&teamId)
+		if err == nil && !teamId.Valid {
+			err = sql.ErrNoRows
+		}
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("no team: campaignName: %s, name: %s: %w", campaignName, event.TeamName, err)
+		}
+		if err != nil {
+			return
+		}
+		_, err = tx.ExecContext(ctx, sqliteInsertTeamScoreEvent, event.Id, teamId.String, event.Kind, event.Category,
+			event.Points, event.Reason, sqliteTime(now))
+		if err != nil {
+			return
+		}
+		events = append(events, event)
+	}
+
+	err = tx.Commit()
+	return
+}
+
+const sqliteSelectTeamScoreHistory = `SELECT team_score_event.Id, team.name, kind, category, '', points, reason, team_score_event.created_on
+		FROM team_score_event
+		INNER JOIN team ON team_score_event.fk_team = team.Id
+		INNER JOIN campaign ON team.fk_campaign = campaign.Id
+		WHERE campaign.name = ?1
+		  AND team.name = ?2
+		UNION ALL
+		SELECT '', team.name, '` + types.TeamScoreKindPullRequest + `', '', participant.login_name, scoring_event.points,
+			scoring_event.repoOwner || '/' || scoring_event.repoName || ' #' || scoring_event.pr, scoring_event.scored_on
+		FROM scoring_event
+		INNER JOIN participant ON participant.fk_campaign = scoring_event.fk_campaign
+			AND participant.fk_scp = scoring_event.fk_scp
+			AND participant.login_name = scoring_event.username
+		INNER JOIN team ON participant.fk_team = team.Id
+		INNER JOIN campaign ON team.fk_campaign = campaign.Id
+		WHERE campaign.name = ?1
+		  AND team.name = ?2
+		  AND scoring_event.voided_on IS NULL
+		ORDER BY 8 NULLS LAST`
+
+func (p *SqliteDB) SelectTeamScoreHistory(ctx context.Context, campaignName, teamName string) (events []types.TeamScoreEvent, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectTeamScoreHistory, campaignName, teamName)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event := types.TeamScoreEvent{}
+		err = rows.Scan(&event.Id, &event.TeamName, &event.Kind, &event.Category, &event.LoginName, &event.Points,
+			&event.Reason, &event.CreatedOn)
+		if err != nil {
+			return
+		}
+		events = append(events, event)
+	}
+	return
+}
+
+const sqliteSelectParticipantIdByName = `(SELECT participant.Id FROM participant
+			INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+			INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+			WHERE campaign.name = ?1
+			  AND source_control_provider.name = ?2
+			  AND login_name = ?3)`
+
+const sqliteUpdateParticipantContact = `UPDATE participant
+		SET Email = COALESCE(?4, Email),
+			DisplayName = COALESCE(?5, DisplayName)
+		WHERE Id = ` + sqliteSelectParticipantIdByName
+
+func (p *SqliteDB) UpdateParticipantContact(ctx context.Context, scpName, loginName string, request *types.ParticipantProfileRequest) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqliteUpdateParticipantContact, request.CampaignName, scpName, loginName, request.Email, request.DisplayName)
+	if err != nil {
+		return
+	}
+	return res.RowsAffected()
+}
+
+const sqliteUpdateParticipantProfile = `UPDATE participant
+		SET DisplayName = COALESCE(NULLIF(DisplayName, ''), NULLIF(?2, '')),
+			avatar_url = COALESCE(NULLIF(?3, ''), avatar_url),
+			profile_refreshed_on = ?4
+		WHERE Id = ?1`
+
+func (p *SqliteDB) UpdateParticipantProfile(ctx context.Context, participantId, displayName, avatarUrl string, now time.Time) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err = p.db.ExecContext(ctx, sqliteUpdateParticipantProfile, participantId, displayName, avatarUrl, sqliteTime(now))
+	return
+}
+
+const sqliteSelectParticipantsToRefresh = `SELECT participant.Id, campaign.name, source_control_provider.name, login_name
+		FROM participant
+		INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+		INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+		WHERE profile_refreshed_on IS NULL OR profile_refreshed_on < ?1
+		ORDER BY profile_refreshed_on NULLS FIRST
+		LIMIT ?2`
+
+func (p *SqliteDB) SelectParticipantsToRefresh(ctx context.Context, refreshedBefore time.Time, limit int) (participants []types.ParticipantStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectParticipantsToRefresh, sqliteTime(refreshedBefore), limit)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		participant := types.ParticipantStruct{}
+		err = rows.Scan(&participant.ID, &participant.CampaignName, &participant.ScpName, &participant.LoginName)
+		if err != nil {
+			return
+		}
+		participants = append(participants, participant)
+	}
+	return
+}
+
+const sqliteParticipantColumns = `participant.Id, campaign.name, source_control_provider.name, login_name, Email,
+		DisplayName, Score, team.name, JoinedAt, COALESCE(avatar_url, ''), participant.version`
+
+const sqliteParticipantJoins = `
+		FROM participant
+		LEFT JOIN team ON team.Id = participant.fk_team
+		INNER JOIN campaign ON campaign.Id = participant.fk_campaign
+		INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id`
+
+// scanParticipant reads the sqliteParticipantColumns.
+func scanParticipant(row sqliteRow, participant *types.ParticipantStruct) (err error) {
+	var nullableTeamName sql.NullString
+	err = row.Scan(&participant.ID, &participant.CampaignName, &participant.ScpName, &participant.LoginName,
+		&participant.Email, &participant.DisplayName, &participant.Score, &nullableTeamName, &participant.JoinedAt,
+		&participant.AvatarUrl, &participant.Version)
+	participant.TeamName = nullableTeamName.String
+	return
+}
+
+func (p *SqliteDB) selectParticipants(ctx context.Context, query string, args ...interface{}) (participants []types.ParticipantStruct, err error) {
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		participant := types.ParticipantStruct{}
+		err = scanParticipant(rows, &participant)
+		if err != nil {
+			return
+		}
+		participants = append(participants, participant)
+	}
+	return
+}
+
+const sqliteSelectParticipantDetail = `SELECT ` + sqliteParticipantColumns + sqliteParticipantJoins + `
+		WHERE campaign.name = ?1
+		  AND source_control_provider.name = ?2
+		  AND participant.login_name = ?3`
+
+func (p *SqliteDB) SelectParticipantDetail(ctx context.Context, campaignName, scpName, loginName string) (participant *types.ParticipantStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	participant = new(types.ParticipantStruct)
+	err = scanParticipant(p.db.QueryRowContext(ctx, sqliteSelectParticipantDetail, campaignName, scpName, loginName), participant)
+	if err != nil {
+		p.logger.Error("getParticipantDetail scan error", zap.Error(err))
+	}
+	return
+}
+
+const sqliteSelectParticipantsByCampaign = `SELECT ` + sqliteParticipantColumns + sqliteParticipantJoins + `
+		WHERE campaign.name = ?1`
+
+func (p *SqliteDB) SelectParticipantsInCampaign(ctx context.Context, campaignName string) (participants []types.ParticipantStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.selectParticipants(ctx, sqliteSelectParticipantsByCampaign, campaignName)
+}
+
+const sqliteSelectParticipantsVersion = `SELECT
+		(SELECT COUNT(*) FROM participant WHERE fk_campaign = campaign.Id),
+		MAX(
+			(SELECT COALESCE(MAX(updated_on), '1970-01-01 00:00:00.000000000+00:00') FROM participant WHERE fk_campaign = campaign.Id),
+			(SELECT COALESCE(MAX(updated_on), '1970-01-01 00:00:00.000000000+00:00') FROM team WHERE fk_campaign = campaign.Id))
+		FROM campaign
+		WHERE name = ?1`
+
+func (p *SqliteDB) SelectParticipantsVersion(ctx context.Context, campaignName string) (version types.ListVersion, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = scanListVersion(p.db.QueryRowContext(ctx, sqliteSelectParticipantsVersion, campaignName), &version)
+	return
+}
+
+// LIKE ignores the case of ascii letters in SQLite. An exact match is listed first.
+const sqliteSearchParticipants = `SELECT ` + sqliteParticipantColumns + sqliteParticipantJoins + `
+		WHERE login_name LIKE '%' || ?1 || '%' ESCAPE '\'
+		ORDER BY lower(login_name) <> lower(?2), login_name, campaign.name, source_control_provider.name
+		LIMIT ?3`
+
+func (p *SqliteDB) SearchParticipants(ctx context.Context, login string, limit int) (participants []types.ParticipantStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return p.selectParticipants(ctx, sqliteSearchParticipants, likeEscaper.Replace(login), login, limit)
+}
+
+const sqliteUpdateParticipant = `UPDATE participant
+		SET
+		    fk_campaign = (SELECT Id FROM campaign WHERE name = ?1),
+		    fk_scp = (SELECT Id FROM source_control_provider WHERE name = ?2),
+		    login_name = ?3,
+		    Email = ?4,
+		    DisplayName = ?5,
+		    Score = ?6,
+		    fk_team = (SELECT Id FROM team WHERE name = ?7),
+		    version = version + 1
+		WHERE Id = ?8 AND version = ?9`
+
+const sqliteSelectParticipantVersion = `SELECT version FROM participant WHERE Id = ?1`
+
+func (p *SqliteDB) UpdateParticipant(ctx context.Context, participant *types.ParticipantStruct) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqliteUpdateParticipant, participant.CampaignName, participant.ScpName,
+		participant.LoginName, participant.Email, participant.DisplayName, participant.Score, participant.TeamName,
+		participant.ID, participant.Version)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		err = staleUpdate(p.db.QueryRowContext(ctx, sqliteSelectParticipantVersion, participant.ID), "participant")
+		return
+	}
+	participant.Version++
+	return
+}
+
+const sqlitePatchParticipant = `UPDATE participant
+		SET
+		    fk_campaign = CASE WHEN ?1 IS NULL THEN fk_campaign ELSE (SELECT Id FROM campaign WHERE name = ?1) END,
+		    fk_scp = CASE WHEN ?2 IS NULL THEN fk_scp ELSE (SELECT Id FROM source_control_provider WHERE name = ?2) END,
+		    login_name = COALESCE(?3, login_name),
+		    Email = COALESCE(?4, Email),
+		    DisplayName = COALESCE(?5, DisplayName),
+		    Score = COALESCE(?6, Score),
+		    fk_team = CASE WHEN ?8 THEN (SELECT Id FROM team WHERE name = ?7) ELSE fk_team END,
+		    version = version + 1
+		WHERE Id = ?9 AND version = ?10`
+
+func (p *SqliteDB) PatchParticipant(ctx context.Context, patch *types.ParticipantPatch) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqlitePatchParticipant, patchArgs(patch)...)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		err = staleUpdate(p.db.QueryRowContext(ctx, sqliteSelectParticipantVersion, patch.ID), "participant")
+		return
+	}
+	patch.Version++
+	return
+}
+
+const sqliteSelectParticipantIdToDelete = `SELECT ` + sqliteSelectParticipantIdByName
+
+const sqliteDeleteParticipant = `DELETE FROM participant WHERE Id = ?1`
+
+// DeleteParticipant removes the participant. sql.ErrNoRows is returned when there is no such participant.
+func (p *SqliteDB) DeleteParticipant(ctx context.Context, campaign, scpName, loginName string) (participantId string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	defer func() {
+		if err != nil {
+			p.logger.Error("error deleting participant",
+				zap.String("campaign", campaign), zap.String("scpName", scpName),
+				zap.String("loginName", loginName), zap.Error(err))
+		}
+	}()
+
+	var id sql.NullString
+	err = p.db.QueryRowContext(ctx, sqliteSelectParticipantIdToDelete, campaign, scpName, loginName).Scan(&id)
+	if err == nil && !id.Valid {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		return
+	}
+
+	_, err = p.db.ExecContext(ctx, sqliteDeleteParticipant, id.String)
+	if err == nil {
+		participantId = id.String
+	}
+	return
+}
+
+const sqliteInsertBan = `INSERT INTO ban
+		(Id, fk_scp, login_name, reason, banned_on)
+		VALUES (?1, (SELECT Id FROM source_control_provider WHERE LOWER(name) = LOWER(?2)), LOWER(?3), ?4, ?5)`
+
+func (p *SqliteDB) InsertBan(ctx context.Context, ban *types.BanStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	id, now := newId(), time.Now().UTC()
+	_, err = p.db.ExecContext(ctx, sqliteInsertBan, id, ban.ScpName, ban.LoginName, ban.Reason, sqliteTime(now))
+	err = sqliteDuplicateError(err, "ban")
+	if err == nil {
+		ban.ID, ban.LoginName, ban.BannedOn = id, strings.ToLower(ban.LoginName), now
+	}
+	return
+}
+
+func (p *SqliteDB) SelectBans(ctx context.Context) (bans []types.BanStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqlSelectBans)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		ban := types.BanStruct{}
+		err = rows.Scan(&ban.ID, &ban.ScpName, &ban.LoginName, &ban.Reason, &ban.BannedOn)
+		if err != nil {
+			return
+		}
+		bans = append(bans, ban)
+	}
+	return
+}
+
+const sqliteDeleteBan = `DELETE FROM ban
+		WHERE fk_scp = (SELECT Id FROM source_control_provider WHERE LOWER(name) = LOWER(?1))
+			AND login_name = LOWER(?2)`
+
+func (p *SqliteDB) DeleteBan(ctx context.Context, scpName, loginName string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqliteDeleteBan, scpName, loginName)
+	if err != nil {
+		return
+	}
+	return res.RowsAffected()
+}
+
+const sqliteSelectBanned = `SELECT EXISTS(SELECT ban.Id FROM ban
+		INNER JOIN source_control_provider ON source_control_provider.Id = ban.fk_scp
+		WHERE LOWER(source_control_provider.name) = LOWER(?1)
+			AND ban.login_name = LOWER(?2))`
+
+func (p *SqliteDB) SelectBanned(ctx context.Context, scpName, loginName string) (banned bool, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.db.QueryRowContext(ctx, sqliteSelectBanned, scpName, loginName).Scan(&banned)
+	return
+}
+
+const sqliteSelectTeamSize = `SELECT campaign.max_team_size,
+		(SELECT COUNT(*) FROM participant
+			WHERE participant.fk_team = team.Id
+			  AND NOT (participant.fk_scp = (SELECT Id FROM source_control_provider WHERE name = ?3)
+				AND participant.login_name = ?4)),
+		EXISTS (SELECT 1 FROM participant
+			WHERE participant.fk_campaign = campaign.Id
+			  AND participant.fk_scp = (SELECT Id FROM source_control_provider WHERE name = ?3)
+			  AND participant.login_name = ?4
+			  AND participant.captain
+			  AND participant.fk_team <> team.Id)
+		FROM team
+		INNER JOIN campaign ON team.fk_campaign = campaign.Id
+		WHERE team.name = ?1
+		  AND campaign.name = ?2`
+
+const sqliteUpdateParticipantTeam = `UPDATE participant
+		SET fk_team = (SELECT Id FROM team WHERE name = ?1 AND fk_campaign = (SELECT Id FROM campaign WHERE name = ?2))
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?2)
+		 AND fk_scp = (SELECT Id FROM source_control_provider WHERE name = ?3)
+		 AND login_name = ?4`
+
+func (p *SqliteDB) UpdateParticipantTeam(ctx context.Context, teamName, campaignName, scpName, loginName string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	rowsAffected, err = updateSqliteParticipantTeam(ctx, tx, teamName, campaignName, scpName, loginName)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+// updateSqliteParticipantTeam is updateParticipantTeam without the row lock, as SQLite locks the whole database for
+// the write of the transaction.
+func updateSqliteParticipantTeam(ctx context.Context, tx *sql.Tx, teamName, campaignName, scpName, loginName string) (rowsAffected int64, err error) {
+	var maxTeamSize, teamSize int
+	var captainElsewhere bool
+	err = tx.QueryRowContext(ctx, sqliteSelectTeamSize, teamName, campaignName, scpName, loginName).
+		Scan(&maxTeamSize, &teamSize, &captainElsewhere)
+	if err != nil {
+		return
+	}
+	if captainElsewhere {
+		err = &CaptainMoveError{CampaignName: campaignName, ScpName: scpName, LoginName: loginName}
+		return
+	}
+	if maxTeamSize > 0 && teamSize >= maxTeamSize {
+		err = &TeamFullError{CampaignName: campaignName, TeamName: teamName, MaxTeamSize: maxTeamSize}
+		return
+	}
+
+	res, err := tx.ExecContext(ctx, sqliteUpdateParticipantTeam, teamName, campaignName, scpName, loginName)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+func (p *SqliteDB) UpdateParticipantTeams(ctx context.Context, assignments []types.TeamAssignment) (results []types.TeamAssignmentResult, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	failed := false
+	for _, assignment := range assignments {
+		result := types.TeamAssignmentResult{TeamAssignment: assignment}
+
+		var rowsAffected int64
+		rowsAffected, err = updateSqliteParticipantTeam(ctx, tx, assignment.TeamName, assignment.CampaignName, assignment.ScpName, assignment.LoginName)
+		var teamFullErr *TeamFullError
+		var captainMoveErr *CaptainMoveError
+		if errors.As(err, &teamFullErr) || errors.As(err, &captainMoveErr) {
+			result.Error = err.Error()
+		} else if err == sql.ErrNoRows {
+			result.Error = fmt.Sprintf("no team: campaignName: %s, name: %s", assignment.CampaignName, assignment.TeamName)
+		} else if err != nil {
+			return
+		} else if rowsAffected == 0 {
+			result.Error = fmt.Sprintf("no participant: campaignName: %s, scpName: %s, loginName: %s",
+				assignment.CampaignName, assignment.ScpName, assignment.LoginName)
+		}
+		err = nil
+
+		failed = failed || result.Error != ""
+		results = append(results, result)
+	}
+
+	if failed {
+		err = tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return
+	}
+	for i := range results {
+		results[i].Assigned = true
+	}
+	return
+}
+
+func (p *SqliteDB) InsertSeedFixture(ctx context.Context, fixture *types.SeedFixture) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for i := range fixture.Campaigns {
+		campaign := &fixture.Campaigns[i]
+		id := newId()
+		_, err = tx.ExecContext(ctx, sqliteInsertCampaign, id, campaign.Name, sqliteTime(campaign.StartOn), sqliteTime(campaign.EndOn),
+			campaign.MaxTeamSize, campaign.TieBreaker, campaign.UnclassifiedBonus, campaign.TenantName, campaign.TimeZone,
+			campaign.FreezeScoring, sqliteNullTime(campaign.RegistrationOpensOn), sqliteNullTime(campaign.RegistrationClosesOn))
+		if err = sqliteDuplicateError(err, "campaign"); err != nil {
+			return
+		}
+		campaign.ID = id
+	}
+	for i := range fixture.Bugs {
+		if err = sqliteDuplicateError(insertSqliteBug(ctx, tx, fixture.Bugs[i].Campaign, &fixture.Bugs[i]), "bug"); err != nil {
+			return
+		}
+	}
+	for i := range fixture.Organizations {
+		organization := &fixture.Organizations[i]
+		id := newId()
+		_, err = tx.ExecContext(ctx, sqliteInsertOrganization, id, organization.SCPName, organization.Organization, organization.CampaignName)
+		if err = sqliteDuplicateError(err, "organization"); err != nil {
+			return
+		}
+		organization.ID = id
+	}
+	for i := range fixture.Teams {
+		team := &fixture.Teams[i]
+		id := newId()
+		_, err = tx.ExecContext(ctx, sqliteInsertTeam, id, team.CampaignName, team.Name)
+		if err = sqliteDuplicateError(err, "team"); err != nil {
+			return
+		}
+		team.Id = id
+	}
+	for i := range fixture.Participants {
+		participant := &fixture.Participants[i]
+		if err = sqliteDuplicateError(p.insertParticipant(ctx, tx, participant), "participant"); err != nil {
+			return
+		}
+		if participant.TeamName == "" {
+			continue
+		}
+		_, err = updateSqliteParticipantTeam(ctx, tx, participant.TeamName, participant.CampaignName, participant.ScpName, participant.LoginName)
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("no team: campaignName: %s, name: %s: %w", participant.CampaignName, participant.TeamName, err)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	err = tx.Commit()
+	return
+}
+
+const sqliteInsertBug = `INSERT INTO bug
+		(Id, fk_campaign, category, pointValue)
+		VALUES (?1, (SELECT Id FROM campaign WHERE name = ?2), ?3, ?4)`
+
+func (p *SqliteDB) InsertBug(ctx context.Context, bug *types.BugStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = insertSqliteBug(ctx, p.db, bug.Campaign, bug)
+	if err != nil {
+		p.logger.Error("error inserting bug", zap.Any("bug", bug), zap.Error(err))
+		err = sqliteDuplicateError(err, "bug")
+	}
+	return
+}
+
+func insertSqliteBug(ctx context.Context, db sqliteExecer, campaignName string, bug *types.BugStruct) (err error) {
+	id := newId()
+	_, err = db.ExecContext(ctx, sqliteInsertBug, id, campaignName, bug.Category, bug.PointValue)
+	if err == nil {
+		bug.Id = id
+	}
+	return
+}
+
+const sqliteSelectPointValueOverrideKey = `SELECT campaign.Id, organization.Id
+		FROM campaign, organization
+		INNER JOIN source_control_provider ON source_control_provider.Id = organization.fk_scp
+		WHERE campaign.name = ?1
+		  AND LOWER(source_control_provider.name) = LOWER(?2)
+		  AND LOWER(organization.Organization) = LOWER(?3)
+		  AND (organization.fk_campaign IS NULL OR organization.fk_campaign = campaign.Id)
+		ORDER BY organization.fk_campaign NULLS LAST
+		LIMIT 1`
+
+const sqliteUpsertPointValueOverride = `INSERT INTO point_value_override
+		(Id, fk_campaign, fk_organization, category, point_value)
+		VALUES (?1, ?2, ?3, ?4, ?5)
+		ON CONFLICT (fk_campaign, fk_organization, category) DO
+			UPDATE SET point_value = excluded.point_value`
+
+const sqliteSelectPointValueOverrideId = `SELECT Id FROM point_value_override
+		WHERE fk_campaign = ?1 AND fk_organization = ?2 AND category = ?3`
+
+// UpsertPointValueOverride sets the point value of a bug category for an organization in the campaign. Returns
+// sql.ErrNoRows if there is no such campaign or organization.
+func (p *SqliteDB) UpsertPointValueOverride(ctx context.Context, override *types.PointValueOverride) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var campaignId, organizationId string
+	err = tx.QueryRowContext(ctx, sqliteSelectPointValueOverrideKey, override.CampaignName, override.ScpName, override.Organization).
+		Scan(&campaignId, &organizationId)
+	if err != nil {
+		return
+	}
+	_, err = tx.ExecContext(ctx, sqliteUpsertPointValueOverride, newId(), campaignId, organizationId, override.Category, override.PointValue)
+	if err != nil {
+		return
+	}
+	err = tx.QueryRowContext(ctx, sqliteSelectPointValueOverrideId, campaignId, organizationId, override.Category).Scan(&override.Id)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+const sqliteSelectPointValueOverrides = `SELECT point_value_override.Id, source_control_provider.name, organization.Organization,
+			point_value_override.category, point_value_override.point_value
+		FROM point_value_override
+		INNER JOIN organization ON organization.Id = point_value_override.fk_organization
+		INNER JOIN source_control_provider ON source_control_provider.Id = organization.fk_scp
+		WHERE point_value_override.fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+		ORDER BY source_control_provider.name, organization.Organization, point_value_override.category`
+
+func (p *SqliteDB) SelectPointValueOverrides(ctx context.Context, campaignName string) (overrides []types.PointValueOverride, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectPointValueOverrides, campaignName)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		override := types.PointValueOverride{CampaignName: campaignName}
+		err = rows.Scan(&override.Id, &override.ScpName, &override.Organization, &override.Category, &override.PointValue)
+		if err != nil {
+			return
+		}
+		overrides = append(overrides, override)
+	}
+	return
+}
+
+const sqliteDeletePointValueOverride = `DELETE FROM point_value_override
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+		  AND fk_organization IN (SELECT organization.Id FROM organization
+			INNER JOIN source_control_provider ON source_control_provider.Id = organization.fk_scp
+			WHERE LOWER(source_control_provider.name) = LOWER(?2) AND LOWER(organization.Organization) = LOWER(?3))
+		  AND category = ?4`
+
+func (p *SqliteDB) DeletePointValueOverride(ctx context.Context, override *types.PointValueOverride) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqliteDeletePointValueOverride, override.CampaignName, override.ScpName, override.Organization, override.Category)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqliteInsertBugCategoryExclusion = `INSERT INTO bug_category_exclusion
+		(Id, fk_campaign, category)
+		SELECT ?1, campaign.Id, ?3
+			FROM campaign
+			WHERE campaign.name = ?2`
+
+// InsertBugCategoryExclusion keeps a bug category from scoring in the campaign. Returns sql.ErrNoRows if there is no
+// such campaign.
+func (p *SqliteDB) InsertBugCategoryExclusion(ctx context.Context, exclusion *types.BugCategoryExclusion) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	id := newId()
+	res, err := p.db.ExecContext(ctx, sqliteInsertBugCategoryExclusion, id, exclusion.CampaignName, exclusion.Category)
+	if err != nil {
+		err = sqliteDuplicateError(err, "bug category exclusion")
+		return
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		err = sql.ErrNoRows
+		return
+	}
+	exclusion.Id = id
+	return
+}
+
+const sqliteSelectBugCategoryExclusions = `SELECT Id, category
+		FROM bug_category_exclusion
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+		ORDER BY category`
+
+func (p *SqliteDB) SelectBugCategoryExclusions(ctx context.Context, campaignName string) (exclusions []types.BugCategoryExclusion, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectBugCategoryExclusions, campaignName)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		exclusion := types.BugCategoryExclusion{CampaignName: campaignName}
+		err = rows.Scan(&exclusion.Id, &exclusion.Category)
+		if err != nil {
+			return
+		}
+		exclusions = append(exclusions, exclusion)
+	}
+	return
+}
+
+const sqliteDeleteBugCategoryExclusion = `DELETE FROM bug_category_exclusion
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+		  AND category = ?2`
+
+func (p *SqliteDB) DeleteBugCategoryExclusion(ctx context.Context, campaignName, category string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqliteDeleteBugCategoryExclusion, campaignName, category)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqliteInsertRepositoryFilter = `INSERT INTO repository_filter
+		(Id, fk_campaign, kind, pattern)
+		SELECT ?1, campaign.Id, ?3, LOWER(?4)
+			FROM campaign
+			WHERE campaign.name = ?2`
+
+// InsertRepositoryFilter adds an allow or deny pattern of the repositories scored in the campaign. Returns
+// sql.ErrNoRows if there is no such campaign.
+func (p *SqliteDB) InsertRepositoryFilter(ctx context.Context, filter *types.RepositoryFilter) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	id := newId()
+	res, err := p.db.ExecContext(ctx, sqliteInsertRepositoryFilter, id, filter.CampaignName, filter.Kind, filter.Pattern)
+	if err != nil {
+		err = sqliteDuplicateError(err, "repository filter")
+		return
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		err = sql.ErrNoRows
+		return
+	}
+	filter.Id, filter.Pattern = id, strings.ToLower(filter.Pattern)
+	return
+}
+
+const sqliteSelectRepositoryFilters = `SELECT Id, kind, pattern
+		FROM repository_filter
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+		ORDER BY kind, pattern`
+
+func (p *SqliteDB) SelectRepositoryFilters(ctx context.Context, campaignName string) (filters []types.RepositoryFilter, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectRepositoryFilters, campaignName)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		filter := types.RepositoryFilter{CampaignName: campaignName}
+		err = rows.Scan(&filter.Id, &filter.Kind, &filter.Pattern)
+		if err != nil {
+			return
+		}
+		filters = append(filters, filter)
+	}
+	return
+}
+
+const sqliteDeleteRepositoryFilter = `DELETE FROM repository_filter
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+		  AND Id = ?2`
+
+func (p *SqliteDB) DeleteRepositoryFilter(ctx context.Context, campaignName, filterId string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqliteDeleteRepositoryFilter, campaignName, filterId)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqliteUpdateBug = `UPDATE bug
+		SET pointValue = ?1, version = version + 1
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?2) AND category = ?3 AND version = ?4`
+
+const sqliteSelectBugVersion = `SELECT version FROM bug
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1) AND category = ?2`
+
+func (p *SqliteDB) UpdateBug(ctx context.Context, bug *types.BugStruct) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqliteUpdateBug, bug.PointValue, bug.Campaign, bug.Category, bug.Version)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		err = staleUpdate(p.db.QueryRowContext(ctx, sqliteSelectBugVersion, bug.Campaign, bug.Category), "bug")
+		return
+	}
+	bug.Version++
+	return
+}
+
+func (p *SqliteDB) SelectBugs(ctx context.Context) (bugs []types.BugStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqlSelectBugs)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		bug := types.BugStruct{}
+		err = rows.Scan(&bug.Id, &bug.Campaign, &bug.Category, &bug.PointValue, &bug.Version)
+		if err != nil {
+			return
+		}
+		bugs = append(bugs, bug)
+	}
+	return
+}
+
+const sqliteSelectBugsInCampaign = sqlSelectBugs + `
+		WHERE campaign.name = ?1
+		ORDER BY category`
+
+func (p *SqliteDB) SelectBugsInCampaign(ctx context.Context, campaignName string) (bugs []types.BugStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectBugsInCampaign, campaignName)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		bug := types.BugStruct{}
+		err = rows.Scan(&bug.Id, &bug.Campaign, &bug.Category, &bug.PointValue, &bug.Version)
+		if err != nil {
+			return
+		}
+		bugs = append(bugs, bug)
+	}
+	err = rows.Err()
+	return
+}
+
+const sqliteSelectBugsVersion = `SELECT COUNT(*), COALESCE(MAX(updated_on), '1970-01-01 00:00:00.000000000+00:00') FROM bug`
+
+func (p *SqliteDB) SelectBugsVersion(ctx context.Context) (version types.ListVersion, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = scanListVersion(p.db.QueryRowContext(ctx, sqliteSelectBugsVersion), &version)
+	return
+}
+
+const sqliteUpsertCampaignConfigStage = `INSERT INTO campaign_config_stage
+		(fk_campaign, config, updated_on)
+		VALUES ((SELECT Id FROM campaign WHERE name = ?1), ?2, ?3)
+		ON CONFLICT (fk_campaign) DO
+			UPDATE SET config = excluded.config, updated_on = excluded.updated_on`
+
+func (p *SqliteDB) UpsertCampaignConfigStage(ctx context.Context, config *types.CampaignConfigStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	configJson, err := sqliteJson(config)
+	if err != nil {
+		return
+	}
+	now := time.Now().UTC()
+	_, err = p.db.ExecContext(ctx, sqliteUpsertCampaignConfigStage, config.Campaign, configJson, sqliteTime(now))
+	if err == nil {
+		config.UpdatedOn = now
+	}
+	return
+}
+
+const sqliteSelectCampaignConfigStage = `SELECT config, updated_on
+		FROM campaign_config_stage
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)`
+
+func (p *SqliteDB) SelectCampaignConfigStage(ctx context.Context, campaignName string) (config *types.CampaignConfigStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return selectCampaignConfigStage(p.db.QueryRowContext(ctx, sqliteSelectCampaignConfigStage, campaignName))
+}
+
+const sqliteDeleteCampaignConfigStage = `DELETE FROM campaign_config_stage
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)`
+
+func (p *SqliteDB) DeleteCampaignConfigStage(ctx context.Context, campaignName string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqliteDeleteCampaignConfigStage, campaignName)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqliteDeleteCampaignBugs = `DELETE FROM bug
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)`
+
+func (p *SqliteDB) PromoteCampaignConfigStage(ctx context.Context, campaignName string) (config *types.CampaignConfigStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	config, err = selectCampaignConfigStage(tx.QueryRowContext(ctx, sqliteSelectCampaignConfigStage, campaignName))
+	if err != nil {
+		return
+	}
+
+	_, err = tx.ExecContext(ctx, sqliteDeleteCampaignBugs, campaignName)
+	if err != nil {
+		return
+	}
+
+	for i := range config.Bugs {
+		bug := &config.Bugs[i]
+		err = insertSqliteBug(ctx, tx, campaignName, bug)
+		if err != nil {
+			p.logger.Error("error promoting bug", zap.Any("bug", bug), zap.Error(err))
+			return
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, sqliteDeleteCampaignConfigStage, campaignName)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+// execCampaignUpsert runs an insert of a row of the named campaign, returning sql.ErrNoRows when there is no such
+// campaign, like the postgres upserts that return the campaign id.
+func (p *SqliteDB) execCampaignUpsert(ctx context.Context, query string, args ...interface{}) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err == nil && rowsAffected == 0 {
+		err = sql.ErrNoRows
+	}
+	return
+}
+
+const sqliteUpsertSlackNotification = `INSERT INTO slack_notification
+		(fk_campaign, webhook_url, point_threshold, notify_lead)
+		SELECT Id, ?2, ?3, ?4 FROM campaign WHERE name = ?1
+		ON CONFLICT (fk_campaign) DO
+			UPDATE SET webhook_url = excluded.webhook_url, point_threshold = excluded.point_threshold,
+				notify_lead = excluded.notify_lead`
+
+func (p *SqliteDB) UpsertSlackNotification(ctx context.Context, config *types.SlackNotificationStruct) (err error) {
+	return p.execCampaignUpsert(ctx, sqliteUpsertSlackNotification, config.CampaignName, config.WebhookUrl, config.PointThreshold, config.NotifyLead)
+}
+
+const sqliteSelectSlackNotification = `SELECT webhook_url, point_threshold, notify_lead
+		FROM slack_notification
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)`
+
+func (p *SqliteDB) SelectSlackNotification(ctx context.Context, campaignName string) (config *types.SlackNotificationStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	config = &types.SlackNotificationStruct{CampaignName: campaignName}
+	err = p.db.QueryRowContext(ctx, sqliteSelectSlackNotification, campaignName).
+		Scan(&config.WebhookUrl, &config.PointThreshold, &config.NotifyLead)
+	if err != nil {
+		config = nil
+	}
+	return
+}
+
+const sqliteDeleteSlackNotification = `DELETE FROM slack_notification
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)`
+
+func (p *SqliteDB) DeleteSlackNotification(ctx context.Context, campaignName string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqliteDeleteSlackNotification, campaignName)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqliteUpsertCampaignPenalty = `INSERT INTO campaign_penalty
+		(fk_campaign, enabled, point_value, floor)
+		SELECT Id, ?2, ?3, ?4 FROM campaign WHERE name = ?1
+		ON CONFLICT (fk_campaign) DO
+			UPDATE SET enabled = excluded.enabled, point_value = excluded.point_value, floor = excluded.floor`
+
+func (p *SqliteDB) UpsertCampaignPenalty(ctx context.Context, penalty *types.CampaignPenaltyStruct) (err error) {
+	return p.execCampaignUpsert(ctx, sqliteUpsertCampaignPenalty, penalty.CampaignName, penalty.Enabled, penalty.PointValue, penalty.Floor)
+}
+
+const sqliteSelectCampaignPenalty = `SELECT enabled, point_value, floor
+		FROM campaign_penalty
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)`
+
+func (p *SqliteDB) SelectCampaignPenalty(ctx context.Context, campaignName string) (penalty *types.CampaignPenaltyStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	penalty = &types.CampaignPenaltyStruct{CampaignName: campaignName}
+	err = p.db.QueryRowContext(ctx, sqliteSelectCampaignPenalty, campaignName).
+		Scan(&penalty.Enabled, &penalty.PointValue, &penalty.Floor)
+	if err != nil {
+		penalty = nil
+	}
+	return
+}
+
+const sqliteUpsertCampaignCaps = `INSERT INTO campaign_caps
+		(fk_campaign, per_pull_request, per_day)
+		SELECT Id, ?2, ?3 FROM campaign WHERE name = ?1
+		ON CONFLICT (fk_campaign) DO
+			UPDATE SET per_pull_request = excluded.per_pull_request, per_day = excluded.per_day`
+
+func (p *SqliteDB) UpsertCampaignCaps(ctx context.Context, caps *types.CampaignCapsStruct) (err error) {
+	return p.execCampaignUpsert(ctx, sqliteUpsertCampaignCaps, caps.CampaignName, caps.PerPullRequest, caps.PerDay)
+}
+
+const sqliteUpsertDuplicatePolicy = `INSERT INTO campaign_duplicate_policy
+		(fk_campaign, policy, weight)
+		SELECT Id, ?2, ?3 FROM campaign WHERE name = ?1
+		ON CONFLICT (fk_campaign) DO
+			UPDATE SET policy = excluded.policy, weight = excluded.weight`
+
+func (p *SqliteDB) UpsertDuplicatePolicy(ctx context.Context, policy *types.DuplicatePolicyStruct) (err error) {
+	return p.execCampaignUpsert(ctx, sqliteUpsertDuplicatePolicy, policy.CampaignName, policy.Policy, policy.Weight)
+}
+
+const sqliteSelectDuplicatePolicy = `SELECT policy, weight
+		FROM campaign_duplicate_policy
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)`
+
+func (p *SqliteDB) SelectDuplicatePolicy(ctx context.Context, campaignName string) (policy *types.DuplicatePolicyStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	policy = &types.DuplicatePolicyStruct{CampaignName: campaignName}
+	err = p.db.QueryRowContext(ctx, sqliteSelectDuplicatePolicy, campaignName).
+		Scan(&policy.Policy, &policy.Weight)
+	if err != nil {
+		policy = nil
+	}
+	return
+}
+
+const sqliteUpsertCoAuthorPolicy = `INSERT INTO campaign_coauthor_policy
+		(fk_campaign, policy)
+		SELECT Id, ?2 FROM campaign WHERE name = ?1
+		ON CONFLICT (fk_campaign) DO
+			UPDATE SET policy = excluded.policy`
+
+func (p *SqliteDB) UpsertCoAuthorPolicy(ctx context.Context, policy *types.CoAuthorPolicyStruct) (err error) {
+	return p.execCampaignUpsert(ctx, sqliteUpsertCoAuthorPolicy, policy.CampaignName, policy.Policy)
+}
+
+const sqliteSelectCoAuthorPolicy = `SELECT policy
+		FROM campaign_coauthor_policy
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)`
+
+func (p *SqliteDB) SelectCoAuthorPolicy(ctx context.Context, campaignName string) (policy *types.CoAuthorPolicyStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	policy = &types.CoAuthorPolicyStruct{CampaignName: campaignName}
+	err = p.db.QueryRowContext(ctx, sqliteSelectCoAuthorPolicy, campaignName).Scan(&policy.Policy)
+	if err != nil {
+		policy = nil
+	}
+	return
+}
+
+const sqliteUpsertCampaignPrivacy = `INSERT INTO campaign_privacy
+		(fk_campaign, anonymize, salt)
+		SELECT Id, ?2, ?3 FROM campaign WHERE name = ?1
+		ON CONFLICT (fk_campaign) DO
+			UPDATE SET anonymize = excluded.anonymize`
+
+func (p *SqliteDB) UpsertCampaignPrivacy(ctx context.Context, privacy *types.CampaignPrivacyStruct) (err error) {
+	return p.execCampaignUpsert(ctx, sqliteUpsertCampaignPrivacy, privacy.CampaignName, privacy.Anonymize, newId())
+}
+
+const sqliteSelectCampaignPrivacy = `SELECT anonymize, salt
+		FROM campaign_privacy
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)`
+
+func (p *SqliteDB) SelectCampaignPrivacy(ctx context.Context, campaignName string) (privacy *types.CampaignPrivacyStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	privacy = &types.CampaignPrivacyStruct{CampaignName: campaignName}
+	err = p.db.QueryRowContext(ctx, sqliteSelectCampaignPrivacy, campaignName).
+		Scan(&privacy.Anonymize, &privacy.Salt)
+	if err != nil {
+		privacy = nil
+	}
+	return
+}
+
+const sqliteSelectCampaignCaps = `SELECT per_pull_request, per_day
+		FROM campaign_caps
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)`
+
+func (p *SqliteDB) SelectCampaignCaps(ctx context.Context, campaignName string) (caps *types.CampaignCapsStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	caps = &types.CampaignCapsStruct{CampaignName: campaignName}
+	err = p.db.QueryRowContext(ctx, sqliteSelectCampaignCaps, campaignName).
+		Scan(&caps.PerPullRequest, &caps.PerDay)
+	if err != nil {
+		caps = nil
+	}
+	return
+}
+
+const sqliteSelectUnclassifiedBonus = `SELECT unclassified_bonus FROM campaign WHERE name = ?1`
+
+func (p *SqliteDB) SelectUnclassifiedBonus(ctx context.Context, campaignName string) (bonus float64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.db.QueryRowContext(ctx, sqliteSelectUnclassifiedBonus, campaignName).Scan(&bonus)
+	return
+}
+
+const sqliteSelectTopParticipants = `SELECT participant.Id, login_name, Score
+		FROM participant
+		INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+		WHERE campaign.name = ?1
+		ORDER BY Score DESC, JoinedAt
+		LIMIT ?2`
+
+func (p *SqliteDB) SelectTopParticipants(ctx context.Context, campaignName string, count int) (participants []types.ParticipantStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectTopParticipants, campaignName, count)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		participant := types.ParticipantStruct{CampaignName: campaignName}
+		err = rows.Scan(&participant.ID, &participant.LoginName, &participant.Score)
+		if err != nil {
+			return
+		}
+		participants = append(participants, participant)
+	}
+	return
+}
+
+// SQLite has no full outer join, so ranks of removed participants are found apart from the participants
+const sqliteSelectLeaderboardStale = `SELECT EXISTS (SELECT 1
+		FROM participant
+		INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+		LEFT JOIN team ON participant.fk_team = team.Id
+		LEFT JOIN leaderboard_rank ON leaderboard_rank.fk_participant = participant.Id
+		WHERE leaderboard_rank.fk_participant IS NULL
+		   OR participant.updated_on <> leaderboard_rank.participant_updated_on
+		   OR team.updated_on IS NOT leaderboard_rank.team_updated_on
+		   OR campaign.updated_on <> leaderboard_rank.campaign_updated_on)
+	OR EXISTS (SELECT 1 FROM leaderboard_rank
+		WHERE NOT EXISTS (SELECT 1 FROM participant WHERE participant.Id = leaderboard_rank.fk_participant))`
+
+func (p *SqliteDB) SelectLeaderboardStale(ctx context.Context) (stale bool, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.db.QueryRowContext(ctx, sqliteSelectLeaderboardStale).Scan(&stale)
+	return
+}
+
+const sqliteDeleteLeaderboard = `DELETE FROM leaderboard_rank`
+
+// the ranks of the postgres leaderboard_rank view, with the bug categories read from scoring_event_category
+const sqliteRefreshLeaderboard = `INSERT INTO leaderboard_rank
+		(fk_participant, fk_campaign, scp_name, login_name, display_name, avatar_url, team_name, score, rank,
+		 refreshed_on, participant_updated_on, team_updated_on, campaign_updated_on)
+		SELECT participant.Id,
+		       participant.fk_campaign,
+		       source_control_provider.name,
+		       participant.login_name,
+		       COALESCE(participant.DisplayName, ''),
+		       COALESCE(participant.avatar_url, ''),
+		       team.name,
+		       COALESCE(participant.Score, 0),
+		       ROW_NUMBER() OVER (PARTITION BY participant.fk_campaign
+		           ORDER BY COALESCE(participant.Score, 0) DESC,
+		               CASE WHEN campaign.tie_breaker = 'reachedScore' THEN activity.reached_score_on END NULLS LAST,
+		               CASE WHEN campaign.tie_breaker = 'bugCategories' THEN COALESCE(activity.bug_categories, 0) END DESC NULLS LAST,
+		               CASE WHEN campaign.tie_breaker = 'joinedAt' THEN participant.JoinedAt END,
+		               participant.login_name,
+		               source_control_provider.name),
+		       ` + sqliteNow + `,
+		       participant.updated_on,
+		       team.updated_on,
+		       campaign.updated_on
+		FROM participant
+		         INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+		         INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+		         LEFT JOIN team ON participant.fk_team = team.Id
+		         -- when the participant last scored, and the number of distinct bug categories they fixed
+		         LEFT JOIN (SELECT scoring_event.fk_campaign, scoring_event.fk_scp, scoring_event.username,
+		                           MAX(scoring_event.scored_on) AS reached_score_on,
+		                           COUNT(DISTINCT scoring_event_category.category) AS bug_categories
+		                    FROM scoring_event
+		                             LEFT JOIN scoring_event_category ON scoring_event_category.fk_scoring_event = scoring_event.Id
+		                    WHERE scoring_event.voided_on IS NULL
+		                    GROUP BY scoring_event.fk_campaign, scoring_event.fk_scp, scoring_event.username) AS activity
+		                   ON activity.fk_campaign = participant.fk_campaign
+		                       AND activity.fk_scp = participant.fk_scp
+		                       AND activity.username = participant.login_name`
+
+// RefreshLeaderboard rewrites the leaderboard ranks of every campaign from the current scores, in one transaction.
+func (p *SqliteDB) RefreshLeaderboard(ctx context.Context) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, sqliteDeleteLeaderboard); err != nil {
+		return
+	}
+	if _, err = tx.ExecContext(ctx, sqliteRefreshLeaderboard); err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+const sqliteSelectLeaderboardTieBreaker = `SELECT tie_breaker FROM campaign WHERE name = ?1`
+
+const sqliteSelectLeaderboard = `SELECT rank, scp_name, login_name, display_name, avatar_url, COALESCE(team_name, ''), score,
+		refreshed_on
+		FROM leaderboard_rank
+		INNER JOIN campaign ON leaderboard_rank.fk_campaign = campaign.Id
+		WHERE campaign.name = ?1
+		ORDER BY rank
+		LIMIT ?2`
+
+// scanLeaderboardEntries reads the rank, participant and score of each entry, then the time the ranks were refreshed
+// when refreshedOn is not nil.
+func scanLeaderboardEntries(rows *sql.Rows, refreshedOn **time.Time) (entries []types.LeaderboardEntry, err error) {
+	defer rows.Close()
+
+	entries = []types.LeaderboardEntry{}
+	for rows.Next() {
+		entry := types.LeaderboardEntry{}
+		dest := []interface{}{&entry.Rank, &entry.ScpName, &entry.LoginName, &entry.DisplayName, &entry.AvatarUrl,
+			&entry.TeamName, &entry.Score}
+		var refreshed time.Time
+		if refreshedOn != nil {
+			dest = append(dest, &refreshed)
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return
+		}
+		if refreshedOn != nil {
+			*refreshedOn = &refreshed
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
+
+func (p *SqliteDB) SelectLeaderboard(ctx context.Context, campaignName string, limit int) (leaderboard *types.Leaderboard, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var tieBreaker string
+	err = p.db.QueryRowContext(ctx, sqliteSelectLeaderboardTieBreaker, campaignName).Scan(&tieBreaker)
+	if err != nil {
+		return
+	}
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectLeaderboard, campaignName, limit)
+	if err != nil {
+		return
+	}
+
+	board := &types.Leaderboard{
+		CampaignName:   campaignName,
+		TieBreaker:     tieBreaker,
+		TieBreakerRule: types.TieBreakerRules[tieBreaker],
+	}
+	board.Participants, err = scanLeaderboardEntries(rows, &board.RefreshedOn)
+	if err != nil {
+		return
+	}
+	leaderboard = board
+	return
+}
+
+const sqliteSelectTeamAwardPoints = `SELECT COALESCE((SELECT SUM(points) FROM team_score_event WHERE fk_team = team.Id), 0)
+		FROM team
+		INNER JOIN campaign ON team.fk_campaign = campaign.Id
+		WHERE campaign.name = ?1
+		  AND team.name = ?2`
+
+const sqliteSelectTeamLeaderboard = `SELECT ROW_NUMBER() OVER (ORDER BY rank), scp_name, login_name, display_name, avatar_url,
+		team_name, score, refreshed_on
+		FROM leaderboard_rank
+		INNER JOIN campaign ON leaderboard_rank.fk_campaign = campaign.Id
+		WHERE campaign.name = ?1
+		  AND team_name = ?2
+		ORDER BY rank`
+
+func (p *SqliteDB) SelectTeamLeaderboard(ctx context.Context, campaignName, teamName string) (leaderboard *types.TeamLeaderboard, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var awardPoints float64
+	err = p.db.QueryRowContext(ctx, sqliteSelectTeamAwardPoints, campaignName, teamName).Scan(&awardPoints)
+	if err != nil {
+		return
+	}
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectTeamLeaderboard, campaignName, teamName)
+	if err != nil {
+		return
+	}
+
+	board := &types.TeamLeaderboard{
+		CampaignName: campaignName,
+		TeamStanding: types.TeamStanding{TeamName: teamName, AwardPoints: awardPoints},
+	}
+	board.Participants, err = scanLeaderboardEntries(rows, &board.RefreshedOn)
+	if err != nil {
+		return
+	}
+	for _, entry := range board.Participants {
+		board.MemberPoints += entry.Score
+	}
+	board.Points = board.MemberPoints + board.AwardPoints
+	leaderboard = board
+	return
+}
+
+const sqliteInsertLeaderboardSnapshot = `INSERT INTO leaderboard_snapshot
+		(fk_campaign, tie_breaker, finalized_on)
+		SELECT Id, tie_breaker, ?2 FROM campaign WHERE name = ?1
+		ON CONFLICT (fk_campaign) DO NOTHING`
+
+const sqliteInsertLeaderboardSnapshotEntries = `INSERT INTO leaderboard_snapshot_entry
+		(fk_campaign, rank, scp_name, login_name, display_name, avatar_url, team_name, score)
+		SELECT fk_campaign, rank, scp_name, login_name, display_name, avatar_url, COALESCE(team_name, ''), score
+		FROM leaderboard_rank
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)`
+
+func (p *SqliteDB) InsertLeaderboardSnapshot(ctx context.Context, campaignName string, now time.Time) (finalized bool, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, sqliteInsertLeaderboardSnapshot, campaignName, sqliteTime(now))
+	if err != nil {
+		return
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		err = tx.Rollback()
+		return
+	}
+
+	_, err = tx.ExecContext(ctx, sqliteInsertLeaderboardSnapshotEntries, campaignName)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	finalized = err == nil
+	return
+}
+
+const sqliteSelectLeaderboardSnapshot = `SELECT leaderboard_snapshot.tie_breaker, leaderboard_snapshot.finalized_on
+		FROM leaderboard_snapshot
+		INNER JOIN campaign ON leaderboard_snapshot.fk_campaign = campaign.Id
+		WHERE campaign.name = ?1`
+
+const sqliteSelectLeaderboardSnapshotEntries = `SELECT rank, scp_name, login_name, display_name, avatar_url, team_name, score
+		FROM leaderboard_snapshot_entry
+		INNER JOIN campaign ON leaderboard_snapshot_entry.fk_campaign = campaign.Id
+		WHERE campaign.name = ?1
+		ORDER BY rank`
+
+func (p *SqliteDB) SelectLeaderboardSnapshot(ctx context.Context, campaignName string) (snapshot *types.LeaderboardSnapshot, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	finalSnapshot := &types.LeaderboardSnapshot{CampaignName: campaignName}
+	err = p.db.QueryRowContext(ctx, sqliteSelectLeaderboardSnapshot, campaignName).
+		Scan(&finalSnapshot.TieBreaker, &finalSnapshot.FinalizedOn)
+	if err != nil {
+		return
+	}
+	finalSnapshot.TieBreakerRule = types.TieBreakerRules[finalSnapshot.TieBreaker]
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectLeaderboardSnapshotEntries, campaignName)
+	if err != nil {
+		return
+	}
+	finalSnapshot.Participants, err = scanLeaderboardEntries(rows, nil)
+	if err != nil {
+		return
+	}
+	snapshot = finalSnapshot
+	return
+}
+
+const sqliteUpsertCampaignEmail = `INSERT INTO campaign_email
+		(fk_campaign, kind, enabled, subject, body)
+		SELECT Id, ?2, ?3, ?4, ?5 FROM campaign WHERE name = ?1
+		ON CONFLICT (fk_campaign, kind) DO
+			UPDATE SET enabled = excluded.enabled, subject = excluded.subject, body = excluded.body`
+
+func (p *SqliteDB) UpsertCampaignEmail(ctx context.Context, email *types.CampaignEmailStruct) (err error) {
+	return p.execCampaignUpsert(ctx, sqliteUpsertCampaignEmail, email.CampaignName, email.Kind, email.Enabled, email.Subject, email.Body)
+}
+
+const sqliteSelectCampaignEmails = `SELECT kind, enabled, subject, body
+		FROM campaign_email
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+		ORDER BY kind`
+
+func (p *SqliteDB) SelectCampaignEmails(ctx context.Context, campaignName string) (emails []types.CampaignEmailStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectCampaignEmails, campaignName)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		email := types.CampaignEmailStruct{CampaignName: campaignName}
+		err = rows.Scan(&email.Kind, &email.Enabled, &email.Subject, &email.Body)
+		if err != nil {
+			return
+		}
+		emails = append(emails, email)
+	}
+	return
+}
+
+const sqliteSelectCampaignEmail = `SELECT enabled, subject, body
+		FROM campaign_email
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+			AND kind = ?2`
+
+func (p *SqliteDB) SelectCampaignEmail(ctx context.Context, campaignName, kind string) (email *types.CampaignEmailStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	email = &types.CampaignEmailStruct{CampaignName: campaignName, Kind: kind}
+	err = p.db.QueryRowContext(ctx, sqliteSelectCampaignEmail, campaignName, kind).
+		Scan(&email.Enabled, &email.Subject, &email.Body)
+	if err != nil {
+		email = nil
+	}
+	return
+}
+
+const sqliteSelectBugsFixed = `SELECT COALESCE(SUM(bugs_fixed), 0)
+		FROM scoring_event
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+		  AND fk_scp = (SELECT Id FROM source_control_provider WHERE name = ?2)
+		  AND username = ?3
+		  AND voided_on IS NULL`
+
+func (p *SqliteDB) SelectBugsFixed(ctx context.Context, participant *types.ParticipantStruct) (bugsFixed float64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.db.QueryRowContext(ctx, sqliteSelectBugsFixed, participant.CampaignName, participant.ScpName, participant.LoginName).
+		Scan(&bugsFixed)
+	return
+}
+
+const sqliteInsertAchievement = `INSERT INTO achievement
+		(fk_participant, kind, awarded_on)
+		VALUES (?1, ?2, ?3)
+		ON CONFLICT (fk_participant, kind) DO NOTHING`
+
+func (p *SqliteDB) InsertAchievement(ctx context.Context, participantId, kind string, now time.Time) (awarded bool, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqliteInsertAchievement, participantId, kind, sqliteTime(now))
+	if err != nil {
+		return
+	}
+	rowsAffected, err := res.RowsAffected()
+	awarded = rowsAffected > 0
+	return
+}
+
+const sqliteSelectAchievements = `SELECT kind, awarded_on
+		FROM achievement
+		WHERE fk_participant = ` + sqliteSelectParticipantIdByName + `
+		ORDER BY awarded_on, kind`
+
+func (p *SqliteDB) SelectAchievements(ctx context.Context, campaignName, scpName, loginName string) (achievements []types.AchievementStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectAchievements, campaignName, scpName, loginName)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		achievement := types.AchievementStruct{}
+		err = rows.Scan(&achievement.Kind, &achievement.AwardedOn)
+		if err != nil {
+			return
+		}
+		achievements = append(achievements, achievement)
+	}
+	return
+}
+
+const sqliteSelectParticipantScoreAt = `SELECT COALESCE(SUM(revision.points), 0),
+			COUNT(CASE WHEN NOT revision.coauthor AND NOT revision.voided THEN 1 END)
+		FROM participant
+		INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+		INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+		LEFT JOIN (SELECT scoring_event.fk_campaign, scoring_event.fk_scp, scoring_event_revision.coauthor,
+				scoring_event_revision.points, scoring_event_revision.voided,
+				ROW_NUMBER() OVER (PARTITION BY scoring_event_revision.fk_scoring_event, scoring_event_revision.coauthor
+					ORDER BY scoring_event_revision.revised_on DESC, scoring_event_revision.Id DESC) AS latest
+			FROM scoring_event_revision
+			INNER JOIN scoring_event ON scoring_event_revision.fk_scoring_event = scoring_event.Id
+			WHERE scoring_event_revision.username = ?3
+				AND scoring_event_revision.revised_on <= ?4) AS revision
+			ON revision.fk_campaign = participant.fk_campaign
+			AND revision.fk_scp = participant.fk_scp
+			AND revision.latest = 1
+		WHERE campaign.name = ?1
+		  AND source_control_provider.name = ?2
+		  AND participant.login_name = ?3
+		GROUP BY participant.Id`
+
+func (p *SqliteDB) SelectParticipantScoreAt(ctx context.Context, campaignName, scpName, loginName string, at time.Time) (score *types.ParticipantScoreAt, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	score = &types.ParticipantScoreAt{CampaignName: campaignName, ScpName: scpName, LoginName: loginName, At: at}
+	err = p.db.QueryRowContext(ctx, sqliteSelectParticipantScoreAt, campaignName, scpName, loginName, sqliteTime(at)).
+		Scan(&score.Score, &score.ScoringEvents)
+	if err != nil {
+		score = nil
+	}
+	return
+}
+
+const sqliteSelectAchievementStats = `SELECT kind, COUNT(*)
+		FROM achievement
+		INNER JOIN participant ON achievement.fk_participant = participant.Id
+		INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+		WHERE campaign.name = ?1
+		GROUP BY kind
+		ORDER BY kind`
+
+func (p *SqliteDB) SelectAchievementStats(ctx context.Context, campaignName string) (stats []types.AchievementStat, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectAchievementStats, campaignName)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		stat := types.AchievementStat{}
+		err = rows.Scan(&stat.Kind, &stat.Participants)
+		if err != nil {
+			return
+		}
+		stats = append(stats, stat)
+	}
+	return
+}
+
+const sqliteInsertWebhook = `INSERT INTO webhook
+		(Id, target_url, secret, events, created_on)
+		VALUES (?1, ?2, ?3, ?4, ?5)`
+
+func (p *SqliteDB) InsertWebhook(ctx context.Context, hook *types.WebhookStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	if hook.Events == nil {
+		hook.Events = []string{}
+	}
+	eventsJson, err := sqliteJson(hook.Events)
+	if err != nil {
+		return
+	}
+	id, now := newId(), time.Now().UTC()
+	_, err = p.db.ExecContext(ctx, sqliteInsertWebhook, id, hook.TargetUrl, hook.Secret, eventsJson, sqliteTime(now))
+	if err == nil {
+		hook.ID, hook.CreatedOn = id, now
+	}
+	return
+}
+
+func (p *SqliteDB) SelectWebhooks(ctx context.Context) (hooks []types.WebhookStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqlSelectWebhooks)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		hook := types.WebhookStruct{}
+		var eventsJson []byte
+		err = rows.Scan(&hook.ID, &hook.TargetUrl, &hook.Secret, &eventsJson, &hook.CreatedOn)
+		if err != nil {
+			return
+		}
+		err = json.Unmarshal(eventsJson, &hook.Events)
+		if err != nil {
+			return
+		}
+		hooks = append(hooks, hook)
+	}
+	return
+}
+
+const sqliteDeleteWebhook = `DELETE FROM webhook WHERE Id = ?1`
+
+func (p *SqliteDB) DeleteWebhook(ctx context.Context, webhookId string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqliteDeleteWebhook, webhookId)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqliteSelectCampaignStatuses = `SELECT ` + sqliteCampaignColumns + `, ended_notified_on IS NULL,
+		CASE WHEN ?1 < start_on THEN 'upcoming'
+			WHEN ?1 < end_on THEN 'active'
+			ELSE 'ended' END
+		FROM campaign`
+
+const sqliteUpdateCampaignStatus = `UPDATE campaign
+		SET status = ?2,
+			ended_notified_on = CASE WHEN ?2 = 'ended' THEN COALESCE(ended_notified_on, ?3) ELSE ended_notified_on END
+		WHERE Id = ?1`
+
+// ClaimCampaignTransitions moves each campaign to the status it has at now, and returns the campaigns that changed
+// status since the last call. The transaction holds the write lock of the database, so each change is returned once.
+func (p *SqliteDB) ClaimCampaignTransitions(ctx context.Context, now time.Time) (transitions []types.CampaignTransition, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			transitions = nil
+		}
+	}()
+
+	rows, err := tx.QueryContext(ctx, sqliteSelectCampaignStatuses, sqliteTime(now))
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		transition := types.CampaignTransition{}
+		var toStatus string
+		if err = scanCampaign(rows, &transition.Campaign, &transition.FirstEnd, &toStatus); err != nil {
+			_ = rows.Close()
+			return
+		}
+		if toStatus != transition.Campaign.Status {
+			transition.FromStatus, transition.Campaign.Status = transition.Campaign.Status, toStatus
+			transitions = append(transitions, transition)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	for _, transition := range transitions {
+		_, err = tx.ExecContext(ctx, sqliteUpdateCampaignStatus, transition.Campaign.ID, transition.Campaign.Status, sqliteTime(now))
+		if err != nil {
+			return
+		}
+	}
+
+	err = tx.Commit()
+	return
+}
+
+const sqliteUpsertClusterInstance = `INSERT INTO cluster_instance
+		(instance_id, version, role, poll_leader, started_on, last_heartbeat)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6)
+		ON CONFLICT (instance_id) DO
+			UPDATE SET version = excluded.version, role = excluded.role, poll_leader = excluded.poll_leader,
+				started_on = excluded.started_on, last_heartbeat = excluded.last_heartbeat`
+
+func (p *SqliteDB) UpsertClusterInstance(ctx context.Context, instance *types.ClusterInstance) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err = p.db.ExecContext(ctx, sqliteUpsertClusterInstance, instance.InstanceId, instance.Version, instance.Role,
+		instance.PollLeader, sqliteTime(instance.StartedOn), sqliteTime(instance.LastHeartbeat))
+	return
+}
+
+func (p *SqliteDB) SelectClusterInstances(ctx context.Context) (instances []types.ClusterInstance, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqlSelectClusterInstances)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		instance := types.ClusterInstance{}
+		err = rows.Scan(&instance.InstanceId, &instance.Version, &instance.Role, &instance.PollLeader,
+			&instance.StartedOn, &instance.LastHeartbeat)
+		if err != nil {
+			return
+		}
+		instances = append(instances, instance)
+	}
+	return
+}
+
+const sqliteAcquirePollLease = `INSERT INTO poll_lease
+		(Id, instance_id, expires_on)
+		VALUES (TRUE, ?1, ?3)
+		ON CONFLICT (Id) DO
+			UPDATE SET instance_id = excluded.instance_id, expires_on = excluded.expires_on
+			WHERE poll_lease.instance_id = ?1 OR poll_lease.expires_on <= ?2`
+
+func (p *SqliteDB) AcquirePollLease(ctx context.Context, instanceId string, now, expiresOn time.Time) (held bool, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqliteAcquirePollLease, instanceId, sqliteTime(now), sqliteTime(expiresOn))
+	if err != nil {
+		return
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	held = rowsAffected == 1
+	return
+}
+
+const sqliteReleasePollLease = `DELETE FROM poll_lease WHERE instance_id = ?1`
+
+func (p *SqliteDB) ReleasePollLease(ctx context.Context, instanceId string) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err = p.db.ExecContext(ctx, sqliteReleasePollLease, instanceId)
+	return
+}
+
+const sqliteUpsertScheduledJobRun = `INSERT INTO scheduled_job_run
+		(name, instance_id, started_on, finished_on, error, runs, failures)
+		VALUES (?1, ?2, ?3, ?4, NULLIF(?5, ''), 1, ?6)
+		ON CONFLICT (name) DO
+			UPDATE SET instance_id = excluded.instance_id, started_on = excluded.started_on,
+				finished_on = excluded.finished_on, error = excluded.error,
+				runs = scheduled_job_run.runs + 1, failures = scheduled_job_run.failures + excluded.failures`
+
+func (p *SqliteDB) UpsertScheduledJobRun(ctx context.Context, run *types.ScheduledJobRun) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	failures := 0
+	if run.Error != "" {
+		failures = 1
+	}
+	_, err = p.db.ExecContext(ctx, sqliteUpsertScheduledJobRun, run.Name, run.InstanceId, sqliteTime(run.StartedOn),
+		sqliteTime(run.FinishedOn), run.Error, failures)
+	return
+}
+
+func (p *SqliteDB) SelectScheduledJobRuns(ctx context.Context) (runs []types.ScheduledJobRun, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqlSelectScheduledJobRuns)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		run := types.ScheduledJobRun{}
+		err = rows.Scan(&run.Name, &run.InstanceId, &run.StartedOn, &run.FinishedOn, &run.Error, &run.Runs, &run.Failures)
+		if err != nil {
+			return
+		}
+		runs = append(runs, run)
+	}
+	err = rows.Err()
+	return
+}
+
+func (p *SqliteDB) SelectMaintenance(ctx context.Context) (state types.MaintenanceState, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var since time.Time
+	err = p.db.QueryRowContext(ctx, sqlSelectMaintenance).Scan(&state.Message, &since)
+	if err == sql.ErrNoRows {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	state.Enabled, state.Since = true, &since
+	return
+}
+
+// SQLite has no RETURNING, so the time maintenance was turned on is read after the upsert
+const sqliteUpsertMaintenance = `INSERT INTO maintenance
+		(Id, message, since)
+		VALUES (TRUE, ?1, ?2)
+		ON CONFLICT (Id) DO
+			UPDATE SET message = excluded.message`
+
+func (p *SqliteDB) UpdateMaintenance(ctx context.Context, enabled bool, message string, now time.Time) (state types.MaintenanceState, err error) {
+	if !enabled {
+		ctx, cancel := p.withTimeout(ctx)
+		defer cancel()
+
+		_, err = p.db.ExecContext(ctx, sqlDeleteMaintenance)
+		return
+	}
+
+	err = func() error {
+		ctx, cancel := p.withTimeout(ctx)
+		defer cancel()
+
+		_, err := p.db.ExecContext(ctx, sqliteUpsertMaintenance, message, sqliteTime(now))
+		return err
+	}()
+	if err != nil {
+		return
+	}
+	return p.SelectMaintenance(ctx)
+}
+
+const sqliteInsertJob = `INSERT INTO job
+		(Id, kind, status, instance_id, done, total, created_on)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)`
+
+func (p *SqliteDB) InsertJob(ctx context.Context, job *types.Job) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	id := newId()
+	_, err = p.db.ExecContext(ctx, sqliteInsertJob, id, job.Kind, job.Status, job.InstanceId, job.Done, job.Total,
+		sqliteTime(job.CreatedOn))
+	if err != nil {
+		return
+	}
+	job.ID = id
+	return
+}
+
+const sqliteUpdateJobProgress = `UPDATE job SET done = ?2, total = ?3 WHERE Id = ?1`
+
+func (p *SqliteDB) UpdateJobProgress(ctx context.Context, jobId string, done, total int64) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err = p.db.ExecContext(ctx, sqliteUpdateJobProgress, jobId, done, total)
+	return
+}
+
+const sqliteFinishJob = `UPDATE job
+		SET status = ?2, done = ?3, total = ?4, result = ?5, error = NULLIF(?6, ''), finished_on = ?7
+		WHERE Id = ?1`
+
+func (p *SqliteDB) FinishJob(ctx context.Context, job *types.Job) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var result interface{}
+	if job.Result != nil {
+		result = string(job.Result)
+	}
+	_, err = p.db.ExecContext(ctx, sqliteFinishJob, job.ID, job.Status, job.Done, job.Total, result, job.Error,
+		sqliteNullTime(job.FinishedOn))
+	return
+}
+
+const sqliteSelectJob = `SELECT Id, kind, status, instance_id, done, total, result, COALESCE(error, ''), created_on,
+			finished_on
+		FROM job
+		WHERE Id = ?1`
+
+func (p *SqliteDB) SelectJob(ctx context.Context, jobId string) (job *types.Job, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	selected := &types.Job{}
+	var result sql.NullString
+	err = p.db.QueryRowContext(ctx, sqliteSelectJob, jobId).Scan(&selected.ID, &selected.Kind, &selected.Status,
+		&selected.InstanceId, &selected.Done, &selected.Total, &result, &selected.Error, &selected.CreatedOn,
+		&selected.FinishedOn)
+	if err != nil {
+		return
+	}
+	if result.Valid {
+		selected.Result = json.RawMessage(result.String)
+	}
+	job = selected
+	return
+}
+
+const sqliteFailInstanceJobs = `UPDATE job
+		SET status = 'failed', error = ?2, finished_on = ?3
+		WHERE instance_id = ?1
+		  AND status = 'running'`
+
+func (p *SqliteDB) FailInstanceJobs(ctx context.Context, instanceId, message string, now time.Time) (failed int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqliteFailInstanceJobs, instanceId, message, sqliteTime(now))
+	if err != nil {
+		return
+	}
+	return res.RowsAffected()
+}
+
+const sqliteFailOrphanedJobs = `UPDATE job
+		SET status = 'failed', error = ?2, finished_on = ?3
+		WHERE status = 'running'
+		  AND created_on < ?1
+		  AND NOT EXISTS (SELECT 1
+			FROM cluster_instance
+			WHERE cluster_instance.instance_id = job.instance_id
+			  AND cluster_instance.last_heartbeat >= ?1)`
+
+func (p *SqliteDB) FailOrphanedJobs(ctx context.Context, heartbeatBefore time.Time, message string, now time.Time) (failed int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqliteFailOrphanedJobs, sqliteTime(heartbeatBefore), message, sqliteTime(now))
+	if err != nil {
+		return
+	}
+	return res.RowsAffected()
+}
+
+const sqliteInsertNotification = `INSERT INTO notification
+		(Id, fk_participant, kind, message, created_on)
+		VALUES (?1, ?2, ?3, ?4, ?5)`
+
+func (p *SqliteDB) InsertNotification(ctx context.Context, notification *types.NotificationStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	id := newId()
+	_, err = p.db.ExecContext(ctx, sqliteInsertNotification, id, notification.ParticipantId, notification.Kind,
+		notification.Message, sqliteTime(notification.CreatedOn))
+	if err != nil {
+		p.logger.Error("error inserting notification", zap.Any("notification", notification), zap.Error(err))
+		return
+	}
+	notification.Id = id
+	return
+}
+
+const sqliteSelectNotifications = `SELECT Id, fk_participant, kind, message, created_on, read_on
+		FROM notification
+		WHERE fk_participant = ` + sqliteSelectParticipantIdByName + `
+		  AND (NOT ?4 OR read_on IS NULL)
+		ORDER BY created_on DESC`
+
+func (p *SqliteDB) SelectNotifications(ctx context.Context, campaignName, scpName, loginName string, unreadOnly bool) (notifications []types.NotificationStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, sqliteSelectNotifications, campaignName, scpName, loginName, unreadOnly)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		notification := types.NotificationStruct{}
+		err = rows.Scan(&notification.Id, &notification.ParticipantId, &notification.Kind, &notification.Message,
+			&notification.CreatedOn, &notification.ReadOn)
+		if err != nil {
+			return
+		}
+		notifications = append(notifications, notification)
+	}
+	return
+}
+
+const sqliteUpdateNotificationsRead = `UPDATE notification
+		SET read_on = ?4
+		WHERE fk_participant = ` + sqliteSelectParticipantIdByName + `
+		  AND read_on IS NULL`
+
+const sqliteUpdateNotificationRead = sqliteUpdateNotificationsRead + `
+		  AND Id = ?5`
+
+func (p *SqliteDB) UpdateNotificationsRead(ctx context.Context, campaignName, scpName, loginName, notificationId string, now time.Time) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var res sql.Result
+	if notificationId == "" {
+		res, err = p.db.ExecContext(ctx, sqliteUpdateNotificationsRead, campaignName, scpName, loginName, sqliteTime(now))
+	} else {
+		res, err = p.db.ExecContext(ctx, sqliteUpdateNotificationRead, campaignName, scpName, loginName, sqliteTime(now), notificationId)
+	}
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqliteSelectReportStats = `SELECT
+		(SELECT COUNT(*) FROM participant WHERE fk_campaign = campaign.Id),
+		(SELECT COUNT(*) FROM team WHERE fk_campaign = campaign.Id),
+		(SELECT COUNT(*) FROM scoring_event WHERE fk_campaign = campaign.Id AND voided_on IS NULL),
+		(SELECT COALESCE(SUM(points), 0) FROM scoring_event WHERE fk_campaign = campaign.Id AND voided_on IS NULL),
+		(SELECT COALESCE(SUM(team_score_event.points), 0) FROM team_score_event
+			INNER JOIN team ON team_score_event.fk_team = team.Id
+			WHERE team.fk_campaign = campaign.Id)
+		FROM campaign
+		WHERE campaign.name = ?1`
+
+const sqliteSelectReportTopParticipants = `SELECT
+		participant.Id, campaign.name, source_control_provider.name, login_name, DisplayName, Score, team.name, JoinedAt
+		FROM participant
+		LEFT JOIN team ON participant.fk_team = team.Id
+		INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+		INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+		WHERE campaign.name = ?1
+		ORDER BY Score DESC, login_name
+		LIMIT ?2`
+
+const sqliteSelectReportTopTeams = `SELECT name, member_points, award_points
+		FROM (SELECT team.name,
+				COALESCE((SELECT SUM(Score) FROM participant WHERE fk_team = team.Id), 0) AS member_points,
+				COALESCE((SELECT SUM(points) FROM team_score_event WHERE fk_team = team.Id), 0) AS award_points
+			FROM team
+			INNER JOIN campaign ON team.fk_campaign = campaign.Id
+			WHERE campaign.name = ?1) standings
+		ORDER BY member_points + award_points DESC, name
+		LIMIT ?2`
+
+const sqliteSelectReportCategories = `SELECT scoring_event_category.category, SUM(scoring_event_category.count),
+			SUM(scoring_event_category.points)
+		FROM scoring_event_category
+		INNER JOIN scoring_event ON scoring_event_category.fk_scoring_event = scoring_event.Id
+		WHERE scoring_event.fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+		  AND scoring_event.voided_on IS NULL
+		GROUP BY 1
+		ORDER BY 3 DESC, 1`
+
+const sqliteSelectReportRepositories = `SELECT repoOwner, repoName, COUNT(*), SUM(points)
+		FROM scoring_event
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+		  AND voided_on IS NULL
+		GROUP BY repoOwner, repoName
+		ORDER BY 4 DESC, 1, 2`
+
+const sqliteSelectReportTimeline = `SELECT day, SUM(pull_requests), SUM(points), SUM(joined)
+		FROM (SELECT strftime('%Y-%m-%d 00:00:00', scored_on) AS day, 1 AS pull_requests, points, 0 AS joined
+				FROM scoring_event
+				WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+				  AND scored_on IS NOT NULL
+				  AND voided_on IS NULL
+			UNION ALL
+			SELECT strftime('%Y-%m-%d 00:00:00', JoinedAt), 0, 0, 1
+				FROM participant
+				WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)) timeline
+		GROUP BY day
+		ORDER BY day`
+
+func (p *SqliteDB) SelectCampaignReport(ctx context.Context, campaignName string, topCount int, now time.Time) (report *types.CampaignReport, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	report = &types.CampaignReport{GeneratedOn: now}
+
+	err = scanCampaign(p.db.QueryRowContext(ctx, sqliteSelectCampaign, campaignName), &report.Campaign)
+	if err != nil {
+		return
+	}
+
+	stats := &report.Stats
+	err = p.db.QueryRowContext(ctx, sqliteSelectReportStats, campaignName).
+		Scan(&stats.Participants, &stats.Teams, &stats.PullRequests, &stats.Points, &stats.JudgedAwardPoints)
+	if err != nil {
+		return
+	}
+
+	err = p.queryRows(ctx, func(rows *sql.Rows) (err error) {
+		participant := types.ParticipantStruct{}
+		var nullableTeamName sql.NullString
+		err = rows.Scan(&participant.ID, &participant.CampaignName, &participant.ScpName, &participant.LoginName,
+			&participant.DisplayName, &participant.Score, &nullableTeamName, &participant.JoinedAt)
+		participant.TeamName = nullableTeamName.String
+		report.TopParticipants = append(report.TopParticipants, participant)
+		return
+	}, sqliteSelectReportTopParticipants, campaignName, topCount)
+	if err != nil {
+		return
+	}
+
+	err = p.queryRows(ctx, func(rows *sql.Rows) (err error) {
+		team := types.TeamStanding{}
+		err = rows.Scan(&team.TeamName, &team.MemberPoints, &team.AwardPoints)
+		team.Points = team.MemberPoints + team.AwardPoints
+		report.TopTeams = append(report.TopTeams, team)
+		return
+	}, sqliteSelectReportTopTeams, campaignName, topCount)
+	if err != nil {
+		return
+	}
+
+	err = p.queryRows(ctx, func(rows *sql.Rows) (err error) {
+		category := types.ReportCategory{}
+		err = rows.Scan(&category.Category, &category.Count, &category.Points)
+		report.Categories = append(report.Categories, category)
+		return
+	}, sqliteSelectReportCategories, campaignName)
+	if err != nil {
+		return
+	}
+
+	err = p.queryRows(ctx, func(rows *sql.Rows) (err error) {
+		repository := types.RepositoryImpact{}
+		err = rows.Scan(&repository.RepoOwner, &repository.RepoName, &repository.PullRequests, &repository.Points)
+		report.Repositories = append(report.Repositories, repository)
+		return
+	}, sqliteSelectReportRepositories, campaignName)
+	if err != nil {
+		return
+	}
+
+	err = p.queryRows(ctx, func(rows *sql.Rows) (err error) {
+		day := types.TimelineDay{}
+		var dayText string
+		if err = rows.Scan(&dayText, &day.PullRequests, &day.Points, &day.ParticipantsJoined); err != nil {
+			return
+		}
+		day.Day, err = time.Parse(sqliteTruncatedLayout, dayText)
+		report.Timeline = append(report.Timeline, day)
+		return
+	}, sqliteSelectReportTimeline, campaignName)
+	return
+}
+
+// queryRows calls scan for each row of the query, and closes the rows before returning, as the one connection of the
+// database is held until they are closed.
+func (p *SqliteDB) queryRows(ctx context.Context, scan func(rows *sql.Rows) error, query string, args ...interface{}) (err error) {
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err = scan(rows); err != nil {
+			return
+		}
+	}
+	err = rows.Err()
+	return
+}
+
+// sqliteTruncations start the interval of a time series holding scored_on, for each date_trunc field postgres takes.
+// A week starts on Monday, as it does for date_trunc.
+var sqliteTruncations = map[string]string{
+	"hour": `strftime('%Y-%m-%d %H:00:00', scoring_event.scored_on)`,
+	"day":  `strftime('%Y-%m-%d 00:00:00', scoring_event.scored_on)`,
+	"week": `strftime('%Y-%m-%d 00:00:00', scoring_event.scored_on, 'weekday 0', '-6 days')`,
+}
+
+const sqliteSelectScoreTimeSeries = `SELECT %s, COALESCE(CASE WHEN ?2 THEN team.name END, ''),
+			SUM(scoring_event.points)
+		FROM scoring_event
+		LEFT JOIN participant ON participant.fk_campaign = scoring_event.fk_campaign
+			AND participant.fk_scp = scoring_event.fk_scp
+			AND participant.login_name = scoring_event.username
+		LEFT JOIN team ON participant.fk_team = team.Id
+		WHERE scoring_event.fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)
+		  AND scoring_event.scored_on IS NOT NULL
+		  AND scoring_event.voided_on IS NULL
+		GROUP BY 1, 2
+		ORDER BY 1, 2`
+
+// SelectScoreTimeSeries sums the points scored in each interval of the campaign, an interval being one of the
+// sqliteTruncations.
+func (p *SqliteDB) SelectScoreTimeSeries(ctx context.Context, campaignName, interval string, byTeam bool) (points []types.TimeSeriesPoint, err error) {
+	truncation, ok := sqliteTruncations[interval]
+	if !ok {
+		err = fmt.Errorf("invalid interval: %s", interval)
+		return
+	}
+
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.queryRows(ctx, func(rows *sql.Rows) (err error) {
+		point := types.TimeSeriesPoint{}
+		var start string
+		if err = rows.Scan(&start, &point.TeamName, &point.Points); err != nil {
+			return
+		}
+		point.Start, err = time.Parse(sqliteTruncatedLayout, start)
+		points = append(points, point)
+		return
+	}, fmt.Sprintf(sqliteSelectScoreTimeSeries, truncation), campaignName, byTeam)
+	return
+}
+
+const sqliteUpsertCampaignReport = `INSERT INTO campaign_report
+		(fk_campaign, report, html, created_on)
+		VALUES ((SELECT Id FROM campaign WHERE name = ?1), ?2, ?3, ?4)
+		ON CONFLICT (fk_campaign) DO
+			UPDATE SET report = excluded.report, html = excluded.html, created_on = excluded.created_on`
+
+func (p *SqliteDB) UpsertCampaignReport(ctx context.Context, report *types.CampaignReport, html string) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	reportJson, err := sqliteJson(report)
+	if err != nil {
+		return
+	}
+	_, err = p.db.ExecContext(ctx, sqliteUpsertCampaignReport, report.Campaign.Name, reportJson, html, sqliteTime(report.GeneratedOn))
+	return
+}
+
+const sqliteSelectStoredCampaignReport = `SELECT report, html
+		FROM campaign_report
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = ?1)`
+
+func (p *SqliteDB) SelectStoredCampaignReport(ctx context.Context, campaignName string) (reportJson []byte, html string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.db.QueryRowContext(ctx, sqliteSelectStoredCampaignReport, campaignName).Scan(&reportJson, &html)
+	return
+}
+
+const sqliteInsertTelemetryEvent = `INSERT INTO telemetry_events
+		(Id, feature, call, created_on)
+		VALUES (?1, ?2, ?3, ?4)`
+
+func (p *SqliteDB) InsertTelemetryEvent(ctx context.Context, feature, call string, now time.Time) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err = p.db.ExecContext(ctx, sqliteInsertTelemetryEvent, newId(), feature, call, sqliteTime(now))
+	return
+}
+
+const sqliteSelectTelemetryUsage = `SELECT feature, COUNT(*)
+		FROM telemetry_events
+		WHERE created_on >= ?1
+		  AND created_on < ?2
+		GROUP BY feature
+		ORDER BY 2 DESC, 1`
+
+func (p *SqliteDB) SelectTelemetryUsage(ctx context.Context, from, to time.Time) (usage []types.TelemetryUsage, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.queryRows(ctx, func(rows *sql.Rows) (err error) {
+		featureUsage := types.TelemetryUsage{}
+		err = rows.Scan(&featureUsage.Feature, &featureUsage.Calls)
+		usage = append(usage, featureUsage)
+		return
+	}, sqliteSelectTelemetryUsage, sqliteTime(from), sqliteTime(to))
+	return
+}
+
+const sqliteInsertAuditLog = `INSERT INTO audit_log
+		(Id, actor, method, path, route, status, payload, created_on)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8)`
+
+func (p *SqliteDB) InsertAuditLog(ctx context.Context, entry *types.AuditLogEntry) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	id := newId()
+	_, err = p.db.ExecContext(ctx, sqliteInsertAuditLog, id, entry.Actor, entry.Method, entry.Path, entry.Route,
+		entry.Status, entry.Payload, sqliteTime(entry.CreatedOn))
+	if err == nil {
+		entry.ID = id
+	}
+	return
+}
+
+const sqliteSelectAuditLog = `SELECT Id, actor, method, path, route, status, payload, created_on
+		FROM audit_log
+		WHERE ?1 = '' OR actor = ?1
+		ORDER BY created_on DESC
+		LIMIT ?2`
+
+func (p *SqliteDB) SelectAuditLog(ctx context.Context, actor string, limit int) (entries []types.AuditLogEntry, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.queryRows(ctx, func(rows *sql.Rows) (err error) {
+		entry := types.AuditLogEntry{}
+		err = rows.Scan(&entry.ID, &entry.Actor, &entry.Method, &entry.Path, &entry.Route, &entry.Status, &entry.Payload, &entry.CreatedOn)
+		entries = append(entries, entry)
+		return
+	}, sqliteSelectAuditLog, actor, limit)
+	return
+}
+
+// sqliteBackupRow reads the row as a JSON object of its columns, as SQLite has no row_to_json. Text columns are read
+// as strings, rather than the base64 of their bytes.
+func sqliteBackupRow(rows *sql.Rows, columns []string) (row []byte, err error) {
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err = rows.Scan(pointers...); err != nil {
+		return
+	}
+	object := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		if text, ok := values[i].([]byte); ok {
+			object[column] = string(text)
+		} else {
+			object[column] = values[i]
+		}
+	}
+	return json.Marshal(object)
+}
+
+func (p *SqliteDB) Backup(ctx context.Context, open func(table string) (io.Writer, error)) (rowCounts map[string]int64, err error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	rowCounts = map[string]int64{}
+	for _, table := range backupTables {
+		var w io.Writer
+		if w, err = open(table); err != nil {
+			return
+		}
+		var rows *sql.Rows
+		if rows, err = tx.QueryContext(ctx, `SELECT * FROM `+table); err != nil {
+			return
+		}
+		var columns []string
+		if columns, err = rows.Columns(); err != nil {
+			_ = rows.Close()
+			return
+		}
+		for rows.Next() {
+			var row []byte
+			if row, err = sqliteBackupRow(rows, columns); err != nil {
+				_ = rows.Close()
+				return
+			}
+			if _, err = w.Write(append(row, '\n')); err != nil {
+				_ = rows.Close()
+				return
+			}
+			rowCounts[table]++
+		}
+		if err = rows.Close(); err != nil {
+			return
+		}
+		if err = rows.Err(); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,31 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build !sqlite
+// +build !sqlite
+
+package db
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+)
+
+// NewSqlite fails unless the binary is built with the sqlite build tag, as the SQLite driver needs cgo.
+func NewSqlite(path string, logger *zap.Logger) (bbashDB IBBashDB, err error) {
+	err = fmt.Errorf("sqlite support is not built in, rebuild with: go build -tags sqlite")
+	return
+}
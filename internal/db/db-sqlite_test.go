@@ -0,0 +1,1294 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build sqlite
+// +build sqlite
+
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"io"
+	"testing"
+	"time"
+)
+
+func setupSqliteDB(t *testing.T) (db IBBashDB, closeDbFunc func()) {
+	db, err := NewSqlite(":memory:", zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, db.MigrateDB())
+	closeDbFunc = func() {
+		_ = db.GetDb().Close()
+	}
+	return
+}
+
+// setupSqliteCampaign adds an active campaign with a GitHub organization and participant.
+func setupSqliteCampaign(t *testing.T, db IBBashDB, now time.Time) (participant *types.ParticipantStruct) {
+	ctx := context.Background()
+	_, err := db.InsertCampaign(ctx, &types.CampaignStruct{
+		Name:    campaignName,
+		StartOn: now.Add(-time.Hour),
+		EndOn:   now.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	_, err = db.InsertOrganization(ctx, &types.OrganizationStruct{SCPName: "GitHub", Organization: "myOrg", CampaignName: campaignName})
+	require.NoError(t, err)
+
+	participant = &types.ParticipantStruct{CampaignName: campaignName, ScpName: "GitHub", LoginName: loginName}
+	require.NoError(t, db.InsertParticipant(ctx, participant))
+	return
+}
+
+func TestSqliteMigrateDB(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+
+	status, err := db.SelectMigrationStatus(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, types.MigrationStatus{Version: 16}, status)
+
+	// migrating again changes nothing
+	assert.NoError(t, db.MigrateDB())
+
+	scps, err := db.GetSourceControlProviders(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(scps))
+
+	// the campaigns are kept when their version is rolled back
+	now := time.Now()
+	setupSqliteCampaign(t, db, now)
+	assert.NoError(t, db.RollbackMigrations(15))
+	status, err = db.SelectMigrationStatus(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, types.MigrationStatus{Version: 1}, status)
+	var campaigns, participants int
+	assert.NoError(t, db.GetDb().QueryRow(`SELECT COUNT(*) FROM campaign`).Scan(&campaigns))
+	assert.Equal(t, 1, campaigns)
+	assert.NoError(t, db.GetDb().QueryRow(`SELECT COUNT(*) FROM participant`).Scan(&participants))
+	assert.Equal(t, 1, participants)
+	var foreignKeys bool
+	assert.NoError(t, db.GetDb().QueryRow(`PRAGMA foreign_keys`).Scan(&foreignKeys))
+	assert.True(t, foreignKeys)
+
+	// and migrated again
+	assert.NoError(t, db.MigrateDB())
+	assert.NoError(t, db.RollbackMigrations(16))
+	_, err = db.GetSourceControlProviders(context.Background())
+	assert.EqualError(t, err, "no such table: source_control_provider")
+}
+
+func TestSqliteCampaign(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+
+	ctx := context.Background()
+	startOn := time.Date(2022, 3, 1, 9, 30, 0, 123456789, time.UTC)
+	opensOn := startOn.Add(-24 * time.Hour)
+	guid, err := db.InsertCampaign(ctx, &types.CampaignStruct{
+		Name:                campaignName,
+		StartOn:             startOn,
+		EndOn:               startOn.Add(24 * time.Hour),
+		RegistrationOpensOn: &opensOn,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 36, len(guid))
+
+	_, err = db.InsertCampaign(ctx, &types.CampaignStruct{Name: campaignName, StartOn: startOn, EndOn: startOn})
+	assert.Equal(t, &DuplicateError{Entity: "campaign"}, err)
+
+	campaign, err := db.GetCampaign(ctx, campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, guid, campaign.ID)
+	assert.Equal(t, startOn, campaign.StartOn)
+	assert.Equal(t, opensOn, *campaign.RegistrationOpensOn)
+	assert.Nil(t, campaign.RegistrationClosesOn)
+	assert.Equal(t, 1, campaign.CreatedOrder)
+	assert.Equal(t, "reachedScore", campaign.TieBreaker)
+	assert.Equal(t, "UTC", campaign.TimeZone)
+	assert.False(t, *campaign.FreezeScoring)
+
+	active, err := db.GetActiveCampaigns(ctx, startOn.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(active))
+	upcoming, err := db.GetUpcomingCampaigns(ctx, startOn.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(upcoming))
+
+	maxTeamSize := 3
+	campaign.MaxTeamSize = &maxTeamSize
+	freeze := true
+	campaign.FreezeScoring = &freeze
+	updatedGuid, err := db.UpdateCampaign(ctx, campaign)
+	assert.NoError(t, err)
+	assert.Equal(t, guid, updatedGuid)
+	assert.Equal(t, 2, campaign.Version)
+
+	// an update made from the version before is refused
+	campaign.Version = 1
+	_, err = db.UpdateCampaign(ctx, campaign)
+	assert.Equal(t, &StaleError{Entity: "campaign", Version: 2}, err)
+
+	// an update without maxTeamSize, freezeScoring or a side of the registration window leaves it as it was
+	closesOn := startOn.Add(time.Hour)
+	campaign.Version = 2
+	campaign.MaxTeamSize = nil
+	campaign.FreezeScoring = nil
+	campaign.RegistrationOpensOn = nil
+	campaign.RegistrationClosesOn = &closesOn
+	_, err = db.UpdateCampaign(ctx, campaign)
+	assert.NoError(t, err)
+	updated, err := db.GetCampaign(ctx, campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, *updated.MaxTeamSize)
+	assert.True(t, *updated.FreezeScoring)
+	assert.Equal(t, opensOn, *updated.RegistrationOpensOn)
+	assert.Equal(t, closesOn, *updated.RegistrationClosesOn)
+	assert.Equal(t, 3, updated.Version)
+
+	_, err = db.UpdateCampaign(ctx, &types.CampaignStruct{Name: "noSuchCampaign"})
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	version, err := db.SelectCampaignsVersion(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, version.Count)
+	assert.True(t, version.UpdatedOn.After(time.Now().Add(-time.Minute)))
+}
+
+func TestSqliteBugsInCampaign(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx, now := context.Background(), time.Now()
+	for _, name := range []string{campaignName, "otherCampaign"} {
+		_, err := db.InsertCampaign(ctx, &types.CampaignStruct{Name: name, StartOn: now, EndOn: now.Add(time.Hour)})
+		assert.NoError(t, err)
+	}
+	for _, bug := range []*types.BugStruct{
+		{Campaign: campaignName, Category: "xss", PointValue: 2},
+		{Campaign: "otherCampaign", Category: "csrf", PointValue: 3},
+		{Campaign: campaignName, Category: "sql-injection", PointValue: 5},
+	} {
+		assert.NoError(t, db.InsertBug(ctx, bug))
+	}
+
+	bugs, err := db.SelectBugsInCampaign(ctx, campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(bugs))
+	assert.Equal(t, "sql-injection", bugs[0].Category)
+	assert.Equal(t, 5.0, bugs[0].PointValue)
+	assert.Equal(t, "xss", bugs[1].Category)
+
+	bugs, err = db.SelectBugsInCampaign(ctx, "noSuchCampaign")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(bugs))
+}
+
+func TestSqliteInsertScoringEventsScoreDeltas(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+
+	ctx, now := context.Background(), time.Now()
+	participant := setupSqliteCampaign(t, db, now)
+	msg := &types.ScoringMessage{EventSource: "GitHub", RepoOwner: "myOrg", RepoName: "myRepo", TriggerUser: loginName, PullRequest: 1}
+
+	assert.NoError(t, db.InsertScoringEvents(ctx, []ScoringEventRow{{Participant: *participant, Msg: *msg, NewPoints: 3}},
+		[]ParticipantScoreDelta{{ParticipantId: participant.ID, Delta: 3}}))
+	assert.Equal(t, float64(3), db.SelectPriorScore(ctx, participant, msg))
+	require.NoError(t, db.UpdateParticipantScore(ctx, participant, 0))
+	assert.Equal(t, float64(3), participant.Score)
+}
+
+func TestSqliteScoring(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+
+	ctx, now := context.Background(), time.Now()
+	participant := setupSqliteCampaign(t, db, now)
+	msg := &types.ScoringMessage{EventSource: "GitHub", RepoOwner: "myOrg", RepoName: "myRepo", TriggerUser: loginName, PullRequest: 5}
+
+	valid, err := db.ValidOrganization(ctx, msg, now)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	toScore, err := db.SelectParticipantsToScore(ctx, msg, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(toScore))
+	assert.Equal(t, participant.ID, toScore[0].ID)
+
+	breakdown := &types.ScoreBreakdown{
+		Categories: []types.CategoryScore{{Category: "sql-injection", Count: 2, PointValue: 3, Points: 6}},
+		Classified: 2,
+		// the bonus of the unclassified bug is not a number of bugs fixed
+		Unclassified:      1,
+		UnclassifiedBonus: 4,
+		Points:            6,
+	}
+	assert.NoError(t, db.InsertScoringEvent(ctx, participant, msg, 6, breakdown))
+	assert.NoError(t, db.UpdateParticipantScore(ctx, participant, 6))
+	assert.Equal(t, float64(6), participant.Score)
+	assert.Equal(t, float64(6), db.SelectPriorScore(ctx, participant, msg))
+
+	bugsFixed, err := db.SelectBugsFixed(ctx, participant)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(3), bugsFixed)
+
+	event, err := db.SelectScoringEvent(ctx, campaignName, "GitHub", "myOrg", "myRepo", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, breakdown, event.Breakdown)
+	assert.Nil(t, event.VoidedOn)
+
+	voided, teamName, err := db.VoidScoringEvent(ctx, event.ID, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "", teamName)
+	assert.NotNil(t, voided.VoidedOn)
+
+	_, _, err = db.VoidScoringEvent(ctx, event.ID, now)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	detail, err := db.SelectParticipantDetail(ctx, campaignName, "GitHub", loginName)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(0), detail.Score)
+}
+
+func TestSqliteExportScoringEvents(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx, now := context.Background(), time.Now()
+	participant := setupSqliteCampaign(t, db, now)
+
+	breakdown := &types.ScoreBreakdown{Classified: 2, Points: 2}
+	for pullRequest := 1; pullRequest <= 3; pullRequest++ {
+		msg := &types.ScoringMessage{EventSource: "GitHub", RepoOwner: "myOrg", RepoName: "myRepo", TriggerUser: loginName, PullRequest: pullRequest}
+		require.NoError(t, db.InsertScoringEvent(ctx, participant, msg, 2, breakdown))
+	}
+
+	var exported []types.ScoringEventStruct
+	err := db.ExportScoringEvents(ctx, campaignName, func(event *types.ScoringEventStruct) error {
+		exported = append(exported, *event)
+		return nil
+	})
+	assert.NoError(t, err)
+	require.Equal(t, 3, len(exported))
+	for _, event := range exported {
+		assert.Equal(t, campaignName, event.CampaignName)
+		assert.Equal(t, "GitHub", event.ScpName)
+		assert.Equal(t, breakdown, event.Breakdown)
+		assert.NotNil(t, event.ScoredOn)
+		assert.Nil(t, event.VoidedOn)
+	}
+
+	forcedError := errors.New("forced write error")
+	calls := 0
+	err = db.ExportScoringEvents(ctx, campaignName, func(event *types.ScoringEventStruct) error {
+		calls++
+		return forcedError
+	})
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, 1, calls)
+}
+
+func TestSqliteImportScoringEvents(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx, now := context.Background(), time.Now()
+	participant := setupSqliteCampaign(t, db, now)
+
+	msg := &types.ScoringMessage{EventSource: "GitHub", RepoOwner: "myOrg", RepoName: "myRepo", TriggerUser: loginName, PullRequest: 1}
+	require.NoError(t, db.InsertScoringEvent(ctx, participant, msg, 2, nil))
+	// a score set by hand is replaced by the recomputed one
+	require.NoError(t, db.UpdateParticipantScore(ctx, participant, 100))
+
+	scoredOn := now.Add(-24 * time.Hour).UTC().Truncate(time.Second)
+	voidedOn := scoredOn.Add(time.Hour)
+	events := []types.ScoringEventStruct{
+		{CampaignName: campaignName, ScpName: "GitHub", RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 1, UserName: loginName, Points: 9},
+		{CampaignName: campaignName, ScpName: "GitHub", RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 2, UserName: loginName, Points: 3,
+			Breakdown: &types.ScoreBreakdown{Classified: 3, Points: 3}, ScoredOn: &scoredOn},
+		{CampaignName: campaignName, ScpName: "GitHub", RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 3, UserName: loginName, Points: 4,
+			ScoredOn: &scoredOn, VoidedOn: &voidedOn},
+		{CampaignName: campaignName, ScpName: "GitHub", RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 4, UserName: "notParticipating", Points: 5},
+	}
+	imported, recomputed, err := db.ImportScoringEvents(ctx, events)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), imported)
+	assert.Equal(t, int64(1), recomputed)
+
+	detail, err := db.SelectParticipantDetail(ctx, campaignName, "GitHub", loginName)
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, detail.Score)
+
+	event, err := db.SelectScoringEvent(ctx, campaignName, "GitHub", "myOrg", "myRepo", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, event.Points)
+	event, err = db.SelectScoringEvent(ctx, campaignName, "GitHub", "myOrg", "myRepo", 2)
+	require.NoError(t, err)
+	assert.Equal(t, events[1].Breakdown, event.Breakdown)
+	event, err = db.SelectScoringEvent(ctx, campaignName, "GitHub", "myOrg", "myRepo", 3)
+	require.NoError(t, err)
+	require.NotNil(t, event.VoidedOn)
+	assert.True(t, voidedOn.Equal(*event.VoidedOn))
+
+	var exportedOn []time.Time
+	require.NoError(t, db.ExportScoringEvents(ctx, campaignName, func(event *types.ScoringEventStruct) error {
+		exportedOn = append(exportedOn, *event.ScoredOn)
+		return nil
+	}))
+	require.Equal(t, 4, len(exportedOn))
+	// the imported events keep when they were scored, so are exported first
+	assert.True(t, scoredOn.Equal(exportedOn[0]))
+
+	imported, recomputed, err = db.ImportScoringEvents(ctx, events)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), imported)
+	assert.Equal(t, int64(0), recomputed)
+
+	_, _, err = db.ImportScoringEvents(ctx, []types.ScoringEventStruct{
+		{CampaignName: "noSuchCampaign", ScpName: "GitHub", RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 5, UserName: loginName, Points: 1},
+	})
+	assert.Error(t, err)
+}
+
+func TestSqliteArchiveScoringEvents(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx, now := context.Background(), time.Now()
+	participant := setupSqliteCampaign(t, db, now)
+	coAuthor := &types.ParticipantStruct{CampaignName: campaignName, ScpName: "GitHub", LoginName: "coAuthor"}
+	require.NoError(t, db.InsertParticipant(ctx, coAuthor))
+
+	msg := &types.ScoringMessage{EventSource: "GitHub", RepoOwner: "myOrg", RepoName: "myRepo", TriggerUser: loginName, PullRequest: 1}
+	require.NoError(t, db.InsertScoringEvent(ctx, participant, msg, 2, &types.ScoreBreakdown{Classified: 2, Points: 2}))
+	require.NoError(t, db.InsertCoAuthorScore(ctx, coAuthor, msg, 1))
+	require.NoError(t, db.UpdateParticipantScore(ctx, coAuthor, 1))
+	msg = &types.ScoringMessage{EventSource: "GitHub", RepoOwner: "myOrg", RepoName: "myRepo", TriggerUser: loginName, PullRequest: 2}
+	require.NoError(t, db.InsertScoringEvent(ctx, participant, msg, 3, nil))
+	require.NoError(t, db.UpdateParticipantScore(ctx, participant, 5))
+	flagged, err := db.SelectScoringEvent(ctx, campaignName, "GitHub", "myOrg", "myRepo", 2)
+	require.NoError(t, err)
+	_, err = db.InsertScoringFlags(ctx, []types.ScoringFlag{{Event: types.ScoringEventStruct{ID: flagged.ID}, Reason: "burst", FlaggedOn: now}})
+	require.NoError(t, err)
+
+	// a campaign with a flag waiting for review is not archived by the job
+	campaignNames, err := db.SelectCampaignsToArchive(ctx, now.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Nil(t, campaignNames)
+
+	archive, err := db.ArchiveScoringEvents(ctx, campaignName, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), archive.EventCount)
+	_, err = db.SelectScoringEvent(ctx, campaignName, "GitHub", "myOrg", "myRepo", 1)
+	assert.Equal(t, sql.ErrNoRows, err)
+	flags, err := db.SelectScoringFlags(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(flags))
+	// the scores are kept
+	detail, err := db.SelectParticipantDetail(ctx, campaignName, "GitHub", loginName)
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, detail.Score)
+	// an import would recompute the scores without the archived events, so is refused
+	_, _, err = db.ImportScoringEvents(ctx, []types.ScoringEventStruct{
+		{CampaignName: campaignName, ScpName: "GitHub", RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 3, UserName: loginName, Points: 1},
+	})
+	assert.Equal(t, ErrCampaignArchived, err)
+	detail, err = db.SelectParticipantDetail(ctx, campaignName, "GitHub", loginName)
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, detail.Score)
+
+	_, err = db.ArchiveScoringEvents(ctx, campaignName, now)
+	assert.Equal(t, sql.ErrNoRows, err)
+	_, err = db.ArchiveScoringEvents(ctx, "noCampaign", now)
+	assert.Equal(t, sql.ErrNoRows, err)
+	campaignNames, err = db.SelectCampaignsToArchive(ctx, now.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Nil(t, campaignNames)
+
+	archive, err = db.RestoreScoringEvents(ctx, campaignName, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), archive.EventCount)
+	require.NotNil(t, archive.RestoredOn)
+	event, err := db.SelectScoringEvent(ctx, campaignName, "GitHub", "myOrg", "myRepo", 1)
+	require.NoError(t, err)
+	assert.Equal(t, &types.ScoreBreakdown{Classified: 2, Points: 2}, event.Breakdown)
+	// the share of the co-author is restored with the event, so voiding it takes their point off
+	_, _, err = db.VoidScoringEvent(ctx, event.ID, now)
+	require.NoError(t, err)
+	detail, err = db.SelectParticipantDetail(ctx, campaignName, "GitHub", "coAuthor")
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, detail.Score)
+
+	_, err = db.RestoreScoringEvents(ctx, campaignName, now)
+	assert.Equal(t, sql.ErrNoRows, err)
+	archives, err := db.SelectScoringEventArchives(ctx)
+	assert.NoError(t, err)
+	require.Equal(t, 1, len(archives))
+	assert.Equal(t, campaignName, archives[0].CampaignName)
+	assert.NotNil(t, archives[0].RestoredOn)
+
+	// a restored campaign is archived again when asked
+	archive, err = db.ArchiveScoringEvents(ctx, campaignName, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), archive.EventCount)
+}
+
+func TestSqlitePatchParticipant(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx := context.Background()
+	participant := setupSqliteCampaign(t, db, time.Now())
+	participant.Email, participant.DisplayName = "me@example.com", "Me"
+	_, err := db.UpsertParticipant(ctx, participant)
+	require.NoError(t, err)
+	require.NoError(t, db.InsertTeam(ctx, &types.TeamStruct{CampaignName: campaignName, Name: teamName}))
+
+	patch := func(patchJson string) int64 {
+		var patch types.ParticipantPatch
+		require.NoError(t, json.Unmarshal([]byte(patchJson), &patch))
+		patch.ID = participant.ID
+		rowsAffected, err := db.PatchParticipant(ctx, &patch)
+		require.NoError(t, err)
+		return rowsAffected
+	}
+
+	// the fields left out are kept
+	assert.Equal(t, int64(1), patch(`{"displayName": "Someone", "score": 3, "teamName": "`+teamName+`", "version": 1}`))
+	detail, err := db.SelectParticipantDetail(ctx, campaignName, "GitHub", loginName)
+	require.NoError(t, err)
+	assert.Equal(t, "me@example.com", detail.Email)
+	assert.Equal(t, "Someone", detail.DisplayName)
+	assert.Equal(t, 3.0, detail.Score)
+	assert.Equal(t, teamName, detail.TeamName)
+
+	// the fields sent as null are cleared
+	assert.Equal(t, int64(1), patch(`{"email": null, "teamName": null, "version": 2}`))
+	detail, err = db.SelectParticipantDetail(ctx, campaignName, "GitHub", loginName)
+	require.NoError(t, err)
+	assert.Equal(t, "", detail.Email)
+	assert.Equal(t, "Someone", detail.DisplayName)
+	assert.Equal(t, 3.0, detail.Score)
+	assert.Equal(t, "", detail.TeamName)
+	assert.Equal(t, 3, detail.Version)
+
+	// a patch made from an earlier version is refused
+	rowsAffected, err := db.PatchParticipant(ctx, &types.ParticipantPatch{ID: participant.ID, DisplayName: types.OptionalString{Set: true, Value: "Late"}, Version: 2})
+	assert.Equal(t, &StaleError{Entity: "participant", Version: 3}, err)
+	assert.Equal(t, int64(0), rowsAffected)
+
+	rowsAffected, err = db.PatchParticipant(ctx, &types.ParticipantPatch{ID: "noSuchParticipant"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), rowsAffected)
+}
+
+func TestSqliteBans(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx := context.Background()
+	setupSqliteCampaign(t, db, time.Now())
+
+	ban := &types.BanStruct{ScpName: "github", LoginName: "Cheater", Reason: "scored forks"}
+	require.NoError(t, db.InsertBan(ctx, ban))
+	assert.NotEqual(t, "", ban.ID)
+	assert.Equal(t, "cheater", ban.LoginName)
+	assert.Equal(t, &DuplicateError{Entity: "ban"}, db.InsertBan(ctx, &types.BanStruct{ScpName: "GitHub", LoginName: "cheater"}))
+
+	bans, err := db.SelectBans(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(bans))
+	assert.Equal(t, "GitHub", bans[0].ScpName)
+	assert.Equal(t, "scored forks", bans[0].Reason)
+
+	banned, err := db.SelectBanned(ctx, "GitHub", "CHEATER")
+	assert.NoError(t, err)
+	assert.True(t, banned)
+	banned, err = db.SelectBanned(ctx, "GitHub", loginName)
+	assert.NoError(t, err)
+	assert.False(t, banned)
+
+	rowsAffected, err := db.DeleteBan(ctx, "GitHub", "Cheater")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+	banned, err = db.SelectBanned(ctx, "GitHub", "cheater")
+	assert.NoError(t, err)
+	assert.False(t, banned)
+	rowsAffected, err = db.DeleteBan(ctx, "GitHub", "cheater")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), rowsAffected)
+}
+
+func TestSqliteCampaignCaps(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx := context.Background()
+	participant := setupSqliteCampaign(t, db, time.Now())
+
+	_, err := db.SelectCampaignCaps(ctx, campaignName)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, sql.ErrNoRows, db.UpsertCampaignCaps(ctx, &types.CampaignCapsStruct{CampaignName: "noSuchCampaign", PerDay: 5}))
+	require.NoError(t, db.UpsertCampaignCaps(ctx, &types.CampaignCapsStruct{CampaignName: campaignName, PerPullRequest: 10, PerDay: 50}))
+	caps, err := db.SelectCampaignCaps(ctx, campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.CampaignCapsStruct{CampaignName: campaignName, PerPullRequest: 10, PerDay: 50}, caps)
+
+	msg := &types.ScoringMessage{RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 1, TriggerUser: loginName}
+	require.NoError(t, db.InsertScoringEvent(ctx, participant, msg, 7, nil))
+	events, err := db.SelectScoringEventsSince(ctx, participant, time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, []ScoringEventRow{{Participant: *participant, Msg: *msg, NewPoints: 7}}, events)
+	events, err = db.SelectScoringEventsSince(ctx, participant, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Nil(t, events)
+}
+
+func TestSqliteDuplicateFingerprints(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx, now := context.Background(), time.Now()
+	participant := setupSqliteCampaign(t, db, now)
+	other := &types.ParticipantStruct{CampaignName: campaignName, ScpName: participant.ScpName, LoginName: "otherLoginName"}
+
+	_, err := db.SelectDuplicatePolicy(ctx, campaignName)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, sql.ErrNoRows, db.UpsertDuplicatePolicy(ctx, &types.DuplicatePolicyStruct{CampaignName: "noSuchCampaign", Policy: types.DuplicatePolicySkip}))
+	require.NoError(t, db.UpsertDuplicatePolicy(ctx, &types.DuplicatePolicyStruct{CampaignName: campaignName, Policy: types.DuplicatePolicyDownweight, Weight: 0.5}))
+	policy, err := db.SelectDuplicatePolicy(ctx, campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.DuplicatePolicyStruct{CampaignName: campaignName, Policy: types.DuplicatePolicyDownweight, Weight: 0.5}, policy)
+
+	first := &types.ScoringMessage{RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 1, TriggerUser: loginName, Fingerprints: []string{"fp1", "fp2"}}
+	require.NoError(t, db.InsertScoringEvent(ctx, participant, first, 2, nil))
+
+	second := &types.ScoringMessage{RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 2, TriggerUser: other.LoginName, Fingerprints: []string{"fp2", "fp3"}}
+	duplicates, err := db.SelectDuplicateFingerprints(ctx, other, second)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"fp2"}, duplicates)
+	// the same fix in another repository is not a duplicate
+	elsewhere := &types.ScoringMessage{RepoOwner: "myOrg", RepoName: "otherRepo", PullRequest: 2, TriggerUser: other.LoginName, Fingerprints: []string{"fp2"}}
+	duplicates, err = db.SelectDuplicateFingerprints(ctx, other, elsewhere)
+	assert.NoError(t, err)
+	assert.Nil(t, duplicates)
+
+	require.NoError(t, db.InsertScoringEvent(ctx, other, second, 1, nil))
+	// scoring the first fix again does not turn it into a duplicate
+	duplicates, err = db.SelectDuplicateFingerprints(ctx, participant, first)
+	assert.NoError(t, err)
+	assert.Nil(t, duplicates)
+
+	// the fixes of a voided event no longer count
+	scored, err := db.SelectScoredEvents(ctx, now.Add(-time.Hour))
+	require.NoError(t, err)
+	for _, event := range scored {
+		if event.PullRequest == first.PullRequest {
+			_, _, err = db.VoidScoringEvent(ctx, event.ID, now)
+			require.NoError(t, err)
+		}
+	}
+	duplicates, err = db.SelectDuplicateFingerprints(ctx, other, second)
+	assert.NoError(t, err)
+	assert.Nil(t, duplicates)
+}
+
+func TestSqliteCoAuthors(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx, now := context.Background(), time.Now()
+	participant := setupSqliteCampaign(t, db, now)
+	pair := &types.ParticipantStruct{CampaignName: campaignName, ScpName: participant.ScpName, LoginName: "pairLoginName"}
+	require.NoError(t, db.InsertParticipant(ctx, pair))
+
+	_, err := db.SelectCoAuthorPolicy(ctx, campaignName)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, sql.ErrNoRows, db.UpsertCoAuthorPolicy(ctx, &types.CoAuthorPolicyStruct{CampaignName: "noSuchCampaign", Policy: types.CoAuthorPolicySplit}))
+	require.NoError(t, db.UpsertCoAuthorPolicy(ctx, &types.CoAuthorPolicyStruct{CampaignName: campaignName, Policy: types.CoAuthorPolicyDuplicate}))
+	policy, err := db.SelectCoAuthorPolicy(ctx, campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.CoAuthorPolicyStruct{CampaignName: campaignName, Policy: types.CoAuthorPolicyDuplicate}, policy)
+
+	msg := &types.ScoringMessage{RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 1, TriggerUser: loginName, CoAuthors: []string{pair.LoginName}}
+	assert.Equal(t, float64(0), db.SelectCoAuthorPriorScore(ctx, pair, msg))
+	require.NoError(t, db.InsertScoringEvent(ctx, participant, msg, 1.5, nil))
+	require.NoError(t, db.InsertCoAuthorScore(ctx, pair, msg, 1.5))
+	require.NoError(t, db.UpdateParticipantScore(ctx, pair, 1.5))
+	assert.Equal(t, 1.5, db.SelectCoAuthorPriorScore(ctx, pair, msg))
+	// scoring again replaces the share
+	require.NoError(t, db.InsertCoAuthorScore(ctx, pair, msg, 2))
+	require.NoError(t, db.UpdateParticipantScore(ctx, pair, 0.5))
+	assert.Equal(t, float64(2), db.SelectCoAuthorPriorScore(ctx, pair, msg))
+	coAuthors, err := db.SelectCoAuthorScores(ctx, participant, msg)
+	assert.NoError(t, err)
+	assert.Equal(t, []CoAuthorScoreRow{{Participant: types.ParticipantStruct{ID: pair.ID, CampaignName: campaignName,
+		ScpName: participant.ScpName, LoginName: pair.LoginName}, Points: 2}}, coAuthors)
+	// the share counts towards the caps of the co-author
+	scored, err := db.SelectScoringEventsSince(ctx, pair, now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(scored))
+	assert.Equal(t, float64(2), scored[0].NewPoints)
+
+	event, err := db.SelectScoringEvent(ctx, campaignName, participant.ScpName, "myOrg", "myRepo", 1)
+	require.NoError(t, err)
+	voided, _, err := db.VoidScoringEvent(ctx, event.ID, now)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.CoAuthorScore{{LoginName: pair.LoginName, Points: 2}}, voided.CoAuthors)
+	require.NoError(t, db.UpdateParticipantScore(ctx, pair, 0))
+	assert.Equal(t, float64(0), pair.Score)
+
+	// a scoring again after the void starts the co-author afresh
+	require.NoError(t, db.InsertScoringEvent(ctx, participant, msg, 1, nil))
+	assert.Equal(t, float64(0), db.SelectCoAuthorPriorScore(ctx, pair, msg))
+
+	// a co-author dropped from the pull request loses the share
+	require.NoError(t, db.InsertCoAuthorScore(ctx, pair, msg, 1))
+	require.NoError(t, db.DeleteCoAuthorScore(ctx, pair, msg))
+	assert.Equal(t, float64(0), db.SelectCoAuthorPriorScore(ctx, pair, msg))
+	coAuthors, err = db.SelectCoAuthorScores(ctx, participant, msg)
+	assert.NoError(t, err)
+	assert.Nil(t, coAuthors)
+}
+
+func TestSqliteParticipantScoreAt(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx, now := context.Background(), time.Now()
+	participant := setupSqliteCampaign(t, db, now)
+	pair := &types.ParticipantStruct{CampaignName: campaignName, ScpName: participant.ScpName, LoginName: "pairLoginName"}
+	require.NoError(t, db.InsertParticipant(ctx, pair))
+
+	first := &types.ScoringMessage{RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 1, TriggerUser: loginName, CoAuthors: []string{pair.LoginName}}
+	second := &types.ScoringMessage{RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 2, TriggerUser: loginName}
+	require.NoError(t, db.InsertScoringEvent(ctx, participant, first, 3, nil))
+	require.NoError(t, db.InsertCoAuthorScore(ctx, pair, first, 1.5))
+	time.Sleep(10 * time.Millisecond)
+	deadline := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	// scored again after the deadline, the pull request counts with the points it had by then
+	require.NoError(t, db.InsertScoringEvent(ctx, participant, first, 5, nil))
+	require.NoError(t, db.InsertCoAuthorScore(ctx, pair, first, 2.5))
+	require.NoError(t, db.InsertScoringEvent(ctx, participant, second, 2, nil))
+	event, err := db.SelectScoringEvent(ctx, campaignName, participant.ScpName, "myOrg", "myRepo", 2)
+	require.NoError(t, err)
+	_, _, err = db.VoidScoringEvent(ctx, event.ID, time.Now())
+	require.NoError(t, err)
+
+	score, err := db.SelectParticipantScoreAt(ctx, campaignName, participant.ScpName, loginName, deadline)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(3), score.Score)
+	assert.Equal(t, 1, score.ScoringEvents)
+	score, err = db.SelectParticipantScoreAt(ctx, campaignName, participant.ScpName, pair.LoginName, deadline)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, score.Score)
+	assert.Equal(t, 0, score.ScoringEvents)
+
+	// the voided event no longer counts
+	score, err = db.SelectParticipantScoreAt(ctx, campaignName, participant.ScpName, loginName, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, float64(5), score.Score)
+	assert.Equal(t, 1, score.ScoringEvents)
+	score, err = db.SelectParticipantScoreAt(ctx, campaignName, participant.ScpName, pair.LoginName, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 2.5, score.Score)
+
+	score, err = db.SelectParticipantScoreAt(ctx, campaignName, participant.ScpName, loginName, now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(0), score.Score)
+	assert.Equal(t, 0, score.ScoringEvents)
+
+	_, err = db.SelectParticipantScoreAt(ctx, campaignName, participant.ScpName, "noSuchLogin", deadline)
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestSqliteCampaignPrivacy(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx, now := context.Background(), time.Now()
+	setupSqliteCampaign(t, db, now)
+
+	_, err := db.SelectCampaignPrivacy(ctx, campaignName)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, sql.ErrNoRows, db.UpsertCampaignPrivacy(ctx, &types.CampaignPrivacyStruct{CampaignName: "noSuchCampaign", Anonymize: true}))
+	require.NoError(t, db.UpsertCampaignPrivacy(ctx, &types.CampaignPrivacyStruct{CampaignName: campaignName, Anonymize: true}))
+	privacy, err := db.SelectCampaignPrivacy(ctx, campaignName)
+	assert.NoError(t, err)
+	assert.True(t, privacy.Anonymize)
+	assert.NotEmpty(t, privacy.Salt)
+
+	// the salt is kept, so the pseudonyms are too
+	require.NoError(t, db.UpsertCampaignPrivacy(ctx, &types.CampaignPrivacyStruct{CampaignName: campaignName, Anonymize: false}))
+	changed, err := db.SelectCampaignPrivacy(ctx, campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.CampaignPrivacyStruct{CampaignName: campaignName, Anonymize: false, Salt: privacy.Salt}, changed)
+}
+
+func TestSqliteBugCategoryExclusions(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx := context.Background()
+	setupSqliteCampaign(t, db, time.Now())
+
+	assert.Equal(t, sql.ErrNoRows, db.InsertBugCategoryExclusion(ctx, &types.BugCategoryExclusion{CampaignName: "noSuchCampaign", Category: "noisy"}))
+
+	exclusion := &types.BugCategoryExclusion{CampaignName: campaignName, Category: "noisy"}
+	require.NoError(t, db.InsertBugCategoryExclusion(ctx, exclusion))
+	assert.NotEqual(t, "", exclusion.Id)
+	assert.Equal(t, &DuplicateError{Entity: "bug category exclusion"},
+		db.InsertBugCategoryExclusion(ctx, &types.BugCategoryExclusion{CampaignName: campaignName, Category: "noisy"}))
+
+	exclusions, err := db.SelectBugCategoryExclusions(ctx, campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.BugCategoryExclusion{*exclusion}, exclusions)
+
+	rowsAffected, err := db.DeleteBugCategoryExclusion(ctx, campaignName, "noisy")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+	rowsAffected, err = db.DeleteBugCategoryExclusion(ctx, campaignName, "noisy")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), rowsAffected)
+}
+
+func TestSqliteScoringFlags(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx, now := context.Background(), time.Now()
+	participant := setupSqliteCampaign(t, db, now)
+
+	msg := &types.ScoringMessage{EventSource: "GitHub", RepoOwner: "myOrg", RepoName: "myRepo", TriggerUser: loginName, PullRequest: 5}
+	breakdown := &types.ScoreBreakdown{Categories: []types.CategoryScore{{Category: "xss", Count: 1, PointValue: 1, Points: 1}}, Classified: 1, Points: 1}
+	require.NoError(t, db.InsertScoringEvent(ctx, participant, msg, 1, breakdown))
+
+	scored, err := db.SelectScoredEvents(ctx, now.Add(-time.Hour))
+	assert.NoError(t, err)
+	require.Equal(t, 1, len(scored))
+	assert.Equal(t, breakdown, scored[0].Breakdown)
+	assert.False(t, scored[0].ScoredOn.IsZero())
+	none, err := db.SelectScoredEvents(ctx, now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(none))
+
+	flags := []types.ScoringFlag{
+		{Reason: types.ScoringFlagBurst, FlaggedOn: now, Event: scored[0].ScoringEventStruct},
+		{Reason: types.ScoringFlagIdentical, FlaggedOn: now, Event: scored[0].ScoringEventStruct},
+	}
+	flagged, err := db.InsertScoringFlags(ctx, flags)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), flagged)
+	// an event is flagged once for each reason
+	flagged, err = db.InsertScoringFlags(ctx, flags[:1])
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), flagged)
+
+	queue, err := db.SelectScoringFlags(ctx)
+	assert.NoError(t, err)
+	require.Equal(t, 2, len(queue))
+	assert.Equal(t, scored[0].ScoringEventStruct, queue[0].Event)
+	assert.Nil(t, queue[0].ResolvedOn)
+
+	resolved, err := db.ResolveScoringFlag(ctx, queue[0].ID, types.ScoringFlagApproved, now)
+	assert.NoError(t, err)
+	assert.Equal(t, types.ScoringFlagApproved, resolved.Resolution)
+	assert.NotNil(t, resolved.ResolvedOn)
+	assert.Equal(t, queue[0].Event, resolved.Event)
+	_, err = db.ResolveScoringFlag(ctx, queue[0].ID, types.ScoringFlagVoided, now)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	queue, err = db.SelectScoringFlags(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(queue))
+
+	// the flags of a voided event leave the queue
+	_, _, err = db.VoidScoringEvent(ctx, scored[0].ID, now)
+	require.NoError(t, err)
+	queue, err = db.SelectScoringFlags(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(queue))
+}
+
+func TestSqliteRepositoryFilters(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx := context.Background()
+	setupSqliteCampaign(t, db, time.Now())
+
+	assert.Equal(t, sql.ErrNoRows, db.InsertRepositoryFilter(ctx, &types.RepositoryFilter{CampaignName: "noSuchCampaign", Kind: "deny", Pattern: "*-docs"}))
+
+	filter := &types.RepositoryFilter{CampaignName: campaignName, Kind: "deny", Pattern: "*-Docs"}
+	require.NoError(t, db.InsertRepositoryFilter(ctx, filter))
+	assert.NotEqual(t, "", filter.Id)
+	assert.Equal(t, "*-docs", filter.Pattern)
+	assert.Equal(t, &DuplicateError{Entity: "repository filter"},
+		db.InsertRepositoryFilter(ctx, &types.RepositoryFilter{CampaignName: campaignName, Kind: "deny", Pattern: "*-docs"}))
+	require.NoError(t, db.InsertRepositoryFilter(ctx, &types.RepositoryFilter{CampaignName: campaignName, Kind: "allow", Pattern: "widget*"}))
+
+	filters, err := db.SelectRepositoryFilters(ctx, campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(filters))
+	assert.Equal(t, "allow", filters[0].Kind)
+	assert.Equal(t, *filter, filters[1])
+
+	rowsAffected, err := db.DeleteRepositoryFilter(ctx, campaignName, filter.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+	rowsAffected, err = db.DeleteRepositoryFilter(ctx, campaignName, filter.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), rowsAffected)
+}
+
+func TestSqliteLeaderboard(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+
+	ctx, now := context.Background(), time.Now()
+	participant := setupSqliteCampaign(t, db, now)
+	assert.NoError(t, db.InsertTeam(ctx, &types.TeamStruct{CampaignName: campaignName, Name: teamName}))
+	assert.Equal(t, &DuplicateError{Entity: "team"}, db.InsertTeam(ctx, &types.TeamStruct{CampaignName: campaignName, Name: teamName}))
+	rowsAffected, err := db.UpdateParticipantTeam(ctx, teamName, campaignName, "GitHub", loginName)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+	assert.NoError(t, db.UpdateParticipantScore(ctx, participant, 4))
+
+	stale, err := db.SelectLeaderboardStale(ctx)
+	assert.NoError(t, err)
+	assert.True(t, stale)
+
+	assert.NoError(t, db.RefreshLeaderboard(ctx))
+	stale, err = db.SelectLeaderboardStale(ctx)
+	assert.NoError(t, err)
+	assert.False(t, stale)
+
+	leaderboard, err := db.SelectLeaderboard(ctx, campaignName, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.LeaderboardEntry{{Rank: 1, ScpName: "GitHub", LoginName: loginName, TeamName: teamName, Score: 4}},
+		leaderboard.Participants)
+	assert.NotNil(t, leaderboard.RefreshedOn)
+
+	teamLeaderboard, err := db.SelectTeamLeaderboard(ctx, campaignName, teamName)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(4), teamLeaderboard.Points)
+
+	finalized, err := db.InsertLeaderboardSnapshot(ctx, campaignName, now)
+	assert.NoError(t, err)
+	assert.True(t, finalized)
+	finalized, err = db.InsertLeaderboardSnapshot(ctx, campaignName, now)
+	assert.NoError(t, err)
+	assert.False(t, finalized)
+
+	snapshot, err := db.SelectLeaderboardSnapshot(ctx, campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, leaderboard.Participants, snapshot.Participants)
+
+	// removing the participant leaves a rank behind until the next refresh
+	_, err = db.DeleteParticipant(ctx, campaignName, "GitHub", loginName)
+	assert.NoError(t, err)
+	stale, err = db.SelectLeaderboardStale(ctx)
+	assert.NoError(t, err)
+	assert.True(t, stale)
+}
+
+func TestSqliteTeamCaptainMove(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+
+	ctx, now := context.Background(), time.Now()
+	setupSqliteCampaign(t, db, now)
+	require.NoError(t, db.InsertTeam(ctx, &types.TeamStruct{CampaignName: campaignName, Name: teamName}))
+	require.NoError(t, db.InsertTeam(ctx, &types.TeamStruct{CampaignName: campaignName, Name: "otherTeam"}))
+	_, err := db.UpdateParticipantTeam(ctx, teamName, campaignName, "GitHub", loginName)
+	require.NoError(t, err)
+	_, err = db.UpdateTeamCaptain(ctx, campaignName, teamName, "GitHub", loginName)
+	require.NoError(t, err)
+
+	// added to their own team again, the captain keeps the role
+	rowsAffected, err := db.UpdateParticipantTeam(ctx, teamName, campaignName, "GitHub", loginName)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+	isCaptain, err := db.IsTeamCaptain(ctx, campaignName, teamName, "GitHub", loginName)
+	assert.NoError(t, err)
+	assert.True(t, isCaptain)
+
+	_, err = db.UpdateParticipantTeam(ctx, "otherTeam", campaignName, "GitHub", loginName)
+	assert.Equal(t, &CaptainMoveError{CampaignName: campaignName, ScpName: "GitHub", LoginName: loginName}, err)
+	detail, err := db.SelectParticipantDetail(ctx, campaignName, "GitHub", loginName)
+	assert.NoError(t, err)
+	assert.Equal(t, teamName, detail.TeamName)
+}
+
+func TestSqliteTeamScoreHistory(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+
+	ctx, now := context.Background(), time.Now()
+	participant := setupSqliteCampaign(t, db, now)
+	assert.NoError(t, db.InsertTeam(ctx, &types.TeamStruct{CampaignName: campaignName, Name: teamName}))
+	_, err := db.UpdateParticipantTeam(ctx, teamName, campaignName, "GitHub", loginName)
+	assert.NoError(t, err)
+	msg := &types.ScoringMessage{EventSource: "GitHub", RepoOwner: "myOrg", RepoName: "myRepo", TriggerUser: loginName, PullRequest: 5}
+	assert.NoError(t, db.InsertScoringEvent(ctx, participant, msg, 2, nil))
+
+	_, err = db.InsertTeamScoreAdjustments(ctx, campaignName, &types.TeamScoreAdjustmentRequest{
+		Category:    "demo",
+		Adjustments: []types.TeamScoreAdjustment{{TeamName: "noSuchTeam", Points: 5}},
+	}, now)
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+
+	// awarded before the pull request was scored
+	awardedOn := now.Add(-time.Hour)
+	events, err := db.InsertTeamScoreAdjustments(ctx, campaignName, &types.TeamScoreAdjustmentRequest{
+		Category:    "demo",
+		Adjustments: []types.TeamScoreAdjustment{{TeamName: teamName, Points: 5, Reason: "best demo"}},
+	}, awardedOn)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(events))
+
+	history, err := db.SelectTeamScoreHistory(ctx, campaignName, teamName)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(history))
+	assert.Equal(t, types.TeamScoreKindJudgedAward, history[0].Kind)
+	assert.Equal(t, awardedOn.UTC().Truncate(time.Microsecond), history[0].CreatedOn.Time.Truncate(time.Microsecond))
+	assert.Equal(t, types.TeamScoreKindPullRequest, history[1].Kind)
+	assert.True(t, history[1].CreatedOn.Valid)
+	assert.False(t, history[1].CreatedOn.Time.Before(awardedOn))
+}
+
+func TestSqliteClaimCampaignTransitions(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+
+	ctx, now := context.Background(), time.Now()
+	setupSqliteCampaign(t, db, now)
+
+	transitions, err := db.ClaimCampaignTransitions(ctx, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(transitions))
+	assert.Equal(t, "upcoming", transitions[0].FromStatus)
+	assert.Equal(t, "active", transitions[0].Campaign.Status)
+
+	transitions, err = db.ClaimCampaignTransitions(ctx, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(transitions))
+
+	transitions, err = db.ClaimCampaignTransitions(ctx, now.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(transitions))
+	assert.Equal(t, "ended", transitions[0].Campaign.Status)
+	assert.True(t, transitions[0].FirstEnd)
+}
+
+func TestSqliteScoreTimeSeries(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+
+	ctx, now := context.Background(), time.Now()
+	participant := setupSqliteCampaign(t, db, now)
+	msg := &types.ScoringMessage{EventSource: "GitHub", RepoOwner: "myOrg", RepoName: "myRepo", TriggerUser: loginName, PullRequest: 5}
+	assert.NoError(t, db.InsertScoringEvent(ctx, participant, msg, 3, nil))
+
+	points, err := db.SelectScoreTimeSeries(ctx, campaignName, "week", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(points))
+	assert.Equal(t, time.Monday, points[0].Start.Weekday())
+	assert.Equal(t, float64(3), points[0].Points)
+
+	_, err = db.SelectScoreTimeSeries(ctx, campaignName, "year", false)
+	assert.EqualError(t, err, "invalid interval: year")
+
+	report, err := db.SelectCampaignReport(ctx, campaignName, 5, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Stats.PullRequests)
+	assert.Equal(t, 1, len(report.Timeline))
+	assert.Equal(t, 1, report.Timeline[0].ParticipantsJoined)
+}
+
+func TestSqliteSeedFixture(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+
+	ctx, now := context.Background(), time.Now()
+	fixture := &types.SeedFixture{
+		Campaigns:     []types.CampaignStruct{{Name: campaignName, StartOn: now, EndOn: now.Add(time.Hour)}},
+		Bugs:          []types.BugStruct{{Campaign: campaignName, Category: "sql-injection", PointValue: 5}},
+		Organizations: []types.OrganizationStruct{{SCPName: "GitHub", Organization: "myOrg", CampaignName: campaignName}},
+		Teams:         []types.TeamStruct{{CampaignName: campaignName, Name: teamName}},
+		Participants:  []types.ParticipantStruct{{CampaignName: campaignName, ScpName: "GitHub", LoginName: loginName, TeamName: teamName}},
+	}
+	assert.NoError(t, db.InsertSeedFixture(ctx, fixture))
+	team, err := db.SelectTeamDetail(ctx, campaignName, teamName)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(team.Members))
+
+	// a failing fixture adds nothing
+	err = db.InsertSeedFixture(ctx, &types.SeedFixture{
+		Campaigns:    []types.CampaignStruct{{Name: "otherCampaign", StartOn: now, EndOn: now.Add(time.Hour)}},
+		Participants: []types.ParticipantStruct{{CampaignName: "otherCampaign", ScpName: "GitHub", LoginName: loginName, TeamName: "noTeam"}},
+	})
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+	campaigns, err := db.GetCampaigns(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(campaigns))
+}
+
+func TestSqliteBackup(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx, now := context.Background(), time.Now()
+	participant := setupSqliteCampaign(t, db, now)
+	msg := &types.ScoringMessage{EventSource: "GitHub", RepoOwner: "myOrg", RepoName: "myRepo", TriggerUser: loginName, PullRequest: 1}
+	require.NoError(t, db.InsertScoringEvent(ctx, participant, msg, 2, nil))
+	coAuthor := &types.ParticipantStruct{CampaignName: campaignName, ScpName: "GitHub", LoginName: "coAuthor"}
+	require.NoError(t, db.InsertParticipant(ctx, coAuthor))
+	require.NoError(t, db.InsertCoAuthorScore(ctx, coAuthor, msg, 1))
+	require.NoError(t, db.UpsertCampaignPenalty(ctx, &types.CampaignPenaltyStruct{CampaignName: campaignName, Enabled: true, PointValue: 1}))
+
+	written := map[string]*bytes.Buffer{}
+	rowCounts, err := db.Backup(ctx, func(table string) (io.Writer, error) {
+		written[table] = &bytes.Buffer{}
+		return written[table], nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, len(backupTables), len(written))
+	assert.Equal(t, int64(1), rowCounts["campaign"])
+	assert.Equal(t, int64(2), rowCounts["participant"])
+	assert.Equal(t, int64(1), rowCounts["scoring_event"])
+	assert.Equal(t, int64(1), rowCounts["scoring_event_coauthor"])
+	assert.Equal(t, int64(1), rowCounts["organization"])
+	assert.Equal(t, int64(1), rowCounts["campaign_penalty"])
+	assert.Equal(t, int64(0), rowCounts["team"])
+
+	var campaign map[string]interface{}
+	require.NoError(t, json.Unmarshal(written["campaign"].Bytes(), &campaign))
+	assert.Equal(t, campaignName, campaign["name"])
+	var event map[string]interface{}
+	require.NoError(t, json.Unmarshal(written["scoring_event"].Bytes(), &event))
+	assert.Equal(t, "myRepo", event["repoName"])
+
+	forcedError := errors.New("forced open error")
+	_, err = db.Backup(ctx, func(table string) (io.Writer, error) {
+		return nil, forcedError
+	})
+	assert.EqualError(t, err, forcedError.Error())
+}
+
+func TestSqliteBackupTables(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+
+	// every table of the schema is backed up, or left out on purpose
+	rows, err := db.(*SqliteDB).db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name != 'schema_migrations'`)
+	require.NoError(t, err)
+	defer rows.Close()
+	backedUp := map[string]bool{}
+	for _, table := range backupTables {
+		backedUp[table] = true
+	}
+	for rows.Next() {
+		var table string
+		require.NoError(t, rows.Scan(&table))
+		_, excluded := BackupExcludedTables[table]
+		assert.True(t, backedUp[table] != excluded, table)
+	}
+	assert.NoError(t, rows.Err())
+}
+
+func TestSqliteScheduledJobRuns(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx, now := context.Background(), time.Now().UTC().Truncate(time.Second)
+
+	run := types.ScheduledJobRun{Name: "heartbeat", InstanceId: "myInstance", StartedOn: now, FinishedOn: now, Error: "forced error"}
+	require.NoError(t, db.UpsertScheduledJobRun(ctx, &run))
+	// the latest run replaces the earlier, by any replica
+	run = types.ScheduledJobRun{Name: "heartbeat", InstanceId: "otherInstance", StartedOn: now.Add(time.Minute), FinishedOn: now.Add(time.Minute)}
+	require.NoError(t, db.UpsertScheduledJobRun(ctx, &run))
+	require.NoError(t, db.UpsertScheduledJobRun(ctx, &types.ScheduledJobRun{Name: "archival", InstanceId: "myInstance", StartedOn: now, FinishedOn: now}))
+
+	runs, err := db.SelectScheduledJobRuns(ctx)
+	assert.NoError(t, err)
+	require.Equal(t, 2, len(runs))
+	assert.Equal(t, "archival", runs[0].Name)
+	assert.Equal(t, int64(1), runs[0].Runs)
+	assert.Equal(t, "heartbeat", runs[1].Name)
+	assert.Equal(t, "otherInstance", runs[1].InstanceId)
+	assert.True(t, now.Add(time.Minute).Equal(runs[1].StartedOn))
+	assert.Equal(t, "", runs[1].Error)
+	assert.Equal(t, int64(2), runs[1].Runs)
+	assert.Equal(t, int64(1), runs[1].Failures)
+}
+
+func TestSqlitePollLease(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx, now := context.Background(), time.Now().UTC().Truncate(time.Second)
+
+	held, err := db.AcquirePollLease(ctx, "leader", now, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, held)
+	// held by the leader until it expires
+	held, err = db.AcquirePollLease(ctx, "other", now.Add(time.Second), now.Add(time.Minute+time.Second))
+	assert.NoError(t, err)
+	assert.False(t, held)
+	held, err = db.AcquirePollLease(ctx, "leader", now.Add(time.Second), now.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, held)
+	held, err = db.AcquirePollLease(ctx, "other", now.Add(2*time.Minute), now.Add(3*time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, held)
+
+	// only the holder releases it
+	assert.NoError(t, db.ReleasePollLease(ctx, "leader"))
+	held, err = db.AcquirePollLease(ctx, "leader", now.Add(2*time.Minute), now.Add(3*time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, held)
+	assert.NoError(t, db.ReleasePollLease(ctx, "other"))
+	held, err = db.AcquirePollLease(ctx, "leader", now.Add(2*time.Minute), now.Add(3*time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, held)
+}
+
+func TestSqliteMaintenance(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx, now := context.Background(), time.Now().UTC().Truncate(time.Second)
+
+	state, err := db.SelectMaintenance(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, types.MaintenanceState{}, state)
+
+	_, err = db.UpdateMaintenance(ctx, true, "migrating", now)
+	require.NoError(t, err)
+	// still on since it was first turned on
+	state, err = db.UpdateMaintenance(ctx, true, "still migrating", now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, state.Enabled)
+	assert.Equal(t, "still migrating", state.Message)
+	require.NotNil(t, state.Since)
+	assert.True(t, now.Equal(*state.Since))
+
+	state, err = db.SelectMaintenance(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "still migrating", state.Message)
+	require.NotNil(t, state.Since)
+	assert.True(t, now.Equal(*state.Since))
+
+	state, err = db.UpdateMaintenance(ctx, false, "", now)
+	assert.NoError(t, err)
+	assert.Equal(t, types.MaintenanceState{}, state)
+	state, err = db.SelectMaintenance(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, types.MaintenanceState{}, state)
+}
+
+func TestSqliteJobs(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx, now := context.Background(), time.Now().UTC().Truncate(time.Second)
+
+	_, err := db.SelectJob(ctx, "notAJob")
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	job := types.Job{Kind: "score-event-import", Status: types.JobStatusRunning, InstanceId: "myInstance", Total: 2, CreatedOn: now}
+	require.NoError(t, db.InsertJob(ctx, &job))
+	require.NotEqual(t, "", job.ID)
+	require.NoError(t, db.UpdateJobProgress(ctx, job.ID, 1, 2))
+
+	selected, err := db.SelectJob(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, types.JobStatusRunning, selected.Status)
+	assert.Equal(t, int64(1), selected.Done)
+	assert.Equal(t, int64(2), selected.Total)
+	assert.Nil(t, selected.Result)
+	assert.Nil(t, selected.FinishedOn)
+
+	finishedOn := now.Add(time.Minute)
+	job.Status, job.Done, job.Result, job.FinishedOn = types.JobStatusSucceeded, 2, json.RawMessage(`{"read":2}`), &finishedOn
+	require.NoError(t, db.FinishJob(ctx, &job))
+
+	selected, err = db.SelectJob(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, types.JobStatusSucceeded, selected.Status)
+	assert.Equal(t, "myInstance", selected.InstanceId)
+	assert.Equal(t, int64(2), selected.Done)
+	assert.JSONEq(t, `{"read":2}`, string(selected.Result))
+	assert.Equal(t, "", selected.Error)
+	assert.True(t, now.Equal(selected.CreatedOn))
+	require.NotNil(t, selected.FinishedOn)
+	assert.True(t, finishedOn.Equal(*selected.FinishedOn))
+}
+
+func TestSqliteFailJobs(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx, now := context.Background(), time.Now().UTC().Truncate(time.Second)
+	deadline := now.Add(-time.Minute)
+	require.NoError(t, db.UpsertClusterInstance(ctx, &types.ClusterInstance{InstanceId: "alive", StartedOn: deadline, LastHeartbeat: now}))
+	require.NoError(t, db.UpsertClusterInstance(ctx, &types.ClusterInstance{InstanceId: "dead", StartedOn: deadline, LastHeartbeat: deadline.Add(-time.Second)}))
+	jobs := map[string]*types.Job{}
+	for _, instanceId := range []string{"alive", "dead", "myInstance"} {
+		job := &types.Job{Kind: "score-event-import", Status: types.JobStatusRunning, InstanceId: instanceId, CreatedOn: deadline.Add(-time.Minute)}
+		require.NoError(t, db.InsertJob(ctx, job))
+		jobs[instanceId] = job
+	}
+	// a replica may start a job before its first heartbeat
+	young := &types.Job{Kind: "score-event-import", Status: types.JobStatusRunning, InstanceId: "young", CreatedOn: now}
+	require.NoError(t, db.InsertJob(ctx, young))
+	jobs["young"] = young
+
+	failed, err := db.FailOrphanedJobs(ctx, deadline, "stopped", now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), failed)
+	failed, err = db.FailInstanceJobs(ctx, "alive", "restarted", now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), failed)
+	// a job already failed is left as it is
+	failed, err = db.FailInstanceJobs(ctx, "dead", "restarted", now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), failed)
+
+	for instanceId, expected := range map[string]string{"alive": "restarted", "dead": "stopped", "myInstance": "stopped", "young": ""} {
+		selected, err := db.SelectJob(ctx, jobs[instanceId].ID)
+		require.NoError(t, err)
+		assert.Equal(t, expected, selected.Error, instanceId)
+		if expected == "" {
+			assert.Equal(t, types.JobStatusRunning, selected.Status, instanceId)
+			assert.Nil(t, selected.FinishedOn, instanceId)
+		} else {
+			assert.Equal(t, types.JobStatusFailed, selected.Status, instanceId)
+			require.NotNil(t, selected.FinishedOn, instanceId)
+			assert.True(t, now.Equal(*selected.FinishedOn), instanceId)
+		}
+	}
+}
+
+func TestSqlitePublicCampaigns(t *testing.T) {
+	db, closeDbFunc := setupSqliteDB(t)
+	defer closeDbFunc()
+	ctx, now := context.Background(), time.Now().UTC().Truncate(time.Second)
+
+	_, err := db.InsertTenant(ctx, &types.TenantStruct{Name: "myTenant", AdminUsername: "tenantAdmin"}, "passwordHash")
+	require.NoError(t, err)
+	_, err = db.InsertCampaign(ctx, &types.CampaignStruct{Name: "tenantCampaign", StartOn: now, EndOn: now.Add(time.Hour), TenantName: "myTenant"})
+	require.NoError(t, err)
+	_, err = db.InsertCampaign(ctx, &types.CampaignStruct{Name: campaignName, StartOn: now, EndOn: now.Add(time.Hour)})
+	require.NoError(t, err)
+
+	campaigns, err := db.SelectPublicCampaigns(ctx)
+	assert.NoError(t, err)
+	require.Equal(t, 1, len(campaigns))
+	assert.Equal(t, campaignName, campaigns[0].Name)
+}
@@ -20,82 +20,280 @@
 package db
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
 	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/lib/pq"
 	"github.com/sonatype-nexus-community/bbash/internal/types"
 	"go.uber.org/zap"
+	"io"
+	"strings"
 	"time"
 )
 
 type IScoreDB interface {
 	GetDb() (db *sql.DB)
-	SelectPriorScore(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64)
-	InsertScoringEvent(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64) (err error)
-	UpdateParticipantScore(participant *types.ParticipantStruct, delta float64) (err error)
+	SelectPriorScore(ctx context.Context, participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64)
+	InsertScoringEvent(ctx context.Context, participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64, breakdown *types.ScoreBreakdown) (err error)
+	InsertScoringEvents(ctx context.Context, events []ScoringEventRow, scoreDeltas []ParticipantScoreDelta) (err error)
+	UpdateParticipantScore(ctx context.Context, participant *types.ParticipantStruct, delta float64) (err error)
+	SelectScoringEventsSince(ctx context.Context, participant *types.ParticipantStruct, since time.Time) (events []ScoringEventRow, err error)
+	SelectDuplicateFingerprints(ctx context.Context, participant *types.ParticipantStruct, msg *types.ScoringMessage) (duplicates []string, err error)
+	SelectCoAuthorPriorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64)
+	InsertCoAuthorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64) (err error)
+	SelectCoAuthorScores(ctx context.Context, participant *types.ParticipantStruct, msg *types.ScoringMessage) (coAuthors []CoAuthorScoreRow, err error)
+	DeleteCoAuthorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage) (err error)
 }
 
 type IBBashDB interface {
-	MigrateDB(migrateSourceURL string) error
+	MigrateDB() error
+	SelectMigrationStatus(ctx context.Context) (status types.MigrationStatus, err error)
+	RollbackMigrations(steps int) (err error)
 
-	GetSourceControlProviders() (scps []types.SourceControlProviderStruct, err error)
+	GetSourceControlProviders(ctx context.Context) (scps []types.SourceControlProviderStruct, err error)
 
-	InsertCampaign(campaign *types.CampaignStruct) (guid string, err error)
-	UpdateCampaign(campaign *types.CampaignStruct) (guid string, err error)
-	GetCampaign(campaignName string) (campaign *types.CampaignStruct, err error)
-	GetCampaigns() (campaigns []types.CampaignStruct, err error)
-	GetActiveCampaigns(now time.Time) (activeCampaigns []types.CampaignStruct, err error)
+	InsertCampaign(ctx context.Context, campaign *types.CampaignStruct) (guid string, err error)
+	UpdateCampaign(ctx context.Context, campaign *types.CampaignStruct) (guid string, err error)
+	GetCampaign(ctx context.Context, campaignName string) (campaign *types.CampaignStruct, err error)
+	GetCampaigns(ctx context.Context) (campaigns []types.CampaignStruct, err error)
+	SelectPublicCampaigns(ctx context.Context) (campaigns []types.CampaignStruct, err error)
+	SelectCampaignsVersion(ctx context.Context) (version types.ListVersion, err error)
+	GetActiveCampaigns(ctx context.Context, now time.Time) (activeCampaigns []types.CampaignStruct, err error)
+	GetUpcomingCampaigns(ctx context.Context, now time.Time) (upcomingCampaigns []types.CampaignStruct, err error)
+	SelectCampaignTenant(ctx context.Context, campaignName string) (tenantName string, err error)
+	SelectRegistrationWindow(ctx context.Context, campaignName string) (opensOn, closesOn *time.Time, err error)
 
-	InsertOrganization(organization *types.OrganizationStruct) (guid string, err error)
-	GetOrganizations() (organizations []types.OrganizationStruct, err error)
-	DeleteOrganization(scpName, orgName string) (rowsAffected int64, err error)
-	ValidOrganization(msg *types.ScoringMessage) (orgExists bool, err error)
+	InsertTenant(ctx context.Context, tenant *types.TenantStruct, passwordHash string) (guid string, err error)
+	SelectTenants(ctx context.Context) (tenants []types.TenantStruct, err error)
+	SelectTenantAdmin(ctx context.Context, tenantName string) (username, passwordHash string, err error)
+	SelectTenantCampaigns(ctx context.Context, tenantName string) (campaigns []types.CampaignStruct, err error)
 
-	SelectParticipantsToScore(msg *types.ScoringMessage, now time.Time) (participantsToScore []types.ParticipantStruct, err error)
-	SelectPointValue(msg *types.ScoringMessage, campaignName, bugType string) (pointValue float64)
+	InsertOrganization(ctx context.Context, organization *types.OrganizationStruct) (guid string, err error)
+	GetOrganizations(ctx context.Context) (organizations []types.OrganizationStruct, err error)
+	DeleteOrganization(ctx context.Context, scpName, orgName, campaignName string) (rowsAffected int64, err error)
+	UpdateOrganization(ctx context.Context, scpName, orgName string, update *types.OrganizationUpdate) (rowsAffected, eventsRelinked int64, err error)
+	ValidOrganization(ctx context.Context, msg *types.ScoringMessage, now time.Time) (orgExists bool, err error)
+
+	SelectParticipantsToScore(ctx context.Context, msg *types.ScoringMessage, now time.Time) (participantsToScore []types.ParticipantStruct, err error)
+	SelectPointValue(ctx context.Context, msg *types.ScoringMessage, campaignName, bugType string) (pointValue float64)
 	IScoreDB
+	SelectScoringEvent(ctx context.Context, campaignName, scpName, repoOwner, repoName string, pullRequest int) (event *types.ScoringEventStruct, err error)
+	ExportScoringEvents(ctx context.Context, campaignName string, each func(event *types.ScoringEventStruct) error) (err error)
+	ImportScoringEvents(ctx context.Context, events []types.ScoringEventStruct) (imported, recomputed int64, err error)
+	SelectCampaignsToArchive(ctx context.Context, endedBefore time.Time) (campaignNames []string, err error)
+	ArchiveScoringEvents(ctx context.Context, campaignName string, now time.Time) (archive *types.ScoringEventArchive, err error)
+	RestoreScoringEvents(ctx context.Context, campaignName string, now time.Time) (archive *types.ScoringEventArchive, err error)
+	SelectScoringEventArchives(ctx context.Context) (archives []types.ScoringEventArchive, err error)
+	VoidScoringEvent(ctx context.Context, eventId string, now time.Time) (event *types.ScoringEventStruct, teamName string, err error)
+	SelectScoredEvents(ctx context.Context, since time.Time) (events []ScoredEvent, err error)
+	InsertScoringFlags(ctx context.Context, flags []types.ScoringFlag) (flagged int64, err error)
+	SelectScoringFlags(ctx context.Context) (flags []types.ScoringFlag, err error)
+	ResolveScoringFlag(ctx context.Context, flagId, resolution string, now time.Time) (flag *types.ScoringFlag, err error)
+
+	InsertParticipant(ctx context.Context, participant *types.ParticipantStruct) (err error)
+	UpsertParticipant(ctx context.Context, participant *types.ParticipantStruct) (inserted bool, err error)
+	SelectParticipantDetail(ctx context.Context, campaignName, scpName, loginName string) (participant *types.ParticipantStruct, err error)
+	SelectParticipantsInCampaign(ctx context.Context, campaignName string) (participants []types.ParticipantStruct, err error)
+	SelectParticipantsVersion(ctx context.Context, campaignName string) (version types.ListVersion, err error)
+	SearchParticipants(ctx context.Context, login string, limit int) (participants []types.ParticipantStruct, err error)
+	UpdateParticipant(ctx context.Context, participant *types.ParticipantStruct) (rowsAffected int64, err error)
+	PatchParticipant(ctx context.Context, patch *types.ParticipantPatch) (rowsAffected int64, err error)
+	UpdateParticipantContact(ctx context.Context, scpName, loginName string, request *types.ParticipantProfileRequest) (rowsAffected int64, err error)
+	UpdateParticipantProfile(ctx context.Context, participantId, displayName, avatarUrl string, now time.Time) (err error)
+	SelectParticipantsToRefresh(ctx context.Context, refreshedBefore time.Time, limit int) (participants []types.ParticipantStruct, err error)
+	DeleteParticipant(ctx context.Context, campaign, scpName, loginName string) (participantId string, err error)
+	InsertBan(ctx context.Context, ban *types.BanStruct) (err error)
+	SelectBans(ctx context.Context) (bans []types.BanStruct, err error)
+	DeleteBan(ctx context.Context, scpName, loginName string) (rowsAffected int64, err error)
+	SelectBanned(ctx context.Context, scpName, loginName string) (banned bool, err error)
+	UpdateParticipantTeam(ctx context.Context, teamName, campaignName, scpName, loginName string) (rowsAffected int64, err error)
+	UpdateParticipantTeams(ctx context.Context, assignments []types.TeamAssignment) (results []types.TeamAssignmentResult, err error)
+	InsertSeedFixture(ctx context.Context, fixture *types.SeedFixture) (err error)
+
+	InsertTeam(ctx context.Context, team *types.TeamStruct) (err error)
+	SelectTeamsInCampaign(ctx context.Context, campaignName string) (teams []types.TeamStruct, err error)
+	SelectTeamDetail(ctx context.Context, campaignName, teamName string) (team *types.TeamStruct, err error)
+	DeleteTeam(ctx context.Context, campaignName, teamName string) (rowsAffected int64, err error)
+	UpdateTeamName(ctx context.Context, campaignName, teamName, newTeamName string) (rowsAffected int64, err error)
+	UpdateTeamCaptain(ctx context.Context, campaignName, teamName, scpName, loginName string) (rowsAffected int64, err error)
+	IsTeamCaptain(ctx context.Context, campaignName, teamName, scpName, loginName string) (isCaptain bool, err error)
+	RemoveParticipantFromTeam(ctx context.Context, teamName, campaignName, scpName, loginName string) (rowsAffected int64, err error)
+	InsertTeamScoreAdjustments(ctx context.Context, campaignName string, request *types.TeamScoreAdjustmentRequest, now time.Time) (events []types.TeamScoreEvent, err error)
+	SelectTeamScoreHistory(ctx context.Context, campaignName, teamName string) (events []types.TeamScoreEvent, err error)
+
+	InsertBug(ctx context.Context, bug *types.BugStruct) (err error)
+	UpdateBug(ctx context.Context, bug *types.BugStruct) (rowsAffected int64, err error)
+	UpsertPointValueOverride(ctx context.Context, override *types.PointValueOverride) (err error)
+	SelectPointValueOverrides(ctx context.Context, campaignName string) (overrides []types.PointValueOverride, err error)
+	DeletePointValueOverride(ctx context.Context, override *types.PointValueOverride) (rowsAffected int64, err error)
+	InsertBugCategoryExclusion(ctx context.Context, exclusion *types.BugCategoryExclusion) (err error)
+	SelectBugCategoryExclusions(ctx context.Context, campaignName string) (exclusions []types.BugCategoryExclusion, err error)
+	DeleteBugCategoryExclusion(ctx context.Context, campaignName, category string) (rowsAffected int64, err error)
+	InsertRepositoryFilter(ctx context.Context, filter *types.RepositoryFilter) (err error)
+	SelectRepositoryFilters(ctx context.Context, campaignName string) (filters []types.RepositoryFilter, err error)
+	DeleteRepositoryFilter(ctx context.Context, campaignName, filterId string) (rowsAffected int64, err error)
+	SelectBugs(ctx context.Context) (bugs []types.BugStruct, err error)
+	SelectBugsInCampaign(ctx context.Context, campaignName string) (bugs []types.BugStruct, err error)
+	SelectBugsVersion(ctx context.Context) (version types.ListVersion, err error)
+
+	UpsertCampaignConfigStage(ctx context.Context, config *types.CampaignConfigStruct) (err error)
+	SelectCampaignConfigStage(ctx context.Context, campaignName string) (config *types.CampaignConfigStruct, err error)
+	DeleteCampaignConfigStage(ctx context.Context, campaignName string) (rowsAffected int64, err error)
+	PromoteCampaignConfigStage(ctx context.Context, campaignName string) (config *types.CampaignConfigStruct, err error)
+
+	UpsertSlackNotification(ctx context.Context, config *types.SlackNotificationStruct) (err error)
+	SelectSlackNotification(ctx context.Context, campaignName string) (config *types.SlackNotificationStruct, err error)
+	DeleteSlackNotification(ctx context.Context, campaignName string) (rowsAffected int64, err error)
+	UpsertCampaignPenalty(ctx context.Context, penalty *types.CampaignPenaltyStruct) (err error)
+	SelectCampaignPenalty(ctx context.Context, campaignName string) (penalty *types.CampaignPenaltyStruct, err error)
+	UpsertCampaignCaps(ctx context.Context, caps *types.CampaignCapsStruct) (err error)
+	SelectCampaignCaps(ctx context.Context, campaignName string) (caps *types.CampaignCapsStruct, err error)
+	UpsertDuplicatePolicy(ctx context.Context, policy *types.DuplicatePolicyStruct) (err error)
+	SelectDuplicatePolicy(ctx context.Context, campaignName string) (policy *types.DuplicatePolicyStruct, err error)
+	UpsertCoAuthorPolicy(ctx context.Context, policy *types.CoAuthorPolicyStruct) (err error)
+	SelectCoAuthorPolicy(ctx context.Context, campaignName string) (policy *types.CoAuthorPolicyStruct, err error)
+	UpsertCampaignPrivacy(ctx context.Context, privacy *types.CampaignPrivacyStruct) (err error)
+	SelectCampaignPrivacy(ctx context.Context, campaignName string) (privacy *types.CampaignPrivacyStruct, err error)
+	SelectUnclassifiedBonus(ctx context.Context, campaignName string) (bonus float64, err error)
+	SelectTopParticipants(ctx context.Context, campaignName string, count int) (participants []types.ParticipantStruct, err error)
+	SelectLeaderboardStale(ctx context.Context) (stale bool, err error)
+	RefreshLeaderboard(ctx context.Context) (err error)
+	SelectLeaderboard(ctx context.Context, campaignName string, limit int) (leaderboard *types.Leaderboard, err error)
+	SelectTeamLeaderboard(ctx context.Context, campaignName, teamName string) (leaderboard *types.TeamLeaderboard, err error)
+	InsertLeaderboardSnapshot(ctx context.Context, campaignName string, now time.Time) (finalized bool, err error)
+	SelectLeaderboardSnapshot(ctx context.Context, campaignName string) (snapshot *types.LeaderboardSnapshot, err error)
+
+	UpsertCampaignEmail(ctx context.Context, email *types.CampaignEmailStruct) (err error)
+	SelectCampaignEmails(ctx context.Context, campaignName string) (emails []types.CampaignEmailStruct, err error)
+	SelectCampaignEmail(ctx context.Context, campaignName, kind string) (email *types.CampaignEmailStruct, err error)
+
+	SelectBugsFixed(ctx context.Context, participant *types.ParticipantStruct) (bugsFixed float64, err error)
+	InsertAchievement(ctx context.Context, participantId, kind string, now time.Time) (awarded bool, err error)
+	SelectAchievements(ctx context.Context, campaignName, scpName, loginName string) (achievements []types.AchievementStruct, err error)
+	SelectParticipantScoreAt(ctx context.Context, campaignName, scpName, loginName string, at time.Time) (score *types.ParticipantScoreAt, err error)
+	SelectAchievementStats(ctx context.Context, campaignName string) (stats []types.AchievementStat, err error)
 
-	InsertParticipant(participant *types.ParticipantStruct) (err error)
-	SelectParticipantDetail(campaignName, scpName, loginName string) (participant *types.ParticipantStruct, err error)
-	SelectParticipantsInCampaign(campaignName string) (participants []types.ParticipantStruct, err error)
-	UpdateParticipant(participant *types.ParticipantStruct) (rowsAffected int64, err error)
-	DeleteParticipant(campaign, scpName, loginName string) (participantId string, err error)
-	UpdateParticipantTeam(teamName, campaignName, scpName, loginName string) (rowsAffected int64, err error)
+	InsertWebhook(ctx context.Context, hook *types.WebhookStruct) (err error)
+	SelectWebhooks(ctx context.Context) (hooks []types.WebhookStruct, err error)
+	DeleteWebhook(ctx context.Context, webhookId string) (rowsAffected int64, err error)
+	ClaimCampaignTransitions(ctx context.Context, now time.Time) (transitions []types.CampaignTransition, err error)
 
-	InsertTeam(team *types.TeamStruct) (err error)
+	InsertNotification(ctx context.Context, notification *types.NotificationStruct) (err error)
+	SelectNotifications(ctx context.Context, campaignName, scpName, loginName string, unreadOnly bool) (notifications []types.NotificationStruct, err error)
+	UpdateNotificationsRead(ctx context.Context, campaignName, scpName, loginName, notificationId string, now time.Time) (rowsAffected int64, err error)
 
-	InsertBug(bug *types.BugStruct) (err error)
-	UpdateBug(bug *types.BugStruct) (rowsAffected int64, err error)
-	SelectBugs() (bugs []types.BugStruct, err error)
+	SelectCampaignReport(ctx context.Context, campaignName string, topCount int, now time.Time) (report *types.CampaignReport, err error)
+	UpsertCampaignReport(ctx context.Context, report *types.CampaignReport, html string) (err error)
+	SelectScoreTimeSeries(ctx context.Context, campaignName, interval string, byTeam bool) (points []types.TimeSeriesPoint, err error)
+	SelectStoredCampaignReport(ctx context.Context, campaignName string) (reportJson []byte, html string, err error)
+
+	InsertTelemetryEvent(ctx context.Context, feature, call string, now time.Time) (err error)
+	SelectTelemetryUsage(ctx context.Context, from, to time.Time) (usage []types.TelemetryUsage, err error)
+
+	InsertAuditLog(ctx context.Context, entry *types.AuditLogEntry) (err error)
+	SelectAuditLog(ctx context.Context, actor string, limit int) (entries []types.AuditLogEntry, err error)
+
+	UpsertClusterInstance(ctx context.Context, instance *types.ClusterInstance) (err error)
+	SelectClusterInstances(ctx context.Context) (instances []types.ClusterInstance, err error)
+	AcquirePollLease(ctx context.Context, instanceId string, now, expiresOn time.Time) (held bool, err error)
+	ReleasePollLease(ctx context.Context, instanceId string) (err error)
+	UpsertScheduledJobRun(ctx context.Context, run *types.ScheduledJobRun) (err error)
+	SelectScheduledJobRuns(ctx context.Context) (runs []types.ScheduledJobRun, err error)
+	SelectMaintenance(ctx context.Context) (state types.MaintenanceState, err error)
+	UpdateMaintenance(ctx context.Context, enabled bool, message string, now time.Time) (state types.MaintenanceState, err error)
+	InsertJob(ctx context.Context, job *types.Job) (err error)
+	UpdateJobProgress(ctx context.Context, jobId string, done, total int64) (err error)
+	FinishJob(ctx context.Context, job *types.Job) (err error)
+	SelectJob(ctx context.Context, jobId string) (job *types.Job, err error)
+	FailInstanceJobs(ctx context.Context, instanceId, message string, now time.Time) (failed int64, err error)
+	FailOrphanedJobs(ctx context.Context, heartbeatBefore time.Time, message string, now time.Time) (failed int64, err error)
+
+	Backup(ctx context.Context, open func(table string) (io.Writer, error)) (rowCounts map[string]int64, err error)
 }
 
 type BBashDB struct {
-	db     *sql.DB
+	db *sql.DB
+	// readDb serves the list and leaderboard queries, and is the same as db unless a read replica is configured.
+	readDb *sql.DB
 	logger *zap.Logger
+	// statementTimeout bounds each call, in addition to any deadline of the caller's context. Zero means no bound.
+	statementTimeout time.Duration
 }
 
 // Roll that beautiful bean footage
 var _ IBBashDB = (*BBashDB)(nil)
 
+// DefaultStatementTimeout is how long a call may take, unless changed by SetStatementTimeout.
+const DefaultStatementTimeout = 30 * time.Second
+
 func New(db *sql.DB, logger *zap.Logger) *BBashDB {
-	return &BBashDB{db: db, logger: logger}
+	return &BBashDB{db: db, readDb: db, logger: logger, statementTimeout: DefaultStatementTimeout}
+}
+
+// NewWithReadReplica sends the read only list and leaderboard queries to readDb, and everything else to db.
+func NewWithReadReplica(db, readDb *sql.DB, logger *zap.Logger) *BBashDB {
+	return &BBashDB{db: db, readDb: readDb, logger: logger, statementTimeout: DefaultStatementTimeout}
+}
+
+// SetStatementTimeout changes how long a call may take. Zero leaves calls bound only by the caller's context.
+func (p *BBashDB) SetStatementTimeout(timeout time.Duration) {
+	p.statementTimeout = timeout
+}
+
+func (p *BBashDB) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.statementTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.statementTimeout)
+}
+
+// retryDb is db, retrying the statements that fail with a transient error.
+func (p *BBashDB) retryDb() retryDB {
+	return retryDB{db: p.db}
+}
+
+// retryReadDb is readDb, retrying the statements that fail with a transient error, including those whose connection
+// dropped while they ran, as its statements only read.
+func (p *BBashDB) retryReadDb() retryDB {
+	return retryDB{db: p.readDb, readOnly: true}
 }
 
 func (p *BBashDB) GetDb() (db *sql.DB) {
 	return p.db
 }
 
-func (p *BBashDB) MigrateDB(migrateSourceURL string) (err error) {
+//go:embed migrations/v2/*.sql
+var migrations embed.FS
 
+const migrationsPath = "migrations/v2"
+
+// newMigrate reads the migrations built into the binary, so the database is migrated wherever the binary runs.
+func (p *BBashDB) newMigrate() (m *migrate.Migrate, err error) {
 	driver, err := postgres.WithInstance(p.db, &postgres.Config{})
 	if err != nil {
 		return
 	}
 
-	m, err := migrate.NewWithDatabaseInstance(
-		migrateSourceURL,
-		"postgres", driver)
+	source, err := iofs.New(migrations, migrationsPath)
+	if err != nil {
+		return
+	}
+
+	m, err = migrate.NewWithInstance("iofs", source, "postgres", driver)
+	return
+}
+
+func (p *BBashDB) MigrateDB() (err error) {
+	m, err := p.newMigrate()
 	if err != nil {
 		return
 	}
@@ -109,11 +307,40 @@ func (p *BBashDB) MigrateDB(migrateSourceURL string) (err error) {
 	return
 }
 
+const sqlSelectMigrationStatus = `SELECT version, dirty FROM schema_migrations`
+
+// SelectMigrationStatus reads the schema version, and whether the last migration failed part way through.
+func (p *BBashDB) SelectMigrationStatus(ctx context.Context) (status types.MigrationStatus, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectMigrationStatus).Scan(&status.Version, &status.Dirty)
+	if err == sql.ErrNoRows {
+		// no migration has run yet
+		err = nil
+	}
+	return
+}
+
+// RollbackMigrations runs the down migration of the given number of most recent migrations.
+func (p *BBashDB) RollbackMigrations(steps int) (err error) {
+	m, err := p.newMigrate()
+	if err != nil {
+		return
+	}
+
+	err = m.Steps(-steps)
+	return
+}
+
 const sqlSelectSourceControlProvider = `SELECT * FROM source_control_provider`
 
-func (p *BBashDB) GetSourceControlProviders() (scps []types.SourceControlProviderStruct, err error) {
+func (p *BBashDB) GetSourceControlProviders(ctx context.Context) (scps []types.SourceControlProviderStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
 	var rows *sql.Rows
-	rows, err = p.db.Query(sqlSelectSourceControlProvider)
+	rows, err = p.retryDb().QueryContext(ctx, sqlSelectSourceControlProvider)
 	if err != nil {
 		return
 	}
@@ -130,68 +357,142 @@ func (p *BBashDB) GetSourceControlProviders() (scps []types.SourceControlProvide
 }
 
 const sqlInsertCampaign = `INSERT INTO campaign 
-		(name, start_on, end_on) 
-		VALUES ($1, $2, $3)
+		(name, start_on, end_on, max_team_size, tie_breaker, unclassified_bonus, fk_tenant, time_zone, freeze_scoring,
+		 registration_opens_on, registration_closes_on) 
+		VALUES ($1, $2::TIMESTAMPTZ AT TIME ZONE COALESCE(NULLIF($8, ''), 'UTC'), $3::TIMESTAMPTZ AT TIME ZONE COALESCE(NULLIF($8, ''), 'UTC'),
+		        COALESCE($4::INTEGER, 0), COALESCE(NULLIF($5, ''), 'reachedScore'), COALESCE($6::NUMERIC, 1),
+		        (SELECT Id FROM tenant WHERE name = NULLIF($7, '')), COALESCE(NULLIF($8, ''), 'UTC'), COALESCE($9::BOOLEAN, FALSE),
+		        $10::TIMESTAMPTZ AT TIME ZONE COALESCE(NULLIF($8, ''), 'UTC'), $11::TIMESTAMPTZ AT TIME ZONE COALESCE(NULLIF($8, ''), 'UTC'))
 		RETURNING Id`
 
-func (p *BBashDB) InsertCampaign(campaign *types.CampaignStruct) (guid string, err error) {
-	err = p.db.QueryRow(
+// InsertCampaign adds the campaign, owned by its tenant if it names one. The caller checks that the tenant exists, as
+// an unknown tenant name adds a campaign of no tenant. The start, end and registration window are stored as the local
+// time of the time zone of the campaign.
+func (p *BBashDB) InsertCampaign(ctx context.Context, campaign *types.CampaignStruct) (guid string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx,
 		sqlInsertCampaign,
 		campaign.Name,
 		campaign.StartOn,
 		campaign.EndOn,
+		campaign.MaxTeamSize,
+		campaign.TieBreaker,
+		campaign.UnclassifiedBonus,
+		campaign.TenantName,
+		campaign.TimeZone,
+		campaign.FreezeScoring,
+		campaign.RegistrationOpensOn,
+		campaign.RegistrationClosesOn,
 	).Scan(&guid)
+	err = duplicateError(err, "campaign")
 	return
 }
 
 const sqlUpdateCampaign = `UPDATE campaign
-		SET start_on = $1,
-			end_on = $2		
-		WHERE name = $3
-		RETURNING id`
+		SET start_on = $1::TIMESTAMPTZ AT TIME ZONE COALESCE(NULLIF($7, ''), time_zone),
+			end_on = $2::TIMESTAMPTZ AT TIME ZONE COALESCE(NULLIF($7, ''), time_zone),
+			max_team_size = COALESCE($4::INTEGER, max_team_size),
+			tie_breaker = COALESCE(NULLIF($5, ''), tie_breaker),
+			unclassified_bonus = COALESCE($6::NUMERIC, unclassified_bonus),
+			time_zone = COALESCE(NULLIF($7, ''), time_zone),
+			freeze_scoring = COALESCE($8::BOOLEAN, freeze_scoring),
+			registration_opens_on = COALESCE($9::TIMESTAMPTZ, registration_opens_on AT TIME ZONE time_zone) AT TIME ZONE COALESCE(NULLIF($7, ''), time_zone),
+			registration_closes_on = COALESCE($10::TIMESTAMPTZ, registration_closes_on AT TIME ZONE time_zone) AT TIME ZONE COALESCE(NULLIF($7, ''), time_zone),
+			version = version + 1
+		WHERE name = $3 AND version = $11
+		RETURNING id, version`
 
-func (p *BBashDB) UpdateCampaign(campaign *types.CampaignStruct) (guid string, err error) {
-	err = p.db.QueryRow(
+const sqlSelectCampaignVersion = `SELECT version FROM campaign WHERE name = $1`
+
+// UpdateCampaign changes the campaign, when its version is the one the update was made from, then sets the version
+// of the update. The start, end and registration window are stored as the local time of the time zone of the
+// campaign, the one being set or else the current one, so a side of the window the update leaves out is moved to the
+// time zone being set. A *StaleError is returned when the version is not current.
+func (p *BBashDB) UpdateCampaign(ctx context.Context, campaign *types.CampaignStruct) (guid string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx,
 		sqlUpdateCampaign,
 		campaign.StartOn,
 		campaign.EndOn,
 		campaign.Name,
-	).Scan(&guid)
+		campaign.MaxTeamSize,
+		campaign.TieBreaker,
+		campaign.UnclassifiedBonus,
+		campaign.TimeZone,
+		campaign.FreezeScoring,
+		campaign.RegistrationOpensOn,
+		campaign.RegistrationClosesOn,
+		campaign.Version,
+	).Scan(&guid, &campaign.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = staleError(p.retryDb().QueryRowContext(ctx, sqlSelectCampaignVersion, campaign.Name), "campaign")
+	}
 	return
 }
 
-const sqlSelectCampaign = `SELECT ID, name, created_on, create_order, start_on, end_on, note 
+const sqlSelectCampaign = `SELECT ID, name, created_on, create_order, start_on AT TIME ZONE time_zone, end_on AT TIME ZONE time_zone, note, max_team_size, tie_breaker, unclassified_bonus, time_zone, status, freeze_scoring,
+		registration_opens_on AT TIME ZONE time_zone, registration_closes_on AT TIME ZONE time_zone, version
 	FROM campaign
 	WHERE name = $1`
 
-func (p *BBashDB) GetCampaign(campaignName string) (campaign *types.CampaignStruct, err error) {
-	rows, err := p.db.Query(sqlSelectCampaign, campaignName)
+func (p *BBashDB) GetCampaign(ctx context.Context, campaignName string) (campaign *types.CampaignStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	campaign = &types.CampaignStruct{}
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectCampaign, campaignName).
+		Scan(&campaign.ID, &campaign.Name, &campaign.CreatedOn, &campaign.CreatedOrder, &campaign.StartOn, &campaign.EndOn, &campaign.Note, &campaign.MaxTeamSize, &campaign.TieBreaker, &campaign.UnclassifiedBonus, &campaign.TimeZone, &campaign.Status, &campaign.FreezeScoring, &campaign.RegistrationOpensOn, &campaign.RegistrationClosesOn, &campaign.Version)
+	if err != nil {
+		campaign = nil
+	}
+	return
+}
+
+const sqlSelectCampaigns = `SELECT ID, name, created_on, create_order, start_on AT TIME ZONE time_zone, end_on AT TIME ZONE time_zone, note, max_team_size, tie_breaker, unclassified_bonus, time_zone, status, freeze_scoring,
+		registration_opens_on AT TIME ZONE time_zone, registration_closes_on AT TIME ZONE time_zone, version FROM campaign`
+
+func (p *BBashDB) GetCampaigns(ctx context.Context) (campaigns []types.CampaignStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectCampaigns)
 	if err != nil {
 		return
 	}
 
-	campaign = &types.CampaignStruct{}
 	for rows.Next() {
-		err = rows.Scan(&campaign.ID, &campaign.Name, &campaign.CreatedOn, &campaign.CreatedOrder, &campaign.StartOn, &campaign.EndOn, &campaign.Note)
+		campaign := types.CampaignStruct{}
+		err = rows.Scan(&campaign.ID, &campaign.Name, &campaign.CreatedOn, &campaign.CreatedOrder, &campaign.StartOn, &campaign.EndOn, &campaign.Note, &campaign.MaxTeamSize, &campaign.TieBreaker, &campaign.UnclassifiedBonus, &campaign.TimeZone, &campaign.Status, &campaign.FreezeScoring, &campaign.RegistrationOpensOn, &campaign.RegistrationClosesOn, &campaign.Version)
 		if err != nil {
 			return
 		}
+		campaigns = append(campaigns, campaign)
 	}
 	return
 }
 
-const sqlSelectCampaigns = `SELECT ID, name, created_on, create_order, start_on, end_on, note FROM campaign`
+const sqlSelectPublicCampaigns = `SELECT ID, name, created_on, create_order, start_on AT TIME ZONE time_zone, end_on AT TIME ZONE time_zone, note, max_team_size, tie_breaker, unclassified_bonus, time_zone, status, freeze_scoring,
+		registration_opens_on AT TIME ZONE time_zone, registration_closes_on AT TIME ZONE time_zone, version FROM campaign
+		WHERE fk_tenant IS NULL
+		ORDER BY create_order`
 
-func (p *BBashDB) GetCampaigns() (campaigns []types.CampaignStruct, err error) {
-	rows, err := p.db.Query(
-		sqlSelectCampaigns)
+// SelectPublicCampaigns reads the campaigns of the deployment admin, leaving out those of the tenants.
+func (p *BBashDB) SelectPublicCampaigns(ctx context.Context) (campaigns []types.CampaignStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectPublicCampaigns)
 	if err != nil {
 		return
 	}
 
 	for rows.Next() {
 		campaign := types.CampaignStruct{}
-		err = rows.Scan(&campaign.ID, &campaign.Name, &campaign.CreatedOn, &campaign.CreatedOrder, &campaign.StartOn, &campaign.EndOn, &campaign.Note)
+		err = rows.Scan(&campaign.ID, &campaign.Name, &campaign.CreatedOn, &campaign.CreatedOrder, &campaign.StartOn, &campaign.EndOn, &campaign.Note, &campaign.MaxTeamSize, &campaign.TieBreaker, &campaign.UnclassifiedBonus, &campaign.TimeZone, &campaign.Status, &campaign.FreezeScoring, &campaign.RegistrationOpensOn, &campaign.RegistrationClosesOn, &campaign.Version)
 		if err != nil {
 			return
 		}
@@ -200,13 +501,28 @@ func (p *BBashDB) GetCampaigns() (campaigns []types.CampaignStruct, err error) {
 	return
 }
 
-const sqlSelectCurrentCampaigns = `SELECT * FROM campaign
-		WHERE $1 >= start_on
-			AND $1 < end_on
-		ORDER BY start_on`
+const sqlSelectCampaignsVersion = `SELECT COUNT(*), COALESCE(MAX(updated_on), 'epoch') FROM campaign`
+
+// SelectCampaignsVersion identifies the current state of the campaign list, without reading the list.
+func (p *BBashDB) SelectCampaignsVersion(ctx context.Context) (version types.ListVersion, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryReadDb().QueryRowContext(ctx, sqlSelectCampaignsVersion).Scan(&version.Count, &version.UpdatedOn)
+	return
+}
+
+const sqlSelectCurrentCampaigns = `SELECT ID, name, created_on, create_order, start_on AT TIME ZONE time_zone, end_on AT TIME ZONE time_zone, note, max_team_size, tie_breaker, unclassified_bonus, time_zone, status, freeze_scoring,
+		registration_opens_on AT TIME ZONE time_zone, registration_closes_on AT TIME ZONE time_zone, version FROM campaign
+		WHERE $1 >= start_on AT TIME ZONE time_zone
+			AND $1 < end_on AT TIME ZONE time_zone
+		ORDER BY start_on AT TIME ZONE time_zone`
 
-func (p *BBashDB) GetActiveCampaigns(now time.Time) (activeCampaigns []types.CampaignStruct, err error) {
-	rows, err := p.db.Query(sqlSelectCurrentCampaigns, now)
+func (p *BBashDB) GetActiveCampaigns(ctx context.Context, now time.Time) (activeCampaigns []types.CampaignStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryDb().QueryContext(ctx, sqlSelectCurrentCampaigns, now)
 	if err != nil {
 		return
 	}
@@ -214,7 +530,7 @@ func (p *BBashDB) GetActiveCampaigns(now time.Time) (activeCampaigns []types.Cam
 	for rows.Next() {
 		activeCampaign := types.CampaignStruct{}
 
-		err = rows.Scan(&activeCampaign.ID, &activeCampaign.Name, &activeCampaign.CreatedOn, &activeCampaign.CreatedOrder, &activeCampaign.StartOn, &activeCampaign.EndOn, &activeCampaign.Note)
+		err = rows.Scan(&activeCampaign.ID, &activeCampaign.Name, &activeCampaign.CreatedOn, &activeCampaign.CreatedOrder, &activeCampaign.StartOn, &activeCampaign.EndOn, &activeCampaign.Note, &activeCampaign.MaxTeamSize, &activeCampaign.TieBreaker, &activeCampaign.UnclassifiedBonus, &activeCampaign.TimeZone, &activeCampaign.Status, &activeCampaign.FreezeScoring, &activeCampaign.RegistrationOpensOn, &activeCampaign.RegistrationClosesOn, &activeCampaign.Version)
 		if err != nil {
 			return
 		}
@@ -224,33 +540,178 @@ func (p *BBashDB) GetActiveCampaigns(now time.Time) (activeCampaigns []types.Cam
 	return
 }
 
+const sqlSelectUpcomingCampaigns = `SELECT ID, name, created_on, create_order, start_on AT TIME ZONE time_zone, end_on AT TIME ZONE time_zone, note, max_team_size, tie_breaker, unclassified_bonus, time_zone, status, freeze_scoring,
+		registration_opens_on AT TIME ZONE time_zone, registration_closes_on AT TIME ZONE time_zone, version FROM campaign
+		WHERE $1 < start_on AT TIME ZONE time_zone
+		ORDER BY start_on AT TIME ZONE time_zone`
+
+// GetUpcomingCampaigns reads the campaigns that start after now, the soonest first.
+func (p *BBashDB) GetUpcomingCampaigns(ctx context.Context, now time.Time) (upcomingCampaigns []types.CampaignStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectUpcomingCampaigns, now)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		campaign := types.CampaignStruct{}
+		err = rows.Scan(&campaign.ID, &campaign.Name, &campaign.CreatedOn, &campaign.CreatedOrder, &campaign.StartOn, &campaign.EndOn, &campaign.Note, &campaign.MaxTeamSize, &campaign.TieBreaker, &campaign.UnclassifiedBonus, &campaign.TimeZone, &campaign.Status, &campaign.FreezeScoring, &campaign.RegistrationOpensOn, &campaign.RegistrationClosesOn, &campaign.Version)
+		if err != nil {
+			return
+		}
+		upcomingCampaigns = append(upcomingCampaigns, campaign)
+	}
+	return
+}
+
+const sqlSelectCampaignTenant = `SELECT COALESCE(tenant.name, '')
+		FROM campaign
+		LEFT JOIN tenant ON tenant.Id = campaign.fk_tenant
+		WHERE campaign.name = $1`
+
+// SelectCampaignTenant reads the name of the tenant owning the campaign, empty for a campaign of no tenant.
+func (p *BBashDB) SelectCampaignTenant(ctx context.Context, campaignName string) (tenantName string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectCampaignTenant, campaignName).Scan(&tenantName)
+	return
+}
+
+const sqlSelectRegistrationWindow = `SELECT registration_opens_on AT TIME ZONE time_zone, registration_closes_on AT TIME ZONE time_zone
+		FROM campaign
+		WHERE name = $1`
+
+// SelectRegistrationWindow reads when participants may join the campaign. A nil bound leaves that side of the window
+// open. sql.ErrNoRows is returned when there is no such campaign.
+func (p *BBashDB) SelectRegistrationWindow(ctx context.Context, campaignName string) (opensOn, closesOn *time.Time, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectRegistrationWindow, campaignName).Scan(&opensOn, &closesOn)
+	return
+}
+
+const sqlInsertTenant = `INSERT INTO tenant
+		(name, admin_username, admin_password_hash)
+		VALUES ($1, $2, $3)
+		RETURNING Id, created_on`
+
+func (p *BBashDB) InsertTenant(ctx context.Context, tenant *types.TenantStruct, passwordHash string) (guid string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlInsertTenant, tenant.Name, tenant.AdminUsername, passwordHash).
+		Scan(&tenant.ID, &tenant.CreatedOn)
+	err = duplicateError(err, "tenant")
+	guid = tenant.ID
+	return
+}
+
+const sqlSelectTenants = `SELECT Id, name, admin_username, created_on FROM tenant ORDER BY name`
+
+func (p *BBashDB) SelectTenants(ctx context.Context) (tenants []types.TenantStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectTenants)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		tenant := types.TenantStruct{}
+		err = rows.Scan(&tenant.ID, &tenant.Name, &tenant.AdminUsername, &tenant.CreatedOn)
+		if err != nil {
+			return
+		}
+		tenants = append(tenants, tenant)
+	}
+	return
+}
+
+const sqlSelectTenantAdmin = `SELECT admin_username, admin_password_hash FROM tenant WHERE name = $1`
+
+// SelectTenantAdmin reads the admin credentials of the tenant, with the password as a bcrypt hash.
+func (p *BBashDB) SelectTenantAdmin(ctx context.Context, tenantName string) (username, passwordHash string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectTenantAdmin, tenantName).Scan(&username, &passwordHash)
+	return
+}
+
+const sqlSelectTenantCampaigns = `SELECT campaign.ID, campaign.name, campaign.created_on, campaign.create_order, campaign.start_on AT TIME ZONE campaign.time_zone,
+		campaign.end_on AT TIME ZONE campaign.time_zone, campaign.note, campaign.max_team_size, campaign.tie_breaker, campaign.unclassified_bonus,
+		campaign.time_zone, campaign.status, campaign.freeze_scoring, campaign.registration_opens_on AT TIME ZONE campaign.time_zone,
+		campaign.registration_closes_on AT TIME ZONE campaign.time_zone, tenant.name, campaign.version
+		FROM campaign
+		JOIN tenant ON tenant.Id = campaign.fk_tenant
+		WHERE tenant.name = $1
+		ORDER BY campaign.create_order`
+
+// SelectTenantCampaigns reads the campaigns owned by the tenant.
+func (p *BBashDB) SelectTenantCampaigns(ctx context.Context, tenantName string) (campaigns []types.CampaignStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectTenantCampaigns, tenantName)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		campaign := types.CampaignStruct{}
+		err = rows.Scan(&campaign.ID, &campaign.Name, &campaign.CreatedOn, &campaign.CreatedOrder, &campaign.StartOn, &campaign.EndOn, &campaign.Note, &campaign.MaxTeamSize, &campaign.TieBreaker, &campaign.UnclassifiedBonus, &campaign.TimeZone, &campaign.Status, &campaign.FreezeScoring, &campaign.RegistrationOpensOn, &campaign.RegistrationClosesOn, &campaign.TenantName, &campaign.Version)
+		if err != nil {
+			return
+		}
+		campaigns = append(campaigns, campaign)
+	}
+	return
+}
+
 const sqlInsertOrganization = `INSERT INTO organization
-		(fk_scp, organization)
-		VALUES ((SELECT id FROM source_control_provider WHERE name = $1), $2)
+		(fk_scp, organization, fk_campaign)
+		VALUES ((SELECT id FROM source_control_provider WHERE LOWER(name) = LOWER($1)), LOWER($2), (SELECT id FROM campaign WHERE name = $3))
 		RETURNING Id`
 
-func (p *BBashDB) InsertOrganization(organization *types.OrganizationStruct) (guid string, err error) {
-	err = p.db.QueryRow(sqlInsertOrganization, organization.SCPName, organization.Organization).
+// InsertOrganization adds an organization to every campaign, or to the one campaign named by the organization. The
+// caller checks that the campaign exists, as an unknown campaign name adds the organization to every campaign. The
+// name is stored in lower case, as source control providers do not distinguish organizations by case.
+func (p *BBashDB) InsertOrganization(ctx context.Context, organization *types.OrganizationStruct) (guid string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlInsertOrganization, organization.SCPName, organization.Organization, organization.CampaignName).
 		Scan(&guid)
+	err = duplicateError(err, "organization")
 	return
 }
 
 const sqlSelectOrganizations = `SELECT
 		organization.Id,
-        Name,
-        Organization
+        source_control_provider.Name,
+        Organization,
+        COALESCE(campaign.name, '')
 		FROM organization
-		INNER JOIN source_control_provider ON fk_scp = source_control_provider.Id`
+		INNER JOIN source_control_provider ON fk_scp = source_control_provider.Id
+		LEFT JOIN campaign ON organization.fk_campaign = campaign.Id`
+
+func (p *BBashDB) GetOrganizations(ctx context.Context) (organizations []types.OrganizationStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-func (p *BBashDB) GetOrganizations() (organizations []types.OrganizationStruct, err error) {
-	rows, err := p.db.Query(sqlSelectOrganizations)
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectOrganizations)
 	if err != nil {
 		return
 	}
 
 	for rows.Next() {
 		org := types.OrganizationStruct{}
-		err = rows.Scan(&org.ID, &org.SCPName, &org.Organization)
+		err = rows.Scan(&org.ID, &org.SCPName, &org.Organization, &org.CampaignName)
 		if err != nil {
 			return
 		}
@@ -260,11 +721,17 @@ func (p *BBashDB) GetOrganizations() (organizations []types.OrganizationStruct,
 }
 
 const sqlDeleteOrganization = `DELETE FROM organization
-	WHERE fk_scp = (SELECT id from source_control_provider WHERE name = $1) 
-	AND Organization = $2`
+	WHERE fk_scp = (SELECT id from source_control_provider WHERE LOWER(name) = LOWER($1)) 
+	AND LOWER(Organization) = LOWER($2)
+	AND fk_campaign IS NOT DISTINCT FROM (SELECT id FROM campaign WHERE name = $3)`
 
-func (p *BBashDB) DeleteOrganization(scpName, orgName string) (rowsAffected int64, err error) {
-	res, err := p.db.Exec(sqlDeleteOrganization, scpName, orgName)
+// DeleteOrganization removes the organization of the campaign, or the organization of every campaign when campaignName
+// is empty.
+func (p *BBashDB) DeleteOrganization(ctx context.Context, scpName, orgName, campaignName string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.retryDb().ExecContext(ctx, sqlDeleteOrganization, scpName, orgName, campaignName)
 	if err != nil {
 		return
 	}
@@ -272,12 +739,73 @@ func (p *BBashDB) DeleteOrganization(scpName, orgName string) (rowsAffected int6
 	return
 }
 
+const sqlUpdateOrganization = `UPDATE organization
+	SET Organization = LOWER($3)
+	WHERE fk_scp = (SELECT id from source_control_provider WHERE LOWER(name) = LOWER($1))
+	AND LOWER(Organization) = LOWER($2)`
+
+const sqlRelinkScoringEvents = `UPDATE scoring_event
+	SET repoOwner = $3
+	WHERE fk_scp = (SELECT id from source_control_provider WHERE LOWER(name) = LOWER($1))
+	AND LOWER(repoOwner) = LOWER($2)`
+
+// UpdateOrganization renames the organization in place, in every campaign it is scored in, so its id and anything
+// linked to it are kept. When asked, the past scoring events of the old name are moved to the new name in the same
+// transaction.
+func (p *BBashDB) UpdateOrganization(ctx context.Context, scpName, orgName string, update *types.OrganizationUpdate) (rowsAffected, eventsRelinked int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.retryDb().BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, sqlUpdateOrganization, scpName, orgName, update.Organization)
+	if err != nil {
+		err = duplicateError(err, "organization")
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+
+	if rowsAffected > 0 && update.RelinkScores {
+		res, err = tx.ExecContext(ctx, sqlRelinkScoringEvents, scpName, orgName, update.Organization)
+		if err != nil {
+			err = duplicateError(err, "scoring event")
+			return
+		}
+		eventsRelinked, err = res.RowsAffected()
+		if err != nil {
+			return
+		}
+	}
+
+	err = tx.Commit()
+	return
+}
+
 const sqlSelectOrganizationExists = `SELECT EXISTS(
 		SELECT Id FROM organization
-		WHERE fk_scp = (SELECT id from source_control_provider WHERE LOWER(name) = $1) AND Organization = $2)`
+		WHERE fk_scp = (SELECT id from source_control_provider WHERE LOWER(name) = LOWER($1))
+			AND LOWER(Organization) = LOWER($2)
+			AND (fk_campaign IS NULL
+				OR fk_campaign IN (SELECT Id FROM campaign WHERE $3 >= start_on AT TIME ZONE time_zone AND $3 < end_on AT TIME ZONE time_zone
+					AND NOT (freeze_scoring AND status = 'ended'))))`
+
+// ValidOrganization checks the organization of the message is scored by any campaign active at now.
+func (p *BBashDB) ValidOrganization(ctx context.Context, msg *types.ScoringMessage, now time.Time) (orgExists bool, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-func (p *BBashDB) ValidOrganization(msg *types.ScoringMessage) (orgExists bool, err error) {
-	row := p.db.QueryRow(sqlSelectOrganizationExists, msg.EventSource, msg.RepoOwner)
+	row := p.retryDb().QueryRowContext(ctx, sqlSelectOrganizationExists, msg.EventSource, msg.RepoOwner, now)
 	err = row.Scan(&orgExists)
 	if err != nil {
 		p.logger.Error("organization read error", zap.Any("msg", msg), zap.Error(err))
@@ -296,15 +824,23 @@ const sqlSelectParticipantId = `SELECT
 		INNER JOIN campaign ON campaign.Id = fk_campaign
 		INNER JOIN source_control_provider ON source_control_provider.Id = fk_scp
 		LEFT JOIN team ON team.Id = participant.fk_team
-		WHERE $1 >= campaign.start_on
-			AND $1 < campaign.end_on
-		    AND LOWER(source_control_provider.name) = $2 
-			AND login_name = $3`
+		WHERE $1 >= campaign.start_on AT TIME ZONE campaign.time_zone
+			AND $1 < campaign.end_on AT TIME ZONE campaign.time_zone
+			AND NOT (campaign.freeze_scoring AND campaign.status = 'ended')
+		    AND LOWER(source_control_provider.name) = LOWER($2) 
+			AND login_name = $3
+			AND EXISTS(SELECT organization.Id FROM organization
+				WHERE organization.fk_scp = source_control_provider.Id
+					AND LOWER(organization.Organization) = LOWER($4)
+					AND (organization.fk_campaign IS NULL OR organization.fk_campaign = campaign.Id))`
+
+func (p *BBashDB) SelectParticipantsToScore(ctx context.Context, msg *types.ScoringMessage, now time.Time) (participantsToScore []types.ParticipantStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-func (p *BBashDB) SelectParticipantsToScore(msg *types.ScoringMessage, now time.Time) (participantsToScore []types.ParticipantStruct, err error) {
 	// Check if participant is registered for an active campaign
 	var rows *sql.Rows
-	rows, err = p.db.Query(sqlSelectParticipantId, now, msg.EventSource, msg.TriggerUser)
+	rows, err = p.retryDb().QueryContext(ctx, sqlSelectParticipantId, now, msg.EventSource, msg.TriggerUser, msg.RepoOwner)
 	if err != nil {
 		p.logger.Error("skip score-error reading participant", zap.Any("msg", msg), zap.Error(err))
 		return
@@ -326,13 +862,29 @@ func (p *BBashDB) SelectParticipantsToScore(msg *types.ScoringMessage, now time.
 	return
 }
 
-const sqlSelectPointValue = `SELECT pointValue FROM bug 
-	INNER JOIN campaign ON campaign.Id = fk_campaign	
-	WHERE fk_campaign = (SELECT campaign.Id FROM campaign WHERE name = $1) 
-	  AND category = $2`
+// sqlSelectPointValue prefers an override for the organization of the repository to the campaign point value
+const sqlSelectPointValue = `SELECT pointValue FROM (
+		SELECT point_value_override.point_value AS pointValue, 0 AS precedence
+			FROM point_value_override
+			INNER JOIN organization ON organization.Id = point_value_override.fk_organization
+			INNER JOIN source_control_provider ON source_control_provider.Id = organization.fk_scp
+			WHERE point_value_override.fk_campaign = (SELECT Id FROM campaign WHERE name = $1)
+			  AND point_value_override.category = $2
+			  AND LOWER(source_control_provider.name) = LOWER($3)
+			  AND LOWER(organization.Organization) = LOWER($4)
+			  AND (organization.fk_campaign IS NULL OR organization.fk_campaign = point_value_override.fk_campaign)
+		UNION ALL
+		SELECT pointValue, 1 FROM bug
+			WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = $1)
+			  AND category = $2) point_values
+		ORDER BY precedence
+		LIMIT 1`
+
+func (p *BBashDB) SelectPointValue(ctx context.Context, msg *types.ScoringMessage, campaignName, bugType string) (pointValue float64) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-func (p *BBashDB) SelectPointValue(msg *types.ScoringMessage, campaignName, bugType string) (pointValue float64) {
-	row := p.db.QueryRow(sqlSelectPointValue, campaignName, bugType)
+	row := p.retryDb().QueryRowContext(ctx, sqlSelectPointValue, campaignName, bugType, msg.EventSource, msg.RepoOwner)
 	pointValue = 1
 	if err := row.Scan(&pointValue); err != nil {
 		// ignore error from scan operation
@@ -347,10 +899,12 @@ const sqlUpdateParticipantScore = `UPDATE participant
 		WHERE id = $2 
 		RETURNING Score`
 
-func (p *BBashDB) UpdateParticipantScore(participant *types.ParticipantStruct, delta float64) (err error) {
-	var score int
-	row := p.db.QueryRow(sqlUpdateParticipantScore, delta, participant.ID)
-	err = row.Scan(&score)
+func (p *BBashDB) UpdateParticipantScore(ctx context.Context, participant *types.ParticipantStruct, delta float64) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	row := p.retryDb().QueryRowContext(ctx, sqlUpdateParticipantScore, delta, participant.ID)
+	err = row.Scan(&participant.Score)
 	return
 }
 
@@ -360,10 +914,14 @@ const sqlScoreQuery = `SELECT points
 			    AND fk_scp = (SELECT id FROM source_control_provider WHERE name = $2)
 			    AND repoOwner = $3
 				AND repoName = $4
-				AND pr = $5`
+				AND pr = $5
+				AND voided_on IS NULL`
 
-func (p *BBashDB) SelectPriorScore(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64) {
-	row := p.db.QueryRow(sqlScoreQuery, participantToScore.CampaignName, participantToScore.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest)
+func (p *BBashDB) SelectPriorScore(ctx context.Context, participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	row := p.retryDb().QueryRowContext(ctx, sqlScoreQuery, participantToScore.CampaignName, participantToScore.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest)
 	oldPoints = 0
 	err := row.Scan(&oldPoints)
 	if err != nil {
@@ -373,219 +931,3895 @@ func (p *BBashDB) SelectPriorScore(participantToScore *types.ParticipantStruct,
 	return
 }
 
-const sqlInsertScoringEvent = `INSERT INTO scoring_event
-			(fk_campaign, fk_scp, repoOwner, repoName, pr, username, points)
-			VALUES ((SELECT id FROM campaign WHERE name = $1), 
-			        (SELECT id FROM source_control_provider WHERE name = $2),
-			        $3, $4, $5, $6, $7)
-			ON CONFLICT (fk_campaign, fk_scp, repoOwner, repoName, pr) DO
-				UPDATE SET points = $7`
-
-func (p *BBashDB) InsertScoringEvent(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64) (err error) {
-	_, err = p.db.Exec(sqlInsertScoringEvent, participantToScore.CampaignName, participantToScore.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, msg.TriggerUser, newPoints)
-	return
-}
+const sqlSelectCoAuthorPriorScore = `SELECT coauthor.points
+			FROM scoring_event_coauthor coauthor
+			INNER JOIN scoring_event ON coauthor.fk_scoring_event = scoring_event.Id
+			WHERE scoring_event.fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+			    AND scoring_event.fk_scp = (SELECT id FROM source_control_provider WHERE name = $2)
+			    AND scoring_event.repoOwner = $3
+				AND scoring_event.repoName = $4
+				AND scoring_event.pr = $5
+				AND scoring_event.voided_on IS NULL
+				AND coauthor.username = $6`
 
-const sqlInsertParticipant = `INSERT INTO participant 
-		(fk_scp, fk_campaign, login_name, Email, DisplayName, Score) 
-		VALUES ((SELECT Id FROM source_control_provider WHERE Name = $1),
-		        (SELECT Id FROM campaign WHERE name = $2),
-		        $3, $4, $5, $6)
-		RETURNING Id, Score, JoinedAt`
+// SelectCoAuthorPriorScore reads the points the co-author scored by an earlier scoring of the pull request.
+func (p *BBashDB) SelectCoAuthorPriorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-func (p *BBashDB) InsertParticipant(participant *types.ParticipantStruct) (err error) {
-	err = p.db.QueryRow(
-		sqlInsertParticipant,
-		participant.ScpName,
-		participant.CampaignName,
-		participant.LoginName,
-		participant.Email,
-		participant.DisplayName,
-		0,
-	).Scan(&participant.ID, &participant.Score, &participant.JoinedAt)
+	row := p.retryDb().QueryRowContext(ctx, sqlSelectCoAuthorPriorScore, coAuthor.CampaignName, coAuthor.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, coAuthor.LoginName)
+	oldPoints = 0
+	err := row.Scan(&oldPoints)
 	if err != nil {
-		p.logger.Error("error inserting participant", zap.Any("participant", participant), zap.Error(err))
+		// ignore error case from scan when no row exists, will occur when this is a new co-author
+		p.logger.Debug("ignoring likely new co-author", zap.Error(err), zap.Any("ScoringMessage", msg))
 	}
 	return
 }
 
-const sqlInsertTeam = `INSERT INTO team
-		(fk_campaign, name)
-		VALUES ((SELECT id FROM campaign WHERE name = $1), $2)
-		RETURNING Id`
+const sqlInsertCoAuthorScore = `INSERT INTO scoring_event_coauthor
+			(fk_scoring_event, username, points)
+			SELECT Id, $6, $7
+				FROM scoring_event
+				WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+				  AND fk_scp = (SELECT id FROM source_control_provider WHERE name = $2)
+				  AND repoOwner = $3
+				  AND repoName = $4
+				  AND pr = $5
+			ON CONFLICT (fk_scoring_event, username) DO
+				UPDATE SET points = $7`
 
-func (p *BBashDB) InsertTeam(team *types.TeamStruct) (err error) {
-	err = p.db.QueryRow(
-		sqlInsertTeam,
-		team.CampaignName,
-		team.Name).Scan(&team.Id)
+// InsertCoAuthorScore records the points the co-author scored for the pull request, which must already have its
+// scoring event.
+func (p *BBashDB) InsertCoAuthorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err = p.retryDb().ExecContext(ctx, sqlInsertCoAuthorScore, coAuthor.CampaignName, coAuthor.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, coAuthor.LoginName, newPoints)
 	return
 }
 
-const sqlSelectParticipantDetail = `SELECT 
-		participant.Id, campaign.name, source_control_provider.name, login_name, Email, DisplayName, Score, team.name, JoinedAt
-		FROM participant
-		LEFT JOIN team ON team.Id = participant.fk_team
-		INNER JOIN campaign ON campaign.Id = participant.fk_campaign
-		INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
-		WHERE campaign.name = $1
-		  AND source_control_provider.name = $2 
-		  AND participant.login_name = $3`
+const sqlSelectCoAuthorScores = `SELECT participant.Id, participant.login_name,
+				COALESCE((SELECT name FROM team WHERE Id = participant.fk_team), ''), coauthor.points
+			FROM scoring_event_coauthor coauthor
+			INNER JOIN scoring_event ON coauthor.fk_scoring_event = scoring_event.Id
+			INNER JOIN participant ON participant.fk_campaign = scoring_event.fk_campaign
+				AND participant.fk_scp = scoring_event.fk_scp
+				AND participant.login_name = coauthor.username
+			WHERE scoring_event.fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+			    AND scoring_event.fk_scp = (SELECT id FROM source_control_provider WHERE name = $2)
+			    AND scoring_event.repoOwner = $3
+				AND scoring_event.repoName = $4
+				AND scoring_event.pr = $5
+				AND scoring_event.voided_on IS NULL`
 
-func (p *BBashDB) SelectParticipantDetail(campaignName, scpName, loginName string) (participant *types.ParticipantStruct, err error) {
-	row := p.db.QueryRow(sqlSelectParticipantDetail, campaignName, scpName, loginName)
+// SelectCoAuthorScores reads the shares the co-authors scored by an earlier scoring of the pull request, with the
+// participants they are. A co-author no longer in the campaign is left out, as there is no score to correct.
+func (p *BBashDB) SelectCoAuthorScores(ctx context.Context, participant *types.ParticipantStruct, msg *types.ScoringMessage) (coAuthors []CoAuthorScoreRow, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-	participant = new(types.ParticipantStruct)
-	var nullableTeamName sql.NullString
-	err = row.Scan(&participant.ID,
-		&participant.CampaignName,
-		&participant.ScpName,
-		&participant.LoginName,
-		&participant.Email,
-		&participant.DisplayName,
-		&participant.Score,
-		&nullableTeamName,
-		&participant.JoinedAt,
-	)
+	rows, err := p.retryDb().QueryContext(ctx, sqlSelectCoAuthorScores, participant.CampaignName, participant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest)
 	if err != nil {
-		p.logger.Error("getParticipantDetail scan error", zap.Error(err))
 		return
 	}
-	if nullableTeamName.Valid {
-		participant.TeamName = nullableTeamName.String
+	defer rows.Close()
+
+	for rows.Next() {
+		coAuthor := CoAuthorScoreRow{Participant: types.ParticipantStruct{CampaignName: participant.CampaignName, ScpName: participant.ScpName}}
+		err = rows.Scan(&coAuthor.Participant.ID, &coAuthor.Participant.LoginName, &coAuthor.Participant.TeamName, &coAuthor.Points)
+		if err != nil {
+			return
+		}
+		coAuthors = append(coAuthors, coAuthor)
 	}
+	err = rows.Err()
 	return
 }
 
-const sqlSelectParticipantsByCampaign = `SELECT
-		participant.Id, campaign.name, source_control_provider.name, login_name, Email, DisplayName, Score, team.name, JoinedAt 
-		FROM participant
-		LEFT JOIN team ON participant.fk_team = team.Id
-		INNER JOIN campaign ON participant.fk_campaign = campaign.Id
-		INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
-		WHERE campaign.name = $1`
+const sqlDeleteCoAuthorScore = `DELETE FROM scoring_event_coauthor
+			WHERE username = $6
+				AND fk_scoring_event IN (SELECT Id
+					FROM scoring_event
+					WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+					  AND fk_scp = (SELECT id FROM source_control_provider WHERE name = $2)
+					  AND repoOwner = $3
+					  AND repoName = $4
+					  AND pr = $5)`
 
-func (p *BBashDB) SelectParticipantsInCampaign(campaignName string) (participants []types.ParticipantStruct, err error) {
-	rows, err := p.db.Query(sqlSelectParticipantsByCampaign, campaignName)
-	if err != nil {
-		return
-	}
+// DeleteCoAuthorScore removes the share of a co-author who is no longer one of the pull request.
+func (p *BBashDB) DeleteCoAuthorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
 
-	for rows.Next() {
-		participant := new(types.ParticipantStruct)
-		var nullableTeamName sql.NullString
-		err = rows.Scan(
-			&participant.ID,
-			&participant.CampaignName,
-			&participant.ScpName,
-			&participant.LoginName,
-			&participant.Email,
-			&participant.DisplayName,
+	_, err = p.retryDb().ExecContext(ctx, sqlDeleteCoAuthorScore, coAuthor.CampaignName, coAuthor.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, coAuthor.LoginName)
+	return
+}
+
+// a voided event that is scored again counts afresh, for the participant of the new score
+const sqlInsertScoringEvent = `INSERT INTO scoring_event
+			(fk_campaign, fk_scp, repoOwner, repoName, pr, username, points, breakdown)
+			VALUES ((SELECT id FROM campaign WHERE name = $1), 
+			        (SELECT id FROM source_control_provider WHERE name = $2),
+			        $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (fk_campaign, fk_scp, repoOwner, repoName, pr) DO
+				UPDATE SET points = $7, breakdown = $8, scored_on = NOW(),
+					username = CASE WHEN scoring_event.voided_on IS NULL THEN scoring_event.username ELSE $6 END,
+					voided_on = NULL`
+
+// InsertScoringEvent inserts the event, or scores its pull request again. The fingerprints of the message are added to
+// those of the event, in the same transaction.
+func (p *BBashDB) InsertScoringEvent(ctx context.Context, participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64, breakdown *types.ScoreBreakdown) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var breakdownJson []byte
+	if breakdown != nil {
+		breakdownJson, err = json.Marshal(breakdown)
+		if err != nil {
+			return
+		}
+	}
+	if len(msg.Fingerprints) == 0 {
+		_, err = p.retryDb().ExecContext(ctx, sqlInsertScoringEvent, participantToScore.CampaignName, participantToScore.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, msg.TriggerUser, newPoints, breakdownJson)
+		return
+	}
+
+	tx, err := p.retryDb().BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, sqlInsertScoringEvent, participantToScore.CampaignName, participantToScore.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, msg.TriggerUser, newPoints, breakdownJson)
+	if err != nil {
+		return
+	}
+	if err = insertFingerprints(ctx, tx, participantToScore, msg); err != nil {
+		return
+	}
+	err = tx.Commit()
+	return
+}
+
+// a fingerprint the event fixed before keeps when it was first fixed
+const sqlInsertScoringEventFingerprints = `INSERT INTO scoring_event_fingerprint
+			(fk_scoring_event, fingerprint)
+			SELECT scoring_event.Id, fingerprint
+				FROM scoring_event, unnest($6::text[]) AS fingerprint
+				WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+				  AND fk_scp = (SELECT id FROM source_control_provider WHERE name = $2)
+				  AND repoOwner = $3
+				  AND repoName = $4
+				  AND pr = $5
+			ON CONFLICT (fk_scoring_event, fingerprint) DO NOTHING`
+
+// insertFingerprints adds the fingerprints of the message to the scoring event of its pull request. A message without
+// fingerprints leaves those the event has.
+func insertFingerprints(ctx context.Context, tx *sql.Tx, participant *types.ParticipantStruct, msg *types.ScoringMessage) (err error) {
+	if len(msg.Fingerprints) == 0 {
+		return
+	}
+	_, err = tx.ExecContext(ctx, sqlInsertScoringEventFingerprints, participant.CampaignName, participant.ScpName,
+		msg.RepoOwner, msg.RepoName, msg.PullRequest, pq.Array(msg.Fingerprints))
+	return
+}
+
+// a fingerprint the pull request fixed no later than the other participant is not a duplicate, so scoring the first
+// fix again does not turn it into one
+const sqlSelectDuplicateFingerprints = `SELECT DISTINCT other.fingerprint
+		FROM scoring_event_fingerprint other
+		INNER JOIN scoring_event ON other.fk_scoring_event = scoring_event.Id
+		WHERE scoring_event.fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		  AND scoring_event.fk_scp = (SELECT id FROM source_control_provider WHERE name = $2)
+		  AND scoring_event.repoOwner = $3
+		  AND scoring_event.repoName = $4
+		  AND scoring_event.username <> $6
+		  AND scoring_event.voided_on IS NULL
+		  AND other.fingerprint = ANY($7)
+		  AND NOT EXISTS (SELECT 1
+				FROM scoring_event_fingerprint own
+				INNER JOIN scoring_event own_event ON own.fk_scoring_event = own_event.Id
+				WHERE own_event.fk_campaign = scoring_event.fk_campaign
+				  AND own_event.fk_scp = scoring_event.fk_scp
+				  AND own_event.repoOwner = $3
+				  AND own_event.repoName = $4
+				  AND own_event.pr = $5
+				  AND own.fingerprint = other.fingerprint
+				  AND own.fixed_on <= other.fixed_on)
+		ORDER BY other.fingerprint`
+
+// SelectDuplicateFingerprints reads the fingerprints of the message that another participant of the campaign fixed
+// first, in the same repository, by a scoring event that still counts.
+func (p *BBashDB) SelectDuplicateFingerprints(ctx context.Context, participant *types.ParticipantStruct, msg *types.ScoringMessage) (duplicates []string, err error) {
+	if len(msg.Fingerprints) == 0 {
+		return
+	}
+
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryDb().QueryContext(ctx, sqlSelectDuplicateFingerprints, participant.CampaignName, participant.ScpName,
+		msg.RepoOwner, msg.RepoName, msg.PullRequest, participant.LoginName, pq.Array(msg.Fingerprints))
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		var fingerprint string
+		if err = rows.Scan(&fingerprint); err != nil {
+			return
+		}
+		duplicates = append(duplicates, fingerprint)
+	}
+	return
+}
+
+const sqlSelectScoringEventsSince = `SELECT repoOwner, repoName, pr, username, points
+		FROM scoring_event
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		  AND fk_scp = (SELECT id FROM source_control_provider WHERE name = $2)
+		  AND username = $3
+		  AND scored_on >= $4
+		  AND voided_on IS NULL
+		UNION ALL
+		SELECT scoring_event.repoOwner, scoring_event.repoName, scoring_event.pr, scoring_event.username, coauthor.points
+		FROM scoring_event_coauthor coauthor
+		INNER JOIN scoring_event ON coauthor.fk_scoring_event = scoring_event.Id
+		WHERE scoring_event.fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		  AND scoring_event.fk_scp = (SELECT id FROM source_control_provider WHERE name = $2)
+		  AND coauthor.username = $3
+		  AND scoring_event.scored_on >= $4
+		  AND scoring_event.voided_on IS NULL`
+
+// SelectScoringEventsSince reads the scoring events of the participant scored since the time, e.g. the start of a day,
+// with the shares of the pull requests they co-authored as events of their own.
+func (p *BBashDB) SelectScoringEventsSince(ctx context.Context, participant *types.ParticipantStruct, since time.Time) (events []ScoringEventRow, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectScoringEventsSince, participant.CampaignName, participant.ScpName, participant.LoginName, since)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		event := ScoringEventRow{Participant: *participant}
+		err = rows.Scan(&event.Msg.RepoOwner, &event.Msg.RepoName, &event.Msg.PullRequest, &event.Msg.TriggerUser, &event.NewPoints)
+		if err != nil {
+			return
+		}
+		events = append(events, event)
+	}
+	return
+}
+
+// ScoringEventRow is a scoring event to insert, with the arguments of InsertScoringEvent.
+type ScoringEventRow struct {
+	Participant types.ParticipantStruct
+	Msg         types.ScoringMessage
+	NewPoints   float64
+	Breakdown   *types.ScoreBreakdown
+}
+
+// CoAuthorScoreRow is the share of a pull request a co-author scored, with the participant they are
+type CoAuthorScoreRow struct {
+	Participant types.ParticipantStruct
+	Points      float64
+}
+
+// ParticipantScoreDelta is a change of the score of a participant, applied with the scoring events inserted with it
+type ParticipantScoreDelta struct {
+	ParticipantId string
+	Delta         float64
+}
+
+// scoringEventsPerInsert keeps the arguments of one insert under the postgres limit of 65535
+const scoringEventsPerInsert = 1000
+
+const scoringEventArgs = 8
+
+const sqlInsertScoringEventsPrefix = `INSERT INTO scoring_event
+			(fk_campaign, fk_scp, repoOwner, repoName, pr, username, points, breakdown)
+			VALUES `
+
+const sqlInsertScoringEventsSuffix = `
+			ON CONFLICT (fk_campaign, fk_scp, repoOwner, repoName, pr) DO
+				UPDATE SET points = EXCLUDED.points, breakdown = EXCLUDED.breakdown, scored_on = NOW(),
+					username = CASE WHEN scoring_event.voided_on IS NULL THEN scoring_event.username ELSE EXCLUDED.username END,
+					voided_on = NULL`
+
+// sqlInsertScoringEvents is the insert of rowCount scoring events
+func sqlInsertScoringEvents(rowCount int) string {
+	values := make([]string, rowCount)
+	for i := range values {
+		arg := i * scoringEventArgs
+		values[i] = fmt.Sprintf(`((SELECT id FROM campaign WHERE name = $%d), 
+			        (SELECT id FROM source_control_provider WHERE name = $%d),
+			        $%d, $%d, $%d, $%d, $%d, $%d)`, arg+1, arg+2, arg+3, arg+4, arg+5, arg+6, arg+7, arg+8)
+	}
+	return sqlInsertScoringEventsPrefix + strings.Join(values, ",\n\t\t\t") + sqlInsertScoringEventsSuffix
+}
+
+// InsertScoringEvents inserts the events with a statement per scoringEventsPerInsert events, rather than one each, in a
+// single transaction, which also applies the score changes. An event may not repeat the pull request of another, as a
+// statement can not update a row twice.
+func (p *BBashDB) InsertScoringEvents(ctx context.Context, events []ScoringEventRow, scoreDeltas []ParticipantScoreDelta) (err error) {
+	if len(events) == 0 && len(scoreDeltas) == 0 {
+		return
+	}
+
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.retryDb().BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for start := 0; start < len(events); start += scoringEventsPerInsert {
+		end := start + scoringEventsPerInsert
+		if end > len(events) {
+			end = len(events)
+		}
+
+		args := make([]interface{}, 0, (end-start)*scoringEventArgs)
+		for _, event := range events[start:end] {
+			var breakdownJson []byte
+			if event.Breakdown != nil {
+				breakdownJson, err = json.Marshal(event.Breakdown)
+				if err != nil {
+					return
+				}
+			}
+			args = append(args, event.Participant.CampaignName, event.Participant.ScpName, event.Msg.RepoOwner,
+				event.Msg.RepoName, event.Msg.PullRequest, event.Msg.TriggerUser, event.NewPoints, breakdownJson)
+		}
+
+		_, err = tx.ExecContext(ctx, sqlInsertScoringEvents(end-start), args...)
+		if err != nil {
+			return
+		}
+	}
+	for i := range events {
+		if err = insertFingerprints(ctx, tx, &events[i].Participant, &events[i].Msg); err != nil {
+			return
+		}
+	}
+	for _, scoreDelta := range scoreDeltas {
+		_, err = tx.ExecContext(ctx, sqlUpdateParticipantScore, scoreDelta.Delta, scoreDelta.ParticipantId)
+		if err != nil {
+			return
+		}
+	}
+
+	err = tx.Commit()
+	return
+}
+
+const sqlSelectScoringEvent = `SELECT scoring_event.Id, campaign.name, source_control_provider.name, repoOwner, repoName, pr, username,
+				points, breakdown, voided_on
+			FROM scoring_event
+			INNER JOIN campaign ON scoring_event.fk_campaign = campaign.Id
+			INNER JOIN source_control_provider ON scoring_event.fk_scp = source_control_provider.Id
+			WHERE campaign.name = $1
+			    AND source_control_provider.name = $2
+			    AND repoOwner = $3
+				AND repoName = $4
+				AND pr = $5`
+
+// SelectScoringEvent reads a scoring event and its breakdown, voided or not. The breakdown is nil for events scored
+// before breakdowns were recorded.
+func (p *BBashDB) SelectScoringEvent(ctx context.Context, campaignName, scpName, repoOwner, repoName string, pullRequest int) (event *types.ScoringEventStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	event = &types.ScoringEventStruct{}
+	var breakdownJson []byte
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectScoringEvent, campaignName, scpName, repoOwner, repoName, pullRequest).
+		Scan(&event.ID, &event.CampaignName, &event.ScpName, &event.RepoOwner, &event.RepoName, &event.PullRequest,
+			&event.UserName, &event.Points, &breakdownJson, &event.VoidedOn)
+	if err != nil {
+		return
+	}
+
+	if breakdownJson != nil {
+		event.Breakdown = &types.ScoreBreakdown{}
+		err = json.Unmarshal(breakdownJson, event.Breakdown)
+	}
+	return
+}
+
+const sqlExportScoringEvents = `SELECT scoring_event.Id, campaign.name, source_control_provider.name, repoOwner, repoName, pr,
+				username, points, breakdown, voided_on, scored_on
+			FROM scoring_event
+			INNER JOIN campaign ON scoring_event.fk_campaign = campaign.Id
+			INNER JOIN source_control_provider ON scoring_event.fk_scp = source_control_provider.Id
+			WHERE campaign.name = $1
+			ORDER BY scored_on, scoring_event.Id`
+
+// ExportScoringEvents reads every scoring event of the campaign, voided or not, in the order they were scored, passing
+// each to the func as it is read rather than holding them all. An error of the func stops the export. There is no
+// statement timeout, as an export of a large campaign takes as long as the func takes to pass the events on.
+func (p *BBashDB) ExportScoringEvents(ctx context.Context, campaignName string, each func(event *types.ScoringEventStruct) error) (err error) {
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlExportScoringEvents, campaignName)
+	if err != nil {
+		return
+	}
+	return readExportedEvents(rows, each)
+}
+
+// readExportedEvents passes each event the rows of sqlExportScoringEvents read to the func, then closes the rows.
+func readExportedEvents(rows *sql.Rows, each func(event *types.ScoringEventStruct) error) (err error) {
+	defer rows.Close()
+
+	for rows.Next() {
+		event := types.ScoringEventStruct{}
+		var breakdownJson []byte
+		var scoredOn sql.NullTime
+		err = rows.Scan(&event.ID, &event.CampaignName, &event.ScpName, &event.RepoOwner, &event.RepoName, &event.PullRequest,
+			&event.UserName, &event.Points, &breakdownJson, &event.VoidedOn, &scoredOn)
+		if err != nil {
+			return
+		}
+		if breakdownJson != nil {
+			event.Breakdown = &types.ScoreBreakdown{}
+			if err = json.Unmarshal(breakdownJson, event.Breakdown); err != nil {
+				return
+			}
+		}
+		if scoredOn.Valid {
+			event.ScoredOn = &scoredOn.Time
+		}
+		if err = each(&event); err != nil {
+			return
+		}
+	}
+	err = rows.Err()
+	return
+}
+
+// importedParticipant is a participant whose score an import recomputes
+type importedParticipant struct {
+	CampaignName string
+	ScpName      string
+	LoginName    string
+}
+
+// addImportedParticipant adds the participant of the event, unless an earlier event of theirs added them already.
+func addImportedParticipant(participants []importedParticipant, seen map[importedParticipant]bool, event *types.ScoringEventStruct) []importedParticipant {
+	participant := importedParticipant{CampaignName: event.CampaignName, ScpName: event.ScpName, LoginName: event.UserName}
+	if seen[participant] {
+		return participants
+	}
+	seen[participant] = true
+	return append(participants, participant)
+}
+
+// an event of a pull request already scored is left as it is, so an import can be run again
+const sqlImportScoringEvent = `INSERT INTO scoring_event
+			(fk_campaign, fk_scp, repoOwner, repoName, pr, username, points, breakdown, voided_on, scored_on)
+			VALUES ((SELECT id FROM campaign WHERE name = $1),
+			        (SELECT id FROM source_control_provider WHERE name = $2),
+			        $3, $4, $5, $6, $7, $8, $9, COALESCE($10, NOW()))
+			ON CONFLICT (fk_campaign, fk_scp, repoOwner, repoName, pr) DO NOTHING`
+
+const sqlRecomputeParticipantScore = `UPDATE participant
+			SET Score = COALESCE((SELECT SUM(scoring_event.points)
+					FROM scoring_event
+					WHERE scoring_event.fk_campaign = participant.fk_campaign
+						AND scoring_event.fk_scp = participant.fk_scp
+						AND scoring_event.username = participant.login_name
+						AND scoring_event.voided_on IS NULL), 0)
+				+ COALESCE((SELECT SUM(coauthor.points)
+					FROM scoring_event_coauthor coauthor
+					INNER JOIN scoring_event ON coauthor.fk_scoring_event = scoring_event.Id
+					WHERE scoring_event.fk_campaign = participant.fk_campaign
+						AND scoring_event.fk_scp = participant.fk_scp
+						AND coauthor.username = participant.login_name
+						AND scoring_event.voided_on IS NULL), 0)
+			WHERE participant.fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+				AND participant.fk_scp = (SELECT id FROM source_control_provider WHERE name = $2)
+				AND participant.login_name = $3`
+
+// ErrCampaignArchived is returned by an import into a campaign whose scoring events are archived, as recomputing the
+// scores from the events left in the campaign would drop the archived points.
+var ErrCampaignArchived = errors.New("scoring events of the campaign are archived")
+
+// locks the archive of the campaign, so it is not archived while the import recomputes its scores
+const sqlSelectCampaignArchived = `SELECT scoring_event_archive.fk_campaign
+			FROM scoring_event_archive
+			INNER JOIN campaign ON scoring_event_archive.fk_campaign = campaign.Id
+			WHERE campaign.name = $1
+				AND scoring_event_archive.restored_on IS NULL
+			FOR SHARE OF scoring_event_archive`
+
+// ImportScoringEvents inserts the events that are not already scored, in a single transaction, keeping when each was
+// scored and voided. The score of each participant with an imported event is then recomputed from their events that
+// are not voided, and their co-author shares. The recomputed count leaves out participants not in the campaign.
+// ErrCampaignArchived is returned, and nothing imported, when the events of a campaign are archived. There is no
+// statement timeout, as a large import takes a while.
+func (p *BBashDB) ImportScoringEvents(ctx context.Context, events []types.ScoringEventStruct) (imported, recomputed int64, err error) {
+	if len(events) == 0 {
+		return
+	}
+
+	tx, err := p.retryDb().BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	checked := map[string]bool{}
+	for i := range events {
+		if checked[events[i].CampaignName] {
+			continue
+		}
+		checked[events[i].CampaignName] = true
+		var campaignId string
+		err = tx.QueryRowContext(ctx, sqlSelectCampaignArchived, events[i].CampaignName).Scan(&campaignId)
+		if err == nil {
+			err = ErrCampaignArchived
+			return
+		} else if err != sql.ErrNoRows {
+			return
+		}
+		err = nil
+	}
+
+	var affected []importedParticipant
+	seen := map[importedParticipant]bool{}
+	for i := range events {
+		event := &events[i]
+		var breakdownJson []byte
+		if event.Breakdown != nil {
+			if breakdownJson, err = json.Marshal(event.Breakdown); err != nil {
+				return
+			}
+		}
+		var res sql.Result
+		res, err = tx.ExecContext(ctx, sqlImportScoringEvent, event.CampaignName, event.ScpName, event.RepoOwner,
+			event.RepoName, event.PullRequest, event.UserName, event.Points, breakdownJson, event.VoidedOn, event.ScoredOn)
+		if err != nil {
+			return
+		}
+		var rowsAffected int64
+		if rowsAffected, err = res.RowsAffected(); err != nil {
+			return
+		}
+		if rowsAffected > 0 {
+			imported++
+			affected = addImportedParticipant(affected, seen, event)
+		}
+	}
+
+	for _, participant := range affected {
+		var res sql.Result
+		res, err = tx.ExecContext(ctx, sqlRecomputeParticipantScore, participant.CampaignName, participant.ScpName, participant.LoginName)
+		if err != nil {
+			return
+		}
+		var rowsAffected int64
+		if rowsAffected, err = res.RowsAffected(); err != nil {
+			return
+		}
+		recomputed += rowsAffected
+	}
+
+	err = tx.Commit()
+	return
+}
+
+// gzipScoringEvents writes the events as gzipped newline delimited JSON, in the format of the export, for an archive.
+func gzipScoringEvents(events []types.ScoringEventStruct) (archived []byte, err error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(zw)
+	for i := range events {
+		if err = encoder.Encode(&events[i]); err != nil {
+			return
+		}
+	}
+	if err = zw.Close(); err != nil {
+		return
+	}
+	archived = buf.Bytes()
+	return
+}
+
+// gunzipScoringEvents reads the events of an archive written by gzipScoringEvents.
+func gunzipScoringEvents(archived []byte) (events []types.ScoringEventStruct, err error) {
+	zr, err := gzip.NewReader(bytes.NewReader(archived))
+	if err != nil {
+		return
+	}
+	defer zr.Close()
+
+	decoder := json.NewDecoder(zr)
+	for decoder.More() {
+		var event types.ScoringEventStruct
+		if err = decoder.Decode(&event); err != nil {
+			return
+		}
+		events = append(events, event)
+	}
+	return
+}
+
+// a campaign is left in the hot table while a flag of one of its events waits for review, as the archive drops flags
+const sqlSelectCampaignsToArchive = `SELECT campaign.name
+			FROM campaign
+			WHERE campaign.end_on AT TIME ZONE campaign.time_zone < $1
+				AND NOT EXISTS (SELECT 1 FROM scoring_event_archive WHERE fk_campaign = campaign.Id)
+				AND NOT EXISTS (SELECT 1
+					FROM scoring_flag
+					INNER JOIN scoring_event ON scoring_flag.fk_scoring_event = scoring_event.Id
+					WHERE scoring_event.fk_campaign = campaign.Id
+						AND scoring_flag.resolved_on IS NULL)
+			ORDER BY campaign.end_on AT TIME ZONE campaign.time_zone`
+
+// SelectCampaignsToArchive reads the campaigns that ended before the time and were never archived, the longest ended
+// first.
+func (p *BBashDB) SelectCampaignsToArchive(ctx context.Context, endedBefore time.Time) (campaignNames []string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryDb().QueryContext(ctx, sqlSelectCampaignsToArchive, endedBefore)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var campaignName string
+		if err = rows.Scan(&campaignName); err != nil {
+			return
+		}
+		campaignNames = append(campaignNames, campaignName)
+	}
+	err = rows.Err()
+	return
+}
+
+// claims the archive of the campaign, so a replica archiving it at the same time waits, then finds it archived. A
+// restored campaign is archived again.
+const sqlClaimScoringEventArchive = `INSERT INTO scoring_event_archive
+			(fk_campaign, event_count, archived_on)
+			SELECT Id, 0, $2 FROM campaign WHERE name = $1
+			ON CONFLICT (fk_campaign) DO
+				UPDATE SET event_count = 0, archived_on = EXCLUDED.archived_on, restored_on = NULL
+				WHERE scoring_event_archive.restored_on IS NOT NULL
+			RETURNING fk_campaign`
+
+const sqlSelectCampaignCoAuthors = `SELECT coauthor.fk_scoring_event, coauthor.username, coauthor.points
+			FROM scoring_event_coauthor coauthor
+			INNER JOIN scoring_event ON coauthor.fk_scoring_event = scoring_event.Id
+			WHERE scoring_event.fk_campaign = $1
+			ORDER BY coauthor.username`
+
+const sqlUpdateScoringEventArchive = `UPDATE scoring_event_archive
+			SET events = $2, event_count = $3
+			WHERE fk_campaign = $1`
+
+// the fingerprints, co-author shares and flags of the events go with them
+const sqlDeleteCampaignScoringEvents = `DELETE FROM scoring_event WHERE fk_campaign = $1`
+
+// ArchiveScoringEvents moves the scoring events of the campaign, with the shares of their co-authors, out of the
+// scoring_event table into a gzipped archive, in one transaction. The scores of the participants are left as they
+// are. sql.ErrNoRows is returned when there is no such campaign, or its events are already archived. There is no
+// statement timeout, as the events of a large campaign take a while to move.
+func (p *BBashDB) ArchiveScoringEvents(ctx context.Context, campaignName string, now time.Time) (archive *types.ScoringEventArchive, err error) {
+	tx, err := p.retryDb().BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var campaignId string
+	if err = tx.QueryRowContext(ctx, sqlClaimScoringEventArchive, campaignName, now).Scan(&campaignId); err != nil {
+		return
+	}
+
+	coAuthors := map[string][]types.CoAuthorScore{}
+	var rows *sql.Rows
+	if rows, err = tx.QueryContext(ctx, sqlSelectCampaignCoAuthors, campaignId); err != nil {
+		return
+	}
+	for rows.Next() {
+		var eventId string
+		var coAuthor types.CoAuthorScore
+		if err = rows.Scan(&eventId, &coAuthor.LoginName, &coAuthor.Points); err != nil {
+			_ = rows.Close()
+			return
+		}
+		coAuthors[eventId] = append(coAuthors[eventId], coAuthor)
+	}
+	if err = rows.Close(); err != nil {
+		return
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	var events []types.ScoringEventStruct
+	if rows, err = tx.QueryContext(ctx, sqlExportScoringEvents, campaignName); err != nil {
+		return
+	}
+	err = readExportedEvents(rows, func(event *types.ScoringEventStruct) error {
+		event.CoAuthors = coAuthors[event.ID]
+		events = append(events, *event)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	var archived []byte
+	if archived, err = gzipScoringEvents(events); err != nil {
+		return
+	}
+	if _, err = tx.ExecContext(ctx, sqlUpdateScoringEventArchive, campaignId, archived, len(events)); err != nil {
+		return
+	}
+	if _, err = tx.ExecContext(ctx, sqlDeleteCampaignScoringEvents, campaignId); err != nil {
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		return
+	}
+
+	archive = &types.ScoringEventArchive{CampaignName: campaignName, EventCount: int64(len(events)), ArchivedOn: now}
+	return
+}
+
+const sqlSelectScoringEventArchiveToRestore = `SELECT scoring_event_archive.fk_campaign, scoring_event_archive.events,
+				scoring_event_archive.archived_on
+			FROM scoring_event_archive
+			INNER JOIN campaign ON scoring_event_archive.fk_campaign = campaign.Id
+			WHERE campaign.name = $1
+				AND scoring_event_archive.restored_on IS NULL
+			FOR UPDATE OF scoring_event_archive`
+
+// an event of a pull request scored again since the archive keeps its new score
+const sqlRestoreScoringEvent = sqlImportScoringEvent + `
+			RETURNING Id`
+
+const sqlRestoreCoAuthorScore = `INSERT INTO scoring_event_coauthor
+			(fk_scoring_event, username, points)
+			VALUES ($1, $2, $3)`
+
+const sqlUpdateScoringEventArchiveRestored = `UPDATE scoring_event_archive
+			SET events = NULL, restored_on = $2
+			WHERE fk_campaign = $1`
+
+// RestoreScoringEvents moves the archived scoring events of the campaign, with the shares of their co-authors, back
+// into the scoring_event table, in one transaction. The archive is kept, without its events, so the campaign is not
+// archived again unless asked. sql.ErrNoRows is returned when the campaign has no archive to restore.
+func (p *BBashDB) RestoreScoringEvents(ctx context.Context, campaignName string, now time.Time) (archive *types.ScoringEventArchive, err error) {
+	tx, err := p.retryDb().BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var campaignId string
+	var archived []byte
+	restored := &types.ScoringEventArchive{CampaignName: campaignName, RestoredOn: &now}
+	err = tx.QueryRowContext(ctx, sqlSelectScoringEventArchiveToRestore, campaignName).Scan(&campaignId, &archived, &restored.ArchivedOn)
+	if err != nil {
+		return
+	}
+	var events []types.ScoringEventStruct
+	if events, err = gunzipScoringEvents(archived); err != nil {
+		return
+	}
+
+	for i := range events {
+		event := &events[i]
+		var breakdownJson []byte
+		if event.Breakdown != nil {
+			if breakdownJson, err = json.Marshal(event.Breakdown); err != nil {
+				return
+			}
+		}
+		var eventId string
+		err = tx.QueryRowContext(ctx, sqlRestoreScoringEvent, campaignName, event.ScpName, event.RepoOwner,
+			event.RepoName, event.PullRequest, event.UserName, event.Points, breakdownJson, event.VoidedOn, event.ScoredOn).
+			Scan(&eventId)
+		if err == sql.ErrNoRows {
+			err = nil
+			continue
+		} else if err != nil {
+			return
+		}
+		restored.EventCount++
+		for _, coAuthor := range event.CoAuthors {
+			if _, err = tx.ExecContext(ctx, sqlRestoreCoAuthorScore, eventId, coAuthor.LoginName, coAuthor.Points); err != nil {
+				return
+			}
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, sqlUpdateScoringEventArchiveRestored, campaignId, now); err != nil {
+		return
+	}
+	if err = tx.Commit(); err != nil {
+		return
+	}
+	archive = restored
+	return
+}
+
+const sqlSelectScoringEventArchives = `SELECT campaign.name, scoring_event_archive.event_count,
+				scoring_event_archive.archived_on, scoring_event_archive.restored_on
+			FROM scoring_event_archive
+			INNER JOIN campaign ON scoring_event_archive.fk_campaign = campaign.Id
+			ORDER BY scoring_event_archive.archived_on, campaign.name`
+
+// SelectScoringEventArchives reads the archives of the campaigns, restored ones included, the oldest first.
+func (p *BBashDB) SelectScoringEventArchives(ctx context.Context) (archives []types.ScoringEventArchive, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectScoringEventArchives)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		archive := types.ScoringEventArchive{}
+		if err = rows.Scan(&archive.CampaignName, &archive.EventCount, &archive.ArchivedOn, &archive.RestoredOn); err != nil {
+			return
+		}
+		archives = append(archives, archive)
+	}
+	err = rows.Err()
+	return
+}
+
+// compared as text, so an id that is not a uuid matches nothing instead of failing
+const sqlVoidScoringEvent = `UPDATE scoring_event
+			SET voided_on = $2
+			FROM campaign, source_control_provider
+			WHERE scoring_event.Id::text = $1
+				AND scoring_event.voided_on IS NULL
+				AND scoring_event.fk_campaign = campaign.Id
+				AND scoring_event.fk_scp = source_control_provider.Id
+			RETURNING scoring_event.Id, campaign.name, source_control_provider.name, repoOwner, repoName, pr, username,
+				points, breakdown, voided_on`
+
+const sqlReverseScoringEvent = `UPDATE participant
+			SET Score = Score - scoring_event.points
+			FROM scoring_event
+			WHERE scoring_event.Id = $1
+				AND participant.fk_campaign = scoring_event.fk_campaign
+				AND participant.fk_scp = scoring_event.fk_scp
+				AND participant.login_name = scoring_event.username
+			RETURNING COALESCE((SELECT name FROM team WHERE Id = participant.fk_team), '')`
+
+const sqlReverseCoAuthorScores = `UPDATE participant
+			SET Score = Score - coauthor.points
+			FROM scoring_event_coauthor coauthor, scoring_event
+			WHERE coauthor.fk_scoring_event = $1
+				AND scoring_event.Id = coauthor.fk_scoring_event
+				AND participant.fk_campaign = scoring_event.fk_campaign
+				AND participant.fk_scp = scoring_event.fk_scp
+				AND participant.login_name = coauthor.username
+			RETURNING participant.login_name, COALESCE((SELECT name FROM team WHERE Id = participant.fk_team), ''),
+				coauthor.points`
+
+// the shares are taken off once, so a scoring again after the void starts the co-authors afresh
+const sqlDeleteCoAuthorScores = `DELETE FROM scoring_event_coauthor WHERE fk_scoring_event = $1`
+
+// VoidScoringEvent marks the scoring event voided, and takes its points off the score of its participant, and the
+// shares of its co-authors off theirs, in one transaction. The teamName is the team of the participant, empty when the
+// participant is not on a team or no longer in the campaign. sql.ErrNoRows is returned when there is no such event, or
+// it is already voided.
+func (p *BBashDB) VoidScoringEvent(ctx context.Context, eventId string, now time.Time) (event *types.ScoringEventStruct, teamName string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.retryDb().BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	voided := &types.ScoringEventStruct{}
+	var breakdownJson []byte
+	err = tx.QueryRowContext(ctx, sqlVoidScoringEvent, eventId, now).
+		Scan(&voided.ID, &voided.CampaignName, &voided.ScpName, &voided.RepoOwner, &voided.RepoName, &voided.PullRequest,
+			&voided.UserName, &voided.Points, &breakdownJson, &voided.VoidedOn)
+	if err != nil {
+		return
+	}
+	if breakdownJson != nil {
+		voided.Breakdown = &types.ScoreBreakdown{}
+		if err = json.Unmarshal(breakdownJson, voided.Breakdown); err != nil {
+			return
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, sqlReverseScoringEvent, voided.ID).Scan(&teamName)
+	if err == sql.ErrNoRows {
+		// the participant has since been removed from the campaign, so there is no score to correct
+		err = nil
+	} else if err != nil {
+		return
+	}
+
+	var rows *sql.Rows
+	rows, err = tx.QueryContext(ctx, sqlReverseCoAuthorScores, voided.ID)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var coAuthor types.CoAuthorScore
+		if err = rows.Scan(&coAuthor.LoginName, &coAuthor.TeamName, &coAuthor.Points); err != nil {
+			_ = rows.Close()
+			return
+		}
+		voided.CoAuthors = append(voided.CoAuthors, coAuthor)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	if _, err = tx.ExecContext(ctx, sqlDeleteCoAuthorScores, voided.ID); err != nil {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		return
+	}
+	event = voided
+	return
+}
+
+// ScoredEvent is a scoring event that counts, with when it was last scored.
+type ScoredEvent struct {
+	types.ScoringEventStruct
+	ScoredOn time.Time
+}
+
+const sqlSelectScoredEvents = `SELECT scoring_event.Id, campaign.name, source_control_provider.name, repoOwner, repoName, pr,
+				username, points, breakdown, scored_on
+			FROM scoring_event
+			INNER JOIN campaign ON scoring_event.fk_campaign = campaign.Id
+			INNER JOIN source_control_provider ON scoring_event.fk_scp = source_control_provider.Id
+			WHERE scored_on >= $1
+				AND voided_on IS NULL
+			ORDER BY scored_on`
+
+// SelectScoredEvents reads the scoring events of every campaign scored since the time, and not voided, oldest first.
+func (p *BBashDB) SelectScoredEvents(ctx context.Context, since time.Time) (events []ScoredEvent, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectScoredEvents, since)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		event := ScoredEvent{}
+		var breakdownJson []byte
+		err = rows.Scan(&event.ID, &event.CampaignName, &event.ScpName, &event.RepoOwner, &event.RepoName,
+			&event.PullRequest, &event.UserName, &event.Points, &breakdownJson, &event.ScoredOn)
+		if err != nil {
+			return
+		}
+		if breakdownJson != nil {
+			event.Breakdown = &types.ScoreBreakdown{}
+			if err = json.Unmarshal(breakdownJson, event.Breakdown); err != nil {
+				return
+			}
+		}
+		events = append(events, event)
+	}
+	return
+}
+
+// an event flagged before, for the same reason, is not flagged again, even once the flag is resolved
+const sqlInsertScoringFlag = `INSERT INTO scoring_flag
+			(fk_scoring_event, reason, flagged_on)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (fk_scoring_event, reason) DO NOTHING`
+
+// InsertScoringFlags adds the scoring events to the review queue, in one transaction. The flagged count leaves out
+// the events already flagged for the same reason.
+func (p *BBashDB) InsertScoringFlags(ctx context.Context, flags []types.ScoringFlag) (flagged int64, err error) {
+	if len(flags) == 0 {
+		return
+	}
+
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.retryDb().BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var count int64
+	for _, flag := range flags {
+		var res sql.Result
+		res, err = tx.ExecContext(ctx, sqlInsertScoringFlag, flag.Event.ID, flag.Reason, flag.FlaggedOn)
+		if err != nil {
+			return
+		}
+		var rowsAffected int64
+		if rowsAffected, err = res.RowsAffected(); err != nil {
+			return
+		}
+		count += rowsAffected
+	}
+
+	if err = tx.Commit(); err != nil {
+		return
+	}
+	flagged = count
+	return
+}
+
+const sqlScoringFlagColumns = `scoring_flag.Id, scoring_flag.reason, scoring_flag.flagged_on, scoring_flag.resolved_on,
+				COALESCE(scoring_flag.resolution, ''), scoring_event.Id, campaign.name, source_control_provider.name,
+				repoOwner, repoName, pr, username, points, breakdown, voided_on`
+
+// the flags of an event voided since it was flagged leave the queue, and come back should it be scored again
+const sqlSelectScoringFlags = `SELECT ` + sqlScoringFlagColumns + `
+			FROM scoring_flag
+			INNER JOIN scoring_event ON scoring_flag.fk_scoring_event = scoring_event.Id
+			INNER JOIN campaign ON scoring_event.fk_campaign = campaign.Id
+			INNER JOIN source_control_provider ON scoring_event.fk_scp = source_control_provider.Id
+			WHERE scoring_flag.resolved_on IS NULL
+				AND scoring_event.voided_on IS NULL
+			ORDER BY scoring_flag.flagged_on, scoring_flag.Id`
+
+// scanScoringFlag reads the sqlScoringFlagColumns.
+func scanScoringFlag(row scanner) (flag *types.ScoringFlag, err error) {
+	scanned := &types.ScoringFlag{}
+	var breakdownJson []byte
+	err = row.Scan(&scanned.ID, &scanned.Reason, &scanned.FlaggedOn, &scanned.ResolvedOn, &scanned.Resolution,
+		&scanned.Event.ID, &scanned.Event.CampaignName, &scanned.Event.ScpName, &scanned.Event.RepoOwner,
+		&scanned.Event.RepoName, &scanned.Event.PullRequest, &scanned.Event.UserName, &scanned.Event.Points,
+		&breakdownJson, &scanned.Event.VoidedOn)
+	if err != nil {
+		return
+	}
+	if breakdownJson != nil {
+		scanned.Event.Breakdown = &types.ScoreBreakdown{}
+		if err = json.Unmarshal(breakdownJson, scanned.Event.Breakdown); err != nil {
+			return
+		}
+	}
+	flag = scanned
+	return
+}
+
+// SelectScoringFlags reads the review queue: the flags not yet resolved, of the events that still count, oldest first.
+func (p *BBashDB) SelectScoringFlags(ctx context.Context) (flags []types.ScoringFlag, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryDb().QueryContext(ctx, sqlSelectScoringFlags)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		var flag *types.ScoringFlag
+		if flag, err = scanScoringFlag(rows); err != nil {
+			return
+		}
+		flags = append(flags, *flag)
+	}
+	return
+}
+
+// compared as text, so an id that is not a uuid matches nothing instead of failing
+const sqlResolveScoringFlag = `UPDATE scoring_flag
+			SET resolved_on = $3, resolution = $2
+			FROM scoring_event, campaign, source_control_provider
+			WHERE scoring_flag.Id::text = $1
+				AND scoring_flag.resolved_on IS NULL
+				AND scoring_flag.fk_scoring_event = scoring_event.Id
+				AND scoring_event.fk_campaign = campaign.Id
+				AND scoring_event.fk_scp = source_control_provider.Id
+			RETURNING ` + sqlScoringFlagColumns
+
+// ResolveScoringFlag takes the flag out of the review queue, with the resolution of the admin. sql.ErrNoRows is
+// returned when there is no such flag, or it is already resolved.
+func (p *BBashDB) ResolveScoringFlag(ctx context.Context, flagId, resolution string, now time.Time) (flag *types.ScoringFlag, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	return scanScoringFlag(p.retryDb().QueryRowContext(ctx, sqlResolveScoringFlag, flagId, resolution, now))
+}
+
+const sqlInsertParticipant = `INSERT INTO participant 
+		(fk_scp, fk_campaign, login_name, Email, DisplayName, Score) 
+		VALUES ((SELECT Id FROM source_control_provider WHERE Name = $1),
+		        (SELECT Id FROM campaign WHERE name = $2),
+		        $3, $4, $5, $6)
+		RETURNING Id, Score, JoinedAt`
+
+func (p *BBashDB) InsertParticipant(ctx context.Context, participant *types.ParticipantStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx,
+		sqlInsertParticipant,
+		participant.ScpName,
+		participant.CampaignName,
+		participant.LoginName,
+		participant.Email,
+		participant.DisplayName,
+		0,
+	).Scan(&participant.ID, &participant.Score, &participant.JoinedAt)
+	if err != nil {
+		p.logger.Error("error inserting participant", zap.Any("participant", participant), zap.Error(err))
+	}
+	err = duplicateError(err, "participant")
+	return
+}
+
+// xmax is only zero for a row version that was inserted, rather than updated, by the statement
+const sqlUpsertParticipant = `INSERT INTO participant 
+		(fk_scp, fk_campaign, login_name, Email, DisplayName, Score) 
+		VALUES ((SELECT Id FROM source_control_provider WHERE Name = $1),
+		        (SELECT Id FROM campaign WHERE name = $2),
+		        $3, $4, $5, 0)
+		ON CONFLICT (fk_campaign, fk_scp, login_name) DO UPDATE
+		    SET Email = EXCLUDED.Email,
+		        DisplayName = EXCLUDED.DisplayName
+		RETURNING Id, Score, COALESCE((SELECT name FROM team WHERE team.Id = participant.fk_team), ''), JoinedAt,
+		    xmax = 0`
+
+// UpsertParticipant adds the participant, or changes the email and display name of the participant with the same
+// campaign, scp and login. The score and team of an existing participant are kept, and read into participant.
+func (p *BBashDB) UpsertParticipant(ctx context.Context, participant *types.ParticipantStruct) (inserted bool, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx,
+		sqlUpsertParticipant,
+		participant.ScpName,
+		participant.CampaignName,
+		participant.LoginName,
+		participant.Email,
+		participant.DisplayName,
+	).Scan(&participant.ID, &participant.Score, &participant.TeamName, &participant.JoinedAt, &inserted)
+	if err != nil {
+		p.logger.Error("error upserting participant", zap.Any("participant", participant), zap.Error(err))
+	}
+	return
+}
+
+const sqlInsertTeam = `INSERT INTO team
+		(fk_campaign, name)
+		VALUES ((SELECT id FROM campaign WHERE name = $1), $2)
+		RETURNING Id`
+
+func (p *BBashDB) InsertTeam(ctx context.Context, team *types.TeamStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx,
+		sqlInsertTeam,
+		team.CampaignName,
+		team.Name).Scan(&team.Id)
+	err = duplicateError(err, "team")
+	return
+}
+
+const sqlSelectTeamsByCampaign = `SELECT team.Id, campaign.name, team.name
+		FROM team
+		INNER JOIN campaign ON team.fk_campaign = campaign.Id
+		WHERE campaign.name = $1
+		ORDER BY team.name`
+
+func (p *BBashDB) SelectTeamsInCampaign(ctx context.Context, campaignName string) (teams []types.TeamStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectTeamsByCampaign, campaignName)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		team := types.TeamStruct{}
+		err = rows.Scan(&team.Id, &team.CampaignName, &team.Name)
+		if err != nil {
+			return
+		}
+		teams = append(teams, team)
+	}
+	return
+}
+
+// sqlSelectTeam finds a team by name. Team names are only unique within a campaign, so when no campaign name
+// is given, the team from the most recently created campaign wins.
+const sqlSelectTeam = `SELECT team.Id, campaign.name, team.name
+		FROM team
+		INNER JOIN campaign ON team.fk_campaign = campaign.Id
+		WHERE team.name = $1
+		  AND ($2 = '' OR campaign.name = $2)
+		ORDER BY campaign.create_order DESC
+		LIMIT 1`
+
+const sqlSelectTeamMembers = `SELECT
+		participant.Id, campaign.name, source_control_provider.name, login_name, Email, DisplayName, Score, team.name, captain, JoinedAt
+		FROM participant
+		INNER JOIN team ON participant.fk_team = team.Id
+		INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+		INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+		WHERE team.Id = $1
+		ORDER BY login_name`
+
+func (p *BBashDB) SelectTeamDetail(ctx context.Context, campaignName, teamName string) (team *types.TeamStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	team = new(types.TeamStruct)
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectTeam, teamName, campaignName).
+		Scan(&team.Id, &team.CampaignName, &team.Name)
+	if err != nil {
+		p.logger.Error("selectTeamDetail scan error",
+			zap.String("campaignName", campaignName), zap.String("teamName", teamName), zap.Error(err))
+		return
+	}
+
+	rows, err := p.retryDb().QueryContext(ctx, sqlSelectTeamMembers, team.Id)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		member := types.ParticipantStruct{}
+		err = rows.Scan(
+			&member.ID,
+			&member.CampaignName,
+			&member.ScpName,
+			&member.LoginName,
+			&member.Email,
+			&member.DisplayName,
+			&member.Score,
+			&member.TeamName,
+			&member.Captain,
+			&member.JoinedAt,
+		)
+		if err != nil {
+			return
+		}
+		team.Members = append(team.Members, member)
+	}
+	return
+}
+
+const sqlUnassignTeamMembers = `UPDATE participant
+		SET fk_team = NULL, captain = FALSE
+		WHERE fk_team = (SELECT team.Id FROM team
+			WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+			  AND name = $2)`
+
+const sqlDeleteTeam = `DELETE FROM team
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		  AND name = $2`
+
+// DeleteTeam removes the team from the campaign. Any participants on the team are unassigned first, in the same
+// transaction, so no participant is left pointing at a missing team.
+func (p *BBashDB) DeleteTeam(ctx context.Context, campaignName, teamName string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.retryDb().BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, sqlUnassignTeamMembers, campaignName, teamName)
+	if err != nil {
+		return
+	}
+
+	res, err := tx.ExecContext(ctx, sqlDeleteTeam, campaignName, teamName)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+const sqlUpdateTeamName = `UPDATE team
+		SET name = $3
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		  AND name = $2`
+
+func (p *BBashDB) UpdateTeamName(ctx context.Context, campaignName, teamName, newTeamName string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.retryDb().ExecContext(ctx, sqlUpdateTeamName, campaignName, teamName, newTeamName)
+	if err != nil {
+		err = duplicateError(err, "team")
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqlClearTeamCaptain = `UPDATE participant
+		SET captain = FALSE
+		WHERE fk_team = (SELECT team.Id FROM team
+			WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+			  AND name = $2)
+		  AND captain`
+
+const sqlSetTeamCaptain = `UPDATE participant
+		SET captain = TRUE
+		WHERE fk_team = (SELECT team.Id FROM team
+			WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+			  AND name = $2)
+		  AND fk_scp = (SELECT id FROM source_control_provider WHERE name = $3)
+		  AND login_name = $4`
+
+// UpdateTeamCaptain makes the given participant the captain of the team, taking the role from any prior captain.
+// The participant must already be a member of the team, otherwise nothing is changed and rowsAffected is zero.
+func (p *BBashDB) UpdateTeamCaptain(ctx context.Context, campaignName, teamName, scpName, loginName string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.retryDb().BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, sqlClearTeamCaptain, campaignName, teamName)
+	if err != nil {
+		return
+	}
+
+	res, err := tx.ExecContext(ctx, sqlSetTeamCaptain, campaignName, teamName, scpName, loginName)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		// not a team member, so keep the old captain
+		err = tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+const sqlSelectIsTeamCaptain = `SELECT EXISTS (SELECT 1 FROM participant
+		INNER JOIN team ON participant.fk_team = team.Id
+		INNER JOIN campaign ON team.fk_campaign = campaign.Id
+		INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+		WHERE campaign.name = $1
+		  AND team.name = $2
+		  AND source_control_provider.name = $3
+		  AND login_name = $4
+		  AND captain)`
+
+func (p *BBashDB) IsTeamCaptain(ctx context.Context, campaignName, teamName, scpName, loginName string) (isCaptain bool, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectIsTeamCaptain, campaignName, teamName, scpName, loginName).Scan(&isCaptain)
+	return
+}
+
+const sqlRemoveParticipantFromTeam = `UPDATE participant
+		SET fk_team = NULL, captain = FALSE
+		WHERE fk_team = (SELECT team.Id FROM team
+			WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $2)
+			  AND name = $1)
+		  AND fk_scp = (SELECT id FROM source_control_provider WHERE name = $3)
+		  AND login_name = $4`
+
+func (p *BBashDB) RemoveParticipantFromTeam(ctx context.Context, teamName, campaignName, scpName, loginName string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.retryDb().ExecContext(ctx, sqlRemoveParticipantFromTeam, teamName, campaignName, scpName, loginName)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqlInsertTeamScoreEvent = `INSERT INTO team_score_event
+		(fk_team, kind, category, points, reason, created_on)
+		SELECT team.Id, $3, $4, $5, $6, $7
+		FROM team
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		  AND name = $2
+		RETURNING Id`
+
+// InsertTeamScoreAdjustments grants the judged award points to each team. Either every adjustment is recorded, or
+// none are, so a typo in one team name does not leave the award half granted.
+func (p *BBashDB) InsertTeamScoreAdjustments(ctx context.Context, campaignName string, request *types.TeamScoreAdjustmentRequest, now time.Time) (events []types.TeamScoreEvent, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.retryDb().BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+			events = nil
+		}
+	}()
+
+	for _, adjustment := range request.Adjustments {
+		event := types.TeamScoreEvent{
+			TeamName:  adjustment.TeamName,
+			Kind:      types.TeamScoreKindJudgedAward,
+			Category:  request.Category,
+			Points:    adjustment.Points,
+			Reason:    adjustment.Reason,
+			CreatedOn: sql.NullTime{Time: now, Valid: true},
+		}
+		err = tx.QueryRowContext(ctx, sqlInsertTeamScoreEvent, campaignName, event.TeamName, event.Kind, event.Category,
+			event.Points, event.Reason, now).Scan(&event.Id)
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("no team: campaignName: %s, name: %s: %w", campaignName, event.TeamName, err)
+		}
+		if err != nil {
+			return
+		}
+		events = append(events, event)
+	}
+
+	err = tx.Commit()
+	return
+}
+
+const sqlSelectTeamScoreHistory = `SELECT team_score_event.Id::text, team.name, kind, category, '', points, reason, created_on
+		FROM team_score_event
+		INNER JOIN team ON team_score_event.fk_team = team.Id
+		INNER JOIN campaign ON team.fk_campaign = campaign.Id
+		WHERE campaign.name = $1
+		  AND team.name = $2
+		UNION ALL
+		SELECT '', team.name, '` + types.TeamScoreKindPullRequest + `', '', participant.login_name, scoring_event.points,
+			scoring_event.repoOwner || '/' || scoring_event.repoName || ' #' || scoring_event.pr, scoring_event.scored_on
+		FROM scoring_event
+		INNER JOIN participant ON participant.fk_campaign = scoring_event.fk_campaign
+			AND participant.fk_scp = scoring_event.fk_scp
+			AND participant.login_name = scoring_event.username
+		INNER JOIN team ON participant.fk_team = team.Id
+		INNER JOIN campaign ON team.fk_campaign = campaign.Id
+		WHERE campaign.name = $1
+		  AND team.name = $2
+		  AND scoring_event.voided_on IS NULL
+		ORDER BY created_on NULLS LAST`
+
+// SelectTeamScoreHistory lists the points earned by the team, both from pull requests of its members and from
+// judged awards, in the order they were earned.
+func (p *BBashDB) SelectTeamScoreHistory(ctx context.Context, campaignName, teamName string) (events []types.TeamScoreEvent, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectTeamScoreHistory, campaignName, teamName)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		event := types.TeamScoreEvent{}
+		err = rows.Scan(
+			&event.Id,
+			&event.TeamName,
+			&event.Kind,
+			&event.Category,
+			&event.LoginName,
+			&event.Points,
+			&event.Reason,
+			&event.CreatedOn,
+		)
+		if err != nil {
+			return
+		}
+		events = append(events, event)
+	}
+	return
+}
+
+const sqlUpdateParticipantContact = `UPDATE participant
+		SET Email = COALESCE($4, Email),
+			DisplayName = COALESCE($5, DisplayName)
+		WHERE Id = ` + sqlSelectParticipantIdByName
+
+// UpdateParticipantContact changes the email and display name of the participant, leaving a nil field as it is.
+func (p *BBashDB) UpdateParticipantContact(ctx context.Context, scpName, loginName string, request *types.ParticipantProfileRequest) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.retryDb().ExecContext(ctx, sqlUpdateParticipantContact, request.CampaignName, scpName, loginName, request.Email, request.DisplayName)
+	if err != nil {
+		return
+	}
+	return res.RowsAffected()
+}
+
+// a display name already set, like one given at registration, is kept. An empty avatar keeps the one already stored,
+// so a failed read of the profile only marks it as refreshed.
+const sqlUpdateParticipantProfile = `UPDATE participant
+		SET DisplayName = COALESCE(NULLIF(DisplayName, ''), NULLIF($2, '')),
+			avatar_url = COALESCE(NULLIF($3, ''), avatar_url),
+			profile_refreshed_on = $4
+		WHERE Id = $1`
+
+func (p *BBashDB) UpdateParticipantProfile(ctx context.Context, participantId, displayName, avatarUrl string, now time.Time) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err = p.retryDb().ExecContext(ctx, sqlUpdateParticipantProfile, participantId, displayName, avatarUrl, now)
+	return
+}
+
+const sqlSelectParticipantsToRefresh = `SELECT participant.Id, campaign.name, source_control_provider.name, login_name
+		FROM participant
+		INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+		INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+		WHERE profile_refreshed_on IS NULL OR profile_refreshed_on < $1
+		ORDER BY profile_refreshed_on NULLS FIRST
+		LIMIT $2`
+
+// SelectParticipantsToRefresh reads the participants whose profile was not read since refreshedBefore, least recently
+// read first.
+func (p *BBashDB) SelectParticipantsToRefresh(ctx context.Context, refreshedBefore time.Time, limit int) (participants []types.ParticipantStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryDb().QueryContext(ctx, sqlSelectParticipantsToRefresh, refreshedBefore, limit)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		participant := types.ParticipantStruct{}
+		err = rows.Scan(&participant.ID, &participant.CampaignName, &participant.ScpName, &participant.LoginName)
+		if err != nil {
+			return
+		}
+		participants = append(participants, participant)
+	}
+	return
+}
+
+const sqlSelectParticipantDetail = `SELECT 
+		participant.Id, campaign.name, source_control_provider.name, login_name, Email, DisplayName, Score, team.name, JoinedAt,
+		COALESCE(avatar_url, ''), participant.version
+		FROM participant
+		LEFT JOIN team ON team.Id = participant.fk_team
+		INNER JOIN campaign ON campaign.Id = participant.fk_campaign
+		INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+		WHERE campaign.name = $1
+		  AND source_control_provider.name = $2 
+		  AND participant.login_name = $3`
+
+func (p *BBashDB) SelectParticipantDetail(ctx context.Context, campaignName, scpName, loginName string) (participant *types.ParticipantStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	row := p.retryDb().QueryRowContext(ctx, sqlSelectParticipantDetail, campaignName, scpName, loginName)
+
+	participant = new(types.ParticipantStruct)
+	var nullableTeamName sql.NullString
+	err = row.Scan(&participant.ID,
+		&participant.CampaignName,
+		&participant.ScpName,
+		&participant.LoginName,
+		&participant.Email,
+		&participant.DisplayName,
+		&participant.Score,
+		&nullableTeamName,
+		&participant.JoinedAt,
+		&participant.AvatarUrl,
+		&participant.Version,
+	)
+	if err != nil {
+		p.logger.Error("getParticipantDetail scan error", zap.Error(err))
+		return
+	}
+	if nullableTeamName.Valid {
+		participant.TeamName = nullableTeamName.String
+	}
+	return
+}
+
+const sqlSelectParticipantsByCampaign = `SELECT
+		participant.Id, campaign.name, source_control_provider.name, login_name, Email, DisplayName, Score, team.name, JoinedAt,
+		COALESCE(avatar_url, '')
+		FROM participant
+		LEFT JOIN team ON participant.fk_team = team.Id
+		INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+		INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+		WHERE campaign.name = $1`
+
+func (p *BBashDB) SelectParticipantsInCampaign(ctx context.Context, campaignName string) (participants []types.ParticipantStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectParticipantsByCampaign, campaignName)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		participant := new(types.ParticipantStruct)
+		var nullableTeamName sql.NullString
+		err = rows.Scan(
+			&participant.ID,
+			&participant.CampaignName,
+			&participant.ScpName,
+			&participant.LoginName,
+			&participant.Email,
+			&participant.DisplayName,
+			&participant.Score,
+			&nullableTeamName,
+			&participant.JoinedAt,
+			&participant.AvatarUrl,
+		)
+		if err != nil {
+			return
+		}
+		if nullableTeamName.Valid {
+			participant.TeamName = nullableTeamName.String
+		}
+		participants = append(participants, *participant)
+	}
+	return
+}
+
+const sqlSelectParticipantsVersion = `SELECT
+		(SELECT COUNT(*) FROM participant WHERE fk_campaign = campaign.Id),
+		GREATEST(
+			(SELECT COALESCE(MAX(updated_on), 'epoch') FROM participant WHERE fk_campaign = campaign.Id),
+			(SELECT COALESCE(MAX(updated_on), 'epoch') FROM team WHERE fk_campaign = campaign.Id))
+		FROM campaign
+		WHERE name = $1`
+
+// SelectParticipantsVersion identifies the current state of the participant list of the campaign. Teams are
+// included, as the list shows the team name of each participant. sql.ErrNoRows is returned when there is no such
+// campaign.
+func (p *BBashDB) SelectParticipantsVersion(ctx context.Context, campaignName string) (version types.ListVersion, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryReadDb().QueryRowContext(ctx, sqlSelectParticipantsVersion, campaignName).Scan(&version.Count, &version.UpdatedOn)
+	return
+}
+
+// an exact match is listed first
+const sqlSearchParticipants = `SELECT
+		participant.Id, campaign.name, source_control_provider.name, login_name, Email, DisplayName, Score, team.name, JoinedAt,
+		COALESCE(avatar_url, '')
+		FROM participant
+		LEFT JOIN team ON participant.fk_team = team.Id
+		INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+		INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+		WHERE login_name ILIKE '%' || $1 || '%'
+		ORDER BY lower(login_name) <> lower($2), login_name, campaign.name, source_control_provider.name
+		LIMIT $3`
+
+// likeEscaper stops the LIKE wildcards in a search from matching anything
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchParticipants finds the participants of every campaign and source control provider whose login contains login,
+// ignoring case.
+func (p *BBashDB) SearchParticipants(ctx context.Context, login string, limit int) (participants []types.ParticipantStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSearchParticipants, likeEscaper.Replace(login), login, limit)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		participant := types.ParticipantStruct{}
+		var nullableTeamName sql.NullString
+		err = rows.Scan(
+			&participant.ID,
+			&participant.CampaignName,
+			&participant.ScpName,
+			&participant.LoginName,
+			&participant.Email,
+			&participant.DisplayName,
 			&participant.Score,
 			&nullableTeamName,
 			&participant.JoinedAt,
+			&participant.AvatarUrl,
+		)
+		if err != nil {
+			return
+		}
+		participant.TeamName = nullableTeamName.String
+		participants = append(participants, participant)
+	}
+	return
+}
+
+const sqlUpdateParticipant = `UPDATE participant 
+		SET 
+		    fk_campaign = (SELECT Id FROM campaign WHERE name = $1),
+		    fk_scp = (SELECT Id FROM source_control_provider WHERE name = $2),
+		    login_name = $3,
+		    Email = $4,
+		    DisplayName = $5,
+		    Score = $6,
+		    fk_team = (SELECT Id FROM team WHERE name = $7),
+		    version = version + 1
+		WHERE Id = $8 AND version = $9`
+
+const sqlSelectParticipantVersion = `SELECT version FROM participant WHERE Id = $1`
+
+// UpdateParticipant replaces the participant, when its version is the one the update was made from. A *StaleError is
+// returned when the version is not current.
+func (p *BBashDB) UpdateParticipant(ctx context.Context, participant *types.ParticipantStruct) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.retryDb().ExecContext(ctx,
+		sqlUpdateParticipant,
+		participant.CampaignName,
+		participant.ScpName,
+		participant.LoginName,
+		participant.Email,
+		participant.DisplayName,
+		participant.Score,
+		participant.TeamName,
+		participant.ID,
+		participant.Version,
+	)
+	if err != nil {
+		return
+	}
+
+	rowsAffected, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		err = staleUpdate(p.retryDb().QueryRowContext(ctx, sqlSelectParticipantVersion, participant.ID), "participant")
+		return
+	}
+	participant.Version++
+	return
+}
+
+// a campaign, scp or login left out is kept, while a team sent as null is left
+const sqlPatchParticipant = `UPDATE participant
+		SET
+		    fk_campaign = CASE WHEN $1::text IS NULL THEN fk_campaign ELSE (SELECT Id FROM campaign WHERE name = $1) END,
+		    fk_scp = CASE WHEN $2::text IS NULL THEN fk_scp ELSE (SELECT Id FROM source_control_provider WHERE name = $2) END,
+		    login_name = COALESCE($3, login_name),
+		    Email = COALESCE($4, Email),
+		    DisplayName = COALESCE($5, DisplayName),
+		    Score = COALESCE($6, Score),
+		    fk_team = CASE WHEN $8 THEN (SELECT Id FROM team WHERE name = $7) ELSE fk_team END,
+		    version = version + 1
+		WHERE Id = $9 AND version = $10`
+
+// PatchParticipant changes only the fields set in the patch, when the version of the participant is the one the patch
+// was made from. A *StaleError is returned when the version is not current.
+func (p *BBashDB) PatchParticipant(ctx context.Context, patch *types.ParticipantPatch) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.retryDb().ExecContext(ctx, sqlPatchParticipant, patchArgs(patch)...)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		err = staleUpdate(p.retryDb().QueryRowContext(ctx, sqlSelectParticipantVersion, patch.ID), "participant")
+		return
+	}
+	patch.Version++
+	return
+}
+
+// patchArgs are the arguments of the patch statement, the same for each database
+func patchArgs(patch *types.ParticipantPatch) []interface{} {
+	return []interface{}{
+		patch.CampaignName.Ptr(),
+		patch.ScpName.Ptr(),
+		patch.LoginName.Ptr(),
+		clearable(patch.Email),
+		clearable(patch.DisplayName),
+		patch.Score.Ptr(),
+		patch.TeamName.Ptr(),
+		patch.TeamName.Set,
+		patch.ID,
+		patch.Version,
+	}
+}
+
+// clearable is nil when the field was left out, so the column is kept, and empty when the field was null, so the
+// column is cleared
+func clearable(field types.OptionalString) *string {
+	if field.Null {
+		empty := ""
+		return &empty
+	}
+	return field.Ptr()
+}
+
+const sqlDeleteParticipant = `DELETE FROM participant WHERE
+                          fk_campaign = (SELECT id from campaign where name =$1)
+                          AND fk_scp = (SELECT id from source_control_provider where name =$2)
+                          AND login_name = $3
+                          RETURNING id`
+
+func (p *BBashDB) DeleteParticipant(ctx context.Context, campaign, scpName, loginName string) (participantId string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlDeleteParticipant, campaign, scpName, loginName).Scan(&participantId)
+	if err != nil {
+		p.logger.Error("error deleting participant",
+			zap.String("campaign", campaign), zap.String("scpName", scpName),
+			zap.String("loginName", loginName), zap.Error(err))
+	}
+	return
+}
+
+const sqlInsertBan = `INSERT INTO ban
+		(fk_scp, login_name, reason)
+		VALUES ((SELECT Id FROM source_control_provider WHERE LOWER(name) = LOWER($1)), LOWER($2), $3)
+		RETURNING Id, banned_on`
+
+// InsertBan bans the login from every campaign. The login is stored in lower case, as logins are matched without case.
+func (p *BBashDB) InsertBan(ctx context.Context, ban *types.BanStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlInsertBan, ban.ScpName, ban.LoginName, ban.Reason).
+		Scan(&ban.ID, &ban.BannedOn)
+	err = duplicateError(err, "ban")
+	if err == nil {
+		ban.LoginName = strings.ToLower(ban.LoginName)
+	}
+	return
+}
+
+const sqlSelectBans = `SELECT ban.Id, source_control_provider.name, ban.login_name, ban.reason, ban.banned_on
+		FROM ban
+		INNER JOIN source_control_provider ON source_control_provider.Id = ban.fk_scp
+		ORDER BY source_control_provider.name, ban.login_name`
+
+func (p *BBashDB) SelectBans(ctx context.Context) (bans []types.BanStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectBans)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		ban := types.BanStruct{}
+		err = rows.Scan(&ban.ID, &ban.ScpName, &ban.LoginName, &ban.Reason, &ban.BannedOn)
+		if err != nil {
+			return
+		}
+		bans = append(bans, ban)
+	}
+	return
+}
+
+const sqlDeleteBan = `DELETE FROM ban
+		WHERE fk_scp = (SELECT Id FROM source_control_provider WHERE LOWER(name) = LOWER($1))
+			AND login_name = LOWER($2)`
+
+func (p *BBashDB) DeleteBan(ctx context.Context, scpName, loginName string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.retryDb().ExecContext(ctx, sqlDeleteBan, scpName, loginName)
+	if err != nil {
+		return
+	}
+	return res.RowsAffected()
+}
+
+const sqlSelectBanned = `SELECT EXISTS(SELECT ban.Id FROM ban
+		INNER JOIN source_control_provider ON source_control_provider.Id = ban.fk_scp
+		WHERE LOWER(source_control_provider.name) = LOWER($1)
+			AND ban.login_name = LOWER($2))`
+
+// SelectBanned reports if the login is banned from every campaign.
+func (p *BBashDB) SelectBanned(ctx context.Context, scpName, loginName string) (banned bool, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectBanned, scpName, loginName).Scan(&banned)
+	return
+}
+
+// DuplicateError is returned when an insert or rename would break a unique constraint, because the Entity already
+// exists.
+type DuplicateError struct {
+	Entity     string
+	Constraint string
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("%s already exists", e.Entity)
+}
+
+// postgres error code of a unique constraint violation
+const pqUniqueViolation = "unique_violation"
+
+// duplicateError replaces a unique constraint violation with a *DuplicateError. Other errors are returned as they are.
+func duplicateError(err error, entity string) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code.Name() == pqUniqueViolation {
+		return &DuplicateError{Entity: entity, Constraint: pqErr.Constraint}
+	}
+	return err
+}
+
+// newId makes a random (version 4) uuid, for the implementations that cannot have the database generate one.
+func newId() string {
+	var b [16]byte
+	// rand.Read only fails when the system has no source of randomness, which the supported platforms all have
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// StaleError is returned by an update made from a version of the entity that is not the current one, as another update
+// came first. Version is the current one.
+type StaleError struct {
+	Entity  string
+	Version int
+}
+
+func (e *StaleError) Error() string {
+	return fmt.Sprintf("%s was changed by another update, current version: %d", e.Entity, e.Version)
+}
+
+// staleError reads the current version of an entity an update found no row of, to return a *StaleError. When the
+// entity does not exist, sql.ErrNoRows is returned.
+func staleError(row scanner, entity string) (err error) {
+	var version int
+	if err = row.Scan(&version); err != nil {
+		return
+	}
+	return &StaleError{Entity: entity, Version: version}
+}
+
+// staleUpdate checks an update that changed no row, returning a *StaleError when the entity exists. When it does
+// not, no error is returned, so the caller reports the zero rows affected as before.
+func staleUpdate(row scanner, entity string) (err error) {
+	err = staleError(row, entity)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	return
+}
+
+// TeamFullError is returned when adding a participant would take a team past the maximum team size of its campaign.
+type TeamFullError struct {
+	CampaignName string
+	TeamName     string
+	MaxTeamSize  int
+}
+
+func (e *TeamFullError) Error() string {
+	return fmt.Sprintf("team is full: campaignName: %s, teamName: %s, maxTeamSize: %d", e.CampaignName, e.TeamName, e.MaxTeamSize)
+}
+
+// CaptainMoveError is returned when moving a participant onto a team would take them away from the team they captain,
+// leaving it without a captain. Another member is made captain first.
+type CaptainMoveError struct {
+	CampaignName string
+	ScpName      string
+	LoginName    string
+}
+
+func (e *CaptainMoveError) Error() string {
+	return fmt.Sprintf("the captain can not leave their team: campaignName: %s, scpName: %s, loginName: %s", e.CampaignName, e.ScpName, e.LoginName)
+}
+
+// sqlSelectTeamSizeForUpdate locks the team row, so concurrent additions to the same team can not both squeeze in
+// under the limit. The participant being added is not counted, in case they are already on the team. The last column
+// tells whether the participant captains another team.
+const sqlSelectTeamSizeForUpdate = `SELECT campaign.max_team_size,
+		(SELECT COUNT(*) FROM participant
+			WHERE participant.fk_team = team.Id
+			  AND NOT (participant.fk_scp = (SELECT id FROM source_control_provider WHERE name = $3)
+				AND participant.login_name = $4)),
+		EXISTS (SELECT 1 FROM participant
+			WHERE participant.fk_campaign = campaign.Id
+			  AND participant.fk_scp = (SELECT id FROM source_control_provider WHERE name = $3)
+			  AND participant.login_name = $4
+			  AND participant.captain
+			  AND participant.fk_team <> team.Id)
+		FROM team
+		INNER JOIN campaign ON team.fk_campaign = campaign.Id
+		WHERE team.name = $1
+		  AND campaign.name = $2
+		FOR UPDATE OF team`
+
+// the captain keeps the role, as a captain is only ever moved onto the team they are on
+const sqlUpdateParticipantTeam = `UPDATE participant 
+		SET fk_team = (SELECT Id FROM team WHERE name = $1 AND fk_campaign = (SELECT id FROM campaign WHERE name = $2))
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $2)
+		 AND fk_scp = (SELECT id FROM source_control_provider WHERE name = $3)
+		 AND login_name = $4`
+
+// UpdateParticipantTeam moves the participant onto the team. A *TeamFullError is returned when the team is already at
+// the maximum team size of the campaign, a *CaptainMoveError when the participant captains another team, and
+// sql.ErrNoRows when there is no such team in the campaign.
+func (p *BBashDB) UpdateParticipantTeam(ctx context.Context, teamName, campaignName, scpName, loginName string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.retryDb().BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	rowsAffected, err = updateParticipantTeam(ctx, tx, teamName, campaignName, scpName, loginName)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+func updateParticipantTeam(ctx context.Context, tx *sql.Tx, teamName, campaignName, scpName, loginName string) (rowsAffected int64, err error) {
+	var maxTeamSize, teamSize int
+	var captainElsewhere bool
+	err = tx.QueryRowContext(ctx, sqlSelectTeamSizeForUpdate, teamName, campaignName, scpName, loginName).
+		Scan(&maxTeamSize, &teamSize, &captainElsewhere)
+	if err != nil {
+		return
+	}
+	if captainElsewhere {
+		err = &CaptainMoveError{CampaignName: campaignName, ScpName: scpName, LoginName: loginName}
+		return
+	}
+	if maxTeamSize > 0 && teamSize >= maxTeamSize {
+		err = &TeamFullError{CampaignName: campaignName, TeamName: teamName, MaxTeamSize: maxTeamSize}
+		return
+	}
+
+	res, err := tx.ExecContext(ctx,
+		sqlUpdateParticipantTeam,
+		teamName,
+		campaignName,
+		scpName,
+		loginName)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+// UpdateParticipantTeams applies all the team assignments in one transaction, so either every assignment is applied or
+// none are. An assignment to a full or missing team, or of a missing participant, is reported in its result, and
+// leaves every Assigned false. Any other error is returned.
+func (p *BBashDB) UpdateParticipantTeams(ctx context.Context, assignments []types.TeamAssignment) (results []types.TeamAssignmentResult, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.retryDb().BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	failed := false
+	for _, assignment := range assignments {
+		result := types.TeamAssignmentResult{TeamAssignment: assignment}
+
+		var rowsAffected int64
+		rowsAffected, err = updateParticipantTeam(ctx, tx, assignment.TeamName, assignment.CampaignName, assignment.ScpName, assignment.LoginName)
+		var teamFullErr *TeamFullError
+		var captainMoveErr *CaptainMoveError
+		if errors.As(err, &teamFullErr) || errors.As(err, &captainMoveErr) {
+			result.Error = err.Error()
+		} else if err == sql.ErrNoRows {
+			result.Error = fmt.Sprintf("no team: campaignName: %s, name: %s", assignment.CampaignName, assignment.TeamName)
+		} else if err != nil {
+			return
+		} else if rowsAffected == 0 {
+			result.Error = fmt.Sprintf("no participant: campaignName: %s, scpName: %s, loginName: %s",
+				assignment.CampaignName, assignment.ScpName, assignment.LoginName)
+		}
+		err = nil
+
+		failed = failed || result.Error != ""
+		results = append(results, result)
+	}
+
+	if failed {
+		err = tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return
+	}
+	for i := range results {
+		results[i].Assigned = true
+	}
+	return
+}
+
+// InsertSeedFixture adds the campaigns of the fixture, then their bugs, organizations and teams, then the
+// participants, in one transaction so either all of the fixture is added or none of it. The ids are set on the fixture.
+// As for the single inserts, the caller checks the campaigns named exist.
+func (p *BBashDB) InsertSeedFixture(ctx context.Context, fixture *types.SeedFixture) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.retryDb().BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for i := range fixture.Campaigns {
+		campaign := &fixture.Campaigns[i]
+		err = tx.QueryRowContext(ctx, sqlInsertCampaign, campaign.Name, campaign.StartOn, campaign.EndOn, campaign.MaxTeamSize,
+			campaign.TieBreaker, campaign.UnclassifiedBonus, campaign.TenantName, campaign.TimeZone, campaign.FreezeScoring,
+			campaign.RegistrationOpensOn, campaign.RegistrationClosesOn).Scan(&campaign.ID)
+		if err = duplicateError(err, "campaign"); err != nil {
+			return
+		}
+	}
+	for i := range fixture.Bugs {
+		bug := &fixture.Bugs[i]
+		err = tx.QueryRowContext(ctx, sqlInsertBug, bug.Campaign, bug.Category, bug.PointValue).Scan(&bug.Id)
+		if err = duplicateError(err, "bug"); err != nil {
+			return
+		}
+	}
+	for i := range fixture.Organizations {
+		organization := &fixture.Organizations[i]
+		err = tx.QueryRowContext(ctx, sqlInsertOrganization, organization.SCPName, organization.Organization, organization.CampaignName).
+			Scan(&organization.ID)
+		if err = duplicateError(err, "organization"); err != nil {
+			return
+		}
+	}
+	for i := range fixture.Teams {
+		team := &fixture.Teams[i]
+		err = tx.QueryRowContext(ctx, sqlInsertTeam, team.CampaignName, team.Name).Scan(&team.Id)
+		if err = duplicateError(err, "team"); err != nil {
+			return
+		}
+	}
+	for i := range fixture.Participants {
+		participant := &fixture.Participants[i]
+		err = tx.QueryRowContext(ctx, sqlInsertParticipant, participant.ScpName, participant.CampaignName, participant.LoginName,
+			participant.Email, participant.DisplayName, 0).Scan(&participant.ID, &participant.Score, &participant.JoinedAt)
+		if err = duplicateError(err, "participant"); err != nil {
+			return
+		}
+		if participant.TeamName == "" {
+			continue
+		}
+		_, err = updateParticipantTeam(ctx, tx, participant.TeamName, participant.CampaignName, participant.ScpName, participant.LoginName)
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("no team: campaignName: %s, name: %s: %w", participant.CampaignName, participant.TeamName, err)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	err = tx.Commit()
+	return
+}
+
+const sqlInsertBug = `INSERT INTO bug
+		(fk_campaign, category, pointValue)
+		VALUES ((SELECT id FROM campaign WHERE name = $1), $2, $3)
+		RETURNING ID`
+
+func (p *BBashDB) InsertBug(ctx context.Context, bug *types.BugStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlInsertBug, bug.Campaign, bug.Category, bug.PointValue).Scan(&bug.Id)
+	if err != nil {
+		p.logger.Error("error inserting bug", zap.Any("bug", bug), zap.Error(err))
+		err = duplicateError(err, "bug")
+		return
+	}
+	return
+}
+
+const sqlUpsertPointValueOverride = `INSERT INTO point_value_override
+		(fk_campaign, fk_organization, category, point_value)
+		SELECT campaign.Id, organization.Id, $4, $5
+			FROM campaign, organization
+			INNER JOIN source_control_provider ON source_control_provider.Id = organization.fk_scp
+			WHERE campaign.name = $1
+			  AND LOWER(source_control_provider.name) = LOWER($2)
+			  AND LOWER(organization.Organization) = LOWER($3)
+			  AND (organization.fk_campaign IS NULL OR organization.fk_campaign = campaign.Id)
+			ORDER BY organization.fk_campaign NULLS LAST
+			LIMIT 1
+		ON CONFLICT (fk_campaign, fk_organization, category) DO
+			UPDATE SET point_value = $5
+		RETURNING Id`
+
+// UpsertPointValueOverride sets the point value of a bug category for an organization in the campaign. Returns
+// sql.ErrNoRows if there is no such campaign or organization.
+func (p *BBashDB) UpsertPointValueOverride(ctx context.Context, override *types.PointValueOverride) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlUpsertPointValueOverride,
+		override.CampaignName,
+		override.ScpName,
+		override.Organization,
+		override.Category,
+		override.PointValue,
+	).Scan(&override.Id)
+	return
+}
+
+const sqlSelectPointValueOverrides = `SELECT point_value_override.Id, source_control_provider.name, organization.Organization,
+			point_value_override.category, point_value_override.point_value
+		FROM point_value_override
+		INNER JOIN organization ON organization.Id = point_value_override.fk_organization
+		INNER JOIN source_control_provider ON source_control_provider.Id = organization.fk_scp
+		WHERE point_value_override.fk_campaign = (SELECT Id FROM campaign WHERE name = $1)
+		ORDER BY source_control_provider.name, organization.Organization, point_value_override.category`
+
+func (p *BBashDB) SelectPointValueOverrides(ctx context.Context, campaignName string) (overrides []types.PointValueOverride, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectPointValueOverrides, campaignName)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		override := types.PointValueOverride{CampaignName: campaignName}
+		err = rows.Scan(&override.Id, &override.ScpName, &override.Organization, &override.Category, &override.PointValue)
+		if err != nil {
+			return
+		}
+		overrides = append(overrides, override)
+	}
+	return
+}
+
+const sqlDeletePointValueOverride = `DELETE FROM point_value_override
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = $1)
+		  AND fk_organization IN (SELECT organization.Id FROM organization
+			INNER JOIN source_control_provider ON source_control_provider.Id = organization.fk_scp
+			WHERE LOWER(source_control_provider.name) = LOWER($2) AND LOWER(organization.Organization) = LOWER($3))
+		  AND category = $4`
+
+func (p *BBashDB) DeletePointValueOverride(ctx context.Context, override *types.PointValueOverride) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.retryDb().ExecContext(ctx, sqlDeletePointValueOverride, override.CampaignName, override.ScpName, override.Organization, override.Category)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqlInsertBugCategoryExclusion = `INSERT INTO bug_category_exclusion
+		(fk_campaign, category)
+		SELECT campaign.Id, $2
+			FROM campaign
+			WHERE campaign.name = $1
+		RETURNING Id`
+
+// InsertBugCategoryExclusion keeps a bug category from scoring in the campaign. Returns sql.ErrNoRows if there is no
+// such campaign.
+func (p *BBashDB) InsertBugCategoryExclusion(ctx context.Context, exclusion *types.BugCategoryExclusion) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlInsertBugCategoryExclusion, exclusion.CampaignName, exclusion.Category).
+		Scan(&exclusion.Id)
+	if err != nil {
+		err = duplicateError(err, "bug category exclusion")
+	}
+	return
+}
+
+const sqlSelectBugCategoryExclusions = `SELECT Id, category
+		FROM bug_category_exclusion
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = $1)
+		ORDER BY category`
+
+func (p *BBashDB) SelectBugCategoryExclusions(ctx context.Context, campaignName string) (exclusions []types.BugCategoryExclusion, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectBugCategoryExclusions, campaignName)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		exclusion := types.BugCategoryExclusion{CampaignName: campaignName}
+		err = rows.Scan(&exclusion.Id, &exclusion.Category)
+		if err != nil {
+			return
+		}
+		exclusions = append(exclusions, exclusion)
+	}
+	return
+}
+
+const sqlDeleteBugCategoryExclusion = `DELETE FROM bug_category_exclusion
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = $1)
+		  AND category = $2`
+
+func (p *BBashDB) DeleteBugCategoryExclusion(ctx context.Context, campaignName, category string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.retryDb().ExecContext(ctx, sqlDeleteBugCategoryExclusion, campaignName, category)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqlInsertRepositoryFilter = `INSERT INTO repository_filter
+		(fk_campaign, kind, pattern)
+		SELECT campaign.Id, $2, LOWER($3)
+			FROM campaign
+			WHERE campaign.name = $1
+		RETURNING Id`
+
+// InsertRepositoryFilter adds an allow or deny pattern of the repositories scored in the campaign. Returns
+// sql.ErrNoRows if there is no such campaign.
+func (p *BBashDB) InsertRepositoryFilter(ctx context.Context, filter *types.RepositoryFilter) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlInsertRepositoryFilter,
+		filter.CampaignName,
+		filter.Kind,
+		filter.Pattern,
+	).Scan(&filter.Id)
+	if err != nil {
+		err = duplicateError(err, "repository filter")
+		return
+	}
+	filter.Pattern = strings.ToLower(filter.Pattern)
+	return
+}
+
+const sqlSelectRepositoryFilters = `SELECT Id, kind, pattern
+		FROM repository_filter
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = $1)
+		ORDER BY kind, pattern`
+
+func (p *BBashDB) SelectRepositoryFilters(ctx context.Context, campaignName string) (filters []types.RepositoryFilter, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectRepositoryFilters, campaignName)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		filter := types.RepositoryFilter{CampaignName: campaignName}
+		err = rows.Scan(&filter.Id, &filter.Kind, &filter.Pattern)
+		if err != nil {
+			return
+		}
+		filters = append(filters, filter)
+	}
+	return
+}
+
+// compared as text, so an id that is not a uuid matches nothing instead of failing
+const sqlDeleteRepositoryFilter = `DELETE FROM repository_filter
+		WHERE fk_campaign = (SELECT Id FROM campaign WHERE name = $1)
+		  AND Id::text = $2`
+
+func (p *BBashDB) DeleteRepositoryFilter(ctx context.Context, campaignName, filterId string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.retryDb().ExecContext(ctx, sqlDeleteRepositoryFilter, campaignName, filterId)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqlUpdateBug = `UPDATE bug
+		SET pointValue = $1, version = version + 1
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $2) AND category = $3 AND version = $4`
+
+const sqlSelectBugVersion = `SELECT version FROM bug
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1) AND category = $2`
+
+// UpdateBug changes the point value of the bug, when its version is the one the update was made from. A *StaleError
+// is returned when the version is not current.
+func (p *BBashDB) UpdateBug(ctx context.Context, bug *types.BugStruct) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.retryDb().ExecContext(ctx, sqlUpdateBug, bug.PointValue, bug.Campaign, bug.Category, bug.Version)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		err = staleUpdate(p.retryDb().QueryRowContext(ctx, sqlSelectBugVersion, bug.Campaign, bug.Category), "bug")
+		return
+	}
+	bug.Version++
+	return
+}
+
+const sqlSelectBugs = `SELECT bug.id, campaign.name, category, pointValue, bug.version FROM bug
+		INNER JOIN campaign ON fk_campaign = campaign.Id`
+
+func (p *BBashDB) SelectBugs(ctx context.Context) (bugs []types.BugStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectBugs)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		bug := types.BugStruct{}
+		err = rows.Scan(&bug.Id, &bug.Campaign, &bug.Category, &bug.PointValue, &bug.Version)
+		if err != nil {
+			return
+		}
+		bugs = append(bugs, bug)
+	}
+	return
+}
+
+const sqlSelectBugsInCampaign = sqlSelectBugs + `
+		WHERE campaign.name = $1
+		ORDER BY category`
+
+// SelectBugsInCampaign reads the bug categories of the campaign, and their point values.
+func (p *BBashDB) SelectBugsInCampaign(ctx context.Context, campaignName string) (bugs []types.BugStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectBugsInCampaign, campaignName)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		bug := types.BugStruct{}
+		err = rows.Scan(&bug.Id, &bug.Campaign, &bug.Category, &bug.PointValue, &bug.Version)
+		if err != nil {
+			return
+		}
+		bugs = append(bugs, bug)
+	}
+	err = rows.Err()
+	return
+}
+
+const sqlSelectBugsVersion = `SELECT COUNT(*), COALESCE(MAX(updated_on), 'epoch') FROM bug`
+
+// SelectBugsVersion identifies the current state of the bug list, without reading the list.
+func (p *BBashDB) SelectBugsVersion(ctx context.Context) (version types.ListVersion, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryReadDb().QueryRowContext(ctx, sqlSelectBugsVersion).Scan(&version.Count, &version.UpdatedOn)
+	return
+}
+
+const sqlUpsertCampaignConfigStage = `INSERT INTO campaign_config_stage
+		(fk_campaign, config, updated_on)
+		VALUES ((SELECT id FROM campaign WHERE name = $1), $2, NOW())
+		ON CONFLICT (fk_campaign) DO
+			UPDATE SET config = $2, updated_on = NOW()
+		RETURNING updated_on`
+
+func (p *BBashDB) UpsertCampaignConfigStage(ctx context.Context, config *types.CampaignConfigStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var configJson []byte
+	configJson, err = json.Marshal(config)
+	if err != nil {
+		return
+	}
+	err = p.retryDb().QueryRowContext(ctx, sqlUpsertCampaignConfigStage, config.Campaign, configJson).Scan(&config.UpdatedOn)
+	return
+}
+
+const sqlSelectCampaignConfigStage = `SELECT config, updated_on
+		FROM campaign_config_stage
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)`
+
+func (p *BBashDB) SelectCampaignConfigStage(ctx context.Context, campaignName string) (config *types.CampaignConfigStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	config, err = selectCampaignConfigStage(p.retryDb().QueryRowContext(ctx, sqlSelectCampaignConfigStage, campaignName))
+	return
+}
+
+func selectCampaignConfigStage(row scanner) (config *types.CampaignConfigStruct, err error) {
+	var configJson []byte
+	var updatedOn time.Time
+	err = row.Scan(&configJson, &updatedOn)
+	if err != nil {
+		return
+	}
+
+	config = &types.CampaignConfigStruct{}
+	err = json.Unmarshal(configJson, config)
+	config.UpdatedOn = updatedOn
+	return
+}
+
+const sqlDeleteCampaignConfigStage = `DELETE FROM campaign_config_stage
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)`
+
+func (p *BBashDB) DeleteCampaignConfigStage(ctx context.Context, campaignName string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.retryDb().ExecContext(ctx, sqlDeleteCampaignConfigStage, campaignName)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqlSelectCampaignConfigStageForUpdate = sqlSelectCampaignConfigStage + `
+		FOR UPDATE`
+
+const sqlDeleteCampaignBugs = `DELETE FROM bug
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)`
+
+// PromoteCampaignConfigStage replaces the live configuration of the campaign with the staged configuration, and
+// removes the stage. All of this happens in one transaction, so scoring never sees a half applied configuration.
+func (p *BBashDB) PromoteCampaignConfigStage(ctx context.Context, campaignName string) (config *types.CampaignConfigStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.retryDb().BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	config, err = selectCampaignConfigStage(tx.QueryRowContext(ctx, sqlSelectCampaignConfigStageForUpdate, campaignName))
+	if err != nil {
+		return
+	}
+
+	_, err = tx.ExecContext(ctx, sqlDeleteCampaignBugs, campaignName)
+	if err != nil {
+		return
+	}
+
+	for i := range config.Bugs {
+		bug := &config.Bugs[i]
+		err = tx.QueryRowContext(ctx, sqlInsertBug, campaignName, bug.Category, bug.PointValue).Scan(&bug.Id)
+		if err != nil {
+			p.logger.Error("error promoting bug", zap.Any("bug", bug), zap.Error(err))
+			return
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, sqlDeleteCampaignConfigStage, campaignName)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+const sqlUpsertSlackNotification = `INSERT INTO slack_notification
+		(fk_campaign, webhook_url, point_threshold, notify_lead)
+		SELECT id, $2, $3, $4 FROM campaign WHERE name = $1
+		ON CONFLICT (fk_campaign) DO
+			UPDATE SET webhook_url = $2, point_threshold = $3, notify_lead = $4
+		RETURNING fk_campaign`
+
+// UpsertSlackNotification sets the Slack webhook of the campaign. Returns sql.ErrNoRows if there is no such campaign.
+func (p *BBashDB) UpsertSlackNotification(ctx context.Context, config *types.SlackNotificationStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var campaignId string
+	err = p.retryDb().QueryRowContext(ctx, sqlUpsertSlackNotification, config.CampaignName, config.WebhookUrl, config.PointThreshold, config.NotifyLead).
+		Scan(&campaignId)
+	return
+}
+
+const sqlSelectSlackNotification = `SELECT webhook_url, point_threshold, notify_lead
+		FROM slack_notification
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)`
+
+func (p *BBashDB) SelectSlackNotification(ctx context.Context, campaignName string) (config *types.SlackNotificationStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	config = &types.SlackNotificationStruct{CampaignName: campaignName}
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectSlackNotification, campaignName).
+		Scan(&config.WebhookUrl, &config.PointThreshold, &config.NotifyLead)
+	if err != nil {
+		config = nil
+	}
+	return
+}
+
+const sqlDeleteSlackNotification = `DELETE FROM slack_notification
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)`
+
+func (p *BBashDB) DeleteSlackNotification(ctx context.Context, campaignName string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.retryDb().ExecContext(ctx, sqlDeleteSlackNotification, campaignName)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqlUpsertCampaignPenalty = `INSERT INTO campaign_penalty
+		(fk_campaign, enabled, point_value, floor)
+		SELECT id, $2, $3, $4 FROM campaign WHERE name = $1
+		ON CONFLICT (fk_campaign) DO
+			UPDATE SET enabled = $2, point_value = $3, floor = $4
+		RETURNING fk_campaign`
+
+// UpsertCampaignPenalty sets the introduced bug penalty of the campaign. Returns sql.ErrNoRows if there is no such
+// campaign.
+func (p *BBashDB) UpsertCampaignPenalty(ctx context.Context, penalty *types.CampaignPenaltyStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var campaignId string
+	err = p.retryDb().QueryRowContext(ctx, sqlUpsertCampaignPenalty, penalty.CampaignName, penalty.Enabled, penalty.PointValue, penalty.Floor).
+		Scan(&campaignId)
+	return
+}
+
+const sqlSelectCampaignPenalty = `SELECT enabled, point_value, floor
+		FROM campaign_penalty
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)`
+
+func (p *BBashDB) SelectCampaignPenalty(ctx context.Context, campaignName string) (penalty *types.CampaignPenaltyStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	penalty = &types.CampaignPenaltyStruct{CampaignName: campaignName}
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectCampaignPenalty, campaignName).
+		Scan(&penalty.Enabled, &penalty.PointValue, &penalty.Floor)
+	if err != nil {
+		penalty = nil
+	}
+	return
+}
+
+const sqlUpsertCampaignCaps = `INSERT INTO campaign_caps
+		(fk_campaign, per_pull_request, per_day)
+		SELECT id, $2, $3 FROM campaign WHERE name = $1
+		ON CONFLICT (fk_campaign) DO
+			UPDATE SET per_pull_request = $2, per_day = $3
+		RETURNING fk_campaign`
+
+// UpsertCampaignCaps sets the scoring caps of the campaign. Returns sql.ErrNoRows if there is no such campaign.
+func (p *BBashDB) UpsertCampaignCaps(ctx context.Context, caps *types.CampaignCapsStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var campaignId string
+	err = p.retryDb().QueryRowContext(ctx, sqlUpsertCampaignCaps, caps.CampaignName, caps.PerPullRequest, caps.PerDay).
+		Scan(&campaignId)
+	return
+}
+
+const sqlUpsertDuplicatePolicy = `INSERT INTO campaign_duplicate_policy
+		(fk_campaign, policy, weight)
+		SELECT id, $2, $3 FROM campaign WHERE name = $1
+		ON CONFLICT (fk_campaign) DO
+			UPDATE SET policy = $2, weight = $3
+		RETURNING fk_campaign`
+
+// UpsertDuplicatePolicy sets how the campaign scores duplicate fixes. Returns sql.ErrNoRows if there is no such
+// campaign.
+func (p *BBashDB) UpsertDuplicatePolicy(ctx context.Context, policy *types.DuplicatePolicyStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var campaignId string
+	err = p.retryDb().QueryRowContext(ctx, sqlUpsertDuplicatePolicy, policy.CampaignName, policy.Policy, policy.Weight).
+		Scan(&campaignId)
+	return
+}
+
+const sqlSelectDuplicatePolicy = `SELECT policy, weight
+		FROM campaign_duplicate_policy
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)`
+
+func (p *BBashDB) SelectDuplicatePolicy(ctx context.Context, campaignName string) (policy *types.DuplicatePolicyStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	policy = &types.DuplicatePolicyStruct{CampaignName: campaignName}
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectDuplicatePolicy, campaignName).
+		Scan(&policy.Policy, &policy.Weight)
+	if err != nil {
+		policy = nil
+	}
+	return
+}
+
+const sqlUpsertCoAuthorPolicy = `INSERT INTO campaign_coauthor_policy
+		(fk_campaign, policy)
+		SELECT id, $2 FROM campaign WHERE name = $1
+		ON CONFLICT (fk_campaign) DO
+			UPDATE SET policy = $2
+		RETURNING fk_campaign`
+
+// UpsertCoAuthorPolicy sets how the campaign scores the co-authors of a pull request. Returns sql.ErrNoRows if there
+// is no such campaign.
+func (p *BBashDB) UpsertCoAuthorPolicy(ctx context.Context, policy *types.CoAuthorPolicyStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var campaignId string
+	err = p.retryDb().QueryRowContext(ctx, sqlUpsertCoAuthorPolicy, policy.CampaignName, policy.Policy).
+		Scan(&campaignId)
+	return
+}
+
+const sqlSelectCoAuthorPolicy = `SELECT policy
+		FROM campaign_coauthor_policy
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)`
+
+func (p *BBashDB) SelectCoAuthorPolicy(ctx context.Context, campaignName string) (policy *types.CoAuthorPolicyStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	policy = &types.CoAuthorPolicyStruct{CampaignName: campaignName}
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectCoAuthorPolicy, campaignName).Scan(&policy.Policy)
+	if err != nil {
+		policy = nil
+	}
+	return
+}
+
+const sqlUpsertCampaignPrivacy = `INSERT INTO campaign_privacy
+		(fk_campaign, anonymize)
+		SELECT id, $2 FROM campaign WHERE name = $1
+		ON CONFLICT (fk_campaign) DO
+			UPDATE SET anonymize = $2
+		RETURNING salt`
+
+// UpsertCampaignPrivacy sets whether the public leaderboards of the campaign show pseudonyms. The salt of the campaign
+// is made on its first privacy setting, and kept after. Returns sql.ErrNoRows if there is no such campaign.
+func (p *BBashDB) UpsertCampaignPrivacy(ctx context.Context, privacy *types.CampaignPrivacyStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlUpsertCampaignPrivacy, privacy.CampaignName, privacy.Anonymize).
+		Scan(&privacy.Salt)
+	return
+}
+
+const sqlSelectCampaignPrivacy = `SELECT anonymize, salt
+		FROM campaign_privacy
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)`
+
+func (p *BBashDB) SelectCampaignPrivacy(ctx context.Context, campaignName string) (privacy *types.CampaignPrivacyStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	privacy = &types.CampaignPrivacyStruct{CampaignName: campaignName}
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectCampaignPrivacy, campaignName).
+		Scan(&privacy.Anonymize, &privacy.Salt)
+	if err != nil {
+		privacy = nil
+	}
+	return
+}
+
+const sqlSelectCampaignCaps = `SELECT per_pull_request, per_day
+		FROM campaign_caps
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)`
+
+func (p *BBashDB) SelectCampaignCaps(ctx context.Context, campaignName string) (caps *types.CampaignCapsStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	caps = &types.CampaignCapsStruct{CampaignName: campaignName}
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectCampaignCaps, campaignName).
+		Scan(&caps.PerPullRequest, &caps.PerDay)
+	if err != nil {
+		caps = nil
+	}
+	return
+}
+
+const sqlSelectUnclassifiedBonus = `SELECT unclassified_bonus FROM campaign WHERE name = $1`
+
+// SelectUnclassifiedBonus reads the points the campaign scores for each fixed bug without a category.
+// sql.ErrNoRows is returned when there is no such campaign.
+func (p *BBashDB) SelectUnclassifiedBonus(ctx context.Context, campaignName string) (bonus float64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectUnclassifiedBonus, campaignName).Scan(&bonus)
+	return
+}
+
+const sqlSelectTopParticipants = `SELECT participant.Id, login_name, Score
+		FROM participant
+		INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+		WHERE campaign.name = $1
+		ORDER BY Score DESC, JoinedAt
+		LIMIT $2`
+
+// SelectTopParticipants reads the highest scoring participants of the campaign, from the primary so a score just
+// written is included.
+func (p *BBashDB) SelectTopParticipants(ctx context.Context, campaignName string, count int) (participants []types.ParticipantStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryDb().QueryContext(ctx, sqlSelectTopParticipants, campaignName, count)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		participant := types.ParticipantStruct{CampaignName: campaignName}
+		err = rows.Scan(&participant.ID, &participant.LoginName, &participant.Score)
+		if err != nil {
+			return
+		}
+		participants = append(participants, participant)
+	}
+	return
+}
+
+const sqlSelectLeaderboardStale = `SELECT EXISTS (SELECT 1
+		FROM participant
+		INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+		LEFT JOIN team ON participant.fk_team = team.Id
+		FULL OUTER JOIN leaderboard_rank ON leaderboard_rank.fk_participant = participant.Id
+		WHERE participant.Id IS NULL
+		   OR leaderboard_rank.fk_participant IS NULL
+		   OR participant.updated_on <> leaderboard_rank.participant_updated_on
+		   OR team.updated_on IS DISTINCT FROM leaderboard_rank.team_updated_on
+		   OR campaign.updated_on <> leaderboard_rank.campaign_updated_on)`
+
+// SelectLeaderboardStale tells if a participant, team or campaign tie breaker was added, removed or changed since the
+// leaderboard ranks were last refreshed.
+func (p *BBashDB) SelectLeaderboardStale(ctx context.Context) (stale bool, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectLeaderboardStale).Scan(&stale)
+	return
+}
+
+const sqlRefreshLeaderboard = `REFRESH MATERIALIZED VIEW CONCURRENTLY leaderboard_rank`
+
+// RefreshLeaderboard recomputes the leaderboard ranks of every campaign from the current scores. The prior ranks are
+// still read while it runs.
+func (p *BBashDB) RefreshLeaderboard(ctx context.Context) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err = p.retryDb().ExecContext(ctx, sqlRefreshLeaderboard)
+	return
+}
+
+const sqlSelectLeaderboardTieBreaker = `SELECT tie_breaker FROM campaign WHERE name = $1`
+
+const sqlSelectLeaderboard = `SELECT rank, scp_name, login_name, display_name, avatar_url, COALESCE(team_name, ''), score,
+		refreshed_on
+		FROM leaderboard_rank
+		INNER JOIN campaign ON leaderboard_rank.fk_campaign = campaign.Id
+		WHERE campaign.name = $1
+		ORDER BY rank
+		LIMIT $2`
+
+// SelectLeaderboard reads the highest ranked participants of the campaign, as of the last RefreshLeaderboard.
+// sql.ErrNoRows is returned when there is no such campaign.
+func (p *BBashDB) SelectLeaderboard(ctx context.Context, campaignName string, limit int) (leaderboard *types.Leaderboard, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var tieBreaker string
+	err = p.retryReadDb().QueryRowContext(ctx, sqlSelectLeaderboardTieBreaker, campaignName).Scan(&tieBreaker)
+	if err != nil {
+		return
+	}
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectLeaderboard, campaignName, limit)
+	if err != nil {
+		return
+	}
+
+	leaderboard = &types.Leaderboard{
+		CampaignName:   campaignName,
+		TieBreaker:     tieBreaker,
+		TieBreakerRule: types.TieBreakerRules[tieBreaker],
+		Participants:   []types.LeaderboardEntry{},
+	}
+	for rows.Next() {
+		entry := types.LeaderboardEntry{}
+		var refreshedOn time.Time
+		err = rows.Scan(
+			&entry.Rank,
+			&entry.ScpName,
+			&entry.LoginName,
+			&entry.DisplayName,
+			&entry.AvatarUrl,
+			&entry.TeamName,
+			&entry.Score,
+			&refreshedOn,
 		)
 		if err != nil {
 			return
 		}
-		if nullableTeamName.Valid {
-			participant.TeamName = nullableTeamName.String
-		}
-		participants = append(participants, *participant)
+		leaderboard.RefreshedOn = &refreshedOn
+		leaderboard.Participants = append(leaderboard.Participants, entry)
+	}
+	return
+}
+
+const sqlSelectTeamAwardPoints = `SELECT COALESCE((SELECT SUM(points) FROM team_score_event WHERE fk_team = team.Id), 0)
+		FROM team
+		INNER JOIN campaign ON team.fk_campaign = campaign.Id
+		WHERE campaign.name = $1
+		  AND team.name = $2`
+
+const sqlSelectTeamLeaderboard = `SELECT ROW_NUMBER() OVER (ORDER BY rank), scp_name, login_name, display_name, avatar_url, team_name, score,
+		refreshed_on
+		FROM leaderboard_rank
+		INNER JOIN campaign ON leaderboard_rank.fk_campaign = campaign.Id
+		WHERE campaign.name = $1
+		  AND team_name = $2
+		ORDER BY rank`
+
+// SelectTeamLeaderboard ranks the members of the team against each other, in the order of the campaign leaderboard as
+// of the last RefreshLeaderboard. The member points of the team add up the ranked scores, so they match the ranks.
+// sql.ErrNoRows is returned when there is no such team.
+func (p *BBashDB) SelectTeamLeaderboard(ctx context.Context, campaignName, teamName string) (leaderboard *types.TeamLeaderboard, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var awardPoints float64
+	err = p.retryReadDb().QueryRowContext(ctx, sqlSelectTeamAwardPoints, campaignName, teamName).Scan(&awardPoints)
+	if err != nil {
+		return
+	}
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectTeamLeaderboard, campaignName, teamName)
+	if err != nil {
+		return
+	}
+
+	leaderboard = &types.TeamLeaderboard{
+		CampaignName: campaignName,
+		TeamStanding: types.TeamStanding{TeamName: teamName, AwardPoints: awardPoints},
+		Participants: []types.LeaderboardEntry{},
+	}
+	for rows.Next() {
+		entry := types.LeaderboardEntry{}
+		var refreshedOn time.Time
+		err = rows.Scan(
+			&entry.Rank,
+			&entry.ScpName,
+			&entry.LoginName,
+			&entry.DisplayName,
+			&entry.AvatarUrl,
+			&entry.TeamName,
+			&entry.Score,
+			&refreshedOn,
+		)
+		if err != nil {
+			return
+		}
+		leaderboard.RefreshedOn = &refreshedOn
+		leaderboard.MemberPoints += entry.Score
+		leaderboard.Participants = append(leaderboard.Participants, entry)
+	}
+	leaderboard.Points = leaderboard.MemberPoints + leaderboard.AwardPoints
+	return
+}
+
+const sqlInsertLeaderboardSnapshot = `INSERT INTO leaderboard_snapshot
+		(fk_campaign, tie_breaker, finalized_on)
+		SELECT Id, tie_breaker, $2 FROM campaign WHERE name = $1
+		ON CONFLICT (fk_campaign) DO NOTHING
+		RETURNING fk_campaign`
+
+const sqlInsertLeaderboardSnapshotEntries = `INSERT INTO leaderboard_snapshot_entry
+		(fk_campaign, rank, scp_name, login_name, display_name, avatar_url, team_name, score)
+		SELECT fk_campaign, rank, scp_name, login_name, display_name, avatar_url, COALESCE(team_name, ''), score
+		FROM leaderboard_rank
+		WHERE fk_campaign = $1`
+
+// InsertLeaderboardSnapshot copies the leaderboard ranks of the campaign, as of the last RefreshLeaderboard, into its
+// final snapshot. A campaign is only finalized once, so finalized is false when the campaign has a snapshot already,
+// or there is no such campaign.
+func (p *BBashDB) InsertLeaderboardSnapshot(ctx context.Context, campaignName string, now time.Time) (finalized bool, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := p.retryDb().BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var campaignId string
+	err = tx.QueryRowContext(ctx, sqlInsertLeaderboardSnapshot, campaignName, now).Scan(&campaignId)
+	if err == sql.ErrNoRows {
+		err = tx.Rollback()
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	_, err = tx.ExecContext(ctx, sqlInsertLeaderboardSnapshotEntries, campaignId)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	finalized = err == nil
+	return
+}
+
+const sqlSelectLeaderboardSnapshot = `SELECT leaderboard_snapshot.tie_breaker, leaderboard_snapshot.finalized_on
+		FROM leaderboard_snapshot
+		INNER JOIN campaign ON leaderboard_snapshot.fk_campaign = campaign.Id
+		WHERE campaign.name = $1`
+
+const sqlSelectLeaderboardSnapshotEntries = `SELECT rank, scp_name, login_name, display_name, avatar_url, team_name, score
+		FROM leaderboard_snapshot_entry
+		INNER JOIN campaign ON leaderboard_snapshot_entry.fk_campaign = campaign.Id
+		WHERE campaign.name = $1
+		ORDER BY rank`
+
+// SelectLeaderboardSnapshot reads the final snapshot of the leaderboard of the campaign. sql.ErrNoRows is returned when
+// the campaign is not finalized, or there is no such campaign.
+func (p *BBashDB) SelectLeaderboardSnapshot(ctx context.Context, campaignName string) (snapshot *types.LeaderboardSnapshot, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	snapshot = &types.LeaderboardSnapshot{
+		CampaignName: campaignName,
+		Participants: []types.LeaderboardEntry{},
+	}
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectLeaderboardSnapshot, campaignName).
+		Scan(&snapshot.TieBreaker, &snapshot.FinalizedOn)
+	if err != nil {
+		snapshot = nil
+		return
+	}
+	snapshot.TieBreakerRule = types.TieBreakerRules[snapshot.TieBreaker]
+
+	rows, err := p.retryDb().QueryContext(ctx, sqlSelectLeaderboardSnapshotEntries, campaignName)
+	if err != nil {
+		snapshot = nil
+		return
+	}
+
+	for rows.Next() {
+		entry := types.LeaderboardEntry{}
+		err = rows.Scan(
+			&entry.Rank,
+			&entry.ScpName,
+			&entry.LoginName,
+			&entry.DisplayName,
+			&entry.AvatarUrl,
+			&entry.TeamName,
+			&entry.Score,
+		)
+		if err != nil {
+			snapshot = nil
+			return
+		}
+		snapshot.Participants = append(snapshot.Participants, entry)
+	}
+	return
+}
+
+const sqlUpsertCampaignEmail = `INSERT INTO campaign_email
+		(fk_campaign, kind, enabled, subject, body)
+		SELECT id, $2, $3, $4, $5 FROM campaign WHERE name = $1
+		ON CONFLICT (fk_campaign, kind) DO
+			UPDATE SET enabled = $3, subject = $4, body = $5
+		RETURNING fk_campaign`
+
+// UpsertCampaignEmail sets the email template of the campaign. Returns sql.ErrNoRows if there is no such campaign.
+func (p *BBashDB) UpsertCampaignEmail(ctx context.Context, email *types.CampaignEmailStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var campaignId string
+	err = p.retryDb().QueryRowContext(ctx, sqlUpsertCampaignEmail, email.CampaignName, email.Kind, email.Enabled, email.Subject, email.Body).
+		Scan(&campaignId)
+	return
+}
+
+const sqlSelectCampaignEmails = `SELECT kind, enabled, subject, body
+		FROM campaign_email
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		ORDER BY kind`
+
+func (p *BBashDB) SelectCampaignEmails(ctx context.Context, campaignName string) (emails []types.CampaignEmailStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryDb().QueryContext(ctx, sqlSelectCampaignEmails, campaignName)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		email := types.CampaignEmailStruct{CampaignName: campaignName}
+		err = rows.Scan(&email.Kind, &email.Enabled, &email.Subject, &email.Body)
+		if err != nil {
+			return
+		}
+		emails = append(emails, email)
+	}
+	return
+}
+
+const sqlSelectCampaignEmail = `SELECT enabled, subject, body
+		FROM campaign_email
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+			AND kind = $2`
+
+func (p *BBashDB) SelectCampaignEmail(ctx context.Context, campaignName, kind string) (email *types.CampaignEmailStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	email = &types.CampaignEmailStruct{CampaignName: campaignName, Kind: kind}
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectCampaignEmail, campaignName, kind).
+		Scan(&email.Enabled, &email.Subject, &email.Body)
+	if err != nil {
+		email = nil
+	}
+	return
+}
+
+// the classified and unclassified counts of a breakdown are numbers of fixed bugs. Breakdowns recorded before the
+// unclassified count hold only the bonus, which was one point for each unclassified bug until campaigns could change
+// it. Events scored before breakdowns were recorded count no bugs.
+const sqlSelectBugsFixed = `SELECT COALESCE(SUM(COALESCE((breakdown->>'classified')::numeric, 0)
+				+ COALESCE((breakdown->>'unclassified')::numeric, (breakdown->>'unclassifiedBonus')::numeric, 0)), 0)
+		FROM scoring_event
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		  AND fk_scp = (SELECT id FROM source_control_provider WHERE name = $2)
+		  AND username = $3
+		  AND voided_on IS NULL`
+
+// SelectBugsFixed reads the number of bugs fixed by the participant in all scored pull requests of the campaign.
+func (p *BBashDB) SelectBugsFixed(ctx context.Context, participant *types.ParticipantStruct) (bugsFixed float64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectBugsFixed, participant.CampaignName, participant.ScpName, participant.LoginName).
+		Scan(&bugsFixed)
+	return
+}
+
+const sqlInsertAchievement = `INSERT INTO achievement
+		(fk_participant, kind, awarded_on)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (fk_participant, kind) DO NOTHING`
+
+// InsertAchievement awards the achievement to the participant. awarded is false if the participant already had it.
+func (p *BBashDB) InsertAchievement(ctx context.Context, participantId, kind string, now time.Time) (awarded bool, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.retryDb().ExecContext(ctx, sqlInsertAchievement, participantId, kind, now)
+	if err != nil {
+		return
+	}
+	var rowsAffected int64
+	rowsAffected, err = res.RowsAffected()
+	awarded = rowsAffected > 0
+	return
+}
+
+const sqlSelectAchievements = `SELECT kind, awarded_on
+		FROM achievement
+		WHERE fk_participant = ` + sqlSelectParticipantIdByName + `
+		ORDER BY awarded_on, kind`
+
+func (p *BBashDB) SelectAchievements(ctx context.Context, campaignName, scpName, loginName string) (achievements []types.AchievementStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectAchievements, campaignName, scpName, loginName)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		achievement := types.AchievementStruct{}
+		err = rows.Scan(&achievement.Kind, &achievement.AwardedOn)
+		if err != nil {
+			return
+		}
+		achievements = append(achievements, achievement)
+	}
+	return
+}
+
+// the latest revision of each scoring event, and of each share of a co-author, at or before the time. A voided event
+// is revised to 0 points when voided, so it still counts before then.
+const sqlSelectParticipantScoreAt = `SELECT COALESCE(SUM(revision.points), 0),
+			COUNT(revision.points) FILTER (WHERE NOT revision.coauthor AND NOT revision.voided)
+		FROM participant
+		INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+		INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+		LEFT JOIN (SELECT scoring_event.fk_campaign, scoring_event.fk_scp, scoring_event_revision.coauthor,
+				scoring_event_revision.points, scoring_event_revision.voided,
+				ROW_NUMBER() OVER (PARTITION BY scoring_event_revision.fk_scoring_event, scoring_event_revision.coauthor
+					ORDER BY scoring_event_revision.revised_on DESC, scoring_event_revision.Id DESC) AS latest
+			FROM scoring_event_revision
+			INNER JOIN scoring_event ON scoring_event_revision.fk_scoring_event = scoring_event.Id
+			WHERE scoring_event_revision.username = $3
+				AND scoring_event_revision.revised_on <= $4) AS revision
+			ON revision.fk_campaign = participant.fk_campaign
+			AND revision.fk_scp = participant.fk_scp
+			AND revision.latest = 1
+		WHERE campaign.name = $1
+		  AND source_control_provider.name = $2
+		  AND participant.login_name = $3
+		GROUP BY participant.Id`
+
+// SelectParticipantScoreAt rebuilds the score the participant had at the time from the revisions of the scoring
+// events, with the shares of the pull requests they co-authored. A pull request scored again after the time counts
+// with the points it had then. sql.ErrNoRows is returned when there is no such participant.
+func (p *BBashDB) SelectParticipantScoreAt(ctx context.Context, campaignName, scpName, loginName string, at time.Time) (score *types.ParticipantScoreAt, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	score = &types.ParticipantScoreAt{CampaignName: campaignName, ScpName: scpName, LoginName: loginName, At: at}
+	err = p.retryReadDb().QueryRowContext(ctx, sqlSelectParticipantScoreAt, campaignName, scpName, loginName, at).
+		Scan(&score.Score, &score.ScoringEvents)
+	if err != nil {
+		score = nil
+	}
+	return
+}
+
+const sqlSelectAchievementStats = `SELECT kind, COUNT(*)
+		FROM achievement
+		INNER JOIN participant ON achievement.fk_participant = participant.Id
+		INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+		WHERE campaign.name = $1
+		GROUP BY kind
+		ORDER BY kind`
+
+// SelectAchievementStats counts the participants of the campaign holding each achievement. Achievements nobody has
+// earned are not included.
+func (p *BBashDB) SelectAchievementStats(ctx context.Context, campaignName string) (stats []types.AchievementStat, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectAchievementStats, campaignName)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		stat := types.AchievementStat{}
+		err = rows.Scan(&stat.Kind, &stat.Participants)
+		if err != nil {
+			return
+		}
+		stats = append(stats, stat)
+	}
+	return
+}
+
+const sqlInsertWebhook = `INSERT INTO webhook
+		(target_url, secret, events)
+		VALUES ($1, $2, $3)
+		RETURNING Id, created_on`
+
+func (p *BBashDB) InsertWebhook(ctx context.Context, hook *types.WebhookStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	if hook.Events == nil {
+		hook.Events = []string{}
+	}
+	var eventsJson []byte
+	eventsJson, err = json.Marshal(hook.Events)
+	if err != nil {
+		return
+	}
+	err = p.retryDb().QueryRowContext(ctx, sqlInsertWebhook, hook.TargetUrl, hook.Secret, eventsJson).
+		Scan(&hook.ID, &hook.CreatedOn)
+	return
+}
+
+const sqlSelectWebhooks = `SELECT Id, target_url, secret, events, created_on
+		FROM webhook
+		ORDER BY created_on`
+
+func (p *BBashDB) SelectWebhooks(ctx context.Context) (hooks []types.WebhookStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryDb().QueryContext(ctx, sqlSelectWebhooks)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		hook := types.WebhookStruct{}
+		var eventsJson []byte
+		err = rows.Scan(&hook.ID, &hook.TargetUrl, &hook.Secret, &eventsJson, &hook.CreatedOn)
+		if err != nil {
+			return
+		}
+		err = json.Unmarshal(eventsJson, &hook.Events)
+		if err != nil {
+			return
+		}
+		hooks = append(hooks, hook)
+	}
+	return
+}
+
+// compared as text, so an id that is not a uuid matches nothing instead of failing
+const sqlDeleteWebhook = `DELETE FROM webhook WHERE Id::text = $1`
+
+func (p *BBashDB) DeleteWebhook(ctx context.Context, webhookId string) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.retryDb().ExecContext(ctx, sqlDeleteWebhook, webhookId)
+	if err != nil {
+		return
+	}
+	rowsAffected, err = res.RowsAffected()
+	return
+}
+
+const sqlClaimCampaignTransitions = `UPDATE campaign
+		SET status = claimed.to_status,
+			ended_notified_on = CASE WHEN claimed.to_status = 'ended' THEN COALESCE(campaign.ended_notified_on, $1::TIMESTAMPTZ)
+				ELSE campaign.ended_notified_on END
+		FROM (SELECT Id, status AS from_status, ended_notified_on IS NULL AS first_end,
+				CASE WHEN $1::TIMESTAMPTZ < start_on AT TIME ZONE time_zone THEN 'upcoming'
+					WHEN $1::TIMESTAMPTZ < end_on AT TIME ZONE time_zone THEN 'active'
+					ELSE 'ended' END AS to_status
+			FROM campaign
+			FOR UPDATE) AS claimed
+		WHERE campaign.Id = claimed.Id
+			AND claimed.to_status <> claimed.from_status
+		RETURNING campaign.ID, campaign.name, campaign.created_on, campaign.create_order, campaign.start_on AT TIME ZONE campaign.time_zone,
+			campaign.end_on AT TIME ZONE campaign.time_zone, campaign.note, campaign.max_team_size, campaign.tie_breaker,
+			campaign.unclassified_bonus, campaign.time_zone, campaign.status, campaign.freeze_scoring,
+			campaign.registration_opens_on AT TIME ZONE campaign.time_zone, campaign.registration_closes_on AT TIME ZONE campaign.time_zone,
+			campaign.version, claimed.from_status, claimed.first_end`
+
+// ClaimCampaignTransitions moves each campaign to the status it has at now, and returns the campaigns that changed
+// status since the last call. Each change is returned once, even when called from many replicas, as the campaigns are
+// locked while they change. A campaign changes back when its start or end is moved later.
+func (p *BBashDB) ClaimCampaignTransitions(ctx context.Context, now time.Time) (transitions []types.CampaignTransition, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryDb().QueryContext(ctx, sqlClaimCampaignTransitions, now)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		transition := types.CampaignTransition{}
+		campaign := &transition.Campaign
+		err = rows.Scan(&campaign.ID, &campaign.Name, &campaign.CreatedOn, &campaign.CreatedOrder, &campaign.StartOn, &campaign.EndOn, &campaign.Note, &campaign.MaxTeamSize, &campaign.TieBreaker, &campaign.UnclassifiedBonus, &campaign.TimeZone, &campaign.Status, &campaign.FreezeScoring, &campaign.RegistrationOpensOn, &campaign.RegistrationClosesOn,
+			&campaign.Version, &transition.FromStatus, &transition.FirstEnd)
+		if err != nil {
+			return
+		}
+		transitions = append(transitions, transition)
+	}
+	return
+}
+
+const sqlUpsertClusterInstance = `INSERT INTO cluster_instance
+		(instance_id, version, role, poll_leader, started_on, last_heartbeat)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (instance_id) DO
+			UPDATE SET version = $2, role = $3, poll_leader = $4, started_on = $5, last_heartbeat = $6`
+
+func (p *BBashDB) UpsertClusterInstance(ctx context.Context, instance *types.ClusterInstance) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err = p.retryDb().ExecContext(ctx, sqlUpsertClusterInstance,
+		instance.InstanceId,
+		instance.Version,
+		instance.Role,
+		instance.PollLeader,
+		instance.StartedOn,
+		instance.LastHeartbeat,
+	)
+	return
+}
+
+const sqlSelectClusterInstances = `SELECT instance_id, version, role, poll_leader, started_on, last_heartbeat
+		FROM cluster_instance
+		ORDER BY last_heartbeat DESC`
+
+func (p *BBashDB) SelectClusterInstances(ctx context.Context) (instances []types.ClusterInstance, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryDb().QueryContext(ctx, sqlSelectClusterInstances)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		instance := types.ClusterInstance{}
+		err = rows.Scan(&instance.InstanceId, &instance.Version, &instance.Role, &instance.PollLeader,
+			&instance.StartedOn, &instance.LastHeartbeat)
+		if err != nil {
+			return
+		}
+		instances = append(instances, instance)
+	}
+	return
+}
+
+// the lease is taken when there is none, renewed by its holder, or taken over once it has expired. Otherwise no row
+// changes.
+const sqlAcquirePollLease = `INSERT INTO poll_lease
+		(Id, instance_id, expires_on)
+		VALUES (TRUE, $1, $3)
+		ON CONFLICT (Id) DO
+			UPDATE SET instance_id = $1, expires_on = $3
+			WHERE poll_lease.instance_id = $1 OR poll_lease.expires_on <= $2`
+
+// AcquirePollLease takes or renews the lease on the datadog poll for the instance until expiresOn, unless another
+// instance holds it as of now. Held reports whether the instance holds the lease.
+func (p *BBashDB) AcquirePollLease(ctx context.Context, instanceId string, now, expiresOn time.Time) (held bool, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, sqlAcquirePollLease, instanceId, now, expiresOn)
+	if err != nil {
+		return
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	held = rowsAffected == 1
+	return
+}
+
+const sqlReleasePollLease = `DELETE FROM poll_lease WHERE instance_id = $1`
+
+// ReleasePollLease gives up the lease on the datadog poll, if the instance holds it, so another instance can take the
+// poll over without waiting for the lease to expire.
+func (p *BBashDB) ReleasePollLease(ctx context.Context, instanceId string) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err = p.retryDb().ExecContext(ctx, sqlReleasePollLease, instanceId)
+	return
+}
+
+// a run replaces the latest run of the job, while the counts add up
+const sqlUpsertScheduledJobRun = `INSERT INTO scheduled_job_run
+		(name, instance_id, started_on, finished_on, error, runs, failures)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), 1, $6)
+		ON CONFLICT (name) DO
+			UPDATE SET instance_id = $2, started_on = $3, finished_on = $4, error = NULLIF($5, ''),
+				runs = scheduled_job_run.runs + 1, failures = scheduled_job_run.failures + $6`
+
+// UpsertScheduledJobRun saves the run of a scheduled job as its latest, counting it with the runs of every replica.
+// Runs and Failures of the run are ignored.
+func (p *BBashDB) UpsertScheduledJobRun(ctx context.Context, run *types.ScheduledJobRun) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	failures := 0
+	if run.Error != "" {
+		failures = 1
+	}
+	_, err = p.retryDb().ExecContext(ctx, sqlUpsertScheduledJobRun, run.Name, run.InstanceId, run.StartedOn,
+		run.FinishedOn, run.Error, failures)
+	return
+}
+
+const sqlSelectScheduledJobRuns = `SELECT name, instance_id, started_on, finished_on, COALESCE(error, ''), runs, failures
+		FROM scheduled_job_run
+		ORDER BY name`
+
+func (p *BBashDB) SelectScheduledJobRuns(ctx context.Context) (runs []types.ScheduledJobRun, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectScheduledJobRuns)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		run := types.ScheduledJobRun{}
+		err = rows.Scan(&run.Name, &run.InstanceId, &run.StartedOn, &run.FinishedOn, &run.Error, &run.Runs, &run.Failures)
+		if err != nil {
+			return
+		}
+		runs = append(runs, run)
 	}
+	err = rows.Err()
 	return
 }
 
-const sqlUpdateParticipant = `UPDATE participant 
-		SET 
-		    fk_campaign = (SELECT Id FROM campaign WHERE name = $1),
-		    fk_scp = (SELECT Id FROM source_control_provider WHERE name = $2),
-		    login_name = $3,
-		    Email = $4,
-		    DisplayName = $5,
-		    Score = $6,
-		    fk_team = (SELECT Id FROM team WHERE name = $7)		    
-		WHERE Id = $8`
+const sqlSelectMaintenance = `SELECT message, since FROM maintenance`
 
-func (p *BBashDB) UpdateParticipant(participant *types.ParticipantStruct) (rowsAffected int64, err error) {
-	res, err := p.db.Exec(
-		sqlUpdateParticipant,
-		participant.CampaignName,
-		participant.ScpName,
-		participant.LoginName,
-		participant.Email,
-		participant.DisplayName,
-		participant.Score,
-		participant.TeamName,
-		participant.ID,
-	)
+// SelectMaintenance reads whether maintenance is on, which it is while its row exists.
+func (p *BBashDB) SelectMaintenance(ctx context.Context) (state types.MaintenanceState, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var since time.Time
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectMaintenance).Scan(&state.Message, &since)
+	if err == sql.ErrNoRows {
+		err = nil
+		return
+	}
 	if err != nil {
 		return
 	}
+	state.Enabled, state.Since = true, &since
+	return
+}
 
-	rowsAffected, err = res.RowsAffected()
+// the time maintenance was turned on is kept while it stays on
+const sqlUpsertMaintenance = `INSERT INTO maintenance
+		(Id, message, since)
+		VALUES (TRUE, $1, $2)
+		ON CONFLICT (Id) DO
+			UPDATE SET message = EXCLUDED.message
+		RETURNING since`
+
+const sqlDeleteMaintenance = `DELETE FROM maintenance`
+
+// UpdateMaintenance turns maintenance on with the message, or off.
+func (p *BBashDB) UpdateMaintenance(ctx context.Context, enabled bool, message string, now time.Time) (state types.MaintenanceState, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	if !enabled {
+		_, err = p.retryDb().ExecContext(ctx, sqlDeleteMaintenance)
+		return
+	}
+	var since time.Time
+	err = p.retryDb().QueryRowContext(ctx, sqlUpsertMaintenance, message, now).Scan(&since)
+	if err != nil {
+		return
+	}
+	state = types.MaintenanceState{Enabled: true, Message: message, Since: &since}
 	return
 }
 
-const sqlDeleteParticipant = `DELETE FROM participant WHERE
-                          fk_campaign = (SELECT id from campaign where name =$1)
-                          AND fk_scp = (SELECT id from source_control_provider where name =$2)
-                          AND login_name = $3
-                          RETURNING id`
+const sqlInsertJob = `INSERT INTO job
+		(kind, status, instance_id, done, total, created_on)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING Id`
+
+func (p *BBashDB) InsertJob(ctx context.Context, job *types.Job) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlInsertJob, job.Kind, job.Status, job.InstanceId, job.Done, job.Total, job.CreatedOn).
+		Scan(&job.ID)
+	return
+}
 
-func (p *BBashDB) DeleteParticipant(campaign, scpName, loginName string) (participantId string, err error) {
-	err = p.db.QueryRow(sqlDeleteParticipant, campaign, scpName, loginName).Scan(&participantId)
+const sqlUpdateJobProgress = `UPDATE job SET done = $2, total = $3 WHERE Id = $1`
+
+func (p *BBashDB) UpdateJobProgress(ctx context.Context, jobId string, done, total int64) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err = p.retryDb().ExecContext(ctx, sqlUpdateJobProgress, jobId, done, total)
+	return
+}
+
+const sqlFinishJob = `UPDATE job
+		SET status = $2, done = $3, total = $4, result = $5, error = NULLIF($6, ''), finished_on = $7
+		WHERE Id = $1`
+
+// FinishJob saves the status of the job once it succeeded or failed, with its result or error.
+func (p *BBashDB) FinishJob(ctx context.Context, job *types.Job) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err = p.retryDb().ExecContext(ctx, sqlFinishJob, job.ID, job.Status, job.Done, job.Total, []byte(job.Result),
+		job.Error, job.FinishedOn)
+	return
+}
+
+// compared as text, so an id that is not a uuid matches nothing instead of failing
+const sqlSelectJob = `SELECT Id, kind, status, instance_id, done, total, result, COALESCE(error, ''), created_on,
+			finished_on
+		FROM job
+		WHERE Id::text = $1`
+
+// SelectJob reads the job, from the primary as its progress is updated often. sql.ErrNoRows is returned when there is
+// no such job.
+func (p *BBashDB) SelectJob(ctx context.Context, jobId string) (job *types.Job, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	selected := &types.Job{}
+	var result []byte
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectJob, jobId).Scan(&selected.ID, &selected.Kind, &selected.Status,
+		&selected.InstanceId, &selected.Done, &selected.Total, &result, &selected.Error, &selected.CreatedOn,
+		&selected.FinishedOn)
 	if err != nil {
-		p.logger.Error("error deleting participant",
-			zap.String("campaign", campaign), zap.String("scpName", scpName),
-			zap.String("loginName", loginName), zap.Error(err))
+		return
 	}
+	if result != nil {
+		selected.Result = result
+	}
+	job = selected
 	return
 }
 
-const sqlUpdateParticipantTeam = `UPDATE participant 
-		SET fk_team = (SELECT Id FROM team WHERE name = $1)
-		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $2)
-		 AND fk_scp = (SELECT id FROM source_control_provider WHERE name = $3)
-		 AND login_name = $4`
+const sqlFailInstanceJobs = `UPDATE job
+		SET status = 'failed', error = $2, finished_on = $3
+		WHERE instance_id = $1
+			AND status = 'running'`
 
-func (p *BBashDB) UpdateParticipantTeam(teamName, campaignName, scpName, loginName string) (rowsAffected int64, err error) {
-	res, err := p.db.Exec(
-		sqlUpdateParticipantTeam,
-		teamName,
-		campaignName,
-		scpName,
-		loginName)
+// FailInstanceJobs fails the jobs the replica left running, as when it restarts with the same instance id, with the
+// message as their error.
+func (p *BBashDB) FailInstanceJobs(ctx context.Context, instanceId, message string, now time.Time) (failed int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.retryDb().ExecContext(ctx, sqlFailInstanceJobs, instanceId, message, now)
 	if err != nil {
 		return
 	}
-	rowsAffected, err = res.RowsAffected()
+	return res.RowsAffected()
+}
+
+// a job started since the heartbeat deadline is left alone, as its replica may not have sent its first heartbeat yet
+const sqlFailOrphanedJobs = `UPDATE job
+		SET status = 'failed', error = $2, finished_on = $3
+		WHERE status = 'running'
+			AND created_on < $1
+			AND NOT EXISTS (SELECT 1
+				FROM cluster_instance
+				WHERE cluster_instance.instance_id = job.instance_id
+					AND cluster_instance.last_heartbeat >= $1)`
+
+// FailOrphanedJobs fails the running jobs of the replicas without a heartbeat since the time, as they stopped before
+// finishing them, with the message as their error.
+func (p *BBashDB) FailOrphanedJobs(ctx context.Context, heartbeatBefore time.Time, message string, now time.Time) (failed int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	res, err := p.retryDb().ExecContext(ctx, sqlFailOrphanedJobs, heartbeatBefore, message, now)
 	if err != nil {
 		return
 	}
+	return res.RowsAffected()
+}
+
+const sqlInsertNotification = `INSERT INTO notification
+		(fk_participant, kind, message, created_on)
+		VALUES ($1, $2, $3, $4)
+		RETURNING Id`
+
+func (p *BBashDB) InsertNotification(ctx context.Context, notification *types.NotificationStruct) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlInsertNotification,
+		notification.ParticipantId,
+		notification.Kind,
+		notification.Message,
+		notification.CreatedOn,
+	).Scan(&notification.Id)
+	if err != nil {
+		p.logger.Error("error inserting notification", zap.Any("notification", notification), zap.Error(err))
+	}
 	return
 }
 
-const sqlInsertBug = `INSERT INTO bug
-		(fk_campaign, category, pointValue)
-		VALUES ((SELECT id FROM campaign WHERE name = $1), $2, $3)
-		RETURNING ID`
+const sqlSelectParticipantIdByName = `(SELECT participant.Id FROM participant
+			INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+			INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+			WHERE campaign.name = $1
+			  AND source_control_provider.name = $2
+			  AND login_name = $3)`
+
+const sqlSelectNotifications = `SELECT Id, fk_participant, kind, message, created_on, read_on
+		FROM notification
+		WHERE fk_participant = ` + sqlSelectParticipantIdByName + `
+		  AND (NOT $4 OR read_on IS NULL)
+		ORDER BY created_on DESC`
 
-func (p *BBashDB) InsertBug(bug *types.BugStruct) (err error) {
-	err = p.db.QueryRow(sqlInsertBug, bug.Campaign, bug.Category, bug.PointValue).Scan(&bug.Id)
+// SelectNotifications reads the inbox of the participant, newest first.
+func (p *BBashDB) SelectNotifications(ctx context.Context, campaignName, scpName, loginName string, unreadOnly bool) (notifications []types.NotificationStruct, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryDb().QueryContext(ctx, sqlSelectNotifications, campaignName, scpName, loginName, unreadOnly)
 	if err != nil {
-		p.logger.Error("error inserting bug", zap.Any("bug", bug), zap.Error(err))
 		return
 	}
+
+	for rows.Next() {
+		notification := types.NotificationStruct{}
+		err = rows.Scan(
+			&notification.Id,
+			&notification.ParticipantId,
+			&notification.Kind,
+			&notification.Message,
+			&notification.CreatedOn,
+			&notification.ReadOn,
+		)
+		if err != nil {
+			return
+		}
+		notifications = append(notifications, notification)
+	}
 	return
 }
 
-const sqlUpdateBug = `UPDATE bug
-		SET pointValue = $1
-		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $2) AND category = $3`
+const sqlUpdateNotificationsRead = `UPDATE notification
+		SET read_on = $4
+		WHERE fk_participant = ` + sqlSelectParticipantIdByName + `
+		  AND read_on IS NULL`
+
+const sqlUpdateNotificationRead = sqlUpdateNotificationsRead + `
+		  AND Id = $5`
 
-func (p *BBashDB) UpdateBug(bug *types.BugStruct) (rowsAffected int64, err error) {
-	res, err := p.db.Exec(sqlUpdateBug, bug.PointValue, bug.Campaign, bug.Category)
+// UpdateNotificationsRead marks the given notification of the participant as read, or every unread notification of
+// the participant when notificationId is empty.
+func (p *BBashDB) UpdateNotificationsRead(ctx context.Context, campaignName, scpName, loginName, notificationId string, now time.Time) (rowsAffected int64, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	var res sql.Result
+	if notificationId == "" {
+		res, err = p.retryDb().ExecContext(ctx, sqlUpdateNotificationsRead, campaignName, scpName, loginName, now)
+	} else {
+		res, err = p.retryDb().ExecContext(ctx, sqlUpdateNotificationRead, campaignName, scpName, loginName, now, notificationId)
+	}
 	if err != nil {
 		return
 	}
@@ -593,22 +4827,378 @@ func (p *BBashDB) UpdateBug(bug *types.BugStruct) (rowsAffected int64, err error
 	return
 }
 
-const sqlSelectBugs = `SELECT bug.id, campaign.name, category, pointValue FROM bug
-		INNER JOIN campaign ON fk_campaign = campaign.Id`
+const sqlSelectReportStats = `SELECT
+		(SELECT COUNT(*) FROM participant WHERE fk_campaign = campaign.Id),
+		(SELECT COUNT(*) FROM team WHERE fk_campaign = campaign.Id),
+		(SELECT COUNT(*) FROM scoring_event WHERE fk_campaign = campaign.Id AND voided_on IS NULL),
+		(SELECT COALESCE(SUM(points), 0) FROM scoring_event WHERE fk_campaign = campaign.Id AND voided_on IS NULL),
+		(SELECT COALESCE(SUM(team_score_event.points), 0) FROM team_score_event
+			INNER JOIN team ON team_score_event.fk_team = team.Id
+			WHERE team.fk_campaign = campaign.Id)
+		FROM campaign
+		WHERE campaign.name = $1`
+
+const sqlSelectReportTopParticipants = `SELECT
+		participant.Id, campaign.name, source_control_provider.name, login_name, DisplayName, Score, team.name, JoinedAt
+		FROM participant
+		LEFT JOIN team ON participant.fk_team = team.Id
+		INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+		INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+		WHERE campaign.name = $1
+		ORDER BY Score DESC, login_name
+		LIMIT $2`
+
+const sqlSelectReportTopTeams = `SELECT name, member_points, award_points
+		FROM (SELECT team.name,
+				COALESCE((SELECT SUM(Score) FROM participant WHERE fk_team = team.Id), 0) AS member_points,
+				COALESCE((SELECT SUM(points) FROM team_score_event WHERE fk_team = team.Id), 0) AS award_points
+			FROM team
+			INNER JOIN campaign ON team.fk_campaign = campaign.Id
+			WHERE campaign.name = $1) standings
+		ORDER BY member_points + award_points DESC, name
+		LIMIT $2`
+
+const sqlSelectReportCategories = `SELECT category->>'category', SUM((category->>'count')::numeric), SUM((category->>'points')::numeric)
+		FROM scoring_event,
+			jsonb_array_elements(CASE WHEN jsonb_typeof(breakdown->'categories') = 'array'
+				THEN breakdown->'categories' ELSE jsonb_build_array() END) AS category
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		  AND voided_on IS NULL
+		GROUP BY 1
+		ORDER BY 3 DESC, 1`
+
+const sqlSelectReportRepositories = `SELECT repoOwner, repoName, COUNT(*), SUM(points)
+		FROM scoring_event
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		  AND voided_on IS NULL
+		GROUP BY repoOwner, repoName
+		ORDER BY 4 DESC, 1, 2`
+
+const sqlSelectReportTimeline = `SELECT day, SUM(pull_requests), SUM(points), SUM(joined)
+		FROM (SELECT date_trunc('day', scored_on) AS day, 1 AS pull_requests, points, 0 AS joined
+				FROM scoring_event
+				WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+				  AND scored_on IS NOT NULL
+				  AND voided_on IS NULL
+			UNION ALL
+			SELECT date_trunc('day', JoinedAt), 0, 0, 1
+				FROM participant
+				WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)) timeline
+		GROUP BY day
+		ORDER BY day`
+
+// SelectCampaignReport assembles the end of campaign report. The topCount limits the number of participants and
+// teams listed as winners. sql.ErrNoRows is returned when there is no such campaign.
+func (p *BBashDB) SelectCampaignReport(ctx context.Context, campaignName string, topCount int, now time.Time) (report *types.CampaignReport, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	report = &types.CampaignReport{GeneratedOn: now}
 
-func (p *BBashDB) SelectBugs() (bugs []types.BugStruct, err error) {
-	rows, err := p.db.Query(sqlSelectBugs)
+	campaign := &report.Campaign
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectCampaign, campaignName).
+		Scan(&campaign.ID, &campaign.Name, &campaign.CreatedOn, &campaign.CreatedOrder, &campaign.StartOn, &campaign.EndOn, &campaign.Note, &campaign.MaxTeamSize, &campaign.TieBreaker, &campaign.UnclassifiedBonus, &campaign.TimeZone, &campaign.Status, &campaign.FreezeScoring, &campaign.RegistrationOpensOn, &campaign.RegistrationClosesOn, &campaign.Version)
 	if err != nil {
 		return
 	}
 
+	stats := &report.Stats
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectReportStats, campaignName).
+		Scan(&stats.Participants, &stats.Teams, &stats.PullRequests, &stats.Points, &stats.JudgedAwardPoints)
+	if err != nil {
+		return
+	}
+
+	rows, err := p.retryDb().QueryContext(ctx, sqlSelectReportTopParticipants, campaignName, topCount)
+	if err != nil {
+		return
+	}
 	for rows.Next() {
-		bug := types.BugStruct{}
-		err = rows.Scan(&bug.Id, &bug.Campaign, &bug.Category, &bug.PointValue)
+		participant := types.ParticipantStruct{}
+		var nullableTeamName sql.NullString
+		err = rows.Scan(&participant.ID, &participant.CampaignName, &participant.ScpName, &participant.LoginName,
+			&participant.DisplayName, &participant.Score, &nullableTeamName, &participant.JoinedAt)
 		if err != nil {
 			return
 		}
-		bugs = append(bugs, bug)
+		participant.TeamName = nullableTeamName.String
+		report.TopParticipants = append(report.TopParticipants, participant)
+	}
+
+	rows, err = p.retryDb().QueryContext(ctx, sqlSelectReportTopTeams, campaignName, topCount)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		team := types.TeamStanding{}
+		err = rows.Scan(&team.TeamName, &team.MemberPoints, &team.AwardPoints)
+		if err != nil {
+			return
+		}
+		team.Points = team.MemberPoints + team.AwardPoints
+		report.TopTeams = append(report.TopTeams, team)
+	}
+
+	rows, err = p.retryDb().QueryContext(ctx, sqlSelectReportCategories, campaignName)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		category := types.ReportCategory{}
+		err = rows.Scan(&category.Category, &category.Count, &category.Points)
+		if err != nil {
+			return
+		}
+		report.Categories = append(report.Categories, category)
+	}
+
+	rows, err = p.retryDb().QueryContext(ctx, sqlSelectReportRepositories, campaignName)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		repository := types.RepositoryImpact{}
+		err = rows.Scan(&repository.RepoOwner, &repository.RepoName, &repository.PullRequests, &repository.Points)
+		if err != nil {
+			return
+		}
+		report.Repositories = append(report.Repositories, repository)
+	}
+
+	rows, err = p.retryDb().QueryContext(ctx, sqlSelectReportTimeline, campaignName)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		day := types.TimelineDay{}
+		err = rows.Scan(&day.Day, &day.PullRequests, &day.Points, &day.ParticipantsJoined)
+		if err != nil {
+			return
+		}
+		report.Timeline = append(report.Timeline, day)
+	}
+	return
+}
+
+// a rescored pull request counts at the time it was last scored. Teams are the current team of the participant.
+const sqlSelectScoreTimeSeries = `SELECT date_trunc($2, scoring_event.scored_on), COALESCE(CASE WHEN $3 THEN team.name END, ''),
+			SUM(scoring_event.points)
+		FROM scoring_event
+		LEFT JOIN participant ON participant.fk_campaign = scoring_event.fk_campaign
+			AND participant.fk_scp = scoring_event.fk_scp
+			AND participant.login_name = scoring_event.username
+		LEFT JOIN team ON participant.fk_team = team.Id
+		WHERE scoring_event.fk_campaign = (SELECT id FROM campaign WHERE name = $1)
+		  AND scoring_event.scored_on IS NOT NULL
+		  AND scoring_event.voided_on IS NULL
+		GROUP BY 1, 2
+		ORDER BY 1, 2`
+
+// SelectScoreTimeSeries sums the points scored in each interval of the campaign, a date_trunc field like "day". When
+// byTeam is set there is a point for each team scoring in an interval. CumulativePoints are left for the caller.
+func (p *BBashDB) SelectScoreTimeSeries(ctx context.Context, campaignName, interval string, byTeam bool) (points []types.TimeSeriesPoint, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectScoreTimeSeries, campaignName, interval, byTeam)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		point := types.TimeSeriesPoint{}
+		err = rows.Scan(&point.Start, &point.TeamName, &point.Points)
+		if err != nil {
+			return
+		}
+		points = append(points, point)
+	}
+	return
+}
+
+const sqlUpsertCampaignReport = `INSERT INTO campaign_report
+		(fk_campaign, report, html, created_on)
+		VALUES ((SELECT id FROM campaign WHERE name = $1), $2, $3, $4)
+		ON CONFLICT (fk_campaign) DO
+			UPDATE SET report = $2, html = $3, created_on = $4`
+
+// UpsertCampaignReport stores the report for download, replacing any earlier report of the campaign.
+func (p *BBashDB) UpsertCampaignReport(ctx context.Context, report *types.CampaignReport, html string) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	reportJson, err := json.Marshal(report)
+	if err != nil {
+		return
+	}
+	_, err = p.retryDb().ExecContext(ctx, sqlUpsertCampaignReport, report.Campaign.Name, reportJson, html, report.GeneratedOn)
+	return
+}
+
+const sqlSelectStoredCampaignReport = `SELECT report, html
+		FROM campaign_report
+		WHERE fk_campaign = (SELECT id FROM campaign WHERE name = $1)`
+
+func (p *BBashDB) SelectStoredCampaignReport(ctx context.Context, campaignName string) (reportJson []byte, html string, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlSelectStoredCampaignReport, campaignName).Scan(&reportJson, &html)
+	return
+}
+
+const sqlInsertTelemetryEvent = `INSERT INTO telemetry_events
+		(feature, call, created_on)
+		VALUES ($1, $2, $3)`
+
+func (p *BBashDB) InsertTelemetryEvent(ctx context.Context, feature, call string, now time.Time) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	_, err = p.retryDb().ExecContext(ctx, sqlInsertTelemetryEvent, feature, call, now)
+	return
+}
+
+const sqlSelectTelemetryUsage = `SELECT feature, COUNT(*)
+		FROM telemetry_events
+		WHERE created_on >= $1
+		  AND created_on < $2
+		GROUP BY feature
+		ORDER BY 2 DESC, 1`
+
+// SelectTelemetryUsage counts the use of each feature from (inclusive) to (exclusive), most used first.
+func (p *BBashDB) SelectTelemetryUsage(ctx context.Context, from, to time.Time) (usage []types.TelemetryUsage, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectTelemetryUsage, from, to)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		featureUsage := types.TelemetryUsage{}
+		err = rows.Scan(&featureUsage.Feature, &featureUsage.Calls)
+		if err != nil {
+			return
+		}
+		usage = append(usage, featureUsage)
+	}
+	return
+}
+
+const sqlInsertAuditLog = `INSERT INTO audit_log
+		(actor, method, path, route, status, payload, created_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING Id`
+
+func (p *BBashDB) InsertAuditLog(ctx context.Context, entry *types.AuditLogEntry) (err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	err = p.retryDb().QueryRowContext(ctx, sqlInsertAuditLog, entry.Actor, entry.Method, entry.Path, entry.Route, entry.Status, entry.Payload, entry.CreatedOn).
+		Scan(&entry.ID)
+	return
+}
+
+const sqlSelectAuditLog = `SELECT Id, actor, method, path, route, status, payload, created_on
+		FROM audit_log
+		WHERE $1 = '' OR actor = $1
+		ORDER BY created_on DESC
+		LIMIT $2`
+
+// SelectAuditLog reads the latest admin calls, newest first. An empty actor reads the calls of every actor.
+func (p *BBashDB) SelectAuditLog(ctx context.Context, actor string, limit int) (entries []types.AuditLogEntry, err error) {
+	ctx, cancel := p.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := p.retryReadDb().QueryContext(ctx, sqlSelectAuditLog, actor, limit)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		entry := types.AuditLogEntry{}
+		err = rows.Scan(&entry.ID, &entry.Actor, &entry.Method, &entry.Path, &entry.Route, &entry.Status, &entry.Payload, &entry.CreatedOn)
+		if err != nil {
+			return
+		}
+		entries = append(entries, entry)
+	}
+	return
+}
+
+// backupTables are the tables of a backup, each before the tables whose rows refer to its rows
+var backupTables = []string{"source_control_provider", "tenant", "campaign", "campaign_penalty", "campaign_caps",
+	"campaign_duplicate_policy", "campaign_coauthor_policy", "campaign_privacy", "campaign_config_stage",
+	"campaign_email", "campaign_report", "organization", "point_value_override", "bug", "bug_category_exclusion",
+	"repository_filter", "ban", "team", "participant", "achievement", "notification", "scoring_event",
+	"scoring_event_coauthor", "scoring_event_fingerprint", "scoring_flag", "team_score_event", "scoring_event_archive",
+	"leaderboard_snapshot", "leaderboard_snapshot_entry"}
+
+// BackupExcludedTables are the tables deliberately left out of a backup, with why each is
+var BackupExcludedTables = map[string]string{
+	"webhook":                "holds the secrets that sign the webhook deliveries",
+	"slack_notification":     "holds the Slack webhook urls, which are secrets",
+	"leaderboard_rank":       "computed from the participants by the leaderboard refresh",
+	"scoring_event_category": "SQLite only, computed from the breakdowns of the scoring events",
+	"audit_log":              "a log of the admin requests, not needed to restore the data",
+	"telemetry_events":       "usage counts of the features, not needed to restore the data",
+	"ingestion_journal":      "state of the datadog poll, which reads the logs again",
+	"poll":                   "state of the datadog poll, which reads the logs again",
+	"cluster_instance":       "state of the running replicas",
+	"scheduled_job_run":      "state of the running replicas",
+	"job":                    "state of the running replicas",
+	"maintenance":            "state of the running replicas",
+	"poll_lease":             "state of the running replicas",
+	"scoring_event_revision": "history of the scores, started again from the restored scoring events",
+}
+
+// sqlBackupTable reads each row of the table as a JSON object of its columns
+func sqlBackupTable(table string) string {
+	return fmt.Sprintf(`SELECT row_to_json(backup_row) FROM %s backup_row`, table)
+}
+
+// Backup writes the rows of each of the backupTables as newline delimited JSON, to the writer the func opens for the
+// table. The tables are read in one read only transaction, so the rows are consistent with each other, even as scoring
+// goes on. There is no statement timeout, as a backup of a large database takes as long as the writers take.
+func (p *BBashDB) Backup(ctx context.Context, open func(table string) (io.Writer, error)) (rowCounts map[string]int64, err error) {
+	tx, err := p.retryDb().BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	rowCounts = map[string]int64{}
+	for _, table := range backupTables {
+		var w io.Writer
+		if w, err = open(table); err != nil {
+			return
+		}
+		var rows *sql.Rows
+		if rows, err = tx.QueryContext(ctx, sqlBackupTable(table)); err != nil {
+			return
+		}
+		for rows.Next() {
+			var row []byte
+			if err = rows.Scan(&row); err != nil {
+				_ = rows.Close()
+				return
+			}
+			if _, err = w.Write(append(row, '\n')); err != nil {
+				_ = rows.Close()
+				return
+			}
+			rowCounts[table]++
+		}
+		if err = rows.Close(); err != nil {
+			return
+		}
+		if err = rows.Err(); err != nil {
+			return
+		}
 	}
 	return
 }
@@ -28,7 +28,8 @@ import (
 )
 
 // SetupMockDB should always be followed by a call to the closeDbFunc, like so:
-// 	mock, db, closeDbFunc := SetupMockDB(t)
+//
+//	mock, db, closeDbFunc := SetupMockDB(t)
 //	defer closeDbFunc()
 func SetupMockDB(t *testing.T) (mock sqlmock.Sqlmock, mockDbIf *BBashDB, closeDbFunc func()) {
 	db, mock, err := sqlmock.New()
@@ -57,6 +58,9 @@ func convertSqlToDbMockExpect(realSql string) string {
 
 	rePlus := regexp.MustCompile(`(\+)`)
 	sqlMatch = rePlus.ReplaceAll(sqlMatch, []byte(`\+`))
+
+	reLeftBracket := regexp.MustCompile(`(\[)`)
+	sqlMatch = reLeftBracket.ReplaceAll(sqlMatch, []byte(`\[`))
 	return string(sqlMatch)
 }
 
@@ -20,12 +20,23 @@
 package db
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/lib/pq"
 	"github.com/sonatype-nexus-community/bbash/internal/types"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"io"
+	"io/fs"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -35,14 +46,38 @@ func TestConvertSqlToDbMockExpect(t *testing.T) {
 	assert.Equal(t, `\$\(\)\*\+`, convertSqlToDbMockExpect(`$()*+`))
 }
 
-// exclude parent 'db' directory for tests
-const testMigrateSourceURL = "file://migrations/v2"
+func TestMigrationsEmbedded(t *testing.T) {
+	source, err := iofs.New(migrations, migrationsPath)
+	assert.NoError(t, err)
+	defer func() {
+		_ = source.Close()
+	}()
+
+	first, err := source.First()
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), first)
+
+	// every migration is embedded
+	upFiles, err := fs.Glob(migrations, migrationsPath+"/*.up.sql")
+	assert.NoError(t, err)
+	versions := 0
+	for version := first; err == nil; version, err = source.Next(version) {
+		versions++
+	}
+	assert.Equal(t, len(upFiles), versions)
+
+	// every migration can be rolled back
+	for _, upFile := range upFiles {
+		_, err = fs.Stat(migrations, strings.TrimSuffix(upFile, ".up.sql")+".down.sql")
+		assert.NoError(t, err, upFile)
+	}
+}
 
 func TestMigrateDBErrorPostgresWithInstance(t *testing.T) {
 	_, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	assert.EqualError(t, db.MigrateDB(testMigrateSourceURL), "all expectations were already fulfilled, call to Query 'SELECT CURRENT_DATABASE()' with args [] was not expected in line 0: SELECT CURRENT_DATABASE()")
+	assert.EqualError(t, db.MigrateDB(), "all expectations were already fulfilled, call to Query 'SELECT CURRENT_DATABASE()' with args [] was not expected in line 0: SELECT CURRENT_DATABASE()")
 }
 
 func TestMigrateDBErrorMigrateUp(t *testing.T) {
@@ -72,7 +107,7 @@ func TestMigrateDBErrorMigrateUp(t *testing.T) {
 		WithArgs(args...).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
-	assert.EqualError(t, db.MigrateDB(testMigrateSourceURL), fmt.Sprintf("try lock failed in line 0: SELECT pg_advisory_lock($1) (details: all expectations were already fulfilled, call to ExecQuery 'SELECT pg_advisory_lock($1)' with args [{Name: Ordinal:1 Value:%s}] was not expected)", args[0]))
+	assert.EqualError(t, db.MigrateDB(), fmt.Sprintf("try lock failed in line 0: SELECT pg_advisory_lock($1) (details: all expectations were already fulfilled, call to ExecQuery 'SELECT pg_advisory_lock($1)' with args [{Name: Ordinal:1 Value:%s}] was not expected)", args[0]))
 }
 
 func TestGetSourceControlProvidersQueryError(t *testing.T) {
@@ -83,7 +118,7 @@ func TestGetSourceControlProvidersQueryError(t *testing.T) {
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectSourceControlProvider)).
 		WillReturnError(forcedError)
 
-	scps, err := db.GetSourceControlProviders()
+	scps, err := db.GetSourceControlProviders(context.Background())
 	assert.EqualError(t, err, forcedError.Error())
 	assert.Equal(t, ([]types.SourceControlProviderStruct)(nil), scps)
 }
@@ -97,7 +132,7 @@ func TestGetSourceControlProvidersScanError(t *testing.T) {
 			// force scan error via invalid datatype
 			AddRow("someId", "someSCP", sql.NullString{}))
 
-	scps, err := db.GetSourceControlProviders()
+	scps, err := db.GetSourceControlProviders(context.Background())
 	assert.EqualError(t, err, "sql: Scan error on column index 2, name \"url\": converting NULL to string is unsupported")
 	assert.Equal(t, ([]types.SourceControlProviderStruct)(nil), scps)
 }
@@ -109,7 +144,7 @@ func TestGetSourceControlProviders(t *testing.T) {
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectSourceControlProvider)).
 		WillReturnRows(sqlmock.NewRows([]string{"Id", "name", "url"}).AddRow("someId", "someSCP", "someUrl"))
 
-	scps, err := db.GetSourceControlProviders()
+	scps, err := db.GetSourceControlProviders(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, []types.SourceControlProviderStruct{
 		{"someId", "someSCP", "someUrl"},
@@ -118,10 +153,27 @@ func TestGetSourceControlProviders(t *testing.T) {
 
 var campaignStartTime = time.Now()
 var campaignEndTime = campaignStartTime.Add(time.Second)
+var campaignRegistrationOpensTime = campaignStartTime.Add(-time.Hour)
+var testUnclassifiedBonus = 0.5
+var testFreezeScoring = true
+var testMaxTeamSize = 4
 var testCampaign = types.CampaignStruct{
 	Name:    "testCampaignName",
 	StartOn: campaignStartTime,
 	EndOn:   campaignEndTime,
+
+	MaxTeamSize: &testMaxTeamSize,
+	TieBreaker:  types.TieBreakerJoinedAt,
+
+	UnclassifiedBonus: &testUnclassifiedBonus,
+	TimeZone:          "America/New_York",
+	Status:            types.CampaignStatusActive,
+	FreezeScoring:     &testFreezeScoring,
+
+	RegistrationOpensOn:  &campaignRegistrationOpensTime,
+	RegistrationClosesOn: &campaignEndTime,
+
+	Version: 1,
 }
 
 const testCampaignGuid = "testCampaignGuid"
@@ -142,10 +194,10 @@ func TestInsertCampaignError(t *testing.T) {
 
 	forcedError := fmt.Errorf("forced SQL insert error")
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertCampaign)).
-		WithArgs(testCampaign.Name, testCampaign.StartOn, testCampaign.EndOn).
+		WithArgs(testCampaign.Name, testCampaign.StartOn, testCampaign.EndOn, *testCampaign.MaxTeamSize, testCampaign.TieBreaker, *testCampaign.UnclassifiedBonus, testCampaign.TenantName, testCampaign.TimeZone, testCampaign.FreezeScoring, testCampaign.RegistrationOpensOn, testCampaign.RegistrationClosesOn).
 		WillReturnError(forcedError)
 
-	guid, err := db.InsertCampaign(&testCampaign)
+	guid, err := db.InsertCampaign(context.Background(), &testCampaign)
 	assert.Error(t, err, forcedError.Error())
 	assert.Equal(t, "", guid)
 }
@@ -155,24 +207,258 @@ func TestInsertCampaign(t *testing.T) {
 	defer closeDbFunc()
 
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertCampaign)).
-		WithArgs(testCampaign.Name, testCampaign.StartOn, testCampaign.EndOn).
+		WithArgs(testCampaign.Name, testCampaign.StartOn, testCampaign.EndOn, *testCampaign.MaxTeamSize, testCampaign.TieBreaker, *testCampaign.UnclassifiedBonus, testCampaign.TenantName, testCampaign.TimeZone, testCampaign.FreezeScoring, testCampaign.RegistrationOpensOn, testCampaign.RegistrationClosesOn).
+		WillReturnRows(sqlmock.NewRows([]string{"guid"}).AddRow(testCampaignGuid))
+
+	guid, err := db.InsertCampaign(context.Background(), &testCampaign)
+	assert.NoError(t, err)
+	assert.Equal(t, testCampaignGuid, guid)
+}
+
+func TestInsertCampaignDefaultUnclassifiedBonus(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	campaign := testCampaign
+	campaign.UnclassifiedBonus = nil
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertCampaign)).
+		WithArgs(campaign.Name, campaign.StartOn, campaign.EndOn, *campaign.MaxTeamSize, campaign.TieBreaker, nil, campaign.TenantName, campaign.TimeZone, campaign.FreezeScoring, campaign.RegistrationOpensOn, campaign.RegistrationClosesOn).
+		WillReturnRows(sqlmock.NewRows([]string{"guid"}).AddRow(testCampaignGuid))
+
+	guid, err := db.InsertCampaign(context.Background(), &campaign)
+	assert.NoError(t, err)
+	assert.Equal(t, testCampaignGuid, guid)
+}
+
+func TestInsertCampaignOfTenant(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	campaign := testCampaign
+	campaign.TenantName = testTenantName
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertCampaign)).
+		WithArgs(campaign.Name, campaign.StartOn, campaign.EndOn, *campaign.MaxTeamSize, campaign.TieBreaker, *campaign.UnclassifiedBonus, testTenantName, campaign.TimeZone, campaign.FreezeScoring, campaign.RegistrationOpensOn, campaign.RegistrationClosesOn).
 		WillReturnRows(sqlmock.NewRows([]string{"guid"}).AddRow(testCampaignGuid))
 
-	guid, err := db.InsertCampaign(&testCampaign)
+	guid, err := db.InsertCampaign(context.Background(), &campaign)
 	assert.NoError(t, err)
 	assert.Equal(t, testCampaignGuid, guid)
 }
 
+const testTenantName = "testTenantName"
+
+func TestSelectCampaignTenant(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignTenant)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow(testTenantName))
+
+	tenantName, err := db.SelectCampaignTenant(context.Background(), testCampaign.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, testTenantName, tenantName)
+}
+
+func TestSelectCampaignTenantNoCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignTenant)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}))
+
+	tenantName, err := db.SelectCampaignTenant(context.Background(), testCampaign.Name)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, "", tenantName)
+}
+
+func TestSelectRegistrationWindow(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectRegistrationWindow)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"registrationOpensOn", "registrationClosesOn"}).AddRow(campaignRegistrationOpensTime, nil))
+
+	opensOn, closesOn, err := db.SelectRegistrationWindow(context.Background(), testCampaign.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, &campaignRegistrationOpensTime, opensOn)
+	assert.Nil(t, closesOn)
+}
+
+func TestSelectRegistrationWindowNoCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectRegistrationWindow)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"registrationOpensOn", "registrationClosesOn"}))
+
+	opensOn, closesOn, err := db.SelectRegistrationWindow(context.Background(), testCampaign.Name)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, opensOn)
+	assert.Nil(t, closesOn)
+}
+
+func TestInsertTenant(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertTenant)).
+		WithArgs(testTenantName, "tenantAdmin", "passwordHash").
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "created_on"}).AddRow("tenantGuid", now))
+
+	tenant := types.TenantStruct{Name: testTenantName, AdminUsername: "tenantAdmin"}
+	guid, err := db.InsertTenant(context.Background(), &tenant, "passwordHash")
+	assert.NoError(t, err)
+	assert.Equal(t, "tenantGuid", guid)
+	assert.Equal(t, types.TenantStruct{ID: "tenantGuid", Name: testTenantName, AdminUsername: "tenantAdmin", CreatedOn: now}, tenant)
+}
+
+func TestInsertTenantDuplicate(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertTenant)).
+		WithArgs(testTenantName, "tenantAdmin", "passwordHash").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "tenant_name_key"})
+
+	guid, err := db.InsertTenant(context.Background(), &types.TenantStruct{Name: testTenantName, AdminUsername: "tenantAdmin"}, "passwordHash")
+	assert.Equal(t, &DuplicateError{Entity: "tenant", Constraint: "tenant_name_key"}, err)
+	assert.Equal(t, "", guid)
+}
+
+func TestInsertBan(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertBan)).
+		WithArgs(scpName, "Cheater", "scored forks").
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "banned_on"}).AddRow("banGuid", now))
+
+	ban := types.BanStruct{ScpName: scpName, LoginName: "Cheater", Reason: "scored forks"}
+	assert.NoError(t, db.InsertBan(context.Background(), &ban))
+	assert.Equal(t, types.BanStruct{ID: "banGuid", ScpName: scpName, LoginName: "cheater", Reason: "scored forks", BannedOn: now}, ban)
+}
+
+func TestInsertBanDuplicate(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertBan)).
+		WithArgs(scpName, "cheater", "").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "ban_scp_login"})
+
+	err := db.InsertBan(context.Background(), &types.BanStruct{ScpName: scpName, LoginName: "cheater"})
+	assert.Equal(t, &DuplicateError{Entity: "ban", Constraint: "ban_scp_login"}, err)
+}
+
+func TestSelectBans(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectBans)).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "scp", "login", "reason", "bannedOn"}).
+			AddRow("banGuid", scpName, "cheater", "", now))
+
+	bans, err := db.SelectBans(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []types.BanStruct{{ID: "banGuid", ScpName: scpName, LoginName: "cheater", BannedOn: now}}, bans)
+}
+
+func TestDeleteBan(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteBan)).
+		WithArgs(scpName, "cheater").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.DeleteBan(context.Background(), scpName, "cheater")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+}
+
+func TestSelectBanned(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectBanned)).
+		WithArgs(scpName, "cheater").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	banned, err := db.SelectBanned(context.Background(), scpName, "cheater")
+	assert.NoError(t, err)
+	assert.True(t, banned)
+}
+
+func TestSelectTenantsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced tenant error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTenants)).
+		WillReturnError(forcedError)
+
+	tenants, err := db.SelectTenants(context.Background())
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, tenants)
+}
+
+func TestSelectTenants(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTenants)).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "name", "admin_username", "created_on"}).
+			AddRow("tenantGuid", testTenantName, "tenantAdmin", now))
+
+	tenants, err := db.SelectTenants(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []types.TenantStruct{{ID: "tenantGuid", Name: testTenantName, AdminUsername: "tenantAdmin", CreatedOn: now}}, tenants)
+}
+
+func TestSelectTenantAdmin(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTenantAdmin)).
+		WithArgs(testTenantName).
+		WillReturnRows(sqlmock.NewRows([]string{"admin_username", "admin_password_hash"}).AddRow("tenantAdmin", "passwordHash"))
+
+	username, passwordHash, err := db.SelectTenantAdmin(context.Background(), testTenantName)
+	assert.NoError(t, err)
+	assert.Equal(t, "tenantAdmin", username)
+	assert.Equal(t, "passwordHash", passwordHash)
+}
+
+func TestSelectTenantCampaigns(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTenantCampaigns)).
+		WithArgs(testTenantName).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note", "maxTeamSize", "tieBreaker", "unclassifiedBonus", "timeZone", "status", "freezeScoring", "registrationOpensOn", "registrationClosesOn", "tenantName", "version"}).
+			AddRow(testCampaign.ID, testCampaign.Name, testCampaign.CreatedOn, testCampaign.CreatedOrder, testCampaign.StartOn, testCampaign.EndOn, testCampaign.Note, *testCampaign.MaxTeamSize, testCampaign.TieBreaker, *testCampaign.UnclassifiedBonus, testCampaign.TimeZone, testCampaign.Status, testCampaign.FreezeScoring, *testCampaign.RegistrationOpensOn, *testCampaign.RegistrationClosesOn, testTenantName, testCampaign.Version))
+
+	campaigns, err := db.SelectTenantCampaigns(context.Background(), testTenantName)
+	assert.NoError(t, err)
+	expected := testCampaign
+	expected.TenantName = testTenantName
+	assert.Equal(t, []types.CampaignStruct{expected}, campaigns)
+}
+
 func TestUpdateCampaignError(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
 	forcedError := fmt.Errorf("forced SQL insert error")
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpdateCampaign)).
-		WithArgs(testCampaign.Name, testCampaign.StartOn, testCampaign.EndOn).
+		WithArgs(testCampaign.Name, testCampaign.StartOn, testCampaign.EndOn, *testCampaign.MaxTeamSize, testCampaign.TieBreaker, *testCampaign.UnclassifiedBonus, testCampaign.TimeZone, testCampaign.FreezeScoring, testCampaign.RegistrationOpensOn, testCampaign.RegistrationClosesOn).
 		WillReturnError(forcedError)
 
-	guid, err := db.UpdateCampaign(&testCampaign)
+	guid, err := db.UpdateCampaign(context.Background(), &testCampaign)
 	assert.Error(t, err, forcedError.Error())
 	assert.Equal(t, "", guid)
 }
@@ -181,13 +467,66 @@ func TestUpdateCampaign(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
+	campaign := testCampaign
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpdateCampaign)).
-		WithArgs(testCampaign.StartOn, testCampaign.EndOn, testCampaign.Name).
-		WillReturnRows(sqlmock.NewRows([]string{"guid"}).AddRow(testCampaignGuid))
+		WithArgs(campaign.StartOn, campaign.EndOn, campaign.Name, *campaign.MaxTeamSize, campaign.TieBreaker, *campaign.UnclassifiedBonus, campaign.TimeZone, campaign.FreezeScoring, campaign.RegistrationOpensOn, campaign.RegistrationClosesOn, campaign.Version).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "version"}).AddRow(testCampaignGuid, 2))
+
+	guid, err := db.UpdateCampaign(context.Background(), &campaign)
+	assert.NoError(t, err)
+	assert.Equal(t, testCampaignGuid, guid)
+	assert.Equal(t, 2, campaign.Version)
+}
 
-	guid, err := db.UpdateCampaign(&testCampaign)
+func TestUpdateCampaignKeepsMaxTeamSize(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	// the stored limit is kept by the update, rather than reset to no limit
+	campaign := testCampaign
+	campaign.MaxTeamSize = nil
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpdateCampaign)).
+		WithArgs(campaign.StartOn, campaign.EndOn, campaign.Name, nil, campaign.TieBreaker, *campaign.UnclassifiedBonus, campaign.TimeZone, campaign.FreezeScoring, campaign.RegistrationOpensOn, campaign.RegistrationClosesOn, campaign.Version).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "version"}).AddRow(testCampaignGuid, 2))
+
+	guid, err := db.UpdateCampaign(context.Background(), &campaign)
 	assert.NoError(t, err)
 	assert.Equal(t, testCampaignGuid, guid)
+	assert.Contains(t, sqlUpdateCampaign, "max_team_size = COALESCE($4::INTEGER, max_team_size)")
+}
+
+func TestUpdateCampaignStale(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	campaign := testCampaign
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpdateCampaign)).
+		WithArgs(campaign.StartOn, campaign.EndOn, campaign.Name, *campaign.MaxTeamSize, campaign.TieBreaker, *campaign.UnclassifiedBonus, campaign.TimeZone, campaign.FreezeScoring, campaign.RegistrationOpensOn, campaign.RegistrationClosesOn, campaign.Version).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "version"}))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignVersion)).
+		WithArgs(campaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+
+	guid, err := db.UpdateCampaign(context.Background(), &campaign)
+	assert.Equal(t, &StaleError{Entity: "campaign", Version: 3}, err)
+	assert.EqualError(t, err, "campaign was changed by another update, current version: 3")
+	assert.Equal(t, "", guid)
+}
+
+func TestUpdateCampaignMissing(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	campaign := testCampaign
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpdateCampaign)).
+		WithArgs(campaign.StartOn, campaign.EndOn, campaign.Name, *campaign.MaxTeamSize, campaign.TieBreaker, *campaign.UnclassifiedBonus, campaign.TimeZone, campaign.FreezeScoring, campaign.RegistrationOpensOn, campaign.RegistrationClosesOn, campaign.Version).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "version"}))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignVersion)).
+		WithArgs(campaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+
+	_, err := db.UpdateCampaign(context.Background(), &campaign)
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
 }
 
 func TestGetCampaignError(t *testing.T) {
@@ -198,7 +537,7 @@ func TestGetCampaignError(t *testing.T) {
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaign)).
 		WillReturnError(forcedError)
 
-	campaign, err := db.GetCampaign(testCampaign.Name)
+	campaign, err := db.GetCampaign(context.Background(), testCampaign.Name)
 	assert.Error(t, err, forcedError.Error())
 	assert.Nil(t, campaign)
 }
@@ -208,13 +547,26 @@ func TestGetCampaignScanError(t *testing.T) {
 	defer closeDbFunc()
 
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaign)).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note"}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note", "maxTeamSize", "tieBreaker", "unclassifiedBonus", "timeZone", "status", "freezeScoring", "registrationOpensOn", "registrationClosesOn", "version"}).
 			// force scan error due to time.Time type mismatch at CreatedOn column
-			AddRow("campaignId", "campaignName", "badness", 1, time.Time{}, time.Time{}, ""))
+			AddRow("campaignId", "campaignName", "badness", 1, time.Time{}, time.Time{}, "", 0, "", 1, "UTC", "active", false, nil, nil, 1))
 
-	campaign, err := db.GetCampaign(testCampaign.Name)
+	campaign, err := db.GetCampaign(context.Background(), testCampaign.Name)
 	assert.EqualError(t, err, `sql: Scan error on column index 2, name "createdOn": unsupported Scan, storing driver.Value type string into type *time.Time`)
-	assert.Equal(t, "campaignId", campaign.ID)
+	assert.Nil(t, campaign)
+}
+
+func TestGetCampaignNotFound(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaign)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note", "maxTeamSize", "tieBreaker", "unclassifiedBonus", "timeZone", "status", "freezeScoring", "registrationOpensOn", "registrationClosesOn", "version"}))
+
+	campaign, err := db.GetCampaign(context.Background(), testCampaign.Name)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, campaign)
 }
 
 func TestGetCampaign(t *testing.T) {
@@ -222,10 +574,10 @@ func TestGetCampaign(t *testing.T) {
 	defer closeDbFunc()
 
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaign)).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note"}).
-			AddRow(testCampaign.ID, testCampaign.Name, testCampaign.CreatedOn, testCampaign.CreatedOrder, testCampaign.StartOn, testCampaign.EndOn, testCampaign.Note))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note", "maxTeamSize", "tieBreaker", "unclassifiedBonus", "timeZone", "status", "freezeScoring", "registrationOpensOn", "registrationClosesOn", "version"}).
+			AddRow(testCampaign.ID, testCampaign.Name, testCampaign.CreatedOn, testCampaign.CreatedOrder, testCampaign.StartOn, testCampaign.EndOn, testCampaign.Note, *testCampaign.MaxTeamSize, testCampaign.TieBreaker, *testCampaign.UnclassifiedBonus, testCampaign.TimeZone, testCampaign.Status, testCampaign.FreezeScoring, *testCampaign.RegistrationOpensOn, *testCampaign.RegistrationClosesOn, testCampaign.Version))
 
-	campaign, err := db.GetCampaign(testCampaign.Name)
+	campaign, err := db.GetCampaign(context.Background(), testCampaign.Name)
 	assert.NoError(t, err)
 	assert.Equal(t, &testCampaign, campaign)
 }
@@ -238,7 +590,7 @@ func TestGetCampaignsError(t *testing.T) {
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaigns)).
 		WillReturnError(forcedError)
 
-	campaigns, err := db.GetCampaigns()
+	campaigns, err := db.GetCampaigns(context.Background())
 	assert.Error(t, err, forcedError.Error())
 	assert.Nil(t, campaigns)
 }
@@ -248,11 +600,11 @@ func TestGetCampaignsScanError(t *testing.T) {
 	defer closeDbFunc()
 
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaigns)).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note"}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note", "maxTeamSize", "tieBreaker", "unclassifiedBonus", "timeZone", "status", "freezeScoring", "registrationOpensOn", "registrationClosesOn", "version"}).
 			// force scan error due to time.Time type mismatch at CreatedOn column
-			AddRow("campaignId", "campaignName", "badness", 1, time.Time{}, time.Time{}, ""))
+			AddRow("campaignId", "campaignName", "badness", 1, time.Time{}, time.Time{}, "", 0, "", 1, "UTC", "active", false, nil, nil, 1))
 
-	campaigns, err := db.GetCampaigns()
+	campaigns, err := db.GetCampaigns(context.Background())
 	assert.EqualError(t, err, `sql: Scan error on column index 2, name "createdOn": unsupported Scan, storing driver.Value type string into type *time.Time`)
 	assert.Nil(t, campaigns)
 }
@@ -262,10 +614,23 @@ func TestGetCampaigns(t *testing.T) {
 	defer closeDbFunc()
 
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaigns)).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note"}).
-			AddRow(testCampaign.ID, testCampaign.Name, testCampaign.CreatedOn, testCampaign.CreatedOrder, testCampaign.StartOn, testCampaign.EndOn, testCampaign.Note))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note", "maxTeamSize", "tieBreaker", "unclassifiedBonus", "timeZone", "status", "freezeScoring", "registrationOpensOn", "registrationClosesOn", "version"}).
+			AddRow(testCampaign.ID, testCampaign.Name, testCampaign.CreatedOn, testCampaign.CreatedOrder, testCampaign.StartOn, testCampaign.EndOn, testCampaign.Note, *testCampaign.MaxTeamSize, testCampaign.TieBreaker, *testCampaign.UnclassifiedBonus, testCampaign.TimeZone, testCampaign.Status, testCampaign.FreezeScoring, *testCampaign.RegistrationOpensOn, *testCampaign.RegistrationClosesOn, testCampaign.Version))
+
+	campaigns, err := db.GetCampaigns(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []types.CampaignStruct{testCampaign}, campaigns)
+}
+
+func TestSelectPublicCampaigns(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
 
-	campaigns, err := db.GetCampaigns()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectPublicCampaigns)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note", "maxTeamSize", "tieBreaker", "unclassifiedBonus", "timeZone", "status", "freezeScoring", "registrationOpensOn", "registrationClosesOn", "version"}).
+			AddRow(testCampaign.ID, testCampaign.Name, testCampaign.CreatedOn, testCampaign.CreatedOrder, testCampaign.StartOn, testCampaign.EndOn, testCampaign.Note, *testCampaign.MaxTeamSize, testCampaign.TieBreaker, *testCampaign.UnclassifiedBonus, testCampaign.TimeZone, testCampaign.Status, testCampaign.FreezeScoring, *testCampaign.RegistrationOpensOn, *testCampaign.RegistrationClosesOn, testCampaign.Version))
+
+	campaigns, err := db.SelectPublicCampaigns(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, []types.CampaignStruct{testCampaign}, campaigns)
 }
@@ -280,7 +645,7 @@ func TestGetActiveCampaignsError(t *testing.T) {
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCurrentCampaigns)).
 		WillReturnError(forcedError)
 
-	activeCampaigns, err := db.GetActiveCampaigns(now)
+	activeCampaigns, err := db.GetActiveCampaigns(context.Background(), now)
 	assert.EqualError(t, err, forcedError.Error())
 	var expectedCampaigns []types.CampaignStruct
 	assert.Equal(t, expectedCampaigns, activeCampaigns)
@@ -291,11 +656,11 @@ func TestGetActiveCampaignsScanError(t *testing.T) {
 	defer closeDbFunc()
 
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCurrentCampaigns)).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note"}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note", "maxTeamSize", "tieBreaker", "unclassifiedBonus", "timeZone", "status", "freezeScoring", "registrationOpensOn", "registrationClosesOn", "version"}).
 			// force scan error due to time.Time type mismatch at CreatedOn column
-			AddRow("campaignId", "campaignName", "badness", 0, now, now, sql.NullString{}))
+			AddRow("campaignId", "campaignName", "badness", 0, now, now, sql.NullString{}, 0, "", 1, "UTC", "active", false, nil, nil, 1))
 
-	activeCampaigns, err := db.GetActiveCampaigns(now)
+	activeCampaigns, err := db.GetActiveCampaigns(context.Background(), now)
 	assert.EqualError(t, err, `sql: Scan error on column index 2, name "createdOn": unsupported Scan, storing driver.Value type string into type *time.Time`)
 	var expectedCampaigns []types.CampaignStruct
 	assert.Equal(t, expectedCampaigns, activeCampaigns)
@@ -306,17 +671,46 @@ func TestGetActiveCampaigns(t *testing.T) {
 	defer closeDbFunc()
 
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCurrentCampaigns)).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note"}).
-			AddRow(testCampaign.ID, testCampaign.Name, time.Time{}, 0, now, now, sql.NullString{}))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note", "maxTeamSize", "tieBreaker", "unclassifiedBonus", "timeZone", "status", "freezeScoring", "registrationOpensOn", "registrationClosesOn", "version"}).
+			AddRow(testCampaign.ID, testCampaign.Name, time.Time{}, 0, now, now, sql.NullString{}, 0, "", 1, "UTC", "active", false, nil, nil, 1))
 
-	activeCampaigns, err := db.GetActiveCampaigns(now)
+	activeCampaigns, err := db.GetActiveCampaigns(context.Background(), now)
 	assert.NoError(t, err)
+	bonus, freeze, maxTeamSize := types.DefaultUnclassifiedBonus, false, 0
 	expectedCampaigns := []types.CampaignStruct{
-		{ID: testCampaign.ID, Name: testCampaign.Name, StartOn: now, EndOn: now},
+		{ID: testCampaign.ID, Name: testCampaign.Name, StartOn: now, EndOn: now, MaxTeamSize: &maxTeamSize, UnclassifiedBonus: &bonus, TimeZone: types.DefaultTimeZone, Status: types.CampaignStatusActive, FreezeScoring: &freeze, Version: 1},
 	}
 	assert.Equal(t, expectedCampaigns, activeCampaigns)
 }
 
+func TestGetUpcomingCampaignsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced campaign error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectUpcomingCampaigns)).
+		WithArgs(now).
+		WillReturnError(forcedError)
+
+	upcomingCampaigns, err := db.GetUpcomingCampaigns(context.Background(), now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, upcomingCampaigns)
+}
+
+func TestGetUpcomingCampaigns(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectUpcomingCampaigns)).
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note", "maxTeamSize", "tieBreaker", "unclassifiedBonus", "timeZone", "status", "freezeScoring", "registrationOpensOn", "registrationClosesOn", "version"}).
+			AddRow(testCampaign.ID, testCampaign.Name, testCampaign.CreatedOn, testCampaign.CreatedOrder, testCampaign.StartOn, testCampaign.EndOn, testCampaign.Note, *testCampaign.MaxTeamSize, testCampaign.TieBreaker, *testCampaign.UnclassifiedBonus, testCampaign.TimeZone, testCampaign.Status, testCampaign.FreezeScoring, *testCampaign.RegistrationOpensOn, *testCampaign.RegistrationClosesOn, testCampaign.Version))
+
+	upcomingCampaigns, err := db.GetUpcomingCampaigns(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.CampaignStruct{testCampaign}, upcomingCampaigns)
+}
+
 func TestInsertOrganizationInsertError(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
@@ -325,11 +719,24 @@ func TestInsertOrganizationInsertError(t *testing.T) {
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertOrganization)).
 		WillReturnError(forcedError)
 
-	guid, err := db.InsertOrganization(&types.OrganizationStruct{})
+	guid, err := db.InsertOrganization(context.Background(), &types.OrganizationStruct{})
 	assert.EqualError(t, err, forcedError.Error())
 	assert.Equal(t, "", guid)
 }
 
+func TestInsertOrganizationDuplicate(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertOrganization)).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "organization_global_key"})
+
+	guid, err := db.InsertOrganization(context.Background(), &types.OrganizationStruct{})
+	assert.Equal(t, &DuplicateError{Entity: "organization", Constraint: "organization_global_key"}, err)
+	assert.EqualError(t, err, "organization already exists")
+	assert.Equal(t, "", guid)
+}
+
 func TestAddOrganization(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
@@ -337,7 +744,22 @@ func TestAddOrganization(t *testing.T) {
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertOrganization)).
 		WillReturnRows(sqlmock.NewRows([]string{"Id"}).AddRow("someId"))
 
-	guid, err := db.InsertOrganization(&testOrganization)
+	guid, err := db.InsertOrganization(context.Background(), &testOrganization)
+	assert.NoError(t, err)
+	assert.Equal(t, "someId", guid)
+}
+
+func TestAddOrganizationWithCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertOrganization)).
+		WithArgs(testOrganization.SCPName, testOrganization.Organization, campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"Id"}).AddRow("someId"))
+
+	organization := testOrganization
+	organization.CampaignName = campaignName
+	guid, err := db.InsertOrganization(context.Background(), &organization)
 	assert.NoError(t, err)
 	assert.Equal(t, "someId", guid)
 }
@@ -350,7 +772,7 @@ func TestGetOrganizationsError(t *testing.T) {
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectOrganizations)).
 		WillReturnError(forcedErr)
 
-	organizations, err := db.GetOrganizations()
+	organizations, err := db.GetOrganizations(context.Background())
 	assert.EqualError(t, err, forcedErr.Error())
 	assert.Nil(t, organizations)
 }
@@ -362,8 +784,8 @@ func TestGetOrganizationsScanError(t *testing.T) {
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectOrganizations)).
 		WillReturnRows(sqlmock.NewRows([]string{}).AddRow())
 
-	organizations, err := db.GetOrganizations()
-	assert.EqualError(t, err, "sql: expected 0 destination arguments in Scan, not 3")
+	organizations, err := db.GetOrganizations(context.Background())
+	assert.EqualError(t, err, "sql: expected 0 destination arguments in Scan, not 4")
 	assert.Nil(t, organizations)
 }
 
@@ -372,12 +794,15 @@ func TestGetOrganizations(t *testing.T) {
 	defer closeDbFunc()
 
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectOrganizations)).
-		WillReturnRows(sqlmock.NewRows([]string{"Id", "SCPName", "Org"}).
-			AddRow(testOrganization.ID, testOrganization.SCPName, testOrganization.Organization))
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "SCPName", "Org", "CampaignName"}).
+			AddRow(testOrganization.ID, testOrganization.SCPName, testOrganization.Organization, "").
+			AddRow(testOrganization.ID, testOrganization.SCPName, testOrganization.Organization, campaignName))
 
-	organizations, err := db.GetOrganizations()
+	organizations, err := db.GetOrganizations(context.Background())
 	assert.NoError(t, err)
-	assert.Equal(t, organizations, []types.OrganizationStruct{testOrganization})
+	campaignOrganization := testOrganization
+	campaignOrganization.CampaignName = campaignName
+	assert.Equal(t, organizations, []types.OrganizationStruct{testOrganization, campaignOrganization})
 }
 
 func TestDeleteOrganizationDeleteError(t *testing.T) {
@@ -388,7 +813,7 @@ func TestDeleteOrganizationDeleteError(t *testing.T) {
 	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteOrganization)).
 		WillReturnError(forcedError)
 
-	rowsAffected, err := db.DeleteOrganization("", "")
+	rowsAffected, err := db.DeleteOrganization(context.Background(), "", "", "")
 	assert.EqualError(t, err, forcedError.Error())
 	assert.Equal(t, int64(0), rowsAffected)
 }
@@ -400,7 +825,7 @@ func TestDeleteOrganizationNotFound(t *testing.T) {
 	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteOrganization)).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
-	rowsAffected, err := db.DeleteOrganization("", "")
+	rowsAffected, err := db.DeleteOrganization(context.Background(), "", "", "")
 	assert.NoError(t, err)
 	assert.Equal(t, int64(0), rowsAffected)
 }
@@ -412,7 +837,20 @@ func TestDeleteOrganization(t *testing.T) {
 	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteOrganization)).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	rowsAffected, err := db.DeleteOrganization("", "")
+	rowsAffected, err := db.DeleteOrganization(context.Background(), "", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+}
+
+func TestDeleteOrganizationOfCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteOrganization)).
+		WithArgs(testOrganization.SCPName, testOrganization.Organization, campaignName).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.DeleteOrganization(context.Background(), testOrganization.SCPName, testOrganization.Organization, campaignName)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), rowsAffected)
 }
@@ -422,11 +860,11 @@ func TestValidOrganizationFalse(t *testing.T) {
 	defer closeDbFunc()
 
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectOrganizationExists)).
-		WithArgs(TestEventSourceValid, TestOrgValid).
+		WithArgs(TestEventSourceValid, TestOrgValid, now).
 		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
 	msg := &types.ScoringMessage{EventSource: TestEventSourceValid, RepoOwner: TestOrgValid}
-	isValidOrg, err := db.ValidOrganization(msg)
+	isValidOrg, err := db.ValidOrganization(context.Background(), msg, now)
 	assert.Nil(t, err)
 	assert.False(t, isValidOrg)
 }
@@ -437,11 +875,11 @@ func TestValidOrganizationError(t *testing.T) {
 
 	forcedError := fmt.Errorf("forced org exists query error")
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectOrganizationExists)).
-		WithArgs("GitHub", TestOrgValid).
+		WithArgs("GitHub", TestOrgValid, now).
 		WillReturnError(forcedError)
 
 	msg := &types.ScoringMessage{EventSource: "GitHub", RepoOwner: TestOrgValid}
-	isValidOrg, err := db.ValidOrganization(msg)
+	isValidOrg, err := db.ValidOrganization(context.Background(), msg, now)
 	assert.EqualError(t, err, forcedError.Error())
 	assert.False(t, isValidOrg)
 }
@@ -451,65 +889,176 @@ func TestValidOrganization(t *testing.T) {
 	defer closeDbFunc()
 
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectOrganizationExists)).
-		WithArgs(TestEventSourceValid, TestOrgValid).
+		WithArgs(TestEventSourceValid, TestOrgValid, now).
 		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
 
 	msg := &types.ScoringMessage{EventSource: TestEventSourceValid, RepoOwner: TestOrgValid}
-	isValidOrg, err := db.ValidOrganization(msg)
+	isValidOrg, err := db.ValidOrganization(context.Background(), msg, now)
 	assert.Nil(t, err)
 	assert.True(t, isValidOrg)
 }
 
-const loginName = "loginName"
-
-func TestSelectParticipantsToScoreSelectError(t *testing.T) {
+func TestValidOrganizationMixedCase(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	forcedError := fmt.Errorf("forced current campaign read error")
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantId)).
-		WithArgs(now, TestEventSourceValid, loginName).
-		WillReturnError(forcedError)
-
-	msg := &types.ScoringMessage{EventSource: TestEventSourceValid, RepoOwner: TestOrgValid, TriggerUser: loginName}
+	// case is ignored by the query, so the message is passed as is
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectOrganizationExists)).
+		WithArgs("GitHub", "MyOrg", now).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
 
-	participantsToScore, err := db.SelectParticipantsToScore(msg, now)
-	assert.EqualError(t, err, forcedError.Error())
-	assert.Nil(t, participantsToScore)
+	msg := &types.ScoringMessage{EventSource: "GitHub", RepoOwner: "MyOrg"}
+	isValidOrg, err := db.ValidOrganization(context.Background(), msg, now)
+	assert.Nil(t, err)
+	assert.True(t, isValidOrg)
+	assert.Equal(t, "MyOrg", msg.RepoOwner)
 }
 
-func TestSelectParticipantsToScoreScanError(t *testing.T) {
+func TestUpdateOrganizationDuplicate(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantId)).
-		WithArgs(now, TestEventSourceValid, loginName).
-		WillReturnRows(sqlmock.NewRows([]string{"Id"}).
-			// force scan error due to mismatched column count
-			AddRow(-1))
-
-	msg := &types.ScoringMessage{EventSource: TestEventSourceValid, RepoOwner: TestOrgValid, TriggerUser: loginName}
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateOrganization)).
+		WithArgs(testOrganization.SCPName, testOrganization.Organization, "newOrg").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "organization_global_key"})
+	mock.ExpectRollback()
 
-	participantsToScore, err := db.SelectParticipantsToScore(msg, now)
-	assert.EqualError(t, err, "sql: expected 1 destination arguments in Scan, not 5")
-	assert.Nil(t, participantsToScore)
+	update := &types.OrganizationUpdate{Organization: "newOrg", RelinkScores: true}
+	rowsAffected, eventsRelinked, err := db.UpdateOrganization(context.Background(), testOrganization.SCPName, testOrganization.Organization, update)
+	assert.EqualError(t, err, "organization already exists")
+	assert.Equal(t, int64(0), rowsAffected)
+	assert.Equal(t, int64(0), eventsRelinked)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestSelectParticipantsToScoreValidTeam(t *testing.T) {
+func TestUpdateOrganizationNotFound(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantId)).
-		WithArgs(now, TestEventSourceValid, loginName).
-		WillReturnRows(sqlmock.NewRows([]string{"Id", "CampaignName", "SCPName", "loginName", "teamName"}).
-			// force scan error due to type mismatch at ID column
-			AddRow(now, "someCampaign", "someSCP", "someLoginName", "someTeamName"))
-
-	msg := &types.ScoringMessage{EventSource: TestEventSourceValid, RepoOwner: TestOrgValid, TriggerUser: loginName}
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateOrganization)).
+		WithArgs(testOrganization.SCPName, testOrganization.Organization, "newOrg").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
 
-	participantsToScore, err := db.SelectParticipantsToScore(msg, now)
+	update := &types.OrganizationUpdate{Organization: "newOrg", RelinkScores: true}
+	rowsAffected, eventsRelinked, err := db.UpdateOrganization(context.Background(), testOrganization.SCPName, testOrganization.Organization, update)
 	assert.NoError(t, err)
-	assert.Equal(t, "someTeamName", participantsToScore[0].TeamName)
+	assert.Equal(t, int64(0), rowsAffected)
+	assert.Equal(t, int64(0), eventsRelinked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateOrganizationWithoutRelink(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateOrganization)).
+		WithArgs(testOrganization.SCPName, testOrganization.Organization, "newOrg").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	update := &types.OrganizationUpdate{Organization: "newOrg"}
+	rowsAffected, eventsRelinked, err := db.UpdateOrganization(context.Background(), testOrganization.SCPName, testOrganization.Organization, update)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+	assert.Equal(t, int64(0), eventsRelinked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateOrganizationRelinkError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateOrganization)).
+		WithArgs(testOrganization.SCPName, testOrganization.Organization, "newOrg").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlRelinkScoringEvents)).
+		WithArgs(testOrganization.SCPName, testOrganization.Organization, "newOrg").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "scoring_event_pkey"})
+	mock.ExpectRollback()
+
+	update := &types.OrganizationUpdate{Organization: "newOrg", RelinkScores: true}
+	_, eventsRelinked, err := db.UpdateOrganization(context.Background(), testOrganization.SCPName, testOrganization.Organization, update)
+	assert.Equal(t, &DuplicateError{Entity: "scoring event", Constraint: "scoring_event_pkey"}, err)
+	assert.Equal(t, int64(0), eventsRelinked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateOrganization(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateOrganization)).
+		WithArgs(testOrganization.SCPName, testOrganization.Organization, "newOrg").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlRelinkScoringEvents)).
+		WithArgs(testOrganization.SCPName, testOrganization.Organization, "newOrg").
+		WillReturnResult(sqlmock.NewResult(0, 5))
+	mock.ExpectCommit()
+
+	update := &types.OrganizationUpdate{Organization: "newOrg", RelinkScores: true}
+	rowsAffected, eventsRelinked, err := db.UpdateOrganization(context.Background(), testOrganization.SCPName, testOrganization.Organization, update)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), rowsAffected)
+	assert.Equal(t, int64(5), eventsRelinked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+const loginName = "loginName"
+
+func TestSelectParticipantsToScoreSelectError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced current campaign read error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantId)).
+		WithArgs(now, TestEventSourceValid, loginName, TestOrgValid).
+		WillReturnError(forcedError)
+
+	msg := &types.ScoringMessage{EventSource: TestEventSourceValid, RepoOwner: TestOrgValid, TriggerUser: loginName}
+
+	participantsToScore, err := db.SelectParticipantsToScore(context.Background(), msg, now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, participantsToScore)
+}
+
+func TestSelectParticipantsToScoreScanError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantId)).
+		WithArgs(now, TestEventSourceValid, loginName, TestOrgValid).
+		WillReturnRows(sqlmock.NewRows([]string{"Id"}).
+			// force scan error due to mismatched column count
+			AddRow(-1))
+
+	msg := &types.ScoringMessage{EventSource: TestEventSourceValid, RepoOwner: TestOrgValid, TriggerUser: loginName}
+
+	participantsToScore, err := db.SelectParticipantsToScore(context.Background(), msg, now)
+	assert.EqualError(t, err, "sql: expected 1 destination arguments in Scan, not 5")
+	assert.Nil(t, participantsToScore)
+}
+
+func TestSelectParticipantsToScoreValidTeam(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantId)).
+		WithArgs(now, TestEventSourceValid, loginName, TestOrgValid).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "CampaignName", "SCPName", "loginName", "teamName"}).
+			// force scan error due to type mismatch at ID column
+			AddRow(now, "someCampaign", "someSCP", "someLoginName", "someTeamName"))
+
+	msg := &types.ScoringMessage{EventSource: TestEventSourceValid, RepoOwner: TestOrgValid, TriggerUser: loginName}
+
+	participantsToScore, err := db.SelectParticipantsToScore(context.Background(), msg, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "someTeamName", participantsToScore[0].TeamName)
 }
 
 func TestSelectParticipantsToScoreNoTeam(t *testing.T) {
@@ -517,14 +1066,14 @@ func TestSelectParticipantsToScoreNoTeam(t *testing.T) {
 	defer closeDbFunc()
 
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantId)).
-		WithArgs(now, TestEventSourceValid, loginName).
+		WithArgs(now, TestEventSourceValid, loginName, TestOrgValid).
 		WillReturnRows(sqlmock.NewRows([]string{"Id", "CampaignName", "SCPName", "loginName", "teamName"}).
 			// force scan error due to type mismatch at ID column
 			AddRow(now, "someCampaign", "someSCP", "someLoginName", nil))
 
 	msg := &types.ScoringMessage{EventSource: TestEventSourceValid, RepoOwner: TestOrgValid, TriggerUser: loginName}
 
-	participantsToScore, err := db.SelectParticipantsToScore(msg, now)
+	participantsToScore, err := db.SelectParticipantsToScore(context.Background(), msg, now)
 	assert.NoError(t, err)
 	assert.Equal(t, "", participantsToScore[0].TeamName)
 }
@@ -535,12 +1084,12 @@ func TestSelectPointValueScanError(t *testing.T) {
 
 	forcedError := fmt.Errorf("forced point value error")
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectPointValue)).
-		WithArgs(testCampaign.Name, testBugType).
+		WithArgs(testCampaign.Name, testBugType, TestEventSourceValid, TestOrgValid).
 		WillReturnError(forcedError)
 
 	msg := &types.ScoringMessage{EventSource: TestEventSourceValid, RepoOwner: TestOrgValid, TriggerUser: loginName}
 
-	participantsToScore := db.SelectPointValue(msg, testCampaign.Name, testBugType)
+	participantsToScore := db.SelectPointValue(context.Background(), msg, testCampaign.Name, testBugType)
 	assert.Equal(t, float64(1), participantsToScore)
 }
 
@@ -549,15 +1098,197 @@ func TestSelectPointValueRead(t *testing.T) {
 	defer closeDbFunc()
 
 	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectPointValue)).
-		WithArgs(testCampaign.Name, testBugType).
+		WithArgs(testCampaign.Name, testBugType, TestEventSourceValid, TestOrgValid).
 		WillReturnRows(sqlmock.NewRows([]string{"points"}).AddRow(5))
 
 	msg := &types.ScoringMessage{EventSource: TestEventSourceValid, RepoOwner: TestOrgValid, TriggerUser: loginName}
 
-	participantsToScore := db.SelectPointValue(msg, testCampaign.Name, testBugType)
+	participantsToScore := db.SelectPointValue(context.Background(), msg, testCampaign.Name, testBugType)
 	assert.Equal(t, float64(5), participantsToScore)
 }
 
+func TestUpsertPointValueOverrideNoOrganization(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertPointValueOverride)).
+		WithArgs(campaignName, "GitHub", "myOrg", testBugType, float64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"Id"}))
+
+	err := db.UpsertPointValueOverride(context.Background(),
+		&types.PointValueOverride{CampaignName: campaignName, ScpName: "GitHub", Organization: "myOrg", Category: testBugType, PointValue: 5})
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestUpsertPointValueOverride(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertPointValueOverride)).
+		WithArgs(campaignName, "GitHub", "myOrg", testBugType, float64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"Id"}).AddRow("overrideId"))
+
+	override := types.PointValueOverride{CampaignName: campaignName, ScpName: "GitHub", Organization: "myOrg", Category: testBugType, PointValue: 5}
+	assert.NoError(t, db.UpsertPointValueOverride(context.Background(), &override))
+	assert.Equal(t, "overrideId", override.Id)
+}
+
+func TestSelectPointValueOverrides(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectPointValueOverrides)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "scp", "organization", "category", "point_value"}).
+			AddRow("overrideId", "GitHub", "myOrg", testBugType, 5))
+
+	overrides, err := db.SelectPointValueOverrides(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.PointValueOverride{
+		{Id: "overrideId", CampaignName: campaignName, ScpName: "GitHub", Organization: "myOrg", Category: testBugType, PointValue: 5},
+	}, overrides)
+}
+
+func TestDeletePointValueOverride(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeletePointValueOverride)).
+		WithArgs(campaignName, "GitHub", "myOrg", testBugType).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.DeletePointValueOverride(context.Background(),
+		&types.PointValueOverride{CampaignName: campaignName, ScpName: "GitHub", Organization: "myOrg", Category: testBugType})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+}
+
+func TestInsertBugCategoryExclusionNoCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertBugCategoryExclusion)).
+		WithArgs(campaignName, testBugType).
+		WillReturnRows(sqlmock.NewRows([]string{"Id"}))
+
+	err := db.InsertBugCategoryExclusion(context.Background(), &types.BugCategoryExclusion{CampaignName: campaignName, Category: testBugType})
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestInsertBugCategoryExclusionDuplicate(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertBugCategoryExclusion)).
+		WithArgs(campaignName, testBugType).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "bug_category_exclusion_fk_campaign_category_key"})
+
+	err := db.InsertBugCategoryExclusion(context.Background(), &types.BugCategoryExclusion{CampaignName: campaignName, Category: testBugType})
+	assert.Equal(t, &DuplicateError{Entity: "bug category exclusion", Constraint: "bug_category_exclusion_fk_campaign_category_key"}, err)
+}
+
+func TestInsertBugCategoryExclusion(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertBugCategoryExclusion)).
+		WithArgs(campaignName, testBugType).
+		WillReturnRows(sqlmock.NewRows([]string{"Id"}).AddRow("exclusionGuid"))
+
+	exclusion := types.BugCategoryExclusion{CampaignName: campaignName, Category: testBugType}
+	assert.NoError(t, db.InsertBugCategoryExclusion(context.Background(), &exclusion))
+	assert.Equal(t, "exclusionGuid", exclusion.Id)
+}
+
+func TestSelectBugCategoryExclusions(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectBugCategoryExclusions)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "category"}).AddRow("exclusionGuid", testBugType))
+
+	exclusions, err := db.SelectBugCategoryExclusions(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.BugCategoryExclusion{{Id: "exclusionGuid", CampaignName: campaignName, Category: testBugType}}, exclusions)
+}
+
+func TestDeleteBugCategoryExclusion(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteBugCategoryExclusion)).
+		WithArgs(campaignName, testBugType).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.DeleteBugCategoryExclusion(context.Background(), campaignName, testBugType)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+}
+
+func TestInsertRepositoryFilterNoCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertRepositoryFilter)).
+		WithArgs(campaignName, "deny", "*-docs").
+		WillReturnRows(sqlmock.NewRows([]string{"Id"}))
+
+	err := db.InsertRepositoryFilter(context.Background(), &types.RepositoryFilter{CampaignName: campaignName, Kind: "deny", Pattern: "*-docs"})
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestInsertRepositoryFilterDuplicate(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertRepositoryFilter)).
+		WithArgs(campaignName, "deny", "*-docs").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "repository_filter_fk_campaign_kind_pattern_key"})
+
+	err := db.InsertRepositoryFilter(context.Background(), &types.RepositoryFilter{CampaignName: campaignName, Kind: "deny", Pattern: "*-docs"})
+	assert.Equal(t, &DuplicateError{Entity: "repository filter", Constraint: "repository_filter_fk_campaign_kind_pattern_key"}, err)
+}
+
+func TestInsertRepositoryFilter(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertRepositoryFilter)).
+		WithArgs(campaignName, "allow", "Widget*").
+		WillReturnRows(sqlmock.NewRows([]string{"Id"}).AddRow("filterGuid"))
+
+	filter := types.RepositoryFilter{CampaignName: campaignName, Kind: "allow", Pattern: "Widget*"}
+	assert.NoError(t, db.InsertRepositoryFilter(context.Background(), &filter))
+	assert.Equal(t, types.RepositoryFilter{Id: "filterGuid", CampaignName: campaignName, Kind: "allow", Pattern: "widget*"}, filter)
+}
+
+func TestSelectRepositoryFilters(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectRepositoryFilters)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "kind", "pattern"}).AddRow("filterGuid", "deny", "*-docs"))
+
+	filters, err := db.SelectRepositoryFilters(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.RepositoryFilter{{Id: "filterGuid", CampaignName: campaignName, Kind: "deny", Pattern: "*-docs"}}, filters)
+}
+
+func TestDeleteRepositoryFilter(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteRepositoryFilter)).
+		WithArgs(campaignName, "filterGuid").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.DeleteRepositoryFilter(context.Background(), campaignName, "filterGuid")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+}
+
 const testParticipantGuid = "testParticipantGuid"
 
 func TestUpdateParticipantScoreError(t *testing.T) {
@@ -569,7 +1300,7 @@ func TestUpdateParticipantScoreError(t *testing.T) {
 		WithArgs(float64(0), testParticipantGuid).
 		WillReturnError(forcedError)
 
-	err := db.UpdateParticipantScore(&types.ParticipantStruct{ID: testParticipantGuid}, 0)
+	err := db.UpdateParticipantScore(context.Background(), &types.ParticipantStruct{ID: testParticipantGuid}, 0)
 	assert.EqualError(t, err, forcedError.Error())
 }
 
@@ -581,7 +1312,9 @@ func TestUpdateParticipantScoreZero(t *testing.T) {
 		WithArgs(float64(0), testParticipantGuid).
 		WillReturnRows(sqlmock.NewRows([]string{"score"}).AddRow(3))
 
-	assert.NoError(t, db.UpdateParticipantScore(&types.ParticipantStruct{ID: testParticipantGuid}, 0))
+	participant := types.ParticipantStruct{ID: testParticipantGuid}
+	assert.NoError(t, db.UpdateParticipantScore(context.Background(), &participant, 0))
+	assert.Equal(t, float64(3), participant.Score)
 }
 
 func TestSelectPriorScoreError(t *testing.T) {
@@ -601,7 +1334,7 @@ func TestSelectPriorScoreError(t *testing.T) {
 		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest).
 		WillReturnError(forcedError)
 
-	oldPoints := db.SelectPriorScore(testParticipant, msg)
+	oldPoints := db.SelectPriorScore(context.Background(), testParticipant, msg)
 	assert.Equal(t, float64(0), oldPoints)
 }
 
@@ -621,7 +1354,7 @@ func TestSelectPriorScore(t *testing.T) {
 		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest).
 		WillReturnRows(sqlmock.NewRows([]string{"score"}).AddRow(-2))
 
-	oldPoints := db.SelectPriorScore(testParticipant, msg)
+	oldPoints := db.SelectPriorScore(context.Background(), testParticipant, msg)
 	assert.Equal(t, float64(-2), oldPoints)
 }
 
@@ -641,10 +1374,10 @@ func TestInsertScoringEventError(t *testing.T) {
 
 	forcedError := fmt.Errorf("forced insert score error")
 	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringEvent)).
-		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, msg.TriggerUser, newPoints).
+		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, msg.TriggerUser, newPoints, []byte(nil)).
 		WillReturnError(forcedError)
 
-	assert.EqualError(t, db.InsertScoringEvent(testParticipant, msg, newPoints), forcedError.Error())
+	assert.EqualError(t, db.InsertScoringEvent(context.Background(), testParticipant, msg, newPoints, nil), forcedError.Error())
 }
 
 func TestInsertScoringEvent(t *testing.T) {
@@ -661,513 +1394,4103 @@ func TestInsertScoringEvent(t *testing.T) {
 
 	const newPoints = float64(11)
 
+	breakdown := &types.ScoreBreakdown{
+		Categories: []types.CategoryScore{{Category: bugCategory, Count: 1, PointValue: 11, Points: 11}},
+		Classified: 1,
+		BasePoints: 11,
+		Points:     newPoints,
+		Delta:      newPoints,
+	}
 	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringEvent)).
-		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, msg.TriggerUser, newPoints).
+		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, msg.RepoOwner, msg.RepoName, msg.PullRequest, msg.TriggerUser, newPoints,
+			[]byte(`{"categories":[{"category":"bugCategory","count":1,"pointValue":11,"points":11}],"classified":1,"basePoints":11,"unclassifiedBonus":0,"points":11,"priorPoints":0,"delta":11}`)).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	assert.NoError(t, db.InsertScoringEvent(testParticipant, msg, newPoints))
+	assert.NoError(t, db.InsertScoringEvent(context.Background(), testParticipant, msg, newPoints, breakdown))
 }
 
-func TestInsertParticipantError(t *testing.T) {
+func TestInsertScoringEventFingerprintsError(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	testParticipant := types.ParticipantStruct{
-		Score: -2,
-	}
+	testParticipant := &types.ParticipantStruct{CampaignName: testCampaign.Name, ScpName: "scpName"}
+	msg := &types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", TriggerUser: loginName, PullRequest: 1, Fingerprints: []string{"fp1"}}
 
-	forcedError := fmt.Errorf("forced insert participant error")
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertParticipant)).
-		WithArgs(testParticipant.ScpName, testParticipant.CampaignName,
-			testParticipant.LoginName, testParticipant.Email, testParticipant.DisplayName, 0).
+	forcedError := fmt.Errorf("forced insert fingerprints error")
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringEvent)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 1, loginName, float64(11), []byte(nil)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringEventFingerprints)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 1, pq.Array([]string{"fp1"})).
 		WillReturnError(forcedError)
+	mock.ExpectRollback()
 
-	assert.EqualError(t, db.InsertParticipant(&testParticipant), forcedError.Error())
-	assert.Equal(t, "", testParticipant.ID)
-	assert.Equal(t, -2, testParticipant.Score)
-	assert.Equal(t, time.Time{}, testParticipant.JoinedAt)
+	assert.EqualError(t, db.InsertScoringEvent(context.Background(), testParticipant, msg, 11, nil), forcedError.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestInsertParticipant(t *testing.T) {
+func TestInsertScoringEventFingerprints(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	testParticipant := types.ParticipantStruct{
-		// ID will be empty when created from endpoint request
-		CampaignName: testCampaign.Name,
-		ScpName:      "scpName",
-		LoginName:    "loginName",
-		Email:        "email",
-		DisplayName:  "displayName",
-		Score:        -1, // this should be ignored during insert
-	}
+	testParticipant := &types.ParticipantStruct{CampaignName: testCampaign.Name, ScpName: "scpName"}
+	msg := &types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", TriggerUser: loginName, PullRequest: 1, Fingerprints: []string{"fp1", "fp2"}}
 
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertParticipant)).
-		WithArgs(testParticipant.ScpName, testParticipant.CampaignName,
-			testParticipant.LoginName, testParticipant.Email, testParticipant.DisplayName, 0).
-		WillReturnRows(sqlmock.NewRows([]string{"guid", "score", "joinedAt"}).
-			AddRow(testParticipantGuid, 0, now))
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringEvent)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 1, loginName, float64(11), []byte(nil)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringEventFingerprints)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 1, pq.Array([]string{"fp1", "fp2"})).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
 
-	assert.NoError(t, db.InsertParticipant(&testParticipant))
-	assert.Equal(t, testParticipantGuid, testParticipant.ID)
-	assert.Equal(t, 0, testParticipant.Score)
-	assert.Equal(t, now, testParticipant.JoinedAt)
+	assert.NoError(t, db.InsertScoringEvent(context.Background(), testParticipant, msg, 11, nil))
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestInsertTeamError(t *testing.T) {
+func TestSelectDuplicateFingerprintsNone(t *testing.T) {
+	_, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	duplicates, err := db.SelectDuplicateFingerprints(context.Background(), &types.ParticipantStruct{}, &types.ScoringMessage{})
+	assert.NoError(t, err)
+	assert.Nil(t, duplicates)
+}
+
+func TestSelectDuplicateFingerprintsError(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	testTeam := types.TeamStruct{
-		// ID will be empty when created from endpoint request
-		CampaignName: testCampaign.Name,
-		Name:         "teamName",
-	}
+	participant := &types.ParticipantStruct{CampaignName: testCampaign.Name, ScpName: "scpName", LoginName: loginName}
+	msg := &types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", PullRequest: 1, Fingerprints: []string{"fp1"}}
 
-	forcedError := fmt.Errorf("forced insert team error")
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertTeam)).
-		WithArgs(testTeam.CampaignName, testTeam.Name).
+	forcedError := fmt.Errorf("forced duplicates error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectDuplicateFingerprints)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 1, loginName, pq.Array([]string{"fp1"})).
 		WillReturnError(forcedError)
 
-	assert.EqualError(t, db.InsertTeam(&testTeam), forcedError.Error())
-	assert.Equal(t, "", testTeam.Id)
+	_, err := db.SelectDuplicateFingerprints(context.Background(), participant, msg)
+	assert.EqualError(t, err, forcedError.Error())
 }
 
-const testTeamGuid = "testTeamGuid"
-
-func TestInsertTeam(t *testing.T) {
+func TestSelectDuplicateFingerprints(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	testTeam := types.TeamStruct{
-		// ID will be empty when created from endpoint request
-		CampaignName: testCampaign.Name,
-		Name:         "teamName",
-	}
+	participant := &types.ParticipantStruct{CampaignName: testCampaign.Name, ScpName: "scpName", LoginName: loginName}
+	msg := &types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", PullRequest: 1, Fingerprints: []string{"fp1", "fp2", "fp3"}}
 
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertTeam)).
-		WithArgs(testTeam.CampaignName, testTeam.Name).
-		WillReturnRows(sqlmock.NewRows([]string{"guid"}).
-			AddRow(testTeamGuid))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectDuplicateFingerprints)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 1, loginName, pq.Array([]string{"fp1", "fp2", "fp3"})).
+		WillReturnRows(sqlmock.NewRows([]string{"fingerprint"}).AddRow("fp1").AddRow("fp3"))
 
-	err := db.InsertTeam(&testTeam)
+	duplicates, err := db.SelectDuplicateFingerprints(context.Background(), participant, msg)
 	assert.NoError(t, err)
-	assert.Equal(t, testTeamGuid, testTeam.Id)
+	assert.Equal(t, []string{"fp1", "fp3"}, duplicates)
 }
 
-const campaignName = "campaignName"
-const scpName = "scpName"
-
-func TestSelectParticipantDetailError(t *testing.T) {
+func TestSelectCoAuthorPriorScore(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	forcedError := fmt.Errorf("forced insert team error")
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantDetail)).
-		WithArgs(campaignName, scpName, loginName).
-		WillReturnError(forcedError)
+	coAuthor := &types.ParticipantStruct{CampaignName: testCampaign.Name, ScpName: "scpName", LoginName: "pairLoginName"}
+	msg := &types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", PullRequest: 1}
 
-	participant, err := db.SelectParticipantDetail(campaignName, scpName, loginName)
-	assert.EqualError(t, err, forcedError.Error())
-	assert.Equal(t, &types.ParticipantStruct{}, participant)
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCoAuthorPriorScore)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 1, "pairLoginName").
+		WillReturnRows(sqlmock.NewRows([]string{"points"}).AddRow(1.5))
+
+	assert.Equal(t, 1.5, db.SelectCoAuthorPriorScore(context.Background(), coAuthor, msg))
 }
 
-func TestSelectParticipantDetailNoTeam(t *testing.T) {
+func TestSelectCoAuthorPriorScoreNew(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantDetail)).
-		WithArgs(campaignName, scpName, loginName).
-		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt"}).
-			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, sql.NullString{}, now))
+	coAuthor := &types.ParticipantStruct{CampaignName: testCampaign.Name, ScpName: "scpName", LoginName: "pairLoginName"}
+	msg := &types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", PullRequest: 1}
 
-	participant, err := db.SelectParticipantDetail(campaignName, scpName, loginName)
-	assert.NoError(t, err)
-	assert.Equal(t, &types.ParticipantStruct{
-		ID:           testParticipantGuid,
-		CampaignName: campaignName,
-		ScpName:      scpName,
-		LoginName:    loginName,
-		Email:        "email",
-		DisplayName:  "display",
-		Score:        -1,
-		TeamName:     "",
-		JoinedAt:     now,
-	}, participant)
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCoAuthorPriorScore)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 1, "pairLoginName").
+		WillReturnRows(sqlmock.NewRows([]string{"points"}))
+
+	assert.Equal(t, float64(0), db.SelectCoAuthorPriorScore(context.Background(), coAuthor, msg))
+}
+
+func TestInsertCoAuthorScore(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	coAuthor := &types.ParticipantStruct{CampaignName: testCampaign.Name, ScpName: "scpName", LoginName: "pairLoginName"}
+	msg := &types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", PullRequest: 1}
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertCoAuthorScore)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 1, "pairLoginName", 1.5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, db.InsertCoAuthorScore(context.Background(), coAuthor, msg, 1.5))
+}
+
+func TestSelectCoAuthorScores(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	participant := &types.ParticipantStruct{CampaignName: testCampaign.Name, ScpName: "scpName", LoginName: "loginName"}
+	msg := &types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", PullRequest: 1}
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCoAuthorScores)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "login_name", "team", "points"}).AddRow("pairId", "pairLoginName", "pairTeam", 1.5))
+
+	coAuthors, err := db.SelectCoAuthorScores(context.Background(), participant, msg)
+	assert.NoError(t, err)
+	assert.Equal(t, []CoAuthorScoreRow{{Participant: types.ParticipantStruct{ID: "pairId", CampaignName: testCampaign.Name,
+		ScpName: "scpName", LoginName: "pairLoginName", TeamName: "pairTeam"}, Points: 1.5}}, coAuthors)
+}
+
+func TestSelectCoAuthorScoresError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced co-author scores error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCoAuthorScores)).WillReturnError(forcedError)
+
+	coAuthors, err := db.SelectCoAuthorScores(context.Background(), &types.ParticipantStruct{}, &types.ScoringMessage{})
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, coAuthors)
+}
+
+func TestDeleteCoAuthorScore(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	coAuthor := &types.ParticipantStruct{CampaignName: testCampaign.Name, ScpName: "scpName", LoginName: "pairLoginName"}
+	msg := &types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", PullRequest: 1}
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteCoAuthorScore)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 1, "pairLoginName").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, db.DeleteCoAuthorScore(context.Background(), coAuthor, msg))
+}
+
+func TestInsertScoringEventsNone(t *testing.T) {
+	_, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	assert.NoError(t, db.InsertScoringEvents(context.Background(), nil, nil))
+}
+
+func TestInsertScoringEventsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	event := ScoringEventRow{
+		Participant: types.ParticipantStruct{CampaignName: testCampaign.Name, ScpName: "scpName"},
+		Msg:         types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", TriggerUser: loginName, PullRequest: 1},
+		NewPoints:   11,
+	}
+	forcedError := fmt.Errorf("forced insert scores error")
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringEvents(1))).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 1, loginName, float64(11), []byte(nil)).
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	assert.EqualError(t, db.InsertScoringEvents(context.Background(), []ScoringEventRow{event}, nil), forcedError.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInsertScoringEvents(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	events := make([]ScoringEventRow, scoringEventsPerInsert+1)
+	for i := range events {
+		events[i] = ScoringEventRow{
+			Participant: types.ParticipantStruct{CampaignName: testCampaign.Name, ScpName: "scpName"},
+			Msg:         types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", TriggerUser: loginName, PullRequest: i},
+			NewPoints:   11,
+			Breakdown:   &types.ScoreBreakdown{Points: 11},
+		}
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringEvents(scoringEventsPerInsert))).
+		WillReturnResult(sqlmock.NewResult(0, scoringEventsPerInsert))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringEvents(1))).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", scoringEventsPerInsert, loginName, float64(11),
+			[]byte(`{"categories":null,"classified":0,"basePoints":0,"unclassifiedBonus":0,"points":11,"priorPoints":0,"delta":0}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, db.InsertScoringEvents(context.Background(), events, nil))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInsertScoringEventsScoreDeltaError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	event := ScoringEventRow{
+		Participant: types.ParticipantStruct{CampaignName: testCampaign.Name, ScpName: "scpName"},
+		Msg:         types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "testRepoName", TriggerUser: loginName, PullRequest: 1},
+		NewPoints:   11,
+	}
+	forcedError := fmt.Errorf("forced update score error")
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringEvents(1))).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 1, loginName, float64(11), []byte(nil)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipantScore)).
+		WithArgs(float64(11), "participantId").
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	assert.EqualError(t, db.InsertScoringEvents(context.Background(), []ScoringEventRow{event},
+		[]ParticipantScoreDelta{{ParticipantId: "participantId", Delta: 11}}), forcedError.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInsertScoringEventsScoreDeltas(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipantScore)).
+		WithArgs(float64(2), "participantId").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipantScore)).
+		WithArgs(float64(-1), "otherParticipantId").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, db.InsertScoringEvents(context.Background(), nil, []ParticipantScoreDelta{
+		{ParticipantId: "participantId", Delta: 2},
+		{ParticipantId: "otherParticipantId", Delta: -1},
+	}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelectScoringEventNotFound(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringEvent)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 5).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := db.SelectScoringEvent(context.Background(), testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 5)
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestSelectScoringEventWithoutBreakdown(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringEvent)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "campaign", "scp", "repoOwner", "repoName", "pr", "username", "points", "breakdown", "voided_on"}).
+			AddRow(testScoreEventId, testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 5, loginName, 3, nil, nil))
+
+	event, err := db.SelectScoringEvent(context.Background(), testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.ScoringEventStruct{
+		ID:           testScoreEventId,
+		CampaignName: testCampaign.Name,
+		ScpName:      "scpName",
+		RepoOwner:    TestOrgValid,
+		RepoName:     "testRepoName",
+		PullRequest:  5,
+		UserName:     loginName,
+		Points:       3,
+	}, event)
+}
+
+func TestSelectScoringEvent(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringEvent)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "campaign", "scp", "repoOwner", "repoName", "pr", "username", "points", "breakdown", "voided_on"}).
+			AddRow(testScoreEventId, testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 5, loginName, 3,
+				[]byte(`{"classified":0,"basePoints":0,"unclassifiedBonus":3,"points":3,"priorPoints":1,"delta":2}`), now))
+
+	event, err := db.SelectScoringEvent(context.Background(), testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.ScoreBreakdown{UnclassifiedBonus: 3, Points: 3, PriorPoints: 1, Delta: 2}, event.Breakdown)
+	assert.Equal(t, now, *event.VoidedOn)
+}
+
+const testScoreEventId = "testScoreEventId"
+
+func TestExportScoringEventsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced export error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlExportScoringEvents)).
+		WithArgs(testCampaign.Name).
+		WillReturnError(forcedError)
+
+	err := db.ExportScoringEvents(context.Background(), testCampaign.Name, func(event *types.ScoringEventStruct) error {
+		assert.Fail(t, "unexpected event", event)
+		return nil
+	})
+	assert.EqualError(t, err, forcedError.Error())
+}
+
+func TestExportScoringEvents(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlExportScoringEvents)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "campaign", "scp", "repoOwner", "repoName", "pr", "username", "points", "breakdown", "voided_on", "scored_on"}).
+			AddRow(testScoreEventId, testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 5, loginName, 3,
+				[]byte(`{"classified":0,"basePoints":0,"unclassifiedBonus":3,"points":3,"priorPoints":0,"delta":3}`), nil, now).
+			AddRow("otherScoreEventId", testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 6, loginName, 1, nil, now, nil))
+
+	var exported []types.ScoringEventStruct
+	err := db.ExportScoringEvents(context.Background(), testCampaign.Name, func(event *types.ScoringEventStruct) error {
+		exported = append(exported, *event)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ScoringEventStruct{
+		{
+			ID: testScoreEventId, CampaignName: testCampaign.Name, ScpName: "scpName", RepoOwner: TestOrgValid,
+			RepoName: "testRepoName", PullRequest: 5, UserName: loginName, Points: 3,
+			Breakdown: &types.ScoreBreakdown{UnclassifiedBonus: 3, Points: 3, Delta: 3}, ScoredOn: &now,
+		},
+		{
+			ID: "otherScoreEventId", CampaignName: testCampaign.Name, ScpName: "scpName", RepoOwner: TestOrgValid,
+			RepoName: "testRepoName", PullRequest: 6, UserName: loginName, Points: 1, VoidedOn: &now,
+		},
+	}, exported)
+}
+
+func TestExportScoringEventsStopped(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlExportScoringEvents)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "campaign", "scp", "repoOwner", "repoName", "pr", "username", "points", "breakdown", "voided_on", "scored_on"}).
+			AddRow(testScoreEventId, testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 5, loginName, 3, nil, nil, now).
+			AddRow("otherScoreEventId", testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 6, loginName, 1, nil, nil, now))
+
+	forcedError := fmt.Errorf("forced write error")
+	calls := 0
+	err := db.ExportScoringEvents(context.Background(), testCampaign.Name, func(event *types.ScoringEventStruct) error {
+		calls++
+		return forcedError
+	})
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, 1, calls)
+}
+
+func TestImportScoringEventsNone(t *testing.T) {
+	_, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	imported, recomputed, err := db.ImportScoringEvents(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), imported)
+	assert.Equal(t, int64(0), recomputed)
+}
+
+func TestImportScoringEventsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced import error")
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignArchived)).
+		WithArgs(testCampaign.Name).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlImportScoringEvent)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 5, loginName, 3.0, []byte(nil), nil, nil).
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	imported, recomputed, err := db.ImportScoringEvents(context.Background(), []types.ScoringEventStruct{
+		{CampaignName: testCampaign.Name, ScpName: "scpName", RepoOwner: TestOrgValid, RepoName: "testRepoName", PullRequest: 5, UserName: loginName, Points: 3},
+	})
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, int64(0), imported)
+	assert.Equal(t, int64(0), recomputed)
+}
+
+func TestImportScoringEventsArchived(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignArchived)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"fk_campaign"}).AddRow("campaignId"))
+	mock.ExpectRollback()
+
+	imported, recomputed, err := db.ImportScoringEvents(context.Background(), []types.ScoringEventStruct{
+		{CampaignName: testCampaign.Name, ScpName: "scpName", RepoOwner: TestOrgValid, RepoName: "testRepoName", PullRequest: 5, UserName: loginName, Points: 3},
+	})
+	assert.Equal(t, ErrCampaignArchived, err)
+	assert.Equal(t, int64(0), imported)
+	assert.Equal(t, int64(0), recomputed)
+}
+
+func TestImportScoringEvents(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignArchived)).
+		WithArgs(testCampaign.Name).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlImportScoringEvent)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 5, loginName, 3.0,
+			[]byte(`{"categories":null,"classified":3,"basePoints":0,"unclassifiedBonus":0,"points":3,"priorPoints":0,"delta":3}`), nil, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// already scored, so skipped
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlImportScoringEvent)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 6, loginName, 1.0, []byte(nil), now, now).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlImportScoringEvent)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 7, loginName, 2.0, []byte(nil), nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlRecomputeParticipantScore)).
+		WithArgs(testCampaign.Name, "scpName", loginName).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	imported, recomputed, err := db.ImportScoringEvents(context.Background(), []types.ScoringEventStruct{
+		{CampaignName: testCampaign.Name, ScpName: "scpName", RepoOwner: TestOrgValid, RepoName: "testRepoName", PullRequest: 5,
+			UserName: loginName, Points: 3, Breakdown: &types.ScoreBreakdown{Classified: 3, Points: 3, Delta: 3}, ScoredOn: &now},
+		{CampaignName: testCampaign.Name, ScpName: "scpName", RepoOwner: TestOrgValid, RepoName: "testRepoName", PullRequest: 6,
+			UserName: loginName, Points: 1, VoidedOn: &now, ScoredOn: &now},
+		{CampaignName: testCampaign.Name, ScpName: "scpName", RepoOwner: TestOrgValid, RepoName: "testRepoName", PullRequest: 7,
+			UserName: loginName, Points: 2},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), imported)
+	assert.Equal(t, int64(1), recomputed)
+}
+
+func TestGzipScoringEvents(t *testing.T) {
+	events := []types.ScoringEventStruct{
+		{CampaignName: testCampaign.Name, ScpName: "scpName", RepoOwner: TestOrgValid, RepoName: "testRepoName", PullRequest: 5,
+			UserName: loginName, Points: 3, CoAuthors: []types.CoAuthorScore{{LoginName: "coAuthor", Points: 1}}},
+		{CampaignName: testCampaign.Name, ScpName: "scpName", RepoOwner: TestOrgValid, RepoName: "testRepoName", PullRequest: 6,
+			UserName: loginName, Points: 1},
+	}
+	archived, err := gzipScoringEvents(events)
+	assert.NoError(t, err)
+
+	unarchived, err := gunzipScoringEvents(archived)
+	assert.NoError(t, err)
+	assert.Equal(t, events, unarchived)
+}
+
+func TestGunzipScoringEventsNotGzip(t *testing.T) {
+	_, err := gunzipScoringEvents([]byte("not gzip"))
+	assert.Error(t, err)
+}
+
+func TestSelectCampaignsToArchive(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignsToArchive)).
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow(testCampaign.Name).AddRow("otherCampaign"))
+
+	campaignNames, err := db.SelectCampaignsToArchive(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{testCampaign.Name, "otherCampaign"}, campaignNames)
+}
+
+func TestArchiveScoringEventsAlreadyArchived(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlClaimScoringEventArchive)).
+		WithArgs(testCampaign.Name, now).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	archive, err := db.ArchiveScoringEvents(context.Background(), testCampaign.Name, now)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, archive)
+}
+
+func TestArchiveScoringEvents(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlClaimScoringEventArchive)).
+		WithArgs(testCampaign.Name, now).
+		WillReturnRows(sqlmock.NewRows([]string{"fk_campaign"}).AddRow("testCampaignId"))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignCoAuthors)).
+		WithArgs("testCampaignId").
+		WillReturnRows(sqlmock.NewRows([]string{"fk_scoring_event", "username", "points"}).AddRow(testScoreEventId, "coAuthor", 1))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlExportScoringEvents)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "campaign", "scp", "repoOwner", "repoName", "pr", "username", "points", "breakdown", "voided_on", "scored_on"}).
+			AddRow(testScoreEventId, testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 5, loginName, 3, nil, nil, now).
+			AddRow("otherScoreEventId", testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 6, loginName, 1, nil, now, now))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateScoringEventArchive)).
+		WithArgs("testCampaignId", sqlmock.AnyArg(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteCampaignScoringEvents)).
+		WithArgs("testCampaignId").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	archive, err := db.ArchiveScoringEvents(context.Background(), testCampaign.Name, now)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.ScoringEventArchive{CampaignName: testCampaign.Name, EventCount: 2, ArchivedOn: now}, archive)
+}
+
+func TestRestoreScoringEventsNoArchive(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringEventArchiveToRestore)).
+		WithArgs(testCampaign.Name).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	archive, err := db.RestoreScoringEvents(context.Background(), testCampaign.Name, now)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, archive)
+}
+
+func TestRestoreScoringEvents(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	archived, err := gzipScoringEvents([]types.ScoringEventStruct{
+		{ID: testScoreEventId, CampaignName: testCampaign.Name, ScpName: "scpName", RepoOwner: TestOrgValid, RepoName: "testRepoName",
+			PullRequest: 5, UserName: loginName, Points: 3, ScoredOn: &now, CoAuthors: []types.CoAuthorScore{{LoginName: "coAuthor", Points: 1}}},
+		{ID: "otherScoreEventId", CampaignName: testCampaign.Name, ScpName: "scpName", RepoOwner: TestOrgValid, RepoName: "testRepoName",
+			PullRequest: 6, UserName: loginName, Points: 1, ScoredOn: &now},
+	})
+	assert.NoError(t, err)
+	archivedOn := now.Add(-time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringEventArchiveToRestore)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"fk_campaign", "events", "archived_on"}).AddRow("testCampaignId", archived, archivedOn))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlRestoreScoringEvent)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 5, loginName, 3.0, []byte(nil), nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"Id"}).AddRow("restoredEventId"))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlRestoreCoAuthorScore)).
+		WithArgs("restoredEventId", "coAuthor", 1.0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// scored again since, so skipped
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlRestoreScoringEvent)).
+		WithArgs(testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 6, loginName, 1.0, []byte(nil), nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"Id"}))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateScoringEventArchiveRestored)).
+		WithArgs("testCampaignId", now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	archive, err := db.RestoreScoringEvents(context.Background(), testCampaign.Name, now)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.ScoringEventArchive{CampaignName: testCampaign.Name, EventCount: 1, ArchivedOn: archivedOn, RestoredOn: &now}, archive)
+}
+
+func TestSelectScoringEventArchives(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringEventArchives)).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "event_count", "archived_on", "restored_on"}).
+			AddRow(testCampaign.Name, 2, now, nil).
+			AddRow("otherCampaign", 1, now, now))
+
+	archives, err := db.SelectScoringEventArchives(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ScoringEventArchive{
+		{CampaignName: testCampaign.Name, EventCount: 2, ArchivedOn: now},
+		{CampaignName: "otherCampaign", EventCount: 1, ArchivedOn: now, RestoredOn: &now},
+	}, archives)
+}
+
+func TestVoidScoringEventBeginError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced begin error")
+	mock.ExpectBegin().WillReturnError(forcedError)
+
+	event, teamName, err := db.VoidScoringEvent(context.Background(), testScoreEventId, now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, event)
+	assert.Equal(t, "", teamName)
+}
+
+func TestVoidScoringEventNotFound(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlVoidScoringEvent)).
+		WithArgs(testScoreEventId, now).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	event, _, err := db.VoidScoringEvent(context.Background(), testScoreEventId, now)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, event)
+}
+
+func voidScoringEventRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "campaign", "scp", "repoOwner", "repoName", "pr", "username", "points", "breakdown", "voided_on"}).
+		AddRow(testScoreEventId, testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 5, loginName, 3,
+			[]byte(`{"classified":0,"basePoints":0,"unclassifiedBonus":3,"points":3}`), now)
+}
+
+func TestVoidScoringEventReverseError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlVoidScoringEvent)).
+		WithArgs(testScoreEventId, now).
+		WillReturnRows(voidScoringEventRows())
+	forcedError := fmt.Errorf("forced reverse error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlReverseScoringEvent)).
+		WithArgs(testScoreEventId).
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	event, _, err := db.VoidScoringEvent(context.Background(), testScoreEventId, now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, event)
+}
+
+func TestVoidScoringEventParticipantRemoved(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlVoidScoringEvent)).
+		WithArgs(testScoreEventId, now).
+		WillReturnRows(voidScoringEventRows())
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlReverseScoringEvent)).
+		WithArgs(testScoreEventId).
+		WillReturnRows(sqlmock.NewRows([]string{"team_name"}))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlReverseCoAuthorScores)).
+		WithArgs(testScoreEventId).
+		WillReturnRows(sqlmock.NewRows([]string{"login_name", "team_name", "points"}))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteCoAuthorScores)).
+		WithArgs(testScoreEventId).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	event, teamName, err := db.VoidScoringEvent(context.Background(), testScoreEventId, now)
+	assert.NoError(t, err)
+	assert.Equal(t, testScoreEventId, event.ID)
+	assert.Equal(t, "", teamName)
+}
+
+func TestVoidScoringEvent(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlVoidScoringEvent)).
+		WithArgs(testScoreEventId, now).
+		WillReturnRows(voidScoringEventRows())
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlReverseScoringEvent)).
+		WithArgs(testScoreEventId).
+		WillReturnRows(sqlmock.NewRows([]string{"team_name"}).AddRow("testTeamName"))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlReverseCoAuthorScores)).
+		WithArgs(testScoreEventId).
+		WillReturnRows(sqlmock.NewRows([]string{"login_name", "team_name", "points"}).AddRow("pairLoginName", "", 1.5))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteCoAuthorScores)).
+		WithArgs(testScoreEventId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	event, teamName, err := db.VoidScoringEvent(context.Background(), testScoreEventId, now)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.ScoringEventStruct{
+		ID:           testScoreEventId,
+		CampaignName: testCampaign.Name,
+		ScpName:      "scpName",
+		RepoOwner:    TestOrgValid,
+		RepoName:     "testRepoName",
+		PullRequest:  5,
+		UserName:     loginName,
+		Points:       3,
+		Breakdown:    &types.ScoreBreakdown{UnclassifiedBonus: 3, Points: 3},
+		VoidedOn:     &now,
+		CoAuthors:    []types.CoAuthorScore{{LoginName: "pairLoginName", Points: 1.5}},
+	}, event)
+	assert.Equal(t, "testTeamName", teamName)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVoidScoringEventReverseCoAuthorsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlVoidScoringEvent)).
+		WithArgs(testScoreEventId, now).
+		WillReturnRows(voidScoringEventRows())
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlReverseScoringEvent)).
+		WithArgs(testScoreEventId).
+		WillReturnRows(sqlmock.NewRows([]string{"team_name"}).AddRow("testTeamName"))
+	forcedError := fmt.Errorf("forced reverse co-authors error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlReverseCoAuthorScores)).
+		WithArgs(testScoreEventId).
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	event, _, err := db.VoidScoringEvent(context.Background(), testScoreEventId, now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, event)
+}
+
+func TestSelectScoredEvents(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoredEvents)).
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "campaign", "scp", "repoOwner", "repoName", "pr", "username", "points", "breakdown", "scored_on"}).
+			AddRow(testScoreEventId, testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 5, loginName, 3,
+				[]byte(`{"classified":0,"basePoints":0,"unclassifiedBonus":3,"points":3}`), now))
+
+	events, err := db.SelectScoredEvents(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, []ScoredEvent{{
+		ScoringEventStruct: types.ScoringEventStruct{
+			ID:           testScoreEventId,
+			CampaignName: testCampaign.Name,
+			ScpName:      "scpName",
+			RepoOwner:    TestOrgValid,
+			RepoName:     "testRepoName",
+			PullRequest:  5,
+			UserName:     loginName,
+			Points:       3,
+			Breakdown:    &types.ScoreBreakdown{UnclassifiedBonus: 3, Points: 3},
+		},
+		ScoredOn: now,
+	}}, events)
+}
+
+func TestInsertScoringFlagsNone(t *testing.T) {
+	_, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	flagged, err := db.InsertScoringFlags(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), flagged)
+}
+
+func TestInsertScoringFlagsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced insert flag error")
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringFlag)).
+		WithArgs(testScoreEventId, types.ScoringFlagBurst, now).
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	flagged, err := db.InsertScoringFlags(context.Background(), []types.ScoringFlag{
+		{Reason: types.ScoringFlagBurst, FlaggedOn: now, Event: types.ScoringEventStruct{ID: testScoreEventId}},
+	})
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, int64(0), flagged)
+}
+
+func TestInsertScoringFlags(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringFlag)).
+		WithArgs(testScoreEventId, types.ScoringFlagBurst, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// flagged before for the same reason
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertScoringFlag)).
+		WithArgs(testScoreEventId, types.ScoringFlagIdentical, now).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	flagged, err := db.InsertScoringFlags(context.Background(), []types.ScoringFlag{
+		{Reason: types.ScoringFlagBurst, FlaggedOn: now, Event: types.ScoringEventStruct{ID: testScoreEventId}},
+		{Reason: types.ScoringFlagIdentical, FlaggedOn: now, Event: types.ScoringEventStruct{ID: testScoreEventId}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), flagged)
+}
+
+func scoringFlagRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "reason", "flagged_on", "resolved_on", "resolution", "event_id", "campaign", "scp",
+		"repoOwner", "repoName", "pr", "username", "points", "breakdown", "voided_on"})
+}
+
+func TestSelectScoringFlags(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringFlags)).
+		WillReturnRows(scoringFlagRows().AddRow("testFlagId", types.ScoringFlagBurst, now, nil, "", testScoreEventId,
+			testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 5, loginName, 3, nil, nil))
+
+	flags, err := db.SelectScoringFlags(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ScoringFlag{{
+		ID:        "testFlagId",
+		Reason:    types.ScoringFlagBurst,
+		FlaggedOn: now,
+		Event: types.ScoringEventStruct{
+			ID:           testScoreEventId,
+			CampaignName: testCampaign.Name,
+			ScpName:      "scpName",
+			RepoOwner:    TestOrgValid,
+			RepoName:     "testRepoName",
+			PullRequest:  5,
+			UserName:     loginName,
+			Points:       3,
+		},
+	}}, flags)
+}
+
+func TestResolveScoringFlagNotFound(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlResolveScoringFlag)).
+		WithArgs("testFlagId", types.ScoringFlagApproved, now).
+		WillReturnRows(scoringFlagRows())
+
+	flag, err := db.ResolveScoringFlag(context.Background(), "testFlagId", types.ScoringFlagApproved, now)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, flag)
+}
+
+func TestResolveScoringFlag(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlResolveScoringFlag)).
+		WithArgs("testFlagId", types.ScoringFlagApproved, now).
+		WillReturnRows(scoringFlagRows().AddRow("testFlagId", types.ScoringFlagBurst, now, now, types.ScoringFlagApproved,
+			testScoreEventId, testCampaign.Name, "scpName", TestOrgValid, "testRepoName", 5, loginName, 3,
+			[]byte(`{"classified":0,"basePoints":0,"unclassifiedBonus":3,"points":3}`), nil))
+
+	flag, err := db.ResolveScoringFlag(context.Background(), "testFlagId", types.ScoringFlagApproved, now)
+	assert.NoError(t, err)
+	assert.Equal(t, types.ScoringFlagApproved, flag.Resolution)
+	assert.Equal(t, &now, flag.ResolvedOn)
+	assert.Equal(t, &types.ScoreBreakdown{UnclassifiedBonus: 3, Points: 3}, flag.Event.Breakdown)
+}
+
+func TestInsertParticipantError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	testParticipant := types.ParticipantStruct{
+		Score: -2,
+	}
+
+	forcedError := fmt.Errorf("forced insert participant error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertParticipant)).
+		WithArgs(testParticipant.ScpName, testParticipant.CampaignName,
+			testParticipant.LoginName, testParticipant.Email, testParticipant.DisplayName, 0).
+		WillReturnError(forcedError)
+
+	assert.EqualError(t, db.InsertParticipant(context.Background(), &testParticipant), forcedError.Error())
+	assert.Equal(t, "", testParticipant.ID)
+	assert.Equal(t, float64(-2), testParticipant.Score)
+	assert.Equal(t, time.Time{}, testParticipant.JoinedAt)
+}
+
+func TestInsertParticipantDuplicate(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertParticipant)).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "participant_fk_campaign_fk_scp_login_name_key"})
+
+	err := db.InsertParticipant(context.Background(), &types.ParticipantStruct{})
+	assert.Equal(t, &DuplicateError{Entity: "participant", Constraint: "participant_fk_campaign_fk_scp_login_name_key"}, err)
+}
+
+func TestInsertParticipantOtherPostgresError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	// a foreign key violation, like a missing campaign, is not a duplicate
+	forcedError := &pq.Error{Code: "23503", Message: "forced fk error"}
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertParticipant)).
+		WillReturnError(forcedError)
+
+	assert.Equal(t, forcedError, db.InsertParticipant(context.Background(), &types.ParticipantStruct{}))
+}
+
+func TestInsertParticipant(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	testParticipant := types.ParticipantStruct{
+		// ID will be empty when created from endpoint request
+		CampaignName: testCampaign.Name,
+		ScpName:      "scpName",
+		LoginName:    "loginName",
+		Email:        "email",
+		DisplayName:  "displayName",
+		Score:        -1, // this should be ignored during insert
+	}
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertParticipant)).
+		WithArgs(testParticipant.ScpName, testParticipant.CampaignName,
+			testParticipant.LoginName, testParticipant.Email, testParticipant.DisplayName, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "score", "joinedAt"}).
+			AddRow(testParticipantGuid, 0, now))
+
+	assert.NoError(t, db.InsertParticipant(context.Background(), &testParticipant))
+	assert.Equal(t, testParticipantGuid, testParticipant.ID)
+	assert.Equal(t, float64(0), testParticipant.Score)
+	assert.Equal(t, now, testParticipant.JoinedAt)
+}
+
+func TestUpsertParticipantError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced upsert participant error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertParticipant)).
+		WillReturnError(forcedError)
+
+	inserted, err := db.UpsertParticipant(context.Background(), &types.ParticipantStruct{})
+	assert.EqualError(t, err, forcedError.Error())
+	assert.False(t, inserted)
+}
+
+func TestUpsertParticipant(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	testParticipant := types.ParticipantStruct{
+		CampaignName: testCampaign.Name,
+		ScpName:      "scpName",
+		LoginName:    "loginName",
+		Email:        "email",
+		DisplayName:  "displayName",
+	}
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertParticipant)).
+		WithArgs(testParticipant.ScpName, testParticipant.CampaignName,
+			testParticipant.LoginName, testParticipant.Email, testParticipant.DisplayName).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "score", "teamName", "joinedAt", "inserted"}).
+			AddRow(testParticipantGuid, 5, "teamName", now, false))
+
+	inserted, err := db.UpsertParticipant(context.Background(), &testParticipant)
+	assert.NoError(t, err)
+	assert.False(t, inserted)
+	assert.Equal(t, testParticipantGuid, testParticipant.ID)
+	assert.Equal(t, float64(5), testParticipant.Score)
+	assert.Equal(t, "teamName", testParticipant.TeamName)
+	assert.Equal(t, now, testParticipant.JoinedAt)
+}
+
+func TestInsertTeamError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	testTeam := types.TeamStruct{
+		// ID will be empty when created from endpoint request
+		CampaignName: testCampaign.Name,
+		Name:         "teamName",
+	}
+
+	forcedError := fmt.Errorf("forced insert team error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertTeam)).
+		WithArgs(testTeam.CampaignName, testTeam.Name).
+		WillReturnError(forcedError)
+
+	assert.EqualError(t, db.InsertTeam(context.Background(), &testTeam), forcedError.Error())
+	assert.Equal(t, "", testTeam.Id)
+}
+
+func TestInsertTeamDuplicate(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertTeam)).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "team_fk_campaign_name_key"})
+
+	err := db.InsertTeam(context.Background(), &types.TeamStruct{})
+	assert.Equal(t, &DuplicateError{Entity: "team", Constraint: "team_fk_campaign_name_key"}, err)
+}
+
+const testTeamGuid = "testTeamGuid"
+
+func TestInsertTeam(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	testTeam := types.TeamStruct{
+		// ID will be empty when created from endpoint request
+		CampaignName: testCampaign.Name,
+		Name:         "teamName",
+	}
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertTeam)).
+		WithArgs(testTeam.CampaignName, testTeam.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"guid"}).
+			AddRow(testTeamGuid))
+
+	err := db.InsertTeam(context.Background(), &testTeam)
+	assert.NoError(t, err)
+	assert.Equal(t, testTeamGuid, testTeam.Id)
+}
+
+func TestSelectTeamsInCampaignError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced select teams error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamsByCampaign)).
+		WithArgs(testCampaign.Name).
+		WillReturnError(forcedError)
+
+	teams, err := db.SelectTeamsInCampaign(context.Background(), testCampaign.Name)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, ([]types.TeamStruct)(nil), teams)
+}
+
+func TestSelectTeamsInCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamsByCampaign)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "name"}).
+			AddRow(testTeamGuid, testCampaign.Name, "teamName"))
+
+	teams, err := db.SelectTeamsInCampaign(context.Background(), testCampaign.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.TeamStruct{{Id: testTeamGuid, CampaignName: testCampaign.Name, Name: "teamName"}}, teams)
+}
+
+func TestSelectTeamDetailNotFound(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeam)).
+		WithArgs("teamName", "").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := db.SelectTeamDetail(context.Background(), "", "teamName")
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestSelectTeamDetail(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeam)).
+		WithArgs("teamName", testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "name"}).
+			AddRow(testTeamGuid, testCampaign.Name, "teamName"))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamMembers)).
+		WithArgs(testTeamGuid).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "captain", "joinedAt"}).
+			AddRow(testParticipantGuid, testCampaign.Name, "scpName", loginName, "email", "display", 3, "teamName", true, now))
+
+	team, err := db.SelectTeamDetail(context.Background(), testCampaign.Name, "teamName")
+	assert.NoError(t, err)
+	assert.Equal(t, &types.TeamStruct{
+		Id:           testTeamGuid,
+		CampaignName: testCampaign.Name,
+		Name:         "teamName",
+		Members: []types.ParticipantStruct{
+			{
+				ID:           testParticipantGuid,
+				CampaignName: testCampaign.Name,
+				ScpName:      "scpName",
+				LoginName:    loginName,
+				Email:        "email",
+				DisplayName:  "display",
+				Score:        3,
+				TeamName:     "teamName",
+				Captain:      true,
+				JoinedAt:     now,
+			},
+		},
+	}, team)
+}
+
+func TestDeleteTeamUnassignError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced unassign team error")
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUnassignTeamMembers)).
+		WithArgs(testCampaign.Name, "teamName").
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	rowsAffected, err := db.DeleteTeam(context.Background(), testCampaign.Name, "teamName")
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, int64(0), rowsAffected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteTeam(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUnassignTeamMembers)).
+		WithArgs(testCampaign.Name, "teamName").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteTeam)).
+		WithArgs(testCampaign.Name, "teamName").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rowsAffected, err := db.DeleteTeam(context.Background(), testCampaign.Name, "teamName")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+const campaignName = "campaignName"
+const scpName = "scpName"
+
+func TestSelectParticipantDetailError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced insert team error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantDetail)).
+		WithArgs(campaignName, scpName, loginName).
+		WillReturnError(forcedError)
+
+	participant, err := db.SelectParticipantDetail(context.Background(), campaignName, scpName, loginName)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, &types.ParticipantStruct{}, participant)
+}
+
+func TestSelectParticipantDetailNoTeam(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantDetail)).
+		WithArgs(campaignName, scpName, loginName).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt", "avatar", "version"}).
+			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, sql.NullString{}, now, "", 1))
+
+	participant, err := db.SelectParticipantDetail(context.Background(), campaignName, scpName, loginName)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.ParticipantStruct{
+		ID:           testParticipantGuid,
+		CampaignName: campaignName,
+		ScpName:      scpName,
+		LoginName:    loginName,
+		Email:        "email",
+		DisplayName:  "display",
+		Score:        -1,
+		TeamName:     "",
+		JoinedAt:     now,
+		Version:      1,
+	}, participant)
 }
 
 func TestSelectParticipantDetail(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	const campaignName = "campaignName"
-	const scpName = "scpName"
-	const loginName = "loginName"
+	const campaignName = "campaignName"
+	const scpName = "scpName"
+	const loginName = "loginName"
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantDetail)).
+		WithArgs(campaignName, scpName, loginName).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt", "avatar", "version"}).
+			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, "teamName", now, "avatarUrl", 1))
+
+	participant, err := db.SelectParticipantDetail(context.Background(), campaignName, scpName, loginName)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.ParticipantStruct{
+		ID:           testParticipantGuid,
+		CampaignName: campaignName,
+		ScpName:      scpName,
+		LoginName:    loginName,
+		Email:        "email",
+		DisplayName:  "display",
+		Score:        -1,
+		TeamName:     "teamName",
+		JoinedAt:     now,
+		Version:      1,
+		AvatarUrl:    "avatarUrl",
+	}, participant)
+}
+
+func TestSelectParticipantsInCampaignError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced select campaign participants error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsByCampaign)).
+		WithArgs(campaignName).
+		WillReturnError(forcedError)
+
+	participants, err := db.SelectParticipantsInCampaign(context.Background(), campaignName)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, ([]types.ParticipantStruct)(nil), participants)
+}
+
+func TestSelectParticipantsInCampaignScanError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsByCampaign)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt", "avatar"}).
+			// force scan error with nil in JoinedAt Time field
+			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, "teamName", nil, ""))
+
+	participants, err := db.SelectParticipantsInCampaign(context.Background(), campaignName)
+	assert.EqualError(t, err, "sql: Scan error on column index 8, name \"joinedAt\": unsupported Scan, storing driver.Value type <nil> into type *time.Time")
+	assert.Equal(t, ([]types.ParticipantStruct)(nil), participants)
+}
+
+func TestSelectParticipantsInCampaignNoTeam(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsByCampaign)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt", "avatar"}).
+			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, sql.NullString{}, now, ""))
+
+	participants, err := db.SelectParticipantsInCampaign(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ParticipantStruct{
+		{
+			ID:           testParticipantGuid,
+			CampaignName: campaignName,
+			ScpName:      scpName,
+			LoginName:    loginName,
+			Email:        "email",
+			DisplayName:  "display",
+			Score:        -1,
+			TeamName:     "",
+			JoinedAt:     now,
+		},
+	}, participants)
+}
+
+func TestSelectParticipantsInCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsByCampaign)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt", "avatar"}).
+			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, "teamName", now, "avatarUrl"))
+
+	participants, err := db.SelectParticipantsInCampaign(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ParticipantStruct{
+		{
+			ID:           testParticipantGuid,
+			CampaignName: campaignName,
+			ScpName:      scpName,
+			LoginName:    loginName,
+			Email:        "email",
+			DisplayName:  "display",
+			Score:        -1,
+			TeamName:     "teamName",
+			JoinedAt:     now,
+			AvatarUrl:    "avatarUrl",
+		},
+	}, participants)
+}
+
+func TestUpdateParticipantError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	testParticipant := types.ParticipantStruct{}
+	forcedError := fmt.Errorf("forced update participant error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipant)).
+		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, testParticipant.LoginName, testParticipant.Email,
+			testParticipant.DisplayName, testParticipant.Score, testParticipant.TeamName,
+			testParticipant.ID, testParticipant.Version).
+		WillReturnError(forcedError)
+
+	rowsAffected, err := db.UpdateParticipant(context.Background(), &testParticipant)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, int64(0), rowsAffected)
+}
+
+func TestUpdateParticipantRowsAffectedError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	testParticipant := types.ParticipantStruct{
+		ID:           testParticipantGuid,
+		CampaignName: campaignName,
+		ScpName:      scpName,
+		LoginName:    loginName,
+		Email:        "email",
+		DisplayName:  "display",
+		Score:        -1,
+		TeamName:     "teamName",
+		JoinedAt:     now,
+	}
+	forcedError := fmt.Errorf("forced update participant rows affected error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipant)).
+		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, testParticipant.LoginName, testParticipant.Email,
+			testParticipant.DisplayName, testParticipant.Score, testParticipant.TeamName,
+			testParticipant.ID, testParticipant.Version).
+		WillReturnResult(sqlmock.NewErrorResult(forcedError))
+
+	rowsAffected, err := db.UpdateParticipant(context.Background(), &testParticipant)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, int64(0), rowsAffected)
+}
+
+func TestUpdateParticipant(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	testParticipant := types.ParticipantStruct{
+		ID:           testParticipantGuid,
+		CampaignName: campaignName,
+		ScpName:      scpName,
+		LoginName:    loginName,
+		Email:        "email",
+		DisplayName:  "display",
+		Score:        -1,
+		TeamName:     "teamName",
+		JoinedAt:     now,
+		Version:      1,
+	}
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipant)).
+		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, testParticipant.LoginName, testParticipant.Email,
+			testParticipant.DisplayName, testParticipant.Score, testParticipant.TeamName,
+			testParticipant.ID, testParticipant.Version).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.UpdateParticipant(context.Background(), &testParticipant)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+	assert.Equal(t, 2, testParticipant.Version)
+}
+
+func TestUpdateParticipantStale(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	testParticipant := types.ParticipantStruct{ID: testParticipantGuid, Version: 1}
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipant)).
+		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, testParticipant.LoginName, testParticipant.Email,
+			testParticipant.DisplayName, testParticipant.Score, testParticipant.TeamName,
+			testParticipant.ID, testParticipant.Version).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantVersion)).
+		WithArgs(testParticipantGuid).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+
+	rowsAffected, err := db.UpdateParticipant(context.Background(), &testParticipant)
+	assert.Equal(t, &StaleError{Entity: "participant", Version: 2}, err)
+	assert.Equal(t, int64(0), rowsAffected)
+}
+
+func TestUpdateParticipantMissing(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	testParticipant := types.ParticipantStruct{ID: testParticipantGuid, Version: 1}
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipant)).
+		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, testParticipant.LoginName, testParticipant.Email,
+			testParticipant.DisplayName, testParticipant.Score, testParticipant.TeamName,
+			testParticipant.ID, testParticipant.Version).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantVersion)).
+		WithArgs(testParticipantGuid).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+
+	rowsAffected, err := db.UpdateParticipant(context.Background(), &testParticipant)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), rowsAffected)
+}
+
+func TestPatchParticipantError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced patch participant error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlPatchParticipant)).
+		WithArgs(nil, nil, nil, nil, nil, nil, nil, false, testParticipantGuid, 0).
+		WillReturnError(forcedError)
+
+	rowsAffected, err := db.PatchParticipant(context.Background(), &types.ParticipantPatch{ID: testParticipantGuid})
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, int64(0), rowsAffected)
+}
+
+func TestPatchParticipant(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	displayName, score := "display", 3.0
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlPatchParticipant)).
+		WithArgs(nil, nil, nil, "", &displayName, &score, nil, true, testParticipantGuid, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	patch := &types.ParticipantPatch{
+		ID:          testParticipantGuid,
+		Email:       types.OptionalString{Set: true, Null: true},
+		DisplayName: types.OptionalString{Set: true, Value: displayName},
+		Score:       types.OptionalFloat{Set: true, Value: score},
+		TeamName:    types.OptionalString{Set: true, Null: true},
+		Version:     1,
+	}
+	rowsAffected, err := db.PatchParticipant(context.Background(), patch)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+	assert.Equal(t, 2, patch.Version)
+}
+
+func TestPatchParticipantStale(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlPatchParticipant)).
+		WithArgs(nil, nil, nil, nil, nil, nil, nil, false, testParticipantGuid, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantVersion)).
+		WithArgs(testParticipantGuid).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+
+	rowsAffected, err := db.PatchParticipant(context.Background(), &types.ParticipantPatch{ID: testParticipantGuid, Version: 1})
+	assert.Equal(t, &StaleError{Entity: "participant", Version: 2}, err)
+	assert.Equal(t, int64(0), rowsAffected)
+}
+
+func TestDeleteParticipantError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced delete participant error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlDeleteParticipant)).
+		WithArgs(campaignName, scpName, loginName).
+		WillReturnError(forcedError)
+
+	deletedParticipantId, err := db.DeleteParticipant(context.Background(), campaignName, scpName, loginName)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, "", deletedParticipantId)
+}
+
+func TestDeleteParticipant(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlDeleteParticipant)).
+		WithArgs(campaignName, scpName, loginName).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testParticipantGuid))
+
+	deletedParticipantId, err := db.DeleteParticipant(context.Background(), campaignName, scpName, loginName)
+	assert.NoError(t, err)
+	assert.Equal(t, testParticipantGuid, deletedParticipantId)
+}
+
+const teamName = "teamName"
+
+func TestUpdateParticipantTeamNoTeam(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamSizeForUpdate)).
+		WithArgs(teamName, campaignName, scpName, loginName).
+		WillReturnRows(sqlmock.NewRows([]string{"maxTeamSize", "teamSize", "captainElsewhere"}))
+	mock.ExpectRollback()
+
+	rowsAffected, err := db.UpdateParticipantTeam(context.Background(), teamName, campaignName, scpName, loginName)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, int64(0), rowsAffected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateParticipantTeamFull(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamSizeForUpdate)).
+		WithArgs(teamName, campaignName, scpName, loginName).
+		WillReturnRows(sqlmock.NewRows([]string{"maxTeamSize", "teamSize", "captainElsewhere"}).AddRow(3, 3, false))
+	mock.ExpectRollback()
+
+	rowsAffected, err := db.UpdateParticipantTeam(context.Background(), teamName, campaignName, scpName, loginName)
+	assert.Equal(t, &TeamFullError{CampaignName: campaignName, TeamName: teamName, MaxTeamSize: 3}, err)
+	assert.EqualError(t, err, "team is full: campaignName: campaignName, teamName: teamName, maxTeamSize: 3")
+	assert.Equal(t, int64(0), rowsAffected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateParticipantTeamCaptainElsewhere(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamSizeForUpdate)).
+		WithArgs(teamName, campaignName, scpName, loginName).
+		WillReturnRows(sqlmock.NewRows([]string{"maxTeamSize", "teamSize", "captainElsewhere"}).AddRow(0, 1, true))
+	mock.ExpectRollback()
+
+	rowsAffected, err := db.UpdateParticipantTeam(context.Background(), teamName, campaignName, scpName, loginName)
+	assert.Equal(t, &CaptainMoveError{CampaignName: campaignName, ScpName: scpName, LoginName: loginName}, err)
+	assert.EqualError(t, err, "the captain can not leave their team: campaignName: campaignName, scpName: scpName, loginName: loginName")
+	assert.Equal(t, int64(0), rowsAffected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateParticipantTeamError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamSizeForUpdate)).
+		WithArgs(teamName, campaignName, scpName, loginName).
+		WillReturnRows(sqlmock.NewRows([]string{"maxTeamSize", "teamSize", "captainElsewhere"}).AddRow(0, 10, false))
+	forcedError := fmt.Errorf("forced update participant team error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipantTeam)).
+		WithArgs(teamName, campaignName, scpName, loginName).
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	rowsAffected, err := db.UpdateParticipantTeam(context.Background(), teamName, campaignName, scpName, loginName)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, int64(0), rowsAffected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateParticipantTeamRowsAffectedError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamSizeForUpdate)).
+		WithArgs(teamName, campaignName, scpName, loginName).
+		WillReturnRows(sqlmock.NewRows([]string{"maxTeamSize", "teamSize", "captainElsewhere"}).AddRow(0, 10, false))
+	forcedError := fmt.Errorf("forced update participant team rows affected error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipantTeam)).
+		WithArgs(teamName, campaignName, scpName, loginName).
+		WillReturnResult(sqlmock.NewErrorResult(forcedError))
+	mock.ExpectRollback()
+
+	rowsAffected, err := db.UpdateParticipantTeam(context.Background(), teamName, campaignName, scpName, loginName)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, int64(0), rowsAffected)
+}
+
+func TestUpdateParticipantTeam(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamSizeForUpdate)).
+		WithArgs(teamName, campaignName, scpName, loginName).
+		WillReturnRows(sqlmock.NewRows([]string{"maxTeamSize", "teamSize", "captainElsewhere"}).AddRow(3, 2, false))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipantTeam)).
+		WithArgs(teamName, campaignName, scpName, loginName).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rowsAffected, err := db.UpdateParticipantTeam(context.Background(), teamName, campaignName, scpName, loginName)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+const bugCategory = "bugCategory"
+const bugGuid = "bugGuid"
+
+func TestUpdateParticipantTeams(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	assignments := []types.TeamAssignment{
+		{CampaignName: campaignName, ScpName: "scpName", LoginName: "one", TeamName: "teamName"},
+		{CampaignName: campaignName, ScpName: "scpName", LoginName: "two", TeamName: "teamName"},
+	}
+	mock.ExpectBegin()
+	for _, assignment := range assignments {
+		mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamSizeForUpdate)).
+			WithArgs(assignment.TeamName, assignment.CampaignName, assignment.ScpName, assignment.LoginName).
+			WillReturnRows(sqlmock.NewRows([]string{"maxTeamSize", "teamSize", "captainElsewhere"}).AddRow(2, 0, false))
+		mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipantTeam)).
+			WithArgs(assignment.TeamName, assignment.CampaignName, assignment.ScpName, assignment.LoginName).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	results, err := db.UpdateParticipantTeams(context.Background(), assignments)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.TeamAssignmentResult{
+		{TeamAssignment: assignments[0], Assigned: true},
+		{TeamAssignment: assignments[1], Assigned: true},
+	}, results)
+}
+
+func TestUpdateParticipantTeamsRollsBackFailedEntries(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	assignments := []types.TeamAssignment{
+		{CampaignName: campaignName, ScpName: "scpName", LoginName: "one", TeamName: "teamName"},
+		{CampaignName: campaignName, ScpName: "scpName", LoginName: "two", TeamName: "fullTeam"},
+		{CampaignName: campaignName, ScpName: "scpName", LoginName: "three", TeamName: "noTeam"},
+		{CampaignName: campaignName, ScpName: "scpName", LoginName: "noLogin", TeamName: "teamName"},
+	}
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamSizeForUpdate)).
+		WillReturnRows(sqlmock.NewRows([]string{"maxTeamSize", "teamSize", "captainElsewhere"}).AddRow(0, 4, false))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipantTeam)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamSizeForUpdate)).
+		WillReturnRows(sqlmock.NewRows([]string{"maxTeamSize", "teamSize", "captainElsewhere"}).AddRow(2, 2, false))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamSizeForUpdate)).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamSizeForUpdate)).
+		WillReturnRows(sqlmock.NewRows([]string{"maxTeamSize", "teamSize", "captainElsewhere"}).AddRow(0, 0, false))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipantTeam)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	results, err := db.UpdateParticipantTeams(context.Background(), assignments)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.TeamAssignmentResult{
+		{TeamAssignment: assignments[0]},
+		{TeamAssignment: assignments[1], Error: "team is full: campaignName: " + campaignName + ", teamName: fullTeam, maxTeamSize: 2"},
+		{TeamAssignment: assignments[2], Error: "no team: campaignName: " + campaignName + ", name: noTeam"},
+		{TeamAssignment: assignments[3], Error: "no participant: campaignName: " + campaignName + ", scpName: scpName, loginName: noLogin"},
+	}, results)
+}
+
+func TestUpdateParticipantTeamsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced team size error")
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamSizeForUpdate)).
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	_, err := db.UpdateParticipantTeams(context.Background(), []types.TeamAssignment{{CampaignName: campaignName}})
+	assert.EqualError(t, err, forcedError.Error())
+}
+
+func TestInsertSeedFixture(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	now := time.Now()
+	fixture := &types.SeedFixture{
+		Campaigns:     []types.CampaignStruct{{Name: campaignName, StartOn: now, EndOn: now}},
+		Bugs:          []types.BugStruct{{Campaign: campaignName, Category: "category", PointValue: 5}},
+		Organizations: []types.OrganizationStruct{{SCPName: "scpName", Organization: "org", CampaignName: campaignName}},
+		Teams:         []types.TeamStruct{{CampaignName: campaignName, Name: teamName}},
+		Participants:  []types.ParticipantStruct{{CampaignName: campaignName, ScpName: "scpName", LoginName: loginName, TeamName: teamName}},
+	}
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertCampaign)).
+		WillReturnRows(sqlmock.NewRows([]string{"Id"}).AddRow("campaignId"))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertBug)).
+		WithArgs(campaignName, "category", float64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"Id"}).AddRow("bugId"))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertOrganization)).
+		WithArgs("scpName", "org", campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"Id"}).AddRow("organizationId"))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertTeam)).
+		WithArgs(campaignName, teamName).
+		WillReturnRows(sqlmock.NewRows([]string{"Id"}).AddRow("teamId"))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertParticipant)).
+		WithArgs("scpName", campaignName, loginName, "", "", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "Score", "JoinedAt"}).AddRow("participantId", 0, now))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamSizeForUpdate)).
+		WithArgs(teamName, campaignName, "scpName", loginName).
+		WillReturnRows(sqlmock.NewRows([]string{"maxTeamSize", "teamSize", "captainElsewhere"}).AddRow(0, 0, false))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipantTeam)).
+		WithArgs(teamName, campaignName, "scpName", loginName).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, db.InsertSeedFixture(context.Background(), fixture))
+	assert.Equal(t, "campaignId", fixture.Campaigns[0].ID)
+	assert.Equal(t, "bugId", fixture.Bugs[0].Id)
+	assert.Equal(t, "organizationId", fixture.Organizations[0].ID)
+	assert.Equal(t, "teamId", fixture.Teams[0].Id)
+	assert.Equal(t, "participantId", fixture.Participants[0].ID)
+}
+
+func TestInsertSeedFixtureDuplicate(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertTeam)).
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "team_name_key"})
+	mock.ExpectRollback()
+
+	err := db.InsertSeedFixture(context.Background(), &types.SeedFixture{Teams: []types.TeamStruct{{CampaignName: campaignName, Name: teamName}}})
+	assert.Equal(t, &DuplicateError{Entity: "team", Constraint: "team_name_key"}, err)
+}
+
+func TestInsertSeedFixtureNoTeam(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertParticipant)).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "Score", "JoinedAt"}).AddRow("participantId", 0, time.Now()))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamSizeForUpdate)).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	err := db.InsertSeedFixture(context.Background(), &types.SeedFixture{
+		Participants: []types.ParticipantStruct{{CampaignName: campaignName, ScpName: "scpName", LoginName: loginName, TeamName: "noTeam"}},
+	})
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+	assert.EqualError(t, err, "no team: campaignName: "+campaignName+", name: noTeam: sql: no rows in result set")
+}
+
+func TestInsertBugError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	bug := types.BugStruct{
+		// empty Id before insert
+		Campaign:   campaignName,
+		Category:   bugCategory,
+		PointValue: 2,
+	}
+	forcedError := fmt.Errorf("forced insert bug error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertBug)).
+		WithArgs(bug.Campaign, bug.Category, bug.PointValue).
+		WillReturnError(forcedError)
+
+	assert.EqualError(t, db.InsertBug(context.Background(), &bug), forcedError.Error())
+	assert.Equal(t, "", bug.Id)
+}
+
+func TestInsertBug(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	bug := types.BugStruct{
+		// empty Id before insert
+		Campaign:   campaignName,
+		Category:   bugCategory,
+		PointValue: 2,
+	}
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertBug)).
+		WithArgs(bug.Campaign, bug.Category, bug.PointValue).
+		WillReturnRows(sqlmock.NewRows([]string{"guid"}).AddRow(bugGuid))
+
+	assert.NoError(t, db.InsertBug(context.Background(), &bug))
+	assert.Equal(t, bugGuid, bug.Id)
+}
+
+func TestUpdateBugError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	bug := types.BugStruct{}
+	forcedError := fmt.Errorf("forced update bug error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateBug)).
+		WithArgs(bug.PointValue, bug.Campaign, bug.Category, bug.Version).
+		WillReturnError(forcedError)
+
+	rowsAffected, err := db.UpdateBug(context.Background(), &bug)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, int64(0), rowsAffected)
+}
+
+func TestUpdateBug(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	bug := types.BugStruct{
+		// empty Id before insert
+		Campaign:   campaignName,
+		Category:   bugCategory,
+		PointValue: 5,
+		Version:    1,
+	}
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateBug)).
+		WithArgs(bug.PointValue, bug.Campaign, bug.Category, bug.Version).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.UpdateBug(context.Background(), &bug)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+	assert.Equal(t, 2, bug.Version)
+}
+
+func TestUpdateBugStale(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	bug := types.BugStruct{Campaign: campaignName, Category: bugCategory, PointValue: 5, Version: 1}
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateBug)).
+		WithArgs(bug.PointValue, bug.Campaign, bug.Category, bug.Version).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectBugVersion)).
+		WithArgs(bug.Campaign, bug.Category).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+
+	rowsAffected, err := db.UpdateBug(context.Background(), &bug)
+	assert.Equal(t, &StaleError{Entity: "bug", Version: 2}, err)
+	assert.Equal(t, int64(0), rowsAffected)
+}
+
+func TestSelectBugsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced select bugs error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectBugs)).
+		WillReturnError(forcedError)
+
+	bugs, err := db.SelectBugs(context.Background())
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, ([]types.BugStruct)(nil), bugs)
+}
+
+func TestSelectBugsScanError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectBugs)).
+		// force scan error with invalid column count
+		WillReturnRows(sqlmock.NewRows([]string{"badColumn"}).AddRow(-1))
+
+	bugs, err := db.SelectBugs(context.Background())
+	assert.EqualError(t, err, "sql: expected 1 destination arguments in Scan, not 5")
+	assert.Equal(t, ([]types.BugStruct)(nil), bugs)
+}
+
+func TestSelectBugs(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	bug := types.BugStruct{
+		// empty Id before insert
+		Campaign:   campaignName,
+		Category:   bugCategory,
+		PointValue: 5,
+		Version:    1,
+	}
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectBugs)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "campiagn", "category", "pointValue", "version"}).
+			AddRow(bug.Id, bug.Campaign, bug.Category, bug.PointValue, bug.Version))
+
+	bugs, err := db.SelectBugs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []types.BugStruct{bug}, bugs)
+}
+
+func TestSelectBugsInCampaignError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced select bugs error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectBugsInCampaign)).
+		WithArgs(campaignName).
+		WillReturnError(forcedError)
+
+	bugs, err := db.SelectBugsInCampaign(context.Background(), campaignName)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, ([]types.BugStruct)(nil), bugs)
+}
+
+func TestSelectBugsInCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	bug := types.BugStruct{Id: "bugId", Campaign: campaignName, Category: bugCategory, PointValue: 5, Version: 1}
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectBugsInCampaign)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "campaign", "category", "pointValue", "version"}).
+			AddRow(bug.Id, bug.Campaign, bug.Category, bug.PointValue, bug.Version))
+
+	bugs, err := db.SelectBugsInCampaign(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.BugStruct{bug}, bugs)
+}
+
+func TestGetDb(t *testing.T) {
+	_, dbFake, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	assert.NotNil(t, dbFake.GetDb())
+	assert.NotNil(t, dbFake.logger)
+}
+
+func TestStatementTimeout(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+	assert.Equal(t, DefaultStatementTimeout, db.statementTimeout)
+
+	db.SetStatementTimeout(time.Millisecond)
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaigns)).
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note", "maxTeamSize", "tieBreaker", "unclassifiedBonus", "timeZone", "status", "freezeScoring", "registrationOpensOn", "registrationClosesOn", "version"}))
+
+	campaigns, err := db.GetCampaigns(context.Background())
+	assert.EqualError(t, err, "canceling query due to user request")
+	assert.Nil(t, campaigns)
+}
+
+func TestStatementTimeoutCallerContext(t *testing.T) {
+	_, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+	db.SetStatementTimeout(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := db.GetCampaigns(ctx)
+	assert.EqualError(t, err, context.Canceled.Error())
+}
+
+func TestSelectMigrationStatusError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced migration status error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectMigrationStatus)).
+		WillReturnError(forcedError)
+
+	status, err := db.SelectMigrationStatus(context.Background())
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, types.MigrationStatus{}, status)
+}
+
+func TestSelectMigrationStatusNoMigrations(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectMigrationStatus)).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}))
+
+	status, err := db.SelectMigrationStatus(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, types.MigrationStatus{}, status)
+}
+
+func TestSelectMigrationStatus(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectMigrationStatus)).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(12, true))
+
+	status, err := db.SelectMigrationStatus(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, types.MigrationStatus{Version: 12, Dirty: true}, status)
+}
+
+func TestRollbackMigrationsErrorPostgresWithInstance(t *testing.T) {
+	_, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	assert.EqualError(t, db.RollbackMigrations(1), "all expectations were already fulfilled, call to Query 'SELECT CURRENT_DATABASE()' with args [] was not expected in line 0: SELECT CURRENT_DATABASE()")
+}
+
+func TestNewWithReadReplica(t *testing.T) {
+	primary, primaryMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		_ = primary.Close()
+	}()
+	replica, replicaMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		_ = replica.Close()
+	}()
+	db := NewWithReadReplica(primary, replica, zaptest.NewLogger(t))
+	assert.Equal(t, primary, db.GetDb())
+
+	replicaMock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaigns)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note", "maxTeamSize", "tieBreaker", "unclassifiedBonus", "timeZone", "status", "freezeScoring", "registrationOpensOn", "registrationClosesOn", "version"}).
+			AddRow(testCampaign.ID, testCampaign.Name, testCampaign.CreatedOn, testCampaign.CreatedOrder, testCampaign.StartOn, testCampaign.EndOn, testCampaign.Note, *testCampaign.MaxTeamSize, testCampaign.TieBreaker, *testCampaign.UnclassifiedBonus, testCampaign.TimeZone, testCampaign.Status, testCampaign.FreezeScoring, *testCampaign.RegistrationOpensOn, *testCampaign.RegistrationClosesOn, testCampaign.Version))
+	primaryMock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertCampaign)).
+		WithArgs(testCampaign.Name, testCampaign.StartOn, testCampaign.EndOn, *testCampaign.MaxTeamSize, testCampaign.TieBreaker, *testCampaign.UnclassifiedBonus, testCampaign.TenantName, testCampaign.TimeZone, testCampaign.FreezeScoring, testCampaign.RegistrationOpensOn, testCampaign.RegistrationClosesOn).
+		WillReturnRows(sqlmock.NewRows([]string{"guid"}).AddRow(testCampaignGuid))
+
+	campaigns, err := db.GetCampaigns(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []types.CampaignStruct{testCampaign}, campaigns)
+
+	guid, err := db.InsertCampaign(context.Background(), &testCampaign)
+	assert.NoError(t, err)
+	assert.Equal(t, testCampaignGuid, guid)
+
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestUpsertClusterInstance(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	instance := types.ClusterInstance{InstanceId: "myInstance", Version: "1.0", Role: "web", PollLeader: true, StartedOn: now, LastHeartbeat: now}
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpsertClusterInstance)).
+		WithArgs(instance.InstanceId, instance.Version, instance.Role, instance.PollLeader, instance.StartedOn, instance.LastHeartbeat).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, db.UpsertClusterInstance(context.Background(), &instance))
+}
+
+func TestSelectClusterInstancesError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced cluster select error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectClusterInstances)).
+		WillReturnError(forcedError)
+
+	instances, err := db.SelectClusterInstances(context.Background())
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, ([]types.ClusterInstance)(nil), instances)
+}
+
+func TestSelectClusterInstances(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectClusterInstances)).
+		WillReturnRows(sqlmock.NewRows([]string{"instance_id", "version", "role", "poll_leader", "started_on", "last_heartbeat"}).
+			AddRow("myInstance", "1.0", "web", true, now, now))
+
+	instances, err := db.SelectClusterInstances(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ClusterInstance{
+		{InstanceId: "myInstance", Version: "1.0", Role: "web", PollLeader: true, StartedOn: now, LastHeartbeat: now},
+	}, instances)
+}
+
+func TestAcquirePollLeaseError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced lease error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlAcquirePollLease)).
+		WithArgs("myInstance", now, now.Add(time.Minute)).
+		WillReturnError(forcedError)
+
+	held, err := db.AcquirePollLease(context.Background(), "myInstance", now, now.Add(time.Minute))
+	assert.EqualError(t, err, forcedError.Error())
+	assert.False(t, held)
+}
+
+func TestAcquirePollLease(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlAcquirePollLease)).
+		WithArgs("myInstance", now, now.Add(time.Minute)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// held by another instance
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlAcquirePollLease)).
+		WithArgs("otherInstance", now, now.Add(time.Minute)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	held, err := db.AcquirePollLease(context.Background(), "myInstance", now, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, held)
+	held, err = db.AcquirePollLease(context.Background(), "otherInstance", now, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, held)
+}
+
+func TestReleasePollLease(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlReleasePollLease)).
+		WithArgs("myInstance").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, db.ReleasePollLease(context.Background(), "myInstance"))
+}
+
+func TestUpsertScheduledJobRun(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpsertScheduledJobRun)).
+		WithArgs("heartbeat", "myInstance", now, now, "", 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpsertScheduledJobRun)).
+		WithArgs("heartbeat", "myInstance", now, now, "forced error", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	run := types.ScheduledJobRun{Name: "heartbeat", InstanceId: "myInstance", StartedOn: now, FinishedOn: now}
+	assert.NoError(t, db.UpsertScheduledJobRun(context.Background(), &run))
+	run.Error = "forced error"
+	assert.NoError(t, db.UpsertScheduledJobRun(context.Background(), &run))
+}
+
+func TestSelectScheduledJobRuns(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScheduledJobRuns)).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "instance_id", "started_on", "finished_on", "error", "runs", "failures"}).
+			AddRow("heartbeat", "myInstance", now, now, "", 3, 0).
+			AddRow("archival", "otherInstance", now, now, "forced error", 2, 1))
+
+	runs, err := db.SelectScheduledJobRuns(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ScheduledJobRun{
+		{Name: "heartbeat", InstanceId: "myInstance", StartedOn: now, FinishedOn: now, Runs: 3},
+		{Name: "archival", InstanceId: "otherInstance", StartedOn: now, FinishedOn: now, Error: "forced error", Runs: 2, Failures: 1},
+	}, runs)
+}
+
+func TestSelectMaintenanceOff(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectMaintenance)).
+		WillReturnRows(sqlmock.NewRows([]string{"message", "since"}))
+
+	state, err := db.SelectMaintenance(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, types.MaintenanceState{}, state)
+}
+
+func TestSelectMaintenanceError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced maintenance error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectMaintenance)).
+		WillReturnError(forcedError)
+
+	_, err := db.SelectMaintenance(context.Background())
+	assert.EqualError(t, err, forcedError.Error())
+}
+
+func TestSelectMaintenance(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectMaintenance)).
+		WillReturnRows(sqlmock.NewRows([]string{"message", "since"}).AddRow("migrating", now))
+
+	state, err := db.SelectMaintenance(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, types.MaintenanceState{Enabled: true, Message: "migrating", Since: &now}, state)
+}
+
+func TestUpdateMaintenance(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	since := now.Add(-time.Hour)
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertMaintenance)).
+		WithArgs("migrating", now).
+		WillReturnRows(sqlmock.NewRows([]string{"since"}).AddRow(since))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteMaintenance)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	state, err := db.UpdateMaintenance(context.Background(), true, "migrating", now)
+	assert.NoError(t, err)
+	assert.Equal(t, types.MaintenanceState{Enabled: true, Message: "migrating", Since: &since}, state)
+
+	state, err = db.UpdateMaintenance(context.Background(), false, "", now)
+	assert.NoError(t, err)
+	assert.Equal(t, types.MaintenanceState{}, state)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateMaintenanceError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced maintenance error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertMaintenance)).
+		WithArgs("migrating", now).
+		WillReturnError(forcedError)
+
+	_, err := db.UpdateMaintenance(context.Background(), true, "migrating", now)
+	assert.EqualError(t, err, forcedError.Error())
+}
+
+func TestInsertJob(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertJob)).
+		WithArgs("score-event-import", types.JobStatusRunning, "myInstance", 0, 2, now).
+		WillReturnRows(sqlmock.NewRows([]string{"Id"}).AddRow("myJobId"))
+
+	job := types.Job{Kind: "score-event-import", Status: types.JobStatusRunning, InstanceId: "myInstance", Total: 2, CreatedOn: now}
+	assert.NoError(t, db.InsertJob(context.Background(), &job))
+	assert.Equal(t, "myJobId", job.ID)
+}
+
+func TestUpdateJobProgress(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateJobProgress)).
+		WithArgs("myJobId", 1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, db.UpdateJobProgress(context.Background(), "myJobId", 1, 2))
+}
+
+func TestFinishJob(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlFinishJob)).
+		WithArgs("myJobId", types.JobStatusSucceeded, 2, 2, []byte(`{"read":2}`), "", &now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlFinishJob)).
+		WithArgs("myJobId", types.JobStatusFailed, 0, 2, []byte(nil), "internal server error", &now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	job := types.Job{ID: "myJobId", Status: types.JobStatusSucceeded, Done: 2, Total: 2, Result: json.RawMessage(`{"read":2}`), FinishedOn: &now}
+	assert.NoError(t, db.FinishJob(context.Background(), &job))
+	job = types.Job{ID: "myJobId", Status: types.JobStatusFailed, Total: 2, Error: "internal server error", FinishedOn: &now}
+	assert.NoError(t, db.FinishJob(context.Background(), &job))
+}
+
+func TestFailInstanceJobs(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlFailInstanceJobs)).
+		WithArgs("myInstance", "stopped", now).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	failed, err := db.FailInstanceJobs(context.Background(), "myInstance", "stopped", now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), failed)
+}
+
+func TestFailOrphanedJobsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced fail jobs error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlFailOrphanedJobs)).
+		WithArgs(now.Add(-time.Minute), "stopped", now).
+		WillReturnError(forcedError)
+
+	failed, err := db.FailOrphanedJobs(context.Background(), now.Add(-time.Minute), "stopped", now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, int64(0), failed)
+}
+
+func TestFailOrphanedJobs(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlFailOrphanedJobs)).
+		WithArgs(now.Add(-time.Minute), "stopped", now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	failed, err := db.FailOrphanedJobs(context.Background(), now.Add(-time.Minute), "stopped", now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), failed)
+}
+
+func TestSelectJobNotFound(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectJob)).
+		WithArgs("myJobId").
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "kind", "status", "instance_id", "done", "total", "result", "error", "created_on", "finished_on"}))
+
+	job, err := db.SelectJob(context.Background(), "myJobId")
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, job)
+}
+
+func TestSelectJob(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectJob)).
+		WithArgs("myJobId").
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "kind", "status", "instance_id", "done", "total", "result", "error", "created_on", "finished_on"}).
+			AddRow("myJobId", "score-event-import", types.JobStatusSucceeded, "myInstance", 2, 2, []byte(`{"read":2}`), "", now, now))
+
+	job, err := db.SelectJob(context.Background(), "myJobId")
+	assert.NoError(t, err)
+	assert.Equal(t, &types.Job{ID: "myJobId", Kind: "score-event-import", Status: types.JobStatusSucceeded, InstanceId: "myInstance",
+		Done: 2, Total: 2, Result: json.RawMessage(`{"read":2}`), CreatedOn: now, FinishedOn: &now}, job)
+}
+
+func TestUpsertCampaignConfigStage(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	config := types.CampaignConfigStruct{
+		Campaign: campaignName,
+		Bugs:     []types.BugStruct{{Campaign: campaignName, Category: bugCategory, PointValue: 2}},
+	}
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertCampaignConfigStage)).
+		WithArgs(campaignName, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_on"}).AddRow(now))
+
+	assert.NoError(t, db.UpsertCampaignConfigStage(context.Background(), &config))
+	assert.Equal(t, now, config.UpdatedOn)
+}
+
+func TestSelectCampaignConfigStageNotFound(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignConfigStage)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"config", "updated_on"}))
+
+	config, err := db.SelectCampaignConfigStage(context.Background(), campaignName)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, config)
+}
+
+func TestSelectCampaignConfigStage(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignConfigStage)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"config", "updated_on"}).
+			AddRow(`{"campaign":"campaignName","bugs":[{"category":"bugCategory","pointValue":2}]}`, now))
+
+	config, err := db.SelectCampaignConfigStage(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.CampaignConfigStruct{
+		Campaign:  campaignName,
+		Bugs:      []types.BugStruct{{Category: bugCategory, PointValue: 2}},
+		UpdatedOn: now,
+	}, config)
+}
+
+func TestDeleteCampaignConfigStage(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteCampaignConfigStage)).
+		WithArgs(campaignName).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.DeleteCampaignConfigStage(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+}
+
+const slackWebhookUrl = "https://hooks.slack.com/services/T0/B0/secret"
+
+func TestUpsertSlackNotificationNoCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	config := types.SlackNotificationStruct{CampaignName: campaignName, WebhookUrl: slackWebhookUrl, PointThreshold: 10, NotifyLead: true}
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertSlackNotification)).
+		WithArgs(campaignName, slackWebhookUrl, 10, true).
+		WillReturnRows(sqlmock.NewRows([]string{"fk_campaign"}))
+
+	assert.EqualError(t, db.UpsertSlackNotification(context.Background(), &config), sql.ErrNoRows.Error())
+}
+
+func TestUpsertSlackNotification(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	config := types.SlackNotificationStruct{CampaignName: campaignName, WebhookUrl: slackWebhookUrl, PointThreshold: 10, NotifyLead: true}
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertSlackNotification)).
+		WithArgs(campaignName, slackWebhookUrl, 10, true).
+		WillReturnRows(sqlmock.NewRows([]string{"fk_campaign"}).AddRow(testCampaign.ID))
+
+	assert.NoError(t, db.UpsertSlackNotification(context.Background(), &config))
+}
+
+func TestSelectSlackNotificationNotFound(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectSlackNotification)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"webhook_url", "point_threshold", "notify_lead"}))
+
+	config, err := db.SelectSlackNotification(context.Background(), campaignName)
+	assert.EqualError(t, err, sql.ErrNoRows.Error())
+	assert.Nil(t, config)
+}
+
+func TestSelectSlackNotification(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectSlackNotification)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"webhook_url", "point_threshold", "notify_lead"}).AddRow(slackWebhookUrl, 10, true))
+
+	config, err := db.SelectSlackNotification(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.SlackNotificationStruct{CampaignName: campaignName, WebhookUrl: slackWebhookUrl, PointThreshold: 10, NotifyLead: true}, config)
+}
+
+func TestUpsertCampaignPenaltyNoCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertCampaignPenalty)).
+		WithArgs(campaignName, true, float64(2), false).
+		WillReturnRows(sqlmock.NewRows([]string{"fk_campaign"}))
+
+	err := db.UpsertCampaignPenalty(context.Background(), &types.CampaignPenaltyStruct{CampaignName: campaignName, Enabled: true, PointValue: 2})
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestUpsertCampaignPenalty(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertCampaignPenalty)).
+		WithArgs(campaignName, true, float64(2), true).
+		WillReturnRows(sqlmock.NewRows([]string{"fk_campaign"}).AddRow("campaignId"))
+
+	assert.NoError(t, db.UpsertCampaignPenalty(context.Background(),
+		&types.CampaignPenaltyStruct{CampaignName: campaignName, Enabled: true, PointValue: 2, Floor: true}))
+}
+
+func TestSelectCampaignPenaltyNotFound(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignPenalty)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"enabled", "point_value", "floor"}))
+
+	penalty, err := db.SelectCampaignPenalty(context.Background(), campaignName)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, penalty)
+}
+
+func TestSelectCampaignPenalty(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignPenalty)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"enabled", "point_value", "floor"}).AddRow(true, 2, true))
+
+	penalty, err := db.SelectCampaignPenalty(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.CampaignPenaltyStruct{CampaignName: campaignName, Enabled: true, PointValue: 2, Floor: true}, penalty)
+}
+
+func TestUpsertCampaignCapsNoCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertCampaignCaps)).
+		WithArgs(campaignName, float64(10), float64(0)).
+		WillReturnRows(sqlmock.NewRows([]string{"fk_campaign"}))
+
+	err := db.UpsertCampaignCaps(context.Background(), &types.CampaignCapsStruct{CampaignName: campaignName, PerPullRequest: 10})
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestUpsertCampaignCaps(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertCampaignCaps)).
+		WithArgs(campaignName, float64(10), float64(50)).
+		WillReturnRows(sqlmock.NewRows([]string{"fk_campaign"}).AddRow("campaignId"))
+
+	assert.NoError(t, db.UpsertCampaignCaps(context.Background(),
+		&types.CampaignCapsStruct{CampaignName: campaignName, PerPullRequest: 10, PerDay: 50}))
+}
+
+func TestSelectCampaignCapsNotFound(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignCaps)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"per_pull_request", "per_day"}))
+
+	caps, err := db.SelectCampaignCaps(context.Background(), campaignName)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, caps)
+}
+
+func TestSelectCampaignCaps(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignCaps)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"per_pull_request", "per_day"}).AddRow(10, 50))
+
+	caps, err := db.SelectCampaignCaps(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.CampaignCapsStruct{CampaignName: campaignName, PerPullRequest: 10, PerDay: 50}, caps)
+}
+
+func TestUpsertDuplicatePolicyNoCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertDuplicatePolicy)).
+		WithArgs(campaignName, types.DuplicatePolicySkip, float64(0)).
+		WillReturnRows(sqlmock.NewRows([]string{"fk_campaign"}))
+
+	err := db.UpsertDuplicatePolicy(context.Background(), &types.DuplicatePolicyStruct{CampaignName: campaignName, Policy: types.DuplicatePolicySkip})
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestUpsertDuplicatePolicy(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertDuplicatePolicy)).
+		WithArgs(campaignName, types.DuplicatePolicyDownweight, 0.5).
+		WillReturnRows(sqlmock.NewRows([]string{"fk_campaign"}).AddRow("campaignId"))
+
+	assert.NoError(t, db.UpsertDuplicatePolicy(context.Background(),
+		&types.DuplicatePolicyStruct{CampaignName: campaignName, Policy: types.DuplicatePolicyDownweight, Weight: 0.5}))
+}
+
+func TestSelectDuplicatePolicyNotFound(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectDuplicatePolicy)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"policy", "weight"}))
+
+	policy, err := db.SelectDuplicatePolicy(context.Background(), campaignName)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, policy)
+}
+
+func TestSelectDuplicatePolicy(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectDuplicatePolicy)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"policy", "weight"}).AddRow(types.DuplicatePolicyDownweight, 0.5))
+
+	policy, err := db.SelectDuplicatePolicy(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.DuplicatePolicyStruct{CampaignName: campaignName, Policy: types.DuplicatePolicyDownweight, Weight: 0.5}, policy)
+}
+
+func TestUpsertCoAuthorPolicyNoCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertCoAuthorPolicy)).
+		WithArgs(campaignName, types.CoAuthorPolicySplit).
+		WillReturnRows(sqlmock.NewRows([]string{"fk_campaign"}))
+
+	err := db.UpsertCoAuthorPolicy(context.Background(), &types.CoAuthorPolicyStruct{CampaignName: campaignName, Policy: types.CoAuthorPolicySplit})
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestUpsertCoAuthorPolicy(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertCoAuthorPolicy)).
+		WithArgs(campaignName, types.CoAuthorPolicyDuplicate).
+		WillReturnRows(sqlmock.NewRows([]string{"fk_campaign"}).AddRow("campaignId"))
+
+	assert.NoError(t, db.UpsertCoAuthorPolicy(context.Background(),
+		&types.CoAuthorPolicyStruct{CampaignName: campaignName, Policy: types.CoAuthorPolicyDuplicate}))
+}
+
+func TestSelectCoAuthorPolicyNotFound(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCoAuthorPolicy)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"policy"}))
+
+	policy, err := db.SelectCoAuthorPolicy(context.Background(), campaignName)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, policy)
+}
+
+func TestSelectCoAuthorPolicy(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCoAuthorPolicy)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"policy"}).AddRow(types.CoAuthorPolicyDuplicate))
+
+	policy, err := db.SelectCoAuthorPolicy(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.CoAuthorPolicyStruct{CampaignName: campaignName, Policy: types.CoAuthorPolicyDuplicate}, policy)
+}
+
+func TestUpsertCampaignPrivacyNoCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertCampaignPrivacy)).
+		WithArgs(campaignName, true).
+		WillReturnRows(sqlmock.NewRows([]string{"salt"}))
+
+	err := db.UpsertCampaignPrivacy(context.Background(), &types.CampaignPrivacyStruct{CampaignName: campaignName, Anonymize: true})
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestUpsertCampaignPrivacy(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertCampaignPrivacy)).
+		WithArgs(campaignName, true).
+		WillReturnRows(sqlmock.NewRows([]string{"salt"}).AddRow("mySalt"))
+
+	privacy := &types.CampaignPrivacyStruct{CampaignName: campaignName, Anonymize: true}
+	assert.NoError(t, db.UpsertCampaignPrivacy(context.Background(), privacy))
+	assert.Equal(t, "mySalt", privacy.Salt)
+}
+
+func TestSelectCampaignPrivacyNotFound(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignPrivacy)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"anonymize", "salt"}))
+
+	privacy, err := db.SelectCampaignPrivacy(context.Background(), campaignName)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, privacy)
+}
+
+func TestSelectCampaignPrivacy(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignPrivacy)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"anonymize", "salt"}).AddRow(true, "mySalt"))
+
+	privacy, err := db.SelectCampaignPrivacy(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.CampaignPrivacyStruct{CampaignName: campaignName, Anonymize: true, Salt: "mySalt"}, privacy)
+}
+
+func TestSelectScoringEventsSince(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	participant := types.ParticipantStruct{CampaignName: campaignName, ScpName: scpName, LoginName: "loginName"}
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoringEventsSince)).
+		WithArgs(campaignName, scpName, "loginName", now).
+		WillReturnRows(sqlmock.NewRows([]string{"repoOwner", "repoName", "pr", "username", "points"}).
+			AddRow(TestOrgValid, "myRepo", 3, "loginName", 7))
+
+	events, err := db.SelectScoringEventsSince(context.Background(), &participant, now)
+	assert.NoError(t, err)
+	assert.Equal(t, []ScoringEventRow{{
+		Participant: participant,
+		Msg:         types.ScoringMessage{RepoOwner: TestOrgValid, RepoName: "myRepo", PullRequest: 3, TriggerUser: "loginName"},
+		NewPoints:   7,
+	}}, events)
+}
+
+func TestSelectUnclassifiedBonusNotFound(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectUnclassifiedBonus)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"unclassified_bonus"}))
+
+	bonus, err := db.SelectUnclassifiedBonus(context.Background(), campaignName)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Equal(t, float64(0), bonus)
+}
+
+func TestSelectUnclassifiedBonus(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectUnclassifiedBonus)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"unclassified_bonus"}).AddRow("0.5"))
+
+	bonus, err := db.SelectUnclassifiedBonus(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, bonus)
+}
+
+func TestDeleteSlackNotification(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteSlackNotification)).
+		WithArgs(campaignName).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.DeleteSlackNotification(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+}
+
+func TestSelectTopParticipantsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced top participants error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTopParticipants)).
+		WithArgs(campaignName, 2).
+		WillReturnError(forcedError)
+
+	participants, err := db.SelectTopParticipants(context.Background(), campaignName, 2)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, participants)
+}
+
+func TestSelectTopParticipants(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTopParticipants)).
+		WithArgs(campaignName, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "login_name", "score"}).
+			AddRow(testParticipantGuid, loginName, 12).
+			AddRow("otherGuid", "otherLogin", 10))
+
+	participants, err := db.SelectTopParticipants(context.Background(), campaignName, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ParticipantStruct{
+		{ID: testParticipantGuid, CampaignName: campaignName, LoginName: loginName, Score: 12},
+		{ID: "otherGuid", CampaignName: campaignName, LoginName: "otherLogin", Score: 10},
+	}, participants)
+}
+
+func TestSelectLeaderboardStale(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectLeaderboardStale)).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	stale, err := db.SelectLeaderboardStale(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, stale)
+}
+
+func TestRefreshLeaderboardError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced refresh error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlRefreshLeaderboard)).
+		WillReturnError(forcedError)
+
+	assert.EqualError(t, db.RefreshLeaderboard(context.Background()), forcedError.Error())
+}
+
+func TestRefreshLeaderboard(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlRefreshLeaderboard)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, db.RefreshLeaderboard(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelectLeaderboardNoCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectLeaderboardTieBreaker)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"tie_breaker"}))
+
+	leaderboard, err := db.SelectLeaderboard(context.Background(), campaignName, 2)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, leaderboard)
+}
+
+func TestSelectLeaderboardError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectLeaderboardTieBreaker)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"tie_breaker"}).AddRow(types.TieBreakerReachedScore))
+	forcedError := fmt.Errorf("forced leaderboard error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectLeaderboard)).
+		WithArgs(campaignName, 2).
+		WillReturnError(forcedError)
+
+	leaderboard, err := db.SelectLeaderboard(context.Background(), campaignName, 2)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, leaderboard)
+}
+
+func TestSelectLeaderboardEmpty(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectLeaderboardTieBreaker)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"tie_breaker"}).AddRow(types.TieBreakerReachedScore))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectLeaderboard)).
+		WithArgs(campaignName, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"rank"}))
+
+	leaderboard, err := db.SelectLeaderboard(context.Background(), campaignName, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.Leaderboard{
+		CampaignName:   campaignName,
+		TieBreaker:     types.TieBreakerReachedScore,
+		TieBreakerRule: "equal scores are ranked by who reached the score first",
+		Participants:   []types.LeaderboardEntry{},
+	}, leaderboard)
+}
+
+func TestSelectLeaderboard(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectLeaderboardTieBreaker)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"tie_breaker"}).AddRow(types.TieBreakerJoinedAt))
+	refreshedOn := time.Now()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectLeaderboard)).
+		WithArgs(campaignName, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"rank", "scp_name", "login_name", "display_name", "avatar_url", "team_name", "score", "refreshed_on"}).
+			AddRow(1, "GitHub", loginName, "", "", "myTeam", 12, refreshedOn).
+			AddRow(2, "GitHub", "otherLogin", "Other", "", "", 12, refreshedOn))
+
+	leaderboard, err := db.SelectLeaderboard(context.Background(), campaignName, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.Leaderboard{
+		CampaignName:   campaignName,
+		TieBreaker:     types.TieBreakerJoinedAt,
+		TieBreakerRule: "equal scores are ranked by who joined the campaign first",
+		RefreshedOn:    &refreshedOn,
+		Participants: []types.LeaderboardEntry{
+			{Rank: 1, ScpName: "GitHub", LoginName: loginName, TeamName: "myTeam", Score: 12},
+			{Rank: 2, ScpName: "GitHub", LoginName: "otherLogin", DisplayName: "Other", Score: 12},
+		},
+	}, leaderboard)
+}
+
+func TestSelectTeamLeaderboardNoTeam(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamAwardPoints)).
+		WithArgs(campaignName, "myTeam").
+		WillReturnRows(sqlmock.NewRows([]string{"award_points"}))
+
+	leaderboard, err := db.SelectTeamLeaderboard(context.Background(), campaignName, "myTeam")
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, leaderboard)
+}
+
+func TestSelectTeamLeaderboardEmpty(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamAwardPoints)).
+		WithArgs(campaignName, "myTeam").
+		WillReturnRows(sqlmock.NewRows([]string{"award_points"}).AddRow(0))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamLeaderboard)).
+		WithArgs(campaignName, "myTeam").
+		WillReturnRows(sqlmock.NewRows([]string{"rank", "scp_name", "login_name", "display_name", "avatar_url", "team_name", "score", "refreshed_on"}))
+
+	leaderboard, err := db.SelectTeamLeaderboard(context.Background(), campaignName, "myTeam")
+	assert.NoError(t, err)
+	assert.Equal(t, &types.TeamLeaderboard{
+		CampaignName: campaignName,
+		TeamStanding: types.TeamStanding{TeamName: "myTeam"},
+		Participants: []types.LeaderboardEntry{},
+	}, leaderboard)
+}
+
+func TestSelectTeamLeaderboard(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamAwardPoints)).
+		WithArgs(campaignName, "myTeam").
+		WillReturnRows(sqlmock.NewRows([]string{"award_points"}).AddRow(2.5))
+	refreshedOn := time.Now()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamLeaderboard)).
+		WithArgs(campaignName, "myTeam").
+		WillReturnRows(sqlmock.NewRows([]string{"rank", "scp_name", "login_name", "display_name", "avatar_url", "team_name", "score", "refreshed_on"}).
+			AddRow(1, "GitHub", loginName, "", "", "myTeam", 12, refreshedOn).
+			AddRow(2, "GitHub", "otherLogin", "Other", "", "myTeam", 4, refreshedOn))
+
+	leaderboard, err := db.SelectTeamLeaderboard(context.Background(), campaignName, "myTeam")
+	assert.NoError(t, err)
+	assert.Equal(t, &types.TeamLeaderboard{
+		CampaignName: campaignName,
+		TeamStanding: types.TeamStanding{TeamName: "myTeam", MemberPoints: 16, AwardPoints: 2.5, Points: 18.5},
+		RefreshedOn:  &refreshedOn,
+		Participants: []types.LeaderboardEntry{
+			{Rank: 1, ScpName: "GitHub", LoginName: loginName, TeamName: "myTeam", Score: 12},
+			{Rank: 2, ScpName: "GitHub", LoginName: "otherLogin", DisplayName: "Other", TeamName: "myTeam", Score: 4},
+		},
+	}, leaderboard)
+}
+
+func TestInsertLeaderboardSnapshotAlreadyFinalized(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertLeaderboardSnapshot)).
+		WithArgs(campaignName, now).
+		WillReturnRows(sqlmock.NewRows([]string{"fk_campaign"}))
+	mock.ExpectRollback()
+
+	finalized, err := db.InsertLeaderboardSnapshot(context.Background(), campaignName, now)
+	assert.NoError(t, err)
+	assert.False(t, finalized)
+}
+
+func TestInsertLeaderboardSnapshotEntriesError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertLeaderboardSnapshot)).
+		WithArgs(campaignName, now).
+		WillReturnRows(sqlmock.NewRows([]string{"fk_campaign"}).AddRow(testCampaignGuid))
+	forcedError := fmt.Errorf("forced snapshot entries error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertLeaderboardSnapshotEntries)).
+		WithArgs(testCampaignGuid).
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	finalized, err := db.InsertLeaderboardSnapshot(context.Background(), campaignName, now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.False(t, finalized)
+}
+
+func TestInsertLeaderboardSnapshot(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertLeaderboardSnapshot)).
+		WithArgs(campaignName, now).
+		WillReturnRows(sqlmock.NewRows([]string{"fk_campaign"}).AddRow(testCampaignGuid))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertLeaderboardSnapshotEntries)).
+		WithArgs(testCampaignGuid).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	finalized, err := db.InsertLeaderboardSnapshot(context.Background(), campaignName, now)
+	assert.NoError(t, err)
+	assert.True(t, finalized)
+}
+
+func TestSelectLeaderboardSnapshotNotFinalized(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectLeaderboardSnapshot)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"tie_breaker", "finalized_on"}))
+
+	snapshot, err := db.SelectLeaderboardSnapshot(context.Background(), campaignName)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, snapshot)
+}
+
+func TestSelectLeaderboardSnapshot(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectLeaderboardSnapshot)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"tie_breaker", "finalized_on"}).AddRow(types.TieBreakerJoinedAt, now))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectLeaderboardSnapshotEntries)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"rank", "scp_name", "login_name", "display_name", "avatar_url", "team_name", "score"}).
+			AddRow(1, "GitHub", loginName, "", "", "myTeam", 12).
+			AddRow(2, "GitHub", "otherLogin", "Other", "", "", 10))
+
+	snapshot, err := db.SelectLeaderboardSnapshot(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.LeaderboardSnapshot{
+		CampaignName:   campaignName,
+		TieBreaker:     types.TieBreakerJoinedAt,
+		TieBreakerRule: "equal scores are ranked by who joined the campaign first",
+		FinalizedOn:    now,
+		Participants: []types.LeaderboardEntry{
+			{Rank: 1, ScpName: "GitHub", LoginName: loginName, TeamName: "myTeam", Score: 12},
+			{Rank: 2, ScpName: "GitHub", LoginName: "otherLogin", DisplayName: "Other", Score: 10},
+		},
+	}, snapshot)
+}
+
+const webhookUrl = "https://example.com/bbash"
+
+func TestUpsertCampaignEmailNoCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	email := types.CampaignEmailStruct{CampaignName: campaignName, Kind: types.EmailKindRegistration, Enabled: true, Subject: "subject", Body: "body"}
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertCampaignEmail)).
+		WithArgs(campaignName, types.EmailKindRegistration, true, "subject", "body").
+		WillReturnRows(sqlmock.NewRows([]string{"fk_campaign"}))
+
+	assert.EqualError(t, db.UpsertCampaignEmail(context.Background(), &email), sql.ErrNoRows.Error())
+}
+
+func TestUpsertCampaignEmail(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	email := types.CampaignEmailStruct{CampaignName: campaignName, Kind: types.EmailKindRegistration, Enabled: true, Subject: "subject", Body: "body"}
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlUpsertCampaignEmail)).
+		WithArgs(campaignName, types.EmailKindRegistration, true, "subject", "body").
+		WillReturnRows(sqlmock.NewRows([]string{"fk_campaign"}).AddRow(testCampaign.ID))
+
+	assert.NoError(t, db.UpsertCampaignEmail(context.Background(), &email))
+}
+
+func TestSelectCampaignEmailsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced emails error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignEmails)).
+		WithArgs(campaignName).
+		WillReturnError(forcedError)
+
+	emails, err := db.SelectCampaignEmails(context.Background(), campaignName)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, emails)
+}
+
+func TestSelectCampaignEmails(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignEmails)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"kind", "enabled", "subject", "body"}).
+			AddRow(types.EmailKindCampaignEnd, false, "end", "ended").
+			AddRow(types.EmailKindRegistration, true, "welcome", "hello"))
+
+	emails, err := db.SelectCampaignEmails(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.CampaignEmailStruct{
+		{CampaignName: campaignName, Kind: types.EmailKindCampaignEnd, Enabled: false, Subject: "end", Body: "ended"},
+		{CampaignName: campaignName, Kind: types.EmailKindRegistration, Enabled: true, Subject: "welcome", Body: "hello"},
+	}, emails)
+}
+
+func TestSelectCampaignEmailNotFound(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignEmail)).
+		WithArgs(campaignName, types.EmailKindTeamAssignment).
+		WillReturnRows(sqlmock.NewRows([]string{"enabled", "subject", "body"}))
+
+	email, err := db.SelectCampaignEmail(context.Background(), campaignName, types.EmailKindTeamAssignment)
+	assert.EqualError(t, err, sql.ErrNoRows.Error())
+	assert.Nil(t, email)
+}
+
+func TestSelectCampaignEmail(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignEmail)).
+		WithArgs(campaignName, types.EmailKindTeamAssignment).
+		WillReturnRows(sqlmock.NewRows([]string{"enabled", "subject", "body"}).AddRow(true, "team", "joined"))
+
+	email, err := db.SelectCampaignEmail(context.Background(), campaignName, types.EmailKindTeamAssignment)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.CampaignEmailStruct{CampaignName: campaignName, Kind: types.EmailKindTeamAssignment, Enabled: true, Subject: "team", Body: "joined"}, email)
+}
+
+func TestSelectBugsFixed(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	participant := types.ParticipantStruct{CampaignName: campaignName, ScpName: "myScpName", LoginName: "myLoginName"}
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectBugsFixed)).
+		WithArgs(campaignName, "myScpName", "myLoginName").
+		WillReturnRows(sqlmock.NewRows([]string{"bugs"}).AddRow(12))
+
+	bugsFixed, err := db.SelectBugsFixed(context.Background(), &participant)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(12), bugsFixed)
+}
+
+func TestInsertAchievementError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced achievement error")
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertAchievement)).
+		WithArgs(testParticipantGuid, types.AchievementFirstFix, now).
+		WillReturnError(forcedError)
+
+	awarded, err := db.InsertAchievement(context.Background(), testParticipantGuid, types.AchievementFirstFix, now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.False(t, awarded)
+}
+
+func TestInsertAchievementAlreadyAwarded(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertAchievement)).
+		WithArgs(testParticipantGuid, types.AchievementFirstFix, now).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	awarded, err := db.InsertAchievement(context.Background(), testParticipantGuid, types.AchievementFirstFix, now)
+	assert.NoError(t, err)
+	assert.False(t, awarded)
+}
+
+func TestInsertAchievement(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertAchievement)).
+		WithArgs(testParticipantGuid, types.AchievementFirstFix, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	awarded, err := db.InsertAchievement(context.Background(), testParticipantGuid, types.AchievementFirstFix, now)
+	assert.NoError(t, err)
+	assert.True(t, awarded)
+}
+
+func TestSelectAchievementsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced achievements error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectAchievements)).
+		WithArgs(campaignName, "myScpName", "myLoginName").
+		WillReturnError(forcedError)
+
+	achievements, err := db.SelectAchievements(context.Background(), campaignName, "myScpName", "myLoginName")
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, achievements)
+}
+
+func TestSelectAchievements(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectAchievements)).
+		WithArgs(campaignName, "myScpName", "myLoginName").
+		WillReturnRows(sqlmock.NewRows([]string{"kind", "awarded_on"}).
+			AddRow(types.AchievementFirstFix, now).
+			AddRow(types.AchievementTenBugs, now))
+
+	achievements, err := db.SelectAchievements(context.Background(), campaignName, "myScpName", "myLoginName")
+	assert.NoError(t, err)
+	assert.Equal(t, []types.AchievementStruct{
+		{Kind: types.AchievementFirstFix, AwardedOn: now},
+		{Kind: types.AchievementTenBugs, AwardedOn: now},
+	}, achievements)
+}
+
+func TestSelectParticipantScoreAtNoParticipant(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantScoreAt)).
+		WithArgs(campaignName, "myScpName", "myLoginName", now).
+		WillReturnRows(sqlmock.NewRows([]string{"score", "scoringEvents"}))
+
+	score, err := db.SelectParticipantScoreAt(context.Background(), campaignName, "myScpName", "myLoginName", now)
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.Nil(t, score)
+}
+
+func TestSelectParticipantScoreAt(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantScoreAt)).
+		WithArgs(campaignName, "myScpName", "myLoginName", now).
+		WillReturnRows(sqlmock.NewRows([]string{"score", "scoringEvents"}).AddRow(12.5, 3))
+
+	score, err := db.SelectParticipantScoreAt(context.Background(), campaignName, "myScpName", "myLoginName", now)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.ParticipantScoreAt{
+		CampaignName:  campaignName,
+		ScpName:       "myScpName",
+		LoginName:     "myLoginName",
+		At:            now,
+		Score:         12.5,
+		ScoringEvents: 3,
+	}, score)
+}
+
+func TestSelectAchievementStatsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced achievement stats error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectAchievementStats)).
+		WithArgs(campaignName).
+		WillReturnError(forcedError)
+
+	stats, err := db.SelectAchievementStats(context.Background(), campaignName)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, stats)
+}
+
+func TestSelectAchievementStats(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectAchievementStats)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"kind", "count"}).
+			AddRow(types.AchievementFirstFix, 5).
+			AddRow(types.AchievementTopThree, 3))
+
+	stats, err := db.SelectAchievementStats(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.AchievementStat{
+		{Kind: types.AchievementFirstFix, Participants: 5},
+		{Kind: types.AchievementTopThree, Participants: 3},
+	}, stats)
+}
+
+func TestInsertWebhook(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertWebhook)).
+		WithArgs(webhookUrl, "mySecret", []byte("[]")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_on"}).AddRow("myWebhookId", now))
+
+	hook := types.WebhookStruct{TargetUrl: webhookUrl, Secret: "mySecret"}
+	assert.NoError(t, db.InsertWebhook(context.Background(), &hook))
+	assert.Equal(t, types.WebhookStruct{ID: "myWebhookId", TargetUrl: webhookUrl, Secret: "mySecret", Events: []string{}, CreatedOn: now}, hook)
+}
+
+func TestSelectWebhooksError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced webhook error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectWebhooks)).
+		WillReturnError(forcedError)
+
+	hooks, err := db.SelectWebhooks(context.Background())
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, hooks)
+}
+
+func TestSelectWebhooks(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectWebhooks)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "target_url", "secret", "events", "created_on"}).
+			AddRow("myWebhookId", webhookUrl, "mySecret", []byte(`["score.changed"]`), now))
+
+	hooks, err := db.SelectWebhooks(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []types.WebhookStruct{
+		{ID: "myWebhookId", TargetUrl: webhookUrl, Secret: "mySecret", Events: []string{types.WebhookEventScoreChanged}, CreatedOn: now},
+	}, hooks)
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteWebhook)).
+		WithArgs("myWebhookId").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.DeleteWebhook(context.Background(), "myWebhookId")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+}
+
+func TestClaimCampaignTransitionsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced claim error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlClaimCampaignTransitions)).
+		WithArgs(now).
+		WillReturnError(forcedError)
+
+	transitions, err := db.ClaimCampaignTransitions(context.Background(), now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, transitions)
+}
+
+func TestClaimCampaignTransitions(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	endedCampaign := testCampaign
+	endedCampaign.Status = types.CampaignStatusEnded
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlClaimCampaignTransitions)).
+		WithArgs(now).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note", "maxTeamSize", "tieBreaker", "unclassifiedBonus", "timeZone", "status", "freezeScoring", "registrationOpensOn", "registrationClosesOn", "version", "fromStatus", "firstEnd"}).
+			AddRow(endedCampaign.ID, endedCampaign.Name, endedCampaign.CreatedOn, endedCampaign.CreatedOrder, endedCampaign.StartOn, endedCampaign.EndOn, endedCampaign.Note, *endedCampaign.MaxTeamSize, endedCampaign.TieBreaker, *endedCampaign.UnclassifiedBonus, endedCampaign.TimeZone, endedCampaign.Status, endedCampaign.FreezeScoring, *endedCampaign.RegistrationOpensOn, *endedCampaign.RegistrationClosesOn, endedCampaign.Version, types.CampaignStatusActive, true))
+
+	transitions, err := db.ClaimCampaignTransitions(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.CampaignTransition{{Campaign: endedCampaign, FromStatus: types.CampaignStatusActive, FirstEnd: true}}, transitions)
+}
+
+func TestPromoteCampaignConfigStageInsertError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignConfigStageForUpdate)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"config", "updated_on"}).
+			AddRow(`{"campaign":"campaignName","bugs":[{"category":"bugCategory","pointValue":2}]}`, now))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteCampaignBugs)).
+		WithArgs(campaignName).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	forcedError := fmt.Errorf("forced promote bug error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertBug)).
+		WithArgs(campaignName, bugCategory, float64(2)).
+		WillReturnError(forcedError)
+	mock.ExpectRollback()
+
+	_, err := db.PromoteCampaignConfigStage(context.Background(), campaignName)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPromoteCampaignConfigStage(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignConfigStageForUpdate)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"config", "updated_on"}).
+			AddRow(`{"campaign":"campaignName","bugs":[{"category":"bugCategory","pointValue":2}]}`, now))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteCampaignBugs)).
+		WithArgs(campaignName).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertBug)).
+		WithArgs(campaignName, bugCategory, float64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(bugGuid))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteCampaignConfigStage)).
+		WithArgs(campaignName).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	config, err := db.PromoteCampaignConfigStage(context.Background(), campaignName)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.CampaignConfigStruct{
+		Campaign:  campaignName,
+		Bugs:      []types.BugStruct{{Id: bugGuid, Category: bugCategory, PointValue: 2}},
+		UpdatedOn: now,
+	}, config)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateTeamName(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateTeamName)).
+		WithArgs(testCampaign.Name, "teamName", "newTeamName").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.UpdateTeamName(context.Background(), testCampaign.Name, "teamName", "newTeamName")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+}
+
+func TestUpdateTeamCaptainNotMember(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlClearTeamCaptain)).
+		WithArgs(testCampaign.Name, "teamName").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlSetTeamCaptain)).
+		WithArgs(testCampaign.Name, "teamName", "scpName", loginName).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	rowsAffected, err := db.UpdateTeamCaptain(context.Background(), testCampaign.Name, "teamName", "scpName", loginName)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), rowsAffected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateTeamCaptain(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlClearTeamCaptain)).
+		WithArgs(testCampaign.Name, "teamName").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlSetTeamCaptain)).
+		WithArgs(testCampaign.Name, "teamName", "scpName", loginName).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rowsAffected, err := db.UpdateTeamCaptain(context.Background(), testCampaign.Name, "teamName", "scpName", loginName)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIsTeamCaptain(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectIsTeamCaptain)).
+		WithArgs(testCampaign.Name, "teamName", "scpName", loginName).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	isCaptain, err := db.IsTeamCaptain(context.Background(), testCampaign.Name, "teamName", "scpName", loginName)
+	assert.NoError(t, err)
+	assert.True(t, isCaptain)
+}
+
+func TestRemoveParticipantFromTeam(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlRemoveParticipantFromTeam)).
+		WithArgs("teamName", testCampaign.Name, "scpName", loginName).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.RemoveParticipantFromTeam(context.Background(), "teamName", testCampaign.Name, "scpName", loginName)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+}
+
+func TestInsertNotification(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	notification := types.NotificationStruct{
+		ParticipantId: testParticipantGuid,
+		Kind:          types.NotificationKindPointsAwarded,
+		Message:       "+1 points",
+		CreatedOn:     now,
+	}
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertNotification)).
+		WithArgs(testParticipantGuid, types.NotificationKindPointsAwarded, "+1 points", now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("notificationId"))
+
+	assert.NoError(t, db.InsertNotification(context.Background(), &notification))
+	assert.Equal(t, "notificationId", notification.Id)
+}
+
+func TestSelectNotifications(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectNotifications)).
+		WithArgs(testCampaign.Name, "scpName", loginName, true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "participant", "kind", "message", "createdOn", "readOn"}).
+			AddRow("notificationId", testParticipantGuid, types.NotificationKindPointsAwarded, "+1 points", now, nil))
+
+	notifications, err := db.SelectNotifications(context.Background(), testCampaign.Name, "scpName", loginName, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.NotificationStruct{
+		{
+			Id:            "notificationId",
+			ParticipantId: testParticipantGuid,
+			Kind:          types.NotificationKindPointsAwarded,
+			Message:       "+1 points",
+			CreatedOn:     now,
+		},
+	}, notifications)
+}
+
+func TestUpdateNotificationsReadAll(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateNotificationsRead)).
+		WithArgs(testCampaign.Name, "scpName", loginName, now).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	rowsAffected, err := db.UpdateNotificationsRead(context.Background(), testCampaign.Name, "scpName", loginName, "", now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), rowsAffected)
+}
+
+func TestUpdateNotificationsReadOne(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateNotificationRead)).
+		WithArgs(testCampaign.Name, "scpName", loginName, now, "notificationId").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.UpdateNotificationsRead(context.Background(), testCampaign.Name, "scpName", loginName, "notificationId", now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+}
+
+var testTeamScoreAdjustmentRequest = &types.TeamScoreAdjustmentRequest{
+	Category: "bestWriteup",
+	Adjustments: []types.TeamScoreAdjustment{
+		{TeamName: "teamOne", Points: 5, Reason: "very clear"},
+		{TeamName: "teamTwo", Points: 2, Reason: "clear"},
+	},
+}
+
+func TestInsertTeamScoreAdjustmentsNoTeam(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertTeamScoreEvent)).
+		WithArgs(testCampaign.Name, "teamOne", types.TeamScoreKindJudgedAward, "bestWriteup", float64(5), "very clear", now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("eventOne"))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertTeamScoreEvent)).
+		WithArgs(testCampaign.Name, "teamTwo", types.TeamScoreKindJudgedAward, "bestWriteup", float64(2), "clear", now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	events, err := db.InsertTeamScoreAdjustments(context.Background(), testCampaign.Name, testTeamScoreAdjustmentRequest, now)
+	assert.EqualError(t, err, "no team: campaignName: testCampaignName, name: teamTwo: sql: no rows in result set")
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+	assert.Nil(t, events)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInsertTeamScoreAdjustments(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertTeamScoreEvent)).
+		WithArgs(testCampaign.Name, "teamOne", types.TeamScoreKindJudgedAward, "bestWriteup", float64(5), "very clear", now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("eventOne"))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertTeamScoreEvent)).
+		WithArgs(testCampaign.Name, "teamTwo", types.TeamScoreKindJudgedAward, "bestWriteup", float64(2), "clear", now).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("eventTwo"))
+	mock.ExpectCommit()
+
+	events, err := db.InsertTeamScoreAdjustments(context.Background(), testCampaign.Name, testTeamScoreAdjustmentRequest, now)
+	assert.NoError(t, err)
+	createdOn := sql.NullTime{Time: now, Valid: true}
+	assert.Equal(t, []types.TeamScoreEvent{
+		{Id: "eventOne", TeamName: "teamOne", Kind: types.TeamScoreKindJudgedAward, Category: "bestWriteup", Points: 5, Reason: "very clear", CreatedOn: createdOn},
+		{Id: "eventTwo", TeamName: "teamTwo", Kind: types.TeamScoreKindJudgedAward, Category: "bestWriteup", Points: 2, Reason: "clear", CreatedOn: createdOn},
+	}, events)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelectTeamScoreHistory(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTeamScoreHistory)).
+		WithArgs(testCampaign.Name, "teamName").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "team", "kind", "category", "login", "points", "reason", "createdOn"}).
+			AddRow("", "teamName", types.TeamScoreKindPullRequest, "", loginName, 3, "owner/repo #1", now).
+			AddRow("eventId", "teamName", types.TeamScoreKindJudgedAward, "bestWriteup", "", 5, "very clear", now).
+			AddRow("", "teamName", types.TeamScoreKindPullRequest, "", loginName, 2, "owner/repo #0", nil))
 
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantDetail)).
-		WithArgs(campaignName, scpName, loginName).
-		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt"}).
-			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, "teamName", now))
+	events, err := db.SelectTeamScoreHistory(context.Background(), testCampaign.Name, "teamName")
+	assert.NoError(t, err)
+	assert.Equal(t, []types.TeamScoreEvent{
+		{TeamName: "teamName", Kind: types.TeamScoreKindPullRequest, LoginName: loginName, Points: 3, Reason: "owner/repo #1",
+			CreatedOn: sql.NullTime{Time: now, Valid: true}},
+		{Id: "eventId", TeamName: "teamName", Kind: types.TeamScoreKindJudgedAward, Category: "bestWriteup", Points: 5, Reason: "very clear",
+			CreatedOn: sql.NullTime{Time: now, Valid: true}},
+		{TeamName: "teamName", Kind: types.TeamScoreKindPullRequest, LoginName: loginName, Points: 2, Reason: "owner/repo #0"},
+	}, events)
+}
+
+func TestSelectCampaignReportNoCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaign)).
+		WithArgs(testCampaign.Name).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := db.SelectCampaignReport(context.Background(), testCampaign.Name, 3, now)
+	assert.True(t, errors.Is(err, sql.ErrNoRows))
+}
+
+func TestSelectCampaignReport(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
 
-	participant, err := db.SelectParticipantDetail(campaignName, scpName, loginName)
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaign)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "createdOn", "createOrder", "startOn", "endOn", "note", "maxTeamSize", "tieBreaker", "unclassifiedBonus", "timeZone", "status", "freezeScoring", "registrationOpensOn", "registrationClosesOn", "version"}).
+			AddRow(testCampaign.ID, testCampaign.Name, testCampaign.CreatedOn, testCampaign.CreatedOrder, testCampaign.StartOn, testCampaign.EndOn, testCampaign.Note, *testCampaign.MaxTeamSize, testCampaign.TieBreaker, *testCampaign.UnclassifiedBonus, testCampaign.TimeZone, testCampaign.Status, testCampaign.FreezeScoring, *testCampaign.RegistrationOpensOn, *testCampaign.RegistrationClosesOn, testCampaign.Version))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectReportStats)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"participants", "teams", "pullRequests", "points", "judgedAwardPoints"}).
+			AddRow(2, 1, 3, 7, 5))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectReportTopParticipants)).
+		WithArgs(testCampaign.Name, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "campaign", "scp", "login", "display", "score", "team", "joinedAt"}).
+			AddRow(testParticipantGuid, testCampaign.Name, scpName, loginName, "", 7, "teamName", now))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectReportTopTeams)).
+		WithArgs(testCampaign.Name, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"team", "memberPoints", "awardPoints"}).
+			AddRow("teamName", 7, 5))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectReportCategories)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"category", "count", "points"}).
+			AddRow(bugCategory, 4, 4))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectReportRepositories)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"owner", "repo", "pullRequests", "points"}).
+			AddRow("owner", "repo", 3, 7))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectReportTimeline)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"day", "pullRequests", "points", "joined"}).
+			AddRow(now, 3, 7, 2))
+
+	report, err := db.SelectCampaignReport(context.Background(), testCampaign.Name, 3, now)
 	assert.NoError(t, err)
-	assert.Equal(t, &types.ParticipantStruct{
-		ID:           testParticipantGuid,
-		CampaignName: campaignName,
-		ScpName:      scpName,
-		LoginName:    loginName,
-		Email:        "email",
-		DisplayName:  "display",
-		Score:        -1,
-		TeamName:     "teamName",
-		JoinedAt:     now,
-	}, participant)
+	assert.Equal(t, &types.CampaignReport{
+		Campaign:    testCampaign,
+		GeneratedOn: now,
+		Stats:       types.CampaignStats{Participants: 2, Teams: 1, PullRequests: 3, Points: 7, JudgedAwardPoints: 5},
+		TopParticipants: []types.ParticipantStruct{
+			{ID: testParticipantGuid, CampaignName: testCampaign.Name, ScpName: scpName, LoginName: loginName, Score: 7, TeamName: "teamName", JoinedAt: now},
+		},
+		TopTeams:     []types.TeamStanding{{TeamName: "teamName", MemberPoints: 7, AwardPoints: 5, Points: 12}},
+		Categories:   []types.ReportCategory{{Category: bugCategory, Count: 4, Points: 4}},
+		Repositories: []types.RepositoryImpact{{RepoOwner: "owner", RepoName: "repo", PullRequests: 3, Points: 7}},
+		Timeline:     []types.TimelineDay{{Day: now, PullRequests: 3, Points: 7, ParticipantsJoined: 2}},
+	}, report)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestSelectParticipantsInCampaignError(t *testing.T) {
+func TestUpsertCampaignReport(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	forcedError := fmt.Errorf("forced select campaign participants error")
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsByCampaign)).
-		WithArgs(campaignName).
-		WillReturnError(forcedError)
+	report := &types.CampaignReport{Campaign: testCampaign, GeneratedOn: now}
+	reportJson, err := json.Marshal(report)
+	assert.NoError(t, err)
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpsertCampaignReport)).
+		WithArgs(testCampaign.Name, reportJson, "<html></html>", now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	participants, err := db.SelectParticipantsInCampaign(campaignName)
-	assert.EqualError(t, err, forcedError.Error())
-	assert.Equal(t, ([]types.ParticipantStruct)(nil), participants)
+	assert.NoError(t, db.UpsertCampaignReport(context.Background(), report, "<html></html>"))
 }
 
-func TestSelectParticipantsInCampaignScanError(t *testing.T) {
+func TestSelectStoredCampaignReport(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsByCampaign)).
-		WithArgs(campaignName).
-		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt"}).
-			// force scan error with nil in JoinedAt Time field
-			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, "teamName", nil))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectStoredCampaignReport)).
+		WithArgs(testCampaign.Name).
+		WillReturnRows(sqlmock.NewRows([]string{"report", "html"}).AddRow([]byte(`{}`), "<html></html>"))
 
-	participants, err := db.SelectParticipantsInCampaign(campaignName)
-	assert.EqualError(t, err, "sql: Scan error on column index 8, name \"joinedAt\": unsupported Scan, storing driver.Value type <nil> into type *time.Time")
-	assert.Equal(t, ([]types.ParticipantStruct)(nil), participants)
+	reportJson, html, err := db.SelectStoredCampaignReport(context.Background(), testCampaign.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(`{}`), reportJson)
+	assert.Equal(t, "<html></html>", html)
 }
 
-func TestSelectParticipantsInCampaignNoTeam(t *testing.T) {
+func TestSelectCampaignsVersion(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsByCampaign)).
-		WithArgs(campaignName).
-		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt"}).
-			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, sql.NullString{}, now))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignsVersion)).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "updatedOn"}).AddRow(2, now))
 
-	participants, err := db.SelectParticipantsInCampaign(campaignName)
+	version, err := db.SelectCampaignsVersion(context.Background())
 	assert.NoError(t, err)
-	assert.Equal(t, []types.ParticipantStruct{
-		{
-			ID:           testParticipantGuid,
-			CampaignName: campaignName,
-			ScpName:      scpName,
-			LoginName:    loginName,
-			Email:        "email",
-			DisplayName:  "display",
-			Score:        -1,
-			TeamName:     "",
-			JoinedAt:     now,
-		},
-	}, participants)
+	assert.Equal(t, types.ListVersion{Count: 2, UpdatedOn: now}, version)
 }
 
-func TestSelectParticipantsInCampaign(t *testing.T) {
+func TestSelectBugsVersion(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsByCampaign)).
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectBugsVersion)).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "updatedOn"}).AddRow(3, now))
+
+	version, err := db.SelectBugsVersion(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, types.ListVersion{Count: 3, UpdatedOn: now}, version)
+}
+
+func TestSelectParticipantsVersionNoCampaign(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsVersion)).
+		WithArgs(campaignName).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "updatedOn"}))
+
+	_, err := db.SelectParticipantsVersion(context.Background(), campaignName)
+	assert.Equal(t, sql.ErrNoRows, err)
+}
+
+func TestSelectParticipantsVersion(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsVersion)).
 		WithArgs(campaignName).
-		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt"}).
-			AddRow(testParticipantGuid, campaignName, scpName, loginName, "email", "display", -1, "teamName", now))
+		WillReturnRows(sqlmock.NewRows([]string{"count", "updatedOn"}).AddRow(5, now))
 
-	participants, err := db.SelectParticipantsInCampaign(campaignName)
+	version, err := db.SelectParticipantsVersion(context.Background(), campaignName)
 	assert.NoError(t, err)
-	assert.Equal(t, []types.ParticipantStruct{
-		{
-			ID:           testParticipantGuid,
-			CampaignName: campaignName,
-			ScpName:      scpName,
-			LoginName:    loginName,
-			Email:        "email",
-			DisplayName:  "display",
-			Score:        -1,
-			TeamName:     "teamName",
-			JoinedAt:     now,
-		},
-	}, participants)
+	assert.Equal(t, types.ListVersion{Count: 5, UpdatedOn: now}, version)
 }
 
-func TestUpdateParticipantError(t *testing.T) {
+func TestSelectScoreTimeSeriesError(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	testParticipant := types.ParticipantStruct{}
-	forcedError := fmt.Errorf("forced update participant error")
-	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipant)).
-		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, testParticipant.LoginName, testParticipant.Email,
-			testParticipant.DisplayName, testParticipant.Score, testParticipant.TeamName,
-			testParticipant.ID).
+	forcedError := fmt.Errorf("forced time series error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoreTimeSeries)).
+		WithArgs(campaignName, "day", false).
 		WillReturnError(forcedError)
 
-	rowsAffected, err := db.UpdateParticipant(&testParticipant)
+	points, err := db.SelectScoreTimeSeries(context.Background(), campaignName, "day", false)
 	assert.EqualError(t, err, forcedError.Error())
-	assert.Equal(t, int64(0), rowsAffected)
+	assert.Nil(t, points)
 }
 
-func TestUpdateParticipantRowsAffectedError(t *testing.T) {
+func TestSelectScoreTimeSeries(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	testParticipant := types.ParticipantStruct{
-		ID:           testParticipantGuid,
-		CampaignName: campaignName,
-		ScpName:      scpName,
-		LoginName:    loginName,
-		Email:        "email",
-		DisplayName:  "display",
-		Score:        -1,
-		TeamName:     "teamName",
-		JoinedAt:     now,
-	}
-	forcedError := fmt.Errorf("forced update participant rows affected error")
-	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipant)).
-		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, testParticipant.LoginName, testParticipant.Email,
-			testParticipant.DisplayName, testParticipant.Score, testParticipant.TeamName,
-			testParticipant.ID).
-		WillReturnResult(sqlmock.NewErrorResult(forcedError))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectScoreTimeSeries)).
+		WithArgs(campaignName, "day", true).
+		WillReturnRows(sqlmock.NewRows([]string{"date_trunc", "team", "points"}).
+			AddRow(now, "blue", 4).
+			AddRow(now, "", 2))
 
-	rowsAffected, err := db.UpdateParticipant(&testParticipant)
-	assert.EqualError(t, err, forcedError.Error())
-	assert.Equal(t, int64(0), rowsAffected)
+	points, err := db.SelectScoreTimeSeries(context.Background(), campaignName, "day", true)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.TimeSeriesPoint{
+		{Start: now, TeamName: "blue", Points: 4},
+		{Start: now, Points: 2},
+	}, points)
 }
 
-func TestUpdateParticipant(t *testing.T) {
+func TestInsertTelemetryEvent(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	testParticipant := types.ParticipantStruct{
-		ID:           testParticipantGuid,
-		CampaignName: campaignName,
-		ScpName:      scpName,
-		LoginName:    loginName,
-		Email:        "email",
-		DisplayName:  "display",
-		Score:        -1,
-		TeamName:     "teamName",
-		JoinedAt:     now,
-	}
-	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipant)).
-		WithArgs(testParticipant.CampaignName, testParticipant.ScpName, testParticipant.LoginName, testParticipant.Email,
-			testParticipant.DisplayName, testParticipant.Score, testParticipant.TeamName,
-			testParticipant.ID).
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlInsertTelemetryEvent)).
+		WithArgs("leaderboard", "getParticipants", now).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	rowsAffected, err := db.UpdateParticipant(&testParticipant)
-	assert.NoError(t, err)
-	assert.Equal(t, int64(1), rowsAffected)
+	assert.NoError(t, db.InsertTelemetryEvent(context.Background(), "leaderboard", "getParticipants", now))
 }
 
-func TestDeleteParticipantError(t *testing.T) {
+func TestSelectTelemetryUsageError(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	forcedError := fmt.Errorf("forced delete participant error")
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlDeleteParticipant)).
-		WithArgs(campaignName, scpName, loginName).
+	forcedError := fmt.Errorf("forced telemetry usage error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTelemetryUsage)).
+		WithArgs(now.Add(-time.Hour), now).
 		WillReturnError(forcedError)
 
-	deletedParticipantId, err := db.DeleteParticipant(campaignName, scpName, loginName)
+	usage, err := db.SelectTelemetryUsage(context.Background(), now.Add(-time.Hour), now)
 	assert.EqualError(t, err, forcedError.Error())
-	assert.Equal(t, "", deletedParticipantId)
+	assert.Nil(t, usage)
 }
 
-func TestDeleteParticipant(t *testing.T) {
+func TestSelectTelemetryUsage(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlDeleteParticipant)).
-		WithArgs(campaignName, scpName, loginName).
-		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testParticipantGuid))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectTelemetryUsage)).
+		WithArgs(now.Add(-time.Hour), now).
+		WillReturnRows(sqlmock.NewRows([]string{"feature", "count"}).
+			AddRow("leaderboard", 7).
+			AddRow("participants", 2))
 
-	deletedParticipantId, err := db.DeleteParticipant(campaignName, scpName, loginName)
+	usage, err := db.SelectTelemetryUsage(context.Background(), now.Add(-time.Hour), now)
 	assert.NoError(t, err)
-	assert.Equal(t, testParticipantGuid, deletedParticipantId)
+	assert.Equal(t, []types.TelemetryUsage{{Feature: "leaderboard", Calls: 7}, {Feature: "participants", Calls: 2}}, usage)
 }
 
-const teamName = "teamName"
+func TestInsertAuditLog(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
 
-func TestUpdateParticipantTeamError(t *testing.T) {
+	entry := types.AuditLogEntry{Actor: "admin", Method: "PUT", Path: "/admin/poll/last", Route: "/admin/poll/last", Status: 200, Payload: "{}", CreatedOn: now}
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertAuditLog)).
+		WithArgs("admin", "PUT", "/admin/poll/last", "/admin/poll/last", 200, "{}", now).
+		WillReturnRows(sqlmock.NewRows([]string{"Id"}).AddRow("auditId"))
+
+	assert.NoError(t, db.InsertAuditLog(context.Background(), &entry))
+	assert.Equal(t, "auditId", entry.ID)
+}
+
+func TestSelectAuditLogError(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	forcedError := fmt.Errorf("forced update participant team error")
-	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipantTeam)).
-		WithArgs(teamName, campaignName, scpName, loginName).
+	forcedError := fmt.Errorf("forced audit log error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectAuditLog)).
+		WithArgs("", 10).
 		WillReturnError(forcedError)
 
-	rowsAffected, err := db.UpdateParticipantTeam(teamName, campaignName, scpName, loginName)
+	entries, err := db.SelectAuditLog(context.Background(), "", 10)
 	assert.EqualError(t, err, forcedError.Error())
-	assert.Equal(t, int64(0), rowsAffected)
+	assert.Nil(t, entries)
 }
 
-func TestUpdateParticipantTeamRowsAffectedError(t *testing.T) {
+func TestSelectAuditLog(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	forcedError := fmt.Errorf("forced update participant team rows affected error")
-	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipantTeam)).
-		WithArgs(teamName, campaignName, scpName, loginName).
-		WillReturnResult(sqlmock.NewErrorResult(forcedError))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectAuditLog)).
+		WithArgs("admin", 10).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "actor", "method", "path", "route", "status", "payload", "created_on"}).
+			AddRow("auditId", "admin", "DELETE", "/admin/poll/stop", "/admin/poll/stop", 200, "", now))
 
-	rowsAffected, err := db.UpdateParticipantTeam(teamName, campaignName, scpName, loginName)
-	assert.EqualError(t, err, forcedError.Error())
-	assert.Equal(t, int64(0), rowsAffected)
+	entries, err := db.SelectAuditLog(context.Background(), "admin", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.AuditLogEntry{{ID: "auditId", Actor: "admin", Method: "DELETE", Path: "/admin/poll/stop", Route: "/admin/poll/stop", Status: 200, CreatedOn: now}}, entries)
 }
 
-func TestUpdateParticipantTeam(t *testing.T) {
+func TestUpdateParticipantProfile(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipantTeam)).
-		WithArgs(teamName, campaignName, scpName, loginName).
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipantProfile)).
+		WithArgs(testParticipantGuid, "My Name", "https://avatars.example.com/u/1", now).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
-	rowsAffected, err := db.UpdateParticipantTeam(teamName, campaignName, scpName, loginName)
-	assert.NoError(t, err)
-	assert.Equal(t, int64(1), rowsAffected)
+	assert.NoError(t, db.UpdateParticipantProfile(context.Background(), testParticipantGuid, "My Name", "https://avatars.example.com/u/1", now))
 }
 
-const bugCategory = "bugCategory"
-const bugGuid = "bugGuid"
-
-func TestInsertBugError(t *testing.T) {
+func TestSelectParticipantsToRefreshError(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	bug := types.BugStruct{
-		// empty Id before insert
-		Campaign:   campaignName,
-		Category:   bugCategory,
-		PointValue: 2,
-	}
-	forcedError := fmt.Errorf("forced insert bug error")
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertBug)).
-		WithArgs(bug.Campaign, bug.Category, bug.PointValue).
+	forcedError := fmt.Errorf("forced refresh error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsToRefresh)).
+		WithArgs(now, 10).
 		WillReturnError(forcedError)
 
-	assert.EqualError(t, db.InsertBug(&bug), forcedError.Error())
-	assert.Equal(t, "", bug.Id)
+	participants, err := db.SelectParticipantsToRefresh(context.Background(), now, 10)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, participants)
 }
 
-func TestInsertBug(t *testing.T) {
+func TestSelectParticipantsToRefresh(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	bug := types.BugStruct{
-		// empty Id before insert
-		Campaign:   campaignName,
-		Category:   bugCategory,
-		PointValue: 2,
-	}
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlInsertBug)).
-		WithArgs(bug.Campaign, bug.Category, bug.PointValue).
-		WillReturnRows(sqlmock.NewRows([]string{"guid"}).AddRow(bugGuid))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectParticipantsToRefresh)).
+		WithArgs(now, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"Id", "campaign", "scp", "login"}).
+			AddRow(testParticipantGuid, campaignName, "GitHub", "myLogin"))
 
-	assert.NoError(t, db.InsertBug(&bug))
-	assert.Equal(t, bugGuid, bug.Id)
+	participants, err := db.SelectParticipantsToRefresh(context.Background(), now, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ParticipantStruct{{ID: testParticipantGuid, CampaignName: campaignName, ScpName: "GitHub", LoginName: "myLogin"}}, participants)
 }
 
-func TestUpdateBugError(t *testing.T) {
+func TestUpdateParticipantContact(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	bug := types.BugStruct{}
-	forcedError := fmt.Errorf("forced update bug error")
-	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateBug)).
-		WithArgs(bug.PointValue, bug.Campaign, bug.Category).
+	email := "me@example.com"
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateParticipantContact)).
+		WithArgs(campaignName, "myScpName", "myLoginName", email, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.UpdateParticipantContact(context.Background(), "myScpName", "myLoginName",
+		&types.ParticipantProfileRequest{CampaignName: campaignName, Email: &email})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+}
+
+func TestSearchParticipantsError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced search error")
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSearchParticipants)).
+		WithArgs("foo", "foo", 10).
 		WillReturnError(forcedError)
 
-	rowsAffected, err := db.UpdateBug(&bug)
+	participants, err := db.SearchParticipants(context.Background(), "foo", 10)
 	assert.EqualError(t, err, forcedError.Error())
-	assert.Equal(t, int64(0), rowsAffected)
+	assert.Nil(t, participants)
 }
 
-func TestUpdateBug(t *testing.T) {
+func TestSearchParticipantsEscapesWildcards(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	bug := types.BugStruct{
-		// empty Id before insert
-		Campaign:   campaignName,
-		Category:   bugCategory,
-		PointValue: 5,
-	}
-	mock.ExpectExec(convertSqlToDbMockExpect(sqlUpdateBug)).
-		WithArgs(bug.PointValue, bug.Campaign, bug.Category).
-		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSearchParticipants)).
+		WithArgs(`100\%\_done`, `100%_done`, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"guid"}))
 
-	rowsAffected, err := db.UpdateBug(&bug)
+	participants, err := db.SearchParticipants(context.Background(), `100%_done`, 10)
 	assert.NoError(t, err)
-	assert.Equal(t, int64(1), rowsAffected)
+	assert.Nil(t, participants)
 }
 
-func TestSelectBugsError(t *testing.T) {
+func TestSearchParticipants(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	forcedError := fmt.Errorf("forced select bugs error")
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectBugs)).
-		WillReturnError(forcedError)
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSearchParticipants)).
+		WithArgs("foo", "foo", 10).
+		WillReturnRows(sqlmock.NewRows([]string{"guid", "campaign", "scp", "login", "email", "display", "score", "team", "joinedAt", "avatar"}).
+			AddRow(testParticipantGuid, campaignName, "GitHub", "foo", "email", "display", 3, sql.NullString{}, now, "").
+			AddRow("otherGuid", "otherCampaign", "GitLab", "FooBar", "email", "display", 1, "teamName", now, "avatarUrl"))
 
-	bugs, err := db.SelectBugs()
-	assert.EqualError(t, err, forcedError.Error())
-	assert.Equal(t, ([]types.BugStruct)(nil), bugs)
+	participants, err := db.SearchParticipants(context.Background(), "foo", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ParticipantStruct{
+		{ID: testParticipantGuid, CampaignName: campaignName, ScpName: "GitHub", LoginName: "foo", Email: "email", DisplayName: "display", Score: 3, JoinedAt: now},
+		{ID: "otherGuid", CampaignName: "otherCampaign", ScpName: "GitLab", LoginName: "FooBar", Email: "email", DisplayName: "display", Score: 1, TeamName: "teamName", JoinedAt: now, AvatarUrl: "avatarUrl"},
+	}, participants)
 }
 
-func TestSelectBugsScanError(t *testing.T) {
+func TestBackupBeginError(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectBugs)).
-		// force scan error with invalid column count
-		WillReturnRows(sqlmock.NewRows([]string{"badColumn"}).AddRow(-1))
+	forcedError := fmt.Errorf("forced begin error")
+	mock.ExpectBegin().WillReturnError(forcedError)
 
-	bugs, err := db.SelectBugs()
-	assert.EqualError(t, err, "sql: expected 1 destination arguments in Scan, not 4")
-	assert.Equal(t, ([]types.BugStruct)(nil), bugs)
+	_, err := db.Backup(context.Background(), func(table string) (io.Writer, error) {
+		assert.Fail(t, "unexpected table", table)
+		return nil, nil
+	})
+	assert.EqualError(t, err, forcedError.Error())
 }
 
-func TestSelectBugs(t *testing.T) {
+func TestBackup(t *testing.T) {
 	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	bug := types.BugStruct{
-		// empty Id before insert
-		Campaign:   campaignName,
-		Category:   bugCategory,
-		PointValue: 5,
+	mock.ExpectBegin()
+	for _, table := range backupTables {
+		rows := sqlmock.NewRows([]string{"row_to_json"})
+		if table == "campaign" {
+			rows.AddRow([]byte(`{"name":"myCampaign"}`)).AddRow([]byte(`{"name":"otherCampaign"}`))
+		}
+		mock.ExpectQuery(convertSqlToDbMockExpect(sqlBackupTable(table))).WillReturnRows(rows)
 	}
-	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectBugs)).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "campiagn", "category", "pointValue"}).
-			AddRow(bug.Id, bug.Campaign, bug.Category, bug.PointValue))
+	mock.ExpectRollback()
 
-	bugs, err := db.SelectBugs()
+	written := map[string]*bytes.Buffer{}
+	rowCounts, err := db.Backup(context.Background(), func(table string) (io.Writer, error) {
+		written[table] = &bytes.Buffer{}
+		return written[table], nil
+	})
 	assert.NoError(t, err)
-	assert.Equal(t, []types.BugStruct{bug}, bugs)
+	assert.Equal(t, map[string]int64{"campaign": 2}, rowCounts)
+	assert.Equal(t, len(backupTables), len(written))
+	assert.Equal(t, "{\"name\":\"myCampaign\"}\n{\"name\":\"otherCampaign\"}\n", written["campaign"].String())
+	assert.Equal(t, "", written["scoring_event"].String())
 }
 
-func TestGetDb(t *testing.T) {
-	_, dbFake, closeDbFunc := SetupMockDB(t)
+func TestBackupTables(t *testing.T) {
+	// every table the migrations leave is backed up, or left out on purpose
+	created := regexp.MustCompile(`(?i)CREATE (?:TABLE|MATERIALIZED VIEW) (\w+)`)
+	dropped := regexp.MustCompile(`(?i)DROP (?:TABLE|MATERIALIZED VIEW) (?:IF EXISTS )?(\w+)`)
+	upFiles, err := fs.Glob(migrations, migrationsPath+"/*.up.sql")
+	assert.NoError(t, err)
+	tables := map[string]bool{}
+	for _, upFile := range upFiles {
+		sqlUp, err := fs.ReadFile(migrations, upFile)
+		assert.NoError(t, err)
+		for _, match := range created.FindAllStringSubmatch(string(sqlUp), -1) {
+			tables[match[1]] = true
+		}
+		for _, match := range dropped.FindAllStringSubmatch(string(sqlUp), -1) {
+			delete(tables, match[1])
+		}
+	}
+	backedUp := map[string]bool{}
+	for _, table := range backupTables {
+		backedUp[table] = true
+		assert.True(t, tables[table], table)
+	}
+	for table := range tables {
+		_, excluded := BackupExcludedTables[table]
+		assert.True(t, backedUp[table] != excluded, table)
+	}
+}
+
+func TestBackupOpenError(t *testing.T) {
+	mock, db, closeDbFunc := SetupMockDB(t)
 	defer closeDbFunc()
 
-	assert.NotNil(t, dbFake.GetDb())
-	assert.NotNil(t, dbFake.logger)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	forcedError := fmt.Errorf("forced open error")
+	_, err := db.Backup(context.Background(), func(table string) (io.Writer, error) {
+		return nil, forcedError
+	})
+	assert.EqualError(t, err, forcedError.Error())
 }
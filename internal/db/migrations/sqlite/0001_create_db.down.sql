@@ -0,0 +1,29 @@
+BEGIN;
+
+DROP TABLE leaderboard_snapshot_entry;
+DROP TABLE leaderboard_snapshot;
+DROP TABLE leaderboard_rank;
+DROP TABLE point_value_override;
+DROP TABLE campaign_penalty;
+DROP TABLE audit_log;
+DROP TABLE telemetry_events;
+DROP TABLE achievement;
+DROP TABLE campaign_email;
+DROP TABLE webhook;
+DROP TABLE slack_notification;
+DROP TABLE campaign_report;
+DROP TABLE team_score_event;
+DROP TABLE notification;
+DROP TABLE campaign_config_stage;
+DROP TABLE cluster_instance;
+DROP TABLE scoring_event_category;
+DROP TABLE scoring_event;
+DROP TABLE participant;
+DROP TABLE bug;
+DROP TABLE team;
+DROP TABLE organization;
+DROP TABLE source_control_provider;
+DROP TABLE campaign;
+DROP TABLE tenant;
+
+COMMIT;
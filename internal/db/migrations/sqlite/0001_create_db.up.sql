@@ -0,0 +1,327 @@
+-- the schema of migrations/v2, as of 0035, adapted to SQLite for local development and demos. The later migrations
+-- here follow those of migrations/v2 from 0036. Like those, each migration begins and commits its own transaction, as
+-- SQLite only turns foreign keys off outside of one.
+--
+-- ids are uuids made by the caller. Timestamps are UTC, written as text of a fixed width so they sort as they compare.
+-- Points are NUMERIC, and json is TEXT.
+
+BEGIN;
+
+CREATE TABLE tenant
+(
+    Id                  TEXT PRIMARY KEY NOT NULL,
+    name                varchar(100) NOT NULL UNIQUE CHECK (name <> ''),
+    admin_username      varchar(250) NOT NULL CHECK (admin_username <> ''),
+    -- bcrypt hash of the admin password
+    admin_password_hash TEXT         NOT NULL,
+    created_on          timestamp    NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now'))
+);
+
+CREATE TABLE campaign
+(
+    Id                     TEXT PRIMARY KEY NOT NULL,
+    name                   varchar(250) NOT NULL UNIQUE CHECK (name <> ''),
+    created_on             timestamp    NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now')),
+    create_order           INTEGER      NOT NULL,
+    start_on               timestamp    NOT NULL,
+    end_on                 timestamp    NOT NULL,
+    note                   TEXT,
+    max_team_size          INTEGER      NOT NULL DEFAULT 0 CHECK (max_team_size >= 0),
+    updated_on             timestamp    NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now')),
+    ended_notified_on      timestamp,
+    tie_breaker            varchar(32)  NOT NULL DEFAULT 'reachedScore'
+        CHECK (tie_breaker IN ('reachedScore', 'bugCategories', 'joinedAt')),
+    unclassified_bonus     NUMERIC      NOT NULL DEFAULT 1 CHECK (unclassified_bonus >= 0),
+    fk_tenant              TEXT REFERENCES tenant (Id),
+    -- kept for the api, as the timestamps of a campaign are stored in UTC
+    time_zone              varchar(64)  NOT NULL DEFAULT 'UTC',
+    status                 varchar(10)  NOT NULL DEFAULT 'upcoming'
+        CHECK (status IN ('upcoming', 'active', 'ended')),
+    freeze_scoring         BOOLEAN      NOT NULL DEFAULT FALSE,
+    registration_opens_on  timestamp,
+    registration_closes_on timestamp
+);
+CREATE INDEX idx_campaign_fk_tenant ON campaign (fk_tenant);
+
+CREATE TABLE source_control_provider
+(
+    Id   TEXT PRIMARY KEY NOT NULL,
+    name TEXT UNIQUE NOT NULL CHECK (name <> ''),
+    url  TEXT UNIQUE NOT NULL
+);
+INSERT INTO source_control_provider (Id, name, url)
+VALUES ('6f1d8ee2-6a5d-4b5e-9c1a-3e0e4d1f6a01', 'GitHub', 'https://github.com'),
+       ('6f1d8ee2-6a5d-4b5e-9c1a-3e0e4d1f6a02', 'GitLab', 'https://gitlab.com'),
+       ('6f1d8ee2-6a5d-4b5e-9c1a-3e0e4d1f6a03', 'Bitbucket', 'https://bitbucket.org/');
+
+CREATE TABLE organization
+(
+    Id           TEXT PRIMARY KEY NOT NULL,
+    fk_scp       TEXT NOT NULL REFERENCES source_control_provider (Id),
+    Organization TEXT NOT NULL CHECK (Organization <> ''),
+    fk_campaign  TEXT REFERENCES campaign (Id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX organization_global_key ON organization (fk_scp, Organization) WHERE fk_campaign IS NULL;
+CREATE UNIQUE INDEX organization_campaign_key ON organization (fk_scp, Organization, fk_campaign) WHERE fk_campaign IS NOT NULL;
+
+CREATE TABLE team
+(
+    Id          TEXT PRIMARY KEY NOT NULL,
+    fk_campaign TEXT         NOT NULL REFERENCES campaign (Id),
+    name        varchar(250) NOT NULL CHECK (name <> ''),
+    updated_on  timestamp    NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now')),
+    UNIQUE (fk_campaign, name)
+);
+
+CREATE TABLE bug
+(
+    Id          TEXT PRIMARY KEY NOT NULL,
+    fk_campaign TEXT         NOT NULL REFERENCES campaign (Id),
+    category    varchar(255) NOT NULL CHECK (category <> ''),
+    pointValue  NUMERIC      NOT NULL,
+    updated_on  timestamp    NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now')),
+    UNIQUE (fk_campaign, category)
+);
+
+CREATE TABLE participant
+(
+    Id                   TEXT PRIMARY KEY NOT NULL,
+    fk_campaign          TEXT         NOT NULL REFERENCES campaign (Id),
+    fk_scp               TEXT         NOT NULL REFERENCES source_control_provider (Id),
+    login_name           varchar(250) NOT NULL CHECK (login_name <> ''),
+    Email                varchar(250),
+    DisplayName          varchar(250),
+    Score                NUMERIC,
+    fk_team              TEXT REFERENCES team (Id),
+    JoinedAt             timestamp    NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now')),
+    captain              BOOLEAN      NOT NULL DEFAULT FALSE,
+    updated_on           timestamp    NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now')),
+    avatar_url           varchar(2048),
+    profile_refreshed_on timestamp,
+    UNIQUE (fk_campaign, fk_scp, login_name)
+);
+CREATE UNIQUE INDEX participant_team_captain ON participant (fk_team) WHERE captain;
+
+-- updated_on is changed by any update that does not set it
+CREATE TRIGGER campaign_updated_on AFTER UPDATE ON campaign FOR EACH ROW WHEN NEW.updated_on IS OLD.updated_on
+BEGIN
+    UPDATE campaign SET updated_on = strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now') WHERE Id = NEW.Id;
+END;
+CREATE TRIGGER bug_updated_on AFTER UPDATE ON bug FOR EACH ROW WHEN NEW.updated_on IS OLD.updated_on
+BEGIN
+    UPDATE bug SET updated_on = strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now') WHERE Id = NEW.Id;
+END;
+CREATE TRIGGER team_updated_on AFTER UPDATE ON team FOR EACH ROW WHEN NEW.updated_on IS OLD.updated_on
+BEGIN
+    UPDATE team SET updated_on = strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now') WHERE Id = NEW.Id;
+END;
+CREATE TRIGGER participant_updated_on AFTER UPDATE ON participant FOR EACH ROW WHEN NEW.updated_on IS OLD.updated_on
+BEGIN
+    UPDATE participant SET updated_on = strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now') WHERE Id = NEW.Id;
+END;
+
+CREATE TABLE scoring_event
+(
+    Id          TEXT    NOT NULL UNIQUE,
+    fk_campaign TEXT    NOT NULL REFERENCES campaign (Id),
+    fk_scp      TEXT REFERENCES source_control_provider (Id),
+    repoOwner   TEXT    NOT NULL CHECK (repoOwner <> ''),
+    repoName    TEXT    NOT NULL CHECK (repoName <> ''),
+    pr          INTEGER,
+    username    TEXT    NOT NULL,
+    points      NUMERIC NOT NULL,
+    breakdown   TEXT,
+    -- the classified and unclassified bugs of the breakdown, which postgres reads from the json
+    bugs_fixed  NUMERIC NOT NULL DEFAULT 0,
+    scored_on   timestamp DEFAULT (strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now')),
+    voided_on   timestamp,
+    PRIMARY KEY (fk_campaign, fk_scp, repoOwner, repoName, pr)
+);
+CREATE INDEX scoring_event_lower_repo_owner ON scoring_event (fk_scp, LOWER(repoOwner));
+
+-- the categories of the breakdown of each scoring event, which postgres reads from the json
+CREATE TABLE scoring_event_category
+(
+    fk_scoring_event TEXT    NOT NULL REFERENCES scoring_event (Id) ON DELETE CASCADE,
+    category         TEXT    NOT NULL,
+    count            NUMERIC NOT NULL,
+    points           NUMERIC NOT NULL
+);
+CREATE INDEX scoring_event_category_event ON scoring_event_category (fk_scoring_event);
+
+CREATE TABLE cluster_instance
+(
+    instance_id    varchar(255) PRIMARY KEY NOT NULL,
+    version        varchar(255)             NOT NULL,
+    role           varchar(50)              NOT NULL,
+    poll_leader    BOOLEAN                  NOT NULL DEFAULT FALSE,
+    started_on     timestamp                NOT NULL,
+    last_heartbeat timestamp                NOT NULL
+);
+
+CREATE TABLE campaign_config_stage
+(
+    fk_campaign TEXT PRIMARY KEY NOT NULL REFERENCES campaign (Id),
+    config      TEXT      NOT NULL,
+    updated_on  timestamp NOT NULL
+);
+
+CREATE TABLE notification
+(
+    Id             TEXT PRIMARY KEY NOT NULL,
+    fk_participant TEXT        NOT NULL REFERENCES participant (Id) ON DELETE CASCADE,
+    kind           varchar(50) NOT NULL,
+    message        TEXT        NOT NULL,
+    created_on     timestamp   NOT NULL,
+    read_on        timestamp
+);
+CREATE INDEX notification_participant ON notification (fk_participant, created_on);
+
+CREATE TABLE team_score_event
+(
+    Id         TEXT PRIMARY KEY NOT NULL,
+    fk_team    TEXT         NOT NULL REFERENCES team (Id) ON DELETE CASCADE,
+    kind       varchar(50)  NOT NULL,
+    category   varchar(250) NOT NULL CHECK (category <> ''),
+    points     NUMERIC      NOT NULL,
+    reason     TEXT         NOT NULL CHECK (reason <> ''),
+    created_on timestamp    NOT NULL
+);
+
+CREATE TABLE campaign_report
+(
+    fk_campaign TEXT PRIMARY KEY NOT NULL REFERENCES campaign (Id) ON DELETE CASCADE,
+    report      TEXT      NOT NULL,
+    html        TEXT      NOT NULL,
+    created_on  timestamp NOT NULL
+);
+
+CREATE TABLE slack_notification
+(
+    fk_campaign     TEXT PRIMARY KEY NOT NULL REFERENCES campaign (Id) ON DELETE CASCADE,
+    webhook_url     VARCHAR(2048) NOT NULL,
+    -- post when a participant reaches this many points, 0 for never
+    point_threshold INTEGER       NOT NULL DEFAULT 0,
+    -- post when a participant takes the lead of the campaign
+    notify_lead     BOOLEAN       NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE webhook
+(
+    Id         TEXT PRIMARY KEY NOT NULL,
+    target_url VARCHAR(2048) NOT NULL,
+    secret     VARCHAR(250)  NOT NULL,
+    -- json array of the event names subscribed to, empty for all events
+    events     TEXT          NOT NULL DEFAULT '[]',
+    created_on timestamp     NOT NULL
+);
+
+CREATE TABLE campaign_email
+(
+    fk_campaign TEXT        NOT NULL REFERENCES campaign (Id) ON DELETE CASCADE,
+    kind        VARCHAR(50) NOT NULL,
+    enabled     BOOLEAN     NOT NULL DEFAULT TRUE,
+    subject     TEXT        NOT NULL,
+    body        TEXT        NOT NULL,
+    PRIMARY KEY (fk_campaign, kind)
+);
+
+CREATE TABLE achievement
+(
+    fk_participant TEXT        NOT NULL REFERENCES participant (Id) ON DELETE CASCADE,
+    kind           varchar(50) NOT NULL,
+    awarded_on     timestamp   NOT NULL,
+    PRIMARY KEY (fk_participant, kind)
+);
+
+CREATE TABLE telemetry_events
+(
+    Id         TEXT PRIMARY KEY NOT NULL,
+    feature    varchar(250) NOT NULL,
+    call       varchar(250) NOT NULL,
+    created_on timestamp    NOT NULL
+);
+CREATE INDEX telemetry_events_created_on ON telemetry_events (created_on);
+
+CREATE TABLE audit_log
+(
+    Id         TEXT PRIMARY KEY NOT NULL,
+    actor      varchar(250)  NOT NULL,
+    method     varchar(10)   NOT NULL,
+    path       varchar(2048) NOT NULL,
+    route      varchar(2048) NOT NULL,
+    status     INTEGER       NOT NULL,
+    payload    TEXT          NOT NULL,
+    created_on timestamp     NOT NULL
+);
+CREATE INDEX audit_log_created_on ON audit_log (created_on);
+
+CREATE TABLE campaign_penalty
+(
+    fk_campaign TEXT PRIMARY KEY NOT NULL REFERENCES campaign (Id) ON DELETE CASCADE,
+    enabled     BOOLEAN NOT NULL DEFAULT FALSE,
+    -- subtracted for each introduced bug
+    point_value NUMERIC NOT NULL DEFAULT 1 CHECK (point_value >= 0),
+    -- keep the points of a pull request from going below zero
+    floor       BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE TABLE point_value_override
+(
+    Id              TEXT PRIMARY KEY NOT NULL,
+    fk_campaign     TEXT         NOT NULL REFERENCES campaign (Id) ON DELETE CASCADE,
+    fk_organization TEXT         NOT NULL REFERENCES organization (Id) ON DELETE CASCADE,
+    category        VARCHAR(255) NOT NULL CHECK (category <> ''),
+    point_value     NUMERIC      NOT NULL CHECK (point_value >= 0),
+    UNIQUE (fk_campaign, fk_organization, category)
+);
+
+-- a table rather than a materialized view, rewritten by each refresh of the leaderboard
+CREATE TABLE leaderboard_rank
+(
+    fk_participant         TEXT PRIMARY KEY NOT NULL,
+    fk_campaign            TEXT      NOT NULL,
+    scp_name               TEXT      NOT NULL,
+    login_name             TEXT      NOT NULL,
+    display_name           TEXT      NOT NULL,
+    avatar_url             TEXT      NOT NULL,
+    team_name              TEXT,
+    score                  NUMERIC   NOT NULL,
+    rank                   INTEGER   NOT NULL,
+    refreshed_on           timestamp NOT NULL,
+    participant_updated_on timestamp NOT NULL,
+    team_updated_on        timestamp,
+    campaign_updated_on    timestamp NOT NULL
+);
+CREATE INDEX leaderboard_rank_campaign_rank ON leaderboard_rank (fk_campaign, rank);
+
+CREATE TABLE leaderboard_snapshot
+(
+    fk_campaign  TEXT PRIMARY KEY NOT NULL REFERENCES campaign (Id) ON DELETE CASCADE,
+    tie_breaker  varchar(32) NOT NULL,
+    finalized_on timestamp   NOT NULL
+);
+
+CREATE TABLE leaderboard_snapshot_entry
+(
+    fk_campaign  TEXT    NOT NULL REFERENCES leaderboard_snapshot (fk_campaign) ON DELETE CASCADE,
+    rank         INTEGER NOT NULL,
+    scp_name     TEXT    NOT NULL,
+    login_name   TEXT    NOT NULL,
+    display_name TEXT    NOT NULL,
+    avatar_url   TEXT    NOT NULL,
+    team_name    TEXT    NOT NULL,
+    score        NUMERIC NOT NULL,
+    PRIMARY KEY (fk_campaign, rank)
+);
+
+CREATE TRIGGER leaderboard_snapshot_immutable BEFORE UPDATE ON leaderboard_snapshot
+BEGIN
+    SELECT RAISE(ABORT, 'leaderboard_snapshot rows cannot be changed');
+END;
+CREATE TRIGGER leaderboard_snapshot_entry_immutable BEFORE UPDATE ON leaderboard_snapshot_entry
+BEGIN
+    SELECT RAISE(ABORT, 'leaderboard_snapshot_entry rows cannot be changed');
+END;
+
+COMMIT;
@@ -0,0 +1,99 @@
+-- SQLite can not drop a column, so each table is copied into one without it. Foreign keys are off while the tables
+-- referencing it point at the copy, and can only be turned off outside a transaction.
+PRAGMA foreign_keys = OFF;
+
+BEGIN;
+
+CREATE TABLE campaign_unversioned
+(
+    Id                     TEXT PRIMARY KEY NOT NULL,
+    name                   varchar(250) NOT NULL UNIQUE CHECK (name <> ''),
+    created_on             timestamp    NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now')),
+    create_order           INTEGER      NOT NULL,
+    start_on               timestamp    NOT NULL,
+    end_on                 timestamp    NOT NULL,
+    note                   TEXT,
+    max_team_size          INTEGER      NOT NULL DEFAULT 0 CHECK (max_team_size >= 0),
+    updated_on             timestamp    NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now')),
+    ended_notified_on      timestamp,
+    tie_breaker            varchar(32)  NOT NULL DEFAULT 'reachedScore'
+        CHECK (tie_breaker IN ('reachedScore', 'bugCategories', 'joinedAt')),
+    unclassified_bonus     NUMERIC      NOT NULL DEFAULT 1 CHECK (unclassified_bonus >= 0),
+    fk_tenant              TEXT REFERENCES tenant (Id),
+    -- kept for the api, as the timestamps of a campaign are stored in UTC
+    time_zone              varchar(64)  NOT NULL DEFAULT 'UTC',
+    status                 varchar(10)  NOT NULL DEFAULT 'upcoming'
+        CHECK (status IN ('upcoming', 'active', 'ended')),
+    freeze_scoring         BOOLEAN      NOT NULL DEFAULT FALSE,
+    registration_opens_on  timestamp,
+    registration_closes_on timestamp
+);
+INSERT INTO campaign_unversioned (Id, name, created_on, create_order, start_on, end_on, note, max_team_size, updated_on,
+                                  ended_notified_on, tie_breaker, unclassified_bonus, fk_tenant, time_zone, status,
+                                  freeze_scoring, registration_opens_on, registration_closes_on)
+SELECT Id, name, created_on, create_order, start_on, end_on, note, max_team_size, updated_on, ended_notified_on,
+       tie_breaker, unclassified_bonus, fk_tenant, time_zone, status, freeze_scoring, registration_opens_on,
+       registration_closes_on
+FROM campaign;
+DROP TABLE campaign;
+ALTER TABLE campaign_unversioned RENAME TO campaign;
+CREATE INDEX idx_campaign_fk_tenant ON campaign (fk_tenant);
+
+CREATE TABLE bug_unversioned
+(
+    Id          TEXT PRIMARY KEY NOT NULL,
+    fk_campaign TEXT         NOT NULL REFERENCES campaign (Id),
+    category    varchar(255) NOT NULL CHECK (category <> ''),
+    pointValue  NUMERIC      NOT NULL,
+    updated_on  timestamp    NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now')),
+    UNIQUE (fk_campaign, category)
+);
+INSERT INTO bug_unversioned (Id, fk_campaign, category, pointValue, updated_on)
+SELECT Id, fk_campaign, category, pointValue, updated_on
+FROM bug;
+DROP TABLE bug;
+ALTER TABLE bug_unversioned RENAME TO bug;
+
+CREATE TABLE participant_unversioned
+(
+    Id                   TEXT PRIMARY KEY NOT NULL,
+    fk_campaign          TEXT         NOT NULL REFERENCES campaign (Id),
+    fk_scp               TEXT         NOT NULL REFERENCES source_control_provider (Id),
+    login_name           varchar(250) NOT NULL CHECK (login_name <> ''),
+    Email                varchar(250),
+    DisplayName          varchar(250),
+    Score                NUMERIC,
+    fk_team              TEXT REFERENCES team (Id),
+    JoinedAt             timestamp    NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now')),
+    captain              BOOLEAN      NOT NULL DEFAULT FALSE,
+    updated_on           timestamp    NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now')),
+    avatar_url           varchar(2048),
+    profile_refreshed_on timestamp,
+    UNIQUE (fk_campaign, fk_scp, login_name)
+);
+INSERT INTO participant_unversioned (Id, fk_campaign, fk_scp, login_name, Email, DisplayName, Score, fk_team, JoinedAt,
+                                     captain, updated_on, avatar_url, profile_refreshed_on)
+SELECT Id, fk_campaign, fk_scp, login_name, Email, DisplayName, Score, fk_team, JoinedAt, captain, updated_on,
+       avatar_url, profile_refreshed_on
+FROM participant;
+DROP TABLE participant;
+ALTER TABLE participant_unversioned RENAME TO participant;
+CREATE UNIQUE INDEX participant_team_captain ON participant (fk_team) WHERE captain;
+
+-- dropped along with the tables
+CREATE TRIGGER campaign_updated_on AFTER UPDATE ON campaign FOR EACH ROW WHEN NEW.updated_on IS OLD.updated_on
+BEGIN
+    UPDATE campaign SET updated_on = strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now') WHERE Id = NEW.Id;
+END;
+CREATE TRIGGER bug_updated_on AFTER UPDATE ON bug FOR EACH ROW WHEN NEW.updated_on IS OLD.updated_on
+BEGIN
+    UPDATE bug SET updated_on = strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now') WHERE Id = NEW.Id;
+END;
+CREATE TRIGGER participant_updated_on AFTER UPDATE ON participant FOR EACH ROW WHEN NEW.updated_on IS OLD.updated_on
+BEGIN
+    UPDATE participant SET updated_on = strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now') WHERE Id = NEW.Id;
+END;
+
+COMMIT;
+
+PRAGMA foreign_keys = ON;
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- counts the updates of each campaign, bug and participant, so an update made from an older version is refused
+ALTER TABLE campaign ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE bug ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE participant ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+
+-- a login of a source control provider kept out of every campaign, stored in lower case
+CREATE TABLE ban
+(
+    Id         TEXT PRIMARY KEY NOT NULL,
+    fk_scp     TEXT         NOT NULL REFERENCES source_control_provider (Id),
+    login_name VARCHAR(250) NOT NULL CHECK (login_name <> ''),
+    reason     VARCHAR(250) NOT NULL DEFAULT '',
+    banned_on  timestamp    NOT NULL,
+    UNIQUE (fk_scp, login_name)
+);
+
+COMMIT;
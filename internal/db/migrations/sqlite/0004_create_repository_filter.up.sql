@@ -0,0 +1,12 @@
+BEGIN;
+
+CREATE TABLE repository_filter
+(
+    Id          TEXT PRIMARY KEY NOT NULL,
+    fk_campaign TEXT         NOT NULL REFERENCES campaign (Id) ON DELETE CASCADE,
+    kind        VARCHAR(5)   NOT NULL CHECK (kind IN ('allow', 'deny')),
+    pattern     VARCHAR(250) NOT NULL CHECK (pattern <> ''),
+    UNIQUE (fk_campaign, kind, pattern)
+);
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+CREATE TABLE bug_category_exclusion
+(
+    Id          TEXT PRIMARY KEY NOT NULL,
+    fk_campaign TEXT         NOT NULL REFERENCES campaign (Id) ON DELETE CASCADE,
+    category    VARCHAR(255) NOT NULL CHECK (category <> ''),
+    UNIQUE (fk_campaign, category)
+);
+
+COMMIT;
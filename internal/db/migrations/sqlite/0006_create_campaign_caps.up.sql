@@ -0,0 +1,12 @@
+BEGIN;
+
+CREATE TABLE campaign_caps
+(
+    fk_campaign      TEXT PRIMARY KEY NOT NULL REFERENCES campaign (Id) ON DELETE CASCADE,
+    per_pull_request NUMERIC NOT NULL DEFAULT 0 CHECK (per_pull_request >= 0),
+    per_day          NUMERIC NOT NULL DEFAULT 0 CHECK (per_day >= 0)
+);
+
+CREATE INDEX scoring_event_username_scored_on ON scoring_event (fk_campaign, fk_scp, username, scored_on);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX scoring_event_scored_on;
+DROP TABLE scoring_flag;
+
+COMMIT;
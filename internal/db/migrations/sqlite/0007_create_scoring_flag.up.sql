@@ -0,0 +1,16 @@
+BEGIN;
+
+-- a scoring event the anomaly job flagged for review, until an admin approves or voids it
+CREATE TABLE scoring_flag
+(
+    Id               TEXT PRIMARY KEY NOT NULL,
+    fk_scoring_event TEXT      NOT NULL REFERENCES scoring_event (Id) ON DELETE CASCADE,
+    reason           TEXT      NOT NULL,
+    flagged_on       timestamp NOT NULL,
+    resolved_on      timestamp,
+    resolution       TEXT,
+    UNIQUE (fk_scoring_event, reason)
+);
+CREATE INDEX scoring_event_scored_on ON scoring_event (scored_on);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE campaign_duplicate_policy;
+DROP TABLE scoring_event_fingerprint;
+
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+
+-- the fixed bugs of a scoring event, kept from when the event first fixed them
+CREATE TABLE scoring_event_fingerprint
+(
+    fk_scoring_event TEXT      NOT NULL REFERENCES scoring_event (Id) ON DELETE CASCADE,
+    fingerprint      TEXT      NOT NULL,
+    fixed_on         timestamp NOT NULL,
+    PRIMARY KEY (fk_scoring_event, fingerprint)
+);
+CREATE INDEX scoring_event_fingerprint_fingerprint ON scoring_event_fingerprint (fingerprint);
+
+CREATE TABLE campaign_duplicate_policy
+(
+    fk_campaign TEXT PRIMARY KEY NOT NULL REFERENCES campaign (Id) ON DELETE CASCADE,
+    policy      TEXT    NOT NULL CHECK (policy IN ('skip', 'downweight', 'score')),
+    weight      NUMERIC NOT NULL DEFAULT 0 CHECK (weight >= 0 AND weight <= 1)
+);
+
+COMMIT;
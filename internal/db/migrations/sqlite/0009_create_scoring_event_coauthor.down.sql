@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE campaign_coauthor_policy;
+DROP TABLE scoring_event_coauthor;
+
+COMMIT;
@@ -0,0 +1,18 @@
+BEGIN;
+
+-- the points each co-author of the pull request of a scoring event scored, besides its username
+CREATE TABLE scoring_event_coauthor
+(
+    fk_scoring_event TEXT    NOT NULL REFERENCES scoring_event (Id) ON DELETE CASCADE,
+    username         TEXT    NOT NULL,
+    points           NUMERIC NOT NULL,
+    PRIMARY KEY (fk_scoring_event, username)
+);
+
+CREATE TABLE campaign_coauthor_policy
+(
+    fk_campaign TEXT PRIMARY KEY NOT NULL REFERENCES campaign (Id) ON DELETE CASCADE,
+    policy      TEXT NOT NULL CHECK (policy IN ('split', 'duplicate'))
+);
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+CREATE TABLE campaign_privacy
+(
+    fk_campaign TEXT PRIMARY KEY NOT NULL REFERENCES campaign (Id) ON DELETE CASCADE,
+    anonymize   BOOLEAN NOT NULL DEFAULT false,
+    salt        TEXT    NOT NULL
+);
+
+COMMIT;
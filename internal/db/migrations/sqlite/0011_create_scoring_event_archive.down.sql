@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE scoring_event_archive;
+
+COMMIT;
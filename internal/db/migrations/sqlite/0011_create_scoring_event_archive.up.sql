@@ -0,0 +1,13 @@
+BEGIN;
+
+-- the scoring events of an ended campaign, moved out of scoring_event as gzipped newline delimited JSON
+CREATE TABLE scoring_event_archive
+(
+    fk_campaign TEXT PRIMARY KEY NOT NULL REFERENCES campaign (Id) ON DELETE CASCADE,
+    events      BLOB,
+    event_count INTEGER   NOT NULL,
+    archived_on timestamp NOT NULL,
+    restored_on timestamp
+);
+
+COMMIT;
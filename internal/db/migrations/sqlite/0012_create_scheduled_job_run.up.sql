@@ -0,0 +1,14 @@
+BEGIN;
+
+CREATE TABLE scheduled_job_run
+(
+    name        varchar(100) PRIMARY KEY NOT NULL,
+    instance_id varchar(255)             NOT NULL,
+    started_on  timestamp                NOT NULL,
+    finished_on timestamp                NOT NULL,
+    error       TEXT,
+    runs        INTEGER                  NOT NULL,
+    failures    INTEGER                  NOT NULL
+);
+
+COMMIT;
@@ -0,0 +1,17 @@
+BEGIN;
+
+CREATE TABLE job
+(
+    Id          TEXT PRIMARY KEY NOT NULL,
+    kind        varchar(50)  NOT NULL,
+    status      varchar(20)  NOT NULL,
+    instance_id varchar(255) NOT NULL,
+    done        INTEGER      NOT NULL DEFAULT 0,
+    total       INTEGER      NOT NULL DEFAULT 0,
+    result      TEXT,
+    error       TEXT,
+    created_on  timestamp    NOT NULL,
+    finished_on timestamp
+);
+
+COMMIT;
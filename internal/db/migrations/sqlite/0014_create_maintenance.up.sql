@@ -0,0 +1,11 @@
+BEGIN;
+
+-- a single row while maintenance is on
+CREATE TABLE maintenance
+(
+    Id      BOOLEAN PRIMARY KEY NOT NULL DEFAULT TRUE CHECK (Id),
+    message TEXT      NOT NULL,
+    since   timestamp NOT NULL
+);
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+-- a single row, naming the replica that runs the datadog poll until the lease expires
+CREATE TABLE poll_lease
+(
+    Id          BOOLEAN PRIMARY KEY NOT NULL DEFAULT TRUE CHECK (Id),
+    instance_id TEXT      NOT NULL,
+    expires_on  timestamp NOT NULL
+);
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP TRIGGER scoring_event_coauthor_deleted;
+DROP TRIGGER scoring_event_coauthor_revised;
+DROP TRIGGER scoring_event_coauthor_scored;
+DROP TRIGGER scoring_event_revised;
+DROP TRIGGER scoring_event_scored;
+DROP TABLE scoring_event_revision;
+
+COMMIT;
@@ -0,0 +1,72 @@
+BEGIN;
+
+-- every score a scoring event, or the share of one of its co-authors, has had, as in postgres
+CREATE TABLE scoring_event_revision
+(
+    Id               INTEGER PRIMARY KEY,
+    fk_scoring_event TEXT      NOT NULL REFERENCES scoring_event (Id) ON DELETE CASCADE,
+    username         TEXT      NOT NULL,
+    coauthor         BOOLEAN   NOT NULL,
+    points           NUMERIC   NOT NULL,
+    voided           BOOLEAN   NOT NULL DEFAULT FALSE,
+    revised_on       timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now'))
+);
+CREATE INDEX scoring_event_revision_username ON scoring_event_revision (username, revised_on);
+
+CREATE TRIGGER scoring_event_scored AFTER INSERT ON scoring_event FOR EACH ROW
+BEGIN
+    INSERT INTO scoring_event_revision (fk_scoring_event, username, coauthor, points, revised_on)
+    VALUES (NEW.Id, NEW.username, FALSE, NEW.points,
+            COALESCE(NEW.scored_on, strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now')));
+    INSERT INTO scoring_event_revision (fk_scoring_event, username, coauthor, points, voided, revised_on)
+    SELECT NEW.Id, NEW.username, FALSE, 0, TRUE, NEW.voided_on
+    WHERE NEW.voided_on IS NOT NULL;
+END;
+CREATE TRIGGER scoring_event_revised AFTER UPDATE OF points, username, scored_on, voided_on ON scoring_event FOR EACH ROW
+BEGIN
+    INSERT INTO scoring_event_revision (fk_scoring_event, username, coauthor, points, revised_on)
+    SELECT NEW.Id, NEW.username, FALSE, NEW.points,
+           COALESCE(NEW.scored_on, strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now'))
+    WHERE NEW.voided_on IS NULL;
+    INSERT INTO scoring_event_revision (fk_scoring_event, username, coauthor, points, voided, revised_on)
+    SELECT NEW.Id, NEW.username, FALSE, 0, TRUE, NEW.voided_on
+    WHERE NEW.voided_on IS NOT NULL;
+END;
+
+CREATE TRIGGER scoring_event_coauthor_scored AFTER INSERT ON scoring_event_coauthor FOR EACH ROW
+BEGIN
+    INSERT INTO scoring_event_revision (fk_scoring_event, username, coauthor, points, revised_on)
+    SELECT Id, NEW.username, TRUE, NEW.points, COALESCE(scored_on, strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now'))
+    FROM scoring_event
+    WHERE Id = NEW.fk_scoring_event;
+END;
+CREATE TRIGGER scoring_event_coauthor_revised AFTER UPDATE OF points ON scoring_event_coauthor FOR EACH ROW
+BEGIN
+    INSERT INTO scoring_event_revision (fk_scoring_event, username, coauthor, points, revised_on)
+    SELECT Id, NEW.username, TRUE, NEW.points, COALESCE(scored_on, strftime('%Y-%m-%d %H:%M:%f000000+00:00', 'now'))
+    FROM scoring_event
+    WHERE Id = NEW.fk_scoring_event;
+END;
+-- a share deleted with its scoring event has no history to keep
+CREATE TRIGGER scoring_event_coauthor_deleted AFTER DELETE ON scoring_event_coauthor FOR EACH ROW
+BEGIN
+    INSERT INTO scoring_event_revision (fk_scoring_event, username, coauthor, points)
+    SELECT Id, OLD.username, TRUE, 0
+    FROM scoring_event
+    WHERE Id = OLD.fk_scoring_event;
+END;
+
+INSERT INTO scoring_event_revision (fk_scoring_event, username, coauthor, points, revised_on)
+SELECT Id, username, FALSE, points, COALESCE(scored_on, '1970-01-01 00:00:00.000000000+00:00')
+FROM scoring_event;
+INSERT INTO scoring_event_revision (fk_scoring_event, username, coauthor, points, voided, revised_on)
+SELECT Id, username, FALSE, 0, TRUE, voided_on
+FROM scoring_event
+WHERE voided_on IS NOT NULL;
+INSERT INTO scoring_event_revision (fk_scoring_event, username, coauthor, points, revised_on)
+SELECT coauthor.fk_scoring_event, coauthor.username, TRUE, coauthor.points,
+       COALESCE(scoring_event.scored_on, '1970-01-01 00:00:00.000000000+00:00')
+FROM scoring_event_coauthor coauthor
+         INNER JOIN scoring_event ON coauthor.fk_scoring_event = scoring_event.Id;
+
+COMMIT;
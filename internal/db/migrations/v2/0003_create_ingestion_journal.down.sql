@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE ingestion_journal;
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+-- table: ingestion_journal
+-- raw scoring messages are written here before processing, and acknowledged once processed
+CREATE TABLE ingestion_journal
+(
+    Id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    log_id       TEXT      NOT NULL,
+    received_on  timestamp NOT NULL DEFAULT NOW(),
+    raw_message  JSONB     NOT NULL,
+    processed_on timestamp
+);
+CREATE INDEX idx_ingestion_journal_unprocessed ON ingestion_journal (received_on) WHERE processed_on IS NULL;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE cluster_instance;
+
+COMMIT;
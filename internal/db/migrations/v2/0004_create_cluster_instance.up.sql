@@ -0,0 +1,15 @@
+BEGIN;
+
+-- table: cluster_instance
+-- each running replica upserts its own row on a regular heartbeat
+CREATE TABLE cluster_instance
+(
+    instance_id    varchar(255) PRIMARY KEY NOT NULL,
+    version        varchar(255)             NOT NULL,
+    role           varchar(50)              NOT NULL,
+    poll_leader    BOOLEAN                  NOT NULL DEFAULT FALSE,
+    started_on     timestamp                NOT NULL,
+    last_heartbeat timestamp                NOT NULL
+);
+
+COMMIT;
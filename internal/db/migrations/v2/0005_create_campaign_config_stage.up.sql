@@ -0,0 +1,12 @@
+BEGIN;
+
+-- table: campaign_config_stage
+-- a staged copy of a campaign's scoring configuration, edited separately and promoted to live in one transaction
+CREATE TABLE campaign_config_stage
+(
+    fk_campaign UUID references campaign (Id) PRIMARY KEY NOT NULL,
+    config      JSONB                                     NOT NULL,
+    updated_on  timestamp                                 NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX participant_team_captain;
+
+ALTER TABLE participant DROP COLUMN captain;
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+-- the captain of a team is the participant on that team with captain = TRUE
+ALTER TABLE participant ADD COLUMN captain BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- at most one captain per team
+CREATE UNIQUE INDEX participant_team_captain ON participant (fk_team) WHERE captain;
+
+COMMIT;
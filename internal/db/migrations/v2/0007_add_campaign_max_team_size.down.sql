@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE campaign DROP COLUMN max_team_size;
+
+COMMIT;
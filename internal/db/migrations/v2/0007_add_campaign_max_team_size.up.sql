@@ -0,0 +1,6 @@
+BEGIN;
+
+-- zero means teams in the campaign have no size limit
+ALTER TABLE campaign ADD COLUMN max_team_size INTEGER NOT NULL DEFAULT 0 CHECK (max_team_size >= 0);
+
+COMMIT;
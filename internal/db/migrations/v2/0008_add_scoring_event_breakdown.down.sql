@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE scoring_event DROP COLUMN breakdown;
+
+COMMIT;
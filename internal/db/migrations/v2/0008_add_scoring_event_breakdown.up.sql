@@ -0,0 +1,6 @@
+BEGIN;
+
+-- explains how the points were computed, null for events scored before this column existed
+ALTER TABLE scoring_event ADD COLUMN breakdown JSONB;
+
+COMMIT;
@@ -0,0 +1,17 @@
+BEGIN;
+
+-- table: notification
+-- in-app inbox of events for a participant
+CREATE TABLE notification
+(
+    Id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    fk_participant UUID references participant (Id) ON DELETE CASCADE NOT NULL,
+    kind           varchar(50) NOT NULL,
+    message        TEXT        NOT NULL,
+    created_on     timestamp   NOT NULL,
+    read_on        timestamp
+);
+
+CREATE INDEX notification_participant ON notification (fk_participant, created_on);
+
+COMMIT;
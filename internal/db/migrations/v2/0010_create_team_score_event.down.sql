@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE team_score_event;
+
+COMMIT;
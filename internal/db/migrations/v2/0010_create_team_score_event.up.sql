@@ -0,0 +1,16 @@
+BEGIN;
+
+-- table: team_score_event
+-- points granted directly to a team, like organizer judged awards
+CREATE TABLE team_score_event
+(
+    Id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    fk_team    UUID references team (Id) ON DELETE CASCADE NOT NULL,
+    kind       varchar(50)  NOT NULL,
+    category   varchar(250) NOT NULL CHECK (category <> ''),
+    points     INT          NOT NULL,
+    reason     TEXT         NOT NULL CHECK (reason <> ''),
+    created_on timestamp    NOT NULL
+);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE campaign_report;
+
+ALTER TABLE scoring_event DROP COLUMN scored_on;
+
+COMMIT;
@@ -0,0 +1,17 @@
+BEGIN;
+
+-- when the scoring event was last scored, used for report timelines. null for events scored before this column existed
+ALTER TABLE scoring_event ADD COLUMN scored_on timestamp;
+ALTER TABLE scoring_event ALTER COLUMN scored_on SET DEFAULT NOW();
+
+-- table: campaign_report
+-- the most recently generated end of campaign report, kept for download
+CREATE TABLE campaign_report
+(
+    fk_campaign UUID references campaign (Id) ON DELETE CASCADE PRIMARY KEY NOT NULL,
+    report      JSONB     NOT NULL,
+    html        TEXT      NOT NULL,
+    created_on  timestamp NOT NULL
+);
+
+COMMIT;
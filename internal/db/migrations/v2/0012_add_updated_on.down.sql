@@ -0,0 +1,17 @@
+BEGIN;
+
+DROP TRIGGER participant_updated_on ON participant;
+ALTER TABLE participant DROP COLUMN updated_on;
+
+DROP TRIGGER team_updated_on ON team;
+ALTER TABLE team DROP COLUMN updated_on;
+
+DROP TRIGGER bug_updated_on ON bug;
+ALTER TABLE bug DROP COLUMN updated_on;
+
+DROP TRIGGER campaign_updated_on ON campaign;
+ALTER TABLE campaign DROP COLUMN updated_on;
+
+DROP FUNCTION set_updated_on();
+
+COMMIT;
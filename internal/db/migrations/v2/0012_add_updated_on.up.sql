@@ -0,0 +1,24 @@
+BEGIN;
+
+-- when a row was last changed, so clients polling a list can tell if it changed since they last read it
+CREATE FUNCTION set_updated_on() RETURNS trigger AS
+$$
+BEGIN
+    NEW.updated_on = NOW();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE campaign ADD COLUMN updated_on timestamp NOT NULL DEFAULT NOW();
+CREATE TRIGGER campaign_updated_on BEFORE UPDATE ON campaign FOR EACH ROW EXECUTE PROCEDURE set_updated_on();
+
+ALTER TABLE bug ADD COLUMN updated_on timestamp NOT NULL DEFAULT NOW();
+CREATE TRIGGER bug_updated_on BEFORE UPDATE ON bug FOR EACH ROW EXECUTE PROCEDURE set_updated_on();
+
+ALTER TABLE team ADD COLUMN updated_on timestamp NOT NULL DEFAULT NOW();
+CREATE TRIGGER team_updated_on BEFORE UPDATE ON team FOR EACH ROW EXECUTE PROCEDURE set_updated_on();
+
+ALTER TABLE participant ADD COLUMN updated_on timestamp NOT NULL DEFAULT NOW();
+CREATE TRIGGER participant_updated_on BEFORE UPDATE ON participant FOR EACH ROW EXECUTE PROCEDURE set_updated_on();
+
+COMMIT;
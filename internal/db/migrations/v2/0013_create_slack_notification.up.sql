@@ -0,0 +1,15 @@
+BEGIN;
+
+-- table: slack_notification
+-- the Slack incoming webhook of a campaign, and which score changes are posted to it
+CREATE TABLE slack_notification
+(
+    fk_campaign     UUID references campaign (Id) ON DELETE CASCADE PRIMARY KEY NOT NULL,
+    webhook_url     VARCHAR(2048) NOT NULL,
+    -- post when a participant reaches this many points, 0 for never
+    point_threshold INT           NOT NULL DEFAULT 0,
+    -- post when a participant takes the lead of the campaign
+    notify_lead     BOOLEAN       NOT NULL DEFAULT FALSE
+);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE campaign DROP COLUMN ended_notified_on;
+
+DROP TABLE webhook;
+
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+
+-- table: webhook
+-- an external system subscribed to events, which are posted to its target url signed with its secret
+CREATE TABLE webhook
+(
+    Id         UUID PRIMARY KEY       DEFAULT gen_random_uuid(),
+    target_url VARCHAR(2048) NOT NULL,
+    secret     VARCHAR(250)  NOT NULL,
+    -- json array of the event names subscribed to, empty for all events
+    events     JSONB         NOT NULL DEFAULT '[]'::jsonb,
+    created_on timestamp     NOT NULL DEFAULT NOW()
+);
+
+-- when the campaign ended event was sent, null until then
+ALTER TABLE campaign ADD COLUMN ended_notified_on timestamp;
+-- campaigns that ended before webhooks existed have nothing to send
+UPDATE campaign SET ended_notified_on = end_on WHERE end_on <= NOW();
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+-- table: campaign_email
+-- the email template of a campaign for each kind of participant email, as go text/template subject and body
+CREATE TABLE campaign_email
+(
+    fk_campaign UUID references campaign (Id) ON DELETE CASCADE NOT NULL,
+    kind        VARCHAR(50) NOT NULL,
+    enabled     BOOLEAN     NOT NULL DEFAULT TRUE,
+    subject     TEXT        NOT NULL,
+    body        TEXT        NOT NULL,
+    PRIMARY KEY (fk_campaign, kind)
+);
+
+COMMIT;
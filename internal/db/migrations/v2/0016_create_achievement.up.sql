@@ -0,0 +1,13 @@
+BEGIN;
+
+-- table: achievement
+-- badges earned by a participant, each kind at most once
+CREATE TABLE achievement
+(
+    fk_participant UUID references participant (Id) ON DELETE CASCADE NOT NULL,
+    kind           varchar(50) NOT NULL,
+    awarded_on     timestamp   NOT NULL,
+    PRIMARY KEY (fk_participant, kind)
+);
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+-- table: telemetry_events
+-- use of a UI feature, reported by the caller in query parameters of public endpoints
+CREATE TABLE telemetry_events
+(
+    Id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    feature    varchar(250) NOT NULL,
+    call       varchar(250) NOT NULL,
+    created_on timestamp    NOT NULL
+);
+
+CREATE INDEX telemetry_events_created_on ON telemetry_events (created_on);
+
+COMMIT;
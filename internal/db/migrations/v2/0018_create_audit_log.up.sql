@@ -0,0 +1,19 @@
+BEGIN;
+
+-- table: audit_log
+-- every mutating admin call, who made it and what they sent
+CREATE TABLE audit_log
+(
+    Id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor      varchar(250)  NOT NULL,
+    method     varchar(10)   NOT NULL,
+    path       varchar(2048) NOT NULL,
+    route      varchar(2048) NOT NULL,
+    status     INT           NOT NULL,
+    payload    TEXT          NOT NULL,
+    created_on timestamp     NOT NULL
+);
+
+CREATE INDEX audit_log_created_on ON audit_log (created_on);
+
+COMMIT;
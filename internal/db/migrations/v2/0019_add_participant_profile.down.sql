@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE participant DROP COLUMN profile_refreshed_on;
+ALTER TABLE participant DROP COLUMN avatar_url;
+
+COMMIT;
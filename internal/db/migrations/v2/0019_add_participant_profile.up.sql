@@ -0,0 +1,8 @@
+BEGIN;
+
+-- profile read from the source control provider, so the leaderboard can show avatars
+ALTER TABLE participant ADD COLUMN avatar_url varchar(2048);
+-- when the profile was last read, null if never
+ALTER TABLE participant ADD COLUMN profile_refreshed_on timestamp;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP INDEX participant_login_name_trgm;
+
+COMMIT;
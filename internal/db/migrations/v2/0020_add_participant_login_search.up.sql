@@ -0,0 +1,7 @@
+BEGIN;
+
+-- lets a search for part of a login name use an index
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX participant_login_name_trgm ON participant USING gin (login_name gin_trgm_ops);
+
+COMMIT;
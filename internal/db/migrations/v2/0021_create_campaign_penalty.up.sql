@@ -0,0 +1,15 @@
+BEGIN;
+
+-- table: campaign_penalty
+-- points taken off pull requests that introduce bugs, per campaign
+CREATE TABLE campaign_penalty
+(
+    fk_campaign UUID references campaign (Id) ON DELETE CASCADE PRIMARY KEY NOT NULL,
+    enabled     BOOLEAN NOT NULL DEFAULT FALSE,
+    -- subtracted for each introduced bug
+    point_value INT     NOT NULL DEFAULT 1 CHECK (point_value >= 0),
+    -- keep the points of a pull request from going below zero
+    floor       BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE point_value_override;
+
+COMMIT;
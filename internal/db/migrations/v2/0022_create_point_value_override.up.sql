@@ -0,0 +1,15 @@
+BEGIN;
+
+-- table: point_value_override
+-- replaces the campaign point value of a bug category, for the repositories of one organization
+CREATE TABLE point_value_override
+(
+    Id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    fk_campaign     UUID references campaign (Id) ON DELETE CASCADE NOT NULL,
+    fk_organization UUID references organization (Id) ON DELETE CASCADE NOT NULL,
+    category        VARCHAR(255) NOT NULL CHECK (category <> ''),
+    point_value     INT          NOT NULL CHECK (point_value >= 0),
+    unique (fk_campaign, fk_organization, category)
+);
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+DELETE FROM organization WHERE fk_campaign IS NOT NULL;
+
+DROP INDEX organization_campaign_key;
+DROP INDEX organization_global_key;
+ALTER TABLE organization ADD CONSTRAINT organization_fk_scp_organization_key UNIQUE (fk_scp, Organization);
+
+ALTER TABLE organization DROP COLUMN fk_campaign;
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+-- an organization with a campaign only scores in that campaign, one without scores in every campaign
+ALTER TABLE organization ADD COLUMN fk_campaign UUID references campaign (Id) ON DELETE CASCADE;
+
+ALTER TABLE organization DROP CONSTRAINT organization_fk_scp_organization_key;
+CREATE UNIQUE INDEX organization_global_key ON organization (fk_scp, Organization) WHERE fk_campaign IS NULL;
+CREATE UNIQUE INDEX organization_campaign_key ON organization (fk_scp, Organization, fk_campaign) WHERE fk_campaign IS NOT NULL;
+
+COMMIT;
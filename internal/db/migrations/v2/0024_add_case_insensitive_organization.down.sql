@@ -0,0 +1,8 @@
+BEGIN;
+
+-- the original case of organization names is not restored
+DROP INDEX scoring_event_lower_repo_owner;
+DROP INDEX organization_lower_organization;
+DROP INDEX source_control_provider_lower_name;
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+-- organizations are matched without regard to case, and stored in lower case from now on
+UPDATE organization
+SET Organization = LOWER(Organization)
+WHERE Organization <> LOWER(Organization)
+  AND NOT EXISTS (SELECT other.Id FROM organization other
+                  WHERE other.fk_scp = organization.fk_scp
+                    AND other.fk_campaign IS NOT DISTINCT FROM organization.fk_campaign
+                    AND other.Organization = LOWER(organization.Organization));
+
+CREATE INDEX source_control_provider_lower_name ON source_control_provider (LOWER(name));
+CREATE INDEX organization_lower_organization ON organization (fk_scp, LOWER(Organization));
+CREATE INDEX scoring_event_lower_repo_owner ON scoring_event (fk_scp, LOWER(repoOwner));
+
+COMMIT;
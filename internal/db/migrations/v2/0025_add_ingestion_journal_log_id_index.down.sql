@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP INDEX idx_ingestion_journal_log_id;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+-- a backfill looks up each log entry, to skip the ones already processed
+CREATE INDEX idx_ingestion_journal_log_id ON ingestion_journal (log_id);
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP MATERIALIZED VIEW leaderboard_rank;
+
+COMMIT;
@@ -0,0 +1,27 @@
+BEGIN;
+
+DROP MATERIALIZED VIEW leaderboard_rank;
+CREATE MATERIALIZED VIEW leaderboard_rank AS
+    SELECT participant.Id                                  AS fk_participant,
+           participant.fk_campaign,
+           source_control_provider.name                    AS scp_name,
+           participant.login_name,
+           COALESCE(participant.DisplayName, '')           AS display_name,
+           COALESCE(participant.avatar_url, '')            AS avatar_url,
+           team.name                                       AS team_name,
+           COALESCE(participant.Score, 0)                  AS score,
+           RANK() OVER (PARTITION BY participant.fk_campaign
+               ORDER BY COALESCE(participant.Score, 0) DESC) AS rank,
+           NOW()                                           AS refreshed_on,
+           participant.updated_on                          AS participant_updated_on,
+           team.updated_on                                 AS team_updated_on
+    FROM participant
+             INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+             LEFT JOIN team ON participant.fk_team = team.Id;
+
+CREATE UNIQUE INDEX leaderboard_rank_participant ON leaderboard_rank (fk_participant);
+CREATE INDEX leaderboard_rank_campaign_rank ON leaderboard_rank (fk_campaign, rank);
+
+ALTER TABLE campaign DROP COLUMN tie_breaker;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE campaign DROP COLUMN unclassified_bonus;
+
+COMMIT;
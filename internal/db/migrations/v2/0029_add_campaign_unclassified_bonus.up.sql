@@ -0,0 +1,7 @@
+BEGIN;
+
+-- points for each fixed bug without a category, zero disables the bonus
+ALTER TABLE campaign ADD COLUMN unclassified_bonus NUMERIC NOT NULL DEFAULT 1
+    CHECK (unclassified_bonus >= 0);
+
+COMMIT;
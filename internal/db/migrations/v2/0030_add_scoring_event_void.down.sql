@@ -0,0 +1,48 @@
+BEGIN;
+
+DROP MATERIALIZED VIEW leaderboard_rank;
+CREATE MATERIALIZED VIEW leaderboard_rank AS
+    SELECT participant.Id                                  AS fk_participant,
+           participant.fk_campaign,
+           source_control_provider.name                    AS scp_name,
+           participant.login_name,
+           COALESCE(participant.DisplayName, '')           AS display_name,
+           COALESCE(participant.avatar_url, '')            AS avatar_url,
+           team.name                                       AS team_name,
+           COALESCE(participant.Score, 0)                  AS score,
+           ROW_NUMBER() OVER (PARTITION BY participant.fk_campaign
+               ORDER BY COALESCE(participant.Score, 0) DESC,
+                   CASE WHEN campaign.tie_breaker = 'reachedScore' THEN activity.reached_score_on END NULLS LAST,
+                   CASE WHEN campaign.tie_breaker = 'bugCategories' THEN activity.bug_categories END DESC NULLS LAST,
+                   CASE WHEN campaign.tie_breaker = 'joinedAt' THEN participant.JoinedAt END,
+                   participant.login_name,
+                   source_control_provider.name)                AS rank,
+           NOW()                                           AS refreshed_on,
+           participant.updated_on                          AS participant_updated_on,
+           team.updated_on                                 AS team_updated_on,
+           campaign.updated_on                             AS campaign_updated_on
+    FROM participant
+             INNER JOIN campaign ON participant.fk_campaign = campaign.Id
+             INNER JOIN source_control_provider ON participant.fk_scp = source_control_provider.Id
+             LEFT JOIN team ON participant.fk_team = team.Id
+             -- when the participant last scored, and the number of distinct bug categories they fixed
+             LEFT JOIN LATERAL (
+                 SELECT MAX(scoring_event.scored_on)              AS reached_score_on,
+                        COUNT(DISTINCT category.value ->> 'category') AS bug_categories
+                 FROM scoring_event
+                          LEFT JOIN LATERAL jsonb_array_elements(
+                         CASE
+                             WHEN jsonb_typeof(scoring_event.breakdown -> 'categories') = 'array'
+                                 THEN scoring_event.breakdown -> 'categories'
+                             ELSE '[]'::jsonb END) AS category ON TRUE
+                 WHERE scoring_event.fk_campaign = participant.fk_campaign
+                   AND scoring_event.fk_scp = participant.fk_scp
+                   AND scoring_event.username = participant.login_name) AS activity ON TRUE;
+
+CREATE UNIQUE INDEX leaderboard_rank_participant ON leaderboard_rank (fk_participant);
+CREATE INDEX leaderboard_rank_campaign_rank ON leaderboard_rank (fk_campaign, rank);
+
+ALTER TABLE scoring_event DROP COLUMN voided_on;
+ALTER TABLE scoring_event DROP COLUMN Id;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE campaign DROP COLUMN fk_tenant;
+DROP TABLE tenant;
+
+COMMIT;
@@ -0,0 +1,18 @@
+BEGIN;
+
+-- table: tenant, an organization with its own campaigns and admin on a shared deployment
+CREATE TABLE tenant(
+    Id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name varchar(100) NOT NULL UNIQUE CHECK (name <> ''),
+    admin_username varchar(250) NOT NULL CHECK (admin_username <> ''),
+    -- bcrypt hash of the admin password
+    admin_password_hash TEXT NOT NULL,
+    created_on timestamp NOT NULL DEFAULT NOW()
+);
+
+-- the participants, bugs and scores of a campaign belong to the tenant of the campaign. Campaigns of no tenant are
+-- only managed by the admin of the deployment
+ALTER TABLE campaign ADD COLUMN fk_tenant UUID REFERENCES tenant (Id);
+CREATE INDEX idx_campaign_fk_tenant ON campaign (fk_tenant);
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+-- keep the same start and end instants, as UTC
+UPDATE campaign
+SET start_on = start_on AT TIME ZONE time_zone AT TIME ZONE 'UTC',
+    end_on   = end_on AT TIME ZONE time_zone AT TIME ZONE 'UTC'
+WHERE time_zone <> 'UTC';
+ALTER TABLE campaign DROP COLUMN time_zone;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+-- the start and end of a campaign are the local time of its IANA time zone. Existing campaigns were compared as UTC.
+ALTER TABLE campaign ADD COLUMN time_zone varchar(64) NOT NULL DEFAULT 'UTC';
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE campaign DROP COLUMN freeze_scoring;
+ALTER TABLE campaign DROP COLUMN status;
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+-- the lifecycle of a campaign, moved along by the scheduler as the campaign starts and ends
+ALTER TABLE campaign ADD COLUMN status varchar(10) NOT NULL DEFAULT 'upcoming'
+    CHECK (status IN ('upcoming', 'active', 'ended'));
+-- a frozen campaign scores nothing once the scheduler has ended it, even log entries from before the end
+ALTER TABLE campaign ADD COLUMN freeze_scoring boolean NOT NULL DEFAULT false;
+
+-- campaigns that ended without their ended event being sent are left active, so the scheduler sends it
+UPDATE campaign
+SET status = CASE
+                 WHEN ended_notified_on IS NOT NULL THEN 'ended'
+                 WHEN NOW() >= start_on AT TIME ZONE time_zone THEN 'active'
+                 ELSE 'upcoming' END;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE campaign DROP COLUMN registration_closes_on;
+ALTER TABLE campaign DROP COLUMN registration_opens_on;
+
+COMMIT;
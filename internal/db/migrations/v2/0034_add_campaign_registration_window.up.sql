@@ -0,0 +1,8 @@
+BEGIN;
+
+-- when participants may join the campaign, as local time in the campaign time zone like start_on and end_on. A null
+-- bound leaves that side of the window open.
+ALTER TABLE campaign ADD COLUMN registration_opens_on timestamp;
+ALTER TABLE campaign ADD COLUMN registration_closes_on timestamp;
+
+COMMIT;
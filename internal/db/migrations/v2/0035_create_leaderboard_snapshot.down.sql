@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE leaderboard_snapshot_entry;
+DROP TABLE leaderboard_snapshot;
+DROP FUNCTION reject_update();
+
+COMMIT;
@@ -0,0 +1,41 @@
+BEGIN;
+
+-- table: leaderboard_snapshot
+-- the official final ranks of a campaign, written once when the campaign is finalized, so later corrections of scores
+-- do not change the results
+CREATE TABLE leaderboard_snapshot
+(
+    fk_campaign  UUID references campaign (Id) ON DELETE CASCADE PRIMARY KEY NOT NULL,
+    tie_breaker  varchar(32) NOT NULL,
+    finalized_on timestamp   NOT NULL
+);
+
+-- table: leaderboard_snapshot_entry
+-- one ranked participant of a leaderboard snapshot, copied from leaderboard_rank
+CREATE TABLE leaderboard_snapshot_entry
+(
+    fk_campaign  UUID references leaderboard_snapshot (fk_campaign) ON DELETE CASCADE NOT NULL,
+    rank         int     NOT NULL,
+    scp_name     TEXT    NOT NULL,
+    login_name   TEXT    NOT NULL,
+    display_name TEXT    NOT NULL,
+    avatar_url   TEXT    NOT NULL,
+    team_name    TEXT    NOT NULL,
+    score        numeric NOT NULL,
+    PRIMARY KEY (fk_campaign, rank)
+);
+
+-- a snapshot is only ever added or removed with its campaign, never changed
+CREATE FUNCTION reject_update() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION '% rows cannot be changed', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER leaderboard_snapshot_immutable BEFORE UPDATE ON leaderboard_snapshot
+    FOR EACH ROW EXECUTE PROCEDURE reject_update();
+CREATE TRIGGER leaderboard_snapshot_entry_immutable BEFORE UPDATE ON leaderboard_snapshot_entry
+    FOR EACH ROW EXECUTE PROCEDURE reject_update();
+
+COMMIT;
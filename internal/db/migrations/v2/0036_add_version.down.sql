@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE participant DROP COLUMN version;
+ALTER TABLE bug DROP COLUMN version;
+ALTER TABLE campaign DROP COLUMN version;
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+-- counts the updates of each campaign, bug and participant, so an update made from an older version is refused rather
+-- than overwriting the update before it
+ALTER TABLE campaign ADD COLUMN version integer NOT NULL DEFAULT 1;
+ALTER TABLE bug ADD COLUMN version integer NOT NULL DEFAULT 1;
+ALTER TABLE participant ADD COLUMN version integer NOT NULL DEFAULT 1;
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+-- table: ban
+-- a login of a source control provider kept out of every campaign. The login is stored in lower case, like the
+-- trigger user of a scoring message
+CREATE TABLE ban
+(
+    Id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    fk_scp     UUID references source_control_provider (Id) NOT NULL,
+    login_name varchar(250) NOT NULL CHECK (login_name <> ''),
+    reason     varchar(250) NOT NULL DEFAULT '',
+    banned_on  timestamp    NOT NULL DEFAULT NOW(),
+    CONSTRAINT ban_scp_login UNIQUE (fk_scp, login_name)
+);
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+
+-- table: repository_filter
+-- an allow or deny pattern of the repository names scored in a campaign, stored in lower case
+CREATE TABLE repository_filter
+(
+    Id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    fk_campaign UUID references campaign (Id) ON DELETE CASCADE NOT NULL,
+    kind        varchar(5)   NOT NULL CHECK (kind IN ('allow', 'deny')),
+    pattern     varchar(250) NOT NULL CHECK (pattern <> ''),
+    unique (fk_campaign, kind, pattern)
+);
+
+COMMIT;
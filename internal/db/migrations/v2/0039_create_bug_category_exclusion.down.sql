@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE bug_category_exclusion;
+
+COMMIT;
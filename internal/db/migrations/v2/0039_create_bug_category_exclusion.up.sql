@@ -0,0 +1,13 @@
+BEGIN;
+
+-- table: bug_category_exclusion
+-- a bug category that scores nothing in a campaign
+CREATE TABLE bug_category_exclusion
+(
+    Id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    fk_campaign UUID references campaign (Id) ON DELETE CASCADE NOT NULL,
+    category    varchar(255) NOT NULL CHECK (category <> ''),
+    unique (fk_campaign, category)
+);
+
+COMMIT;
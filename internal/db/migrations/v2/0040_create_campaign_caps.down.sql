@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX scoring_event_username_scored_on;
+DROP TABLE campaign_caps;
+
+COMMIT;
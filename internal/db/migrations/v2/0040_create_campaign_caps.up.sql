@@ -0,0 +1,15 @@
+BEGIN;
+
+-- table: campaign_caps
+-- the most points a pull request, or a participant in a day, scores in a campaign. Zero is no cap.
+CREATE TABLE campaign_caps
+(
+    fk_campaign      UUID references campaign (Id) ON DELETE CASCADE PRIMARY KEY NOT NULL,
+    per_pull_request NUMERIC NOT NULL DEFAULT 0 CHECK (per_pull_request >= 0),
+    per_day          NUMERIC NOT NULL DEFAULT 0 CHECK (per_day >= 0)
+);
+
+-- the points a participant scored in a day
+CREATE INDEX scoring_event_username_scored_on ON scoring_event (fk_campaign, fk_scp, username, scored_on);
+
+COMMIT;
@@ -0,0 +1,19 @@
+BEGIN;
+
+-- table: scoring_flag
+-- a scoring event the anomaly job flagged for review, until an admin approves or voids it
+CREATE TABLE scoring_flag
+(
+    Id               UUID PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+    fk_scoring_event UUID references scoring_event (Id) ON DELETE CASCADE NOT NULL,
+    reason           varchar(50)      NOT NULL,
+    flagged_on       timestamp        NOT NULL,
+    resolved_on      timestamp,
+    resolution       varchar(50),
+    UNIQUE (fk_scoring_event, reason)
+);
+
+-- the scoring events the anomaly job reads each run
+CREATE INDEX scoring_event_scored_on ON scoring_event (scored_on);
+
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN;
+
+-- table: scoring_event_fingerprint
+-- the fixed bugs of a scoring event, by a fingerprint the same in any pull request fixing the bug, kept from when the
+-- event first fixed it
+CREATE TABLE scoring_event_fingerprint
+(
+    fk_scoring_event UUID references scoring_event (Id) ON DELETE CASCADE NOT NULL,
+    fingerprint      TEXT      NOT NULL,
+    fixed_on         timestamp NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (fk_scoring_event, fingerprint)
+);
+CREATE INDEX scoring_event_fingerprint_fingerprint ON scoring_event_fingerprint (fingerprint);
+
+-- table: campaign_duplicate_policy
+-- how the campaign scores a fixed bug another participant fixed first. Without a policy, duplicates are skipped.
+CREATE TABLE campaign_duplicate_policy
+(
+    fk_campaign UUID references campaign (Id) ON DELETE CASCADE PRIMARY KEY NOT NULL,
+    policy      varchar(20) NOT NULL CHECK (policy IN ('skip', 'downweight', 'score')),
+    weight      NUMERIC     NOT NULL DEFAULT 0 CHECK (weight >= 0 AND weight <= 1)
+);
+
+COMMIT;
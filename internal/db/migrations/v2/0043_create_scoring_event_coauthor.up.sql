@@ -0,0 +1,21 @@
+BEGIN;
+
+-- table: scoring_event_coauthor
+-- the points each co-author of the pull request of a scoring event scored, besides its username
+CREATE TABLE scoring_event_coauthor
+(
+    fk_scoring_event UUID references scoring_event (Id) ON DELETE CASCADE NOT NULL,
+    username         TEXT    NOT NULL,
+    points           NUMERIC NOT NULL,
+    PRIMARY KEY (fk_scoring_event, username)
+);
+
+-- table: campaign_coauthor_policy
+-- how the campaign scores the co-authors of a pull request. Without a policy, the points are split.
+CREATE TABLE campaign_coauthor_policy
+(
+    fk_campaign UUID references campaign (Id) ON DELETE CASCADE PRIMARY KEY NOT NULL,
+    policy      varchar(20) NOT NULL CHECK (policy IN ('split', 'duplicate'))
+);
+
+COMMIT;
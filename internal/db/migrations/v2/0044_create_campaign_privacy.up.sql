@@ -0,0 +1,13 @@
+BEGIN;
+
+-- table: campaign_privacy
+-- whether the public leaderboards of the campaign show pseudonyms rather than login names. The salt the pseudonyms are
+-- derived from is kept when anonymize is changed, so a participant keeps the same pseudonym.
+CREATE TABLE campaign_privacy
+(
+    fk_campaign UUID references campaign (Id) ON DELETE CASCADE PRIMARY KEY NOT NULL,
+    anonymize   BOOLEAN NOT NULL DEFAULT false,
+    salt        UUID    NOT NULL DEFAULT gen_random_uuid()
+);
+
+COMMIT;
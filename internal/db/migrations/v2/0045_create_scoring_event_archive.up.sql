@@ -0,0 +1,16 @@
+BEGIN;
+
+-- table: scoring_event_archive
+-- the scoring events of an ended campaign, moved out of scoring_event as gzipped newline delimited JSON so the hot
+-- table stays small. The events are cleared when restored, and restored_on kept, so the job does not archive the
+-- campaign again.
+CREATE TABLE scoring_event_archive
+(
+    fk_campaign UUID references campaign (Id) ON DELETE CASCADE PRIMARY KEY NOT NULL,
+    events      BYTEA,
+    event_count INT       NOT NULL,
+    archived_on timestamp NOT NULL,
+    restored_on timestamp
+);
+
+COMMIT;
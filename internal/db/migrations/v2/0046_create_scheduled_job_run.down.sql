@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE scheduled_job_run;
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+-- table: scheduled_job_run
+-- the latest run of each scheduled job, by any replica. The counts add up the runs of every replica.
+CREATE TABLE scheduled_job_run
+(
+    name        varchar(100) PRIMARY KEY NOT NULL,
+    instance_id varchar(255)             NOT NULL,
+    started_on  timestamp                NOT NULL,
+    finished_on timestamp                NOT NULL,
+    error       TEXT,
+    runs        BIGINT                   NOT NULL,
+    failures    BIGINT                   NOT NULL
+);
+
+COMMIT;
@@ -0,0 +1,20 @@
+BEGIN;
+
+-- table: job
+-- a long running operation started by a request, which answers with the job rather than waiting for it. The replica
+-- running the job updates its progress, and its result or error once it finishes.
+CREATE TABLE job
+(
+    Id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind        varchar(50)  NOT NULL,
+    status      varchar(20)  NOT NULL,
+    instance_id varchar(255) NOT NULL,
+    done        BIGINT       NOT NULL DEFAULT 0,
+    total       BIGINT       NOT NULL DEFAULT 0,
+    result      JSONB,
+    error       TEXT,
+    created_on  timestamp    NOT NULL,
+    finished_on timestamp
+);
+
+COMMIT;
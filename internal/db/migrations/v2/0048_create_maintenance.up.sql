@@ -0,0 +1,12 @@
+BEGIN;
+
+-- table: maintenance
+-- a single row while maintenance is on, so every replica turns changes away. since is when it was turned on.
+CREATE TABLE maintenance
+(
+    Id      BOOLEAN PRIMARY KEY NOT NULL DEFAULT TRUE CHECK (Id),
+    message TEXT      NOT NULL,
+    since   timestamp NOT NULL
+);
+
+COMMIT;
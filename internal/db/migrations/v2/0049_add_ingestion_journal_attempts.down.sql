@@ -0,0 +1,11 @@
+BEGIN;
+
+DROP INDEX idx_ingestion_journal_unprocessed;
+CREATE INDEX idx_ingestion_journal_unprocessed ON ingestion_journal (received_on) WHERE processed_on IS NULL;
+
+ALTER TABLE ingestion_journal
+    DROP COLUMN attempts,
+    DROP COLUMN last_error,
+    DROP COLUMN dead_lettered_on;
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+
+-- a message that keeps failing is retried a few times, then dead lettered, so it is kept with its error but no longer
+-- blocks the journal recovery
+ALTER TABLE ingestion_journal
+    ADD COLUMN attempts         INTEGER   NOT NULL DEFAULT 0,
+    ADD COLUMN last_error       TEXT,
+    ADD COLUMN dead_lettered_on timestamp;
+
+DROP INDEX idx_ingestion_journal_unprocessed;
+CREATE INDEX idx_ingestion_journal_unprocessed ON ingestion_journal (received_on)
+    WHERE processed_on IS NULL AND dead_lettered_on IS NULL;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE poll_lease;
+
+COMMIT;
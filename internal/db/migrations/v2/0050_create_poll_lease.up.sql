@@ -0,0 +1,13 @@
+BEGIN;
+
+-- table: poll_lease
+-- a single row, naming the replica that runs the datadog poll until the lease expires. The leader renews it on each
+-- heartbeat, and another replica takes it over once it has expired.
+CREATE TABLE poll_lease
+(
+    Id          BOOLEAN PRIMARY KEY NOT NULL DEFAULT TRUE CHECK (Id),
+    instance_id varchar(255) NOT NULL,
+    expires_on  timestamp    NOT NULL
+);
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP TRIGGER scoring_event_coauthor_revised ON scoring_event_coauthor;
+DROP TRIGGER scoring_event_revised ON scoring_event;
+DROP FUNCTION revise_scoring_event_coauthor();
+DROP FUNCTION revise_scoring_event();
+DROP TABLE scoring_event_revision;
+
+COMMIT;
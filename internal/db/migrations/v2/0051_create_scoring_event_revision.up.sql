@@ -0,0 +1,72 @@
+BEGIN;
+
+-- table: scoring_event_revision
+-- every score a scoring event, or the share of one of its co-authors, has had, so the score of a participant can be
+-- rebuilt as of any time. A voided event, or a co-author no longer of the pull request, is revised to 0 points. The Id
+-- orders the revisions made at the same time.
+CREATE TABLE scoring_event_revision
+(
+    Id               BIGSERIAL PRIMARY KEY,
+    fk_scoring_event UUID references scoring_event (Id) ON DELETE CASCADE NOT NULL,
+    username         TEXT      NOT NULL,
+    coauthor         BOOLEAN   NOT NULL,
+    points           NUMERIC   NOT NULL,
+    voided           BOOLEAN   NOT NULL DEFAULT FALSE,
+    revised_on       timestamp NOT NULL DEFAULT NOW()
+);
+CREATE INDEX scoring_event_revision_username ON scoring_event_revision (username, revised_on);
+
+CREATE FUNCTION revise_scoring_event() RETURNS trigger AS
+$$
+BEGIN
+    IF TG_OP = 'INSERT' OR NEW.voided_on IS NULL THEN
+        INSERT INTO scoring_event_revision (fk_scoring_event, username, coauthor, points, revised_on)
+        VALUES (NEW.Id, NEW.username, FALSE, NEW.points, COALESCE(NEW.scored_on, NOW()));
+    END IF;
+    IF NEW.voided_on IS NOT NULL THEN
+        INSERT INTO scoring_event_revision (fk_scoring_event, username, coauthor, points, voided, revised_on)
+        VALUES (NEW.Id, NEW.username, FALSE, 0, TRUE, NEW.voided_on);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- a share is revised when its pull request is scored, and a share deleted with its scoring event has no history to keep
+CREATE FUNCTION revise_scoring_event_coauthor() RETURNS trigger AS
+$$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO scoring_event_revision (fk_scoring_event, username, coauthor, points)
+        SELECT Id, OLD.username, TRUE, 0
+        FROM scoring_event
+        WHERE Id = OLD.fk_scoring_event;
+    ELSE
+        INSERT INTO scoring_event_revision (fk_scoring_event, username, coauthor, points, revised_on)
+        SELECT Id, NEW.username, TRUE, NEW.points, COALESCE(scored_on, NOW())
+        FROM scoring_event
+        WHERE Id = NEW.fk_scoring_event;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER scoring_event_revised AFTER INSERT OR UPDATE OF points, username, scored_on, voided_on ON scoring_event
+    FOR EACH ROW EXECUTE PROCEDURE revise_scoring_event();
+CREATE TRIGGER scoring_event_coauthor_revised AFTER INSERT OR UPDATE OF points OR DELETE ON scoring_event_coauthor
+    FOR EACH ROW EXECUTE PROCEDURE revise_scoring_event_coauthor();
+
+-- the history starts from the scores the events have now, and an event scored before scored_on was recorded counts at
+-- any time
+INSERT INTO scoring_event_revision (fk_scoring_event, username, coauthor, points, revised_on)
+SELECT Id, username, FALSE, points, COALESCE(scored_on, 'epoch')
+FROM scoring_event;
+INSERT INTO scoring_event_revision (fk_scoring_event, username, coauthor, points, voided, revised_on)
+SELECT Id, username, FALSE, 0, TRUE, voided_on
+FROM scoring_event
+WHERE voided_on IS NOT NULL;
+INSERT INTO scoring_event_revision (fk_scoring_event, username, coauthor, points, revised_on)
+SELECT coauthor.fk_scoring_event, coauthor.username, TRUE, coauthor.points, COALESCE(scoring_event.scored_on, 'epoch')
+FROM scoring_event_coauthor coauthor
+         INNER JOIN scoring_event ON coauthor.fk_scoring_event = scoring_event.Id;
+
+COMMIT;
@@ -0,0 +1,152 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/lib/pq"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+// retryAttempts is how many times a statement is tried, when it fails with a transient error
+const retryAttempts = 3
+
+// retryBackoff is the most the first retry waits. Each retry after that may wait twice as long as the one before.
+var retryBackoff = 100 * time.Millisecond
+
+// scanner is a row to read, from a query or from a transaction
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// retryDB runs the statements of db again, after a short and random wait, when they fail with a transient error, such
+// as while a managed database fails over. Statements of a transaction are not retried, only its begin.
+type retryDB struct {
+	db *sql.DB
+	// readOnly is true when every statement only reads, so a statement can be retried when the connection dropped
+	// while it ran
+	readOnly bool
+}
+
+func (r retryDB) QueryContext(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, err error) {
+	err = retry(ctx, r.readOnly, func() (err error) {
+		rows, err = r.db.QueryContext(ctx, query, args...)
+		return
+	})
+	return
+}
+
+func (r retryDB) ExecContext(ctx context.Context, query string, args ...interface{}) (result sql.Result, err error) {
+	err = retry(ctx, r.readOnly, func() (err error) {
+		result, err = r.db.ExecContext(ctx, query, args...)
+		return
+	})
+	return
+}
+
+// QueryRowContext runs the query when the row is scanned, as the error of sql.Row only shows then.
+func (r retryDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) scanner {
+	return retryRow{retryDB: r, ctx: ctx, query: query, args: args}
+}
+
+func (r retryDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (tx *sql.Tx, err error) {
+	// nothing has run yet, so every transient error can be retried
+	err = retry(ctx, true, func() (err error) {
+		tx, err = r.db.BeginTx(ctx, opts)
+		return
+	})
+	return
+}
+
+type retryRow struct {
+	retryDB
+	ctx   context.Context
+	query string
+	args  []interface{}
+}
+
+func (r retryRow) Scan(dest ...interface{}) error {
+	return retry(r.ctx, r.readOnly, func() error {
+		return r.db.QueryRowContext(r.ctx, r.query, r.args...).Scan(dest...)
+	})
+}
+
+// retry calls fn until it succeeds, fails with an error that is not transient, has been called retryAttempts times,
+// or the context is done.
+func retry(ctx context.Context, readOnly bool, fn func() error) (err error) {
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if attempt == retryAttempts || !transient(err, readOnly) {
+			return
+		}
+
+		// a random wait, so the callers that failed together do not all retry together
+		wait := time.NewTimer(time.Duration(rand.Int63n(int64(backoff)) + 1))
+		select {
+		case <-ctx.Done():
+			wait.Stop()
+			return
+		case <-wait.C:
+		}
+		backoff *= 2
+	}
+}
+
+// transient tells whether a retry may get past the error: the database could not be reached for a moment, or it
+// rolled the statement back, as on a serialization failure or a deadlock. Unless readOnly, only the errors raised
+// before the statement could take effect count, so a write is never made twice.
+func transient(err error, readOnly bool) bool {
+	// the caller gave up, or the breaker fails fast, so a retry would fail the same way
+	if err == nil || errors.Is(err, ErrCircuitOpen) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		// serialization failure, deadlock, the server is starting up, and the connection could not be made
+		case "40001", "40P01", "57P03", "08001", "08004":
+			return true
+		}
+		// the connection dropped, as when the server shuts down for a failover
+		code := string(pqErr.Code)
+		return readOnly && (strings.HasPrefix(code, "08") || code == "57P01" || code == "57P02")
+	}
+
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	// the statement was not sent, when the connection could not be made or the write of the statement failed
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && (opErr.Op == "dial" || opErr.Op == "write") {
+		return true
+	}
+	var netErr net.Error
+	return readOnly && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr))
+}
@@ -0,0 +1,148 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func setupRetryBackoff(t *testing.T) {
+	backoff := retryBackoff
+	retryBackoff = time.Millisecond
+	t.Cleanup(func() {
+		retryBackoff = backoff
+	})
+}
+
+func TestRetrySerializationFailure(t *testing.T) {
+	setupRetryBackoff(t)
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := &pq.Error{Code: "40001"}
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteOrganization)).
+		WillReturnError(forcedError)
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteOrganization)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rowsAffected, err := db.DeleteOrganization(context.Background(), "", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), rowsAffected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetryWriteNotRetriedOnDroppedConnection(t *testing.T) {
+	setupRetryBackoff(t)
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	// the delete may have been made before the connection dropped
+	mock.ExpectExec(convertSqlToDbMockExpect(sqlDeleteOrganization)).
+		WillReturnError(io.ErrUnexpectedEOF)
+
+	_, err := db.DeleteOrganization(context.Background(), "", "", "")
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetryReadOnDroppedConnection(t *testing.T) {
+	setupRetryBackoff(t)
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignsVersion)).
+		WillReturnError(&pq.Error{Code: "57P01"})
+	mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignsVersion)).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "updatedOn"}).AddRow(2, now))
+
+	version, err := db.SelectCampaignsVersion(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, types.ListVersion{Count: 2, UpdatedOn: now}, version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	setupRetryBackoff(t)
+	mock, db, closeDbFunc := SetupMockDB(t)
+	defer closeDbFunc()
+
+	forcedError := &pq.Error{Code: "57P03"}
+	for i := 0; i < retryAttempts; i++ {
+		mock.ExpectQuery(convertSqlToDbMockExpect(sqlSelectCampaignsVersion)).
+			WillReturnError(forcedError)
+	}
+
+	_, err := db.SelectCampaignsVersion(context.Background())
+	assert.Equal(t, forcedError, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetryContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := retry(ctx, true, func() error {
+		calls++
+		cancel()
+		return io.EOF
+	})
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestTransient(t *testing.T) {
+	for _, test := range []struct {
+		err       error
+		readOnly  bool
+		transient bool
+	}{
+		{nil, true, false},
+		{ErrCircuitOpen, true, false},
+		{sql.ErrNoRows, true, false},
+		{&pq.Error{Code: "23505"}, true, false},
+		{&pq.Error{Code: "40001"}, false, true},
+		{&pq.Error{Code: "40P01"}, false, true},
+		{&pq.Error{Code: "57P03"}, false, true},
+		{&pq.Error{Code: "08001"}, false, true},
+		{&pq.Error{Code: "08006"}, false, false},
+		{&pq.Error{Code: "08006"}, true, true},
+		{&pq.Error{Code: "57P01"}, false, false},
+		{&pq.Error{Code: "57P01"}, true, true},
+		{fmt.Errorf("wrapped: %w", driver.ErrBadConn), false, true},
+		{&net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}, false, true},
+		{&net.OpError{Op: "read", Err: fmt.Errorf("connection reset by peer")}, false, false},
+		{&net.OpError{Op: "read", Err: fmt.Errorf("connection reset by peer")}, true, true},
+		{io.EOF, false, false},
+		{io.EOF, true, true},
+		{context.DeadlineExceeded, true, false},
+	} {
+		assert.Equal(t, test.transient, transient(test.err, test.readOnly), "%v, readOnly: %t", test.err, test.readOnly)
+	}
+}
@@ -0,0 +1,212 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package errreport
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Reporter sends errors to an error tracker, so a failure that is only logged still gets noticed.
+type Reporter interface {
+	// Report sends the error, with the extra values as its context.
+	Report(err error, extra map[string]interface{})
+}
+
+// NoReporter is the Reporter used when no error tracker is configured. It drops every error.
+type NoReporter struct{}
+
+var _ Reporter = NoReporter{}
+
+func (NoReporter) Report(error, map[string]interface{}) {}
+
+// PanicError is a recovered panic, reported as an error.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// SentryReporter is the Reporter that posts errors as events to a Sentry project.
+type SentryReporter struct {
+	client      *http.Client
+	envelopeUrl string
+	auth        string
+	release     string
+	environment string
+	logger      *zap.Logger
+}
+
+var _ Reporter = (*SentryReporter)(nil)
+
+// NewSentry returns a reporter to the project of the Sentry DSN, whose posts give up after the timeout. The release
+// and environment, which may be empty, are sent with every event.
+func NewSentry(logger *zap.Logger, dsn, release, environment string, timeout time.Duration) (r *SentryReporter, err error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return
+	}
+	slash := strings.LastIndex(parsed.Path, "/")
+	if parsed.User == nil || parsed.User.Username() == "" || parsed.Host == "" || slash < 0 || slash == len(parsed.Path)-1 {
+		err = fmt.Errorf("invalid sentry dsn, expected: https://key@host/projectId")
+		return
+	}
+	projectId := parsed.Path[slash+1:]
+
+	r = &SentryReporter{
+		client:      &http.Client{Timeout: timeout},
+		envelopeUrl: fmt.Sprintf("%s://%s%s/api/%s/envelope/", parsed.Scheme, parsed.Host, parsed.Path[:slash], projectId),
+		auth: fmt.Sprintf("Sentry sentry_version=7, sentry_client=bbash/%s, sentry_key=%s",
+			release, parsed.User.Username()),
+		release:     release,
+		environment: environment,
+		logger:      logger,
+	}
+	return
+}
+
+type exception struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type exceptions struct {
+	Values []exception `json:"values"`
+}
+
+type event struct {
+	EventId     string                 `json:"event_id"`
+	Timestamp   time.Time              `json:"timestamp"`
+	Level       string                 `json:"level"`
+	Platform    string                 `json:"platform"`
+	Release     string                 `json:"release,omitempty"`
+	Environment string                 `json:"environment,omitempty"`
+	Exception   exceptions             `json:"exception"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+}
+
+// Report posts the error in the background, so a slow or failing tracker never holds up the caller.
+func (r *SentryReporter) Report(err error, extra map[string]interface{}) {
+	go func() {
+		_ = r.report(err, extra, time.Now())
+	}()
+}
+
+func (r *SentryReporter) report(err error, extra map[string]interface{}, now time.Time) (postErr error) {
+	ev := newEvent(err, extra, now)
+	ev.Release = r.release
+	ev.Environment = r.environment
+
+	body, postErr := envelope(ev, now)
+	if postErr == nil {
+		postErr = r.post(body)
+	}
+	if postErr != nil {
+		// not reported, so at least it is logged next to the error
+		r.logger.Error("error report failed", zap.String("eventId", ev.EventId), zap.Error(postErr))
+	}
+	return
+}
+
+func newEvent(err error, extra map[string]interface{}, now time.Time) (ev event) {
+	ev = event{
+		EventId:   newEventId(),
+		Timestamp: now.UTC(),
+		Level:     "error",
+		Platform:  "go",
+		Extra:     map[string]interface{}{},
+	}
+	for key, value := range extra {
+		ev.Extra[key] = value
+	}
+
+	ex := exception{Type: fmt.Sprintf("%T", err), Value: err.Error()}
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		ev.Level = "fatal"
+		ex.Type = "panic"
+		ev.Extra["stack"] = string(panicErr.Stack)
+	}
+	ev.Exception.Values = []exception{ex}
+	return
+}
+
+// newEventId is 32 random hex digits, as sentry expects
+func newEventId() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// envelope wraps the event, as the sentry envelope endpoint expects: a header line, an item header line, then the
+// event.
+func envelope(ev event, now time.Time) (body []byte, err error) {
+	eventJson, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	if err = encoder.Encode(map[string]interface{}{"event_id": ev.EventId, "sent_at": now.UTC()}); err != nil {
+		return
+	}
+	if err = encoder.Encode(map[string]interface{}{"type": "event", "length": len(eventJson)}); err != nil {
+		return
+	}
+	buf.Write(eventJson)
+	buf.WriteString("\n")
+	body = buf.Bytes()
+	return
+}
+
+func (r *SentryReporter) post(body []byte) (err error) {
+	req, err := http.NewRequest(http.MethodPost, r.envelopeUrl, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", r.auth)
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		err = fmt.Errorf("sentry status: %d", res.StatusCode)
+	}
+	return
+}
@@ -0,0 +1,130 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package errreport
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSentry(t *testing.T) {
+	r, err := NewSentry(zaptest.NewLogger(t), "https://publicKey@o1.ingest.sentry.example/sentry/42", "1.2.3", "prod", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://o1.ingest.sentry.example/sentry/api/42/envelope/", r.envelopeUrl)
+	assert.Equal(t, "Sentry sentry_version=7, sentry_client=bbash/1.2.3, sentry_key=publicKey", r.auth)
+}
+
+func TestNewSentryInvalidDsn(t *testing.T) {
+	for _, dsn := range []string{"https://o1.ingest.sentry.example/42", "https://publicKey@o1.ingest.sentry.example/", "publicKey", ":"} {
+		_, err := NewSentry(zaptest.NewLogger(t), dsn, "", "", time.Second)
+		assert.Error(t, err, dsn)
+	}
+}
+
+// readEnvelope splits the posted envelope into its header, item header and event
+func readEnvelope(t *testing.T, r *http.Request) (header, itemHeader map[string]interface{}, ev event) {
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(nil, 1024*1024)
+	assert.True(t, scanner.Scan())
+	assert.NoError(t, json.Unmarshal(scanner.Bytes(), &header))
+	assert.True(t, scanner.Scan())
+	assert.NoError(t, json.Unmarshal(scanner.Bytes(), &itemHeader))
+	assert.True(t, scanner.Scan())
+	assert.Equal(t, float64(len(scanner.Bytes())), itemHeader["length"])
+	assert.NoError(t, json.Unmarshal(scanner.Bytes(), &ev))
+	return
+}
+
+func TestReport(t *testing.T) {
+	var header, itemHeader map[string]interface{}
+	var posted event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/42/envelope/", r.URL.Path)
+		assert.Equal(t, "application/x-sentry-envelope", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Sentry sentry_version=7, sentry_client=bbash/1.2.3, sentry_key=publicKey", r.Header.Get("X-Sentry-Auth"))
+		header, itemHeader, posted = readEnvelope(t, r)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	r, err := NewSentry(zaptest.NewLogger(t), strings.Replace(server.URL, "://", "://publicKey@", 1)+"/42", "1.2.3", "prod", time.Second)
+	assert.NoError(t, err)
+
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, r.report(fmt.Errorf("forced scoring error"), map[string]interface{}{"campaign": "myCampaign"}, now))
+
+	assert.Equal(t, posted.EventId, header["event_id"])
+	assert.Equal(t, "event", itemHeader["type"])
+	assert.Equal(t, 32, len(posted.EventId))
+	assert.Equal(t, now, posted.Timestamp)
+	assert.Equal(t, "error", posted.Level)
+	assert.Equal(t, "go", posted.Platform)
+	assert.Equal(t, "1.2.3", posted.Release)
+	assert.Equal(t, "prod", posted.Environment)
+	assert.Equal(t, []exception{{Type: "*errors.errorString", Value: "forced scoring error"}}, posted.Exception.Values)
+	assert.Equal(t, map[string]interface{}{"campaign": "myCampaign"}, posted.Extra)
+}
+
+func TestReportPanic(t *testing.T) {
+	var posted event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _, posted = readEnvelope(t, r)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	r, err := NewSentry(zaptest.NewLogger(t), strings.Replace(server.URL, "://", "://publicKey@", 1)+"/42", "", "", time.Second)
+	assert.NoError(t, err)
+
+	extra := map[string]interface{}{"campaign": "myCampaign"}
+	panicErr := &PanicError{Value: "forced panic", Stack: []byte("goroutine 1 [running]:")}
+	assert.NoError(t, r.report(fmt.Errorf("wrapped: %w", panicErr), extra, time.Now()))
+
+	assert.Equal(t, "fatal", posted.Level)
+	assert.Equal(t, []exception{{Type: "panic", Value: "wrapped: panic: forced panic"}}, posted.Exception.Values)
+	assert.Equal(t, "goroutine 1 [running]:", posted.Extra["stack"])
+	assert.Equal(t, "myCampaign", posted.Extra["campaign"])
+	// the extra values of the caller are left alone
+	assert.Equal(t, map[string]interface{}{"campaign": "myCampaign"}, extra)
+}
+
+func TestReportStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	r, err := NewSentry(zaptest.NewLogger(t), strings.Replace(server.URL, "://", "://publicKey@", 1)+"/42", "", "", time.Second)
+	assert.NoError(t, err)
+	assert.EqualError(t, r.report(fmt.Errorf("forced error"), nil, time.Now()), "sentry status: 429")
+}
+
+func TestNoReporter(t *testing.T) {
+	NoReporter{}.Report(fmt.Errorf("forced error"), nil)
+}
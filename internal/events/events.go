@@ -0,0 +1,70 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package events
+
+import (
+	"context"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"sync"
+	"time"
+)
+
+// ScoreChanged is published once a change to the score of a participant is stored.
+type ScoreChanged struct {
+	types.ScoreDelta
+	// Participant, Message and Breakdown are those of the scoring message scored. They are nil when the change did not
+	// come from a scoring message, as when a scoring event is voided.
+	Participant *types.ParticipantStruct
+	Message     *types.ScoringMessage
+	Breakdown   *types.ScoreBreakdown
+	OccurredOn  time.Time
+}
+
+// ScoreChangedHandler reacts to a score change. A failure is the handler's to log, as it must not undo the change.
+type ScoreChangedHandler func(ctx context.Context, event ScoreChanged)
+
+// Bus hands each event to the handlers of its type, so the code making a change does not need to know what reacts to
+// it. Handlers run in the order they were added, on the goroutine publishing the event.
+type Bus struct {
+	mu           sync.RWMutex
+	scoreChanged []ScoreChangedHandler
+}
+
+func New() *Bus {
+	return &Bus{}
+}
+
+// OnScoreChanged adds a handler of every ScoreChanged published after it.
+func (b *Bus) OnScoreChanged(handler ScoreChangedHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.scoreChanged = append(b.scoreChanged, handler)
+}
+
+// PublishScoreChanged calls each ScoreChanged handler with the event.
+func (b *Bus) PublishScoreChanged(ctx context.Context, event ScoreChanged) {
+	b.mu.RLock()
+	handlers := b.scoreChanged
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(ctx, event)
+	}
+}
@@ -0,0 +1,62 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package events
+
+import (
+	"context"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPublishScoreChangedNoHandlers(t *testing.T) {
+	New().PublishScoreChanged(context.Background(), ScoreChanged{ScoreDelta: types.ScoreDelta{Delta: 1}})
+}
+
+func TestPublishScoreChangedInOrder(t *testing.T) {
+	b := New()
+	var calls []string
+	var received []ScoreChanged
+	b.OnScoreChanged(func(ctx context.Context, event ScoreChanged) {
+		calls = append(calls, "first")
+		received = append(received, event)
+	})
+	b.OnScoreChanged(func(ctx context.Context, event ScoreChanged) {
+		calls = append(calls, "second")
+	})
+
+	event := ScoreChanged{ScoreDelta: types.ScoreDelta{CampaignName: "myCampaignName", LoginName: "loginName", Delta: 3}}
+	b.PublishScoreChanged(context.Background(), event)
+	assert.Equal(t, []string{"first", "second"}, calls)
+	assert.Equal(t, []ScoreChanged{event}, received)
+}
+
+func TestOnScoreChangedLater(t *testing.T) {
+	b := New()
+	b.PublishScoreChanged(context.Background(), ScoreChanged{})
+
+	var calls int
+	b.OnScoreChanged(func(ctx context.Context, event ScoreChanged) {
+		calls++
+	})
+	assert.Equal(t, 0, calls)
+	b.PublishScoreChanged(context.Background(), ScoreChanged{})
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,280 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package grpcapi
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Code is the status of a call, as listed by gRPC.
+type Code int
+
+const (
+	OK                Code = 0
+	InvalidArgument   Code = 3
+	ResourceExhausted Code = 8
+	Unimplemented     Code = 12
+	Internal          Code = 13
+	Unavailable       Code = 14
+	Unauthenticated   Code = 16
+)
+
+// the methods of the bbash.v1.Scoring service
+const (
+	MethodSubmitScoringMessage = "/bbash.v1.Scoring/SubmitScoringMessage"
+	MethodWatchLeaderboard     = "/bbash.v1.Scoring/WatchLeaderboard"
+)
+
+// maxMessageSize is the largest message received, the default of the gRPC servers
+const maxMessageSize = 4 << 20
+
+const contentType = "application/grpc"
+
+// Error ends a call with its code and message, rather than with Internal.
+type Error struct {
+	Code    Code
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("grpc status: %d, message: %s", e.Code, e.Message)
+}
+
+func Errorf(code Code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Scorer scores a scoring message the way it is scored when read from the scanner logs.
+type Scorer func(ctx context.Context, msg *types.ScoringMessage) error
+
+// Authorizer tells whether the token of a call may submit the scoring messages of the eventSource. The tokens are those
+// of the gRPC service, not the secrets signing the scoring messages pushed over HTTP.
+type Authorizer func(eventSource, token string) bool
+
+// Watcher hands out the score deltas of a campaign, as hub.Hub does.
+type Watcher interface {
+	Subscribe(campaignName string) (deltas chan types.ScoreDelta)
+	Unsubscribe(campaignName string, deltas chan types.ScoreDelta)
+}
+
+// Server serves the bbash.v1.Scoring service of bbash.proto.
+type Server struct {
+	logger    *zap.Logger
+	score     Scorer
+	authorize Authorizer
+	watcher   Watcher
+}
+
+func New(logger *zap.Logger, score Scorer, authorize Authorizer, watcher Watcher) *Server {
+	return &Server{logger: logger, score: score, authorize: authorize, watcher: watcher}
+}
+
+// HTTPServer serves the calls on addr over HTTP/2 with TLS, with the certificate and key of the files. The calls carry
+// their token as a bearer token, so they are never taken without TLS.
+func (s *Server) HTTPServer(addr, certFile, keyFile string) (server *http.Server, err error) {
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return
+	}
+
+	server = &http.Server{
+		Addr:      addr,
+		Handler:   s,
+		TLSConfig: &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{certificate}},
+	}
+	err = http2.ConfigureServer(server, &http2.Server{})
+	return
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), contentType) {
+		http.Error(w, "only gRPC calls are served", http.StatusUnsupportedMediaType)
+		return
+	}
+	if r.TLS == nil {
+		http.Error(w, "gRPC calls are only served over TLS", http.StatusUpgradeRequired)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+
+	var err error
+	switch r.URL.Path {
+	case MethodSubmitScoringMessage:
+		err = s.submitScoringMessage(w, r)
+	case MethodWatchLeaderboard:
+		err = s.watchLeaderboard(w, r)
+	default:
+		err = Errorf(Unimplemented, "unknown method: %s", r.URL.Path)
+	}
+	s.writeStatus(w, r, err)
+}
+
+// submitScoringMessage scores each message of the stream, ending the call at the first that fails.
+func (s *Server) submitScoringMessage(w http.ResponseWriter, r *http.Request) (err error) {
+	token := r.Header.Get("Authorization")
+	if !strings.HasPrefix(token, "Bearer ") {
+		return Errorf(Unauthenticated, "missing authorization")
+	}
+	token = strings.TrimPrefix(token, "Bearer ")
+
+	scored := 0
+	for {
+		var payload []byte
+		payload, err = readMessage(r.Body)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return
+		}
+
+		var msg types.ScoringMessage
+		msg, err = decodeScoringMessage(payload)
+		if err != nil {
+			return Errorf(InvalidArgument, "invalid scoring message: %v", err)
+		}
+		// an unknown eventSource fails the same way as a wrong token, so the sources can not be guessed
+		if !s.authorize(msg.EventSource, token) {
+			s.logger.Info("grpc scoring message not authorized", zap.String("eventSource", msg.EventSource))
+			return Errorf(Unauthenticated, "scoring message is not authorized")
+		}
+		if err = s.score(r.Context(), &msg); err != nil {
+			return
+		}
+		scored++
+	}
+	return writeMessage(w, encodeSubmitScoringMessageResponse(scored))
+}
+
+// watchLeaderboard streams the score deltas of the campaign until the client goes away.
+func (s *Server) watchLeaderboard(w http.ResponseWriter, r *http.Request) (err error) {
+	payload, err := readMessage(r.Body)
+	if err == io.EOF {
+		return Errorf(InvalidArgument, "missing request")
+	} else if err != nil {
+		return
+	}
+	campaignName, err := decodeWatchLeaderboardRequest(payload)
+	if err != nil {
+		return Errorf(InvalidArgument, "invalid request: %v", err)
+	}
+	if campaignName == "" {
+		return Errorf(InvalidArgument, "campaign_name is required")
+	}
+
+	deltas := s.watcher.Subscribe(campaignName)
+	defer s.watcher.Unsubscribe(campaignName, deltas)
+
+	// send the headers now, so the client knows the call started before the first change
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+
+	for {
+		select {
+		case delta, ok := <-deltas:
+			if !ok {
+				return
+			}
+			if sendErr := writeMessage(w, encodeScoreDelta(delta)); sendErr != nil {
+				s.logger.Debug("grpc leaderboard send error", zap.String("campaignName", campaignName), zap.Error(sendErr))
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeStatus sets the trailers ending the call. An error other than an *Error is logged, and not shown to the client.
+func (s *Server) writeStatus(w http.ResponseWriter, r *http.Request, err error) {
+	code, message := OK, ""
+	var statusErr *Error
+	if errors.As(err, &statusErr) {
+		code, message = statusErr.Code, statusErr.Message
+	} else if err != nil {
+		s.logger.Error("grpc call error", zap.String("method", r.URL.Path), zap.Error(err))
+		code, message = Internal, "internal error"
+	}
+
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(code)))
+	if message != "" {
+		w.Header().Set("Grpc-Message", encodeStatusMessage(message))
+	}
+}
+
+// encodeStatusMessage percent encodes the bytes of the message that are not printable ASCII, as gRPC requires.
+func encodeStatusMessage(message string) string {
+	var encoded strings.Builder
+	for i := 0; i < len(message); i++ {
+		if c := message[i]; c < ' ' || c > '~' || c == '%' {
+			_, _ = fmt.Fprintf(&encoded, "%%%02X", c)
+		} else {
+			encoded.WriteByte(c)
+		}
+	}
+	return encoded.String()
+}
+
+// readMessage reads the next length prefixed message of the call, and io.EOF once the client sent them all.
+func readMessage(body io.Reader) (msg []byte, err error) {
+	var prefix [5]byte
+	if _, err = io.ReadFull(body, prefix[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = Errorf(InvalidArgument, "truncated message")
+		}
+		return
+	}
+	if prefix[0] != 0 {
+		return nil, Errorf(Unimplemented, "compressed messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > maxMessageSize {
+		return nil, Errorf(ResourceExhausted, "message larger than %d bytes", maxMessageSize)
+	}
+
+	msg = make([]byte, size)
+	if _, err = io.ReadFull(body, msg); err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = Errorf(InvalidArgument, "truncated message")
+	}
+	return
+}
+
+// writeMessage sends the message to the client at once, length prefixed.
+func writeMessage(w http.ResponseWriter, msg []byte) (err error) {
+	prefix := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(msg)))
+	if _, err = w.Write(append(prefix, msg...)); err != nil {
+		return
+	}
+	w.(http.Flusher).Flush()
+	return
+}
@@ -0,0 +1,314 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"github.com/sonatype-nexus-community/bbash/internal/hub"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protowire"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const token = "myScoringSecret"
+
+const campaignName = "myCampaignName"
+
+// authorizeGitHub authorizes only the token of the GitHub eventSource
+func authorizeGitHub(eventSource, callToken string) bool {
+	return eventSource == "GitHub" && callToken == token
+}
+
+// writeCertificate writes a self-signed certificate of 127.0.0.1 and its key, returning the files and a pool trusting it
+func writeCertificate(t *testing.T) (certFile, keyFile string, pool *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	certFile, keyFile = filepath.Join(t.TempDir(), "cert.pem"), filepath.Join(t.TempDir(), "key.pem")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+
+	certificate, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	pool = x509.NewCertPool()
+	pool.AddCert(certificate)
+	return
+}
+
+// setupServer serves the service, returning its url and a client calling it over HTTP/2 with TLS, as gRPC does
+func setupServer(t *testing.T, score Scorer, watcher Watcher) (url string, client *http.Client) {
+	certFile, keyFile, pool := writeCertificate(t)
+	server, err := New(zaptest.NewLogger(t), score, authorizeGitHub, watcher).HTTPServer("127.0.0.1:0", certFile, keyFile)
+	assert.NoError(t, err)
+	listener, err := net.Listen("tcp", server.Addr)
+	assert.NoError(t, err)
+	go func() {
+		_ = server.ServeTLS(listener, "", "")
+	}()
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+
+	client = &http.Client{Transport: &http2.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	return "https://" + listener.Addr().String(), client
+}
+
+func frame(msg []byte) []byte {
+	prefix := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(msg)))
+	return append(prefix, msg...)
+}
+
+func newCall(t *testing.T, url, method, callToken string, body io.Reader) *http.Request {
+	req, err := http.NewRequest(http.MethodPost, url+method, body)
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	if callToken != "" {
+		req.Header.Set("Authorization", "Bearer "+callToken)
+	}
+	return req
+}
+
+// call sends the messages, and returns the messages received and the status of the call
+func call(t *testing.T, client *http.Client, req *http.Request) (received [][]byte, status, message string) {
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/grpc", resp.Header.Get("Content-Type"))
+
+	for {
+		msg, readErr := readMessage(resp.Body)
+		if readErr == io.EOF {
+			break
+		}
+		assert.NoError(t, readErr)
+		received = append(received, msg)
+	}
+	return received, resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+}
+
+func encodeScoringMessage(msg types.ScoringMessage) (buf []byte) {
+	buf = appendString(buf, 1, msg.EventSource)
+	buf = appendString(buf, 2, msg.RepoOwner)
+	buf = appendString(buf, 3, msg.RepoName)
+	buf = appendString(buf, 4, msg.TriggerUser)
+	buf = appendInt32(buf, 5, msg.TotalFixed)
+	for _, bugCount := range msg.BugCounts {
+		var nested []byte
+		nested = appendString(nested, 1, bugCount.Type)
+		nested = appendDouble(nested, 2, bugCount.Count)
+		buf = protowire.AppendTag(buf, 6, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, nested)
+	}
+	buf = appendInt32(buf, 7, msg.PullRequest)
+	return appendInt32(buf, 8, msg.TotalIntroduced)
+}
+
+func TestSubmitScoringMessage(t *testing.T) {
+	var scored []types.ScoringMessage
+	url, client := setupServer(t, func(ctx context.Context, msg *types.ScoringMessage) error {
+		scored = append(scored, *msg)
+		return nil
+	}, hub.New())
+
+	first := types.ScoringMessage{EventSource: "GitHub", RepoOwner: "myOrg", RepoName: "myRepo", TriggerUser: "MyLoginName",
+		TotalFixed: 3, BugCounts: []types.BugCount{{Type: "SQL Injection", Count: 2}, {Type: "XSS", Count: 1}}, PullRequest: 5}
+	second := types.ScoringMessage{EventSource: "GitHub", RepoOwner: "myOrg", RepoName: "myRepo", TriggerUser: "MyLoginName", PullRequest: 6, TotalIntroduced: 1}
+	body := append(frame(encodeScoringMessage(first)), frame(encodeScoringMessage(second))...)
+
+	received, status, message := call(t, client, newCall(t, url, MethodSubmitScoringMessage, token, bytes.NewReader(body)))
+	assert.Equal(t, "0", status)
+	assert.Equal(t, "", message)
+	assert.Equal(t, [][]byte{encodeSubmitScoringMessageResponse(2)}, received)
+
+	first.SchemaVersion = types.ScoringSchemaVersion
+	second.SchemaVersion = types.ScoringSchemaVersion
+	assert.Equal(t, []types.ScoringMessage{first, second}, scored)
+}
+
+func TestSubmitScoringMessageEmptyStream(t *testing.T) {
+	url, client := setupServer(t, nil, hub.New())
+
+	received, status, _ := call(t, client, newCall(t, url, MethodSubmitScoringMessage, token, bytes.NewReader(nil)))
+	assert.Equal(t, "0", status)
+	// scored is zero, so the response has no fields
+	assert.Equal(t, 1, len(received))
+	assert.Empty(t, received[0])
+}
+
+func TestSubmitScoringMessageErrors(t *testing.T) {
+	forcedError := fmt.Errorf("forced scoring error")
+	url, client := setupServer(t, func(ctx context.Context, msg *types.ScoringMessage) error {
+		if msg.PullRequest == 1 {
+			return forcedError
+		}
+		if msg.PullRequest == 2 {
+			return Errorf(Unavailable, "%s", "Paused for maintenance, 100%")
+		}
+		return nil
+	}, hub.New())
+	valid := frame(encodeScoringMessage(types.ScoringMessage{EventSource: "GitHub"}))
+
+	for _, test := range []struct {
+		name      string
+		callToken string
+		body      []byte
+		status    string
+		message   string
+	}{
+		{"no token", "", valid, "16", "missing authorization"},
+		{"wrong token", "notTheToken", valid, "16", "scoring message is not authorized"},
+		{"other event source", token, frame(encodeScoringMessage(types.ScoringMessage{EventSource: "GitLab"})), "16", "scoring message is not authorized"},
+		{"invalid message", token, frame([]byte{0x0a, 0x05, 'G'}), "3", "invalid scoring message: unexpected EOF"},
+		{"truncated frame", token, valid[:len(valid)-1], "3", "truncated message"},
+		{"compressed", token, append([]byte{1}, valid[1:]...), "12", "compressed messages are not supported"},
+		{"too large", token, []byte{0, 0xff, 0xff, 0xff, 0xff}, "8", "message larger than 4194304 bytes"},
+		{"scoring error", token, frame(encodeScoringMessage(types.ScoringMessage{EventSource: "GitHub", PullRequest: 1})), "13", "internal error"},
+		{"status error", token, frame(encodeScoringMessage(types.ScoringMessage{EventSource: "GitHub", PullRequest: 2})), "14", "Paused for maintenance, 100%25"},
+	} {
+		received, status, message := call(t, client, newCall(t, url, MethodSubmitScoringMessage, test.callToken, bytes.NewReader(test.body)))
+		assert.Equal(t, 0, len(received), test.name)
+		assert.Equal(t, test.status, status, test.name)
+		assert.Equal(t, test.message, message, test.name)
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	url, client := setupServer(t, nil, hub.New())
+
+	_, status, message := call(t, client, newCall(t, url, "/bbash.v1.Scoring/Unknown", "", bytes.NewReader(nil)))
+	assert.Equal(t, "12", status)
+	assert.Equal(t, "unknown method: /bbash.v1.Scoring/Unknown", message)
+}
+
+func TestNotGrpc(t *testing.T) {
+	url, client := setupServer(t, nil, hub.New())
+
+	resp, err := client.Get(url + MethodWatchLeaderboard)
+	assert.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+}
+
+func TestHTTPServerMissingCertificate(t *testing.T) {
+	certFile := filepath.Join(t.TempDir(), "missing.pem")
+
+	_, err := New(zaptest.NewLogger(t), nil, authorizeGitHub, hub.New()).HTTPServer(":0", certFile, certFile)
+	assert.EqualError(t, err, fmt.Sprintf("open %s: no such file or directory", certFile))
+}
+
+func TestNotTls(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, MethodSubmitScoringMessage, bytes.NewReader(nil))
+	req.Header.Set("Content-Type", "application/grpc")
+
+	New(zaptest.NewLogger(t), nil, authorizeGitHub, hub.New()).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUpgradeRequired, rec.Code)
+	assert.Equal(t, "", rec.Header().Get("Grpc-Status"))
+}
+
+func TestWatchLeaderboardInvalid(t *testing.T) {
+	url, client := setupServer(t, nil, hub.New())
+
+	_, status, message := call(t, client, newCall(t, url, MethodWatchLeaderboard, "", bytes.NewReader(nil)))
+	assert.Equal(t, "3", status)
+	assert.Equal(t, "missing request", message)
+
+	_, status, message = call(t, client, newCall(t, url, MethodWatchLeaderboard, "", bytes.NewReader(frame(nil))))
+	assert.Equal(t, "3", status)
+	assert.Equal(t, "campaign_name is required", message)
+}
+
+func TestWatchLeaderboard(t *testing.T) {
+	scoreHub := hub.New()
+	url, client := setupServer(t, nil, scoreHub)
+
+	// the request stays open, as a client of a server stream leaves it
+	body, requestWriter := io.Pipe()
+	defer func() {
+		_ = requestWriter.Close()
+	}()
+	go func() {
+		_, _ = requestWriter.Write(frame(appendString(nil, 1, campaignName)))
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	resp, err := client.Do(newCall(t, url, MethodWatchLeaderboard, "", body).WithContext(ctx))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	assert.Eventually(t, func() bool {
+		return scoreHub.SubscriberCount(campaignName) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	delta := types.ScoreDelta{CampaignName: campaignName, ScpName: "GitHub", LoginName: "loginName", TeamName: "teamName", Delta: -2.5}
+	scoreHub.Publish(types.ScoreDelta{CampaignName: "otherCampaign", Delta: 5})
+	scoreHub.Publish(delta)
+
+	msg, err := readMessage(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, encodeScoreDelta(delta), msg)
+
+	cancel()
+	_ = resp.Body.Close()
+	assert.Eventually(t, func() bool {
+		return scoreHub.SubscriberCount(campaignName) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestEncodeStatusMessage(t *testing.T) {
+	assert.Equal(t, "100%25 d%C3%A9j%C3%A0 vu%0A", encodeStatusMessage("100% déjà vu\n"))
+}
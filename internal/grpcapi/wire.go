@@ -0,0 +1,214 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package grpcapi
+
+import (
+	"fmt"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+)
+
+// fieldReader reads the fields of an encoded protobuf message, one at a time.
+type fieldReader struct {
+	buf []byte
+	err error
+}
+
+// next reads the number and wire type of the next field, false once there are no more fields or a field is invalid.
+func (r *fieldReader) next() (number protowire.Number, wireType protowire.Type, ok bool) {
+	if r.err != nil || len(r.buf) == 0 {
+		return
+	}
+	number, wireType, n := protowire.ConsumeTag(r.buf)
+	if !r.consumed(n) {
+		return
+	}
+	return number, wireType, true
+}
+
+// consumed moves past the n bytes a protowire.Consume function read, or keeps its error when n is negative.
+func (r *fieldReader) consumed(n int) bool {
+	if n < 0 {
+		r.err = protowire.ParseError(n)
+		return false
+	}
+	r.buf = r.buf[n:]
+	return true
+}
+
+// int32 reads an int32 field, which has negative values sign extended to 64 bits
+func (r *fieldReader) int32(wireType protowire.Type) int {
+	if !r.expect(wireType, protowire.VarintType) {
+		return 0
+	}
+	value, n := protowire.ConsumeVarint(r.buf)
+	if !r.consumed(n) {
+		return 0
+	}
+	return int(int32(value))
+}
+
+func (r *fieldReader) string(wireType protowire.Type) string {
+	return string(r.bytes(wireType))
+}
+
+func (r *fieldReader) double(wireType protowire.Type) float64 {
+	if !r.expect(wireType, protowire.Fixed64Type) {
+		return 0
+	}
+	value, n := protowire.ConsumeFixed64(r.buf)
+	if !r.consumed(n) {
+		return 0
+	}
+	return math.Float64frombits(value)
+}
+
+// bytes reads a length delimited field, a string or a nested message.
+func (r *fieldReader) bytes(wireType protowire.Type) []byte {
+	if !r.expect(wireType, protowire.BytesType) {
+		return nil
+	}
+	value, n := protowire.ConsumeBytes(r.buf)
+	if !r.consumed(n) {
+		return nil
+	}
+	return value
+}
+
+func (r *fieldReader) expect(wireType, expected protowire.Type) bool {
+	if wireType != expected {
+		r.err = fmt.Errorf("invalid wire type %d, expected %d", wireType, expected)
+	}
+	return r.err == nil
+}
+
+// skip reads past a field of a number the message does not know, so a newer client can send fields added since.
+func (r *fieldReader) skip(number protowire.Number, wireType protowire.Type) {
+	r.consumed(protowire.ConsumeFieldValue(number, wireType, r.buf))
+}
+
+// decodeScoringMessage reads a bbash.v1.ScoringMessage into the current types.ScoringMessage.
+func decodeScoringMessage(buf []byte) (msg types.ScoringMessage, err error) {
+	r := &fieldReader{buf: buf}
+	for number, wireType, ok := r.next(); ok; number, wireType, ok = r.next() {
+		switch number {
+		case 1:
+			msg.EventSource = r.string(wireType)
+		case 2:
+			msg.RepoOwner = r.string(wireType)
+		case 3:
+			msg.RepoName = r.string(wireType)
+		case 4:
+			msg.TriggerUser = r.string(wireType)
+		case 5:
+			msg.TotalFixed = r.int32(wireType)
+		case 6:
+			if nested := r.bytes(wireType); r.err == nil {
+				var bugCount types.BugCount
+				bugCount, r.err = decodeBugCount(nested)
+				msg.BugCounts = append(msg.BugCounts, bugCount)
+			}
+		case 7:
+			msg.PullRequest = r.int32(wireType)
+		case 8:
+			msg.TotalIntroduced = r.int32(wireType)
+		case 9:
+			msg.Fingerprints = append(msg.Fingerprints, r.string(wireType))
+		case 10:
+			msg.CoAuthors = append(msg.CoAuthors, r.string(wireType))
+		default:
+			r.skip(number, wireType)
+		}
+	}
+	if r.err != nil {
+		return types.ScoringMessage{}, r.err
+	}
+	msg.SchemaVersion = types.ScoringSchemaVersion
+	return
+}
+
+func decodeBugCount(buf []byte) (bugCount types.BugCount, err error) {
+	r := &fieldReader{buf: buf}
+	for number, wireType, ok := r.next(); ok; number, wireType, ok = r.next() {
+		switch number {
+		case 1:
+			bugCount.Type = r.string(wireType)
+		case 2:
+			bugCount.Count = r.double(wireType)
+		default:
+			r.skip(number, wireType)
+		}
+	}
+	return bugCount, r.err
+}
+
+// decodeWatchLeaderboardRequest reads a bbash.v1.WatchLeaderboardRequest, returning its campaign_name.
+func decodeWatchLeaderboardRequest(buf []byte) (campaignName string, err error) {
+	r := &fieldReader{buf: buf}
+	for number, wireType, ok := r.next(); ok; number, wireType, ok = r.next() {
+		if number == 1 {
+			campaignName = r.string(wireType)
+		} else {
+			r.skip(number, wireType)
+		}
+	}
+	return campaignName, r.err
+}
+
+// the append functions leave out fields of the zero value, as proto3 does
+
+func appendString(buf []byte, number protowire.Number, value string) []byte {
+	if value == "" {
+		return buf
+	}
+	buf = protowire.AppendTag(buf, number, protowire.BytesType)
+	return protowire.AppendString(buf, value)
+}
+
+func appendInt32(buf []byte, number protowire.Number, value int) []byte {
+	if value == 0 {
+		return buf
+	}
+	buf = protowire.AppendTag(buf, number, protowire.VarintType)
+	return protowire.AppendVarint(buf, uint64(int64(int32(value))))
+}
+
+func appendDouble(buf []byte, number protowire.Number, value float64) []byte {
+	if value == 0 {
+		return buf
+	}
+	buf = protowire.AppendTag(buf, number, protowire.Fixed64Type)
+	return protowire.AppendFixed64(buf, math.Float64bits(value))
+}
+
+// encodeSubmitScoringMessageResponse writes a bbash.v1.SubmitScoringMessageResponse.
+func encodeSubmitScoringMessageResponse(scored int) []byte {
+	return appendInt32(nil, 1, scored)
+}
+
+// encodeScoreDelta writes a bbash.v1.ScoreDelta.
+func encodeScoreDelta(delta types.ScoreDelta) (buf []byte) {
+	buf = appendString(buf, 1, delta.CampaignName)
+	buf = appendString(buf, 2, delta.ScpName)
+	buf = appendString(buf, 3, delta.LoginName)
+	buf = appendString(buf, 4, delta.TeamName)
+	return appendDouble(buf, 5, delta.Delta)
+}
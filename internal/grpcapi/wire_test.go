@@ -0,0 +1,96 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package grpcapi
+
+import (
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDecodeScoringMessage(t *testing.T) {
+	// the encoding of event_source "GitHub", fixed_bugs 2, fixed_bug_types {type "XSS" count 1.5},
+	// pull_request_id -1, fixed_bug_fingerprints "a" and "b", co_authors "c", and fields 20 and 21, a group, unknown to
+	// this version
+	buf := []byte{
+		0x0a, 0x06, 'G', 'i', 't', 'H', 'u', 'b',
+		0x28, 0x02,
+		0x32, 0x0e, 0x0a, 0x03, 'X', 'S', 'S', 0x11, 0, 0, 0, 0, 0, 0, 0xf8, 0x3f,
+		0x38, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01,
+		0x4a, 0x01, 'a', 0x4a, 0x01, 'b',
+		0x52, 0x01, 'c',
+		0xa0, 0x01, 0x07,
+		0xab, 0x01, 0x08, 0x01, 0xac, 0x01,
+	}
+	msg, err := decodeScoringMessage(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, types.ScoringMessage{
+		SchemaVersion: types.ScoringSchemaVersion,
+		EventSource:   "GitHub",
+		TotalFixed:    2,
+		BugCounts:     []types.BugCount{{Type: "XSS", Count: 1.5}},
+		PullRequest:   -1,
+		Fingerprints:  []string{"a", "b"},
+		CoAuthors:     []string{"c"},
+	}, msg)
+}
+
+func TestDecodeScoringMessageInvalid(t *testing.T) {
+	for _, test := range []struct {
+		buf []byte
+		err string
+	}{
+		{[]byte{0x0a, 0x06, 'G'}, "unexpected EOF"},
+		{[]byte{0x08, 0x01}, "invalid wire type 0, expected 2"},
+		{[]byte{0x28}, "unexpected EOF"},
+		{[]byte{0x28, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02}, "variable length integer overflow"},
+		{[]byte{0x00}, "invalid field number"},
+		{[]byte{0x33}, "invalid wire type 3, expected 2"},
+		// field 20, unknown to this version, as a group that does not end
+		{[]byte{0xa3, 0x01}, "unexpected EOF"},
+		{[]byte{0xa6, 0x01}, "cannot parse reserved wire type"},
+		{[]byte{0x32, 0x02, 0x11, 0x00}, "unexpected EOF"},
+	} {
+		// protowire errors have a prefix that varies between builds, so only their text is compared
+		_, err := decodeScoringMessage(test.buf)
+		if assert.Error(t, err, "%x", test.buf) {
+			assert.Contains(t, err.Error(), test.err, "%x", test.buf)
+		}
+	}
+}
+
+func TestDecodeWatchLeaderboardRequest(t *testing.T) {
+	campaignName, err := decodeWatchLeaderboardRequest([]byte{0x0a, 0x02, 'c', '1', 0x10, 0x01})
+	assert.NoError(t, err)
+	assert.Equal(t, "c1", campaignName)
+}
+
+func TestEncodeScoreDelta(t *testing.T) {
+	assert.Equal(t, []byte{
+		0x0a, 0x02, 'c', '1',
+		0x1a, 0x01, 'l',
+		0x29, 0, 0, 0, 0, 0, 0, 0x04, 0xc0,
+	}, encodeScoreDelta(types.ScoreDelta{CampaignName: "c1", LoginName: "l", Delta: -2.5}))
+}
+
+func TestEncodeSubmitScoringMessageResponse(t *testing.T) {
+	assert.Equal(t, []byte{0x08, 0x96, 0x01}, encodeSubmitScoringMessageResponse(150))
+	assert.Equal(t, []byte{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}, encodeSubmitScoringMessageResponse(-1))
+}
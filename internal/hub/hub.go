@@ -0,0 +1,87 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package hub
+
+import (
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"sync"
+)
+
+// subscriberBuffer is how many deltas a slow subscriber can fall behind before deltas are dropped for it.
+const subscriberBuffer = 32
+
+// Hub fans score deltas out to the subscribers of each campaign. Publish never blocks on a subscriber.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan types.ScoreDelta]bool
+}
+
+func New() *Hub {
+	return &Hub{subscribers: make(map[string]map[chan types.ScoreDelta]bool)}
+}
+
+// Subscribe returns a channel receiving the score deltas of the campaign. Call Unsubscribe when done with it.
+func (h *Hub) Subscribe(campaignName string) (deltas chan types.ScoreDelta) {
+	deltas = make(chan types.ScoreDelta, subscriberBuffer)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers[campaignName] == nil {
+		h.subscribers[campaignName] = make(map[chan types.ScoreDelta]bool)
+	}
+	h.subscribers[campaignName][deltas] = true
+	return
+}
+
+// Unsubscribe stops delivery to the channel, and closes it.
+func (h *Hub) Unsubscribe(campaignName string, deltas chan types.ScoreDelta) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[campaignName][deltas]; !ok {
+		return
+	}
+	delete(h.subscribers[campaignName], deltas)
+	if len(h.subscribers[campaignName]) == 0 {
+		delete(h.subscribers, campaignName)
+	}
+	close(deltas)
+}
+
+// Publish sends the delta to every subscriber of its campaign, and returns how many subscribers it was dropped for
+// because they were too far behind.
+func (h *Hub) Publish(delta types.ScoreDelta) (dropped int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for deltas := range h.subscribers[delta.CampaignName] {
+		select {
+		case deltas <- delta:
+		default:
+			dropped++
+		}
+	}
+	return
+}
+
+// SubscriberCount is the number of subscribers to the campaign.
+func (h *Hub) SubscriberCount(campaignName string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers[campaignName])
+}
@@ -0,0 +1,70 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package hub
+
+import (
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const campaignName = "myCampaignName"
+
+func TestPublishNoSubscribers(t *testing.T) {
+	h := New()
+	assert.Equal(t, 0, h.Publish(types.ScoreDelta{CampaignName: campaignName, Delta: 1}))
+}
+
+func TestPublishOnlyToCampaignSubscribers(t *testing.T) {
+	h := New()
+	deltas := h.Subscribe(campaignName)
+	otherDeltas := h.Subscribe("otherCampaign")
+
+	delta := types.ScoreDelta{CampaignName: campaignName, LoginName: "loginName", Delta: 3}
+	assert.Equal(t, 0, h.Publish(delta))
+
+	assert.Equal(t, delta, <-deltas)
+	assert.Equal(t, 0, len(otherDeltas))
+}
+
+func TestPublishDropsForSlowSubscriber(t *testing.T) {
+	h := New()
+	h.Subscribe(campaignName)
+
+	for i := 0; i < subscriberBuffer; i++ {
+		assert.Equal(t, 0, h.Publish(types.ScoreDelta{CampaignName: campaignName, Delta: float64(i)}))
+	}
+	assert.Equal(t, 1, h.Publish(types.ScoreDelta{CampaignName: campaignName, Delta: -1}))
+}
+
+func TestUnsubscribe(t *testing.T) {
+	h := New()
+	deltas := h.Subscribe(campaignName)
+	assert.Equal(t, 1, h.SubscriberCount(campaignName))
+
+	h.Unsubscribe(campaignName, deltas)
+	assert.Equal(t, 0, h.SubscriberCount(campaignName))
+	_, open := <-deltas
+	assert.False(t, open)
+
+	// a second unsubscribe is harmless
+	h.Unsubscribe(campaignName, deltas)
+	assert.Equal(t, 0, h.Publish(types.ScoreDelta{CampaignName: campaignName, Delta: 1}))
+}
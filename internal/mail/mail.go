@@ -0,0 +1,100 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"go.uber.org/zap"
+	"net/smtp"
+	"strings"
+)
+
+// Message is a plain text email to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails.
+type Mailer interface {
+	Send(msg Message)
+}
+
+// SMTPMailer is the Mailer that sends through an SMTP server, such as the SMTP interface of Amazon SES.
+type SMTPMailer struct {
+	addr     string
+	auth     smtp.Auth
+	from     string
+	logger   *zap.Logger
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+var _ Mailer = (*SMTPMailer)(nil)
+
+// NewSMTP returns a mailer sending from the from address through the server at host:port. No username means the
+// server does not need authentication.
+func NewSMTP(logger *zap.Logger, host string, port int, username, password, from string) *SMTPMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPMailer{
+		addr:     fmt.Sprintf("%s:%d", host, port),
+		auth:     auth,
+		from:     from,
+		logger:   logger,
+		sendMail: smtp.SendMail,
+	}
+}
+
+// Send sends the email in the background, so a slow mail server never holds up the caller. A failure is only logged.
+func (m *SMTPMailer) Send(msg Message) {
+	go func() {
+		_ = m.send(msg)
+	}()
+}
+
+func (m *SMTPMailer) send(msg Message) (err error) {
+	to := headerValue(msg.To)
+	err = m.sendMail(m.addr, m.auth, m.from, []string{to}, format(m.from, msg))
+	if err != nil {
+		m.logger.Error("email error", zap.String("to", to), zap.String("subject", msg.Subject), zap.Error(err))
+	}
+	return
+}
+
+// headerValue removes line breaks, so a value cannot add headers of its own
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+func format(from string, msg Message) []byte {
+	var b bytes.Buffer
+	b.WriteString("From: " + headerValue(from) + "\r\n")
+	b.WriteString("To: " + headerValue(msg.To) + "\r\n")
+	b.WriteString("Subject: " + headerValue(msg.Subject) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
@@ -0,0 +1,77 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package mail
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"net/smtp"
+	"testing"
+)
+
+const from = "bugbash@example.com"
+
+func TestFormat(t *testing.T) {
+	msg := Message{To: "participant@example.com", Subject: "Welcome\r\nBcc: someone@example.com", Body: "Hello\nthere"}
+	assert.Equal(t, "From: bugbash@example.com\r\n"+
+		"To: participant@example.com\r\n"+
+		"Subject: WelcomeBcc: someone@example.com\r\n"+
+		"MIME-Version: 1.0\r\n"+
+		"Content-Type: text/plain; charset=UTF-8\r\n"+
+		"\r\n"+
+		"Hello\r\nthere", string(format(from, msg)))
+}
+
+func TestNewSMTPNoAuth(t *testing.T) {
+	m := NewSMTP(zaptest.NewLogger(t), "smtp.example.com", 25, "", "", from)
+	assert.Equal(t, "smtp.example.com:25", m.addr)
+	assert.Nil(t, m.auth)
+}
+
+func TestSend(t *testing.T) {
+	m := NewSMTP(zaptest.NewLogger(t), "email-smtp.us-east-1.amazonaws.com", 587, "myUser", "myPassword", from)
+	assert.NotNil(t, m.auth)
+
+	var sentTo []string
+	var sentMsg []byte
+	m.sendMail = func(addr string, a smtp.Auth, f string, to []string, msg []byte) error {
+		assert.Equal(t, "email-smtp.us-east-1.amazonaws.com:587", addr)
+		assert.Equal(t, from, f)
+		sentTo = to
+		sentMsg = msg
+		return nil
+	}
+
+	msg := Message{To: "participant@example.com", Subject: "Welcome", Body: "Hello"}
+	assert.NoError(t, m.send(msg))
+	assert.Equal(t, []string{"participant@example.com"}, sentTo)
+	assert.Equal(t, format(from, msg), sentMsg)
+}
+
+func TestSendError(t *testing.T) {
+	m := NewSMTP(zaptest.NewLogger(t), "smtp.example.com", 25, "", "", from)
+	forcedError := fmt.Errorf("forced smtp error")
+	m.sendMail = func(addr string, a smtp.Auth, f string, to []string, msg []byte) error {
+		return forcedError
+	}
+
+	assert.EqualError(t, m.send(Message{To: "participant@example.com"}), forcedError.Error())
+}
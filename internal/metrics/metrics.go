@@ -0,0 +1,161 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentType is that of the Prometheus text format, which WriteTo writes.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds, in seconds, of the request durations counted together.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// RouteMetrics counts the requests to each route, by how long they took and by the status class of their responses.
+type RouteMetrics struct {
+	mu      sync.Mutex
+	buckets []float64
+	routes  map[routeKey]*routeStats
+}
+
+type routeKey struct {
+	method string
+	route  string
+}
+
+type routeStats struct {
+	// bucketCounts has the count of the durations up to each bucket, but over the bucket before it
+	bucketCounts []uint64
+	count        uint64
+	sum          float64
+	// responses has the count of each status class, such as 2xx
+	responses map[string]uint64
+}
+
+// New returns metrics counting the request durations in the buckets, which are sorted upper bounds in seconds.
+func New(buckets []float64) *RouteMetrics {
+	return &RouteMetrics{buckets: buckets, routes: map[routeKey]*routeStats{}}
+}
+
+// Observe counts a request to the route, the pattern it matched such as /leaderboard/:campaignName, never its URL.
+func (m *RouteMetrics) Observe(method, route string, status int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := routeKey{method: method, route: route}
+	stats, ok := m.routes[key]
+	if !ok {
+		stats = &routeStats{bucketCounts: make([]uint64, len(m.buckets)), responses: map[string]uint64{}}
+		m.routes[key] = stats
+	}
+
+	seconds := duration.Seconds()
+	if i := sort.SearchFloat64s(m.buckets, seconds); i < len(m.buckets) {
+		stats.bucketCounts[i]++
+	}
+	stats.count++
+	stats.sum += seconds
+	stats.responses[statusClass(status)]++
+}
+
+// statusClass is 2xx for status 200 to 299, and so on
+func statusClass(status int) string {
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+// WriteTo writes the metrics in the Prometheus text format, sorted by route and method.
+func (m *RouteMetrics) WriteTo(w io.Writer) (n int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]routeKey, 0, len(m.routes))
+	for key := range m.routes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].method < keys[j].method
+	})
+
+	counter := &countingWriter{w: w}
+	out := bufio.NewWriter(counter)
+
+	_, _ = fmt.Fprintln(out, "# HELP bbash_http_request_duration_seconds How long the requests to each route took.")
+	_, _ = fmt.Fprintln(out, "# TYPE bbash_http_request_duration_seconds histogram")
+	for _, key := range keys {
+		stats := m.routes[key]
+		labels := fmt.Sprintf(`method="%s",route="%s"`, escapeLabel(key.method), escapeLabel(key.route))
+		var cumulative uint64
+		for i, bucket := range m.buckets {
+			cumulative += stats.bucketCounts[i]
+			_, _ = fmt.Fprintf(out, "bbash_http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n",
+				labels, strconv.FormatFloat(bucket, 'g', -1, 64), cumulative)
+		}
+		_, _ = fmt.Fprintf(out, "bbash_http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, stats.count)
+		_, _ = fmt.Fprintf(out, "bbash_http_request_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(stats.sum, 'g', -1, 64))
+		_, _ = fmt.Fprintf(out, "bbash_http_request_duration_seconds_count{%s} %d\n", labels, stats.count)
+	}
+
+	_, _ = fmt.Fprintln(out, "# HELP bbash_http_responses_total The responses from each route, by status class.")
+	_, _ = fmt.Fprintln(out, "# TYPE bbash_http_responses_total counter")
+	for _, key := range keys {
+		stats := m.routes[key]
+		classes := make([]string, 0, len(stats.responses))
+		for class := range stats.responses {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		for _, class := range classes {
+			_, _ = fmt.Fprintf(out, "bbash_http_responses_total{method=\"%s\",route=\"%s\",status=\"%s\"} %d\n",
+				escapeLabel(key.method), escapeLabel(key.route), class, stats.responses[class])
+		}
+	}
+
+	err = out.Flush()
+	n = counter.n
+	return
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = c.w.Write(p)
+	c.n += int64(n)
+	return
+}
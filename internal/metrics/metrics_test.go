@@ -0,0 +1,76 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package metrics
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestWriteToEmpty(t *testing.T) {
+	var out bytes.Buffer
+	n, err := New(DefaultBuckets).WriteTo(&out)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(out.Len()), n)
+	assert.Equal(t, `# HELP bbash_http_request_duration_seconds How long the requests to each route took.
+# TYPE bbash_http_request_duration_seconds histogram
+# HELP bbash_http_responses_total The responses from each route, by status class.
+# TYPE bbash_http_responses_total counter
+`, out.String())
+}
+
+func TestObserve(t *testing.T) {
+	m := New([]float64{.1, 1})
+	m.Observe("GET", "/leaderboard/:campaignName", 200, 50*time.Millisecond)
+	m.Observe("GET", "/leaderboard/:campaignName", 200, 100*time.Millisecond)
+	m.Observe("GET", "/leaderboard/:campaignName", 404, 500*time.Millisecond)
+	m.Observe("GET", "/leaderboard/:campaignName", 500, 2*time.Second)
+	m.Observe("PUT", "/admin/bug/add", 201, time.Second)
+
+	var out bytes.Buffer
+	n, err := m.WriteTo(&out)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(out.Len()), n)
+	assert.Equal(t, `# HELP bbash_http_request_duration_seconds How long the requests to each route took.
+# TYPE bbash_http_request_duration_seconds histogram
+bbash_http_request_duration_seconds_bucket{method="PUT",route="/admin/bug/add",le="0.1"} 0
+bbash_http_request_duration_seconds_bucket{method="PUT",route="/admin/bug/add",le="1"} 1
+bbash_http_request_duration_seconds_bucket{method="PUT",route="/admin/bug/add",le="+Inf"} 1
+bbash_http_request_duration_seconds_sum{method="PUT",route="/admin/bug/add"} 1
+bbash_http_request_duration_seconds_count{method="PUT",route="/admin/bug/add"} 1
+bbash_http_request_duration_seconds_bucket{method="GET",route="/leaderboard/:campaignName",le="0.1"} 2
+bbash_http_request_duration_seconds_bucket{method="GET",route="/leaderboard/:campaignName",le="1"} 3
+bbash_http_request_duration_seconds_bucket{method="GET",route="/leaderboard/:campaignName",le="+Inf"} 4
+bbash_http_request_duration_seconds_sum{method="GET",route="/leaderboard/:campaignName"} 2.65
+bbash_http_request_duration_seconds_count{method="GET",route="/leaderboard/:campaignName"} 4
+# HELP bbash_http_responses_total The responses from each route, by status class.
+# TYPE bbash_http_responses_total counter
+bbash_http_responses_total{method="PUT",route="/admin/bug/add",status="2xx"} 1
+bbash_http_responses_total{method="GET",route="/leaderboard/:campaignName",status="2xx"} 2
+bbash_http_responses_total{method="GET",route="/leaderboard/:campaignName",status="4xx"} 1
+bbash_http_responses_total{method="GET",route="/leaderboard/:campaignName",status="5xx"} 1
+`, out.String())
+}
+
+func TestEscapeLabel(t *testing.T) {
+	assert.Equal(t, `a\\b\"c\nd`, escapeLabel("a\\b\"c\nd"))
+}
@@ -0,0 +1,186 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/sonatype-nexus-community/bbash/internal/db"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// Backfill replays the scoring messages logged in a past window, one window at a time, without moving the poll
+// cursor. It is safe to backfill a window more than once: a message already processed, by the poll or an earlier
+// backfill, is skipped, and rescoring a pull request replaces its prior score rather than adding to it.
+type Backfill struct {
+	mu       sync.Mutex
+	progress *types.BackfillProgress
+}
+
+func NewBackfill() *Backfill {
+	return &Backfill{}
+}
+
+// Start begins replaying the window in the background. started is false, and progress is that of the running
+// backfill, if a backfill is already running.
+func (b *Backfill) Start(pollDb db.IDBPoll, scoreDb db.IScoreDB, from, to time.Time, processScoringMessage func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error)) (progress types.BackfillProgress, started bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.progress != nil && b.progress.Running {
+		return *b.progress, false
+	}
+
+	logger = pollDb.GetLogger()
+	b.progress = &types.BackfillProgress{From: from, To: to, Running: true, StartedOn: time.Now()}
+	go func() {
+		err := backfill(pollDb, scoreDb, from, to, processScoringMessage, b.report)
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		finishedOn := time.Now()
+		b.progress.Running = false
+		b.progress.FinishedOn = &finishedOn
+		if err != nil {
+			logger.Error("backfill error", zap.Any("progress", b.progress), zap.Error(err))
+			b.progress.Error = err.Error()
+		} else {
+			logger.Info("backfill complete", zap.Any("progress", b.progress))
+		}
+	}()
+	return *b.progress, true
+}
+
+// Progress returns the progress of the latest backfill, or nil if there has not been one.
+func (b *Backfill) Progress() *types.BackfillProgress {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.progress == nil {
+		return nil
+	}
+	progress := *b.progress
+	return &progress
+}
+
+func (b *Backfill) report(fetched, processed, skipped int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.progress.Fetched = fetched
+	b.progress.Processed = processed
+	b.progress.Skipped = skipped
+}
+
+// backfill fetches the window a page at a time, and processes each message that was not already processed. Each
+// message is journaled first, like a polled one, and scored as of its env base time, or the end of the window if it
+// has none. The scoring events of a page are inserted together, before its messages are acknowledged.
+func backfill(pollDb db.IDBPoll, scoreDb db.IScoreDB, from, to time.Time, processScoringMessage func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error), report func(fetched, processed, skipped int)) (err error) {
+	var fetched, processed, skipped int
+	pageCursor := ""
+	isDone := false
+	for !isDone {
+		var logPage []ddLog
+		isDone, pageCursor, logPage, _, err = fetchLogPage(from, to, &pageCursor)
+		if err != nil {
+			return
+		}
+		fetched += len(logPage)
+		report(fetched, processed, skipped)
+
+		batch := db.NewScoringEventBatch(scoreDb)
+		var journalIds []string
+		var pageErr error
+		for _, log := range logPage {
+			var journalId string
+			journalId, pageErr = backfillMessage(pollDb, batch, to, log, processScoringMessage)
+			if pageErr != nil {
+				break
+			}
+			if journalId == "" {
+				skipped++
+				report(fetched, processed, skipped)
+				continue
+			}
+			journalIds = append(journalIds, journalId)
+		}
+
+		// acknowledge the messages scored before any error, so they are not scored again by journal recovery
+		if len(journalIds) > 0 {
+			err = batch.Flush(context.Background())
+			if err != nil {
+				return
+			}
+			for _, journalId := range journalIds {
+				err = pollDb.AckJournalEntry(journalId, time.Now())
+				if err != nil {
+					return
+				}
+				processed++
+			}
+		}
+		if pageErr != nil {
+			err = pageErr
+			return
+		}
+		report(fetched, processed, skipped)
+	}
+	return
+}
+
+// backfillMessage journals and scores the message, unless it was already processed, when journalId is empty. A message
+// with a schema version this server can not read is journaled and dead lettered, and its journalId is empty too.
+func backfillMessage(pollDb db.IDBPoll, scoreDb db.IScoreDB, to time.Time, log ddLog, processScoringMessage func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error)) (journalId string, err error) {
+	alreadyProcessed, err := pollDb.SelectJournalEntryProcessed(log.Id)
+	if err != nil || alreadyProcessed {
+		return
+	}
+
+	scoredOn := log.Fields.envBaseTime
+	if scoredOn.IsZero() {
+		scoredOn = to
+	}
+
+	rawMessage := log.Fields.rawMessage
+	if rawMessage == nil {
+		rawMessage, err = json.Marshal(log.Fields.scoringMessage)
+		if err != nil {
+			return
+		}
+	}
+	journalId, err = pollDb.InsertJournalEntry(log.Id, rawMessage, scoredOn)
+	if err != nil {
+		return
+	}
+
+	if log.Fields.versionErr != nil {
+		_, err = pollDb.FailJournalEntry(journalId, log.Fields.versionErr.Error(), 1, time.Now())
+		journalId = ""
+		return
+	}
+
+	msg := log.Fields.scoringMessage
+	err = processMessage(processScoringMessage, scoreDb, scoredOn, &msg)
+	if err != nil {
+		// left in the journal, unacknowledged
+		journalId = ""
+	}
+	return
+}
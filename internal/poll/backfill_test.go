@@ -0,0 +1,202 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/DataDog/datadog-api-client-go/api/v2/datadog"
+	"github.com/sonatype-nexus-community/bbash/internal/db"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// setupMockDDogLogs serves a single page of logs, with the given ids
+func setupMockDDogLogs(t *testing.T, logIds ...string) (closeDDog func()) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+
+		var logs []datadog.Log
+		for i := range logIds {
+			logs = append(logs, datadog.Log{
+				Id: &logIds[i],
+				Attributes: &datadog.LogAttributes{
+					Attributes: map[string]interface{}{
+						qryEnv: map[string]interface{}{
+							qryEnvExtraJsonFields: map[string]interface{}{
+								"eventSource": "myEventSource",
+							},
+						},
+					},
+				},
+			})
+		}
+		jsonObj, err := json.Marshal(datadog.LogsListResponse{Data: &logs})
+		assert.NoError(t, err)
+		_, err = w.Write(jsonObj)
+		assert.NoError(t, err)
+	}))
+	urlTs, err := url.Parse(ts.URL)
+	assert.NoError(t, err)
+	closeApiClient := setupMockDDogApiClient(urlTs)
+	return func() {
+		closeApiClient()
+		ts.Close()
+	}
+}
+
+func TestBackfillFetchError(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+	urlTs, err := url.Parse(ts.URL)
+	assert.NoError(t, err)
+	closeApiClient := setupMockDDogApiClient(urlTs)
+	defer closeApiClient()
+
+	_, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	now := time.Now()
+	err = backfill(dbPoll, createMockScoreDb(t), now, now, nil, func(fetched, processed, skipped int) {})
+	assert.Error(t, err)
+}
+
+func TestBackfillProcessError(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	closeDDog := setupMockDDogLogs(t, "myLogId")
+	defer closeDDog()
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	db.SetupMockJournalProcessed(mock, "myLogId", false)
+	db.SetupMockJournalInsert(mock, "myLogId", "myJournalId")
+
+	forcedError := fmt.Errorf("forced backfill process error")
+	processScoringMessage := func(scoreDbCalled db.IScoreDB, nowCalled time.Time, msgCalled *types.ScoringMessage) (err error) {
+		return forcedError
+	}
+
+	now := time.Now()
+	err := backfill(dbPoll, createMockScoreDb(t), now, now, processScoringMessage, func(fetched, processed, skipped int) {})
+	assert.EqualError(t, err, forcedError.Error())
+	// failed message is left in the journal, unacknowledged
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBackfillSkipsProcessed(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	closeDDog := setupMockDDogLogs(t, "oldLogId", "newLogId")
+	defer closeDDog()
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	db.SetupMockJournalProcessed(mock, "oldLogId", true)
+	db.SetupMockJournalProcessed(mock, "newLogId", false)
+	db.SetupMockJournalInsert(mock, "newLogId", "myJournalId")
+	db.SetupMockJournalAck(mock, "myJournalId")
+
+	scoreDb := createMockScoreDb(t)
+	to := time.Now()
+	from := to.Add(-time.Hour)
+	processScoringMessage := func(scoreDbCalled db.IScoreDB, nowCalled time.Time, msgCalled *types.ScoringMessage) (err error) {
+		assert.Equal(t, scoreDb, scoreDbCalled.(*db.ScoringEventBatch).IScoreDB)
+		// no env base time, so scored as of the end of the window
+		assert.Equal(t, to, nowCalled)
+		assert.Equal(t, "myEventSource", msgCalled.EventSource)
+		return
+	}
+
+	var fetched, processed, skipped int
+	err := backfill(dbPoll, scoreDb, from, to, processScoringMessage, func(f, p, s int) {
+		fetched, processed, skipped = f, p, s
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fetched)
+	assert.Equal(t, 1, processed)
+	assert.Equal(t, 1, skipped)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBackfillMessageUnknownSchemaVersion(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	db.SetupMockJournalProcessed(mock, "newerLogId", false)
+	db.SetupMockJournalInsert(mock, "newerLogId", "newerJournalId")
+	db.SetupMockJournalFail(mock, "newerJournalId", 1, true)
+
+	log := ddLog{Id: "newerLogId", Fields: extraFields{rawMessage: []byte(`{"schemaVersion":99}`), versionErr: &UnknownSchemaVersionError{SchemaVersion: 99}}}
+	journalId, err := backfillMessage(dbPoll, createMockScoreDb(t), time.Now(), log, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "", journalId)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBackfillProgressNone(t *testing.T) {
+	assert.Nil(t, NewBackfill().Progress())
+}
+
+func TestBackfillStartAlreadyRunning(t *testing.T) {
+	now := time.Now()
+	b := NewBackfill()
+	b.progress = &types.BackfillProgress{From: now, To: now, Running: true, Fetched: 3}
+
+	progress, started := b.Start(nil, nil, now, now, nil)
+	assert.False(t, started)
+	assert.Equal(t, *b.progress, progress)
+}
+
+func TestBackfillStart(t *testing.T) {
+	closeDDog := setupMockDDogLogs(t, "myLogId")
+	defer closeDDog()
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	db.SetupMockJournalProcessed(mock, "myLogId", true)
+
+	to := time.Now()
+	from := to.Add(-time.Hour)
+	b := NewBackfill()
+	progress, started := b.Start(dbPoll, createMockScoreDb(t), from, to, nil)
+	assert.True(t, started)
+	assert.True(t, progress.Running)
+	assert.Equal(t, from, progress.From)
+	assert.Equal(t, to, progress.To)
+
+	assert.Eventually(t, func() bool {
+		return !b.Progress().Running
+	}, time.Second*5, time.Millisecond*10)
+	finished := b.Progress()
+	assert.Equal(t, 1, finished.Fetched)
+	assert.Equal(t, 1, finished.Skipped)
+	assert.Equal(t, "", finished.Error)
+	assert.NotNil(t, finished.FinishedOn)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,117 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"go.uber.org/zap"
+	"sort"
+)
+
+// bugCountAdapter normalizes one entry of the fixed bug types sent by a scanner. It returns false when the entry is
+// not in the shape it reads, so the next adapter can try.
+type bugCountAdapter func(key string, value interface{}) (counts []types.BugCount, ok bool)
+
+// bugCountAdapters are tried in order for each entry of the fixed bug types.
+var bugCountAdapters = []bugCountAdapter{
+	flatBugCount,
+	nestedBugCounts,
+}
+
+// flatBugCount reads a bug type and its count, as most scanners send them, e.g. {"G104": 2}.
+func flatBugCount(key string, value interface{}) (counts []types.BugCount, ok bool) {
+	count, ok := value.(float64)
+	if !ok {
+		return
+	}
+	return []types.BugCount{{Type: key, Count: count}}, true
+}
+
+// nestedBugCounts reads the bug types whose rule id contains dots, which the log pipeline splits into nested maps,
+// e.g. the Semgrep rule opt.semgrep.sprintf-host-port arrives as {"opt": {"semgrep": {"sprintf-host-port": 2}}}. The
+// bug type is the last part of the rule id.
+func nestedBugCounts(key string, value interface{}) (counts []types.BugCount, ok bool) {
+	nested, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for nestedKey, nestedValue := range nested {
+		nestedCounts, nestedOk := flatBugCount(nestedKey, nestedValue)
+		if !nestedOk {
+			nestedCounts, nestedOk = nestedBugCounts(nestedKey, nestedValue)
+		}
+		if !nestedOk {
+			return nil, false
+		}
+		counts = append(counts, nestedCounts...)
+	}
+	return counts, true
+}
+
+func adaptBugCount(key string, value interface{}) (counts []types.BugCount, ok bool) {
+	for _, adapter := range bugCountAdapters {
+		counts, ok = adapter(key, value)
+		if ok {
+			return
+		}
+	}
+	return
+}
+
+// parseBugCounts normalizes the fixed bug types of a payload into one count per bug type, ordered by bug type. A
+// payload journaled by this server after normalizing is already a list of counts. Entries no adapter can read are
+// logged and left out, so the rest of the pull request is still scored.
+func parseBugCounts(raw json.RawMessage) (bugCounts []types.BugCount, err error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return
+	}
+	if raw[0] == '[' {
+		err = json.Unmarshal(raw, &bugCounts)
+		return
+	}
+
+	var bugTypes map[string]interface{}
+	err = json.Unmarshal(raw, &bugTypes)
+	if err != nil {
+		return
+	}
+
+	totals := map[string]float64{}
+	for key, value := range bugTypes {
+		counts, ok := adaptBugCount(key, value)
+		if !ok {
+			logger.Error("skip unexpected bug count", zap.String("bugType", key), zap.Any("value", value))
+			continue
+		}
+		for _, count := range counts {
+			totals[count.Type] += count.Count
+		}
+	}
+	for bugType, count := range totals {
+		bugCounts = append(bugCounts, types.BugCount{Type: bugType, Count: count})
+	}
+	sort.Slice(bugCounts, func(i, j int) bool {
+		return bugCounts[i].Type < bugCounts[j].Type
+	})
+	return
+}
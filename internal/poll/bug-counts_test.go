@@ -0,0 +1,92 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"encoding/json"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFlatBugCount(t *testing.T) {
+	counts, ok := flatBugCount("G104", float64(2))
+	assert.True(t, ok)
+	assert.Equal(t, []types.BugCount{{Type: "G104", Count: 2}}, counts)
+}
+
+func TestFlatBugCountNotANumber(t *testing.T) {
+	counts, ok := flatBugCount("G104", "two")
+	assert.False(t, ok)
+	assert.Nil(t, counts)
+}
+
+func TestNestedBugCounts(t *testing.T) {
+	counts, ok := nestedBugCounts("opt", map[string]interface{}{
+		"semgrep": map[string]interface{}{"sprintf-host-port": float64(2)},
+	})
+	assert.True(t, ok)
+	assert.Equal(t, []types.BugCount{{Type: "sprintf-host-port", Count: 2}}, counts)
+}
+
+func TestNestedBugCountsNotAMap(t *testing.T) {
+	counts, ok := nestedBugCounts("opt", float64(2))
+	assert.False(t, ok)
+	assert.Nil(t, counts)
+}
+
+func TestNestedBugCountsUnexpectedLeaf(t *testing.T) {
+	counts, ok := nestedBugCounts("opt", map[string]interface{}{
+		"semgrep": map[string]interface{}{"sprintf-host-port": "two"},
+	})
+	assert.False(t, ok)
+	assert.Nil(t, counts)
+}
+
+func TestParseBugCountsNone(t *testing.T) {
+	for _, raw := range []string{``, `null`} {
+		bugCounts, err := parseBugCounts(json.RawMessage(raw))
+		assert.NoError(t, err)
+		assert.Nil(t, bugCounts)
+	}
+}
+
+func TestParseBugCountsNormalized(t *testing.T) {
+	bugCounts, err := parseBugCounts(json.RawMessage(`[{"type":"G104","count":2}]`))
+	assert.NoError(t, err)
+	assert.Equal(t, []types.BugCount{{Type: "G104", Count: 2}}, bugCounts)
+}
+
+func TestParseBugCountsNotAMap(t *testing.T) {
+	_, err := parseBugCounts(json.RawMessage(`"notAMap"`))
+	assert.EqualError(t, err, "json: cannot unmarshal string into Go value of type map[string]interface {}")
+}
+
+func TestParseBugCounts(t *testing.T) {
+	bugCounts, err := parseBugCounts(json.RawMessage(`{"ShellCheck":1,"G104":2,"bogus":"bogusValueType",
+		"opt":{"semgrep":{"sprintf-host-port":2,"G104":1}}}`))
+	assert.NoError(t, err)
+	// ordered by bug type, with the unexpected entry left out and the counts of a repeated bug type added together
+	assert.Equal(t, []types.BugCount{
+		{Type: "G104", Count: 3},
+		{Type: "ShellCheck", Count: 1},
+		{Type: "sprintf-host-port", Count: 2},
+	}, bugCounts)
+}
@@ -0,0 +1,78 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"sync"
+	"time"
+)
+
+// Controller pauses and resumes the poll. It is safe to call from multiple goroutines, and pausing a paused poll, or
+// resuming a running one, leaves the poll as it is.
+type Controller struct {
+	mu        sync.Mutex
+	start     func() (quit chan bool, errChan chan error, err error)
+	quit      chan bool
+	changedOn time.Time
+}
+
+// NewController returns a paused controller, which calls start to begin polling when it is resumed.
+func NewController(start func() (quit chan bool, errChan chan error, err error)) *Controller {
+	return &Controller{start: start, changedOn: time.Now()}
+}
+
+// Resume starts the poll, unless it is already running.
+func (c *Controller) Resume() (state types.PollState, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.quit == nil {
+		var quit chan bool
+		quit, _, err = c.start()
+		if err != nil {
+			return c.state(), err
+		}
+		c.quit = quit
+		c.changedOn = time.Now()
+	}
+	return c.state(), nil
+}
+
+// Pause stops the poll, unless it is already stopped. A poll in progress finishes first.
+func (c *Controller) Pause() (state types.PollState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.quit != nil {
+		close(c.quit)
+		c.quit = nil
+		c.changedOn = time.Now()
+	}
+	return c.state()
+}
+
+func (c *Controller) State() (state types.PollState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state()
+}
+
+func (c *Controller) state() types.PollState {
+	return types.PollState{Running: c.quit != nil, ChangedOn: c.changedOn}
+}
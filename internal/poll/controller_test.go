@@ -0,0 +1,112 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func setupMockStart(startErr error) (start func() (quit chan bool, errChan chan error, err error), starts *int, quits *[]chan bool) {
+	starts = new(int)
+	quits = &[]chan bool{}
+	start = func() (quit chan bool, errChan chan error, err error) {
+		*starts++
+		if startErr != nil {
+			return nil, nil, startErr
+		}
+		quit = make(chan bool)
+		*quits = append(*quits, quit)
+		return quit, make(chan error), nil
+	}
+	return
+}
+
+func TestControllerNew(t *testing.T) {
+	start, starts, _ := setupMockStart(nil)
+	c := NewController(start)
+
+	assert.False(t, c.State().Running)
+	assert.False(t, c.State().ChangedOn.IsZero())
+	assert.Equal(t, 0, *starts)
+}
+
+func TestControllerResumeError(t *testing.T) {
+	forcedError := fmt.Errorf("forced start error")
+	start, _, _ := setupMockStart(forcedError)
+	c := NewController(start)
+
+	state, err := c.Resume()
+	assert.EqualError(t, err, forcedError.Error())
+	assert.False(t, state.Running)
+}
+
+func TestControllerResumeTwice(t *testing.T) {
+	start, starts, _ := setupMockStart(nil)
+	c := NewController(start)
+
+	state, err := c.Resume()
+	assert.NoError(t, err)
+	assert.True(t, state.Running)
+
+	again, err := c.Resume()
+	assert.NoError(t, err)
+	assert.Equal(t, state, again)
+	assert.Equal(t, 1, *starts)
+}
+
+func TestControllerPauseTwice(t *testing.T) {
+	start, _, quits := setupMockStart(nil)
+	c := NewController(start)
+	_, err := c.Resume()
+	assert.NoError(t, err)
+
+	state := c.Pause()
+	assert.False(t, state.Running)
+	_, open := <-(*quits)[0]
+	assert.False(t, open)
+
+	// closing the quit channel again would panic
+	assert.Equal(t, state, c.Pause())
+}
+
+func TestControllerConcurrent(t *testing.T) {
+	start, _, _ := setupMockStart(nil)
+	c := NewController(start)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, _ = c.Resume()
+		}()
+		go func() {
+			defer wg.Done()
+			c.Pause()
+		}()
+	}
+	wg.Wait()
+
+	c.Pause()
+	assert.False(t, c.State().Running)
+}
@@ -22,18 +22,28 @@ package poll
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/DataDog/datadog-api-client-go/api/v2/datadog"
+	"github.com/sonatype-nexus-community/bbash/internal/config"
 	"github.com/sonatype-nexus-community/bbash/internal/db"
+	"github.com/sonatype-nexus-community/bbash/internal/errreport"
 	"github.com/sonatype-nexus-community/bbash/internal/types"
 	"go.uber.org/zap"
 	"net/http"
 	"net/http/httputil"
-	"os"
+	"runtime/debug"
+	"sync"
 	"time"
 )
 
-var logger *zap.Logger
+var logger = zap.NewNop()
+
+// reporter is sent the scoring messages that fail, so a scoring failure does not go unnoticed
+var reporter errreport.Reporter = errreport.NoReporter{}
+
+// inMaintenance reports whether changes are paused for maintenance, when the poll leaves the messages in datadog
+var inMaintenance = func() bool { return false }
 
 var dogApiClient IDogApiClient
 
@@ -46,22 +56,80 @@ type IDogApiClient interface {
 }
 
 type DogApiClient struct {
+	mu     sync.RWMutex
+	apiKey string
+	appKey string
 }
 
 var _ IDogApiClient = (*DogApiClient)(nil)
 
+// dogApiClientMu guards dogApiClient while its keys are rotated
+var dogApiClientMu sync.Mutex
+
+// Configure sets the datadog keys the poll reads the logs with.
+func Configure(cfg *config.Config) {
+	dogApiClientMu.Lock()
+	defer dogApiClientMu.Unlock()
+	dogApiClient = &DogApiClient{apiKey: cfg.DDClientApiKey, appKey: cfg.DDClientAppKey}
+}
+
+// SetKeys rotates the datadog keys, which the next poll reads the logs with. An empty key is left as it was.
+func SetKeys(apiKey, appKey string) {
+	dogApiClientMu.Lock()
+	defer dogApiClientMu.Unlock()
+	client, ok := dogApiClient.(*DogApiClient)
+	if !ok {
+		return
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if apiKey != "" {
+		client.apiKey = apiKey
+	}
+	if appKey != "" {
+		client.appKey = appKey
+	}
+}
+
+// SetReporter sets the error tracker the scoring messages that fail are reported to.
+func SetReporter(r errreport.Reporter) {
+	reporter = r
+}
+
+// SetMaintenance sets the check of whether changes are paused for maintenance.
+func SetMaintenance(check func() bool) {
+	inMaintenance = check
+}
+
+// processMessage scores the message, recovering a panic as an error, so a bad message can not stop the poll. A
+// failure is reported with the message attached.
+func processMessage(processScoringMessage func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error), scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &errreport.PanicError{Value: r, Stack: debug.Stack()}
+			logger.Error("panic processing scoring message", zap.Any("msg", msg), zap.Error(err))
+		}
+		if err != nil {
+			reporter.Report(err, map[string]interface{}{"scoringMessage": *msg})
+		}
+	}()
+	return processScoringMessage(scoreDb, now, msg)
+}
+
 func (c *DogApiClient) getDDApiClient() (context.Context, *datadog.APIClient) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	ctx := context.WithValue(
 		context.Background(),
 		datadog.ContextAPIKeys,
 		map[string]datadog.APIKey{
 			// API Key
 			"apiKeyAuth": {
-				Key: os.Getenv("DD_CLIENT_API_KEY"),
+				Key: c.apiKey,
 			},
 			// Application Key
 			"appKeyAuth": {
-				Key: os.Getenv("DD_CLIENT_APP_KEY"),
+				Key: c.appKey,
 			},
 		},
 	)
@@ -81,10 +149,12 @@ const qryFldFixedBugs = "fixed-bugs"
 // should be negative
 const pollFudgeSeconds = -5
 
-func pollTheDog(pollDB db.IDBPoll, priorPollTime, now time.Time) (logs []ddLog, err error) {
+// pollTheDog reads the logs sent since the last poll. The poll is returned for commitPoll, which moves the last poll
+// time on only once the logs are journaled.
+func pollTheDog(pollDB db.IDBPoll, priorPollTime, now time.Time) (logs []ddLog, poll types.Poll, err error) {
 
 	// get last poll time from database
-	poll := pollDB.NewPoll()
+	poll = pollDB.NewPoll()
 	err = pollDB.SelectPoll(&poll)
 	if err != nil {
 		return
@@ -125,19 +195,17 @@ func pollTheDog(pollDB db.IDBPoll, priorPollTime, now time.Time) (logs []ddLog,
 		zap.Duration("totalFetchDuration", totalFetchDuration),
 		zap.Int("maxLogsPerPage", maxLogsPerPage),
 	)
+	return
+}
 
-	// Update Poll completed time
+// commitPoll records the poll as completed at now, so the next poll starts from there.
+func commitPoll(pollDB db.IDBPoll, poll *types.Poll, logs []ddLog, now time.Time) (err error) {
 	poll.LastPolled = now
-	if logCount > 0 {
-		poll.EnvBaseTime = logs[logCount-1].Fields.envBaseTime
+	if len(logs) > 0 {
+		poll.EnvBaseTime = logs[len(logs)-1].Fields.envBaseTime
 	}
 	poll.LastPollCompleted = time.Now()
-	err = pollDB.UpdatePoll(&poll)
-	if err != nil {
-		return
-	}
-
-	return
+	return pollDB.UpdatePoll(poll)
 }
 
 const maxLogsPerPage = 500
@@ -261,9 +329,14 @@ func processResponseData(responseData []datadog.Log) (logs []ddLog, err error) {
 				if err != nil {
 					return
 				}
-				extra.scoringMessage = types.ScoringMessage{}
-				err = json.Unmarshal(jsonMap, &extra.scoringMessage)
-				if err != nil {
+				extra.rawMessage = jsonMap
+				extra.scoringMessage, err = DecodeScoringMessage(jsonMap)
+				if errors.As(err, &extra.versionErr) {
+					// kept rather than fail the poll, so it is journaled and dead lettered, and the messages this
+					// server can read are still scored
+					logger.Error("unknown scoring message schema version", zap.String("logId", logStruct.Id), zap.Any("valueMap", valueMap), zap.Error(err))
+					err = nil
+				} else if err != nil {
 					logger.Error("error unmarshalling scoring message", zap.Any("valueMap", valueMap))
 					return
 				}
@@ -281,7 +354,10 @@ func processResponseData(responseData []datadog.Log) (logs []ddLog, err error) {
 
 type extraFields struct {
 	envBaseTime    time.Time
+	rawMessage     []byte
 	scoringMessage types.ScoringMessage
+	// versionErr is set when the message has a schema version this server can not read
+	versionErr *UnknownSchemaVersionError
 }
 
 type ddLog struct {
@@ -289,7 +365,9 @@ type ddLog struct {
 	Fields extraFields
 }
 
-// ChaseTail will loop every given interval, polling dataDog for new scoring data
+// ChaseTail will loop every given interval, polling dataDog for new scoring data. The polled messages are journaled
+// before the poll time moves on, so a crash or a failed journal insert has the next poll read them again, and each
+// interval first retries the journaled messages that were not yet processed.
 func ChaseTail(pollDb db.IDBPoll, scoreDb db.IScoreDB, seconds time.Duration, processScoringMessage func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (pollErr error)) (quit chan bool, errChan chan error) {
 	logger = pollDb.GetLogger()
 	logger.Info("poll ticker starting", zap.Duration("chase tail seconds", seconds))
@@ -305,9 +383,24 @@ func ChaseTail(pollDb db.IDBPoll, scoreDb db.IScoreDB, seconds time.Duration, pr
 		for {
 			select {
 			case <-ticker.C:
+				if inMaintenance() {
+					// the prior poll time is kept, so the first poll after maintenance reads the messages sent during it
+					logger.Debug("poll skipped in maintenance")
+					continue
+				}
+				_, pollErr = RecoverJournal(pollDb, scoreDb, processScoringMessage)
+				if pollErr != nil {
+					logger.Error("error in journal retry chase", zap.Error(pollErr))
+					errCount++
+					if errCount < errBufferSize {
+						errChan <- pollErr
+					}
+				}
+
 				now := time.Now()
 				var logs []ddLog
-				logs, pollErr = pollTheDog(pollDb, priorPollTime, now)
+				var poll types.Poll
+				logs, poll, pollErr = pollTheDog(pollDb, priorPollTime, now)
 				if pollErr != nil {
 					logger.Error("error in polling chase", zap.Error(pollErr))
 					errCount++
@@ -316,10 +409,24 @@ func ChaseTail(pollDb db.IDBPoll, scoreDb db.IScoreDB, seconds time.Duration, pr
 					}
 					continue // continue allows polling to keep running when errors occur
 				}
+				var journalIds []string
+				journalIds, pollErr = journalLogs(pollDb, logs, now)
+				if pollErr == nil {
+					pollErr = commitPoll(pollDb, &poll, logs, now)
+				}
+				if pollErr != nil {
+					// the poll time is left as it was, so the next poll reads the messages again
+					logger.Error("error in journal chase", zap.Error(pollErr))
+					errCount++
+					if errCount < errBufferSize {
+						errChan <- pollErr
+					}
+					continue // continue allows polling to keep running when errors occur
+				}
 				// track actual poll time to avoid db write oddness
 				priorPollTime = now
 
-				pollErr = processLogs(scoreDb, logs, now, processScoringMessage)
+				pollErr = processLogs(pollDb, scoreDb, logs, journalIds, now, processScoringMessage)
 				if pollErr != nil {
 					logger.Error("error in process logs chase", zap.Error(pollErr))
 					errCount++
@@ -339,13 +446,140 @@ func ChaseTail(pollDb db.IDBPoll, scoreDb db.IScoreDB, seconds time.Duration, pr
 	return
 }
 
-func processLogs(scoreDb db.IScoreDB, logs []ddLog, nowPoll time.Time, processScoringMessage func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error)) (err error) {
-	for _, log := range logs {
+// journalLogs records every message before any processing begins, so nothing accepted here can be lost by a crash. The
+// journal ids are returned in the order of the logs.
+func journalLogs(pollDb db.IDBPoll, logs []ddLog, nowPoll time.Time) (journalIds []string, err error) {
+	journalIds = make([]string, len(logs))
+	for i, log := range logs {
+		rawMessage := log.Fields.rawMessage
+		if rawMessage == nil {
+			rawMessage, err = json.Marshal(log.Fields.scoringMessage)
+			if err != nil {
+				return
+			}
+		}
+		journalIds[i], err = pollDb.InsertJournalEntry(log.Id, rawMessage, nowPoll)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// processLogs scores the journaled messages. Scoring events are inserted together, and a message is acknowledged once
+// its events are inserted. A message that fails is counted against its journal entry, for journal recovery to retry,
+// and the rest are still processed. The first failure is returned.
+func processLogs(pollDb db.IDBPoll, scoreDb db.IScoreDB, logs []ddLog, journalIds []string, nowPoll time.Time, processScoringMessage func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error)) (err error) {
+	batch := db.NewScoringEventBatch(scoreDb)
+	var processedIds []string
+	for i, log := range logs {
+		if log.Fields.versionErr != nil {
+			// it can not be scored here, so it is dead lettered, and kept in the journal with its error
+			_, failErr := pollDb.FailJournalEntry(journalIds[i], log.Fields.versionErr.Error(), 1, nowPoll)
+			if failErr != nil {
+				return failErr
+			}
+			continue
+		}
 		msg := log.Fields.scoringMessage
-		err = processScoringMessage(scoreDb, nowPoll, &msg)
+		processErr := processMessage(processScoringMessage, batch, nowPoll, &msg)
+		if processErr != nil {
+			logger.Error("error processing journal entry", zap.String("guid", journalIds[i]), zap.String("logId", log.Id), zap.Error(processErr))
+			failErr := failJournalEntry(pollDb, types.JournalEntry{Id: journalIds[i], LogId: log.Id}, processErr, journalMaxAttempts)
+			if failErr != nil {
+				return failErr
+			}
+			if err == nil {
+				err = processErr
+			}
+			continue
+		}
+		processedIds = append(processedIds, journalIds[i])
+	}
+	if len(processedIds) == 0 {
+		return
+	}
+
+	flushErr := batch.Flush(context.Background())
+	if flushErr != nil {
+		logger.Error("error inserting scoring events", zap.Int("events", batch.Len()), zap.Error(flushErr))
+		return flushErr
+	}
+	for _, journalId := range processedIds {
+		ackErr := pollDb.AckJournalEntry(journalId, time.Now())
+		if ackErr != nil {
+			return ackErr
+		}
+	}
+	return
+}
+
+// journalMaxAttempts is how many times journal recovery tries a message before dead lettering it
+const journalMaxAttempts = 3
+
+// RecoverJournal reprocesses any journaled messages that were never acknowledged, because the server stopped while
+// processing them or because processing failed. It runs at startup and on every poll interval. Each message is scored
+// as of the time it was originally received. A message that fails is counted against its entry and skipped, so it can
+// not hold back the rest, and is dead lettered after journalMaxAttempts failures, or at once if it can not be read.
+func RecoverJournal(pollDb db.IDBPoll, scoreDb db.IScoreDB, processScoringMessage func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error)) (recovered int, err error) {
+	logger = pollDb.GetLogger()
+
+	var entries []types.JournalEntry
+	entries, err = pollDb.SelectUnackedJournalEntries()
+	if err != nil {
+		return
+	}
+
+	failed := 0
+	for _, entry := range entries {
+		msg, decodeErr := DecodeScoringMessage(entry.RawMessage)
+		var versionErr *UnknownSchemaVersionError
+		if errors.As(decodeErr, &versionErr) {
+			// leave it unacknowledged, for a server that can read it
+			logger.Error("skip journal entry with unknown schema version", zap.Any("entry", entry), zap.Error(decodeErr))
+			continue
+		} else if decodeErr != nil {
+			logger.Error("error unmarshalling journal entry", zap.Any("entry", entry), zap.Error(decodeErr))
+			err = failJournalEntry(pollDb, entry, decodeErr, 1)
+			if err != nil {
+				return
+			}
+			failed++
+			continue
+		}
+
+		processErr := processMessage(processScoringMessage, scoreDb, entry.ReceivedOn, &msg)
+		if processErr != nil {
+			logger.Error("error reprocessing journal entry", zap.Any("entry", entry), zap.Error(processErr))
+			err = failJournalEntry(pollDb, entry, processErr, journalMaxAttempts)
+			if err != nil {
+				return
+			}
+			failed++
+			continue
+		}
+
+		err = pollDb.AckJournalEntry(entry.Id, time.Now())
 		if err != nil {
 			return
 		}
+		recovered++
+	}
+
+	if len(entries) > 0 {
+		logger.Info("journal recovery complete", zap.Int("recovered", recovered), zap.Int("failed", failed), zap.Int("unacked", len(entries)))
+	}
+	return
+}
+
+// failJournalEntry counts the failure against the entry, dead lettering it once it has failed maxAttempts times.
+func failJournalEntry(pollDb db.IDBPoll, entry types.JournalEntry, failure error, maxAttempts int) (err error) {
+	deadLettered, err := pollDb.FailJournalEntry(entry.Id, failure.Error(), maxAttempts, time.Now())
+	if err != nil {
+		return
+	}
+	if deadLettered {
+		logger.Error("journal entry dead lettered", zap.String("guid", entry.Id), zap.String("logId", entry.LogId), zap.Error(failure))
 	}
 	return
 }
@@ -23,10 +23,13 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/DataDog/datadog-api-client-go/api/v2/datadog"
 	"github.com/joho/godotenv"
+	"github.com/sonatype-nexus-community/bbash/internal/config"
 	"github.com/sonatype-nexus-community/bbash/internal/db"
+	"github.com/sonatype-nexus-community/bbash/internal/errreport"
 	"github.com/sonatype-nexus-community/bbash/internal/types"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap/zaptest"
@@ -88,6 +91,35 @@ func TestGetDDApiClientReal(t *testing.T) {
 	assert.Equal(t, "https://{subdomain}.{site}", clientReal.GetConfig().Servers[0].URL)
 }
 
+func TestConfigure(t *testing.T) {
+	origDogApiClient := dogApiClient
+	defer func() {
+		dogApiClient = origDogApiClient
+	}()
+
+	Configure(&config.Config{DDClientApiKey: "myApiKey", DDClientAppKey: "myAppKey"})
+	ctx, _ := dogApiClient.getDDApiClient()
+	assert.Equal(t, map[string]datadog.APIKey{
+		"apiKeyAuth": {Key: "myApiKey"},
+		"appKeyAuth": {Key: "myAppKey"},
+	}, ctx.Value(datadog.ContextAPIKeys))
+}
+
+func TestSetKeys(t *testing.T) {
+	origDogApiClient := dogApiClient
+	defer func() {
+		dogApiClient = origDogApiClient
+	}()
+
+	Configure(&config.Config{DDClientApiKey: "myApiKey", DDClientAppKey: "myAppKey"})
+	SetKeys("myRotatedApiKey", "")
+	ctx, _ := dogApiClient.getDDApiClient()
+	assert.Equal(t, map[string]datadog.APIKey{
+		"apiKeyAuth": {Key: "myRotatedApiKey"},
+		"appKeyAuth": {Key: "myAppKey"},
+	}, ctx.Value(datadog.ContextAPIKeys))
+}
+
 func TestFetchLogPagesErrorMissingKey(t *testing.T) {
 	logger = zaptest.NewLogger(t)
 
@@ -459,11 +491,38 @@ func TestProcessResponseDataScoringMessageUnexpectedMarshalError(t *testing.T) {
 
 	logs, err := processResponseData(responseData)
 	assert.Equal(t, 0, len(logs))
-	assert.EqualError(t, err, "json: cannot unmarshal string into Go struct field ScoringMessage.fixed-bug-types of type map[string]interface {}")
+	assert.EqualError(t, err, "json: cannot unmarshal string into Go value of type map[string]interface {}")
+}
+
+func TestProcessResponseDataScoringMessageUnknownSchemaVersion(t *testing.T) {
+	newerId := "newerLogId"
+	newerEnv := map[string]interface{}{qryEnv: map[string]interface{}{
+		qryEnvExtraJsonFields: map[string]interface{}{"schemaVersion": 99},
+	}}
+	logId := "myLogId"
+	logEnv := map[string]interface{}{qryEnv: map[string]interface{}{
+		qryEnvExtraJsonFields: map[string]interface{}{"eventSource": "myEventSource"},
+	}}
+	responseData := []datadog.Log{
+		{Id: &newerId, Attributes: &datadog.LogAttributes{Attributes: newerEnv}},
+		{Id: &logId, Attributes: &datadog.LogAttributes{Attributes: logEnv}},
+	}
+
+	logger = zaptest.NewLogger(t)
+
+	logs, err := processResponseData(responseData)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(logs))
+	assert.Equal(t, newerId, logs[0].Id)
+	assert.Equal(t, &UnknownSchemaVersionError{SchemaVersion: 99}, logs[0].Fields.versionErr)
+	assert.Equal(t, []byte(`{"schemaVersion":99}`), logs[0].Fields.rawMessage)
+	assert.Equal(t, logId, logs[1].Id)
+	assert.Nil(t, logs[1].Fields.versionErr)
+	assert.Equal(t, "myEventSource", logs[1].Fields.scoringMessage.EventSource)
 }
 
 func TestProcessResponseDataScoringMessageFixedBugsWithOptMap(t *testing.T) {
-	// this map is odd, but allowed. assumes leaf keys are unique for assigning score values
+	// a semgrep rule id, split on its dots into nested maps
 	mapSprintf := map[string]interface{}{"sprintf-host-port": float64(2)}
 	mapSemGrep := map[string]interface{}{"semgrep": mapSprintf}
 	mapBugTypes := map[string]interface{}{
@@ -492,9 +551,11 @@ func TestProcessResponseDataScoringMessageFixedBugsWithOptMap(t *testing.T) {
 	logs, err := processResponseData(responseData)
 	assert.Equal(t, 1, len(logs))
 	assert.NoError(t, err)
-	assert.Equal(t, float64(1), logs[0].Fields.scoringMessage.BugCounts["G104"])
-	assert.Equal(t, float64(1), logs[0].Fields.scoringMessage.BugCounts["ShellCheck"])
-	assert.Equal(t, mapSemGrep, logs[0].Fields.scoringMessage.BugCounts["opt"])
+	assert.Equal(t, []types.BugCount{
+		{Type: "G104", Count: 1},
+		{Type: "ShellCheck", Count: 1},
+		{Type: "sprintf-host-port", Count: 2},
+	}, logs[0].Fields.scoringMessage.BugCounts)
 }
 
 func TestProcessResponseDataScoringMessage(t *testing.T) {
@@ -530,8 +591,7 @@ func TestProcessResponseDataScoringMessage(t *testing.T) {
 	logs, err := processResponseData(responseData)
 	assert.Equal(t, 1, len(logs))
 	assert.NoError(t, err)
-	assert.Equal(t, float64(1), logs[0].Fields.scoringMessage.BugCounts["G104"])
-	assert.Equal(t, float64(2), logs[0].Fields.scoringMessage.BugCounts["ShellCheck"])
+	assert.Equal(t, []types.BugCount{{Type: "G104", Count: 1}, {Type: "ShellCheck", Count: 2}}, logs[0].Fields.scoringMessage.BugCounts)
 }
 
 func TestPollTheDogDBError(t *testing.T) {
@@ -545,7 +605,7 @@ func TestPollTheDogDBError(t *testing.T) {
 	db.SetupMockPollSelectForcedError(mock, forcedError, poll.Id)
 
 	now := time.Now()
-	logs, err := pollTheDog(dbPoll, now, now)
+	logs, _, err := pollTheDog(dbPoll, now, now)
 	assert.EqualError(t, err, forcedError.Error())
 	assert.Equal(t, ([]ddLog)(nil), logs)
 }
@@ -571,7 +631,7 @@ func TestPollTheDogPollError(t *testing.T) {
 	closeApiClient := setupMockDDogApiClient(urlTs)
 	defer closeApiClient()
 
-	logs, err := pollTheDog(dbPoll, now, now)
+	logs, _, err := pollTheDog(dbPoll, now, now)
 	assert.EqualError(t, err, "500 Internal Server Error")
 	assert.Equal(t, ([]ddLog)(nil), logs)
 }
@@ -584,7 +644,7 @@ func TestPollTheDogUsePriorPollTime(t *testing.T) {
 
 	poll := dbPoll.NewPoll()
 	now := time.Now()
-	db.SetupMockPollSelectAndUpdate(mock, poll.Id, now, 1)
+	db.SetupMockPollSelect(mock, poll.Id, now)
 
 	priorPollTime := now.Add(time.Second * -1)
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -604,9 +664,10 @@ func TestPollTheDogUsePriorPollTime(t *testing.T) {
 	closeApiClient := setupMockDDogApiClient(urlTs)
 	defer closeApiClient()
 
-	logs, err := pollTheDog(dbPoll, priorPollTime, now)
+	logs, _, err := pollTheDog(dbPoll, priorPollTime, now)
 	assert.NoError(t, err)
 	assert.Equal(t, ([]ddLog)(nil), logs)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestPollTheDogOneLog(t *testing.T) {
@@ -617,7 +678,7 @@ func TestPollTheDogOneLog(t *testing.T) {
 
 	poll := dbPoll.NewPoll()
 	now := time.Now()
-	db.SetupMockPollSelectAndUpdate(mock, poll.Id, now, 1)
+	db.SetupMockPollSelect(mock, poll.Id, now)
 
 	logId := "myLogId"
 	eventSource := "myEventSource"
@@ -653,12 +714,48 @@ func TestPollTheDogOneLog(t *testing.T) {
 	closeApiClient := setupMockDDogApiClient(urlTs)
 	defer closeApiClient()
 
-	logs, err := pollTheDog(dbPoll, now, now)
+	logs, polled, err := pollTheDog(dbPoll, now, now)
 	assert.NoError(t, err)
 
 	assert.Equal(t, 1, len(logs))
 	assert.Equal(t, logId, logs[0].Id)
 	assert.Equal(t, eventSource, logs[0].Fields.scoringMessage.EventSource)
+	assert.Equal(t, poll.Id, polled.Id)
+	// the last poll time is left for commitPoll to move on
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCommitPollError(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	poll := dbPoll.NewPoll()
+	forcedError := fmt.Errorf("forced poll update error")
+	mock.ExpectExec(".*").WillReturnError(forcedError)
+
+	assert.EqualError(t, commitPoll(dbPoll, &poll, nil, time.Now()), forcedError.Error())
+}
+
+func TestCommitPoll(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	poll := dbPoll.NewPoll()
+	now := time.Now()
+	db.SetupMockPollUpdate(mock, poll.Id, now, 1)
+
+	envBaseTime := now.Add(time.Second * -3)
+	logs := []ddLog{
+		{Fields: extraFields{envBaseTime: envBaseTime}},
+	}
+	assert.NoError(t, commitPoll(dbPoll, &poll, logs, now))
+	assert.Equal(t, now, poll.LastPolled)
+	assert.Equal(t, envBaseTime, poll.EnvBaseTime)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 type MockScoreDB struct {
@@ -672,11 +769,15 @@ type MockScoreDB struct {
 	insertEvtParticipant *types.ParticipantStruct
 	insertEvtMsg         *types.ScoringMessage
 	insertEvtNewPoints   int
+	insertEvtBreakdown   *types.ScoreBreakdown
 	insertEvtError       error
 
 	updateScoreParticipant *types.ParticipantStruct
 	updateScoreDelta       float64
 	updateScoreError       error
+
+	insertEvtsCount int
+	insertEvtsError error
 }
 
 func (m MockScoreDB) GetDb() (db *sql.DB) {
@@ -691,7 +792,7 @@ func createMockScoreDb(t *testing.T) (scoreDb *MockScoreDB) {
 	}
 }
 
-func (m MockScoreDB) SelectPriorScore(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64) {
+func (m MockScoreDB) SelectPriorScore(ctx context.Context, participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.selectPriorScore, participantToScore)
 		assert.Equal(m.t, m.selectPriorMsg, msg)
@@ -699,16 +800,17 @@ func (m MockScoreDB) SelectPriorScore(participantToScore *types.ParticipantStruc
 	return m.selectPriorOldPoints
 }
 
-func (m MockScoreDB) InsertScoringEvent(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64) (err error) {
+func (m MockScoreDB) InsertScoringEvent(ctx context.Context, participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64, breakdown *types.ScoreBreakdown) (err error) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.insertEvtParticipant, participantToScore)
 		assert.Equal(m.t, m.insertEvtMsg, msg)
 		assert.Equal(m.t, m.insertEvtNewPoints, newPoints)
+		assert.Equal(m.t, m.insertEvtBreakdown, breakdown)
 	}
 	return m.insertEvtError
 }
 
-func (m MockScoreDB) UpdateParticipantScore(participant *types.ParticipantStruct, delta float64) (err error) {
+func (m MockScoreDB) UpdateParticipantScore(ctx context.Context, participant *types.ParticipantStruct, delta float64) (err error) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.updateScoreParticipant, participant)
 		assert.Equal(m.t, m.updateScoreDelta, delta)
@@ -716,13 +818,48 @@ func (m MockScoreDB) UpdateParticipantScore(participant *types.ParticipantStruct
 	return m.updateScoreError
 }
 
+func (m MockScoreDB) SelectScoringEventsSince(ctx context.Context, participant *types.ParticipantStruct, since time.Time) (events []db.ScoringEventRow, err error) {
+	return
+}
+
+func (m MockScoreDB) SelectDuplicateFingerprints(ctx context.Context, participant *types.ParticipantStruct, msg *types.ScoringMessage) (duplicates []string, err error) {
+	return
+}
+
+func (m MockScoreDB) SelectCoAuthorPriorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64) {
+	return
+}
+
+func (m MockScoreDB) InsertCoAuthorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64) (err error) {
+	return
+}
+
+func (m MockScoreDB) SelectCoAuthorScores(ctx context.Context, participant *types.ParticipantStruct, msg *types.ScoringMessage) (coAuthors []db.CoAuthorScoreRow, err error) {
+	return
+}
+
+func (m MockScoreDB) DeleteCoAuthorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage) (err error) {
+	return
+}
+
+func (m MockScoreDB) InsertScoringEvents(ctx context.Context, events []db.ScoringEventRow, scoreDeltas []db.ParticipantScoreDelta) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.insertEvtsCount, len(events))
+	}
+	return m.insertEvtsError
+}
+
 var _ db.IScoreDB = (*MockScoreDB)(nil)
 
 func TestProcessLogsZeroLogs(t *testing.T) {
-	assert.NoError(t, processLogs(nil, nil, time.Now(), nil))
+	assert.NoError(t, processLogs(nil, nil, nil, nil, time.Now(), nil))
 }
 
 func TestProcessLogsOneWithError(t *testing.T) {
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	db.SetupMockJournalFail(mock, "myJournalId", journalMaxAttempts, false)
+
 	scoreDb := createMockScoreDb(t)
 
 	logs := []ddLog{
@@ -731,17 +868,114 @@ func TestProcessLogsOneWithError(t *testing.T) {
 	now := time.Now()
 	forcedError := fmt.Errorf("forced process logs error")
 	processScoringMessage := func(scoreDbCalled db.IScoreDB, nowCalled time.Time, msgCalled *types.ScoringMessage) (err error) {
-		assert.Equal(t, scoreDb, scoreDbCalled)
+		assert.Equal(t, scoreDb, scoreDbCalled.(*db.ScoringEventBatch).IScoreDB)
 		assert.Equal(t, now, nowCalled)
 		assert.Equal(t, &types.ScoringMessage{}, msgCalled)
 		return forcedError
 	}
 
-	err := processLogs(scoreDb, logs, now, processScoringMessage)
+	err := processLogs(dbPoll, scoreDb, logs, []string{"myJournalId"}, now, processScoringMessage)
 	assert.EqualError(t, forcedError, err.Error())
+	// failed message is counted against its journal entry, and left unacknowledged
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// recordingReporter keeps the errors reported, rather than sending them
+type recordingReporter struct {
+	errs   []error
+	extras []map[string]interface{}
+}
+
+func (r *recordingReporter) Report(err error, extra map[string]interface{}) {
+	r.errs = append(r.errs, err)
+	r.extras = append(r.extras, extra)
+}
+
+func setupRecordingReporter(t *testing.T) (recorder *recordingReporter) {
+	recorder = &recordingReporter{}
+	SetReporter(recorder)
+	t.Cleanup(func() {
+		SetReporter(errreport.NoReporter{})
+	})
+	return
+}
+
+func TestProcessLogsOneWithErrorReported(t *testing.T) {
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	db.SetupMockJournalFail(mock, "myJournalId", journalMaxAttempts, false)
+	recorder := setupRecordingReporter(t)
+
+	msg := types.ScoringMessage{RepoOwner: "myRepoOwner", PullRequest: 5}
+	forcedError := fmt.Errorf("forced process logs error")
+	processScoringMessage := func(scoreDbCalled db.IScoreDB, nowCalled time.Time, msgCalled *types.ScoringMessage) (err error) {
+		return forcedError
+	}
+
+	err := processLogs(dbPoll, createMockScoreDb(t), []ddLog{{Fields: extraFields{scoringMessage: msg}}}, []string{"myJournalId"}, time.Now(), processScoringMessage)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, []error{forcedError}, recorder.errs)
+	assert.Equal(t, []map[string]interface{}{{"scoringMessage": msg}}, recorder.extras)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProcessLogsOnePanics(t *testing.T) {
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	db.SetupMockJournalFail(mock, "myJournalId", journalMaxAttempts, false)
+	recorder := setupRecordingReporter(t)
+
+	msg := types.ScoringMessage{RepoOwner: "myRepoOwner", PullRequest: 5}
+	processScoringMessage := func(scoreDbCalled db.IScoreDB, nowCalled time.Time, msgCalled *types.ScoringMessage) (err error) {
+		panic("forced scoring panic")
+	}
+
+	err := processLogs(dbPoll, createMockScoreDb(t), []ddLog{{Fields: extraFields{scoringMessage: msg}}}, []string{"myJournalId"}, time.Now(), processScoringMessage)
+	var panicErr *errreport.PanicError
+	assert.True(t, errors.As(err, &panicErr))
+	assert.Equal(t, "forced scoring panic", panicErr.Value)
+	assert.Contains(t, string(panicErr.Stack), "TestProcessLogsOnePanics")
+	assert.Equal(t, []error{err}, recorder.errs)
+	assert.Equal(t, []map[string]interface{}{{"scoringMessage": msg}}, recorder.extras)
+	// the message is left in the journal, unacknowledged, for journal recovery to reprocess
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestJournalLogsInsertError(t *testing.T) {
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced journal error")
+	mock.ExpectQuery(".*").WillReturnError(forcedError)
+
+	logs := []ddLog{
+		{},
+	}
+	_, err := journalLogs(dbPoll, logs, time.Now())
+	assert.EqualError(t, err, forcedError.Error())
+}
+
+func TestJournalLogs(t *testing.T) {
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	db.SetupMockJournalInsert(mock, "firstLog", "firstJournalId")
+	db.SetupMockJournalInsert(mock, "secondLog", "secondJournalId")
+
+	logs := []ddLog{
+		{Id: "firstLog"},
+		{Id: "secondLog"},
+	}
+	journalIds, err := journalLogs(dbPoll, logs, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"firstJournalId", "secondJournalId"}, journalIds)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestProcessLogsOne(t *testing.T) {
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	db.SetupMockJournalAck(mock, "myJournalId")
+
 	scoreDb := createMockScoreDb(t)
 
 	logs := []ddLog{
@@ -749,14 +983,214 @@ func TestProcessLogsOne(t *testing.T) {
 	}
 	now := time.Now()
 	processScoringMessage := func(scoreDbCalled db.IScoreDB, nowCalled time.Time, msgCalled *types.ScoringMessage) (err error) {
-		assert.Equal(t, scoreDb, scoreDbCalled)
+		assert.Equal(t, scoreDb, scoreDbCalled.(*db.ScoringEventBatch).IScoreDB)
 		assert.Equal(t, now, nowCalled)
 		assert.Equal(t, &types.ScoringMessage{}, msgCalled)
 		return
 	}
 
-	err := processLogs(scoreDb, logs, now, processScoringMessage)
+	err := processLogs(dbPoll, scoreDb, logs, []string{"myJournalId"}, now, processScoringMessage)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProcessLogsFlushError(t *testing.T) {
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	scoreDb := createMockScoreDb(t)
+	scoreDb.insertEvtsCount = 1
+	forcedError := fmt.Errorf("forced flush error")
+	scoreDb.insertEvtsError = forcedError
+
+	logs := []ddLog{
+		{},
+	}
+	processScoringMessage := func(scoreDbCalled db.IScoreDB, nowCalled time.Time, msgCalled *types.ScoringMessage) (err error) {
+		return scoreDbCalled.InsertScoringEvent(context.Background(), &types.ParticipantStruct{}, msgCalled, 1, nil)
+	}
+
+	err := processLogs(dbPoll, scoreDb, logs, []string{"myJournalId"}, time.Now(), processScoringMessage)
+	assert.EqualError(t, err, forcedError.Error())
+	// events were not inserted, so the message is left in the journal, unacknowledged
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProcessLogsAcksOnlyProcessed(t *testing.T) {
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	db.SetupMockJournalFail(mock, "secondJournalId", journalMaxAttempts, false)
+	db.SetupMockJournalAck(mock, "firstJournalId")
+	db.SetupMockJournalAck(mock, "thirdJournalId")
+
+	scoreDb := createMockScoreDb(t)
+	scoreDb.insertEvtsCount = 2
+
+	logs := []ddLog{
+		{Id: "firstLog", Fields: extraFields{scoringMessage: types.ScoringMessage{PullRequest: 1}}},
+		{Id: "secondLog", Fields: extraFields{scoringMessage: types.ScoringMessage{PullRequest: 2}}},
+		{Id: "thirdLog", Fields: extraFields{scoringMessage: types.ScoringMessage{PullRequest: 3}}},
+	}
+	forcedError := fmt.Errorf("forced process logs error")
+	processScoringMessage := func(scoreDbCalled db.IScoreDB, nowCalled time.Time, msgCalled *types.ScoringMessage) (err error) {
+		if msgCalled.PullRequest == 2 {
+			return forcedError
+		}
+		return scoreDbCalled.InsertScoringEvent(context.Background(), &types.ParticipantStruct{}, msgCalled, 1, nil)
+	}
+
+	err := processLogs(dbPoll, scoreDb, logs, []string{"firstJournalId", "secondJournalId", "thirdJournalId"}, time.Now(), processScoringMessage)
+	// the failed message does not hold back the one after it
+	assert.EqualError(t, err, forcedError.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProcessLogsFailJournalError(t *testing.T) {
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	forcedFailError := fmt.Errorf("forced fail journal error")
+	db.SetupMockJournalFailForcedError(mock, "myJournalId", forcedFailError)
+
+	processScoringMessage := func(scoreDbCalled db.IScoreDB, nowCalled time.Time, msgCalled *types.ScoringMessage) (err error) {
+		return fmt.Errorf("forced process logs error")
+	}
+
+	err := processLogs(dbPoll, createMockScoreDb(t), []ddLog{{}}, []string{"myJournalId"}, time.Now(), processScoringMessage)
+	assert.EqualError(t, err, forcedFailError.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProcessLogsDeadLettersUnknownSchemaVersion(t *testing.T) {
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+	db.SetupMockJournalFail(mock, "newerJournalId", 1, true)
+	db.SetupMockJournalAck(mock, "myJournalId")
+
+	scoreDb := createMockScoreDb(t)
+	scoreDb.insertEvtsCount = 1
+
+	logs := []ddLog{
+		{Id: "newerLog", Fields: extraFields{rawMessage: []byte(`{"schemaVersion":99}`), versionErr: &UnknownSchemaVersionError{SchemaVersion: 99}}},
+		{Id: "myLog", Fields: extraFields{scoringMessage: types.ScoringMessage{PullRequest: 1}}},
+	}
+	processScoringMessage := func(scoreDbCalled db.IScoreDB, nowCalled time.Time, msgCalled *types.ScoringMessage) (err error) {
+		assert.Equal(t, 1, msgCalled.PullRequest)
+		return scoreDbCalled.InsertScoringEvent(context.Background(), &types.ParticipantStruct{}, msgCalled, 1, nil)
+	}
+
+	assert.NoError(t, processLogs(dbPoll, scoreDb, logs, []string{"newerJournalId", "myJournalId"}, time.Now(), processScoringMessage))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecoverJournalSelectError(t *testing.T) {
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	forcedError := fmt.Errorf("forced journal select error")
+	mock.ExpectQuery(".*").WillReturnError(forcedError)
+
+	recovered, err := RecoverJournal(dbPoll, createMockScoreDb(t), nil)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, 0, recovered)
+}
+
+func TestRecoverJournalProcessError(t *testing.T) {
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	receivedOn := time.Now()
+	db.SetupMockJournalSelectUnacked(mock, []types.JournalEntry{
+		{Id: "myJournalId", LogId: "myLogId", ReceivedOn: receivedOn, RawMessage: []byte(`{"eventSource":"myEventSource"}`)},
+	})
+
+	forcedError := fmt.Errorf("forced process error")
+	processScoringMessage := func(scoreDbCalled db.IScoreDB, nowCalled time.Time, msgCalled *types.ScoringMessage) (err error) {
+		return forcedError
+	}
+	db.SetupMockJournalFailForcedError(mock, "myJournalId", fmt.Errorf("forced fail journal error"))
+
+	recovered, err := RecoverJournal(dbPoll, createMockScoreDb(t), processScoringMessage)
+	assert.EqualError(t, err, "forced fail journal error")
+	assert.Equal(t, 0, recovered)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecoverJournalSkipsFailingEntries(t *testing.T) {
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	receivedOn := time.Now()
+	db.SetupMockJournalSelectUnacked(mock, []types.JournalEntry{
+		{Id: "poisonJournalId", LogId: "poisonLogId", ReceivedOn: receivedOn, RawMessage: []byte(`{"eventSource":"poison"}`)},
+		{Id: "badJsonJournalId", LogId: "badJsonLogId", ReceivedOn: receivedOn, RawMessage: []byte(`{`)},
+		{Id: "myJournalId", LogId: "myLogId", ReceivedOn: receivedOn, RawMessage: []byte(`{"eventSource":"myEventSource"}`)},
+	})
+	db.SetupMockJournalFail(mock, "poisonJournalId", journalMaxAttempts, false)
+	// it can never be read, so it is dead lettered at once
+	db.SetupMockJournalFail(mock, "badJsonJournalId", 1, true)
+	db.SetupMockJournalAck(mock, "myJournalId")
+
+	processScoringMessage := func(scoreDbCalled db.IScoreDB, nowCalled time.Time, msgCalled *types.ScoringMessage) (err error) {
+		if msgCalled.EventSource == "poison" {
+			panic("poison message")
+		}
+		return
+	}
+
+	recovered, err := RecoverJournal(dbPoll, createMockScoreDb(t), processScoringMessage)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, recovered)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecoverJournal(t *testing.T) {
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	receivedOn := time.Now()
+	db.SetupMockJournalSelectUnacked(mock, []types.JournalEntry{
+		{Id: "myJournalId", LogId: "myLogId", ReceivedOn: receivedOn, RawMessage: []byte(`{"eventSource":"myEventSource"}`)},
+	})
+	db.SetupMockJournalAck(mock, "myJournalId")
+
+	scoreDb := createMockScoreDb(t)
+	processScoringMessage := func(scoreDbCalled db.IScoreDB, nowCalled time.Time, msgCalled *types.ScoringMessage) (err error) {
+		assert.Equal(t, scoreDb, scoreDbCalled)
+		assert.Equal(t, receivedOn, nowCalled)
+		assert.Equal(t, &types.ScoringMessage{SchemaVersion: types.ScoringSchemaVersion, EventSource: "myEventSource"}, msgCalled)
+		return
+	}
+
+	recovered, err := RecoverJournal(dbPoll, scoreDb, processScoringMessage)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, recovered)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecoverJournalSkipsUnknownSchemaVersion(t *testing.T) {
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	receivedOn := time.Now()
+	db.SetupMockJournalSelectUnacked(mock, []types.JournalEntry{
+		{Id: "newerJournalId", LogId: "newerLogId", ReceivedOn: receivedOn, RawMessage: []byte(`{"schemaVersion":99}`)},
+		{Id: "myJournalId", LogId: "myLogId", ReceivedOn: receivedOn, RawMessage: []byte(`{"eventSource":"myEventSource"}`)},
+	})
+	// only the readable entry is acknowledged
+	db.SetupMockJournalAck(mock, "myJournalId")
+
+	processed := 0
+	processScoringMessage := func(scoreDbCalled db.IScoreDB, nowCalled time.Time, msgCalled *types.ScoringMessage) (err error) {
+		assert.Equal(t, "myEventSource", msgCalled.EventSource)
+		processed++
+		return
+	}
+
+	recovered, err := RecoverJournal(dbPoll, createMockScoreDb(t), processScoringMessage)
 	assert.NoError(t, err)
+	assert.Equal(t, 1, recovered)
+	assert.Equal(t, 1, processed)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestChaseTailPollError(t *testing.T) {
@@ -767,6 +1201,7 @@ func TestChaseTailPollError(t *testing.T) {
 
 	poll := dbPoll.NewPoll()
 	forcedError := fmt.Errorf("forced poll db error")
+	db.SetupMockJournalSelectUnacked(mock, nil)
 	db.SetupMockPollSelectForcedError(mock, forcedError, poll.Id)
 
 	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
@@ -774,7 +1209,10 @@ func TestChaseTailPollError(t *testing.T) {
 		return
 	}
 
-	quitChan, errChan := ChaseTail(dbPoll, createMockScoreDb(t), 1, processScoringMessage)
+	scoreDb := createMockScoreDb(t)
+	scoreDb.selectPriorScore = &types.ParticipantStruct{}
+	scoreDb.selectPriorMsg = &types.ScoringMessage{}
+	quitChan, errChan := ChaseTail(dbPoll, scoreDb, 1, processScoringMessage)
 	defer close(quitChan)
 
 	assert.EqualError(t, <-errChan, forcedError.Error())
@@ -788,6 +1226,7 @@ func TestChaseTailQuit(t *testing.T) {
 
 	poll := dbPoll.NewPoll()
 	now := time.Now()
+	db.SetupMockJournalSelectUnacked(mock, nil)
 	db.SetupMockPollSelectAndUpdateAnyUpdateTime(mock, poll.Id, now, 1)
 
 	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
@@ -795,9 +1234,125 @@ func TestChaseTailQuit(t *testing.T) {
 		return
 	}
 
+	scoreDb := createMockScoreDb(t)
+	scoreDb.selectPriorScore = &types.ParticipantStruct{}
+	scoreDb.selectPriorMsg = &types.ScoringMessage{}
+	quitChan, errChan := ChaseTail(dbPoll, scoreDb, 1, processScoringMessage)
+	close(quitChan)
+	assert.Nil(t, <-errChan)
+}
+
+func TestChaseTailMaintenance(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	SetMaintenance(func() bool {
+		return true
+	})
+	defer SetMaintenance(func() bool {
+		return false
+	})
+
+	// nothing is polled, so the database is not read
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
+		assert.Fail(t, "this should never run")
+		return
+	}
+
 	quitChan, errChan := ChaseTail(dbPoll, createMockScoreDb(t), 1, processScoringMessage)
+	time.Sleep(1500 * time.Millisecond)
 	close(quitChan)
 	assert.Nil(t, <-errChan)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChaseTailRecoversJournal(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	poll := dbPoll.NewPoll()
+	db.SetupMockJournalSelectUnacked(mock, []types.JournalEntry{
+		{Id: "myJournalId", LogId: "myLogId", ReceivedOn: time.Now(), RawMessage: []byte(`{"eventSource":"myEventSource"}`)},
+	})
+	db.SetupMockJournalAck(mock, "myJournalId")
+	forcedError := fmt.Errorf("forced poll db error")
+	db.SetupMockPollSelectForcedError(mock, forcedError, poll.Id)
+
+	msgProcessed := false
+	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
+		msgProcessed = true
+		assert.Equal(t, "myEventSource", msg.EventSource)
+		return
+	}
+
+	quitChan, errChan := ChaseTail(dbPoll, createMockScoreDb(t), 1, processScoringMessage)
+	defer close(quitChan)
+
+	// the unacknowledged entry is retried before the poll
+	assert.EqualError(t, <-errChan, forcedError.Error())
+	assert.True(t, msgProcessed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChaseTailJournalError(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
+	defer closeDbFunc()
+
+	poll := dbPoll.NewPoll()
+	now := time.Now()
+	db.SetupMockJournalSelectUnacked(mock, nil)
+	db.SetupMockPollSelect(mock, poll.Id, now)
+	forcedError := fmt.Errorf("forced journal error")
+	mock.ExpectQuery(".*").WillReturnError(forcedError)
+
+	logId := "myLogId"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+
+		apiResp := datadog.LogsListResponse{
+			Data: &[]datadog.Log{
+				{
+					Id: &logId,
+					Attributes: &datadog.LogAttributes{
+						Attributes: map[string]interface{}{
+							qryEnv: map[string]interface{}{
+								qryEnvExtraJsonFields: map[string]interface{}{
+									"eventSource": "myEventSource",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		jsonObj, err := json.Marshal(apiResp)
+		assert.NoError(t, err)
+		_, err = w.Write(jsonObj)
+		assert.NoError(t, err)
+	}))
+	defer ts.Close()
+	urlTs, err := url.Parse(ts.URL)
+	assert.NoError(t, err)
+
+	closeApiClient := setupMockDDogApiClient(urlTs)
+	defer closeApiClient()
+
+	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
+		assert.Fail(t, "message should not be processed before it is journaled")
+		return
+	}
+
+	quitChan, errChan := ChaseTail(dbPoll, createMockScoreDb(t), 1, processScoringMessage)
+	defer close(quitChan)
+
+	// the poll time is not updated, so the next poll reads the message again
+	assert.EqualError(t, <-errChan, forcedError.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestChaseTailProcessLogsError(t *testing.T) {
@@ -808,7 +1363,11 @@ func TestChaseTailProcessLogsError(t *testing.T) {
 
 	poll := dbPoll.NewPoll()
 	now := time.Now()
-	db.SetupMockPollSelectAndUpdateAnyUpdateTime(mock, poll.Id, now, 1)
+	db.SetupMockJournalSelectUnacked(mock, nil)
+	db.SetupMockPollSelect(mock, poll.Id, now)
+	db.SetupMockJournalInsert(mock, "myLogId", "myJournalId")
+	db.SetupMockPollUpdateAnyUpdateTime(mock, poll.Id, 1)
+	db.SetupMockJournalFail(mock, "myJournalId", journalMaxAttempts, false)
 
 	logId := "myLogId"
 	eventSource := "myEventSource"
@@ -848,14 +1407,17 @@ func TestChaseTailProcessLogsError(t *testing.T) {
 	forcedError := fmt.Errorf("forced process logs error")
 	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
 		msgProcessed = true
-		scoreDb.SelectPriorScore(nil, nil)
-		assert.NoError(t, scoreDb.UpdateParticipantScore(nil, 0))
+		scoreDb.SelectPriorScore(context.Background(), &types.ParticipantStruct{}, &types.ScoringMessage{})
+		assert.NoError(t, scoreDb.UpdateParticipantScore(context.Background(), &types.ParticipantStruct{}, 0))
 		assert.Equal(t, eventSource, msg.EventSource)
 		err = forcedError
 		return
 	}
 
-	quitChan, _ := ChaseTail(dbPoll, createMockScoreDb(t), 1, processScoringMessage)
+	scoreDb := createMockScoreDb(t)
+	scoreDb.selectPriorScore = &types.ParticipantStruct{}
+	scoreDb.selectPriorMsg = &types.ScoringMessage{}
+	quitChan, _ := ChaseTail(dbPoll, scoreDb, 1, processScoringMessage)
 
 	time.Sleep(2 * time.Second)
 	close(quitChan)
@@ -870,7 +1432,11 @@ func TestChaseTailOneLog(t *testing.T) {
 
 	poll := dbPoll.NewPoll()
 	now := time.Now()
-	db.SetupMockPollSelectAndUpdateAnyUpdateTime(mock, poll.Id, now, 1)
+	db.SetupMockJournalSelectUnacked(mock, nil)
+	db.SetupMockPollSelect(mock, poll.Id, now)
+	db.SetupMockJournalInsert(mock, "myLogId", "myJournalId")
+	db.SetupMockPollUpdateAnyUpdateTime(mock, poll.Id, 1)
+	db.SetupMockJournalAck(mock, "myJournalId")
 
 	logId := "myLogId"
 	eventSource := "myEventSource"
@@ -909,13 +1475,16 @@ func TestChaseTailOneLog(t *testing.T) {
 	msgProcessed := false
 	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
 		msgProcessed = true
-		scoreDb.SelectPriorScore(nil, nil)
-		assert.NoError(t, scoreDb.UpdateParticipantScore(nil, 0))
+		scoreDb.SelectPriorScore(context.Background(), &types.ParticipantStruct{}, &types.ScoringMessage{})
+		assert.NoError(t, scoreDb.UpdateParticipantScore(context.Background(), &types.ParticipantStruct{}, 0))
 		assert.Equal(t, eventSource, msg.EventSource)
 		return
 	}
 
-	quitChan, _ := ChaseTail(dbPoll, createMockScoreDb(t), 1, processScoringMessage)
+	scoreDb := createMockScoreDb(t)
+	scoreDb.selectPriorScore = &types.ParticipantStruct{}
+	scoreDb.selectPriorMsg = &types.ScoringMessage{}
+	quitChan, _ := ChaseTail(dbPoll, scoreDb, 1, processScoringMessage)
 
 	time.Sleep(2 * time.Second)
 	close(quitChan)
@@ -930,7 +1499,11 @@ func TestChaseTailOneLogWithOptMap(t *testing.T) {
 
 	poll := dbPoll.NewPoll()
 	now := time.Now()
-	db.SetupMockPollSelectAndUpdateAnyUpdateTime(mock, poll.Id, now, 1)
+	db.SetupMockJournalSelectUnacked(mock, nil)
+	db.SetupMockPollSelect(mock, poll.Id, now)
+	db.SetupMockJournalInsert(mock, "myLogId", "myJournalId")
+	db.SetupMockPollUpdateAnyUpdateTime(mock, poll.Id, 1)
+	db.SetupMockJournalAck(mock, "myJournalId")
 
 	eventSource := "myEventSource"
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -980,13 +1553,16 @@ func TestChaseTailOneLogWithOptMap(t *testing.T) {
 	msgProcessed := false
 	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
 		msgProcessed = true
-		scoreDb.SelectPriorScore(nil, nil)
-		assert.NoError(t, scoreDb.UpdateParticipantScore(nil, 0))
+		scoreDb.SelectPriorScore(context.Background(), &types.ParticipantStruct{}, &types.ScoringMessage{})
+		assert.NoError(t, scoreDb.UpdateParticipantScore(context.Background(), &types.ParticipantStruct{}, 0))
 		assert.Equal(t, eventSource, msg.EventSource)
 		return
 	}
 
-	quitChan, _ := ChaseTail(dbPoll, createMockScoreDb(t), 1, processScoringMessage)
+	scoreDb := createMockScoreDb(t)
+	scoreDb.selectPriorScore = &types.ParticipantStruct{}
+	scoreDb.selectPriorMsg = &types.ScoringMessage{}
+	quitChan, _ := ChaseTail(dbPoll, scoreDb, 1, processScoringMessage)
 
 	time.Sleep(2 * time.Second)
 	close(quitChan)
@@ -998,6 +1574,9 @@ func xxxTestChaseTailLive(t *testing.T) {
 	logger = zaptest.NewLogger(t)
 
 	assert.NoError(t, godotenv.Load("../../.env.dd.bak"))
+	cfg, err := config.Load("")
+	assert.NoError(t, err)
+	Configure(cfg)
 
 	mock, dbPoll, closeDbFunc := db.SetupMockDBPoll(t)
 	defer closeDbFunc()
@@ -1006,16 +1585,20 @@ func xxxTestChaseTailLive(t *testing.T) {
 	now := time.Now()
 	// simulate day old poll
 	yesterday := now.Add(time.Hour * -24)
+	db.SetupMockJournalSelectUnacked(mock, nil)
 	db.SetupMockPollSelectAndUpdateAnyUpdateTime(mock, poll.Id, yesterday, 1)
 
 	processScoringMessage := func(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
-		scoreDb.SelectPriorScore(nil, nil)
-		assert.NoError(t, scoreDb.UpdateParticipantScore(nil, 0))
+		scoreDb.SelectPriorScore(context.Background(), &types.ParticipantStruct{}, &types.ScoringMessage{})
+		assert.NoError(t, scoreDb.UpdateParticipantScore(context.Background(), &types.ParticipantStruct{}, 0))
 		assert.Equal(t, "github", msg.EventSource)
 		return
 	}
 
-	quitChan, errChan := ChaseTail(dbPoll, createMockScoreDb(t), 1, processScoringMessage)
+	scoreDb := createMockScoreDb(t)
+	scoreDb.selectPriorScore = &types.ParticipantStruct{}
+	scoreDb.selectPriorMsg = &types.ScoringMessage{}
+	quitChan, errChan := ChaseTail(dbPoll, scoreDb, 1, processScoringMessage)
 	//defer close(quitChan)
 
 	time.Sleep(3 * time.Second)
@@ -0,0 +1,90 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+)
+
+// scoringDecoders upgrade each known payload version to the current types.ScoringMessage. When the scanner changes its
+// payload, the new version gets a decoder here, and the older decoders are changed to fill in the new shape.
+var scoringDecoders = map[int]func(raw []byte) (msg types.ScoringMessage, err error){
+	1: decodeScoringMessageV1,
+}
+
+// payloads from before the scanner sent a schemaVersion are version 1
+const defaultSchemaVersion = 1
+
+// UnknownSchemaVersionError is returned for a payload version that has no decoder, likely sent by a newer scanner.
+type UnknownSchemaVersionError struct {
+	SchemaVersion int
+}
+
+func (e *UnknownSchemaVersionError) Error() string {
+	return fmt.Sprintf("unknown scoring message schemaVersion: %d", e.SchemaVersion)
+}
+
+// DecodeScoringMessage reads a scoring message payload of any known version into the current types.ScoringMessage.
+func DecodeScoringMessage(raw []byte) (msg types.ScoringMessage, err error) {
+	var header struct {
+		SchemaVersion *int `json:"schemaVersion"`
+	}
+	err = json.Unmarshal(raw, &header)
+	if err != nil {
+		return
+	}
+	schemaVersion := defaultSchemaVersion
+	if header.SchemaVersion != nil {
+		schemaVersion = *header.SchemaVersion
+	}
+
+	decode, ok := scoringDecoders[schemaVersion]
+	if !ok {
+		err = &UnknownSchemaVersionError{SchemaVersion: schemaVersion}
+		return
+	}
+	msg, err = decode(raw)
+	if err != nil {
+		return
+	}
+	msg.SchemaVersion = types.ScoringSchemaVersion
+	return
+}
+
+// scoringMessageV1 is the original payload, with the fixed bug types in the shape sent by the scanner.
+type scoringMessageV1 struct {
+	types.ScoringMessage
+	BugCounts json.RawMessage `json:"fixed-bug-types"`
+}
+
+// decodeScoringMessageV1 reads the original payload, which has the shape of types.ScoringMessage apart from the fixed
+// bug types.
+func decodeScoringMessageV1(raw []byte) (msg types.ScoringMessage, err error) {
+	var payload scoringMessageV1
+	err = json.Unmarshal(raw, &payload)
+	if err != nil {
+		return
+	}
+	msg = payload.ScoringMessage
+	msg.BugCounts, err = parseBugCounts(payload.BugCounts)
+	return
+}
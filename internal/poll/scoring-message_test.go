@@ -0,0 +1,66 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package poll
+
+import (
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDecodeScoringMessageInvalidJson(t *testing.T) {
+	_, err := DecodeScoringMessage([]byte(`{`))
+	assert.EqualError(t, err, "unexpected end of JSON input")
+}
+
+func TestDecodeScoringMessageUnknownVersion(t *testing.T) {
+	_, err := DecodeScoringMessage([]byte(`{"schemaVersion":99,"eventSource":"myEventSource"}`))
+	assert.Equal(t, &UnknownSchemaVersionError{SchemaVersion: 99}, err)
+	assert.EqualError(t, err, "unknown scoring message schemaVersion: 99")
+}
+
+func TestDecodeScoringMessageV1Error(t *testing.T) {
+	_, err := DecodeScoringMessage([]byte(`{"fixed-bug-types":"notAMap"}`))
+	assert.EqualError(t, err, "json: cannot unmarshal string into Go value of type map[string]interface {}")
+}
+
+func TestDecodeScoringMessageV1(t *testing.T) {
+	expected := types.ScoringMessage{
+		SchemaVersion: types.ScoringSchemaVersion,
+		EventSource:   "myEventSource",
+		RepoOwner:     "myRepoOwner",
+		RepoName:      "myRepoName",
+		TriggerUser:   "myTriggerUser",
+		TotalFixed:    3,
+		BugCounts:     []types.BugCount{{Type: "G104", Count: 2}},
+		PullRequest:   5,
+	}
+
+	// without a schemaVersion, as sent before payloads were versioned
+	msg, err := DecodeScoringMessage([]byte(`{"eventSource":"myEventSource","repositoryOwner":"myRepoOwner","repositoryName":"myRepoName",
+		"triggerUser":"myTriggerUser","fixed-bugs":3,"fixed-bug-types":{"G104":2},"pullRequestId":5}`))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, msg)
+
+	msg, err = DecodeScoringMessage([]byte(`{"schemaVersion":1,"eventSource":"myEventSource","repositoryOwner":"myRepoOwner","repositoryName":"myRepoName",
+		"triggerUser":"myTriggerUser","fixed-bugs":3,"fixed-bug-types":{"G104":2},"pullRequestId":5}`))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, msg)
+}
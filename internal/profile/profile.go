@@ -0,0 +1,170 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// names of the source control providers with a profile API, as in the source_control_provider table
+const (
+	ScpGitHub = "GitHub"
+	ScpGitLab = "GitLab"
+)
+
+const defaultGitHubApiUrl = "https://api.github.com"
+const defaultGitLabApiUrl = "https://gitlab.com/api/v4"
+
+// ErrUnsupportedScp is returned for a source control provider without a profile API.
+var ErrUnsupportedScp = errors.New("source control provider has no profile API")
+
+// ErrInvalidToken is returned when the source control provider refuses the access token of a user.
+var ErrInvalidToken = errors.New("source control provider refused the access token")
+
+// Profile is the public profile of a user of a source control provider. Fields the user did not fill in are empty.
+type Profile struct {
+	DisplayName string
+	AvatarUrl   string
+}
+
+// Fetcher reads the public profile of a user of a source control provider.
+type Fetcher interface {
+	Fetch(scpName, loginName string) (profile *Profile, err error)
+}
+
+// ScpFetcher is the Fetcher that calls the REST APIs of GitHub and GitLab.
+type ScpFetcher struct {
+	client       *http.Client
+	githubToken  string
+	githubApiUrl string
+	gitlabApiUrl string
+}
+
+// Authenticator finds the user an access token of a source control provider belongs to, so a user proves who they are
+// without bbash keeping a password.
+type Authenticator interface {
+	Authenticate(scpName, token string) (loginName string, err error)
+}
+
+var _ Fetcher = (*ScpFetcher)(nil)
+var _ Authenticator = (*ScpFetcher)(nil)
+
+// New returns a fetcher whose calls give up after the timeout. The optional githubToken raises the GitHub rate limit.
+func New(timeout time.Duration, githubToken string) *ScpFetcher {
+	return &ScpFetcher{
+		client:       &http.Client{Timeout: timeout},
+		githubToken:  githubToken,
+		githubApiUrl: defaultGitHubApiUrl,
+		gitlabApiUrl: defaultGitLabApiUrl,
+	}
+}
+
+type userResponse struct {
+	Name      string `json:"name"`
+	AvatarUrl string `json:"avatar_url"`
+}
+
+func (f *ScpFetcher) Fetch(scpName, loginName string) (profile *Profile, err error) {
+	switch scpName {
+	case ScpGitHub:
+		var user userResponse
+		err = f.get(fmt.Sprintf("%s/users/%s", f.githubApiUrl, url.PathEscape(loginName)), f.githubToken, &user)
+		if err != nil {
+			return
+		}
+		profile = &Profile{DisplayName: user.Name, AvatarUrl: user.AvatarUrl}
+	case ScpGitLab:
+		var users []userResponse
+		err = f.get(fmt.Sprintf("%s/users?username=%s", f.gitlabApiUrl, url.QueryEscape(loginName)), "", &users)
+		if err != nil {
+			return
+		}
+		if len(users) == 0 {
+			return nil, fmt.Errorf("no %s user: %s", scpName, loginName)
+		}
+		profile = &Profile{DisplayName: users[0].Name, AvatarUrl: users[0].AvatarUrl}
+	default:
+		err = ErrUnsupportedScp
+	}
+	return
+}
+
+type authenticatedUserResponse struct {
+	// Login is the login of a GitHub user
+	Login string `json:"login"`
+	// Username is the login of a GitLab user
+	Username string `json:"username"`
+}
+
+// Authenticate asks the source control provider for the user of the token. ErrInvalidToken is returned when the
+// provider refuses it.
+func (f *ScpFetcher) Authenticate(scpName, token string) (loginName string, err error) {
+	var user authenticatedUserResponse
+	switch scpName {
+	case ScpGitHub:
+		if err = f.get(f.githubApiUrl+"/user", token, &user); err != nil {
+			return
+		}
+		loginName = user.Login
+	case ScpGitLab:
+		if err = f.get(f.gitlabApiUrl+"/user", token, &user); err != nil {
+			return
+		}
+		loginName = user.Username
+	default:
+		return "", ErrUnsupportedScp
+	}
+	if loginName == "" {
+		err = fmt.Errorf("no %s login for the access token", scpName)
+	}
+	return
+}
+
+func (f *ScpFetcher) get(apiUrl, token string, response interface{}) (err error) {
+	req, err := http.NewRequest(http.MethodGet, apiUrl, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := f.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return ErrInvalidToken
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("profile status: %d, url: %s", res.StatusCode, apiUrl)
+	}
+	return json.NewDecoder(res.Body).Decode(response)
+}
@@ -0,0 +1,138 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package profile
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func setupFetcher(t *testing.T, handler http.HandlerFunc) (fetcher *ScpFetcher) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	fetcher = New(time.Second, "myToken")
+	fetcher.githubApiUrl = server.URL
+	fetcher.gitlabApiUrl = server.URL
+	return
+}
+
+func TestFetchGitHub(t *testing.T) {
+	fetcher := setupFetcher(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/users/myLogin", r.URL.Path)
+		assert.Equal(t, "Bearer myToken", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"login":"myLogin","name":"My Name","avatar_url":"https://avatars.example.com/u/1"}`))
+	})
+
+	profile, err := fetcher.Fetch(ScpGitHub, "myLogin")
+	assert.NoError(t, err)
+	assert.Equal(t, &Profile{DisplayName: "My Name", AvatarUrl: "https://avatars.example.com/u/1"}, profile)
+}
+
+func TestFetchGitHubErrorStatus(t *testing.T) {
+	fetcher := setupFetcher(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	profile, err := fetcher.Fetch(ScpGitHub, "myLogin")
+	assert.EqualError(t, err, "profile status: 404, url: "+fetcher.githubApiUrl+"/users/myLogin")
+	assert.Nil(t, profile)
+}
+
+func TestFetchGitLab(t *testing.T) {
+	fetcher := setupFetcher(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/users", r.URL.Path)
+		assert.Equal(t, "myLogin", r.URL.Query().Get("username"))
+		assert.Equal(t, "", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`[{"username":"myLogin","name":"My Name","avatar_url":"https://gitlab.example.com/avatar.png"}]`))
+	})
+
+	profile, err := fetcher.Fetch(ScpGitLab, "myLogin")
+	assert.NoError(t, err)
+	assert.Equal(t, &Profile{DisplayName: "My Name", AvatarUrl: "https://gitlab.example.com/avatar.png"}, profile)
+}
+
+func TestFetchGitLabNoUser(t *testing.T) {
+	fetcher := setupFetcher(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[]`))
+	})
+
+	profile, err := fetcher.Fetch(ScpGitLab, "myLogin")
+	assert.EqualError(t, err, "no GitLab user: myLogin")
+	assert.Nil(t, profile)
+}
+
+func TestFetchUnsupportedScp(t *testing.T) {
+	profile, err := New(time.Second, "").Fetch("Bitbucket", "myLogin")
+	assert.Equal(t, ErrUnsupportedScp, err)
+	assert.Nil(t, profile)
+}
+
+func TestAuthenticateGitHub(t *testing.T) {
+	fetcher := setupFetcher(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/user", r.URL.Path)
+		assert.Equal(t, "Bearer userToken", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"login":"myLogin","name":"My Name"}`))
+	})
+
+	loginName, err := fetcher.Authenticate(ScpGitHub, "userToken")
+	assert.NoError(t, err)
+	assert.Equal(t, "myLogin", loginName)
+}
+
+func TestAuthenticateGitLab(t *testing.T) {
+	fetcher := setupFetcher(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/user", r.URL.Path)
+		assert.Equal(t, "Bearer userToken", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"username":"myLogin","name":"My Name"}`))
+	})
+
+	loginName, err := fetcher.Authenticate(ScpGitLab, "userToken")
+	assert.NoError(t, err)
+	assert.Equal(t, "myLogin", loginName)
+}
+
+func TestAuthenticateInvalidToken(t *testing.T) {
+	fetcher := setupFetcher(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	loginName, err := fetcher.Authenticate(ScpGitHub, "badToken")
+	assert.Equal(t, ErrInvalidToken, err)
+	assert.Equal(t, "", loginName)
+}
+
+func TestAuthenticateNoLogin(t *testing.T) {
+	fetcher := setupFetcher(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	_, err := fetcher.Authenticate(ScpGitLab, "userToken")
+	assert.EqualError(t, err, "no GitLab login for the access token")
+}
+
+func TestAuthenticateUnsupportedScp(t *testing.T) {
+	loginName, err := New(time.Second, "").Authenticate("Bitbucket", "userToken")
+	assert.Equal(t, ErrUnsupportedScp, err)
+	assert.Equal(t, "", loginName)
+}
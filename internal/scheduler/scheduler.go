@@ -0,0 +1,178 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"github.com/sonatype-nexus-community/bbash/internal/errreport"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"go.uber.org/zap"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Func is the work of a job, passed the time its run started. An error marks the run as failed.
+type Func func(ctx context.Context, now time.Time) error
+
+// Store saves the runs of the jobs, so their outcomes are seen across replicas and restarts.
+type Store interface {
+	UpsertScheduledJobRun(ctx context.Context, run *types.ScheduledJobRun) (err error)
+	SelectScheduledJobRuns(ctx context.Context) (runs []types.ScheduledJobRun, err error)
+}
+
+type job struct {
+	name      string
+	every     time.Duration
+	run       Func
+	running   bool
+	nextRunOn time.Time
+}
+
+// Scheduler runs registered jobs on a fixed interval, saving the outcome of each run to its store. It is safe to call
+// from multiple goroutines.
+type Scheduler struct {
+	mu         sync.Mutex
+	logger     *zap.Logger
+	store      Store
+	instanceId string
+	jobs       []*job
+	started    bool
+}
+
+// New returns a scheduler without jobs, saving their runs as runs of the instance.
+func New(logger *zap.Logger, store Store, instanceId string) *Scheduler {
+	return &Scheduler{logger: logger, store: store, instanceId: instanceId}
+}
+
+// Register adds a job run every interval once the scheduler starts. A job cannot be registered twice, nor once the
+// scheduler has started.
+func (s *Scheduler) Register(name string, every time.Duration, run Func) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return fmt.Errorf("scheduler already started: job: %s", name)
+	}
+	if every <= 0 {
+		return fmt.Errorf("job interval must be positive: job: %s, every: %s", name, every)
+	}
+	for _, existing := range s.jobs {
+		if existing.name == name {
+			return fmt.Errorf("job already registered: job: %s", name)
+		}
+	}
+	s.jobs = append(s.jobs, &job{name: name, every: every, run: run})
+	return
+}
+
+// Start runs each job at once, then on its own ticker until quit is closed, each job in its own goroutine, so Start
+// returns without waiting on any of them. A tick due while a run of the job is still going is dropped, so a slow job
+// never runs twice at once.
+func (s *Scheduler) Start() (quit chan bool) {
+	s.mu.Lock()
+	s.started = true
+	jobs := append([]*job(nil), s.jobs...)
+	s.mu.Unlock()
+
+	quit = make(chan bool)
+	for _, j := range jobs {
+		go func(j *job) {
+			s.runJob(j)
+
+			ticker := time.NewTicker(j.every)
+			for {
+				select {
+				case <-ticker.C:
+					s.runJob(j)
+				case <-quit:
+					ticker.Stop()
+					return
+				}
+			}
+		}(j)
+	}
+	return
+}
+
+func (s *Scheduler) runJob(j *job) {
+	startedOn := time.Now()
+	s.mu.Lock()
+	j.running = true
+	s.mu.Unlock()
+
+	err := s.callJob(j, startedOn)
+
+	run := &types.ScheduledJobRun{Name: j.name, InstanceId: s.instanceId, StartedOn: startedOn, FinishedOn: time.Now()}
+	s.mu.Lock()
+	j.running = false
+	j.nextRunOn = startedOn.Add(j.every)
+	s.mu.Unlock()
+	if err != nil {
+		// the job logs its own errors
+		run.Error = err.Error()
+		s.logger.Debug("scheduled job failed", zap.String("job", j.name), zap.Error(err))
+	}
+
+	if err = s.store.UpsertScheduledJobRun(context.Background(), run); err != nil {
+		s.logger.Error("scheduled job run not saved", zap.String("job", j.name), zap.Error(err))
+	}
+}
+
+// callJob runs the job, recovering a panic as an error, so a bad run fails rather than stop the job or the server.
+func (s *Scheduler) callJob(j *job, startedOn time.Time) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &errreport.PanicError{Value: r, Stack: debug.Stack()}
+			s.logger.Error("panic in scheduled job", zap.String("job", j.name), zap.Error(err))
+		}
+	}()
+	return j.run(context.Background(), startedOn)
+}
+
+// Jobs reads the jobs in the order they were registered, each with its latest run by any replica.
+func (s *Scheduler) Jobs(ctx context.Context) (jobs []types.ScheduledJob, err error) {
+	runs, err := s.store.SelectScheduledJobRuns(ctx)
+	if err != nil {
+		return
+	}
+	lastRuns := map[string]types.ScheduledJobRun{}
+	for _, run := range runs {
+		lastRuns[run.Name] = run
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs = []types.ScheduledJob{}
+	for _, j := range s.jobs {
+		scheduled := types.ScheduledJob{Name: j.name, Schedule: "@every " + j.every.String(), Running: j.running}
+		if !j.nextRunOn.IsZero() {
+			nextRunOn := j.nextRunOn
+			scheduled.NextRunOn = &nextRunOn
+		}
+		if run, ok := lastRuns[j.name]; ok {
+			scheduled.LastRun = &run
+		}
+		jobs = append(jobs, scheduled)
+	}
+	return
+}
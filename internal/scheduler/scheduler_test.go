@@ -0,0 +1,224 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockStore struct {
+	mu        sync.Mutex
+	runs      []types.ScheduledJobRun
+	upsertErr error
+	selectErr error
+}
+
+func (m *mockStore) UpsertScheduledJobRun(ctx context.Context, run *types.ScheduledJobRun) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs = append(m.runs, *run)
+	return m.upsertErr
+}
+
+func (m *mockStore) SelectScheduledJobRuns(ctx context.Context) (runs []types.ScheduledJobRun, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append(runs, m.runs...), m.selectErr
+}
+
+func TestRegister(t *testing.T) {
+	s := New(zaptest.NewLogger(t), &mockStore{}, "myInstance")
+	noop := func(ctx context.Context, now time.Time) error { return nil }
+
+	assert.NoError(t, s.Register("myJob", time.Minute, noop))
+	assert.EqualError(t, s.Register("myJob", time.Minute, noop), "job already registered: job: myJob")
+	assert.EqualError(t, s.Register("otherJob", 0, noop), "job interval must be positive: job: otherJob, every: 0s")
+
+	quit := s.Start()
+	defer close(quit)
+	assert.EqualError(t, s.Register("otherJob", time.Minute, noop), "scheduler already started: job: otherJob")
+}
+
+// savedRuns waits for the store to have saved count runs, and returns them sorted by job name
+func savedRuns(t *testing.T, store *mockStore, count int) (runs []types.ScheduledJobRun) {
+	assert.Eventually(t, func() bool {
+		runs, _ = store.SelectScheduledJobRuns(context.Background())
+		return len(runs) >= count
+	}, time.Second*5, time.Millisecond)
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Name < runs[j].Name
+	})
+	return
+}
+
+func TestStart(t *testing.T) {
+	store := &mockStore{}
+	s := New(zaptest.NewLogger(t), store, "myInstance")
+	require.NoError(t, s.Register("myJob", time.Hour, func(ctx context.Context, now time.Time) error {
+		return nil
+	}))
+	require.NoError(t, s.Register("failingJob", time.Hour, func(ctx context.Context, now time.Time) error {
+		return fmt.Errorf("forced job error")
+	}))
+
+	quit := s.Start()
+	defer close(quit)
+
+	// each job runs once at start
+	runs := savedRuns(t, store, 2)
+	require.Equal(t, 2, len(runs))
+	assert.Equal(t, "failingJob", runs[0].Name)
+	assert.Equal(t, "forced job error", runs[0].Error)
+	assert.Equal(t, "myJob", runs[1].Name)
+	assert.Equal(t, "myInstance", runs[1].InstanceId)
+	assert.Equal(t, "", runs[1].Error)
+	assert.False(t, runs[1].FinishedOn.Before(runs[1].StartedOn))
+}
+
+func TestStartDoesNotWait(t *testing.T) {
+	store := &mockStore{}
+	s := New(zaptest.NewLogger(t), store, "myInstance")
+	release := make(chan bool)
+	require.NoError(t, s.Register("slowJob", time.Hour, func(ctx context.Context, now time.Time) error {
+		<-release
+		return nil
+	}))
+	require.NoError(t, s.Register("myJob", time.Hour, func(ctx context.Context, now time.Time) error {
+		return nil
+	}))
+
+	quit := s.Start()
+	defer close(quit)
+
+	// the slow job holds up neither Start nor the other jobs
+	assert.Equal(t, "myJob", savedRuns(t, store, 1)[0].Name)
+	close(release)
+	assert.Equal(t, 2, len(savedRuns(t, store, 2)))
+}
+
+func TestStartRecoversPanic(t *testing.T) {
+	store := &mockStore{}
+	s := New(zaptest.NewLogger(t), store, "myInstance")
+	ran := make(chan bool, 10)
+	require.NoError(t, s.Register("panicJob", time.Millisecond, func(ctx context.Context, now time.Time) error {
+		ran <- true
+		panic("forced job panic")
+	}))
+
+	quit := s.Start()
+	defer close(quit)
+
+	assert.Equal(t, "panic: forced job panic", savedRuns(t, store, 1)[0].Error)
+	// the job keeps running
+	<-ran
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		assert.Fail(t, "job did not run again")
+	}
+}
+
+func TestStartTicks(t *testing.T) {
+	store := &mockStore{}
+	s := New(zaptest.NewLogger(t), store, "myInstance")
+	ran := make(chan time.Time, 10)
+	require.NoError(t, s.Register("myJob", time.Millisecond, func(ctx context.Context, now time.Time) error {
+		ran <- now
+		return nil
+	}))
+
+	quit := s.Start()
+	<-ran
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		assert.Fail(t, "job did not run again")
+	}
+	close(quit)
+}
+
+func TestStartStoreError(t *testing.T) {
+	store := &mockStore{upsertErr: fmt.Errorf("forced upsert error")}
+	s := New(zaptest.NewLogger(t), store, "myInstance")
+	runs := 0
+	require.NoError(t, s.Register("myJob", time.Hour, func(ctx context.Context, now time.Time) error {
+		runs++
+		return nil
+	}))
+
+	quit := s.Start()
+	defer close(quit)
+	assert.Equal(t, 1, len(savedRuns(t, store, 1)))
+	assert.Equal(t, 1, runs)
+}
+
+func TestJobsNotStarted(t *testing.T) {
+	s := New(zaptest.NewLogger(t), &mockStore{}, "myInstance")
+	require.NoError(t, s.Register("myJob", 15*time.Second, func(ctx context.Context, now time.Time) error { return nil }))
+
+	jobs, err := s.Jobs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ScheduledJob{{Name: "myJob", Schedule: "@every 15s"}}, jobs)
+}
+
+func TestJobs(t *testing.T) {
+	store := &mockStore{}
+	s := New(zaptest.NewLogger(t), store, "myInstance")
+	require.NoError(t, s.Register("myJob", time.Hour, func(ctx context.Context, now time.Time) error { return nil }))
+	require.NoError(t, s.Register("otherJob", time.Minute, func(ctx context.Context, now time.Time) error { return nil }))
+
+	quit := s.Start()
+	defer close(quit)
+	savedRuns(t, store, 2)
+	// a run by another replica is seen too
+	store.mu.Lock()
+	store.runs = append(store.runs, types.ScheduledJobRun{Name: "otherJob", InstanceId: "otherInstance", Runs: 7})
+	store.mu.Unlock()
+
+	jobs, err := s.Jobs(context.Background())
+	assert.NoError(t, err)
+	require.Equal(t, 2, len(jobs))
+	assert.Equal(t, "myJob", jobs[0].Name)
+	assert.Equal(t, "@every 1h0m0s", jobs[0].Schedule)
+	assert.False(t, jobs[0].Running)
+	require.NotNil(t, jobs[0].NextRunOn)
+	require.NotNil(t, jobs[0].LastRun)
+	assert.Equal(t, jobs[0].LastRun.StartedOn.Add(time.Hour), *jobs[0].NextRunOn)
+	assert.Equal(t, "otherInstance", jobs[1].LastRun.InstanceId)
+	assert.Equal(t, int64(7), jobs[1].LastRun.Runs)
+}
+
+func TestJobsError(t *testing.T) {
+	forcedError := fmt.Errorf("forced select error")
+	s := New(zaptest.NewLogger(t), &mockStore{selectErr: forcedError}, "myInstance")
+
+	jobs, err := s.Jobs(context.Background())
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Nil(t, jobs)
+}
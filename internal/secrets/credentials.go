@@ -0,0 +1,163 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the endpoints of the role credentials, of the ECS task and of the EC2 instance
+const (
+	ecsCredentialsEndpoint = "http://169.254.170.2"
+	imdsEndpoint           = "http://169.254.169.254"
+)
+
+// imdsTokenTtl is how long the session token of the instance metadata service lasts, in seconds
+const imdsTokenTtl = "21600"
+
+// awsCredentialsRefresh is how long before role credentials expire that they are fetched again
+const awsCredentialsRefresh = 5 * time.Minute
+
+// awsCredentials sign the requests to AWS. The token and expiration are only set for temporary credentials, such as
+// those of a role. The field names are those of the ECS and EC2 credentials endpoints.
+type awsCredentials struct {
+	AccessKeyId     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// awsRoleCredentials fetches the credentials of the role of the ECS task, or else of the EC2 instance, and keeps them
+// until shortly before they expire.
+type awsRoleCredentials struct {
+	client *http.Client
+	// ecsEndpoint is the URL of the credentials of the ECS task, empty outside ECS
+	ecsEndpoint      string
+	ecsAuthorization string
+	// imdsEndpoint is the instance metadata service of EC2, empty when AWS_EC2_METADATA_DISABLED
+	imdsEndpoint string
+
+	mu     sync.Mutex
+	cached awsCredentials
+}
+
+// newAwsRoleCredentials finds the credentials endpoint in the environment the way the AWS SDKs do, the ECS one when
+// AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or AWS_CONTAINER_CREDENTIALS_FULL_URI is set, and the EC2 one otherwise.
+func newAwsRoleCredentials(client *http.Client, getenv func(key string) string) *awsRoleCredentials {
+	r := &awsRoleCredentials{client: client, ecsAuthorization: getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")}
+	if relative := getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		r.ecsEndpoint = ecsCredentialsEndpoint + relative
+	} else {
+		r.ecsEndpoint = getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	}
+	if !strings.EqualFold(getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		r.imdsEndpoint = imdsEndpoint
+	}
+	return r
+}
+
+// get returns the credentials of the role, fetching them when there are none yet or they are about to expire.
+func (r *awsRoleCredentials) get(ctx context.Context, now time.Time) (credentials awsCredentials, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cached.AccessKeyId != "" && now.Before(r.cached.Expiration.Add(-awsCredentialsRefresh)) {
+		return r.cached, nil
+	}
+	switch {
+	case r.ecsEndpoint != "":
+		credentials, err = r.fetchEcs(ctx)
+	case r.imdsEndpoint != "":
+		credentials, err = r.fetchImds(ctx)
+	default:
+		err = fmt.Errorf("no credentials, set AWS_ACCESS_KEY_ID or run with a role")
+	}
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("aws role credentials: %w", err)
+	}
+	if credentials.AccessKeyId == "" || credentials.SecretAccessKey == "" {
+		return awsCredentials{}, fmt.Errorf("aws role credentials: no access key")
+	}
+	r.cached = credentials
+	return
+}
+
+func (r *awsRoleCredentials) fetchEcs(ctx context.Context) (credentials awsCredentials, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.ecsEndpoint, nil)
+	if err != nil {
+		return
+	}
+	if r.ecsAuthorization != "" {
+		req.Header.Set("Authorization", r.ecsAuthorization)
+	}
+	return r.readCredentials(req)
+}
+
+// fetchImds reads the credentials of the role of the instance from version 2 of the instance metadata service, which
+// takes a session token first.
+func (r *awsRoleCredentials) fetchImds(ctx context.Context) (credentials awsCredentials, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, r.imdsEndpoint+"/latest/api/token", nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", imdsTokenTtl)
+	token, err := readResponse(r.client, req)
+	if err != nil {
+		return
+	}
+
+	rolesPath := r.imdsEndpoint + "/latest/meta-data/iam/security-credentials/"
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, rolesPath, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("X-Aws-Ec2-Metadata-Token", string(token))
+	roles, err := readResponse(r.client, req)
+	if err != nil {
+		return
+	}
+	// an instance has at most one role
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+	if role == "" {
+		return credentials, fmt.Errorf("the instance has no role")
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, rolesPath+role, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("X-Aws-Ec2-Metadata-Token", string(token))
+	return r.readCredentials(req)
+}
+
+func (r *awsRoleCredentials) readCredentials(req *http.Request) (credentials awsCredentials, err error) {
+	body, err := readResponse(r.client, req)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &credentials)
+	return
+}
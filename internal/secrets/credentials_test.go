@@ -0,0 +1,175 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var credentialsExpiration = time.Date(2022, 4, 1, 13, 0, 0, 0, time.UTC)
+
+// envMap holds environment variables, read by its get in place of os.Getenv
+type envMap map[string]string
+
+func (env envMap) get(key string) string {
+	return env[key]
+}
+
+func credentialsJson(accessKeyId string) string {
+	return fmt.Sprintf(`{"AccessKeyId":"%s","SecretAccessKey":"theSecretAccessKey","Token":"theToken","Expiration":"%s"}`,
+		accessKeyId, credentialsExpiration.Format(time.RFC3339))
+}
+
+func TestNewAwsRoleCredentials(t *testing.T) {
+	for _, test := range []struct {
+		env          envMap
+		ecsEndpoint  string
+		imdsEndpoint string
+	}{
+		{envMap{}, "", imdsEndpoint},
+		{envMap{"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI": "/v2/credentials/abc"}, "http://169.254.170.2/v2/credentials/abc", imdsEndpoint},
+		{envMap{"AWS_CONTAINER_CREDENTIALS_FULL_URI": "http://localhost:8080/creds"}, "http://localhost:8080/creds", imdsEndpoint},
+		{envMap{"AWS_EC2_METADATA_DISABLED": "TRUE"}, "", ""},
+	} {
+		r := newAwsRoleCredentials(http.DefaultClient, test.env.get)
+		assert.Equal(t, test.ecsEndpoint, r.ecsEndpoint, test.env)
+		assert.Equal(t, test.imdsEndpoint, r.imdsEndpoint, test.env)
+	}
+}
+
+func TestAwsRoleCredentialsEcs(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/creds", r.URL.Path)
+		assert.Equal(t, "theAuthorizationToken", r.Header.Get("Authorization"))
+		_, _ = fmt.Fprint(w, credentialsJson(fmt.Sprintf("theAccessKeyId%d", calls)))
+	}))
+	defer ts.Close()
+	r := newAwsRoleCredentials(ts.Client(), envMap{
+		"AWS_CONTAINER_CREDENTIALS_FULL_URI": ts.URL + "/creds",
+		"AWS_CONTAINER_AUTHORIZATION_TOKEN":  "theAuthorizationToken",
+	}.get)
+
+	credentials, err := r.get(context.Background(), credentialsExpiration.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, awsCredentials{AccessKeyId: "theAccessKeyId1", SecretAccessKey: "theSecretAccessKey", Token: "theToken",
+		Expiration: credentialsExpiration}, credentials)
+
+	// kept until shortly before they expire
+	credentials, err = r.get(context.Background(), credentialsExpiration.Add(-awsCredentialsRefresh-time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, "theAccessKeyId1", credentials.AccessKeyId)
+	credentials, err = r.get(context.Background(), credentialsExpiration.Add(-awsCredentialsRefresh))
+	assert.NoError(t, err)
+	assert.Equal(t, "theAccessKeyId2", credentials.AccessKeyId)
+	assert.Equal(t, 2, calls)
+}
+
+func TestAwsRoleCredentialsImds(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/latest/api/token" {
+			assert.Equal(t, http.MethodPut, r.Method)
+			assert.Equal(t, imdsTokenTtl, r.Header.Get("X-Aws-Ec2-Metadata-Token-Ttl-Seconds"))
+			_, _ = fmt.Fprint(w, "theImdsToken")
+			return
+		}
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "theImdsToken", r.Header.Get("X-Aws-Ec2-Metadata-Token"))
+		switch r.URL.Path {
+		case "/latest/meta-data/iam/security-credentials/":
+			_, _ = fmt.Fprint(w, "theRole\n")
+		case "/latest/meta-data/iam/security-credentials/theRole":
+			_, _ = fmt.Fprint(w, credentialsJson("theAccessKeyId"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	r := newAwsRoleCredentials(ts.Client(), envMap{}.get)
+	r.imdsEndpoint = ts.URL
+
+	credentials, err := r.get(context.Background(), credentialsExpiration.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, "theAccessKeyId", credentials.AccessKeyId)
+	assert.Equal(t, "theToken", credentials.Token)
+}
+
+func TestAwsRoleCredentialsErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/api/token":
+			_, _ = fmt.Fprint(w, "theImdsToken")
+		case "/empty":
+			_, _ = fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	_, err := newAwsRoleCredentials(ts.Client(), envMap{"AWS_EC2_METADATA_DISABLED": "true"}.get).get(context.Background(), time.Now())
+	assert.EqualError(t, err, "aws role credentials: no credentials, set AWS_ACCESS_KEY_ID or run with a role")
+
+	_, err = newAwsRoleCredentials(ts.Client(), envMap{"AWS_CONTAINER_CREDENTIALS_FULL_URI": ts.URL + "/empty"}.get).get(context.Background(), time.Now())
+	assert.EqualError(t, err, "aws role credentials: no access key")
+
+	noRole := newAwsRoleCredentials(ts.Client(), envMap{}.get)
+	noRole.imdsEndpoint = ts.URL
+	_, err = noRole.get(context.Background(), time.Now())
+	assert.EqualError(t, err, "aws role credentials: status: 404")
+}
+
+func TestAwsSecretsManagerResolveRoleCredentials(t *testing.T) {
+	credentialsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, credentialsJson("theRoleAccessKeyId"))
+	}))
+	defer credentialsServer.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "theToken", r.Header.Get("X-Amz-Security-Token"))
+		assert.Regexp(t, `^AWS4-HMAC-SHA256 Credential=theRoleAccessKeyId/20220401/us-east-1/secretsmanager/aws4_request, `,
+			r.Header.Get("Authorization"))
+		_, _ = fmt.Fprint(w, `{"Name":"bbash/plain","SecretString":"myPlainSecret"}`)
+	}))
+	defer ts.Close()
+
+	sm := NewAwsSecretsManager("us-east-1", "", "", "", time.Second)
+	sm.endpoint = ts.URL
+	sm.now = func() time.Time {
+		return time.Date(2022, 4, 1, 12, 0, 0, 0, time.UTC)
+	}
+	sm.role = newAwsRoleCredentials(credentialsServer.Client(), envMap{"AWS_CONTAINER_CREDENTIALS_FULL_URI": credentialsServer.URL}.get)
+
+	value, err := sm.Resolve(context.Background(), "bbash/plain")
+	assert.NoError(t, err)
+	assert.Equal(t, "myPlainSecret", value)
+}
+
+func TestAwsSecretsManagerResolveStaticCredentials(t *testing.T) {
+	sm := NewAwsSecretsManager("us-east-1", "myAccessKeyId", "mySecretAccessKey", "", time.Second)
+	assert.Nil(t, sm.role)
+}
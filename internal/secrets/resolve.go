@@ -0,0 +1,326 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the schemes of the references to secrets kept in a secrets backend, such as vault:secret/data/bbash#pgPassword
+const (
+	SchemeVault             = "vault"
+	SchemeAwsSecretsManager = "awssm"
+)
+
+// Resolver fetches a secret from a secrets backend. The reference is what follows the scheme, a path or name, then
+// optionally # and the key of the secret within it.
+type Resolver interface {
+	Resolve(ctx context.Context, reference string) (value string, err error)
+}
+
+// Resolvers fetch the secrets named by references, each from the backend of its scheme. A secret is only fetched
+// once, so a rotated secret is used after a restart.
+type Resolvers struct {
+	schemes map[string]Resolver
+	mu      sync.Mutex
+	cache   map[string]string
+}
+
+// NewResolvers returns resolvers for the schemes, which may be empty when no secrets backend is configured.
+func NewResolvers(schemes map[string]Resolver) *Resolvers {
+	return &Resolvers{schemes: schemes, cache: map[string]string{}}
+}
+
+// Resolve returns the secret, when the value is a reference to one, such as vault:secret/data/bbash#pgPassword. Any
+// other value is returned as it is. A reference to a backend that is not configured is an error, rather than being
+// taken for the secret.
+func (r *Resolvers) Resolve(ctx context.Context, value string) (resolved string, err error) {
+	scheme, reference, ok := parseReference(value)
+	if !ok {
+		return value, nil
+	}
+	resolver, ok := r.schemes[scheme]
+	if !ok {
+		return "", fmt.Errorf("%s secret %s: no %s secrets backend is configured", scheme, reference, scheme)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cached, found := r.cache[value]; found {
+		return cached, nil
+	}
+	resolved, err = resolver.Resolve(ctx, reference)
+	if err != nil {
+		return "", fmt.Errorf("%s secret %s: %w", scheme, reference, err)
+	}
+	r.cache[value] = resolved
+	return
+}
+
+// IsReference tells whether the value is a reference to a secret, such as vault:secret/data/bbash#pgPassword.
+func IsReference(value string) bool {
+	_, _, ok := parseReference(value)
+	return ok
+}
+
+func parseReference(value string) (scheme, reference string, ok bool) {
+	scheme, reference, found := cut(value, ":")
+	ok = found && reference != "" && (scheme == SchemeVault || scheme == SchemeAwsSecretsManager)
+	return
+}
+
+// cut is strings.Cut, which go 1.17 lacks
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// secretKey picks the key from the fields of a secret, or the only field when the reference names no key.
+func secretKey(fields map[string]interface{}, key string) (value string, err error) {
+	if key == "" {
+		if len(fields) != 1 {
+			return "", fmt.Errorf("secret has %d keys, name one after #", len(fields))
+		}
+		for only := range fields {
+			key = only
+		}
+	}
+	field, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no key %s", key)
+	}
+	value, ok = field.(string)
+	if !ok {
+		return "", fmt.Errorf("secret key %s is not a string", key)
+	}
+	return
+}
+
+// Vault reads secrets from the KV secrets engine of a HashiCorp Vault server, version 1 or 2. A reference is the
+// path of the secret as read from the API, such as secret/data/bbash#pgPassword.
+type Vault struct {
+	client *http.Client
+	addr   string
+	token  string
+}
+
+var _ Resolver = (*Vault)(nil)
+
+// NewVault returns a resolver reading from the Vault server at addr, such as https://vault.example.com:8200, with the
+// token.
+func NewVault(addr, token string, timeout time.Duration) *Vault {
+	return &Vault{client: &http.Client{Timeout: timeout}, addr: strings.TrimSuffix(addr, "/"), token: token}
+}
+
+func (v *Vault) Resolve(ctx context.Context, reference string) (value string, err error) {
+	path, key, _ := cut(reference, "#")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.addr+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+
+	body, err := readResponse(v.client, req)
+	if err != nil {
+		return
+	}
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err = json.Unmarshal(body, &secret); err != nil {
+		return
+	}
+	fields := secret.Data
+	// version 2 nests the fields under data, next to the metadata
+	if nested, ok := fields["data"].(map[string]interface{}); ok {
+		if _, hasMetadata := fields["metadata"]; hasMetadata {
+			fields = nested
+		}
+	}
+	return secretKey(fields, key)
+}
+
+// AwsSecretsManager reads secrets from AWS Secrets Manager. A reference is the name or ARN of the secret, then # and
+// the key when the secret is a JSON object, such as bbash/prod#pgPassword.
+type AwsSecretsManager struct {
+	client   *http.Client
+	endpoint string
+	region   string
+	// static are the credentials given, and role fetches those of the role when none are
+	static awsCredentials
+	role   *awsRoleCredentials
+	now    func() time.Time
+}
+
+var _ Resolver = (*AwsSecretsManager)(nil)
+
+// NewAwsSecretsManager returns a resolver reading from the region with the credentials of an IAM user or role. The
+// session token is only needed for temporary credentials. Without an access key, the credentials of the role of the
+// ECS task or EC2 instance are used.
+func NewAwsSecretsManager(region, accessKeyId, secretAccessKey, sessionToken string, timeout time.Duration) *AwsSecretsManager {
+	client := &http.Client{Timeout: timeout}
+	a := &AwsSecretsManager{
+		client:   client,
+		endpoint: fmt.Sprintf("https://secretsmanager.%s.amazonaws.com/", region),
+		region:   region,
+		static:   awsCredentials{AccessKeyId: accessKeyId, SecretAccessKey: secretAccessKey, Token: sessionToken},
+		now:      time.Now,
+	}
+	if accessKeyId == "" {
+		a.role = newAwsRoleCredentials(client, os.Getenv)
+	}
+	return a
+}
+
+func (a *AwsSecretsManager) credentials(ctx context.Context) (awsCredentials, error) {
+	if a.role == nil {
+		return a.static, nil
+	}
+	return a.role.get(ctx, a.now())
+}
+
+func (a *AwsSecretsManager) Resolve(ctx context.Context, reference string) (value string, err error) {
+	credentials, err := a.credentials(ctx)
+	if err != nil {
+		return
+	}
+	secretId, key, _ := cut(reference, "#")
+	payload, err := json.Marshal(map[string]string{"SecretId": secretId})
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	if credentials.Token != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.Token)
+	}
+	signV4(req, payload, a.region, "secretsmanager", credentials.AccessKeyId, credentials.SecretAccessKey, a.now())
+
+	body, err := readResponse(a.client, req)
+	if err != nil {
+		return
+	}
+	var secret struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err = json.Unmarshal(body, &secret); err != nil {
+		return
+	}
+	if key == "" {
+		return secret.SecretString, nil
+	}
+	var fields map[string]interface{}
+	if err = json.Unmarshal([]byte(secret.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object, so has no key %s", key)
+	}
+	return secretKey(fields, key)
+}
+
+// readResponse returns the body of a 2xx response, and an error with the status of any other
+func readResponse(client *http.Client, req *http.Request) (body []byte, err error) {
+	res, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	body, err = io.ReadAll(res.Body)
+	if err != nil {
+		return
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		// the body may describe the error, but is not logged in case it holds a secret
+		return nil, fmt.Errorf("status: %d", res.StatusCode)
+	}
+	return
+}
+
+// signV4 signs the request with AWS Signature Version 4, covering the host and every header of the request.
+func signV4(req *http.Request, payload []byte, region, service, accessKeyId, secretAccessKey string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	query := strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20")
+	canonicalRequest := strings.Join([]string{req.Method, path, query, canonicalHeaders.String(), signedHeaders, sha256Hex(payload)}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSha256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSha256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyId, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
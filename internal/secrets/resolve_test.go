@@ -0,0 +1,212 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeResolver counts its calls, returning the reference reversed
+type fakeResolver struct {
+	calls int
+	err   error
+}
+
+func (f *fakeResolver) Resolve(_ context.Context, reference string) (value string, err error) {
+	f.calls++
+	if f.err != nil {
+		return "", f.err
+	}
+	for _, r := range reference {
+		value = string(r) + value
+	}
+	return
+}
+
+func TestResolversResolve(t *testing.T) {
+	vault := &fakeResolver{}
+	resolvers := NewResolvers(map[string]Resolver{SchemeVault: vault})
+
+	for _, value := range []string{"", "plainPassword", "vault:", "https://example.com"} {
+		resolved, err := resolvers.Resolve(context.Background(), value)
+		assert.NoError(t, err)
+		assert.Equal(t, value, resolved)
+		assert.False(t, IsReference(value), value)
+	}
+	assert.Equal(t, 0, vault.calls)
+
+	assert.True(t, IsReference("vault:abc#d"))
+	resolved, err := resolvers.Resolve(context.Background(), "vault:abc#d")
+	assert.NoError(t, err)
+	assert.Equal(t, "d#cba", resolved)
+	// fetched once
+	resolved, err = resolvers.Resolve(context.Background(), "vault:abc#d")
+	assert.NoError(t, err)
+	assert.Equal(t, "d#cba", resolved)
+	assert.Equal(t, 1, vault.calls)
+}
+
+func TestResolversResolveError(t *testing.T) {
+	vault := &fakeResolver{err: fmt.Errorf("forced vault error")}
+	resolvers := NewResolvers(map[string]Resolver{SchemeVault: vault})
+
+	_, err := resolvers.Resolve(context.Background(), "vault:secret/data/bbash#pgPassword")
+	assert.EqualError(t, err, "vault secret secret/data/bbash#pgPassword: forced vault error")
+	// not cached
+	_, err = resolvers.Resolve(context.Background(), "vault:secret/data/bbash#pgPassword")
+	assert.Error(t, err)
+	assert.Equal(t, 2, vault.calls)
+
+	// not taken for the secret itself
+	assert.True(t, IsReference("awssm:bbash/prod#pgPassword"))
+	_, err = resolvers.Resolve(context.Background(), "awssm:bbash/prod#pgPassword")
+	assert.EqualError(t, err, "awssm secret bbash/prod#pgPassword: no awssm secrets backend is configured")
+}
+
+func TestVaultResolve(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "myToken", r.Header.Get("X-Vault-Token"))
+		switch r.URL.Path {
+		case "/v1/secret/data/bbash":
+			_, _ = fmt.Fprint(w, `{"data":{"data":{"pgPassword":"myPgPassword","points":3},"metadata":{"version":2}}}`)
+		case "/v1/kv/bbash":
+			_, _ = fmt.Fprint(w, `{"data":{"adminPassword":"myAdminPassword"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"errors":[]}`)
+		}
+	}))
+	defer ts.Close()
+	vault := NewVault(ts.URL+"/", "myToken", time.Second)
+
+	for _, test := range []struct {
+		reference string
+		value     string
+		err       string
+	}{
+		{"secret/data/bbash#pgPassword", "myPgPassword", ""},
+		{"kv/bbash#adminPassword", "myAdminPassword", ""},
+		// the only key
+		{"kv/bbash", "myAdminPassword", ""},
+		{"secret/data/bbash", "", "secret has 2 keys, name one after #"},
+		{"secret/data/bbash#points", "", "secret key points is not a string"},
+		{"secret/data/bbash#bogus", "", "secret has no key bogus"},
+		{"secret/data/missing#pgPassword", "", "status: 404"},
+	} {
+		value, err := vault.Resolve(context.Background(), test.reference)
+		if test.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, test.err)
+		}
+		assert.Equal(t, test.value, value, test.reference)
+	}
+}
+
+func TestAwsSecretsManagerResolve(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		assert.Equal(t, "mySessionToken", r.Header.Get("X-Amz-Security-Token"))
+		assert.Equal(t, "20220401T120000Z", r.Header.Get("X-Amz-Date"))
+		assert.Regexp(t, `^AWS4-HMAC-SHA256 Credential=myAccessKeyId/20220401/us-east-1/secretsmanager/aws4_request, SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target, Signature=[0-9a-f]{64}$`,
+			r.Header.Get("Authorization"))
+
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		switch string(body) {
+		case `{"SecretId":"bbash/prod"}`:
+			_, _ = fmt.Fprint(w, `{"Name":"bbash/prod","SecretString":"{\"pgPassword\":\"myPgPassword\"}"}`)
+		case `{"SecretId":"bbash/plain"}`:
+			_, _ = fmt.Fprint(w, `{"Name":"bbash/plain","SecretString":"myPlainSecret"}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprint(w, `{"__type":"ResourceNotFoundException"}`)
+		}
+	}))
+	defer ts.Close()
+	sm := NewAwsSecretsManager("us-east-1", "myAccessKeyId", "mySecretAccessKey", "mySessionToken", time.Second)
+	sm.endpoint = ts.URL
+	sm.now = func() time.Time {
+		return time.Date(2022, 4, 1, 12, 0, 0, 0, time.UTC)
+	}
+
+	for _, test := range []struct {
+		reference string
+		value     string
+		err       string
+	}{
+		{"bbash/prod#pgPassword", "myPgPassword", ""},
+		{"bbash/plain", "myPlainSecret", ""},
+		{"bbash/plain#pgPassword", "", "secret is not a JSON object, so has no key pgPassword"},
+		{"bbash/missing", "", "status: 400"},
+	} {
+		value, err := sm.Resolve(context.Background(), test.reference)
+		if test.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, test.err)
+		}
+		assert.Equal(t, test.value, value, test.reference)
+	}
+}
+
+// the example request signed in the AWS Signature Version 4 documentation
+func TestSignV4(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	signV4(req, nil, "us-east-1", "iam", "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, "+
+		"SignedHeaders=content-type;host;x-amz-date, "+
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7", req.Header.Get("Authorization"))
+}
+
+// the requests of the AWS Signature Version 4 test suite, signed with its credentials for its service
+func TestSignV4TestSuite(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		method    string
+		url       string
+		signature string
+	}{
+		{"get-vanilla", http.MethodGet, "https://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-vanilla-query-order-key-case", http.MethodGet, "https://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"get-vanilla-empty-query-key", http.MethodGet, "https://example.amazonaws.com/?Param1=value1", "a67d582fa61cc504c4bae71f336f98b97f1ea3c7a6bfe1b6e45aec72011b9aeb"},
+		{"post-vanilla", http.MethodPost, "https://example.amazonaws.com/", "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+		{"post-vanilla-query", http.MethodPost, "https://example.amazonaws.com/?Param1=value1", "28038455d6de14eafc1f9222cf5aa6f1a96197d7deb8263271d420d138af7f11"},
+	} {
+		req := httptest.NewRequest(test.method, test.url, nil)
+		signV4(req, nil, "us-east-1", "service", "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+			time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+		assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+			"SignedHeaders=host;x-amz-date, Signature="+test.signature, req.Header.Get("Authorization"), test.name)
+	}
+}
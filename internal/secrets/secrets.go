@@ -0,0 +1,134 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package secrets
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Secrets are the credentials that can be rotated without a restart, by changing the file they are read from.
+type Secrets struct {
+	Admins         []Admin `yaml:"admins" json:"admins"`
+	DDClientApiKey string  `yaml:"ddClientApiKey" json:"ddClientApiKey"`
+	DDClientAppKey string  `yaml:"ddClientAppKey" json:"ddClientAppKey"`
+}
+
+// Admin is a user of the admin endpoints. The password is only kept as a bcrypt hash.
+type Admin struct {
+	Username     string `yaml:"username" json:"username"`
+	PasswordHash string `yaml:"passwordHash" json:"passwordHash"`
+}
+
+// ValidAdmin tells whether the credentials are those of one of the admins.
+func (s Secrets) ValidAdmin(username, password string) (valid bool) {
+	for _, admin := range s.Admins {
+		// Be careful to use constant time comparison to prevent timing attacks
+		if subtle.ConstantTimeCompare([]byte(username), []byte(admin.Username)) == 1 &&
+			bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(password)) == nil {
+			valid = true
+		}
+	}
+	return
+}
+
+func (s Secrets) validate() error {
+	for i, admin := range s.Admins {
+		if admin.Username == "" {
+			return fmt.Errorf("admin %d has no username", i)
+		}
+		// a plain password, rather than its hash, is turned away
+		if _, err := bcrypt.Cost([]byte(admin.PasswordHash)); err != nil {
+			return fmt.Errorf("admin %s has an invalid passwordHash: %w", admin.Username, err)
+		}
+	}
+	return nil
+}
+
+// Store holds the secrets of a file, such as a mounted Kubernetes secret, read again by each Reload.
+type Store struct {
+	path     string
+	mu       sync.RWMutex
+	contents []byte
+	secrets  Secrets
+}
+
+// NewStore reads the secrets file at path, which is read as JSON when named *.json, and as YAML otherwise.
+func NewStore(path string) (store *Store, err error) {
+	store = &Store{path: path}
+	if _, err = store.Reload(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Secrets are those last read from the file.
+func (s *Store) Secrets() Secrets {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.secrets
+}
+
+// Reload reads the file again. changed is true when its contents changed. An unreadable or invalid file leaves the
+// secrets as they were, so a half written file does not lock the admins out.
+func (s *Store) Reload() (changed bool, err error) {
+	contents, err := os.ReadFile(s.path)
+	if err != nil {
+		return
+	}
+
+	s.mu.RLock()
+	changed = s.contents == nil || !bytes.Equal(contents, s.contents)
+	s.mu.RUnlock()
+	if !changed {
+		return
+	}
+
+	var secrets Secrets
+	if strings.EqualFold(filepath.Ext(s.path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(contents))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&secrets)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(contents))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&secrets)
+	}
+	if err == nil {
+		err = secrets.validate()
+	}
+	if err != nil {
+		return false, fmt.Errorf("invalid secrets file %s: %w", s.path, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.contents = contents
+	s.secrets = secrets
+	return
+}
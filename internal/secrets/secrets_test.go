@@ -0,0 +1,134 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package secrets
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func hashPassword(t *testing.T, password string) string {
+	// the minimum cost keeps the tests fast
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	assert.NoError(t, err)
+	return string(hash)
+}
+
+func writeSecrets(t *testing.T, path, contents string) {
+	assert.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+}
+
+func TestNewStoreYaml(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.yaml")
+	hash := hashPassword(t, "myPassword")
+	writeSecrets(t, path, fmt.Sprintf(`admins:
+  - username: myAdmin
+    passwordHash: %s
+ddClientApiKey: myApiKey
+ddClientAppKey: myAppKey
+`, hash))
+
+	store, err := NewStore(path)
+	assert.NoError(t, err)
+	assert.Equal(t, Secrets{
+		Admins:         []Admin{{Username: "myAdmin", PasswordHash: hash}},
+		DDClientApiKey: "myApiKey",
+		DDClientAppKey: "myAppKey",
+	}, store.Secrets())
+}
+
+func TestNewStoreJson(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	writeSecrets(t, path, `{"ddClientApiKey": "myApiKey"}`)
+
+	store, err := NewStore(path)
+	assert.NoError(t, err)
+	assert.Equal(t, Secrets{DDClientApiKey: "myApiKey"}, store.Secrets())
+}
+
+func TestNewStoreMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.yaml")
+	_, err := NewStore(path)
+	assert.EqualError(t, err, fmt.Sprintf("open %s: no such file or directory", path))
+}
+
+func TestNewStoreInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.yaml")
+
+	writeSecrets(t, path, "adminz: []\n")
+	_, err := NewStore(path)
+	assert.EqualError(t, err, fmt.Sprintf("invalid secrets file %s: yaml: unmarshal errors:\n  line 1: field adminz not found in type secrets.Secrets", path))
+
+	writeSecrets(t, path, "admins:\n  - passwordHash: x\n")
+	_, err = NewStore(path)
+	assert.EqualError(t, err, fmt.Sprintf("invalid secrets file %s: admin 0 has no username", path))
+
+	// a plain password is not a hash
+	writeSecrets(t, path, "admins:\n  - username: myAdmin\n    passwordHash: myPassword\n")
+	_, err = NewStore(path)
+	assert.EqualError(t, err, fmt.Sprintf("invalid secrets file %s: admin myAdmin has an invalid passwordHash: crypto/bcrypt: hashedSecret too short to be a bcrypted password", path))
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.yaml")
+	writeSecrets(t, path, "ddClientApiKey: myApiKey\n")
+	store, err := NewStore(path)
+	assert.NoError(t, err)
+
+	changed, err := store.Reload()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	writeSecrets(t, path, "ddClientApiKey: myRotatedApiKey\n")
+	changed, err = store.Reload()
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "myRotatedApiKey", store.Secrets().DDClientApiKey)
+
+	// a bad file keeps the secrets as they were, and is read again by the next reload
+	writeSecrets(t, path, "ddClientApiKey: [\n")
+	changed, err = store.Reload()
+	assert.Error(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, "myRotatedApiKey", store.Secrets().DDClientApiKey)
+	_, err = store.Reload()
+	assert.Error(t, err)
+
+	assert.NoError(t, os.Remove(path))
+	_, err = store.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, "myRotatedApiKey", store.Secrets().DDClientApiKey)
+}
+
+func TestValidAdmin(t *testing.T) {
+	secrets := Secrets{Admins: []Admin{
+		{Username: "myAdmin", PasswordHash: hashPassword(t, "myPassword")},
+		{Username: "myOtherAdmin", PasswordHash: hashPassword(t, "myOtherPassword")},
+	}}
+	assert.True(t, secrets.ValidAdmin("myAdmin", "myPassword"))
+	assert.True(t, secrets.ValidAdmin("myOtherAdmin", "myOtherPassword"))
+	assert.False(t, secrets.ValidAdmin("myAdmin", "myOtherPassword"))
+	assert.False(t, secrets.ValidAdmin("", ""))
+	assert.False(t, Secrets{}.ValidAdmin("", ""))
+}
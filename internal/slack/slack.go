@@ -0,0 +1,69 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Notifier posts messages to Slack incoming webhooks.
+type Notifier interface {
+	Post(webhookUrl, text string) (err error)
+}
+
+// WebhookNotifier is the Notifier that calls the webhook over HTTP.
+type WebhookNotifier struct {
+	client *http.Client
+}
+
+var _ Notifier = (*WebhookNotifier)(nil)
+
+// New returns a notifier whose posts give up after the timeout.
+func New(timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{client: &http.Client{Timeout: timeout}}
+}
+
+type message struct {
+	Text string `json:"text"`
+}
+
+func (n *WebhookNotifier) Post(webhookUrl, text string) (err error) {
+	body, err := json.Marshal(message{Text: text})
+	if err != nil {
+		return
+	}
+
+	res, err := n.client.Post(webhookUrl, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode != http.StatusOK {
+		err = fmt.Errorf("slack webhook status: %d", res.StatusCode)
+	}
+	return
+}
@@ -21,6 +21,7 @@ package types
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 )
 
@@ -32,53 +33,306 @@ type SourceControlProviderStruct struct {
 
 type CampaignStruct struct {
 	ID           string         `json:"guid"`
-	Name         string         `json:"name"`
+	Name         string         `json:"name" validate:"required"`
 	CreatedOn    time.Time      `json:"createdOn"`
 	CreatedOrder int            `json:"createdOrder"`
-	StartOn      time.Time      `json:"startOn"`
-	EndOn        time.Time      `json:"endOn"`
+	StartOn      time.Time      `json:"startOn" validate:"required"`
+	EndOn        time.Time      `json:"endOn" validate:"required,gtfield=StartOn"`
 	Note         sql.NullString `json:"note"`
+	// MaxTeamSize limits the number of participants on each team in the campaign, zero means no limit. Nil means no
+	// limit for a new campaign, and no change for an updated one.
+	MaxTeamSize *int `json:"maxTeamSize" validate:"omitempty,gte=0"`
+	// TieBreaker orders the participants with equal scores on the leaderboard, one of the TieBreakerRules. Empty
+	// means TieBreakerReachedScore for a new campaign, and no change for an updated one.
+	TieBreaker string `json:"tieBreaker" validate:"omitempty,oneof=reachedScore bugCategories joinedAt"`
+	// UnclassifiedBonus is the points scored for each fixed bug without a category, zero disables the bonus. Nil
+	// means DefaultUnclassifiedBonus for a new campaign, and no change for an updated one.
+	UnclassifiedBonus *float64 `json:"unclassifiedBonus" validate:"omitempty,gte=0"`
+	// TimeZone is the IANA time zone, like America/New_York, of the local time the campaign starts and ends at. Empty
+	// means DefaultTimeZone for a new campaign, and no change for an updated one.
+	TimeZone string `json:"timeZone" validate:"omitempty,timezone"`
+	// Status is where the campaign is in its lifecycle, one of the CampaignStatus values. It is set by the scheduler
+	// as the campaign starts and ends, never by the request body.
+	Status string `json:"status"`
+	// FreezeScoring stops all scoring once the campaign has ended, even of log entries from before the end that arrive
+	// late. Nil means false for a new campaign, and no change for an updated one.
+	FreezeScoring *bool `json:"freezeScoring"`
+	// RegistrationOpensOn and RegistrationClosesOn bound when participants may join the campaign, independent of
+	// StartOn and EndOn. Nil leaves that side of the window open for a new campaign, and unchanged for an updated one.
+	RegistrationOpensOn  *time.Time `json:"registrationOpensOn,omitempty"`
+	RegistrationClosesOn *time.Time `json:"registrationClosesOn,omitempty"`
+	// TenantName is the tenant owning the campaign, empty for a campaign of the deployment admin. It is set by the
+	// tenant route adding the campaign, never by the request body.
+	TenantName string `json:"tenantName,omitempty"`
+	// Version counts the updates of the campaign. An update must be made from the current version, so it does not
+	// overwrite an update it has not seen. Zero when not read.
+	Version int `json:"version,omitempty"`
+}
+
+// PublicCampaign is a campaign as the public API shows it, without the settings only the admins see.
+type PublicCampaign struct {
+	Name     string    `json:"name"`
+	StartOn  time.Time `json:"startOn"`
+	EndOn    time.Time `json:"endOn"`
+	TimeZone string    `json:"timeZone"`
+	Status   string    `json:"status"`
+}
+
+// DefaultUnclassifiedBonus is the UnclassifiedBonus of a campaign that does not set one.
+const DefaultUnclassifiedBonus float64 = 1
+
+// DefaultTimeZone is the TimeZone of a campaign that does not set one.
+const DefaultTimeZone = "UTC"
+
+// the Status of a campaign
+const (
+	CampaignStatusUpcoming = "upcoming"
+	CampaignStatusActive   = "active"
+	CampaignStatusEnded    = "ended"
+)
+
+// CampaignTransition is a change of the Status of a campaign, made by the scheduler. FirstEnd is set the first time
+// the campaign ends, but not when it ends again after its end was moved later.
+type CampaignTransition struct {
+	Campaign   CampaignStruct
+	FromStatus string
+	FirstEnd   bool
+}
+
+// TenantStruct is an organization with its own campaigns and admin on a shared deployment. The name is part of the
+// tenant routes. AdminPassword is only read when the tenant is added, and is stored as a hash.
+type TenantStruct struct {
+	ID            string    `json:"guid"`
+	Name          string    `json:"name" validate:"required,max=100,excludesall=/?#%"`
+	AdminUsername string    `json:"adminUsername" validate:"required,max=250"`
+	AdminPassword string    `json:"adminPassword,omitempty" validate:"required,min=8,max=72"`
+	CreatedOn     time.Time `json:"createdOn"`
+}
+
+// tie breakers of CampaignStruct
+const (
+	TieBreakerReachedScore  = "reachedScore"
+	TieBreakerBugCategories = "bugCategories"
+	TieBreakerJoinedAt      = "joinedAt"
+)
+
+// TieBreakerRules describes how each tie breaker orders participants with equal scores. Participants still tied are
+// ordered by login name.
+var TieBreakerRules = map[string]string{
+	TieBreakerReachedScore:  "equal scores are ranked by who reached the score first",
+	TieBreakerBugCategories: "equal scores are ranked by who fixed bugs in the most distinct categories",
+	TieBreakerJoinedAt:      "equal scores are ranked by who joined the campaign first",
 }
 
 type OrganizationStruct struct {
 	ID           string `json:"guid"`
-	SCPName      string `json:"scpName"`
-	Organization string `json:"organization"`
+	SCPName      string `json:"scpName" validate:"required"`
+	Organization string `json:"organization" validate:"required"`
+	// CampaignName limits scoring of the organization to one campaign, empty for every campaign
+	CampaignName string `json:"campaignName,omitempty"`
+}
+
+// OrganizationUpdate renames an organization, e.g. after it is renamed in the source control provider.
+type OrganizationUpdate struct {
+	Organization string `json:"organization" validate:"required"`
+	// RelinkScores moves the past scoring events of the old name to the new name
+	RelinkScores bool `json:"relinkScores"`
 }
 
+// ScoringSchemaVersion is the payload version that ScoringMessage has. Payloads of older versions are upgraded to it as
+// they are decoded.
+const ScoringSchemaVersion = 1
+
 type ScoringMessage struct {
-	EventSource string                 `json:"eventSource"`
-	RepoOwner   string                 `json:"repositoryOwner"`
-	RepoName    string                 `json:"repositoryName"`
-	TriggerUser string                 `json:"triggerUser"`
-	TotalFixed  int                    `json:"fixed-bugs"`
-	BugCounts   map[string]interface{} `json:"fixed-bug-types"`
-	PullRequest int                    `json:"pullRequestId"`
+	SchemaVersion int        `json:"schemaVersion"`
+	EventSource   string     `json:"eventSource"`
+	RepoOwner     string     `json:"repositoryOwner"`
+	RepoName      string     `json:"repositoryName"`
+	TriggerUser   string     `json:"triggerUser"`
+	TotalFixed    int        `json:"fixed-bugs"`
+	BugCounts     []BugCount `json:"fixed-bug-types"`
+	PullRequest   int        `json:"pullRequestId"`
+	// TotalIntroduced is the number of new bugs found in the pull request, optional as not every scanner reports it
+	TotalIntroduced int `json:"introduced-bugs"`
+	// Fingerprints identify each fixed bug in its repository, the same in any pull request fixing it. Optional as not
+	// every scanner reports them.
+	Fingerprints []string `json:"fixed-bug-fingerprints,omitempty"`
+	// CoAuthors are the logins, besides TriggerUser, who wrote the pull request, e.g. from its Co-authored-by trailers
+	CoAuthors []string `json:"coAuthors,omitempty"`
+}
+
+// BugCount is the number of fixed bugs of one type, normalized from the payload shape of the scanner.
+type BugCount struct {
+	Type  string  `json:"type"`
+	Count float64 `json:"count"`
 }
 
 type ParticipantStruct struct {
 	ID           string    `json:"guid"`
-	CampaignName string    `json:"campaignName"`
-	ScpName      string    `json:"scpName"`
-	LoginName    string    `json:"loginName"`
-	Email        string    `json:"email"`
+	CampaignName string    `json:"campaignName" validate:"required"`
+	ScpName      string    `json:"scpName" validate:"required"`
+	LoginName    string    `json:"loginName" validate:"required"`
+	Email        string    `json:"email" validate:"omitempty,email"`
 	DisplayName  string    `json:"displayName"`
-	Score        int       `json:"score"`
+	AvatarUrl    string    `json:"avatarUrl"`
+	Score        float64   `json:"score"`
 	TeamName     string    `json:"teamName"`
+	Captain      bool      `json:"captain"`
 	JoinedAt     time.Time `json:"joinedAt"`
+	// Version counts the admin updates of the participant, like the Version of a campaign
+	Version int `json:"version,omitempty"`
+}
+
+// ParticipantProfileRequest changes the contact details of a participant. A field left out is not changed.
+type ParticipantProfileRequest struct {
+	CampaignName string  `json:"campaignName" validate:"required"`
+	Email        *string `json:"email" validate:"omitempty,max=250,email"`
+	DisplayName  *string `json:"displayName" validate:"omitempty,max=250"`
+}
+
+// ParticipantPatch changes only the fields it was sent with, of the participant with the guid. A null email or display
+// name clears it, and a null team name removes the participant from their team.
+type ParticipantPatch struct {
+	ID           string         `json:"guid" validate:"required"`
+	CampaignName OptionalString `json:"campaignName"`
+	ScpName      OptionalString `json:"scpName"`
+	LoginName    OptionalString `json:"loginName" validate:"omitempty,max=250"`
+	Email        OptionalString `json:"email" validate:"omitempty,max=250,email"`
+	DisplayName  OptionalString `json:"displayName" validate:"omitempty,max=250"`
+	Score        OptionalFloat  `json:"score"`
+	TeamName     OptionalString `json:"teamName"`
+	// Version is the version of the participant the patch was made from
+	Version int `json:"version"`
+}
+
+// BanStruct keeps a login of a source control provider out of every campaign. A banned login cannot join a campaign,
+// and scores nothing in a campaign it joined before the ban.
+type BanStruct struct {
+	ID        string    `json:"guid"`
+	ScpName   string    `json:"scpName" validate:"required"`
+	LoginName string    `json:"loginName" validate:"required,max=250"`
+	Reason    string    `json:"reason,omitempty" validate:"max=250"`
+	BannedOn  time.Time `json:"bannedOn"`
+}
+
+// OptionalString is a field of a partial update, which tells a field left out from one sent as null.
+type OptionalString struct {
+	// Set is true when the field was sent, even as null
+	Set   bool
+	Null  bool
+	Value string
+}
+
+func (o *OptionalString) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+// Ptr is the value sent, nil when the field was left out or null
+func (o OptionalString) Ptr() *string {
+	if !o.Set || o.Null {
+		return nil
+	}
+	return &o.Value
+}
+
+// OptionalFloat is a number field of a partial update, which tells a field left out from one sent as null.
+type OptionalFloat struct {
+	Set   bool
+	Null  bool
+	Value float64
+}
+
+func (o *OptionalFloat) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+// Ptr is the value sent, nil when the field was left out or null
+func (o OptionalFloat) Ptr() *float64 {
+	if !o.Set || o.Null {
+		return nil
+	}
+	return &o.Value
 }
 
 type TeamStruct struct {
-	Id           string `json:"guid"`
-	CampaignName string `json:"campaignName"`
-	Name         string `json:"name"`
+	Id           string              `json:"guid"`
+	CampaignName string              `json:"campaignName" validate:"required"`
+	Name         string              `json:"name" validate:"required"`
+	Members      []ParticipantStruct `json:"members,omitempty"`
 }
 
 type BugStruct struct {
-	Id         string `json:"guid"`
-	Campaign   string `json:"campaign"`
-	Category   string `json:"category"`
-	PointValue int    `json:"pointValue"`
+	Id         string  `json:"guid"`
+	Campaign   string  `json:"campaign" validate:"required"`
+	Category   string  `json:"category" validate:"required"`
+	PointValue float64 `json:"pointValue" validate:"gte=0"`
+	// Version counts the updates of the bug, like the Version of a campaign
+	Version int `json:"version,omitempty"`
+}
+
+// PointValueOverride replaces the campaign point value of a bug category, for the repositories of one organization.
+type PointValueOverride struct {
+	Id           string  `json:"guid"`
+	CampaignName string  `json:"campaignName" validate:"required"`
+	ScpName      string  `json:"scpName" validate:"required"`
+	Organization string  `json:"organization" validate:"required"`
+	Category     string  `json:"category" validate:"required"`
+	PointValue   float64 `json:"pointValue" validate:"gte=0"`
+}
+
+// BugCategoryExclusion is a bug category the campaign does not reward, e.g. a noisy one. Its fixed bugs score neither
+// the point value of the category nor the unclassified bonus.
+type BugCategoryExclusion struct {
+	Id           string `json:"guid"`
+	CampaignName string `json:"campaignName" validate:"required"`
+	Category     string `json:"category" validate:"required,max=255"`
+}
+
+// RepositoryFilter leaves repositories of the organizations of a campaign out of its scoring, e.g. forks or docs. A
+// repository matching a deny pattern is not scored, and when the campaign has allow patterns, only a repository
+// matching one of them is. Patterns are those of path.Match, compared without case to the repository name.
+type RepositoryFilter struct {
+	Id           string `json:"guid"`
+	CampaignName string `json:"campaignName" validate:"required"`
+	Kind         string `json:"kind" validate:"oneof=allow deny"`
+	Pattern      string `json:"pattern" validate:"required,max=250"`
+}
+
+const (
+	RepositoryFilterAllow = "allow"
+	RepositoryFilterDeny  = "deny"
+)
+
+// ListVersion changes whenever a list changes, by a row being added, removed or updated.
+type ListVersion struct {
+	Count     int
+	UpdatedOn time.Time
+}
+
+// MigrationStatus is the version of the database schema. Dirty means the last migration failed part way through.
+type MigrationStatus struct {
+	Version uint `json:"version"`
+	Dirty   bool `json:"dirty"`
+}
+
+// BackupManifest describes a backup, with the count of the rows of each table, so it can be checked and restored into
+// the schema it was taken from. ExcludedTables are the tables left out on purpose, with why each is.
+type BackupManifest struct {
+	CreatedOn      time.Time         `json:"createdOn"`
+	BuildVersion   string            `json:"buildVersion"`
+	SchemaVersion  uint              `json:"schemaVersion"`
+	RowCounts      map[string]int64  `json:"rowCounts"`
+	ExcludedTables map[string]string `json:"excludedTables"`
 }
 
 type Poll struct {
@@ -87,3 +341,577 @@ type Poll struct {
 	EnvBaseTime       time.Time `json:"envBaseTime"`
 	LastPollCompleted time.Time `json:"lastPollCompleted"`
 }
+
+// MaintenanceState is whether changes to data are turned away for maintenance, by every replica. Since is when it was
+// turned on.
+type MaintenanceState struct {
+	Enabled bool       `json:"enabled"`
+	Message string     `json:"message,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+// PollState reports whether the poll is running, and when it last started or stopped.
+type PollState struct {
+	Running   bool      `json:"running"`
+	ChangedOn time.Time `json:"changedOn"`
+}
+
+// ScheduledJobRun is the latest run of a scheduled job by any replica, with the count of the runs of every replica.
+// Error is empty when the run succeeded.
+type ScheduledJobRun struct {
+	Name       string    `json:"name"`
+	InstanceId string    `json:"instanceId"`
+	StartedOn  time.Time `json:"startedOn"`
+	FinishedOn time.Time `json:"finishedOn"`
+	Error      string    `json:"error,omitempty"`
+	Runs       int64     `json:"runs"`
+	Failures   int64     `json:"failures"`
+}
+
+// ScheduledJob is a periodic job of the scheduler of a replica. NextRunOn is unset until the scheduler starts.
+type ScheduledJob struct {
+	Name      string           `json:"name"`
+	Schedule  string           `json:"schedule"`
+	Running   bool             `json:"running"`
+	NextRunOn *time.Time       `json:"nextRunOn,omitempty"`
+	LastRun   *ScheduledJobRun `json:"lastRun,omitempty"`
+}
+
+// the Status of a job
+const (
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+)
+
+// Job is a long running operation, answered with 202 and followed by its guid rather than blocking the request. Done
+// counts up to Total while it runs, and Result is what the request would have answered, once the job succeeded.
+type Job struct {
+	ID         string          `json:"guid"`
+	Kind       string          `json:"kind"`
+	Status     string          `json:"status"`
+	InstanceId string          `json:"instanceId"`
+	Done       int64           `json:"done"`
+	Total      int64           `json:"total"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedOn  time.Time       `json:"createdOn"`
+	FinishedOn *time.Time      `json:"finishedOn,omitempty"`
+}
+
+// BackfillRequest is a window of past scoring messages to replay.
+type BackfillRequest struct {
+	From time.Time `json:"from" validate:"required"`
+	To   time.Time `json:"to" validate:"required,gtfield=From"`
+}
+
+// BackfillProgress reports how far a backfill has got. Fetched messages are either processed, or skipped because they
+// were already processed.
+type BackfillProgress struct {
+	From       time.Time  `json:"from"`
+	To         time.Time  `json:"to"`
+	Running    bool       `json:"running"`
+	Fetched    int        `json:"fetched"`
+	Processed  int        `json:"processed"`
+	Skipped    int        `json:"skipped"`
+	StartedOn  time.Time  `json:"startedOn"`
+	FinishedOn *time.Time `json:"finishedOn,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+type JournalEntry struct {
+	Id          string       `json:"guid"`
+	LogId       string       `json:"logId"`
+	ReceivedOn  time.Time    `json:"receivedOn"`
+	RawMessage  []byte       `json:"rawMessage"`
+	ProcessedOn sql.NullTime `json:"processedOn"`
+}
+
+type ClusterInstance struct {
+	InstanceId    string    `json:"instanceId"`
+	Version       string    `json:"version"`
+	Role          string    `json:"role"`
+	PollLeader    bool      `json:"pollLeader"`
+	StartedOn     time.Time `json:"startedOn"`
+	LastHeartbeat time.Time `json:"lastHeartbeat"`
+	Alive         bool      `json:"alive"`
+}
+
+// CampaignConfigStruct is the scoring configuration of a campaign that can be staged and then promoted to live.
+type CampaignConfigStruct struct {
+	Campaign  string      `json:"campaign"`
+	Bugs      []BugStruct `json:"bugs" validate:"dive"`
+	UpdatedOn time.Time   `json:"updatedOn"`
+}
+
+// SlackNotificationStruct is the Slack incoming webhook of a campaign, and which score changes are posted to it.
+// A PointThreshold of 0 posts no threshold messages.
+type SlackNotificationStruct struct {
+	CampaignName   string `json:"campaignName"`
+	WebhookUrl     string `json:"webhookUrl" validate:"required,url"`
+	PointThreshold int    `json:"pointThreshold" validate:"gte=0"`
+	NotifyLead     bool   `json:"notifyLead"`
+}
+
+// CampaignPenaltyStruct takes points off pull requests that introduce bugs. Each introduced bug costs PointValue
+// points, and Floor keeps the points of a pull request from going below zero.
+type CampaignPenaltyStruct struct {
+	CampaignName string  `json:"campaignName"`
+	Enabled      bool    `json:"enabled"`
+	PointValue   float64 `json:"pointValue" validate:"gte=0"`
+	Floor        bool    `json:"floor"`
+}
+
+// CampaignCapsStruct limits the points scored in the campaign, to deter farming points. PerPullRequest caps the points
+// of one pull request, and PerDay the points of the pull requests a participant has scored in a (UTC) day. A cap of
+// zero leaves it off.
+type CampaignCapsStruct struct {
+	CampaignName   string  `json:"campaignName"`
+	PerPullRequest float64 `json:"perPullRequest" validate:"gte=0"`
+	PerDay         float64 `json:"perDay" validate:"gte=0"`
+}
+
+// policies of DuplicatePolicyStruct
+const (
+	DuplicatePolicySkip       = "skip"
+	DuplicatePolicyDownweight = "downweight"
+	DuplicatePolicyScore      = "score"
+)
+
+// policies of CoAuthorPolicyStruct
+const (
+	CoAuthorPolicySplit     = "split"
+	CoAuthorPolicyDuplicate = "duplicate"
+)
+
+// CoAuthorPolicyStruct is how the campaign scores the co-authors of a pull request who are participants: split divides
+// the points evenly between the authors, and duplicate scores each author the full points. A campaign without a policy
+// splits the points.
+type CoAuthorPolicyStruct struct {
+	CampaignName string `json:"campaignName"`
+	Policy       string `json:"policy" validate:"oneof=split duplicate"`
+}
+
+// CampaignPrivacyStruct is whether the public leaderboards of the campaign show each participant by a pseudonym rather
+// than by login name. The pseudonym of a participant is derived from Salt, which the campaign keeps for good, so it does
+// not change when the campaign is anonymized again.
+type CampaignPrivacyStruct struct {
+	CampaignName string `json:"campaignName"`
+	Anonymize    bool   `json:"anonymize"`
+	Salt         string `json:"-"`
+}
+
+// DuplicatePolicyStruct is how the campaign scores a fixed bug that another participant fixed first, in the same
+// repository: skip scores nothing for it, downweight scores Weight of its points, and score scores it in full. A
+// campaign without a policy skips duplicates.
+type DuplicatePolicyStruct struct {
+	CampaignName string  `json:"campaignName"`
+	Policy       string  `json:"policy" validate:"oneof=skip downweight score"`
+	Weight       float64 `json:"weight" validate:"gte=0,lte=1"`
+}
+
+// kinds of CampaignEmailStruct
+const (
+	EmailKindRegistration   = "registration"
+	EmailKindTeamAssignment = "team_assignment"
+	EmailKindCampaignEnd    = "campaign_end"
+)
+
+// CampaignEmailStruct is the template of an email sent to the participants of a campaign. Subject and Body are go
+// text/template templates.
+type CampaignEmailStruct struct {
+	CampaignName string `json:"campaignName"`
+	Kind         string `json:"kind" validate:"oneof=registration team_assignment campaign_end"`
+	Enabled      bool   `json:"enabled"`
+	Subject      string `json:"subject" validate:"required"`
+	Body         string `json:"body" validate:"required"`
+}
+
+// kinds of AchievementStruct
+const (
+	AchievementFirstFix = "first_fix"
+	AchievementTenBugs  = "ten_bugs"
+	AchievementTopThree = "top_three"
+)
+
+// AchievementStruct is a badge earned by a participant. Each kind is awarded at most once per participant.
+type AchievementStruct struct {
+	Kind      string    `json:"kind"`
+	AwardedOn time.Time `json:"awardedOn"`
+}
+
+// AchievementStat is the number of participants of a campaign who earned an achievement.
+type AchievementStat struct {
+	Kind         string `json:"kind"`
+	Participants int    `json:"participants"`
+}
+
+// events sent to webhooks
+const (
+	WebhookEventParticipantAdded = "participant.added"
+	WebhookEventScoreChanged     = "score.changed"
+	WebhookEventCampaignStarted  = "campaign.started"
+	WebhookEventCampaignEnded    = "campaign.ended"
+)
+
+// WebhookStruct is an external system subscribed to events. Events are posted to TargetUrl, signed with Secret. No
+// Events means every event.
+type WebhookStruct struct {
+	ID        string    `json:"guid"`
+	TargetUrl string    `json:"targetUrl" validate:"required,url"`
+	Secret    string    `json:"secret,omitempty" validate:"required,min=16"`
+	Events    []string  `json:"events" validate:"dive,oneof=participant.added score.changed campaign.started campaign.ended"`
+	CreatedOn time.Time `json:"createdOn"`
+}
+
+// WebhookEvent is the payload posted to a webhook.
+type WebhookEvent struct {
+	Event      string      `json:"event"`
+	OccurredOn time.Time   `json:"occurredOn"`
+	Data       interface{} `json:"data"`
+}
+
+// ScoreBreakdown explains how the points of a scoring event were computed. It is stored with the scoring event.
+type ScoreBreakdown struct {
+	Categories []CategoryScore `json:"categories"`
+	// Classified is the number of fixed bugs with a category, scored at the point value of that category
+	Classified float64 `json:"classified"`
+	BasePoints float64 `json:"basePoints"`
+	// Unclassified is the number of fixed bugs without a category, and UnclassifiedBonus the points the bonus of the
+	// campaign scored for them
+	Unclassified      float64 `json:"unclassified,omitempty"`
+	UnclassifiedBonus float64 `json:"unclassifiedBonus"`
+	// Excluded is the number of fixed bugs in a category the campaign excludes, which score nothing
+	Excluded float64 `json:"excluded,omitempty"`
+	// Introduced bugs cost Penalty points, when the campaign has penalties enabled
+	Introduced int     `json:"introduced,omitempty"`
+	Penalty    float64 `json:"penalty,omitempty"`
+	// Capped points were over the caps of the campaign, and not awarded
+	Capped float64 `json:"capped,omitempty"`
+	// Duplicates is the number of fixed bugs another participant fixed first, and DuplicatePoints the points they did
+	// not score
+	Duplicates      int     `json:"duplicates,omitempty"`
+	DuplicatePoints float64 `json:"duplicatePoints,omitempty"`
+	// CoAuthors is the number of co-authors who scored the pull request too, and SplitPoints the points they took when
+	// the campaign splits the points between its authors
+	CoAuthors   int     `json:"coAuthors,omitempty"`
+	SplitPoints float64 `json:"splitPoints,omitempty"`
+	Points      float64 `json:"points"`
+	// PriorPoints were awarded by an earlier scoring of the same pull request, and are subtracted from the delta
+	PriorPoints float64 `json:"priorPoints"`
+	Delta       float64 `json:"delta"`
+}
+
+// BugsFixed is the number of bugs fixed by the scored pull request. Breakdowns recorded before the unclassified count
+// hold only the bonus, which was one point for each unclassified bug until campaigns could change it.
+func (b *ScoreBreakdown) BugsFixed() float64 {
+	unclassified := b.Unclassified
+	if unclassified == 0 {
+		unclassified = b.UnclassifiedBonus
+	}
+	return b.Classified + unclassified
+}
+
+type CategoryScore struct {
+	Category   string  `json:"category"`
+	Count      float64 `json:"count"`
+	PointValue float64 `json:"pointValue"`
+	Points     float64 `json:"points"`
+}
+
+// ScoringDryRun is how a scoring message would be scored, without scoring it. Message is the scoring message as it was
+// read, and Participants are each participant it would score.
+type ScoringDryRun struct {
+	Message      ScoringMessage           `json:"message"`
+	Participants []DryRunParticipantScore `json:"participants"`
+}
+
+type DryRunParticipantScore struct {
+	CampaignName string          `json:"campaignName"`
+	ScpName      string          `json:"scpName"`
+	LoginName    string          `json:"loginName"`
+	TeamName     string          `json:"teamName"`
+	Points       float64         `json:"points"`
+	Breakdown    *ScoreBreakdown `json:"breakdown"`
+}
+
+type ScoringEventStruct struct {
+	ID           string          `json:"guid"`
+	CampaignName string          `json:"campaignName"`
+	ScpName      string          `json:"scpName"`
+	RepoOwner    string          `json:"repositoryOwner"`
+	RepoName     string          `json:"repositoryName"`
+	PullRequest  int             `json:"pullRequestId"`
+	UserName     string          `json:"username"`
+	Points       float64         `json:"points"`
+	Breakdown    *ScoreBreakdown `json:"breakdown"`
+	// VoidedOn is when an admin voided the event, and took its points off the participant. Nil while the event counts.
+	VoidedOn *time.Time `json:"voidedOn,omitempty"`
+	// CoAuthors are the shares of the co-authors of the pull request, only read when the event is voided
+	CoAuthors []CoAuthorScore `json:"coAuthors,omitempty"`
+	// ScoredOn is when the pull request was last scored, only read by the export. Nil for events scored before the
+	// time was recorded.
+	ScoredOn *time.Time `json:"scoredOn,omitempty"`
+}
+
+// ScoringEventImport is the outcome of an import of scoring events. The skipped events are of pull requests the
+// campaign had already scored.
+type ScoringEventImport struct {
+	CampaignName string `json:"campaignName"`
+	Read         int    `json:"read"`
+	Imported     int64  `json:"imported"`
+	Skipped      int64  `json:"skipped"`
+	// Recomputed is how many participants had their score recomputed
+	Recomputed int64 `json:"participantsRecomputed"`
+}
+
+// ScoringEventArchive is the scoring events of an ended campaign, moved out of the scoring events the server reads, so
+// they stay few. RestoredOn is set once the events are moved back.
+type ScoringEventArchive struct {
+	CampaignName string     `json:"campaignName"`
+	EventCount   int64      `json:"eventCount"`
+	ArchivedOn   time.Time  `json:"archivedOn"`
+	RestoredOn   *time.Time `json:"restoredOn,omitempty"`
+}
+
+// CoAuthorScore is the points a co-author scored for the pull request of a scoring event.
+type CoAuthorScore struct {
+	LoginName string  `json:"loginName"`
+	TeamName  string  `json:"teamName,omitempty"`
+	Points    float64 `json:"points"`
+}
+
+// reasons of ScoringFlag
+const (
+	// ScoringFlagBurst is a participant scoring many events within minutes
+	ScoringFlagBurst = "burst"
+	// ScoringFlagIdentical is a participant scoring the same breakdown of fixed bugs in several repositories
+	ScoringFlagIdentical = "identical"
+)
+
+// resolutions of ScoringFlag
+const (
+	ScoringFlagApproved = "approved"
+	ScoringFlagVoided   = "voided"
+)
+
+// ScoringFlag is a scoring event held in the review queue, for the Reason the anomaly job flagged it. The points of
+// the event still count while it is in the queue. ResolvedOn and Resolution are set once an admin approves or voids it.
+type ScoringFlag struct {
+	ID         string             `json:"guid"`
+	Reason     string             `json:"reason"`
+	FlaggedOn  time.Time          `json:"flaggedOn"`
+	ResolvedOn *time.Time         `json:"resolvedOn,omitempty"`
+	Resolution string             `json:"resolution,omitempty"`
+	Event      ScoringEventStruct `json:"event"`
+}
+
+// kinds of NotificationStruct
+const (
+	NotificationKindPointsAwarded = "pointsAwarded"
+	NotificationKindAchievement   = "achievement"
+)
+
+// NotificationStruct is an entry in the notification inbox of a participant.
+type NotificationStruct struct {
+	Id            string       `json:"guid"`
+	ParticipantId string       `json:"participantId"`
+	Kind          string       `json:"kind"`
+	Message       string       `json:"message"`
+	CreatedOn     time.Time    `json:"createdOn"`
+	ReadOn        sql.NullTime `json:"readOn"`
+}
+
+// ScoreDelta is pushed to live leaderboard subscribers when the score of a participant changes.
+type ScoreDelta struct {
+	CampaignName string  `json:"campaignName"`
+	ScpName      string  `json:"scpName"`
+	LoginName    string  `json:"loginName"`
+	TeamName     string  `json:"teamName"`
+	Delta        float64 `json:"delta"`
+}
+
+// LeaderboardEntry is one participant on the leaderboard. Every participant has a distinct rank, as equal scores are
+// ordered by the tie breaker of the campaign.
+type LeaderboardEntry struct {
+	Rank        int     `json:"rank"`
+	ScpName     string  `json:"scpName"`
+	LoginName   string  `json:"loginName"`
+	DisplayName string  `json:"displayName"`
+	AvatarUrl   string  `json:"avatarUrl"`
+	TeamName    string  `json:"teamName"`
+	Score       float64 `json:"score"`
+}
+
+// Leaderboard ranks the participants of a campaign by score, as of RefreshedOn. RefreshedOn is left out if the
+// campaign has no ranked participants yet. TieBreakerRule describes the TieBreaker in words.
+type Leaderboard struct {
+	CampaignName   string             `json:"campaignName"`
+	TieBreaker     string             `json:"tieBreaker"`
+	TieBreakerRule string             `json:"tieBreakerRule"`
+	RefreshedOn    *time.Time         `json:"refreshedOn,omitempty"`
+	Participants   []LeaderboardEntry `json:"participants"`
+}
+
+// LeaderboardSnapshot is the official final ranks of a campaign, as of FinalizedOn. It is never changed by later
+// corrections of scores.
+type LeaderboardSnapshot struct {
+	CampaignName   string             `json:"campaignName"`
+	TieBreaker     string             `json:"tieBreaker"`
+	TieBreakerRule string             `json:"tieBreakerRule"`
+	FinalizedOn    time.Time          `json:"finalizedOn"`
+	Participants   []LeaderboardEntry `json:"participants"`
+}
+
+// TeamAssignment puts a participant on a team, as one entry of a bulk team assignment.
+type TeamAssignment struct {
+	CampaignName string `json:"campaign" validate:"required"`
+	ScpName      string `json:"scp" validate:"required"`
+	LoginName    string `json:"login" validate:"required"`
+	TeamName     string `json:"team" validate:"required"`
+}
+
+// SeedFixture is a known state loaded in one go, so preview environments and end to end tests start from it. A
+// participant naming a team is put on it.
+type SeedFixture struct {
+	Campaigns     []CampaignStruct     `json:"campaigns" validate:"dive"`
+	Bugs          []BugStruct          `json:"bugs" validate:"dive"`
+	Organizations []OrganizationStruct `json:"organizations" validate:"dive"`
+	Teams         []TeamStruct         `json:"teams" validate:"dive"`
+	Participants  []ParticipantStruct  `json:"participants" validate:"dive"`
+}
+
+// TeamAssignmentResult is the outcome of one entry of a bulk team assignment. Error is empty when the entry could be
+// applied.
+type TeamAssignmentResult struct {
+	TeamAssignment
+	Assigned bool   `json:"assigned"`
+	Error    string `json:"error,omitempty"`
+}
+
+// kinds of TeamScoreEvent
+const (
+	TeamScoreKindPullRequest = "pullRequest"
+	TeamScoreKindJudgedAward = "judgedAward"
+)
+
+// TeamScoreAdjustmentRequest grants points to teams for an organizer judged award category.
+type TeamScoreAdjustmentRequest struct {
+	Category    string                `json:"category"`
+	Adjustments []TeamScoreAdjustment `json:"adjustments"`
+}
+
+type TeamScoreAdjustment struct {
+	TeamName string  `json:"teamName"`
+	Points   float64 `json:"points"`
+	Reason   string  `json:"reason"`
+}
+
+// TeamScoreEvent is an entry in the score history of a team. Pull request events come from the scoring of team
+// members, and are created on when they were scored. Those scored before that was recorded have no CreatedOn.
+type TeamScoreEvent struct {
+	Id        string       `json:"guid"`
+	TeamName  string       `json:"teamName"`
+	Kind      string       `json:"kind"`
+	Category  string       `json:"category"`
+	LoginName string       `json:"loginName"`
+	Points    float64      `json:"points"`
+	Reason    string       `json:"reason"`
+	CreatedOn sql.NullTime `json:"createdOn"`
+}
+
+// CampaignReport is the end of campaign report.
+type CampaignReport struct {
+	Campaign        CampaignStruct      `json:"campaign"`
+	GeneratedOn     time.Time           `json:"generatedOn"`
+	Stats           CampaignStats       `json:"stats"`
+	TopParticipants []ParticipantStruct `json:"topParticipants"`
+	TopTeams        []TeamStanding      `json:"topTeams"`
+	Categories      []ReportCategory    `json:"categories"`
+	Repositories    []RepositoryImpact  `json:"repositories"`
+	Timeline        []TimelineDay       `json:"timeline"`
+}
+
+type CampaignStats struct {
+	Participants      int     `json:"participants"`
+	Teams             int     `json:"teams"`
+	PullRequests      int     `json:"pullRequests"`
+	Points            float64 `json:"points"`
+	JudgedAwardPoints float64 `json:"judgedAwardPoints"`
+}
+
+type TeamStanding struct {
+	TeamName     string  `json:"teamName"`
+	MemberPoints float64 `json:"memberPoints"`
+	AwardPoints  float64 `json:"awardPoints"`
+	Points       float64 `json:"points"`
+}
+
+// TeamLeaderboard ranks the members of one team of a campaign by score, as of RefreshedOn, with the totals of the team.
+// RefreshedOn is left out if the team has no ranked members yet.
+type TeamLeaderboard struct {
+	CampaignName string `json:"campaignName"`
+	TeamStanding
+	RefreshedOn  *time.Time         `json:"refreshedOn,omitempty"`
+	Participants []LeaderboardEntry `json:"participants"`
+}
+
+type ReportCategory struct {
+	Category string  `json:"category"`
+	Count    float64 `json:"count"`
+	Points   float64 `json:"points"`
+}
+
+type RepositoryImpact struct {
+	RepoOwner    string  `json:"repositoryOwner"`
+	RepoName     string  `json:"repositoryName"`
+	PullRequests int     `json:"pullRequests"`
+	Points       float64 `json:"points"`
+}
+
+type TimelineDay struct {
+	Day                time.Time `json:"day"`
+	PullRequests       int       `json:"pullRequests"`
+	Points             float64   `json:"points"`
+	ParticipantsJoined int       `json:"participantsJoined"`
+}
+
+// ParticipantScoreAt is the score a participant had at a point in time, rebuilt from the scoring events. ScoringEvents
+// is the number of scored pull requests counted.
+type ParticipantScoreAt struct {
+	CampaignName  string    `json:"campaignName"`
+	ScpName       string    `json:"scpName"`
+	LoginName     string    `json:"loginName"`
+	At            time.Time `json:"at"`
+	Score         float64   `json:"score"`
+	ScoringEvents int       `json:"scoringEvents"`
+}
+
+// TimeSeriesPoint is the points scored in an interval starting at Start. CumulativePoints includes every earlier
+// interval, for burn-up charts. TeamName is set when the series is split by team, and is empty for participants
+// without a team.
+type TimeSeriesPoint struct {
+	Start            time.Time `json:"start"`
+	TeamName         string    `json:"teamName,omitempty"`
+	Points           float64   `json:"points"`
+	CumulativePoints float64   `json:"cumulativePoints"`
+}
+
+// TelemetryUsage is the number of times a feature was used.
+type TelemetryUsage struct {
+	Feature string `json:"feature"`
+	Calls   int    `json:"calls"`
+}
+
+// AuditLogEntry is a mutating admin call. Route is the path pattern of the endpoint, and Payload is the request body
+// with secrets redacted.
+type AuditLogEntry struct {
+	ID        string    `json:"guid"`
+	Actor     string    `json:"actor"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Route     string    `json:"route"`
+	Status    int       `json:"status"`
+	Payload   string    `json:"payload"`
+	CreatedOn time.Time `json:"createdOn"`
+}
@@ -0,0 +1,134 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+const (
+	HeaderEvent     = "X-BBash-Event"
+	HeaderSignature = "X-BBash-Signature"
+)
+
+// Deliverer sends events to webhooks.
+type Deliverer interface {
+	Deliver(hook types.WebhookStruct, event types.WebhookEvent)
+}
+
+// Dispatcher is the Deliverer that posts events over HTTP, retrying failed posts with an exponential backoff.
+type Dispatcher struct {
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+	logger   *zap.Logger
+	// resolveSecret, when set, turns the secret of a webhook into the key the events are signed with
+	resolveSecret func(secret string) (string, error)
+}
+
+var _ Deliverer = (*Dispatcher)(nil)
+
+// New returns a dispatcher making up to attempts posts of each event, waiting backoff after the first failure, and
+// twice as long after each failure after that.
+func New(logger *zap.Logger, timeout time.Duration, attempts int, backoff time.Duration) *Dispatcher {
+	return &Dispatcher{client: &http.Client{Timeout: timeout}, attempts: attempts, backoff: backoff, logger: logger}
+}
+
+// SetSecretResolver sets how the secret of a webhook is turned into the key the events are signed with, such as by
+// fetching the secret a reference to a secrets backend names.
+func (d *Dispatcher) SetSecretResolver(resolve func(secret string) (string, error)) {
+	d.resolveSecret = resolve
+}
+
+// Deliver posts the event in the background, so a slow or failing webhook never holds up the caller.
+func (d *Dispatcher) Deliver(hook types.WebhookStruct, event types.WebhookEvent) {
+	go func() {
+		_ = d.deliver(hook, event)
+	}()
+}
+
+func (d *Dispatcher) deliver(hook types.WebhookStruct, event types.WebhookEvent) (err error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		d.logger.Error("webhook payload error", zap.String("event", event.Event), zap.Error(err))
+		return
+	}
+	if d.resolveSecret != nil {
+		if hook.Secret, err = d.resolveSecret(hook.Secret); err != nil {
+			d.logger.Error("webhook secret error", zap.String("webhookId", hook.ID), zap.String("event", event.Event), zap.Error(err))
+			return
+		}
+	}
+
+	wait := d.backoff
+	for attempt := 1; attempt <= d.attempts; attempt++ {
+		if err = d.post(hook, event.Event, body); err == nil {
+			return
+		}
+		d.logger.Warn("webhook post failed", zap.String("webhookId", hook.ID), zap.String("event", event.Event),
+			zap.Int("attempt", attempt), zap.Error(err))
+		if attempt < d.attempts {
+			time.Sleep(wait)
+			wait *= 2
+		}
+	}
+	d.logger.Error("webhook delivery failed", zap.String("webhookId", hook.ID), zap.String("event", event.Event), zap.Error(err))
+	return
+}
+
+func (d *Dispatcher) post(hook types.WebhookStruct, eventName string, body []byte) (err error) {
+	req, err := http.NewRequest(http.MethodPost, hook.TargetUrl, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, eventName)
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		err = fmt.Errorf("webhook status: %d", res.StatusCode)
+	}
+	return
+}
+
+// Sign computes the signature header value of the body, so the receiver can check the post came from us. It is the
+// hex encoded HMAC-SHA256 of the body, keyed with the secret of the webhook.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,137 @@
+//
+// Copyright (c) 2021-present Sonatype, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+//go:build go1.16
+// +build go1.16
+
+package webhook
+
+import (
+	"fmt"
+	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zaptest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const secret = "mySecretThatIsLongEnough"
+
+var event = types.WebhookEvent{
+	Event:      types.WebhookEventCampaignEnded,
+	OccurredOn: time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+	Data:       map[string]string{"name": "myCampaignName"},
+}
+
+const eventJson = `{"event":"campaign.ended","occurredOn":"2022-01-02T03:04:05Z","data":{"name":"myCampaignName"}}`
+
+func TestSign(t *testing.T) {
+	assert.Equal(t, "sha256=515aae133b435d4000956731f68ae5cf5eb85d4f0dc6a546d2bfcd3595ec1ae1", Sign("key", []byte("body")))
+}
+
+func TestDeliver(t *testing.T) {
+	posted := make(chan *http.Request, 1)
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		posted <- r
+	}))
+	defer server.Close()
+
+	New(zaptest.NewLogger(t), time.Second, 1, 0).
+		Deliver(types.WebhookStruct{ID: "myId", TargetUrl: server.URL, Secret: secret}, event)
+
+	r := <-posted
+	assert.Equal(t, http.MethodPost, r.Method)
+	assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+	assert.Equal(t, types.WebhookEventCampaignEnded, r.Header.Get(HeaderEvent))
+	assert.Equal(t, eventJson, string(body))
+	assert.Equal(t, Sign(secret, []byte(eventJson)), r.Header.Get(HeaderSignature))
+}
+
+func TestDeliverRetries(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	d := New(zaptest.NewLogger(t), time.Second, 3, time.Millisecond)
+	assert.NoError(t, d.deliver(types.WebhookStruct{TargetUrl: server.URL, Secret: secret}, event))
+	assert.Equal(t, 3, calls)
+}
+
+func TestDeliverGivesUp(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	d := New(zaptest.NewLogger(t), time.Second, 2, time.Millisecond)
+	assert.EqualError(t, d.deliver(types.WebhookStruct{TargetUrl: server.URL, Secret: secret}, event), "webhook status: 500")
+	assert.Equal(t, 2, calls)
+}
+
+func TestDeliverBadUrl(t *testing.T) {
+	d := New(zaptest.NewLogger(t), time.Second, 1, 0)
+	assert.Error(t, d.deliver(types.WebhookStruct{TargetUrl: "::not a url", Secret: secret}, event))
+}
+
+func TestDeliverPayloadError(t *testing.T) {
+	d := New(zaptest.NewLogger(t), time.Second, 1, 0)
+	badEvent := types.WebhookEvent{Event: types.WebhookEventScoreChanged, Data: make(chan int)}
+	assert.Error(t, d.deliver(types.WebhookStruct{TargetUrl: "http://localhost", Secret: secret}, badEvent))
+}
+
+func TestDeliverResolvedSecret(t *testing.T) {
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(HeaderSignature)
+	}))
+	defer server.Close()
+
+	d := New(zaptest.NewLogger(t), time.Second, 1, 0)
+	d.SetSecretResolver(func(reference string) (string, error) {
+		assert.Equal(t, "vault:kv/bbash#webhookSecret", reference)
+		return secret, nil
+	})
+	assert.NoError(t, d.deliver(types.WebhookStruct{TargetUrl: server.URL, Secret: "vault:kv/bbash#webhookSecret"}, event))
+	assert.Equal(t, Sign(secret, []byte(eventJson)), signature)
+}
+
+func TestDeliverSecretError(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	d := New(zaptest.NewLogger(t), time.Second, 1, 0)
+	d.SetSecretResolver(func(reference string) (string, error) {
+		return "", fmt.Errorf("forced secret error")
+	})
+	// never posted unsigned
+	assert.EqualError(t, d.deliver(types.WebhookStruct{TargetUrl: server.URL, Secret: "vault:kv/bbash#webhookSecret"}, event), "forced secret error")
+	assert.Equal(t, 0, calls)
+}
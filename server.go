@@ -17,21 +17,64 @@
 package main
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"crypto/subtle"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/lib/pq"
+	"github.com/sonatype-nexus-community/bbash/internal/config"
 	"github.com/sonatype-nexus-community/bbash/internal/db"
+	"github.com/sonatype-nexus-community/bbash/internal/errreport"
+	"github.com/sonatype-nexus-community/bbash/internal/events"
+	"github.com/sonatype-nexus-community/bbash/internal/grpcapi"
+	"github.com/sonatype-nexus-community/bbash/internal/hub"
+	"github.com/sonatype-nexus-community/bbash/internal/mail"
+	"github.com/sonatype-nexus-community/bbash/internal/metrics"
 	"github.com/sonatype-nexus-community/bbash/internal/poll"
+	"github.com/sonatype-nexus-community/bbash/internal/profile"
+	"github.com/sonatype-nexus-community/bbash/internal/scheduler"
+	"github.com/sonatype-nexus-community/bbash/internal/secrets"
+	"github.com/sonatype-nexus-community/bbash/internal/slack"
 	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/sonatype-nexus-community/bbash/internal/webhook"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/net/websocket"
+	"golang.org/x/time/rate"
+	"html/template"
+	"io"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path"
+	"reflect"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	texttemplate "text/template"
 	"time"
+	// the image has no zoneinfo, which the campaign time zones need
+	_ "time/tzdata"
 
 	"github.com/sonatype-nexus-community/bbash/buildversion"
 
@@ -44,10 +87,70 @@ var postgresDB db.IBBashDB
 var scoreDB db.IScoreDB
 var pollDB db.IDBPoll
 
+// scoreHub carries score changes to the live leaderboard sockets
+var scoreHub = hub.New()
+
+// scoreEvents carries score changes to what reacts to them, such as the leaderboard sockets, webhooks, notifications
+// and achievements
+var scoreEvents = newScoreEvents()
+
+// slackTimeout is how long a post to a Slack webhook may take, so a slow Slack does not hold up scoring
+const slackTimeout = 5 * time.Second
+
+var slackNotifier slack.Notifier = slack.New(slackTimeout)
+
+// webhook posts are made up to webhookAttempts times, backing off from webhookBackoff between failed attempts
+const webhookTimeout = 10 * time.Second
+const webhookAttempts = 5
+const webhookBackoff = 2 * time.Second
+
+// profileTimeout is how long a read of a participant profile from GitHub or GitLab may take, as registration waits
+// for it
+const profileTimeout = 5 * time.Second
+
+// profileRefreshBatch is the most participant profiles refreshed on each heartbeat
+const profileRefreshBatch = 10
+
+// profileRefreshAge is how old a participant profile gets before it is refreshed
+var profileRefreshAge = 24 * time.Hour
+
+// profileFetcher reads participant profiles from the source control providers, nil to not read them
+var profileFetcher profile.Fetcher
+
+// participantAuthenticator finds the login of the access token a participant sends with their own changes, nil when
+// participants can not sign in
+var participantAuthenticator profile.Authenticator
+
+// mailer sends the campaign emails to participants, nil when no mail server is configured
+var mailer mail.Mailer
+
+// webhookDeliverer sends events to the subscribed webhooks, set up in main once the logger exists
+var webhookDeliverer webhook.Deliverer
+
+// routeMetrics counts the requests to each route, by duration and by status class
+var routeMetrics = metrics.New(metrics.DefaultBuckets)
+
+// secretsBackendTimeout is how long a read of the secrets from a secrets backend may take
+const secretsBackendTimeout = 10 * time.Second
+
+// secretResolvers fetch the secrets the config and the webhooks name in a secrets backend, rather than holding them
+var secretResolvers = secrets.NewResolvers(nil)
+
+// secretsStore holds the admins and datadog keys of the secrets file, nil when there is no secrets file
+var secretsStore *secrets.Store
+
+// errorReportTimeout is how long a post of an error to the error tracker may take
+const errorReportTimeout = 5 * time.Second
+
+// errorReporter is sent the unexpected errors, so they are noticed rather than only logged
+var errorReporter errreport.Reporter = errreport.NoReporter{}
+
+// creationResponse is sent by every endpoint adding something: its id, the endpoints reaching what was added, and
+// what was added
 type creationResponse struct {
-	Id        string                 `json:"guid"`
-	Endpoints map[string]interface{} `json:"endpoints"`
-	Object    interface{}            `json:"object"`
+	Id        string                    `json:"guid"`
+	Endpoints map[string]endpointDetail `json:"endpoints"`
+	Object    interface{}               `json:"object"`
 }
 
 type endpointDetail struct {
@@ -60,63 +163,380 @@ const (
 	ParamLoginName        string = "loginName"
 	ParamCampaignName     string = "campaignName"
 	ParamTeamName         string = "teamName"
+	ParamNewTeamName      string = "newTeamName"
 	ParamBugCategory      string = "bugCategory"
 	ParamPointValue       string = "pointValue"
 	ParamOrganizationName string = "organizationName"
+	ParamRepoOwner        string = "repoOwner"
+	ParamRepoName         string = "repoName"
+	ParamPullRequest      string = "pullRequest"
+	ParamNotificationId   string = "notificationId"
+	ParamWebhookId        string = "webhookId"
+	ParamRepositoryFilter string = "repositoryFilterId"
+	ParamEmailKind        string = "emailKind"
+	ParamScoreEventId     string = "scoreEventId"
+	ParamScoringFlagId    string = "scoringFlagId"
+	ParamTenantName       string = "tenantName"
+	ParamLogLevel         string = "logLevel"
+	ParamJobId            string = "jobId"
 	pathAdmin             string = "/admin"
+	pathPublic            string = "/public"
 	SourceControlProvider string = "/scp"
 	Organization          string = "/organization"
 	Participant           string = "/participant"
 	Detail                string = "/detail"
 	List                  string = "/list"
 	active                string = "/active"
+	upcoming              string = "/upcoming"
 	Update                string = "/update"
 	Delete                string = "/delete"
 	Team                  string = "/team"
 	Add                   string = "/add"
 	Person                string = "/person"
+	Captain               string = "/captain"
+	Rename                string = "/rename"
+	Adjust                string = "/adjust"
+	History               string = "/history"
 	Bug                   string = "/bug"
 	Campaign              string = "/campaign"
 	Poll                  string = "/poll"
+	Score                 string = "/score"
+	ScoreEvent            string = "/score-event"
+	Notification          string = "/notification"
+	Read                  string = "/read"
+	WebSocket             string = "/ws"
+	Leaderboard           string = "/leaderboard"
+	Cluster               string = "/cluster"
+	Stage                 string = "/stage"
+	Promote               string = "/promote"
+	Report                string = "/report"
+	Migrations            string = "/migrations"
+	Backup                string = "/backup"
+	Slack                 string = "/slack"
+	Webhooks              string = "/webhooks"
+	Email                 string = "/email"
+	Achievements          string = "/achievements"
+	TimeSeries            string = "/timeseries"
+	Telemetry             string = "/telemetry"
+	Audit                 string = "/audit"
+	Profile               string = "/profile"
+	Search                string = "/search"
+	Upsert                string = "/upsert"
+	Bulk                  string = "/bulk"
+	Penalty               string = "/penalty"
+	Override              string = "/override"
+	Rollback              string = "/rollback"
+	Backfill              string = "/backfill"
+	Pause                 string = "/pause"
+	Resume                string = "/resume"
+	DryRun                string = "/dryrun"
+	Push                  string = "/push"
+	Config                string = "/config"
+	Tenant                string = "/tenant"
+	Final                 string = "/final"
+	Seed                  string = "/seed"
+	Routes                string = "/routes"
+	Log                   string = "/log"
+	Metrics               string = "/metrics"
+	Level                 string = "/level"
+	Maintenance           string = "/maintenance"
+	Info                  string = "/info"
+	Version               string = "/version"
+	Ban                   string = "/ban"
+	RepositoryFilter      string = "/repository-filter"
+	Exclusion             string = "/exclusion"
+	Caps                  string = "/caps"
+	Duplicates            string = "/duplicates"
+	CoAuthors             string = "/coauthors"
+	Privacy               string = "/privacy"
+	Flag                  string = "/flag"
+	Export                string = "/export"
+	Import                string = "/import"
+	Archive               string = "/archive"
+	Restore               string = "/restore"
+	Jobs                  string = "/jobs"
+	Approve               string = "/approve"
+	Void                  string = "/void"
 	buildLocation         string = "build"
 )
 
-const defaultServicePort = ":7777"
+const defaultServicePort = 7777
+
+// where Let's Encrypt certificates are kept between restarts, unless TLS_AUTOCERT_CACHE_DIR says otherwise
+const defaultAutocertCacheDir = ".autocert"
+
+// headerScpName names the source control provider of the access token a participant signs their own changes with
+const headerScpName = "X-BBash-Scp-Name"
+
+// headerApiVersion asks for the responses of an earlier version of the api. Only apiVersion1 is known.
+const headerApiVersion = "X-BBash-Api-Version"
+
+// apiVersion1 is the api before every add endpoint sent a creationResponse, when some sent only the id, or only what
+// was added
+const apiVersion1 = "1"
+
+// headerApiKey identifies an api client, which is rate limited separately from its IP address
+const headerApiKey = "X-BBash-Api-Key"
+
+// headers of a pushed scoring message, signed like the webhook posts, over the timestamp, a dot, then the body
+const headerSignature = "X-Signature"
+const headerSignatureTimestamp = "X-Signature-Timestamp"
 
-const envPGHost = "PG_HOST"
-const envPGPort = "PG_PORT"
-const envPGUsername = "PG_USERNAME"
-const envPGPassword = "PG_PASSWORD"
-const envPGDBName = "PG_DB_NAME"
-const envSSLMode = "SSL_MODE"
-const envAdminUsername = "ADMIN_USERNAME"
-const envAdminPassword = "ADMIN_PASSWORD"
-const envLogFilterIncludeHostname = "LOG_FILTER_INCLUDE_HOSTNAME"
+// scoringSignatureTolerance is how far the timestamp of a pushed scoring message may be from now, so a captured
+// message can not be replayed later
+const scoringSignatureTolerance = 5 * time.Minute
 
 var errRecovered error
 var logger *zap.Logger
 
-var stopPoll chan bool
+// logLevel is the level of logger, which an admin can change while the server runs
+var logLevel = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+
+// logLevels are those an admin can set
+var logLevels = []zapcore.Level{zapcore.DebugLevel, zapcore.InfoLevel, zapcore.WarnLevel}
+
+// maintenance turns away the changes to data while it is on, so migrations and backfills can run during a live event
+var maintenance = &maintenanceMode{}
+
+// maintenanceExemptJobs are the scheduled jobs still run while in maintenance, as they change no campaign data
+var maintenanceExemptJobs = map[string]bool{
+	"heartbeat":      true,
+	"secrets-reload": true,
+}
+
+// maintenanceExemptRoutes are the changes still allowed while in maintenance: turning maintenance off, pausing the poll,
+// and changing the log level, along with the dry run of scoring and the backup, which change nothing
+var maintenanceExemptRoutes = map[string]bool{
+	pathAdmin + Maintenance:   true,
+	pathAdmin + Poll + Pause:  true,
+	pathAdmin + Poll + Resume: true,
+	fmt.Sprintf("%s%s%s/:%s", pathAdmin, Log, Level, ParamLogLevel): true,
+	pathAdmin + Score + DryRun:                                      true,
+	pathAdmin + Backup:                                              true,
+}
+
+// pollController starts and stops the datadog poll, replaced in main by one polling with the loaded config
+var pollController = poll.NewController(func() (quit chan bool, errChan chan error, err error) {
+	return beginLogPolling(config.Defaults())
+})
+
+// backfill replays past windows of the poll, one at a time
+var backfill = poll.NewBackfill()
+
+// commands of the bbash binary
+const (
+	commandServe   = "serve"
+	commandMigrate = "migrate"
+	commandSeed    = "seed"
+	commandRoutes  = "routes"
+)
+
+// the campaign, organization and participant added by the seed command, unless its flags say otherwise
+const (
+	seedCampaignName = "localCampaign"
+	seedScpName      = "GitHub"
+	seedOrganization = "sonatype-nexus-community"
+	seedLoginName    = "bbash-participant"
+)
+
+// seedCampaignDays is how long the campaign added by the seed command is active
+const seedCampaignDays = 30
 
 func main() {
-	e := echo.New()
+	if err := runCommand(os.Args[1:], os.Stdout); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// runCommand runs the command named by the first argument with the flags that follow it. Without a command, as when
+// the arguments start with a flag, the server is started.
+func runCommand(args []string, out io.Writer) (err error) {
+	command := commandServe
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	configFile := flags.String("config", "", fmt.Sprintf("YAML or JSON config file, %s when not given", config.EnvConfigFile))
+	var port, rollback *int
+	var seedRequest seedRequest
+	switch command {
+	case commandServe:
+		port = flags.Int("port", defaultServicePort, "port to serve on")
+	case commandMigrate:
+		rollback = flags.Int("rollback", 0, "number of migrations to roll back, instead of applying the pending ones")
+	case commandSeed:
+		flags.StringVar(&seedRequest.CampaignName, "campaign", seedCampaignName, "campaign to add")
+		flags.StringVar(&seedRequest.Organization, "organization", seedOrganization, "GitHub organization to add to the campaign")
+		flags.StringVar(&seedRequest.LoginName, "login", seedLoginName, "GitHub login of the participant to add to the campaign")
+	case commandRoutes:
+	default:
+		return fmt.Errorf("unknown command: %s, expected one of: %s, %s, %s, %s",
+			command, commandServe, commandMigrate, commandSeed, commandRoutes)
+	}
+	if err = flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			err = nil
+		}
+		return
+	}
 
-	var err error
-	config := zap.NewProductionConfig()
-	config.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
-	logger, err = config.Build()
+	zapConfig := zap.NewProductionConfig()
+	zapConfig.Level = logLevel
+	logger, err = zapConfig.Build()
 	if err != nil {
-		e.Logger.Fatal("can not initialize zap logger: %+v", err)
+		return fmt.Errorf("can not initialize zap logger: %+v", err)
 	}
 	defer func() {
 		_ = logger.Sync()
 	}()
 
-	// NOTE: using middleware.Logger() makes lots of AWS ELB Healthcheck noise in server logs
-	//e.Use(middleware.Logger(), /* Log everything to stdout*/)
-	//e.Use(echozap.ZapLogger(logger))
-	e.Use(ZapLoggerFilterAwsElb(logger))
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		logger.Error("config load", zap.Error(err))
+		return fmt.Errorf("failed to load config. err: %+v", err)
+	}
+	if cfg.LogLevel != "" {
+		if err = setLevel(cfg.LogLevel); err != nil {
+			return fmt.Errorf("failed to set log level. LOG_LEVEL: %s, err: %+v", cfg.LogLevel, err)
+		}
+	}
+
+	secretResolvers = secretResolversFromConfig(cfg)
+	if command != commandRoutes {
+		if err = resolveSecrets(cfg); err != nil {
+			logger.Error("secrets resolve", zap.Error(err))
+			return fmt.Errorf("failed to resolve secrets. err: %+v", err)
+		}
+	}
+
+	switch command {
+	case commandMigrate:
+		return migrate(cfg, *rollback, out)
+	case commandSeed:
+		return seed(cfg, &seedRequest, out)
+	case commandRoutes:
+		printRoutes(cfg, out)
+		return
+	}
+	serve(cfg, fmt.Sprintf(":%d", *port))
+	return
+}
 
+// loadConfig reads the config file, or the one named by the environment when configFile is empty. The .env files are
+// loaded first, so they can set the environment.
+func loadConfig(configFile string) (cfg *config.Config, err error) {
+	err = godotenv.Load(".env")
+	if err != nil {
+		logger.Error("env load", zap.Error(err))
+	}
+	err = godotenv.Load(".env.dd")
+	if err != nil {
+		logger.Error(".env.dd load error", zap.Error(err))
+	}
+
+	if configFile == "" {
+		configFile = os.Getenv(config.EnvConfigFile)
+	}
+	return config.Load(configFile)
+}
+
+// openBBashDB connects to the database of the configured driver. The returned func closes the connections.
+func openBBashDB(cfg *config.Config) (bbashDB db.IBBashDB, closeDB func(), err error) {
+	switch cfg.DBDriver {
+	case config.DBDriverPostgres, "":
+		return openPostgresDB(cfg)
+	case config.DBDriverSqlite:
+		return openSqliteDB(cfg)
+	case config.DBDriverMemory:
+		return openMemoryDB()
+	}
+	err = fmt.Errorf("unknown database driver. DB_DRIVER: %s", cfg.DBDriver)
+	return
+}
+
+// openSqliteDB opens the SQLite database file, creating it when missing.
+func openSqliteDB(cfg *config.Config) (bbashDB db.IBBashDB, closeDB func(), err error) {
+	bbashDB, err = db.NewSqlite(cfg.SqlitePath, logger)
+	if err != nil {
+		logger.Error("sqlite open", zap.Error(err))
+		err = fmt.Errorf("failed to open sqlite database. path: %s, err: %+v", cfg.SqlitePath, err)
+		return
+	}
+	closeDB = func() {
+		if err := bbashDB.GetDb().Close(); err != nil {
+			logger.Error("db close", zap.Error(err))
+		}
+	}
+	logger.Info("db sqlite enabled", zap.String("path", cfg.SqlitePath))
+	return
+}
+
+// openMemoryDB makes an empty in memory database. There is nothing to close.
+func openMemoryDB() (bbashDB db.IBBashDB, closeDB func(), err error) {
+	bbashDB, closeDB = db.NewMemory(logger), func() {}
+	logger.Info("db memory enabled, nothing is kept once the server stops")
+	return
+}
+
+// openPostgresDB connects to the database, and the read replica when one is configured and reachable. The returned
+// func closes the connections.
+func openPostgresDB(cfg *config.Config) (bbashDB db.IBBashDB, closeDB func(), err error) {
+	pg, host, port, dbname, _, err := openDB(cfg)
+	if err != nil {
+		logger.Error("db open", zap.Error(err))
+		err = fmt.Errorf("failed to load database driver. host: %s, port: %d, dbname: %s, err: %+v", host, port, dbname, err)
+		return
+	}
+	closeDB = func() {
+		if err := pg.Close(); err != nil {
+			logger.Error("db close", zap.Error(err))
+		}
+	}
+
+	err = pg.Ping()
+	if err != nil {
+		logger.Error("db ping", zap.Error(err))
+		err = fmt.Errorf("failed to ping database. host: %s, port: %d, dbname: %s, err: %+v", host, port, dbname, err)
+		return
+	}
+
+	replica, err := openReadReplicaDB(cfg)
+	if err != nil {
+		logger.Error("read replica open", zap.Error(err))
+		err = nil
+	} else if replica != nil {
+		closePrimary := closeDB
+		closeDB = func() {
+			if err := replica.Close(); err != nil {
+				logger.Error("read replica close", zap.Error(err))
+			}
+			closePrimary()
+		}
+		err = replica.Ping()
+		if err != nil {
+			logger.Error("read replica ping, reading from primary", zap.Error(err))
+			replica = nil
+			err = nil
+		}
+	}
+	var pgDB *db.BBashDB
+	if replica != nil {
+		pgDB = db.NewWithReadReplica(pg, replica, logger)
+		logger.Info("db read replica enabled")
+	} else {
+		pgDB = db.New(pg, logger)
+	}
+	if statementTimeout, ok := statementTimeoutFromConfig(cfg); ok {
+		pgDB.SetStatementTimeout(statementTimeout)
+	}
+	bbashDB = pgDB
+	return
+}
+
+func serve(cfg *config.Config, address string) {
+	e := echo.New()
 	e.Debug = true
 
 	defer func() {
@@ -135,31 +555,21 @@ func main() {
 	logger.Info("build", zap.String("buildMsg", buildInfoMessage))
 	fmt.Println(buildInfoMessage)
 
-	err = godotenv.Load(".env")
-	if err != nil {
-		logger.Error("env load", zap.Error(err))
-	}
+	// NOTE: using middleware.Logger() makes lots of AWS ELB Healthcheck noise in server logs
+	//e.Use(middleware.Logger(), /* Log everything to stdout*/)
+	//e.Use(echozap.ZapLogger(logger))
+	e.Use(ZapLoggerFilter(logger, requestLogFilterFromConfig(cfg)))
 
-	pg, host, port, dbname, _, err := openDB()
-	if err != nil {
-		logger.Error("db open", zap.Error(err))
-		panic(fmt.Errorf("failed to load database driver. host: %s, port: %d, dbname: %s, err: %+v", host, port, dbname, err))
+	bbashDB, closeDB, err := openBBashDB(cfg)
+	if closeDB != nil {
+		defer closeDB()
 	}
-	defer func() {
-		if err := pg.Close(); err != nil {
-			logger.Error("db close", zap.Error(err))
-		}
-	}()
-
-	err = pg.Ping()
 	if err != nil {
-		logger.Error("db ping", zap.Error(err))
-		panic(fmt.Errorf("failed to ping database. host: %s, port: %d, dbname: %s, err: %+v", host, port, dbname, err))
+		panic(err)
 	}
+	postgresDB = bbashDB
 
-	postgresDB = db.New(pg, logger)
-
-	err = postgresDB.MigrateDB("file://internal/db/migrations/v2")
+	err = postgresDB.MigrateDB()
 	if err != nil {
 		logger.Error("db migrate", zap.Error(err))
 		panic(fmt.Errorf("failed to migrate database. err: %+v", err))
@@ -167,771 +577,6524 @@ func main() {
 		logger.Info("db migration complete")
 	}
 
-	setupRoutes(e, buildInfoMessage)
+	if cfg.SecretsFile != "" {
+		secretsStore, err = secrets.NewStore(cfg.SecretsFile)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	setupRoutes(e, cfg, buildInfoMessage)
+
+	dispatcher := webhook.New(logger, webhookTimeout, webhookAttempts, webhookBackoff)
+	dispatcher.SetSecretResolver(resolveWebhookSecret)
+	webhookDeliverer = dispatcher
+	mailer = mailerFromConfig(cfg)
+	errorReporter = errorReporterFromConfig(cfg)
+	poll.SetReporter(errorReporter)
+	poll.SetMaintenance(func() bool {
+		return maintenance.State().Enabled
+	})
+	scpFetcher := profile.New(profileTimeout, cfg.GithubToken)
+	profileFetcher = scpFetcher
+	participantAuthenticator = scpFetcher
+	if cfg.ProfileRefreshHours > 0 {
+		profileRefreshAge = time.Duration(cfg.ProfileRefreshHours) * time.Hour
+	}
+
+	thisInstance = newClusterInstance(cfg, time.Now())
+	failInstanceJobs(thisInstance.StartedOn)
 
+	// polling voodoo: the heartbeat starts the poll on the replica that takes the lease
 	scoreDB = postgresDB
-	if os.Getenv("DISABLE_DATADOG_POLL") == "" {
-		// polling voodoo
-		var errChan chan error
-		stopPoll, errChan, err = beginLogPolling()
-		defer func() {
-			close(stopPoll)
-			pollErr := <-errChan
-			logger.Error("defer poll error", zap.Error(pollErr))
-		}()
+	pollController = poll.NewController(func() (quit chan bool, errChan chan error, err error) {
+		return beginLogPolling(cfg)
+	})
+	pollWanted = !cfg.DisableDatadogPoll
+	defer stopPolling()
+
+	jobScheduler = scheduler.New(logger, postgresDB, thisInstance.InstanceId)
+	if err = scheduleJobs(cfg); err != nil {
+		panic(err)
+	}
+	stopScheduler := jobScheduler.Start()
+	defer close(stopScheduler)
+	if cfg.GrpcPort > 0 {
+		stopGrpcServer, grpcErr := beginGrpcServer(cfg)
+		if grpcErr != nil {
+			panic(grpcErr)
+		}
+		defer close(stopGrpcServer)
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- startServer(e, cfg, address)
+	}()
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err = <-serverErr:
+		logger.Fatal("application end", zap.Error(err))
+	case sig := <-stop:
+		logger.Info("shutdown", zap.String("signal", sig.String()))
 	}
 
-	logger.Fatal("application end", zap.Error(e.Start(defaultServicePort)))
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err = e.Shutdown(ctx); err != nil {
+		logger.Error("server shutdown error", zap.Error(err))
+	}
+	drainJobs(shutdownTimeout)
+	logger.Info("application end")
 }
 
-func beginLogPolling() (quit chan bool, errChan chan error, err error) {
-	err = godotenv.Load(".env.dd")
+// shutdownTimeout is how long a shutdown waits for the requests in flight, then for the jobs running in the
+// background, to finish.
+var shutdownTimeout = 30 * time.Second
+
+// migrate applies the pending migrations, or rolls back the most recent ones, then writes the schema version.
+func migrate(cfg *config.Config, rollback int, out io.Writer) (err error) {
+	bbashDB, closeDB, err := openBBashDB(cfg)
+	if closeDB != nil {
+		defer closeDB()
+	}
 	if err != nil {
-		logger.Error(".env.dd load error", zap.Error(err))
-		// clear error from load .env.dd file
-		err = nil
+		return
 	}
 
-	var pollDogIntervalSeconds int
-	pollDogIntervalSeconds, err = strconv.Atoi(os.Getenv("DD_CLIENT_POLL_SECONDS"))
+	if rollback > 0 {
+		err = bbashDB.RollbackMigrations(rollback)
+	} else {
+		err = bbashDB.MigrateDB()
+	}
 	if err != nil {
-		pollDogIntervalSeconds = 120
-		logger.Info("missing env var DD_CLIENT_POLL_SECONDS, using default",
-			zap.Int("pollDogIntervalSeconds", pollDogIntervalSeconds),
-			zap.Error(err),
-		)
-		// clear error from read env var
-		err = nil
+		return
 	}
 
-	pollDB = db.NewDBPoll(scoreDB.GetDb(), logger)
-	quit, errChan = poll.ChaseTail(pollDB, scoreDB, time.Duration(pollDogIntervalSeconds), processScoringMessage)
-	return
-}
-
-//goland:noinspection GoUnusedParameter
-func restartPolling(c echo.Context) (err error) {
-	if stopPoll != nil {
-		close(stopPoll)
+	status, err := bbashDB.SelectMigrationStatus(context.Background())
+	if err != nil {
+		return
 	}
-	stopPoll, _, err = beginLogPolling()
+	_, err = fmt.Fprintf(out, "schema version: %d, dirty: %t\n", status.Version, status.Dirty)
 	return
 }
 
-//goland:noinspection GoUnusedParameter
-func stopPolling(c echo.Context) (err error) {
-	close(stopPoll)
-	stopPoll = nil
-	return
+// seedRequest names what the seed command adds
+type seedRequest struct {
+	CampaignName string
+	Organization string
+	LoginName    string
 }
 
-func setPollDate(c echo.Context) (err error) {
-	pollFromRequest := types.Poll{}
-	err = json.NewDecoder(c.Request().Body).Decode(&pollFromRequest)
+func seed(cfg *config.Config, request *seedRequest, out io.Writer) (err error) {
+	bbashDB, closeDB, err := openBBashDB(cfg)
+	if closeDB != nil {
+		defer closeDB()
+	}
 	if err != nil {
 		return
 	}
+	return seedDB(context.Background(), bbashDB, request, time.Now(), out)
+}
 
-	pollFromDb := pollDB.NewPoll()
-	err = pollDB.SelectPoll(&pollFromDb)
-	if err != nil {
+// seedDB adds an active campaign, with an organization and a participant, for local development. Those that already
+// exist are left as they are, so it can be run again.
+func seedDB(ctx context.Context, bbashDB db.IBBashDB, request *seedRequest, now time.Time, out io.Writer) (err error) {
+	seeded := func(entity, name string, err error) error {
+		var duplicateErr *db.DuplicateError
+		if errors.As(err, &duplicateErr) {
+			_, err = fmt.Fprintf(out, "%s exists: %s\n", entity, name)
+		} else if err == nil {
+			_, err = fmt.Fprintf(out, "%s added: %s\n", entity, name)
+		}
+		return err
+	}
+
+	_, err = bbashDB.InsertCampaign(ctx, &types.CampaignStruct{
+		Name:    request.CampaignName,
+		StartOn: now,
+		EndOn:   now.AddDate(0, 0, seedCampaignDays),
+	})
+	if err = seeded("campaign", request.CampaignName, err); err != nil {
 		return
 	}
 
-	pollFromDb.LastPolled = pollFromRequest.LastPolled
-	err = pollDB.UpdatePoll(&pollFromDb)
-	if err != nil {
+	_, err = bbashDB.InsertOrganization(ctx, &types.OrganizationStruct{
+		SCPName:      seedScpName,
+		Organization: request.Organization,
+		CampaignName: request.CampaignName,
+	})
+	if err = seeded("organization", request.Organization, err); err != nil {
 		return
 	}
 
-	logger.Info("set poll", zap.Any("poll", pollFromDb))
-	return
+	err = bbashDB.InsertParticipant(ctx, &types.ParticipantStruct{
+		CampaignName: request.CampaignName,
+		ScpName:      seedScpName,
+		LoginName:    request.LoginName,
+	})
+	return seeded("participant", request.LoginName, err)
 }
 
-func setupRoutes(e *echo.Echo, buildInfoMessage string) (customRouteCount int) {
-	e.GET("/", func(c echo.Context) error {
-		return c.String(http.StatusOK, fmt.Sprintf("I am ALIVE. %s", buildInfoMessage))
+// printRoutes writes the routes we created ourselves, sorted by path, without starting the server.
+func printRoutes(cfg *config.Config, out io.Writer) {
+	e := echo.New()
+	setupRoutes(e, cfg, "")
+
+	for _, route := range customRoutes(e) {
+		_, _ = fmt.Fprintf(out, "%s %s as %s\n", route.Method, route.Path, route.Name)
+	}
+}
+
+// customRoutes are the routes we created ourselves, sorted by path, then by method
+func customRoutes(e *echo.Echo) (routes []*echo.Route) {
+	for _, route := range e.Routes() {
+		if !strings.HasPrefix(route.Name, echoDefaultRouteNamePrefix) {
+			routes = append(routes, route)
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
 	})
+	return
+}
 
-	// admin endpoint group
-	adminGroup := e.Group(pathAdmin, middleware.BasicAuth(infoBasicValidator))
+// startServer serves HTTPS with the configured certificate, or with certificates from Let's Encrypt for the configured
+// autocert hosts. Without either it serves plain HTTP, for a proxy in front to terminate HTTPS. A certificate takes
+// precedence over autocert.
+func startServer(e *echo.Echo, cfg *config.Config, address string) error {
+	if cfg.TlsCertFile != "" || cfg.TlsKeyFile != "" {
+		logger.Info("tls", zap.String("certFile", cfg.TlsCertFile), zap.String("keyFile", cfg.TlsKeyFile))
+		return e.StartTLS(address, cfg.TlsCertFile, cfg.TlsKeyFile)
+	}
 
-	// Source Control Provider endpoints
-	scpGroup := adminGroup.Group(SourceControlProvider)
-	scpGroup.GET(List, getSourceControlProviders).Name = "scp-list"
+	if len(cfg.TlsAutocertHosts) > 0 {
+		configureAutocert(&e.AutoTLSManager, cfg)
+		logger.Info("tls autocert", zap.Strings("hosts", cfg.TlsAutocertHosts))
+		return e.StartAutoTLS(address)
+	}
 
-	// Organization related endpoints
-	organizationGroup := adminGroup.Group(Organization)
+	return e.Start(address)
+}
 
-	organizationGroup.GET(List, getOrganizations).Name = "organization-list"
-	organizationGroup.PUT(Add, addOrganization).Name = "organization-add"
-	organizationGroup.DELETE(
-		fmt.Sprintf("%s/:%s/:%s", Delete, ParamScpName, ParamOrganizationName),
-		deleteOrganization).Name = "organization-delete"
+// configureAutocert limits the Let's Encrypt certificates to those of the hosts, so a request for another host name
+// can not run up the Let's Encrypt rate limits. The certificates are cached on disk, so a restart does not request them
+// again.
+func configureAutocert(manager *autocert.Manager, cfg *config.Config) {
+	cacheDir := cfg.TlsAutocertCacheDir
+	if cacheDir == "" {
+		cacheDir = defaultAutocertCacheDir
+	}
+	manager.HostPolicy = autocert.HostWhitelist(cfg.TlsAutocertHosts...)
+	manager.Cache = autocert.DirCache(cacheDir)
+	manager.Email = cfg.TlsAutocertEmail
+}
 
-	// Participant related endpoints and group
+func beginLogPolling(cfg *config.Config) (quit chan bool, errChan chan error, err error) {
+	pollDogIntervalSeconds := cfg.DDClientPollSeconds
+	if pollDogIntervalSeconds < 1 {
+		pollDogIntervalSeconds = config.Defaults().DDClientPollSeconds
+		logger.Info("invalid DD_CLIENT_POLL_SECONDS, using default",
+			zap.Int("pollDogIntervalSeconds", pollDogIntervalSeconds),
+		)
+	}
+	if cfg.DBDriver != config.DBDriverPostgres && cfg.DBDriver != "" {
+		err = fmt.Errorf("datadog poll needs postgres. DB_DRIVER: %s", cfg.DBDriver)
+		return
+	}
+	poll.Configure(cfg)
+	if secretsStore != nil {
+		// the keys may have been rotated since the config was loaded
+		current := secretsStore.Secrets()
+		poll.SetKeys(current.DDClientApiKey, current.DDClientAppKey)
+	}
 
-	publicParticipantGroup := e.Group(Participant)
-	publicParticipantGroup.GET(
-		fmt.Sprintf("%s/:%s", List, ParamCampaignName),
-		getParticipantsList).Name = "participant-list"
+	pollDB = db.NewDBPoll(scoreDB.GetDb(), logger)
 
-	participantGroup := adminGroup.Group(Participant)
-	participantGroup.GET(
-		fmt.Sprintf("%s/:%s/:%s/:%s", Detail, ParamCampaignName, ParamScpName, ParamLoginName),
-		getParticipantDetail).Name = "participant-detail"
+	// reprocess anything accepted by a prior poll that did not finish processing
+	recovered, recoverErr := poll.RecoverJournal(pollDB, scoreDB, processScoringMessage)
+	if recoverErr != nil {
+		logger.Error("journal recovery error", zap.Int("recovered", recovered), zap.Error(recoverErr))
+	}
 
-	participantGroup.POST(Update, updateParticipant).Name = "participant-update"
-	participantGroup.PUT(Add, logAddParticipant).Name = "participant-add"
-	participantGroup.DELETE(
-		fmt.Sprintf("%s/:%s/:%s/:%s", Delete, ParamCampaignName, ParamScpName, ParamLoginName),
-		deleteParticipant,
-	)
+	quit, errChan = poll.ChaseTail(pollDB, scoreDB, time.Duration(pollDogIntervalSeconds), processScoringMessage)
+	return
+}
 
-	// Team related endpoints and group
+// thisInstance describes this replica, as reported to the other replicas via the cluster_instance table.
+var thisInstance types.ClusterInstance
 
-	teamGroup := adminGroup.Group(Team)
+// jobScheduler runs the periodic jobs of this replica.
+var jobScheduler *scheduler.Scheduler
 
-	teamGroup.PUT(Add, addTeam)
-	teamGroup.PUT(fmt.Sprintf("%s/:%s/:%s/:%s/:%s", Person, ParamCampaignName, ParamScpName, ParamLoginName, ParamTeamName), addPersonToTeam)
+// heartbeatInterval is how often this replica reports in. A replica is considered dead once it misses a few beats.
+var heartbeatInterval = 30 * time.Second
 
-	// Bug related endpoints and group
+const missedHeartbeatsAllowed = 3
 
-	bugGroup := adminGroup.Group(Bug)
+const defaultInstanceRole = "web"
 
-	bugGroup.PUT(Add, addBug)
-	bugGroup.POST(fmt.Sprintf("%s/:%s/:%s/:%s", Update, ParamCampaignName, ParamBugCategory, ParamPointValue), updateBug)
-	bugGroup.GET(List, getBugs)
-	bugGroup.PUT(List, putBugs)
+func newClusterInstance(cfg *config.Config, now time.Time) types.ClusterInstance {
+	instanceId := cfg.InstanceId
+	if instanceId == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "unknown"
+		}
+		instanceId = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
 
-	// Campaign related endpoints and group
+	role := cfg.InstanceRole
+	if role == "" {
+		role = defaultInstanceRole
+	}
 
-	publicCampaignGroup := e.Group(Campaign)
-	publicCampaignGroup.GET(active, getActiveCampaigns)
+	return types.ClusterInstance{
+		InstanceId: instanceId,
+		Version:    buildversion.BuildVersion,
+		Role:       role,
+		StartedOn:  now,
+	}
+}
 
-	campaignGroup := adminGroup.Group(Campaign)
-	campaignGroup.GET(List, getCampaigns)
-	campaignGroup.PUT(fmt.Sprintf("%s/:%s", Add, ParamCampaignName), addCampaign)
-	campaignGroup.PUT(fmt.Sprintf("%s/:%s", Update, ParamCampaignName), updateCampaign)
+// sendHeartbeat renews the lease on the datadog poll, if this replica polls, and reports in as the poll leader if it
+// holds the lease.
+func sendHeartbeat(now time.Time) (err error) {
+	leader, leaseErr := leadPoll(now)
+	thisInstance.PollLeader = leader
+	thisInstance.LastHeartbeat = now
+	err = postgresDB.UpsertClusterInstance(context.Background(), &thisInstance)
+	if err != nil {
+		logger.Error("heartbeat error", zap.Any("instance", thisInstance), zap.Error(err))
+		return
+	}
+	return leaseErr
+}
 
-	// Poll related endpoints and group
+// pollLeaseMu guards pollWanted, and keeps the lease and the poll in step
+var pollLeaseMu sync.Mutex
 
-	pollGroup := adminGroup.Group(Poll)
-	pollGroup.PUT("/last", setPollDate)
-	pollGroup.DELETE("/stop", stopPolling)
-	pollGroup.GET("/restart", restartPolling)
+// pollWanted is whether this replica takes part in the datadog poll. It is off with DISABLE_DATADOG_POLL, and while
+// the poll is paused by an admin.
+var pollWanted bool
 
-	e.Static("/", buildLocation)
+// pollLeaseDuration is how long the lease on the datadog poll lasts without being renewed, so another replica takes the
+// poll over once the leader has missed a few heartbeats.
+func pollLeaseDuration() time.Duration {
+	return heartbeatInterval * missedHeartbeatsAllowed
+}
 
-	routes := e.Routes()
+// leadPoll takes or renews the lease on the datadog poll while this replica wants to poll, and runs the poll only while
+// it holds the lease, so one replica polls at a time. The poll stops when the lease can not be renewed, as another
+// replica may take it over.
+func leadPoll(now time.Time) (leader bool, err error) {
+	pollLeaseMu.Lock()
+	defer pollLeaseMu.Unlock()
+	return leadPollLocked(now)
+}
 
-	for _, v := range routes {
-		routeInfo := fmt.Sprintf("%s %s as %s", v.Method, v.Path, v.Name)
-		// only print the routes we created ourselves, ignoring the default ones added automatically by echo
-		if !strings.HasPrefix(v.Name, echoDefaultRouteNamePrefix) {
-			customRouteCount++
-			logger.Info("route", zap.String("info", routeInfo))
+func leadPollLocked(now time.Time) (leader bool, err error) {
+	if pollWanted {
+		leader, err = postgresDB.AcquirePollLease(context.Background(), thisInstance.InstanceId, now, now.Add(pollLeaseDuration()))
+		if err != nil {
+			logger.Error("poll lease error", zap.String("instanceId", thisInstance.InstanceId), zap.Error(err))
+			leader = false
 		}
 	}
-	return
-}
-
-const echoDefaultRouteNamePrefix = "github.com/labstack/echo/v4."
 
-//goland:noinspection GoUnusedParameter
-func infoBasicValidator(username, password string, c echo.Context) (isValidLogin bool, err error) {
-	// Be careful to use constant time comparison to prevent timing attacks
-	if subtle.ConstantTimeCompare([]byte(username), []byte(os.Getenv(envAdminUsername))) == 1 &&
-		subtle.ConstantTimeCompare([]byte(password), []byte(os.Getenv(envAdminPassword))) == 1 {
-		isValidLogin = true
-	} else {
-		logger.Info("failed info endpoint login",
-			zap.String("username", username),
-			zap.String("password", password),
-		)
+	running := pollController.State().Running
+	if leader && !running {
+		if _, err = pollController.Resume(); err != nil {
+			logger.Error("datadog poll not started", zap.Error(err))
+			leader = false
+			releasePollLease()
+		}
+	} else if !leader && running {
+		pollController.Pause()
 	}
 	return
 }
 
-// ZapLoggerFilterAwsElb is a middleware and zap to provide an "access log" like logging for each request.
-// Adapted from ZapLogger, until I find a better way to filter out AWS ELB Healthcheck messages.
-func ZapLoggerFilterAwsElb(log *zap.Logger) echo.MiddlewareFunc {
-	return func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
-			start := time.Now()
-
-			err := next(c)
-			if err != nil {
-				c.Error(err)
-			}
+// releasePollLease gives up the lease on the datadog poll, so another replica takes the poll over at its next heartbeat.
+func releasePollLease() {
+	if err := postgresDB.ReleasePollLease(context.Background(), thisInstance.InstanceId); err != nil {
+		logger.Error("poll lease not released", zap.String("instanceId", thisInstance.InstanceId), zap.Error(err))
+	}
+}
 
-			req := c.Request()
-			res := c.Response()
+// stopPolling stops the datadog poll on this replica until it is resumed, and gives up its lease.
+func stopPolling() (state types.PollState) {
+	pollLeaseMu.Lock()
+	defer pollLeaseMu.Unlock()
 
-			fields := []zapcore.Field{
-				zap.String("remote_ip", c.RealIP()),
-				zap.String("latency", time.Since(start).String()),
-				zap.String("host", req.Host),
-				zap.String("request", fmt.Sprintf("%s %s", req.Method, req.RequestURI)),
-				zap.Int("status", res.Status),
-				zap.Int64("size", res.Size),
-				zap.String("user_agent", req.UserAgent()),
-			}
+	pollWanted = false
+	state = pollController.Pause()
+	releasePollLease()
+	return
+}
 
-			userAgent := req.UserAgent()
-			if strings.Contains(userAgent, "ELB-HealthChecker") {
-				//fmt.Printf("userAgent: %s\n", userAgent)
-				// skip logging of this AWS ELB healthcheck
-				return nil
-			}
+// scheduledJob is a periodic job of this replica, registered with the jobScheduler
+type scheduledJob struct {
+	name  string
+	every time.Duration
+	run   scheduler.Func
+}
 
-			logIncludeHostname := os.Getenv(envLogFilterIncludeHostname)
-			if logIncludeHostname != "" && req.Host != "" {
-				// only log legit stuff from expected host
-				if logIncludeHostname != req.Host {
-					return nil
-				}
-			}
+// scheduleJobs registers the periodic jobs of this replica, each on the interval configured for it. The datadog poll
+// is not one of them, as it is paused and resumed on its own.
+func scheduleJobs(cfg *config.Config) (err error) {
+	if cfg.HeartbeatSeconds > 0 {
+		heartbeatInterval = time.Duration(cfg.HeartbeatSeconds) * time.Second
+	}
+	if cfg.SecretsReloadSeconds > 0 {
+		secretsReloadInterval = time.Duration(cfg.SecretsReloadSeconds) * time.Second
+	}
+	if cfg.LeaderboardRefreshSeconds > 0 {
+		leaderboardRefreshInterval = time.Duration(cfg.LeaderboardRefreshSeconds) * time.Second
+	}
+	if cfg.CampaignLifecycleSeconds > 0 {
+		campaignLifecycleInterval = time.Duration(cfg.CampaignLifecycleSeconds) * time.Second
+	}
+	if cfg.AnomalySeconds > 0 {
+		anomalyInterval = time.Duration(cfg.AnomalySeconds) * time.Second
+	}
 
-			id := req.Header.Get(echo.HeaderXRequestID)
-			if id == "" {
-				id = res.Header().Get(echo.HeaderXRequestID)
-				fields = append(fields, zap.String("request_id", id))
+	jobs := []scheduledJob{
+		{"heartbeat", heartbeatInterval, func(ctx context.Context, now time.Time) error {
+			if err := sendHeartbeat(now); err != nil {
+				return err
 			}
+			return failOrphanedJobs(now)
+		}},
+		{"profile-refresh", heartbeatInterval, func(ctx context.Context, now time.Time) error {
+			return refreshStaleProfiles(now)
+		}},
+		{"leaderboard-refresh", leaderboardRefreshInterval, func(ctx context.Context, now time.Time) error {
+			return refreshLeaderboard()
+		}},
+		{"campaign-lifecycle", campaignLifecycleInterval, func(ctx context.Context, now time.Time) error {
+			return publishCampaignTransitions(now)
+		}},
+		{"anomaly-detection", anomalyInterval, func(ctx context.Context, now time.Time) error {
+			return flagAnomalies(now)
+		}},
+	}
+	if secretsStore != nil {
+		jobs = append(jobs, scheduledJob{"secrets-reload", secretsReloadInterval, func(ctx context.Context, now time.Time) error {
+			return reloadSecrets()
+		}})
+	}
+	if cfg.ArchiveAfterDays > 0 {
+		age := time.Duration(cfg.ArchiveAfterDays) * 24 * time.Hour
+		jobs = append(jobs, scheduledJob{"archival", archiveInterval, func(ctx context.Context, now time.Time) error {
+			return archiveCampaigns(now, age)
+		}})
+	}
 
-			n := res.Status
-			switch {
-			case n >= 500:
-				log.With(zap.Error(err)).Error("Server error", fields...)
-			case n >= 400:
-				log.With(zap.Error(err)).Warn("Client error", fields...)
-			case n >= 300:
-				log.Info("Redirection", fields...)
-			default:
-				log.Info("Success", fields...)
-			}
+	for _, job := range jobs {
+		run := job.run
+		if !maintenanceExemptJobs[job.name] {
+			run = unlessInMaintenance(job.name, run)
+		}
+		if err = jobScheduler.Register(job.name, job.every, run); err != nil {
+			return
+		}
+	}
+	return
+}
 
+// unlessInMaintenance skips the runs of the job while in maintenance. A skipped run counts as a run that succeeded.
+func unlessInMaintenance(name string, run scheduler.Func) scheduler.Func {
+	return func(ctx context.Context, now time.Time) error {
+		if maintenance.State().Enabled {
+			logger.Debug("scheduled job skipped in maintenance", zap.String("job", name))
 			return nil
 		}
+		return run(ctx, now)
 	}
 }
 
-func openDB() (db *sql.DB, host string, port int, dbname, sslMode string, err error) {
-	host = os.Getenv(envPGHost)
-	port, _ = strconv.Atoi(os.Getenv(envPGPort))
-	user := os.Getenv(envPGUsername)
-	password := os.Getenv(envPGPassword)
-	dbname = os.Getenv(envPGDBName)
-	sslMode = os.Getenv(envSSLMode)
+// secretsReloadInterval is how often the secrets file is read again, so rotated admins and datadog keys are used
+var secretsReloadInterval = 30 * time.Second
 
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s "+
-		"password=%s dbname=%s sslmode=%s",
-		host, port, user, password, dbname, sslMode)
-	db, err = sql.Open("postgres", psqlInfo)
+// reloadSecrets reads the secrets file again, rotating the datadog keys of the poll when the file changed. An invalid
+// file is logged, and the previous secrets kept.
+func reloadSecrets() (err error) {
+	changed, err := secretsStore.Reload()
+	if err != nil {
+		logger.Error("secrets reload error", zap.Error(err))
+		return
+	}
+	if !changed {
+		return
+	}
+	current := secretsStore.Secrets()
+	logger.Info("secrets reloaded", zap.Int("admins", len(current.Admins)))
+	poll.SetKeys(current.DDClientApiKey, current.DDClientAppKey)
 	return
 }
 
-func getSourceControlProviders(c echo.Context) (err error) {
-	var scps []types.SourceControlProviderStruct
-	scps, err = postgresDB.GetSourceControlProviders()
+// campaignLifecycleInterval is how often the campaigns are moved to the status they have at the time, so a campaign
+// starts or ends up to this late.
+var campaignLifecycleInterval = 15 * time.Second
+
+// anomalyInterval is how often the recent scoring events are checked for suspicious patterns.
+var anomalyInterval = 60 * time.Second
+
+// anomalyLookback is how far back each check reads the scoring events, so a pattern spread over several checks is
+// still seen whole.
+const anomalyLookback = time.Hour
+
+// a participant scoring at least anomalyBurstEvents events within anomalyBurstWindow has them flagged
+const anomalyBurstEvents = 20
+const anomalyBurstWindow = 10 * time.Minute
+
+// a participant scoring the same fixed bugs in at least anomalyIdenticalRepos repositories has them flagged
+const anomalyIdenticalRepos = 3
+
+// flagAnomalies adds the scoring events of suspicious patterns to the review queue. Every replica checks, as an event
+// already flagged for a reason is not flagged again.
+func flagAnomalies(now time.Time) (err error) {
+	scored, err := postgresDB.SelectScoredEvents(context.Background(), now.Add(-anomalyLookback))
+	if err != nil {
+		logger.Error("anomaly events error", zap.Error(err))
+		return
+	}
+	flagged, err := postgresDB.InsertScoringFlags(context.Background(), detectAnomalies(scored, now))
 	if err != nil {
+		logger.Error("anomaly flags error", zap.Error(err))
 		return
 	}
+	if flagged > 0 {
+		logger.Info("scoring events flagged", zap.Int64("flagged", flagged))
+	}
+	return
+}
+
+// detectAnomalies flags the events of each participant scoring a burst of events within minutes, or the same fixed
+// bugs in several repositories, as they are more likely a script farming points than a person fixing bugs. The scored
+// events are oldest first.
+func detectAnomalies(scored []db.ScoredEvent, now time.Time) (flags []types.ScoringFlag) {
+	var participants []string
+	byParticipant := map[string][]db.ScoredEvent{}
+	for _, event := range scored {
+		key := fmt.Sprintf("%s/%s/%s", event.CampaignName, event.ScpName, event.UserName)
+		if _, ok := byParticipant[key]; !ok {
+			participants = append(participants, key)
+		}
+		byParticipant[key] = append(byParticipant[key], event)
+	}
+
+	flag := func(event db.ScoredEvent, reason string) {
+		flags = append(flags, types.ScoringFlag{Reason: reason, FlaggedOn: now, Event: event.ScoringEventStruct})
+	}
+	for _, key := range participants {
+		participantEvents := byParticipant[key]
+
+		burst := make([]bool, len(participantEvents))
+		start := 0
+		for end := range participantEvents {
+			for participantEvents[end].ScoredOn.Sub(participantEvents[start].ScoredOn) > anomalyBurstWindow {
+				start++
+			}
+			if end-start+1 >= anomalyBurstEvents {
+				for i := start; i <= end; i++ {
+					burst[i] = true
+				}
+			}
+		}
+		for i, event := range participantEvents {
+			if burst[i] {
+				flag(event, types.ScoringFlagBurst)
+			}
+		}
+
+		var fixes []string
+		byFixes := map[string][]db.ScoredEvent{}
+		for _, event := range participantEvents {
+			signature := fixedBugsSignature(event.Breakdown)
+			if signature == "" {
+				continue
+			}
+			if _, ok := byFixes[signature]; !ok {
+				fixes = append(fixes, signature)
+			}
+			byFixes[signature] = append(byFixes[signature], event)
+		}
+		for _, signature := range fixes {
+			repos := map[string]bool{}
+			for _, event := range byFixes[signature] {
+				repos[strings.ToLower(event.RepoOwner+"/"+event.RepoName)] = true
+			}
+			if len(repos) < anomalyIdenticalRepos {
+				continue
+			}
+			for _, event := range byFixes[signature] {
+				flag(event, types.ScoringFlagIdentical)
+			}
+		}
+	}
+	return
+}
 
-	return c.JSON(http.StatusOK, scps)
+// fixedBugsSignature is the categories of the fixed bugs of the breakdown, and how many of each, in a form the same
+// for the same fixes. It is empty when no fixed bug has a category, which tells too little to compare.
+func fixedBugsSignature(breakdown *types.ScoreBreakdown) string {
+	if breakdown == nil || len(breakdown.Categories) == 0 {
+		return ""
+	}
+	counts := make([]string, len(breakdown.Categories))
+	for i, category := range breakdown.Categories {
+		counts[i] = fmt.Sprintf("%s=%g", category.Category, category.Count)
+	}
+	sort.Strings(counts)
+	return strings.Join(counts, ",")
 }
 
-func addOrganization(c echo.Context) (err error) {
-	organization := types.OrganizationStruct{}
+// archiveInterval is how often the campaigns are checked for scoring events old enough to archive.
+var archiveInterval = time.Hour
 
-	err = json.NewDecoder(c.Request().Body).Decode(&organization)
+// archiveCampaigns moves the scoring events of each campaign ended longer ago than the age into its archive. A
+// campaign another replica archived first is skipped.
+func archiveCampaigns(now time.Time, age time.Duration) (err error) {
+	campaignNames, err := postgresDB.SelectCampaignsToArchive(context.Background(), now.Add(-age))
 	if err != nil {
+		logger.Error("archive campaigns error", zap.Error(err))
 		return
 	}
+	for _, campaignName := range campaignNames {
+		archive, archiveErr := postgresDB.ArchiveScoringEvents(context.Background(), campaignName, now)
+		if archiveErr == sql.ErrNoRows {
+			continue
+		} else if archiveErr != nil {
+			logger.Error("archive scoring events error", zap.String("campaignName", campaignName), zap.Error(archiveErr))
+			// the other campaigns are still archived, while the run fails
+			err = archiveErr
+			continue
+		}
+		logger.Info("scoring events archived", zap.String("campaignName", campaignName), zap.Int64("events", archive.EventCount))
+	}
+	return
+}
 
-	var guid string
-	guid, err = postgresDB.InsertOrganization(&organization)
+// leaderboardRefreshInterval is how often the leaderboard ranks are checked, and refreshed if a score changed.
+var leaderboardRefreshInterval = 15 * time.Second
+
+// refreshLeaderboard recomputes the leaderboard ranks if a participant or team changed since they were last computed.
+func refreshLeaderboard() (err error) {
+	stale, err := postgresDB.SelectLeaderboardStale(context.Background())
 	if err != nil {
-		logger.Error("error inserting organization", zap.Any("organization", organization), zap.Error(err))
+		logger.Error("leaderboard stale error", zap.Error(err))
 		return
 	}
-
-	logger.Debug("added organization", zap.Any("organization", organization))
-	return c.String(http.StatusCreated, guid)
+	if !stale {
+		return
+	}
+	err = postgresDB.RefreshLeaderboard(context.Background())
+	if err != nil {
+		logger.Error("leaderboard refresh error", zap.Error(err))
+	}
+	return
 }
 
-func getOrganizations(c echo.Context) (err error) {
-	var orgs []types.OrganizationStruct
-	orgs, err = postgresDB.GetOrganizations()
-	if err != nil {
+// refreshProfile reads the display name and avatar of the participant from the source control provider. A failure is
+// only logged, and the profile is still marked as refreshed so it is not retried until it is stale.
+func refreshProfile(ctx context.Context, participant *types.ParticipantStruct, now time.Time) {
+	if profileFetcher == nil {
 		return
 	}
 
-	return c.JSON(http.StatusOK, orgs)
+	var displayName, avatarUrl string
+	fetched, err := profileFetcher.Fetch(participant.ScpName, participant.LoginName)
+	if err == nil {
+		displayName, avatarUrl = fetched.DisplayName, fetched.AvatarUrl
+	} else if err != profile.ErrUnsupportedScp {
+		logger.Info("profile error", zap.String("scpName", participant.ScpName), zap.String("loginName", participant.LoginName), zap.Error(err))
+	}
+
+	err = postgresDB.UpdateParticipantProfile(ctx, participant.ID, displayName, avatarUrl, now)
+	if err != nil {
+		logger.Error("profile update error", zap.Any("participant", participant), zap.Error(err))
+		return
+	}
+	if participant.DisplayName == "" {
+		participant.DisplayName = displayName
+	}
+	if avatarUrl != "" {
+		participant.AvatarUrl = avatarUrl
+	}
 }
 
-func deleteOrganization(c echo.Context) (err error) {
-	scpName := c.Param(ParamScpName)
-	orgName := c.Param(ParamOrganizationName)
+// refreshStaleProfiles refreshes a batch of the participant profiles older than profileRefreshAge.
+func refreshStaleProfiles(now time.Time) (err error) {
+	if profileFetcher == nil {
+		return
+	}
 
-	var rowsAffected int64
-	rowsAffected, err = postgresDB.DeleteOrganization(scpName, orgName)
+	participants, err := postgresDB.SelectParticipantsToRefresh(context.Background(), now.Add(-profileRefreshAge), profileRefreshBatch)
 	if err != nil {
+		logger.Error("stale profiles error", zap.Error(err))
 		return
 	}
-	logger.Info("delete organization",
-		zap.String("scpName", scpName),
-		zap.String("orgName", orgName),
-		zap.Int64("rowsAffected", rowsAffected))
-	if rowsAffected > 0 {
-		return c.NoContent(http.StatusNoContent)
+	for i := range participants {
+		refreshProfile(context.Background(), &participants[i], now)
 	}
-	return c.JSON(http.StatusNotFound, fmt.Sprintf("no organization: scpName: %s, name: %s", scpName, orgName))
+	return
 }
 
-func validScore(msg *types.ScoringMessage, now time.Time) (participantsToScore []types.ParticipantStruct, err error) {
-	// check if repo is in participating set
-	isValidOrg, err := postgresDB.ValidOrganization(msg)
+// publishCampaignTransitions sends the lifecycle events of each campaign that started or ended since the last check,
+// by any replica. The end of campaign emails and awards are only sent the first time a campaign ends.
+func publishCampaignTransitions(now time.Time) (err error) {
+	transitions, err := postgresDB.ClaimCampaignTransitions(context.Background(), now)
 	if err != nil {
-		logger.Debug("skip score-error reading organization", zap.Any("msg", msg), zap.Error(err))
+		logger.Error("campaign transitions error", zap.Error(err))
 		return
 	}
-	if !isValidOrg {
-		logger.Debug("skip score-missing organization",
-			zap.String("RepoOwner", msg.RepoOwner), zap.String("TriggerUser", msg.TriggerUser))
+	for _, transition := range transitions {
+		campaign := transition.Campaign
+		logger.Info("campaign transition", zap.String("campaignName", campaign.Name),
+			zap.String("from", transition.FromStatus), zap.String("to", campaign.Status))
+
+		switch campaign.Status {
+		case types.CampaignStatusActive:
+			publishWebhookEvent(context.Background(), types.WebhookEventCampaignStarted, campaign)
+			notifySlackLifecycle(campaign.Name, fmt.Sprintf("%s has started, happy bug hunting", campaign.Name))
+		case types.CampaignStatusEnded:
+			publishWebhookEvent(context.Background(), types.WebhookEventCampaignEnded, campaign)
+			notifySlackLifecycle(campaign.Name, fmt.Sprintf("%s has ended", campaign.Name))
+			if transition.FirstEnd {
+				if _, err = finalizeLeaderboard(context.Background(), campaign.Name, now); err != nil {
+					logger.Error("leaderboard finalize error", zap.String("campaignName", campaign.Name), zap.Error(err))
+				}
+				emailFinalRanks(context.Background(), campaign.Name)
+				awardTopFinishers(context.Background(), campaign.Name, now)
+			}
+		}
+	}
+	return
+}
+
+// notifySlackLifecycle posts the start or end of the campaign to its Slack channel, if it has one. A failure is only
+// logged.
+func notifySlackLifecycle(campaignName, message string) {
+	config, err := postgresDB.SelectSlackNotification(context.Background(), campaignName)
+	if err == sql.ErrNoRows {
 		return
+	} else if err != nil {
+		logger.Error("slack notification config error", zap.String("campaignName", campaignName), zap.Error(err))
+		return
+	}
+	if err = slackNotifier.Post(config.WebhookUrl, message); err != nil {
+		logger.Error("slack notification error", zap.String("campaignName", campaignName), zap.String("message", message), zap.Error(err))
 	}
+}
 
-	// Check if participant is registered for an active campaign
-	participantsToScore, err = postgresDB.SelectParticipantsToScore(msg, now)
+func getClusterStatus(c echo.Context) (err error) {
+	var instances []types.ClusterInstance
+	instances, err = postgresDB.SelectClusterInstances(c.Request().Context())
 	if err != nil {
-		logger.Error("skip score-error reading participant", zap.Any("msg", msg), zap.Error(err))
 		return
 	}
-	if len(participantsToScore) == 0 {
-		logger.Debug("skip score-missing participant", zap.Any("msg", msg), zap.Error(err))
+
+	deadline := time.Now().Add(-heartbeatInterval * missedHeartbeatsAllowed)
+	for i := range instances {
+		instances[i].Alive = instances[i].LastHeartbeat.After(deadline)
+	}
+
+	return c.JSON(http.StatusOK, instances)
+}
+
+// getScheduledJobs lists the periodic jobs of this replica, with when each runs next and its latest run by any
+// replica.
+func getScheduledJobs(c echo.Context) (err error) {
+	jobs, err := jobScheduler.Jobs(c.Request().Context())
+	if err != nil {
 		return
 	}
-	return
+	return c.JSON(http.StatusOK, jobs)
 }
 
-func scorePoints(msg *types.ScoringMessage, campaignName string) (points float64) {
-	points = 0
-	scored := float64(0)
+// kinds of the jobs run in the background
+const (
+	jobKindScoringEventImport  = "score-event-import"
+	jobKindScoringEventArchive = "score-event-archive"
+	jobKindScoringEventRestore = "score-event-restore"
+)
+
+// asyncJobs counts the jobs running in the background, so a shutdown, or a test, can wait for them to finish
+var asyncJobs sync.WaitGroup
+
+// jobsCtx is the context of the jobs running in the background, cancelled by cancelJobs when a shutdown gives up
+// waiting for them
+var jobsCtx, cancelJobs = context.WithCancel(context.Background())
+
+// errors of the jobs this replica did not finish
+const (
+	jobOrphanedError = "the replica running the job stopped before it finished"
+	jobShutdownError = "the job was cancelled by a shutdown of its replica"
+)
+
+// jobRun is the work of a job, reporting how far it got through progress. What it returns is saved as the result of
+// the job.
+type jobRun func(ctx context.Context, progress func(done, total int64)) (result interface{}, err error)
+
+// startJob saves a running job of the kind, then runs it in the background, answering 202 with the job to follow at
+// GET /admin/jobs/:jobId. The run is not tied to the request, so it goes on when the caller disconnects. The checks
+// the request can fail are made before the job starts, so a job only fails on an unexpected error.
+func startJob(c echo.Context, kind string, total int64, run jobRun) (err error) {
+	job := &types.Job{Kind: kind, Status: types.JobStatusRunning, InstanceId: thisInstance.InstanceId, Total: total,
+		CreatedOn: time.Now()}
+	if err = postgresDB.InsertJob(c.Request().Context(), job); err != nil {
+		return
+	}
+	accepted := *job
+
+	asyncJobs.Add(1)
+	go func() {
+		defer asyncJobs.Done()
+		runJob(job, run)
+	}()
+
+	logger.Info("job started", zap.String("jobId", accepted.ID), zap.String("kind", kind))
+	c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("%s%s/%s", pathAdmin, Jobs, accepted.ID))
+	return c.JSON(http.StatusAccepted, accepted)
+}
+
+// runJob runs the job, then saves whether it succeeded, with its result, or failed. The error of a failed job is
+// described as it would be to the client of a request, and logged in full. The job is saved even when a shutdown
+// cancelled its run.
+func runJob(job *types.Job, run jobRun) {
+	ctx := context.Background()
+	progress := func(done, total int64) {
+		job.Done, job.Total = done, total
+		if err := postgresDB.UpdateJobProgress(ctx, job.ID, done, total); err != nil {
+			logger.Error("job progress error", zap.String("jobId", job.ID), zap.Error(err))
+		}
+	}
 
-	err := traverseBugCounts(msg, campaignName, &points, &scored, &msg.BugCounts)
+	result, err := func() (result interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("job panic: %v", r)
+			}
+		}()
+		return run(jobsCtx, progress)
+	}()
+	if err == nil {
+		job.Result, err = json.Marshal(result)
+	} else if jobsCtx.Err() != nil {
+		err = newApiError(http.StatusServiceUnavailable, jobShutdownError)
+	}
+	finishedOn := time.Now()
+	job.FinishedOn = &finishedOn
 	if err != nil {
-		logger.Error("error traversing bugCounts", zap.Error(err), zap.Any("msg", msg))
+		job.Status, job.Result, job.Error = types.JobStatusFailed, nil, toApiError(err).Message
+		logger.Error("job failed", zap.String("jobId", job.ID), zap.String("kind", job.Kind), zap.Error(err))
+	} else {
+		job.Status = types.JobStatusSucceeded
+		logger.Info("job succeeded", zap.String("jobId", job.ID), zap.String("kind", job.Kind))
 	}
 
-	// add 1 point for all non-classified fixed bugs
-	if scored < float64(msg.TotalFixed) {
-		points += float64(msg.TotalFixed) - scored
+	if err = postgresDB.FinishJob(ctx, job); err != nil {
+		logger.Error("job finish error", zap.String("jobId", job.ID), zap.Error(err))
 	}
+}
 
-	return
+// drainJobs waits for the jobs running in the background to finish. Those still running after the timeout are
+// cancelled, which rolls back their work, and saved as failed.
+func drainJobs(timeout time.Duration) {
+	drained := make(chan struct{})
+	go func() {
+		asyncJobs.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return
+	case <-time.After(timeout):
+	}
+	logger.Warn("jobs cancelled by shutdown", zap.Duration("timeout", timeout))
+	cancelJobs()
+	<-drained
 }
 
-func traverseBugCounts(msg *types.ScoringMessage, campaignName string,
-	points, scored *float64, bugTypes *map[string]interface{}) (err error) {
+// failInstanceJobs fails the jobs a previous run of this replica, with the same instance id, left running.
+func failInstanceJobs(now time.Time) {
+	failed, err := postgresDB.FailInstanceJobs(context.Background(), thisInstance.InstanceId, jobOrphanedError, now)
+	if err != nil {
+		logger.Error("fail instance jobs error", zap.String("instanceId", thisInstance.InstanceId), zap.Error(err))
+	} else if failed > 0 {
+		logger.Warn("instance jobs failed", zap.String("instanceId", thisInstance.InstanceId), zap.Int64("jobs", failed))
+	}
+}
 
-	for bugType, bugValue := range *bugTypes {
-		switch v := bugValue.(type) {
-		case float64:
-			value := postgresDB.SelectPointValue(msg, campaignName, bugType)
-			*points += v * value
-			*scored += v
-		case map[string]interface{}:
-			// oh joy, recursion.
-			err = traverseBugCounts(msg, campaignName, points, scored, &v)
-		default:
-			err = fmt.Errorf("bugType: %+v has unexpected bugValue type: %+v", bugType, v)
-			logger.Error("traverseBugCounts", zap.Error(err), zap.Any("msg", msg))
-		}
+// failOrphanedJobs fails the jobs left running by replicas that stopped sending heartbeats, so they are not followed
+// forever.
+func failOrphanedJobs(now time.Time) (err error) {
+	failed, err := postgresDB.FailOrphanedJobs(context.Background(), now.Add(-heartbeatInterval*missedHeartbeatsAllowed), jobOrphanedError, now)
+	if err != nil {
+		logger.Error("fail orphaned jobs error", zap.Error(err))
+	} else if failed > 0 {
+		logger.Warn("orphaned jobs failed", zap.Int64("jobs", failed))
 	}
 	return
 }
 
-func processScoringMessage(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
-	// force triggerUser to lower case to match database values
-	msg.TriggerUser = strings.ToLower(msg.TriggerUser)
+// getJob shows the progress of a job, and its result or error once it finished.
+func getJob(c echo.Context) (err error) {
+	jobId := c.Param(ParamJobId)
+	job, err := postgresDB.SelectJob(c.Request().Context(), jobId)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no job: jobId: %s", jobId))
+	} else if err != nil {
+		return
+	}
+	return c.JSON(http.StatusOK, job)
+}
 
-	// if this particular entry is not valid, ignore it and continue processing
-	var activeParticipantsToScore []types.ParticipantStruct
-	activeParticipantsToScore, err = validScore(msg, now)
+func getMigrationStatus(c echo.Context) (err error) {
+	var status types.MigrationStatus
+	status, err = postgresDB.SelectMigrationStatus(c.Request().Context())
 	if err != nil {
-		logger.Debug("error validating ScoringMessage", zap.Error(err), zap.Any("msg", msg))
 		return
 	}
-	if len(activeParticipantsToScore) == 0 {
+	return c.JSON(http.StatusOK, status)
+}
+
+// mimeZip is a zip archive
+const mimeZip = "application/zip"
+
+// backupFileTimeLayout names the archive of a backup by when it was taken
+const backupFileTimeLayout = "20060102T150405Z"
+
+// backupDatabase streams a zip archive of the campaigns with their settings, organizations, teams, participants, bugs
+// and scoring events, and the source control providers they refer to, with a newline delimited JSON file of the rows of
+// each table, and a manifest.json of the build, schema version, row counts and the tables left out. It is for taking a
+// copy before a migration, without access to the database itself. The tables are read in one transaction, so the rows
+// are consistent with each other. An error after the first table ends the archive early, without its manifest, and is
+// logged, as the status has already been sent.
+func backupDatabase(c echo.Context) (err error) {
+	var status types.MigrationStatus
+	if status, err = postgresDB.SelectMigrationStatus(c.Request().Context()); err != nil {
+		return
+	}
+
+	now := time.Now().UTC()
+	res := c.Response()
+	archive := zip.NewWriter(res)
+	// the status is sent with the first file, so an error before then is answered as usual
+	createFile := func(name string) (io.Writer, error) {
+		if res.Committed {
+			res.Flush()
+		} else {
+			res.Header().Set(echo.HeaderContentType, mimeZip)
+			res.Header().Set(echo.HeaderContentDisposition,
+				fmt.Sprintf(`attachment; filename="bbash-backup-%s.zip"`, now.Format(backupFileTimeLayout)))
+			res.WriteHeader(http.StatusOK)
+		}
+		return archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+	}
+	rowCounts, err := postgresDB.Backup(c.Request().Context(), func(table string) (io.Writer, error) {
+		return createFile(table + ".ndjson")
+	})
+	if err != nil {
+		if res.Committed {
+			logger.Error("backup ended early", zap.Any("rowCounts", rowCounts), zap.Error(err))
+		}
+		return
+	}
+
+	manifest := types.BackupManifest{
+		CreatedOn:      now,
+		BuildVersion:   buildversion.BuildVersion,
+		SchemaVersion:  status.Version,
+		RowCounts:      rowCounts,
+		ExcludedTables: db.BackupExcludedTables,
+	}
+	var w io.Writer
+	if w, err = createFile("manifest.json"); err != nil {
+		return
+	}
+	if err = json.NewEncoder(w).Encode(manifest); err != nil {
+		return
+	}
+	if err = archive.Close(); err != nil {
+		return
+	}
+	res.Flush()
+
+	logger.Info("database backed up", zap.Any("manifest", manifest))
+	return
+}
+
+type versionInfo struct {
+	BuildVersion  string `json:"buildVersion"`
+	BuildTime     string `json:"buildTime"`
+	BuildCommit   string `json:"buildCommit"`
+	GoVersion     string `json:"goVersion"`
+	SchemaVersion uint   `json:"schemaVersion"`
+	SchemaDirty   bool   `json:"schemaDirty"`
+}
+
+// getVersionInfo describes the build serving the request, and the schema of its database, so a deploy can be verified.
+func getVersionInfo(c echo.Context) (err error) {
+	var status types.MigrationStatus
+	status, err = postgresDB.SelectMigrationStatus(c.Request().Context())
+	if err != nil {
+		return
+	}
+	return c.JSON(http.StatusOK, versionInfo{
+		BuildVersion:  buildversion.BuildVersion,
+		BuildTime:     buildversion.BuildTime,
+		BuildCommit:   buildversion.BuildCommit,
+		GoVersion:     runtime.Version(),
+		SchemaVersion: status.Version,
+		SchemaDirty:   status.Dirty,
+	})
+}
+
+// rollbackMigrations reverts the most recent migrations, one unless the steps query parameter says otherwise. A restart
+// applies them again, so deploy a build without the bad migration before restarting.
+func rollbackMigrations(c echo.Context) (err error) {
+	steps := 1
+	if stepsParam := c.QueryParam("steps"); stepsParam != "" {
+		steps, err = strconv.Atoi(stepsParam)
+		if err != nil || steps < 1 {
+			return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid steps: %s", stepsParam))
+		}
+	}
+
+	var status types.MigrationStatus
+	status, err = postgresDB.SelectMigrationStatus(c.Request().Context())
+	if err != nil {
+		return
+	}
+	if status.Dirty {
+		return newApiError(http.StatusConflict, fmt.Sprintf("schema version %d is dirty", status.Version))
+	}
+	if uint(steps) > status.Version {
+		return newApiError(http.StatusBadRequest, fmt.Sprintf("cannot roll back %d steps from schema version %d", steps, status.Version))
+	}
+
+	logger.Info("rolling back migrations", zap.Uint("version", status.Version), zap.Int("steps", steps))
+	err = postgresDB.RollbackMigrations(steps)
+	if err != nil {
+		return
+	}
+
+	status, err = postgresDB.SelectMigrationStatus(c.Request().Context())
+	if err != nil {
+		return
+	}
+	return c.JSON(http.StatusOK, status)
+}
+
+// loadSeedFixture adds the campaigns, bugs, organizations, teams and participants of the fixture, either all of them
+// or none of them.
+func loadSeedFixture(c echo.Context) (err error) {
+	fixture := types.SeedFixture{}
+	err = json.NewDecoder(c.Request().Body).Decode(&fixture)
+	if err != nil {
+		return
+	}
+	if err = validateRequest(&fixture); err != nil {
+		return
+	}
+
+	// the database takes an unknown campaign of an organization to mean every campaign, so each campaign named must be
+	// in the fixture or exist already
+	known := map[string]bool{}
+	for i := range fixture.Campaigns {
+		fixture.Campaigns[i].TenantName = ""
+		known[fixture.Campaigns[i].Name] = true
+	}
+	var named []string
+	for _, bug := range fixture.Bugs {
+		named = append(named, bug.Campaign)
+	}
+	for _, organization := range fixture.Organizations {
+		if organization.CampaignName != "" {
+			named = append(named, organization.CampaignName)
+		}
+	}
+	for _, team := range fixture.Teams {
+		named = append(named, team.CampaignName)
+	}
+	for _, participant := range fixture.Participants {
+		named = append(named, participant.CampaignName)
+	}
+	for _, campaignName := range named {
+		if known[campaignName] {
+			continue
+		}
+		_, err = postgresDB.GetCampaign(c.Request().Context(), campaignName)
+		if errors.Is(err, sql.ErrNoRows) {
+			return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+		} else if err != nil {
+			return
+		}
+		known[campaignName] = true
+	}
+
+	err = postgresDB.InsertSeedFixture(c.Request().Context(), &fixture)
+	if errors.Is(err, sql.ErrNoRows) {
+		return newApiError(http.StatusNotFound, err.Error())
+	} else if err != nil {
+		return
+	}
+
+	logger.Info("seed fixture loaded",
+		zap.Int("campaigns", len(fixture.Campaigns)),
+		zap.Int("bugs", len(fixture.Bugs)),
+		zap.Int("organizations", len(fixture.Organizations)),
+		zap.Int("teams", len(fixture.Teams)),
+		zap.Int("participants", len(fixture.Participants)))
+	return c.JSON(http.StatusCreated, fixture)
+}
+
+type logLevelResponse struct {
+	Level string `json:"level"`
+}
+
+// setLevel changes the level of logger to the named one, which must be one of logLevels.
+func setLevel(name string) (err error) {
+	var level zapcore.Level
+	if err = level.UnmarshalText([]byte(name)); err != nil {
+		return
+	}
+	for _, allowed := range logLevels {
+		if level == allowed {
+			logLevel.SetLevel(level)
+			return
+		}
+	}
+	return fmt.Errorf("unsupported log level: %s", name)
+}
+
+func getLogLevel(c echo.Context) (err error) {
+	return c.JSON(http.StatusOK, logLevelResponse{Level: logLevel.Level().String()})
+}
+
+func putLogLevel(c echo.Context) (err error) {
+	name := c.Param(ParamLogLevel)
+	previous := logLevel.Level()
+	if err = setLevel(name); err != nil {
+		return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid log level: %s, expected one of: %s", name, logLevels))
+	}
+	// logged at warn, so the change shows at every level
+	logger.Warn("log level changed",
+		zap.Stringer("previous", previous),
+		zap.Stringer("level", logLevel.Level()))
+	return c.JSON(http.StatusOK, logLevelResponse{Level: logLevel.Level().String()})
+}
+
+const defaultMaintenanceMessage = "bbash is down for maintenance, changes are paused, try again later"
+
+// maintenanceRetryAfter is the Retry-After, in seconds, of the changes turned away while in maintenance
+const maintenanceRetryAfter = "60"
+
+// maintenanceCacheTtl is how long an instance trusts the maintenance state it last read, so a change made through one
+// replica reaches the others within it
+const maintenanceCacheTtl = 5 * time.Second
+
+// maintenanceMode caches the maintenance state, which is kept in the database so every replica shares it.
+type maintenanceMode struct {
+	mu     sync.Mutex
+	state  types.MaintenanceState
+	readOn time.Time
+}
+
+// State reads the maintenance state once its cached state is older than maintenanceCacheTtl. When the read fails, the
+// cached state is kept until the next read is due.
+func (m *maintenanceMode) State() types.MaintenanceState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if !m.readOn.IsZero() && now.Sub(m.readOn) < maintenanceCacheTtl {
+		return m.state
+	}
+	m.readOn = now
+	state, err := postgresDB.SelectMaintenance(context.Background())
+	if err != nil {
+		logger.Error("maintenance not read", zap.Error(err))
+		return m.state
+	}
+	m.state = state
+	return m.state
+}
+
+// Set turns maintenance on or off for every replica, keeping the time it was turned on while it stays on.
+func (m *maintenanceMode) Set(ctx context.Context, enabled bool, message string, now time.Time) (state types.MaintenanceState, err error) {
+	if enabled && message == "" {
+		message = defaultMaintenanceMessage
+	}
+	if !enabled {
+		message = ""
+	}
+	state, err = postgresDB.UpdateMaintenance(ctx, enabled, message, now)
+	if err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state, m.readOn = state, time.Now()
+	return
+}
+
+// rejectChangesInMaintenance turns away, with 503, the requests that would change data while in maintenance. Reads
+// keep working.
+func rejectChangesInMaintenance(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		if req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodOptions ||
+			maintenanceExemptRoutes[c.Path()] {
+			return next(c)
+		}
+		state := maintenance.State()
+		if !state.Enabled {
+			return next(c)
+		}
+		logger.Debug("change rejected in maintenance", zap.String("method", req.Method), zap.String("route", c.Path()))
+		c.Response().Header().Set(echo.HeaderRetryAfter, maintenanceRetryAfter)
+		return newApiError(http.StatusServiceUnavailable, state.Message)
+	}
+}
+
+func getMaintenance(c echo.Context) (err error) {
+	return c.JSON(http.StatusOK, maintenance.State())
+}
+
+func putMaintenance(c echo.Context) (err error) {
+	request := types.MaintenanceState{}
+	if err = json.NewDecoder(c.Request().Body).Decode(&request); err != nil {
+		return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid maintenance: %v", err))
+	}
+	state, err := maintenance.Set(c.Request().Context(), request.Enabled, request.Message, time.Now())
+	if err != nil {
+		return
+	}
+	// logged at warn, so the change shows at every level
+	logger.Warn("maintenance changed", zap.Bool("enabled", state.Enabled), zap.String("message", state.Message))
+	return c.JSON(http.StatusOK, state)
+}
+
+// resumePolling has this replica take part in the datadog poll again. It polls once it holds the lease, so not while
+// another replica leads the poll.
+func resumePolling(c echo.Context) (err error) {
+	pollLeaseMu.Lock()
+	defer pollLeaseMu.Unlock()
+
+	pollWanted = true
+	if _, err = leadPollLocked(time.Now()); err != nil {
+		return
+	}
+	state := pollController.State()
+	logger.Info("resume poll", zap.Any("state", state))
+	return c.JSON(http.StatusOK, state)
+}
+
+func pausePolling(c echo.Context) (err error) {
+	state := stopPolling()
+	logger.Info("pause poll", zap.Any("state", state))
+	return c.JSON(http.StatusOK, state)
+}
+
+func setPollDate(c echo.Context) (err error) {
+	pollFromRequest := types.Poll{}
+	err = json.NewDecoder(c.Request().Body).Decode(&pollFromRequest)
+	if err != nil {
+		return
+	}
+
+	pollFromDb := pollDB.NewPoll()
+	err = pollDB.SelectPoll(&pollFromDb)
+	if err != nil {
+		return
+	}
+
+	pollFromDb.LastPolled = pollFromRequest.LastPolled
+	err = pollDB.UpdatePoll(&pollFromDb)
+	if err != nil {
+		return
+	}
+
+	logger.Info("set poll", zap.Any("poll", pollFromDb))
+	return
+}
+
+func startBackfill(c echo.Context) (err error) {
+	request := types.BackfillRequest{}
+	err = json.NewDecoder(c.Request().Body).Decode(&request)
+	if err != nil {
+		return
+	}
+	if err = validateRequest(&request); err != nil {
+		return
+	}
+	if pollDB == nil {
+		return newApiError(http.StatusServiceUnavailable, "polling is disabled")
+	}
+
+	progress, started := backfill.Start(pollDB, scoreDB, request.From, request.To, processScoringMessage)
+	if !started {
+		apiErr := newApiError(http.StatusConflict, "backfill already running")
+		apiErr.Details = progress
+		return apiErr
+	}
+	logger.Info("backfill started", zap.Any("request", request))
+	return c.JSON(http.StatusAccepted, progress)
+}
+
+func getBackfill(c echo.Context) (err error) {
+	progress := backfill.Progress()
+	if progress == nil {
+		return newApiError(http.StatusNotFound, "no backfill")
+	}
+	return c.JSON(http.StatusOK, progress)
+}
+
+func setupRoutes(e *echo.Echo, cfg *config.Config, buildInfoMessage string) (customRouteCount int) {
+	e.HTTPErrorHandler = jsonErrorHandler
+	e.IPExtractor = ipExtractorFromConfig(cfg)
+
+	// first, so the time the other middleware takes, and the requests it turns away, are counted too
+	e.Use(measureRoute(routeMetrics))
+	if corsConfig := corsConfigFromConfig(cfg); corsConfig != nil {
+		e.Use(middleware.CORSWithConfig(*corsConfig))
+	}
+	if rateLimitPerSecond, rateLimitBurst := rateLimitFromConfig(cfg); rateLimitPerSecond > 0 {
+		e.Use(newRateLimiter(rateLimitPerSecond, rateLimitBurst, rateLimitIdentifierIP),
+			newRateLimiter(rateLimitPerSecond, rateLimitBurst, rateLimitIdentifierApiKey))
+	}
+	e.Use(rejectChangesInMaintenance)
+
+	e.GET("/", func(c echo.Context) error {
+		return c.String(http.StatusOK, fmt.Sprintf("I am ALIVE. %s%s", buildInfoMessage, poolStatsMessage()))
+	})
+
+	e.GET(Info+Version, getVersionInfo).Name = "version-info"
+
+	// admin endpoint group
+	adminGroup := e.Group(pathAdmin, middleware.BasicAuth(newAdminValidator(cfg)), auditAdminCall)
+
+	adminGroup.GET(Config, getConfig(cfg)).Name = "config-detail"
+	adminGroup.GET(Routes, getRoutes).Name = "route-list"
+	adminGroup.GET(Metrics, getMetrics).Name = "metrics"
+
+	// Source Control Provider endpoints
+	scpGroup := adminGroup.Group(SourceControlProvider)
+	scpGroup.GET(List, getSourceControlProviders).Name = "scp-list"
+
+	// Organization related endpoints
+	organizationGroup := adminGroup.Group(Organization)
+
+	organizationGroup.GET(List, getOrganizations).Name = "organization-list"
+	organizationGroup.PUT(Add, addOrganization).Name = "organization-add"
+	organizationGroup.DELETE(
+		fmt.Sprintf("%s/:%s/:%s", Delete, ParamScpName, ParamOrganizationName),
+		deleteOrganization).Name = "organization-delete"
+	organizationGroup.PUT(fmt.Sprintf("/:%s/:%s", ParamScpName, ParamOrganizationName), updateOrganization).Name = "organization-update"
+
+	// leaderboard
+	e.GET(fmt.Sprintf("%s/:%s", Leaderboard, ParamCampaignName), getLeaderboard).Name = "leaderboard"
+	// live leaderboard
+	e.GET(fmt.Sprintf("%s%s/:%s", WebSocket, Leaderboard, ParamCampaignName), leaderboardSocket).Name = "leaderboard-ws"
+
+	// Participant related endpoints and group
+
+	publicParticipantGroup := e.Group(Participant)
+	publicParticipantGroup.GET(
+		fmt.Sprintf("%s/:%s", List, ParamCampaignName),
+		getParticipantsList).Name = "participant-list"
+
+	publicParticipantGroup.GET(
+		fmt.Sprintf("%s/:%s/:%s/:%s", Notification, ParamCampaignName, ParamScpName, ParamLoginName),
+		getNotifications, requireParticipant, requirePathParticipant).Name = "participant-self-notification-list"
+	publicParticipantGroup.POST(
+		fmt.Sprintf("%s%s/:%s/:%s/:%s", Notification, Read, ParamCampaignName, ParamScpName, ParamLoginName),
+		readNotifications, requireParticipant, requirePathParticipant).Name = "participant-self-notification-read-all"
+	publicParticipantGroup.POST(
+		fmt.Sprintf("%s%s/:%s/:%s/:%s/:%s", Notification, Read, ParamCampaignName, ParamScpName, ParamLoginName, ParamNotificationId),
+		readNotifications, requireParticipant, requirePathParticipant).Name = "participant-self-notification-read"
+	publicParticipantGroup.GET(
+		fmt.Sprintf("%s/:%s/:%s/:%s", Achievements, ParamCampaignName, ParamScpName, ParamLoginName),
+		getAchievements).Name = "participant-achievements"
+	publicParticipantGroup.PATCH(Profile, updateParticipantProfile, requireParticipant).Name = "participant-self-profile-update"
+	publicParticipantGroup.GET(
+		fmt.Sprintf("/:%s/:%s/:%s%s", ParamCampaignName, ParamScpName, ParamLoginName, Score),
+		getParticipantScoreAt).Name = "participant-score-at"
+
+	participantGroup := adminGroup.Group(Participant)
+	participantGroup.GET(
+		fmt.Sprintf("%s/:%s/:%s/:%s", Detail, ParamCampaignName, ParamScpName, ParamLoginName),
+		getParticipantDetail).Name = "participant-detail"
+
+	participantGroup.GET(Search, searchParticipants).Name = "participant-search"
+	participantGroup.POST(Update, updateParticipant).Name = "participant-update"
+	participantGroup.PATCH(Update, patchParticipant).Name = "participant-patch"
+	participantGroup.PUT(Add, logAddParticipant).Name = "participant-add"
+	participantGroup.PUT(Upsert, upsertParticipant).Name = "participant-upsert"
+	participantGroup.PUT(Ban+Add, addBan).Name = "participant-ban-add"
+	participantGroup.GET(Ban+List, getBans).Name = "participant-ban-list"
+	participantGroup.DELETE(fmt.Sprintf("%s/:%s/:%s", Ban, ParamScpName, ParamLoginName), deleteBan).Name = "participant-ban-delete"
+	participantGroup.DELETE(
+		fmt.Sprintf("%s/:%s/:%s/:%s", Delete, ParamCampaignName, ParamScpName, ParamLoginName),
+		deleteParticipant,
+	)
+
+	// Team related endpoints and group
+
+	teamGroup := adminGroup.Group(Team)
+
+	teamGroup.PUT(Add, addTeam)
+	teamGroup.PUT(fmt.Sprintf("%s/:%s/:%s/:%s/:%s", Person, ParamCampaignName, ParamScpName, ParamLoginName, ParamTeamName), addPersonToTeam)
+	teamGroup.PUT(Person+Bulk, addPeopleToTeams).Name = "team-person-bulk"
+	teamGroup.GET(fmt.Sprintf("%s/:%s", List, ParamCampaignName), getTeams).Name = "team-list"
+	teamGroup.GET(fmt.Sprintf("%s/:%s", Detail, ParamTeamName), getTeamDetail).Name = "team-detail"
+	teamGroup.DELETE(fmt.Sprintf("/:%s/:%s", ParamCampaignName, ParamTeamName), deleteTeam).Name = "team-delete"
+	teamGroup.DELETE(fmt.Sprintf("%s/:%s/:%s/:%s/:%s", Person, ParamCampaignName, ParamScpName, ParamLoginName, ParamTeamName), removePersonFromTeam).Name = "team-person-remove"
+	teamGroup.PUT(fmt.Sprintf("%s/:%s/:%s/:%s/:%s", Captain, ParamCampaignName, ParamTeamName, ParamScpName, ParamLoginName), setTeamCaptain).Name = "team-captain"
+	teamGroup.POST(fmt.Sprintf("%s/:%s/:%s/:%s", Rename, ParamCampaignName, ParamTeamName, ParamNewTeamName), renameTeam).Name = "team-rename"
+	teamGroup.POST(fmt.Sprintf("%s/:%s", Adjust, ParamCampaignName), adjustTeamScores).Name = "team-score-adjust"
+	teamGroup.GET(fmt.Sprintf("%s/:%s/:%s", History, ParamCampaignName, ParamTeamName), getTeamScoreHistory).Name = "team-score-history"
+
+	publicTeamGroup := e.Group(Team)
+	publicTeamGroup.GET(fmt.Sprintf("/:%s/:%s%s", ParamCampaignName, ParamTeamName, Leaderboard), getTeamLeaderboard).Name = "team-leaderboard"
+
+	if cfg.TeamSelfService {
+		// the same team edits, without admin credentials, but only for the team captain. The names keep the
+		// teamSelfServiceRouteNamePrefix, so GET /admin/routes reports the captain role of these routes.
+		publicTeamGroup.PUT(fmt.Sprintf("%s/:%s/:%s/:%s/:%s", Person, ParamCampaignName, ParamScpName, ParamLoginName, ParamTeamName), addPersonToTeam, requireParticipant, requireTeamCaptain, requireJoinableParticipant).Name = "team-self-person-add"
+		publicTeamGroup.DELETE(fmt.Sprintf("%s/:%s/:%s/:%s/:%s", Person, ParamCampaignName, ParamScpName, ParamLoginName, ParamTeamName), removePersonFromTeam, requireParticipant, requireTeamCaptain).Name = "team-self-person-remove"
+		publicTeamGroup.PUT(fmt.Sprintf("%s/:%s/:%s/:%s/:%s", Captain, ParamCampaignName, ParamTeamName, ParamScpName, ParamLoginName), setTeamCaptain, requireParticipant, requireTeamCaptain).Name = "team-self-captain"
+		publicTeamGroup.POST(fmt.Sprintf("%s/:%s/:%s/:%s", Rename, ParamCampaignName, ParamTeamName, ParamNewTeamName), renameTeam, requireParticipant, requireTeamCaptain).Name = "team-self-rename"
+	}
+
+	// Bug related endpoints and group
+
+	bugGroup := adminGroup.Group(Bug)
+
+	bugGroup.PUT(Add, addBug)
+	bugGroup.POST(fmt.Sprintf("%s/:%s/:%s/:%s", Update, ParamCampaignName, ParamBugCategory, ParamPointValue), updateBug)
+	bugGroup.GET(List, getBugs).Name = "bug-list"
+	bugGroup.PUT(List, putBugs)
+	bugGroup.GET(fmt.Sprintf("%s/:%s", Override, ParamCampaignName), getPointValueOverrides).Name = "bug-override-list"
+	bugGroup.PUT(Override, putPointValueOverride).Name = "bug-override-update"
+	bugGroup.DELETE(fmt.Sprintf("%s/:%s/:%s/:%s/:%s", Override, ParamCampaignName, ParamScpName, ParamOrganizationName, ParamBugCategory),
+		deletePointValueOverride).Name = "bug-override-delete"
+	bugGroup.GET(fmt.Sprintf("%s/:%s", Exclusion, ParamCampaignName), getBugCategoryExclusions).Name = "bug-exclusion-list"
+	bugGroup.PUT(fmt.Sprintf("%s/:%s/:%s", Exclusion, ParamCampaignName, ParamBugCategory), addBugCategoryExclusion).Name = "bug-exclusion-add"
+	bugGroup.DELETE(fmt.Sprintf("%s/:%s/:%s", Exclusion, ParamCampaignName, ParamBugCategory), deleteBugCategoryExclusion).Name = "bug-exclusion-delete"
+
+	// Scoring event endpoints
+
+	publicScoreGroup := e.Group(Score)
+	if scoringSecrets := scoringSecretsFromConfig(cfg); len(scoringSecrets) > 0 {
+		publicScoreGroup.POST(Push, pushScoringMessage(scoringSecrets)).Name = "score-push"
+	}
+
+	scoreGroup := adminGroup.Group(Score)
+	scoreGroup.GET(fmt.Sprintf("/:%s/:%s/:%s/:%s/:%s", ParamCampaignName, ParamScpName, ParamRepoOwner, ParamRepoName, ParamPullRequest),
+		getScoringEvent).Name = "score-detail"
+	scoreGroup.POST(DryRun, scoreDryRun).Name = "score-dryrun"
+	adminGroup.DELETE(fmt.Sprintf("%s/:%s", ScoreEvent, ParamScoreEventId), voidScoringEvent).Name = "score-event-void"
+	adminGroup.GET(ScoreEvent+Flag, getScoringFlags).Name = "score-flag-list"
+	adminGroup.GET(ScoreEvent+Export, exportScoringEvents).Name = "score-event-export"
+	adminGroup.POST(ScoreEvent+Import, importScoringEvents).Name = "score-event-import"
+	adminGroup.GET(ScoreEvent+Archive, getScoringEventArchives).Name = "score-event-archive-list"
+	adminGroup.POST(ScoreEvent+Archive, archiveScoringEvents).Name = "score-event-archive"
+	adminGroup.POST(ScoreEvent+Archive+Restore, restoreScoringEvents).Name = "score-event-restore"
+	adminGroup.PUT(fmt.Sprintf("%s%s/:%s%s", ScoreEvent, Flag, ParamScoringFlagId, Approve), approveScoringFlag).Name = "score-flag-approve"
+	adminGroup.PUT(fmt.Sprintf("%s%s/:%s%s", ScoreEvent, Flag, ParamScoringFlagId, Void), voidScoringFlag).Name = "score-flag-void"
+
+	// Campaign related endpoints and group
+
+	publicCampaignGroup := e.Group(Campaign)
+	publicCampaignGroup.GET(active, getActiveCampaigns)
+	publicCampaignGroup.GET(upcoming, getUpcomingCampaigns).Name = "campaign-upcoming"
+	publicCampaignGroup.GET(fmt.Sprintf("%s/:%s", Achievements, ParamCampaignName), getAchievementStats).Name = "campaign-achievements"
+	publicCampaignGroup.GET(fmt.Sprintf("/:%s%s", ParamCampaignName, TimeSeries), getScoreTimeSeries).Name = "campaign-timeseries"
+	publicCampaignGroup.GET(fmt.Sprintf("/:%s%s%s", ParamCampaignName, Leaderboard, Final), getFinalLeaderboard).Name = "campaign-leaderboard-final"
+
+	// public API, a read only subset of the public endpoints without auth, for other sites to embed
+	publicApiGroup := e.Group(pathPublic)
+	publicApiGroup.GET(Campaign+List, getPublicCampaigns, publicCacheControl).Name = "public-campaign-list"
+	publicApiGroup.GET(fmt.Sprintf("%s/:%s%s", Campaign, ParamCampaignName, Leaderboard), getPublicLeaderboard, publicCacheControl).Name = "public-campaign-leaderboard"
+
+	adminGroup.POST(Webhooks, addWebhook).Name = "webhook-add"
+	adminGroup.GET(Webhooks, getWebhooks).Name = "webhook-list"
+	adminGroup.DELETE(fmt.Sprintf("%s/:%s", Webhooks, ParamWebhookId), deleteWebhook).Name = "webhook-delete"
+
+	campaignGroup := adminGroup.Group(Campaign)
+	campaignGroup.GET(List, getCampaigns)
+	campaignGroup.GET(fmt.Sprintf("%s/:%s", Detail, ParamCampaignName), getCampaign).Name = "campaign-detail"
+	campaignGroup.PUT(fmt.Sprintf("%s/:%s", Add, ParamCampaignName), addCampaign)
+	campaignGroup.PUT(fmt.Sprintf("%s/:%s", Update, ParamCampaignName), updateCampaign).Name = "campaign-update"
+	campaignGroup.GET(fmt.Sprintf("%s/:%s", Stage, ParamCampaignName), getCampaignConfigStage).Name = "campaign-stage-detail"
+	campaignGroup.PUT(fmt.Sprintf("%s/:%s", Stage, ParamCampaignName), putCampaignConfigStage).Name = "campaign-stage-update"
+	campaignGroup.DELETE(fmt.Sprintf("%s/:%s", Stage, ParamCampaignName), deleteCampaignConfigStage).Name = "campaign-stage-delete"
+	campaignGroup.POST(fmt.Sprintf("%s/:%s", Promote, ParamCampaignName), promoteCampaignConfigStage).Name = "campaign-stage-promote"
+	campaignGroup.POST(fmt.Sprintf("%s/:%s", Report, ParamCampaignName), createCampaignReport).Name = "campaign-report-create"
+	campaignGroup.GET(fmt.Sprintf("%s/:%s", Report, ParamCampaignName), getCampaignReport).Name = "campaign-report-detail"
+	campaignGroup.POST(fmt.Sprintf("%s%s/:%s", Leaderboard, Final, ParamCampaignName), finalizeCampaignLeaderboard).Name = "campaign-leaderboard-finalize"
+	campaignGroup.GET(fmt.Sprintf("%s/:%s", Slack, ParamCampaignName), getSlackNotification).Name = "campaign-slack-detail"
+	campaignGroup.PUT(fmt.Sprintf("%s/:%s", Slack, ParamCampaignName), putSlackNotification).Name = "campaign-slack-update"
+	campaignGroup.DELETE(fmt.Sprintf("%s/:%s", Slack, ParamCampaignName), deleteSlackNotification).Name = "campaign-slack-delete"
+	campaignGroup.GET(fmt.Sprintf("%s/:%s", Penalty, ParamCampaignName), getCampaignPenalty).Name = "campaign-penalty-detail"
+	campaignGroup.PUT(fmt.Sprintf("%s/:%s", Penalty, ParamCampaignName), putCampaignPenalty).Name = "campaign-penalty-update"
+	campaignGroup.GET(fmt.Sprintf("%s/:%s", Caps, ParamCampaignName), getCampaignCaps).Name = "campaign-caps-detail"
+	campaignGroup.PUT(fmt.Sprintf("%s/:%s", Caps, ParamCampaignName), putCampaignCaps).Name = "campaign-caps-update"
+	campaignGroup.GET(fmt.Sprintf("%s/:%s", Duplicates, ParamCampaignName), getDuplicatePolicy).Name = "campaign-duplicates-detail"
+	campaignGroup.PUT(fmt.Sprintf("%s/:%s", Duplicates, ParamCampaignName), putDuplicatePolicy).Name = "campaign-duplicates-update"
+	campaignGroup.GET(fmt.Sprintf("%s/:%s", CoAuthors, ParamCampaignName), getCoAuthorPolicy).Name = "campaign-coauthors-detail"
+	campaignGroup.PUT(fmt.Sprintf("%s/:%s", CoAuthors, ParamCampaignName), putCoAuthorPolicy).Name = "campaign-coauthors-update"
+	campaignGroup.GET(fmt.Sprintf("%s/:%s", Privacy, ParamCampaignName), getCampaignPrivacy).Name = "campaign-privacy-detail"
+	campaignGroup.PUT(fmt.Sprintf("%s/:%s", Privacy, ParamCampaignName), putCampaignPrivacy).Name = "campaign-privacy-update"
+	campaignGroup.GET(fmt.Sprintf("%s/:%s", Email, ParamCampaignName), getCampaignEmails).Name = "campaign-email-list"
+	campaignGroup.PUT(fmt.Sprintf("%s/:%s/:%s", Email, ParamCampaignName, ParamEmailKind), putCampaignEmail).Name = "campaign-email-update"
+	campaignGroup.GET(fmt.Sprintf("%s/:%s", RepositoryFilter, ParamCampaignName), getRepositoryFilters).Name = "campaign-repository-filter-list"
+	campaignGroup.PUT(fmt.Sprintf("%s/:%s", RepositoryFilter, ParamCampaignName), addRepositoryFilter).Name = "campaign-repository-filter-add"
+	campaignGroup.DELETE(fmt.Sprintf("%s/:%s/:%s", RepositoryFilter, ParamCampaignName, ParamRepositoryFilter),
+		deleteRepositoryFilter).Name = "campaign-repository-filter-delete"
+
+	// Poll related endpoints and group
+
+	pollGroup := adminGroup.Group(Poll)
+	pollGroup.PUT("/last", setPollDate)
+	pollGroup.POST(Pause, pausePolling).Name = "poll-pause"
+	pollGroup.POST(Resume, resumePolling).Name = "poll-resume"
+	pollGroup.POST(Backfill, startBackfill).Name = "poll-backfill-start"
+	pollGroup.GET(Backfill, getBackfill).Name = "poll-backfill-detail"
+
+	adminGroup.GET(Cluster, getClusterStatus).Name = "cluster-status"
+	adminGroup.GET(Jobs, getScheduledJobs).Name = "scheduled-job-list"
+	adminGroup.GET(fmt.Sprintf("%s/:%s", Jobs, ParamJobId), getJob).Name = "job-detail"
+
+	// turning on debug logging during an incident, without a redeploy losing the evidence
+	adminGroup.GET(Log+Level, getLogLevel).Name = "log-level-detail"
+	adminGroup.PUT(fmt.Sprintf("%s%s/:%s", Log, Level, ParamLogLevel), putLogLevel).Name = "log-level-update"
+
+	// read only, while schema migrations or backfills run during a live event
+	adminGroup.GET(Maintenance, getMaintenance).Name = "maintenance-detail"
+	adminGroup.PUT(Maintenance, putMaintenance).Name = "maintenance-update"
+
+	adminGroup.GET(Telemetry, getTelemetryUsage).Name = "telemetry-usage"
+	adminGroup.GET(Audit, getAuditLog).Name = "audit-log"
+
+	adminGroup.GET(Migrations, getMigrationStatus).Name = "migration-status"
+	adminGroup.POST(Migrations+Rollback, rollbackMigrations).Name = "migration-rollback"
+	adminGroup.POST(Backup, backupDatabase).Name = "backup"
+
+	if cfg.AdminSeed {
+		// for preview environments and end to end tests, never for a server holding real campaigns
+		adminGroup.POST(Seed, loadSeedFixture).Name = "admin-seed"
+	}
+
+	// Tenant related endpoints and group
+
+	adminTenantGroup := adminGroup.Group(Tenant)
+	adminTenantGroup.PUT(Add, addTenant).Name = "tenant-add"
+	adminTenantGroup.GET(List, getTenants).Name = "tenant-list"
+
+	// the admin of a tenant only reaches the campaigns of the tenant, and the organizations, participants, teams and
+	// bugs of those campaigns
+	tenantGroup := e.Group(fmt.Sprintf("%s/:%s%s", Tenant, ParamTenantName, pathAdmin),
+		middleware.BasicAuth(newTenantValidator(cfg)), setTenant, auditAdminCall)
+
+	tenantCampaignGroup := tenantGroup.Group(Campaign)
+	tenantCampaignGroup.GET(List, getCampaigns).Name = "tenant-campaign-list"
+	tenantCampaignGroup.GET(fmt.Sprintf("%s/:%s", Detail, ParamCampaignName), getCampaign, requireTenantCampaign).Name = "tenant-campaign-detail"
+	tenantCampaignGroup.PUT(fmt.Sprintf("%s/:%s", Add, ParamCampaignName), addCampaign).Name = "tenant-campaign-add"
+	tenantCampaignGroup.PUT(fmt.Sprintf("%s/:%s", Update, ParamCampaignName), updateCampaign, requireTenantCampaign).Name = "tenant-campaign-update"
+
+	tenantGroup.PUT(Organization+Add, addOrganization).Name = "tenant-organization-add"
+
+	tenantParticipantGroup := tenantGroup.Group(Participant)
+	tenantParticipantGroup.PUT(Add, logAddParticipant).Name = "tenant-participant-add"
+	tenantParticipantGroup.GET(
+		fmt.Sprintf("%s/:%s/:%s/:%s", Detail, ParamCampaignName, ParamScpName, ParamLoginName),
+		getParticipantDetail, requireTenantCampaign).Name = "tenant-participant-detail"
+	tenantParticipantGroup.DELETE(
+		fmt.Sprintf("%s/:%s/:%s/:%s", Delete, ParamCampaignName, ParamScpName, ParamLoginName),
+		deleteParticipant, requireTenantCampaign).Name = "tenant-participant-delete"
+
+	tenantGroup.GET(fmt.Sprintf("%s%s/:%s", Team, List, ParamCampaignName), getTeams, requireTenantCampaign).Name = "tenant-team-list"
+
+	tenantBugGroup := tenantGroup.Group(Bug)
+	tenantBugGroup.PUT(Add, addBug).Name = "tenant-bug-add"
+	tenantBugGroup.POST(fmt.Sprintf("%s/:%s/:%s/:%s", Update, ParamCampaignName, ParamBugCategory, ParamPointValue),
+		updateBug, requireTenantCampaign).Name = "tenant-bug-update"
+
+	tenantGroup.GET(fmt.Sprintf("%s/:%s/:%s/:%s/:%s/:%s", Score, ParamCampaignName, ParamScpName, ParamRepoOwner, ParamRepoName, ParamPullRequest),
+		getScoringEvent, requireTenantCampaign).Name = "tenant-score-detail"
+
+	e.Static("/", buildLocation)
+
+	routes := e.Routes()
+
+	for _, v := range routes {
+		routeInfo := fmt.Sprintf("%s %s as %s", v.Method, v.Path, v.Name)
+		// only print the routes we created ourselves, ignoring the default ones added automatically by echo
+		if !strings.HasPrefix(v.Name, echoDefaultRouteNamePrefix) {
+			customRouteCount++
+			logger.Info("route", zap.String("info", routeInfo))
+		}
+	}
+	return
+}
+
+const echoDefaultRouteNamePrefix = "github.com/labstack/echo/v4."
+
+// codes of an apiError, so clients need not parse the message
+const (
+	errCodeBadRequest      = "bad_request"
+	errCodeUnauthorized    = "unauthorized"
+	errCodeForbidden       = "forbidden"
+	errCodeNotFound        = "not_found"
+	errCodeConflict        = "conflict"
+	errCodeValidation      = "validation_failed"
+	errCodeTooManyRequests = "too_many_requests"
+	errCodeInternal        = "internal_error"
+	errCodeUnavailable     = "unavailable"
+)
+
+var errCodes = map[int]string{
+	http.StatusBadRequest:          errCodeBadRequest,
+	http.StatusUnauthorized:        errCodeUnauthorized,
+	http.StatusForbidden:           errCodeForbidden,
+	http.StatusNotFound:            errCodeNotFound,
+	http.StatusConflict:            errCodeConflict,
+	http.StatusUnprocessableEntity: errCodeValidation,
+	http.StatusTooManyRequests:     errCodeTooManyRequests,
+	http.StatusInternalServerError: errCodeInternal,
+	http.StatusServiceUnavailable:  errCodeUnavailable,
+}
+
+// apiError is the body of every error response.
+type apiError struct {
+	Status  int         `json:"-"`
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+func newApiError(status int, message string) *apiError {
+	code, ok := errCodes[status]
+	if !ok {
+		code = strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+	}
+	return &apiError{Status: status, Code: code, Message: message}
+}
+
+// toApiError maps an error returned by a handler to the response sent to the client. Unexpected errors, like those
+// from the database, are not described to the client.
+func toApiError(err error) (apiErr *apiError) {
+	if errors.As(err, &apiErr) {
+		return
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return newApiError(httpErr.Code, fmt.Sprint(httpErr.Message))
+	}
+
+	var duplicateErr *db.DuplicateError
+	if errors.As(err, &duplicateErr) {
+		apiErr = newApiError(http.StatusConflict, duplicateErr.Error())
+		apiErr.Details = map[string]string{"entity": duplicateErr.Entity}
+		return
+	}
+
+	var staleErr *db.StaleError
+	if errors.As(err, &staleErr) {
+		apiErr = newApiError(http.StatusConflict, staleErr.Error())
+		apiErr.Details = map[string]int{"version": staleErr.Version}
+		return
+	}
+
+	var teamFullErr *db.TeamFullError
+	if errors.As(err, &teamFullErr) {
+		apiErr = newApiError(http.StatusConflict, teamFullErr.Error())
+		apiErr.Details = map[string]int{"maxTeamSize": teamFullErr.MaxTeamSize}
+		return
+	}
+
+	var captainMoveErr *db.CaptainMoveError
+	if errors.As(err, &captainMoveErr) {
+		return newApiError(http.StatusConflict, captainMoveErr.Error())
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return newApiError(http.StatusNotFound, "not found")
+	}
+
+	if errors.Is(err, db.ErrCircuitOpen) {
+		return newApiError(http.StatusServiceUnavailable, "database unavailable, try again later")
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		apiErr = newApiError(http.StatusBadRequest, "invalid request body")
+		apiErr.Details = err.Error()
+		return
+	}
+
+	return newApiError(http.StatusInternalServerError, "internal server error")
+}
+
+// jsonErrorHandler replaces the echo error handler, so every error response has the same JSON body.
+func jsonErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	apiErr := toApiError(err)
+	if apiErr.Status == http.StatusInternalServerError {
+		logger.Error("request failed", zap.Error(err), zap.String("method", c.Request().Method), zap.String("path", c.Path()))
+		errorReporter.Report(err, map[string]interface{}{"method": c.Request().Method, "path": c.Path()})
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(apiErr.Status)
+	} else {
+		err = c.JSON(apiErr.Status, apiErr)
+	}
+	if err != nil {
+		logger.Error("error response", zap.Error(err))
+	}
+}
+
+// fieldError describes why one field of a request body is not valid
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// requestValidator checks request bodies against the validate tags of their types, and names fields as in the JSON.
+var requestValidator = newRequestValidator()
+
+func newRequestValidator() (v *validator.Validate) {
+	v = validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	v.RegisterStructValidation(validateRegistrationWindow, types.CampaignStruct{})
+	// the fields of a partial update are checked as the value sent, which is empty when left out or null
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		return field.Interface().(types.OptionalString).Value
+	}, types.OptionalString{})
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		return field.Interface().(types.OptionalFloat).Value
+	}, types.OptionalFloat{})
+	return
+}
+
+// validateRegistrationWindow checks the registration window of a campaign closes after it opens. The gtfield tag
+// cannot, as either bound may be nil.
+func validateRegistrationWindow(sl validator.StructLevel) {
+	campaign := sl.Current().Interface().(types.CampaignStruct)
+	if campaign.RegistrationOpensOn != nil && campaign.RegistrationClosesOn != nil &&
+		!campaign.RegistrationClosesOn.After(*campaign.RegistrationOpensOn) {
+		sl.ReportError(campaign.RegistrationClosesOn, "registrationClosesOn", "RegistrationClosesOn", "gtfield", "RegistrationOpensOn")
+	}
+}
+
+func fieldErrorMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be an email address"
+	case "gte":
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "lte":
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s characters", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", fe.Param())
+	case "timezone":
+		return "must be an IANA time zone, like America/New_York"
+	case "gtfield":
+		// the param names the go field, which is the json name with a leading capital
+		return fmt.Sprintf("must be after %s", strings.ToLower(fe.Param()[:1])+fe.Param()[1:])
+	default:
+		return fmt.Sprintf("failed %s validation", fe.Tag())
+	}
+}
+
+// validateRequest checks a decoded request body before it reaches the database. The returned apiError lists every
+// field that is not valid.
+func validateRequest(request interface{}) (err error) {
+	value := reflect.Indirect(reflect.ValueOf(request))
+	if value.Kind() == reflect.Slice {
+		err = requestValidator.Var(request, "dive")
+	} else {
+		err = requestValidator.Struct(request)
+	}
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return
+	}
+
+	var fields []fieldError
+	for _, fe := range validationErrs {
+		field := fe.Namespace()
+		if !strings.HasPrefix(field, "[") {
+			// drop the name of the struct type
+			field = field[strings.Index(field, ".")+1:]
+		}
+		fields = append(fields, fieldError{Field: field, Message: fieldErrorMessage(fe)})
+	}
+	apiErr := newApiError(http.StatusUnprocessableEntity, "request is not valid")
+	apiErr.Details = fields
+	logger.Info("request is not valid", zap.Any("fields", fields))
+	return apiErr
+}
+
+// getConfig shows the config the service was started with, without the secrets.
+func getConfig(cfg *config.Config) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return c.JSON(http.StatusOK, cfg.Redacted())
+	}
+}
+
+// unmatchedRoute is the route label of the requests that match no route, so their URLs do not each add a label
+const unmatchedRoute = "unmatched"
+
+// measureRoute is a middleware counting each request in the metrics, by the route pattern it matched.
+func measureRoute(m *metrics.RouteMetrics) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			err := next(c)
+			if err != nil {
+				// sends the error response now, so its status is the one counted
+				c.Error(err)
+			}
+
+			route := c.Path()
+			if route == "" {
+				route = unmatchedRoute
+			}
+			m.Observe(c.Request().Method, route, c.Response().Status, time.Since(start))
+			return nil
+		}
+	}
+}
+
+// getMetrics shows the request metrics of each route, in the Prometheus text format, for a scrape with the admin
+// credentials.
+func getMetrics(c echo.Context) (err error) {
+	c.Response().Header().Set(echo.HeaderContentType, metrics.ContentType)
+	c.Response().WriteHeader(http.StatusOK)
+	_, err = routeMetrics.WriteTo(c.Response())
+	return
+}
+
+// roles that a route requires of the caller
+const (
+	routeRolePublic      = "public"
+	routeRoleAdmin       = "admin"
+	routeRoleTenantAdmin = "tenantAdmin"
+	routeRoleTeamCaptain = "teamCaptain"
+	routeRoleParticipant = "participant"
+)
+
+// teamSelfServiceRouteNamePrefix begins the names of the team routes that requireTeamCaptain guards
+const teamSelfServiceRouteNamePrefix = "team-self-"
+
+// participantSelfRouteNamePrefix begins the names of the participant routes that requireParticipant guards
+const participantSelfRouteNamePrefix = "participant-self-"
+
+// tenantRouteNamePrefix begins the names of the routes of a tenant admin
+const tenantRouteNamePrefix = "tenant-"
+
+type routeDetail struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Role   string `json:"role"`
+	// Handler is the name setupRoutes gave the route, or the name of the handler function for a route left unnamed
+	Handler string `json:"handler"`
+}
+
+type routeInventory struct {
+	BuildVersion string        `json:"buildVersion"`
+	BuildTime    string        `json:"buildTime"`
+	BuildCommit  string        `json:"buildCommit"`
+	Routes       []routeDetail `json:"routes"`
+}
+
+// routeRole is the role the credentials of a request to the route must have, going by the group the route is in
+func routeRole(route *echo.Route) string {
+	switch {
+	case route.Path == pathAdmin || strings.HasPrefix(route.Path, pathAdmin+"/"):
+		return routeRoleAdmin
+	case strings.HasPrefix(route.Path, fmt.Sprintf("%s/:%s%s/", Tenant, ParamTenantName, pathAdmin)):
+		return routeRoleTenantAdmin
+	case strings.HasPrefix(route.Name, teamSelfServiceRouteNamePrefix):
+		return routeRoleTeamCaptain
+	case strings.HasPrefix(route.Name, participantSelfRouteNamePrefix):
+		return routeRoleParticipant
+	}
+	return routeRolePublic
+}
+
+// getRoutes lists the routes this server has, as printRoutes does, with the role each requires and the build serving
+// them.
+func getRoutes(c echo.Context) (err error) {
+	inventory := routeInventory{
+		BuildVersion: buildversion.BuildVersion,
+		BuildTime:    buildversion.BuildTime,
+		BuildCommit:  buildversion.BuildCommit,
+		Routes:       []routeDetail{},
+	}
+	for _, route := range customRoutes(c.Echo()) {
+		inventory.Routes = append(inventory.Routes, routeDetail{
+			Method:  route.Method,
+			Path:    route.Path,
+			Role:    routeRole(route),
+			Handler: route.Name,
+		})
+	}
+	return c.JSON(http.StatusOK, inventory)
+}
+
+// corsConfigFromConfig builds the CORS policy. There is no policy unless some origins are allowed. Methods default to
+// those of echo. Credentials can not be allowed for any origin ("*"), as that would let every site act as the user.
+func corsConfigFromConfig(cfg *config.Config) (corsConfig *middleware.CORSConfig) {
+	origins := cfg.CorsAllowedOrigins
+	if len(origins) == 0 {
+		return
+	}
+
+	corsConfig = &middleware.CORSConfig{
+		AllowOrigins: origins,
+		AllowMethods: middleware.DefaultCORSConfig.AllowMethods,
+	}
+	if len(cfg.CorsAllowedMethods) > 0 {
+		corsConfig.AllowMethods = cfg.CorsAllowedMethods
+	}
+	if cfg.CorsAllowCredentials {
+		for _, origin := range origins {
+			if origin == "*" {
+				logger.Error("cors credentials are not allowed for any origin", zap.Strings("origins", origins))
+				return
+			}
+		}
+		corsConfig.AllowCredentials = true
+	}
+	return
+}
+
+// rateLimitFromConfig reads the rate limit settings, where a zero rate disables rate limiting. The burst defaults to
+// the per second rate, and is at least one.
+func rateLimitFromConfig(cfg *config.Config) (perSecond float64, burst int) {
+	perSecond = cfg.RateLimitPerSecond
+	if perSecond <= 0 {
+		perSecond = 0
+		return
+	}
+	burst = cfg.RateLimitBurst
+	if burst < 1 {
+		burst = int(math.Ceil(perSecond))
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return
+}
+
+// ipExtractorFromConfig reads the IP of the client from X-Forwarded-For, as added by the proxies it came through. Only
+// proxies on a loopback, link-local or private address, like a load balancer, and those in TRUSTED_PROXIES are
+// trusted, so a client can not pick the IP it is rate limited and logged by. An entry that is not a CIDR range is
+// logged and left out.
+func ipExtractorFromConfig(cfg *config.Config) echo.IPExtractor {
+	var options []echo.TrustOption
+	for _, cidr := range cfg.TrustedProxies {
+		_, ipRange, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			logger.Error("invalid TRUSTED_PROXIES entry, expected a CIDR range", zap.String("entry", cidr))
+			continue
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+func rateLimitIdentifierIP(c echo.Context) string {
+	return "ip:" + c.RealIP()
+}
+
+func rateLimitIdentifierApiKey(c echo.Context) string {
+	apiKey := c.Request().Header.Get(headerApiKey)
+	if apiKey == "" {
+		return ""
+	}
+	return "key:" + apiKey
+}
+
+// newRateLimiter builds a token bucket rate limiter per client, as named by the identify func. Requests with no
+// identifier, and the health check, are not limited. A limited request is rejected with 429 and a Retry-After header.
+func newRateLimiter(perSecond float64, burst int, identify func(c echo.Context) string) echo.MiddlewareFunc {
+	retryAfter := strconv.Itoa(int(math.Ceil(1 / perSecond)))
+	return middleware.RateLimiterWithConfig(middleware.RateLimiterConfig{
+		Skipper: func(c echo.Context) bool {
+			return c.Path() == "/" || identify(c) == ""
+		},
+		Store: middleware.NewRateLimiterMemoryStoreWithConfig(middleware.RateLimiterMemoryStoreConfig{
+			Rate:  rate.Limit(perSecond),
+			Burst: burst,
+		}),
+		IdentifierExtractor: func(c echo.Context) (string, error) {
+			return identify(c), nil
+		},
+		DenyHandler: func(c echo.Context, identifier string, err error) error {
+			logger.Debug("rate limited", zap.String("identifier", identifier))
+			c.Response().Header().Set("Retry-After", retryAfter)
+			return newApiError(http.StatusTooManyRequests, "rate limit exceeded")
+		},
+	})
+}
+
+// newAdminValidator checks the credentials of the admin endpoints against the configured admin user.
+func newAdminValidator(cfg *config.Config) middleware.BasicAuthValidator {
+	//goland:noinspection GoUnusedParameter
+	return func(username, password string, c echo.Context) (isValidLogin bool, err error) {
+		// Be careful to use constant time comparison to prevent timing attacks. With no configured admin, as when the
+		// admins are only in the secrets file, blank credentials must not match.
+		if cfg.AdminUsername != "" &&
+			subtle.ConstantTimeCompare([]byte(username), []byte(cfg.AdminUsername)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(password), []byte(cfg.AdminPassword)) == 1 {
+			isValidLogin = true
+		} else if secretsStore != nil && secretsStore.Secrets().ValidAdmin(username, password) {
+			isValidLogin = true
+		} else {
+			logger.Info("failed info endpoint login",
+				zap.String("username", username),
+				zap.String("password", password),
+			)
+		}
+		return
+	}
+}
+
+// newTenantValidator checks the credentials of the tenant endpoints against the admin of the tenant named by the
+// path. The configured admin user may use the tenant endpoints too.
+func newTenantValidator(cfg *config.Config) middleware.BasicAuthValidator {
+	adminValidator := newAdminValidator(cfg)
+	return func(username, password string, c echo.Context) (isValidLogin bool, err error) {
+		tenantName := c.Param(ParamTenantName)
+
+		var tenantUsername, passwordHash string
+		tenantUsername, passwordHash, err = postgresDB.SelectTenantAdmin(c.Request().Context(), tenantName)
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+		} else if err != nil {
+			return
+		} else if subtle.ConstantTimeCompare([]byte(username), []byte(tenantUsername)) == 1 &&
+			bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) == nil {
+			isValidLogin = true
+			return
+		}
+		return adminValidator(username, password, c)
+	}
+}
+
+// requestLogFilter picks the requests left out of the access log, such as the AWS ELB health checks
+type requestLogFilter struct {
+	// includeHostname, unless empty, is the only host whose requests are logged
+	includeHostname  string
+	skipUserAgents   []string
+	skipPathPrefixes []string
+}
+
+func requestLogFilterFromConfig(cfg *config.Config) requestLogFilter {
+	return requestLogFilter{
+		includeHostname:  cfg.LogFilterIncludeHostname,
+		skipUserAgents:   cfg.LogSkipUserAgents,
+		skipPathPrefixes: cfg.LogSkipPaths,
+	}
+}
+
+// skip is true for a request whose user agent contains one of skipUserAgents, whose path begins with one of
+// skipPathPrefixes, or that is to a host other than includeHostname.
+func (f requestLogFilter) skip(req *http.Request) bool {
+	userAgent := req.UserAgent()
+	for _, skipUserAgent := range f.skipUserAgents {
+		if strings.Contains(userAgent, skipUserAgent) {
+			return true
+		}
+	}
+
+	for _, skipPathPrefix := range f.skipPathPrefixes {
+		if strings.HasPrefix(req.URL.Path, skipPathPrefix) {
+			return true
+		}
+	}
+
+	// only log legit stuff from expected host
+	return f.includeHostname != "" && req.Host != "" && f.includeHostname != req.Host
+}
+
+// ZapLoggerFilter is a middleware and zap to provide an "access log" like logging for each request, except those the
+// filter skips. Adapted from ZapLogger, which can not skip the noise of health checks and metrics scrapes.
+func ZapLoggerFilter(log *zap.Logger, filter requestLogFilter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			req := c.Request()
+			res := c.Response()
+
+			fields := []zapcore.Field{
+				zap.String("remote_ip", c.RealIP()),
+				zap.String("latency", time.Since(start).String()),
+				zap.String("host", req.Host),
+				zap.String("request", fmt.Sprintf("%s %s", req.Method, req.RequestURI)),
+				zap.Int("status", res.Status),
+				zap.Int64("size", res.Size),
+				zap.String("user_agent", req.UserAgent()),
+			}
+
+			if filter.skip(req) {
+				return nil
+			}
+
+			id := req.Header.Get(echo.HeaderXRequestID)
+			if id == "" {
+				id = res.Header().Get(echo.HeaderXRequestID)
+				fields = append(fields, zap.String("request_id", id))
+			}
+
+			n := res.Status
+			switch {
+			case n >= 500:
+				log.With(zap.Error(err)).Error("Server error", fields...)
+			case n >= 400:
+				log.With(zap.Error(err)).Warn("Client error", fields...)
+			case n >= 300:
+				log.Info("Redirection", fields...)
+			default:
+				log.Info("Success", fields...)
+			}
+
+			return nil
+		}
+	}
+}
+
+func openDB(cfg *config.Config) (db *sql.DB, host string, port int, dbname, sslMode string, err error) {
+	host = cfg.PGHost
+	port = cfg.PGPort
+	user := cfg.PGUsername
+	password := cfg.PGPassword
+	dbname = cfg.PGDBName
+	sslMode = cfg.SSLMode
+
+	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s "+
+		"password=%s dbname=%s sslmode=%s",
+		host, port, user, password, dbname, sslMode)
+	connector, err := pq.NewConnector(psqlInfo)
+	if err != nil {
+		return
+	}
+	dbBreaker = breakerFromConfig(cfg, "primary")
+	db = sql.OpenDB(withBreaker(connector, dbBreaker))
+	applyPoolSettings(db, cfg)
+	return
+}
+
+// openReadReplicaDB opens the optional read only database, which serves the list and leaderboard queries. Returns a
+// nil db when no replica is configured.
+func openReadReplicaDB(cfg *config.Config) (db *sql.DB, err error) {
+	dsn := cfg.PGReadReplicaDSN
+	if dsn == "" {
+		return
+	}
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return
+	}
+	// a replica of its own, so the primary keeps serving while the replica is down
+	db = sql.OpenDB(withBreaker(connector, breakerFromConfig(cfg, "replica")))
+	applyPoolSettings(db, cfg)
+	return
+}
+
+// dbBreaker fails the calls to the primary database fast while it is unreachable, nil when disabled
+var dbBreaker *db.Breaker
+
+// breakerFromConfig returns the circuit breaker of a database, nil when disabled by a PG_BREAKER_FAILURES of zero or
+// less.
+func breakerFromConfig(cfg *config.Config, name string) *db.Breaker {
+	if cfg.PGBreakerFailures <= 0 {
+		return nil
+	}
+	return db.NewBreaker(logger.With(zap.String("db", name)), cfg.PGBreakerFailures, time.Duration(cfg.PGBreakerOpenDuration))
+}
+
+func withBreaker(connector driver.Connector, breaker *db.Breaker) driver.Connector {
+	if breaker == nil {
+		return connector
+	}
+	return breaker.Connector(connector)
+}
+
+// applyPoolSettings sizes the connection pool from the config. Negative values leave the database/sql default in
+// place.
+func applyPoolSettings(db *sql.DB, cfg *config.Config) {
+	if cfg.PGMaxOpenConns >= 0 {
+		db.SetMaxOpenConns(cfg.PGMaxOpenConns)
+	}
+	if cfg.PGMaxIdleConns >= 0 {
+		db.SetMaxIdleConns(cfg.PGMaxIdleConns)
+	}
+	if cfg.PGConnMaxLifetime >= 0 {
+		db.SetConnMaxLifetime(time.Duration(cfg.PGConnMaxLifetime))
+	}
+}
+
+// statementTimeoutFromConfig reads how long a database call may take. A zero duration removes the limit. Not ok when
+// negative (unset), leaving the default in place.
+func statementTimeoutFromConfig(cfg *config.Config) (timeout time.Duration, ok bool) {
+	timeout = time.Duration(cfg.PGStatementTimeout)
+	ok = timeout >= 0
+	return
+}
+
+func poolStatsMessage() string {
+	if postgresDB == nil || postgresDB.GetDb() == nil {
+		return ""
+	}
+	stats := postgresDB.GetDb().Stats()
+	msg := fmt.Sprintf(" DB pool: maxOpen: %d, open: %d, inUse: %d, idle: %d, waitCount: %d, waitDuration: %s",
+		stats.MaxOpenConnections, stats.OpenConnections, stats.InUse, stats.Idle, stats.WaitCount, stats.WaitDuration)
+	if dbBreaker != nil {
+		msg += fmt.Sprintf(", circuit: %s", dbBreaker.State())
+	}
+	return msg
+}
+
+func getSourceControlProviders(c echo.Context) (err error) {
+	var scps []types.SourceControlProviderStruct
+	scps, err = postgresDB.GetSourceControlProviders(c.Request().Context())
+	if err != nil {
+		return
+	}
+
+	return listJSON(c, scps)
+}
+
+func addOrganization(c echo.Context) (err error) {
+	organization := types.OrganizationStruct{}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&organization)
+	if err != nil {
+		return
+	}
+	if err = validateRequest(&organization); err != nil {
+		return
+	}
+	if err = checkTenantCampaign(c, organization.CampaignName); err != nil {
+		return
+	}
+	if organization.CampaignName != "" {
+		_, err = postgresDB.GetCampaign(c.Request().Context(), organization.CampaignName)
+		if errors.Is(err, sql.ErrNoRows) {
+			return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", organization.CampaignName))
+		} else if err != nil {
+			return
+		}
+	}
+
+	var guid string
+	guid, err = postgresDB.InsertOrganization(c.Request().Context(), &organization)
+	if err != nil {
+		logger.Error("error inserting organization", zap.Any("organization", organization), zap.Error(err))
+		return
+	}
+
+	logger.Debug("added organization", zap.Any("organization", organization))
+	creation := newCreation(guid, organization)
+	addEndpoint(c, &creation, "organizationUpdate", "organization-update", http.MethodPut, organization.SCPName, organization.Organization)
+	addEndpoint(c, &creation, "organizationDelete", "organization-delete", http.MethodDelete, organization.SCPName, organization.Organization)
+	return sendCreation(c, creation, v1CreationId)
+}
+
+func getOrganizations(c echo.Context) (err error) {
+	var orgs []types.OrganizationStruct
+	orgs, err = postgresDB.GetOrganizations(c.Request().Context())
+	if err != nil {
+		return
+	}
+
+	return listJSON(c, orgs)
+}
+
+func deleteOrganization(c echo.Context) (err error) {
+	scpName := c.Param(ParamScpName)
+	orgName := c.Param(ParamOrganizationName)
+	// an organization of one campaign is deleted by naming the campaign, one of every campaign by naming none
+	campaignName := c.QueryParam(ParamCampaignName)
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.DeleteOrganization(c.Request().Context(), scpName, orgName, campaignName)
+	if err != nil {
+		return
+	}
+	logger.Info("delete organization",
+		zap.String("scpName", scpName),
+		zap.String("orgName", orgName),
+		zap.String("campaignName", campaignName),
+		zap.Int64("rowsAffected", rowsAffected))
+	if rowsAffected > 0 {
+		return c.NoContent(http.StatusNoContent)
+	}
+	if campaignName != "" {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no organization: scpName: %s, name: %s, campaignName: %s", scpName, orgName, campaignName))
+	}
+	return newApiError(http.StatusNotFound, fmt.Sprintf("no organization: scpName: %s, name: %s", scpName, orgName))
+}
+
+func updateOrganization(c echo.Context) (err error) {
+	scpName := c.Param(ParamScpName)
+	orgName := c.Param(ParamOrganizationName)
+
+	update := types.OrganizationUpdate{}
+	err = json.NewDecoder(c.Request().Body).Decode(&update)
+	if err != nil {
+		return
+	}
+	if err = validateRequest(&update); err != nil {
+		return
+	}
+
+	var rowsAffected, eventsRelinked int64
+	rowsAffected, eventsRelinked, err = postgresDB.UpdateOrganization(c.Request().Context(), scpName, orgName, &update)
+	if err != nil {
+		return
+	}
+	logger.Info("update organization",
+		zap.String("scpName", scpName),
+		zap.String("orgName", orgName),
+		zap.Any("update", update),
+		zap.Int64("rowsAffected", rowsAffected),
+		zap.Int64("eventsRelinked", eventsRelinked))
+	if rowsAffected > 0 {
+		return c.NoContent(http.StatusNoContent)
+	}
+	return newApiError(http.StatusNotFound, fmt.Sprintf("no organization: scpName: %s, name: %s", scpName, orgName))
+}
+
+func validScore(msg *types.ScoringMessage, now time.Time) (participantsToScore []types.ParticipantStruct, err error) {
+	// check if repo is in participating set
+	isValidOrg, err := postgresDB.ValidOrganization(context.Background(), msg, now)
+	if err != nil {
+		logger.Debug("skip score-error reading organization", zap.Any("msg", msg), zap.Error(err))
+		return
+	}
+	if !isValidOrg {
+		logger.Debug("skip score-missing organization",
+			zap.String("RepoOwner", msg.RepoOwner), zap.String("TriggerUser", msg.TriggerUser))
+		return
+	}
+
+	// Check if participant is registered for an active campaign
+	participantsToScore, err = postgresDB.SelectParticipantsToScore(context.Background(), msg, now)
+	if err != nil {
+		logger.Error("skip score-error reading participant", zap.Any("msg", msg), zap.Error(err))
+		return
+	}
+	if len(participantsToScore) == 0 {
+		logger.Debug("skip score-missing participant", zap.Any("msg", msg), zap.Error(err))
+		return
+	}
+
+	// a banned login scores in no campaign, not even one it joined before the ban
+	var banned bool
+	banned, err = postgresDB.SelectBanned(context.Background(), participantsToScore[0].ScpName, participantsToScore[0].LoginName)
+	if err != nil {
+		logger.Error("skip score-error reading ban", zap.Any("msg", msg), zap.Error(err))
+		participantsToScore = nil
+		return
+	}
+	if banned {
+		logger.Info("skip score-banned participant", zap.Any("msg", msg))
+		participantsToScore = nil
+		return
+	}
+
+	// each campaign decides which repositories of its organizations score, e.g. not forks or docs
+	var scored []types.ParticipantStruct
+	for _, participant := range participantsToScore {
+		var filters []types.RepositoryFilter
+		filters, err = postgresDB.SelectRepositoryFilters(context.Background(), participant.CampaignName)
+		if err != nil {
+			logger.Error("skip score-error reading repository filters", zap.Any("msg", msg), zap.Error(err))
+			participantsToScore = nil
+			return
+		}
+		if repositoryScored(filters, msg.RepoName) {
+			scored = append(scored, participant)
+		} else {
+			logger.Debug("skip score-repository filtered",
+				zap.String("campaignName", participant.CampaignName), zap.String("RepoName", msg.RepoName))
+		}
+	}
+	participantsToScore = scored
+	return
+}
+
+// coAuthorsToScore resolves the co-authors of the message to the participants they are in each campaign scored, by
+// campaign name. The trigger user, a login named twice, and banned logins are left out, as is a co-author who can not
+// be read.
+func coAuthorsToScore(msg *types.ScoringMessage, participantsToScore []types.ParticipantStruct, now time.Time) (coAuthors map[string][]types.ParticipantStruct) {
+	coAuthors = map[string][]types.ParticipantStruct{}
+	seen := map[string]bool{msg.TriggerUser: true}
+	for _, loginName := range msg.CoAuthors {
+		// force co-author to lower case to match database values, as the trigger user
+		loginName = strings.ToLower(strings.TrimSpace(loginName))
+		if loginName == "" || seen[loginName] {
+			continue
+		}
+		seen[loginName] = true
+
+		coAuthorMsg := *msg
+		coAuthorMsg.TriggerUser = loginName
+		participants, err := postgresDB.SelectParticipantsToScore(context.Background(), &coAuthorMsg, now)
+		if err != nil {
+			logger.Error("skip co-author-error reading participant", zap.String("coAuthor", loginName), zap.Error(err))
+			continue
+		}
+		if len(participants) == 0 {
+			logger.Debug("skip co-author-missing participant", zap.String("coAuthor", loginName))
+			continue
+		}
+		banned, err := postgresDB.SelectBanned(context.Background(), participants[0].ScpName, loginName)
+		if err != nil {
+			logger.Error("skip co-author-error reading ban", zap.String("coAuthor", loginName), zap.Error(err))
+			continue
+		}
+		if banned {
+			logger.Info("skip co-author-banned participant", zap.String("coAuthor", loginName))
+			continue
+		}
+
+		for _, participant := range participants {
+			for _, scored := range participantsToScore {
+				if participant.CampaignName == scored.CampaignName {
+					coAuthors[participant.CampaignName] = append(coAuthors[participant.CampaignName], participant)
+				}
+			}
+		}
+	}
+	return
+}
+
+// repositoryScored reports whether the repository scores, given the repository filters of a campaign. A deny pattern
+// wins over an allow pattern, and with no allow pattern every repository not denied scores.
+func repositoryScored(filters []types.RepositoryFilter, repoName string) bool {
+	repoName = strings.ToLower(repoName)
+	allowed, hasAllow := false, false
+	for _, filter := range filters {
+		// patterns are checked when added, so a malformed one does not match
+		matched, _ := path.Match(filter.Pattern, repoName)
+		switch filter.Kind {
+		case types.RepositoryFilterDeny:
+			if matched {
+				return false
+			}
+		case types.RepositoryFilterAllow:
+			hasAllow = true
+			allowed = allowed || matched
+		}
+	}
+	return allowed || !hasAllow
+}
+
+func scorePoints(msg *types.ScoringMessage, campaignName string) (points float64, breakdown *types.ScoreBreakdown) {
+	breakdown = &types.ScoreBreakdown{}
+
+	scoreBugCounts(msg, campaignName, breakdown)
+	sort.Slice(breakdown.Categories, func(i, j int) bool {
+		return breakdown.Categories[i].Category < breakdown.Categories[j].Category
+	})
+
+	// add the bonus of the campaign for all non-classified fixed bugs, which excluded bugs are not
+	if unclassified := float64(msg.TotalFixed) - breakdown.Classified - breakdown.Excluded; unclassified > 0 {
+		breakdown.Unclassified = unclassified
+		breakdown.UnclassifiedBonus = unclassified * unclassifiedBonus(msg, campaignName)
+	}
+
+	points = breakdown.BasePoints + breakdown.UnclassifiedBonus
+	if msg.TotalIntroduced > 0 {
+		points = applyPenalty(msg, campaignName, points, breakdown)
+	}
+	breakdown.Points = points
+	return
+}
+
+// unclassifiedBonus reads the points the campaign scores for each fixed bug without a category. When the campaign
+// can not be read, the DefaultUnclassifiedBonus is scored.
+func unclassifiedBonus(msg *types.ScoringMessage, campaignName string) float64 {
+	bonus, err := postgresDB.SelectUnclassifiedBonus(context.Background(), campaignName)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logger.Error("error reading campaign unclassified bonus", zap.String("campaignName", campaignName), zap.Error(err), zap.Any("msg", msg))
+		}
+		return types.DefaultUnclassifiedBonus
+	}
+	return bonus
+}
+
+// applyPenalty takes the introduced bug penalty of the campaign off the points of a pull request. Without a penalty
+// configured, or enabled, for the campaign, the points are unchanged.
+func applyPenalty(msg *types.ScoringMessage, campaignName string, points float64, breakdown *types.ScoreBreakdown) float64 {
+	penalty, err := postgresDB.SelectCampaignPenalty(context.Background(), campaignName)
+	if err == sql.ErrNoRows {
+		return points
+	} else if err != nil {
+		logger.Error("error reading campaign penalty", zap.String("campaignName", campaignName), zap.Error(err), zap.Any("msg", msg))
+		return points
+	}
+	if !penalty.Enabled {
+		return points
+	}
+
+	breakdown.Introduced = msg.TotalIntroduced
+	breakdown.Penalty = float64(msg.TotalIntroduced) * penalty.PointValue
+	if penalty.Floor && breakdown.Penalty > points {
+		breakdown.Penalty = points
+	}
+	return points - breakdown.Penalty
+}
+
+// scoreBugCounts adds the points of each classified bug type of the message to the breakdown.
+func scoreBugCounts(msg *types.ScoringMessage, campaignName string, breakdown *types.ScoreBreakdown) {
+	if len(msg.BugCounts) == 0 {
+		return
+	}
+	excluded := excludedBugCategories(msg, campaignName)
+	for _, bugCount := range msg.BugCounts {
+		if excluded[bugCount.Type] {
+			breakdown.Excluded += bugCount.Count
+			continue
+		}
+		value := postgresDB.SelectPointValue(context.Background(), msg, campaignName, bugCount.Type)
+		breakdown.Categories = append(breakdown.Categories, types.CategoryScore{
+			Category:   bugCount.Type,
+			Count:      bugCount.Count,
+			PointValue: value,
+			Points:     bugCount.Count * value,
+		})
+		breakdown.BasePoints += bugCount.Count * value
+		breakdown.Classified += bugCount.Count
+	}
+}
+
+// applyDuplicates takes off the points of the fixed bugs that another participant of the campaign fixed first, in the
+// same repository, as the duplicate policy of the campaign says, and records them in the breakdown. Each fingerprint
+// of the message is an equal share of the points. Without fingerprints, or when the duplicates can not be read, the
+// points are unchanged.
+func applyDuplicates(scoreDb db.IScoreDB, participant *types.ParticipantStruct, msg *types.ScoringMessage, points float64, breakdown *types.ScoreBreakdown) float64 {
+	if len(msg.Fingerprints) == 0 || points <= 0 {
+		return points
+	}
+
+	var weight float64
+	policy, err := postgresDB.SelectDuplicatePolicy(context.Background(), participant.CampaignName)
+	if err == nil {
+		switch policy.Policy {
+		case types.DuplicatePolicyScore:
+			return points
+		case types.DuplicatePolicyDownweight:
+			weight = policy.Weight
+		}
+	} else if err != sql.ErrNoRows {
+		logger.Error("error reading duplicate policy", zap.String("campaignName", participant.CampaignName), zap.Error(err), zap.Any("msg", msg))
+		return points
+	}
+
+	duplicates, err := scoreDb.SelectDuplicateFingerprints(context.Background(), participant, msg)
+	if err != nil {
+		logger.Error("error reading duplicate fixes", zap.Any("participant", participant), zap.Error(err), zap.Any("msg", msg))
+		return points
+	}
+	if len(duplicates) == 0 {
+		return points
+	}
+
+	fingerprints := map[string]bool{}
+	for _, fingerprint := range msg.Fingerprints {
+		fingerprints[fingerprint] = true
+	}
+	breakdown.Duplicates = len(duplicates)
+	breakdown.DuplicatePoints = points * float64(len(duplicates)) / float64(len(fingerprints)) * (1 - weight)
+	breakdown.Points = points - breakdown.DuplicatePoints
+	return breakdown.Points
+}
+
+// applyCoAuthors shares the points of the pull request with its co-authors as the co-author policy of the campaign
+// says, and records the share in the breakdown. The points returned are those of each author. A policy that can not be
+// read splits the points, as does a campaign without one.
+func applyCoAuthors(campaignName string, coAuthors int, points float64, breakdown *types.ScoreBreakdown) float64 {
+	if coAuthors == 0 {
+		return points
+	}
+	breakdown.CoAuthors = coAuthors
+
+	policy, err := postgresDB.SelectCoAuthorPolicy(context.Background(), campaignName)
+	if err == nil && policy.Policy == types.CoAuthorPolicyDuplicate {
+		return points
+	} else if err != nil && err != sql.ErrNoRows {
+		logger.Error("error reading co-author policy", zap.String("campaignName", campaignName), zap.Error(err))
+	}
+
+	share := points / float64(coAuthors+1)
+	breakdown.SplitPoints = points - share
+	breakdown.Points = share
+	return share
+}
+
+// applyCaps keeps the points of a pull request under the caps of the campaign, per pull request and per participant
+// per day, and records the points capped in the breakdown. Without caps, or when they can not be read, the points are
+// unchanged.
+func applyCaps(scoreDb db.IScoreDB, participant *types.ParticipantStruct, msg *types.ScoringMessage, points float64, breakdown *types.ScoreBreakdown, now time.Time) float64 {
+	caps, err := postgresDB.SelectCampaignCaps(context.Background(), participant.CampaignName)
+	if err == sql.ErrNoRows {
+		return points
+	} else if err != nil {
+		logger.Error("error reading campaign caps", zap.String("campaignName", participant.CampaignName), zap.Error(err), zap.Any("msg", msg))
+		return points
+	}
+
+	capped := points
+	if caps.PerPullRequest > 0 && capped > caps.PerPullRequest {
+		capped = caps.PerPullRequest
+	}
+	if caps.PerDay > 0 && capped > 0 {
+		var scoredToday float64
+		scoredToday, err = pointsScoredToday(scoreDb, participant, msg, now)
+		if err != nil {
+			logger.Error("error reading points scored today", zap.Any("participant", participant), zap.Error(err), zap.Any("msg", msg))
+		} else if remaining := math.Max(caps.PerDay-scoredToday, 0); capped > remaining {
+			capped = remaining
+		}
+	}
+	if capped < points {
+		breakdown.Capped = points - capped
+		breakdown.Points = capped
+	}
+	return capped
+}
+
+// pointsScoredToday sums the points of the other pull requests the participant scored since the start of the UTC day of
+// now. The pull request being scored is left out, as its points are about to be replaced.
+func pointsScoredToday(scoreDb db.IScoreDB, participant *types.ParticipantStruct, msg *types.ScoringMessage, now time.Time) (points float64, err error) {
+	var scored []db.ScoringEventRow
+	scored, err = scoreDb.SelectScoringEventsSince(context.Background(), participant, now.UTC().Truncate(24*time.Hour))
+	if err != nil {
+		return
+	}
+	for _, event := range scored {
+		if event.Msg.RepoOwner == msg.RepoOwner && event.Msg.RepoName == msg.RepoName && event.Msg.PullRequest == msg.PullRequest {
+			continue
+		}
+		points += event.NewPoints
+	}
+	return
+}
+
+// excludedBugCategories reads the bug categories the campaign does not reward. When they can not be read, no category
+// is excluded.
+func excludedBugCategories(msg *types.ScoringMessage, campaignName string) (excluded map[string]bool) {
+	exclusions, err := postgresDB.SelectBugCategoryExclusions(context.Background(), campaignName)
+	if err != nil {
+		logger.Error("error reading bug category exclusions", zap.String("campaignName", campaignName), zap.Error(err), zap.Any("msg", msg))
+		return
+	}
+	excluded = map[string]bool{}
+	for _, exclusion := range exclusions {
+		excluded[exclusion.Category] = true
+	}
+	return
+}
+
+// newScoreEvents returns the bus with the reactions to a score change. The ones to a scored scoring message skip the
+// changes from elsewhere.
+func newScoreEvents() *events.Bus {
+	bus := events.New()
+	bus.OnScoreChanged(publishScoreDelta)
+	bus.OnScoreChanged(func(ctx context.Context, event events.ScoreChanged) {
+		publishWebhookEvent(ctx, types.WebhookEventScoreChanged, event.ScoreDelta)
+	})
+	bus.OnScoreChanged(func(ctx context.Context, event events.ScoreChanged) {
+		if event.Message == nil {
+			return
+		}
+		notifyPointsAwarded(event.Participant, event.Message, event.Breakdown, event.OccurredOn)
+		notifySlack(event.Participant, event.Delta)
+		evaluateAchievements(event.Participant, event.Delta, event.OccurredOn)
+	})
+	return bus
+}
+
+// publishScoreDelta sends the score change to the live leaderboards, with the pseudonym of the participant when the
+// campaign hides login names. The privacy setting is read once for all the subscribers of the campaign, and not at
+// all when there are none.
+func publishScoreDelta(ctx context.Context, event events.ScoreChanged) {
+	delta := event.ScoreDelta
+	if scoreHub.SubscriberCount(delta.CampaignName) == 0 {
+		return
+	}
+	privacy, err := selectAnonymized(ctx, delta.CampaignName)
+	if err != nil {
+		// dropped, rather than risk showing the login name of an anonymized campaign
+		logger.Error("leaderboard delta privacy error", zap.String("campaignName", delta.CampaignName), zap.Error(err))
+		return
+	} else if privacy != nil {
+		delta.LoginName = pseudonym(privacy, delta.ScpName, delta.LoginName)
+	}
+	scoreHub.Publish(delta)
+}
+
+func processScoringMessage(scoreDb db.IScoreDB, now time.Time, msg *types.ScoringMessage) (err error) {
+	// force triggerUser to lower case to match database values
+	msg.TriggerUser = strings.ToLower(msg.TriggerUser)
+
+	// if this particular entry is not valid, ignore it and continue processing
+	var activeParticipantsToScore []types.ParticipantStruct
+	activeParticipantsToScore, err = validScore(msg, now)
+	if err != nil {
+		logger.Debug("error validating ScoringMessage", zap.Error(err), zap.Any("msg", msg))
+		return
+	}
+	if len(activeParticipantsToScore) == 0 {
+		return
+	}
+	var coAuthors map[string][]types.ParticipantStruct
+	if len(msg.CoAuthors) > 0 {
+		coAuthors = coAuthorsToScore(msg, activeParticipantsToScore, now)
+	}
+	for _, participantToScore := range activeParticipantsToScore {
+
+		newPoints, breakdown := scorePoints(msg, participantToScore.CampaignName)
+		newPoints = applyDuplicates(scoreDb, &participantToScore, msg, newPoints, breakdown)
+		campaignCoAuthors := coAuthors[participantToScore.CampaignName]
+		share := applyCoAuthors(participantToScore.CampaignName, len(campaignCoAuthors), newPoints, breakdown)
+		// each author's share is kept under their own caps
+		shareBreakdown := *breakdown
+		newPoints = applyCaps(scoreDb, &participantToScore, msg, share, breakdown, now)
+
+		oldPoints := scoreDb.SelectPriorScore(context.Background(), &participantToScore, msg)
+		breakdown.PriorPoints = oldPoints
+		breakdown.Delta = newPoints - oldPoints
+
+		err = scoreDb.InsertScoringEvent(context.Background(), &participantToScore, msg, newPoints, breakdown)
+		if err != nil {
+			return
+		}
+
+		err = scoreDb.UpdateParticipantScore(context.Background(), &participantToScore, newPoints-oldPoints)
+		if err != nil {
+			return
+		}
+
+		publishScoreChanged(&participantToScore, msg, breakdown, now)
+
+		logger.Debug("score updated",
+			zap.Float64("newPoints", newPoints), zap.Float64("oldPoints", oldPoints), zap.Any("ScoringMessage", msg))
+
+		for i := range campaignCoAuthors {
+			if err = scoreCoAuthor(scoreDb, &campaignCoAuthors[i], msg, share, &shareBreakdown, now); err != nil {
+				return
+			}
+		}
+		if err = reverseDroppedCoAuthors(scoreDb, &participantToScore, msg, campaignCoAuthors); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// scoreCoAuthor scores the co-author their share of the pull request, kept under their caps, recorded against its
+// scoring event, which must already be inserted. The breakdown is that of the share before any cap.
+func scoreCoAuthor(scoreDb db.IScoreDB, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage, share float64, shareBreakdown *types.ScoreBreakdown, now time.Time) (err error) {
+	coAuthorBreakdown := *shareBreakdown
+	newPoints := applyCaps(scoreDb, coAuthor, msg, share, &coAuthorBreakdown, now)
+	oldPoints := scoreDb.SelectCoAuthorPriorScore(context.Background(), coAuthor, msg)
+	coAuthorBreakdown.PriorPoints = oldPoints
+	coAuthorBreakdown.Delta = newPoints - oldPoints
+
+	err = scoreDb.InsertCoAuthorScore(context.Background(), coAuthor, msg, newPoints)
+	if err != nil {
+		return
+	}
+
+	err = scoreDb.UpdateParticipantScore(context.Background(), coAuthor, newPoints-oldPoints)
+	if err != nil {
+		return
+	}
+
+	publishScoreChanged(coAuthor, msg, &coAuthorBreakdown, now)
+
+	logger.Debug("co-author score updated", zap.String("coAuthor", coAuthor.LoginName),
+		zap.Float64("newPoints", newPoints), zap.Float64("oldPoints", oldPoints), zap.Any("ScoringMessage", msg))
+	return
+}
+
+// reverseDroppedCoAuthors takes the shares of an earlier scoring of the pull request off the co-authors it no longer
+// has, so that a co-author removed from the pull request does not keep the points. As for a void, the change is not a
+// scored message.
+func reverseDroppedCoAuthors(scoreDb db.IScoreDB, participant *types.ParticipantStruct, msg *types.ScoringMessage, coAuthors []types.ParticipantStruct) (err error) {
+	var scored []db.CoAuthorScoreRow
+	scored, err = scoreDb.SelectCoAuthorScores(context.Background(), participant, msg)
+	if err != nil {
+		return
+	}
+	kept := make(map[string]bool, len(coAuthors))
+	for _, coAuthor := range coAuthors {
+		kept[coAuthor.LoginName] = true
+	}
+	for i := range scored {
+		dropped := &scored[i].Participant
+		if kept[dropped.LoginName] {
+			continue
+		}
+		if err = scoreDb.DeleteCoAuthorScore(context.Background(), dropped, msg); err != nil {
+			return
+		}
+		if err = scoreDb.UpdateParticipantScore(context.Background(), dropped, -scored[i].Points); err != nil {
+			return
+		}
+
+		scoreEvents.PublishScoreChanged(context.Background(), events.ScoreChanged{
+			ScoreDelta: types.ScoreDelta{
+				CampaignName: dropped.CampaignName,
+				ScpName:      dropped.ScpName,
+				LoginName:    dropped.LoginName,
+				TeamName:     dropped.TeamName,
+				Delta:        -scored[i].Points,
+			},
+			OccurredOn: time.Now(),
+		})
+
+		logger.Debug("dropped co-author score reversed", zap.String("coAuthor", dropped.LoginName),
+			zap.Float64("oldPoints", scored[i].Points), zap.Any("ScoringMessage", msg))
+	}
+	return
+}
+
+func publishScoreChanged(participant *types.ParticipantStruct, msg *types.ScoringMessage, breakdown *types.ScoreBreakdown, now time.Time) {
+	scoreEvents.PublishScoreChanged(context.Background(), events.ScoreChanged{
+		ScoreDelta: types.ScoreDelta{
+			CampaignName: participant.CampaignName,
+			ScpName:      participant.ScpName,
+			LoginName:    participant.LoginName,
+			TeamName:     participant.TeamName,
+			Delta:        breakdown.Delta,
+		},
+		Participant: participant,
+		Message:     msg,
+		Breakdown:   breakdown,
+		OccurredOn:  now,
+	})
+}
+
+// scoringSecretsFromConfig reads the eventSource=secret pairs of SCORING_SECRETS.
+func scoringSecretsFromConfig(cfg *config.Config) map[string]string {
+	return eventSourceSecrets("SCORING_SECRETS", cfg.ScoringSecrets)
+}
+
+// eventSourceSecrets reads the comma separated eventSource=secret pairs of the setting, keyed by the lower case
+// eventSource. A pair without both is logged and left out.
+func eventSourceSecrets(setting, value string) map[string]string {
+	secrets := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		eventSource := strings.TrimSpace(parts[0])
+		if len(parts) < 2 || eventSource == "" || strings.TrimSpace(parts[1]) == "" {
+			logger.Error("invalid "+setting+" entry, expected eventSource=secret", zap.String("eventSource", eventSource))
+			continue
+		}
+		secrets[strings.ToLower(eventSource)] = strings.TrimSpace(parts[1])
+	}
+	return secrets
+}
+
+// pushScoringMessage scores a scoring message pushed by its source, rather than read from the scanner logs. It is only
+// scored when signed with the secret of its eventSource, at a time close to now.
+func pushScoringMessage(scoringSecrets map[string]string) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		req := c.Request()
+		signature, timestamp := req.Header.Get(headerSignature), req.Header.Get(headerSignatureTimestamp)
+		if signature == "" || timestamp == "" {
+			return newApiError(http.StatusUnauthorized, "scoring message is not signed")
+		}
+		now := time.Now()
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return newApiError(http.StatusUnauthorized, fmt.Sprintf("invalid signature timestamp: %s", timestamp))
+		}
+		if age := now.Sub(time.Unix(seconds, 0)); age > scoringSignatureTolerance || age < -scoringSignatureTolerance {
+			return newApiError(http.StatusUnauthorized, "scoring message signature is stale")
+		}
+
+		var payload []byte
+		payload, err = io.ReadAll(req.Body)
+		if err != nil {
+			return
+		}
+		var msg types.ScoringMessage
+		msg, err = poll.DecodeScoringMessage(payload)
+		if err != nil {
+			return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid scoring message: %s", err.Error()))
+		}
+
+		// an unknown eventSource fails the same way as a bad signature, so the sources can not be guessed
+		secret, ok := scoringSecrets[strings.ToLower(msg.EventSource)]
+		if !ok || !hmac.Equal([]byte(signature), []byte(webhook.Sign(secret, append([]byte(timestamp+"."), payload...)))) {
+			logger.Info("scoring message signature invalid", zap.String("eventSource", msg.EventSource))
+			return newApiError(http.StatusUnauthorized, "scoring message signature is invalid")
+		}
+
+		if err = processScoringMessage(scoreDB, now, &msg); err != nil {
+			return
+		}
+		return c.NoContent(http.StatusAccepted)
+	}
+}
+
+// beginGrpcServer serves the gRPC scoring service on GRPC_PORT with TLS, until quit is closed. The certificate of the
+// HTTP server is used unless the service has one of its own, and an error is returned when there is neither.
+func beginGrpcServer(cfg *config.Config) (quit chan bool, err error) {
+	certFile, keyFile := cfg.GrpcTlsCertFile, cfg.GrpcTlsKeyFile
+	if certFile == "" && keyFile == "" {
+		certFile, keyFile = cfg.TlsCertFile, cfg.TlsKeyFile
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("GRPC_PORT is set without a certificate, set GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE")
+	}
+
+	service := grpcapi.New(logger, scoreGrpcMessage, scoringTokenAuthorizer(eventSourceSecrets("GRPC_TOKENS", cfg.GrpcTokens)), scoreHub)
+	var server *http.Server
+	server, err = service.HTTPServer(fmt.Sprintf(":%d", cfg.GrpcPort), certFile, keyFile)
+	if err != nil {
+		return
+	}
+
+	go func() {
+		logger.Info("grpc server starting", zap.Int("port", cfg.GrpcPort), zap.String("certFile", certFile))
+		if err := server.ListenAndServeTLS("", ""); err != http.ErrServerClosed {
+			logger.Error("grpc server error", zap.Error(err))
+		}
+	}()
+
+	quit = make(chan bool)
+	go func() {
+		<-quit
+		_ = server.Close()
+	}()
+	return
+}
+
+// scoreGrpcMessage scores a scoring message submitted over gRPC, unless in maintenance, as when pushed over HTTP.
+func scoreGrpcMessage(ctx context.Context, msg *types.ScoringMessage) error {
+	if state := maintenance.State(); state.Enabled {
+		return grpcapi.Errorf(grpcapi.Unavailable, "%s", state.Message)
+	}
+	return processScoringMessage(scoreDB, time.Now(), msg)
+}
+
+// scoringTokenAuthorizer authorizes the scoring messages of an eventSource sent with its GRPC_TOKENS token.
+func scoringTokenAuthorizer(tokens map[string]string) grpcapi.Authorizer {
+	return func(eventSource, token string) bool {
+		expected, ok := tokens[strings.ToLower(eventSource)]
+		return ok && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+	}
+}
+
+// scoreDryRun scores a scoring message the way it would be scored when read from the scanner logs, without storing
+// anything, so an integrator can check a payload before sending it for real.
+func scoreDryRun(c echo.Context) (err error) {
+	var payload []byte
+	payload, err = io.ReadAll(c.Request().Body)
+	if err != nil {
+		return
+	}
+
+	var msg types.ScoringMessage
+	msg, err = poll.DecodeScoringMessage(payload)
+	if err != nil {
+		return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid scoring message: %s", err.Error()))
+	}
+	// force triggerUser to lower case to match database values
+	msg.TriggerUser = strings.ToLower(msg.TriggerUser)
+
+	var participantsToScore []types.ParticipantStruct
+	participantsToScore, err = validScore(&msg, time.Now())
+	if err != nil {
+		return
+	}
+
+	var coAuthors map[string][]types.ParticipantStruct
+	if len(msg.CoAuthors) > 0 {
+		coAuthors = coAuthorsToScore(&msg, participantsToScore, time.Now())
+	}
+
+	dryRun := types.ScoringDryRun{Message: msg, Participants: []types.DryRunParticipantScore{}}
+	for i := range participantsToScore {
+		participant := &participantsToScore[i]
+		points, breakdown := scorePoints(&msg, participant.CampaignName)
+		points = applyDuplicates(postgresDB, participant, &msg, points, breakdown)
+		campaignCoAuthors := coAuthors[participant.CampaignName]
+		share := applyCoAuthors(participant.CampaignName, len(campaignCoAuthors), points, breakdown)
+		shareBreakdown := *breakdown
+		points = applyCaps(postgresDB, participant, &msg, share, breakdown, time.Now())
+		breakdown.PriorPoints = postgresDB.SelectPriorScore(c.Request().Context(), participant, &msg)
+		breakdown.Delta = points - breakdown.PriorPoints
+
+		dryRun.Participants = append(dryRun.Participants, types.DryRunParticipantScore{
+			CampaignName: participant.CampaignName,
+			ScpName:      participant.ScpName,
+			LoginName:    participant.LoginName,
+			TeamName:     participant.TeamName,
+			Points:       points,
+			Breakdown:    breakdown,
+		})
+		kept := make(map[string]bool, len(campaignCoAuthors))
+		for _, coAuthor := range campaignCoAuthors {
+			kept[coAuthor.LoginName] = true
+			coAuthorBreakdown := shareBreakdown
+			coAuthorPoints := applyCaps(postgresDB, &coAuthor, &msg, share, &coAuthorBreakdown, time.Now())
+			coAuthorBreakdown.PriorPoints = postgresDB.SelectCoAuthorPriorScore(c.Request().Context(), &coAuthor, &msg)
+			coAuthorBreakdown.Delta = coAuthorPoints - coAuthorBreakdown.PriorPoints
+
+			dryRun.Participants = append(dryRun.Participants, types.DryRunParticipantScore{
+				CampaignName: coAuthor.CampaignName,
+				ScpName:      coAuthor.ScpName,
+				LoginName:    coAuthor.LoginName,
+				TeamName:     coAuthor.TeamName,
+				Points:       coAuthorPoints,
+				Breakdown:    &coAuthorBreakdown,
+			})
+		}
+
+		// the co-authors the pull request no longer has would lose their shares
+		var scored []db.CoAuthorScoreRow
+		scored, err = postgresDB.SelectCoAuthorScores(c.Request().Context(), participant, &msg)
+		if err != nil {
+			return
+		}
+		for _, dropped := range scored {
+			if kept[dropped.Participant.LoginName] {
+				continue
+			}
+			dryRun.Participants = append(dryRun.Participants, types.DryRunParticipantScore{
+				CampaignName: dropped.Participant.CampaignName,
+				ScpName:      dropped.Participant.ScpName,
+				LoginName:    dropped.Participant.LoginName,
+				TeamName:     dropped.Participant.TeamName,
+				Breakdown:    &types.ScoreBreakdown{PriorPoints: dropped.Points, Delta: -dropped.Points},
+			})
+		}
+	}
+
+	return c.JSON(http.StatusOK, dryRun)
+}
+
+// notifySlack posts to the Slack webhook of the campaign when the participant reaches the point threshold of the
+// campaign, or takes the lead. Slack being down only costs the channel the post, which is logged and not retried; the
+// lead is left out when the leaders can not be read.
+func notifySlack(participant *types.ParticipantStruct, delta float64) {
+	if delta <= 0 {
+		return
+	}
+
+	config, err := postgresDB.SelectSlackNotification(context.Background(), participant.CampaignName)
+	if err == sql.ErrNoRows {
+		return
+	} else if err != nil {
+		logger.Error("slack notification config error", zap.String("campaignName", participant.CampaignName), zap.Error(err))
+		return
+	}
+
+	newScore := participant.Score
+	oldScore := newScore - delta
+	var messages []string
+	if config.PointThreshold > 0 && oldScore < float64(config.PointThreshold) && newScore >= float64(config.PointThreshold) {
+		messages = append(messages, fmt.Sprintf("%s reached %d points in %s, and now has %g points",
+			participant.LoginName, config.PointThreshold, participant.CampaignName, participant.Score))
+	}
+	if config.NotifyLead {
+		var leaders []types.ParticipantStruct
+		leaders, err = postgresDB.SelectTopParticipants(context.Background(), participant.CampaignName, 2)
+		if err != nil {
+			logger.Error("slack notification leaders error", zap.String("campaignName", participant.CampaignName), zap.Error(err))
+		} else if len(leaders) > 0 && leaders[0].ID == participant.ID && (len(leaders) == 1 || oldScore <= leaders[1].Score) {
+			messages = append(messages, fmt.Sprintf("%s took the lead in %s with %g points",
+				participant.LoginName, participant.CampaignName, participant.Score))
+		}
+	}
+
+	for _, message := range messages {
+		if err = slackNotifier.Post(config.WebhookUrl, message); err != nil {
+			logger.Error("slack notification error", zap.String("campaignName", participant.CampaignName), zap.String("message", message), zap.Error(err))
+		}
+	}
+}
+
+// getScoringEvent returns the scoring event of a pull request, including the breakdown of how its points were computed.
+func getScoringEvent(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	scpName := c.Param(ParamScpName)
+	repoOwner := c.Param(ParamRepoOwner)
+	repoName := c.Param(ParamRepoName)
+
+	var pullRequest int
+	pullRequest, err = strconv.Atoi(c.Param(ParamPullRequest))
+	if err != nil {
+		return newApiError(http.StatusBadRequest, err.Error())
+	}
+
+	var event *types.ScoringEventStruct
+	event, err = postgresDB.SelectScoringEvent(c.Request().Context(), campaignName, scpName, repoOwner, repoName, pullRequest)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return newApiError(http.StatusNotFound, fmt.Sprintf("no scoring event: campaignName: %s, scpName: %s, repoOwner: %s, repoName: %s, pullRequest: %d",
+				campaignName, scpName, repoOwner, repoName, pullRequest))
+		}
+		return
+	}
+
+	return c.JSON(http.StatusOK, event)
+}
+
+// voidScoringEvent corrects a scoring event scored for the wrong participant. Its points are taken off the participant,
+// and the event is kept, marked voided, rather than deleted.
+func voidScoringEvent(c echo.Context) (err error) {
+	eventId := c.Param(ParamScoreEventId)
+
+	var event *types.ScoringEventStruct
+	var teamName string
+	event, teamName, err = postgresDB.VoidScoringEvent(c.Request().Context(), eventId, time.Now())
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no scoring event to void: scoreEventId: %s", eventId))
+	} else if err != nil {
+		return
+	}
+	publishScoringEventVoided(c.Request().Context(), event, teamName)
+
+	return c.JSON(http.StatusOK, event)
+}
+
+// publishScoringEventVoided sends the points of the voided event, taken off its participant.
+func publishScoringEventVoided(ctx context.Context, event *types.ScoringEventStruct, teamName string) {
+	logger.Info("scoring event voided", zap.Any("event", event))
+
+	scoreEvents.PublishScoreChanged(ctx, events.ScoreChanged{
+		ScoreDelta: types.ScoreDelta{
+			CampaignName: event.CampaignName,
+			ScpName:      event.ScpName,
+			LoginName:    event.UserName,
+			TeamName:     teamName,
+			Delta:        -event.Points,
+		},
+		OccurredOn: time.Now(),
+	})
+	for _, coAuthor := range event.CoAuthors {
+		scoreEvents.PublishScoreChanged(ctx, events.ScoreChanged{
+			ScoreDelta: types.ScoreDelta{
+				CampaignName: event.CampaignName,
+				ScpName:      event.ScpName,
+				LoginName:    coAuthor.LoginName,
+				TeamName:     coAuthor.TeamName,
+				Delta:        -coAuthor.Points,
+			},
+			OccurredOn: time.Now(),
+		})
+	}
+}
+
+// mimeNdjson is newline delimited JSON, a JSON value on each line
+const mimeNdjson = "application/x-ndjson"
+
+// exportFlushEvents is how many exported events are written between flushes, so a large export reaches the client as
+// it is read, without a flush for every line.
+const exportFlushEvents = 100
+
+// exportScoringEvents streams every scoring event of the campaign, as newline delimited JSON. Each event is written as
+// it is read from the database, so a slow client slows the read, rather than the events piling up in memory. An error
+// after the first event ends the export early, and is logged, as the status has already been sent.
+func exportScoringEvents(c echo.Context) (err error) {
+	campaignName := c.QueryParam(ParamCampaignName)
+	if campaignName == "" {
+		return newApiError(http.StatusBadRequest, "missing campaignName")
+	}
+	if _, err = postgresDB.GetCampaign(c.Request().Context(), campaignName); err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, mimeNdjson)
+	res.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(res)
+	exported := 0
+	err = postgresDB.ExportScoringEvents(c.Request().Context(), campaignName, func(event *types.ScoringEventStruct) (err error) {
+		if err = encoder.Encode(event); err != nil {
+			return
+		}
+		exported++
+		if exported%exportFlushEvents == 0 {
+			res.Flush()
+		}
+		return
+	})
+	if err != nil {
+		logger.Error("scoring event export ended early", zap.String("campaignName", campaignName),
+			zap.Int("exported", exported), zap.Error(err))
+		return
+	}
+	res.Flush()
+
+	logger.Info("scoring events exported", zap.String("campaignName", campaignName), zap.Int("exported", exported))
+	return
+}
+
+// getScoringEventArchives lists the campaigns with archived scoring events, restored ones included.
+func getScoringEventArchives(c echo.Context) (err error) {
+	archives, err := postgresDB.SelectScoringEventArchives(c.Request().Context())
+	if err != nil {
+		return
+	}
+	if archives == nil {
+		archives = []types.ScoringEventArchive{}
+	}
+	return c.JSON(http.StatusOK, archives)
+}
+
+// archiveScoringEvents moves the scoring events of an ended campaign into its archive now, instead of waiting for the
+// archival job. The scores, teams and final leaderboard of the campaign are kept, while the reports reading its events
+// find none until they are restored. The events are moved by a job, answered with 202, whose result is the archive.
+func archiveScoringEvents(c echo.Context) (err error) {
+	campaignName := c.QueryParam(ParamCampaignName)
+	if campaignName == "" {
+		return newApiError(http.StatusBadRequest, "missing campaignName")
+	}
+	campaign, err := postgresDB.GetCampaign(c.Request().Context(), campaignName)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+	now := time.Now()
+	if now.Before(campaign.EndOn) {
+		return newApiError(http.StatusConflict, fmt.Sprintf("campaign has not ended: campaignName: %s", campaignName))
+	}
+
+	archive, err := selectScoringEventArchive(c.Request().Context(), campaignName)
+	if err != nil {
+		return
+	} else if archive != nil && archive.RestoredOn == nil {
+		return newApiError(http.StatusConflict, fmt.Sprintf("scoring events already archived: campaignName: %s", campaignName))
+	}
+
+	return startJob(c, jobKindScoringEventArchive, 0, func(ctx context.Context, progress func(done, total int64)) (interface{}, error) {
+		archive, err := postgresDB.ArchiveScoringEvents(ctx, campaignName, now)
+		if err == sql.ErrNoRows {
+			return nil, newApiError(http.StatusConflict, fmt.Sprintf("scoring events already archived: campaignName: %s", campaignName))
+		} else if err != nil {
+			return nil, err
+		}
+		progress(archive.EventCount, archive.EventCount)
+		logger.Info("scoring events archived", zap.String("campaignName", campaignName), zap.Int64("events", archive.EventCount))
+		return archive, nil
+	})
+}
+
+// selectScoringEventArchive finds the archive of the campaign, restored or not, or nil when its events were never
+// archived.
+func selectScoringEventArchive(ctx context.Context, campaignName string) (archive *types.ScoringEventArchive, err error) {
+	archives, err := postgresDB.SelectScoringEventArchives(ctx)
+	if err != nil {
+		return
+	}
+	for i := range archives {
+		if archives[i].CampaignName == campaignName {
+			return &archives[i], nil
+		}
+	}
+	return
+}
+
+// restoreScoringEvents moves the archived scoring events of a campaign back, for the reports reading them. The
+// campaign is not archived again by the archival job, only when asked. The events are moved back by a job, answered
+// with 202, whose result is the archive.
+func restoreScoringEvents(c echo.Context) (err error) {
+	campaignName := c.QueryParam(ParamCampaignName)
+	if campaignName == "" {
+		return newApiError(http.StatusBadRequest, "missing campaignName")
+	}
+
+	archive, err := selectScoringEventArchive(c.Request().Context(), campaignName)
+	if err != nil {
+		return
+	} else if archive == nil || archive.RestoredOn != nil {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no scoring event archive: campaignName: %s", campaignName))
+	}
+
+	return startJob(c, jobKindScoringEventRestore, archive.EventCount, func(ctx context.Context, progress func(done, total int64)) (interface{}, error) {
+		archive, err := postgresDB.RestoreScoringEvents(ctx, campaignName, time.Now())
+		if err == sql.ErrNoRows {
+			return nil, newApiError(http.StatusNotFound, fmt.Sprintf("no scoring event archive: campaignName: %s", campaignName))
+		} else if err != nil {
+			return nil, err
+		}
+		progress(archive.EventCount, archive.EventCount)
+		logger.Info("scoring events restored", zap.String("campaignName", campaignName), zap.Int64("events", archive.EventCount))
+		return archive, nil
+	})
+}
+
+// importMaxLineBytes is the longest line an import reads, well over the size of an exported event with its breakdown
+const importMaxLineBytes = 1024 * 1024
+
+// importScoringEvents reads scoring events into the campaign, as newline delimited JSON in the format of the export.
+// Every event is checked before any is inserted, so an import with an event that is not valid imports nothing, and the
+// error lists the fields of each by its line number. An event of a pull request the campaign has already scored is
+// skipped, so an import can be run again. The score of each participant with an imported event is recomputed from
+// their events, replacing a score set by hand. The imported events are not announced, as they were scored long ago.
+// The events are checked during the request, then inserted by a job, answered with 202, whose result is the import.
+// A campaign with archived events is refused until they are restored, as its scores would lose the archived points.
+func importScoringEvents(c echo.Context) (err error) {
+	campaignName := c.QueryParam(ParamCampaignName)
+	if campaignName == "" {
+		return newApiError(http.StatusBadRequest, "missing campaignName")
+	}
+	if _, err = postgresDB.GetCampaign(c.Request().Context(), campaignName); err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+	archive, err := selectScoringEventArchive(c.Request().Context(), campaignName)
+	if err != nil {
+		return
+	} else if archive != nil && archive.RestoredOn == nil {
+		return newApiError(http.StatusConflict, fmt.Sprintf("scoring events archived, restore them first: campaignName: %s", campaignName))
+	}
+	var scps []types.SourceControlProviderStruct
+	if scps, err = postgresDB.GetSourceControlProviders(c.Request().Context()); err != nil {
+		return
+	}
+	scpNames := map[string]bool{}
+	for _, scp := range scps {
+		scpNames[scp.SCPName] = true
+	}
+
+	var events []types.ScoringEventStruct
+	var fields []fieldError
+	// the line of each pull request, as a statement can not insert one twice
+	pullRequestLines := map[string]int{}
+	scanner := bufio.NewScanner(c.Request().Body)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), importMaxLineBytes)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var event types.ScoringEventStruct
+		if jsonErr := json.Unmarshal(text, &event); jsonErr != nil {
+			fields = append(fields, fieldError{Field: fmt.Sprintf("[%d]", line), Message: "must be a scoring event: " + jsonErr.Error()})
+			continue
+		}
+		// a new id is given to each event, and co-author shares are not imported
+		event.ID = ""
+		event.CoAuthors = nil
+		if event.CampaignName == "" {
+			event.CampaignName = campaignName
+		}
+		fields = append(fields, validateImportedEvent(line, campaignName, scpNames, &event)...)
+
+		pullRequest := fmt.Sprintf("%s/%s/%s/%d", event.ScpName, event.RepoOwner, event.RepoName, event.PullRequest)
+		if first, ok := pullRequestLines[pullRequest]; ok {
+			fields = append(fields, fieldError{Field: fmt.Sprintf("[%d].pullRequestId", line), Message: fmt.Sprintf("repeats the pull request of line %d", first)})
+		} else {
+			pullRequestLines[pullRequest] = line
+		}
+		events = append(events, event)
+	}
+	if err = scanner.Err(); err == bufio.ErrTooLong {
+		return newApiError(http.StatusBadRequest, fmt.Sprintf("line %d is longer than %d bytes", line+1, importMaxLineBytes))
+	} else if err != nil {
+		return
+	}
+	if len(fields) > 0 {
+		apiErr := newApiError(http.StatusUnprocessableEntity, "scoring events are not valid")
+		apiErr.Details = fields
+		logger.Info("scoring events are not valid", zap.String("campaignName", campaignName), zap.Any("fields", fields))
+		return apiErr
+	}
+	if len(events) == 0 {
+		return newApiError(http.StatusBadRequest, "no scoring events")
+	}
+
+	return startJob(c, jobKindScoringEventImport, int64(len(events)), func(ctx context.Context, progress func(done, total int64)) (interface{}, error) {
+		result := types.ScoringEventImport{CampaignName: campaignName, Read: len(events)}
+		var err error
+		result.Imported, result.Recomputed, err = postgresDB.ImportScoringEvents(ctx, events)
+		if err == db.ErrCampaignArchived {
+			return nil, newApiError(http.StatusConflict, fmt.Sprintf("scoring events archived, restore them first: campaignName: %s", campaignName))
+		} else if err != nil {
+			return nil, err
+		}
+		result.Skipped = int64(len(events)) - result.Imported
+		// the events are imported in one transaction, so are all done at once
+		progress(int64(len(events)), int64(len(events)))
+		logger.Info("scoring events imported", zap.Any("result", result))
+		return result, nil
+	})
+}
+
+// validateImportedEvent lists the fields of the event on the line that are not valid.
+func validateImportedEvent(line int, campaignName string, scpNames map[string]bool, event *types.ScoringEventStruct) (fields []fieldError) {
+	invalid := func(field, message string) {
+		fields = append(fields, fieldError{Field: fmt.Sprintf("[%d].%s", line, field), Message: message})
+	}
+	if event.CampaignName != campaignName {
+		invalid("campaignName", "must be "+campaignName)
+	}
+	if event.ScpName == "" {
+		invalid("scpName", "is required")
+	} else if !scpNames[event.ScpName] {
+		invalid("scpName", "must be a source control provider")
+	}
+	if event.RepoOwner == "" {
+		invalid("repositoryOwner", "is required")
+	}
+	if event.RepoName == "" {
+		invalid("repositoryName", "is required")
+	}
+	if event.PullRequest <= 0 {
+		invalid("pullRequestId", "must be at least 1")
+	}
+	if event.UserName == "" {
+		invalid("username", "is required")
+	}
+	if event.VoidedOn != nil && event.ScoredOn != nil && event.VoidedOn.Before(*event.ScoredOn) {
+		invalid("voidedOn", "must be after scoredOn")
+	}
+	return
+}
+
+// getScoringFlags lists the review queue, of the scoring events flagged as suspicious and not yet approved or voided.
+func getScoringFlags(c echo.Context) (err error) {
+	flags, err := postgresDB.SelectScoringFlags(c.Request().Context())
+	if err != nil {
+		return
+	}
+	return listJSON(c, flags)
+}
+
+// approveScoringFlag takes the flag out of the review queue, leaving the points of its scoring event as they are.
+func approveScoringFlag(c echo.Context) (err error) {
+	flagId := c.Param(ParamScoringFlagId)
+
+	flag, err := postgresDB.ResolveScoringFlag(c.Request().Context(), flagId, types.ScoringFlagApproved, time.Now())
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no scoring flag to resolve: scoringFlagId: %s", flagId))
+	} else if err != nil {
+		return
+	}
+	logger.Info("scoring flag approved", zap.Any("flag", flag))
+
+	return c.JSON(http.StatusOK, flag)
+}
+
+// voidScoringFlag takes the flag out of the review queue, and voids its scoring event, as voidScoringEvent does.
+func voidScoringFlag(c echo.Context) (err error) {
+	flagId := c.Param(ParamScoringFlagId)
+
+	flag, err := postgresDB.ResolveScoringFlag(c.Request().Context(), flagId, types.ScoringFlagVoided, time.Now())
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no scoring flag to resolve: scoringFlagId: %s", flagId))
+	} else if err != nil {
+		return
+	}
+
+	event, teamName, err := postgresDB.VoidScoringEvent(c.Request().Context(), flag.Event.ID, time.Now())
+	if err == sql.ErrNoRows {
+		// voided since it was flagged, so its points are already taken off
+		err = nil
+	} else if err != nil {
+		logger.Error("scoring flag resolved without voiding its event", zap.Any("flag", flag), zap.Error(err))
+		return
+	} else {
+		publishScoringEventVoided(c.Request().Context(), event, teamName)
+		flag.Event = *event
+	}
+
+	return c.JSON(http.StatusOK, flag)
+}
+
+// notifyPointsAwarded tells the participant in their inbox how the pull request changed their score, unless it did not.
+// The score is already stored, so a notification that can not be added is logged and the participant goes without it.
+func notifyPointsAwarded(participant *types.ParticipantStruct, msg *types.ScoringMessage, breakdown *types.ScoreBreakdown, now time.Time) {
+	if breakdown.Delta == 0 {
+		return
+	}
+
+	notification := types.NotificationStruct{
+		ParticipantId: participant.ID,
+		Kind:          types.NotificationKindPointsAwarded,
+		Message: fmt.Sprintf("%+g points for pull request %s/%s #%d, score for the pull request is now %g",
+			breakdown.Delta, msg.RepoOwner, msg.RepoName, msg.PullRequest, breakdown.Points),
+		CreatedOn: now,
+	}
+	if err := postgresDB.InsertNotification(context.Background(), &notification); err != nil {
+		logger.Error("notification error", zap.Any("notification", notification), zap.Error(err))
+	}
+}
+
+// number of bugs a participant must fix to earn types.AchievementTenBugs
+const achievementBugsFixed = 10
+
+// number of highest scoring participants of an ended campaign who earn types.AchievementTopThree
+const achievementTopFinishers = 3
+
+// evaluateAchievements awards the achievements earned by a score change. An achievement missed because the database
+// failed is logged, and awarded by the next score change that earns it, as awarding one again changes nothing.
+func evaluateAchievements(participant *types.ParticipantStruct, delta float64, now time.Time) {
+	if delta <= 0 {
+		return
+	}
+
+	awardAchievement(participant, types.AchievementFirstFix, now)
+
+	bugsFixed, err := postgresDB.SelectBugsFixed(context.Background(), participant)
+	if err != nil {
+		logger.Error("achievement bugs fixed error", zap.Any("participant", participant), zap.Error(err))
+		return
+	}
+	if bugsFixed >= achievementBugsFixed {
+		awardAchievement(participant, types.AchievementTenBugs, now)
+	}
+}
+
+// awardTopFinishers awards the highest scoring participants of an ended campaign. Participants without points do not
+// qualify.
+func awardTopFinishers(ctx context.Context, campaignName string, now time.Time) {
+	leaders, err := postgresDB.SelectTopParticipants(ctx, campaignName, achievementTopFinishers)
+	if err != nil {
+		logger.Error("achievement top finishers error", zap.String("campaignName", campaignName), zap.Error(err))
+		return
+	}
+	for i := range leaders {
+		if leaders[i].Score > 0 {
+			awardAchievement(&leaders[i], types.AchievementTopThree, now)
+		}
+	}
+}
+
+// awardAchievement records the achievement, and tells the participant when it is new to them.
+func awardAchievement(participant *types.ParticipantStruct, kind string, now time.Time) {
+	awarded, err := postgresDB.InsertAchievement(context.Background(), participant.ID, kind, now)
+	if err != nil {
+		logger.Error("achievement error", zap.Any("participant", participant), zap.String("kind", kind), zap.Error(err))
+		return
+	}
+	if !awarded {
+		return
+	}
+
+	notification := types.NotificationStruct{
+		ParticipantId: participant.ID,
+		Kind:          types.NotificationKindAchievement,
+		Message:       fmt.Sprintf("achievement earned in %s: %s", participant.CampaignName, kind),
+		CreatedOn:     now,
+	}
+	if err = postgresDB.InsertNotification(context.Background(), &notification); err != nil {
+		logger.Error("notification error", zap.Any("notification", notification), zap.Error(err))
+	}
+}
+
+func getAchievements(c echo.Context) (err error) {
+	var achievements []types.AchievementStruct
+	achievements, err = postgresDB.SelectAchievements(c.Request().Context(), c.Param(ParamCampaignName), c.Param(ParamScpName), c.Param(ParamLoginName))
+	if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, achievements)
+}
+
+// getParticipantScoreAt rebuilds the score the participant had at the time given by at, or now without it, to settle
+// disputes about the standings at a deadline.
+func getParticipantScoreAt(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	scpName := c.Param(ParamScpName)
+	loginName := c.Param(ParamLoginName)
+
+	at := time.Now()
+	if atParam := c.QueryParam("at"); atParam != "" {
+		at, err = time.Parse(time.RFC3339, atParam)
+		if err != nil {
+			return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid at: %s", atParam))
+		}
+	}
+
+	var score *types.ParticipantScoreAt
+	score, err = postgresDB.SelectParticipantScoreAt(c.Request().Context(), campaignName, scpName, loginName, at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no participant: campaignName: %s, scpName: %s, loginName: %s",
+			campaignName, scpName, loginName))
+	}
+	if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, score)
+}
+
+func getAchievementStats(c echo.Context) (err error) {
+	var stats []types.AchievementStat
+	stats, err = postgresDB.SelectAchievementStats(c.Request().Context(), c.Param(ParamCampaignName))
+	if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
+
+func getNotifications(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	scpName := c.Param(ParamScpName)
+	loginName := c.Param(ParamLoginName)
+	unreadOnly := c.QueryParam("unread") == "true"
+
+	var notifications []types.NotificationStruct
+	notifications, err = postgresDB.SelectNotifications(c.Request().Context(), campaignName, scpName, loginName, unreadOnly)
+	if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, notifications)
+}
+
+// readNotifications marks one notification as read, or all of them when no notificationId is given.
+func readNotifications(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	scpName := c.Param(ParamScpName)
+	loginName := c.Param(ParamLoginName)
+	notificationId := c.Param(ParamNotificationId)
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.UpdateNotificationsRead(c.Request().Context(), campaignName, scpName, loginName, notificationId, time.Now())
+	if err != nil {
+		return
+	}
+	if notificationId != "" && rowsAffected == 0 {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no unread notification: campaignName: %s, scpName: %s, loginName: %s, notificationId: %s",
+			campaignName, scpName, loginName, notificationId))
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+const defaultLeaderboardLimit = 100
+
+// pseudonymPrefix starts every pseudonym, so a pseudonym is not taken for a login name.
+const pseudonymPrefix = "participant-"
+
+// pseudonym stands in for the login name of a participant on the public leaderboards of an anonymized campaign. It is
+// the same on every read, but cannot be turned back into the login name without the salt of the campaign.
+func pseudonym(privacy *types.CampaignPrivacyStruct, scpName, loginName string) string {
+	mac := hmac.New(sha256.New, []byte(privacy.Salt))
+	mac.Write([]byte(scpName + "/" + loginName))
+	return pseudonymPrefix + hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// selectAnonymized reads the privacy setting of the campaign, and is nil unless the campaign shows pseudonyms.
+func selectAnonymized(ctx context.Context, campaignName string) (privacy *types.CampaignPrivacyStruct, err error) {
+	privacy, err = postgresDB.SelectCampaignPrivacy(ctx, campaignName)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return
+	}
+	if !privacy.Anonymize {
+		privacy = nil
+	}
+	return
+}
+
+// anonymizeEntries replaces the login name of each leaderboard entry with its pseudonym, leaving out the display name
+// and avatar that would give the participant away.
+func anonymizeEntries(privacy *types.CampaignPrivacyStruct, entries []types.LeaderboardEntry) {
+	for i := range entries {
+		entries[i].LoginName = pseudonym(privacy, entries[i].ScpName, entries[i].LoginName)
+		entries[i].DisplayName = ""
+		entries[i].AvatarUrl = ""
+	}
+}
+
+// getLeaderboard reads the ranked participants of the campaign, with equal scores ordered by the tie breaker of the
+// campaign. The ranks are precomputed, so they can lag a score change by up to leaderboardRefreshInterval. The optional limit query parameter sets how many participants are read.
+func getLeaderboard(c echo.Context) (err error) {
+	logTelemetry(c)
+
+	return sendLeaderboard(c, 0)
+}
+
+// getPublicLeaderboard is the leaderboard of the public API, which sends at most defaultLeaderboardLimit participants,
+// so an embedding site cannot read the whole of a large campaign on each page view.
+func getPublicLeaderboard(c echo.Context) (err error) {
+	logTelemetry(c)
+
+	// the campaigns of a tenant are not public, so are reported as missing
+	campaignName := c.Param(ParamCampaignName)
+	var tenantName string
+	tenantName, err = postgresDB.SelectCampaignTenant(c.Request().Context(), campaignName)
+	if err == sql.ErrNoRows || (err == nil && tenantName != "") {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+
+	return sendLeaderboard(c, defaultLeaderboardLimit)
+}
+
+// sendLeaderboard sends the leaderboard of the campaign, with no more participants than the limit query parameter,
+// which may not be above maxLimit. Zero maxLimit is no maximum.
+func sendLeaderboard(c echo.Context, maxLimit int) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	limit := defaultLeaderboardLimit
+	if limitParam := c.QueryParam("limit"); limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || (maxLimit > 0 && limit > maxLimit) {
+			return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid limit: %s", limitParam))
+		}
+	}
+
+	var leaderboard *types.Leaderboard
+	leaderboard, err = postgresDB.SelectLeaderboard(c.Request().Context(), campaignName, limit)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+
+	var privacy *types.CampaignPrivacyStruct
+	if privacy, err = selectAnonymized(c.Request().Context(), campaignName); err != nil {
+		return
+	} else if privacy != nil {
+		anonymizeEntries(privacy, leaderboard.Participants)
+	}
+
+	return c.JSON(http.StatusOK, leaderboard)
+}
+
+// finalizeLeaderboard ranks the campaign from its current scores, and keeps those ranks as its official final results.
+// finalized is false when the campaign was finalized before, or there is no such campaign.
+func finalizeLeaderboard(ctx context.Context, campaignName string, now time.Time) (finalized bool, err error) {
+	if err = postgresDB.RefreshLeaderboard(ctx); err != nil {
+		return
+	}
+	finalized, err = postgresDB.InsertLeaderboardSnapshot(ctx, campaignName, now)
+	if finalized {
+		logger.Info("leaderboard finalized", zap.String("campaignName", campaignName))
+	}
+	return
+}
+
+// finalizeCampaignLeaderboard finalizes the leaderboard of the campaign on demand, rather than waiting for the campaign
+// to end. A campaign is only finalized once.
+func finalizeCampaignLeaderboard(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	var finalized bool
+	finalized, err = finalizeLeaderboard(c.Request().Context(), campaignName, time.Now())
+	if err != nil {
+		return
+	}
+
+	var snapshot *types.LeaderboardSnapshot
+	snapshot, err = postgresDB.SelectLeaderboardSnapshot(c.Request().Context(), campaignName)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+	if !finalized {
+		return newApiError(http.StatusConflict, fmt.Sprintf("campaign %s was finalized at %s", campaignName, snapshot.FinalizedOn.Format(time.RFC3339)))
+	}
+
+	return c.JSON(http.StatusCreated, snapshot)
+}
+
+// getFinalLeaderboard reads the official final results of the campaign, which later corrections of scores do not
+// change.
+func getFinalLeaderboard(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	var snapshot *types.LeaderboardSnapshot
+	snapshot, err = postgresDB.SelectLeaderboardSnapshot(c.Request().Context(), campaignName)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no final leaderboard: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+
+	var privacy *types.CampaignPrivacyStruct
+	if privacy, err = selectAnonymized(c.Request().Context(), campaignName); err != nil {
+		return
+	} else if privacy != nil {
+		anonymizeEntries(privacy, snapshot.Participants)
+	}
+
+	return c.JSON(http.StatusOK, snapshot)
+}
+
+// getTeamLeaderboard ranks the members of one team by score, with the totals of the team.
+func getTeamLeaderboard(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	teamName := c.Param(ParamTeamName)
+
+	var leaderboard *types.TeamLeaderboard
+	leaderboard, err = postgresDB.SelectTeamLeaderboard(c.Request().Context(), campaignName, teamName)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no team: campaignName: %s, name: %s", campaignName, teamName))
+	} else if err != nil {
+		return
+	}
+
+	var privacy *types.CampaignPrivacyStruct
+	if privacy, err = selectAnonymized(c.Request().Context(), campaignName); err != nil {
+		return
+	} else if privacy != nil {
+		anonymizeEntries(privacy, leaderboard.Participants)
+	}
+
+	return c.JSON(http.StatusOK, leaderboard)
+}
+
+// leaderboardSocket pushes the score deltas of the campaign to the client as they happen, so the UI does not need to
+// poll the participant list. The deltas are already anonymized by publishScoreDelta when the campaign hides login
+// names.
+func leaderboardSocket(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	websocket.Handler(func(ws *websocket.Conn) {
+		defer func() {
+			_ = ws.Close()
+		}()
+
+		deltas := scoreHub.Subscribe(campaignName)
+		defer scoreHub.Unsubscribe(campaignName, deltas)
+
+		// the client does not send anything, so a read only returns once the client goes away
+		gone := make(chan bool)
+		go func() {
+			var ignored string
+			for websocket.Message.Receive(ws, &ignored) == nil {
+			}
+			close(gone)
+		}()
+
+		for {
+			select {
+			case delta := <-deltas:
+				if sendErr := websocket.JSON.Send(ws, delta); sendErr != nil {
+					logger.Debug("leaderboard socket send error", zap.String("campaignName", campaignName), zap.Error(sendErr))
+					return
+				}
+			case <-gone:
+				return
+			}
+		}
+	}).ServeHTTP(c.Response(), c.Request())
+	return
+}
+
+func getParticipantDetail(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	scpName := c.Param(ParamScpName)
+	loginName := c.Param(ParamLoginName)
+	logger.Debug("getting detail for campaign",
+		zap.String("campaignName", campaignName), zap.String("scpName", scpName), zap.String("loginName", loginName))
+
+	var participant *types.ParticipantStruct
+	participant, err = postgresDB.SelectParticipantDetail(c.Request().Context(), campaignName, scpName, loginName)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return newApiError(http.StatusNotFound, fmt.Sprintf("no participant: campaignName: %s, scpName: %s, loginName: %s",
+				campaignName, scpName, loginName))
+		}
+		return
+	}
+
+	setVersionETag(c, participant.Version)
+	return c.JSON(http.StatusOK, participant)
+}
+
+func getParticipantsList(c echo.Context) (err error) {
+	logTelemetry(c)
+
+	campaignName := c.Param(ParamCampaignName)
+	logger.Debug("Getting participant list for campaign", zap.String("campaignName", campaignName))
+
+	var version types.ListVersion
+	version, err = postgresDB.SelectParticipantsVersion(c.Request().Context(), campaignName)
+	if err == nil && listNotModified(c, version) {
+		return c.NoContent(http.StatusNotModified)
+	} else if err != nil && err != sql.ErrNoRows {
+		return
+	}
+
+	var participants []types.ParticipantStruct
+	participants, err = postgresDB.SelectParticipantsInCampaign(c.Request().Context(), campaignName)
+	if err != nil {
+		return
+	}
+
+	var privacy *types.CampaignPrivacyStruct
+	if privacy, err = selectAnonymized(c.Request().Context(), campaignName); err != nil {
+		return
+	} else if privacy != nil {
+		for i := range participants {
+			participants[i].LoginName = pseudonym(privacy, participants[i].ScpName, participants[i].LoginName)
+			participants[i].Email = ""
+			participants[i].DisplayName = ""
+			participants[i].AvatarUrl = ""
+		}
+	}
+
+	return listJSON(c, participants)
+}
+
+func updateParticipant(c echo.Context) (err error) {
+	participant := types.ParticipantStruct{}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&participant)
+	if err != nil {
+		return
+	}
+	if err = validateRequest(&participant); err != nil {
+		return
+	}
+	if participant.Version, err = updateVersion(c, participant.Version); err != nil {
+		return
+	}
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.UpdateParticipant(c.Request().Context(), &participant)
+	if err != nil {
+		return
+	}
+
+	if rowsAffected == 1 {
+		logger.Info("participant updated", zap.Any("participant", participant))
+		setVersionETag(c, participant.Version)
+		return c.NoContent(http.StatusNoContent)
+	} else {
+		logger.Error("no participant row was updated, something goofy has occurred",
+			zap.Any("participant", participant), zap.Int64("rowsAffected", rowsAffected))
+		return newApiError(http.StatusBadRequest, fmt.Sprintf("no participant was updated: campaignName: %s, scpName: %s, loginName: %s",
+			participant.CampaignName, participant.ScpName, participant.LoginName))
+	}
+}
+
+// patchParticipant changes only the fields sent, of the participant with the guid. The fields a participant must
+// have cannot be sent as null.
+func patchParticipant(c echo.Context) (err error) {
+	patch := types.ParticipantPatch{}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&patch)
+	if err != nil {
+		return
+	}
+	if err = validateRequest(&patch); err != nil {
+		return
+	}
+	for _, required := range []struct {
+		field string
+		null  bool
+	}{
+		{"campaignName", patch.CampaignName.Null},
+		{"scpName", patch.ScpName.Null},
+		{"loginName", patch.LoginName.Null},
+		{"score", patch.Score.Null},
+	} {
+		if required.null {
+			return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid null: %s", required.field))
+		}
+	}
+	if patch.LoginName.Set && patch.LoginName.Value == "" {
+		return newApiError(http.StatusBadRequest, "invalid empty: loginName")
+	}
+	if patch.Version, err = updateVersion(c, patch.Version); err != nil {
+		return
+	}
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.PatchParticipant(c.Request().Context(), &patch)
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no participant: guid: %s", patch.ID))
+	}
+
+	logger.Info("participant patched", zap.Any("patch", patch))
+	setVersionETag(c, patch.Version)
+	return c.NoContent(http.StatusNoContent)
+}
+
+const defaultSearchLimit = 50
+
+// searchParticipants finds a login in every campaign, as the login query parameter matches any part of a login. The
+// optional limit query parameter sets how many participants are read.
+func searchParticipants(c echo.Context) (err error) {
+	login := strings.TrimSpace(c.QueryParam("login"))
+	if login == "" {
+		return newApiError(http.StatusBadRequest, "missing login")
+	}
+	limit := defaultSearchLimit
+	if limitParam := c.QueryParam("limit"); limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 {
+			return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid limit: %s", limitParam))
+		}
+	}
+
+	var participants []types.ParticipantStruct
+	participants, err = postgresDB.SearchParticipants(c.Request().Context(), login, limit)
+	if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, participants)
+}
+
+// updateParticipantProfile lets the participant signed in by requireParticipant change their own contact details.
+// Admins change everything else with updateParticipant.
+func updateParticipantProfile(c echo.Context) (err error) {
+	scpName, loginName := participantOf(c)
+	if scpName == "" || loginName == "" {
+		return newApiError(http.StatusUnauthorized, "participant not signed in")
+	}
+
+	request := types.ParticipantProfileRequest{}
+	err = json.NewDecoder(c.Request().Body).Decode(&request)
+	if err != nil {
+		return
+	}
+	if err = validateRequest(&request); err != nil {
+		return
+	}
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.UpdateParticipantContact(c.Request().Context(), scpName, loginName, &request)
+	if err != nil {
+		return
+	}
+	if rowsAffected == 0 {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no participant: campaignName: %s, scpName: %s, loginName: %s",
+			request.CampaignName, scpName, loginName))
+	}
+
+	var participant *types.ParticipantStruct
+	participant, err = postgresDB.SelectParticipantDetail(c.Request().Context(), request.CampaignName, scpName, loginName)
+	if err != nil {
+		return
+	}
+	logger.Info("participant profile updated", zap.Any("participant", participant))
+	return c.JSON(http.StatusOK, participant)
+}
+
+func deleteParticipant(c echo.Context) (err error) {
+	campaign := c.Param(ParamCampaignName)
+	scpName := c.Param(ParamScpName)
+	loginName := c.Param(ParamLoginName)
+
+	var participantId string
+	participantId, err = postgresDB.DeleteParticipant(c.Request().Context(), campaign, scpName, loginName)
+	if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, fmt.Sprintf("deleted participant: campaign: %s, scpName: %s, loginName: %s, participant.id: %s",
+		campaign, scpName, loginName, participantId))
+}
+
+// addBan bans a login from every campaign. Where the login already joined a campaign, it stays a participant, but scores
+// nothing more.
+func addBan(c echo.Context) (err error) {
+	ban := types.BanStruct{}
+	err = json.NewDecoder(c.Request().Body).Decode(&ban)
+	if err != nil {
+		return
+	}
+	if err = validateRequest(&ban); err != nil {
+		return
+	}
+
+	err = postgresDB.InsertBan(c.Request().Context(), &ban)
+	if err != nil {
+		return
+	}
+	logger.Info("participant banned", zap.Any("ban", ban))
+
+	creation := newCreation(ban.ID, ban)
+	addEndpoint(c, &creation, "banList", "participant-ban-list", http.MethodGet)
+	addEndpoint(c, &creation, "banDelete", "participant-ban-delete", http.MethodDelete, ban.ScpName, ban.LoginName)
+	return sendCreation(c, creation, v1CreationEnvelope)
+}
+
+func getBans(c echo.Context) (err error) {
+	var bans []types.BanStruct
+	bans, err = postgresDB.SelectBans(c.Request().Context())
+	if err != nil {
+		return
+	}
+
+	return listJSON(c, bans)
+}
+
+func deleteBan(c echo.Context) (err error) {
+	scpName := c.Param(ParamScpName)
+	loginName := c.Param(ParamLoginName)
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.DeleteBan(c.Request().Context(), scpName, loginName)
+	if err != nil {
+		return
+	}
+	if rowsAffected > 0 {
+		logger.Info("participant ban lifted", zap.String("scpName", scpName), zap.String("loginName", loginName))
+		return c.NoContent(http.StatusNoContent)
+	}
+	return newApiError(http.StatusNotFound, fmt.Sprintf("no ban: scpName: %s, loginName: %s", scpName, loginName))
+}
+
+// was not seeing enough detail when addParticipant() returns error, so capturing such cases in the log.
+func logAddParticipant(c echo.Context) (err error) {
+	if err = addParticipant(c); err != nil {
+		logger.Error("error calling addParticipant", zap.Error(err))
+	}
+	return
+}
+
+func addParticipant(c echo.Context) (err error) {
+	participant := types.ParticipantStruct{}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&participant)
+	if err != nil {
+		return
+	}
+	if err = validateRequest(&participant); err != nil {
+		return
+	}
+	if err = checkTenantCampaign(c, participant.CampaignName); err != nil {
+		return
+	}
+	if err = checkRegistrationOpen(c.Request().Context(), participant.CampaignName, time.Now()); err != nil {
+		return
+	}
+	if err = checkNotBanned(c.Request().Context(), participant.ScpName, participant.LoginName); err != nil {
+		return
+	}
+
+	err = postgresDB.InsertParticipant(c.Request().Context(), &participant)
+	if err != nil {
+		return
+	}
+	welcomeParticipant(c.Request().Context(), &participant)
+
+	return sendCreation(c, participantCreation(c, participant), v1CreationEnvelope)
+}
+
+// checkRegistrationOpen only lets a participant join the campaign while its registration window is open at now. The
+// window is separate from the start and end of the campaign, so registration can open early or close before the end.
+func checkRegistrationOpen(ctx context.Context, campaignName string, now time.Time) (err error) {
+	var opensOn, closesOn *time.Time
+	opensOn, closesOn, err = postgresDB.SelectRegistrationWindow(ctx, campaignName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+	}
+	if err != nil {
+		return
+	}
+
+	if opensOn != nil && now.Before(*opensOn) {
+		return newApiError(http.StatusForbidden, fmt.Sprintf("registration for campaign %s opens at %s", campaignName, opensOn.Format(time.RFC3339)))
+	}
+	if closesOn != nil && !now.Before(*closesOn) {
+		return newApiError(http.StatusForbidden, fmt.Sprintf("registration for campaign %s closed at %s", campaignName, closesOn.Format(time.RFC3339)))
+	}
+	return
+}
+
+// checkNotBanned keeps a banned login from joining any campaign.
+func checkNotBanned(ctx context.Context, scpName, loginName string) (err error) {
+	var banned bool
+	banned, err = postgresDB.SelectBanned(ctx, scpName, loginName)
+	if err != nil {
+		return
+	}
+	if banned {
+		return newApiError(http.StatusForbidden, fmt.Sprintf("banned: scpName: %s, loginName: %s", scpName, loginName))
+	}
+	return
+}
+
+const (
+	upsertActionInserted = "inserted"
+	upsertActionUpdated  = "updated"
+)
+
+type upsertResponse struct {
+	creationResponse
+	Action string `json:"action"`
+}
+
+// upsertParticipant adds the participant, or changes the contact details of the participant with the same campaign,
+// scp and login, so registration can be repeated. The action in the response tells which happened. A new participant
+// is only added while registration is open, but one already registered may change their details at any time.
+func upsertParticipant(c echo.Context) (err error) {
+	participant := types.ParticipantStruct{}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&participant)
+	if err != nil {
+		return
+	}
+	if err = validateRequest(&participant); err != nil {
+		return
+	}
+	if err = checkUpsertRegistration(c.Request().Context(), &participant, time.Now()); err != nil {
+		return
+	}
+	if err = checkNotBanned(c.Request().Context(), participant.ScpName, participant.LoginName); err != nil {
+		return
+	}
+
+	var inserted bool
+	inserted, err = postgresDB.UpsertParticipant(c.Request().Context(), &participant)
+	if err != nil {
+		return
+	}
+
+	if !inserted {
+		logger.Info("participant upserted", zap.Any("participant", participant))
+		return c.JSON(http.StatusOK, upsertResponse{creationResponse: participantCreation(c, participant), Action: upsertActionUpdated})
+	}
+	welcomeParticipant(c.Request().Context(), &participant)
+	return c.JSON(http.StatusCreated, upsertResponse{creationResponse: participantCreation(c, participant), Action: upsertActionInserted})
+}
+
+// checkUpsertRegistration refuses to upsert a participant while registration is not open, unless the participant is
+// already registered and so would only be updated.
+func checkUpsertRegistration(ctx context.Context, participant *types.ParticipantStruct, now time.Time) (err error) {
+	err = checkRegistrationOpen(ctx, participant.CampaignName, now)
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusForbidden {
+		return
+	}
+
+	_, lookupErr := postgresDB.SelectParticipantDetail(ctx, participant.CampaignName, participant.ScpName, participant.LoginName)
+	if errors.Is(lookupErr, sql.ErrNoRows) {
+		return
+	}
+	return lookupErr
+}
+
+// welcomeParticipant reads the profile of a newly added participant, then announces them to the webhooks and by email.
+func welcomeParticipant(ctx context.Context, participant *types.ParticipantStruct) {
+	refreshProfile(ctx, participant, time.Now())
+
+	publishWebhookEvent(ctx, types.WebhookEventParticipantAdded, *participant)
+	if participant.Email != "" {
+		sendCampaignEmail(loadCampaignEmail(ctx, participant.CampaignName, types.EmailKindRegistration),
+			emailData{CampaignName: participant.CampaignName, Participant: *participant})
+	}
+}
+
+// the shape an add endpoint responded with in apiVersion1
+type v1Creation int
+
+const (
+	v1CreationEnvelope v1Creation = iota
+	v1CreationId
+	v1CreationObject
+)
+
+func newCreation(id string, object interface{}) creationResponse {
+	return creationResponse{Id: id, Endpoints: map[string]endpointDetail{}, Object: object}
+}
+
+// addEndpoint adds the named route to the endpoints of the creation. A tenant admin is given the route of the tenant
+// instead, and none when the tenant has no such route.
+func addEndpoint(c echo.Context, creation *creationResponse, key, routeName, verb string, params ...interface{}) {
+	if tenantName := tenantOf(c); tenantName != "" {
+		routeName = tenantRouteNamePrefix + routeName
+		if !hasRoute(c.Echo(), routeName) {
+			return
+		}
+		params = append([]interface{}{tenantName}, params...)
+	}
+	creation.Endpoints[key] = endpointDetail{URI: c.Echo().Reverse(routeName, params...), Verb: verb}
+}
+
+func hasRoute(e *echo.Echo, routeName string) bool {
+	for _, route := range e.Routes() {
+		if route.Name == routeName {
+			return true
+		}
+	}
+	return false
+}
+
+// sendCreation responds with the creation, or with what the endpoint sent in apiVersion1 when the request asks for it
+func sendCreation(c echo.Context, creation creationResponse, v1 v1Creation) error {
+	if c.Request().Header.Get(headerApiVersion) == apiVersion1 {
+		switch v1 {
+		case v1CreationId:
+			return c.String(http.StatusCreated, creation.Id)
+		case v1CreationObject:
+			return c.JSON(http.StatusCreated, creation.Object)
+		}
+	}
+	return c.JSON(http.StatusCreated, creation)
+}
+
+func participantCreation(c echo.Context, participant types.ParticipantStruct) creationResponse {
+	creation := newCreation(participant.ID, participant)
+	addEndpoint(c, &creation, "participantDetail", "participant-detail", http.MethodGet,
+		participant.CampaignName, participant.ScpName, participant.LoginName)
+	addEndpoint(c, &creation, "participantUpdate", "participant-update", http.MethodPost)
+	return creation
+}
+
+func addTeam(c echo.Context) (err error) {
+	team := types.TeamStruct{}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&team)
+	if err != nil {
+		return
+	}
+	if err = validateRequest(&team); err != nil {
+		return
+	}
+
+	err = postgresDB.InsertTeam(c.Request().Context(), &team)
+	if err != nil {
+		return
+	}
+
+	creation := newCreation(team.Id, team)
+	addEndpoint(c, &creation, "teamDetail", "team-detail", http.MethodGet, team.Name)
+	// the team name is only unique within its campaign
+	if detail, ok := creation.Endpoints["teamDetail"]; ok {
+		detail.URI += "?" + url.Values{ParamCampaignName: {team.CampaignName}}.Encode()
+		creation.Endpoints["teamDetail"] = detail
+	}
+	addEndpoint(c, &creation, "teamList", "team-list", http.MethodGet, team.CampaignName)
+	return sendCreation(c, creation, v1CreationId)
+}
+
+func addPersonToTeam(c echo.Context) (err error) {
+	teamName := c.Param(ParamTeamName)
+	campaignName := c.Param(ParamCampaignName)
+	scpName := c.Param(ParamScpName)
+	loginName := c.Param(ParamLoginName)
+
+	if teamName == "" || campaignName == "" || scpName == "" || loginName == "" {
+		return newApiError(http.StatusBadRequest, fmt.Sprintf("missing team member: campaignName: %s, teamName: %s, scpName: %s, loginName: %s",
+			campaignName, teamName, scpName, loginName))
+	}
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.UpdateParticipantTeam(c.Request().Context(), teamName, campaignName, scpName, loginName)
+	if err != nil {
+		var teamFullErr *db.TeamFullError
+		if errors.As(err, &teamFullErr) {
+			logger.Info("team is full", zap.Error(err), zap.String("scpName", scpName), zap.String("loginName", loginName))
+			return
+		}
+		var captainMoveErr *db.CaptainMoveError
+		if errors.As(err, &captainMoveErr) {
+			logger.Info("captain can not leave their team", zap.Error(err), zap.String("scpName", scpName), zap.String("loginName", loginName))
+			return
+		}
+		if err == sql.ErrNoRows {
+			return newApiError(http.StatusNotFound, fmt.Sprintf("no team: campaignName: %s, name: %s", campaignName, teamName))
+		}
+		return
+	}
+
+	if rowsAffected > 0 {
+		logger.Info("team updated",
+			zap.String("teamName", teamName), zap.String("campaignName", campaignName),
+			zap.String("scpName", scpName), zap.String("loginName", loginName))
+
+		emailTeamAssignment(c.Request().Context(), campaignName, scpName, loginName)
+
+		return c.NoContent(http.StatusNoContent)
+	} else {
+		logger.Error("no team row was updated, something goofy has occurred",
+			zap.String("teamName", teamName), zap.String("campaignName", campaignName),
+			zap.String("scpName", scpName), zap.String("loginName", loginName))
+
+		return newApiError(http.StatusBadRequest, fmt.Sprintf("no participant was added to the team: campaignName: %s, teamName: %s, scpName: %s, loginName: %s",
+			campaignName, teamName, scpName, loginName))
+	}
+}
+
+// addPeopleToTeams applies a list of team assignments together. When any of them can not be applied, none are, and
+// the results tell which entries failed.
+func addPeopleToTeams(c echo.Context) (err error) {
+	var assignments []types.TeamAssignment
+	err = json.NewDecoder(c.Request().Body).Decode(&assignments)
+	if err != nil {
+		return
+	}
+	if len(assignments) == 0 {
+		return newApiError(http.StatusBadRequest, "no team assignments")
+	}
+	if err = validateRequest(assignments); err != nil {
+		return
+	}
+
+	var results []types.TeamAssignmentResult
+	results, err = postgresDB.UpdateParticipantTeams(c.Request().Context(), assignments)
+	if err != nil {
+		return
+	}
+
+	for _, result := range results {
+		if !result.Assigned {
+			logger.Info("team assignments not applied", zap.Any("results", results))
+			apiErr := newApiError(http.StatusConflict, "team assignments not applied")
+			apiErr.Details = results
+			return apiErr
+		}
+	}
+
+	logger.Info("teams updated", zap.Int("assignments", len(results)))
+	for _, result := range results {
+		emailTeamAssignment(c.Request().Context(), result.CampaignName, result.ScpName, result.LoginName)
+	}
+	return c.JSON(http.StatusOK, results)
+}
+
+func getTeams(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	var teams []types.TeamStruct
+	teams, err = postgresDB.SelectTeamsInCampaign(c.Request().Context(), campaignName)
+	if err != nil {
+		return
+	}
+
+	return listJSON(c, teams)
+}
+
+// getTeamDetail returns the team and its members. Team names are only unique within a campaign, so the optional
+// campaignName query parameter can be used to pick the team from a specific campaign.
+func getTeamDetail(c echo.Context) (err error) {
+	teamName := c.Param(ParamTeamName)
+	campaignName := c.QueryParam(ParamCampaignName)
+
+	var team *types.TeamStruct
+	team, err = postgresDB.SelectTeamDetail(c.Request().Context(), campaignName, teamName)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return newApiError(http.StatusNotFound, fmt.Sprintf("no team: campaignName: %s, name: %s", campaignName, teamName))
+		}
+		return
+	}
+
+	return c.JSON(http.StatusOK, team)
+}
+
+func deleteTeam(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	teamName := c.Param(ParamTeamName)
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.DeleteTeam(c.Request().Context(), campaignName, teamName)
+	if err != nil {
+		return
+	}
+	logger.Info("delete team",
+		zap.String("campaignName", campaignName),
+		zap.String("teamName", teamName),
+		zap.Int64("rowsAffected", rowsAffected))
+	if rowsAffected > 0 {
+		return c.NoContent(http.StatusNoContent)
+	}
+	return newApiError(http.StatusNotFound, fmt.Sprintf("no team: campaignName: %s, name: %s", campaignName, teamName))
+}
+
+func removePersonFromTeam(c echo.Context) (err error) {
+	teamName := c.Param(ParamTeamName)
+	campaignName := c.Param(ParamCampaignName)
+	scpName := c.Param(ParamScpName)
+	loginName := c.Param(ParamLoginName)
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.RemoveParticipantFromTeam(c.Request().Context(), teamName, campaignName, scpName, loginName)
+	if err != nil {
+		return
+	}
+	logger.Info("remove person from team",
+		zap.String("teamName", teamName), zap.String("campaignName", campaignName),
+		zap.String("scpName", scpName), zap.String("loginName", loginName),
+		zap.Int64("rowsAffected", rowsAffected))
+	if rowsAffected > 0 {
+		return c.NoContent(http.StatusNoContent)
+	}
+	return newApiError(http.StatusNotFound, fmt.Sprintf("no team member: campaignName: %s, teamName: %s, scpName: %s, loginName: %s",
+		campaignName, teamName, scpName, loginName))
+}
+
+// setTeamCaptain makes a team member the captain, which also transfers the role away from the current captain.
+func setTeamCaptain(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	teamName := c.Param(ParamTeamName)
+	scpName := c.Param(ParamScpName)
+	loginName := c.Param(ParamLoginName)
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.UpdateTeamCaptain(c.Request().Context(), campaignName, teamName, scpName, loginName)
+	if err != nil {
+		return
+	}
+	logger.Info("set team captain",
+		zap.String("teamName", teamName), zap.String("campaignName", campaignName),
+		zap.String("scpName", scpName), zap.String("loginName", loginName),
+		zap.Int64("rowsAffected", rowsAffected))
+	if rowsAffected > 0 {
+		return c.NoContent(http.StatusNoContent)
+	}
+	return newApiError(http.StatusNotFound, fmt.Sprintf("no team member: campaignName: %s, teamName: %s, scpName: %s, loginName: %s",
+		campaignName, teamName, scpName, loginName))
+}
+
+func renameTeam(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	teamName := c.Param(ParamTeamName)
+	newTeamName := strings.TrimSpace(c.Param(ParamNewTeamName))
+	if newTeamName == "" {
+		return newApiError(http.StatusBadRequest, "new team name is empty")
+	}
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.UpdateTeamName(c.Request().Context(), campaignName, teamName, newTeamName)
+	if err != nil {
+		return
+	}
+	logger.Info("rename team",
+		zap.String("campaignName", campaignName),
+		zap.String("teamName", teamName),
+		zap.String("newTeamName", newTeamName),
+		zap.Int64("rowsAffected", rowsAffected))
+	if rowsAffected > 0 {
+		return c.NoContent(http.StatusNoContent)
+	}
+	return newApiError(http.StatusNotFound, fmt.Sprintf("no team: campaignName: %s, name: %s", campaignName, teamName))
+}
+
+// request body fields that are replaced before a payload is written to the audit log
+var auditRedactedFields = []string{"secret", "password", "adminPassword", "webhookUrl"}
+
+const auditRedacted = "REDACTED"
+
+// auditPayloadLimit is the largest payload recorded. A larger one, like a bulk import, is passed on to the handler
+// without being held in memory, and recorded by its size only.
+const auditPayloadLimit = 64 * 1024
+
+// auditAdminCall records each admin call that can change something, after it is handled. Reads are not recorded. A
+// failure to record is only logged, as the call has already been made.
+func auditAdminCall(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		req := c.Request()
+		if req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodOptions {
+			return next(c)
+		}
+
+		var payload []byte
+		if req.Body != nil {
+			payload, err = io.ReadAll(io.LimitReader(req.Body, auditPayloadLimit+1))
+			if err != nil {
+				return
+			}
+			// let the handler read the body again, along with the rest of a larger one
+			req.Body = io.NopCloser(io.MultiReader(bytes.NewReader(payload), req.Body))
+		}
+
+		err = next(c)
+
+		status := c.Response().Status
+		if err != nil {
+			status = toApiError(err).Status
+		}
+		actor, _, _ := req.BasicAuth()
+		entry := types.AuditLogEntry{
+			Actor:     actor,
+			Method:    req.Method,
+			Path:      req.URL.Path,
+			Route:     c.Path(),
+			Status:    status,
+			Payload:   auditPayload(payload),
+			CreatedOn: time.Now(),
+		}
+		if auditErr := postgresDB.InsertAuditLog(req.Context(), &entry); auditErr != nil {
+			logger.Error("audit log error", zap.String("method", entry.Method), zap.String("path", entry.Path), zap.Error(auditErr))
+		}
+		return
+	}
+}
+
+// auditPayload is the payload recorded of an admin call, read up to one byte over auditPayloadLimit.
+func auditPayload(payload []byte) string {
+	if len(payload) > auditPayloadLimit {
+		return fmt.Sprintf("not recorded, over %d bytes", auditPayloadLimit)
+	}
+	return redactAuditPayload(payload)
+}
+
+// redactAuditPayload hides the secrets of a JSON payload, at any depth. Other payloads are kept as they are.
+func redactAuditPayload(payload []byte) string {
+	var value interface{}
+	if json.Unmarshal(payload, &value) != nil || !redactAuditValue(value) {
+		return string(payload)
+	}
+	redactedPayload, _ := json.Marshal(value)
+	return string(redactedPayload)
+}
+
+// redactAuditValue hides the secret fields of the objects in value, reporting whether any was hidden.
+func redactAuditValue(value interface{}) (redacted bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for name, field := range v {
+			if isAuditRedactedField(name) {
+				v[name] = auditRedacted
+				redacted = true
+			} else if redactAuditValue(field) {
+				redacted = true
+			}
+		}
+	case []interface{}:
+		for _, element := range v {
+			if redactAuditValue(element) {
+				redacted = true
+			}
+		}
+	}
+	return
+}
+
+func isAuditRedactedField(name string) bool {
+	for _, secretField := range auditRedactedFields {
+		if strings.EqualFold(name, secretField) {
+			return true
+		}
+	}
+	return false
+}
+
+const defaultAuditLimit = 100
+
+// getAuditLog reads the latest admin calls, newest first. The optional actor query parameter only reads the calls of
+// that admin, and limit sets how many calls are read.
+func getAuditLog(c echo.Context) (err error) {
+	limit := defaultAuditLimit
+	if limitParam := c.QueryParam("limit"); limitParam != "" {
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit < 1 {
+			return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid limit: %s", limitParam))
+		}
+	}
+
+	var entries []types.AuditLogEntry
+	entries, err = postgresDB.SelectAuditLog(c.Request().Context(), c.QueryParam("actor"), limit)
+	if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, entries)
+}
+
+// ctxTenantName is the echo context key holding the tenant of a tenant endpoint
+const ctxTenantName = "tenantName"
+
+// setTenant keeps the tenant named by the path, so the handlers shared with the admin endpoints only reach the
+// campaigns of the tenant.
+func setTenant(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Set(ctxTenantName, c.Param(ParamTenantName))
+		return next(c)
+	}
+}
+
+// tenantOf is the tenant of the request, empty for the admin endpoints.
+func tenantOf(c echo.Context) string {
+	tenantName, _ := c.Get(ctxTenantName).(string)
+	return tenantName
+}
+
+// checkTenantCampaign fails unless the campaign belongs to the tenant of the request. A campaign of another tenant is
+// reported as missing, so a tenant cannot learn the campaigns of others. Admin endpoints reach every campaign.
+func checkTenantCampaign(c echo.Context, campaignName string) (err error) {
+	tenantName := tenantOf(c)
+	if tenantName == "" {
+		return
+	}
+	if campaignName == "" {
+		return newApiError(http.StatusUnprocessableEntity, fmt.Sprintf("missing campaignName: tenantName: %s", tenantName))
+	}
+
+	var campaignTenant string
+	campaignTenant, err = postgresDB.SelectCampaignTenant(c.Request().Context(), campaignName)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && campaignTenant != tenantName) {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+	}
+	return
+}
+
+// requireTenantCampaign only lets a tenant request through when the campaign named by the path belongs to the tenant.
+func requireTenantCampaign(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		if err = checkTenantCampaign(c, c.Param(ParamCampaignName)); err != nil {
+			return
+		}
+		return next(c)
+	}
+}
+
+// addTenant adds a tenant with its own admin, who manages the campaigns of the tenant through the tenant endpoints.
+// Only a hash of the admin password is kept.
+func addTenant(c echo.Context) (err error) {
+	tenant := types.TenantStruct{}
+	err = json.NewDecoder(c.Request().Body).Decode(&tenant)
+	if err != nil {
+		return
+	}
+	if err = validateRequest(&tenant); err != nil {
+		return
+	}
+
+	var passwordHash []byte
+	passwordHash, err = bcrypt.GenerateFromPassword([]byte(tenant.AdminPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return
+	}
+	tenant.AdminPassword = ""
+
+	var guid string
+	guid, err = postgresDB.InsertTenant(c.Request().Context(), &tenant, string(passwordHash))
+	if err != nil {
+		return
+	}
+
+	creation := newCreation(guid, tenant)
+	addEndpoint(c, &creation, "tenantList", "tenant-list", http.MethodGet)
+	return sendCreation(c, creation, v1CreationEnvelope)
+}
+
+func getTenants(c echo.Context) (err error) {
+	var tenants []types.TenantStruct
+	tenants, err = postgresDB.SelectTenants(c.Request().Context())
+	if err != nil {
+		return
+	}
+
+	return listJSON(c, tenants)
+}
+
+// echo context keys holding the participant signed in by requireParticipant
+const (
+	ctxParticipantScpName   = "participantScpName"
+	ctxParticipantLoginName = "participantLoginName"
+)
+
+// participantTokenTtl is how long the login of an access token is trusted before the source control provider is asked
+// again
+const participantTokenTtl = 5 * time.Minute
+
+type participantLogin struct {
+	loginName string
+	expiresOn time.Time
+}
+
+// participantLogins caches the login of each access token, by a hash of the source control provider and the token, so
+// not every request of a participant calls the provider
+var participantLogins = struct {
+	sync.Mutex
+	byToken map[string]participantLogin
+}{byToken: map[string]participantLogin{}}
+
+// authenticateParticipant finds the login of the access token, from the cache while it is fresh.
+func authenticateParticipant(scpName, token string, now time.Time) (loginName string, err error) {
+	sum := sha256.Sum256([]byte(scpName + "\n" + token))
+	key := hex.EncodeToString(sum[:])
+
+	participantLogins.Lock()
+	cached, ok := participantLogins.byToken[key]
+	participantLogins.Unlock()
+	if ok && now.Before(cached.expiresOn) {
+		return cached.loginName, nil
+	}
+
+	if loginName, err = participantAuthenticator.Authenticate(scpName, token); err != nil {
+		return
+	}
+
+	participantLogins.Lock()
+	defer participantLogins.Unlock()
+	for cachedKey, login := range participantLogins.byToken {
+		if !now.Before(login.expiresOn) {
+			delete(participantLogins.byToken, cachedKey)
+		}
+	}
+	participantLogins.byToken[key] = participantLogin{loginName: loginName, expiresOn: now.Add(participantTokenTtl)}
+	return
+}
+
+// requireParticipant only lets a participant's own change through once they proved who they are, with an access token
+// of their source control provider as the bearer of the Authorization header, and the name of the provider in the
+// X-BBash-Scp-Name header. The login the provider gives for the token is kept in the context.
+func requireParticipant(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		scpName := c.Request().Header.Get(headerScpName)
+		authorization := c.Request().Header.Get(echo.HeaderAuthorization)
+		token := strings.TrimPrefix(authorization, "Bearer ")
+		if scpName == "" || token == "" || token == authorization {
+			return newApiError(http.StatusUnauthorized, fmt.Sprintf("missing %s header or bearer token", headerScpName))
+		}
+		if participantAuthenticator == nil {
+			return newApiError(http.StatusUnauthorized, "participants can not sign in")
+		}
+
+		var loginName string
+		loginName, err = authenticateParticipant(scpName, token, time.Now())
+		if errors.Is(err, profile.ErrInvalidToken) || errors.Is(err, profile.ErrUnsupportedScp) {
+			logger.Info("participant token refused", zap.String("scpName", scpName), zap.Error(err))
+			return newApiError(http.StatusUnauthorized, fmt.Sprintf("access token refused: scpName: %s", scpName))
+		} else if err != nil {
+			return
+		}
+
+		c.Set(ctxParticipantScpName, scpName)
+		c.Set(ctxParticipantLoginName, loginName)
+		return next(c)
+	}
+}
+
+// participantOf is the participant signed in by requireParticipant.
+func participantOf(c echo.Context) (scpName, loginName string) {
+	scpName, _ = c.Get(ctxParticipantScpName).(string)
+	loginName, _ = c.Get(ctxParticipantLoginName).(string)
+	return
+}
+
+// requirePathParticipant only lets the participant signed in by requireParticipant reach the routes of their own
+// login, such as their notifications.
+func requirePathParticipant(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		scpName, loginName := participantOf(c)
+		if scpName == "" || loginName == "" {
+			return newApiError(http.StatusUnauthorized, "participant not signed in")
+		}
+		if !strings.EqualFold(scpName, c.Param(ParamScpName)) || !strings.EqualFold(loginName, c.Param(ParamLoginName)) {
+			return newApiError(http.StatusForbidden, fmt.Sprintf("not the participant: scpName: %s, loginName: %s",
+				c.Param(ParamScpName), c.Param(ParamLoginName)))
+		}
+		return next(c)
+	}
+}
+
+// requireTeamCaptain only lets a self-service team change through when the participant signed in by
+// requireParticipant is the captain of the team being changed. Admin routes do not use this check.
+func requireTeamCaptain(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		scpName, loginName := participantOf(c)
+		if scpName == "" || loginName == "" {
+			return newApiError(http.StatusUnauthorized, "participant not signed in")
+		}
+
+		campaignName := c.Param(ParamCampaignName)
+		teamName := c.Param(ParamTeamName)
+
+		var isCaptain bool
+		isCaptain, err = postgresDB.IsTeamCaptain(c.Request().Context(), campaignName, teamName, scpName, loginName)
+		if err != nil {
+			return
+		}
+		if !isCaptain {
+			logger.Info("team change refused, not the captain",
+				zap.String("campaignName", campaignName), zap.String("teamName", teamName),
+				zap.String("scpName", scpName), zap.String("loginName", loginName))
+			return newApiError(http.StatusForbidden, fmt.Sprintf("not the team captain: campaignName: %s, teamName: %s, scpName: %s, loginName: %s",
+				campaignName, teamName, scpName, loginName))
+		}
+		return next(c)
+	}
+}
+
+// requireJoinableParticipant only lets a captain add a participant through self-service when the participant is not
+// yet on a team, so a captain can not take a member away from another team without their consent. Adding a participant
+// to the team they are already on is let through, and an unknown participant is left to the handler to report.
+func requireJoinableParticipant(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		campaignName := c.Param(ParamCampaignName)
+		teamName := c.Param(ParamTeamName)
+		scpName := c.Param(ParamScpName)
+		loginName := c.Param(ParamLoginName)
+
+		var participant *types.ParticipantStruct
+		participant, err = postgresDB.SelectParticipantDetail(c.Request().Context(), campaignName, scpName, loginName)
+		if err == sql.ErrNoRows {
+			return next(c)
+		}
+		if err != nil {
+			return
+		}
+		if participant.TeamName != "" && participant.TeamName != teamName {
+			logger.Info("team change refused, participant is on another team",
+				zap.String("campaignName", campaignName), zap.String("teamName", teamName),
+				zap.String("otherTeamName", participant.TeamName),
+				zap.String("scpName", scpName), zap.String("loginName", loginName))
+			return newApiError(http.StatusForbidden, fmt.Sprintf("participant is on another team: campaignName: %s, teamName: %s, scpName: %s, loginName: %s",
+				campaignName, participant.TeamName, scpName, loginName))
+		}
+		return next(c)
+	}
+}
+
+func validateTeamScoreAdjustments(request *types.TeamScoreAdjustmentRequest) (err error) {
+	if strings.TrimSpace(request.Category) == "" {
+		err = fmt.Errorf("team score adjustment is not valid, empty category")
+	} else if len(request.Adjustments) == 0 {
+		err = fmt.Errorf("team score adjustment is not valid, no adjustments: category: %s", request.Category)
+	}
+	for i := 0; err == nil && i < len(request.Adjustments); i++ {
+		adjustment := request.Adjustments[i]
+		if adjustment.TeamName == "" {
+			err = fmt.Errorf("team score adjustment is not valid, empty teamName: adjustment: %+v", adjustment)
+		} else if strings.TrimSpace(adjustment.Reason) == "" {
+			err = fmt.Errorf("team score adjustment is not valid, empty reason: adjustment: %+v", adjustment)
+		}
+	}
+	if err != nil {
+		logger.Error("validateTeamScoreAdjustments error", zap.Error(err))
+	}
+	return
+}
+
+// adjustTeamScores grants points to teams for an organizer judged award category, like best writeup.
+func adjustTeamScores(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	request := types.TeamScoreAdjustmentRequest{}
+	err = json.NewDecoder(c.Request().Body).Decode(&request)
+	if err != nil {
+		return
+	}
+
+	if err = validateTeamScoreAdjustments(&request); err != nil {
+		return newApiError(http.StatusBadRequest, err.Error())
+	}
+
+	var events []types.TeamScoreEvent
+	events, err = postgresDB.InsertTeamScoreAdjustments(c.Request().Context(), campaignName, &request, time.Now())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return newApiError(http.StatusNotFound, err.Error())
+		}
+		return
+	}
+	logger.Info("team scores adjusted",
+		zap.String("campaignName", campaignName), zap.String("category", request.Category), zap.Any("events", events))
+
+	return c.JSON(http.StatusCreated, events)
+}
+
+func getTeamScoreHistory(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	teamName := c.Param(ParamTeamName)
+
+	var events []types.TeamScoreEvent
+	events, err = postgresDB.SelectTeamScoreHistory(c.Request().Context(), campaignName, teamName)
+	if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, events)
+}
+
+func addBug(c echo.Context) (err error) {
+	bug := types.BugStruct{}
+
+	err = json.NewDecoder(c.Request().Body).Decode(&bug)
+	if err != nil {
+		logger.Error("error decoding bug body", zap.Error(err))
+		return
+	}
+
+	if err = validateRequest(&bug); err != nil {
+		return
+	}
+	if err = checkTenantCampaign(c, bug.Campaign); err != nil {
+		return
+	}
+
+	err = postgresDB.InsertBug(c.Request().Context(), &bug)
+	if err != nil {
+		return
+	}
+
+	creation := newCreation(bug.Id, bug)
+	addEndpoint(c, &creation, "bugList", "bug-list", http.MethodGet)
+	return sendCreation(c, creation, v1CreationEnvelope)
+}
+
+func updateBug(c echo.Context) (err error) {
+	campaign := c.Param(ParamCampaignName)
+	category := c.Param(ParamBugCategory)
+	pointValueParam := c.Param(ParamPointValue)
+	// ParseFloat reads NaN and Inf too, which the point value validation lets through
+	pointValue, err := strconv.ParseFloat(pointValueParam, 64)
+	if err != nil || math.IsNaN(pointValue) || math.IsInf(pointValue, 0) {
+		return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid pointValue: %s", pointValueParam))
+	}
+
+	bug := types.BugStruct{Campaign: campaign, Category: category, PointValue: pointValue}
+	if err = validateRequest(&bug); err != nil {
+		return
+	}
+	// the bug is named by the path, so its version is only in If-Match
+	if bug.Version, err = updateVersion(c, 0); err != nil {
+		return
+	}
+
+	logger.Debug(category)
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.UpdateBug(c.Request().Context(), &bug)
+	if err != nil {
+		return
+	}
+	if rowsAffected < 1 {
+		return newApiError(http.StatusNotFound, "Bug Category not found")
+	}
+
+	setVersionETag(c, bug.Version)
+	return c.String(http.StatusOK, "Success")
+}
+
+func getPointValueOverrides(c echo.Context) (err error) {
+	var overrides []types.PointValueOverride
+	overrides, err = postgresDB.SelectPointValueOverrides(c.Request().Context(), c.Param(ParamCampaignName))
+	if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, overrides)
+}
+
+// putPointValueOverride sets the point value of a bug category for the repositories of one organization, in place of
+// the point value of the campaign.
+func putPointValueOverride(c echo.Context) (err error) {
+	override := types.PointValueOverride{}
+	err = json.NewDecoder(c.Request().Body).Decode(&override)
+	if err != nil {
+		return
+	}
+	if err = validateRequest(&override); err != nil {
+		return
+	}
+
+	err = postgresDB.UpsertPointValueOverride(c.Request().Context(), &override)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign or organization: campaignName: %s, scpName: %s, organization: %s",
+			override.CampaignName, override.ScpName, override.Organization))
+	} else if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, override)
+}
+
+func deletePointValueOverride(c echo.Context) (err error) {
+	override := types.PointValueOverride{
+		CampaignName: c.Param(ParamCampaignName),
+		ScpName:      c.Param(ParamScpName),
+		Organization: c.Param(ParamOrganizationName),
+		Category:     c.Param(ParamBugCategory),
+	}
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.DeletePointValueOverride(c.Request().Context(), &override)
+	if err != nil {
+		return
+	}
+	if rowsAffected > 0 {
+		return c.NoContent(http.StatusNoContent)
+	}
+	return newApiError(http.StatusNotFound, fmt.Sprintf("no point value override: campaignName: %s, scpName: %s, organization: %s, category: %s",
+		override.CampaignName, override.ScpName, override.Organization, override.Category))
+}
+
+func getBugCategoryExclusions(c echo.Context) (err error) {
+	var exclusions []types.BugCategoryExclusion
+	exclusions, err = postgresDB.SelectBugCategoryExclusions(c.Request().Context(), c.Param(ParamCampaignName))
+	if err != nil {
+		return
+	}
+
+	return listJSON(c, exclusions)
+}
+
+// addBugCategoryExclusion keeps the fixed bugs of a category from scoring in the campaign, neither at the point value
+// of the category nor as unclassified bugs.
+func addBugCategoryExclusion(c echo.Context) (err error) {
+	exclusion := types.BugCategoryExclusion{
+		CampaignName: c.Param(ParamCampaignName),
+		Category:     c.Param(ParamBugCategory),
+	}
+	if err = validateRequest(&exclusion); err != nil {
+		return
+	}
+
+	err = postgresDB.InsertBugCategoryExclusion(c.Request().Context(), &exclusion)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", exclusion.CampaignName))
+	} else if err != nil {
+		return
+	}
+	logger.Info("bug category excluded", zap.Any("exclusion", exclusion))
+
+	creation := newCreation(exclusion.Id, exclusion)
+	addEndpoint(c, &creation, "exclusionList", "bug-exclusion-list", http.MethodGet, exclusion.CampaignName)
+	addEndpoint(c, &creation, "exclusionDelete", "bug-exclusion-delete", http.MethodDelete, exclusion.CampaignName, exclusion.Category)
+	return sendCreation(c, creation, v1CreationEnvelope)
+}
+
+func deleteBugCategoryExclusion(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	category := c.Param(ParamBugCategory)
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.DeleteBugCategoryExclusion(c.Request().Context(), campaignName, category)
+	if err != nil {
+		return
+	}
+	if rowsAffected > 0 {
+		return c.NoContent(http.StatusNoContent)
+	}
+	return newApiError(http.StatusNotFound, fmt.Sprintf("no bug category exclusion: campaignName: %s, category: %s", campaignName, category))
+}
+
+func getBugs(c echo.Context) (err error) {
+	var version types.ListVersion
+	version, err = postgresDB.SelectBugsVersion(c.Request().Context())
+	if err != nil {
+		return
+	}
+	if listNotModified(c, version) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	var bugs []types.BugStruct
+	bugs, err = postgresDB.SelectBugs(c.Request().Context())
+	if err != nil {
+		return
+	}
+
+	return listJSON(c, bugs)
+}
+
+func putBugs(c echo.Context) (err error) {
+	var bugs []types.BugStruct
+	err = json.NewDecoder(c.Request().Body).Decode(&bugs)
+	if err != nil {
+		logger.Error("error decoding bug body", zap.Error(err))
+		return
+	}
+
+	// check every bug before inserting any of them
+	if err = validateRequest(bugs); err != nil {
+		return
+	}
+
+	var inserted []types.BugStruct
+	for _, bug := range bugs {
+		err = postgresDB.InsertBug(c.Request().Context(), &bug)
+		if err != nil {
+			logger.Error("error inserting bug", zap.Any("bug", bug), zap.Error(err))
+			return
+		}
+		inserted = append(inserted, bug)
+	}
+
+	creation := newCreation(inserted[0].Id, inserted)
+	addEndpoint(c, &creation, "bugList", "bug-list", http.MethodGet)
+	return sendCreation(c, creation, v1CreationEnvelope)
+}
+
+// the related collections a campaign can be read with, named by the include query parameter
+const (
+	includeParticipants = "participants"
+	includeBugs         = "bugs"
+)
+
+// campaignDetail is a campaign, with the related collections it was asked to include. A collection not included is
+// left out, rather than empty.
+type campaignDetail struct {
+	*types.CampaignStruct
+	Participants *[]types.ParticipantStruct `json:"participants,omitempty"`
+	Bugs         *[]types.BugStruct         `json:"bugs,omitempty"`
+}
+
+// parseCampaignIncludes reads the optional include query parameter, a comma separated list like participants,bugs.
+func parseCampaignIncludes(c echo.Context) (includes map[string]bool, err error) {
+	includes = map[string]bool{}
+	for _, include := range strings.Split(c.QueryParam("include"), ",") {
+		switch include = strings.TrimSpace(include); include {
+		case "":
+		case includeParticipants, includeBugs:
+			includes[include] = true
+		default:
+			return nil, newApiError(http.StatusBadRequest,
+				fmt.Sprintf("invalid include: %s, expected %s or %s", include, includeParticipants, includeBugs))
+		}
+	}
+	return
+}
+
+// getCampaign reads the campaign, and each collection it is asked to include with a single query, so a client does not
+// need a request for each.
+func getCampaign(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	var includes map[string]bool
+	includes, err = parseCampaignIncludes(c)
+	if err != nil {
+		return
+	}
+
+	var campaign *types.CampaignStruct
+	campaign, err = postgresDB.GetCampaign(c.Request().Context(), campaignName)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+		}
+		return
+	}
+
+	setVersionETag(c, campaign.Version)
+	detail := campaignDetail{CampaignStruct: campaign}
+	// an included collection without any rows is an empty list, not null
+	if includes[includeParticipants] {
+		var participants []types.ParticipantStruct
+		participants, err = postgresDB.SelectParticipantsInCampaign(c.Request().Context(), campaignName)
+		if err != nil {
+			return
+		}
+		if participants == nil {
+			participants = []types.ParticipantStruct{}
+		}
+		detail.Participants = &participants
+	}
+	if includes[includeBugs] {
+		var bugs []types.BugStruct
+		bugs, err = postgresDB.SelectBugsInCampaign(c.Request().Context(), campaignName)
+		if err != nil {
+			return
+		}
+		if bugs == nil {
+			bugs = []types.BugStruct{}
+		}
+		detail.Bugs = &bugs
+	}
+
+	return c.JSON(http.StatusOK, detail)
+}
+
+// parseCampaignRange reads the optional since and until query parameters, formatted like 2006-01-02T15:04:05Z07:00.
+// A zero time means no limit.
+func parseCampaignRange(c echo.Context) (since, until time.Time, err error) {
+	if sinceParam := c.QueryParam("since"); sinceParam != "" {
+		since, err = time.Parse(time.RFC3339, sinceParam)
+		if err != nil {
+			return since, until, newApiError(http.StatusBadRequest, fmt.Sprintf("invalid since: %s", sinceParam))
+		}
+	}
+	if untilParam := c.QueryParam("until"); untilParam != "" {
+		until, err = time.Parse(time.RFC3339, untilParam)
+		if err != nil {
+			return since, until, newApiError(http.StatusBadRequest, fmt.Sprintf("invalid until: %s", untilParam))
+		}
+	}
+	if !since.IsZero() && !until.IsZero() && !until.After(since) {
+		return since, until, newApiError(http.StatusBadRequest, "until is before since")
+	}
+	return
+}
+
+// campaignsInRange keeps the campaigns running at any time between since and until, so a campaign that ends after
+// since and starts before until.
+func campaignsInRange(campaigns []types.CampaignStruct, since, until time.Time) (inRange []types.CampaignStruct) {
+	if since.IsZero() && until.IsZero() {
+		return campaigns
+	}
+	inRange = []types.CampaignStruct{}
+	for _, campaign := range campaigns {
+		if (since.IsZero() || campaign.EndOn.After(since)) && (until.IsZero() || campaign.StartOn.Before(until)) {
+			inRange = append(inRange, campaign)
+		}
+	}
+	return
+}
+
+// getCampaigns lists the campaigns, of the tenant on the tenant endpoints. The optional since and until query
+// parameters only list the campaigns running at some time in between.
+func getCampaigns(c echo.Context) (err error) {
+	var since, until time.Time
+	since, until, err = parseCampaignRange(c)
+	if err != nil {
+		return
+	}
+
+	var campaigns []types.CampaignStruct
+	if tenantName := tenantOf(c); tenantName != "" {
+		campaigns, err = postgresDB.SelectTenantCampaigns(c.Request().Context(), tenantName)
+		if err != nil {
+			return
+		}
+		return listJSON(c, campaignsInRange(campaigns, since, until))
+	}
+
+	var version types.ListVersion
+	version, err = postgresDB.SelectCampaignsVersion(c.Request().Context())
+	if err != nil {
+		return
+	}
+	if listNotModified(c, version) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	campaigns, err = postgresDB.GetCampaigns(c.Request().Context())
+	if err != nil {
+		return
+	}
+
+	return listJSON(c, campaignsInRange(campaigns, since, until))
+}
+
+const headerETag = "ETag"
+const headerCacheControl = "Cache-Control"
+const headerIfNoneMatch = "If-None-Match"
+const headerIfMatch = "If-Match"
+
+// setVersionETag sets the strong ETag of the version of a campaign, bug or participant, which an update of it sends
+// back in If-Match. Nothing is set for an unknown version.
+func setVersionETag(c echo.Context, version int) {
+	if version > 0 {
+		c.Response().Header().Set(headerETag, fmt.Sprintf(`"%d"`, version))
+	}
+}
+
+// updateVersion is the version of a campaign, bug or participant an update was made from, read from the If-Match
+// header, or else from the version in the request body. Each update must name one, so it cannot overwrite a change it
+// has not seen.
+func updateVersion(c echo.Context, bodyVersion int) (version int, err error) {
+	ifMatch := strings.TrimSpace(c.Request().Header.Get(headerIfMatch))
+	if ifMatch == "" {
+		if bodyVersion < 1 {
+			return 0, newApiError(http.StatusPreconditionRequired, fmt.Sprintf("missing %s header or version", headerIfMatch))
+		}
+		return bodyVersion, nil
+	}
+
+	// a weak ETag, or a list of them, never matches a single version
+	version, err = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(ifMatch, `"`), `"`))
+	if err != nil || version < 1 {
+		return 0, newApiError(http.StatusBadRequest, fmt.Sprintf("invalid %s: %s", headerIfMatch, ifMatch))
+	}
+	if bodyVersion != 0 && bodyVersion != version {
+		return 0, newApiError(http.StatusBadRequest, fmt.Sprintf("%s %s and version %d differ", headerIfMatch, ifMatch, bodyVersion))
+	}
+	return
+}
+
+// weakETag names a version of a list. It is weak, as the list is not compared byte for byte.
+func weakETag(version types.ListVersion) string {
+	return fmt.Sprintf(`W/"%d-%d"`, version.Count, version.UpdatedOn.UnixNano())
+}
+
+// listNotModified sets the ETag of the list version about to be sent, and reports if the client already has it.
+// The list is read after its version, so a change in between is only sent again, never missed.
+func listNotModified(c echo.Context, version types.ListVersion) bool {
+	etag := weakETag(version)
+	c.Response().Header().Set(headerETag, etag)
+
+	for _, match := range strings.Split(c.Request().Header.Get(headerIfNoneMatch), ",") {
+		match = strings.TrimSpace(match)
+		if match == "*" || strings.TrimPrefix(match, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+const qpFields = "fields"
+
+// listJSON sends the list, a slice of structs, with only the fields named by the optional fields query parameter, like
+// loginName,score,teamName, so a client can leave out the fields it does not use.
+func listJSON(c echo.Context, list interface{}) (err error) {
+	fieldsParam := c.QueryParam(qpFields)
+	if fieldsParam == "" {
+		return c.JSON(http.StatusOK, list)
+	}
+
+	known := jsonFieldNames(reflect.TypeOf(list).Elem())
+	var fields []string
+	for _, field := range strings.Split(fieldsParam, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		if !known[field] {
+			return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid field: %s", field))
+		}
+		fields = append(fields, field)
+	}
+
+	var raw []byte
+	raw, err = json.Marshal(list)
+	if err != nil {
+		return
+	}
+	var items []map[string]json.RawMessage
+	if err = json.Unmarshal(raw, &items); err != nil {
+		return
+	}
+	if items == nil {
+		return c.JSON(http.StatusOK, list)
+	}
+
+	projected := make([]map[string]json.RawMessage, len(items))
+	for i, item := range items {
+		projected[i] = make(map[string]json.RawMessage, len(fields))
+		for _, field := range fields {
+			// a field left out when empty stays left out
+			if value, ok := item[field]; ok {
+				projected[i][field] = value
+			}
+		}
+	}
+	return c.JSON(http.StatusOK, projected)
+}
+
+// jsonFieldNames are the names of the fields of the struct type in JSON, including those of its embedded structs.
+func jsonFieldNames(t reflect.Type) (names map[string]bool) {
+	names = map[string]bool{}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if field.Anonymous && name == "" {
+			for embedded := range jsonFieldNames(field.Type) {
+				names[embedded] = true
+			}
+			continue
+		}
+		if field.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return
+}
+
+const msgTelemetry = "log-telemetry"
+const qpFeature = "feature"
+const qpCall = "call"
+
+// logTelemetry records the use of a UI feature reported in the query parameters. The use is logged before it is stored,
+// so a use the database turns away still shows up in the logs, and the request is answered either way.
+func logTelemetry(c echo.Context) {
+	feature := c.QueryParam(qpFeature)
+	call := c.QueryParam(qpCall)
+	if feature != "" && call != "" {
+		logger.Info(msgTelemetry,
+			zap.String(qpFeature, feature),
+			zap.String(qpCall, call),
+		)
+		if err := postgresDB.InsertTelemetryEvent(c.Request().Context(), feature, call, time.Now()); err != nil {
+			logger.Error("telemetry error", zap.String(qpFeature, feature), zap.String(qpCall, call), zap.Error(err))
+		}
+	}
+}
+
+const telemetryDateLayout = "2006-01-02"
+
+// getTelemetryUsage counts the use of each feature over the dates given by the from and to query parameters, both
+// inclusive and formatted like 2006-01-02. Without from, all usage until to is counted. Without to, usage until now
+// is counted.
+func getTelemetryUsage(c echo.Context) (err error) {
+	var from time.Time
+	if fromParam := c.QueryParam("from"); fromParam != "" {
+		from, err = time.Parse(telemetryDateLayout, fromParam)
+		if err != nil {
+			return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid from: %s", fromParam))
+		}
+	}
+	to := time.Now()
+	if toParam := c.QueryParam("to"); toParam != "" {
+		to, err = time.Parse(telemetryDateLayout, toParam)
+		if err != nil {
+			return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid to: %s", toParam))
+		}
+		// include all of the last day
+		to = to.AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		return newApiError(http.StatusBadRequest, "to is before from")
+	}
+
+	var usage []types.TelemetryUsage
+	usage, err = postgresDB.SelectTelemetryUsage(c.Request().Context(), from, to)
+	if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, usage)
+}
+
+func getActiveCampaigns(c echo.Context) (err error) {
+	logTelemetry(c)
+
+	current, err := postgresDB.GetActiveCampaigns(c.Request().Context(), time.Now())
+	if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, current)
+}
+
+// getUpcomingCampaigns lists the campaigns that have not started yet, the soonest first, so participants can register
+// before they start.
+func getUpcomingCampaigns(c echo.Context) (err error) {
+	logTelemetry(c)
+
+	var upcoming []types.CampaignStruct
+	upcoming, err = postgresDB.GetUpcomingCampaigns(c.Request().Context(), time.Now())
+	if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, upcoming)
+}
+
+// publicCacheControl lets browsers and proxies cache a successful response of the public API for as long as the
+// leaderboard takes to refresh, as the response would not change any sooner. Errors are not cached.
+func publicCacheControl(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) (err error) {
+		c.Response().Header().Set(headerCacheControl, fmt.Sprintf("public, max-age=%d", int(leaderboardRefreshInterval.Seconds())))
+		if err = next(c); err != nil {
+			c.Response().Header().Del(headerCacheControl)
+		}
+		return
+	}
+}
+
+// getPublicCampaigns lists the campaigns of the deployment admin, with only the fields fit to show on other sites.
+// Tenant campaigns are left out.
+func getPublicCampaigns(c echo.Context) (err error) {
+	logTelemetry(c)
+
+	var version types.ListVersion
+	version, err = postgresDB.SelectCampaignsVersion(c.Request().Context())
+	if err != nil {
+		return
+	}
+	if listNotModified(c, version) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	var campaigns []types.CampaignStruct
+	campaigns, err = postgresDB.SelectPublicCampaigns(c.Request().Context())
+	if err != nil {
+		return
+	}
+
+	publicCampaigns := []types.PublicCampaign{}
+	for _, campaign := range campaigns {
+		publicCampaigns = append(publicCampaigns, types.PublicCampaign{
+			Name:     campaign.Name,
+			StartOn:  campaign.StartOn,
+			EndOn:    campaign.EndOn,
+			TimeZone: campaign.TimeZone,
+			Status:   campaign.Status,
+		})
+	}
+	return c.JSON(http.StatusOK, publicCampaigns)
+}
+
+func addCampaign(c echo.Context) (err error) {
+	campaignName := strings.TrimSpace(c.Param(ParamCampaignName))
+	if len(campaignName) == 0 {
+		err = fmt.Errorf("invalid parameter %s: %s", ParamCampaignName, campaignName)
+		logger.Error("addCampaign", zap.Error(err))
+
+		return newApiError(http.StatusBadRequest, err.Error())
+	}
+
+	campaignFromRequest := types.CampaignStruct{}
+	err = json.NewDecoder(c.Request().Body).Decode(&campaignFromRequest)
+	if err != nil {
+		return
+	}
+	campaignFromRequest.Name = campaignName
+	// only the tenant endpoints add a campaign of a tenant
+	campaignFromRequest.TenantName = tenantOf(c)
+	if err = validateRequest(&campaignFromRequest); err != nil {
+		return
+	}
+
+	var guid string
+	guid, err = postgresDB.InsertCampaign(c.Request().Context(), &campaignFromRequest)
+	if err != nil {
+		return
+	}
+
+	creation := newCreation(guid, campaignFromRequest)
+	addEndpoint(c, &creation, "campaignDetail", "campaign-detail", http.MethodGet, campaignName)
+	addEndpoint(c, &creation, "campaignUpdate", "campaign-update", http.MethodPut, campaignName)
+	return sendCreation(c, creation, v1CreationId)
+}
+
+func updateCampaign(c echo.Context) (err error) {
+	campaignName := strings.TrimSpace(c.Param(ParamCampaignName))
+	if len(campaignName) == 0 {
+		err = fmt.Errorf("invalid parameter %s: %s", ParamCampaignName, campaignName)
+		logger.Error("updateCampaign", zap.Error(err))
+
+		return newApiError(http.StatusBadRequest, err.Error())
+	}
+
+	// update campaign stored in db
+	campaignFromRequest := types.CampaignStruct{}
+	err = json.NewDecoder(c.Request().Body).Decode(&campaignFromRequest)
+	if err != nil {
+		return
+	}
+
+	// force use of path parameter campaign name value
+	campaignFromRequest.Name = campaignName
+	if err = validateRequest(&campaignFromRequest); err != nil {
+		return
+	}
+	if campaignFromRequest.Version, err = updateVersion(c, campaignFromRequest.Version); err != nil {
+		return
+	}
+
+	var guid string
+	guid, err = postgresDB.UpdateCampaign(c.Request().Context(), &campaignFromRequest)
+	if err != nil {
+		return
+	}
+
+	setVersionETag(c, campaignFromRequest.Version)
+	return c.String(http.StatusOK, guid)
+}
+
+// validateCampaignConfig checks the bugs of the config name each category once. The bugs themselves are checked by
+// validateRequest.
+func validateCampaignConfig(config *types.CampaignConfigStruct) (err error) {
+	categories := make(map[string]bool)
+	for _, bug := range config.Bugs {
+		if categories[bug.Category] {
+			err = fmt.Errorf("campaign config is not valid, duplicate category: %s", bug.Category)
+			logger.Error("validateCampaignConfig error", zap.Error(err))
+			return
+		}
+		categories[bug.Category] = true
+	}
+	return
+}
+
+func getCampaignConfigStage(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	var config *types.CampaignConfigStruct
+	config, err = postgresDB.SelectCampaignConfigStage(c.Request().Context(), campaignName)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no staged config: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, config)
+}
+
+// putCampaignConfigStage replaces the staged configuration of the campaign. The live configuration is not touched
+// until the stage is promoted.
+func putCampaignConfigStage(c echo.Context) (err error) {
+	campaignName := strings.TrimSpace(c.Param(ParamCampaignName))
+	if len(campaignName) == 0 {
+		err = fmt.Errorf("invalid parameter %s: %s", ParamCampaignName, campaignName)
+		logger.Error("putCampaignConfigStage", zap.Error(err))
+
+		return newApiError(http.StatusBadRequest, err.Error())
+	}
+
+	config := types.CampaignConfigStruct{}
+	err = json.NewDecoder(c.Request().Body).Decode(&config)
+	if err != nil {
+		return
+	}
+
+	// force use of path parameter campaign name value
+	config.Campaign = campaignName
+	for i := range config.Bugs {
+		config.Bugs[i].Campaign = campaignName
+	}
+
+	if err = validateRequest(&config); err != nil {
+		return
+	}
+	if err = validateCampaignConfig(&config); err != nil {
+		return newApiError(http.StatusBadRequest, err.Error())
+	}
+
+	err = postgresDB.UpsertCampaignConfigStage(c.Request().Context(), &config)
+	if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, config)
+}
+
+func deleteCampaignConfigStage(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.DeleteCampaignConfigStage(c.Request().Context(), campaignName)
+	if err != nil {
+		return
+	}
+	if rowsAffected > 0 {
+		return c.NoContent(http.StatusNoContent)
+	}
+	return newApiError(http.StatusNotFound, fmt.Sprintf("no staged config: campaignName: %s", campaignName))
+}
+
+func promoteCampaignConfigStage(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	var config *types.CampaignConfigStruct
+	config, err = postgresDB.PromoteCampaignConfigStage(c.Request().Context(), campaignName)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no staged config: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+
+	logger.Info("promoted staged campaign config", zap.Any("config", config))
+	return c.JSON(http.StatusOK, config)
+}
+
+func getSlackNotification(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	var config *types.SlackNotificationStruct
+	config, err = postgresDB.SelectSlackNotification(c.Request().Context(), campaignName)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no slack notification: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, config)
+}
+
+func putSlackNotification(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	config := types.SlackNotificationStruct{}
+	err = json.NewDecoder(c.Request().Body).Decode(&config)
+	if err != nil {
+		return
+	}
+
+	// force use of path parameter campaign name value
+	config.CampaignName = campaignName
+
+	if err = validateRequest(&config); err != nil {
+		return
+	}
+
+	err = postgresDB.UpsertSlackNotification(c.Request().Context(), &config)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, config)
+}
+
+func getCampaignPenalty(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	var penalty *types.CampaignPenaltyStruct
+	penalty, err = postgresDB.SelectCampaignPenalty(c.Request().Context(), campaignName)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign penalty: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, penalty)
+}
+
+func putCampaignPenalty(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	penalty := types.CampaignPenaltyStruct{}
+	err = json.NewDecoder(c.Request().Body).Decode(&penalty)
+	if err != nil {
+		return
+	}
+
+	// force use of path parameter campaign name value
+	penalty.CampaignName = campaignName
+
+	if err = validateRequest(&penalty); err != nil {
+		return
+	}
+
+	err = postgresDB.UpsertCampaignPenalty(c.Request().Context(), &penalty)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, penalty)
+}
+
+func getCoAuthorPolicy(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	var policy *types.CoAuthorPolicyStruct
+	policy, err = postgresDB.SelectCoAuthorPolicy(c.Request().Context(), campaignName)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no co-author policy: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, policy)
+}
+
+// putCoAuthorPolicy sets how the campaign scores the co-authors of a pull request. Points already scored are not
+// changed until their pull request is scored again.
+func putCoAuthorPolicy(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	policy := types.CoAuthorPolicyStruct{}
+	err = json.NewDecoder(c.Request().Body).Decode(&policy)
+	if err != nil {
+		return
+	}
+
+	// force use of path parameter campaign name value
+	policy.CampaignName = campaignName
+
+	if err = validateRequest(&policy); err != nil {
+		return
+	}
+
+	err = postgresDB.UpsertCoAuthorPolicy(c.Request().Context(), &policy)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, policy)
+}
+
+func getCampaignPrivacy(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	var privacy *types.CampaignPrivacyStruct
+	privacy, err = postgresDB.SelectCampaignPrivacy(c.Request().Context(), campaignName)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no privacy setting: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, privacy)
+}
+
+// putCampaignPrivacy sets whether the public leaderboards of the campaign show pseudonyms rather than login names. The
+// admin endpoints always show login names.
+func putCampaignPrivacy(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	privacy := types.CampaignPrivacyStruct{}
+	err = json.NewDecoder(c.Request().Body).Decode(&privacy)
+	if err != nil {
+		return
+	}
+
+	// force use of path parameter campaign name value
+	privacy.CampaignName = campaignName
+
+	err = postgresDB.UpsertCampaignPrivacy(c.Request().Context(), &privacy)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+
+	logger.Info("campaign privacy updated", zap.String("campaignName", campaignName), zap.Bool("anonymize", privacy.Anonymize))
+	return c.JSON(http.StatusOK, privacy)
+}
+
+func getDuplicatePolicy(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	var policy *types.DuplicatePolicyStruct
+	policy, err = postgresDB.SelectDuplicatePolicy(c.Request().Context(), campaignName)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no duplicate policy: campaignName: %s", campaignName))
+	} else if err != nil {
 		return
 	}
-	for _, participantToScore := range activeParticipantsToScore {
 
-		newPoints := scorePoints(msg, participantToScore.CampaignName)
+	return c.JSON(http.StatusOK, policy)
+}
 
-		oldPoints := scoreDb.SelectPriorScore(&participantToScore, msg)
+// putDuplicatePolicy sets how the campaign scores a fixed bug that another participant fixed first. Points already
+// scored are not changed until their pull request is scored again.
+func putDuplicatePolicy(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
 
-		err = scoreDb.InsertScoringEvent(&participantToScore, msg, newPoints)
-		if err != nil {
-			return
-		}
+	policy := types.DuplicatePolicyStruct{}
+	err = json.NewDecoder(c.Request().Body).Decode(&policy)
+	if err != nil {
+		return
+	}
 
-		err = scoreDb.UpdateParticipantScore(&participantToScore, newPoints-oldPoints)
-		if err != nil {
-			return
-		}
+	// force use of path parameter campaign name value
+	policy.CampaignName = campaignName
 
-		logger.Debug("score updated",
-			zap.Float64("newPoints", newPoints), zap.Float64("oldPoints", oldPoints), zap.Any("ScoringMessage", msg))
+	if err = validateRequest(&policy); err != nil {
+		return
 	}
-	return
+
+	err = postgresDB.UpsertDuplicatePolicy(c.Request().Context(), &policy)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, policy)
 }
 
-func getParticipantDetail(c echo.Context) (err error) {
+func getCampaignCaps(c echo.Context) (err error) {
 	campaignName := c.Param(ParamCampaignName)
-	scpName := c.Param(ParamScpName)
-	loginName := c.Param(ParamLoginName)
-	logger.Debug("getting detail for campaign",
-		zap.String("campaignName", campaignName), zap.String("scpName", scpName), zap.String("loginName", loginName))
 
-	var participant *types.ParticipantStruct
-	participant, err = postgresDB.SelectParticipantDetail(campaignName, scpName, loginName)
-	if err != nil {
+	var caps *types.CampaignCapsStruct
+	caps, err = postgresDB.SelectCampaignCaps(c.Request().Context(), campaignName)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign caps: campaignName: %s", campaignName))
+	} else if err != nil {
 		return
 	}
 
-	return c.JSON(http.StatusOK, participant)
+	return c.JSON(http.StatusOK, caps)
 }
 
-func getParticipantsList(c echo.Context) (err error) {
-	logTelemetry(c)
-
+// putCampaignCaps sets the most points a pull request, and a participant in a day, score in the campaign. Points
+// already scored are not capped again until their pull request is scored again.
+func putCampaignCaps(c echo.Context) (err error) {
 	campaignName := c.Param(ParamCampaignName)
-	logger.Debug("Getting participant list for campaign", zap.String("campaignName", campaignName))
 
-	var participants []types.ParticipantStruct
-	participants, err = postgresDB.SelectParticipantsInCampaign(campaignName)
+	caps := types.CampaignCapsStruct{}
+	err = json.NewDecoder(c.Request().Body).Decode(&caps)
 	if err != nil {
 		return
 	}
 
-	return c.JSON(http.StatusOK, participants)
-}
+	// force use of path parameter campaign name value
+	caps.CampaignName = campaignName
 
-func updateParticipant(c echo.Context) (err error) {
-	participant := types.ParticipantStruct{}
+	if err = validateRequest(&caps); err != nil {
+		return
+	}
 
-	err = json.NewDecoder(c.Request().Body).Decode(&participant)
-	if err != nil {
+	err = postgresDB.UpsertCampaignCaps(c.Request().Context(), &caps)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+	} else if err != nil {
 		return
 	}
 
+	return c.JSON(http.StatusOK, caps)
+}
+
+func deleteSlackNotification(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
 	var rowsAffected int64
-	rowsAffected, err = postgresDB.UpdateParticipant(&participant)
+	rowsAffected, err = postgresDB.DeleteSlackNotification(c.Request().Context(), campaignName)
 	if err != nil {
 		return
 	}
-
-	if rowsAffected == 1 {
-		logger.Info("participant updated", zap.Any("participant", participant))
+	if rowsAffected > 0 {
 		return c.NoContent(http.StatusNoContent)
-	} else {
-		logger.Error("no participant row was updated, something goofy has occurred",
-			zap.Any("participant", participant), zap.Int64("rowsAffected", rowsAffected))
-		return c.NoContent(http.StatusBadRequest)
 	}
+	return newApiError(http.StatusNotFound, fmt.Sprintf("no slack notification: campaignName: %s", campaignName))
 }
 
-func deleteParticipant(c echo.Context) (err error) {
-	campaign := c.Param(ParamCampaignName)
-	scpName := c.Param(ParamScpName)
-	loginName := c.Param(ParamLoginName)
+func webhookSubscribes(hook types.WebhookStruct, eventName string) bool {
+	if len(hook.Events) == 0 {
+		return true
+	}
+	for _, subscribed := range hook.Events {
+		if subscribed == eventName {
+			return true
+		}
+	}
+	return false
+}
 
-	var participantId string
-	participantId, err = postgresDB.DeleteParticipant(campaign, scpName, loginName)
+// publishWebhookEvent sends the event to every webhook subscribed to it, each delivered and retried in the background.
+// When the webhooks can not be read, the event is logged and dropped; the change it is about stands.
+func publishWebhookEvent(ctx context.Context, eventName string, data interface{}) {
+	hooks, err := postgresDB.SelectWebhooks(ctx)
 	if err != nil {
+		logger.Error("webhook lookup error", zap.String("event", eventName), zap.Error(err))
 		return
 	}
 
-	return c.JSON(http.StatusOK, fmt.Sprintf("deleted participant: campaign: %s, scpName: %s, loginName: %s, participant.id: %s",
-		campaign, scpName, loginName, participantId))
-}
-
-// was not seeing enough detail when addParticipant() returns error, so capturing such cases in the log.
-func logAddParticipant(c echo.Context) (err error) {
-	if err = addParticipant(c); err != nil {
-		logger.Error("error calling addParticipant", zap.Error(err))
+	event := types.WebhookEvent{Event: eventName, OccurredOn: time.Now(), Data: data}
+	for _, hook := range hooks {
+		if webhookSubscribes(hook, eventName) {
+			webhookDeliverer.Deliver(hook, event)
+		}
 	}
-	return
 }
 
-func addParticipant(c echo.Context) (err error) {
-	participant := types.ParticipantStruct{}
-
-	err = json.NewDecoder(c.Request().Body).Decode(&participant)
+func addWebhook(c echo.Context) (err error) {
+	hook := types.WebhookStruct{}
+	err = json.NewDecoder(c.Request().Body).Decode(&hook)
 	if err != nil {
 		return
 	}
+	if err = validateRequest(&hook); err != nil {
+		return
+	}
+	if secrets.IsReference(hook.Secret) {
+		// found now, rather than when the first event fails to be signed
+		if _, err = secretResolvers.Resolve(c.Request().Context(), hook.Secret); err != nil {
+			return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid secret: %v", err))
+		}
+	}
 
-	err = postgresDB.InsertParticipant(&participant)
+	err = postgresDB.InsertWebhook(c.Request().Context(), &hook)
 	if err != nil {
 		return
 	}
 
-	detailUri := c.Echo().Reverse("participant-detail", participant.LoginName)
-	updateUri := c.Echo().Reverse("participant-update")
-	endpoints := make(map[string]interface{})
-	endpoints["participantDetail"] = endpointDetail{URI: detailUri, Verb: "GET"}
-	endpoints["participantUpdate"] = endpointDetail{URI: updateUri, Verb: "PUT"}
+	// the secret is never sent back
+	hook.Secret = ""
+	creation := newCreation(hook.ID, hook)
+	addEndpoint(c, &creation, "webhookList", "webhook-list", http.MethodGet)
+	addEndpoint(c, &creation, "webhookDelete", "webhook-delete", http.MethodDelete, hook.ID)
+	return sendCreation(c, creation, v1CreationObject)
+}
 
-	creation := creationResponse{
-		Id:        participant.ID,
-		Endpoints: endpoints,
-		Object:    participant,
+func getWebhooks(c echo.Context) (err error) {
+	var hooks []types.WebhookStruct
+	hooks, err = postgresDB.SelectWebhooks(c.Request().Context())
+	if err != nil {
+		return
 	}
 
-	return c.JSON(http.StatusCreated, creation)
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	return c.JSON(http.StatusOK, hooks)
 }
 
-func addTeam(c echo.Context) (err error) {
-	team := types.TeamStruct{}
+func deleteWebhook(c echo.Context) (err error) {
+	webhookId := c.Param(ParamWebhookId)
 
-	err = json.NewDecoder(c.Request().Body).Decode(&team)
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.DeleteWebhook(c.Request().Context(), webhookId)
 	if err != nil {
 		return
 	}
+	if rowsAffected > 0 {
+		return c.NoContent(http.StatusNoContent)
+	}
+	return newApiError(http.StatusNotFound, fmt.Sprintf("no webhook: webhookId: %s", webhookId))
+}
 
-	err = postgresDB.InsertTeam(&team)
+// errorReporterFromConfig returns the reporter to the configured Sentry project. Errors are not reported when no
+// project is configured, or its DSN is not valid.
+func errorReporterFromConfig(cfg *config.Config) errreport.Reporter {
+	if cfg.SentryDsn == "" {
+		return errreport.NoReporter{}
+	}
+	reporter, err := errreport.NewSentry(logger, cfg.SentryDsn, buildversion.BuildVersion, cfg.SentryEnvironment, errorReportTimeout)
 	if err != nil {
-		return
+		logger.Error("error reporting not started", zap.Error(err))
+		return errreport.NoReporter{}
 	}
+	logger.Info("error reporting enabled", zap.String("environment", cfg.SentryEnvironment))
+	return reporter
+}
 
-	return c.String(http.StatusCreated, team.Id)
+// secretResolversFromConfig returns the resolvers of the configured secrets backends, Vault and AWS Secrets Manager.
+func secretResolversFromConfig(cfg *config.Config) *secrets.Resolvers {
+	schemes := map[string]secrets.Resolver{}
+	if cfg.VaultAddr != "" {
+		schemes[secrets.SchemeVault] = secrets.NewVault(cfg.VaultAddr, cfg.VaultToken, secretsBackendTimeout)
+	}
+	if cfg.AwsRegion != "" {
+		schemes[secrets.SchemeAwsSecretsManager] = secrets.NewAwsSecretsManager(cfg.AwsRegion, cfg.AwsAccessKeyId,
+			cfg.AwsSecretAccessKey, cfg.AwsSessionToken, secretsBackendTimeout)
+	}
+	return secrets.NewResolvers(schemes)
 }
 
-func addPersonToTeam(c echo.Context) (err error) {
-	teamName := c.Param(ParamTeamName)
-	campaignName := c.Param(ParamCampaignName)
-	scpName := c.Param(ParamScpName)
-	loginName := c.Param(ParamLoginName)
+// resolveSecrets replaces each secret of the config that names a secret in a secrets backend with the secret.
+func resolveSecrets(cfg *config.Config) error {
+	ctx, cancel := context.WithTimeout(context.Background(), secretsBackendTimeout)
+	defer cancel()
+	return cfg.ResolveSecrets(func(value string) (string, error) {
+		return secretResolvers.Resolve(ctx, value)
+	})
+}
 
-	if teamName == "" || campaignName == "" || scpName == "" || loginName == "" {
-		return c.NoContent(http.StatusBadRequest)
+// resolveWebhookSecret returns the secret of a webhook, fetching it when it names a secret in a secrets backend
+func resolveWebhookSecret(secret string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretsBackendTimeout)
+	defer cancel()
+	return secretResolvers.Resolve(ctx, secret)
+}
+
+// mailerFromConfig returns the mailer of the configured SMTP server, such as the SMTP interface of Amazon SES. Nil when
+// no server or from address is configured.
+func mailerFromConfig(cfg *config.Config) mail.Mailer {
+	if cfg.SmtpHost == "" || cfg.MailFrom == "" {
+		return nil
+	}
+	port := cfg.SmtpPort
+	if port < 1 {
+		port = config.Defaults().SmtpPort
 	}
+	return mail.NewSMTP(logger, cfg.SmtpHost, port, cfg.SmtpUsername, cfg.SmtpPassword, cfg.MailFrom)
+}
 
-	var rowsAffected int64
-	rowsAffected, err = postgresDB.UpdateParticipantTeam(teamName, campaignName, scpName, loginName)
+// emailData is what the campaign email templates can refer to, for example {{.Participant.LoginName}}. Rank and
+// ParticipantCount are only set for the campaign end email.
+type emailData struct {
+	CampaignName     string
+	Participant      types.ParticipantStruct
+	Rank             int
+	ParticipantCount int
+}
+
+func renderEmailTemplate(name, text string, data emailData) (rendered string, err error) {
+	tmpl, err := texttemplate.New(name).Parse(text)
 	if err != nil {
 		return
 	}
+	var b strings.Builder
+	err = tmpl.Execute(&b, data)
+	rendered = b.String()
+	return
+}
 
-	if rowsAffected > 0 {
-		logger.Info("team updated",
-			zap.String("teamName", teamName), zap.String("campaignName", campaignName),
-			zap.String("scpName", scpName), zap.String("loginName", loginName))
-
-		return c.NoContent(http.StatusNoContent)
-	} else {
-		logger.Error("no team row was updated, something goofy has occurred",
-			zap.String("teamName", teamName), zap.String("campaignName", campaignName),
-			zap.String("scpName", scpName), zap.String("loginName", loginName))
-
-		return c.NoContent(http.StatusBadRequest)
+func renderCampaignEmail(email *types.CampaignEmailStruct, data emailData) (subject, body string, err error) {
+	subject, err = renderEmailTemplate("subject", email.Subject, data)
+	if err != nil {
+		return
 	}
+	body, err = renderEmailTemplate("body", email.Body, data)
+	return
 }
 
-func validateBug(bugToValidate *types.BugStruct) (err error) {
-	if len(bugToValidate.Campaign) == 0 {
-		err = fmt.Errorf("bug is not valid, empty campaign: bug: %+v", bugToValidate)
-	} else if len(bugToValidate.Category) == 0 {
-		err = fmt.Errorf("bug is not valid, empty category: bug: %+v", bugToValidate)
-	} else if bugToValidate.PointValue < 0 {
-		err = fmt.Errorf("bug is not valid, negative PointValue: bug: %+v", bugToValidate)
+// loadCampaignEmail reads the email template of the campaign. Nil when there is no mailer, or the campaign does not
+// send this kind of email.
+func loadCampaignEmail(ctx context.Context, campaignName, kind string) *types.CampaignEmailStruct {
+	if mailer == nil {
+		return nil
 	}
+	email, err := postgresDB.SelectCampaignEmail(ctx, campaignName, kind)
 	if err != nil {
-		logger.Error("validateBug error", zap.Error(err))
+		if err != sql.ErrNoRows {
+			logger.Error("campaign email error", zap.String("campaignName", campaignName), zap.String("kind", kind), zap.Error(err))
+		}
+		return nil
 	}
-	return
+	if !email.Enabled {
+		return nil
+	}
+	return email
 }
 
-func addBug(c echo.Context) (err error) {
-	bug := types.BugStruct{}
-
-	err = json.NewDecoder(c.Request().Body).Decode(&bug)
+// sendCampaignEmail sends the email to the participant, if there is an email and the participant has an address. An
+// email whose template can not be rendered is logged and not sent, and the mailer sends in the background, so the
+// caller never waits on, or fails with, the mail server.
+func sendCampaignEmail(email *types.CampaignEmailStruct, data emailData) {
+	if email == nil || data.Participant.Email == "" {
+		return
+	}
+	subject, body, err := renderCampaignEmail(email, data)
 	if err != nil {
-		logger.Error("error decoding bug body", zap.Error(err))
+		logger.Error("campaign email template error", zap.String("campaignName", email.CampaignName), zap.String("kind", email.Kind), zap.Error(err))
 		return
 	}
+	mailer.Send(mail.Message{To: data.Participant.Email, Subject: subject, Body: body})
+}
 
-	if err = validateBug(&bug); err != nil {
+func emailTeamAssignment(ctx context.Context, campaignName, scpName, loginName string) {
+	email := loadCampaignEmail(ctx, campaignName, types.EmailKindTeamAssignment)
+	if email == nil {
+		return
+	}
+	participant, err := postgresDB.SelectParticipantDetail(ctx, campaignName, scpName, loginName)
+	if err != nil {
+		logger.Error("team assignment email error", zap.String("campaignName", campaignName), zap.String("loginName", loginName), zap.Error(err))
 		return
 	}
+	sendCampaignEmail(email, emailData{CampaignName: campaignName, Participant: *participant})
+}
 
-	err = postgresDB.InsertBug(&bug)
+// emailFinalRanks sends every participant of the ended campaign their final rank. Tied participants share a rank.
+func emailFinalRanks(ctx context.Context, campaignName string) {
+	email := loadCampaignEmail(ctx, campaignName, types.EmailKindCampaignEnd)
+	if email == nil {
+		return
+	}
+	participants, err := postgresDB.SelectParticipantsInCampaign(ctx, campaignName)
 	if err != nil {
+		logger.Error("campaign end email error", zap.String("campaignName", campaignName), zap.Error(err))
 		return
 	}
 
-	creation := creationResponse{
-		Id:     bug.Id,
-		Object: bug,
+	sort.SliceStable(participants, func(i, j int) bool {
+		return participants[i].Score > participants[j].Score
+	})
+	rank := 0
+	for i, participant := range participants {
+		if i == 0 || participant.Score < participants[i-1].Score {
+			rank = i + 1
+		}
+		sendCampaignEmail(email, emailData{CampaignName: campaignName, Participant: participant, Rank: rank, ParticipantCount: len(participants)})
 	}
-	return c.JSON(http.StatusCreated, creation)
 }
 
-func updateBug(c echo.Context) (err error) {
-	campaign := c.Param(ParamCampaignName)
-	category := c.Param(ParamBugCategory)
-	pointValue, err := strconv.Atoi(c.Param(ParamPointValue))
+func getCampaignEmails(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	var emails []types.CampaignEmailStruct
+	emails, err = postgresDB.SelectCampaignEmails(c.Request().Context(), campaignName)
 	if err != nil {
 		return
 	}
+	return c.JSON(http.StatusOK, emails)
+}
 
-	bug := types.BugStruct{Campaign: campaign, Category: category, PointValue: pointValue}
-	if err = validateBug(&bug); err != nil {
+// putCampaignEmail sets the template of a kind of email of the campaign. The template must render for an empty
+// participant, so a mistake is found here instead of when the email is sent.
+func putCampaignEmail(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	email := types.CampaignEmailStruct{}
+	err = json.NewDecoder(c.Request().Body).Decode(&email)
+	if err != nil {
 		return
 	}
 
-	logger.Debug(category)
+	// force use of path parameter values
+	email.CampaignName = campaignName
+	email.Kind = c.Param(ParamEmailKind)
 
-	var rowsAffected int64
-	rowsAffected, err = postgresDB.UpdateBug(&bug)
-	if err != nil {
+	if err = validateRequest(&email); err != nil {
 		return
 	}
-	if rowsAffected < 1 {
-		return c.String(http.StatusNotFound, "Bug Category not found")
+	if _, _, err = renderCampaignEmail(&email, emailData{}); err != nil {
+		return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid email template: %s", err.Error()))
 	}
 
-	return c.String(http.StatusOK, "Success")
+	err = postgresDB.UpsertCampaignEmail(c.Request().Context(), &email)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
+	}
+
+	return c.JSON(http.StatusOK, email)
 }
 
-func getBugs(c echo.Context) (err error) {
-	var bugs []types.BugStruct
-	bugs, err = postgresDB.SelectBugs()
+func getRepositoryFilters(c echo.Context) (err error) {
+	var filters []types.RepositoryFilter
+	filters, err = postgresDB.SelectRepositoryFilters(c.Request().Context(), c.Param(ParamCampaignName))
 	if err != nil {
 		return
 	}
 
-	return c.JSON(http.StatusOK, bugs)
+	return listJSON(c, filters)
 }
 
-func putBugs(c echo.Context) (err error) {
-	var bugs []types.BugStruct
-	err = json.NewDecoder(c.Request().Body).Decode(&bugs)
+// addRepositoryFilter adds an allow or deny pattern of the repository names scored in the campaign. The pattern is
+// that of path.Match, so "*-docs" denies every docs repository.
+func addRepositoryFilter(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	filter := types.RepositoryFilter{}
+	err = json.NewDecoder(c.Request().Body).Decode(&filter)
 	if err != nil {
-		logger.Error("error decoding bug body", zap.Error(err))
 		return
 	}
 
-	var inserted []types.BugStruct
-	for _, bug := range bugs {
-		if err = validateBug(&bug); err != nil {
-			return
-		}
+	// force use of path parameter values
+	filter.CampaignName = campaignName
 
-		err = postgresDB.InsertBug(&bug)
-		if err != nil {
-			logger.Error("error inserting bug", zap.Any("bug", bug), zap.Error(err))
-			return
-		}
-		inserted = append(inserted, bug)
+	if err = validateRequest(&filter); err != nil {
+		return
+	}
+	if _, err = path.Match(filter.Pattern, ""); err != nil {
+		return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid pattern: %s", filter.Pattern))
 	}
 
-	response := creationResponse{
-		Id:     inserted[0].Id,
-		Object: inserted,
+	err = postgresDB.InsertRepositoryFilter(c.Request().Context(), &filter)
+	if err == sql.ErrNoRows {
+		return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+	} else if err != nil {
+		return
 	}
+	logger.Info("repository filter added", zap.Any("filter", filter))
 
-	return c.JSON(http.StatusCreated, response)
+	creation := newCreation(filter.Id, filter)
+	addEndpoint(c, &creation, "repositoryFilterList", "campaign-repository-filter-list", http.MethodGet, campaignName)
+	addEndpoint(c, &creation, "repositoryFilterDelete", "campaign-repository-filter-delete", http.MethodDelete, campaignName, filter.Id)
+	return sendCreation(c, creation, v1CreationEnvelope)
 }
 
-func getCampaigns(c echo.Context) (err error) {
-	var campaigns []types.CampaignStruct
-	campaigns, err = postgresDB.GetCampaigns()
+func deleteRepositoryFilter(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+	filterId := c.Param(ParamRepositoryFilter)
+
+	var rowsAffected int64
+	rowsAffected, err = postgresDB.DeleteRepositoryFilter(c.Request().Context(), campaignName, filterId)
 	if err != nil {
 		return
 	}
-
-	return c.JSON(http.StatusOK, campaigns)
+	if rowsAffected > 0 {
+		return c.NoContent(http.StatusNoContent)
+	}
+	return newApiError(http.StatusNotFound, fmt.Sprintf("no repository filter: campaignName: %s, id: %s", campaignName, filterId))
 }
 
-const msgTelemetry = "log-telemetry"
-const qpFeature = "feature"
-const qpCall = "call"
-
-func logTelemetry(c echo.Context) {
-	feature := c.QueryParam(qpFeature)
-	call := c.QueryParam(qpCall)
-	if feature != "" && call != "" {
-		logger.Info(msgTelemetry,
-			zap.String(qpFeature, feature),
-			zap.String(qpCall, call),
-		)
-	}
+// defaultReportTopCount is the number of winning participants and teams listed in a campaign report
+const defaultReportTopCount = 3
+
+const reportFormatHtml = "html"
+
+var campaignReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Campaign.Name}} report</title></head>
+<body>
+<h1>{{.Campaign.Name}}</h1>
+<p>{{.Campaign.StartOn.Format "2006-01-02"}} to {{.Campaign.EndOn.Format "2006-01-02"}}, generated {{.GeneratedOn.Format "2006-01-02 15:04 MST"}}</p>
+<h2>Stats</h2>
+<ul>
+<li>Participants: {{.Stats.Participants}}</li>
+<li>Teams: {{.Stats.Teams}}</li>
+<li>Pull requests: {{.Stats.PullRequests}}</li>
+<li>Points: {{.Stats.Points}}</li>
+<li>Judged award points: {{.Stats.JudgedAwardPoints}}</li>
+</ul>
+<h2>Top participants</h2>
+<table>
+<tr><th>Login</th><th>Team</th><th>Score</th></tr>
+{{range .TopParticipants}}<tr><td>{{.LoginName}}</td><td>{{.TeamName}}</td><td>{{.Score}}</td></tr>
+{{end}}</table>
+<h2>Top teams</h2>
+<table>
+<tr><th>Team</th><th>Member points</th><th>Award points</th><th>Points</th></tr>
+{{range .TopTeams}}<tr><td>{{.TeamName}}</td><td>{{.MemberPoints}}</td><td>{{.AwardPoints}}</td><td>{{.Points}}</td></tr>
+{{end}}</table>
+<h2>Categories</h2>
+<table>
+<tr><th>Category</th><th>Fixed</th><th>Points</th></tr>
+{{range .Categories}}<tr><td>{{.Category}}</td><td>{{.Count}}</td><td>{{.Points}}</td></tr>
+{{end}}</table>
+<h2>Repositories</h2>
+<table>
+<tr><th>Repository</th><th>Pull requests</th><th>Points</th></tr>
+{{range .Repositories}}<tr><td>{{.RepoOwner}}/{{.RepoName}}</td><td>{{.PullRequests}}</td><td>{{.Points}}</td></tr>
+{{end}}</table>
+<h2>Timeline</h2>
+<table>
+<tr><th>Day</th><th>Pull requests</th><th>Points</th><th>Participants joined</th></tr>
+{{range .Timeline}}<tr><td>{{.Day.Format "2006-01-02"}}</td><td>{{.PullRequests}}</td><td>{{.Points}}</td><td>{{.ParticipantsJoined}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func renderCampaignReport(report *types.CampaignReport) (html string, err error) {
+	var sb strings.Builder
+	err = campaignReportTemplate.Execute(&sb, report)
+	html = sb.String()
+	return
 }
 
-func getActiveCampaigns(c echo.Context) (err error) {
-	logTelemetry(c)
+// createCampaignReport assembles the end of campaign report, and stores it for later download. The optional top query
+// parameter sets how many winning participants and teams are listed.
+func createCampaignReport(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
 
-	current, err := postgresDB.GetActiveCampaigns(time.Now())
-	if err != nil {
-		return c.String(http.StatusBadRequest, err.Error())
+	topCount := defaultReportTopCount
+	if top := c.QueryParam("top"); top != "" {
+		topCount, err = strconv.Atoi(top)
+		if err != nil || topCount < 1 {
+			return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid top: %s", top))
+		}
 	}
 
-	return c.JSON(http.StatusOK, current)
-}
-
-func addCampaign(c echo.Context) (err error) {
-	campaignName := strings.TrimSpace(c.Param(ParamCampaignName))
-	if len(campaignName) == 0 {
-		err = fmt.Errorf("invalid parameter %s: %s", ParamCampaignName, campaignName)
-		logger.Error("addCampaign", zap.Error(err))
-
-		return c.String(http.StatusBadRequest, err.Error())
+	var report *types.CampaignReport
+	report, err = postgresDB.SelectCampaignReport(c.Request().Context(), campaignName, topCount, time.Now())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return newApiError(http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaignName))
+		}
+		return
 	}
 
-	campaignFromRequest := types.CampaignStruct{}
-	err = json.NewDecoder(c.Request().Body).Decode(&campaignFromRequest)
+	var html string
+	html, err = renderCampaignReport(report)
 	if err != nil {
 		return
 	}
-	campaignFromRequest.Name = campaignName
 
-	var guid string
-	guid, err = postgresDB.InsertCampaign(&campaignFromRequest)
+	err = postgresDB.UpsertCampaignReport(c.Request().Context(), report, html)
 	if err != nil {
 		return
 	}
+	logger.Info("campaign report created", zap.String("campaignName", campaignName), zap.Any("stats", report.Stats))
 
-	return c.String(http.StatusCreated, guid)
+	return c.JSON(http.StatusCreated, report)
 }
 
-func updateCampaign(c echo.Context) (err error) {
-	campaignName := strings.TrimSpace(c.Param(ParamCampaignName))
-	if len(campaignName) == 0 {
-		err = fmt.Errorf("invalid parameter %s: %s", ParamCampaignName, campaignName)
-		logger.Error("updateCampaign", zap.Error(err))
+const defaultTimeSeriesInterval = "day"
+
+// intervals of a time series, named as postgres date_trunc fields
+var timeSeriesIntervals = map[string]bool{
+	"hour": true,
+	"day":  true,
+	"week": true,
+}
+
+// getScoreTimeSeries reads the points scored in each interval of the campaign, for charts. The interval query
+// parameter is hour, day (the default) or week. With byTeam=true there is a separate series for each team.
+func getScoreTimeSeries(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
 
-		return c.String(http.StatusBadRequest, err.Error())
+	interval := c.QueryParam("interval")
+	if interval == "" {
+		interval = defaultTimeSeriesInterval
+	}
+	if !timeSeriesIntervals[interval] {
+		return newApiError(http.StatusBadRequest, fmt.Sprintf("invalid interval: %s", interval))
 	}
 
-	// update campaign stored in db
-	campaignFromRequest := types.CampaignStruct{}
-	err = json.NewDecoder(c.Request().Body).Decode(&campaignFromRequest)
+	var points []types.TimeSeriesPoint
+	points, err = postgresDB.SelectScoreTimeSeries(c.Request().Context(), campaignName, interval, c.QueryParam("byTeam") == "true")
 	if err != nil {
 		return
 	}
 
-	// force use of path parameter campaign name value
-	campaignFromRequest.Name = campaignName
+	// points are in order of time, so a running total per team is the burn-up
+	cumulative := map[string]float64{}
+	for i := range points {
+		cumulative[points[i].TeamName] += points[i].Points
+		points[i].CumulativePoints = cumulative[points[i].TeamName]
+	}
 
-	var guid string
-	guid, err = postgresDB.UpdateCampaign(&campaignFromRequest)
+	return c.JSON(http.StatusOK, points)
+}
+
+// getCampaignReport downloads the stored report as JSON, or as HTML with format=html.
+func getCampaignReport(c echo.Context) (err error) {
+	campaignName := c.Param(ParamCampaignName)
+
+	var reportJson []byte
+	var html string
+	reportJson, html, err = postgresDB.SelectStoredCampaignReport(c.Request().Context(), campaignName)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return newApiError(http.StatusNotFound, fmt.Sprintf("no report: campaignName: %s", campaignName))
+		}
 		return
 	}
 
-	return c.String(http.StatusOK, guid)
+	if c.QueryParam("format") == reportFormatHtml {
+		return c.HTML(http.StatusOK, html)
+	}
+	return c.JSONBlob(http.StatusOK, reportJson)
 }
@@ -17,19 +17,45 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/sonatype-nexus-community/bbash/buildversion"
+	"github.com/sonatype-nexus-community/bbash/internal/config"
 	"github.com/sonatype-nexus-community/bbash/internal/db"
+	"github.com/sonatype-nexus-community/bbash/internal/errreport"
+	"github.com/sonatype-nexus-community/bbash/internal/events"
+	"github.com/sonatype-nexus-community/bbash/internal/grpcapi"
+	"github.com/sonatype-nexus-community/bbash/internal/mail"
+	"github.com/sonatype-nexus-community/bbash/internal/metrics"
+	"github.com/sonatype-nexus-community/bbash/internal/poll"
+	"github.com/sonatype-nexus-community/bbash/internal/profile"
+	"github.com/sonatype-nexus-community/bbash/internal/scheduler"
+	"github.com/sonatype-nexus-community/bbash/internal/secrets"
 	"github.com/sonatype-nexus-community/bbash/internal/types"
+	"github.com/sonatype-nexus-community/bbash/internal/webhook"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/net/websocket"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	url2 "net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
 	"strconv"
 	"strings"
 	"testing"
@@ -38,13 +64,9 @@ import (
 
 var now = time.Now()
 
-func resetEnvVariable(t *testing.T, variableName, originalValue string) {
-	if originalValue == "" {
-		assert.NoError(t, os.Unsetenv(variableName))
-	} else {
-		assert.NoError(t, os.Setenv(variableName, originalValue))
-	}
-}
+// the database settings main reads from the environment
+const envPGHost = "PG_HOST"
+const envPGPort = "PG_PORT"
 
 func resetEnvVar(t *testing.T, envVarName, origValue string) {
 	if origValue != "" {
@@ -62,6 +84,7 @@ func resetEnvVarPGHost(t *testing.T, origEnvPGHost string) {
 var insertBugGuidCount int
 var priorScoreCallCount float64
 var updateScoreLastDelta float64
+var insertNotificationLast *types.NotificationStruct
 
 type MockBBashDB struct {
 	t                *testing.T
@@ -69,8 +92,12 @@ type MockBBashDB struct {
 
 	mockDb *sql.DB
 
-	migrateDbSourceURL string
-	migrateDbErr       error
+	migrateDbErr error
+
+	selectMigrationStatus    types.MigrationStatus
+	selectMigrationStatusErr error
+	rollbackMigrationsSteps  int
+	rollbackMigrationsErr    error
 
 	getSCPPs    []types.SourceControlProviderStruct
 	getSCPPsErr error
@@ -92,9 +119,41 @@ type MockBBashDB struct {
 	getActiveCampaignsResult    []types.CampaignStruct
 	getActiveCampaignsErr       error
 
+	getUpcomingCampaignsResult []types.CampaignStruct
+	getUpcomingCampaignsErr    error
+
 	getCampaignsResult []types.CampaignStruct
 	getCampaignsErr    error
 
+	selectPublicCampaignsResult []types.CampaignStruct
+	selectPublicCampaignsErr    error
+
+	selectCampaignsVersion    types.ListVersion
+	selectCampaignsVersionErr error
+
+	selectCampaignTenantResult string
+	selectCampaignTenantErr    error
+
+	selectRegistrationOpensOn  *time.Time
+	selectRegistrationClosesOn *time.Time
+	selectRegistrationErr      error
+
+	insertTenantParam    *types.TenantStruct
+	insertTenantPassword string
+	insertTenantGuid     string
+	insertTenantErr      error
+
+	selectTenantsResult []types.TenantStruct
+	selectTenantsErr    error
+
+	selectTenantAdminUsername     string
+	selectTenantAdminPasswordHash string
+	selectTenantAdminErr          error
+
+	selectTenantCampaignsParam  string
+	selectTenantCampaignsResult []types.CampaignStruct
+	selectTenantCampaignsErr    error
+
 	insertOrganizationParam *types.OrganizationStruct
 	insertOrganizationGuid  string
 	insertOrganizationErr   error
@@ -104,9 +163,17 @@ type MockBBashDB struct {
 
 	deleteOrgSCPName      string
 	deleteOrgOrgName      string
+	deleteOrgCampaignName string
 	deleteOrgRowsAffected int64
 	deleteOrgErr          error
 
+	updateOrgSCPName        string
+	updateOrgOrgName        string
+	updateOrgParam          *types.OrganizationUpdate
+	updateOrgRowsAffected   int64
+	updateOrgEventsRelinked int64
+	updateOrgErr            error
+
 	validOrgParam  *types.ScoringMessage
 	validOrgResult bool
 	validOrgErr    error
@@ -116,12 +183,52 @@ type MockBBashDB struct {
 	partiesToScoreNow     time.Time
 	partiesToScoreResult  []types.ParticipantStruct
 	partiesToScoreErr     error
+	// the participants of the co-authors, by login, read instead of partiesToScoreResult
+	partiesToScoreCoAuthors map[string][]types.ParticipantStruct
 
 	selectPointValueMsg      *types.ScoringMessage
 	selectPointValueCampaign string
 	selectPointValueBugType  string
 	selectPointValueResult   float64
 
+	upsertOverride    *types.PointValueOverride
+	upsertOverrideId  string
+	upsertOverrideErr error
+
+	selectOverridesCampName string
+	selectOverridesResult   []types.PointValueOverride
+	selectOverridesErr      error
+
+	deleteOverride             *types.PointValueOverride
+	deleteOverrideRowsAffected int64
+	deleteOverrideErr          error
+
+	insertExclusion    *types.BugCategoryExclusion
+	insertExclusionId  string
+	insertExclusionErr error
+
+	selectExclusionsCampName string
+	selectExclusionsResult   []types.BugCategoryExclusion
+	selectExclusionsErr      error
+
+	deleteExclusionCampName     string
+	deleteExclusionCategory     string
+	deleteExclusionRowsAffected int64
+	deleteExclusionErr          error
+
+	insertRepoFilter    *types.RepositoryFilter
+	insertRepoFilterId  string
+	insertRepoFilterErr error
+
+	selectRepoFiltersCampName string
+	selectRepoFiltersResult   []types.RepositoryFilter
+	selectRepoFiltersErr      error
+
+	deleteRepoFilterCampName     string
+	deleteRepoFilterId           string
+	deleteRepoFilterRowsAffected int64
+	deleteRepoFilterErr          error
+
 	updateScoreParticipant *types.ParticipantStruct
 	updateScoreDelta       int
 	updateScoreErr         error
@@ -132,17 +239,91 @@ type MockBBashDB struct {
 
 	insertScoreEvtPartier   *types.ParticipantStruct
 	insertScoreEvtMsg       *types.ScoringMessage
-	insertScoreEvtNewPoints int
+	insertScoreEvtNewPoints float64
+	insertScoreEvtBreakdown *types.ScoreBreakdown
 	insertScoreEvtErr       error
 
+	insertScoreEvtsErr error
+
+	selectScoreEvtCampName    string
+	selectScoreEvtScpName     string
+	selectScoreEvtRepoOwner   string
+	selectScoreEvtRepoName    string
+	selectScoreEvtPullRequest int
+	selectScoreEvtResult      *types.ScoringEventStruct
+	selectScoreEvtErr         error
+
+	exportScoreEvtCampName string
+	exportScoreEvtResult   []types.ScoringEventStruct
+	// exportScoreEvtErr is returned once the events are passed on
+	exportScoreEvtErr error
+
+	importScoreEvtEvents     []types.ScoringEventStruct
+	importScoreEvtImported   int64
+	importScoreEvtRecomputed int64
+	importScoreEvtErr        error
+	archiveSelectEndedBefore time.Time
+	archiveSelectNames       []string
+	archiveSelectErr         error
+	archiveCampaignNames     []string
+	archiveResult            *types.ScoringEventArchive
+	archiveErr               error
+	restoreCampaignName      string
+	restoreResult            *types.ScoringEventArchive
+	restoreErr               error
+	archives                 []types.ScoringEventArchive
+	archivesErr              error
+
+	voidScoreEvtId       string
+	voidScoreEvtResult   *types.ScoringEventStruct
+	voidScoreEvtTeamName string
+	voidScoreEvtErr      error
+
+	selectScoredEventsSince  time.Time
+	selectScoredEventsResult []db.ScoredEvent
+	selectScoredEventsErr    error
+	insertFlagsFlags         []types.ScoringFlag
+	insertFlagsFlagged       int64
+	insertFlagsErr           error
+	selectFlagsResult        []types.ScoringFlag
+	selectFlagsErr           error
+	resolveFlagId            string
+	resolveFlagResolution    string
+	resolveFlagResult        *types.ScoringFlag
+	resolveFlagErr           error
+
+	insertNotificationErr error
+
+	selectNotificationsCampName   string
+	selectNotificationsScpName    string
+	selectNotificationsLoginName  string
+	selectNotificationsUnreadOnly bool
+	selectNotificationsResult     []types.NotificationStruct
+	selectNotificationsErr        error
+
+	readNotificationsCampName     string
+	readNotificationsScpName      string
+	readNotificationsLoginName    string
+	readNotificationsId           string
+	readNotificationsRowsAffected int64
+	readNotificationsErr          error
+
 	insertParticipantPartier  *types.ParticipantStruct
 	insertParticipantGuid     string
 	insertParticipantJoinedAt time.Time
 	insertParticipantErr      error
 
+	upsertParticipantPartier *types.ParticipantStruct
+	upsertParticipantResult  types.ParticipantStruct
+	upsertParticipantInsert  bool
+	upsertParticipantErr     error
+
 	updateParticipantPartier      *types.ParticipantStruct
 	updateParticipantRowsAffected int64
 	updateParticipantErr          error
+	patchParticipantPatch         *types.ParticipantPatch
+	patchParticipantRowsAffected  int64
+	patchParticipantErr           error
 
 	selectPartDetailCampName  string
 	selectPartDetailSCPName   string
@@ -154,16 +335,88 @@ type MockBBashDB struct {
 	selectPartInCampResult []types.ParticipantStruct
 	selectPartInCampErr    error
 
+	selectPartVersionCamp string
+	selectPartVersion     types.ListVersion
+	selectPartVersionErr  error
+
 	deletePartCampaign  string
 	deletePartSCPName   string
 	deletePartLoginName string
 	deletePartGuid      string
 	deletePartErr       error
 
+	insertBanParam *types.BanStruct
+	insertBanId    string
+	insertBanErr   error
+
+	selectBansResult []types.BanStruct
+	selectBansErr    error
+
+	deleteBanScpName      string
+	deleteBanLoginName    string
+	deleteBanRowsAffected int64
+	deleteBanErr          error
+
+	selectBannedScpName   string
+	selectBannedLoginName string
+	selectBannedResult    bool
+	selectBannedErr       error
+
 	insertTeamTm   *types.TeamStruct
 	insertTeamGuid string
 	insertTeamErr  error
 
+	selectTeamsInCampCamp   string
+	selectTeamsInCampResult []types.TeamStruct
+	selectTeamsInCampErr    error
+
+	selectTeamDetailCampName string
+	selectTeamDetailTeamName string
+	selectTeamDetailResult   *types.TeamStruct
+	selectTeamDetailErr      error
+
+	deleteTeamCampName     string
+	deleteTeamTeamName     string
+	deleteTeamRowsAffected int64
+	deleteTeamErr          error
+
+	updateTeamNameCampName     string
+	updateTeamNameTeamName     string
+	updateTeamNameNewTeamName  string
+	updateTeamNameRowsAffected int64
+	updateTeamNameErr          error
+
+	updateTeamCaptainCampName     string
+	updateTeamCaptainTeamName     string
+	updateTeamCaptainScpName      string
+	updateTeamCaptainLoginName    string
+	updateTeamCaptainRowsAffected int64
+	updateTeamCaptainErr          error
+
+	isTeamCaptainCampName  string
+	isTeamCaptainTeamName  string
+	isTeamCaptainScpName   string
+	isTeamCaptainLoginName string
+	isTeamCaptainResult    bool
+	isTeamCaptainErr       error
+
+	removeFromTeamTeamName     string
+	removeFromTeamCampName     string
+	removeFromTeamScpName      string
+	removeFromTeamLoginName    string
+	removeFromTeamRowsAffected int64
+	removeFromTeamErr          error
+
+	insertTeamAdjustCampName string
+	insertTeamAdjustRequest  *types.TeamScoreAdjustmentRequest
+	insertTeamAdjustResult   []types.TeamScoreEvent
+	insertTeamAdjustErr      error
+
+	selectTeamHistoryCampName string
+	selectTeamHistoryTeamName string
+	selectTeamHistoryResult   []types.TeamScoreEvent
+	selectTeamHistoryErr      error
+
 	updatePartTeamTeamName     string
 	updatePartTeamCampaignName string
 	updatePartTeamSCPName      string
@@ -171,6 +424,13 @@ type MockBBashDB struct {
 	updatePartTeamRowsAffected int64
 	updatePartTeamErr          error
 
+	updatePartTeamsAssignments []types.TeamAssignment
+	updatePartTeamsResults     []types.TeamAssignmentResult
+	updatePartTeamsErr         error
+
+	insertSeedFixture    *types.SeedFixture
+	insertSeedFixtureErr error
+
 	insertBugBug  *types.BugStruct
 	insertBugGuid string
 	insertBugErr  error
@@ -179,8 +439,266 @@ type MockBBashDB struct {
 	updateBugRowsAffected int64
 	updateBugErr          error
 
-	selectBugsResult []types.BugStruct
-	selectBugsErr    error
+	selectBugsResult             []types.BugStruct
+	selectBugsErr                error
+	selectBugsInCampaignCampaign string
+	selectBugsInCampaignResult   []types.BugStruct
+	selectBugsInCampaignErr      error
+
+	selectBugsVersion    types.ListVersion
+	selectBugsVersionErr error
+
+	upsertConfigStage    *types.CampaignConfigStruct
+	upsertConfigStageErr error
+
+	selectConfigStageCampName string
+	selectConfigStageResult   *types.CampaignConfigStruct
+	selectConfigStageErr      error
+
+	deleteConfigStageCampName     string
+	deleteConfigStageRowsAffected int64
+	deleteConfigStageErr          error
+
+	promoteConfigStageCampName string
+	promoteConfigStageResult   *types.CampaignConfigStruct
+	promoteConfigStageErr      error
+
+	upsertSlack    *types.SlackNotificationStruct
+	upsertSlackErr error
+
+	// an empty selectSlackCampName accepts any campaign, as scoring looks up the config of every campaign it scores
+	selectSlackCampName string
+	selectSlackResult   *types.SlackNotificationStruct
+	selectSlackErr      error
+
+	deleteSlackCampName     string
+	deleteSlackRowsAffected int64
+	deleteSlackErr          error
+
+	upsertPenalty    *types.CampaignPenaltyStruct
+	upsertPenaltyErr error
+
+	selectPenaltyCampName string
+	selectPenaltyResult   *types.CampaignPenaltyStruct
+	selectPenaltyErr      error
+
+	upsertCaps    *types.CampaignCapsStruct
+	upsertCapsErr error
+
+	selectCapsCampName string
+	selectCapsResult   *types.CampaignCapsStruct
+	selectCapsErr      error
+
+	upsertDuplicatePolicy    *types.DuplicatePolicyStruct
+	upsertDuplicatePolicyErr error
+
+	selectDuplicatePolicyCampName string
+	selectDuplicatePolicyResult   *types.DuplicatePolicyStruct
+	selectDuplicatePolicyErr      error
+
+	selectDuplicatesResult []string
+	selectDuplicatesErr    error
+
+	upsertCoAuthorPolicy    *types.CoAuthorPolicyStruct
+	upsertCoAuthorPolicyErr error
+
+	selectCoAuthorPolicyCampName string
+	selectCoAuthorPolicyResult   *types.CoAuthorPolicyStruct
+	selectCoAuthorPolicyErr      error
+
+	upsertPrivacy    *types.CampaignPrivacyStruct
+	upsertPrivacyErr error
+
+	selectPrivacyCampName string
+	selectPrivacyResult   *types.CampaignPrivacyStruct
+	selectPrivacyErr      error
+
+	coAuthorPriorScore     float64
+	insertCoAuthorScoreErr error
+	coAuthorScoresResult   []db.CoAuthorScoreRow
+	coAuthorScoresErr      error
+	deleteCoAuthorScoreErr error
+
+	selectEventsSinceParticipant *types.ParticipantStruct
+	selectEventsSince            time.Time
+	selectEventsSinceResult      []db.ScoringEventRow
+	selectEventsSinceErr         error
+
+	// an empty selectBonusCampName accepts any campaign, as scoring looks up the bonus of every campaign it scores
+	selectBonusCampName string
+	selectBonusResult   float64
+	selectBonusErr      error
+
+	selectTopCampName string
+	selectTopCount    int
+	selectTopResult   []types.ParticipantStruct
+	selectTopErr      error
+
+	leaderboardStale         bool
+	leaderboardStaleErr      error
+	refreshLeaderboardCalled *bool
+	refreshLeaderboardErr    error
+	leaderboardCampName      string
+	leaderboardLimit         int
+	leaderboardResult        *types.Leaderboard
+	leaderboardErr           error
+
+	teamLeaderboardCampName string
+	teamLeaderboardTeamName string
+	teamLeaderboardResult   *types.TeamLeaderboard
+	teamLeaderboardErr      error
+
+	insertSnapshotCampName  string
+	insertSnapshotCalled    *bool
+	insertSnapshotFinalized bool
+	insertSnapshotErr       error
+	selectSnapshotCampName  string
+	selectSnapshotResult    *types.LeaderboardSnapshot
+	selectSnapshotErr       error
+
+	upsertEmail    *types.CampaignEmailStruct
+	upsertEmailErr error
+
+	selectEmailsCampName string
+	selectEmailsResult   []types.CampaignEmailStruct
+	selectEmailsErr      error
+
+	selectEmailCampName string
+	selectEmailKind     string
+	selectEmailResult   *types.CampaignEmailStruct
+	selectEmailErr      error
+
+	selectBugsFixedParticipant *types.ParticipantStruct
+	selectBugsFixedResult      float64
+	selectBugsFixedErr         error
+
+	insertAchievementAwarded bool
+	insertAchievementErr     error
+
+	selectAchievementsCampName  string
+	selectAchievementsScpName   string
+	selectAchievementsLoginName string
+	selectAchievementsResult    []types.AchievementStruct
+	selectAchievementsErr       error
+
+	selectScoreAtCampName  string
+	selectScoreAtScpName   string
+	selectScoreAtLoginName string
+	selectScoreAtAt        time.Time
+	selectScoreAtResult    *types.ParticipantScoreAt
+	selectScoreAtErr       error
+
+	selectAchievementStatsCampName string
+	selectAchievementStatsResult   []types.AchievementStat
+	selectAchievementStatsErr      error
+
+	selectTimeSeriesCampName string
+	selectTimeSeriesInterval string
+	selectTimeSeriesByTeam   bool
+	selectTimeSeriesResult   []types.TimeSeriesPoint
+	selectTimeSeriesErr      error
+
+	insertTelemetryFeature string
+	insertTelemetryCall    string
+	insertTelemetryErr     error
+
+	selectTelemetryFrom   time.Time
+	selectTelemetryTo     time.Time
+	selectTelemetryResult []types.TelemetryUsage
+	selectTelemetryErr    error
+
+	insertAuditErr error
+
+	selectAuditActor  string
+	selectAuditLimit  int
+	selectAuditResult []types.AuditLogEntry
+	selectAuditErr    error
+
+	updateProfileId          string
+	updateProfileDisplayName string
+	updateProfileAvatarUrl   string
+	updateProfileErr         error
+
+	selectRefreshBefore time.Time
+	selectRefreshResult []types.ParticipantStruct
+	selectRefreshErr    error
+
+	updateContactScpName      string
+	updateContactLoginName    string
+	updateContactRequest      *types.ParticipantProfileRequest
+	updateContactRowsAffected int64
+	updateContactErr          error
+
+	searchLogin  string
+	searchLimit  int
+	searchResult []types.ParticipantStruct
+	searchErr    error
+
+	insertWebhook          *types.WebhookStruct
+	insertWebhookId        string
+	insertWebhookCreatedOn time.Time
+	insertWebhookErr       error
+
+	selectWebhooksResult []types.WebhookStruct
+	selectWebhooksErr    error
+
+	deleteWebhookId           string
+	deleteWebhookRowsAffected int64
+	deleteWebhookErr          error
+
+	claimTransitionsNow    time.Time
+	claimTransitionsResult []types.CampaignTransition
+	claimTransitionsErr    error
+
+	selectReportCampName string
+	selectReportTopCount int
+	selectReportResult   *types.CampaignReport
+	selectReportErr      error
+	upsertReportHtml     string
+	upsertReportErr      error
+
+	selectStoredReportCampName string
+	selectStoredReportJson     []byte
+	selectStoredReportHtml     string
+	selectStoredReportErr      error
+
+	upsertClusterInstance    *types.ClusterInstance
+	upsertClusterInstanceErr error
+
+	selectClusterInstancesResult []types.ClusterInstance
+	selectClusterInstancesErr    error
+
+	acquirePollLeaseHeld bool
+	acquirePollLeaseErr  error
+	releasePollLeaseErr  error
+
+	upsertScheduledJobRunErr  error
+	selectScheduledJobRuns    []types.ScheduledJobRun
+	selectScheduledJobRunsErr error
+	selectMaintenance         types.MaintenanceState
+	selectMaintenanceErr      error
+	updateMaintenanceErr      error
+
+	insertJobKind string
+	insertJobId   string
+	insertJobErr  error
+	// finishedJob, when set, receives the job as it finished
+	finishedJob     *types.Job
+	finishJobErr    error
+	selectJobId     string
+	selectJobResult *types.Job
+	selectJobErr    error
+	updateJobErr    error
+	// failJobsInstanceId and failJobsHeartbeatBefore are expected by FailInstanceJobs and FailOrphanedJobs
+	failJobsInstanceId      string
+	failJobsHeartbeatBefore time.Time
+	failJobsResult          int64
+	failJobsErr             error
+
+	// backupRows are the rows written for each table, in the order of the tables
+	backupRows []mockBackupTable
+	// backupErr is returned once the rows are written
+	backupErr error
 
 	selectPoll    types.Poll
 	selectPollErr error
@@ -192,43 +710,59 @@ func (m MockBBashDB) GetDb() (db *sql.DB) {
 	return m.mockDb
 }
 
-func (m MockBBashDB) MigrateDB(migrateSourceURL string) error {
+func (m MockBBashDB) MigrateDB() error {
+	return m.migrateDbErr
+}
+
+func (m MockBBashDB) SelectMigrationStatus(ctx context.Context) (status types.MigrationStatus, err error) {
+	return m.selectMigrationStatus, m.selectMigrationStatusErr
+}
+
+func (m MockBBashDB) RollbackMigrations(steps int) (err error) {
 	if m.assertParameters {
-		assert.Equal(m.t, m.migrateDbSourceURL, migrateSourceURL)
+		assert.Equal(m.t, m.rollbackMigrationsSteps, steps)
 	}
-	return m.migrateDbErr
+	return m.rollbackMigrationsErr
 }
 
-func (m MockBBashDB) GetSourceControlProviders() (scps []types.SourceControlProviderStruct, err error) {
+func (m MockBBashDB) GetSourceControlProviders(ctx context.Context) (scps []types.SourceControlProviderStruct, err error) {
 	return m.getSCPPs, m.getSCPPsErr
 }
 
-func (m MockBBashDB) InsertCampaign(campaign *types.CampaignStruct) (guid string, err error) {
+func (m MockBBashDB) InsertCampaign(ctx context.Context, campaign *types.CampaignStruct) (guid string, err error) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.insertCampaignParam, campaign)
 	}
 	return m.insertCampaignGuid, m.insertCampaignErr
 }
 
-func (m MockBBashDB) UpdateCampaign(campaign *types.CampaignStruct) (guid string, err error) {
+func (m MockBBashDB) UpdateCampaign(ctx context.Context, campaign *types.CampaignStruct) (guid string, err error) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.updateCampaignParam, campaign)
 	}
 	return m.updateCampaignGuid, m.updateCampaignErr
 }
 
-func (m MockBBashDB) GetCampaign(campaignName string) (campaign *types.CampaignStruct, err error) {
+func (m MockBBashDB) GetCampaign(ctx context.Context, campaignName string) (campaign *types.CampaignStruct, err error) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.getCampaignParam, campaignName)
 	}
 	return m.getCampaignResult, m.getCampaignErr
 }
 
-func (m MockBBashDB) GetCampaigns() (campaigns []types.CampaignStruct, err error) {
+func (m MockBBashDB) GetCampaigns(ctx context.Context) (campaigns []types.CampaignStruct, err error) {
 	return m.getCampaignsResult, m.getCampaignsErr
 }
 
-func (m MockBBashDB) GetActiveCampaigns(now time.Time) (activeCampaigns []types.CampaignStruct, err error) {
+func (m MockBBashDB) SelectPublicCampaigns(ctx context.Context) (campaigns []types.CampaignStruct, err error) {
+	return m.selectPublicCampaignsResult, m.selectPublicCampaignsErr
+}
+
+func (m MockBBashDB) SelectCampaignsVersion(ctx context.Context) (version types.ListVersion, err error) {
+	return m.selectCampaignsVersion, m.selectCampaignsVersionErr
+}
+
+func (m MockBBashDB) GetActiveCampaigns(ctx context.Context, now time.Time) (activeCampaigns []types.CampaignStruct, err error) {
 	if m.assertParameters {
 		if !m.getActiveCampaignsParamSkip {
 			assert.Equal(m.t, m.getActiveCampaignsParam, now)
@@ -237,33 +771,83 @@ func (m MockBBashDB) GetActiveCampaigns(now time.Time) (activeCampaigns []types.
 	return m.getActiveCampaignsResult, m.getActiveCampaignsErr
 }
 
-func (m MockBBashDB) InsertOrganization(organization *types.OrganizationStruct) (guid string, err error) {
+func (m MockBBashDB) GetUpcomingCampaigns(ctx context.Context, now time.Time) (upcomingCampaigns []types.CampaignStruct, err error) {
+	return m.getUpcomingCampaignsResult, m.getUpcomingCampaignsErr
+}
+
+func (m MockBBashDB) SelectCampaignTenant(ctx context.Context, campaignName string) (tenantName string, err error) {
+	return m.selectCampaignTenantResult, m.selectCampaignTenantErr
+}
+
+func (m MockBBashDB) SelectRegistrationWindow(ctx context.Context, campaignName string) (opensOn, closesOn *time.Time, err error) {
+	return m.selectRegistrationOpensOn, m.selectRegistrationClosesOn, m.selectRegistrationErr
+}
+
+func (m MockBBashDB) InsertTenant(ctx context.Context, tenant *types.TenantStruct, passwordHash string) (guid string, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.insertTenantParam, tenant)
+	}
+	if m.insertTenantPassword != "" {
+		assert.NoError(m.t, bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(m.insertTenantPassword)))
+	}
+	return m.insertTenantGuid, m.insertTenantErr
+}
+
+func (m MockBBashDB) SelectTenants(ctx context.Context) (tenants []types.TenantStruct, err error) {
+	return m.selectTenantsResult, m.selectTenantsErr
+}
+
+func (m MockBBashDB) SelectTenantAdmin(ctx context.Context, tenantName string) (username, passwordHash string, err error) {
+	return m.selectTenantAdminUsername, m.selectTenantAdminPasswordHash, m.selectTenantAdminErr
+}
+
+func (m MockBBashDB) SelectTenantCampaigns(ctx context.Context, tenantName string) (campaigns []types.CampaignStruct, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectTenantCampaignsParam, tenantName)
+	}
+	return m.selectTenantCampaignsResult, m.selectTenantCampaignsErr
+}
+
+func (m MockBBashDB) InsertOrganization(ctx context.Context, organization *types.OrganizationStruct) (guid string, err error) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.insertOrganizationParam, organization)
 	}
 	return m.insertOrganizationGuid, m.insertOrganizationErr
 }
 
-func (m MockBBashDB) GetOrganizations() (organizations []types.OrganizationStruct, err error) {
+func (m MockBBashDB) GetOrganizations(ctx context.Context) (organizations []types.OrganizationStruct, err error) {
 	return m.getOrganizationsResult, m.getOrganizationsErr
 }
 
-func (m MockBBashDB) DeleteOrganization(scpName, orgName string) (rowsAffected int64, err error) {
+func (m MockBBashDB) DeleteOrganization(ctx context.Context, scpName, orgName, campaignName string) (rowsAffected int64, err error) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.deleteOrgSCPName, scpName)
 		assert.Equal(m.t, m.deleteOrgOrgName, orgName)
+		assert.Equal(m.t, m.deleteOrgCampaignName, campaignName)
 	}
 	return m.deleteOrgRowsAffected, m.deleteOrgErr
 }
 
-func (m MockBBashDB) ValidOrganization(msg *types.ScoringMessage) (orgExists bool, err error) {
+func (m MockBBashDB) UpdateOrganization(ctx context.Context, scpName, orgName string, update *types.OrganizationUpdate) (rowsAffected, eventsRelinked int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.updateOrgSCPName, scpName)
+		assert.Equal(m.t, m.updateOrgOrgName, orgName)
+		assert.Equal(m.t, m.updateOrgParam, update)
+	}
+	return m.updateOrgRowsAffected, m.updateOrgEventsRelinked, m.updateOrgErr
+}
+
+func (m MockBBashDB) ValidOrganization(ctx context.Context, msg *types.ScoringMessage, now time.Time) (orgExists bool, err error) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.validOrgParam, msg)
 	}
 	return m.validOrgResult, m.validOrgErr
 }
 
-func (m MockBBashDB) SelectParticipantsToScore(msg *types.ScoringMessage, now time.Time) (participantsToScore []types.ParticipantStruct, err error) {
+func (m MockBBashDB) SelectParticipantsToScore(ctx context.Context, msg *types.ScoringMessage, now time.Time) (participantsToScore []types.ParticipantStruct, err error) {
+	if coAuthors, ok := m.partiesToScoreCoAuthors[msg.TriggerUser]; ok {
+		return coAuthors, nil
+	}
 	if m.assertParameters {
 		assert.Equal(m.t, m.partiesToScoreMsg, msg)
 		// some callers use dynamic Time.now() value, so we can't validate exact value
@@ -274,7 +858,7 @@ func (m MockBBashDB) SelectParticipantsToScore(msg *types.ScoringMessage, now ti
 	return m.partiesToScoreResult, m.partiesToScoreErr
 }
 
-func (m MockBBashDB) SelectPointValue(msg *types.ScoringMessage, campaignName, bugType string) (pointValue float64) {
+func (m MockBBashDB) SelectPointValue(ctx context.Context, msg *types.ScoringMessage, campaignName, bugType string) (pointValue float64) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.selectPointValueMsg, msg)
 		assert.Equal(m.t, m.selectPointValueCampaign, campaignName)
@@ -283,7 +867,7 @@ func (m MockBBashDB) SelectPointValue(msg *types.ScoringMessage, campaignName, b
 	return m.selectPointValueResult
 }
 
-func (m MockBBashDB) UpdateParticipantScore(participant *types.ParticipantStruct, delta float64) (err error) {
+func (m MockBBashDB) UpdateParticipantScore(ctx context.Context, participant *types.ParticipantStruct, delta float64) (err error) {
 	if m.assertParameters {
 		// multiple mock kludge
 		if priorScoreCallCount == 0 {
@@ -295,7 +879,7 @@ func (m MockBBashDB) UpdateParticipantScore(participant *types.ParticipantStruct
 	return m.updateScoreErr
 }
 
-func (m MockBBashDB) SelectPriorScore(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64) {
+func (m MockBBashDB) SelectPriorScore(ctx context.Context, participantToScore *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64) {
 	if m.assertParameters {
 		// multiple mock kludge
 		if priorScoreCallCount == 0 {
@@ -309,19 +893,112 @@ func (m MockBBashDB) SelectPriorScore(participantToScore *types.ParticipantStruc
 	return scoreToReturn
 }
 
-func (m MockBBashDB) InsertScoringEvent(participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64) (err error) {
+func (m MockBBashDB) InsertScoringEvents(ctx context.Context, events []db.ScoringEventRow, scoreDeltas []db.ParticipantScoreDelta) (err error) {
+	return m.insertScoreEvtsErr
+}
+
+func (m MockBBashDB) InsertScoringEvent(ctx context.Context, participantToScore *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64, breakdown *types.ScoreBreakdown) (err error) {
 	if m.assertParameters {
 		// multiple mock kludge
 		if priorScoreCallCount == 0 {
 			assert.Equal(m.t, m.insertScoreEvtPartier, participantToScore)
 			assert.Equal(m.t, m.insertScoreEvtMsg, msg)
 			assert.Equal(m.t, m.insertScoreEvtNewPoints, newPoints)
+			assert.Equal(m.t, m.insertScoreEvtBreakdown, breakdown)
 		}
 	}
 	return m.insertScoreEvtErr
 }
 
-func (m MockBBashDB) InsertParticipant(participant *types.ParticipantStruct) (err error) {
+func (m MockBBashDB) SelectScoringEvent(ctx context.Context, campaignName, scpName, repoOwner, repoName string, pullRequest int) (event *types.ScoringEventStruct, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectScoreEvtCampName, campaignName)
+		assert.Equal(m.t, m.selectScoreEvtScpName, scpName)
+		assert.Equal(m.t, m.selectScoreEvtRepoOwner, repoOwner)
+		assert.Equal(m.t, m.selectScoreEvtRepoName, repoName)
+		assert.Equal(m.t, m.selectScoreEvtPullRequest, pullRequest)
+	}
+	return m.selectScoreEvtResult, m.selectScoreEvtErr
+}
+
+func (m MockBBashDB) ExportScoringEvents(ctx context.Context, campaignName string, each func(event *types.ScoringEventStruct) error) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.exportScoreEvtCampName, campaignName)
+	}
+	for i := range m.exportScoreEvtResult {
+		if err = each(&m.exportScoreEvtResult[i]); err != nil {
+			return
+		}
+	}
+	return m.exportScoreEvtErr
+}
+
+func (m MockBBashDB) ImportScoringEvents(ctx context.Context, events []types.ScoringEventStruct) (imported, recomputed int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.importScoreEvtEvents, events)
+	}
+	return m.importScoreEvtImported, m.importScoreEvtRecomputed, m.importScoreEvtErr
+}
+
+func (m MockBBashDB) SelectCampaignsToArchive(ctx context.Context, endedBefore time.Time) (campaignNames []string, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.archiveSelectEndedBefore, endedBefore)
+	}
+	return m.archiveSelectNames, m.archiveSelectErr
+}
+
+func (m MockBBashDB) ArchiveScoringEvents(ctx context.Context, campaignName string, now time.Time) (archive *types.ScoringEventArchive, err error) {
+	if m.assertParameters {
+		assert.Contains(m.t, m.archiveCampaignNames, campaignName)
+	}
+	return m.archiveResult, m.archiveErr
+}
+
+func (m MockBBashDB) RestoreScoringEvents(ctx context.Context, campaignName string, now time.Time) (archive *types.ScoringEventArchive, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.restoreCampaignName, campaignName)
+	}
+	return m.restoreResult, m.restoreErr
+}
+
+func (m MockBBashDB) SelectScoringEventArchives(ctx context.Context) (archives []types.ScoringEventArchive, err error) {
+	return m.archives, m.archivesErr
+}
+
+func (m MockBBashDB) VoidScoringEvent(ctx context.Context, eventId string, now time.Time) (event *types.ScoringEventStruct, teamName string, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.voidScoreEvtId, eventId)
+	}
+	return m.voidScoreEvtResult, m.voidScoreEvtTeamName, m.voidScoreEvtErr
+}
+
+func (m MockBBashDB) SelectScoredEvents(ctx context.Context, since time.Time) (events []db.ScoredEvent, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectScoredEventsSince, since)
+	}
+	return m.selectScoredEventsResult, m.selectScoredEventsErr
+}
+
+func (m MockBBashDB) InsertScoringFlags(ctx context.Context, flags []types.ScoringFlag) (flagged int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.insertFlagsFlags, flags)
+	}
+	return m.insertFlagsFlagged, m.insertFlagsErr
+}
+
+func (m MockBBashDB) SelectScoringFlags(ctx context.Context) (flags []types.ScoringFlag, err error) {
+	return m.selectFlagsResult, m.selectFlagsErr
+}
+
+func (m MockBBashDB) ResolveScoringFlag(ctx context.Context, flagId, resolution string, now time.Time) (flag *types.ScoringFlag, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.resolveFlagId, flagId)
+		assert.Equal(m.t, m.resolveFlagResolution, resolution)
+	}
+	return m.resolveFlagResult, m.resolveFlagErr
+}
+
+func (m MockBBashDB) InsertParticipant(ctx context.Context, participant *types.ParticipantStruct) (err error) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.insertParticipantPartier, participant)
 	}
@@ -332,7 +1009,17 @@ func (m MockBBashDB) InsertParticipant(participant *types.ParticipantStruct) (er
 	return m.insertParticipantErr
 }
 
-func (m MockBBashDB) SelectParticipantDetail(campaignName, scpName, loginName string) (participant *types.ParticipantStruct, err error) {
+func (m MockBBashDB) UpsertParticipant(ctx context.Context, participant *types.ParticipantStruct) (inserted bool, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.upsertParticipantPartier, participant)
+	}
+	if m.upsertParticipantErr == nil {
+		*participant = m.upsertParticipantResult
+	}
+	return m.upsertParticipantInsert, m.upsertParticipantErr
+}
+
+func (m MockBBashDB) SelectParticipantDetail(ctx context.Context, campaignName, scpName, loginName string) (participant *types.ParticipantStruct, err error) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.selectPartDetailCampName, campaignName)
 		assert.Equal(m.t, m.selectPartDetailSCPName, scpName)
@@ -341,7 +1028,7 @@ func (m MockBBashDB) SelectParticipantDetail(campaignName, scpName, loginName st
 	return m.selectPartDetailResult, m.selectPartDetailErr
 }
 
-func (m MockBBashDB) DeleteParticipant(campaign, scpName, loginName string) (participantId string, err error) {
+func (m MockBBashDB) DeleteParticipant(ctx context.Context, campaign, scpName, loginName string) (participantId string, err error) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.deletePartCampaign, campaign)
 		assert.Equal(m.t, m.deletePartSCPName, scpName)
@@ -350,14 +1037,50 @@ func (m MockBBashDB) DeleteParticipant(campaign, scpName, loginName string) (par
 	return m.deletePartGuid, m.deletePartErr
 }
 
-func (m MockBBashDB) SelectParticipantsInCampaign(campaignName string) (participants []types.ParticipantStruct, err error) {
+func (m MockBBashDB) InsertBan(ctx context.Context, ban *types.BanStruct) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.insertBanParam, ban)
+	}
+	ban.ID = m.insertBanId
+	return m.insertBanErr
+}
+
+func (m MockBBashDB) SelectBans(ctx context.Context) (bans []types.BanStruct, err error) {
+	return m.selectBansResult, m.selectBansErr
+}
+
+func (m MockBBashDB) DeleteBan(ctx context.Context, scpName, loginName string) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.deleteBanScpName, scpName)
+		assert.Equal(m.t, m.deleteBanLoginName, loginName)
+	}
+	return m.deleteBanRowsAffected, m.deleteBanErr
+}
+
+func (m MockBBashDB) SelectBanned(ctx context.Context, scpName, loginName string) (banned bool, err error) {
+	// most tests never ban anyone, so only check the login of those that do
+	if m.selectBannedLoginName != "" {
+		assert.Equal(m.t, m.selectBannedScpName, scpName)
+		assert.Equal(m.t, m.selectBannedLoginName, loginName)
+	}
+	return m.selectBannedResult, m.selectBannedErr
+}
+
+func (m MockBBashDB) SelectParticipantsInCampaign(ctx context.Context, campaignName string) (participants []types.ParticipantStruct, err error) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.selectPartInCampCamp, campaignName)
 	}
 	return m.selectPartInCampResult, m.selectPartInCampErr
 }
 
-func (m MockBBashDB) InsertTeam(team *types.TeamStruct) (err error) {
+func (m MockBBashDB) SelectParticipantsVersion(ctx context.Context, campaignName string) (version types.ListVersion, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectPartVersionCamp, campaignName)
+	}
+	return m.selectPartVersion, m.selectPartVersionErr
+}
+
+func (m MockBBashDB) InsertTeam(ctx context.Context, team *types.TeamStruct) (err error) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.insertTeamTm, team)
 	}
@@ -366,14 +1089,137 @@ func (m MockBBashDB) InsertTeam(team *types.TeamStruct) (err error) {
 	return m.insertTeamErr
 }
 
-func (m MockBBashDB) UpdateParticipant(participant *types.ParticipantStruct) (rowsAffected int64, err error) {
+func (m MockBBashDB) SelectTeamsInCampaign(ctx context.Context, campaignName string) (teams []types.TeamStruct, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectTeamsInCampCamp, campaignName)
+	}
+	return m.selectTeamsInCampResult, m.selectTeamsInCampErr
+}
+
+func (m MockBBashDB) SelectTeamDetail(ctx context.Context, campaignName, teamName string) (team *types.TeamStruct, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectTeamDetailCampName, campaignName)
+		assert.Equal(m.t, m.selectTeamDetailTeamName, teamName)
+	}
+	return m.selectTeamDetailResult, m.selectTeamDetailErr
+}
+
+func (m MockBBashDB) DeleteTeam(ctx context.Context, campaignName, teamName string) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.deleteTeamCampName, campaignName)
+		assert.Equal(m.t, m.deleteTeamTeamName, teamName)
+	}
+	return m.deleteTeamRowsAffected, m.deleteTeamErr
+}
+
+func (m MockBBashDB) UpdateTeamName(ctx context.Context, campaignName, teamName, newTeamName string) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.updateTeamNameCampName, campaignName)
+		assert.Equal(m.t, m.updateTeamNameTeamName, teamName)
+		assert.Equal(m.t, m.updateTeamNameNewTeamName, newTeamName)
+	}
+	return m.updateTeamNameRowsAffected, m.updateTeamNameErr
+}
+
+func (m MockBBashDB) UpdateTeamCaptain(ctx context.Context, campaignName, teamName, scpName, loginName string) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.updateTeamCaptainCampName, campaignName)
+		assert.Equal(m.t, m.updateTeamCaptainTeamName, teamName)
+		assert.Equal(m.t, m.updateTeamCaptainScpName, scpName)
+		assert.Equal(m.t, m.updateTeamCaptainLoginName, loginName)
+	}
+	return m.updateTeamCaptainRowsAffected, m.updateTeamCaptainErr
+}
+
+func (m MockBBashDB) IsTeamCaptain(ctx context.Context, campaignName, teamName, scpName, loginName string) (isCaptain bool, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.isTeamCaptainCampName, campaignName)
+		assert.Equal(m.t, m.isTeamCaptainTeamName, teamName)
+		assert.Equal(m.t, m.isTeamCaptainScpName, scpName)
+		assert.Equal(m.t, m.isTeamCaptainLoginName, loginName)
+	}
+	return m.isTeamCaptainResult, m.isTeamCaptainErr
+}
+
+func (m MockBBashDB) RemoveParticipantFromTeam(ctx context.Context, teamName, campaignName, scpName, loginName string) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.removeFromTeamTeamName, teamName)
+		assert.Equal(m.t, m.removeFromTeamCampName, campaignName)
+		assert.Equal(m.t, m.removeFromTeamScpName, scpName)
+		assert.Equal(m.t, m.removeFromTeamLoginName, loginName)
+	}
+	return m.removeFromTeamRowsAffected, m.removeFromTeamErr
+}
+
+//goland:noinspection GoUnusedParameter
+func (m MockBBashDB) InsertTeamScoreAdjustments(ctx context.Context, campaignName string, request *types.TeamScoreAdjustmentRequest, now time.Time) (events []types.TeamScoreEvent, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.insertTeamAdjustCampName, campaignName)
+		assert.Equal(m.t, m.insertTeamAdjustRequest, request)
+	}
+	return m.insertTeamAdjustResult, m.insertTeamAdjustErr
+}
+
+func (m MockBBashDB) SelectTeamScoreHistory(ctx context.Context, campaignName, teamName string) (events []types.TeamScoreEvent, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectTeamHistoryCampName, campaignName)
+		assert.Equal(m.t, m.selectTeamHistoryTeamName, teamName)
+	}
+	return m.selectTeamHistoryResult, m.selectTeamHistoryErr
+}
+
+func (m MockBBashDB) SelectCampaignReport(ctx context.Context, campaignName string, topCount int, _ time.Time) (report *types.CampaignReport, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectReportCampName, campaignName)
+		assert.Equal(m.t, m.selectReportTopCount, topCount)
+	}
+	return m.selectReportResult, m.selectReportErr
+}
+
+func (m MockBBashDB) UpsertCampaignReport(ctx context.Context, report *types.CampaignReport, html string) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectReportResult, report)
+		assert.Contains(m.t, html, m.upsertReportHtml)
+	}
+	return m.upsertReportErr
+}
+
+func (m MockBBashDB) SelectStoredCampaignReport(ctx context.Context, campaignName string) (reportJson []byte, html string, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectStoredReportCampName, campaignName)
+	}
+	return m.selectStoredReportJson, m.selectStoredReportHtml, m.selectStoredReportErr
+}
+
+func (m MockBBashDB) UpdateParticipant(ctx context.Context, participant *types.ParticipantStruct) (rowsAffected int64, err error) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.updateParticipantPartier, participant)
 	}
 	return m.updateParticipantRowsAffected, m.updateParticipantErr
 }
 
-func (m MockBBashDB) UpdateParticipantTeam(teamName, campaignName, scpName, loginName string) (rowsAffected int64, err error) {
+func (m MockBBashDB) PatchParticipant(ctx context.Context, patch *types.ParticipantPatch) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.patchParticipantPatch, patch)
+	}
+	return m.patchParticipantRowsAffected, m.patchParticipantErr
+}
+
+func (m MockBBashDB) UpdateParticipantTeams(ctx context.Context, assignments []types.TeamAssignment) (results []types.TeamAssignmentResult, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.updatePartTeamsAssignments, assignments)
+	}
+	return m.updatePartTeamsResults, m.updatePartTeamsErr
+}
+
+func (m MockBBashDB) InsertSeedFixture(ctx context.Context, fixture *types.SeedFixture) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.insertSeedFixture, fixture)
+	}
+	return m.insertSeedFixtureErr
+}
+
+func (m MockBBashDB) UpdateParticipantTeam(ctx context.Context, teamName, campaignName, scpName, loginName string) (rowsAffected int64, err error) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.updatePartTeamTeamName, teamName)
 		assert.Equal(m.t, m.updatePartTeamCampaignName, campaignName)
@@ -383,7 +1229,7 @@ func (m MockBBashDB) UpdateParticipantTeam(teamName, campaignName, scpName, logi
 	return m.updatePartTeamRowsAffected, m.updatePartTeamErr
 }
 
-func (m MockBBashDB) InsertBug(bug *types.BugStruct) (err error) {
+func (m MockBBashDB) InsertBug(ctx context.Context, bug *types.BugStruct) (err error) {
 	if m.assertParameters {
 		// only validate the first calls parameter. maybe later, could change mocks to support lists to validate
 		if insertBugGuidCount == 0 {
@@ -400,1931 +1246,10987 @@ func (m MockBBashDB) InsertBug(bug *types.BugStruct) (err error) {
 	return m.insertBugErr
 }
 
-func (m MockBBashDB) UpdateBug(bug *types.BugStruct) (rowsAffected int64, err error) {
+func (m MockBBashDB) UpdateBug(ctx context.Context, bug *types.BugStruct) (rowsAffected int64, err error) {
 	if m.assertParameters {
 		assert.Equal(m.t, m.updateBugBug, bug)
 	}
 	return m.updateBugRowsAffected, m.updateBugErr
 }
 
-func (m MockBBashDB) SelectBugs() (bugs []types.BugStruct, err error) {
+func (m MockBBashDB) SelectBugs(ctx context.Context) (bugs []types.BugStruct, err error) {
 	return m.selectBugsResult, m.selectBugsErr
 }
 
-func (m MockBBashDB) NewPoll() types.Poll {
-	return db.NewPoll()
+func (m MockBBashDB) SelectBugsInCampaign(ctx context.Context, campaignName string) (bugs []types.BugStruct, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectBugsInCampaignCampaign, campaignName)
+	}
+	return m.selectBugsInCampaignResult, m.selectBugsInCampaignErr
 }
 
-func (m MockBBashDB) UpdatePoll(poll *types.Poll) (err error) {
+func (m MockBBashDB) SelectBugsVersion(ctx context.Context) (version types.ListVersion, err error) {
+	return m.selectBugsVersion, m.selectBugsVersionErr
+}
+
+func (m MockBBashDB) UpsertCampaignConfigStage(ctx context.Context, config *types.CampaignConfigStruct) (err error) {
 	if m.assertParameters {
-		assert.Equal(m.t, m.updatePoll, poll)
+		assert.Equal(m.t, m.upsertConfigStage, config)
 	}
-	return m.updatePollErr
+	return m.upsertConfigStageErr
 }
 
-func (m MockBBashDB) SelectPoll(poll *types.Poll) (err error) {
+func (m MockBBashDB) SelectCampaignConfigStage(ctx context.Context, campaignName string) (config *types.CampaignConfigStruct, err error) {
 	if m.assertParameters {
-		assert.Equal(m.t, m.selectPoll, poll)
+		assert.Equal(m.t, m.selectConfigStageCampName, campaignName)
 	}
-	return m.selectPollErr
+	return m.selectConfigStageResult, m.selectConfigStageErr
 }
 
-var _ db.IBBashDB = (*MockBBashDB)(nil)
+func (m MockBBashDB) DeleteCampaignConfigStage(ctx context.Context, campaignName string) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.deleteConfigStageCampName, campaignName)
+	}
+	return m.deleteConfigStageRowsAffected, m.deleteConfigStageErr
+}
 
-func newMockDb(t *testing.T) (mockDbIF *MockBBashDB) {
-	mockDbIF = &MockBBashDB{
-		t:                t,
-		assertParameters: true,
+func (m MockBBashDB) PromoteCampaignConfigStage(ctx context.Context, campaignName string) (config *types.CampaignConfigStruct, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.promoteConfigStageCampName, campaignName)
 	}
-	// reset loop kludge counters
-	insertBugGuidCount = 0
-	priorScoreCallCount = 0
-	updateScoreLastDelta = 0
+	return m.promoteConfigStageResult, m.promoteConfigStageErr
+}
 
-	logger = zaptest.NewLogger(t)
+func (m MockBBashDB) UpsertSlackNotification(ctx context.Context, config *types.SlackNotificationStruct) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.upsertSlack, config)
+	}
+	return m.upsertSlackErr
+}
 
-	// side effect: set up the postgresDB var
-	postgresDB = mockDbIF
-	return
+func (m MockBBashDB) SelectSlackNotification(ctx context.Context, campaignName string) (config *types.SlackNotificationStruct, err error) {
+	if m.assertParameters && m.selectSlackCampName != "" {
+		assert.Equal(m.t, m.selectSlackCampName, campaignName)
+	}
+	return m.selectSlackResult, m.selectSlackErr
 }
 
-func TestZapLoggerFilterSkipsELB(t *testing.T) {
-	req := httptest.NewRequest("", "/", nil)
-	req.Header.Set("User-Agent", "bing ELB-HealthChecker yadda")
-	logger := zaptest.NewLogger(t)
-	result := ZapLoggerFilterAwsElb(logger)
+func (m MockBBashDB) UpsertCampaignPenalty(ctx context.Context, penalty *types.CampaignPenaltyStruct) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.upsertPenalty, penalty)
+	}
+	return m.upsertPenaltyErr
+}
 
-	//handlerFunc := func(next echo.HandlerFunc) echo.HandlerFunc {
-	//	return func(c echo.Context) error {
-	//		return nil
-	//	}
-	//}
-	//r2 := result(handlerFunc)
-	//assert.Nil(t, result)
-	// @TODO figure out how to test these hoops
-	result(nil)
+func (m MockBBashDB) SelectCampaignPenalty(ctx context.Context, campaignName string) (penalty *types.CampaignPenaltyStruct, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectPenaltyCampName, campaignName)
+	}
+	return m.selectPenaltyResult, m.selectPenaltyErr
 }
 
-func TestMainDBPingError(t *testing.T) {
-	errRecovered = nil
-	origEnvPGHost := os.Getenv(envPGHost)
-	defer func() {
-		resetEnvVarPGHost(t, origEnvPGHost)
-	}()
-	assert.NoError(t, os.Setenv(envPGHost, "bogus-db-hostname"))
+func (m MockBBashDB) UpsertCampaignCaps(ctx context.Context, caps *types.CampaignCapsStruct) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.upsertCaps, caps)
+	}
+	return m.upsertCapsErr
+}
 
-	defer func() {
-		errRecovered = nil
-	}()
+func (m MockBBashDB) SelectCampaignCaps(ctx context.Context, campaignName string) (caps *types.CampaignCapsStruct, err error) {
+	// every scoring reads the caps, so only check the campaign of tests that cap
+	if m.selectCapsCampName != "" {
+		assert.Equal(m.t, m.selectCapsCampName, campaignName)
+	}
+	return m.selectCapsResult, m.selectCapsErr
+}
 
-	main()
-
-	assert.True(t, strings.HasPrefix(errRecovered.Error(), "failed to ping database. host: bogus-db-hostname, port: "))
+func (m MockBBashDB) UpsertDuplicatePolicy(ctx context.Context, policy *types.DuplicatePolicyStruct) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.upsertDuplicatePolicy, policy)
+	}
+	return m.upsertDuplicatePolicyErr
 }
 
-func TestMainDBMigrateError(t *testing.T) {
-	errRecovered = nil
-	origEnvPGHost := os.Getenv(envPGHost)
-	defer func() {
-		resetEnvVarPGHost(t, origEnvPGHost)
-	}()
-	assert.NoError(t, os.Setenv(envPGHost, "localhost"))
+func (m MockBBashDB) SelectDuplicatePolicy(ctx context.Context, campaignName string) (policy *types.DuplicatePolicyStruct, err error) {
+	if m.selectDuplicatePolicyCampName != "" {
+		assert.Equal(m.t, m.selectDuplicatePolicyCampName, campaignName)
+	}
+	return m.selectDuplicatePolicyResult, m.selectDuplicatePolicyErr
+}
 
-	// setup mock db endpoint
-	l, err := net.Listen("tcp", "localhost:0")
-	assert.NoError(t, err)
-	defer func(l net.Listener) {
-		_ = l.Close()
-	}(l)
-	//goland:noinspection HttpUrlsUsage
-	u, err := url2.Parse("http://" + l.Addr().String())
-	assert.NoError(t, err)
-	freeLocalPort := u.Port()
-	assert.NoError(t, os.Setenv(envPGPort, freeLocalPort))
-	go func() {
-		conn, err := l.Accept()
-		assert.NoError(t, err)
-		defer func(conn net.Conn) {
-			_ = conn.Close()
-		}(conn)
-		b := make([]byte, 0, 512)
-		count, err := conn.Read(b)
-		_, _ = conn.Write(b)
-		assert.NoError(t, err)
-		assert.Equal(t, count, 0)
-	}()
+func (m MockBBashDB) SelectDuplicateFingerprints(ctx context.Context, participant *types.ParticipantStruct, msg *types.ScoringMessage) (duplicates []string, err error) {
+	return m.selectDuplicatesResult, m.selectDuplicatesErr
+}
 
-	defer func() {
-		errRecovered = nil
-	}()
+func (m MockBBashDB) UpsertCoAuthorPolicy(ctx context.Context, policy *types.CoAuthorPolicyStruct) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.upsertCoAuthorPolicy, policy)
+	}
+	return m.upsertCoAuthorPolicyErr
+}
 
-	main()
+func (m MockBBashDB) SelectCoAuthorPolicy(ctx context.Context, campaignName string) (policy *types.CoAuthorPolicyStruct, err error) {
+	if m.selectCoAuthorPolicyCampName != "" {
+		assert.Equal(m.t, m.selectCoAuthorPolicyCampName, campaignName)
+	}
+	return m.selectCoAuthorPolicyResult, m.selectCoAuthorPolicyErr
+}
 
-	assert.True(t, strings.HasPrefix(errRecovered.Error(), "failed to ping database. host: localhost, port: "+freeLocalPort), errRecovered.Error())
+func (m MockBBashDB) UpsertCampaignPrivacy(ctx context.Context, privacy *types.CampaignPrivacyStruct) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.upsertPrivacy, privacy)
+	}
+	return m.upsertPrivacyErr
 }
 
-func TestMigrateDB(t *testing.T) {
-	dbMock := newMockDb(t)
-	dbMock.migrateDbSourceURL = "testMigrateUrl"
-	assert.NoError(t, dbMock.MigrateDB("testMigrateUrl"))
+func (m MockBBashDB) SelectCampaignPrivacy(ctx context.Context, campaignName string) (privacy *types.CampaignPrivacyStruct, err error) {
+	if m.selectPrivacyCampName != "" {
+		assert.Equal(m.t, m.selectPrivacyCampName, campaignName)
+	}
+	return m.selectPrivacyResult, m.selectPrivacyErr
 }
 
-func TestSetupRoutes(t *testing.T) {
-	e := echo.New()
+func (m MockBBashDB) SelectCoAuthorPriorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage) (oldPoints float64) {
+	return m.coAuthorPriorScore
+}
 
-	logger = zaptest.NewLogger(t)
+// insertCoAuthorScores kludge keeps the login=points of each co-author score inserted, for the test to check
+var insertCoAuthorScores []string
 
-	customRouteCount := setupRoutes(e, "myBuildInfoMsg")
-	routes := e.Routes()
-	// when using "groups", extra "default" routes are automatically added by echo
-	//assert.Equal(t, 22, len(routes))
-	// Out main() method will only print "custom" routes, ignoring defaults added by echo. such defaults are still
-	// included in the "total" route count below
-	assert.Equal(t, 200, len(routes))
+func (m MockBBashDB) InsertCoAuthorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage, newPoints float64) (err error) {
+	insertCoAuthorScores = append(insertCoAuthorScores, fmt.Sprintf("%s=%g", coAuthor.LoginName, newPoints))
+	return m.insertCoAuthorScoreErr
+}
 
-	assert.Equal(t, 23, customRouteCount)
+func (m MockBBashDB) SelectCoAuthorScores(ctx context.Context, participant *types.ParticipantStruct, msg *types.ScoringMessage) (coAuthors []db.CoAuthorScoreRow, err error) {
+	return m.coAuthorScoresResult, m.coAuthorScoresErr
 }
 
-const timeLayout = "2006-01-02T15:04:05.000Z"
+// deleteCoAuthorScores kludge keeps the login of each co-author score deleted, for the test to check
+var deleteCoAuthorScores []string
 
-var testStartOn time.Time
-var testEndOn time.Time
+func (m MockBBashDB) DeleteCoAuthorScore(ctx context.Context, coAuthor *types.ParticipantStruct, msg *types.ScoringMessage) (err error) {
+	deleteCoAuthorScores = append(deleteCoAuthorScores, coAuthor.LoginName)
+	return m.deleteCoAuthorScoreErr
+}
 
-func init() {
-	var err error
-	testStartOn, err = time.Parse(timeLayout, "2021-11-01T12:00:00.000Z")
-	if err != nil {
-		panic(err)
+func (m MockBBashDB) SelectScoringEventsSince(ctx context.Context, participant *types.ParticipantStruct, since time.Time) (events []db.ScoringEventRow, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectEventsSinceParticipant, participant)
+		assert.Equal(m.t, m.selectEventsSince, since)
 	}
-	testEndOn, err = time.Parse(timeLayout, "2021-11-02T12:00:00.000Z")
-	if err != nil {
-		panic(err)
+	return m.selectEventsSinceResult, m.selectEventsSinceErr
+}
+
+func (m MockBBashDB) SelectUnclassifiedBonus(ctx context.Context, campaignName string) (bonus float64, err error) {
+	if m.assertParameters && m.selectBonusCampName != "" {
+		assert.Equal(m.t, m.selectBonusCampName, campaignName)
 	}
+	return m.selectBonusResult, m.selectBonusErr
 }
-func setupMockContextCampaign(campaignName string) (c echo.Context, rec *httptest.ResponseRecorder, expectedCampaign *types.CampaignStruct) {
-	c, rec = setupMockContextCampaignWithBody(campaignName, fmt.Sprintf("{ \"startOn\": \"%s\", \"endOn\": \"%s\"}",
-		testStartOn.Format(timeLayout), testEndOn.Format(timeLayout)))
-	expectedCampaign = &types.CampaignStruct{
-		Name:    campaignName,
-		StartOn: testStartOn,
-		EndOn:   testEndOn,
+
+func (m MockBBashDB) UpsertPointValueOverride(ctx context.Context, override *types.PointValueOverride) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.upsertOverride, override)
 	}
-	return
+	override.Id = m.upsertOverrideId
+	return m.upsertOverrideErr
 }
-func setupMockContextCampaignWithBody(campaignName, bodyCampaign string) (c echo.Context, rec *httptest.ResponseRecorder) {
-	e := echo.New()
-	req := httptest.NewRequest("", "/", strings.NewReader(bodyCampaign))
-	rec = httptest.NewRecorder()
-	c = e.NewContext(req, rec)
-	c.SetParamNames(ParamCampaignName)
-	c.SetParamValues(campaignName)
-	return
+
+func (m MockBBashDB) SelectPointValueOverrides(ctx context.Context, campaignName string) (overrides []types.PointValueOverride, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectOverridesCampName, campaignName)
+	}
+	return m.selectOverridesResult, m.selectOverridesErr
 }
 
-func TestAddCampaignEmptyName(t *testing.T) {
-	campaignName := " "
-	c, rec, testCampaign := setupMockContextCampaign(campaignName)
+func (m MockBBashDB) DeletePointValueOverride(ctx context.Context, override *types.PointValueOverride) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.deleteOverride, override)
+	}
+	return m.deleteOverrideRowsAffected, m.deleteOverrideErr
+}
 
-	mock := newMockDb(t)
-	mock.insertCampaignParam = testCampaign
+func (m MockBBashDB) InsertBugCategoryExclusion(ctx context.Context, exclusion *types.BugCategoryExclusion) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.insertExclusion, exclusion)
+	}
+	exclusion.Id = m.insertExclusionId
+	return m.insertExclusionErr
+}
 
-	expectedError := fmt.Errorf("invalid parameter %s: %s", ParamCampaignName, "")
+func (m MockBBashDB) SelectBugCategoryExclusions(ctx context.Context, campaignName string) (exclusions []types.BugCategoryExclusion, err error) {
+	// every scoring of bug counts reads the exclusions, so only check the campaign of tests that exclude
+	if m.selectExclusionsCampName != "" {
+		assert.Equal(m.t, m.selectExclusionsCampName, campaignName)
+	}
+	return m.selectExclusionsResult, m.selectExclusionsErr
+}
 
-	assert.NoError(t, addCampaign(c))
-	assert.Equal(t, http.StatusBadRequest, c.Response().Status)
-	assert.Equal(t, expectedError.Error(), rec.Body.String())
+func (m MockBBashDB) DeleteBugCategoryExclusion(ctx context.Context, campaignName, category string) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.deleteExclusionCampName, campaignName)
+		assert.Equal(m.t, m.deleteExclusionCategory, category)
+	}
+	return m.deleteExclusionRowsAffected, m.deleteExclusionErr
 }
 
-func TestGetCampaignsError(t *testing.T) {
-	c, rec := setupMockContext()
+func (m MockBBashDB) InsertRepositoryFilter(ctx context.Context, filter *types.RepositoryFilter) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.insertRepoFilter, filter)
+	}
+	filter.Id = m.insertRepoFilterId
+	return m.insertRepoFilterErr
+}
 
-	mock := newMockDb(t)
-	forcedError := fmt.Errorf("forced campaign error")
-	mock.getCampaignsErr = forcedError
+func (m MockBBashDB) SelectRepositoryFilters(ctx context.Context, campaignName string) (filters []types.RepositoryFilter, err error) {
+	// every scored participant reads the filters of its campaign, so only check the campaign of tests that filter
+	if m.selectRepoFiltersCampName != "" {
+		assert.Equal(m.t, m.selectRepoFiltersCampName, campaignName)
+	}
+	return m.selectRepoFiltersResult, m.selectRepoFiltersErr
+}
 
-	assert.EqualError(t, getCampaigns(c), forcedError.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+func (m MockBBashDB) DeleteRepositoryFilter(ctx context.Context, campaignName, filterId string) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.deleteRepoFilterCampName, campaignName)
+		assert.Equal(m.t, m.deleteRepoFilterId, filterId)
+	}
+	return m.deleteRepoFilterRowsAffected, m.deleteRepoFilterErr
 }
 
-func TestGetCampaigns(t *testing.T) {
-	c, rec := setupMockContext()
+func (m MockBBashDB) DeleteSlackNotification(ctx context.Context, campaignName string) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.deleteSlackCampName, campaignName)
+	}
+	return m.deleteSlackRowsAffected, m.deleteSlackErr
+}
 
-	mock := newMockDb(t)
-	mock.getCampaignsResult = []types.CampaignStruct{{
-		ID:           campaignId,
-		Name:         campaign,
-		CreatedOn:    time.Time{},
-		CreatedOrder: 1,
-		StartOn:      now,
-		EndOn:        now,
-	}}
-	assert.NoError(t, getCampaigns(c))
-	assert.Equal(t, http.StatusOK, c.Response().Status)
-	expectedCampaigns := []types.CampaignStruct{
-		{ID: campaignId, Name: campaign, CreatedOn: time.Time{}, CreatedOrder: 1, StartOn: now, EndOn: now},
+func (m MockBBashDB) SelectTopParticipants(ctx context.Context, campaignName string, count int) (participants []types.ParticipantStruct, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectTopCampName, campaignName)
+		assert.Equal(m.t, m.selectTopCount, count)
 	}
-	jsonExpectedCampaign, err := json.Marshal(expectedCampaigns)
-	assert.NoError(t, err)
-	assert.Equal(t, string(jsonExpectedCampaign)+"\n", rec.Body.String())
+	return m.selectTopResult, m.selectTopErr
 }
 
-func TestGetActiveCampaignsError(t *testing.T) {
-	c, rec, testCampaign := setupMockContextCampaign(campaign)
+func (m MockBBashDB) SelectLeaderboardStale(ctx context.Context) (stale bool, err error) {
+	return m.leaderboardStale, m.leaderboardStaleErr
+}
 
-	mock := newMockDb(t)
-	mock.getActiveCampaignsResult = []types.CampaignStruct{*testCampaign}
+func (m MockBBashDB) RefreshLeaderboard(ctx context.Context) (err error) {
+	if m.refreshLeaderboardCalled != nil {
+		*m.refreshLeaderboardCalled = true
+	}
+	return m.refreshLeaderboardErr
+}
 
-	forcedError := fmt.Errorf("forced campaign error")
-	mock.getActiveCampaignsErr = forcedError
-	// caller users Time.now(), so don't assert time parameter
-	mock.getActiveCampaignsParamSkip = true
-	assert.NoError(t, getActiveCampaigns(c))
-	assert.Equal(t, http.StatusBadRequest, c.Response().Status)
-	assert.Equal(t, forcedError.Error(), rec.Body.String())
+func (m MockBBashDB) SelectLeaderboard(ctx context.Context, campaignName string, limit int) (leaderboard *types.Leaderboard, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.leaderboardCampName, campaignName)
+		assert.Equal(m.t, m.leaderboardLimit, limit)
+	}
+	return m.leaderboardResult, m.leaderboardErr
 }
 
-func TestGetActiveCampaigns(t *testing.T) {
-	c, rec, _ := setupMockContextCampaign(campaign)
+func (m MockBBashDB) SelectTeamLeaderboard(ctx context.Context, campaignName, teamName string) (leaderboard *types.TeamLeaderboard, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.teamLeaderboardCampName, campaignName)
+		assert.Equal(m.t, m.teamLeaderboardTeamName, teamName)
+	}
+	return m.teamLeaderboardResult, m.teamLeaderboardErr
+}
 
-	mock := newMockDb(t)
-	mock.getActiveCampaignsResult = []types.CampaignStruct{
-		{ID: campaignId, Name: campaign, StartOn: now, EndOn: now},
+func (m MockBBashDB) InsertLeaderboardSnapshot(ctx context.Context, campaignName string, now time.Time) (finalized bool, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.insertSnapshotCampName, campaignName)
 	}
-	// caller users Time.now(), so don't assert time parameter
-	mock.getActiveCampaignsParamSkip = true
+	if m.insertSnapshotCalled != nil {
+		*m.insertSnapshotCalled = true
+	}
+	return m.insertSnapshotFinalized, m.insertSnapshotErr
+}
 
-	assert.NoError(t, getActiveCampaigns(c))
-	assert.Equal(t, http.StatusOK, c.Response().Status)
+func (m MockBBashDB) SelectLeaderboardSnapshot(ctx context.Context, campaignName string) (snapshot *types.LeaderboardSnapshot, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectSnapshotCampName, campaignName)
+	}
+	return m.selectSnapshotResult, m.selectSnapshotErr
+}
 
-	jsonExpectedCampaign, err := json.Marshal(mock.getActiveCampaignsResult)
-	assert.NoError(t, err)
-	assert.Equal(t, string(jsonExpectedCampaign)+"\n", rec.Body.String())
+func (m MockBBashDB) UpsertCampaignEmail(ctx context.Context, email *types.CampaignEmailStruct) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.upsertEmail, email)
+	}
+	return m.upsertEmailErr
 }
 
-func TestAddCampaignErrorReadingCampaignFromRequestBody(t *testing.T) {
-	c, rec := setupMockContextCampaignWithBody(campaign, "")
+func (m MockBBashDB) SelectCampaignEmails(ctx context.Context, campaignName string) (emails []types.CampaignEmailStruct, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectEmailsCampName, campaignName)
+	}
+	return m.selectEmailsResult, m.selectEmailsErr
+}
 
-	assert.EqualError(t, addCampaign(c), "EOF")
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+func (m MockBBashDB) SelectCampaignEmail(ctx context.Context, campaignName, kind string) (email *types.CampaignEmailStruct, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectEmailCampName, campaignName)
+		assert.Equal(m.t, m.selectEmailKind, kind)
+	}
+	return m.selectEmailResult, m.selectEmailErr
 }
 
-func TestAddCampaignError(t *testing.T) {
-	c, rec, testCampaign := setupMockContextCampaign(campaign)
+func (m MockBBashDB) SelectBugsFixed(ctx context.Context, participant *types.ParticipantStruct) (bugsFixed float64, err error) {
+	if m.assertParameters && m.selectBugsFixedParticipant != nil {
+		assert.Equal(m.t, m.selectBugsFixedParticipant, participant)
+	}
+	return m.selectBugsFixedResult, m.selectBugsFixedErr
+}
 
-	mock := newMockDb(t)
-	mock.insertCampaignParam = testCampaign
-	forcedError := fmt.Errorf("forced campaign error")
-	mock.insertCampaignErr = forcedError
+// insertAchievementKinds kludge records every achievement awarded by the test
+var insertAchievementKinds []string
 
-	assert.EqualError(t, addCampaign(c), forcedError.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+func (m MockBBashDB) InsertAchievement(ctx context.Context, participantId, kind string, now time.Time) (awarded bool, err error) {
+	insertAchievementKinds = append(insertAchievementKinds, participantId+":"+kind)
+	return m.insertAchievementAwarded, m.insertAchievementErr
 }
 
-func TestAddCampaign(t *testing.T) {
-	c, rec, testCampaign := setupMockContextCampaign(campaign)
-
-	mock := newMockDb(t)
-	mock.insertCampaignParam = testCampaign
-	mock.insertCampaignGuid = campaignId
+func (m MockBBashDB) SelectAchievements(ctx context.Context, campaignName, scpName, loginName string) (achievements []types.AchievementStruct, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectAchievementsCampName, campaignName)
+		assert.Equal(m.t, m.selectAchievementsScpName, scpName)
+		assert.Equal(m.t, m.selectAchievementsLoginName, loginName)
+	}
+	return m.selectAchievementsResult, m.selectAchievementsErr
+}
 
-	assert.NoError(t, addCampaign(c))
-	assert.Equal(t, http.StatusCreated, c.Response().Status)
-	assert.Equal(t, campaignId, rec.Body.String())
+func (m MockBBashDB) SelectParticipantScoreAt(ctx context.Context, campaignName, scpName, loginName string, at time.Time) (score *types.ParticipantScoreAt, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectScoreAtCampName, campaignName)
+		assert.Equal(m.t, m.selectScoreAtScpName, scpName)
+		assert.Equal(m.t, m.selectScoreAtLoginName, loginName)
+		if !m.selectScoreAtAt.IsZero() {
+			assert.True(m.t, m.selectScoreAtAt.Equal(at), at)
+		}
+	}
+	return m.selectScoreAtResult, m.selectScoreAtErr
 }
 
-func TestUpdateCampaignMissingParamCampaign(t *testing.T) {
-	c, rec, _ := setupMockContextCampaign("")
+func (m MockBBashDB) SelectAchievementStats(ctx context.Context, campaignName string) (stats []types.AchievementStat, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectAchievementStatsCampName, campaignName)
+	}
+	return m.selectAchievementStatsResult, m.selectAchievementStatsErr
+}
 
-	assert.NoError(t, updateCampaign(c))
-	assert.Equal(t, http.StatusBadRequest, c.Response().Status)
-	assert.Equal(t, "invalid parameter campaignName: ", rec.Body.String())
+func (m MockBBashDB) SelectScoreTimeSeries(ctx context.Context, campaignName, interval string, byTeam bool) (points []types.TimeSeriesPoint, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectTimeSeriesCampName, campaignName)
+		assert.Equal(m.t, m.selectTimeSeriesInterval, interval)
+		assert.Equal(m.t, m.selectTimeSeriesByTeam, byTeam)
+	}
+	return m.selectTimeSeriesResult, m.selectTimeSeriesErr
 }
 
-func TestUpdateCampaignErrorReadingCampaignFromRequestBody(t *testing.T) {
-	c, rec := setupMockContextCampaignWithBody(campaign, "")
+// insertTelemetryCount kludge counts the telemetry events stored by the test
+var insertTelemetryCount int
 
-	assert.EqualError(t, updateCampaign(c), "EOF")
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+func (m MockBBashDB) InsertTelemetryEvent(ctx context.Context, feature, call string, now time.Time) (err error) {
+	insertTelemetryCount++
+	if m.assertParameters && m.insertTelemetryFeature != "" {
+		assert.Equal(m.t, m.insertTelemetryFeature, feature)
+		assert.Equal(m.t, m.insertTelemetryCall, call)
+	}
+	return m.insertTelemetryErr
 }
 
-func TestUpdateCampaignError(t *testing.T) {
-	c, rec, testCampaign := setupMockContextCampaign(campaign)
+func (m MockBBashDB) SelectTelemetryUsage(ctx context.Context, from, to time.Time) (usage []types.TelemetryUsage, err error) {
+	if m.assertParameters && !m.selectTelemetryTo.IsZero() {
+		assert.Equal(m.t, m.selectTelemetryFrom, from)
+		assert.Equal(m.t, m.selectTelemetryTo, to)
+	}
+	return m.selectTelemetryResult, m.selectTelemetryErr
+}
 
-	mock := newMockDb(t)
-	mock.updateCampaignParam = testCampaign
-	forcedError := fmt.Errorf("forced scan error update campaign")
-	mock.updateCampaignErr = forcedError
+// insertAuditLast kludge keeps the last audit log entry for the test to check
+var insertAuditLast *types.AuditLogEntry
 
-	assert.EqualError(t, updateCampaign(c), forcedError.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+func (m MockBBashDB) InsertAuditLog(ctx context.Context, entry *types.AuditLogEntry) (err error) {
+	insertAuditLast = entry
+	return m.insertAuditErr
 }
 
-func TestUpdateCampaign(t *testing.T) {
-	c, rec, testCampaign := setupMockContextCampaign(campaign)
+func (m MockBBashDB) SelectAuditLog(ctx context.Context, actor string, limit int) (entries []types.AuditLogEntry, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectAuditActor, actor)
+		assert.Equal(m.t, m.selectAuditLimit, limit)
+	}
+	return m.selectAuditResult, m.selectAuditErr
+}
 
-	mock := newMockDb(t)
-	mock.updateCampaignParam = testCampaign
-	mock.updateCampaignGuid = campaignId
+// updateProfileCount kludge counts the profiles refreshed by the test
+var updateProfileCount int
 
-	assert.NoError(t, updateCampaign(c))
-	assert.Equal(t, http.StatusOK, c.Response().Status)
-	assert.Equal(t, campaignId, rec.Body.String())
+func (m MockBBashDB) UpdateParticipantProfile(ctx context.Context, participantId, displayName, avatarUrl string, now time.Time) (err error) {
+	updateProfileCount++
+	if m.assertParameters && m.updateProfileId != "" {
+		assert.Equal(m.t, m.updateProfileId, participantId)
+		assert.Equal(m.t, m.updateProfileDisplayName, displayName)
+		assert.Equal(m.t, m.updateProfileAvatarUrl, avatarUrl)
+	}
+	return m.updateProfileErr
 }
 
-func setupMockContextParticipant(participantJson string) (c echo.Context, rec *httptest.ResponseRecorder) {
-	e := echo.New()
-	req := httptest.NewRequest("", "/", strings.NewReader(participantJson))
-	rec = httptest.NewRecorder()
-	c = e.NewContext(req, rec)
-	return
+func (m MockBBashDB) SelectParticipantsToRefresh(ctx context.Context, refreshedBefore time.Time, limit int) (participants []types.ParticipantStruct, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectRefreshBefore, refreshedBefore)
+		assert.Equal(m.t, profileRefreshBatch, limit)
+	}
+	return m.selectRefreshResult, m.selectRefreshErr
 }
 
-func TestAddParticipantBodyInvalid(t *testing.T) {
-	c, rec := setupMockContextParticipant("")
-
-	assert.EqualError(t, addParticipant(c), "EOF")
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+func (m MockBBashDB) UpdateParticipantContact(ctx context.Context, scpName, loginName string, request *types.ParticipantProfileRequest) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.updateContactScpName, scpName)
+		assert.Equal(m.t, m.updateContactLoginName, loginName)
+		assert.Equal(m.t, m.updateContactRequest, request)
+	}
+	return m.updateContactRowsAffected, m.updateContactErr
 }
 
-func TestAddParticipantCampaignMissing(t *testing.T) {
-	participantJson := fmt.Sprintf(`{"campaignName":"%s", "loginName": "%s"}`, campaign, loginName)
-	c, rec := setupMockContextParticipant(participantJson)
+func (m MockBBashDB) SearchParticipants(ctx context.Context, login string, limit int) (participants []types.ParticipantStruct, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.searchLogin, login)
+		assert.Equal(m.t, m.searchLimit, limit)
+	}
+	return m.searchResult, m.searchErr
+}
 
-	mock := newMockDb(t)
-	mock.insertParticipantPartier = &types.ParticipantStruct{
-		CampaignName: campaign,
-		LoginName:    loginName,
+func (m MockBBashDB) InsertWebhook(ctx context.Context, hook *types.WebhookStruct) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.insertWebhook, hook)
 	}
-	forcedError := fmt.Errorf("forced SQL insert error")
-	mock.insertParticipantErr = forcedError
+	hook.ID = m.insertWebhookId
+	hook.CreatedOn = m.insertWebhookCreatedOn
+	return m.insertWebhookErr
+}
 
-	assert.EqualError(t, addParticipant(c), forcedError.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+func (m MockBBashDB) SelectWebhooks(ctx context.Context) (hooks []types.WebhookStruct, err error) {
+	return m.selectWebhooksResult, m.selectWebhooksErr
 }
 
-func TestAddParticipant(t *testing.T) {
-	participantJson := fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s"}`, campaign, scpName, loginName)
-	c, rec := setupMockContextParticipant(participantJson)
+func (m MockBBashDB) DeleteWebhook(ctx context.Context, webhookId string) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.deleteWebhookId, webhookId)
+	}
+	return m.deleteWebhookRowsAffected, m.deleteWebhookErr
+}
 
-	mock := newMockDb(t)
-	mock.insertParticipantPartier = &types.ParticipantStruct{
-		CampaignName: campaign,
-		ScpName:      scpName,
-		LoginName:    loginName,
+func (m MockBBashDB) ClaimCampaignTransitions(ctx context.Context, now time.Time) (transitions []types.CampaignTransition, err error) {
+	if m.assertParameters && !m.claimTransitionsNow.IsZero() {
+		assert.Equal(m.t, m.claimTransitionsNow, now)
 	}
-	mock.insertParticipantGuid = participantID
-	mock.insertParticipantJoinedAt = now
+	return m.claimTransitionsResult, m.claimTransitionsErr
+}
 
-	assert.NoError(t, addParticipant(c))
-	assert.Equal(t, http.StatusCreated, c.Response().Status)
-	assert.True(t, strings.HasPrefix(rec.Body.String(), `{"guid":"`+participantID+`","endpoints":{"participantDetail"`), rec.Body.String())
-	assert.True(t, strings.Contains(rec.Body.String(), `"loginName":"`+loginName+`"`), rec.Body.String())
+func (m MockBBashDB) InsertNotification(ctx context.Context, notification *types.NotificationStruct) (err error) {
+	// multiple mock kludge, keep the last one for the test to check
+	insertNotificationLast = notification
+	return m.insertNotificationErr
 }
 
-func TestLogAddParticipantWithError(t *testing.T) {
-	c, rec := setupMockContext()
-	err := logAddParticipant(c)
-	assert.EqualError(t, err, "EOF")
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+func (m MockBBashDB) SelectNotifications(ctx context.Context, campaignName, scpName, loginName string, unreadOnly bool) (notifications []types.NotificationStruct, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectNotificationsCampName, campaignName)
+		assert.Equal(m.t, m.selectNotificationsScpName, scpName)
+		assert.Equal(m.t, m.selectNotificationsLoginName, loginName)
+		assert.Equal(m.t, m.selectNotificationsUnreadOnly, unreadOnly)
+	}
+	return m.selectNotificationsResult, m.selectNotificationsErr
 }
 
-func TestLogAddParticipantNoError(t *testing.T) {
-	participantJson := fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s"}`, campaign, scpName, loginName)
-	c, rec := setupMockContextParticipant(participantJson)
+//goland:noinspection GoUnusedParameter
+func (m MockBBashDB) UpdateNotificationsRead(ctx context.Context, campaignName, scpName, loginName, notificationId string, now time.Time) (rowsAffected int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.readNotificationsCampName, campaignName)
+		assert.Equal(m.t, m.readNotificationsScpName, scpName)
+		assert.Equal(m.t, m.readNotificationsLoginName, loginName)
+		assert.Equal(m.t, m.readNotificationsId, notificationId)
+	}
+	return m.readNotificationsRowsAffected, m.readNotificationsErr
+}
 
-	mock := newMockDb(t)
-	mock.insertParticipantPartier = &types.ParticipantStruct{
-		CampaignName: campaign,
-		ScpName:      scpName,
-		LoginName:    loginName,
+func (m MockBBashDB) UpsertClusterInstance(ctx context.Context, instance *types.ClusterInstance) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.upsertClusterInstance, instance)
 	}
-	mock.insertParticipantGuid = participantID
-	mock.insertParticipantJoinedAt = now
+	return m.upsertClusterInstanceErr
+}
 
-	err := logAddParticipant(c)
-	assert.Nil(t, err)
-	assert.Equal(t, http.StatusCreated, c.Response().Status)
-	assert.True(t, strings.HasPrefix(rec.Body.String(), `{"guid":"`+participantID+`","endpoints":{"participantDetail"`), rec.Body.String())
-	assert.True(t, strings.Contains(rec.Body.String(), `"loginName":"`+loginName+`"`), rec.Body.String())
+func (m MockBBashDB) SelectClusterInstances(ctx context.Context) (instances []types.ClusterInstance, err error) {
+	return m.selectClusterInstancesResult, m.selectClusterInstancesErr
 }
 
-func setupMockContextUpdateParticipant(participantJson string) (c echo.Context, rec *httptest.ResponseRecorder) {
-	e := echo.New()
-	req := httptest.NewRequest("", "/", strings.NewReader(participantJson))
-	rec = httptest.NewRecorder()
-	c = e.NewContext(req, rec)
-	return
+func (m MockBBashDB) AcquirePollLease(ctx context.Context, instanceId string, now, expiresOn time.Time) (held bool, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, thisInstance.InstanceId, instanceId)
+		assert.Equal(m.t, now.Add(pollLeaseDuration()), expiresOn)
+	}
+	return m.acquirePollLeaseHeld, m.acquirePollLeaseErr
 }
 
-func TestUpdateParticipantBodyInvalid(t *testing.T) {
-	c, rec := setupMockContextUpdateParticipant("")
+func (m MockBBashDB) ReleasePollLease(ctx context.Context, instanceId string) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, thisInstance.InstanceId, instanceId)
+	}
+	return m.releasePollLeaseErr
+}
 
-	assert.EqualError(t, updateParticipant(c), "EOF")
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+func (m MockBBashDB) UpsertScheduledJobRun(ctx context.Context, run *types.ScheduledJobRun) (err error) {
+	return m.upsertScheduledJobRunErr
 }
 
-// unit test values
-const campaignId = "myCampaignId"
-const campaign = "myCampaignName"
-const scpName = "myScpName"
-const participantID = "participantUUId"
-const loginName = "loginName"
-const teamName = "myTeamName"
+func (m MockBBashDB) SelectScheduledJobRuns(ctx context.Context) (runs []types.ScheduledJobRun, err error) {
+	return m.selectScheduledJobRuns, m.selectScheduledJobRunsErr
+}
 
-func TestUpdateParticipantMissingParticipantID(t *testing.T) {
-	participantJson := fmt.Sprintf(`{"loginName": "%s","campaignName": "%s", "scpName": "%s"}`, loginName, campaign, scpName)
-	c, rec := setupMockContextUpdateParticipant(participantJson)
+func (m MockBBashDB) SelectMaintenance(ctx context.Context) (state types.MaintenanceState, err error) {
+	return m.selectMaintenance, m.selectMaintenanceErr
+}
 
-	mock := newMockDb(t)
-	mock.updateParticipantPartier = &types.ParticipantStruct{
-		CampaignName: campaign,
-		ScpName:      scpName,
-		LoginName:    loginName,
+// UpdateMaintenance answers as the database would when maintenance was off
+func (m MockBBashDB) UpdateMaintenance(ctx context.Context, enabled bool, message string, now time.Time) (state types.MaintenanceState, err error) {
+	if enabled {
+		state = types.MaintenanceState{Enabled: true, Message: message, Since: &now}
 	}
-	forcedError := fmt.Errorf("forced SQL insert error")
-	mock.updateParticipantErr = forcedError
-
-	assert.EqualError(t, updateParticipant(c), forcedError.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	return state, m.updateMaintenanceErr
 }
 
-func TestUpdateParticipantUpdateError(t *testing.T) {
-	participantJson := fmt.Sprintf(`{"guid": "%s","campaignName": "%s", "scpName": "%s", "loginName": "%s"}`, participantID, campaign, scpName, loginName)
-	c, rec := setupMockContextUpdateParticipant(participantJson)
-
-	mock := newMockDb(t)
-	mock.updateParticipantPartier = &types.ParticipantStruct{
-		ID:           participantID,
-		CampaignName: campaign,
-		ScpName:      scpName,
-		LoginName:    loginName,
+func (m MockBBashDB) InsertJob(ctx context.Context, job *types.Job) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.insertJobKind, job.Kind)
+		assert.Equal(m.t, types.JobStatusRunning, job.Status)
 	}
-	forcedError := fmt.Errorf("forced SQL insert error")
-	mock.updateParticipantErr = forcedError
-
-	assert.EqualError(t, updateParticipant(c), forcedError.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	job.ID = m.insertJobId
+	return m.insertJobErr
 }
 
-func TestUpdateParticipantNoRowsUpdated(t *testing.T) {
-	participantJson := fmt.Sprintf(`{"guid": "%s", "campaignName": "%s", "scpName": "%s", "loginName": "%s", "teamName": "%s"}`, participantID, campaign, scpName, loginName, teamName)
-	c, rec := setupMockContextUpdateParticipant(participantJson)
-
-	mock := newMockDb(t)
-	mock.updateParticipantPartier = &types.ParticipantStruct{
-		ID:           participantID,
-		CampaignName: campaign,
-		ScpName:      scpName,
-		LoginName:    loginName,
-		TeamName:     teamName,
+func (m MockBBashDB) UpdateJobProgress(ctx context.Context, jobId string, done, total int64) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.insertJobId, jobId)
 	}
+	return m.updateJobErr
+}
 
-	mock.updateScoreParticipant = &types.ParticipantStruct{ID: participantID}
-
-	logger = zaptest.NewLogger(t)
-
-	assert.NoError(t, updateParticipant(c))
-	assert.Equal(t, http.StatusBadRequest, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+func (m MockBBashDB) FinishJob(ctx context.Context, job *types.Job) (err error) {
+	if m.finishedJob != nil {
+		*m.finishedJob = *job
+	}
+	return m.finishJobErr
 }
 
-func TestUpdateParticipant(t *testing.T) {
-	participantJson := fmt.Sprintf(`{"guid": "%s", "campaignName": "%s", "scpName": "%s", "loginName": "%s"}`, participantID, campaign, scpName, loginName)
-	c, rec := setupMockContextUpdateParticipant(participantJson)
+func (m MockBBashDB) SelectJob(ctx context.Context, jobId string) (job *types.Job, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectJobId, jobId)
+	}
+	return m.selectJobResult, m.selectJobErr
+}
 
-	mock := newMockDb(t)
-	mock.updateParticipantPartier = &types.ParticipantStruct{
-		ID:           participantID,
-		CampaignName: campaign,
-		ScpName:      scpName,
-		LoginName:    loginName,
+func (m MockBBashDB) FailInstanceJobs(ctx context.Context, instanceId, message string, now time.Time) (failed int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.failJobsInstanceId, instanceId)
+		assert.Equal(m.t, jobOrphanedError, message)
 	}
-	mock.updateParticipantRowsAffected = 1
+	return m.failJobsResult, m.failJobsErr
+}
 
-	mock.updateScoreParticipant = &types.ParticipantStruct{
-		ID: participantID,
+func (m MockBBashDB) FailOrphanedJobs(ctx context.Context, heartbeatBefore time.Time, message string, now time.Time) (failed int64, err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.failJobsHeartbeatBefore, heartbeatBefore)
+		assert.Equal(m.t, jobOrphanedError, message)
 	}
+	return m.failJobsResult, m.failJobsErr
+}
 
-	assert.NoError(t, updateParticipant(c))
-	assert.Equal(t, http.StatusNoContent, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+type mockBackupTable struct {
+	table string
+	rows  string
 }
 
-func setupMockContextTeam(teamJson string) (c echo.Context, rec *httptest.ResponseRecorder) {
-	e := echo.New()
-	req := httptest.NewRequest("", "/", strings.NewReader(teamJson))
-	rec = httptest.NewRecorder()
-	c = e.NewContext(req, rec)
+func (m MockBBashDB) Backup(ctx context.Context, open func(table string) (io.Writer, error)) (rowCounts map[string]int64, err error) {
+	rowCounts = map[string]int64{}
+	for _, table := range m.backupRows {
+		var w io.Writer
+		if w, err = open(table.table); err != nil {
+			return
+		}
+		if _, err = io.WriteString(w, table.rows); err != nil {
+			return
+		}
+		rowCounts[table.table] = int64(strings.Count(table.rows, "\n"))
+	}
+	err = m.backupErr
 	return
 }
 
-func TestAddTeamMissingTeam(t *testing.T) {
-	c, rec := setupMockContextTeam("")
-
-	assert.EqualError(t, addTeam(c), "EOF")
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+func (m MockBBashDB) NewPoll() types.Poll {
+	return db.NewPoll()
 }
 
-func TestAddTeamInsertError(t *testing.T) {
-	teamName := "myTeamName"
-	teamJson := `{"name": "` + teamName + `"}`
-	c, rec := setupMockContextTeam(teamJson)
+func (m MockBBashDB) UpdatePoll(poll *types.Poll) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.updatePoll, poll)
+	}
+	return m.updatePollErr
+}
 
-	mock := newMockDb(t)
-	mock.insertTeamTm = &types.TeamStruct{
-		Name: teamName,
+func (m MockBBashDB) SelectPoll(poll *types.Poll) (err error) {
+	if m.assertParameters {
+		assert.Equal(m.t, m.selectPoll, poll)
 	}
-	forcedError := fmt.Errorf("forced SQL insert error")
-	mock.insertTeamErr = forcedError
+	return m.selectPollErr
+}
 
-	assert.EqualError(t, addTeam(c), forcedError.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+var _ db.IBBashDB = (*MockBBashDB)(nil)
+
+// assertApiError checks the handler returned an error, which the central error handler sends with the status and message
+func assertApiError(t *testing.T, err error, status int, message string) {
+	apiErr := toApiError(err)
+	assert.Equal(t, status, apiErr.Status)
+	assert.Equal(t, message, apiErr.Message)
 }
 
-func TestAddTeam(t *testing.T) {
-	teamJson := `{"campaignName": "` + campaign + `","name":"` + teamName + `"}`
-	c, rec := setupMockContextTeam(teamJson)
+// assertCreation asserts the body is a creationResponse with the id, and returns it
+func assertCreation(t *testing.T, rec *httptest.ResponseRecorder, id string) (creation creationResponse) {
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &creation), rec.Body.String())
+	assert.Equal(t, id, creation.Id)
+	return
+}
 
-	mock := newMockDb(t)
-	mock.insertTeamTm = &types.TeamStruct{
-		Name:         teamName,
-		CampaignName: campaign,
+func newMockDb(t *testing.T) (mockDbIF *MockBBashDB) {
+	mockDbIF = &MockBBashDB{
+		t:                t,
+		assertParameters: true,
+		// most campaigns do not post to slack
+		selectSlackErr: sql.ErrNoRows,
+		// most campaigns score the default unclassified bonus
+		selectBonusErr: sql.ErrNoRows,
+		// most campaigns cap nothing
+		selectCapsErr: sql.ErrNoRows,
+		// most campaigns skip duplicate fixes
+		selectDuplicatePolicyErr: sql.ErrNoRows,
+		// most campaigns split the points between co-authors
+		selectCoAuthorPolicyErr: sql.ErrNoRows,
+		// most campaigns show login names
+		selectPrivacyErr: sql.ErrNoRows,
 	}
+	// reset loop kludge counters
+	insertBugGuidCount = 0
+	priorScoreCallCount = 0
+	updateScoreLastDelta = 0
+	insertNotificationLast = nil
+	insertAchievementKinds = nil
+	insertTelemetryCount = 0
+	insertAuditLast = nil
+	updateProfileCount = 0
+	insertCoAuthorScores = nil
+	deleteCoAuthorScores = nil
 
-	teamID := "teamUUId"
-	mock.insertTeamGuid = teamID
+	logger = zaptest.NewLogger(t)
 
-	assert.NoError(t, addTeam(c))
-	assert.Equal(t, http.StatusCreated, c.Response().Status)
-	assert.Equal(t, teamID, rec.Body.String())
+	// side effect: set up the postgresDB var
+	postgresDB = mockDbIF
+	return
 }
 
-func setupMockContextAddPersonToTeam(campaignName, scpName, loginName, teamName string) (c echo.Context, rec *httptest.ResponseRecorder) {
-	e := echo.New()
-	req := httptest.NewRequest("", "/", nil)
-	rec = httptest.NewRecorder()
-	c = e.NewContext(req, rec)
-	c.SetParamNames(ParamCampaignName, ParamScpName, ParamLoginName, ParamTeamName)
-	c.SetParamValues(campaignName, scpName, loginName, teamName)
+// newMemoryDb sets up the postgresDB var with an empty in memory database, for tests checking what a handler stored
+// rather than the calls it made.
+func newMemoryDb(t *testing.T) (memoryDB *db.MemoryDB) {
+	logger = zaptest.NewLogger(t)
+	memoryDB = db.NewMemory(logger)
+	postgresDB = memoryDB
 	return
 }
 
-func TestAddPersonToTeamMissingParameters(t *testing.T) {
-	c, rec := setupMockContextAddPersonToTeam("", "", "", "")
+func TestZapLoggerFilterSkipsELB(t *testing.T) {
+	req := httptest.NewRequest("", "/", nil)
+	req.Header.Set("User-Agent", "bing ELB-HealthChecker yadda")
+	assert.True(t, requestLogFilterFromConfig(config.Defaults()).skip(req))
+}
 
-	assert.NoError(t, addPersonToTeam(c))
-	assert.Equal(t, http.StatusBadRequest, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+func TestRequestLogFilterSkip(t *testing.T) {
+	filter := requestLogFilter{
+		includeHostname:  "bbash.example",
+		skipUserAgents:   []string{"ELB-HealthChecker", "Prometheus"},
+		skipPathPrefixes: []string{"/metrics", "/healthz"},
+	}
+	for _, test := range []struct {
+		host      string
+		target    string
+		userAgent string
+		skip      bool
+	}{
+		{"bbash.example", "/leaderboard/myCampaign", "Mozilla/5.0", false},
+		{"", "/leaderboard/myCampaign", "Mozilla/5.0", false},
+		{"bbash.example", "/", "ELB-HealthChecker/2.0", true},
+		{"bbash.example", "/", "Prometheus/2.33.5", true},
+		{"bbash.example", "/metrics", "curl/7.79.1", true},
+		{"bbash.example", "/healthz?verbose=true", "curl/7.79.1", true},
+		{"bbash.example", "/leaderboard/metrics", "curl/7.79.1", false},
+		{"other.example", "/leaderboard/myCampaign", "Mozilla/5.0", true},
+	} {
+		req := httptest.NewRequest(http.MethodGet, test.target, nil)
+		req.Host = test.host
+		req.Header.Set("User-Agent", test.userAgent)
+		assert.Equal(t, test.skip, filter.skip(req), "%s %s %s", test.host, test.target, test.userAgent)
+	}
 }
 
-func TestAddPersonToTeamUpdateError(t *testing.T) {
-	c, rec := setupMockContextAddPersonToTeam(campaign, scpName, loginName, teamName)
+func TestZapLoggerFilter(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	e := echo.New()
+	e.Use(ZapLoggerFilter(zap.New(core), requestLogFilter{skipPathPrefixes: []string{"/healthz"}}))
+	e.GET("/healthz", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/hello", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, 0, logs.Len())
+
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hello", nil))
+	assert.Equal(t, 1, logs.Len())
+	assert.Equal(t, "Success", logs.All()[0].Message)
+}
+
+func TestMainDBPingError(t *testing.T) {
+	errRecovered = nil
+	origEnvPGHost := os.Getenv(envPGHost)
+	defer func() {
+		resetEnvVarPGHost(t, origEnvPGHost)
+	}()
+	assert.NoError(t, os.Setenv(envPGHost, "bogus-db-hostname"))
+
+	defer func() {
+		errRecovered = nil
+	}()
+
+	assert.NoError(t, runCommand([]string{commandServe}, io.Discard))
+
+	assert.True(t, strings.HasPrefix(errRecovered.Error(), "failed to ping database. host: bogus-db-hostname, port: "))
+}
+
+func TestMainDBMigrateError(t *testing.T) {
+	errRecovered = nil
+	origEnvPGHost := os.Getenv(envPGHost)
+	defer func() {
+		resetEnvVarPGHost(t, origEnvPGHost)
+	}()
+	assert.NoError(t, os.Setenv(envPGHost, "localhost"))
+
+	// setup mock db endpoint
+	l, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	defer func(l net.Listener) {
+		_ = l.Close()
+	}(l)
+	//goland:noinspection HttpUrlsUsage
+	u, err := url2.Parse("http://" + l.Addr().String())
+	assert.NoError(t, err)
+	freeLocalPort := u.Port()
+	assert.NoError(t, os.Setenv(envPGPort, freeLocalPort))
+	go func() {
+		conn, err := l.Accept()
+		assert.NoError(t, err)
+		defer func(conn net.Conn) {
+			_ = conn.Close()
+		}(conn)
+		b := make([]byte, 0, 512)
+		count, err := conn.Read(b)
+		_, _ = conn.Write(b)
+		assert.NoError(t, err)
+		assert.Equal(t, count, 0)
+	}()
+
+	defer func() {
+		errRecovered = nil
+	}()
+
+	assert.NoError(t, runCommand([]string{commandServe}, io.Discard))
+
+	assert.True(t, strings.HasPrefix(errRecovered.Error(), "failed to ping database. host: localhost, port: "+freeLocalPort), errRecovered.Error())
+}
+
+func TestMigrateDB(t *testing.T) {
+	dbMock := newMockDb(t)
+	assert.NoError(t, dbMock.MigrateDB())
+}
+
+func TestRunCommandUnknown(t *testing.T) {
+	assert.EqualError(t, runCommand([]string{"bogus"}, io.Discard), "unknown command: bogus, expected one of: serve, migrate, seed, routes")
+}
+
+func TestRunCommandHelp(t *testing.T) {
+	assert.NoError(t, runCommand([]string{commandSeed, "-h"}, io.Discard))
+}
+
+func TestRunCommandInvalidFlag(t *testing.T) {
+	assert.EqualError(t, runCommand([]string{commandRoutes, "-port", "8080"}, io.Discard), "flag provided but not defined: -port")
+}
+
+func TestRunCommandConfigMissing(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "missing.yaml")
+	assert.EqualError(t, runCommand([]string{commandRoutes, "-config", configFile}, io.Discard),
+		fmt.Sprintf("failed to load config. err: open %s: no such file or directory", configFile))
+}
+
+func TestRunCommandRoutes(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, runCommand([]string{commandRoutes}, &out))
+	assert.Contains(t, out.String(), "GET /admin/config as config-detail\n")
+}
+
+func TestPrintRoutes(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	var out bytes.Buffer
+	printRoutes(config.Defaults(), &out)
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	assert.Equal(t, 132, len(lines))
+	assert.True(t, strings.HasPrefix(lines[0], "GET / as "))
+	assert.Contains(t, lines, "GET /admin/config as config-detail")
+}
+
+func TestGetRoutes(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	e := echo.New()
+	cfg := config.Defaults()
+	cfg.TeamSelfService = true
+	setupRoutes(e, cfg, "")
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	assert.NoError(t, getRoutes(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var inventory routeInventory
+	assert.NoError(t, json.NewDecoder(rec.Body).Decode(&inventory))
+	assert.Equal(t, buildversion.BuildVersion, inventory.BuildVersion)
+	assert.Equal(t, 136, len(inventory.Routes))
+	assert.Equal(t, "/", inventory.Routes[0].Path)
+	assert.Equal(t, routeRolePublic, inventory.Routes[0].Role)
+
+	roles := map[string]string{}
+	for _, route := range inventory.Routes {
+		roles[route.Handler] = route.Role
+	}
+	assert.Equal(t, routeRoleAdmin, roles["route-list"])
+	assert.Equal(t, routeRoleAdmin, roles["config-detail"])
+	assert.Equal(t, routeRoleAdmin, roles["score-dryrun"])
+	assert.Equal(t, routeRoleTenantAdmin, roles["tenant-campaign-list"])
+	assert.Equal(t, routeRoleTeamCaptain, roles["team-self-rename"])
+	assert.Equal(t, routeRoleAdmin, roles["team-rename"])
+	assert.Equal(t, routeRoleParticipant, roles["participant-self-profile-update"])
+	assert.Equal(t, routeRoleParticipant, roles["participant-self-notification-list"])
+	assert.Equal(t, routeRolePublic, roles["leaderboard"])
+	assert.Equal(t, routeRolePublic, roles["public-campaign-leaderboard"])
+}
+
+func TestGetRoutesAdminAuth(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	e := echo.New()
+	setupRoutes(e, config.Defaults(), "")
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, pathAdmin+Routes, nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, pathAdmin+Score+DryRun, strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// no longer public
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, Score+DryRun, strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestGetLogLevel(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	defer logLevel.SetLevel(logLevel.Level())
+	logLevel.SetLevel(zapcore.InfoLevel)
+
+	c, rec := setupMockContext()
+	assert.NoError(t, getLogLevel(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"level":"info"}`+"\n", rec.Body.String())
+}
+
+func TestPutLogLevel(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	defer logLevel.SetLevel(logLevel.Level())
+	logLevel.SetLevel(zapcore.DebugLevel)
+
+	c, rec := setupMockContext()
+	c.SetParamNames(ParamLogLevel)
+	c.SetParamValues("warn")
+	assert.NoError(t, putLogLevel(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"level":"warn"}`+"\n", rec.Body.String())
+	assert.Equal(t, zapcore.WarnLevel, logLevel.Level())
+}
+
+func TestPutLogLevelInvalid(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	defer logLevel.SetLevel(logLevel.Level())
+	logLevel.SetLevel(zapcore.DebugLevel)
+
+	for _, name := range []string{"bogus", "error", "fatal"} {
+		c, _ := setupMockContext()
+		c.SetParamNames(ParamLogLevel)
+		c.SetParamValues(name)
+		err := putLogLevel(c)
+		assert.Equal(t, newApiError(http.StatusBadRequest, "invalid log level: "+name+", expected one of: [debug info warn]"), err)
+		assert.Equal(t, zapcore.DebugLevel, logLevel.Level())
+	}
+}
+
+func TestMaintenanceModeSet(t *testing.T) {
+	newMockDb(t)
+	m := &maintenanceMode{}
+	assert.Equal(t, types.MaintenanceState{}, m.State())
+
+	since := now
+	state, err := m.Set(context.Background(), true, "", now)
+	assert.NoError(t, err)
+	assert.Equal(t, types.MaintenanceState{Enabled: true, Message: defaultMaintenanceMessage, Since: &since}, state)
+	// cached, rather than read again
+	assert.Equal(t, state, m.State())
+
+	state, err = m.Set(context.Background(), false, "migrating", now)
+	assert.NoError(t, err)
+	assert.Equal(t, types.MaintenanceState{}, state)
+	assert.Equal(t, types.MaintenanceState{}, m.State())
+}
+
+func TestMaintenanceModeSetError(t *testing.T) {
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced maintenance error")
+	mock.updateMaintenanceErr = forcedError
+	m := &maintenanceMode{}
+
+	_, err := m.Set(context.Background(), true, "migrating", now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.False(t, m.State().Enabled)
+}
+
+func TestMaintenanceModeStateReadAgain(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	mock := newMockDb(t)
+	m := &maintenanceMode{}
+	assert.False(t, m.State().Enabled)
+
+	// turned on through another replica
+	mock.selectMaintenance = types.MaintenanceState{Enabled: true, Message: "migrating", Since: &now}
+	assert.False(t, m.State().Enabled)
+	m.readOn = m.readOn.Add(-maintenanceCacheTtl)
+	assert.Equal(t, mock.selectMaintenance, m.State())
+
+	// the cached state is kept when the read fails
+	mock.selectMaintenance = types.MaintenanceState{}
+	mock.selectMaintenanceErr = fmt.Errorf("forced maintenance error")
+	m.readOn = m.readOn.Add(-maintenanceCacheTtl)
+	assert.True(t, m.State().Enabled)
+}
+
+func TestUnlessInMaintenance(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	newMockDb(t)
+	defer func() {
+		maintenance = &maintenanceMode{}
+	}()
+	runs := 0
+	run := unlessInMaintenance("anomaly-detection", func(ctx context.Context, now time.Time) error {
+		runs++
+		return nil
+	})
+
+	assert.NoError(t, run(context.Background(), now))
+	assert.Equal(t, 1, runs)
+
+	_, err := maintenance.Set(context.Background(), true, "migrating", now)
+	assert.NoError(t, err)
+	assert.NoError(t, run(context.Background(), now))
+	assert.Equal(t, 1, runs)
+}
+
+func TestPutMaintenance(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	newMockDb(t)
+	defer func() {
+		maintenance = &maintenanceMode{}
+	}()
+
+	c, rec := setupMockContextWithBody(http.MethodPut, `{"enabled":true,"message":"migrating the schema"}`)
+	assert.NoError(t, putMaintenance(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	state := maintenance.State()
+	assert.True(t, state.Enabled)
+	assert.Equal(t, "migrating the schema", state.Message)
+	assert.NotNil(t, state.Since)
+
+	c, rec = setupMockContext()
+	assert.NoError(t, getMaintenance(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"enabled":true,"message":"migrating the schema","since":`)
+}
+
+func TestPutMaintenanceInvalid(t *testing.T) {
+	newMockDb(t)
+	c, _ := setupMockContextWithBody(http.MethodPut, `{"enabled":"yes"}`)
+	assertApiError(t, putMaintenance(c), http.StatusBadRequest,
+		"invalid maintenance: json: cannot unmarshal string into Go struct field MaintenanceState.enabled of type bool")
+	assert.False(t, maintenance.State().Enabled)
+}
+
+func TestPutMaintenanceError(t *testing.T) {
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced maintenance error")
+	mock.updateMaintenanceErr = forcedError
+
+	c, _ := setupMockContextWithBody(http.MethodPut, `{"enabled":true}`)
+	assert.EqualError(t, putMaintenance(c), forcedError.Error())
+}
+
+func TestSetupRoutesMaintenance(t *testing.T) {
+	e := echo.New()
+
+	logger = zaptest.NewLogger(t)
+	defer func() {
+		maintenance = &maintenanceMode{}
+	}()
+	newMockDb(t)
+	_, err := maintenance.Set(context.Background(), true, "migrating the schema", time.Now())
+	assert.NoError(t, err)
+
+	cfg := config.Defaults()
+	cfg.AdminUsername, cfg.AdminPassword = "theAdminUsername", "theAdminPassword"
+	setupRoutes(e, cfg, "myBuildInfoMsg")
+
+	// changes are turned away, before reaching the handler or the database
+	req := httptest.NewRequest(http.MethodPut, pathAdmin+Bug+Add, strings.NewReader(`{}`))
+	req.SetBasicAuth("theAdminUsername", "theAdminPassword")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, maintenanceRetryAfter, rec.Header().Get(echo.HeaderRetryAfter))
+	assert.Equal(t, `{"code":"unavailable","message":"migrating the schema"}`+"\n", rec.Body.String())
+
+	// reads keep working
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// as does a backup, taken before the migration
+	req = httptest.NewRequest(http.MethodPost, pathAdmin+Backup, nil)
+	req.SetBasicAuth("theAdminUsername", "theAdminPassword")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, mimeZip, rec.Header().Get(echo.HeaderContentType))
+
+	req = httptest.NewRequest(http.MethodPut, pathAdmin+Maintenance, strings.NewReader(`{"enabled":false}`))
+	req.SetBasicAuth("theAdminUsername", "theAdminPassword")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.False(t, maintenance.State().Enabled)
+	assert.Equal(t, pathAdmin+Maintenance, insertAuditLast.Route)
+}
+
+func TestMigrateDBPingError(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	cfg := config.Defaults()
+	cfg.PGHost = "bogus-db-hostname"
+	err := migrate(cfg, 0, io.Discard)
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "failed to ping database. host: bogus-db-hostname, port: "), err.Error())
+}
+
+func TestMigrateDBUnknownDriver(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	cfg := config.Defaults()
+	cfg.DBDriver = "bogus"
+	assert.EqualError(t, migrate(cfg, 0, io.Discard), "unknown database driver. DB_DRIVER: bogus")
+}
+
+func TestBeginLogPollingSqlite(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	cfg := config.Defaults()
+	cfg.DBDriver = config.DBDriverSqlite
+	quit, _, err := beginLogPolling(cfg)
+	assert.EqualError(t, err, "datadog poll needs postgres. DB_DRIVER: sqlite")
+	assert.Nil(t, quit)
+}
+
+func TestBeginLogPollingMemory(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	cfg := config.Defaults()
+	cfg.DBDriver = config.DBDriverMemory
+	quit, _, err := beginLogPolling(cfg)
+	assert.EqualError(t, err, "datadog poll needs postgres. DB_DRIVER: memory")
+	assert.Nil(t, quit)
+}
+
+func TestMigrateDBMemory(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	cfg := config.Defaults()
+	cfg.DBDriver = config.DBDriverMemory
+	assert.NoError(t, migrate(cfg, 0, io.Discard))
+}
+
+func TestSeedDB(t *testing.T) {
+	memoryDB := db.NewMemory(zaptest.NewLogger(t))
+
+	var out bytes.Buffer
+	assert.NoError(t, seedDB(context.Background(), memoryDB, &seedRequest{CampaignName: campaign, Organization: "myOrganization", LoginName: loginName}, now, &out))
+	assert.Equal(t, "campaign added: myCampaignName\norganization added: myOrganization\nparticipant added: loginName\n", out.String())
+
+	seeded, err := memoryDB.GetCampaign(context.Background(), campaign)
+	assert.NoError(t, err)
+	assert.True(t, now.Equal(seeded.StartOn))
+	assert.True(t, now.AddDate(0, 0, seedCampaignDays).Equal(seeded.EndOn))
+	organizations, err := memoryDB.GetOrganizations(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(organizations))
+	assert.Equal(t, "myorganization", organizations[0].Organization)
+	assert.Equal(t, campaign, organizations[0].CampaignName)
+	_, err = memoryDB.SelectParticipantDetail(context.Background(), campaign, seedScpName, loginName)
+	assert.NoError(t, err)
+}
+
+func TestSeedDBExisting(t *testing.T) {
+	memoryDB := db.NewMemory(zaptest.NewLogger(t))
+	request := &seedRequest{CampaignName: campaign, Organization: "myOrganization", LoginName: loginName}
+	assert.NoError(t, seedDB(context.Background(), memoryDB, request, now, io.Discard))
+
+	var out bytes.Buffer
+	assert.NoError(t, seedDB(context.Background(), memoryDB, request, now, &out))
+	assert.Equal(t, "campaign exists: myCampaignName\norganization exists: myOrganization\nparticipant exists: loginName\n", out.String())
+}
+
+func TestSeedDBError(t *testing.T) {
+	memoryDB := db.NewMemory(zaptest.NewLogger(t))
+	memoryDB.Record()
+	forcedError := fmt.Errorf("forced organization error")
+	memoryDB.FailWith("InsertOrganization", forcedError)
+
+	var out bytes.Buffer
+	assert.EqualError(t, seedDB(context.Background(), memoryDB, &seedRequest{CampaignName: campaign, Organization: "myOrganization", LoginName: loginName}, now, &out), forcedError.Error())
+	assert.Equal(t, "campaign added: myCampaignName\n", out.String())
+	assert.Equal(t, 0, len(memoryDB.Calls("InsertParticipant")))
+}
+
+func TestSetupRoutes(t *testing.T) {
+	e := echo.New()
+
+	logger = zaptest.NewLogger(t)
+
+	customRouteCount := setupRoutes(e, config.Defaults(), "myBuildInfoMsg")
+	routes := e.Routes()
+	// when using "groups", extra "default" routes are automatically added by echo
+	//assert.Equal(t, 22, len(routes))
+	// Out main() method will only print "custom" routes, ignoring defaults added by echo. such defaults are still
+	// included in the "total" route count below
+	assert.Equal(t, 441, len(routes))
+
+	assert.Equal(t, 132, customRouteCount)
+}
+
+func TestSetupRoutesTeamSelfService(t *testing.T) {
+	e := echo.New()
+
+	logger = zaptest.NewLogger(t)
+
+	cfg := config.Defaults()
+	cfg.TeamSelfService = true
+
+	customRouteCount := setupRoutes(e, cfg, "myBuildInfoMsg")
+	assert.Equal(t, 445, len(e.Routes()))
+	assert.Equal(t, 136, customRouteCount)
+}
+
+func TestSetupRoutesRateLimit(t *testing.T) {
+	e := echo.New()
+
+	logger = zaptest.NewLogger(t)
+
+	cfg := config.Defaults()
+	cfg.RateLimitPerSecond, cfg.RateLimitBurst = 0.5, 1
+
+	customRouteCount := setupRoutes(e, cfg, "myBuildInfoMsg")
+	assert.Equal(t, 441, len(e.Routes()))
+	assert.Equal(t, 132, customRouteCount)
+
+	sendRequest := func(path, remoteAddr, apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		if apiKey != "" {
+			req.Header.Set(headerApiKey, apiKey)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	path := Participant + List + "/" + campaign
+	mock := newMockDb(t)
+	mock.selectPartInCampCamp = campaign
+	mock.selectPartVersionCamp = campaign
+	assert.Equal(t, http.StatusOK, sendRequest(path, "10.0.0.1:1234", "").Code)
+	rec := sendRequest(path, "10.0.0.1:1234", "")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"code":"too_many_requests","message":"rate limit exceeded"}`, rec.Body.String())
+
+	// a new api key does not get around the limit on its IP address
+	assert.Equal(t, http.StatusTooManyRequests, sendRequest(path, "10.0.0.1:1234", "myApiKey").Code)
+
+	// an api key is limited across IP addresses
+	assert.Equal(t, http.StatusOK, sendRequest(path, "10.0.0.2:1234", "myApiKey").Code)
+	assert.Equal(t, http.StatusTooManyRequests, sendRequest(path, "10.0.0.3:1234", "myApiKey").Code)
+
+	// the health check is never limited
+	assert.Equal(t, http.StatusOK, sendRequest("/", "10.0.0.1:1234", "").Code)
+
+	// a client forwarding its requests as if from others is limited on its own IP address
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = "198.51.100.7:1234"
+	req.Header.Set(echo.HeaderXForwardedFor, "10.0.0.4")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	req.Header.Set(echo.HeaderXForwardedFor, "10.0.0.5")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+}
+
+func TestSetupRoutesCors(t *testing.T) {
+	e := echo.New()
+
+	logger = zaptest.NewLogger(t)
+
+	cfg := config.Defaults()
+	cfg.CorsAllowedOrigins = []string{"https://bbash.example"}
+	cfg.CorsAllowedMethods = []string{http.MethodGet}
+	cfg.CorsAllowCredentials = true
+
+	customRouteCount := setupRoutes(e, cfg, "myBuildInfoMsg")
+	assert.Equal(t, 441, len(e.Routes()))
+	assert.Equal(t, 132, customRouteCount)
+
+	req := httptest.NewRequest(http.MethodOptions, Participant+List+"/"+campaign, nil)
+	req.Header.Set(echo.HeaderOrigin, "https://bbash.example")
+	req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodGet)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://bbash.example", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	assert.Equal(t, http.MethodGet, rec.Header().Get(echo.HeaderAccessControlAllowMethods))
+	assert.Equal(t, "true", rec.Header().Get(echo.HeaderAccessControlAllowCredentials))
+
+	req = httptest.NewRequest(http.MethodOptions, Participant+List+"/"+campaign, nil)
+	req.Header.Set(echo.HeaderOrigin, "https://evil.example")
+	req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodGet)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, "", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+}
+
+func TestCorsConfigFromConfig(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	cfg := config.Defaults()
+	cfg.CorsAllowCredentials = true
+	assert.Nil(t, corsConfigFromConfig(cfg))
+
+	cfg.CorsAllowedOrigins = []string{"https://one.example", "https://two.example"}
+	assert.Equal(t, &middleware.CORSConfig{
+		AllowOrigins:     []string{"https://one.example", "https://two.example"},
+		AllowMethods:     middleware.DefaultCORSConfig.AllowMethods,
+		AllowCredentials: true,
+	}, corsConfigFromConfig(cfg))
+
+	cfg.CorsAllowedMethods = []string{"GET", "PUT"}
+	cfg.CorsAllowCredentials = false
+	assert.Equal(t, &middleware.CORSConfig{
+		AllowOrigins: []string{"https://one.example", "https://two.example"},
+		AllowMethods: []string{"GET", "PUT"},
+	}, corsConfigFromConfig(cfg))
+
+	cfg.CorsAllowedOrigins = []string{"*"}
+	cfg.CorsAllowCredentials = true
+	assert.Equal(t, &middleware.CORSConfig{
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{"GET", "PUT"},
+	}, corsConfigFromConfig(cfg))
+}
+
+func TestToApiError(t *testing.T) {
+	var syntaxErr error = &json.SyntaxError{}
+	for _, tc := range []struct {
+		err     error
+		status  int
+		code    string
+		message string
+		details interface{}
+	}{
+		{newApiError(http.StatusNotFound, "no thing"), http.StatusNotFound, errCodeNotFound, "no thing", nil},
+		{newApiError(http.StatusTeapot, "short and stout"), http.StatusTeapot, "i'm_a_teapot", "short and stout", nil},
+		{echo.ErrUnauthorized, http.StatusUnauthorized, errCodeUnauthorized, "Unauthorized", nil},
+		{&db.TeamFullError{CampaignName: campaign, TeamName: teamName, MaxTeamSize: 3}, http.StatusConflict, errCodeConflict,
+			"team is full: campaignName: myCampaignName, teamName: myTeamName, maxTeamSize: 3", map[string]int{"maxTeamSize": 3}},
+		{&db.DuplicateError{Entity: "participant", Constraint: "participant_key"}, http.StatusConflict, errCodeConflict,
+			"participant already exists", map[string]string{"entity": "participant"}},
+		{fmt.Errorf("no team: %w", sql.ErrNoRows), http.StatusNotFound, errCodeNotFound, "not found", nil},
+		{io.EOF, http.StatusBadRequest, errCodeBadRequest, "invalid request body", "EOF"},
+		{syntaxErr, http.StatusBadRequest, errCodeBadRequest, "invalid request body", ""},
+		{fmt.Errorf("forced SQL insert error"), http.StatusInternalServerError, errCodeInternal, "internal server error", nil},
+		{fmt.Errorf("no bugs: %w", db.ErrCircuitOpen), http.StatusServiceUnavailable, errCodeUnavailable, "database unavailable, try again later", nil},
+	} {
+		apiErr := toApiError(tc.err)
+		assert.Equal(t, tc.status, apiErr.Status, tc.err.Error())
+		assert.Equal(t, tc.code, apiErr.Code, tc.err.Error())
+		assert.Equal(t, tc.message, apiErr.Message, tc.err.Error())
+		assert.Equal(t, tc.details, apiErr.Details, tc.err.Error())
+	}
+}
+
+func TestBreakerFromConfig(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	cfg := config.Defaults()
+	breaker := breakerFromConfig(cfg, "primary")
+	assert.NotNil(t, breaker)
+	assert.Equal(t, db.BreakerClosed, breaker.State())
+
+	cfg.PGBreakerFailures = 0
+	assert.Nil(t, breakerFromConfig(cfg, "primary"))
+}
+
+func TestPoolStatsMessageCircuit(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	_, dbFake, closeDbFunc := db.SetupMockDB(t)
+	defer closeDbFunc()
+	postgresDB = dbFake
+	origDbBreaker := dbBreaker
+	defer func() {
+		dbBreaker = origDbBreaker
+	}()
+	dbBreaker = db.NewBreaker(logger, 5, time.Second)
+
+	assert.True(t, strings.HasSuffix(poolStatsMessage(), ", circuit: closed"), poolStatsMessage())
+}
+
+func TestJsonErrorHandler(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	c, rec := setupMockContext()
+	jsonErrorHandler(&db.TeamFullError{CampaignName: campaign, TeamName: teamName, MaxTeamSize: 3}, c)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.JSONEq(t, `{"code":"conflict","message":"team is full: campaignName: myCampaignName, teamName: myTeamName, maxTeamSize: 3","details":{"maxTeamSize":3}}`,
+		rec.Body.String())
+
+	c, rec = setupMockContext()
+	jsonErrorHandler(fmt.Errorf("forced SQL insert error"), c)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.JSONEq(t, `{"code":"internal_error","message":"internal server error"}`, rec.Body.String())
+
+	c, rec = setupMockContext()
+	c.Request().Method = http.MethodHead
+	jsonErrorHandler(newApiError(http.StatusNotFound, "no thing"), c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "", rec.Body.String())
+
+	c, rec = setupMockContext()
+	assert.NoError(t, c.NoContent(http.StatusNoContent))
+	jsonErrorHandler(newApiError(http.StatusNotFound, "no thing"), c)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+// recordingReporter keeps the errors reported, rather than sending them
+type recordingReporter struct {
+	errs   []error
+	extras []map[string]interface{}
+}
+
+func (r *recordingReporter) Report(err error, extra map[string]interface{}) {
+	r.errs = append(r.errs, err)
+	r.extras = append(r.extras, extra)
+}
+
+func TestJsonErrorHandlerReportsInternalError(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	recorder := &recordingReporter{}
+	origErrorReporter := errorReporter
+	defer func() {
+		errorReporter = origErrorReporter
+	}()
+	errorReporter = recorder
+
+	c, _ := setupMockContext()
+	jsonErrorHandler(newApiError(http.StatusNotFound, "no thing"), c)
+	assert.Equal(t, 0, len(recorder.errs))
+
+	c, _ = setupMockContext()
+	c.SetPath("/admin/campaign/add")
+	forcedError := fmt.Errorf("forced SQL insert error")
+	jsonErrorHandler(forcedError, c)
+	assert.Equal(t, []error{forcedError}, recorder.errs)
+	assert.Equal(t, []map[string]interface{}{{"method": http.MethodGet, "path": "/admin/campaign/add"}}, recorder.extras)
+}
+
+func TestErrorReporterFromConfig(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	cfg := config.Defaults()
+	assert.Equal(t, errreport.NoReporter{}, errorReporterFromConfig(cfg))
+
+	cfg.SentryDsn = "https://publicKey@o1.ingest.sentry.example/42"
+	assert.IsType(t, &errreport.SentryReporter{}, errorReporterFromConfig(cfg))
+
+	cfg.SentryDsn = "not a dsn"
+	assert.Equal(t, errreport.NoReporter{}, errorReporterFromConfig(cfg))
+}
+
+func TestSetupRoutesErrorResponse(t *testing.T) {
+	e := echo.New()
+
+	logger = zaptest.NewLogger(t)
+
+	setupRoutes(e, config.Defaults(), "myBuildInfoMsg")
+
+	req := httptest.NewRequest(http.MethodGet, pathAdmin+Campaign+List, nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.JSONEq(t, `{"code":"unauthorized","message":"Unauthorized"}`, rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/no/such/route", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.JSONEq(t, `{"code":"not_found","message":"Not Found"}`, rec.Body.String())
+}
+
+func TestSetupRoutesConfig(t *testing.T) {
+	e := echo.New()
+
+	logger = zaptest.NewLogger(t)
+
+	cfg := config.Defaults()
+	cfg.AdminUsername, cfg.AdminPassword = "theAdminUsername", "theAdminPassword"
+	cfg.PGHost = "localhost"
+	setupRoutes(e, cfg, "myBuildInfoMsg")
+
+	req := httptest.NewRequest(http.MethodGet, pathAdmin+Config, nil)
+	req.SetBasicAuth("theAdminUsername", "theAdminPassword")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var shown config.Config
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &shown))
+	assert.Equal(t, "theAdminUsername", shown.AdminUsername)
+	assert.Equal(t, "REDACTED", shown.AdminPassword)
+	assert.Equal(t, "localhost", shown.PGHost)
+	assert.Equal(t, "", shown.PGPassword)
+	assert.NotContains(t, rec.Body.String(), "theAdminPassword")
+}
+
+func TestSetupRoutesMetrics(t *testing.T) {
+	e := echo.New()
+
+	logger = zaptest.NewLogger(t)
+	origRouteMetrics := routeMetrics
+	defer func() {
+		routeMetrics = origRouteMetrics
+	}()
+	routeMetrics = metrics.New([]float64{10})
+
+	cfg := config.Defaults()
+	cfg.AdminUsername, cfg.AdminPassword = "theAdminUsername", "theAdminPassword"
+	setupRoutes(e, cfg, "myBuildInfoMsg")
+
+	// no credentials
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, pathAdmin+Config, nil))
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/bogus/myCampaign", nil))
+
+	req := httptest.NewRequest(http.MethodGet, pathAdmin+Metrics, nil)
+	req.SetBasicAuth("theAdminUsername", "theAdminPassword")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, metrics.ContentType, rec.Header().Get(echo.HeaderContentType))
+	assert.Contains(t, rec.Body.String(), `bbash_http_request_duration_seconds_count{method="GET",route="/admin/config"} 1`)
+	assert.Contains(t, rec.Body.String(), `bbash_http_responses_total{method="GET",route="/admin/config",status="4xx"} 1`)
+	// the url is not a label, so a scan of made up urls adds no labels, as they match the static files route
+	assert.Contains(t, rec.Body.String(), `bbash_http_responses_total{method="DELETE",route="/*",status="4xx"} 1`)
+	assert.NotContains(t, rec.Body.String(), "myCampaign")
+}
+
+func TestStartServerCertificateMissing(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	cfg := config.Defaults()
+	cfg.TlsCertFile = filepath.Join(t.TempDir(), "missing.pem")
+	// a certificate takes precedence over autocert
+	cfg.TlsAutocertHosts = []string{"bugbash.example"}
+
+	assert.EqualError(t, startServer(echo.New(), cfg, fmt.Sprintf(":%d", defaultServicePort)), fmt.Sprintf("open %s: no such file or directory", cfg.TlsCertFile))
+}
+
+func TestConfigureAutocert(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.TlsAutocertHosts = []string{"bugbash.example"}
+
+	manager := &autocert.Manager{}
+	configureAutocert(manager, cfg)
+	assert.NoError(t, manager.HostPolicy(context.Background(), "bugbash.example"))
+	assert.Error(t, manager.HostPolicy(context.Background(), "other.example"))
+	assert.Equal(t, autocert.DirCache(defaultAutocertCacheDir), manager.Cache)
+	assert.Equal(t, "", manager.Email)
+
+	cfg.TlsAutocertCacheDir = "/var/cache/bbash"
+	cfg.TlsAutocertEmail = "admin@bugbash.example"
+	configureAutocert(manager, cfg)
+	assert.Equal(t, autocert.DirCache("/var/cache/bbash"), manager.Cache)
+	assert.Equal(t, "admin@bugbash.example", manager.Email)
+}
+
+func TestIpExtractorFromConfig(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	clientIp := func(extract echo.IPExtractor, remoteAddr, forwardedFor string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+		return extract(req)
+	}
+
+	extract := ipExtractorFromConfig(config.Defaults())
+	// a client can not pick its IP
+	assert.Equal(t, "198.51.100.7", clientIp(extract, "198.51.100.7:1234", "10.9.9.9"))
+	// the load balancer on a private address is trusted
+	assert.Equal(t, "198.51.100.7", clientIp(extract, "10.0.0.5:1234", "10.9.9.9, 198.51.100.7"))
+	// a proxy on a public address is not, unless it is in TRUSTED_PROXIES
+	assert.Equal(t, "203.0.113.5", clientIp(extract, "203.0.113.5:1234", "198.51.100.7"))
+
+	cfg := config.Defaults()
+	cfg.TrustedProxies = []string{"bogus", " 203.0.113.0/24"}
+	extract = ipExtractorFromConfig(cfg)
+	assert.Equal(t, "198.51.100.7", clientIp(extract, "203.0.113.5:1234", "198.51.100.7"))
+	assert.Equal(t, "198.51.100.7", clientIp(extract, "198.51.100.7:1234", "10.9.9.9"))
+}
+
+func TestRateLimitFromConfig(t *testing.T) {
+	cfg := config.Defaults()
+	perSecond, burst := rateLimitFromConfig(cfg)
+	assert.Equal(t, float64(0), perSecond)
+	assert.Equal(t, 0, burst)
+
+	cfg.RateLimitPerSecond = -1
+	perSecond, burst = rateLimitFromConfig(cfg)
+	assert.Equal(t, float64(0), perSecond)
+	assert.Equal(t, 0, burst)
+
+	cfg.RateLimitPerSecond = 2.5
+	perSecond, burst = rateLimitFromConfig(cfg)
+	assert.Equal(t, 2.5, perSecond)
+	assert.Equal(t, 3, burst)
+
+	cfg.RateLimitPerSecond = 0.2
+	perSecond, burst = rateLimitFromConfig(cfg)
+	assert.Equal(t, 0.2, perSecond)
+	assert.Equal(t, 1, burst)
+
+	cfg.RateLimitBurst = 20
+	perSecond, burst = rateLimitFromConfig(cfg)
+	assert.Equal(t, 0.2, perSecond)
+	assert.Equal(t, 20, burst)
+}
+
+func TestOpenDBPoolSettings(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.PGMaxOpenConns = 7
+	cfg.PGMaxIdleConns = 2
+	cfg.PGConnMaxLifetime = config.Duration(5 * time.Minute)
+	pg, _, _, _, _, err := openDB(cfg)
+	assert.NoError(t, err)
+	defer func() {
+		_ = pg.Close()
+	}()
+	assert.Equal(t, 7, pg.Stats().MaxOpenConnections)
+}
+
+func TestApplyPoolSettingsUnset(t *testing.T) {
+	pg, _, _, _, _, err := openDB(config.Defaults())
+	assert.NoError(t, err)
+	defer func() {
+		_ = pg.Close()
+	}()
+	assert.Equal(t, 0, pg.Stats().MaxOpenConnections)
+}
+
+func TestOpenReadReplicaDBNotConfigured(t *testing.T) {
+	replica, err := openReadReplicaDB(config.Defaults())
+	assert.NoError(t, err)
+	assert.Nil(t, replica)
+}
+
+func TestOpenReadReplicaDB(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.PGReadReplicaDSN = "host=replica port=5432 dbname=db sslmode=disable"
+	cfg.PGMaxOpenConns = 4
+	replica, err := openReadReplicaDB(cfg)
+	assert.NoError(t, err)
+	defer func() {
+		_ = replica.Close()
+	}()
+	assert.Equal(t, 4, replica.Stats().MaxOpenConnections)
+}
+
+func TestStatementTimeoutFromConfig(t *testing.T) {
+	cfg := config.Defaults()
+	_, ok := statementTimeoutFromConfig(cfg)
+	assert.False(t, ok)
+
+	cfg.PGStatementTimeout = 0
+	timeout, ok := statementTimeoutFromConfig(cfg)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), timeout)
+
+	cfg.PGStatementTimeout = config.Duration(10 * time.Second)
+	timeout, ok = statementTimeoutFromConfig(cfg)
+	assert.True(t, ok)
+	assert.Equal(t, 10*time.Second, timeout)
+}
+
+func TestPoolStatsMessageNoPool(t *testing.T) {
+	postgresDB = newMockDb(t)
+	assert.Equal(t, "", poolStatsMessage())
+}
+
+func TestSetupRoutesHealthPoolStats(t *testing.T) {
+	e := echo.New()
+	logger = zaptest.NewLogger(t)
+	_, dbFake, closeDbFunc := db.SetupMockDB(t)
+	defer closeDbFunc()
+	postgresDB = dbFake
+	dbFake.GetDb().SetMaxOpenConns(9)
+	setupRoutes(e, config.Defaults(), buildLocation)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "I am ALIVE. "))
+	assert.Contains(t, rec.Body.String(), "DB pool: maxOpen: 9, open: ")
+}
+
+const timeLayout = "2006-01-02T15:04:05.000Z"
+
+var testStartOn time.Time
+var testEndOn time.Time
+
+func init() {
+	var err error
+	testStartOn, err = time.Parse(timeLayout, "2021-11-01T12:00:00.000Z")
+	if err != nil {
+		panic(err)
+	}
+	testEndOn, err = time.Parse(timeLayout, "2021-11-02T12:00:00.000Z")
+	if err != nil {
+		panic(err)
+	}
+}
+func setupMockContextCampaign(campaignName string) (c echo.Context, rec *httptest.ResponseRecorder, expectedCampaign *types.CampaignStruct) {
+	c, rec = setupMockContextCampaignWithBody(campaignName, fmt.Sprintf("{ \"startOn\": \"%s\", \"endOn\": \"%s\", \"version\": 1}",
+		testStartOn.Format(timeLayout), testEndOn.Format(timeLayout)))
+	expectedCampaign = &types.CampaignStruct{
+		Name:    campaignName,
+		StartOn: testStartOn,
+		EndOn:   testEndOn,
+		Version: 1,
+	}
+	return
+}
+func setupMockContextCampaignWithBody(campaignName, bodyCampaign string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest("", "/", strings.NewReader(bodyCampaign))
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaignName)
+	return
+}
+
+func TestAddCampaignEmptyName(t *testing.T) {
+	campaignName := " "
+	c, _, testCampaign := setupMockContextCampaign(campaignName)
+
+	mock := newMockDb(t)
+	mock.insertCampaignParam = testCampaign
+
+	expectedError := fmt.Errorf("invalid parameter %s: %s", ParamCampaignName, "")
+
+	assertApiError(t, addCampaign(c), http.StatusBadRequest, expectedError.Error())
+}
+
+func TestGetCampaignsError(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced campaign error")
+	mock.getCampaignsErr = forcedError
+
+	assert.EqualError(t, getCampaigns(c), forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetCampaignsVersionError(t *testing.T) {
+	c, _ := setupMockContext()
+
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced version error")
+	mock.selectCampaignsVersionErr = forcedError
+
+	assert.EqualError(t, getCampaigns(c), forcedError.Error())
+}
+
+func TestGetCampaignsNotModified(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	mock.selectCampaignsVersion = types.ListVersion{Count: 2, UpdatedOn: time.Unix(0, 5)}
+	c.Request().Header.Set(headerIfNoneMatch, `W/"2-5"`)
+
+	assert.NoError(t, getCampaigns(c))
+	assert.Equal(t, http.StatusNotModified, c.Response().Status)
+	assert.Equal(t, `W/"2-5"`, rec.Header().Get(headerETag))
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetCampaigns(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	mock.getCampaignsResult = []types.CampaignStruct{{
+		ID:           campaignId,
+		Name:         campaign,
+		CreatedOn:    time.Time{},
+		CreatedOrder: 1,
+		StartOn:      now,
+		EndOn:        now,
+	}}
+	assert.NoError(t, getCampaigns(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	expectedCampaigns := []types.CampaignStruct{
+		{ID: campaignId, Name: campaign, CreatedOn: time.Time{}, CreatedOrder: 1, StartOn: now, EndOn: now},
+	}
+	jsonExpectedCampaign, err := json.Marshal(expectedCampaigns)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpectedCampaign)+"\n", rec.Body.String())
+}
+
+func TestGetPublicCampaignsError(t *testing.T) {
+	c, _ := setupMockContext()
+
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced campaign error")
+	mock.selectPublicCampaignsErr = forcedError
+
+	assert.EqualError(t, getPublicCampaigns(c), forcedError.Error())
+}
+
+func TestGetPublicCampaignsNotModified(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	mock.selectCampaignsVersion = types.ListVersion{Count: 2, UpdatedOn: time.Unix(0, 5)}
+	c.Request().Header.Set(headerIfNoneMatch, `W/"2-5"`)
+
+	assert.NoError(t, getPublicCampaigns(c))
+	assert.Equal(t, http.StatusNotModified, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetPublicCampaigns(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	note := sql.NullString{String: "admins only", Valid: true}
+	maxTeamSize := 5
+	mock.selectPublicCampaignsResult = []types.CampaignStruct{
+		{ID: campaignId, Name: campaign, StartOn: now, EndOn: now, TimeZone: "UTC", Status: types.CampaignStatusActive, Note: note, MaxTeamSize: &maxTeamSize},
+	}
+
+	assert.NoError(t, getPublicCampaigns(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var campaigns []types.PublicCampaign
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &campaigns))
+	assert.Equal(t, []types.PublicCampaign{
+		{Name: campaign, StartOn: now.UTC(), EndOn: now.UTC(), TimeZone: "UTC", Status: types.CampaignStatusActive},
+	}, campaigns)
+	assert.False(t, strings.Contains(rec.Body.String(), "admins only"), rec.Body.String())
+}
+
+func TestGetPublicCampaignsNone(t *testing.T) {
+	c, rec := setupMockContext()
+
+	newMockDb(t)
+
+	assert.NoError(t, getPublicCampaigns(c))
+	assert.Equal(t, "[]\n", rec.Body.String())
+}
+
+func TestPublicCacheControl(t *testing.T) {
+	c, rec := setupMockContext()
+
+	assert.NoError(t, publicCacheControl(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})(c))
+	assert.Equal(t, fmt.Sprintf("public, max-age=%d", int(leaderboardRefreshInterval.Seconds())), rec.Header().Get(headerCacheControl))
+}
+
+func TestPublicCacheControlError(t *testing.T) {
+	c, rec := setupMockContext()
+
+	forcedError := fmt.Errorf("forced handler error")
+	assert.EqualError(t, publicCacheControl(func(c echo.Context) error {
+		return forcedError
+	})(c), forcedError.Error())
+	assert.Equal(t, "", rec.Header().Get(headerCacheControl))
+}
+
+func TestGetCampaignNotFound(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignErr = sql.ErrNoRows
+
+	assertApiError(t, getCampaign(c), http.StatusNotFound, "no campaign: campaignName: "+campaign)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetCampaignError(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	forcedError := fmt.Errorf("forced campaign error")
+	mock.getCampaignErr = forcedError
+
+	assert.EqualError(t, getCampaign(c), forcedError.Error())
+}
+
+func TestGetCampaign(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{ID: campaignId, Name: campaign, StartOn: now, EndOn: now}
+
+	assert.NoError(t, getCampaign(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	jsonExpectedCampaign, err := json.Marshal(mock.getCampaignResult)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpectedCampaign)+"\n", rec.Body.String())
+}
+
+func TestGetCampaignETag(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{ID: campaignId, Name: campaign, StartOn: now, EndOn: now, Version: 3}
+
+	assert.NoError(t, getCampaign(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `"3"`, rec.Header().Get(headerETag))
+}
+
+func setupMockContextCampaignInclude(include string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	c, rec = setupMockContextCampaignRange("include=" + include)
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
+	return
+}
+
+func TestGetCampaignIncludeInvalid(t *testing.T) {
+	c, _ := setupMockContextCampaignInclude("participants,teams")
+
+	newMockDb(t)
+
+	assertApiError(t, getCampaign(c), http.StatusBadRequest, "invalid include: teams, expected participants or bugs")
+}
+
+func TestGetCampaignIncludeError(t *testing.T) {
+	c, _ := setupMockContextCampaignInclude("bugs")
+
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{ID: campaignId, Name: campaign, StartOn: now, EndOn: now}
+	mock.selectBugsInCampaignCampaign = campaign
+	forcedError := fmt.Errorf("forced bugs error")
+	mock.selectBugsInCampaignErr = forcedError
+
+	assert.EqualError(t, getCampaign(c), forcedError.Error())
+}
+
+func TestGetCampaignInclude(t *testing.T) {
+	c, rec := setupMockContextCampaignInclude("participants,bugs,")
+
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{ID: campaignId, Name: campaign, StartOn: now, EndOn: now}
+	mock.selectPartInCampCamp = campaign
+	mock.selectPartInCampResult = []types.ParticipantStruct{{ID: participantID, CampaignName: campaign, ScpName: scpName, LoginName: loginName}}
+	mock.selectBugsInCampaignCampaign = campaign
+	mock.selectBugsInCampaignResult = []types.BugStruct{{Id: "bugId", Campaign: campaign, Category: "sql-injection", PointValue: 5}}
+
+	assert.NoError(t, getCampaign(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var detail struct {
+		types.CampaignStruct
+		Participants []types.ParticipantStruct `json:"participants"`
+		Bugs         []types.BugStruct         `json:"bugs"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &detail))
+	assert.Equal(t, campaign, detail.Name)
+	assert.Equal(t, mock.selectPartInCampResult, detail.Participants)
+	assert.Equal(t, mock.selectBugsInCampaignResult, detail.Bugs)
+}
+
+func TestGetCampaignIncludeEmpty(t *testing.T) {
+	c, rec := setupMockContextCampaignInclude("participants")
+
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{ID: campaignId, Name: campaign, StartOn: now, EndOn: now}
+	mock.selectPartInCampCamp = campaign
+
+	assert.NoError(t, getCampaign(c))
+	assert.Contains(t, rec.Body.String(), `"participants":[]`)
+	assert.NotContains(t, rec.Body.String(), `"bugs"`)
+}
+
+func TestGetActiveCampaignsError(t *testing.T) {
+	c, _, testCampaign := setupMockContextCampaign(campaign)
+
+	mock := newMockDb(t)
+	mock.getActiveCampaignsResult = []types.CampaignStruct{*testCampaign}
+
+	forcedError := fmt.Errorf("forced campaign error")
+	mock.getActiveCampaignsErr = forcedError
+	// caller users Time.now(), so don't assert time parameter
+	mock.getActiveCampaignsParamSkip = true
+	assert.EqualError(t, getActiveCampaigns(c), forcedError.Error())
+}
+
+func TestGetActiveCampaigns(t *testing.T) {
+	c, rec, _ := setupMockContextCampaign(campaign)
+
+	mock := newMockDb(t)
+	mock.getActiveCampaignsResult = []types.CampaignStruct{
+		{ID: campaignId, Name: campaign, StartOn: now, EndOn: now},
+	}
+	// caller users Time.now(), so don't assert time parameter
+	mock.getActiveCampaignsParamSkip = true
+
+	assert.NoError(t, getActiveCampaigns(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+
+	jsonExpectedCampaign, err := json.Marshal(mock.getActiveCampaignsResult)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpectedCampaign)+"\n", rec.Body.String())
+}
+
+func TestGetUpcomingCampaignsError(t *testing.T) {
+	c, _ := setupMockContext()
+
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced campaign error")
+	mock.getUpcomingCampaignsErr = forcedError
+
+	assert.EqualError(t, getUpcomingCampaigns(c), forcedError.Error())
+}
+
+func TestGetUpcomingCampaigns(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	mock.getUpcomingCampaignsResult = []types.CampaignStruct{
+		{ID: campaignId, Name: campaign, StartOn: now.Add(time.Hour), EndOn: now.Add(2 * time.Hour), Status: types.CampaignStatusUpcoming},
+	}
+
+	assert.NoError(t, getUpcomingCampaigns(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+
+	jsonExpectedCampaign, err := json.Marshal(mock.getUpcomingCampaignsResult)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpectedCampaign)+"\n", rec.Body.String())
+}
+
+func setupMockContextCampaignRange(query string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	return
+}
+
+func TestGetCampaignsInvalidSince(t *testing.T) {
+	c, _ := setupMockContextCampaignRange("since=yesterday")
+
+	newMockDb(t)
+
+	assertApiError(t, getCampaigns(c), http.StatusBadRequest, "invalid since: yesterday")
+}
+
+func TestGetCampaignsInvalidUntil(t *testing.T) {
+	c, _ := setupMockContextCampaignRange("until=2022-13-01T00:00:00Z")
+
+	newMockDb(t)
+
+	assertApiError(t, getCampaigns(c), http.StatusBadRequest, "invalid until: 2022-13-01T00:00:00Z")
+}
+
+func TestGetCampaignsUntilBeforeSince(t *testing.T) {
+	c, _ := setupMockContextCampaignRange("since=2022-02-01T00:00:00Z&until=2022-01-01T00:00:00Z")
+
+	newMockDb(t)
+
+	assertApiError(t, getCampaigns(c), http.StatusBadRequest, "until is before since")
+}
+
+func TestGetCampaignsInRange(t *testing.T) {
+	c, rec := setupMockContextCampaignRange(url2.Values{
+		"since": {testStartOn.Add(time.Hour).Format(time.RFC3339)},
+		"until": {testEndOn.Add(time.Hour).Format(time.RFC3339)},
+	}.Encode())
+
+	mock := newMockDb(t)
+	running := types.CampaignStruct{Name: "running", StartOn: testStartOn, EndOn: testEndOn}
+	mock.getCampaignsResult = []types.CampaignStruct{
+		{Name: "endedBefore", StartOn: testStartOn.Add(-2 * time.Hour), EndOn: testStartOn.Add(-time.Hour)},
+		running,
+		{Name: "startsAfter", StartOn: testEndOn.Add(2 * time.Hour), EndOn: testEndOn.Add(3 * time.Hour)},
+	}
+
+	assert.NoError(t, getCampaigns(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var campaigns []types.CampaignStruct
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &campaigns))
+	assert.Equal(t, 1, len(campaigns))
+	assert.Equal(t, running.Name, campaigns[0].Name)
+}
+
+func TestGetCampaignsInRangeNone(t *testing.T) {
+	c, rec := setupMockContextCampaignRange("since=2099-01-01T00:00:00Z")
+
+	mock := newMockDb(t)
+	mock.getCampaignsResult = []types.CampaignStruct{{Name: campaign, StartOn: testStartOn, EndOn: testEndOn}}
+
+	assert.NoError(t, getCampaigns(c))
+	assert.Equal(t, "[]\n", rec.Body.String())
+}
+
+func TestAddCampaignEndBeforeStart(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, fmt.Sprintf(`{"startOn": "%s", "endOn": "%s", "maxTeamSize": -1}`,
+		testEndOn.Format(timeLayout), testStartOn.Format(timeLayout)))
+
+	newMockDb(t)
+
+	err := addCampaign(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{
+		{Field: "endOn", Message: "must be after startOn"},
+		{Field: "maxTeamSize", Message: "must be at least 0"},
+	}, toApiError(err).Details)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestAddCampaignInvalidTieBreaker(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, fmt.Sprintf(`{"startOn": "%s", "endOn": "%s", "tieBreaker": "coinToss"}`,
+		testStartOn.Format(timeLayout), testEndOn.Format(timeLayout)))
+
+	newMockDb(t)
+
+	err := addCampaign(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{
+		{Field: "tieBreaker", Message: "must be one of: reachedScore bugCategories joinedAt"},
+	}, toApiError(err).Details)
+}
+
+func TestAddCampaignInvalidTimeZone(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, fmt.Sprintf(`{"startOn": "%s", "endOn": "%s", "timeZone": "Mars/Olympus_Mons"}`,
+		testStartOn.Format(timeLayout), testEndOn.Format(timeLayout)))
+
+	newMockDb(t)
+
+	err := addCampaign(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{
+		{Field: "timeZone", Message: "must be an IANA time zone, like America/New_York"},
+	}, toApiError(err).Details)
+}
+
+func TestAddCampaignTimeZone(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, fmt.Sprintf(`{"startOn": "%s", "endOn": "%s", "timeZone": "Asia/Kolkata"}`,
+		testStartOn.Format(timeLayout), testEndOn.Format(timeLayout)))
+
+	mock := newMockDb(t)
+	mock.insertCampaignParam = &types.CampaignStruct{Name: campaign, StartOn: testStartOn, EndOn: testEndOn, TimeZone: "Asia/Kolkata"}
+	mock.insertCampaignGuid = campaignId
+
+	assert.NoError(t, addCampaign(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	assertCreation(t, rec, campaignId)
+}
+
+func TestAddCampaignRegistrationWindow(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, fmt.Sprintf(`{"startOn": "%s", "endOn": "%s", "registrationOpensOn": "%s", "registrationClosesOn": "%s"}`,
+		testStartOn.Format(timeLayout), testEndOn.Format(timeLayout), testStartOn.Add(-time.Hour*24).Format(timeLayout), testStartOn.Format(timeLayout)))
+
+	opensOn := testStartOn.Add(-time.Hour * 24)
+	closesOn := testStartOn
+	mock := newMockDb(t)
+	mock.insertCampaignParam = &types.CampaignStruct{Name: campaign, StartOn: testStartOn, EndOn: testEndOn, RegistrationOpensOn: &opensOn, RegistrationClosesOn: &closesOn}
+	mock.insertCampaignGuid = campaignId
+
+	assert.NoError(t, addCampaign(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	assertCreation(t, rec, campaignId)
+}
+
+func TestAddCampaignRegistrationClosesBeforeOpens(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, fmt.Sprintf(`{"startOn": "%s", "endOn": "%s", "registrationOpensOn": "%s", "registrationClosesOn": "%s"}`,
+		testStartOn.Format(timeLayout), testEndOn.Format(timeLayout), testEndOn.Format(timeLayout), testStartOn.Format(timeLayout)))
+
+	newMockDb(t)
+
+	err := addCampaign(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{
+		{Field: "registrationClosesOn", Message: "must be after registrationOpensOn"},
+	}, toApiError(err).Details)
+}
+
+func TestAddCampaignRegistrationOnlyCloses(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, fmt.Sprintf(`{"startOn": "%s", "endOn": "%s", "registrationClosesOn": "%s"}`,
+		testStartOn.Format(timeLayout), testEndOn.Format(timeLayout), testStartOn.Format(timeLayout)))
+
+	closesOn := testStartOn
+	mock := newMockDb(t)
+	mock.insertCampaignParam = &types.CampaignStruct{Name: campaign, StartOn: testStartOn, EndOn: testEndOn, RegistrationClosesOn: &closesOn}
+	mock.insertCampaignGuid = campaignId
+
+	assert.NoError(t, addCampaign(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+}
+
+func TestAddCampaignErrorReadingCampaignFromRequestBody(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+
+	assert.EqualError(t, addCampaign(c), "EOF")
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestAddCampaignError(t *testing.T) {
+	c, rec, testCampaign := setupMockContextCampaign(campaign)
+
+	mock := newMockDb(t)
+	mock.insertCampaignParam = testCampaign
+	forcedError := fmt.Errorf("forced campaign error")
+	mock.insertCampaignErr = forcedError
+
+	assert.EqualError(t, addCampaign(c), forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestAddCampaign(t *testing.T) {
+	c, rec, testCampaign := setupMockContextCampaign(campaign)
+
+	mock := newMockDb(t)
+	mock.insertCampaignParam = testCampaign
+	mock.insertCampaignGuid = campaignId
+	c.Echo().GET("/campaign/detail/:campaignName", getCampaign).Name = "campaign-detail"
+	c.Echo().PUT("/campaign/update/:campaignName", updateCampaign).Name = "campaign-update"
+
+	assert.NoError(t, addCampaign(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	creation := assertCreation(t, rec, campaignId)
+	assert.Equal(t, map[string]endpointDetail{
+		"campaignDetail": {URI: "/campaign/detail/" + campaign, Verb: http.MethodGet},
+		"campaignUpdate": {URI: "/campaign/update/" + campaign, Verb: http.MethodPut},
+	}, creation.Endpoints)
+	assert.Equal(t, campaign, creation.Object.(map[string]interface{})["name"])
+}
+
+func TestAddCampaignApiVersion1(t *testing.T) {
+	c, rec, testCampaign := setupMockContextCampaign(campaign)
+	c.Request().Header.Set(headerApiVersion, apiVersion1)
+
+	mock := newMockDb(t)
+	mock.insertCampaignParam = testCampaign
+	mock.insertCampaignGuid = campaignId
+
+	assert.NoError(t, addCampaign(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	assert.Equal(t, campaignId, rec.Body.String())
+}
+
+func TestUpdateCampaignMissingParamCampaign(t *testing.T) {
+	c, _, _ := setupMockContextCampaign("")
+
+	assertApiError(t, updateCampaign(c), http.StatusBadRequest, "invalid parameter campaignName: ")
+}
+
+func TestUpdateCampaignErrorReadingCampaignFromRequestBody(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+
+	assert.EqualError(t, updateCampaign(c), "EOF")
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestUpdateCampaignError(t *testing.T) {
+	c, rec, testCampaign := setupMockContextCampaign(campaign)
+
+	mock := newMockDb(t)
+	mock.updateCampaignParam = testCampaign
+	forcedError := fmt.Errorf("forced scan error update campaign")
+	mock.updateCampaignErr = forcedError
+
+	assert.EqualError(t, updateCampaign(c), forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestUpdateCampaign(t *testing.T) {
+	c, rec, testCampaign := setupMockContextCampaign(campaign)
+
+	mock := newMockDb(t)
+	mock.updateCampaignParam = testCampaign
+	mock.updateCampaignGuid = campaignId
+
+	assert.NoError(t, updateCampaign(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, campaignId, rec.Body.String())
+}
+
+func TestUpdateCampaignNegativeUnclassifiedBonus(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, fmt.Sprintf(`{"startOn": "%s", "endOn": "%s", "unclassifiedBonus": -1}`,
+		testStartOn.Format(timeLayout), testEndOn.Format(timeLayout)))
+
+	newMockDb(t)
+
+	err := updateCampaign(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{
+		{Field: "unclassifiedBonus", Message: "must be at least 0"},
+	}, toApiError(err).Details)
+}
+
+func TestUpdateCampaignDisableUnclassifiedBonus(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, fmt.Sprintf(`{"startOn": "%s", "endOn": "%s", "unclassifiedBonus": 0, "version": 2}`,
+		testStartOn.Format(timeLayout), testEndOn.Format(timeLayout)))
+
+	mock := newMockDb(t)
+	bonus := float64(0)
+	mock.updateCampaignParam = &types.CampaignStruct{Name: campaign, StartOn: testStartOn, EndOn: testEndOn, UnclassifiedBonus: &bonus, Version: 2}
+	mock.updateCampaignGuid = campaignId
+
+	assert.NoError(t, updateCampaign(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, campaignId, rec.Body.String())
+}
+
+func TestUpdateCampaignMissingVersion(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, fmt.Sprintf(`{"startOn": "%s", "endOn": "%s"}`,
+		testStartOn.Format(timeLayout), testEndOn.Format(timeLayout)))
+
+	newMockDb(t)
+
+	assertApiError(t, updateCampaign(c), http.StatusPreconditionRequired, "missing If-Match header or version")
+}
+
+func TestUpdateCampaignIfMatchInvalid(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, fmt.Sprintf(`{"startOn": "%s", "endOn": "%s"}`,
+		testStartOn.Format(timeLayout), testEndOn.Format(timeLayout)))
+	c.Request().Header.Set(headerIfMatch, `W/"2"`)
+
+	newMockDb(t)
+
+	assertApiError(t, updateCampaign(c), http.StatusBadRequest, `invalid If-Match: W/"2"`)
+}
+
+func TestUpdateCampaignIfMatchDiffers(t *testing.T) {
+	c, _, _ := setupMockContextCampaign(campaign)
+	c.Request().Header.Set(headerIfMatch, `"2"`)
+
+	newMockDb(t)
+
+	assertApiError(t, updateCampaign(c), http.StatusBadRequest, `If-Match "2" and version 1 differ`)
+}
+
+func TestUpdateCampaignStale(t *testing.T) {
+	c, _, testCampaign := setupMockContextCampaign(campaign)
+
+	mock := newMockDb(t)
+	mock.updateCampaignParam = testCampaign
+	mock.updateCampaignErr = &db.StaleError{Entity: "campaign", Version: 2}
+
+	err := updateCampaign(c)
+	assertApiError(t, err, http.StatusConflict, "campaign was changed by another update, current version: 2")
+	assert.Equal(t, map[string]int{"version": 2}, toApiError(err).Details)
+}
+
+func TestUpdateCampaignIfMatch(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, fmt.Sprintf(`{"startOn": "%s", "endOn": "%s"}`,
+		testStartOn.Format(timeLayout), testEndOn.Format(timeLayout)))
+	c.Request().Header.Set(headerIfMatch, `"2"`)
+
+	mock := newMockDb(t)
+	mock.updateCampaignParam = &types.CampaignStruct{Name: campaign, StartOn: testStartOn, EndOn: testEndOn, Version: 2}
+	mock.updateCampaignGuid = campaignId
+
+	assert.NoError(t, updateCampaign(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, campaignId, rec.Body.String())
+	assert.Equal(t, `"2"`, rec.Header().Get(headerETag))
+}
+
+func setupMockContextParticipant(participantJson string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest("", "/", strings.NewReader(participantJson))
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	return
+}
+
+func TestAddParticipantBodyInvalid(t *testing.T) {
+	c, rec := setupMockContextParticipant("")
+
+	assert.EqualError(t, addParticipant(c), "EOF")
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestAddParticipantCampaignMissing(t *testing.T) {
+	participantJson := fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s", "loginName": "%s"}`, campaign, scpName, loginName)
+	c, rec := setupMockContextParticipant(participantJson)
+
+	mock := newMockDb(t)
+	mock.insertParticipantPartier = &types.ParticipantStruct{
+		CampaignName: campaign,
+		ScpName:      scpName,
+		LoginName:    loginName,
+	}
+	forcedError := fmt.Errorf("forced SQL insert error")
+	mock.insertParticipantErr = forcedError
+
+	assert.EqualError(t, addParticipant(c), forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestAddParticipantInvalid(t *testing.T) {
+	c, _ := setupMockContextParticipant(fmt.Sprintf(`{"campaignName":"%s", "email": "notAnEmail"}`, campaign))
+
+	newMockDb(t)
+
+	err := addParticipant(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{
+		{Field: "scpName", Message: "is required"},
+		{Field: "loginName", Message: "is required"},
+		{Field: "email", Message: "must be an email address"},
+	}, toApiError(err).Details)
+}
+
+func TestValidateRequestNotValidatable(t *testing.T) {
+	assert.Error(t, validateRequest(nil))
+	assert.NoError(t, validateRequest(&types.BugStruct{Campaign: campaign, Category: category}))
+	assert.NoError(t, validateRequest([]types.BugStruct{}))
+}
+
+func TestAddParticipant(t *testing.T) {
+	participantJson := fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s"}`, campaign, scpName, loginName)
+	c, rec := setupMockContextParticipant(participantJson)
+
+	mock := newMockDb(t)
+	mock.insertParticipantPartier = &types.ParticipantStruct{
+		CampaignName: campaign,
+		ScpName:      scpName,
+		LoginName:    loginName,
+	}
+	mock.insertParticipantGuid = participantID
+	mock.insertParticipantJoinedAt = now
+
+	assert.NoError(t, addParticipant(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), `{"guid":"`+participantID+`","endpoints":{"participantDetail"`), rec.Body.String())
+	assert.True(t, strings.Contains(rec.Body.String(), `"loginName":"`+loginName+`"`), rec.Body.String())
+}
+
+func TestAddParticipantBanned(t *testing.T) {
+	participantJson := fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s"}`, campaign, scpName, loginName)
+	c, rec := setupMockContextParticipant(participantJson)
+
+	mock := newMockDb(t)
+	mock.selectBannedScpName = scpName
+	mock.selectBannedLoginName = loginName
+	mock.selectBannedResult = true
+
+	assertApiError(t, addParticipant(c), http.StatusForbidden, "banned: scpName: myScpName, loginName: loginName")
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestAddParticipantBanReadError(t *testing.T) {
+	participantJson := fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s"}`, campaign, scpName, loginName)
+	c, _ := setupMockContextParticipant(participantJson)
+
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced ban read error")
+	mock.selectBannedErr = forcedError
+
+	assert.EqualError(t, addParticipant(c), forcedError.Error())
+}
+
+func TestAddParticipantRegistrationNotOpen(t *testing.T) {
+	participantJson := fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s"}`, campaign, scpName, loginName)
+	c, rec := setupMockContextParticipant(participantJson)
+
+	opensOn := time.Date(2099, 1, 2, 14, 0, 0, 0, time.UTC)
+	mock := newMockDb(t)
+	mock.selectRegistrationOpensOn = &opensOn
+
+	err := addParticipant(c)
+	assertApiError(t, err, http.StatusForbidden, "registration for campaign myCampaignName opens at 2099-01-02T14:00:00Z")
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestAddParticipantRegistrationClosed(t *testing.T) {
+	participantJson := fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s"}`, campaign, scpName, loginName)
+	c, rec := setupMockContextParticipant(participantJson)
+
+	closesOn := time.Date(2021, 1, 2, 14, 0, 0, 0, time.UTC)
+	mock := newMockDb(t)
+	mock.selectRegistrationClosesOn = &closesOn
+
+	err := addParticipant(c)
+	assertApiError(t, err, http.StatusForbidden, "registration for campaign myCampaignName closed at 2021-01-02T14:00:00Z")
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestAddParticipantRegistrationNoCampaign(t *testing.T) {
+	participantJson := fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s"}`, campaign, scpName, loginName)
+	c, _ := setupMockContextParticipant(participantJson)
+
+	mock := newMockDb(t)
+	mock.selectRegistrationErr = sql.ErrNoRows
+
+	assertApiError(t, addParticipant(c), http.StatusNotFound, "no campaign: campaignName: myCampaignName")
+}
+
+func TestCheckRegistrationOpen(t *testing.T) {
+	opensOn := time.Date(2021, 1, 2, 14, 0, 0, 0, time.UTC)
+	closesOn := opensOn.Add(time.Hour * 24)
+	mock := newMockDb(t)
+	mock.selectRegistrationOpensOn = &opensOn
+	mock.selectRegistrationClosesOn = &closesOn
+
+	assert.NoError(t, checkRegistrationOpen(context.Background(), campaign, opensOn))
+	assert.NoError(t, checkRegistrationOpen(context.Background(), campaign, closesOn.Add(-time.Second)))
+	assert.Error(t, checkRegistrationOpen(context.Background(), campaign, opensOn.Add(-time.Second)))
+	assert.Error(t, checkRegistrationOpen(context.Background(), campaign, closesOn))
+}
+
+func TestCheckRegistrationOpenError(t *testing.T) {
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced registration window error")
+	mock.selectRegistrationErr = forcedError
+
+	assert.EqualError(t, checkRegistrationOpen(context.Background(), campaign, now), forcedError.Error())
+}
+
+func TestLogAddParticipantWithError(t *testing.T) {
+	c, rec := setupMockContext()
+	err := logAddParticipant(c)
+	assert.EqualError(t, err, "EOF")
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestLogAddParticipantNoError(t *testing.T) {
+	participantJson := fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s"}`, campaign, scpName, loginName)
+	c, rec := setupMockContextParticipant(participantJson)
+
+	mock := newMockDb(t)
+	mock.insertParticipantPartier = &types.ParticipantStruct{
+		CampaignName: campaign,
+		ScpName:      scpName,
+		LoginName:    loginName,
+	}
+	mock.insertParticipantGuid = participantID
+	mock.insertParticipantJoinedAt = now
+
+	err := logAddParticipant(c)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), `{"guid":"`+participantID+`","endpoints":{"participantDetail"`), rec.Body.String())
+	assert.True(t, strings.Contains(rec.Body.String(), `"loginName":"`+loginName+`"`), rec.Body.String())
+}
+
+func TestUpsertParticipantInvalid(t *testing.T) {
+	c, _ := setupMockContextParticipant(fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s"}`, campaign, scpName))
+
+	newMockDb(t)
+
+	assertApiError(t, upsertParticipant(c), http.StatusUnprocessableEntity, "request is not valid")
+}
+
+func TestUpsertParticipantError(t *testing.T) {
+	c, rec := setupMockContextParticipant(fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s"}`, campaign, scpName, loginName))
+
+	mock := newMockDb(t)
+	mock.upsertParticipantPartier = &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+	forcedError := fmt.Errorf("forced upsert error")
+	mock.upsertParticipantErr = forcedError
+
+	assert.EqualError(t, upsertParticipant(c), forcedError.Error())
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestUpsertParticipantBanned(t *testing.T) {
+	c, rec := setupMockContextParticipant(fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s"}`, campaign, scpName, loginName))
+
+	mock := newMockDb(t)
+	mock.selectBannedScpName = scpName
+	mock.selectBannedLoginName = loginName
+	mock.selectBannedResult = true
+
+	assertApiError(t, upsertParticipant(c), http.StatusForbidden, "banned: scpName: myScpName, loginName: loginName")
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestUpsertParticipantRegistrationClosed(t *testing.T) {
+	c, rec := setupMockContextParticipant(fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s"}`, campaign, scpName, loginName))
+
+	closesOn := time.Date(2021, 1, 2, 14, 0, 0, 0, time.UTC)
+	mock := newMockDb(t)
+	mock.selectRegistrationClosesOn = &closesOn
+	mock.selectPartDetailCampName = campaign
+	mock.selectPartDetailSCPName = scpName
+	mock.selectPartDetailLoginName = loginName
+	mock.selectPartDetailErr = sql.ErrNoRows
+
+	assertApiError(t, upsertParticipant(c), http.StatusForbidden, "registration for campaign myCampaignName closed at 2021-01-02T14:00:00Z")
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestUpsertParticipantRegistrationClosedLookupError(t *testing.T) {
+	c, rec := setupMockContextParticipant(fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s"}`, campaign, scpName, loginName))
+
+	closesOn := time.Date(2021, 1, 2, 14, 0, 0, 0, time.UTC)
+	mock := newMockDb(t)
+	mock.selectRegistrationClosesOn = &closesOn
+	mock.selectPartDetailCampName = campaign
+	mock.selectPartDetailSCPName = scpName
+	mock.selectPartDetailLoginName = loginName
+	forcedError := fmt.Errorf("forced participant error")
+	mock.selectPartDetailErr = forcedError
+
+	assert.EqualError(t, upsertParticipant(c), forcedError.Error())
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestUpsertParticipantRegistrationClosedUpdated(t *testing.T) {
+	c, rec := setupMockContextParticipant(fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s"}`, campaign, scpName, loginName))
+
+	closesOn := time.Date(2021, 1, 2, 14, 0, 0, 0, time.UTC)
+	mock := newMockDb(t)
+	mock.selectRegistrationClosesOn = &closesOn
+	mock.selectPartDetailCampName = campaign
+	mock.selectPartDetailSCPName = scpName
+	mock.selectPartDetailLoginName = loginName
+	mock.selectPartDetailResult = &types.ParticipantStruct{ID: participantID, CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+	mock.upsertParticipantPartier = &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+	mock.upsertParticipantResult = types.ParticipantStruct{ID: participantID, CampaignName: campaign, ScpName: scpName, LoginName: loginName, JoinedAt: now}
+
+	assert.NoError(t, upsertParticipant(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.True(t, strings.HasSuffix(rec.Body.String(), `"action":"updated"}`+"\n"), rec.Body.String())
+}
+
+func TestUpsertParticipantInserted(t *testing.T) {
+	c, rec := setupMockContextParticipant(fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s"}`, campaign, scpName, loginName))
+
+	mock := newMockDb(t)
+	mock.upsertParticipantPartier = &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+	mock.upsertParticipantResult = types.ParticipantStruct{ID: participantID, CampaignName: campaign, ScpName: scpName, LoginName: loginName, JoinedAt: now}
+	mock.upsertParticipantInsert = true
+
+	assert.NoError(t, upsertParticipant(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), `{"guid":"`+participantID+`","endpoints":{"participantDetail"`), rec.Body.String())
+	assert.True(t, strings.HasSuffix(rec.Body.String(), `"action":"inserted"}`+"\n"), rec.Body.String())
+}
+
+func TestUpsertParticipantUpdated(t *testing.T) {
+	c, rec := setupMockContextParticipant(fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s","email":"%s"}`,
+		campaign, scpName, loginName, participantEmail))
+
+	mock := newMockDb(t)
+	mock.upsertParticipantPartier = &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName, Email: participantEmail}
+	mock.upsertParticipantResult = types.ParticipantStruct{ID: participantID, CampaignName: campaign, ScpName: scpName, LoginName: loginName,
+		Email: participantEmail, Score: 7, TeamName: teamName, JoinedAt: now}
+	// an update is not a registration, so no email is sent
+	sender := setupMockMailer(t)
+
+	assert.NoError(t, upsertParticipant(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.True(t, strings.Contains(rec.Body.String(), `"score":7,"teamName":"`+teamName+`"`), rec.Body.String())
+	assert.True(t, strings.HasSuffix(rec.Body.String(), `"action":"updated"}`+"\n"), rec.Body.String())
+	assert.Equal(t, 0, len(sender.sent))
+}
+
+func setupMockContextUpdateParticipant(participantJson string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest("", "/", strings.NewReader(participantJson))
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	return
+}
+
+func TestUpdateParticipantBodyInvalid(t *testing.T) {
+	c, rec := setupMockContextUpdateParticipant("")
+
+	assert.EqualError(t, updateParticipant(c), "EOF")
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+// unit test values
+const campaignId = "myCampaignId"
+const campaign = "myCampaignName"
+const scpName = "myScpName"
+const participantID = "participantUUId"
+const loginName = "loginName"
+const teamName = "myTeamName"
+
+func TestUpdateParticipantMissingParticipantID(t *testing.T) {
+	participantJson := fmt.Sprintf(`{"version": 1, "loginName": "%s","campaignName": "%s", "scpName": "%s"}`, loginName, campaign, scpName)
+	c, rec := setupMockContextUpdateParticipant(participantJson)
+
+	mock := newMockDb(t)
+	mock.updateParticipantPartier = &types.ParticipantStruct{
+		CampaignName: campaign,
+		ScpName:      scpName,
+		LoginName:    loginName,
+		Version:      1,
+	}
+	forcedError := fmt.Errorf("forced SQL insert error")
+	mock.updateParticipantErr = forcedError
+
+	assert.EqualError(t, updateParticipant(c), forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestUpdateParticipantUpdateError(t *testing.T) {
+	participantJson := fmt.Sprintf(`{"version": 1, "guid": "%s","campaignName": "%s", "scpName": "%s", "loginName": "%s"}`, participantID, campaign, scpName, loginName)
+	c, rec := setupMockContextUpdateParticipant(participantJson)
+
+	mock := newMockDb(t)
+	mock.updateParticipantPartier = &types.ParticipantStruct{
+		ID:           participantID,
+		CampaignName: campaign,
+		ScpName:      scpName,
+		LoginName:    loginName,
+		Version:      1,
+	}
+	forcedError := fmt.Errorf("forced SQL insert error")
+	mock.updateParticipantErr = forcedError
+
+	assert.EqualError(t, updateParticipant(c), forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestUpdateParticipantNoRowsUpdated(t *testing.T) {
+	participantJson := fmt.Sprintf(`{"version": 1, "guid": "%s", "campaignName": "%s", "scpName": "%s", "loginName": "%s", "teamName": "%s"}`, participantID, campaign, scpName, loginName, teamName)
+	c, _ := setupMockContextUpdateParticipant(participantJson)
+
+	mock := newMockDb(t)
+	mock.updateParticipantPartier = &types.ParticipantStruct{
+		ID:           participantID,
+		CampaignName: campaign,
+		ScpName:      scpName,
+		LoginName:    loginName,
+		Version:      1,
+		TeamName:     teamName,
+	}
+
+	mock.updateScoreParticipant = &types.ParticipantStruct{ID: participantID}
+
+	logger = zaptest.NewLogger(t)
+
+	assertApiError(t, updateParticipant(c), http.StatusBadRequest, "no participant was updated: campaignName: myCampaignName, scpName: myScpName, loginName: loginName")
+}
+
+func TestUpdateParticipant(t *testing.T) {
+	participantJson := fmt.Sprintf(`{"version": 1, "guid": "%s", "campaignName": "%s", "scpName": "%s", "loginName": "%s"}`, participantID, campaign, scpName, loginName)
+	c, rec := setupMockContextUpdateParticipant(participantJson)
+
+	mock := newMockDb(t)
+	mock.updateParticipantPartier = &types.ParticipantStruct{
+		ID:           participantID,
+		CampaignName: campaign,
+		ScpName:      scpName,
+		LoginName:    loginName,
+		Version:      1,
+	}
+	mock.updateParticipantRowsAffected = 1
+
+	mock.updateScoreParticipant = &types.ParticipantStruct{
+		ID: participantID,
+	}
+
+	assert.NoError(t, updateParticipant(c))
+	assert.Equal(t, http.StatusNoContent, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestPatchParticipantNull(t *testing.T) {
+	c, _ := setupMockContextUpdateParticipant(`{"guid": "` + participantID + `", "loginName": null}`)
+
+	assertApiError(t, patchParticipant(c), http.StatusBadRequest, "invalid null: loginName")
+}
+
+func TestPatchParticipantEmailInvalid(t *testing.T) {
+	c, _ := setupMockContextUpdateParticipant(`{"guid": "` + participantID + `", "email": "notAnEmail"}`)
+
+	err := patchParticipant(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{{Field: "email", Message: "must be an email address"}}, toApiError(err).Details)
+}
+
+func TestPatchParticipantMissing(t *testing.T) {
+	c, _ := setupMockContextUpdateParticipant(`{"guid": "` + participantID + `", "score": 3, "version": 1}`)
+
+	mock := newMockDb(t)
+	mock.patchParticipantPatch = &types.ParticipantPatch{ID: participantID, Score: types.OptionalFloat{Set: true, Value: 3}, Version: 1}
+
+	assertApiError(t, patchParticipant(c), http.StatusNotFound, "no participant: guid: "+participantID)
+}
+
+func TestPatchParticipant(t *testing.T) {
+	c, rec := setupMockContextUpdateParticipant(`{"guid": "` + participantID + `", "displayName": "Someone", "email": null, "teamName": null}`)
+	c.Request().Header.Set(headerIfMatch, `"4"`)
+
+	mock := newMockDb(t)
+	mock.patchParticipantPatch = &types.ParticipantPatch{
+		ID:          participantID,
+		Version:     4,
+		DisplayName: types.OptionalString{Set: true, Value: "Someone"},
+		Email:       types.OptionalString{Set: true, Null: true},
+		TeamName:    types.OptionalString{Set: true, Null: true},
+	}
+	mock.patchParticipantRowsAffected = 1
+
+	assert.NoError(t, patchParticipant(c))
+	assert.Equal(t, http.StatusNoContent, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func setupMockContextTeam(teamJson string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest("", "/", strings.NewReader(teamJson))
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	return
+}
+
+func TestAddTeamMissingTeam(t *testing.T) {
+	c, rec := setupMockContextTeam("")
+
+	assert.EqualError(t, addTeam(c), "EOF")
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestAddTeamInsertError(t *testing.T) {
+	teamJson := `{"campaignName": "` + campaign + `","name":"` + teamName + `"}`
+	c, rec := setupMockContextTeam(teamJson)
+
+	mock := newMockDb(t)
+	mock.insertTeamTm = &types.TeamStruct{
+		Name:         teamName,
+		CampaignName: campaign,
+	}
+	forcedError := fmt.Errorf("forced SQL insert error")
+	mock.insertTeamErr = forcedError
+
+	assert.EqualError(t, addTeam(c), forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestAddTeamDuplicate(t *testing.T) {
+	teamJson := `{"campaignName": "` + campaign + `","name":"` + teamName + `"}`
+	c, _ := setupMockContextTeam(teamJson)
+
+	mock := newMockDb(t)
+	mock.insertTeamTm = &types.TeamStruct{
+		Name:         teamName,
+		CampaignName: campaign,
+	}
+	mock.insertTeamErr = &db.DuplicateError{Entity: "team", Constraint: "team_fk_campaign_name_key"}
+
+	assertApiError(t, addTeam(c), http.StatusConflict, "team already exists")
+}
+
+func TestAddTeam(t *testing.T) {
+	teamJson := `{"campaignName": "` + campaign + `","name":"` + teamName + `"}`
+	c, rec := setupMockContextTeam(teamJson)
+
+	mock := newMockDb(t)
+	mock.insertTeamTm = &types.TeamStruct{
+		Name:         teamName,
+		CampaignName: campaign,
+	}
+
+	teamID := "teamUUId"
+	mock.insertTeamGuid = teamID
+
+	c.Echo().GET("/team/detail/:teamName", getTeamDetail).Name = "team-detail"
+	c.Echo().GET("/team/list/:campaignName", getTeams).Name = "team-list"
+
+	assert.NoError(t, addTeam(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	creation := assertCreation(t, rec, teamID)
+	assert.Equal(t, map[string]endpointDetail{
+		"teamDetail": {URI: "/team/detail/" + teamName + "?campaignName=" + campaign, Verb: http.MethodGet},
+		"teamList":   {URI: "/team/list/" + campaign, Verb: http.MethodGet},
+	}, creation.Endpoints)
+}
+
+func TestAddTeamApiVersion1(t *testing.T) {
+	c, rec := setupMockContextTeam(`{"campaignName": "` + campaign + `","name":"` + teamName + `"}`)
+	c.Request().Header.Set(headerApiVersion, apiVersion1)
+
+	mock := newMockDb(t)
+	mock.insertTeamTm = &types.TeamStruct{Name: teamName, CampaignName: campaign}
+	mock.insertTeamGuid = "teamUUId"
+
+	assert.NoError(t, addTeam(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	assert.Equal(t, "teamUUId", rec.Body.String())
+}
+
+func setupMockContextAddPersonToTeam(campaignName, scpName, loginName, teamName string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest("", "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamCampaignName, ParamScpName, ParamLoginName, ParamTeamName)
+	c.SetParamValues(campaignName, scpName, loginName, teamName)
+	return
+}
+
+func TestAddPersonToTeamMissingParameters(t *testing.T) {
+	c, _ := setupMockContextAddPersonToTeam("", "", "", "")
+
+	assertApiError(t, addPersonToTeam(c), http.StatusBadRequest, "missing team member: campaignName: , teamName: , scpName: , loginName: ")
+}
+
+func TestAddPersonToTeamUpdateError(t *testing.T) {
+	c, rec := setupMockContextAddPersonToTeam(campaign, scpName, loginName, teamName)
+
+	mock := newMockDb(t)
+	mock.updatePartTeamTeamName = teamName
+	mock.updatePartTeamCampaignName = campaign
+	mock.updatePartTeamSCPName = scpName
+	mock.updatePartTeamLoginName = loginName
+	forcedError := fmt.Errorf("forced SQL update error")
+	mock.updatePartTeamErr = forcedError
+
+	assert.EqualError(t, addPersonToTeam(c), forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestAddPersonToTeamFull(t *testing.T) {
+	c, _ := setupMockContextAddPersonToTeam(campaign, scpName, loginName, teamName)
+
+	mock := newMockDb(t)
+	mock.updatePartTeamTeamName = teamName
+	mock.updatePartTeamCampaignName = campaign
+	mock.updatePartTeamSCPName = scpName
+	mock.updatePartTeamLoginName = loginName
+	mock.updatePartTeamErr = &db.TeamFullError{CampaignName: campaign, TeamName: teamName, MaxTeamSize: 3}
+
+	assertApiError(t, addPersonToTeam(c), http.StatusConflict, "team is full: campaignName: myCampaignName, teamName: myTeamName, maxTeamSize: 3")
+}
+
+func TestAddPersonToTeamCaptainMove(t *testing.T) {
+	c, _ := setupMockContextAddPersonToTeam(campaign, scpName, loginName, teamName)
+
+	mock := newMockDb(t)
+	mock.updatePartTeamTeamName = teamName
+	mock.updatePartTeamCampaignName = campaign
+	mock.updatePartTeamSCPName = scpName
+	mock.updatePartTeamLoginName = loginName
+	mock.updatePartTeamErr = &db.CaptainMoveError{CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+
+	assertApiError(t, addPersonToTeam(c), http.StatusConflict, "the captain can not leave their team: campaignName: myCampaignName, scpName: myScpName, loginName: loginName")
+}
+
+func TestAddPeopleToTeamsEmpty(t *testing.T) {
+	c, _ := setupMockContextTeam("[]")
+
+	newMockDb(t)
+
+	assertApiError(t, addPeopleToTeams(c), http.StatusBadRequest, "no team assignments")
+}
+
+func TestAddPeopleToTeamsInvalid(t *testing.T) {
+	c, _ := setupMockContextTeam(fmt.Sprintf(`[{"campaign":"%s","scp":"%s","login":"%s"}]`, campaign, scpName, loginName))
+
+	newMockDb(t)
+
+	err := addPeopleToTeams(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{{Field: "[0].team", Message: "is required"}}, toApiError(err).Details)
+}
+
+func TestAddPeopleToTeamsError(t *testing.T) {
+	c, _ := setupMockContextTeam(fmt.Sprintf(`[{"campaign":"%s","scp":"%s","login":"%s","team":"%s"}]`, campaign, scpName, loginName, teamName))
+
+	mock := newMockDb(t)
+	mock.updatePartTeamsAssignments = []types.TeamAssignment{{CampaignName: campaign, ScpName: scpName, LoginName: loginName, TeamName: teamName}}
+	forcedError := fmt.Errorf("forced team assignment error")
+	mock.updatePartTeamsErr = forcedError
+
+	assert.EqualError(t, addPeopleToTeams(c), forcedError.Error())
+}
+
+func TestAddPeopleToTeamsNotApplied(t *testing.T) {
+	c, rec := setupMockContextTeam(fmt.Sprintf(`[{"campaign":"%s","scp":"%s","login":"%s","team":"%s"},{"campaign":"%s","scp":"%s","login":"other","team":"%s"}]`,
+		campaign, scpName, loginName, teamName, campaign, scpName, teamName))
+
+	mock := newMockDb(t)
+	assigned := types.TeamAssignment{CampaignName: campaign, ScpName: scpName, LoginName: loginName, TeamName: teamName}
+	full := types.TeamAssignment{CampaignName: campaign, ScpName: scpName, LoginName: "other", TeamName: teamName}
+	mock.updatePartTeamsAssignments = []types.TeamAssignment{assigned, full}
+	mock.updatePartTeamsResults = []types.TeamAssignmentResult{
+		{TeamAssignment: assigned},
+		{TeamAssignment: full, Error: "team is full"},
+	}
+
+	err := addPeopleToTeams(c)
+	assertApiError(t, err, http.StatusConflict, "team assignments not applied")
+	assert.Equal(t, mock.updatePartTeamsResults, toApiError(err).Details)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestAddPeopleToTeams(t *testing.T) {
+	c, rec := setupMockContextTeam(fmt.Sprintf(`[{"campaign":"%s","scp":"%s","login":"%s","team":"%s"}]`, campaign, scpName, loginName, teamName))
+
+	mock := newMockDb(t)
+	assignment := types.TeamAssignment{CampaignName: campaign, ScpName: scpName, LoginName: loginName, TeamName: teamName}
+	mock.updatePartTeamsAssignments = []types.TeamAssignment{assignment}
+	mock.updatePartTeamsResults = []types.TeamAssignmentResult{{TeamAssignment: assignment, Assigned: true}}
+
+	assert.NoError(t, addPeopleToTeams(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, fmt.Sprintf(`[{"campaign":"%s","scp":"%s","login":"%s","team":"%s","assigned":true}]`+"\n", campaign, scpName, loginName, teamName),
+		rec.Body.String())
+}
+
+func TestAddPersonToTeamNoTeam(t *testing.T) {
+	c, _ := setupMockContextAddPersonToTeam(campaign, scpName, loginName, teamName)
+
+	mock := newMockDb(t)
+	mock.updatePartTeamTeamName = teamName
+	mock.updatePartTeamCampaignName = campaign
+	mock.updatePartTeamSCPName = scpName
+	mock.updatePartTeamLoginName = loginName
+	mock.updatePartTeamErr = sql.ErrNoRows
+
+	assertApiError(t, addPersonToTeam(c), http.StatusNotFound, "no team: campaignName: myCampaignName, name: myTeamName")
+}
+
+func TestAddPersonToTeamZeroRowsAffected(t *testing.T) {
+	c, _ := setupMockContextAddPersonToTeam(campaign, scpName, loginName, teamName)
+
+	mock := newMockDb(t)
+	mock.updatePartTeamCampaignName = campaign
+	mock.updatePartTeamSCPName = scpName
+	mock.updatePartTeamLoginName = loginName
+	mock.updatePartTeamTeamName = teamName
+	mock.updatePartTeamRowsAffected = 0
+
+	assertApiError(t, addPersonToTeam(c), http.StatusBadRequest,
+		"no participant was added to the team: campaignName: myCampaignName, teamName: myTeamName, scpName: myScpName, loginName: loginName")
+}
+
+func TestAddPersonToTeamSomeRowsAffected(t *testing.T) {
+	c, rec := setupMockContextAddPersonToTeam(campaign, scpName, loginName, teamName)
+
+	mock := newMockDb(t)
+	mock.updatePartTeamCampaignName = campaign
+	mock.updatePartTeamSCPName = scpName
+	mock.updatePartTeamLoginName = loginName
+	mock.updatePartTeamTeamName = teamName
+	mock.updatePartTeamRowsAffected = 5
+
+	assert.NoError(t, addPersonToTeam(c))
+	assert.Equal(t, http.StatusNoContent, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func setupMockContextTeamParams(campaignName, teamName string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamCampaignName, ParamTeamName)
+	c.SetParamValues(campaignName, teamName)
+	return
+}
+
+func TestGetTeamsError(t *testing.T) {
+	c, rec := setupMockContextTeamParams(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectTeamsInCampCamp = campaign
+	forcedError := fmt.Errorf("forced select teams error")
+	mock.selectTeamsInCampErr = forcedError
+
+	assert.EqualError(t, getTeams(c), forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetTeams(t *testing.T) {
+	c, rec := setupMockContextTeamParams(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectTeamsInCampCamp = campaign
+	mock.selectTeamsInCampResult = []types.TeamStruct{{Id: "teamUUId", CampaignName: campaign, Name: teamName}}
+
+	assert.NoError(t, getTeams(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	jsonExpectedTeams, err := json.Marshal(mock.selectTeamsInCampResult)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpectedTeams)+"\n", rec.Body.String())
+}
+
+func TestGetTeamDetailNotFound(t *testing.T) {
+	c, _ := setupMockContextTeamParams("", teamName)
+
+	mock := newMockDb(t)
+	mock.selectTeamDetailTeamName = teamName
+	mock.selectTeamDetailErr = sql.ErrNoRows
+
+	assertApiError(t, getTeamDetail(c), http.StatusNotFound, "no team: campaignName: , name: myTeamName")
+}
+
+func TestGetTeamDetailError(t *testing.T) {
+	c, rec := setupMockContextTeamParams("", teamName)
+
+	mock := newMockDb(t)
+	mock.selectTeamDetailTeamName = teamName
+	forcedError := fmt.Errorf("forced team detail error")
+	mock.selectTeamDetailErr = forcedError
+
+	assert.EqualError(t, getTeamDetail(c), forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetTeamDetail(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/?"+ParamCampaignName+"="+campaign, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames(ParamTeamName)
+	c.SetParamValues(teamName)
+
+	mock := newMockDb(t)
+	mock.selectTeamDetailCampName = campaign
+	mock.selectTeamDetailTeamName = teamName
+	mock.selectTeamDetailResult = &types.TeamStruct{
+		Id:           "teamUUId",
+		CampaignName: campaign,
+		Name:         teamName,
+		Members:      []types.ParticipantStruct{{ID: participantID, LoginName: loginName, TeamName: teamName}},
+	}
+
+	assert.NoError(t, getTeamDetail(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	jsonExpectedTeam, err := json.Marshal(mock.selectTeamDetailResult)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpectedTeam)+"\n", rec.Body.String())
+}
+
+func TestDeleteTeamError(t *testing.T) {
+	c, rec := setupMockContextTeamParams(campaign, teamName)
+
+	mock := newMockDb(t)
+	mock.deleteTeamCampName = campaign
+	mock.deleteTeamTeamName = teamName
+	forcedError := fmt.Errorf("forced team delete error")
+	mock.deleteTeamErr = forcedError
+
+	assert.EqualError(t, deleteTeam(c), forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestDeleteTeamNotFound(t *testing.T) {
+	c, _ := setupMockContextTeamParams(campaign, teamName)
+
+	mock := newMockDb(t)
+	mock.deleteTeamCampName = campaign
+	mock.deleteTeamTeamName = teamName
+
+	assertApiError(t, deleteTeam(c), http.StatusNotFound, "no team: campaignName: myCampaignName, name: myTeamName")
+}
+
+func TestDeleteTeam(t *testing.T) {
+	c, rec := setupMockContextTeamParams(campaign, teamName)
+
+	mock := newMockDb(t)
+	mock.deleteTeamCampName = campaign
+	mock.deleteTeamTeamName = teamName
+	mock.deleteTeamRowsAffected = 1
+
+	assert.NoError(t, deleteTeam(c))
+	assert.Equal(t, http.StatusNoContent, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func setupMockContextParticipantDetail(campaignName, scpName, loginName string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest("", "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamCampaignName, ParamScpName, ParamLoginName)
+	c.SetParamValues(campaignName, scpName, loginName)
+	return
+}
+
+func TestGetParticipantDetailScanError(t *testing.T) {
+	c, rec := setupMockContextParticipantDetail("", "", "")
+
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced Scan error")
+	mock.selectPartDetailErr = forcedError
+
+	assert.EqualError(t, getParticipantDetail(c), forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetParticipantDetailNotFound(t *testing.T) {
+	c, rec := setupMockContextParticipantDetail(campaign, scpName, loginName)
+
+	mock := newMockDb(t)
+	mock.selectPartDetailCampName = campaign
+	mock.selectPartDetailSCPName = scpName
+	mock.selectPartDetailLoginName = loginName
+	mock.selectPartDetailErr = sql.ErrNoRows
+
+	assertApiError(t, getParticipantDetail(c), http.StatusNotFound,
+		fmt.Sprintf("no participant: campaignName: %s, scpName: %s, loginName: %s", campaign, scpName, loginName))
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetParticipantDetail(t *testing.T) {
+	c, rec := setupMockContextParticipantDetail(campaign, scpName, loginName)
+
+	mock := newMockDb(t)
+	mock.selectPartDetailCampName = campaign
+	mock.selectPartDetailSCPName = scpName
+	mock.selectPartDetailLoginName = loginName
+	mock.selectPartDetailResult = &types.ParticipantStruct{
+		ID:           participantID,
+		CampaignName: campaign,
+		ScpName:      scpName,
+		LoginName:    loginName,
+		JoinedAt:     now,
+	}
+
+	assert.NoError(t, getParticipantDetail(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), `{"guid":"`+participantID+`","campaignName":"`+campaign+`","scpName":"`+scpName+`","loginName":"`+loginName+`"`), rec.Body.String())
+}
+
+func setupMockContextParticipantList(campaignName string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest("", "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaignName)
+	return
+}
+
+func TestGetParticipantsListError(t *testing.T) {
+	campaignName := ""
+	c, rec := setupMockContextParticipantList(campaignName)
+
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced Scan error")
+	mock.selectPartInCampErr = forcedError
+
+	assert.EqualError(t, getParticipantsList(c), forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetParticipantsListNotModified(t *testing.T) {
+	c, rec := setupMockContextParticipantList(campaign)
+
+	mock := newMockDb(t)
+	mock.selectPartVersionCamp = campaign
+	mock.selectPartVersion = types.ListVersion{Count: 1, UpdatedOn: time.Unix(0, 9)}
+	c.Request().Header.Set(headerIfNoneMatch, `W/"1-9"`)
+
+	assert.NoError(t, getParticipantsList(c))
+	assert.Equal(t, http.StatusNotModified, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetParticipantsListNoCampaignVersion(t *testing.T) {
+	c, rec := setupMockContextParticipantList(campaign)
+
+	mock := newMockDb(t)
+	mock.selectPartInCampCamp = campaign
+	mock.selectPartVersionCamp = campaign
+	mock.selectPartVersionErr = sql.ErrNoRows
+	c.Request().Header.Set(headerIfNoneMatch, "*")
+
+	assert.NoError(t, getParticipantsList(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, "", rec.Header().Get(headerETag))
+	assert.Equal(t, "null\n", rec.Body.String())
+}
+
+func TestListNotModified(t *testing.T) {
+	version := types.ListVersion{Count: 1, UpdatedOn: time.Unix(0, 9)}
+	for ifNoneMatch, expected := range map[string]bool{
+		"":                  false,
+		`W/"1-8"`:           false,
+		`W/"1-9"`:           true,
+		`"1-9"`:             true,
+		`"abc", W/"1-9"`:    true,
+		"*":                 true,
+		`W/"2-9", W/"1-10"`: false,
+	} {
+		c, rec := setupMockContext()
+		c.Request().Header.Set(headerIfNoneMatch, ifNoneMatch)
+		assert.Equal(t, expected, listNotModified(c, version), ifNoneMatch)
+		assert.Equal(t, `W/"1-9"`, rec.Header().Get(headerETag))
+	}
+}
+
+func TestGetParticipantsList(t *testing.T) {
+	c, rec := setupMockContextParticipantList(campaign)
+
+	mock := newMockDb(t)
+	mock.selectPartInCampCamp = campaign
+	mock.selectPartVersionCamp = campaign
+	mock.selectPartInCampResult = []types.ParticipantStruct{
+		{
+			ID:           participantID,
+			CampaignName: campaign,
+			JoinedAt:     now,
+		},
+	}
+
+	assert.NoError(t, getParticipantsList(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), `[{"guid":"`+participantID+`","campaignName":"`+campaign+`","scpName":"","loginName":""`), rec.Body.String())
+}
+
+func TestGetParticipantsListAnonymized(t *testing.T) {
+	c, rec := setupMockContextParticipantList(campaign)
+
+	mock := newMockDb(t)
+	setupMockAnonymized(mock)
+	mock.selectPartInCampCamp = campaign
+	mock.selectPartVersionCamp = campaign
+	mock.selectPartInCampResult = []types.ParticipantStruct{
+		{ID: participantID, CampaignName: campaign, ScpName: scpName, LoginName: loginName, Email: "me@example.com", DisplayName: "Me", AvatarUrl: "https://avatars/me", Score: 3},
+	}
+
+	assert.NoError(t, getParticipantsList(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var participants []types.ParticipantStruct
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &participants))
+	assert.Equal(t, []types.ParticipantStruct{
+		{ID: participantID, CampaignName: campaign, ScpName: scpName, LoginName: pseudonym(mock.selectPrivacyResult, scpName, loginName), Score: 3},
+	}, participants)
+}
+
+func TestGetParticipantsListPrivacyError(t *testing.T) {
+	c, _ := setupMockContextParticipantList(campaign)
+
+	mock := newMockDb(t)
+	mock.selectPartInCampCamp = campaign
+	mock.selectPartVersionCamp = campaign
+	forcedError := fmt.Errorf("forced privacy error")
+	mock.selectPrivacyErr = forcedError
+
+	assert.EqualError(t, getParticipantsList(c), forcedError.Error())
+}
+
+func TestGetParticipantsListFields(t *testing.T) {
+	c, rec := setupMockContextParticipantList(campaign)
+	c.Request().URL.RawQuery = qpFields + "=loginName,score, teamName,"
+
+	mock := newMockDb(t)
+	mock.selectPartInCampCamp = campaign
+	mock.selectPartVersionCamp = campaign
+	mock.selectPartInCampResult = []types.ParticipantStruct{
+		{ID: participantID, CampaignName: campaign, LoginName: loginName, Score: 3, TeamName: teamName, JoinedAt: now},
+		{ID: "otherParticipantID", CampaignName: campaign, LoginName: "otherLoginName", JoinedAt: now},
+	}
+
+	assert.NoError(t, getParticipantsList(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `[{"loginName":"`+loginName+`","score":3,"teamName":"`+teamName+`"},{"loginName":"otherLoginName","score":0,"teamName":""}]`+"\n",
+		rec.Body.String())
+}
+
+func TestGetParticipantsListFieldsInvalid(t *testing.T) {
+	c, _ := setupMockContextParticipantList(campaign)
+	c.Request().URL.RawQuery = qpFields + "=loginName,password"
+
+	mock := newMockDb(t)
+	mock.selectPartInCampCamp = campaign
+	mock.selectPartVersionCamp = campaign
+
+	assertApiError(t, getParticipantsList(c), http.StatusBadRequest, "invalid field: password")
+}
+
+func TestGetParticipantsListFieldsNone(t *testing.T) {
+	c, rec := setupMockContextParticipantList(campaign)
+	c.Request().URL.RawQuery = qpFields + "=loginName"
+
+	mock := newMockDb(t)
+	mock.selectPartInCampCamp = campaign
+	mock.selectPartVersionCamp = campaign
+
+	assert.NoError(t, getParticipantsList(c))
+	assert.Equal(t, "null\n", rec.Body.String())
+}
+
+func TestJsonFieldNames(t *testing.T) {
+	assert.Equal(t, map[string]bool{"guid": true, "campaign": true, "category": true, "pointValue": true, "version": true},
+		jsonFieldNames(reflect.TypeOf(types.BugStruct{})))
+
+	names := jsonFieldNames(reflect.TypeOf(&campaignDetail{}))
+	assert.True(t, names["name"])
+	assert.True(t, names["participants"])
+	assert.False(t, names["CampaignStruct"])
+
+	assert.Equal(t, map[string]bool{"Exported": true}, jsonFieldNames(reflect.TypeOf(struct {
+		Exported   string
+		Ignored    string `json:"-"`
+		unexported string
+	}{})))
+}
+
+func setupMockContextAddBug(bugJson string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest("", "/", strings.NewReader(bugJson))
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	return
+}
+
+func TestAddBugMissingBug(t *testing.T) {
+	c, rec := setupMockContextAddBug("")
+
+	assert.EqualError(t, addBug(c), "EOF")
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+const category = "myCategory"
+
+func TestAddBugScanError(t *testing.T) {
+	c, rec := setupMockContextAddBug(`{"campaign": "` + campaign + `", "category":"` + category + `"}`)
+
+	mock := newMockDb(t)
+	mock.insertBugBug = &types.BugStruct{
+		Campaign: campaign,
+		Category: category,
+	}
+	forcedError := fmt.Errorf("forced insert bug error")
+	mock.insertBugErr = forcedError
+
+	assert.EqualError(t, addBug(c), forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestAddBugInvalidBug(t *testing.T) {
+	c, rec := setupMockContextAddBug(`{"pointValue":-1}`)
+
+	newMockDb(t)
+
+	err := addBug(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{
+		{Field: "campaign", Message: "is required"},
+		{Field: "category", Message: "is required"},
+		{Field: "pointValue", Message: "must be at least 0"},
+	}, toApiError(err).Details)
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+func TestAddBug(t *testing.T) {
+	pointValue := 9.5
+	c, rec := setupMockContextAddBug(`{"campaign": "` + campaign + `", "category":"` + category + `","pointValue":` + strconv.FormatFloat(pointValue, 'f', -1, 64) + `}`)
+
+	mock := newMockDb(t)
+	mock.insertBugBug = &types.BugStruct{
+		Campaign:   campaign,
+		Category:   category,
+		PointValue: pointValue,
+	}
+	bugId := "myBugId"
+	mock.insertBugGuid = bugId
+
+	assert.NoError(t, addBug(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), `{"guid":"`+bugId+`","endpoints":`), rec.Body.String())
+	assert.True(t, strings.HasSuffix(rec.Body.String(), `"object":{"guid":"`+bugId+`","campaign":"`+campaign+`","category":"`+category+`","pointValue":`+strconv.FormatFloat(pointValue, 'f', -1, 64)+`}}`+"\n"), rec.Body.String())
+}
+
+func setupMockContextUpdateBug(campaign, bugCategory, pointValue string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest("", "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamCampaignName, ParamBugCategory, ParamPointValue)
+	c.SetParamValues(campaign, bugCategory, pointValue)
+	return
+}
+
+func TestUpdateBugInvalidPointValue(t *testing.T) {
+	c, rec := setupMockContextUpdateBug("", "", "non-number")
+
+	assertApiError(t, updateBug(c), http.StatusBadRequest, "invalid pointValue: non-number")
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestUpdateBugUpdateError(t *testing.T) {
+	pointValue := float64(9)
+	c, rec := setupMockContextUpdateBug(campaign, category, strconv.FormatFloat(pointValue, 'f', -1, 64))
+	c.Request().Header.Set(headerIfMatch, `"3"`)
+
+	mock := newMockDb(t)
+	mock.updateBugBug = &types.BugStruct{
+		Campaign:   campaign,
+		Category:   category,
+		PointValue: pointValue,
+		Version:    3,
+	}
+	forcedError := fmt.Errorf("forced Update bug error")
+	mock.updateBugErr = forcedError
+
+	assert.EqualError(t, updateBug(c), forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestUpdateBugRowsAffectedZero(t *testing.T) {
+	pointValue := float64(9)
+	c, _ := setupMockContextUpdateBug(campaign, category, strconv.FormatFloat(pointValue, 'f', -1, 64))
+	c.Request().Header.Set(headerIfMatch, `"3"`)
+
+	mock := newMockDb(t)
+	mock.updateBugBug = &types.BugStruct{
+		Campaign:   campaign,
+		Category:   category,
+		PointValue: pointValue,
+		Version:    3,
+	}
+	mock.updateBugRowsAffected = 0
+
+	assertApiError(t, updateBug(c), http.StatusNotFound, "Bug Category not found")
+}
+
+func TestUpdateBugInvalidBug(t *testing.T) {
+	c, rec := setupMockContextUpdateBug("myCampaign", "myCategory", "-1")
+
+	newMockDb(t)
+
+	err := updateBug(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{{Field: "pointValue", Message: "must be at least 0"}}, toApiError(err).Details)
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestUpdateBugNotANumber(t *testing.T) {
+	for _, value := range []string{"NaN", "Inf", "-Infinity"} {
+		c, _ := setupMockContextUpdateBug("myCampaign", "myCategory", value)
+		newMockDb(t)
+
+		assertApiError(t, updateBug(c), http.StatusBadRequest, "invalid pointValue: "+value)
+	}
+}
+
+func TestUpdateBug(t *testing.T) {
+	pointValue := 9.5
+	c, rec := setupMockContextUpdateBug(campaign, category, strconv.FormatFloat(pointValue, 'f', -1, 64))
+	c.Request().Header.Set(headerIfMatch, `"3"`)
+
+	mock := newMockDb(t)
+	mock.updateBugBug = &types.BugStruct{
+		Campaign:   campaign,
+		Category:   category,
+		PointValue: pointValue,
+		Version:    3,
+	}
+	mock.updateBugRowsAffected = 5
+
+	assert.NoError(t, updateBug(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, "Success", rec.Body.String())
+}
+
+func setupMockContextGetBugs() (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest("", "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	return
+}
+
+func TestGetBugsError(t *testing.T) {
+	c, rec := setupMockContextGetBugs()
+
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced Select error")
+	mock.selectBugsErr = forcedError
+
+	assert.EqualError(t, getBugs(c), forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetBugsNotModified(t *testing.T) {
+	c, rec := setupMockContextGetBugs()
+
+	mock := newMockDb(t)
+	mock.selectBugsVersion = types.ListVersion{Count: 3, UpdatedOn: time.Unix(0, 7)}
+	c.Request().Header.Set(headerIfNoneMatch, `W/"3-7"`)
+
+	assert.NoError(t, getBugs(c))
+	assert.Equal(t, http.StatusNotModified, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetBugsModified(t *testing.T) {
+	c, rec := setupMockContextGetBugs()
+
+	mock := newMockDb(t)
+	mock.selectBugsVersion = types.ListVersion{Count: 4, UpdatedOn: time.Unix(0, 7)}
+	c.Request().Header.Set(headerIfNoneMatch, `W/"3-7"`)
+
+	assert.NoError(t, getBugs(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `W/"4-7"`, rec.Header().Get(headerETag))
+	assert.Equal(t, "null\n", rec.Body.String())
+}
+
+func TestGetBugs(t *testing.T) {
+	c, rec := setupMockContextGetBugs()
+
+	mock := newMockDb(t)
+	bugId := "myBugId"
+	category := "myCategory"
+	pointValue := float64(9)
+	mock.selectBugsResult = []types.BugStruct{
+		{
+			Id:         bugId,
+			Campaign:   campaign,
+			Category:   category,
+			PointValue: pointValue,
+		},
+	}
+
+	assert.NoError(t, getBugs(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `[{"guid":"`+bugId+`","campaign":"`+campaign+`","category":"`+category+`","pointValue":`+strconv.FormatFloat(pointValue, 'f', -1, 64)+`}]`+"\n", rec.Body.String())
+}
+
+const overrideOrg = "myOrg"
+
+func TestGetPointValueOverrides(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectOverridesCampName = campaign
+	mock.selectOverridesResult = []types.PointValueOverride{
+		{Id: "myOverrideId", CampaignName: campaign, ScpName: scpName, Organization: overrideOrg, Category: "myCategory", PointValue: 5},
+	}
+
+	assert.NoError(t, getPointValueOverrides(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `[{"guid":"myOverrideId","campaignName":"`+campaign+`","scpName":"`+scpName+`","organization":"`+overrideOrg+
+		`","category":"myCategory","pointValue":5}]`+"\n", rec.Body.String())
+}
+
+func TestGetPointValueOverridesError(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectOverridesCampName = campaign
+	forcedError := fmt.Errorf("forced override error")
+	mock.selectOverridesErr = forcedError
+
+	assert.EqualError(t, getPointValueOverrides(c), forcedError.Error())
+}
+
+func TestPutPointValueOverrideInvalid(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody("", `{"campaignName":"`+campaign+`","scpName":"`+scpName+`","category":"myCategory","pointValue":-1}`)
+
+	newMockDb(t)
+
+	err := putPointValueOverride(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{
+		{Field: "organization", Message: "is required"},
+		{Field: "pointValue", Message: "must be at least 0"},
+	}, toApiError(err).Details)
+}
+
+func TestPutPointValueOverrideNoOrganization(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody("", `{"campaignName":"`+campaign+`","scpName":"`+scpName+`","organization":"`+overrideOrg+`","category":"myCategory","pointValue":5}`)
+
+	mock := newMockDb(t)
+	mock.upsertOverride = &types.PointValueOverride{CampaignName: campaign, ScpName: scpName, Organization: overrideOrg, Category: "myCategory", PointValue: 5}
+	mock.upsertOverrideErr = sql.ErrNoRows
+
+	assertApiError(t, putPointValueOverride(c), http.StatusNotFound,
+		"no campaign or organization: campaignName: "+campaign+", scpName: "+scpName+", organization: "+overrideOrg)
+}
+
+func TestPutPointValueOverride(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody("", `{"campaignName":"`+campaign+`","scpName":"`+scpName+`","organization":"`+overrideOrg+`","category":"myCategory","pointValue":5}`)
+
+	mock := newMockDb(t)
+	mock.upsertOverride = &types.PointValueOverride{CampaignName: campaign, ScpName: scpName, Organization: overrideOrg, Category: "myCategory", PointValue: 5}
+	mock.upsertOverrideId = "myOverrideId"
+
+	assert.NoError(t, putPointValueOverride(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `{"guid":"myOverrideId","campaignName":"`+campaign+`","scpName":"`+scpName+`","organization":"`+overrideOrg+
+		`","category":"myCategory","pointValue":5}`+"\n", rec.Body.String())
+}
+
+func setupMockContextDeletePointValueOverride() (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamCampaignName, ParamScpName, ParamOrganizationName, ParamBugCategory)
+	c.SetParamValues(campaign, scpName, overrideOrg, "myCategory")
+	return
+}
+
+func TestDeletePointValueOverrideNotFound(t *testing.T) {
+	c, _ := setupMockContextDeletePointValueOverride()
+
+	mock := newMockDb(t)
+	mock.deleteOverride = &types.PointValueOverride{CampaignName: campaign, ScpName: scpName, Organization: overrideOrg, Category: "myCategory"}
+
+	assertApiError(t, deletePointValueOverride(c), http.StatusNotFound,
+		"no point value override: campaignName: "+campaign+", scpName: "+scpName+", organization: "+overrideOrg+", category: myCategory")
+}
+
+func TestDeletePointValueOverride(t *testing.T) {
+	c, rec := setupMockContextDeletePointValueOverride()
+
+	mock := newMockDb(t)
+	mock.deleteOverride = &types.PointValueOverride{CampaignName: campaign, ScpName: scpName, Organization: overrideOrg, Category: "myCategory"}
+	mock.deleteOverrideRowsAffected = 1
+
+	assert.NoError(t, deletePointValueOverride(c))
+	assert.Equal(t, http.StatusNoContent, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func setupMockContextPutBugs(bugsJson string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest("", "/", strings.NewReader(bugsJson))
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	return
+}
+
+func TestPutBugsBodyInvalid(t *testing.T) {
+	c, rec := setupMockContextPutBugs("")
+
+	assert.EqualError(t, putBugs(c), "EOF")
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestPutBugsScanError(t *testing.T) {
+	c, rec := setupMockContextPutBugs(
+		`[{"campaign":"` + campaign + `","category":"` + category + `", "pointValue":5}]`)
+
+	mock := newMockDb(t)
+	mock.insertBugBug = &types.BugStruct{
+		Campaign:   campaign,
+		Category:   category,
+		PointValue: 5,
+	}
+	forcedError := fmt.Errorf("forced Scan error")
+	mock.insertBugErr = forcedError
+
+	assert.EqualError(t, putBugs(c), forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestPutBugsOneBugInvalidBug(t *testing.T) {
+	c, rec := setupMockContextPutBugs(`[{"campaign":"myCampaign","category":"bugCat1"},{"campaign":"myCampaign"}]`)
+
+	newMockDb(t)
+
+	err := putBugs(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{{Field: "[1].category", Message: "is required"}}, toApiError(err).Details)
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+func TestPutBugsOneBug(t *testing.T) {
+	c, rec := setupMockContextPutBugs(`[{"campaign":"myCampaign","category":"bugCat2", "pointValue":5}]`)
+
+	mock := newMockDb(t)
+	bugId := "myBugId"
+	mock.insertBugBug = &types.BugStruct{
+		Campaign:   "myCampaign",
+		Category:   "bugCat2",
+		PointValue: 5,
+	}
+	mock.insertBugGuid = bugId
+
+	assert.NoError(t, putBugs(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	assert.Equal(t, `{"guid":"`+bugId+`","endpoints":{"bugList":{"uri":"","httpVerb":"GET"}},"object":[{"guid":"`+bugId+`","campaign":"myCampaign","category":"bugCat2","pointValue":5}]}`+"\n", rec.Body.String())
+}
+
+func TestPutBugsMultipleBugs(t *testing.T) {
+	c, rec := setupMockContextPutBugs(`[{"campaign":"myCampaign","category":"bugCat2", "pointValue":5}, {"campaign":"myCampaign","category":"bugCat3", "pointValue":9}]`)
+
+	mock := newMockDb(t)
+	mock.insertBugBug = &types.BugStruct{
+		Campaign:   "myCampaign",
+		Category:   "bugCat2",
+		PointValue: 5,
+	}
+	bugId := "myBugId"
+	mock.insertBugGuid = bugId
+	bugId2 := fmt.Sprintf("%s%d", bugId, 1)
+
+	assert.NoError(t, putBugs(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	assert.Equal(t, `{"guid":"`+bugId+`","endpoints":{"bugList":{"uri":"","httpVerb":"GET"}},"object":[{"guid":"`+bugId+`","campaign":"myCampaign","category":"bugCat2","pointValue":5},{"guid":"`+bugId2+`","campaign":"myCampaign","category":"bugCat3","pointValue":9}]}`+"\n", rec.Body.String())
+}
+
+func setupMockContextParticipantDelete(campaignName, scpName, loginName string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamCampaignName, ParamScpName, ParamLoginName)
+	c.SetParamValues(campaignName, scpName, loginName)
+	return
+}
+
+func TestDeleteParticipant(t *testing.T) {
+	c, rec := setupMockContextParticipantDelete(campaign, scpName, loginName)
+
+	mock := newMockDb(t)
+	mock.deletePartCampaign = campaign
+	mock.deletePartSCPName = scpName
+	mock.deletePartLoginName = loginName
+	mock.deletePartGuid = participantID
+
+	assert.NoError(t, deleteParticipant(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, fmt.Sprintf("\"deleted participant: campaign: %s, scpName: %s, loginName: %s, participant.id: %s\"\n", campaign, scpName, loginName, participantID), rec.Body.String())
+}
+
+func TestDeleteParticipantWithDBDeleteError(t *testing.T) {
+	c, rec := setupMockContextParticipantDelete(campaign, scpName, loginName)
+
+	mock := newMockDb(t)
+	mock.deletePartCampaign = campaign
+	mock.deletePartSCPName = scpName
+	mock.deletePartLoginName = loginName
+	forcedError := fmt.Errorf("forced delete error")
+	mock.deletePartErr = forcedError
+
+	assert.EqualError(t, deleteParticipant(c), forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestValidScoreErrorValidatingOrganization(t *testing.T) {
+	_, _ = setupMockContext()
+
+	mock := newMockDb(t)
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid}
+	mock.validOrgParam = msg
+	forcedError := fmt.Errorf("forced org exists query error")
+	mock.validOrgErr = forcedError
+
+	activeParticipantsToScore, err := validScore(msg, now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, 0, len(activeParticipantsToScore))
+}
+
+func TestValidScoreOrganizationNotValid(t *testing.T) {
+	_, _ = setupMockContext()
+
+	mock := newMockDb(t)
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid}
+	mock.validOrgParam = msg
+	mock.validOrgResult = false
+
+	activeParticipantsToScore, err := validScore(msg, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(activeParticipantsToScore))
+}
+
+func TestValidScoreUnknownRepoOwner(t *testing.T) {
+	_, _ = setupMockContext()
+
+	mock := newMockDb(t)
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid}
+	mock.validOrgParam = msg
+	mock.validOrgResult = false
+
+	activeParticipantsToScore, err := validScore(msg, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(activeParticipantsToScore))
+}
+
+func setupMockContext() (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	return
+}
+
+func setupMockContextWithBody(method string, body string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	return
+}
+
+func TestValidScoreParticipantNotRegistered(t *testing.T) {
+	mock := newMockDb(t)
+	msg := types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: "unregisteredUser"}
+	mock.validOrgParam = &msg
+
+	_, _ = setupMockContext()
+
+	activeParticipantsToScore, err := validScore(&msg, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(activeParticipantsToScore))
+}
+
+func TestValidScoreParticipantError(t *testing.T) {
+	mock := newMockDb(t)
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName}
+	mock.validOrgParam = msg
+	forcedError := fmt.Errorf("forced current campaign read error")
+	mock.validOrgErr = forcedError
+
+	_, _ = setupMockContext()
+
+	activeParticipantsToScore, err := validScore(msg, now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, 0, len(activeParticipantsToScore))
+}
+
+func TestValidScoreParticipantErrorReadingParticipant(t *testing.T) {
+	mock := newMockDb(t)
+	setupMockDBOrgValid(mock)
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName}
+	mock.validOrgParam = msg
+	mock.partiesToScoreMsg = msg
+	mock.partiesToScoreNow = now
+
+	forcedError := fmt.Errorf("forced current campaign read error")
+	mock.partiesToScoreErr = forcedError
+
+	_, _ = setupMockContext()
+
+	activeParticipantsToScore, err := validScore(msg, now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, 0, len(activeParticipantsToScore))
+}
+
+func TestValidScoreParticipant(t *testing.T) {
+	mock := newMockDb(t)
+	setupMockDBOrgValid(mock)
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName}
+	mock.validOrgParam = msg
+	mock.partiesToScoreMsg = msg
+	mock.partiesToScoreNow = now
+	mock.partiesToScoreResult = []types.ParticipantStruct{
+		{
+			ID:           "someId",
+			CampaignName: "someCampaign",
+			ScpName:      "someSCP",
+			LoginName:    "someLoginName",
+		},
+	}
+
+	_, _ = setupMockContext()
+
+	activeParticipantsToScore, err := validScore(msg, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(activeParticipantsToScore))
+	assert.Equal(t, "someCampaign", activeParticipantsToScore[0].CampaignName)
+	assert.Equal(t, "someSCP", activeParticipantsToScore[0].ScpName)
+}
+
+func TestValidScoreParticipantBanned(t *testing.T) {
+	mock := newMockDb(t)
+	setupMockDBOrgValid(mock)
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName}
+	mock.validOrgParam = msg
+	mock.partiesToScoreMsg = msg
+	mock.partiesToScoreNow = now
+	mock.partiesToScoreResult = []types.ParticipantStruct{{ID: "someId", CampaignName: "someCampaign", ScpName: "someSCP", LoginName: "someLoginName"}}
+	mock.selectBannedScpName = "someSCP"
+	mock.selectBannedLoginName = "someLoginName"
+	mock.selectBannedResult = true
+
+	activeParticipantsToScore, err := validScore(msg, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(activeParticipantsToScore))
+}
+
+func TestValidScoreParticipantErrorReadingBan(t *testing.T) {
+	mock := newMockDb(t)
+	setupMockDBOrgValid(mock)
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName}
+	mock.validOrgParam = msg
+	mock.partiesToScoreMsg = msg
+	mock.partiesToScoreNow = now
+	mock.partiesToScoreResult = []types.ParticipantStruct{{ID: "someId", CampaignName: "someCampaign", ScpName: "someSCP", LoginName: "someLoginName"}}
+	forcedError := fmt.Errorf("forced ban read error")
+	mock.selectBannedErr = forcedError
+
+	activeParticipantsToScore, err := validScore(msg, now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, 0, len(activeParticipantsToScore))
+}
+
+func TestValidScoreRepositoryFiltered(t *testing.T) {
+	mock := newMockDb(t)
+	setupMockDBOrgValid(mock)
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, RepoName: "Widget-Docs", TriggerUser: loginName}
+	mock.validOrgParam = msg
+	mock.partiesToScoreMsg = msg
+	mock.partiesToScoreNow = now
+	mock.partiesToScoreResult = []types.ParticipantStruct{{ID: "someId", CampaignName: "someCampaign", ScpName: "someSCP", LoginName: "someLoginName"}}
+	mock.selectRepoFiltersCampName = "someCampaign"
+	mock.selectRepoFiltersResult = []types.RepositoryFilter{{CampaignName: "someCampaign", Kind: types.RepositoryFilterDeny, Pattern: "*-docs"}}
+
+	activeParticipantsToScore, err := validScore(msg, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(activeParticipantsToScore))
+}
+
+func TestValidScoreRepositoryAllowed(t *testing.T) {
+	mock := newMockDb(t)
+	setupMockDBOrgValid(mock)
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, RepoName: "widget", TriggerUser: loginName}
+	mock.validOrgParam = msg
+	mock.partiesToScoreMsg = msg
+	mock.partiesToScoreNow = now
+	mock.partiesToScoreResult = []types.ParticipantStruct{{ID: "someId", CampaignName: "someCampaign", ScpName: "someSCP", LoginName: "someLoginName"}}
+	mock.selectRepoFiltersCampName = "someCampaign"
+	mock.selectRepoFiltersResult = []types.RepositoryFilter{{CampaignName: "someCampaign", Kind: types.RepositoryFilterDeny, Pattern: "*-docs"}}
+
+	activeParticipantsToScore, err := validScore(msg, now)
+	assert.NoError(t, err)
+	assert.Equal(t, mock.partiesToScoreResult, activeParticipantsToScore)
+}
+
+func TestValidScoreErrorReadingRepositoryFilters(t *testing.T) {
+	mock := newMockDb(t)
+	setupMockDBOrgValid(mock)
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, RepoName: "widget", TriggerUser: loginName}
+	mock.validOrgParam = msg
+	mock.partiesToScoreMsg = msg
+	mock.partiesToScoreNow = now
+	mock.partiesToScoreResult = []types.ParticipantStruct{{ID: "someId", CampaignName: "someCampaign", ScpName: "someSCP", LoginName: "someLoginName"}}
+	forcedError := fmt.Errorf("forced repository filter read error")
+	mock.selectRepoFiltersErr = forcedError
+
+	activeParticipantsToScore, err := validScore(msg, now)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, 0, len(activeParticipantsToScore))
+}
+
+func TestRepositoryScored(t *testing.T) {
+	allow := func(pattern string) types.RepositoryFilter {
+		return types.RepositoryFilter{Kind: types.RepositoryFilterAllow, Pattern: pattern}
+	}
+	deny := func(pattern string) types.RepositoryFilter {
+		return types.RepositoryFilter{Kind: types.RepositoryFilterDeny, Pattern: pattern}
+	}
+	for _, tc := range []struct {
+		name     string
+		filters  []types.RepositoryFilter
+		repoName string
+		scored   bool
+	}{
+		{"no filters", nil, "widget", true},
+		{"denied", []types.RepositoryFilter{deny("*-docs")}, "widget-docs", false},
+		{"denied without case", []types.RepositoryFilter{deny("*-docs")}, "Widget-Docs", false},
+		{"not denied", []types.RepositoryFilter{deny("*-docs")}, "widget", true},
+		{"allowed", []types.RepositoryFilter{allow("widget*")}, "widget-api", true},
+		{"not allowed", []types.RepositoryFilter{allow("widget*")}, "gadget", false},
+		{"deny wins over allow", []types.RepositoryFilter{allow("widget*"), deny("*-docs")}, "widget-docs", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.scored, repositoryScored(tc.filters, tc.repoName))
+		})
+	}
+}
+
+func setupMockDBOrgValid(mock *MockBBashDB) {
+	mock.validOrgParam = &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid}
+	mock.validOrgResult = true
+}
+
+func TestScoreBugCountsEmpty(t *testing.T) {
+	breakdown := types.ScoreBreakdown{BasePoints: 1, Classified: 2}
+
+	scoreBugCounts(&types.ScoringMessage{}, "", &breakdown)
+	assert.Equal(t, types.ScoreBreakdown{BasePoints: 1, Classified: 2}, breakdown)
+}
+
+func TestScoreBugCounts(t *testing.T) {
+	bugType := "myBugType"
+
+	mock := newMockDb(t)
+	mock.selectPointValueBugType = bugType
+	mock.selectPointValueResult = 2
+
+	breakdown := types.ScoreBreakdown{BasePoints: 1, Classified: 2}
+	msg := &types.ScoringMessage{BugCounts: []types.BugCount{{Type: bugType, Count: 3}}}
+	mock.selectPointValueMsg = msg
+
+	scoreBugCounts(msg, "", &breakdown)
+	assert.Equal(t, float64(7), breakdown.BasePoints)
+	assert.Equal(t, float64(5), breakdown.Classified)
+	assert.Equal(t, []types.CategoryScore{{Category: bugType, Count: 3, PointValue: 2, Points: 6}}, breakdown.Categories)
+}
+
+func TestScoreBugCountsNonClassified(t *testing.T) {
+	mock := newMockDb(t)
+	mock.assertParameters = false
+
+	breakdown := types.ScoreBreakdown{BasePoints: 1, Classified: 2}
+	msg := &types.ScoringMessage{BugCounts: []types.BugCount{
+		{Type: "bugTypeFirst", Count: 2},
+		{Type: "bugTypeNested", Count: 3},
+		{Type: "bugTypeLast", Count: 4},
+	}}
+
+	scoreBugCounts(msg, "", &breakdown)
+	assert.Equal(t, float64(1), breakdown.BasePoints)
+	assert.Equal(t, float64(11), breakdown.Classified)
+	assert.Equal(t, 3, len(breakdown.Categories))
+}
+
+func TestScorePointsNothing(t *testing.T) {
+	msg := &types.ScoringMessage{}
+	points, _ := scorePoints(msg, campaign)
+	assert.Equal(t, float64(0), points)
+}
+
+func TestScorePoints(t *testing.T) {
+	mock := newMockDb(t)
+	msg := &types.ScoringMessage{BugCounts: []types.BugCount{{Type: "myBugType", Count: 1}}}
+	mock.selectPointValueMsg = msg
+	mock.selectPointValueCampaign = campaign
+	mock.selectPointValueBugType = "myBugType"
+	mock.selectPointValueResult = 1
+
+	_, _ = setupMockContext()
+
+	points, _ := scorePoints(msg, campaign)
+	assert.Equal(t, float64(1), points)
+}
+
+func TestScorePointsFixedTwoThreePointers(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectPointValueResult = 3
+	bugType := "threePointBugType"
+	msg := &types.ScoringMessage{BugCounts: []types.BugCount{{Type: bugType, Count: 2}}}
+	mock.selectPointValueMsg = msg
+	mock.selectPointValueCampaign = campaign
+	mock.selectPointValueBugType = bugType
+
+	points, _ := scorePoints(msg, campaign)
+	assert.Equal(t, float64(6), points)
+}
+
+func TestScorePointsBonusForNonClassified(t *testing.T) {
+	msg := &types.ScoringMessage{TotalFixed: 1}
+	points, _ := scorePoints(msg, campaign)
+	assert.Equal(t, float64(1), points)
+}
+
+func TestScorePointsPenaltyNotConfigured(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectPenaltyCampName = campaign
+	mock.selectPenaltyErr = sql.ErrNoRows
+
+	points, breakdown := scorePoints(&types.ScoringMessage{TotalFixed: 3, TotalIntroduced: 2}, campaign)
+	assert.Equal(t, float64(3), points)
+	assert.Equal(t, float64(0), breakdown.Penalty)
+}
+
+func TestScorePointsPenaltyError(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectPenaltyCampName = campaign
+	mock.selectPenaltyErr = fmt.Errorf("forced penalty error")
+
+	points, _ := scorePoints(&types.ScoringMessage{TotalFixed: 3, TotalIntroduced: 2}, campaign)
+	assert.Equal(t, float64(3), points)
+}
+
+func TestScorePointsPenaltyDisabled(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectPenaltyCampName = campaign
+	mock.selectPenaltyResult = &types.CampaignPenaltyStruct{CampaignName: campaign, PointValue: 1}
+
+	points, breakdown := scorePoints(&types.ScoringMessage{TotalFixed: 3, TotalIntroduced: 2}, campaign)
+	assert.Equal(t, float64(3), points)
+	assert.Equal(t, 0, breakdown.Introduced)
+}
+
+func TestScorePointsPenalty(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectPenaltyCampName = campaign
+	mock.selectPenaltyResult = &types.CampaignPenaltyStruct{CampaignName: campaign, Enabled: true, PointValue: 2}
+
+	points, breakdown := scorePoints(&types.ScoringMessage{TotalFixed: 3, TotalIntroduced: 2}, campaign)
+	assert.Equal(t, float64(-1), points)
+	assert.Equal(t, &types.ScoreBreakdown{Unclassified: 3, UnclassifiedBonus: 3, Introduced: 2, Penalty: 4, Points: -1}, breakdown)
+}
+
+func TestScorePointsPenaltyFloor(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectPenaltyCampName = campaign
+	mock.selectPenaltyResult = &types.CampaignPenaltyStruct{CampaignName: campaign, Enabled: true, PointValue: 2, Floor: true}
+
+	points, breakdown := scorePoints(&types.ScoringMessage{TotalFixed: 3, TotalIntroduced: 2}, campaign)
+	assert.Equal(t, float64(0), points)
+	assert.Equal(t, &types.ScoreBreakdown{Unclassified: 3, UnclassifiedBonus: 3, Introduced: 2, Penalty: 3, Points: 0}, breakdown)
+
+	// a penalty smaller than the points is taken in full
+	points, _ = scorePoints(&types.ScoringMessage{TotalFixed: 5, TotalIntroduced: 2}, campaign)
+	assert.Equal(t, float64(1), points)
+}
+
+func TestScorePointsUnclassifiedBonus(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectBonusCampName = campaign
+	mock.selectBonusResult = 2.5
+	mock.selectBonusErr = nil
+
+	points, breakdown := scorePoints(&types.ScoringMessage{TotalFixed: 2}, campaign)
+	assert.Equal(t, float64(5), points)
+	assert.Equal(t, &types.ScoreBreakdown{Unclassified: 2, UnclassifiedBonus: 5, Points: 5}, breakdown)
+}
+
+func TestScorePointsUnclassifiedBonusDisabled(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectBonusCampName = campaign
+	mock.selectBonusResult = 0
+	mock.selectBonusErr = nil
+
+	points, _ := scorePoints(&types.ScoringMessage{TotalFixed: 2}, campaign)
+	assert.Equal(t, float64(0), points)
+}
+
+func TestScorePointsUnclassifiedBonusError(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectBonusCampName = campaign
+	mock.selectBonusErr = fmt.Errorf("forced bonus error")
+
+	points, _ := scorePoints(&types.ScoringMessage{TotalFixed: 2}, campaign)
+	assert.Equal(t, float64(2), points)
+}
+
+func TestScorePointsBreakdown(t *testing.T) {
+	mock := newMockDb(t)
+	mock.assertParameters = false
+	mock.selectPointValueResult = 3
+
+	msg := &types.ScoringMessage{
+		TotalFixed: 5,
+		BugCounts: []types.BugCount{
+			{Type: "zBugType", Count: 1},
+			{Type: "aBugType", Count: 2},
+		},
+	}
+
+	points, breakdown := scorePoints(msg, campaign)
+	assert.Equal(t, float64(11), points)
+	assert.Equal(t, &types.ScoreBreakdown{
+		Categories: []types.CategoryScore{
+			{Category: "aBugType", Count: 2, PointValue: 3, Points: 6},
+			{Category: "zBugType", Count: 1, PointValue: 3, Points: 3},
+		},
+		Classified:        3,
+		BasePoints:        9,
+		Unclassified:      2,
+		UnclassifiedBonus: 2,
+		Points:            11,
+	}, breakdown)
+}
+
+func TestScorePointsExcludedCategory(t *testing.T) {
+	mock := newMockDb(t)
+	mock.assertParameters = false
+	mock.selectPointValueResult = 3
+	mock.selectExclusionsCampName = campaign
+	mock.selectExclusionsResult = []types.BugCategoryExclusion{{CampaignName: campaign, Category: "noisyBugType"}}
+
+	msg := &types.ScoringMessage{
+		TotalFixed: 5,
+		BugCounts: []types.BugCount{
+			{Type: "noisyBugType", Count: 2},
+			{Type: "aBugType", Count: 2},
+		},
+	}
+
+	points, breakdown := scorePoints(msg, campaign)
+	assert.Equal(t, float64(7), points)
+	assert.Equal(t, &types.ScoreBreakdown{
+		Categories:        []types.CategoryScore{{Category: "aBugType", Count: 2, PointValue: 3, Points: 6}},
+		Classified:        2,
+		Excluded:          2,
+		BasePoints:        6,
+		Unclassified:      1,
+		UnclassifiedBonus: 1,
+		Points:            7,
+	}, breakdown)
+}
+
+func TestScorePointsExclusionsError(t *testing.T) {
+	mock := newMockDb(t)
+	mock.assertParameters = false
+	mock.selectPointValueResult = 3
+	mock.selectExclusionsErr = fmt.Errorf("forced exclusions error")
+
+	msg := &types.ScoringMessage{TotalFixed: 2, BugCounts: []types.BugCount{{Type: "noisyBugType", Count: 2}}}
+
+	points, _ := scorePoints(msg, campaign)
+	assert.Equal(t, float64(6), points)
+}
+
+func TestApplyDuplicatesNoFingerprints(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectDuplicatesResult = []string{"fp1"}
+	participant := &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+	breakdown := &types.ScoreBreakdown{Points: 12}
+
+	assert.Equal(t, float64(12), applyDuplicates(mock, participant, &types.ScoringMessage{}, 12, breakdown))
+	assert.Equal(t, &types.ScoreBreakdown{Points: 12}, breakdown)
+}
+
+func TestApplyDuplicatesSkip(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectDuplicatePolicyCampName = campaign
+	mock.selectDuplicatesResult = []string{"fp1"}
+	participant := &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+	// a fingerprint repeated in the message is one fixed bug
+	msg := &types.ScoringMessage{Fingerprints: []string{"fp1", "fp2", "fp2", "fp3"}}
+	breakdown := &types.ScoreBreakdown{Points: 12}
+
+	assert.Equal(t, float64(8), applyDuplicates(mock, participant, msg, 12, breakdown))
+	assert.Equal(t, &types.ScoreBreakdown{Duplicates: 1, DuplicatePoints: 4, Points: 8}, breakdown)
+}
+
+func TestApplyDuplicatesDownweight(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectDuplicatePolicyResult = &types.DuplicatePolicyStruct{CampaignName: campaign, Policy: types.DuplicatePolicyDownweight, Weight: 0.25}
+	mock.selectDuplicatePolicyErr = nil
+	mock.selectDuplicatesResult = []string{"fp1", "fp2"}
+	participant := &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+	breakdown := &types.ScoreBreakdown{Points: 8}
+
+	assert.Equal(t, float64(2), applyDuplicates(mock, participant, &types.ScoringMessage{Fingerprints: []string{"fp1", "fp2"}}, 8, breakdown))
+	assert.Equal(t, &types.ScoreBreakdown{Duplicates: 2, DuplicatePoints: 6, Points: 2}, breakdown)
+}
+
+func TestApplyDuplicatesScore(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectDuplicatePolicyResult = &types.DuplicatePolicyStruct{CampaignName: campaign, Policy: types.DuplicatePolicyScore}
+	mock.selectDuplicatePolicyErr = nil
+	mock.selectDuplicatesErr = fmt.Errorf("duplicates read with score policy")
+	participant := &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+
+	assert.Equal(t, float64(8), applyDuplicates(mock, participant, &types.ScoringMessage{Fingerprints: []string{"fp1"}}, 8, &types.ScoreBreakdown{Points: 8}))
+}
+
+func TestApplyDuplicatesPolicyError(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectDuplicatePolicyErr = fmt.Errorf("forced policy error")
+	mock.selectDuplicatesResult = []string{"fp1"}
+	participant := &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+
+	assert.Equal(t, float64(8), applyDuplicates(mock, participant, &types.ScoringMessage{Fingerprints: []string{"fp1"}}, 8, &types.ScoreBreakdown{Points: 8}))
+}
+
+func TestApplyDuplicatesError(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectDuplicatesErr = fmt.Errorf("forced duplicates error")
+	participant := &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+
+	assert.Equal(t, float64(8), applyDuplicates(mock, participant, &types.ScoringMessage{Fingerprints: []string{"fp1"}}, 8, &types.ScoreBreakdown{Points: 8}))
+}
+
+func TestApplyCapsNone(t *testing.T) {
+	mock := newMockDb(t)
+	participant := &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+	breakdown := &types.ScoreBreakdown{Points: 12}
+
+	assert.Equal(t, float64(12), applyCaps(mock, participant, &types.ScoringMessage{}, 12, breakdown, now))
+	assert.Equal(t, &types.ScoreBreakdown{Points: 12}, breakdown)
+}
+
+func TestApplyCapsError(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectCapsErr = fmt.Errorf("forced caps error")
+	participant := &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+
+	assert.Equal(t, float64(12), applyCaps(mock, participant, &types.ScoringMessage{}, 12, &types.ScoreBreakdown{Points: 12}, now))
+}
+
+func TestApplyCapsPerPullRequest(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectCapsCampName = campaign
+	mock.selectCapsResult = &types.CampaignCapsStruct{CampaignName: campaign, PerPullRequest: 10}
+	mock.selectCapsErr = nil
+	participant := &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+	breakdown := &types.ScoreBreakdown{Points: 12}
+
+	assert.Equal(t, float64(10), applyCaps(mock, participant, &types.ScoringMessage{}, 12, breakdown, now))
+	assert.Equal(t, &types.ScoreBreakdown{Capped: 2, Points: 10}, breakdown)
+}
+
+func TestApplyCapsPerDay(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectCapsCampName = campaign
+	mock.selectCapsResult = &types.CampaignCapsStruct{CampaignName: campaign, PerPullRequest: 10, PerDay: 15}
+	mock.selectCapsErr = nil
+	participant := &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+	msg := &types.ScoringMessage{RepoOwner: "myRepoOwner", RepoName: "myRepoName", PullRequest: 2}
+	mock.selectEventsSinceParticipant = participant
+	mock.selectEventsSince = now.UTC().Truncate(24 * time.Hour)
+	mock.selectEventsSinceResult = []db.ScoringEventRow{
+		{Msg: types.ScoringMessage{RepoOwner: "myRepoOwner", RepoName: "myRepoName", PullRequest: 1}, NewPoints: 9},
+		// the pull request being scored again is not counted
+		{Msg: types.ScoringMessage{RepoOwner: "myRepoOwner", RepoName: "myRepoName", PullRequest: 2}, NewPoints: 4},
+	}
+	breakdown := &types.ScoreBreakdown{Points: 12}
+
+	assert.Equal(t, float64(6), applyCaps(mock, participant, msg, 12, breakdown, now))
+	assert.Equal(t, &types.ScoreBreakdown{Capped: 6, Points: 6}, breakdown)
+}
+
+func TestApplyCapsPerDayReached(t *testing.T) {
+	mock := newMockDb(t)
+	mock.assertParameters = false
+	mock.selectCapsResult = &types.CampaignCapsStruct{CampaignName: campaign, PerDay: 5}
+	mock.selectCapsErr = nil
+	mock.selectEventsSinceResult = []db.ScoringEventRow{{Msg: types.ScoringMessage{PullRequest: 1}, NewPoints: 9}}
+	participant := &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+
+	assert.Equal(t, float64(0), applyCaps(mock, participant, &types.ScoringMessage{PullRequest: 2}, 3, &types.ScoreBreakdown{Points: 3}, now))
+}
+
+func TestApplyCapsPerDayError(t *testing.T) {
+	mock := newMockDb(t)
+	mock.assertParameters = false
+	mock.selectCapsResult = &types.CampaignCapsStruct{CampaignName: campaign, PerPullRequest: 10, PerDay: 5}
+	mock.selectCapsErr = nil
+	mock.selectEventsSinceErr = fmt.Errorf("forced scoring events error")
+	participant := &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+
+	assert.Equal(t, float64(10), applyCaps(mock, participant, &types.ScoringMessage{}, 12, &types.ScoreBreakdown{Points: 12}, now))
+}
+
+func TestProcessScoringMessageInvalidScore_Error(t *testing.T) {
+	msg := types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName}
+
+	mock := newMockDb(t)
+	setupMockDBOrgValid(mock)
+	msgLowerCase := msg
+	msgLowerCase.TriggerUser = strings.ToLower(msgLowerCase.TriggerUser)
+	mock.validOrgParam = &msgLowerCase
+	forcedError := fmt.Errorf("forced validScore error")
+	mock.validOrgErr = forcedError
+
+	err := processScoringMessage(mock, now, &msg)
+	assert.EqualError(t, err, forcedError.Error())
+}
+
+func TestProcessScoringMessageInvalidScore_NoTriggerUserFound(t *testing.T) {
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName}
+
+	mock := newMockDb(t)
+	setupMockDBOrgValid(mock)
+	msgLowerCase := msg
+	msgLowerCase.TriggerUser = strings.ToLower(loginName)
+	mock.validOrgParam = msgLowerCase
+	mock.partiesToScoreMsg = msgLowerCase
+	// caller users Time.now(), so don't assert time parameter
+	mock.partiesToScoreNowSkip = true
+
+	err := processScoringMessage(mock, now, msg)
+	assert.NoError(t, err)
+}
+
+func TestProcessScoringMessageUserCapitalizationMismatch(t *testing.T) {
+	loginNameWithCaps := "MYGithubName"
+	//loginNameLowerCase := strings.ToLower(loginName)
+	repoName := "myRepoName"
+	prId := -5
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginNameWithCaps, RepoName: repoName, PullRequest: prId}
+
+	mock := newMockDb(t)
+	setupMockDBOrgValid(mock)
+	msgLowerCase := msg
+	msgLowerCase.TriggerUser = strings.ToLower(loginNameWithCaps)
+	mock.validOrgParam = msgLowerCase
+	mock.partiesToScoreMsg = msgLowerCase
+	// caller users Time.now(), so don't assert time parameter
+	mock.partiesToScoreNowSkip = true
+
+	err := processScoringMessage(mock, now, msg)
+	assert.NoError(t, err)
+}
+
+func TestProcessScoringMessageOne(t *testing.T) {
+	repoName := "myRepoName"
+	prId := -5
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName, RepoName: repoName, PullRequest: prId}
+
+	mock := newMockDb(t)
+	setupMockDBOrgValid(mock)
+	msgLowerCase := msg
+	msgLowerCase.TriggerUser = strings.ToLower(loginName)
+	mock.validOrgParam = msgLowerCase
+	mock.partiesToScoreMsg = msgLowerCase
+	// caller users Time.now(), so don't assert time parameter
+	mock.partiesToScoreNowSkip = true
+
+	err := processScoringMessage(mock, now, msg)
+	assert.NoError(t, err)
+}
+
+func TestProcessScoringMessageTwoParticipantsToScore(t *testing.T) {
+	repoName := "myRepoName"
+	prId := -5
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName,
+		RepoName: repoName, PullRequest: prId, TotalFixed: 2}
+
+	mock := newMockDb(t)
+	setupMockDBOrgValid(mock)
+	msgLowerCase := msg
+	msgLowerCase.TriggerUser = strings.ToLower(loginName)
+	mock.validOrgParam = msgLowerCase
+	mock.partiesToScoreMsg = msgLowerCase
+	// caller users Time.now(), so don't assert time parameter
+	mock.partiesToScoreNowSkip = true
+	mock.partiesToScoreResult = []types.ParticipantStruct{
+		{
+			ID:           "someId",
+			CampaignName: "someCampaign",
+			ScpName:      "someSCP",
+			LoginName:    "someLoginName",
+		},
+		{
+			ID:           "someId2",
+			CampaignName: "someCampaign2",
+			ScpName:      "someSCP2",
+			LoginName:    "someLoginName2",
+		},
+	}
+	mock.priorScoreResult = 4
+
+	mock.priorScoreParticipant = &mock.partiesToScoreResult[0]
+	mock.priorScoreMsg = msg
+
+	mock.insertScoreEvtPartier = &mock.partiesToScoreResult[0]
+	mock.insertScoreEvtMsg = msg
+	mock.insertScoreEvtNewPoints = 2
+
+	err := processScoringMessage(mock, now, msg)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(-3), updateScoreLastDelta)
+}
+
+func TestProcessScoringMessageParticipantPriorScoreError(t *testing.T) {
+	repoName := "myRepoName"
+	prId := -5
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName, RepoName: repoName, PullRequest: prId}
+
+	mock := newMockDb(t)
+	setupMockDBOrgValid(mock)
+	msgLowerCase := msg
+	msgLowerCase.TriggerUser = strings.ToLower(loginName)
+	mock.validOrgParam = msgLowerCase
+	mock.partiesToScoreMsg = msgLowerCase
+	// caller users Time.now(), so don't assert time parameter
+	mock.partiesToScoreNowSkip = true
+	mock.partiesToScoreResult = []types.ParticipantStruct{
+		{
+			ID:           "someId",
+			CampaignName: "someCampaign",
+			ScpName:      "someSCP",
+			LoginName:    "someLoginName",
+		},
+	}
+
+	mock.priorScoreParticipant = &mock.partiesToScoreResult[0]
+	mock.priorScoreMsg = msgLowerCase
+
+	mock.insertScoreEvtPartier = &mock.partiesToScoreResult[0]
+	mock.insertScoreEvtMsg = msgLowerCase
+	forcedError := fmt.Errorf("forced prior score error")
+	mock.insertScoreEvtErr = forcedError
+
+	err := processScoringMessage(mock, now, msg)
+	assert.EqualError(t, err, forcedError.Error())
+}
+
+func TestProcessScoringMessageParticipantUpdateScoreError(t *testing.T) {
+	repoName := "myRepoName"
+	prId := -5
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName, RepoName: repoName, PullRequest: prId}
+
+	mock := newMockDb(t)
+	setupMockDBOrgValid(mock)
+	msgLowerCase := msg
+	msgLowerCase.TriggerUser = strings.ToLower(loginName)
+	mock.validOrgParam = msgLowerCase
+	mock.partiesToScoreMsg = msgLowerCase
+	// caller users Time.now(), so don't assert time parameter
+	mock.partiesToScoreNowSkip = true
+	mock.partiesToScoreResult = []types.ParticipantStruct{
+		{
+			ID:           "someId",
+			CampaignName: "someCampaign",
+			ScpName:      "someSCP",
+			LoginName:    "someLoginName",
+		},
+	}
+
+	mock.priorScoreParticipant = &mock.partiesToScoreResult[0]
+	mock.priorScoreMsg = msgLowerCase
+
+	mock.insertScoreEvtPartier = &mock.partiesToScoreResult[0]
+	mock.insertScoreEvtMsg = msgLowerCase
+
+	mock.updateScoreParticipant = &mock.partiesToScoreResult[0]
+	forcedError := fmt.Errorf("forced update participant score error")
+	mock.updateScoreErr = forcedError
+
+	err := processScoringMessage(mock, now, msg)
+	assert.EqualError(t, err, forcedError.Error())
+}
+
+func TestProcessScoringMessageParticipant(t *testing.T) {
+	repoName := "myRepoName"
+	prId := -5
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName, RepoName: repoName, PullRequest: prId,
+		BugCounts: []types.BugCount{{Type: category, Count: 2}}}
+
+	mock := newMockDb(t)
+	setupMockDBOrgValid(mock)
+	msgLowerCase := msg
+	msgLowerCase.TriggerUser = strings.ToLower(loginName)
+	mock.validOrgParam = msgLowerCase
+	mock.partiesToScoreMsg = msgLowerCase
+	// caller users Time.now(), so don't assert time parameter
+	mock.partiesToScoreNowSkip = true
+	mock.partiesToScoreResult = []types.ParticipantStruct{
+		{
+			ID:           "someId",
+			CampaignName: campaign,
+			ScpName:      "someSCP",
+			LoginName:    "someLoginName",
+		},
+	}
+
+	mock.selectPointValueMsg = msgLowerCase
+	mock.selectPointValueCampaign = campaign
+	mock.selectPointValueBugType = category
+	mock.selectPointValueResult = 3
+
+	mock.priorScoreParticipant = &mock.partiesToScoreResult[0]
+	mock.priorScoreMsg = msgLowerCase
+	mock.priorScoreResult = 2
+
+	mock.insertScoreEvtPartier = &mock.partiesToScoreResult[0]
+	mock.insertScoreEvtMsg = msgLowerCase
+	mock.insertScoreEvtNewPoints = 6
+
+	mock.updateScoreParticipant = &mock.partiesToScoreResult[0]
+	mock.updateScoreDelta = 4
+
+	err := processScoringMessage(mock, now, msg)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.NotificationStruct{
+		ParticipantId: "someId",
+		Kind:          types.NotificationKindPointsAwarded,
+		Message:       "+4 points for pull request " + db.TestOrgValid + "/myRepoName #-5, score for the pull request is now 6",
+		CreatedOn:     now,
+	}, insertNotificationLast)
+}
+
+func TestGetSourceControlProvidersQueryError(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced scp error")
+	mock.getSCPPsErr = forcedError
+
+	err := getSourceControlProviders(c)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetSourceControlProviders(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	mock.getSCPPs = []types.SourceControlProviderStruct{
+		{
+			ID:      "someId",
+			SCPName: "someSCP",
+			Url:     "someUrl",
+		},
+	}
+
+	err := getSourceControlProviders(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, "[{\"guid\":\"someId\",\"scpName\":\"someSCP\",\"url\":\"someUrl\"}]\n", rec.Body.String())
+}
+
+func TestGetOrganizationsError(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	forcedErr := fmt.Errorf("forced org list error")
+	mock.getOrganizationsErr = forcedErr
+
+	err := getOrganizations(c)
+	assert.EqualError(t, err, forcedErr.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetOrganizations(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	mock.getOrganizationsResult = []types.OrganizationStruct{
+		{
+			ID:           "someId",
+			SCPName:      "someSCP",
+			Organization: "someOrg",
+		},
+	}
+
+	err := getOrganizations(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, "[{\"guid\":\"someId\",\"scpName\":\"someSCP\",\"organization\":\"someOrg\"}]\n", rec.Body.String())
+}
+
+func TestAddOrganizationBodyBad(t *testing.T) {
+	c, rec := setupMockContext()
+
+	err := addOrganization(c)
+	assert.EqualError(t, err, "EOF")
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestAddOrganizationInsertError(t *testing.T) {
+	c, rec := setupMockContextWithBody(http.MethodPut, "{\"scpName\":\"myScpName\",\"organization\":\"myOrganizationName\"}")
+
+	mock := newMockDb(t)
+	mock.insertOrganizationParam = &types.OrganizationStruct{
+		SCPName:      scpName,
+		Organization: "myOrganizationName",
+	}
+	forcedError := fmt.Errorf("forced org add error")
+	mock.insertOrganizationErr = forcedError
+
+	err := addOrganization(c)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestAddOrganization(t *testing.T) {
+	c, rec := setupMockContextWithBody(http.MethodPut, "{\"scpName\":\"myScpName\",\"organization\":\"myOrganizationName\"}")
+
+	mock := newMockDb(t)
+	mock.insertOrganizationParam = &types.OrganizationStruct{
+		SCPName:      scpName,
+		Organization: "myOrganizationName",
+	}
+	mock.insertOrganizationGuid = "someId"
+
+	err := addOrganization(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	creation := assertCreation(t, rec, "someId")
+	assert.Equal(t, map[string]endpointDetail{
+		"organizationDelete": {Verb: http.MethodDelete},
+		"organizationUpdate": {Verb: http.MethodPut},
+	}, creation.Endpoints)
+}
+
+func TestAddOrganizationApiVersion1(t *testing.T) {
+	c, rec := setupMockContextWithBody(http.MethodPut, "{\"scpName\":\"myScpName\",\"organization\":\"myOrganizationName\"}")
+	c.Request().Header.Set(headerApiVersion, apiVersion1)
+
+	mock := newMockDb(t)
+	mock.insertOrganizationParam = &types.OrganizationStruct{SCPName: scpName, Organization: "myOrganizationName"}
+	mock.insertOrganizationGuid = "someId"
+
+	assert.NoError(t, addOrganization(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	assert.Equal(t, "someId", rec.Body.String())
+}
+
+func TestAddOrganizationCampaignMissing(t *testing.T) {
+	c, _ := setupMockContextWithBody(http.MethodPut, "{\"scpName\":\"myScpName\",\"organization\":\"myOrganizationName\",\"campaignName\":\""+campaign+"\"}")
+
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignErr = sql.ErrNoRows
+
+	assertApiError(t, addOrganization(c), http.StatusNotFound, "no campaign: campaignName: "+campaign)
+}
+
+func TestAddOrganizationCampaignError(t *testing.T) {
+	c, _ := setupMockContextWithBody(http.MethodPut, "{\"scpName\":\"myScpName\",\"organization\":\"myOrganizationName\",\"campaignName\":\""+campaign+"\"}")
+
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	forcedError := fmt.Errorf("forced campaign error")
+	mock.getCampaignErr = forcedError
+
+	assert.EqualError(t, addOrganization(c), forcedError.Error())
+}
+
+func TestAddOrganizationWithCampaign(t *testing.T) {
+	c, rec := setupMockContextWithBody(http.MethodPut, "{\"scpName\":\"myScpName\",\"organization\":\"myOrganizationName\",\"campaignName\":\""+campaign+"\"}")
+
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{ID: campaignId, Name: campaign, StartOn: now, EndOn: now}
+	mock.insertOrganizationParam = &types.OrganizationStruct{
+		SCPName:      scpName,
+		Organization: "myOrganizationName",
+		CampaignName: campaign,
+	}
+	mock.insertOrganizationGuid = "someId"
+
+	err := addOrganization(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	assertCreation(t, rec, "someId")
+}
+
+func TestDeleteOrganizationDeleteError(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+
+	forcedError := fmt.Errorf("forced org delete error")
+	mock.deleteOrgErr = forcedError
+
+	err := deleteOrganization(c)
+	assert.EqualError(t, err, forcedError.Error())
+	assert.Equal(t, 0, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestDeleteOrganizationNotFound(t *testing.T) {
+	c, _ := setupMockContext()
+
+	mock := newMockDb(t)
+	mock.deleteOrgRowsAffected = 0
+
+	assertApiError(t, deleteOrganization(c), http.StatusNotFound, "no organization: scpName: , name: ")
+}
+
+func TestDeleteOrganization(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	mock.deleteOrgRowsAffected = 1
+
+	err := deleteOrganization(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func setupMockContextDeleteOrganizationOfCampaign() (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/?"+ParamCampaignName+"="+campaign, nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamScpName, ParamOrganizationName)
+	c.SetParamValues(scpName, overrideOrg)
+	return
+}
+
+func TestDeleteOrganizationOfCampaignNotFound(t *testing.T) {
+	c, _ := setupMockContextDeleteOrganizationOfCampaign()
+
+	mock := newMockDb(t)
+	mock.deleteOrgSCPName = scpName
+	mock.deleteOrgOrgName = overrideOrg
+	mock.deleteOrgCampaignName = campaign
+
+	assertApiError(t, deleteOrganization(c), http.StatusNotFound,
+		"no organization: scpName: "+scpName+", name: "+overrideOrg+", campaignName: "+campaign)
+}
+
+func TestDeleteOrganizationOfCampaign(t *testing.T) {
+	c, rec := setupMockContextDeleteOrganizationOfCampaign()
+
+	mock := newMockDb(t)
+	mock.deleteOrgSCPName = scpName
+	mock.deleteOrgOrgName = overrideOrg
+	mock.deleteOrgCampaignName = campaign
+	mock.deleteOrgRowsAffected = 1
+
+	err := deleteOrganization(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func setupMockContextUpdateOrganization(body string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	c, rec = setupMockContextWithBody(http.MethodPut, body)
+	c.SetParamNames(ParamScpName, ParamOrganizationName)
+	c.SetParamValues(scpName, overrideOrg)
+	return
+}
+
+func TestUpdateOrganizationBodyBad(t *testing.T) {
+	c, _ := setupMockContextUpdateOrganization("")
+
+	newMockDb(t)
+
+	assert.EqualError(t, updateOrganization(c), "EOF")
+}
+
+func TestUpdateOrganizationInvalid(t *testing.T) {
+	c, _ := setupMockContextUpdateOrganization(`{"relinkScores": true}`)
+
+	newMockDb(t)
+
+	assertApiError(t, updateOrganization(c), http.StatusUnprocessableEntity, "request is not valid")
+}
+
+func TestUpdateOrganizationError(t *testing.T) {
+	c, _ := setupMockContextUpdateOrganization(`{"organization": "myNewOrg"}`)
+
+	mock := newMockDb(t)
+	mock.updateOrgSCPName = scpName
+	mock.updateOrgOrgName = overrideOrg
+	mock.updateOrgParam = &types.OrganizationUpdate{Organization: "myNewOrg"}
+	forcedError := fmt.Errorf("forced org update error")
+	mock.updateOrgErr = forcedError
+
+	assert.EqualError(t, updateOrganization(c), forcedError.Error())
+}
+
+func TestUpdateOrganizationNotFound(t *testing.T) {
+	c, _ := setupMockContextUpdateOrganization(`{"organization": "myNewOrg"}`)
+
+	mock := newMockDb(t)
+	mock.updateOrgSCPName = scpName
+	mock.updateOrgOrgName = overrideOrg
+	mock.updateOrgParam = &types.OrganizationUpdate{Organization: "myNewOrg"}
+
+	assertApiError(t, updateOrganization(c), http.StatusNotFound, "no organization: scpName: "+scpName+", name: "+overrideOrg)
+}
+
+func TestUpdateOrganization(t *testing.T) {
+	c, rec := setupMockContextUpdateOrganization(`{"organization": "myNewOrg", "relinkScores": true}`)
+
+	mock := newMockDb(t)
+	mock.updateOrgSCPName = scpName
+	mock.updateOrgOrgName = overrideOrg
+	mock.updateOrgParam = &types.OrganizationUpdate{Organization: "myNewOrg", RelinkScores: true}
+	mock.updateOrgRowsAffected = 1
+	mock.updateOrgEventsRelinked = 3
+
+	err := updateOrganization(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestAdminValidatorNotConfigured(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	isValid, err := newAdminValidator(config.Defaults())("yadda", "bing", nil)
+	assert.NoError(t, err)
+	assert.False(t, isValid)
+}
+
+func TestAdminValidatorInValid(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	isValid, err := newAdminValidator(&config.Config{AdminUsername: "yadda", AdminPassword: "Doh!"})("yadda", "bing", nil)
+	assert.NoError(t, err)
+	assert.False(t, isValid)
+}
+
+func TestAdminValidatorValid(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	isValid, err := newAdminValidator(&config.Config{AdminUsername: "yadda", AdminPassword: "bing"})("yadda", "bing", nil)
+	assert.NoError(t, err)
+	assert.True(t, isValid)
+}
+
+func TestAdminValidatorNotConfiguredBlank(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	isValid, err := newAdminValidator(config.Defaults())("", "", nil)
+	assert.NoError(t, err)
+	assert.False(t, isValid)
+}
+
+func setupSecretsStore(t *testing.T, contents string) (path string) {
+	origSecretsStore := secretsStore
+	t.Cleanup(func() {
+		secretsStore = origSecretsStore
+	})
+
+	path = filepath.Join(t.TempDir(), "secrets.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+	var err error
+	secretsStore, err = secrets.NewStore(path)
+	assert.NoError(t, err)
+	return
+}
+
+func TestAdminValidatorSecretsFile(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	path := setupSecretsStore(t, fmt.Sprintf("admins:\n  - username: rotated\n    passwordHash: %s\n", hashTenantPassword(t, "rotatedPassword")))
+
+	validator := newAdminValidator(&config.Config{AdminUsername: "yadda", AdminPassword: "bing"})
+	for _, test := range []struct {
+		username string
+		password string
+		valid    bool
+	}{
+		{"yadda", "bing", true},
+		{"rotated", "rotatedPassword", true},
+		{"rotated", "bing", false},
+	} {
+		isValid, err := validator(test.username, test.password, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, test.valid, isValid, test.username)
+	}
+
+	// rotated, without a restart
+	assert.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf("admins:\n  - username: rotated\n    passwordHash: %s\n", hashTenantPassword(t, "newPassword"))), 0600))
+	reloadSecrets()
+	isValid, err := validator("rotated", "rotatedPassword", nil)
+	assert.NoError(t, err)
+	assert.False(t, isValid)
+	isValid, err = validator("rotated", "newPassword", nil)
+	assert.NoError(t, err)
+	assert.True(t, isValid)
+}
+
+func TestReloadSecretsInvalid(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger = zap.New(core)
+	path := setupSecretsStore(t, "ddClientApiKey: myApiKey\n")
+
+	assert.NoError(t, os.WriteFile(path, []byte("ddClientApiKey: [\n"), 0600))
+	reloadSecrets()
+	assert.Equal(t, "myApiKey", secretsStore.Secrets().DDClientApiKey)
+	assert.Equal(t, 1, logs.FilterMessage("secrets reload error").Len())
+
+	assert.NoError(t, os.WriteFile(path, []byte("ddClientApiKey: myRotatedApiKey\n"), 0600))
+	reloadSecrets()
+	assert.Equal(t, "myRotatedApiKey", secretsStore.Secrets().DDClientApiKey)
+	assert.Equal(t, 1, logs.FilterMessage("secrets reloaded").Len())
+}
+
+func TestLogTelemetry(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	q := req.URL.Query()
+	q.Add(qpFeature, "testFeature")
+	q.Add(qpCall, "testCaller")
+	req.URL.RawQuery = q.Encode()
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mock := newMockDb(t)
+	mock.insertTelemetryFeature = "testFeature"
+	mock.insertTelemetryCall = "testCaller"
+	logTelemetry(c)
+	assert.Equal(t, 1, insertTelemetryCount)
+}
+
+func TestLogTelemetryInsertError(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/?feature=testFeature&call=testCaller", nil)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mock := newMockDb(t)
+	mock.insertTelemetryErr = fmt.Errorf("forced telemetry error")
+	logTelemetry(c)
+	assert.Equal(t, 1, insertTelemetryCount)
+}
+
+func TestLogTelemetryNoQueryParameters(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	newMockDb(t)
+	logTelemetry(c)
+	assert.Equal(t, 0, insertTelemetryCount)
+}
+
+func setupMockContextTelemetry(query string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	return
+}
+
+func TestGetTelemetryUsageInvalidFrom(t *testing.T) {
+	c, _ := setupMockContextTelemetry("from=yesterday")
+	newMockDb(t)
+
+	assertApiError(t, getTelemetryUsage(c), http.StatusBadRequest, "invalid from: yesterday")
+}
+
+func TestGetTelemetryUsageInvalidTo(t *testing.T) {
+	c, _ := setupMockContextTelemetry("to=2021-13-01")
+	newMockDb(t)
+
+	assertApiError(t, getTelemetryUsage(c), http.StatusBadRequest, "invalid to: 2021-13-01")
+}
+
+func TestGetTelemetryUsageToBeforeFrom(t *testing.T) {
+	c, _ := setupMockContextTelemetry("from=2021-11-02&to=2021-11-01")
+	newMockDb(t)
+
+	assertApiError(t, getTelemetryUsage(c), http.StatusBadRequest, "to is before from")
+}
+
+func TestGetTelemetryUsageError(t *testing.T) {
+	c, _ := setupMockContextTelemetry("")
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced telemetry usage error")
+	mock.selectTelemetryErr = forcedError
+
+	assert.EqualError(t, getTelemetryUsage(c), forcedError.Error())
+}
+
+func TestGetTelemetryUsage(t *testing.T) {
+	c, rec := setupMockContextTelemetry("from=2021-11-01&to=2021-11-01")
+	mock := newMockDb(t)
+	mock.selectTelemetryFrom = time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)
+	mock.selectTelemetryTo = time.Date(2021, 11, 2, 0, 0, 0, 0, time.UTC)
+	mock.selectTelemetryResult = []types.TelemetryUsage{{Feature: "leaderboard", Calls: 12}, {Feature: "participants", Calls: 3}}
+
+	assert.NoError(t, getTelemetryUsage(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `[{"feature":"leaderboard","calls":12},{"feature":"participants","calls":3}]`+"\n", rec.Body.String())
+}
+
+func TestProcessScoringMessage(t *testing.T) {
+	mock := newMockDb(t)
+	setupMockDBOrgValid(mock)
+
+	mock.assertParameters = false
+	now := time.Now()
+	// caller users Time.now(), so don't assert time parameter
+	//mock.partiesToScoreNowSkip = true
+	mock.partiesToScoreNow = now
+	mock.partiesToScoreResult = []types.ParticipantStruct{
+		{
+			ID:           "someId",
+			CampaignName: "someCampaign",
+			ScpName:      "someSCP",
+			LoginName:    "someLoginName",
+		},
+	}
+
+	msg := &types.ScoringMessage{
+		BugCounts: []types.BugCount{
+			{Type: "G104", Count: 1},
+			{Type: "ShellCheck", Count: 1},
+			{Type: "sprintf-host-port", Count: 2},
+		},
+	}
+	err := processScoringMessage(mock, now, msg)
+	assert.NoError(t, err)
+}
+
+func TestBeginLogPolling(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	_, sqlDb, closeDbFunc := db.SetupMockDB(t)
+	defer closeDbFunc()
+	// side effect: set up the postgresDB var
+	scoreDB = sqlDb
+
+	quit, errChan, err := beginLogPolling(config.Defaults())
+	defer func() {
+		//close(quit)
+		//close(errChan)
+	}()
+	assert.NoError(t, err)
+	assert.NotNil(t, quit)
+	assert.NotNil(t, errChan)
+}
+
+// setupMockPollController replaces the poll controller with one that does not poll, and returns a func to restore it
+// along with pollWanted
+func setupMockPollController(startErr error) (resetPollController func()) {
+	origPollController, origPollWanted := pollController, pollWanted
+	resetPollController = func() {
+		pollController.Pause()
+		pollController, pollWanted = origPollController, origPollWanted
+	}
+	pollController = poll.NewController(func() (quit chan bool, errChan chan error, err error) {
+		return make(chan bool), make(chan error), startErr
+	})
+	return
+}
+
+func TestResumePollingError(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	forcedError := fmt.Errorf("forced poll start error")
+	defer setupMockPollController(forcedError)()
+
+	mock := newMockDb(t)
+	mock.acquirePollLeaseHeld = true
+
+	c, _ := setupMockContextWithBody(http.MethodPost, "")
+
+	assert.EqualError(t, resumePolling(c), forcedError.Error())
+	assert.False(t, pollController.State().Running)
+}
+
+func TestResumePolling(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	defer setupMockPollController(nil)()
+	mock := newMockDb(t)
+	mock.acquirePollLeaseHeld = true
+
+	c, rec := setupMockContextWithBody(http.MethodPost, "")
+
+	assert.NoError(t, resumePolling(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	state := types.PollState{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &state))
+	assert.True(t, state.Running)
+	assert.True(t, pollWanted)
+}
+
+func TestResumePollingLeaseHeldElsewhere(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	defer setupMockPollController(nil)()
+	newMockDb(t)
+
+	c, rec := setupMockContextWithBody(http.MethodPost, "")
+
+	assert.NoError(t, resumePolling(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	state := types.PollState{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &state))
+	// another replica leads the poll
+	assert.False(t, state.Running)
+	assert.True(t, pollWanted)
+}
+
+func TestPausePolling(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	defer setupMockPollController(nil)()
+	mock := newMockDb(t)
+	mock.releasePollLeaseErr = fmt.Errorf("forced release error")
+	pollWanted = true
+	_, err := pollController.Resume()
+	assert.NoError(t, err)
+
+	c, rec := setupMockContextWithBody(http.MethodPost, "")
+
+	assert.NoError(t, pausePolling(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	state := types.PollState{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &state))
+	assert.False(t, state.Running)
+	assert.False(t, pollWanted)
+}
+
+func TestSetPollDateEmptyBody(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest("", "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	assert.EqualError(t, setPollDate(c), "EOF")
+}
+
+func setupMockContextPollDate(t *testing.T, poll types.Poll) echo.Context {
+	logger = zaptest.NewLogger(t)
+
+	e := echo.New()
+
+	bodyBytes, err := json.Marshal(poll)
+	assert.NoError(t, err)
+	req := httptest.NewRequest("", "/", strings.NewReader(string(bodyBytes)))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	return c
+}
+
+func TestSetPollDateSelectError(t *testing.T) {
+	c := setupMockContextPollDate(t, types.Poll{})
+
+	mock, dbFake, closeDbFunc := db.SetupMockDB(t)
+	defer closeDbFunc()
+	// side effect: set up the postgresDB var
+	scoreDB = dbFake
+	pollDB = db.NewDBPoll(scoreDB.GetDb(), logger)
+
+	forcedError := fmt.Errorf("forced select poll error")
+	db.SetupMockPollSelectForcedError(mock, forcedError, "1")
+
+	assert.EqualError(t, setPollDate(c), forcedError.Error())
+}
+
+func TestSetPollDateUpdateError(t *testing.T) {
+	c := setupMockContextPollDate(t, types.Poll{})
+
+	mock, dbFake, closeDbFunc := db.SetupMockDB(t)
+	defer closeDbFunc()
+	// side effect: set up the postgresDB var
+	scoreDB = dbFake
+	pollDB = db.NewDBPoll(scoreDB.GetDb(), logger)
+
+	db.SetupMockPollSelectAndUpdate(mock, "1", now, 1)
+
+	err := setPollDate(c)
+	assert.True(t, strings.HasPrefix(err.Error(), "ExecQuery 'UPDATE poll"))
+}
+
+func TestSetPollDate(t *testing.T) {
+	c := setupMockContextPollDate(t, types.Poll{})
+
+	mock, dbFake, closeDbFunc := db.SetupMockDB(t)
+	defer closeDbFunc()
+	// side effect: set up the postgresDB var
+	scoreDB = dbFake
+	pollDB = db.NewDBPoll(scoreDB.GetDb(), logger)
+
+	db.SetupMockPollSelectAndUpdateAnyUpdateTime(mock, "1", now, 1)
+
+	err := setPollDate(c)
+	assert.NoError(t, err)
+}
+
+func TestStartBackfillBodyBad(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	c, _ := setupMockContextWithBody(http.MethodPost, "")
+
+	assert.EqualError(t, startBackfill(c), "EOF")
+}
+
+func TestStartBackfillInvalid(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	c, _ := setupMockContextWithBody(http.MethodPost, `{"from": "2022-03-15T12:00:00Z", "to": "2022-03-10T12:00:00Z"}`)
+
+	assertApiError(t, startBackfill(c), http.StatusUnprocessableEntity, "request is not valid")
+}
+
+func TestStartBackfillPollingDisabled(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	origPollDB := pollDB
+	defer func() {
+		pollDB = origPollDB
+	}()
+	pollDB = nil
+
+	c, _ := setupMockContextWithBody(http.MethodPost, `{"from": "2022-03-10T12:00:00Z", "to": "2022-03-15T12:00:00Z"}`)
+
+	assertApiError(t, startBackfill(c), http.StatusServiceUnavailable, "polling is disabled")
+}
+
+func TestGetBackfillNone(t *testing.T) {
+	origBackfill := backfill
+	defer func() {
+		backfill = origBackfill
+	}()
+	backfill = poll.NewBackfill()
+
+	c, _ := setupMockContext()
+
+	assertApiError(t, getBackfill(c), http.StatusNotFound, "no backfill")
+}
+
+func TestNewClusterInstanceFromConfig(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.InstanceId = "myInstance"
+	cfg.InstanceRole = "poller"
+
+	instance := newClusterInstance(cfg, now)
+	assert.Equal(t, "myInstance", instance.InstanceId)
+	assert.Equal(t, "poller", instance.Role)
+	assert.Equal(t, now, instance.StartedOn)
+}
+
+func TestNewClusterInstanceDefaults(t *testing.T) {
+	instance := newClusterInstance(config.Defaults(), now)
+	assert.True(t, strings.HasSuffix(instance.InstanceId, fmt.Sprintf("-%d", os.Getpid())))
+	assert.Equal(t, defaultInstanceRole, instance.Role)
+}
+
+func TestSendHeartbeat(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	mock := newMockDb(t)
+	defer setupMockPollController(nil)()
+	pollWanted = true
+	mock.acquirePollLeaseHeld = true
+
+	thisInstance = types.ClusterInstance{InstanceId: "myInstance", Role: defaultInstanceRole, StartedOn: now}
+	mock.upsertClusterInstance = &types.ClusterInstance{
+		InstanceId:    "myInstance",
+		Role:          defaultInstanceRole,
+		PollLeader:    true,
+		StartedOn:     now,
+		LastHeartbeat: now,
+	}
+
+	// the first heartbeat takes the lease and starts the poll
+	assert.NoError(t, sendHeartbeat(now))
+	assert.True(t, pollController.State().Running)
+}
+
+func TestSendHeartbeatLeaseHeldElsewhere(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	mock := newMockDb(t)
+	defer setupMockPollController(nil)()
+	pollWanted = true
+	_, err := pollController.Resume()
+	assert.NoError(t, err)
+
+	thisInstance = types.ClusterInstance{InstanceId: "myInstance", Role: defaultInstanceRole, StartedOn: now}
+	mock.upsertClusterInstance = &types.ClusterInstance{
+		InstanceId:    "myInstance",
+		Role:          defaultInstanceRole,
+		StartedOn:     now,
+		LastHeartbeat: now,
+	}
+
+	// the lease was taken over, so the poll stops
+	assert.NoError(t, sendHeartbeat(now))
+	assert.False(t, pollController.State().Running)
+}
+
+func TestSendHeartbeatLeaseError(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	mock := newMockDb(t)
+	defer setupMockPollController(nil)()
+	pollWanted = true
+	_, err := pollController.Resume()
+	assert.NoError(t, err)
+	forcedError := fmt.Errorf("forced lease error")
+	mock.acquirePollLeaseErr = forcedError
+
+	thisInstance = types.ClusterInstance{InstanceId: "myInstance", Role: defaultInstanceRole, StartedOn: now}
+	mock.upsertClusterInstance = &types.ClusterInstance{
+		InstanceId:    "myInstance",
+		Role:          defaultInstanceRole,
+		StartedOn:     now,
+		LastHeartbeat: now,
+	}
+
+	assert.EqualError(t, sendHeartbeat(now), forcedError.Error())
+	assert.False(t, pollController.State().Running)
+}
+
+func TestSendHeartbeatPollNotWanted(t *testing.T) {
+	mock := newMockDb(t)
+	defer setupMockPollController(nil)()
+	pollWanted = false
+	// not asked for the lease
+	mock.acquirePollLeaseErr = fmt.Errorf("forced lease error")
+
+	thisInstance = types.ClusterInstance{InstanceId: "myInstance", Role: defaultInstanceRole, StartedOn: now}
+	mock.upsertClusterInstance = &types.ClusterInstance{
+		InstanceId:    "myInstance",
+		Role:          defaultInstanceRole,
+		StartedOn:     now,
+		LastHeartbeat: now,
+	}
+
+	assert.NoError(t, sendHeartbeat(now))
+	assert.False(t, pollController.State().Running)
+}
+
+func TestGetClusterStatusError(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced cluster error")
+	mock.selectClusterInstancesErr = forcedError
+
+	assert.EqualError(t, getClusterStatus(c), forcedError.Error())
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetClusterStatus(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	staleHeartbeat := time.Now().Add(-heartbeatInterval * (missedHeartbeatsAllowed + 1))
+	mock.selectClusterInstancesResult = []types.ClusterInstance{
+		{InstanceId: "alive", PollLeader: true, LastHeartbeat: time.Now()},
+		{InstanceId: "dead", LastHeartbeat: staleHeartbeat},
+	}
+
+	assert.NoError(t, getClusterStatus(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+
+	var instances []types.ClusterInstance
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &instances))
+	assert.Equal(t, 2, len(instances))
+	assert.True(t, instances[0].Alive)
+	assert.True(t, instances[0].PollLeader)
+	assert.False(t, instances[1].Alive)
+}
+
+func setupMockScheduler(t *testing.T) (mock *MockBBashDB, resetScheduler func()) {
+	mock = newMockDb(t)
+	origScheduler := jobScheduler
+	jobScheduler = scheduler.New(logger, mock, "myInstance")
+	resetScheduler = func() {
+		jobScheduler = origScheduler
+	}
+	return
+}
+
+func TestScheduleJobs(t *testing.T) {
+	mock, resetScheduler := setupMockScheduler(t)
+	defer resetScheduler()
+	cfg := config.Defaults()
+	cfg.ArchiveAfterDays = 90
+
+	mock.selectScheduledJobRuns = []types.ScheduledJobRun{{Name: "heartbeat", InstanceId: "otherInstance", Runs: 3}}
+
+	assert.NoError(t, scheduleJobs(cfg))
+
+	jobs, err := jobScheduler.Jobs(context.Background())
+	assert.NoError(t, err)
+	var names []string
+	for _, job := range jobs {
+		names = append(names, job.Name)
+	}
+	assert.Equal(t, []string{"heartbeat", "profile-refresh", "leaderboard-refresh", "campaign-lifecycle", "anomaly-detection", "archival"}, names)
+	assert.Equal(t, "@every "+archiveInterval.String(), jobs[5].Schedule)
+	assert.Equal(t, int64(3), jobs[0].LastRun.Runs)
+}
+
+func TestScheduleJobsTwice(t *testing.T) {
+	_, resetScheduler := setupMockScheduler(t)
+	defer resetScheduler()
+
+	assert.NoError(t, scheduleJobs(config.Defaults()))
+	assert.EqualError(t, scheduleJobs(config.Defaults()), "job already registered: job: heartbeat")
+}
+
+func TestGetScheduledJobsError(t *testing.T) {
+	c, rec := setupMockContext()
+	mock, resetScheduler := setupMockScheduler(t)
+	defer resetScheduler()
+	forcedError := fmt.Errorf("forced scheduled job error")
+	mock.selectScheduledJobRunsErr = forcedError
+
+	assert.EqualError(t, getScheduledJobs(c), forcedError.Error())
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetScheduledJobs(t *testing.T) {
+	c, rec := setupMockContext()
+	mock, resetScheduler := setupMockScheduler(t)
+	defer resetScheduler()
+	mock.selectScheduledJobRuns = []types.ScheduledJobRun{
+		{Name: "anomaly-detection", InstanceId: "otherInstance", StartedOn: now.UTC(), FinishedOn: now.UTC(), Error: "forced error", Runs: 2, Failures: 1},
+	}
+	assert.NoError(t, scheduleJobs(config.Defaults()))
+
+	assert.NoError(t, getScheduledJobs(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var jobs []types.ScheduledJob
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &jobs))
+	assert.Equal(t, 5, len(jobs))
+	assert.Equal(t, "heartbeat", jobs[0].Name)
+	assert.Nil(t, jobs[0].NextRunOn)
+	assert.Nil(t, jobs[0].LastRun)
+	assert.Equal(t, &types.ScheduledJobRun{Name: "anomaly-detection", InstanceId: "otherInstance", StartedOn: now.UTC(),
+		FinishedOn: now.UTC(), Error: "forced error", Runs: 2, Failures: 1}, jobs[4].LastRun)
+}
+
+func TestGetMigrationStatusError(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced migration status error")
+	mock.selectMigrationStatusErr = forcedError
+
+	assert.EqualError(t, getMigrationStatus(c), forcedError.Error())
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetMigrationStatus(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	mock.selectMigrationStatus = types.MigrationStatus{Version: 12}
+
+	assert.NoError(t, getMigrationStatus(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, "{\"version\":12,\"dirty\":false}\n", rec.Body.String())
+}
+
+// readBackup reads each file of the backup archive in the body
+func readBackup(t *testing.T, rec *httptest.ResponseRecorder) (files map[string]string) {
+	files = map[string]string{}
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, file := range archive.File {
+		r, err := file.Open()
+		assert.NoError(t, err)
+		content, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		files[file.Name] = string(content)
+	}
+	return
+}
+
+func TestBackupDatabaseMigrationStatusError(t *testing.T) {
+	c, rec := setupMockContext()
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced migration status error")
+	mock.selectMigrationStatusErr = forcedError
+
+	assert.EqualError(t, backupDatabase(c), forcedError.Error())
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestBackupDatabaseError(t *testing.T) {
+	c, rec := setupMockContext()
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced backup error")
+	mock.backupErr = forcedError
+
+	assert.EqualError(t, backupDatabase(c), forcedError.Error())
+	assert.False(t, c.Response().Committed)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestBackupDatabase(t *testing.T) {
+	c, rec := setupMockContext()
+	mock := newMockDb(t)
+	mock.selectMigrationStatus = types.MigrationStatus{Version: 44}
+	mock.backupRows = []mockBackupTable{
+		{table: "campaign", rows: "{\"name\":\"myCampaign\"}\n"},
+		{table: "scoring_event", rows: "{\"pr\":1}\n{\"pr\":2}\n"},
+	}
+
+	assert.NoError(t, backupDatabase(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, mimeZip, rec.Header().Get(echo.HeaderContentType))
+	assert.True(t, strings.HasPrefix(rec.Header().Get(echo.HeaderContentDisposition), `attachment; filename="bbash-backup-`))
+	assert.True(t, rec.Flushed)
+
+	files := readBackup(t, rec)
+	assert.Equal(t, 3, len(files))
+	assert.Equal(t, mock.backupRows[0].rows, files["campaign.ndjson"])
+	assert.Equal(t, mock.backupRows[1].rows, files["scoring_event.ndjson"])
+	var manifest types.BackupManifest
+	assert.NoError(t, json.Unmarshal([]byte(files["manifest.json"]), &manifest))
+	assert.Equal(t, uint(44), manifest.SchemaVersion)
+	assert.Equal(t, buildversion.BuildVersion, manifest.BuildVersion)
+	assert.Equal(t, map[string]int64{"campaign": 1, "scoring_event": 2}, manifest.RowCounts)
+	assert.Equal(t, db.BackupExcludedTables, manifest.ExcludedTables)
+}
+
+func TestBackupDatabaseErrorPartWay(t *testing.T) {
+	c, rec := setupMockContext()
+	mock := newMockDb(t)
+	mock.backupRows = []mockBackupTable{{table: "campaign", rows: "{\"name\":\"myCampaign\"}\n"}}
+	forcedError := fmt.Errorf("forced backup error")
+	mock.backupErr = forcedError
+
+	assert.EqualError(t, backupDatabase(c), forcedError.Error())
+	// the status went out with the first table, so the archive just ends, without its manifest
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.NotContains(t, rec.Body.String(), "manifest.json")
+}
+
+func TestGetVersionInfoError(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced migration status error")
+	mock.selectMigrationStatusErr = forcedError
+
+	assert.EqualError(t, getVersionInfo(c), forcedError.Error())
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetVersionInfo(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	mock.selectMigrationStatus = types.MigrationStatus{Version: 12}
+
+	assert.NoError(t, getVersionInfo(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, fmt.Sprintf(`{"buildVersion":"0.0.0-dev","buildTime":"","buildCommit":"","goVersion":"%s","schemaVersion":12,"schemaDirty":false}`+"\n",
+		runtime.Version()), rec.Body.String())
+}
+
+func setupMockContextRollback(steps string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, Migrations+Rollback+"?steps="+steps, nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	return
+}
+
+func TestRollbackMigrationsInvalidSteps(t *testing.T) {
+	newMockDb(t)
+
+	c, _ := setupMockContextRollback("none")
+	assertApiError(t, rollbackMigrations(c), http.StatusBadRequest, "invalid steps: none")
+
+	c, _ = setupMockContextRollback("0")
+	assertApiError(t, rollbackMigrations(c), http.StatusBadRequest, "invalid steps: 0")
+}
+
+func TestRollbackMigrationsTooManySteps(t *testing.T) {
+	c, _ := setupMockContextRollback("3")
+
+	mock := newMockDb(t)
+	mock.selectMigrationStatus = types.MigrationStatus{Version: 2}
+
+	assertApiError(t, rollbackMigrations(c), http.StatusBadRequest, "cannot roll back 3 steps from schema version 2")
+}
+
+func TestRollbackMigrationsDirty(t *testing.T) {
+	c, _ := setupMockContextRollback("1")
+
+	mock := newMockDb(t)
+	mock.selectMigrationStatus = types.MigrationStatus{Version: 12, Dirty: true}
+
+	assertApiError(t, rollbackMigrations(c), http.StatusConflict, "schema version 12 is dirty")
+}
+
+func TestRollbackMigrationsError(t *testing.T) {
+	c, _ := setupMockContextRollback("")
+	logger = zaptest.NewLogger(t)
+
+	mock := newMockDb(t)
+	mock.selectMigrationStatus = types.MigrationStatus{Version: 12}
+	mock.rollbackMigrationsSteps = 1
+	forcedError := fmt.Errorf("forced rollback error")
+	mock.rollbackMigrationsErr = forcedError
+
+	assert.EqualError(t, rollbackMigrations(c), forcedError.Error())
+}
+
+func TestRollbackMigrations(t *testing.T) {
+	c, rec := setupMockContextRollback("2")
+	logger = zaptest.NewLogger(t)
+
+	mock := newMockDb(t)
+	mock.selectMigrationStatus = types.MigrationStatus{Version: 12}
+	mock.rollbackMigrationsSteps = 2
+
+	assert.NoError(t, rollbackMigrations(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, "{\"version\":12,\"dirty\":false}\n", rec.Body.String())
+}
+
+const seedFixtureBody = `{
+	"campaigns": [{"name": "myCampaignName", "startOn": "2022-03-01T00:00:00Z", "endOn": "2022-03-31T00:00:00Z"}],
+	"bugs": [{"campaign": "myCampaignName", "category": "sql-injection", "pointValue": 5}],
+	"organizations": [{"scpName": "GitHub", "organization": "myOrg", "campaignName": "myCampaignName"}],
+	"teams": [{"campaignName": "myCampaignName", "name": "myTeamName"}],
+	"participants": [{"campaignName": "myCampaignName", "scpName": "GitHub", "loginName": "loginName", "teamName": "myTeamName"}]
+}`
+
+func TestSetupRoutesAdminSeed(t *testing.T) {
+	e := echo.New()
+
+	logger = zaptest.NewLogger(t)
+
+	cfg := config.Defaults()
+	cfg.AdminSeed = true
+
+	customRouteCount := setupRoutes(e, cfg, "myBuildInfoMsg")
+	assert.Equal(t, 442, len(e.Routes()))
+	assert.Equal(t, 133, customRouteCount)
+}
+
+func TestLoadSeedFixture(t *testing.T) {
+	memoryDB := newMemoryDb(t)
+	c, rec := setupMockContextWithBody(http.MethodPost, seedFixtureBody)
+
+	assert.NoError(t, loadSeedFixture(c))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	loaded := types.SeedFixture{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &loaded))
+	assert.Equal(t, 36, len(loaded.Participants[0].ID))
+
+	team, err := memoryDB.SelectTeamDetail(context.Background(), campaign, "myTeamName")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(team.Members))
+	assert.Equal(t, loginName, team.Members[0].LoginName)
+	bugs, err := memoryDB.SelectBugs(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(bugs))
+}
+
+func TestLoadSeedFixtureInvalid(t *testing.T) {
+	newMemoryDb(t)
+	c, _ := setupMockContextWithBody(http.MethodPost, `{"teams": [{"campaignName": "myCampaignName"}]}`)
+
+	assertApiError(t, loadSeedFixture(c), http.StatusUnprocessableEntity, "request is not valid")
+}
+
+func TestLoadSeedFixtureUnknownCampaign(t *testing.T) {
+	memoryDB := newMemoryDb(t)
+	memoryDB.Record()
+	c, _ := setupMockContextWithBody(http.MethodPost, `{"organizations": [{"scpName": "GitHub", "organization": "myOrg", "campaignName": "noSuchCampaign"}]}`)
+
+	assertApiError(t, loadSeedFixture(c), http.StatusNotFound, "no campaign: campaignName: noSuchCampaign")
+	assert.Equal(t, 0, len(memoryDB.Calls("InsertSeedFixture")))
+}
+
+func TestLoadSeedFixtureExistingCampaign(t *testing.T) {
+	memoryDB := newMemoryDb(t)
+	_, err := memoryDB.InsertCampaign(context.Background(), &types.CampaignStruct{Name: campaign, StartOn: now, EndOn: now.Add(time.Hour)})
+	assert.NoError(t, err)
+	c, rec := setupMockContextWithBody(http.MethodPost, `{"teams": [{"campaignName": "myCampaignName", "name": "myTeamName"}]}`)
+
+	assert.NoError(t, loadSeedFixture(c))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+}
+
+func TestLoadSeedFixtureNoTeam(t *testing.T) {
+	memoryDB := newMemoryDb(t)
+	c, _ := setupMockContextWithBody(http.MethodPost, strings.Replace(seedFixtureBody, `"teamName": "myTeamName"`, `"teamName": "noSuchTeam"`, 1))
+
+	assertApiError(t, loadSeedFixture(c), http.StatusNotFound, "no team: campaignName: myCampaignName, name: noSuchTeam: sql: no rows in result set")
+
+	// nothing of the fixture is kept
+	campaigns, err := memoryDB.GetCampaigns(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(campaigns))
+}
+
+func TestLoadSeedFixtureDuplicate(t *testing.T) {
+	newMemoryDb(t)
+	c, _ := setupMockContextWithBody(http.MethodPost, seedFixtureBody)
+	assert.NoError(t, loadSeedFixture(c))
+
+	c, _ = setupMockContextWithBody(http.MethodPost, seedFixtureBody)
+	assertApiError(t, loadSeedFixture(c), http.StatusConflict, "campaign already exists")
+}
+
+func TestGetCampaignConfigStageNotFound(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectConfigStageCampName = campaign
+	mock.selectConfigStageErr = sql.ErrNoRows
+
+	assertApiError(t, getCampaignConfigStage(c), http.StatusNotFound, "no staged config: campaignName: myCampaignName")
+}
+
+func TestGetCampaignConfigStage(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectConfigStageCampName = campaign
+	mock.selectConfigStageResult = &types.CampaignConfigStruct{
+		Campaign: campaign,
+		Bugs:     []types.BugStruct{{Campaign: campaign, Category: category, PointValue: 3}},
+	}
+
+	assert.NoError(t, getCampaignConfigStage(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	jsonExpected, err := json.Marshal(mock.selectConfigStageResult)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpected)+"\n", rec.Body.String())
+}
+
+func TestPutCampaignConfigStageDuplicateCategory(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign,
+		`{"bugs":[{"category":"`+category+`","pointValue":1},{"category":"`+category+`","pointValue":2}]}`)
+
+	newMockDb(t)
+
+	assertApiError(t, putCampaignConfigStage(c), http.StatusBadRequest, "campaign config is not valid, duplicate category: "+category)
+}
+
+func TestPutCampaignConfigStageInvalidBug(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, `{"bugs":[{"category":"`+category+`","pointValue":-1}]}`)
+
+	newMockDb(t)
+
+	err := putCampaignConfigStage(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{{Field: "bugs[0].pointValue", Message: "must be at least 0"}}, toApiError(err).Details)
+}
+
+func TestPutCampaignConfigStage(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign,
+		`{"campaign":"ignored","bugs":[{"category":"`+category+`","pointValue":5}]}`)
+
+	mock := newMockDb(t)
+	mock.upsertConfigStage = &types.CampaignConfigStruct{
+		Campaign: campaign,
+		Bugs:     []types.BugStruct{{Campaign: campaign, Category: category, PointValue: 5}},
+	}
+
+	assert.NoError(t, putCampaignConfigStage(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	jsonExpected, err := json.Marshal(mock.upsertConfigStage)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpected)+"\n", rec.Body.String())
+}
+
+func TestDeleteCampaignConfigStageNotFound(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.deleteConfigStageCampName = campaign
+
+	assertApiError(t, deleteCampaignConfigStage(c), http.StatusNotFound, "no staged config: campaignName: myCampaignName")
+}
+
+func TestDeleteCampaignConfigStage(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.deleteConfigStageCampName = campaign
+	mock.deleteConfigStageRowsAffected = 1
+
+	assert.NoError(t, deleteCampaignConfigStage(c))
+	assert.Equal(t, http.StatusNoContent, c.Response().Status)
+}
+
+func TestPromoteCampaignConfigStageError(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.promoteConfigStageCampName = campaign
+	forcedError := fmt.Errorf("forced promote error")
+	mock.promoteConfigStageErr = forcedError
+
+	assert.EqualError(t, promoteCampaignConfigStage(c), forcedError.Error())
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestPromoteCampaignConfigStage(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.promoteConfigStageCampName = campaign
+	mock.promoteConfigStageResult = &types.CampaignConfigStruct{
+		Campaign: campaign,
+		Bugs:     []types.BugStruct{{Id: "bugId", Campaign: campaign, Category: category, PointValue: 5}},
+	}
+
+	assert.NoError(t, promoteCampaignConfigStage(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	jsonExpected, err := json.Marshal(mock.promoteConfigStageResult)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpected)+"\n", rec.Body.String())
+}
+
+func setupMockContextTeamMember(campaignName, teamName, scpName, loginName string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest("", "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamCampaignName, ParamTeamName, ParamScpName, ParamLoginName)
+	c.SetParamValues(campaignName, teamName, scpName, loginName)
+	return
+}
+
+func TestRemovePersonFromTeamNotFound(t *testing.T) {
+	c, _ := setupMockContextTeamMember(campaign, teamName, scpName, loginName)
+
+	mock := newMockDb(t)
+	mock.removeFromTeamTeamName = teamName
+	mock.removeFromTeamCampName = campaign
+	mock.removeFromTeamScpName = scpName
+	mock.removeFromTeamLoginName = loginName
+
+	assertApiError(t, removePersonFromTeam(c), http.StatusNotFound, "no team member: campaignName: myCampaignName, teamName: myTeamName, scpName: myScpName, loginName: loginName")
+}
+
+func TestRemovePersonFromTeam(t *testing.T) {
+	c, _ := setupMockContextTeamMember(campaign, teamName, scpName, loginName)
+
+	mock := newMockDb(t)
+	mock.removeFromTeamTeamName = teamName
+	mock.removeFromTeamCampName = campaign
+	mock.removeFromTeamScpName = scpName
+	mock.removeFromTeamLoginName = loginName
+	mock.removeFromTeamRowsAffected = 1
+
+	assert.NoError(t, removePersonFromTeam(c))
+	assert.Equal(t, http.StatusNoContent, c.Response().Status)
+}
+
+func TestSetTeamCaptainError(t *testing.T) {
+	c, rec := setupMockContextTeamMember(campaign, teamName, scpName, loginName)
+
+	mock := newMockDb(t)
+	mock.updateTeamCaptainCampName = campaign
+	mock.updateTeamCaptainTeamName = teamName
+	mock.updateTeamCaptainScpName = scpName
+	mock.updateTeamCaptainLoginName = loginName
+	forcedError := fmt.Errorf("forced captain error")
+	mock.updateTeamCaptainErr = forcedError
+
+	assert.EqualError(t, setTeamCaptain(c), forcedError.Error())
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestSetTeamCaptainNotMember(t *testing.T) {
+	c, _ := setupMockContextTeamMember(campaign, teamName, scpName, loginName)
+
+	mock := newMockDb(t)
+	mock.updateTeamCaptainCampName = campaign
+	mock.updateTeamCaptainTeamName = teamName
+	mock.updateTeamCaptainScpName = scpName
+	mock.updateTeamCaptainLoginName = loginName
+
+	assertApiError(t, setTeamCaptain(c), http.StatusNotFound, "no team member: campaignName: myCampaignName, teamName: myTeamName, scpName: myScpName, loginName: loginName")
+}
+
+func TestSetTeamCaptain(t *testing.T) {
+	c, _ := setupMockContextTeamMember(campaign, teamName, scpName, loginName)
+
+	mock := newMockDb(t)
+	mock.updateTeamCaptainCampName = campaign
+	mock.updateTeamCaptainTeamName = teamName
+	mock.updateTeamCaptainScpName = scpName
+	mock.updateTeamCaptainLoginName = loginName
+	mock.updateTeamCaptainRowsAffected = 1
+
+	assert.NoError(t, setTeamCaptain(c))
+	assert.Equal(t, http.StatusNoContent, c.Response().Status)
+}
+
+func setupMockContextRenameTeam(campaignName, teamName, newTeamName string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest("", "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamCampaignName, ParamTeamName, ParamNewTeamName)
+	c.SetParamValues(campaignName, teamName, newTeamName)
+	return
+}
+
+func TestRenameTeamEmptyName(t *testing.T) {
+	c, _ := setupMockContextRenameTeam(campaign, teamName, " ")
+
+	newMockDb(t)
+
+	assertApiError(t, renameTeam(c), http.StatusBadRequest, "new team name is empty")
+}
+
+func TestRenameTeamNotFound(t *testing.T) {
+	c, _ := setupMockContextRenameTeam(campaign, teamName, "newTeamName")
+
+	mock := newMockDb(t)
+	mock.updateTeamNameCampName = campaign
+	mock.updateTeamNameTeamName = teamName
+	mock.updateTeamNameNewTeamName = "newTeamName"
+
+	assertApiError(t, renameTeam(c), http.StatusNotFound, "no team: campaignName: myCampaignName, name: myTeamName")
+}
+
+func TestRenameTeam(t *testing.T) {
+	c, _ := setupMockContextRenameTeam(campaign, teamName, "newTeamName")
+
+	mock := newMockDb(t)
+	mock.updateTeamNameCampName = campaign
+	mock.updateTeamNameTeamName = teamName
+	mock.updateTeamNameNewTeamName = "newTeamName"
+	mock.updateTeamNameRowsAffected = 1
+
+	assert.NoError(t, renameTeam(c))
+	assert.Equal(t, http.StatusNoContent, c.Response().Status)
+}
+
+type mockParticipantAuthenticator struct {
+	loginName string
+	err       error
+	tokens    []string
+}
+
+func (m *mockParticipantAuthenticator) Authenticate(scpName, token string) (string, error) {
+	m.tokens = append(m.tokens, scpName+"/"+token)
+	return m.loginName, m.err
+}
+
+func setupMockParticipantAuthenticator(t *testing.T) (authenticator *mockParticipantAuthenticator) {
+	origAuthenticator := participantAuthenticator
+	participantLogins.Lock()
+	participantLogins.byToken = map[string]participantLogin{}
+	participantLogins.Unlock()
+	t.Cleanup(func() {
+		participantAuthenticator = origAuthenticator
+	})
+	authenticator = &mockParticipantAuthenticator{loginName: loginName}
+	participantAuthenticator = authenticator
+	return
+}
+
+// signInParticipant sets the participant requireParticipant would have signed in
+func signInParticipant(c echo.Context, scpName, loginName string) {
+	c.Set(ctxParticipantScpName, scpName)
+	c.Set(ctxParticipantLoginName, loginName)
+}
+
+func setupMockContextParticipantToken(scpName, authorization string) (c echo.Context) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	if scpName != "" {
+		req.Header.Set(headerScpName, scpName)
+	}
+	if authorization != "" {
+		req.Header.Set(echo.HeaderAuthorization, authorization)
+	}
+	return e.NewContext(req, httptest.NewRecorder())
+}
+
+func TestRequireParticipantMissingToken(t *testing.T) {
+	authenticator := setupMockParticipantAuthenticator(t)
+
+	for _, c := range []echo.Context{
+		setupMockContextParticipantToken(scpName, ""),
+		setupMockContextParticipantToken("", "Bearer userToken"),
+		setupMockContextParticipantToken(scpName, "Basic dXNlcjpwYXNz"),
+	} {
+		assertApiError(t, requireParticipant(func(c echo.Context) error {
+			t.Fatal("handler called")
+			return nil
+		})(c), http.StatusUnauthorized, "missing X-BBash-Scp-Name header or bearer token")
+	}
+	assert.Nil(t, authenticator.tokens)
+}
+
+func TestRequireParticipantNoAuthenticator(t *testing.T) {
+	setupMockParticipantAuthenticator(t)
+	participantAuthenticator = nil
+	c := setupMockContextParticipantToken(scpName, "Bearer userToken")
+
+	assertApiError(t, requireParticipant(func(c echo.Context) error {
+		t.Fatal("handler called")
+		return nil
+	})(c), http.StatusUnauthorized, "participants can not sign in")
+}
+
+func TestRequireParticipantTokenRefused(t *testing.T) {
+	authenticator := setupMockParticipantAuthenticator(t)
+	authenticator.err = profile.ErrInvalidToken
+	c := setupMockContextParticipantToken(scpName, "Bearer userToken")
+
+	assertApiError(t, requireParticipant(func(c echo.Context) error {
+		t.Fatal("handler called")
+		return nil
+	})(c), http.StatusUnauthorized, "access token refused: scpName: "+scpName)
+}
+
+func TestRequireParticipantError(t *testing.T) {
+	authenticator := setupMockParticipantAuthenticator(t)
+	forcedError := fmt.Errorf("forced authenticate error")
+	authenticator.err = forcedError
+	c := setupMockContextParticipantToken(scpName, "Bearer userToken")
+
+	assert.EqualError(t, requireParticipant(func(c echo.Context) error {
+		t.Fatal("handler called")
+		return nil
+	})(c), forcedError.Error())
+}
+
+func TestRequireParticipant(t *testing.T) {
+	authenticator := setupMockParticipantAuthenticator(t)
+
+	for i := 0; i < 2; i++ {
+		c := setupMockContextParticipantToken(scpName, "Bearer userToken")
+		handlerCalled := false
+		assert.NoError(t, requireParticipant(func(c echo.Context) error {
+			handlerCalled = true
+			signedInScp, signedInLogin := participantOf(c)
+			assert.Equal(t, scpName, signedInScp)
+			assert.Equal(t, loginName, signedInLogin)
+			return nil
+		})(c))
+		assert.True(t, handlerCalled)
+	}
+	// the second request is signed in from the cache
+	assert.Equal(t, []string{scpName + "/userToken"}, authenticator.tokens)
+}
+
+func TestAuthenticateParticipantExpired(t *testing.T) {
+	authenticator := setupMockParticipantAuthenticator(t)
+
+	_, err := authenticateParticipant(scpName, "userToken", now)
+	assert.NoError(t, err)
+	authenticator.loginName = "renamedLogin"
+	signedInLogin, err := authenticateParticipant(scpName, "userToken", now.Add(participantTokenTtl))
+	assert.NoError(t, err)
+	assert.Equal(t, "renamedLogin", signedInLogin)
+	assert.Equal(t, 2, len(authenticator.tokens))
+}
+
+func TestRequirePathParticipantNotSignedIn(t *testing.T) {
+	c, _ := setupMockContextTeamMember(campaign, teamName, scpName, loginName)
+
+	assertApiError(t, requirePathParticipant(func(c echo.Context) error {
+		t.Fatal("handler called")
+		return nil
+	})(c), http.StatusUnauthorized, "participant not signed in")
+}
+
+func TestRequirePathParticipantOther(t *testing.T) {
+	c, _ := setupMockContextTeamMember(campaign, teamName, scpName, loginName)
+	signInParticipant(c, scpName, "someoneElse")
+
+	assertApiError(t, requirePathParticipant(func(c echo.Context) error {
+		t.Fatal("handler called")
+		return nil
+	})(c), http.StatusForbidden, "not the participant: scpName: myScpName, loginName: loginName")
+}
+
+func TestRequirePathParticipant(t *testing.T) {
+	c, _ := setupMockContextTeamMember(campaign, teamName, scpName, loginName)
+	// logins are not case sensitive
+	signInParticipant(c, scpName, strings.ToUpper(loginName))
+
+	handlerCalled := false
+	assert.NoError(t, requirePathParticipant(func(c echo.Context) error {
+		handlerCalled = true
+		return nil
+	})(c))
+	assert.True(t, handlerCalled)
+}
+
+func TestRequireTeamCaptainNotSignedIn(t *testing.T) {
+	c, _ := setupMockContextTeamMember(campaign, teamName, scpName, loginName)
+
+	newMockDb(t)
+
+	handlerCalled := false
+	assertApiError(t, requireTeamCaptain(func(c echo.Context) error {
+		handlerCalled = true
+		return nil
+	})(c), http.StatusUnauthorized, "participant not signed in")
+	assert.False(t, handlerCalled)
+}
+
+func TestRequireTeamCaptainNotCaptain(t *testing.T) {
+	c, _ := setupMockContextTeamMember(campaign, teamName, scpName, loginName)
+	signInParticipant(c, scpName, "someoneElse")
+
+	mock := newMockDb(t)
+	mock.isTeamCaptainCampName = campaign
+	mock.isTeamCaptainTeamName = teamName
+	mock.isTeamCaptainScpName = scpName
+	mock.isTeamCaptainLoginName = "someoneElse"
+
+	handlerCalled := false
+	assertApiError(t, requireTeamCaptain(func(c echo.Context) error {
+		handlerCalled = true
+		return nil
+	})(c), http.StatusForbidden, "not the team captain: campaignName: myCampaignName, teamName: myTeamName, scpName: myScpName, loginName: someoneElse")
+	assert.False(t, handlerCalled)
+}
+
+func TestRequireTeamCaptain(t *testing.T) {
+	c, _ := setupMockContextTeamMember(campaign, teamName, scpName, loginName)
+	signInParticipant(c, scpName, loginName)
+
+	mock := newMockDb(t)
+	mock.isTeamCaptainCampName = campaign
+	mock.isTeamCaptainTeamName = teamName
+	mock.isTeamCaptainScpName = scpName
+	mock.isTeamCaptainLoginName = loginName
+	mock.isTeamCaptainResult = true
+
+	handlerCalled := false
+	assert.NoError(t, requireTeamCaptain(func(c echo.Context) error {
+		handlerCalled = true
+		return nil
+	})(c))
+	assert.True(t, handlerCalled)
+}
+
+func TestRequireJoinableParticipantError(t *testing.T) {
+	c, _ := setupMockContextTeamMember(campaign, teamName, scpName, loginName)
+
+	mock := newMockDb(t)
+	mock.selectPartDetailCampName = campaign
+	mock.selectPartDetailSCPName = scpName
+	mock.selectPartDetailLoginName = loginName
+	forcedError := fmt.Errorf("forced participant select error")
+	mock.selectPartDetailErr = forcedError
+
+	handlerCalled := false
+	assert.EqualError(t, requireJoinableParticipant(func(c echo.Context) error {
+		handlerCalled = true
+		return nil
+	})(c), forcedError.Error())
+	assert.False(t, handlerCalled)
+}
+
+func TestRequireJoinableParticipantUnknown(t *testing.T) {
+	c, _ := setupMockContextTeamMember(campaign, teamName, scpName, loginName)
+
+	mock := newMockDb(t)
+	mock.selectPartDetailCampName = campaign
+	mock.selectPartDetailSCPName = scpName
+	mock.selectPartDetailLoginName = loginName
+	mock.selectPartDetailErr = sql.ErrNoRows
+
+	handlerCalled := false
+	assert.NoError(t, requireJoinableParticipant(func(c echo.Context) error {
+		handlerCalled = true
+		return nil
+	})(c))
+	assert.True(t, handlerCalled)
+}
+
+func TestRequireJoinableParticipantNoTeam(t *testing.T) {
+	c, _ := setupMockContextTeamMember(campaign, teamName, scpName, loginName)
+
+	mock := newMockDb(t)
+	mock.selectPartDetailCampName = campaign
+	mock.selectPartDetailSCPName = scpName
+	mock.selectPartDetailLoginName = loginName
+	mock.selectPartDetailResult = &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+
+	handlerCalled := false
+	assert.NoError(t, requireJoinableParticipant(func(c echo.Context) error {
+		handlerCalled = true
+		return nil
+	})(c))
+	assert.True(t, handlerCalled)
+}
+
+func TestRequireJoinableParticipantSameTeam(t *testing.T) {
+	// a captain adding themselves to their own team again
+	c, _ := setupMockContextTeamMember(campaign, teamName, scpName, loginName)
+	signInParticipant(c, scpName, loginName)
+
+	mock := newMockDb(t)
+	mock.selectPartDetailCampName = campaign
+	mock.selectPartDetailSCPName = scpName
+	mock.selectPartDetailLoginName = loginName
+	mock.selectPartDetailResult = &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName, TeamName: teamName}
+
+	handlerCalled := false
+	assert.NoError(t, requireJoinableParticipant(func(c echo.Context) error {
+		handlerCalled = true
+		return nil
+	})(c))
+	assert.True(t, handlerCalled)
+}
+
+func TestRequireJoinableParticipantOtherTeam(t *testing.T) {
+	// a member, or the captain, of another team can not be taken onto this one
+	c, _ := setupMockContextTeamMember(campaign, teamName, scpName, loginName)
+	signInParticipant(c, scpName, "someCaptain")
+
+	mock := newMockDb(t)
+	mock.selectPartDetailCampName = campaign
+	mock.selectPartDetailSCPName = scpName
+	mock.selectPartDetailLoginName = loginName
+	mock.selectPartDetailResult = &types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: loginName, TeamName: "otherTeam"}
+
+	handlerCalled := false
+	assertApiError(t, requireJoinableParticipant(func(c echo.Context) error {
+		handlerCalled = true
+		return nil
+	})(c), http.StatusForbidden, "participant is on another team: campaignName: myCampaignName, teamName: otherTeam, scpName: myScpName, loginName: loginName")
+	assert.False(t, handlerCalled)
+}
+
+func setupMockContextScoringEvent(pullRequest string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamCampaignName, ParamScpName, ParamRepoOwner, ParamRepoName, ParamPullRequest)
+	c.SetParamValues(campaign, scpName, "repoOwner", "repoName", pullRequest)
+	return
+}
+
+func TestGetScoringEventBadPullRequest(t *testing.T) {
+	c, _ := setupMockContextScoringEvent("notANumber")
+
+	assertApiError(t, getScoringEvent(c), http.StatusBadRequest, `strconv.Atoi: parsing "notANumber": invalid syntax`)
+}
+
+func TestGetScoringEventNotFound(t *testing.T) {
+	c, _ := setupMockContextScoringEvent("5")
+
+	mock := newMockDb(t)
+	mock.selectScoreEvtCampName = campaign
+	mock.selectScoreEvtScpName = scpName
+	mock.selectScoreEvtRepoOwner = "repoOwner"
+	mock.selectScoreEvtRepoName = "repoName"
+	mock.selectScoreEvtPullRequest = 5
+	mock.selectScoreEvtErr = sql.ErrNoRows
+
+	assertApiError(t, getScoringEvent(c), http.StatusNotFound, "no scoring event: campaignName: myCampaignName, scpName: myScpName, repoOwner: repoOwner, repoName: repoName, pullRequest: 5")
+}
+
+func TestGetScoringEvent(t *testing.T) {
+	c, rec := setupMockContextScoringEvent("5")
+
+	mock := newMockDb(t)
+	mock.selectScoreEvtCampName = campaign
+	mock.selectScoreEvtScpName = scpName
+	mock.selectScoreEvtRepoOwner = "repoOwner"
+	mock.selectScoreEvtRepoName = "repoName"
+	mock.selectScoreEvtPullRequest = 5
+	mock.selectScoreEvtResult = &types.ScoringEventStruct{
+		CampaignName: campaign,
+		PullRequest:  5,
+		Points:       4,
+		Breakdown: &types.ScoreBreakdown{
+			Categories:  []types.CategoryScore{{Category: "myBugType", Count: 2, PointValue: 2, Points: 4}},
+			Classified:  2,
+			BasePoints:  4,
+			Points:      4,
+			PriorPoints: 1,
+			Delta:       3,
+		},
+	}
+
+	assert.NoError(t, getScoringEvent(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	jsonExpected, err := json.Marshal(mock.selectScoreEvtResult)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpected)+"\n", rec.Body.String())
+}
+
+const scoreEventId = "myScoreEventId"
+
+func setupMockContextVoidScoringEvent() (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamScoreEventId)
+	c.SetParamValues(scoreEventId)
+	return
+}
+
+func TestVoidScoringEventNotFound(t *testing.T) {
+	c, _ := setupMockContextVoidScoringEvent()
+
+	mock := newMockDb(t)
+	mock.voidScoreEvtId = scoreEventId
+	mock.voidScoreEvtErr = sql.ErrNoRows
+
+	assertApiError(t, voidScoringEvent(c), http.StatusNotFound, "no scoring event to void: scoreEventId: myScoreEventId")
+}
+
+func TestVoidScoringEventError(t *testing.T) {
+	c, _ := setupMockContextVoidScoringEvent()
+
+	mock := newMockDb(t)
+	mock.voidScoreEvtId = scoreEventId
+	forcedError := fmt.Errorf("forced void error")
+	mock.voidScoreEvtErr = forcedError
+
+	assert.EqualError(t, voidScoringEvent(c), forcedError.Error())
+}
+
+func TestVoidScoringEvent(t *testing.T) {
+	c, rec := setupMockContextVoidScoringEvent()
+
+	mock := newMockDb(t)
+	mock.voidScoreEvtId = scoreEventId
+	voidedOn := now
+	mock.voidScoreEvtResult = &types.ScoringEventStruct{
+		ID:           scoreEventId,
+		CampaignName: campaign,
+		ScpName:      scpName,
+		PullRequest:  5,
+		UserName:     loginName,
+		Points:       4,
+		VoidedOn:     &voidedOn,
+	}
+	mock.voidScoreEvtTeamName = teamName
+
+	deltas := scoreHub.Subscribe(campaign)
+	defer scoreHub.Unsubscribe(campaign, deltas)
+
+	assert.NoError(t, voidScoringEvent(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	jsonExpected, err := json.Marshal(mock.voidScoreEvtResult)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpected)+"\n", rec.Body.String())
+	assert.Equal(t, types.ScoreDelta{CampaignName: campaign, ScpName: scpName, LoginName: loginName, TeamName: teamName, Delta: -4}, <-deltas)
+}
+
+func TestVoidScoringEventCoAuthors(t *testing.T) {
+	c, _ := setupMockContextVoidScoringEvent()
+
+	mock := newMockDb(t)
+	mock.voidScoreEvtId = scoreEventId
+	mock.voidScoreEvtResult = &types.ScoringEventStruct{
+		ID:           scoreEventId,
+		CampaignName: campaign,
+		ScpName:      scpName,
+		UserName:     loginName,
+		Points:       2,
+		CoAuthors:    []types.CoAuthorScore{{LoginName: "myPair", TeamName: "otherTeamName", Points: 2}},
+	}
+
+	deltas := scoreHub.Subscribe(campaign)
+	defer scoreHub.Unsubscribe(campaign, deltas)
+
+	assert.NoError(t, voidScoringEvent(c))
+	assert.Equal(t, types.ScoreDelta{CampaignName: campaign, ScpName: scpName, LoginName: loginName, Delta: -2}, <-deltas)
+	assert.Equal(t, types.ScoreDelta{CampaignName: campaign, ScpName: scpName, LoginName: "myPair", TeamName: "otherTeamName", Delta: -2}, <-deltas)
+}
+
+// scoredEvent is an event of the participant in the repository, scored minutes after now
+func scoredEvent(id, loginName, repoName string, minutes int, breakdown *types.ScoreBreakdown) db.ScoredEvent {
+	return db.ScoredEvent{
+		ScoringEventStruct: types.ScoringEventStruct{
+			ID:           id,
+			CampaignName: campaign,
+			ScpName:      scpName,
+			RepoOwner:    "myRepoOwner",
+			RepoName:     repoName,
+			PullRequest:  minutes,
+			UserName:     loginName,
+			Points:       1,
+			Breakdown:    breakdown,
+		},
+		ScoredOn: now.Add(time.Duration(minutes) * time.Minute),
+	}
+}
+
+func TestDetectAnomaliesNone(t *testing.T) {
+	scored := []db.ScoredEvent{
+		scoredEvent("event1", loginName, "repo1", 0, nil),
+		scoredEvent("event2", loginName, "repo2", 1, &types.ScoreBreakdown{}),
+	}
+	assert.Nil(t, detectAnomalies(scored, now))
+	assert.Nil(t, detectAnomalies(nil, now))
+}
+
+func TestDetectAnomaliesBurst(t *testing.T) {
+	var scored []db.ScoredEvent
+	// one more than a burst, though the first is too long before the others
+	scored = append(scored, scoredEvent("early", loginName, "myRepoName", 0, nil))
+	for i := 0; i < anomalyBurstEvents; i++ {
+		scored = append(scored, scoredEvent(fmt.Sprintf("event%d", i), loginName, "myRepoName", 20+i/4, nil))
+	}
+	// another participant scoring at the same time is no part of the burst
+	scored = append(scored, scoredEvent("other", "otherLogin", "myRepoName", 21, nil))
+
+	flags := detectAnomalies(scored, now)
+	assert.Equal(t, anomalyBurstEvents, len(flags))
+	for i, flag := range flags {
+		assert.Equal(t, types.ScoringFlag{Reason: types.ScoringFlagBurst, FlaggedOn: now, Event: scored[i+1].ScoringEventStruct}, flag)
+	}
+
+	// one short of a burst
+	assert.Nil(t, detectAnomalies(scored[:anomalyBurstEvents], now))
+}
+
+func TestDetectAnomaliesIdentical(t *testing.T) {
+	fixes := func(counts ...float64) *types.ScoreBreakdown {
+		breakdown := &types.ScoreBreakdown{}
+		for i, count := range counts {
+			breakdown.Categories = append(breakdown.Categories, types.CategoryScore{Category: fmt.Sprintf("category%d", i), Count: count})
+		}
+		return breakdown
+	}
+	scored := []db.ScoredEvent{
+		scoredEvent("event1", loginName, "repo1", 0, fixes(2, 1)),
+		scoredEvent("event2", loginName, "Repo2", 1, fixes(2, 1)),
+		// the same repository again counts once
+		scoredEvent("event3", loginName, "repo2", 2, fixes(2, 1)),
+		scoredEvent("event4", loginName, "repo3", 3, fixes(2, 2)),
+		scoredEvent("event5", "otherLogin", "repo3", 4, fixes(2, 1)),
+	}
+	assert.Nil(t, detectAnomalies(scored, now))
+
+	scored = append(scored, scoredEvent("event6", loginName, "repo4", 5, fixes(2, 1)))
+	assert.Equal(t, []types.ScoringFlag{
+		{Reason: types.ScoringFlagIdentical, FlaggedOn: now, Event: scored[0].ScoringEventStruct},
+		{Reason: types.ScoringFlagIdentical, FlaggedOn: now, Event: scored[1].ScoringEventStruct},
+		{Reason: types.ScoringFlagIdentical, FlaggedOn: now, Event: scored[2].ScoringEventStruct},
+		{Reason: types.ScoringFlagIdentical, FlaggedOn: now, Event: scored[5].ScoringEventStruct},
+	}, detectAnomalies(scored, now))
+}
+
+func TestFixedBugsSignature(t *testing.T) {
+	assert.Equal(t, "", fixedBugsSignature(nil))
+	assert.Equal(t, "", fixedBugsSignature(&types.ScoreBreakdown{}))
+	assert.Equal(t, "a=1,b=2.5", fixedBugsSignature(&types.ScoreBreakdown{Categories: []types.CategoryScore{
+		{Category: "b", Count: 2.5, Points: 10},
+		{Category: "a", Count: 1, Points: 3},
+	}}))
+}
+
+func TestFlagAnomaliesSelectError(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectScoredEventsSince = now.Add(-anomalyLookback)
+	mock.selectScoredEventsErr = fmt.Errorf("forced scored events error")
+
+	flagAnomalies(now)
+}
+
+func TestFlagAnomalies(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectScoredEventsSince = now.Add(-anomalyLookback)
+	for i := 0; i < anomalyBurstEvents; i++ {
+		mock.selectScoredEventsResult = append(mock.selectScoredEventsResult, scoredEvent(fmt.Sprintf("event%d", i), loginName, "myRepoName", 0, nil))
+	}
+	mock.insertFlagsFlags = detectAnomalies(mock.selectScoredEventsResult, now)
+	mock.insertFlagsFlagged = int64(anomalyBurstEvents)
+
+	flagAnomalies(now)
+}
+
+const scoringFlagId = "myScoringFlagId"
+
+func setupMockContextScoringFlag() (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPut, "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamScoringFlagId)
+	c.SetParamValues(scoringFlagId)
+	return
+}
+
+func newScoringFlag() *types.ScoringFlag {
+	return &types.ScoringFlag{
+		ID:        scoringFlagId,
+		Reason:    types.ScoringFlagBurst,
+		FlaggedOn: now,
+		Event: types.ScoringEventStruct{
+			ID:           scoreEventId,
+			CampaignName: campaign,
+			ScpName:      scpName,
+			PullRequest:  5,
+			UserName:     loginName,
+			Points:       4,
+		},
+	}
+}
+
+func setupMockContextExport(campaignName string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/?"+ParamCampaignName+"="+campaignName, nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	return
+}
+
+func TestExportScoringEventsMissingCampaign(t *testing.T) {
+	c, _ := setupMockContextExport("")
+	newMockDb(t)
+
+	assertApiError(t, exportScoringEvents(c), http.StatusBadRequest, "missing campaignName")
+}
+
+func TestExportScoringEventsNoCampaign(t *testing.T) {
+	c, rec := setupMockContextExport(campaign)
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignErr = sql.ErrNoRows
+
+	assertApiError(t, exportScoringEvents(c), http.StatusNotFound, "no campaign: campaignName: "+campaign)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestExportScoringEvents(t *testing.T) {
+	c, rec := setupMockContextExport(campaign)
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{Name: campaign}
+	mock.exportScoreEvtCampName = campaign
+	scoredOn := now.UTC()
+	for i := 0; i < exportFlushEvents+1; i++ {
+		mock.exportScoreEvtResult = append(mock.exportScoreEvtResult, types.ScoringEventStruct{
+			ID: fmt.Sprintf("event%d", i), CampaignName: campaign, ScpName: scpName, RepoOwner: "myOrg", RepoName: "myRepo",
+			PullRequest: i, UserName: loginName, Points: 2, ScoredOn: &scoredOn,
+		})
+	}
+
+	assert.NoError(t, exportScoringEvents(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, mimeNdjson, rec.Header().Get(echo.HeaderContentType))
+	assert.True(t, rec.Flushed)
+	lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+	assert.Equal(t, exportFlushEvents+1, len(lines))
+	for i, line := range lines {
+		var event types.ScoringEventStruct
+		assert.NoError(t, json.Unmarshal([]byte(line), &event))
+		assert.Equal(t, mock.exportScoreEvtResult[i], event)
+	}
+}
+
+func TestExportScoringEventsNone(t *testing.T) {
+	c, rec := setupMockContextExport(campaign)
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{Name: campaign}
+	mock.exportScoreEvtCampName = campaign
+
+	assert.NoError(t, exportScoringEvents(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestExportScoringEventsErrorPartWay(t *testing.T) {
+	c, rec := setupMockContextExport(campaign)
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{Name: campaign}
+	mock.exportScoreEvtCampName = campaign
+	mock.exportScoreEvtResult = []types.ScoringEventStruct{{ID: "event0", CampaignName: campaign, Points: 2}}
+	forcedError := fmt.Errorf("forced export error")
+	mock.exportScoreEvtErr = forcedError
+
+	assert.EqualError(t, exportScoringEvents(c), forcedError.Error())
+	// the status went out with the first event, so the export just ends
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, 1, strings.Count(rec.Body.String(), "\n"))
+}
+
+func setupMockContextImport(campaignName, body string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/?"+ParamCampaignName+"="+campaignName, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, mimeNdjson)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	return
+}
+
+func setupMockImport(t *testing.T) (mock *MockBBashDB) {
+	mock = newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{Name: campaign}
+	mock.getSCPPs = []types.SourceControlProviderStruct{{SCPName: scpName}}
+	return
+}
+
+func TestImportScoringEventsMissingCampaign(t *testing.T) {
+	c, _ := setupMockContextImport("", "")
+	newMockDb(t)
+
+	assertApiError(t, importScoringEvents(c), http.StatusBadRequest, "missing campaignName")
+}
+
+func TestImportScoringEventsNoCampaign(t *testing.T) {
+	c, _ := setupMockContextImport(campaign, "")
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignErr = sql.ErrNoRows
+
+	assertApiError(t, importScoringEvents(c), http.StatusNotFound, "no campaign: campaignName: "+campaign)
+}
+
+func TestImportScoringEventsNone(t *testing.T) {
+	c, _ := setupMockContextImport(campaign, "\n  \n")
+	setupMockImport(t)
+
+	assertApiError(t, importScoringEvents(c), http.StatusBadRequest, "no scoring events")
+}
+
+func TestImportScoringEventsNotValid(t *testing.T) {
+	c, rec := setupMockContextImport(campaign, fmt.Sprintf(`{"scpName": "%[1]s", "repositoryOwner": "myOrg", "repositoryName": "myRepo", "pullRequestId": 1, "username": "%[2]s"}
+not json
+{"campaignName": "otherCampaign", "scpName": "otherScp", "repositoryOwner": "", "pullRequestId": 0}
+
+{"scpName": "%[1]s", "repositoryOwner": "myOrg", "repositoryName": "myRepo", "pullRequestId": 1, "username": "%[2]s", `+
+		`"scoredOn": "2022-01-02T00:00:00Z", "voidedOn": "2022-01-01T00:00:00Z"}
+`, scpName, loginName))
+	setupMockImport(t)
+
+	err := importScoringEvents(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "scoring events are not valid")
+	details := toApiError(err).Details.([]fieldError)
+	assert.Equal(t, "[2]", details[0].Field)
+	assert.True(t, strings.HasPrefix(details[0].Message, "must be a scoring event: "), details[0].Message)
+	assert.Equal(t, []fieldError{
+		{Field: "[3].campaignName", Message: "must be " + campaign},
+		{Field: "[3].scpName", Message: "must be a source control provider"},
+		{Field: "[3].repositoryOwner", Message: "is required"},
+		{Field: "[3].repositoryName", Message: "is required"},
+		{Field: "[3].pullRequestId", Message: "must be at least 1"},
+		{Field: "[3].username", Message: "is required"},
+		{Field: "[5].voidedOn", Message: "must be after scoredOn"},
+		{Field: "[5].pullRequestId", Message: "repeats the pull request of line 1"},
+	}, details[1:])
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestImportScoringEventsLineTooLong(t *testing.T) {
+	c, _ := setupMockContextImport(campaign, "\n"+strings.Repeat(" ", importMaxLineBytes+1))
+	setupMockImport(t)
+
+	assertApiError(t, importScoringEvents(c), http.StatusBadRequest, fmt.Sprintf("line 2 is longer than %d bytes", importMaxLineBytes))
+}
+
+const jobId = "myJobId"
+
+// setupMockJob expects a job of the kind to start, returning the job as it will be once finished
+func setupMockJob(mock *MockBBashDB, kind string) (finished *types.Job) {
+	mock.insertJobKind = kind
+	mock.insertJobId = jobId
+	mock.finishedJob = &types.Job{}
+	return mock.finishedJob
+}
+
+// assertJobAccepted checks the job was answered with 202, then waits for it to finish
+func assertJobAccepted(t *testing.T, c echo.Context, rec *httptest.ResponseRecorder, kind string) {
+	assert.Equal(t, http.StatusAccepted, c.Response().Status)
+	assert.Equal(t, pathAdmin+Jobs+"/"+jobId, rec.Header().Get(echo.HeaderLocation))
+	var job types.Job
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &job))
+	assert.Equal(t, jobId, job.ID)
+	assert.Equal(t, kind, job.Kind)
+	assert.Equal(t, types.JobStatusRunning, job.Status)
+	assert.Nil(t, job.FinishedOn)
+
+	asyncJobs.Wait()
+}
+
+func TestImportScoringEvents(t *testing.T) {
+	scoredOn := now.UTC()
+	voidedOn := scoredOn.Add(time.Hour)
+	exported := []types.ScoringEventStruct{
+		{ID: "event0", CampaignName: campaign, ScpName: scpName, RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 1,
+			UserName: loginName, Points: 2, Breakdown: &types.ScoreBreakdown{Classified: 2}, ScoredOn: &scoredOn},
+		{ID: "event1", ScpName: scpName, RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 2, UserName: loginName,
+			Points: 3, VoidedOn: &voidedOn, ScoredOn: &scoredOn, CoAuthors: []types.CoAuthorScore{{LoginName: "other", Points: 1}}},
+	}
+	var body strings.Builder
+	encoder := json.NewEncoder(&body)
+	for _, event := range exported {
+		assert.NoError(t, encoder.Encode(event))
+	}
+	c, rec := setupMockContextImport(campaign, body.String())
+	mock := setupMockImport(t)
+	mock.importScoreEvtEvents = []types.ScoringEventStruct{
+		{CampaignName: campaign, ScpName: scpName, RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 1,
+			UserName: loginName, Points: 2, Breakdown: &types.ScoreBreakdown{Classified: 2}, ScoredOn: &scoredOn},
+		{CampaignName: campaign, ScpName: scpName, RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 2, UserName: loginName,
+			Points: 3, VoidedOn: &voidedOn, ScoredOn: &scoredOn},
+	}
+	mock.importScoreEvtImported = 1
+	mock.importScoreEvtRecomputed = 1
+	finished := setupMockJob(mock, jobKindScoringEventImport)
+
+	assert.NoError(t, importScoringEvents(c))
+	assertJobAccepted(t, c, rec, jobKindScoringEventImport)
+	assert.Equal(t, types.JobStatusSucceeded, finished.Status)
+	assert.Equal(t, int64(2), finished.Done)
+	assert.Equal(t, int64(2), finished.Total)
+	assert.NotNil(t, finished.FinishedOn)
+	var result types.ScoringEventImport
+	assert.NoError(t, json.Unmarshal(finished.Result, &result))
+	assert.Equal(t, types.ScoringEventImport{CampaignName: campaign, Read: 2, Imported: 1, Skipped: 1, Recomputed: 1}, result)
+}
+
+func TestImportScoringEventsError(t *testing.T) {
+	c, rec := setupMockContextImport(campaign, fmt.Sprintf(`{"scpName": "%s", "repositoryOwner": "myOrg", "repositoryName": "myRepo", "pullRequestId": 1, "username": "%s"}`,
+		scpName, loginName))
+	mock := setupMockImport(t)
+	mock.importScoreEvtEvents = []types.ScoringEventStruct{
+		{CampaignName: campaign, ScpName: scpName, RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 1, UserName: loginName},
+	}
+	mock.importScoreEvtErr = fmt.Errorf("forced import error")
+	finished := setupMockJob(mock, jobKindScoringEventImport)
+
+	assert.NoError(t, importScoringEvents(c))
+	assertJobAccepted(t, c, rec, jobKindScoringEventImport)
+	assert.Equal(t, types.JobStatusFailed, finished.Status)
+	assert.Equal(t, "internal server error", finished.Error)
+	assert.Nil(t, finished.Result)
+	assert.NotNil(t, finished.FinishedOn)
+}
+
+func TestImportScoringEventsArchived(t *testing.T) {
+	c, _ := setupMockContextImport(campaign, fmt.Sprintf(`{"scpName": "%s", "repositoryOwner": "myOrg", "repositoryName": "myRepo", "pullRequestId": 1, "username": "%s"}`,
+		scpName, loginName))
+	mock := setupMockImport(t)
+	mock.archives = []types.ScoringEventArchive{{CampaignName: campaign, EventCount: 2, ArchivedOn: now}}
+
+	assertApiError(t, importScoringEvents(c), http.StatusConflict, "scoring events archived, restore them first: campaignName: "+campaign)
+}
+
+func TestImportScoringEventsArchivedRestored(t *testing.T) {
+	c, rec := setupMockContextImport(campaign, fmt.Sprintf(`{"scpName": "%s", "repositoryOwner": "myOrg", "repositoryName": "myRepo", "pullRequestId": 1, "username": "%s"}`,
+		scpName, loginName))
+	mock := setupMockImport(t)
+	mock.archives = []types.ScoringEventArchive{{CampaignName: campaign, EventCount: 2, ArchivedOn: now, RestoredOn: &now}}
+	mock.importScoreEvtEvents = []types.ScoringEventStruct{
+		{CampaignName: campaign, ScpName: scpName, RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 1, UserName: loginName},
+	}
+	mock.importScoreEvtImported = 1
+	finished := setupMockJob(mock, jobKindScoringEventImport)
+
+	assert.NoError(t, importScoringEvents(c))
+	assertJobAccepted(t, c, rec, jobKindScoringEventImport)
+	assert.Equal(t, types.JobStatusSucceeded, finished.Status)
+}
+
+func TestImportScoringEventsArchivedMeanwhile(t *testing.T) {
+	c, rec := setupMockContextImport(campaign, fmt.Sprintf(`{"scpName": "%s", "repositoryOwner": "myOrg", "repositoryName": "myRepo", "pullRequestId": 1, "username": "%s"}`,
+		scpName, loginName))
+	mock := setupMockImport(t)
+	mock.importScoreEvtEvents = []types.ScoringEventStruct{
+		{CampaignName: campaign, ScpName: scpName, RepoOwner: "myOrg", RepoName: "myRepo", PullRequest: 1, UserName: loginName},
+	}
+	mock.importScoreEvtErr = db.ErrCampaignArchived
+	finished := setupMockJob(mock, jobKindScoringEventImport)
+
+	assert.NoError(t, importScoringEvents(c))
+	assertJobAccepted(t, c, rec, jobKindScoringEventImport)
+	assert.Equal(t, types.JobStatusFailed, finished.Status)
+	assert.Equal(t, "scoring events archived, restore them first: campaignName: "+campaign, finished.Error)
+}
+
+func TestImportScoringEventsInsertJobError(t *testing.T) {
+	c, rec := setupMockContextImport(campaign, fmt.Sprintf(`{"scpName": "%s", "repositoryOwner": "myOrg", "repositoryName": "myRepo", "pullRequestId": 1, "username": "%s"}`,
+		scpName, loginName))
+	mock := setupMockImport(t)
+	setupMockJob(mock, jobKindScoringEventImport)
+	forcedError := fmt.Errorf("forced insert job error")
+	mock.insertJobErr = forcedError
+
+	assert.EqualError(t, importScoringEvents(c), forcedError.Error())
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestArchiveCampaignsSelectError(t *testing.T) {
+	mock := newMockDb(t)
+	mock.archiveSelectEndedBefore = now.Add(-24 * time.Hour)
+	mock.archiveSelectErr = fmt.Errorf("forced select archive error")
+
+	archiveCampaigns(now, 24*time.Hour)
+}
+
+func TestArchiveCampaigns(t *testing.T) {
+	mock := newMockDb(t)
+	mock.archiveSelectEndedBefore = now.Add(-24 * time.Hour)
+	mock.archiveSelectNames = []string{campaign, "otherCampaign"}
+	mock.archiveCampaignNames = mock.archiveSelectNames
+	mock.archiveResult = &types.ScoringEventArchive{CampaignName: campaign, EventCount: 2, ArchivedOn: now}
+
+	archiveCampaigns(now, 24*time.Hour)
+}
+
+func TestArchiveCampaignsAlreadyArchived(t *testing.T) {
+	mock := newMockDb(t)
+	mock.archiveSelectEndedBefore = now.Add(-24 * time.Hour)
+	mock.archiveSelectNames = []string{campaign}
+	mock.archiveCampaignNames = mock.archiveSelectNames
+	mock.archiveErr = sql.ErrNoRows
+
+	archiveCampaigns(now, 24*time.Hour)
+}
+
+func setupMockContextArchive(campaignName string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/?"+ParamCampaignName+"="+campaignName, nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	return
+}
+
+func TestGetScoringEventArchivesNone(t *testing.T) {
+	c, rec := setupMockContextArchive("")
+	newMockDb(t)
+
+	assert.NoError(t, getScoringEventArchives(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, "[]\n", rec.Body.String())
+}
+
+func TestGetScoringEventArchives(t *testing.T) {
+	c, rec := setupMockContextArchive("")
+	mock := newMockDb(t)
+	restoredOn := now.UTC()
+	mock.archives = []types.ScoringEventArchive{
+		{CampaignName: campaign, EventCount: 2, ArchivedOn: now.UTC(), RestoredOn: &restoredOn},
+	}
+
+	assert.NoError(t, getScoringEventArchives(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var archives []types.ScoringEventArchive
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &archives))
+	assert.Equal(t, 1, len(archives))
+	assert.Equal(t, campaign, archives[0].CampaignName)
+	assert.Equal(t, int64(2), archives[0].EventCount)
+	assert.NotNil(t, archives[0].RestoredOn)
+}
+
+func TestArchiveScoringEventsMissingCampaign(t *testing.T) {
+	c, _ := setupMockContextArchive("")
+	newMockDb(t)
+
+	assertApiError(t, archiveScoringEvents(c), http.StatusBadRequest, "missing campaignName")
+}
+
+func TestArchiveScoringEventsNoCampaign(t *testing.T) {
+	c, _ := setupMockContextArchive(campaign)
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignErr = sql.ErrNoRows
+
+	assertApiError(t, archiveScoringEvents(c), http.StatusNotFound, "no campaign: campaignName: "+campaign)
+}
+
+func TestArchiveScoringEventsNotEnded(t *testing.T) {
+	c, _ := setupMockContextArchive(campaign)
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{Name: campaign, EndOn: time.Now().Add(time.Hour)}
+
+	assertApiError(t, archiveScoringEvents(c), http.StatusConflict, "campaign has not ended: campaignName: "+campaign)
+}
+
+func TestArchiveScoringEventsAlreadyArchived(t *testing.T) {
+	c, _ := setupMockContextArchive(campaign)
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{Name: campaign, EndOn: time.Now().Add(-time.Hour)}
+	mock.archives = []types.ScoringEventArchive{{CampaignName: "otherCampaign"}, {CampaignName: campaign, EventCount: 2, ArchivedOn: now}}
+
+	assertApiError(t, archiveScoringEvents(c), http.StatusConflict, "scoring events already archived: campaignName: "+campaign)
+}
+
+func TestArchiveScoringEventsArchivedMeanwhile(t *testing.T) {
+	c, rec := setupMockContextArchive(campaign)
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{Name: campaign, EndOn: time.Now().Add(-time.Hour)}
+	mock.archiveCampaignNames = []string{campaign}
+	mock.archiveErr = sql.ErrNoRows
+	finished := setupMockJob(mock, jobKindScoringEventArchive)
+
+	assert.NoError(t, archiveScoringEvents(c))
+	assertJobAccepted(t, c, rec, jobKindScoringEventArchive)
+	assert.Equal(t, types.JobStatusFailed, finished.Status)
+	assert.Equal(t, "scoring events already archived: campaignName: "+campaign, finished.Error)
+}
+
+func TestArchiveScoringEventsSelectArchivesError(t *testing.T) {
+	c, _ := setupMockContextArchive(campaign)
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{Name: campaign, EndOn: time.Now().Add(-time.Hour)}
+	forcedError := fmt.Errorf("forced select archives error")
+	mock.archivesErr = forcedError
+
+	assert.EqualError(t, archiveScoringEvents(c), forcedError.Error())
+}
+
+func TestArchiveScoringEvents(t *testing.T) {
+	c, rec := setupMockContextArchive(campaign)
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{Name: campaign, EndOn: time.Now().Add(-time.Hour)}
+	mock.archiveCampaignNames = []string{campaign}
+	// an archive restored before is archived again when asked
+	restoredOn := now.UTC()
+	mock.archives = []types.ScoringEventArchive{{CampaignName: campaign, EventCount: 2, ArchivedOn: now, RestoredOn: &restoredOn}}
+	mock.archiveResult = &types.ScoringEventArchive{CampaignName: campaign, EventCount: 2, ArchivedOn: now.UTC()}
+	finished := setupMockJob(mock, jobKindScoringEventArchive)
+
+	assert.NoError(t, archiveScoringEvents(c))
+	assertJobAccepted(t, c, rec, jobKindScoringEventArchive)
+	assert.Equal(t, types.JobStatusSucceeded, finished.Status)
+	assert.Equal(t, int64(2), finished.Done)
+	var archive types.ScoringEventArchive
+	assert.NoError(t, json.Unmarshal(finished.Result, &archive))
+	assert.Equal(t, campaign, archive.CampaignName)
+	assert.Equal(t, int64(2), archive.EventCount)
+	assert.Nil(t, archive.RestoredOn)
+}
+
+func TestArchiveScoringEventsError(t *testing.T) {
+	c, rec := setupMockContextArchive(campaign)
+	mock := newMockDb(t)
+	mock.getCampaignParam = campaign
+	mock.getCampaignResult = &types.CampaignStruct{Name: campaign, EndOn: time.Now().Add(-time.Hour)}
+	mock.archiveCampaignNames = []string{campaign}
+	mock.archiveErr = fmt.Errorf("forced archive error")
+	finished := setupMockJob(mock, jobKindScoringEventArchive)
+
+	assert.NoError(t, archiveScoringEvents(c))
+	assertJobAccepted(t, c, rec, jobKindScoringEventArchive)
+	assert.Equal(t, types.JobStatusFailed, finished.Status)
+	assert.Equal(t, "internal server error", finished.Error)
+}
+
+func TestRestoreScoringEventsMissingCampaign(t *testing.T) {
+	c, _ := setupMockContextArchive("")
+	newMockDb(t)
+
+	assertApiError(t, restoreScoringEvents(c), http.StatusBadRequest, "missing campaignName")
+}
+
+func TestRestoreScoringEventsNoArchive(t *testing.T) {
+	c, _ := setupMockContextArchive(campaign)
+	mock := newMockDb(t)
+	mock.archives = []types.ScoringEventArchive{{CampaignName: "otherCampaign"}}
+
+	assertApiError(t, restoreScoringEvents(c), http.StatusNotFound, "no scoring event archive: campaignName: "+campaign)
+}
+
+func TestRestoreScoringEventsAlreadyRestored(t *testing.T) {
+	c, _ := setupMockContextArchive(campaign)
+	mock := newMockDb(t)
+	restoredOn := now.UTC()
+	mock.archives = []types.ScoringEventArchive{{CampaignName: campaign, ArchivedOn: now, RestoredOn: &restoredOn}}
+
+	assertApiError(t, restoreScoringEvents(c), http.StatusNotFound, "no scoring event archive: campaignName: "+campaign)
+}
+
+func TestRestoreScoringEvents(t *testing.T) {
+	c, rec := setupMockContextArchive(campaign)
+	mock := newMockDb(t)
+	mock.restoreCampaignName = campaign
+	mock.archives = []types.ScoringEventArchive{{CampaignName: campaign, EventCount: 1, ArchivedOn: now}}
+	restoredOn := now.UTC()
+	mock.restoreResult = &types.ScoringEventArchive{CampaignName: campaign, EventCount: 1, ArchivedOn: now.UTC(), RestoredOn: &restoredOn}
+	finished := setupMockJob(mock, jobKindScoringEventRestore)
+
+	assert.NoError(t, restoreScoringEvents(c))
+	assertJobAccepted(t, c, rec, jobKindScoringEventRestore)
+	assert.Equal(t, types.JobStatusSucceeded, finished.Status)
+	assert.Equal(t, int64(1), finished.Total)
+	var archive types.ScoringEventArchive
+	assert.NoError(t, json.Unmarshal(finished.Result, &archive))
+	assert.Equal(t, int64(1), archive.EventCount)
+	assert.NotNil(t, archive.RestoredOn)
+}
+
+func TestRestoreScoringEventsError(t *testing.T) {
+	c, rec := setupMockContextArchive(campaign)
+	mock := newMockDb(t)
+	mock.restoreCampaignName = campaign
+	mock.archives = []types.ScoringEventArchive{{CampaignName: campaign, EventCount: 1, ArchivedOn: now}}
+	mock.restoreErr = fmt.Errorf("forced restore error")
+	finished := setupMockJob(mock, jobKindScoringEventRestore)
+
+	assert.NoError(t, restoreScoringEvents(c))
+	assertJobAccepted(t, c, rec, jobKindScoringEventRestore)
+	assert.Equal(t, types.JobStatusFailed, finished.Status)
+	assert.Equal(t, "internal server error", finished.Error)
+}
+
+func setupMockContextJob(jobId string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamJobId)
+	c.SetParamValues(jobId)
+	return
+}
+
+func TestGetJobNotFound(t *testing.T) {
+	c, _ := setupMockContextJob(jobId)
+	mock := newMockDb(t)
+	mock.selectJobId = jobId
+	mock.selectJobErr = sql.ErrNoRows
+
+	assertApiError(t, getJob(c), http.StatusNotFound, "no job: jobId: "+jobId)
+}
+
+func TestGetJobError(t *testing.T) {
+	c, _ := setupMockContextJob(jobId)
+	mock := newMockDb(t)
+	mock.selectJobId = jobId
+	forcedError := fmt.Errorf("forced select job error")
+	mock.selectJobErr = forcedError
+
+	assert.EqualError(t, getJob(c), forcedError.Error())
+}
+
+func TestGetJob(t *testing.T) {
+	c, rec := setupMockContextJob(jobId)
+	mock := newMockDb(t)
+	mock.selectJobId = jobId
+	finishedOn := now.UTC()
+	mock.selectJobResult = &types.Job{ID: jobId, Kind: jobKindScoringEventRestore, Status: types.JobStatusSucceeded,
+		InstanceId: "myInstance", Done: 1, Total: 1, Result: json.RawMessage(`{"eventCount":1}`), CreatedOn: now.UTC(),
+		FinishedOn: &finishedOn}
+
+	assert.NoError(t, getJob(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	jsonExpected, err := json.Marshal(mock.selectJobResult)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpected)+"\n", rec.Body.String())
+}
+
+func TestRunJobPanic(t *testing.T) {
+	mock := newMockDb(t)
+	finished := setupMockJob(mock, jobKindScoringEventImport)
+	job := &types.Job{ID: jobId, Kind: jobKindScoringEventImport, Status: types.JobStatusRunning}
+
+	runJob(job, func(ctx context.Context, progress func(done, total int64)) (interface{}, error) {
+		progress(1, 2)
+		panic("forced job panic")
+	})
+	assert.Equal(t, types.JobStatusFailed, finished.Status)
+	assert.Equal(t, "internal server error", finished.Error)
+	assert.Equal(t, int64(1), finished.Done)
+	assert.Equal(t, int64(2), finished.Total)
+}
+
+func TestDrainJobs(t *testing.T) {
+	mock := newMockDb(t)
+	finished := setupMockJob(mock, jobKindScoringEventImport)
+	job := &types.Job{ID: jobId, Kind: jobKindScoringEventImport, Status: types.JobStatusRunning}
+
+	asyncJobs.Add(1)
+	go func() {
+		defer asyncJobs.Done()
+		runJob(job, func(ctx context.Context, progress func(done, total int64)) (interface{}, error) {
+			return 1, nil
+		})
+	}()
+	drainJobs(time.Minute)
+	assert.Equal(t, types.JobStatusSucceeded, finished.Status)
+	assert.Nil(t, jobsCtx.Err())
+}
+
+func TestDrainJobsTimeout(t *testing.T) {
+	mock := newMockDb(t)
+	finished := setupMockJob(mock, jobKindScoringEventImport)
+	job := &types.Job{ID: jobId, Kind: jobKindScoringEventImport, Status: types.JobStatusRunning}
+	defer func() {
+		jobsCtx, cancelJobs = context.WithCancel(context.Background())
+	}()
+
+	asyncJobs.Add(1)
+	go func() {
+		defer asyncJobs.Done()
+		runJob(job, func(ctx context.Context, progress func(done, total int64)) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+	}()
+	drainJobs(time.Millisecond)
+	assert.Equal(t, types.JobStatusFailed, finished.Status)
+	assert.Equal(t, jobShutdownError, finished.Error)
+	assert.NotNil(t, finished.FinishedOn)
+}
+
+func TestFailInstanceJobs(t *testing.T) {
+	mock := newMockDb(t)
+	thisInstance = types.ClusterInstance{InstanceId: "myInstance", StartedOn: now}
+	mock.failJobsInstanceId = "myInstance"
+	mock.failJobsResult = 1
+
+	failInstanceJobs(now)
+}
+
+func TestFailOrphanedJobsError(t *testing.T) {
+	mock := newMockDb(t)
+	mock.failJobsHeartbeatBefore = now.Add(-heartbeatInterval * missedHeartbeatsAllowed)
+	forcedError := fmt.Errorf("forced fail jobs error")
+	mock.failJobsErr = forcedError
+
+	assert.EqualError(t, failOrphanedJobs(now), forcedError.Error())
+}
+
+func TestFailOrphanedJobs(t *testing.T) {
+	mock := newMockDb(t)
+	mock.failJobsHeartbeatBefore = now.Add(-heartbeatInterval * missedHeartbeatsAllowed)
+	mock.failJobsResult = 2
+
+	assert.NoError(t, failOrphanedJobs(now))
+}
+
+func TestGetScoringFlags(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mock := newMockDb(t)
+	mock.selectFlagsResult = []types.ScoringFlag{*newScoringFlag()}
+
+	assert.NoError(t, getScoringFlags(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	jsonExpected, err := json.Marshal(mock.selectFlagsResult)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpected)+"\n", rec.Body.String())
+}
+
+func TestGetScoringFlagsError(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced flags error")
+	mock.selectFlagsErr = forcedError
+
+	assert.EqualError(t, getScoringFlags(c), forcedError.Error())
+}
+
+func TestApproveScoringFlagNotFound(t *testing.T) {
+	c, _ := setupMockContextScoringFlag()
+
+	mock := newMockDb(t)
+	mock.resolveFlagId = scoringFlagId
+	mock.resolveFlagResolution = types.ScoringFlagApproved
+	mock.resolveFlagErr = sql.ErrNoRows
+
+	assertApiError(t, approveScoringFlag(c), http.StatusNotFound, "no scoring flag to resolve: scoringFlagId: myScoringFlagId")
+}
+
+func TestApproveScoringFlag(t *testing.T) {
+	c, rec := setupMockContextScoringFlag()
+
+	mock := newMockDb(t)
+	mock.resolveFlagId = scoringFlagId
+	mock.resolveFlagResolution = types.ScoringFlagApproved
+	mock.resolveFlagResult = newScoringFlag()
+	resolvedOn := now
+	mock.resolveFlagResult.ResolvedOn, mock.resolveFlagResult.Resolution = &resolvedOn, types.ScoringFlagApproved
+
+	assert.NoError(t, approveScoringFlag(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	jsonExpected, err := json.Marshal(mock.resolveFlagResult)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpected)+"\n", rec.Body.String())
+}
+
+func TestVoidScoringFlagNotFound(t *testing.T) {
+	c, _ := setupMockContextScoringFlag()
+
+	mock := newMockDb(t)
+	mock.resolveFlagId = scoringFlagId
+	mock.resolveFlagResolution = types.ScoringFlagVoided
+	mock.resolveFlagErr = sql.ErrNoRows
+
+	assertApiError(t, voidScoringFlag(c), http.StatusNotFound, "no scoring flag to resolve: scoringFlagId: myScoringFlagId")
+}
+
+func TestVoidScoringFlagVoidError(t *testing.T) {
+	c, _ := setupMockContextScoringFlag()
+
+	mock := newMockDb(t)
+	mock.resolveFlagId = scoringFlagId
+	mock.resolveFlagResolution = types.ScoringFlagVoided
+	mock.resolveFlagResult = newScoringFlag()
+	mock.voidScoreEvtId = scoreEventId
+	forcedError := fmt.Errorf("forced void error")
+	mock.voidScoreEvtErr = forcedError
+
+	assert.EqualError(t, voidScoringFlag(c), forcedError.Error())
+}
+
+func TestVoidScoringFlagAlreadyVoided(t *testing.T) {
+	c, rec := setupMockContextScoringFlag()
+
+	mock := newMockDb(t)
+	mock.resolveFlagId = scoringFlagId
+	mock.resolveFlagResolution = types.ScoringFlagVoided
+	mock.resolveFlagResult = newScoringFlag()
+	mock.voidScoreEvtId = scoreEventId
+	mock.voidScoreEvtErr = sql.ErrNoRows
+
+	assert.NoError(t, voidScoringFlag(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	jsonExpected, err := json.Marshal(mock.resolveFlagResult)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpected)+"\n", rec.Body.String())
+}
+
+func TestVoidScoringFlag(t *testing.T) {
+	c, rec := setupMockContextScoringFlag()
+
+	mock := newMockDb(t)
+	mock.resolveFlagId = scoringFlagId
+	mock.resolveFlagResolution = types.ScoringFlagVoided
+	mock.resolveFlagResult = newScoringFlag()
+	mock.voidScoreEvtId = scoreEventId
+	voidedOn := now
+	mock.voidScoreEvtResult = &newScoringFlag().Event
+	mock.voidScoreEvtResult.VoidedOn = &voidedOn
+	mock.voidScoreEvtTeamName = teamName
+
+	deltas := scoreHub.Subscribe(campaign)
+	defer scoreHub.Unsubscribe(campaign, deltas)
+
+	assert.NoError(t, voidScoringFlag(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	expected := newScoringFlag()
+	expected.Event.VoidedOn = &voidedOn
+	jsonExpected, err := json.Marshal(expected)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpected)+"\n", rec.Body.String())
+	assert.Equal(t, types.ScoreDelta{CampaignName: campaign, ScpName: scpName, LoginName: loginName, TeamName: teamName, Delta: -4}, <-deltas)
+}
+
+func setupMockContextScoreDryRun(payload string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	return
+}
+
+const scoringSecret = "myScoringSecret"
+
+// setupMockContextScorePush signs the payload with the secret at the time, leaving the headers out when secret is empty
+func setupMockContextScorePush(payload, secret string, signedOn time.Time) (c echo.Context, rec *httptest.ResponseRecorder) {
+	c, rec = setupMockContextScoreDryRun(payload)
+	if secret != "" {
+		timestamp := strconv.FormatInt(signedOn.Unix(), 10)
+		c.Request().Header.Set(headerSignatureTimestamp, timestamp)
+		c.Request().Header.Set(headerSignature, webhook.Sign(secret, []byte(timestamp+"."+payload)))
+	}
+	return
+}
+
+func TestScoringSecretsFromConfig(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	assert.Equal(t, map[string]string{}, scoringSecretsFromConfig(config.Defaults()))
+	assert.Equal(t, map[string]string{"github": "s3cret=with=equals", "gitlab": "0th3r"},
+		scoringSecretsFromConfig(&config.Config{ScoringSecrets: " GitHub=s3cret=with=equals, GitLab = 0th3r,,noSecret=,=noSource,bogus"}))
+}
+
+func TestPushScoringMessageUnsigned(t *testing.T) {
+	c, _ := setupMockContextScorePush(`{}`, "", time.Now())
+	assertApiError(t, pushScoringMessage(map[string]string{"github": scoringSecret})(c), http.StatusUnauthorized, "scoring message is not signed")
+}
+
+func TestPushScoringMessageStale(t *testing.T) {
+	payload := fmt.Sprintf(`{"eventSource": "%s", "repositoryOwner": "%s", "triggerUser": "MyLoginName"}`, db.TestEventSourceValid, db.TestOrgValid)
+	for _, signedOn := range []time.Time{time.Now().Add(-6 * time.Minute), time.Now().Add(6 * time.Minute)} {
+		c, _ := setupMockContextScorePush(payload, scoringSecret, signedOn)
+		assertApiError(t, pushScoringMessage(map[string]string{"github": scoringSecret})(c), http.StatusUnauthorized, "scoring message signature is stale")
+	}
+
+	c, _ := setupMockContextScorePush(payload, scoringSecret, time.Now())
+	c.Request().Header.Set(headerSignatureTimestamp, "yesterday")
+	assertApiError(t, pushScoringMessage(map[string]string{"github": scoringSecret})(c), http.StatusUnauthorized, "invalid signature timestamp: yesterday")
+}
+
+func TestPushScoringMessageInvalidPayload(t *testing.T) {
+	c, _ := setupMockContextScorePush(`{"schemaVersion": 99}`, scoringSecret, time.Now())
+	assertApiError(t, pushScoringMessage(map[string]string{"github": scoringSecret})(c), http.StatusBadRequest,
+		"invalid scoring message: unknown scoring message schemaVersion: 99")
+}
+
+func TestPushScoringMessageBadSignature(t *testing.T) {
+	payload := fmt.Sprintf(`{"eventSource": "%s", "repositoryOwner": "%s", "triggerUser": "MyLoginName"}`, db.TestEventSourceValid, db.TestOrgValid)
+	newMockDb(t)
+
+	for _, test := range []struct {
+		name    string
+		secret  string
+		secrets map[string]string
+	}{
+		{"wrong secret", "notTheScoringSecret", map[string]string{"github": scoringSecret}},
+		// the secret of another source
+		{"unknown source", scoringSecret, map[string]string{"gitlab": scoringSecret}},
+	} {
+		c, _ := setupMockContextScorePush(payload, test.secret, time.Now())
+		assertApiError(t, pushScoringMessage(test.secrets)(c), http.StatusUnauthorized, "scoring message signature is invalid")
+	}
+
+	// the body changed after it was signed
+	c, _ := setupMockContextScorePush(payload, scoringSecret, time.Now())
+	c.Request().Body = io.NopCloser(strings.NewReader(strings.Replace(payload, "MyLoginName", "SomeoneElse", 1)))
+	assertApiError(t, pushScoringMessage(map[string]string{"github": scoringSecret})(c), http.StatusUnauthorized, "scoring message signature is invalid")
+}
+
+func TestPushScoringMessage(t *testing.T) {
+	payload := fmt.Sprintf(`{"eventSource": "%s", "repositoryOwner": "%s", "triggerUser": "MyLoginName"}`, "GitHub", db.TestOrgValid)
+	c, rec := setupMockContextScorePush(payload, scoringSecret, time.Now())
+
+	mock := newMockDb(t)
+	mock.assertParameters = false
+	mock.validOrgResult = false
+
+	assert.NoError(t, pushScoringMessage(map[string]string{"github": scoringSecret})(c))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+}
+
+func TestPushScoringMessageError(t *testing.T) {
+	payload := fmt.Sprintf(`{"eventSource": "%s", "repositoryOwner": "%s", "triggerUser": "MyLoginName"}`, db.TestEventSourceValid, db.TestOrgValid)
+	c, _ := setupMockContextScorePush(payload, scoringSecret, time.Now())
+
+	mock := newMockDb(t)
+	mock.assertParameters = false
+	forcedError := fmt.Errorf("forced organization error")
+	mock.validOrgErr = forcedError
+
+	assert.EqualError(t, pushScoringMessage(map[string]string{"github": scoringSecret})(c), forcedError.Error())
+}
+
+func TestScoringTokenAuthorizer(t *testing.T) {
+	authorize := scoringTokenAuthorizer(map[string]string{"github": scoringSecret})
+	assert.True(t, authorize("GitHub", scoringSecret))
+	assert.False(t, authorize("GitHub", "notTheScoringSecret"))
+	assert.False(t, authorize("GitHub", ""))
+	assert.False(t, authorize("GitLab", scoringSecret))
+}
+
+func TestBeginGrpcServerWithoutCertificate(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	cfg := config.Defaults()
+	cfg.GrpcPort = 7778
+	cfg.GrpcTlsCertFile = "cert.pem"
+
+	_, err := beginGrpcServer(cfg)
+	assert.EqualError(t, err, "GRPC_PORT is set without a certificate, set GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE")
+}
+
+func TestBeginGrpcServerCertificateOfHttpServer(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	cfg := config.Defaults()
+	cfg.GrpcPort = 7778
+	cfg.TlsCertFile = filepath.Join(t.TempDir(), "missing.pem")
+	cfg.TlsKeyFile = cfg.TlsCertFile
+
+	_, err := beginGrpcServer(cfg)
+	assert.EqualError(t, err, fmt.Sprintf("open %s: no such file or directory", cfg.TlsCertFile))
+}
+
+func TestScoreGrpcMessageMaintenance(t *testing.T) {
+	defer func() {
+		maintenance = &maintenanceMode{}
+	}()
+	// nothing is scored, so the mock is not called
+	newMockDb(t)
+	_, err := maintenance.Set(context.Background(), true, "migrating the schema", now)
+	assert.NoError(t, err)
+
+	err = scoreGrpcMessage(context.Background(), &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid})
+	assert.Equal(t, grpcapi.Errorf(grpcapi.Unavailable, "migrating the schema"), err)
+}
+
+func TestScoreGrpcMessage(t *testing.T) {
+	mock := newMockDb(t)
+	mock.assertParameters = false
+	forcedError := fmt.Errorf("forced organization error")
+	mock.validOrgErr = forcedError
+
+	err := scoreGrpcMessage(context.Background(), &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid})
+	assert.EqualError(t, err, forcedError.Error())
+}
+
+func TestScoreDryRunInvalidPayload(t *testing.T) {
+	c, _ := setupMockContextScoreDryRun(`{"schemaVersion": 99}`)
+
+	assertApiError(t, scoreDryRun(c), http.StatusBadRequest, "invalid scoring message: unknown scoring message schemaVersion: 99")
+}
+
+func TestScoreDryRunValidScoreError(t *testing.T) {
+	c, _ := setupMockContextScoreDryRun(fmt.Sprintf(`{"eventSource": "%s", "repositoryOwner": "%s"}`, db.TestEventSourceValid, db.TestOrgValid))
+
+	mock := newMockDb(t)
+	mock.validOrgParam = &types.ScoringMessage{SchemaVersion: types.ScoringSchemaVersion, EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid}
+	forcedError := fmt.Errorf("forced validOrg error")
+	mock.validOrgErr = forcedError
+
+	assert.EqualError(t, scoreDryRun(c), forcedError.Error())
+}
+
+func TestScoreDryRunNoParticipants(t *testing.T) {
+	c, rec := setupMockContextScoreDryRun(fmt.Sprintf(`{"eventSource": "%s", "repositoryOwner": "%s"}`, db.TestEventSourceValid, db.TestOrgValid))
+
+	mock := newMockDb(t)
+	mock.validOrgParam = &types.ScoringMessage{SchemaVersion: types.ScoringSchemaVersion, EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid}
+
+	assert.NoError(t, scoreDryRun(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var dryRun types.ScoringDryRun
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &dryRun))
+	assert.Equal(t, db.TestOrgValid, dryRun.Message.RepoOwner)
+	assert.Equal(t, []types.DryRunParticipantScore{}, dryRun.Participants)
+}
+
+func TestScoreDryRun(t *testing.T) {
+	c, rec := setupMockContextScoreDryRun(fmt.Sprintf(`{"eventSource": "%s", "repositoryOwner": "%s", "triggerUser": "MyLoginName", "fixed-bugs": 3, "fixed-bug-types": {"myBugType": 2}}`,
+		db.TestEventSourceValid, db.TestOrgValid))
+
+	mock := newMockDb(t)
+	mock.assertParameters = false
+	mock.validOrgResult = true
+	mock.partiesToScoreResult = []types.ParticipantStruct{
+		{CampaignName: campaign, ScpName: scpName, LoginName: "myloginname", TeamName: "myTeamName"},
+	}
+	mock.selectPointValueResult = 2
+	mock.priorScoreResult = 1
+
+	assert.NoError(t, scoreDryRun(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var dryRun types.ScoringDryRun
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &dryRun))
+	assert.Equal(t, "myloginname", dryRun.Message.TriggerUser)
+	assert.Equal(t, []types.DryRunParticipantScore{
+		{
+			CampaignName: campaign,
+			ScpName:      scpName,
+			LoginName:    "myloginname",
+			TeamName:     "myTeamName",
+			Points:       5,
+			Breakdown: &types.ScoreBreakdown{
+				Categories:        []types.CategoryScore{{Category: "myBugType", Count: 2, PointValue: 2, Points: 4}},
+				Classified:        2,
+				BasePoints:        4,
+				Unclassified:      1,
+				UnclassifiedBonus: 1,
+				Points:            5,
+				PriorPoints:       1,
+				Delta:             4,
+			},
+		},
+	}, dryRun.Participants)
+	// no score was changed
+	assert.Equal(t, float64(0), updateScoreLastDelta)
+}
+
+func TestScoreDryRunCoAuthors(t *testing.T) {
+	c, rec := setupMockContextScoreDryRun(fmt.Sprintf(`{"eventSource": "%s", "repositoryOwner": "%s", "triggerUser": "MyLoginName", "fixed-bugs": 4, "coAuthors": ["MyPair"]}`,
+		db.TestEventSourceValid, db.TestOrgValid))
+
+	mock := newMockDb(t)
+	mock.assertParameters = false
+	mock.validOrgResult = true
+	mock.partiesToScoreResult = []types.ParticipantStruct{{CampaignName: campaign, ScpName: scpName, LoginName: "myloginname"}}
+	mock.partiesToScoreCoAuthors = map[string][]types.ParticipantStruct{
+		"mypair": {{CampaignName: campaign, ScpName: scpName, LoginName: "mypair", TeamName: "myTeamName"}},
+	}
+	mock.coAuthorPriorScore = 1
+
+	assert.NoError(t, scoreDryRun(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var dryRun types.ScoringDryRun
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &dryRun))
+	assert.Equal(t, 2, len(dryRun.Participants))
+	assert.Equal(t, float64(2), dryRun.Participants[0].Points)
+	assert.Equal(t, float64(2), dryRun.Participants[0].Breakdown.Delta)
+	assert.Equal(t, types.DryRunParticipantScore{
+		CampaignName: campaign,
+		ScpName:      scpName,
+		LoginName:    "mypair",
+		TeamName:     "myTeamName",
+		Points:       2,
+		Breakdown: &types.ScoreBreakdown{
+			Unclassified:      4,
+			UnclassifiedBonus: 4,
+			CoAuthors:         1,
+			SplitPoints:       2,
+			Points:            2,
+			PriorPoints:       1,
+			Delta:             1,
+		},
+	}, dryRun.Participants[1])
+	assert.Nil(t, insertCoAuthorScores)
+}
+
+func TestScoreDryRunDroppedCoAuthor(t *testing.T) {
+	c, rec := setupMockContextScoreDryRun(fmt.Sprintf(`{"eventSource": "%s", "repositoryOwner": "%s", "triggerUser": "MyLoginName", "fixed-bugs": 4}`,
+		db.TestEventSourceValid, db.TestOrgValid))
+
+	mock := newMockDb(t)
+	mock.assertParameters = false
+	mock.validOrgResult = true
+	mock.partiesToScoreResult = []types.ParticipantStruct{{CampaignName: campaign, ScpName: scpName, LoginName: "myloginname"}}
+	mock.coAuthorScoresResult = []db.CoAuthorScoreRow{
+		{Participant: types.ParticipantStruct{CampaignName: campaign, ScpName: scpName, LoginName: "mypair", TeamName: "myTeamName"}, Points: 2},
+	}
+
+	assert.NoError(t, scoreDryRun(c))
+	var dryRun types.ScoringDryRun
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &dryRun))
+	assert.Equal(t, 2, len(dryRun.Participants))
+	assert.Equal(t, types.DryRunParticipantScore{
+		CampaignName: campaign,
+		ScpName:      scpName,
+		LoginName:    "mypair",
+		TeamName:     "myTeamName",
+		Breakdown:    &types.ScoreBreakdown{PriorPoints: 2, Delta: -2},
+	}, dryRun.Participants[1])
+	assert.Nil(t, deleteCoAuthorScores)
+}
+
+func TestNotifyPointsAwardedNoChange(t *testing.T) {
+	newMockDb(t)
+
+	notifyPointsAwarded(&types.ParticipantStruct{ID: participantID}, &types.ScoringMessage{}, &types.ScoreBreakdown{Points: 3, PriorPoints: 3}, now)
+	assert.Nil(t, insertNotificationLast)
+}
+
+func TestNotifyPointsAwardedInsertError(t *testing.T) {
+	mock := newMockDb(t)
+	mock.insertNotificationErr = fmt.Errorf("forced notification error")
+
+	// the error is only logged
+	notifyPointsAwarded(&types.ParticipantStruct{ID: participantID}, &types.ScoringMessage{}, &types.ScoreBreakdown{Points: 3, Delta: 3}, now)
+	assert.NotNil(t, insertNotificationLast)
+}
+
+func TestNotifyPointsAwarded(t *testing.T) {
+	newMockDb(t)
+
+	msg := &types.ScoringMessage{RepoOwner: "repoOwner", RepoName: "repoName", PullRequest: 7}
+	notifyPointsAwarded(&types.ParticipantStruct{ID: participantID}, msg, &types.ScoreBreakdown{Points: 3, PriorPoints: 5, Delta: -2}, now)
+	assert.Equal(t, &types.NotificationStruct{
+		ParticipantId: participantID,
+		Kind:          types.NotificationKindPointsAwarded,
+		Message:       "-2 points for pull request repoOwner/repoName #7, score for the pull request is now 3",
+		CreatedOn:     now,
+	}, insertNotificationLast)
+}
+
+func setupMockContextNotification(notificationId string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/?unread=true", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamCampaignName, ParamScpName, ParamLoginName, ParamNotificationId)
+	c.SetParamValues(campaign, scpName, loginName, notificationId)
+	return
+}
+
+func TestGetNotificationsError(t *testing.T) {
+	c, rec := setupMockContextNotification("")
+
+	mock := newMockDb(t)
+	mock.selectNotificationsCampName = campaign
+	mock.selectNotificationsScpName = scpName
+	mock.selectNotificationsLoginName = loginName
+	mock.selectNotificationsUnreadOnly = true
+	forcedError := fmt.Errorf("forced notification select error")
+	mock.selectNotificationsErr = forcedError
+
+	assert.EqualError(t, getNotifications(c), forcedError.Error())
+	assert.Equal(t, "", rec.Body.String())
+}
+
+func TestGetNotifications(t *testing.T) {
+	c, rec := setupMockContextNotification("")
+
+	mock := newMockDb(t)
+	mock.selectNotificationsCampName = campaign
+	mock.selectNotificationsScpName = scpName
+	mock.selectNotificationsLoginName = loginName
+	mock.selectNotificationsUnreadOnly = true
+	mock.selectNotificationsResult = []types.NotificationStruct{
+		{Id: "notificationId", ParticipantId: participantID, Kind: types.NotificationKindPointsAwarded, Message: "+1 points", CreatedOn: now},
+	}
+
+	assert.NoError(t, getNotifications(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	jsonExpected, err := json.Marshal(mock.selectNotificationsResult)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpected)+"\n", rec.Body.String())
+}
+
+func TestReadNotificationsAll(t *testing.T) {
+	c, _ := setupMockContextNotification("")
+
+	mock := newMockDb(t)
+	mock.readNotificationsCampName = campaign
+	mock.readNotificationsScpName = scpName
+	mock.readNotificationsLoginName = loginName
+
+	assert.NoError(t, readNotifications(c))
+	assert.Equal(t, http.StatusNoContent, c.Response().Status)
+}
+
+func TestReadNotificationNotFound(t *testing.T) {
+	c, _ := setupMockContextNotification("notificationId")
+
+	mock := newMockDb(t)
+	mock.readNotificationsCampName = campaign
+	mock.readNotificationsScpName = scpName
+	mock.readNotificationsLoginName = loginName
+	mock.readNotificationsId = "notificationId"
+
+	assertApiError(t, readNotifications(c), http.StatusNotFound, "no unread notification: campaignName: myCampaignName, scpName: myScpName, loginName: loginName, notificationId: notificationId")
+}
+
+func TestReadNotification(t *testing.T) {
+	c, _ := setupMockContextNotification("notificationId")
+
+	mock := newMockDb(t)
+	mock.readNotificationsCampName = campaign
+	mock.readNotificationsScpName = scpName
+	mock.readNotificationsLoginName = loginName
+	mock.readNotificationsId = "notificationId"
+	mock.readNotificationsRowsAffected = 1
+
+	assert.NoError(t, readNotifications(c))
+	assert.Equal(t, http.StatusNoContent, c.Response().Status)
+}
+
+func TestLeaderboardSocket(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	newMockDb(t)
+
+	e := echo.New()
+	e.GET(fmt.Sprintf("%s%s/:%s", WebSocket, Leaderboard, ParamCampaignName), leaderboardSocket)
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+WebSocket+Leaderboard+"/"+campaign, "", server.URL)
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return scoreHub.SubscriberCount(campaign) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	delta := types.ScoreDelta{CampaignName: campaign, ScpName: scpName, LoginName: loginName, Delta: 3}
+	scoreHub.Publish(delta)
+	scoreHub.Publish(types.ScoreDelta{CampaignName: "otherCampaign", Delta: 5})
+
+	var received types.ScoreDelta
+	assert.NoError(t, websocket.JSON.Receive(ws, &received))
+	assert.Equal(t, delta, received)
+
+	assert.NoError(t, ws.Close())
+	assert.Eventually(t, func() bool {
+		return scoreHub.SubscriberCount(campaign) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestLeaderboardSocketAnonymized(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	mock := newMockDb(t)
+	setupMockAnonymized(mock)
+
+	e := echo.New()
+	e.GET(fmt.Sprintf("%s%s/:%s", WebSocket, Leaderboard, ParamCampaignName), leaderboardSocket)
+	server := httptest.NewServer(e)
+	defer server.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http")+WebSocket+Leaderboard+"/"+campaign, "", server.URL)
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return scoreHub.SubscriberCount(campaign) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	publishScoreDelta(context.Background(), events.ScoreChanged{
+		ScoreDelta: types.ScoreDelta{CampaignName: campaign, ScpName: scpName, LoginName: loginName, Delta: 3},
+	})
+
+	var received types.ScoreDelta
+	assert.NoError(t, websocket.JSON.Receive(ws, &received))
+	assert.Equal(t, types.ScoreDelta{CampaignName: campaign, ScpName: scpName, LoginName: pseudonym(mock.selectPrivacyResult, scpName, loginName), Delta: 3}, received)
+
+	assert.NoError(t, ws.Close())
+	assert.Eventually(t, func() bool {
+		return scoreHub.SubscriberCount(campaign) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestPublishScoreDeltaPrivacyError(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	mock := newMockDb(t)
+	mock.selectPrivacyErr = fmt.Errorf("forced privacy error")
+
+	deltas := scoreHub.Subscribe(campaign)
+	defer scoreHub.Unsubscribe(campaign, deltas)
+
+	publishScoreDelta(context.Background(), events.ScoreChanged{
+		ScoreDelta: types.ScoreDelta{CampaignName: campaign, ScpName: scpName, LoginName: loginName, Delta: 3},
+	})
+	select {
+	case delta := <-deltas:
+		assert.Fail(t, "unexpected delta", "%+v", delta)
+	default:
+	}
+}
+
+func TestPublishScoreDeltaNoSubscribers(t *testing.T) {
+	mock := newMockDb(t)
+	// no privacy lookup without subscribers
+	mock.selectPrivacyErr = fmt.Errorf("unexpected privacy lookup")
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger = zap.New(core)
+
+	publishScoreDelta(context.Background(), events.ScoreChanged{
+		ScoreDelta: types.ScoreDelta{CampaignName: campaign, ScpName: scpName, LoginName: loginName, Delta: 3},
+	})
+	assert.Equal(t, 0, logs.Len())
+}
+
+func TestProcessScoringMessagePublishesDelta(t *testing.T) {
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName, TotalFixed: 2}
+
+	mock := newMockDb(t)
+	setupMockDBOrgValid(mock)
+	mock.assertParameters = false
+	mock.partiesToScoreResult = []types.ParticipantStruct{
+		{ID: participantID, CampaignName: campaign, ScpName: scpName, LoginName: loginName, TeamName: teamName},
+	}
+
+	deltas := scoreHub.Subscribe(campaign)
+	defer scoreHub.Unsubscribe(campaign, deltas)
+
+	assert.NoError(t, processScoringMessage(mock, now, msg))
+	assert.Equal(t, types.ScoreDelta{CampaignName: campaign, ScpName: scpName, LoginName: loginName, TeamName: teamName, Delta: 2}, <-deltas)
+}
+
+func TestProcessScoringMessagePublishesScoreChanged(t *testing.T) {
+	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName, TotalFixed: 2}
+
+	mock := newMockDb(t)
+	setupMockDBOrgValid(mock)
+	mock.assertParameters = false
+	mock.partiesToScoreResult = []types.ParticipantStruct{
+		{ID: participantID, CampaignName: campaign, ScpName: scpName, LoginName: loginName, TeamName: teamName},
+	}
+
+	origScoreEvents := scoreEvents
+	defer func() {
+		scoreEvents = origScoreEvents
+	}()
+	scoreEvents = events.New()
+	var published []events.ScoreChanged
+	scoreEvents.OnScoreChanged(func(ctx context.Context, event events.ScoreChanged) {
+		published = append(published, event)
+	})
+
+	assert.NoError(t, processScoringMessage(mock, now, msg))
+	assert.Equal(t, 1, len(published))
+	assert.Equal(t, types.ScoreDelta{CampaignName: campaign, ScpName: scpName, LoginName: loginName, TeamName: teamName, Delta: 2}, published[0].ScoreDelta)
+	assert.Equal(t, participantID, published[0].Participant.ID)
+	assert.Equal(t, msg, published[0].Message)
+	assert.Equal(t, float64(2), published[0].Breakdown.Points)
+	assert.Equal(t, now, published[0].OccurredOn)
+}
+
+// setupMockCoAuthors has the trigger user pair with myPair, a participant of the campaign and of another one, and
+// notAParticipant
+func setupMockCoAuthors(t *testing.T) (mock *MockBBashDB, msg *types.ScoringMessage, published *[]events.ScoreChanged) {
+	msg = &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName, TotalFixed: 3,
+		CoAuthors: []string{"MyPair", "myPair", "notAParticipant", loginName}}
+
+	mock = newMockDb(t)
+	setupMockDBOrgValid(mock)
+	mock.assertParameters = false
+	mock.partiesToScoreResult = []types.ParticipantStruct{
+		{ID: participantID, CampaignName: campaign, ScpName: scpName, LoginName: loginName, TeamName: teamName},
+	}
+	mock.partiesToScoreCoAuthors = map[string][]types.ParticipantStruct{
+		"mypair": {
+			{ID: "myPairId", CampaignName: campaign, ScpName: scpName, LoginName: "mypair"},
+			{ID: "myPairOtherId", CampaignName: "otherCampaign", ScpName: scpName, LoginName: "mypair"},
+		},
+		"notaparticipant": nil,
+	}
+
+	origScoreEvents := scoreEvents
+	t.Cleanup(func() {
+		scoreEvents = origScoreEvents
+	})
+	scoreEvents = events.New()
+	published = &[]events.ScoreChanged{}
+	scoreEvents.OnScoreChanged(func(ctx context.Context, event events.ScoreChanged) {
+		*published = append(*published, event)
+	})
+	return
+}
+
+func TestProcessScoringMessageCoAuthorsSplit(t *testing.T) {
+	mock, msg, published := setupMockCoAuthors(t)
+	mock.coAuthorPriorScore = 1
+
+	assert.NoError(t, processScoringMessage(mock, now, msg))
+	assert.Equal(t, []string{"mypair=1.5"}, insertCoAuthorScores)
+	assert.Equal(t, 2, len(*published))
+	assert.Equal(t, types.ScoreDelta{CampaignName: campaign, ScpName: scpName, LoginName: loginName, TeamName: teamName, Delta: 1.5}, (*published)[0].ScoreDelta)
+	assert.Equal(t, types.ScoreDelta{CampaignName: campaign, ScpName: scpName, LoginName: "mypair", Delta: 0.5}, (*published)[1].ScoreDelta)
+	assert.Equal(t, "myPairId", (*published)[1].Participant.ID)
+	assert.Equal(t, 1, (*published)[1].Breakdown.CoAuthors)
+	assert.Equal(t, 1.5, (*published)[1].Breakdown.SplitPoints)
+	assert.Equal(t, float64(1), (*published)[1].Breakdown.PriorPoints)
+	// the breakdown of the trigger user is not changed by that of the co-author
+	assert.Equal(t, float64(0), (*published)[0].Breakdown.PriorPoints)
+}
+
+func TestProcessScoringMessageCoAuthorsDuplicate(t *testing.T) {
+	mock, msg, published := setupMockCoAuthors(t)
+	mock.selectCoAuthorPolicyCampName = campaign
+	mock.selectCoAuthorPolicyResult = &types.CoAuthorPolicyStruct{CampaignName: campaign, Policy: types.CoAuthorPolicyDuplicate}
+	mock.selectCoAuthorPolicyErr = nil
+
+	assert.NoError(t, processScoringMessage(mock, now, msg))
+	assert.Equal(t, []string{"mypair=3"}, insertCoAuthorScores)
+	assert.Equal(t, 2, len(*published))
+	assert.Equal(t, float64(3), (*published)[0].ScoreDelta.Delta)
+	assert.Equal(t, float64(3), (*published)[1].ScoreDelta.Delta)
+	assert.Equal(t, float64(0), (*published)[1].Breakdown.SplitPoints)
+}
+
+func TestProcessScoringMessageCoAuthorError(t *testing.T) {
+	mock, msg, published := setupMockCoAuthors(t)
+	forcedError := fmt.Errorf("forced co-author error")
+	mock.insertCoAuthorScoreErr = forcedError
+
+	assert.EqualError(t, processScoringMessage(mock, now, msg), forcedError.Error())
+	assert.Equal(t, 1, len(*published))
+}
+
+func TestProcessScoringMessageCoAuthorsCapped(t *testing.T) {
+	mock, msg, published := setupMockCoAuthors(t)
+	mock.selectCapsResult = &types.CampaignCapsStruct{CampaignName: campaign, PerPullRequest: 1}
+	mock.selectCapsErr = nil
+
+	assert.NoError(t, processScoringMessage(mock, now, msg))
+	assert.Equal(t, []string{"mypair=1"}, insertCoAuthorScores)
+	assert.Equal(t, 2, len(*published))
+	assert.Equal(t, float64(1), (*published)[0].ScoreDelta.Delta)
+	assert.Equal(t, float64(1), (*published)[1].ScoreDelta.Delta)
+	assert.Equal(t, 1.5, (*published)[1].Breakdown.SplitPoints)
+	assert.Equal(t, 0.5, (*published)[1].Breakdown.Capped)
+	assert.Equal(t, float64(1), (*published)[1].Breakdown.Points)
+}
+
+func TestProcessScoringMessageCoAuthorsDropped(t *testing.T) {
+	mock, msg, published := setupMockCoAuthors(t)
+	mock.coAuthorScoresResult = []db.CoAuthorScoreRow{
+		{Participant: types.ParticipantStruct{ID: "myPairId", CampaignName: campaign, ScpName: scpName, LoginName: "mypair"}, Points: 1.5},
+		{Participant: types.ParticipantStruct{ID: "formerPairId", CampaignName: campaign, ScpName: scpName, LoginName: "formerpair", TeamName: "theirTeam"}, Points: 2},
+	}
+
+	assert.NoError(t, processScoringMessage(mock, now, msg))
+	assert.Equal(t, []string{"mypair=1.5"}, insertCoAuthorScores)
+	assert.Equal(t, []string{"formerpair"}, deleteCoAuthorScores)
+	assert.Equal(t, 3, len(*published))
+	assert.Equal(t, types.ScoreDelta{CampaignName: campaign, ScpName: scpName, LoginName: "formerpair", TeamName: "theirTeam", Delta: -2}, (*published)[2].ScoreDelta)
+	assert.Nil(t, (*published)[2].Message)
+}
+
+func TestProcessScoringMessageCoAuthorsDroppedError(t *testing.T) {
+	mock, msg, _ := setupMockCoAuthors(t)
+	mock.coAuthorScoresResult = []db.CoAuthorScoreRow{
+		{Participant: types.ParticipantStruct{ID: "formerPairId", CampaignName: campaign, ScpName: scpName, LoginName: "formerpair"}, Points: 2},
+	}
+	forcedError := fmt.Errorf("forced delete co-author error")
+	mock.deleteCoAuthorScoreErr = forcedError
+
+	assert.EqualError(t, processScoringMessage(mock, now, msg), forcedError.Error())
+
+	mock.deleteCoAuthorScoreErr = nil
+	mock.coAuthorScoresErr = fmt.Errorf("forced co-author scores error")
+	assert.EqualError(t, processScoringMessage(mock, now, msg), "forced co-author scores error")
+}
+
+func TestApplyCoAuthorsPolicyError(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectCoAuthorPolicyErr = fmt.Errorf("forced policy error")
+	breakdown := &types.ScoreBreakdown{Points: 6}
+
+	assert.Equal(t, float64(2), applyCoAuthors(campaign, 2, 6, breakdown))
+	assert.Equal(t, &types.ScoreBreakdown{CoAuthors: 2, SplitPoints: 4, Points: 2}, breakdown)
+}
+
+func TestApplyCoAuthorsNone(t *testing.T) {
+	newMockDb(t)
+	breakdown := &types.ScoreBreakdown{Points: 6}
+
+	assert.Equal(t, float64(6), applyCoAuthors(campaign, 0, 6, breakdown))
+	assert.Equal(t, &types.ScoreBreakdown{Points: 6}, breakdown)
+}
+
+func TestScoreEventsSkipScoringReactionsWithoutMessage(t *testing.T) {
+	newMockDb(t)
+	insertNotificationLast = nil
+
+	deltas := scoreHub.Subscribe(campaign)
+	defer scoreHub.Unsubscribe(campaign, deltas)
+
+	delta := types.ScoreDelta{CampaignName: campaign, ScpName: scpName, LoginName: loginName, Delta: -2}
+	newScoreEvents().PublishScoreChanged(context.Background(), events.ScoreChanged{ScoreDelta: delta, OccurredOn: now})
+	assert.Equal(t, delta, <-deltas)
+	assert.Nil(t, insertNotificationLast)
+}
+
+func TestValidateTeamScoreAdjustments(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+
+	assert.EqualError(t, validateTeamScoreAdjustments(&types.TeamScoreAdjustmentRequest{Category: " "}),
+		"team score adjustment is not valid, empty category")
+	assert.EqualError(t, validateTeamScoreAdjustments(&types.TeamScoreAdjustmentRequest{Category: "bestWriteup"}),
+		"team score adjustment is not valid, no adjustments: category: bestWriteup")
+	assert.EqualError(t, validateTeamScoreAdjustments(&types.TeamScoreAdjustmentRequest{
+		Category:    "bestWriteup",
+		Adjustments: []types.TeamScoreAdjustment{{TeamName: teamName, Points: 5, Reason: "clear"}, {Points: 5, Reason: "clear"}},
+	}), "team score adjustment is not valid, empty teamName: adjustment: {TeamName: Points:5 Reason:clear}")
+	assert.EqualError(t, validateTeamScoreAdjustments(&types.TeamScoreAdjustmentRequest{
+		Category:    "bestWriteup",
+		Adjustments: []types.TeamScoreAdjustment{{TeamName: teamName, Points: 5}},
+	}), "team score adjustment is not valid, empty reason: adjustment: {TeamName:myTeamName Points:5 Reason:}")
+	assert.NoError(t, validateTeamScoreAdjustments(&types.TeamScoreAdjustmentRequest{
+		Category:    "bestWriteup",
+		Adjustments: []types.TeamScoreAdjustment{{TeamName: teamName, Points: 5, Reason: "clear"}},
+	}))
+}
+
+const teamScoreAdjustmentJson = `{"category":"bestWriteup","adjustments":[{"teamName":"myTeamName","points":5,"reason":"very clear"}]}`
+
+var teamScoreAdjustmentRequest = &types.TeamScoreAdjustmentRequest{
+	Category:    "bestWriteup",
+	Adjustments: []types.TeamScoreAdjustment{{TeamName: teamName, Points: 5, Reason: "very clear"}},
+}
+
+func TestAdjustTeamScoresInvalid(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, `{"category":"bestWriteup"}`)
+
+	newMockDb(t)
+
+	assertApiError(t, adjustTeamScores(c), http.StatusBadRequest, "team score adjustment is not valid, no adjustments: category: bestWriteup")
+}
+
+func TestAdjustTeamScoresNoTeam(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, teamScoreAdjustmentJson)
+
+	mock := newMockDb(t)
+	mock.insertTeamAdjustCampName = campaign
+	mock.insertTeamAdjustRequest = teamScoreAdjustmentRequest
+	mock.insertTeamAdjustErr = fmt.Errorf("no team: campaignName: %s, name: %s: %w", campaign, teamName, sql.ErrNoRows)
+
+	assertApiError(t, adjustTeamScores(c), http.StatusNotFound, "no team: campaignName: myCampaignName, name: myTeamName: sql: no rows in result set")
+}
+
+func TestAdjustTeamScores(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, teamScoreAdjustmentJson)
+
+	mock := newMockDb(t)
+	mock.insertTeamAdjustCampName = campaign
+	mock.insertTeamAdjustRequest = teamScoreAdjustmentRequest
+	mock.insertTeamAdjustResult = []types.TeamScoreEvent{
+		{Id: "eventId", TeamName: teamName, Kind: types.TeamScoreKindJudgedAward, Category: "bestWriteup", Points: 5, Reason: "very clear"},
+	}
+
+	assert.NoError(t, adjustTeamScores(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	jsonExpected, err := json.Marshal(mock.insertTeamAdjustResult)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpected)+"\n", rec.Body.String())
+}
+
+func TestGetTeamScoreHistory(t *testing.T) {
+	c, rec := setupMockContextTeamParams(campaign, teamName)
+
+	mock := newMockDb(t)
+	mock.selectTeamHistoryCampName = campaign
+	mock.selectTeamHistoryTeamName = teamName
+	mock.selectTeamHistoryResult = []types.TeamScoreEvent{
+		{TeamName: teamName, Kind: types.TeamScoreKindPullRequest, LoginName: loginName, Points: 3, Reason: "owner/repo #1"},
+		{Id: "eventId", TeamName: teamName, Kind: types.TeamScoreKindJudgedAward, Category: "bestWriteup", Points: 5, Reason: "very clear"},
+	}
+
+	assert.NoError(t, getTeamScoreHistory(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	jsonExpected, err := json.Marshal(mock.selectTeamHistoryResult)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpected)+"\n", rec.Body.String())
+}
+
+func TestCreateCampaignReportInvalidTop(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
+	c.Request().URL.RawQuery = "top=0"
+
+	assertApiError(t, createCampaignReport(c), http.StatusBadRequest, "invalid top: 0")
+}
+
+func TestCreateCampaignReportNoCampaign(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectReportCampName = campaign
+	mock.selectReportTopCount = defaultReportTopCount
+	mock.selectReportErr = sql.ErrNoRows
+
+	assertApiError(t, createCampaignReport(c), http.StatusNotFound, "no campaign: campaignName: myCampaignName")
+}
+
+func TestCreateCampaignReport(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+	c.Request().URL.RawQuery = "top=5"
+
+	mock := newMockDb(t)
+	mock.selectReportCampName = campaign
+	mock.selectReportTopCount = 5
+	mock.selectReportResult = &types.CampaignReport{
+		Campaign:        types.CampaignStruct{Name: campaign, StartOn: testStartOn, EndOn: testEndOn},
+		GeneratedOn:     testEndOn,
+		Stats:           types.CampaignStats{Participants: 1, PullRequests: 1, Points: 3},
+		TopParticipants: []types.ParticipantStruct{{LoginName: loginName, TeamName: teamName, Score: 3}},
+		Categories:      []types.ReportCategory{{Category: category, Count: 2, Points: 2}},
+		Repositories:    []types.RepositoryImpact{{RepoOwner: "owner", RepoName: "<repo>", PullRequests: 1, Points: 3}},
+	}
+	mock.upsertReportHtml = "<td>owner/&lt;repo&gt;</td>"
+
+	assert.NoError(t, createCampaignReport(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	jsonExpected, err := json.Marshal(mock.selectReportResult)
+	assert.NoError(t, err)
+	assert.Equal(t, string(jsonExpected)+"\n", rec.Body.String())
+}
+
+func TestCreateCampaignReportUpsertError(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectReportCampName = campaign
+	mock.selectReportTopCount = defaultReportTopCount
+	mock.selectReportResult = &types.CampaignReport{Campaign: types.CampaignStruct{Name: campaign}}
+	forcedError := fmt.Errorf("forced upsert error")
+	mock.upsertReportErr = forcedError
+
+	assert.EqualError(t, createCampaignReport(c), forcedError.Error())
+}
+
+func TestGetCampaignReportMissing(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectStoredReportCampName = campaign
+	mock.selectStoredReportErr = sql.ErrNoRows
+
+	assertApiError(t, getCampaignReport(c), http.StatusNotFound, "no report: campaignName: myCampaignName")
+}
+
+func TestGetCampaignReport(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectStoredReportCampName = campaign
+	mock.selectStoredReportJson = []byte(`{"stats":{"points":3}}`)
+
+	assert.NoError(t, getCampaignReport(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, echo.MIMEApplicationJSONCharsetUTF8, c.Response().Header().Get(echo.HeaderContentType))
+	assert.Equal(t, `{"stats":{"points":3}}`, rec.Body.String())
+}
+
+func TestGetCampaignReportHtml(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+	c.Request().URL.RawQuery = "format=html"
+
+	mock := newMockDb(t)
+	mock.selectStoredReportCampName = campaign
+	mock.selectStoredReportHtml = "<html></html>"
+
+	assert.NoError(t, getCampaignReport(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, echo.MIMETextHTMLCharsetUTF8, c.Response().Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "<html></html>", rec.Body.String())
+}
+
+type mockSlackNotifier struct {
+	webhookUrls []string
+	texts       []string
+	err         error
+}
+
+func (n *mockSlackNotifier) Post(webhookUrl, text string) (err error) {
+	n.webhookUrls = append(n.webhookUrls, webhookUrl)
+	n.texts = append(n.texts, text)
+	return n.err
+}
+
+func setupMockSlackNotifier(t *testing.T) (notifier *mockSlackNotifier) {
+	origNotifier := slackNotifier
+	t.Cleanup(func() {
+		slackNotifier = origNotifier
+	})
+	notifier = &mockSlackNotifier{}
+	slackNotifier = notifier
+	return
+}
+
+const slackWebhookUrl = "https://hooks.slack.com/services/T0/B0/secret"
+
+func TestNotifySlackNoConfig(t *testing.T) {
+	notifier := setupMockSlackNotifier(t)
+	newMockDb(t)
+
+	notifySlack(&types.ParticipantStruct{CampaignName: campaign, Score: 12}, 5)
+	assert.Nil(t, notifier.texts)
+}
+
+func TestNotifySlackConfigError(t *testing.T) {
+	notifier := setupMockSlackNotifier(t)
+	mock := newMockDb(t)
+	mock.selectSlackCampName = campaign
+	mock.selectSlackErr = fmt.Errorf("forced slack config error")
+
+	notifySlack(&types.ParticipantStruct{CampaignName: campaign, Score: 12}, 5)
+	assert.Nil(t, notifier.texts)
+}
+
+func TestNotifySlackScoreDecreased(t *testing.T) {
+	notifier := setupMockSlackNotifier(t)
+	newMockDb(t)
+
+	notifySlack(&types.ParticipantStruct{CampaignName: campaign, Score: 12}, -5)
+	assert.Nil(t, notifier.texts)
+}
+
+func setupMockDbSlack(t *testing.T, config types.SlackNotificationStruct) (mock *MockBBashDB) {
+	mock = newMockDb(t)
+	mock.selectSlackCampName = campaign
+	mock.selectSlackErr = nil
+	config.CampaignName = campaign
+	config.WebhookUrl = slackWebhookUrl
+	mock.selectSlackResult = &config
+	return
+}
+
+func TestNotifySlackThreshold(t *testing.T) {
+	notifier := setupMockSlackNotifier(t)
+	setupMockDbSlack(t, types.SlackNotificationStruct{PointThreshold: 10})
+
+	notifySlack(&types.ParticipantStruct{CampaignName: campaign, LoginName: loginName, Score: 12}, 5)
+	assert.Equal(t, []string{slackWebhookUrl}, notifier.webhookUrls)
+	assert.Equal(t, []string{"loginName reached 10 points in myCampaignName, and now has 12 points"}, notifier.texts)
+}
+
+func TestNotifySlackThresholdAlreadyReached(t *testing.T) {
+	notifier := setupMockSlackNotifier(t)
+	setupMockDbSlack(t, types.SlackNotificationStruct{PointThreshold: 10})
+
+	notifySlack(&types.ParticipantStruct{CampaignName: campaign, LoginName: loginName, Score: 12}, 2)
+	assert.Nil(t, notifier.texts)
+}
+
+func TestNotifySlackTookLead(t *testing.T) {
+	notifier := setupMockSlackNotifier(t)
+	mock := setupMockDbSlack(t, types.SlackNotificationStruct{NotifyLead: true})
+	mock.selectTopCampName = campaign
+	mock.selectTopCount = 2
+	mock.selectTopResult = []types.ParticipantStruct{{ID: participantID, Score: 12}, {ID: "other", Score: 10}}
+
+	notifySlack(&types.ParticipantStruct{ID: participantID, CampaignName: campaign, LoginName: loginName, Score: 12}, 5)
+	assert.Equal(t, []string{"loginName took the lead in myCampaignName with 12 points"}, notifier.texts)
+}
+
+func TestNotifySlackAlreadyLeading(t *testing.T) {
+	notifier := setupMockSlackNotifier(t)
+	mock := setupMockDbSlack(t, types.SlackNotificationStruct{NotifyLead: true})
+	mock.selectTopCampName = campaign
+	mock.selectTopCount = 2
+	mock.selectTopResult = []types.ParticipantStruct{{ID: participantID, Score: 12}, {ID: "other", Score: 5}}
+
+	notifySlack(&types.ParticipantStruct{ID: participantID, CampaignName: campaign, LoginName: loginName, Score: 12}, 3)
+	assert.Nil(t, notifier.texts)
+}
+
+func TestNotifySlackNotLeading(t *testing.T) {
+	notifier := setupMockSlackNotifier(t)
+	mock := setupMockDbSlack(t, types.SlackNotificationStruct{NotifyLead: true})
+	mock.selectTopCampName = campaign
+	mock.selectTopCount = 2
+	mock.selectTopResult = []types.ParticipantStruct{{ID: "other", Score: 20}, {ID: participantID, Score: 12}}
+
+	notifySlack(&types.ParticipantStruct{ID: participantID, CampaignName: campaign, LoginName: loginName, Score: 12}, 5)
+	assert.Nil(t, notifier.texts)
+}
+
+func TestNotifySlackLeadersError(t *testing.T) {
+	notifier := setupMockSlackNotifier(t)
+	mock := setupMockDbSlack(t, types.SlackNotificationStruct{PointThreshold: 10, NotifyLead: true})
+	mock.selectTopCampName = campaign
+	mock.selectTopCount = 2
+	mock.selectTopErr = fmt.Errorf("forced leaders error")
+
+	notifySlack(&types.ParticipantStruct{ID: participantID, CampaignName: campaign, LoginName: loginName, Score: 12}, 5)
+	assert.Equal(t, []string{"loginName reached 10 points in myCampaignName, and now has 12 points"}, notifier.texts)
+}
+
+func TestNotifySlackPostError(t *testing.T) {
+	notifier := setupMockSlackNotifier(t)
+	notifier.err = fmt.Errorf("forced post error")
+	mock := setupMockDbSlack(t, types.SlackNotificationStruct{PointThreshold: 10, NotifyLead: true})
+	mock.selectTopCampName = campaign
+	mock.selectTopCount = 2
+	mock.selectTopResult = []types.ParticipantStruct{{ID: participantID, Score: 12}}
+
+	notifySlack(&types.ParticipantStruct{ID: participantID, CampaignName: campaign, LoginName: loginName, Score: 12}, 12)
+	assert.Equal(t, 2, len(notifier.texts))
+}
+
+func TestGetSlackNotificationNotFound(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectSlackCampName = campaign
+
+	assertApiError(t, getSlackNotification(c), http.StatusNotFound, "no slack notification: campaignName: myCampaignName")
+}
+
+func TestGetSlackNotificationError(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectSlackCampName = campaign
+	forcedError := fmt.Errorf("forced slack config error")
+	mock.selectSlackErr = forcedError
+
+	assert.EqualError(t, getSlackNotification(c), forcedError.Error())
+}
+
+func TestGetSlackNotification(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+
+	setupMockDbSlack(t, types.SlackNotificationStruct{PointThreshold: 10})
+
+	assert.NoError(t, getSlackNotification(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `{"campaignName":"myCampaignName","webhookUrl":"`+slackWebhookUrl+`","pointThreshold":10,"notifyLead":false}`+"\n", rec.Body.String())
+}
+
+func TestPutSlackNotificationInvalid(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, `{"webhookUrl":"not a url","pointThreshold":-1}`)
+
+	newMockDb(t)
+
+	err := putSlackNotification(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, 2, len(toApiError(err).Details.([]fieldError)))
+}
+
+func TestPutSlackNotificationNoCampaign(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, `{"webhookUrl":"`+slackWebhookUrl+`"}`)
+
+	mock := newMockDb(t)
+	mock.upsertSlack = &types.SlackNotificationStruct{CampaignName: campaign, WebhookUrl: slackWebhookUrl}
+	mock.upsertSlackErr = sql.ErrNoRows
+
+	assertApiError(t, putSlackNotification(c), http.StatusNotFound, "no campaign: campaignName: myCampaignName")
+}
+
+func TestPutSlackNotification(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign,
+		`{"campaignName":"ignored","webhookUrl":"`+slackWebhookUrl+`","pointThreshold":100,"notifyLead":true}`)
+
+	mock := newMockDb(t)
+	mock.upsertSlack = &types.SlackNotificationStruct{CampaignName: campaign, WebhookUrl: slackWebhookUrl, PointThreshold: 100, NotifyLead: true}
+
+	assert.NoError(t, putSlackNotification(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `{"campaignName":"myCampaignName","webhookUrl":"`+slackWebhookUrl+`","pointThreshold":100,"notifyLead":true}`+"\n", rec.Body.String())
+}
+
+func TestGetCampaignPenaltyNotFound(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectPenaltyCampName = campaign
+	mock.selectPenaltyErr = sql.ErrNoRows
+
+	assertApiError(t, getCampaignPenalty(c), http.StatusNotFound, "no campaign penalty: campaignName: myCampaignName")
+}
+
+func TestGetCampaignPenalty(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectPenaltyCampName = campaign
+	mock.selectPenaltyResult = &types.CampaignPenaltyStruct{CampaignName: campaign, Enabled: true, PointValue: 2, Floor: true}
+
+	assert.NoError(t, getCampaignPenalty(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `{"campaignName":"myCampaignName","enabled":true,"pointValue":2,"floor":true}`+"\n", rec.Body.String())
+}
+
+func TestPutCampaignPenaltyInvalid(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, `{"enabled":true,"pointValue":-1}`)
+
+	newMockDb(t)
+
+	err := putCampaignPenalty(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{{Field: "pointValue", Message: "must be at least 0"}}, toApiError(err).Details)
+}
+
+func TestPutCampaignPenaltyNoCampaign(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, `{"enabled":true,"pointValue":1}`)
+
+	mock := newMockDb(t)
+	mock.upsertPenalty = &types.CampaignPenaltyStruct{CampaignName: campaign, Enabled: true, PointValue: 1}
+	mock.upsertPenaltyErr = sql.ErrNoRows
+
+	assertApiError(t, putCampaignPenalty(c), http.StatusNotFound, "no campaign: campaignName: myCampaignName")
+}
+
+func TestPutCampaignPenalty(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, `{"campaignName":"ignored","enabled":true,"pointValue":3,"floor":true}`)
+
+	mock := newMockDb(t)
+	mock.upsertPenalty = &types.CampaignPenaltyStruct{CampaignName: campaign, Enabled: true, PointValue: 3, Floor: true}
+
+	assert.NoError(t, putCampaignPenalty(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `{"campaignName":"myCampaignName","enabled":true,"pointValue":3,"floor":true}`+"\n", rec.Body.String())
+}
+
+func TestGetCampaignCapsNotFound(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectCapsCampName = campaign
+
+	assertApiError(t, getCampaignCaps(c), http.StatusNotFound, "no campaign caps: campaignName: myCampaignName")
+}
+
+func TestGetCampaignCaps(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectCapsCampName = campaign
+	mock.selectCapsResult = &types.CampaignCapsStruct{CampaignName: campaign, PerPullRequest: 10, PerDay: 50}
+	mock.selectCapsErr = nil
+
+	assert.NoError(t, getCampaignCaps(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `{"campaignName":"myCampaignName","perPullRequest":10,"perDay":50}`+"\n", rec.Body.String())
+}
+
+func TestPutCampaignCapsInvalid(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, `{"perPullRequest":10,"perDay":-1}`)
+
+	newMockDb(t)
+
+	err := putCampaignCaps(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{{Field: "perDay", Message: "must be at least 0"}}, toApiError(err).Details)
+}
+
+func TestPutCampaignCapsNoCampaign(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, `{"perPullRequest":10}`)
+
+	mock := newMockDb(t)
+	mock.upsertCaps = &types.CampaignCapsStruct{CampaignName: campaign, PerPullRequest: 10}
+	mock.upsertCapsErr = sql.ErrNoRows
+
+	assertApiError(t, putCampaignCaps(c), http.StatusNotFound, "no campaign: campaignName: myCampaignName")
+}
+
+func TestPutCampaignCaps(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, `{"campaignName":"ignored","perPullRequest":10,"perDay":50}`)
+
+	mock := newMockDb(t)
+	mock.upsertCaps = &types.CampaignCapsStruct{CampaignName: campaign, PerPullRequest: 10, PerDay: 50}
+
+	assert.NoError(t, putCampaignCaps(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `{"campaignName":"myCampaignName","perPullRequest":10,"perDay":50}`+"\n", rec.Body.String())
+}
+
+func TestGetDuplicatePolicyNotFound(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectDuplicatePolicyCampName = campaign
+
+	assertApiError(t, getDuplicatePolicy(c), http.StatusNotFound, "no duplicate policy: campaignName: myCampaignName")
+}
+
+func TestGetDuplicatePolicy(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectDuplicatePolicyCampName = campaign
+	mock.selectDuplicatePolicyResult = &types.DuplicatePolicyStruct{CampaignName: campaign, Policy: types.DuplicatePolicyDownweight, Weight: 0.5}
+	mock.selectDuplicatePolicyErr = nil
+
+	assert.NoError(t, getDuplicatePolicy(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `{"campaignName":"myCampaignName","policy":"downweight","weight":0.5}`+"\n", rec.Body.String())
+}
+
+func TestPutDuplicatePolicyInvalid(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, `{"policy":"ignore","weight":2}`)
+
+	newMockDb(t)
+
+	err := putDuplicatePolicy(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{
+		{Field: "policy", Message: "must be one of: skip downweight score"},
+		{Field: "weight", Message: "must be at most 1"},
+	}, toApiError(err).Details)
+}
+
+func TestPutDuplicatePolicyNoCampaign(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, `{"policy":"skip"}`)
+
+	mock := newMockDb(t)
+	mock.upsertDuplicatePolicy = &types.DuplicatePolicyStruct{CampaignName: campaign, Policy: types.DuplicatePolicySkip}
+	mock.upsertDuplicatePolicyErr = sql.ErrNoRows
+
+	assertApiError(t, putDuplicatePolicy(c), http.StatusNotFound, "no campaign: campaignName: myCampaignName")
+}
+
+func TestPutDuplicatePolicy(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, `{"campaignName":"ignored","policy":"downweight","weight":0.5}`)
+
+	mock := newMockDb(t)
+	mock.upsertDuplicatePolicy = &types.DuplicatePolicyStruct{CampaignName: campaign, Policy: types.DuplicatePolicyDownweight, Weight: 0.5}
+
+	assert.NoError(t, putDuplicatePolicy(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `{"campaignName":"myCampaignName","policy":"downweight","weight":0.5}`+"\n", rec.Body.String())
+}
+
+func TestGetCoAuthorPolicyNotFound(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectCoAuthorPolicyCampName = campaign
+
+	assertApiError(t, getCoAuthorPolicy(c), http.StatusNotFound, "no co-author policy: campaignName: myCampaignName")
+}
+
+func TestGetCoAuthorPolicy(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectCoAuthorPolicyCampName = campaign
+	mock.selectCoAuthorPolicyResult = &types.CoAuthorPolicyStruct{CampaignName: campaign, Policy: types.CoAuthorPolicyDuplicate}
+	mock.selectCoAuthorPolicyErr = nil
+
+	assert.NoError(t, getCoAuthorPolicy(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `{"campaignName":"myCampaignName","policy":"duplicate"}`+"\n", rec.Body.String())
+}
+
+func TestPutCoAuthorPolicyInvalid(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, `{"policy":"share"}`)
+
+	newMockDb(t)
+
+	err := putCoAuthorPolicy(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{{Field: "policy", Message: "must be one of: split duplicate"}}, toApiError(err).Details)
+}
+
+func TestPutCoAuthorPolicyNoCampaign(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, `{"policy":"split"}`)
+
+	mock := newMockDb(t)
+	mock.upsertCoAuthorPolicy = &types.CoAuthorPolicyStruct{CampaignName: campaign, Policy: types.CoAuthorPolicySplit}
+	mock.upsertCoAuthorPolicyErr = sql.ErrNoRows
+
+	assertApiError(t, putCoAuthorPolicy(c), http.StatusNotFound, "no campaign: campaignName: myCampaignName")
+}
+
+func TestPutCoAuthorPolicy(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, `{"campaignName":"ignored","policy":"duplicate"}`)
+
+	mock := newMockDb(t)
+	mock.upsertCoAuthorPolicy = &types.CoAuthorPolicyStruct{CampaignName: campaign, Policy: types.CoAuthorPolicyDuplicate}
+
+	assert.NoError(t, putCoAuthorPolicy(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `{"campaignName":"myCampaignName","policy":"duplicate"}`+"\n", rec.Body.String())
+}
+
+func TestGetCampaignPrivacyNotFound(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectPrivacyCampName = campaign
+
+	assertApiError(t, getCampaignPrivacy(c), http.StatusNotFound, "no privacy setting: campaignName: myCampaignName")
+}
+
+func TestGetCampaignPrivacy(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	setupMockAnonymized(mock)
+
+	assert.NoError(t, getCampaignPrivacy(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `{"campaignName":"myCampaignName","anonymize":true}`+"\n", rec.Body.String())
+}
+
+func TestPutCampaignPrivacyNoCampaign(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, `{"anonymize":true}`)
+
+	mock := newMockDb(t)
+	mock.upsertPrivacy = &types.CampaignPrivacyStruct{CampaignName: campaign, Anonymize: true}
+	mock.upsertPrivacyErr = sql.ErrNoRows
+
+	assertApiError(t, putCampaignPrivacy(c), http.StatusNotFound, "no campaign: campaignName: myCampaignName")
+}
+
+func TestPutCampaignPrivacy(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, `{"campaignName":"ignored","anonymize":true}`)
+
+	mock := newMockDb(t)
+	mock.upsertPrivacy = &types.CampaignPrivacyStruct{CampaignName: campaign, Anonymize: true}
+
+	assert.NoError(t, putCampaignPrivacy(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `{"campaignName":"myCampaignName","anonymize":true}`+"\n", rec.Body.String())
+}
+
+func TestDeleteSlackNotificationNotFound(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.deleteSlackCampName = campaign
+
+	assertApiError(t, deleteSlackNotification(c), http.StatusNotFound, "no slack notification: campaignName: myCampaignName")
+}
+
+func TestDeleteSlackNotification(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.deleteSlackCampName = campaign
+	mock.deleteSlackRowsAffected = 1
+
+	assert.NoError(t, deleteSlackNotification(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+type mockWebhookDeliverer struct {
+	hooks  []types.WebhookStruct
+	events []types.WebhookEvent
+}
+
+func (d *mockWebhookDeliverer) Deliver(hook types.WebhookStruct, event types.WebhookEvent) {
+	d.hooks = append(d.hooks, hook)
+	d.events = append(d.events, event)
+}
+
+func setupMockWebhookDeliverer(t *testing.T) (deliverer *mockWebhookDeliverer) {
+	origDeliverer := webhookDeliverer
+	t.Cleanup(func() {
+		webhookDeliverer = origDeliverer
+	})
+	deliverer = &mockWebhookDeliverer{}
+	webhookDeliverer = deliverer
+	return
+}
+
+const webhookUrl = "https://example.com/bbash"
+const webhookSecret = "mySecretThatIsLongEnough"
+
+func TestPublishWebhookEventLookupError(t *testing.T) {
+	deliverer := setupMockWebhookDeliverer(t)
+	mock := newMockDb(t)
+	mock.selectWebhooksErr = fmt.Errorf("forced webhook lookup error")
+
+	publishWebhookEvent(context.Background(), types.WebhookEventScoreChanged, nil)
+	assert.Nil(t, deliverer.hooks)
+}
+
+func TestPublishWebhookEvent(t *testing.T) {
+	deliverer := setupMockWebhookDeliverer(t)
+	mock := newMockDb(t)
+	mock.selectWebhooksResult = []types.WebhookStruct{
+		{ID: "all"},
+		{ID: "scores", Events: []string{types.WebhookEventScoreChanged}},
+		{ID: "campaigns", Events: []string{types.WebhookEventCampaignEnded}},
+	}
+
+	delta := types.ScoreDelta{CampaignName: campaign, Delta: 3}
+	publishWebhookEvent(context.Background(), types.WebhookEventScoreChanged, delta)
+	assert.Equal(t, []types.WebhookStruct{mock.selectWebhooksResult[0], mock.selectWebhooksResult[1]}, deliverer.hooks)
+	assert.Equal(t, 2, len(deliverer.events))
+	assert.Equal(t, types.WebhookEventScoreChanged, deliverer.events[0].Event)
+	assert.Equal(t, delta, deliverer.events[0].Data)
+}
+
+func TestPublishCampaignTransitionsError(t *testing.T) {
+	deliverer := setupMockWebhookDeliverer(t)
+	mock := newMockDb(t)
+	mock.claimTransitionsNow = now
+	mock.claimTransitionsErr = fmt.Errorf("forced claim error")
+	mock.selectWebhooksResult = []types.WebhookStruct{{ID: "all"}}
+
+	publishCampaignTransitions(now)
+	assert.Nil(t, deliverer.events)
+}
+
+func TestPublishCampaignTransitionsStarted(t *testing.T) {
+	deliverer := setupMockWebhookDeliverer(t)
+	notifier := setupMockSlackNotifier(t)
+	mock := newMockDb(t)
+	mock.claimTransitionsNow = now
+	startedCampaign := types.CampaignStruct{Name: campaign, StartOn: now, Status: types.CampaignStatusActive}
+	mock.claimTransitionsResult = []types.CampaignTransition{{Campaign: startedCampaign, FromStatus: types.CampaignStatusUpcoming}}
+	mock.selectWebhooksResult = []types.WebhookStruct{{ID: "started", Events: []string{types.WebhookEventCampaignStarted}}}
+	mock.selectSlackErr = nil
+	mock.selectSlackResult = &types.SlackNotificationStruct{CampaignName: campaign, WebhookUrl: slackWebhookUrl}
+
+	publishCampaignTransitions(now)
+	assert.Equal(t, 1, len(deliverer.events))
+	assert.Equal(t, types.WebhookEventCampaignStarted, deliverer.events[0].Event)
+	assert.Equal(t, startedCampaign, deliverer.events[0].Data)
+	assert.Equal(t, []string{"myCampaignName has started, happy bug hunting"}, notifier.texts)
+	assert.Nil(t, insertAchievementKinds)
+}
+
+func TestPublishCampaignTransitionsEnded(t *testing.T) {
+	deliverer := setupMockWebhookDeliverer(t)
+	notifier := setupMockSlackNotifier(t)
+	mock := newMockDb(t)
+	mock.claimTransitionsNow = now
+	endedCampaign := types.CampaignStruct{Name: campaign, EndOn: now, Status: types.CampaignStatusEnded}
+	mock.claimTransitionsResult = []types.CampaignTransition{{Campaign: endedCampaign, FromStatus: types.CampaignStatusActive, FirstEnd: true}}
+	mock.selectWebhooksResult = []types.WebhookStruct{{ID: "all"}}
+	mock.selectTopCampName = campaign
+	mock.selectTopCount = achievementTopFinishers
+	mock.selectTopResult = []types.ParticipantStruct{{ID: participantID, CampaignName: campaign, Score: 5}}
+	refreshed, snapshotted := false, false
+	mock.refreshLeaderboardCalled = &refreshed
+	mock.insertSnapshotCampName = campaign
+	mock.insertSnapshotCalled = &snapshotted
+	mock.insertSnapshotFinalized = true
+
+	publishCampaignTransitions(now)
+	assert.Equal(t, 1, len(deliverer.events))
+	assert.Equal(t, types.WebhookEventCampaignEnded, deliverer.events[0].Event)
+	assert.Equal(t, endedCampaign, deliverer.events[0].Data)
+	assert.True(t, refreshed)
+	assert.True(t, snapshotted)
+	assert.Equal(t, []string{participantID + ":" + types.AchievementTopThree}, insertAchievementKinds)
+	// the campaign has no Slack channel
+	assert.Nil(t, notifier.texts)
+}
+
+func TestPublishCampaignTransitionsEndedAgain(t *testing.T) {
+	deliverer := setupMockWebhookDeliverer(t)
+	mock := newMockDb(t)
+	mock.claimTransitionsNow = now
+	endedCampaign := types.CampaignStruct{Name: campaign, EndOn: now, Status: types.CampaignStatusEnded}
+	mock.claimTransitionsResult = []types.CampaignTransition{{Campaign: endedCampaign, FromStatus: types.CampaignStatusActive}}
+	mock.selectWebhooksResult = []types.WebhookStruct{{ID: "all"}}
+	mock.selectTopResult = []types.ParticipantStruct{{ID: participantID, CampaignName: campaign, Score: 5}}
+	snapshotted := false
+	mock.insertSnapshotCalled = &snapshotted
+
+	publishCampaignTransitions(now)
+	assert.Equal(t, 1, len(deliverer.events))
+	assert.Equal(t, types.WebhookEventCampaignEnded, deliverer.events[0].Event)
+	// the top finishers were awarded, and the leaderboard finalized, when the campaign first ended
+	assert.Nil(t, insertAchievementKinds)
+	assert.False(t, snapshotted)
+}
+
+func TestPublishCampaignTransitionsRescheduled(t *testing.T) {
+	deliverer := setupMockWebhookDeliverer(t)
+	mock := newMockDb(t)
+	mock.claimTransitionsNow = now
+	rescheduledCampaign := types.CampaignStruct{Name: campaign, StartOn: now.Add(time.Hour), Status: types.CampaignStatusUpcoming}
+	mock.claimTransitionsResult = []types.CampaignTransition{{Campaign: rescheduledCampaign, FromStatus: types.CampaignStatusActive}}
+	mock.selectWebhooksResult = []types.WebhookStruct{{ID: "all"}}
+
+	publishCampaignTransitions(now)
+	assert.Nil(t, deliverer.events)
+}
+
+func TestAddParticipantPublishesWebhookEvent(t *testing.T) {
+	deliverer := setupMockWebhookDeliverer(t)
+	participantJson := fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s"}`, campaign, scpName, loginName)
+	c, _ := setupMockContextParticipant(participantJson)
+
+	mock := newMockDb(t)
+	mock.insertParticipantPartier = &types.ParticipantStruct{
+		CampaignName: campaign,
+		ScpName:      scpName,
+		LoginName:    loginName,
+	}
+	mock.insertParticipantGuid = participantID
+	mock.insertParticipantJoinedAt = now
+	mock.selectWebhooksResult = []types.WebhookStruct{{ID: "participants", Events: []string{types.WebhookEventParticipantAdded}}}
+
+	assert.NoError(t, addParticipant(c))
+	assert.Equal(t, 1, len(deliverer.events))
+	assert.Equal(t, types.WebhookEventParticipantAdded, deliverer.events[0].Event)
+	assert.Equal(t, participantID, deliverer.events[0].Data.(types.ParticipantStruct).ID)
+}
+
+func TestAddWebhookInvalid(t *testing.T) {
+	c, _ := setupMockContextWithBody(http.MethodPost, `{"targetUrl":"not a url","secret":"short","events":["unknown"]}`)
+
+	newMockDb(t)
+
+	err := addWebhook(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, 3, len(toApiError(err).Details.([]fieldError)))
+}
+
+func TestAddWebhookError(t *testing.T) {
+	c, _ := setupMockContextWithBody(http.MethodPost, `{"targetUrl":"`+webhookUrl+`","secret":"`+webhookSecret+`"}`)
+
+	mock := newMockDb(t)
+	mock.insertWebhook = &types.WebhookStruct{TargetUrl: webhookUrl, Secret: webhookSecret}
+	forcedError := fmt.Errorf("forced insert webhook error")
+	mock.insertWebhookErr = forcedError
+
+	assert.EqualError(t, addWebhook(c), forcedError.Error())
+}
+
+func TestAddWebhook(t *testing.T) {
+	c, rec := setupMockContextWithBody(http.MethodPost,
+		`{"targetUrl":"`+webhookUrl+`","secret":"`+webhookSecret+`","events":["score.changed"]}`)
+
+	mock := newMockDb(t)
+	mock.insertWebhook = &types.WebhookStruct{TargetUrl: webhookUrl, Secret: webhookSecret, Events: []string{types.WebhookEventScoreChanged}}
+	mock.insertWebhookId = "myWebhookId"
+	mock.insertWebhookCreatedOn = testStartOn
+
+	assert.NoError(t, addWebhook(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	assert.Equal(t, `{"guid":"myWebhookId","endpoints":{"webhookDelete":{"uri":"","httpVerb":"DELETE"},"webhookList":{"uri":"","httpVerb":"GET"}},`+
+		`"object":{"guid":"myWebhookId","targetUrl":"`+webhookUrl+`","events":["score.changed"],"createdOn":"2021-11-01T12:00:00Z"}}`+"\n", rec.Body.String())
+}
+
+func TestAddWebhookApiVersion1(t *testing.T) {
+	c, rec := setupMockContextWithBody(http.MethodPost,
+		`{"targetUrl":"`+webhookUrl+`","secret":"`+webhookSecret+`","events":["score.changed"]}`)
+	c.Request().Header.Set(headerApiVersion, apiVersion1)
+
+	mock := newMockDb(t)
+	mock.insertWebhook = &types.WebhookStruct{TargetUrl: webhookUrl, Secret: webhookSecret, Events: []string{types.WebhookEventScoreChanged}}
+	mock.insertWebhookId = "myWebhookId"
+	mock.insertWebhookCreatedOn = testStartOn
+
+	assert.NoError(t, addWebhook(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	assert.Equal(t, `{"guid":"myWebhookId","targetUrl":"`+webhookUrl+`","events":["score.changed"],"createdOn":"2021-11-01T12:00:00Z"}`+"\n", rec.Body.String())
+}
+
+func TestAddWebhookSecretUnresolved(t *testing.T) {
+	c, _ := setupMockContextWithBody(http.MethodPost, `{"targetUrl":"`+webhookUrl+`","secret":"vault:kv/bbash#webhookSecret"}`)
+
+	newMockDb(t)
+
+	assertApiError(t, addWebhook(c), http.StatusBadRequest,
+		"invalid secret: vault secret kv/bbash#webhookSecret: no vault secrets backend is configured")
+}
+
+func TestResolveSecretsVault(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/secret/data/bbash", r.URL.Path)
+		assert.Equal(t, "theVaultToken", r.Header.Get("X-Vault-Token"))
+		_, _ = fmt.Fprint(w, `{"data":{"data":{"pgPassword":"thePgPassword","webhookSecret":"theWebhookSecret"},"metadata":{}}}`)
+	}))
+	defer ts.Close()
+	origSecretResolvers := secretResolvers
+	defer func() {
+		secretResolvers = origSecretResolvers
+	}()
+
+	cfg := config.Defaults()
+	cfg.VaultAddr, cfg.VaultToken = ts.URL, "theVaultToken"
+	cfg.PGPassword = "vault:secret/data/bbash#pgPassword"
+	cfg.AdminPassword = "theAdminPassword"
+	secretResolvers = secretResolversFromConfig(cfg)
+
+	assert.NoError(t, resolveSecrets(cfg))
+	assert.Equal(t, "thePgPassword", cfg.PGPassword)
+	assert.Equal(t, "theAdminPassword", cfg.AdminPassword)
+	assert.Equal(t, "theVaultToken", cfg.VaultToken)
+
+	secret, err := resolveWebhookSecret("vault:secret/data/bbash#webhookSecret")
+	assert.NoError(t, err)
+	assert.Equal(t, "theWebhookSecret", secret)
+}
+
+func TestResolveSecretsNoBackend(t *testing.T) {
+	origSecretResolvers := secretResolvers
+	defer func() {
+		secretResolvers = origSecretResolvers
+	}()
+
+	cfg := config.Defaults()
+	cfg.PGPassword = "awssm:bbash/prod#pgPassword"
+	// no region, so no secrets backend
+	cfg.AwsAccessKeyId = "theAccessKeyId"
+	secretResolvers = secretResolversFromConfig(cfg)
+
+	assert.EqualError(t, resolveSecrets(cfg), "invalid PG_PASSWORD: awssm secret bbash/prod#pgPassword: no awssm secrets backend is configured")
+}
+
+func TestGetWebhooksError(t *testing.T) {
+	c, _ := setupMockContext()
+
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced webhook lookup error")
+	mock.selectWebhooksErr = forcedError
+
+	assert.EqualError(t, getWebhooks(c), forcedError.Error())
+}
+
+func TestGetWebhooks(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	mock.selectWebhooksResult = []types.WebhookStruct{{ID: "myWebhookId", TargetUrl: webhookUrl, Secret: webhookSecret, Events: []string{}, CreatedOn: testStartOn}}
+
+	assert.NoError(t, getWebhooks(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `[{"guid":"myWebhookId","targetUrl":"`+webhookUrl+`","events":[],"createdOn":"2021-11-01T12:00:00Z"}]`+"\n", rec.Body.String())
+}
+
+func setupMockContextWebhook(webhookId string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamWebhookId)
+	c.SetParamValues(webhookId)
+	return
+}
+
+func TestDeleteWebhookNotFound(t *testing.T) {
+	c, _ := setupMockContextWebhook("myWebhookId")
+
+	mock := newMockDb(t)
+	mock.deleteWebhookId = "myWebhookId"
+
+	assertApiError(t, deleteWebhook(c), http.StatusNotFound, "no webhook: webhookId: myWebhookId")
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	c, rec := setupMockContextWebhook("myWebhookId")
+
+	mock := newMockDb(t)
+	mock.deleteWebhookId = "myWebhookId"
+	mock.deleteWebhookRowsAffected = 1
+
+	assert.NoError(t, deleteWebhook(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestAddBanInvalid(t *testing.T) {
+	c, _ := setupMockContextWithBody(http.MethodPut, `{"scpName": "GitHub"}`)
+
+	newMockDb(t)
+
+	err := addBan(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{{Field: "loginName", Message: "is required"}}, toApiError(err).Details)
+}
+
+func TestAddBanDuplicate(t *testing.T) {
+	c, _ := setupMockContextWithBody(http.MethodPut, `{"scpName": "GitHub", "loginName": "cheater"}`)
+
+	mock := newMockDb(t)
+	mock.insertBanParam = &types.BanStruct{ScpName: "GitHub", LoginName: "cheater"}
+	mock.insertBanErr = &db.DuplicateError{Entity: "ban"}
+
+	assertApiError(t, addBan(c), http.StatusConflict, "ban already exists")
+}
+
+func TestAddBan(t *testing.T) {
+	c, rec := setupMockContextWithBody(http.MethodPut, `{"scpName": "GitHub", "loginName": "cheater", "reason": "scored forks"}`)
+
+	mock := newMockDb(t)
+	mock.insertBanParam = &types.BanStruct{ScpName: "GitHub", LoginName: "cheater", Reason: "scored forks"}
+	mock.insertBanId = "banGuid"
+
+	assert.NoError(t, addBan(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	creation := assertCreation(t, rec, "banGuid")
+	assert.Equal(t, http.MethodDelete, creation.Endpoints["banDelete"].Verb)
+}
+
+func TestGetBans(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	mock.selectBansResult = []types.BanStruct{{ID: "banGuid", ScpName: "GitHub", LoginName: "cheater"}}
+
+	assert.NoError(t, getBans(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var bans []types.BanStruct
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &bans))
+	assert.Equal(t, mock.selectBansResult, bans)
+}
+
+func setupMockContextBan(scpName, loginName string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamScpName, ParamLoginName)
+	c.SetParamValues(scpName, loginName)
+	return
+}
+
+func TestDeleteBanNotFound(t *testing.T) {
+	c, _ := setupMockContextBan("GitHub", "cheater")
+
+	mock := newMockDb(t)
+	mock.deleteBanScpName = "GitHub"
+	mock.deleteBanLoginName = "cheater"
+
+	assertApiError(t, deleteBan(c), http.StatusNotFound, "no ban: scpName: GitHub, loginName: cheater")
+}
+
+func TestDeleteBan(t *testing.T) {
+	c, rec := setupMockContextBan("GitHub", "cheater")
+
+	mock := newMockDb(t)
+	mock.deleteBanScpName = "GitHub"
+	mock.deleteBanLoginName = "cheater"
+	mock.deleteBanRowsAffected = 1
+
+	assert.NoError(t, deleteBan(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func setupMockContextRepositoryFilter(method, body string, paramValues ...string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamCampaignName, ParamRepositoryFilter)
+	c.SetParamValues(append([]string{campaign}, paramValues...)...)
+	return
+}
+
+func TestAddRepositoryFilterInvalid(t *testing.T) {
+	c, _ := setupMockContextRepositoryFilter(http.MethodPut, `{"kind": "maybe", "pattern": "*-docs"}`)
+
+	newMockDb(t)
+
+	err := addRepositoryFilter(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{{Field: "kind", Message: "must be one of: allow deny"}}, toApiError(err).Details)
+}
+
+func TestAddRepositoryFilterBadPattern(t *testing.T) {
+	c, _ := setupMockContextRepositoryFilter(http.MethodPut, `{"kind": "deny", "pattern": "[docs"}`)
+
+	newMockDb(t)
+
+	assertApiError(t, addRepositoryFilter(c), http.StatusBadRequest, "invalid pattern: [docs")
+}
+
+func TestAddRepositoryFilterNoCampaign(t *testing.T) {
+	c, _ := setupMockContextRepositoryFilter(http.MethodPut, `{"kind": "deny", "pattern": "*-docs"}`)
+
+	mock := newMockDb(t)
+	mock.insertRepoFilter = &types.RepositoryFilter{CampaignName: campaign, Kind: types.RepositoryFilterDeny, Pattern: "*-docs"}
+	mock.insertRepoFilterErr = sql.ErrNoRows
+
+	assertApiError(t, addRepositoryFilter(c), http.StatusNotFound, "no campaign: campaignName: myCampaignName")
+}
+
+func TestAddRepositoryFilterDuplicate(t *testing.T) {
+	c, _ := setupMockContextRepositoryFilter(http.MethodPut, `{"kind": "deny", "pattern": "*-docs"}`)
+
+	mock := newMockDb(t)
+	mock.insertRepoFilter = &types.RepositoryFilter{CampaignName: campaign, Kind: types.RepositoryFilterDeny, Pattern: "*-docs"}
+	mock.insertRepoFilterErr = &db.DuplicateError{Entity: "repository filter"}
+
+	assertApiError(t, addRepositoryFilter(c), http.StatusConflict, "repository filter already exists")
+}
+
+func TestAddRepositoryFilter(t *testing.T) {
+	c, rec := setupMockContextRepositoryFilter(http.MethodPut, `{"campaignName": "ignored", "kind": "allow", "pattern": "widget*"}`)
+
+	mock := newMockDb(t)
+	mock.insertRepoFilter = &types.RepositoryFilter{CampaignName: campaign, Kind: types.RepositoryFilterAllow, Pattern: "widget*"}
+	mock.insertRepoFilterId = "filterGuid"
+
+	assert.NoError(t, addRepositoryFilter(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	creation := assertCreation(t, rec, "filterGuid")
+	assert.Equal(t, http.MethodDelete, creation.Endpoints["repositoryFilterDelete"].Verb)
+}
+
+func TestGetRepositoryFilters(t *testing.T) {
+	c, rec := setupMockContextRepositoryFilter(http.MethodGet, "")
+
+	mock := newMockDb(t)
+	mock.selectRepoFiltersCampName = campaign
+	mock.selectRepoFiltersResult = []types.RepositoryFilter{{Id: "filterGuid", CampaignName: campaign, Kind: types.RepositoryFilterDeny, Pattern: "*-docs"}}
+
+	assert.NoError(t, getRepositoryFilters(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var filters []types.RepositoryFilter
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &filters))
+	assert.Equal(t, mock.selectRepoFiltersResult, filters)
+}
+
+func TestDeleteRepositoryFilterNotFound(t *testing.T) {
+	c, _ := setupMockContextRepositoryFilter(http.MethodDelete, "", "filterGuid")
+
+	mock := newMockDb(t)
+	mock.deleteRepoFilterCampName = campaign
+	mock.deleteRepoFilterId = "filterGuid"
+
+	assertApiError(t, deleteRepositoryFilter(c), http.StatusNotFound, "no repository filter: campaignName: myCampaignName, id: filterGuid")
+}
+
+func TestDeleteRepositoryFilter(t *testing.T) {
+	c, rec := setupMockContextRepositoryFilter(http.MethodDelete, "", "filterGuid")
+
+	mock := newMockDb(t)
+	mock.deleteRepoFilterCampName = campaign
+	mock.deleteRepoFilterId = "filterGuid"
+	mock.deleteRepoFilterRowsAffected = 1
+
+	assert.NoError(t, deleteRepositoryFilter(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func setupMockContextBugCategoryExclusion(method string, paramValues ...string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, "/", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamCampaignName, ParamBugCategory)
+	c.SetParamValues(append([]string{campaign}, paramValues...)...)
+	return
+}
+
+func TestAddBugCategoryExclusionNoCampaign(t *testing.T) {
+	c, _ := setupMockContextBugCategoryExclusion(http.MethodPut, "noisyBugType")
+
+	mock := newMockDb(t)
+	mock.insertExclusion = &types.BugCategoryExclusion{CampaignName: campaign, Category: "noisyBugType"}
+	mock.insertExclusionErr = sql.ErrNoRows
+
+	assertApiError(t, addBugCategoryExclusion(c), http.StatusNotFound, "no campaign: campaignName: myCampaignName")
+}
+
+func TestAddBugCategoryExclusionDuplicate(t *testing.T) {
+	c, _ := setupMockContextBugCategoryExclusion(http.MethodPut, "noisyBugType")
+
+	mock := newMockDb(t)
+	mock.insertExclusion = &types.BugCategoryExclusion{CampaignName: campaign, Category: "noisyBugType"}
+	mock.insertExclusionErr = &db.DuplicateError{Entity: "bug category exclusion"}
+
+	assertApiError(t, addBugCategoryExclusion(c), http.StatusConflict, "bug category exclusion already exists")
+}
+
+func TestAddBugCategoryExclusion(t *testing.T) {
+	c, rec := setupMockContextBugCategoryExclusion(http.MethodPut, "noisyBugType")
+
+	mock := newMockDb(t)
+	mock.insertExclusion = &types.BugCategoryExclusion{CampaignName: campaign, Category: "noisyBugType"}
+	mock.insertExclusionId = "exclusionGuid"
+
+	assert.NoError(t, addBugCategoryExclusion(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	creation := assertCreation(t, rec, "exclusionGuid")
+	assert.Equal(t, http.MethodDelete, creation.Endpoints["exclusionDelete"].Verb)
+}
+
+func TestGetBugCategoryExclusions(t *testing.T) {
+	c, rec := setupMockContextBugCategoryExclusion(http.MethodGet)
+
+	mock := newMockDb(t)
+	mock.selectExclusionsCampName = campaign
+	mock.selectExclusionsResult = []types.BugCategoryExclusion{{Id: "exclusionGuid", CampaignName: campaign, Category: "noisyBugType"}}
+
+	assert.NoError(t, getBugCategoryExclusions(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var exclusions []types.BugCategoryExclusion
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &exclusions))
+	assert.Equal(t, mock.selectExclusionsResult, exclusions)
+}
+
+func TestDeleteBugCategoryExclusionNotFound(t *testing.T) {
+	c, _ := setupMockContextBugCategoryExclusion(http.MethodDelete, "noisyBugType")
+
+	mock := newMockDb(t)
+	mock.deleteExclusionCampName = campaign
+	mock.deleteExclusionCategory = "noisyBugType"
+
+	assertApiError(t, deleteBugCategoryExclusion(c), http.StatusNotFound, "no bug category exclusion: campaignName: myCampaignName, category: noisyBugType")
+}
+
+func TestDeleteBugCategoryExclusion(t *testing.T) {
+	c, rec := setupMockContextBugCategoryExclusion(http.MethodDelete, "noisyBugType")
+
+	mock := newMockDb(t)
+	mock.deleteExclusionCampName = campaign
+	mock.deleteExclusionCategory = "noisyBugType"
+	mock.deleteExclusionRowsAffected = 1
+
+	assert.NoError(t, deleteBugCategoryExclusion(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+type mockMailer struct {
+	sent []mail.Message
+}
+
+func (m *mockMailer) Send(msg mail.Message) {
+	m.sent = append(m.sent, msg)
+}
+
+func setupMockMailer(t *testing.T) (sender *mockMailer) {
+	origMailer := mailer
+	t.Cleanup(func() {
+		mailer = origMailer
+	})
+	sender = &mockMailer{}
+	mailer = sender
+	return
+}
+
+const participantEmail = "participant@example.com"
+
+func TestMailerFromConfig(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.MailFrom = "bugbash@example.com"
+	assert.Nil(t, mailerFromConfig(cfg))
+
+	cfg.SmtpHost = "smtp.example.com"
+	cfg.SmtpPort = 25
+	assert.NotNil(t, mailerFromConfig(cfg))
+
+	cfg.MailFrom = ""
+	assert.Nil(t, mailerFromConfig(cfg))
+}
+
+func TestRenderCampaignEmail(t *testing.T) {
+	email := types.CampaignEmailStruct{
+		Subject: "Results of {{.CampaignName}}",
+		Body:    "{{.Participant.LoginName}}, you placed {{.Rank}} of {{.ParticipantCount}}",
+	}
+	subject, body, err := renderCampaignEmail(&email, emailData{CampaignName: campaign, Participant: types.ParticipantStruct{LoginName: loginName}, Rank: 2, ParticipantCount: 7})
+	assert.NoError(t, err)
+	assert.Equal(t, "Results of myCampaignName", subject)
+	assert.Equal(t, "loginName, you placed 2 of 7", body)
+}
+
+func TestRenderCampaignEmailErrors(t *testing.T) {
+	_, _, err := renderCampaignEmail(&types.CampaignEmailStruct{Subject: "{{.CampaignName", Body: "body"}, emailData{})
+	assert.Error(t, err)
+
+	_, _, err = renderCampaignEmail(&types.CampaignEmailStruct{Subject: "subject", Body: "{{.NoSuchField}}"}, emailData{})
+	assert.Error(t, err)
+}
+
+func TestLoadCampaignEmailNoMailer(t *testing.T) {
+	origMailer := mailer
+	defer func() {
+		mailer = origMailer
+	}()
+	mailer = nil
+	newMockDb(t)
+
+	assert.Nil(t, loadCampaignEmail(context.Background(), campaign, types.EmailKindRegistration))
+}
+
+func TestLoadCampaignEmail(t *testing.T) {
+	setupMockMailer(t)
+	mock := newMockDb(t)
+	mock.selectEmailCampName = campaign
+	mock.selectEmailKind = types.EmailKindRegistration
+
+	mock.selectEmailErr = sql.ErrNoRows
+	assert.Nil(t, loadCampaignEmail(context.Background(), campaign, types.EmailKindRegistration))
+
+	mock.selectEmailErr = fmt.Errorf("forced email error")
+	assert.Nil(t, loadCampaignEmail(context.Background(), campaign, types.EmailKindRegistration))
+
+	mock.selectEmailErr = nil
+	mock.selectEmailResult = &types.CampaignEmailStruct{Enabled: false, Subject: "subject", Body: "body"}
+	assert.Nil(t, loadCampaignEmail(context.Background(), campaign, types.EmailKindRegistration))
+
+	mock.selectEmailResult = &types.CampaignEmailStruct{Enabled: true, Subject: "subject", Body: "body"}
+	assert.Equal(t, mock.selectEmailResult, loadCampaignEmail(context.Background(), campaign, types.EmailKindRegistration))
+}
+
+func TestSendCampaignEmail(t *testing.T) {
+	sender := setupMockMailer(t)
+	newMockDb(t)
+	email := &types.CampaignEmailStruct{Subject: "Welcome to {{.CampaignName}}", Body: "Hi {{.Participant.LoginName}}"}
+
+	sendCampaignEmail(nil, emailData{Participant: types.ParticipantStruct{Email: participantEmail}})
+	sendCampaignEmail(email, emailData{Participant: types.ParticipantStruct{}})
+	sendCampaignEmail(&types.CampaignEmailStruct{Subject: "{{.Nope}}"}, emailData{Participant: types.ParticipantStruct{Email: participantEmail}})
+	assert.Nil(t, sender.sent)
+
+	sendCampaignEmail(email, emailData{CampaignName: campaign, Participant: types.ParticipantStruct{LoginName: loginName, Email: participantEmail}})
+	assert.Equal(t, []mail.Message{{To: participantEmail, Subject: "Welcome to myCampaignName", Body: "Hi loginName"}}, sender.sent)
+}
+
+func TestAddParticipantSendsRegistrationEmail(t *testing.T) {
+	sender := setupMockMailer(t)
+	participantJson := fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s","email":"%s"}`, campaign, scpName, loginName, participantEmail)
+	c, _ := setupMockContextParticipant(participantJson)
+
+	mock := newMockDb(t)
+	mock.insertParticipantPartier = &types.ParticipantStruct{
+		CampaignName: campaign,
+		ScpName:      scpName,
+		LoginName:    loginName,
+		Email:        participantEmail,
+	}
+	mock.insertParticipantGuid = participantID
+	mock.insertParticipantJoinedAt = now
+	mock.selectEmailCampName = campaign
+	mock.selectEmailKind = types.EmailKindRegistration
+	mock.selectEmailResult = &types.CampaignEmailStruct{Enabled: true, Subject: "Welcome", Body: "Hi {{.Participant.LoginName}}"}
+
+	assert.NoError(t, addParticipant(c))
+	assert.Equal(t, []mail.Message{{To: participantEmail, Subject: "Welcome", Body: "Hi loginName"}}, sender.sent)
+}
+
+func TestAddPersonToTeamSendsTeamAssignmentEmail(t *testing.T) {
+	sender := setupMockMailer(t)
+	c, _ := setupMockContextAddPersonToTeam(campaign, scpName, loginName, teamName)
 
 	mock := newMockDb(t)
-	mock.updatePartTeamTeamName = teamName
 	mock.updatePartTeamCampaignName = campaign
 	mock.updatePartTeamSCPName = scpName
 	mock.updatePartTeamLoginName = loginName
-	forcedError := fmt.Errorf("forced SQL update error")
-	mock.updatePartTeamErr = forcedError
+	mock.updatePartTeamTeamName = teamName
+	mock.updatePartTeamRowsAffected = 1
+	mock.selectEmailCampName = campaign
+	mock.selectEmailKind = types.EmailKindTeamAssignment
+	mock.selectEmailResult = &types.CampaignEmailStruct{Enabled: true, Subject: "Team", Body: "You joined {{.Participant.TeamName}}"}
+	mock.selectPartDetailCampName = campaign
+	mock.selectPartDetailSCPName = scpName
+	mock.selectPartDetailLoginName = loginName
+	mock.selectPartDetailResult = &types.ParticipantStruct{LoginName: loginName, Email: participantEmail, TeamName: teamName}
 
-	assert.EqualError(t, addPersonToTeam(c), forcedError.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	assert.NoError(t, addPersonToTeam(c))
+	assert.Equal(t, []mail.Message{{To: participantEmail, Subject: "Team", Body: "You joined myTeamName"}}, sender.sent)
+}
+
+func TestEmailTeamAssignmentParticipantError(t *testing.T) {
+	sender := setupMockMailer(t)
+	mock := newMockDb(t)
+	mock.selectEmailCampName = campaign
+	mock.selectEmailKind = types.EmailKindTeamAssignment
+	mock.selectEmailResult = &types.CampaignEmailStruct{Enabled: true, Subject: "Team", Body: "body"}
+	mock.selectPartDetailCampName = campaign
+	mock.selectPartDetailSCPName = scpName
+	mock.selectPartDetailLoginName = loginName
+	mock.selectPartDetailErr = fmt.Errorf("forced participant error")
+
+	emailTeamAssignment(context.Background(), campaign, scpName, loginName)
+	assert.Nil(t, sender.sent)
+}
+
+func TestEmailFinalRanks(t *testing.T) {
+	sender := setupMockMailer(t)
+	mock := newMockDb(t)
+	mock.selectEmailCampName = campaign
+	mock.selectEmailKind = types.EmailKindCampaignEnd
+	mock.selectEmailResult = &types.CampaignEmailStruct{Enabled: true, Subject: "Final", Body: "{{.Participant.LoginName}} {{.Rank}}/{{.ParticipantCount}}"}
+	mock.selectPartInCampCamp = campaign
+	mock.selectPartInCampResult = []types.ParticipantStruct{
+		{LoginName: "third", Email: "third@example.com", Score: 1},
+		{LoginName: "first", Email: "first@example.com", Score: 9},
+		{LoginName: "tied", Email: "tied@example.com", Score: 9},
+		{LoginName: "noEmail", Score: 0},
+	}
+
+	emailFinalRanks(context.Background(), campaign)
+	assert.Equal(t, []mail.Message{
+		{To: "first@example.com", Subject: "Final", Body: "first 1/4"},
+		{To: "tied@example.com", Subject: "Final", Body: "tied 1/4"},
+		{To: "third@example.com", Subject: "Final", Body: "third 3/4"},
+	}, sender.sent)
+}
+
+func TestEmailFinalRanksParticipantsError(t *testing.T) {
+	sender := setupMockMailer(t)
+	mock := newMockDb(t)
+	mock.selectEmailCampName = campaign
+	mock.selectEmailKind = types.EmailKindCampaignEnd
+	mock.selectEmailResult = &types.CampaignEmailStruct{Enabled: true, Subject: "Final", Body: "body"}
+	mock.selectPartInCampCamp = campaign
+	mock.selectPartInCampErr = fmt.Errorf("forced participants error")
+
+	emailFinalRanks(context.Background(), campaign)
+	assert.Nil(t, sender.sent)
 }
 
-func TestAddPersonToTeamZeroRowsAffected(t *testing.T) {
-	c, rec := setupMockContextAddPersonToTeam(campaign, scpName, loginName, teamName)
+func TestGetCampaignEmailsError(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
 
 	mock := newMockDb(t)
-	mock.updatePartTeamCampaignName = campaign
-	mock.updatePartTeamSCPName = scpName
-	mock.updatePartTeamLoginName = loginName
-	mock.updatePartTeamTeamName = teamName
-	mock.updatePartTeamRowsAffected = 0
+	mock.selectEmailsCampName = campaign
+	forcedError := fmt.Errorf("forced emails error")
+	mock.selectEmailsErr = forcedError
 
-	assert.NoError(t, addPersonToTeam(c))
-	assert.Equal(t, http.StatusBadRequest, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	assert.EqualError(t, getCampaignEmails(c), forcedError.Error())
 }
 
-func TestAddPersonToTeamSomeRowsAffected(t *testing.T) {
-	c, rec := setupMockContextAddPersonToTeam(campaign, scpName, loginName, teamName)
+func TestGetCampaignEmails(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
 
 	mock := newMockDb(t)
-	mock.updatePartTeamCampaignName = campaign
-	mock.updatePartTeamSCPName = scpName
-	mock.updatePartTeamLoginName = loginName
-	mock.updatePartTeamTeamName = teamName
-	mock.updatePartTeamRowsAffected = 5
+	mock.selectEmailsCampName = campaign
+	mock.selectEmailsResult = []types.CampaignEmailStruct{{CampaignName: campaign, Kind: types.EmailKindRegistration, Enabled: true, Subject: "s", Body: "b"}}
 
-	assert.NoError(t, addPersonToTeam(c))
-	assert.Equal(t, http.StatusNoContent, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	assert.NoError(t, getCampaignEmails(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, `[{"campaignName":"myCampaignName","kind":"registration","enabled":true,"subject":"s","body":"b"}]`+"\n", rec.Body.String())
 }
 
-func setupMockContextParticipantDetail(campaignName, scpName, loginName string) (c echo.Context, rec *httptest.ResponseRecorder) {
+func setupMockContextCampaignEmail(emailKind, body string) (c echo.Context, rec *httptest.ResponseRecorder) {
 	e := echo.New()
-	req := httptest.NewRequest("", "/", nil)
+	req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
 	rec = httptest.NewRecorder()
 	c = e.NewContext(req, rec)
-	c.SetParamNames(ParamCampaignName, ParamScpName, ParamLoginName)
-	c.SetParamValues(campaignName, scpName, loginName)
+	c.SetParamNames(ParamCampaignName, ParamEmailKind)
+	c.SetParamValues(campaign, emailKind)
 	return
 }
 
-func TestGetParticipantDetailScanError(t *testing.T) {
-	c, rec := setupMockContextParticipantDetail("", "", "")
+func TestPutCampaignEmailInvalidKind(t *testing.T) {
+	c, _ := setupMockContextCampaignEmail("birthday", `{"subject":"s","body":"b"}`)
+
+	newMockDb(t)
+
+	err := putCampaignEmail(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{{Field: "kind", Message: "must be one of: registration team_assignment campaign_end"}}, toApiError(err).Details)
+}
+
+func TestPutCampaignEmailInvalidTemplate(t *testing.T) {
+	c, _ := setupMockContextCampaignEmail(types.EmailKindRegistration, `{"subject":"s","body":"{{.Nope}}"}`)
+
+	newMockDb(t)
+
+	err := putCampaignEmail(c)
+	assert.Equal(t, http.StatusBadRequest, toApiError(err).Status)
+	assert.True(t, strings.HasPrefix(toApiError(err).Message, "invalid email template: "), toApiError(err).Message)
+}
+
+func TestPutCampaignEmailNoCampaign(t *testing.T) {
+	c, _ := setupMockContextCampaignEmail(types.EmailKindRegistration, `{"subject":"s","body":"b"}`)
 
 	mock := newMockDb(t)
-	forcedError := fmt.Errorf("forced Scan error")
-	mock.selectPartDetailErr = forcedError
+	mock.upsertEmail = &types.CampaignEmailStruct{CampaignName: campaign, Kind: types.EmailKindRegistration, Subject: "s", Body: "b"}
+	mock.upsertEmailErr = sql.ErrNoRows
 
-	assert.EqualError(t, getParticipantDetail(c), forcedError.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	assertApiError(t, putCampaignEmail(c), http.StatusNotFound, "no campaign: campaignName: myCampaignName")
 }
 
-func TestGetParticipantDetail(t *testing.T) {
-	c, rec := setupMockContextParticipantDetail(campaign, scpName, loginName)
+func TestPutCampaignEmail(t *testing.T) {
+	c, rec := setupMockContextCampaignEmail(types.EmailKindCampaignEnd,
+		`{"kind":"ignored","enabled":true,"subject":"Results","body":"You placed {{.Rank}}"}`)
 
 	mock := newMockDb(t)
-	mock.selectPartDetailCampName = campaign
-	mock.selectPartDetailSCPName = scpName
-	mock.selectPartDetailLoginName = loginName
-	mock.selectPartDetailResult = &types.ParticipantStruct{
-		ID:           participantID,
-		CampaignName: campaign,
-		ScpName:      scpName,
-		LoginName:    loginName,
-		JoinedAt:     now,
-	}
+	mock.upsertEmail = &types.CampaignEmailStruct{CampaignName: campaign, Kind: types.EmailKindCampaignEnd, Enabled: true, Subject: "Results", Body: "You placed {{.Rank}}"}
 
-	assert.NoError(t, getParticipantDetail(c))
+	assert.NoError(t, putCampaignEmail(c))
 	assert.Equal(t, http.StatusOK, c.Response().Status)
-	assert.True(t, strings.HasPrefix(rec.Body.String(), `{"guid":"`+participantID+`","campaignName":"`+campaign+`","scpName":"`+scpName+`","loginName":"`+loginName+`"`), rec.Body.String())
+	assert.Equal(t, `{"campaignName":"myCampaignName","kind":"campaign_end","enabled":true,"subject":"Results","body":"You placed {{.Rank}}"}`+"\n", rec.Body.String())
 }
 
-func setupMockContextParticipantList(campaignName string) (c echo.Context, rec *httptest.ResponseRecorder) {
-	e := echo.New()
-	req := httptest.NewRequest("", "/", nil)
-	rec = httptest.NewRecorder()
-	c = e.NewContext(req, rec)
-	c.SetParamNames(ParamCampaignName)
-	c.SetParamValues(campaignName)
-	return
-}
+func TestEvaluateAchievementsNoPoints(t *testing.T) {
+	newMockDb(t)
 
-func TestGetParticipantsListError(t *testing.T) {
-	campaignName := ""
-	c, rec := setupMockContextParticipantList(campaignName)
+	evaluateAchievements(&types.ParticipantStruct{ID: participantID}, 0, now)
+	assert.Nil(t, insertAchievementKinds)
+}
 
+func TestEvaluateAchievementsFirstFix(t *testing.T) {
 	mock := newMockDb(t)
-	forcedError := fmt.Errorf("forced Scan error")
-	mock.selectPartInCampErr = forcedError
+	participant := &types.ParticipantStruct{ID: participantID, CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+	mock.selectBugsFixedParticipant = participant
+	mock.selectBugsFixedResult = achievementBugsFixed - 1
 
-	assert.EqualError(t, getParticipantsList(c), forcedError.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	evaluateAchievements(participant, 1, now)
+	assert.Equal(t, []string{participantID + ":" + types.AchievementFirstFix}, insertAchievementKinds)
 }
 
-func TestGetParticipantsList(t *testing.T) {
-	c, rec := setupMockContextParticipantList(campaign)
-
+func TestEvaluateAchievementsTenBugs(t *testing.T) {
 	mock := newMockDb(t)
-	mock.selectPartInCampCamp = campaign
-	mock.selectPartInCampResult = []types.ParticipantStruct{
-		{
-			ID:           participantID,
-			CampaignName: campaign,
-			JoinedAt:     now,
-		},
-	}
+	participant := &types.ParticipantStruct{ID: participantID, CampaignName: campaign, ScpName: scpName, LoginName: loginName}
+	mock.selectBugsFixedParticipant = participant
+	mock.selectBugsFixedResult = achievementBugsFixed
 
-	assert.NoError(t, getParticipantsList(c))
-	assert.Equal(t, http.StatusOK, c.Response().Status)
-	assert.True(t, strings.HasPrefix(rec.Body.String(), `[{"guid":"`+participantID+`","campaignName":"`+campaign+`","scpName":"","loginName":""`), rec.Body.String())
+	evaluateAchievements(participant, 1, now)
+	assert.Equal(t, []string{participantID + ":" + types.AchievementFirstFix, participantID + ":" + types.AchievementTenBugs}, insertAchievementKinds)
 }
 
-func TestValidateBug(t *testing.T) {
-	_, _ = setupMockContext()
-	logger = zaptest.NewLogger(t)
-	assert.EqualError(t, validateBug(&types.BugStruct{}), "bug is not valid, empty campaign: bug: &{Id: Campaign: Category: PointValue:0}")
-	assert.EqualError(t, validateBug(&types.BugStruct{Campaign: "myCampaign"}), "bug is not valid, empty category: bug: &{Id: Campaign:myCampaign Category: PointValue:0}")
-	assert.EqualError(t, validateBug(&types.BugStruct{Campaign: "myCampaign", Category: ""}), "bug is not valid, empty category: bug: &{Id: Campaign:myCampaign Category: PointValue:0}")
-	assert.EqualError(t, validateBug(&types.BugStruct{Campaign: "myCampaign", Category: "myCategory", PointValue: -1}), "bug is not valid, negative PointValue: bug: &{Id: Campaign:myCampaign Category:myCategory PointValue:-1}")
-	assert.NoError(t, validateBug(&types.BugStruct{Campaign: "myCampaign", Category: "myCategory", PointValue: 0}))
+func TestEvaluateAchievementsBugsFixedError(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectBugsFixedErr = fmt.Errorf("forced bugs fixed error")
+
+	evaluateAchievements(&types.ParticipantStruct{ID: participantID}, 1, now)
+	assert.Equal(t, []string{participantID + ":" + types.AchievementFirstFix}, insertAchievementKinds)
 }
 
-func setupMockContextAddBug(bugJson string) (c echo.Context, rec *httptest.ResponseRecorder) {
-	e := echo.New()
-	req := httptest.NewRequest("", "/", strings.NewReader(bugJson))
-	rec = httptest.NewRecorder()
-	c = e.NewContext(req, rec)
-	return
+func TestAwardAchievementNotifiesNewAchievement(t *testing.T) {
+	mock := newMockDb(t)
+	mock.insertAchievementAwarded = true
+
+	awardAchievement(&types.ParticipantStruct{ID: participantID, CampaignName: campaign}, types.AchievementFirstFix, now)
+	assert.Equal(t, &types.NotificationStruct{
+		ParticipantId: participantID,
+		Kind:          types.NotificationKindAchievement,
+		Message:       "achievement earned in myCampaignName: first_fix",
+		CreatedOn:     now,
+	}, insertNotificationLast)
 }
 
-func TestAddBugMissingBug(t *testing.T) {
-	c, rec := setupMockContextAddBug("")
+func TestAwardAchievementAlreadyAwarded(t *testing.T) {
+	newMockDb(t)
 
-	assert.EqualError(t, addBug(c), "EOF")
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	awardAchievement(&types.ParticipantStruct{ID: participantID, CampaignName: campaign}, types.AchievementFirstFix, now)
+	assert.Nil(t, insertNotificationLast)
 }
 
-const category = "myCategory"
+func TestAwardAchievementError(t *testing.T) {
+	mock := newMockDb(t)
+	mock.insertAchievementAwarded = true
+	mock.insertAchievementErr = fmt.Errorf("forced achievement error")
 
-func TestAddBugScanError(t *testing.T) {
-	c, rec := setupMockContextAddBug(`{"campaign": "` + campaign + `", "category":"` + category + `"}`)
+	awardAchievement(&types.ParticipantStruct{ID: participantID, CampaignName: campaign}, types.AchievementFirstFix, now)
+	assert.Nil(t, insertNotificationLast)
+}
 
+func TestAwardTopFinishers(t *testing.T) {
 	mock := newMockDb(t)
-	mock.insertBugBug = &types.BugStruct{
-		Campaign: campaign,
-		Category: category,
+	mock.selectTopCampName = campaign
+	mock.selectTopCount = achievementTopFinishers
+	mock.selectTopResult = []types.ParticipantStruct{
+		{ID: "first", Score: 9},
+		{ID: "second", Score: 3},
+		{ID: "none", Score: 0},
 	}
-	forcedError := fmt.Errorf("forced insert bug error")
-	mock.insertBugErr = forcedError
 
-	assert.EqualError(t, addBug(c), forcedError.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	awardTopFinishers(context.Background(), campaign, now)
+	assert.Equal(t, []string{"first:" + types.AchievementTopThree, "second:" + types.AchievementTopThree}, insertAchievementKinds)
 }
 
-func TestAddBugInvalidBug(t *testing.T) {
-	c, rec := setupMockContextAddBug(`{}`)
+func TestAwardTopFinishersError(t *testing.T) {
+	mock := newMockDb(t)
+	mock.selectTopCampName = campaign
+	mock.selectTopCount = achievementTopFinishers
+	mock.selectTopErr = fmt.Errorf("forced top error")
 
-	newMockDb(t)
+	awardTopFinishers(context.Background(), campaign, now)
+	assert.Nil(t, insertAchievementKinds)
+}
 
-	assert.EqualError(t, addBug(c), "bug is not valid, empty campaign: bug: &{Id: Campaign: Category: PointValue:0}")
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+func TestGetAchievementsError(t *testing.T) {
+	c, _ := setupMockContextParticipantDetail(campaign, scpName, loginName)
+
+	mock := newMockDb(t)
+	mock.selectAchievementsCampName = campaign
+	mock.selectAchievementsScpName = scpName
+	mock.selectAchievementsLoginName = loginName
+	forcedError := fmt.Errorf("forced achievements error")
+	mock.selectAchievementsErr = forcedError
+
+	assert.EqualError(t, getAchievements(c), forcedError.Error())
 }
-func TestAddBug(t *testing.T) {
-	pointValue := 9
-	c, rec := setupMockContextAddBug(`{"campaign": "` + campaign + `", "category":"` + category + `","pointValue":` + strconv.Itoa(pointValue) + `}`)
+
+func TestGetAchievements(t *testing.T) {
+	c, rec := setupMockContextParticipantDetail(campaign, scpName, loginName)
 
 	mock := newMockDb(t)
-	mock.insertBugBug = &types.BugStruct{
-		Campaign:   campaign,
-		Category:   category,
-		PointValue: pointValue,
-	}
-	bugId := "myBugId"
-	mock.insertBugGuid = bugId
+	mock.selectAchievementsCampName = campaign
+	mock.selectAchievementsScpName = scpName
+	mock.selectAchievementsLoginName = loginName
+	mock.selectAchievementsResult = []types.AchievementStruct{{Kind: types.AchievementFirstFix, AwardedOn: now}}
 
-	assert.NoError(t, addBug(c))
-	assert.Equal(t, http.StatusCreated, c.Response().Status)
-	assert.True(t, strings.HasPrefix(rec.Body.String(), `{"guid":"`+bugId+`","endpoints":`), rec.Body.String())
-	assert.True(t, strings.HasSuffix(rec.Body.String(), `"object":{"guid":"`+bugId+`","campaign":"`+campaign+`","category":"`+category+`","pointValue":`+strconv.Itoa(pointValue)+`}}`+"\n"), rec.Body.String())
+	assert.NoError(t, getAchievements(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, fmt.Sprintf(`[{"kind":"first_fix","awardedOn":"%s"}]`, now.Format(time.RFC3339Nano))+"\n", rec.Body.String())
 }
 
-func setupMockContextUpdateBug(campaign, bugCategory, pointValue string) (c echo.Context, rec *httptest.ResponseRecorder) {
+func setupMockContextParticipantScoreAt(query string) (c echo.Context, rec *httptest.ResponseRecorder) {
 	e := echo.New()
-	req := httptest.NewRequest("", "/", nil)
+	req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
 	rec = httptest.NewRecorder()
 	c = e.NewContext(req, rec)
-	c.SetParamNames(ParamCampaignName, ParamBugCategory, ParamPointValue)
-	c.SetParamValues(campaign, bugCategory, pointValue)
+	c.SetParamNames(ParamCampaignName, ParamScpName, ParamLoginName)
+	c.SetParamValues(campaign, scpName, loginName)
 	return
 }
 
-func TestUpdateBugInvalidPointValue(t *testing.T) {
-	c, rec := setupMockContextUpdateBug("", "", "non-number")
+func TestGetParticipantScoreAtInvalidAt(t *testing.T) {
+	c, _ := setupMockContextParticipantScoreAt("at=yesterday")
 
-	assert.EqualError(t, updateBug(c), `strconv.Atoi: parsing "non-number": invalid syntax`)
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	newMockDb(t)
+
+	assertApiError(t, getParticipantScoreAt(c), http.StatusBadRequest, "invalid at: yesterday")
 }
 
-func TestUpdateBugUpdateError(t *testing.T) {
-	pointValue := 9
-	c, rec := setupMockContextUpdateBug(campaign, category, strconv.Itoa(pointValue))
+func TestGetParticipantScoreAtNoParticipant(t *testing.T) {
+	c, _ := setupMockContextParticipantScoreAt("at=2021-11-15T00:00:00Z")
 
 	mock := newMockDb(t)
-	mock.updateBugBug = &types.BugStruct{
-		Campaign:   campaign,
-		Category:   category,
-		PointValue: pointValue,
-	}
-	forcedError := fmt.Errorf("forced Update bug error")
-	mock.updateBugErr = forcedError
+	mock.selectScoreAtCampName = campaign
+	mock.selectScoreAtScpName = scpName
+	mock.selectScoreAtLoginName = loginName
+	mock.selectScoreAtErr = sql.ErrNoRows
 
-	assert.EqualError(t, updateBug(c), forcedError.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	assertApiError(t, getParticipantScoreAt(c), http.StatusNotFound,
+		fmt.Sprintf("no participant: campaignName: %s, scpName: %s, loginName: %s", campaign, scpName, loginName))
 }
 
-func TestUpdateBugRowsAffectedZero(t *testing.T) {
-	pointValue := 9
-	c, rec := setupMockContextUpdateBug(campaign, category, strconv.Itoa(pointValue))
+func TestGetParticipantScoreAtError(t *testing.T) {
+	c, _ := setupMockContextParticipantScoreAt("")
 
 	mock := newMockDb(t)
-	mock.updateBugBug = &types.BugStruct{
-		Campaign:   campaign,
-		Category:   category,
-		PointValue: pointValue,
-	}
-	mock.updateBugRowsAffected = 0
+	mock.selectScoreAtCampName = campaign
+	mock.selectScoreAtScpName = scpName
+	mock.selectScoreAtLoginName = loginName
+	forcedError := fmt.Errorf("forced score at error")
+	mock.selectScoreAtErr = forcedError
 
-	assert.NoError(t, updateBug(c))
-	assert.Equal(t, http.StatusNotFound, c.Response().Status)
-	assert.Equal(t, "Bug Category not found", rec.Body.String())
+	assert.EqualError(t, getParticipantScoreAt(c), forcedError.Error())
 }
 
-func TestUpdateBugInvalidBug(t *testing.T) {
-	c, rec := setupMockContextUpdateBug("myCampaign", "myCategory", "-1")
+func TestGetParticipantScoreAt(t *testing.T) {
+	c, rec := setupMockContextParticipantScoreAt("at=2021-11-15T00:00:00Z")
 
-	newMockDb(t)
+	at := time.Date(2021, 11, 15, 0, 0, 0, 0, time.UTC)
+	mock := newMockDb(t)
+	mock.selectScoreAtCampName = campaign
+	mock.selectScoreAtScpName = scpName
+	mock.selectScoreAtLoginName = loginName
+	mock.selectScoreAtAt = at
+	mock.selectScoreAtResult = &types.ParticipantScoreAt{CampaignName: campaign, ScpName: scpName, LoginName: loginName, At: at, Score: 12.5, ScoringEvents: 3}
 
-	assert.EqualError(t, updateBug(c), "bug is not valid, negative PointValue: bug: &{Id: Campaign:myCampaign Category:myCategory PointValue:-1}")
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	assert.NoError(t, getParticipantScoreAt(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, fmt.Sprintf(`{"campaignName":"%s","scpName":"%s","loginName":"%s","at":"2021-11-15T00:00:00Z","score":12.5,"scoringEvents":3}`,
+		campaign, scpName, loginName)+"\n", rec.Body.String())
 }
 
-func TestUpdateBug(t *testing.T) {
-	pointValue := 9
-	c, rec := setupMockContextUpdateBug(campaign, category, strconv.Itoa(pointValue))
+func TestGetAchievementStatsError(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, "")
 
 	mock := newMockDb(t)
-	mock.updateBugBug = &types.BugStruct{
-		Campaign:   campaign,
-		Category:   category,
-		PointValue: pointValue,
-	}
-	mock.updateBugRowsAffected = 5
+	mock.selectAchievementStatsCampName = campaign
+	forcedError := fmt.Errorf("forced achievement stats error")
+	mock.selectAchievementStatsErr = forcedError
 
-	assert.NoError(t, updateBug(c))
+	assert.EqualError(t, getAchievementStats(c), forcedError.Error())
+}
+
+func TestGetAchievementStats(t *testing.T) {
+	c, rec := setupMockContextCampaignWithBody(campaign, "")
+
+	mock := newMockDb(t)
+	mock.selectAchievementStatsCampName = campaign
+	mock.selectAchievementStatsResult = []types.AchievementStat{{Kind: types.AchievementFirstFix, Participants: 4}, {Kind: types.AchievementTopThree, Participants: 3}}
+
+	assert.NoError(t, getAchievementStats(c))
 	assert.Equal(t, http.StatusOK, c.Response().Status)
-	assert.Equal(t, "Success", rec.Body.String())
+	assert.Equal(t, `[{"kind":"first_fix","participants":4},{"kind":"top_three","participants":3}]`+"\n", rec.Body.String())
 }
 
-func setupMockContextGetBugs() (c echo.Context, rec *httptest.ResponseRecorder) {
+func setupMockContextTimeSeries(query string) (c echo.Context, rec *httptest.ResponseRecorder) {
 	e := echo.New()
-	req := httptest.NewRequest("", "/", nil)
+	req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
 	rec = httptest.NewRecorder()
 	c = e.NewContext(req, rec)
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
 	return
 }
 
-func TestGetBugsError(t *testing.T) {
-	c, rec := setupMockContextGetBugs()
+func TestGetScoreTimeSeriesInvalidInterval(t *testing.T) {
+	c, _ := setupMockContextTimeSeries("interval=fortnight")
+
+	newMockDb(t)
+
+	assertApiError(t, getScoreTimeSeries(c), http.StatusBadRequest, "invalid interval: fortnight")
+}
+
+func TestGetScoreTimeSeriesError(t *testing.T) {
+	c, _ := setupMockContextTimeSeries("")
 
 	mock := newMockDb(t)
-	forcedError := fmt.Errorf("forced Select error")
-	mock.selectBugsErr = forcedError
+	mock.selectTimeSeriesCampName = campaign
+	mock.selectTimeSeriesInterval = defaultTimeSeriesInterval
+	forcedError := fmt.Errorf("forced time series error")
+	mock.selectTimeSeriesErr = forcedError
 
-	assert.EqualError(t, getBugs(c), forcedError.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	assert.EqualError(t, getScoreTimeSeries(c), forcedError.Error())
 }
 
-func TestGetBugs(t *testing.T) {
-	c, rec := setupMockContextGetBugs()
+func TestGetScoreTimeSeries(t *testing.T) {
+	c, rec := setupMockContextTimeSeries("interval=hour")
 
 	mock := newMockDb(t)
-	bugId := "myBugId"
-	category := "myCategory"
-	pointValue := 9
-	mock.selectBugsResult = []types.BugStruct{
-		{
-			Id:         bugId,
-			Campaign:   campaign,
-			Category:   category,
-			PointValue: pointValue,
-		},
+	mock.selectTimeSeriesCampName = campaign
+	mock.selectTimeSeriesInterval = "hour"
+	firstHour := now.Truncate(time.Hour)
+	mock.selectTimeSeriesResult = []types.TimeSeriesPoint{
+		{Start: firstHour, Points: 3},
+		{Start: firstHour.Add(time.Hour), Points: 4},
 	}
 
-	assert.NoError(t, getBugs(c))
+	assert.NoError(t, getScoreTimeSeries(c))
 	assert.Equal(t, http.StatusOK, c.Response().Status)
-	assert.Equal(t, `[{"guid":"`+bugId+`","campaign":"`+campaign+`","category":"`+category+`","pointValue":`+strconv.Itoa(pointValue)+`}]`+"\n", rec.Body.String())
+	var points []types.TimeSeriesPoint
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &points))
+	assert.Equal(t, []float64{3, 7}, []float64{points[0].CumulativePoints, points[1].CumulativePoints})
 }
 
-func setupMockContextPutBugs(bugsJson string) (c echo.Context, rec *httptest.ResponseRecorder) {
+func TestGetScoreTimeSeriesByTeam(t *testing.T) {
+	c, rec := setupMockContextTimeSeries("interval=week&byTeam=true")
+
+	mock := newMockDb(t)
+	mock.selectTimeSeriesCampName = campaign
+	mock.selectTimeSeriesInterval = "week"
+	mock.selectTimeSeriesByTeam = true
+	mock.selectTimeSeriesResult = []types.TimeSeriesPoint{
+		{Start: now, TeamName: "blue", Points: 2},
+		{Start: now, TeamName: "red", Points: 5},
+		{Start: now.Add(7 * 24 * time.Hour), TeamName: "blue", Points: 1},
+	}
+
+	assert.NoError(t, getScoreTimeSeries(c))
+	var points []types.TimeSeriesPoint
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &points))
+	assert.Equal(t, []float64{2, 5, 3}, []float64{points[0].CumulativePoints, points[1].CumulativePoints, points[2].CumulativePoints})
+	assert.Equal(t, "red", points[1].TeamName)
+}
+
+func setupMockContextAudit(method, body string) (c echo.Context, rec *httptest.ResponseRecorder) {
 	e := echo.New()
-	req := httptest.NewRequest("", "/", strings.NewReader(bugsJson))
+	req := httptest.NewRequest(method, "/admin/campaign/add/"+campaign, strings.NewReader(body))
+	req.SetBasicAuth("adminUser", "adminPassword")
 	rec = httptest.NewRecorder()
 	c = e.NewContext(req, rec)
+	c.SetPath("/admin/campaign/add/:campaignName")
 	return
 }
 
-func TestPutBugsBodyInvalid(t *testing.T) {
-	c, rec := setupMockContextPutBugs("")
+func TestAuditAdminCallSkipsReads(t *testing.T) {
+	c, _ := setupMockContextAudit(http.MethodGet, "")
+	newMockDb(t)
 
-	assert.EqualError(t, putBugs(c), "EOF")
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	assert.NoError(t, auditAdminCall(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})(c))
+	assert.Nil(t, insertAuditLast)
 }
 
-func TestPutBugsScanError(t *testing.T) {
-	c, rec := setupMockContextPutBugs(
-		`[{"campaign":"` + campaign + `","category":"` + category + `", "pointValue":5}]`)
+func TestAuditAdminCall(t *testing.T) {
+	c, _ := setupMockContextAudit(http.MethodPut, `{"startOn":"2021-11-01T12:00:00Z"}`)
+	newMockDb(t)
 
+	assert.NoError(t, auditAdminCall(func(c echo.Context) error {
+		body, err := io.ReadAll(c.Request().Body)
+		assert.NoError(t, err)
+		return c.String(http.StatusCreated, string(body))
+	})(c))
+	assert.Equal(t, `{"startOn":"2021-11-01T12:00:00Z"}`, c.Response().Writer.(*httptest.ResponseRecorder).Body.String())
+	assert.Equal(t, "adminUser", insertAuditLast.Actor)
+	assert.Equal(t, http.MethodPut, insertAuditLast.Method)
+	assert.Equal(t, "/admin/campaign/add/myCampaignName", insertAuditLast.Path)
+	assert.Equal(t, "/admin/campaign/add/:campaignName", insertAuditLast.Route)
+	assert.Equal(t, http.StatusCreated, insertAuditLast.Status)
+	assert.Equal(t, `{"startOn":"2021-11-01T12:00:00Z"}`, insertAuditLast.Payload)
+}
+
+func TestAuditAdminCallHandlerError(t *testing.T) {
+	c, _ := setupMockContextAudit(http.MethodDelete, "")
 	mock := newMockDb(t)
-	mock.insertBugBug = &types.BugStruct{
-		Campaign:   campaign,
-		Category:   category,
-		PointValue: 5,
-	}
-	forcedError := fmt.Errorf("forced Scan error")
-	mock.insertBugErr = forcedError
+	mock.insertAuditErr = fmt.Errorf("forced audit error")
 
-	assert.EqualError(t, putBugs(c), forcedError.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	err := auditAdminCall(func(c echo.Context) error {
+		return newApiError(http.StatusNotFound, "no campaign")
+	})(c)
+	assertApiError(t, err, http.StatusNotFound, "no campaign")
+	assert.Equal(t, http.StatusNotFound, insertAuditLast.Status)
+	assert.Equal(t, "", insertAuditLast.Payload)
+}
+
+func TestAuditAdminCallLargePayload(t *testing.T) {
+	body := `{"note":"` + strings.Repeat("a", auditPayloadLimit) + `"}`
+	c, _ := setupMockContextAudit(http.MethodPut, body)
+	newMockDb(t)
+
+	assert.NoError(t, auditAdminCall(func(c echo.Context) error {
+		read, err := io.ReadAll(c.Request().Body)
+		assert.NoError(t, err)
+		assert.Equal(t, body, string(read))
+		return c.NoContent(http.StatusNoContent)
+	})(c))
+	assert.Equal(t, fmt.Sprintf("not recorded, over %d bytes", auditPayloadLimit), insertAuditLast.Payload)
 }
 
-func TestPutBugsOneBugInvalidBug(t *testing.T) {
-	c, rec := setupMockContextPutBugs(`[{}]`)
+func TestRedactAuditPayload(t *testing.T) {
+	assert.Equal(t, "not json", redactAuditPayload([]byte("not json")))
+	assert.Equal(t, `[{"secret":"REDACTED"}]`, redactAuditPayload([]byte(`[{"secret":"in a list"}]`)))
+	assert.Equal(t, `{"slack":{"channel":"#bbash","webhookUrl":"REDACTED"}}`,
+		redactAuditPayload([]byte(`{"slack":{"webhookUrl":"https://hooks.slack.com/services/T0/B0/x","channel":"#bbash"}}`)))
+	assert.Equal(t, `{"targetUrl": "https://example.com"}`, redactAuditPayload([]byte(`{"targetUrl": "https://example.com"}`)))
+	assert.Equal(t, `{"Password":"REDACTED","secret":"REDACTED","targetUrl":"https://example.com"}`,
+		redactAuditPayload([]byte(`{"targetUrl":"https://example.com","secret":"0123456789abcdef","Password":"hunter2"}`)))
+}
 
+func TestGetAuditLogInvalidLimit(t *testing.T) {
+	c, _ := setupMockContextTelemetry("limit=0")
 	newMockDb(t)
 
-	assert.EqualError(t, putBugs(c), "bug is not valid, empty campaign: bug: &{Id: Campaign: Category: PointValue:0}")
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	assertApiError(t, getAuditLog(c), http.StatusBadRequest, "invalid limit: 0")
 }
-func TestPutBugsOneBug(t *testing.T) {
-	c, rec := setupMockContextPutBugs(`[{"campaign":"myCampaign","category":"bugCat2", "pointValue":5}]`)
 
+func TestGetAuditLogError(t *testing.T) {
+	c, _ := setupMockContextTelemetry("")
 	mock := newMockDb(t)
-	bugId := "myBugId"
-	mock.insertBugBug = &types.BugStruct{
-		Campaign:   "myCampaign",
-		Category:   "bugCat2",
-		PointValue: 5,
-	}
-	mock.insertBugGuid = bugId
+	mock.selectAuditLimit = defaultAuditLimit
+	forcedError := fmt.Errorf("forced audit log error")
+	mock.selectAuditErr = forcedError
 
-	assert.NoError(t, putBugs(c))
-	assert.Equal(t, http.StatusCreated, c.Response().Status)
-	assert.Equal(t, `{"guid":"`+bugId+`","endpoints":null,"object":[{"guid":"`+bugId+`","campaign":"myCampaign","category":"bugCat2","pointValue":5}]}`+"\n", rec.Body.String())
+	assert.EqualError(t, getAuditLog(c), forcedError.Error())
 }
 
-func TestPutBugsMultipleBugs(t *testing.T) {
-	c, rec := setupMockContextPutBugs(`[{"campaign":"myCampaign","category":"bugCat2", "pointValue":5}, {"campaign":"myCampaign","category":"bugCat3", "pointValue":9}]`)
-
+func TestGetAuditLog(t *testing.T) {
+	c, rec := setupMockContextTelemetry("actor=adminUser&limit=5")
 	mock := newMockDb(t)
-	mock.insertBugBug = &types.BugStruct{
-		Campaign:   "myCampaign",
-		Category:   "bugCat2",
-		PointValue: 5,
-	}
-	bugId := "myBugId"
-	mock.insertBugGuid = bugId
-	bugId2 := fmt.Sprintf("%s%d", bugId, 1)
+	mock.selectAuditActor = "adminUser"
+	mock.selectAuditLimit = 5
+	mock.selectAuditResult = []types.AuditLogEntry{{ID: "auditId", Actor: "adminUser", Method: http.MethodPost, Path: "/admin/poll/last", Route: "/admin/poll/last", Status: http.StatusOK, Payload: "{}", CreatedOn: now}}
 
-	assert.NoError(t, putBugs(c))
-	assert.Equal(t, http.StatusCreated, c.Response().Status)
-	assert.Equal(t, `{"guid":"`+bugId+`","endpoints":null,"object":[{"guid":"`+bugId+`","campaign":"myCampaign","category":"bugCat2","pointValue":5},{"guid":"`+bugId2+`","campaign":"myCampaign","category":"bugCat3","pointValue":9}]}`+"\n", rec.Body.String())
+	assert.NoError(t, getAuditLog(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var entries []types.AuditLogEntry
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &entries))
+	assert.Equal(t, mock.selectAuditResult[0].ID, entries[0].ID)
+	assert.Equal(t, mock.selectAuditResult[0].Route, entries[0].Route)
 }
 
-func setupMockContextParticipantDelete(campaignName, scpName, loginName string) (c echo.Context, rec *httptest.ResponseRecorder) {
-	e := echo.New()
-	req := httptest.NewRequest(http.MethodDelete, "/", nil)
-	rec = httptest.NewRecorder()
-	c = e.NewContext(req, rec)
-	c.SetParamNames(ParamCampaignName, ParamScpName, ParamLoginName)
-	c.SetParamValues(campaignName, scpName, loginName)
+type mockProfileFetcher struct {
+	profile *profile.Profile
+	err     error
+	fetched []string
+}
+
+func (m *mockProfileFetcher) Fetch(scpName, loginName string) (*profile.Profile, error) {
+	m.fetched = append(m.fetched, scpName+"/"+loginName)
+	return m.profile, m.err
+}
+
+func setupMockProfileFetcher(t *testing.T) (fetcher *mockProfileFetcher) {
+	origFetcher := profileFetcher
+	t.Cleanup(func() {
+		profileFetcher = origFetcher
+	})
+	fetcher = &mockProfileFetcher{}
+	profileFetcher = fetcher
 	return
 }
 
-func TestDeleteParticipant(t *testing.T) {
-	c, rec := setupMockContextParticipantDelete(campaign, scpName, loginName)
+func TestRefreshProfileNoFetcher(t *testing.T) {
+	origFetcher := profileFetcher
+	defer func() {
+		profileFetcher = origFetcher
+	}()
+	profileFetcher = nil
+	newMockDb(t)
+
+	refreshProfile(context.Background(), &types.ParticipantStruct{ID: participantID}, now)
+	assert.Equal(t, 0, updateProfileCount)
+}
 
+func TestRefreshProfile(t *testing.T) {
+	fetcher := setupMockProfileFetcher(t)
+	fetcher.profile = &profile.Profile{DisplayName: "My Name", AvatarUrl: "https://avatars.example.com/u/1"}
 	mock := newMockDb(t)
-	mock.deletePartCampaign = campaign
-	mock.deletePartSCPName = scpName
-	mock.deletePartLoginName = loginName
-	mock.deletePartGuid = participantID
+	mock.updateProfileId = participantID
+	mock.updateProfileDisplayName = "My Name"
+	mock.updateProfileAvatarUrl = "https://avatars.example.com/u/1"
 
-	assert.NoError(t, deleteParticipant(c))
-	assert.Equal(t, http.StatusOK, c.Response().Status)
-	assert.Equal(t, fmt.Sprintf("\"deleted participant: campaign: %s, scpName: %s, loginName: %s, participant.id: %s\"\n", campaign, scpName, loginName, participantID), rec.Body.String())
+	participant := types.ParticipantStruct{ID: participantID, ScpName: scpName, LoginName: loginName}
+	refreshProfile(context.Background(), &participant, now)
+	assert.Equal(t, []string{scpName + "/" + loginName}, fetcher.fetched)
+	assert.Equal(t, "My Name", participant.DisplayName)
+	assert.Equal(t, "https://avatars.example.com/u/1", participant.AvatarUrl)
 }
 
-func TestDeleteParticipantWithDBDeleteError(t *testing.T) {
-	c, rec := setupMockContextParticipantDelete(campaign, scpName, loginName)
+func TestRefreshProfileKeepsDisplayName(t *testing.T) {
+	fetcher := setupMockProfileFetcher(t)
+	fetcher.profile = &profile.Profile{DisplayName: "My Name", AvatarUrl: "https://avatars.example.com/u/1"}
+	newMockDb(t)
+
+	participant := types.ParticipantStruct{ID: participantID, DisplayName: "Chosen Name"}
+	refreshProfile(context.Background(), &participant, now)
+	assert.Equal(t, "Chosen Name", participant.DisplayName)
+	assert.Equal(t, "https://avatars.example.com/u/1", participant.AvatarUrl)
+}
 
+func TestRefreshProfileFetchError(t *testing.T) {
+	fetcher := setupMockProfileFetcher(t)
+	fetcher.err = fmt.Errorf("forced fetch error")
 	mock := newMockDb(t)
-	mock.deletePartCampaign = campaign
-	mock.deletePartSCPName = scpName
-	mock.deletePartLoginName = loginName
-	forcedError := fmt.Errorf("forced delete error")
-	mock.deletePartErr = forcedError
+	mock.updateProfileId = participantID
 
-	assert.EqualError(t, deleteParticipant(c), forcedError.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	participant := types.ParticipantStruct{ID: participantID, AvatarUrl: "https://old.example.com"}
+	refreshProfile(context.Background(), &participant, now)
+	assert.Equal(t, 1, updateProfileCount)
+	assert.Equal(t, "https://old.example.com", participant.AvatarUrl)
 }
 
-func TestValidScoreErrorValidatingOrganization(t *testing.T) {
-	_, _ = setupMockContext()
+func TestRefreshProfileUnsupportedScp(t *testing.T) {
+	fetcher := setupMockProfileFetcher(t)
+	fetcher.err = profile.ErrUnsupportedScp
+	mock := newMockDb(t)
+	mock.updateProfileId = participantID
 
+	refreshProfile(context.Background(), &types.ParticipantStruct{ID: participantID}, now)
+	assert.Equal(t, 1, updateProfileCount)
+}
+
+func TestRefreshProfileUpdateError(t *testing.T) {
+	fetcher := setupMockProfileFetcher(t)
+	fetcher.profile = &profile.Profile{DisplayName: "My Name", AvatarUrl: "https://avatars.example.com/u/1"}
 	mock := newMockDb(t)
-	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid}
-	mock.validOrgParam = msg
-	forcedError := fmt.Errorf("forced org exists query error")
-	mock.validOrgErr = forcedError
+	mock.updateProfileErr = fmt.Errorf("forced profile update error")
 
-	activeParticipantsToScore, err := validScore(msg, now)
-	assert.EqualError(t, err, forcedError.Error())
-	assert.Equal(t, 0, len(activeParticipantsToScore))
+	participant := types.ParticipantStruct{ID: participantID}
+	refreshProfile(context.Background(), &participant, now)
+	assert.Equal(t, "", participant.DisplayName)
+	assert.Equal(t, "", participant.AvatarUrl)
 }
 
-func TestValidScoreOrganizationNotValid(t *testing.T) {
-	_, _ = setupMockContext()
+func TestRefreshStaleProfiles(t *testing.T) {
+	fetcher := setupMockProfileFetcher(t)
+	fetcher.profile = &profile.Profile{}
+	mock := newMockDb(t)
+	mock.selectRefreshBefore = now.Add(-profileRefreshAge)
+	mock.selectRefreshResult = []types.ParticipantStruct{
+		{ID: "one", ScpName: "GitHub", LoginName: "first"},
+		{ID: "two", ScpName: "GitLab", LoginName: "second"},
+	}
+
+	refreshStaleProfiles(now)
+	assert.Equal(t, []string{"GitHub/first", "GitLab/second"}, fetcher.fetched)
+	assert.Equal(t, 2, updateProfileCount)
+}
 
+func TestRefreshStaleProfilesError(t *testing.T) {
+	fetcher := setupMockProfileFetcher(t)
 	mock := newMockDb(t)
-	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid}
-	mock.validOrgParam = msg
-	mock.validOrgResult = false
+	mock.selectRefreshBefore = now.Add(-profileRefreshAge)
+	mock.selectRefreshErr = fmt.Errorf("forced stale profiles error")
 
-	activeParticipantsToScore, err := validScore(msg, now)
-	assert.NoError(t, err)
-	assert.Equal(t, 0, len(activeParticipantsToScore))
+	refreshStaleProfiles(now)
+	assert.Nil(t, fetcher.fetched)
 }
 
-func TestValidScoreUnknownRepoOwner(t *testing.T) {
-	_, _ = setupMockContext()
+func TestAddParticipantRefreshesProfile(t *testing.T) {
+	fetcher := setupMockProfileFetcher(t)
+	fetcher.profile = &profile.Profile{DisplayName: "My Name", AvatarUrl: "https://avatars.example.com/u/1"}
+	participantJson := fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s","loginName": "%s"}`, campaign, scpName, loginName)
+	c, rec := setupMockContextParticipant(participantJson)
 
 	mock := newMockDb(t)
-	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid}
-	mock.validOrgParam = msg
-	mock.validOrgResult = false
+	mock.insertParticipantPartier = &types.ParticipantStruct{
+		CampaignName: campaign,
+		ScpName:      scpName,
+		LoginName:    loginName,
+	}
+	mock.insertParticipantGuid = participantID
+	mock.insertParticipantJoinedAt = now
+	mock.updateProfileId = participantID
+	mock.updateProfileDisplayName = "My Name"
+	mock.updateProfileAvatarUrl = "https://avatars.example.com/u/1"
 
-	activeParticipantsToScore, err := validScore(msg, now)
-	assert.NoError(t, err)
-	assert.Equal(t, 0, len(activeParticipantsToScore))
+	assert.NoError(t, addParticipant(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	assert.Contains(t, rec.Body.String(), `"displayName":"My Name","avatarUrl":"https://avatars.example.com/u/1"`)
 }
 
-func setupMockContext() (c echo.Context, rec *httptest.ResponseRecorder) {
+func setupMockContextParticipantProfile(scpName, loginName, body string) (c echo.Context, rec *httptest.ResponseRecorder) {
 	e := echo.New()
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req := httptest.NewRequest(http.MethodPatch, "/participant/profile", strings.NewReader(body))
 	rec = httptest.NewRecorder()
 	c = e.NewContext(req, rec)
+	if loginName != "" {
+		signInParticipant(c, scpName, loginName)
+	}
 	return
 }
 
-func setupMockContextWithBody(method string, body string) (c echo.Context, rec *httptest.ResponseRecorder) {
-	e := echo.New()
-	req := httptest.NewRequest(method, "/", strings.NewReader(body))
-	rec = httptest.NewRecorder()
-	c = e.NewContext(req, rec)
-	return
-}
+func TestUpdateParticipantProfileNotSignedIn(t *testing.T) {
+	c, _ := setupMockContextParticipantProfile(scpName, "", `{"campaignName":"myCampaignName"}`)
+	newMockDb(t)
 
-func TestValidScoreParticipantNotRegistered(t *testing.T) {
-	mock := newMockDb(t)
-	msg := types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: "unregisteredUser"}
-	mock.validOrgParam = &msg
+	assertApiError(t, updateParticipantProfile(c), http.StatusUnauthorized, "participant not signed in")
+}
 
-	_, _ = setupMockContext()
+func TestUpdateParticipantProfileInvalidEmail(t *testing.T) {
+	c, _ := setupMockContextParticipantProfile(scpName, loginName, `{"campaignName":"myCampaignName","email":"nope"}`)
+	newMockDb(t)
 
-	activeParticipantsToScore, err := validScore(&msg, now)
-	assert.NoError(t, err)
-	assert.Equal(t, 0, len(activeParticipantsToScore))
+	err := updateParticipantProfile(c)
+	assertApiError(t, err, http.StatusUnprocessableEntity, "request is not valid")
+	assert.Equal(t, []fieldError{{Field: "email", Message: "must be an email address"}}, toApiError(err).Details)
 }
 
-func TestValidScoreParticipantError(t *testing.T) {
+func TestUpdateParticipantProfileNoParticipant(t *testing.T) {
+	c, _ := setupMockContextParticipantProfile(scpName, loginName, `{"campaignName":"myCampaignName","displayName":"My Name"}`)
 	mock := newMockDb(t)
-	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName}
-	mock.validOrgParam = msg
-	forcedError := fmt.Errorf("forced current campaign read error")
-	mock.validOrgErr = forcedError
-
-	_, _ = setupMockContext()
+	displayName := "My Name"
+	mock.updateContactScpName = scpName
+	mock.updateContactLoginName = loginName
+	mock.updateContactRequest = &types.ParticipantProfileRequest{CampaignName: campaign, DisplayName: &displayName}
 
-	activeParticipantsToScore, err := validScore(msg, now)
-	assert.EqualError(t, err, forcedError.Error())
-	assert.Equal(t, 0, len(activeParticipantsToScore))
+	assertApiError(t, updateParticipantProfile(c), http.StatusNotFound, "no participant: campaignName: myCampaignName, scpName: myScpName, loginName: loginName")
 }
 
-func TestValidScoreParticipantErrorReadingParticipant(t *testing.T) {
+func TestUpdateParticipantProfileError(t *testing.T) {
+	c, _ := setupMockContextParticipantProfile(scpName, loginName, `{"campaignName":"myCampaignName"}`)
 	mock := newMockDb(t)
-	setupMockDBOrgValid(mock)
-	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName}
-	mock.validOrgParam = msg
-	mock.partiesToScoreMsg = msg
-	mock.partiesToScoreNow = now
-
-	forcedError := fmt.Errorf("forced current campaign read error")
-	mock.partiesToScoreErr = forcedError
+	mock.updateContactScpName = scpName
+	mock.updateContactLoginName = loginName
+	mock.updateContactRequest = &types.ParticipantProfileRequest{CampaignName: campaign}
+	forcedError := fmt.Errorf("forced contact error")
+	mock.updateContactErr = forcedError
 
-	_, _ = setupMockContext()
-
-	activeParticipantsToScore, err := validScore(msg, now)
-	assert.EqualError(t, err, forcedError.Error())
-	assert.Equal(t, 0, len(activeParticipantsToScore))
+	assert.EqualError(t, updateParticipantProfile(c), forcedError.Error())
 }
 
-func TestValidScoreParticipant(t *testing.T) {
+func TestUpdateParticipantProfile(t *testing.T) {
+	c, rec := setupMockContextParticipantProfile(scpName, loginName, `{"campaignName":"myCampaignName","email":"me@example.com","displayName":""}`)
 	mock := newMockDb(t)
-	setupMockDBOrgValid(mock)
-	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName}
-	mock.validOrgParam = msg
-	mock.partiesToScoreMsg = msg
-	mock.partiesToScoreNow = now
-	mock.partiesToScoreResult = []types.ParticipantStruct{
-		{
-			ID:           "someId",
-			CampaignName: "someCampaign",
-			ScpName:      "someSCP",
-			LoginName:    "someLoginName",
-		},
-	}
-
-	_, _ = setupMockContext()
+	email := "me@example.com"
+	displayName := ""
+	mock.updateContactScpName = scpName
+	mock.updateContactLoginName = loginName
+	mock.updateContactRequest = &types.ParticipantProfileRequest{CampaignName: campaign, Email: &email, DisplayName: &displayName}
+	mock.updateContactRowsAffected = 1
+	mock.selectPartDetailCampName = campaign
+	mock.selectPartDetailSCPName = scpName
+	mock.selectPartDetailLoginName = loginName
+	mock.selectPartDetailResult = &types.ParticipantStruct{ID: participantID, CampaignName: campaign, ScpName: scpName, LoginName: loginName, Email: email}
 
-	activeParticipantsToScore, err := validScore(msg, now)
-	assert.NoError(t, err)
-	assert.Equal(t, 1, len(activeParticipantsToScore))
-	assert.Equal(t, "someCampaign", activeParticipantsToScore[0].CampaignName)
-	assert.Equal(t, "someSCP", activeParticipantsToScore[0].ScpName)
+	assert.NoError(t, updateParticipantProfile(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Contains(t, rec.Body.String(), `"email":"me@example.com","displayName":""`)
 }
 
-func setupMockDBOrgValid(mock *MockBBashDB) {
-	mock.validOrgParam = &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid}
-	mock.validOrgResult = true
+func TestSearchParticipantsMissingLogin(t *testing.T) {
+	c, _ := setupMockContextTelemetry("login=%20")
+	newMockDb(t)
+
+	assertApiError(t, searchParticipants(c), http.StatusBadRequest, "missing login")
 }
 
-func TestTraverseBugCountsEmpty(t *testing.T) {
-	points := float64(1)
-	scored := float64(2)
-	bugCounts := map[string]interface{}{}
+func TestSearchParticipantsInvalidLimit(t *testing.T) {
+	c, _ := setupMockContextTelemetry("login=foo&limit=none")
+	newMockDb(t)
 
-	err := traverseBugCounts(nil, "", &points, &scored, &bugCounts)
-	assert.NoError(t, err)
-	assert.Equal(t, float64(1), points)
-	assert.Equal(t, float64(2), scored)
+	assertApiError(t, searchParticipants(c), http.StatusBadRequest, "invalid limit: none")
 }
 
-func TestTraverseBugCountsSimple(t *testing.T) {
-	bugType := "myBugType"
-
+func TestSearchParticipantsError(t *testing.T) {
+	c, _ := setupMockContextTelemetry("login=foo")
 	mock := newMockDb(t)
-	mock.selectPointValueBugType = bugType
-	mock.selectPointValueResult = 2
+	mock.searchLogin = "foo"
+	mock.searchLimit = defaultSearchLimit
+	forcedError := fmt.Errorf("forced search error")
+	mock.searchErr = forcedError
+
+	assert.EqualError(t, searchParticipants(c), forcedError.Error())
+}
 
-	points := float64(1)
-	scored := float64(2)
-	bugCounts := map[string]interface{}{
-		bugType: float64(3),
+func TestSearchParticipants(t *testing.T) {
+	c, rec := setupMockContextTelemetry("login=foo&limit=2")
+	mock := newMockDb(t)
+	mock.searchLogin = "foo"
+	mock.searchLimit = 2
+	mock.searchResult = []types.ParticipantStruct{
+		{ID: "one", CampaignName: "first", ScpName: scpName, LoginName: "foo"},
+		{ID: "two", CampaignName: "second", ScpName: scpName, LoginName: "foobar"},
 	}
 
-	err := traverseBugCounts(nil, "", &points, &scored, &bugCounts)
-	assert.NoError(t, err)
-	assert.Equal(t, float64(7), points)
-	assert.Equal(t, float64(5), scored)
+	assert.NoError(t, searchParticipants(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var participants []types.ParticipantStruct
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &participants))
+	assert.Equal(t, mock.searchResult, participants)
 }
 
-func TestTraverseBugCountsNestedMap(t *testing.T) {
-	bugType := "myBugType"
-	nestedBugType := "myNestedBugType"
+func TestGetLeaderboardInvalidLimit(t *testing.T) {
+	c, _ := setupMockContextTelemetry("limit=0")
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
+	newMockDb(t)
 
-	mock := newMockDb(t)
-	mock.selectPointValueBugType = nestedBugType
-	mock.selectPointValueResult = 2
+	assertApiError(t, getLeaderboard(c), http.StatusBadRequest, "invalid limit: 0")
+}
 
-	points := float64(1)
-	scored := float64(2)
-	mapNestedBugType := map[string]interface{}{
-		nestedBugType: float64(3),
-	}
-	bugCounts := map[string]interface{}{
-		bugType: mapNestedBugType,
-	}
+func TestGetLeaderboardNoCampaign(t *testing.T) {
+	c, _ := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
+	mock := newMockDb(t)
+	mock.leaderboardCampName = campaign
+	mock.leaderboardLimit = defaultLeaderboardLimit
+	mock.leaderboardErr = sql.ErrNoRows
 
-	err := traverseBugCounts(nil, "", &points, &scored, &bugCounts)
-	assert.NoError(t, err)
-	assert.Equal(t, float64(7), points)
-	assert.Equal(t, float64(5), scored)
+	assertApiError(t, getLeaderboard(c), http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaign))
 }
 
-func TestTraverseBugCountsSimpleAndNestedMap(t *testing.T) {
-	bugType := "myBugType"
-	nestedBugType := "myNestedBugType"
-
+func TestGetLeaderboardError(t *testing.T) {
+	c, _ := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
 	mock := newMockDb(t)
-	mock.assertParameters = false
-	mock.selectPointValueResult = 2
+	mock.leaderboardCampName = campaign
+	mock.leaderboardLimit = defaultLeaderboardLimit
+	forcedError := fmt.Errorf("forced leaderboard error")
+	mock.leaderboardErr = forcedError
 
-	points := float64(1)
-	scored := float64(2)
-	mapNestedBugType := map[string]interface{}{
-		nestedBugType: float64(3),
-	}
-	bugCounts := map[string]interface{}{
-		"bugTypeSimpleFirst": float64(2),
-		bugType:              mapNestedBugType,
-		"bugTypeSimpleLast":  float64(4),
+	assert.EqualError(t, getLeaderboard(c), forcedError.Error())
+}
+
+func TestGetLeaderboard(t *testing.T) {
+	c, rec := setupMockContextTelemetry("limit=2")
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
+	mock := newMockDb(t)
+	mock.leaderboardCampName = campaign
+	mock.leaderboardLimit = 2
+	refreshedOn := now.UTC()
+	mock.leaderboardResult = &types.Leaderboard{
+		CampaignName:   campaign,
+		TieBreaker:     types.TieBreakerBugCategories,
+		TieBreakerRule: types.TieBreakerRules[types.TieBreakerBugCategories],
+		RefreshedOn:    &refreshedOn,
+		Participants: []types.LeaderboardEntry{
+			{Rank: 1, ScpName: scpName, LoginName: "first", Score: 5},
+			{Rank: 2, ScpName: scpName, LoginName: "second", Score: 5},
+		},
 	}
 
-	err := traverseBugCounts(nil, "", &points, &scored, &bugCounts)
-	assert.NoError(t, err)
-	assert.Equal(t, float64(19), points)
-	assert.Equal(t, float64(11), scored)
+	assert.NoError(t, getLeaderboard(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var leaderboard types.Leaderboard
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &leaderboard))
+	assert.Equal(t, *mock.leaderboardResult, leaderboard)
 }
 
-func TestTraverseBugCountsSimpleAndNestedMapNonClassified(t *testing.T) {
-	bugType := "myBugType"
-	nestedBugType := "myNestedBugType"
+// setupMockAnonymized makes the campaign show pseudonyms on its public leaderboards.
+func setupMockAnonymized(mock *MockBBashDB) {
+	mock.selectPrivacyCampName = campaign
+	mock.selectPrivacyResult = &types.CampaignPrivacyStruct{CampaignName: campaign, Anonymize: true, Salt: "mySalt"}
+	mock.selectPrivacyErr = nil
+}
 
-	mock := newMockDb(t)
-	mock.assertParameters = false
+func TestPseudonym(t *testing.T) {
+	privacy := &types.CampaignPrivacyStruct{Salt: "mySalt"}
+	first := pseudonym(privacy, scpName, loginName)
+	assert.True(t, strings.HasPrefix(first, pseudonymPrefix), first)
+	assert.Equal(t, len(pseudonymPrefix)+12, len(first))
+	assert.Equal(t, first, pseudonym(privacy, scpName, loginName))
+	assert.NotEqual(t, first, pseudonym(privacy, scpName, "other"))
+	assert.NotEqual(t, first, pseudonym(privacy, "otherScp", loginName))
+	assert.NotEqual(t, first, pseudonym(&types.CampaignPrivacyStruct{Salt: "otherSalt"}, scpName, loginName))
+}
 
-	points := float64(1)
-	scored := float64(2)
-	mapNestedBugType := map[string]interface{}{
-		nestedBugType: float64(3),
-	}
-	bugCounts := map[string]interface{}{
-		"bugTypeSimpleFirst": float64(2),
-		bugType:              mapNestedBugType,
-		"bugTypeSimpleLast":  float64(4),
+func TestGetLeaderboardAnonymized(t *testing.T) {
+	c, rec := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
+	mock := newMockDb(t)
+	setupMockAnonymized(mock)
+	mock.leaderboardCampName = campaign
+	mock.leaderboardLimit = defaultLeaderboardLimit
+	mock.leaderboardResult = &types.Leaderboard{
+		CampaignName: campaign,
+		Participants: []types.LeaderboardEntry{
+			{Rank: 1, ScpName: scpName, LoginName: "first", DisplayName: "First", AvatarUrl: "https://avatars/first", Score: 5},
+		},
 	}
 
-	err := traverseBugCounts(nil, "", &points, &scored, &bugCounts)
-	assert.NoError(t, err)
-	assert.Equal(t, float64(1), points)
-	assert.Equal(t, float64(11), scored)
+	assert.NoError(t, getLeaderboard(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var leaderboard types.Leaderboard
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &leaderboard))
+	assert.Equal(t, []types.LeaderboardEntry{
+		{Rank: 1, ScpName: scpName, LoginName: pseudonym(mock.selectPrivacyResult, scpName, "first"), Score: 5},
+	}, leaderboard.Participants)
+	assert.False(t, strings.Contains(rec.Body.String(), "first"), rec.Body.String())
 }
 
-func TestScorePointsNothing(t *testing.T) {
-	msg := &types.ScoringMessage{}
-	points := scorePoints(msg, campaign)
-	assert.Equal(t, float64(0), points)
+func TestGetLeaderboardNotAnonymized(t *testing.T) {
+	c, rec := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
+	mock := newMockDb(t)
+	mock.selectPrivacyCampName = campaign
+	mock.selectPrivacyResult = &types.CampaignPrivacyStruct{CampaignName: campaign, Anonymize: false, Salt: "mySalt"}
+	mock.selectPrivacyErr = nil
+	mock.leaderboardCampName = campaign
+	mock.leaderboardLimit = defaultLeaderboardLimit
+	mock.leaderboardResult = &types.Leaderboard{
+		CampaignName: campaign,
+		Participants: []types.LeaderboardEntry{{Rank: 1, ScpName: scpName, LoginName: "first", Score: 5}},
+	}
+
+	assert.NoError(t, getLeaderboard(c))
+	var leaderboard types.Leaderboard
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &leaderboard))
+	assert.Equal(t, "first", leaderboard.Participants[0].LoginName)
 }
 
-func TestScorePoints(t *testing.T) {
+func TestGetLeaderboardPrivacyError(t *testing.T) {
+	c, _ := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
 	mock := newMockDb(t)
-	msg := &types.ScoringMessage{BugCounts: map[string]interface{}{"myBugType": float64(1)}}
-	mock.selectPointValueMsg = msg
-	mock.selectPointValueCampaign = campaign
-	mock.selectPointValueBugType = "myBugType"
-	mock.selectPointValueResult = 1
+	mock.leaderboardCampName = campaign
+	mock.leaderboardLimit = defaultLeaderboardLimit
+	mock.leaderboardResult = &types.Leaderboard{CampaignName: campaign}
+	forcedError := fmt.Errorf("forced privacy error")
+	mock.selectPrivacyErr = forcedError
 
-	_, _ = setupMockContext()
+	assert.EqualError(t, getLeaderboard(c), forcedError.Error())
+}
+
+func TestGetPublicLeaderboardLimitTooHigh(t *testing.T) {
+	c, _ := setupMockContextTelemetry(fmt.Sprintf("limit=%d", defaultLeaderboardLimit+1))
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
+	newMockDb(t)
 
-	points := scorePoints(msg, campaign)
-	assert.Equal(t, float64(1), points)
+	assertApiError(t, getPublicLeaderboard(c), http.StatusBadRequest, fmt.Sprintf("invalid limit: %d", defaultLeaderboardLimit+1))
 }
 
-func TestScorePointsWithTraverseError(t *testing.T) {
+func TestGetPublicLeaderboardNoCampaign(t *testing.T) {
+	c, _ := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
 	mock := newMockDb(t)
-	msg := &types.ScoringMessage{BugCounts: map[string]interface{}{
-		"myBadBugType": "bogusValueType",
-		"myGoodugType": float64(2),
-	}}
-	mock.assertParameters = false
-	mock.selectPointValueResult = 2
-
-	_, _ = setupMockContext()
+	mock.selectCampaignTenantErr = sql.ErrNoRows
 
-	points := scorePoints(msg, campaign)
-	assert.Equal(t, float64(4), points)
+	assertApiError(t, getPublicLeaderboard(c), http.StatusNotFound, "no campaign: campaignName: "+campaign)
 }
 
-func TestScorePointsFixedTwoThreePointers(t *testing.T) {
+func TestGetPublicLeaderboardTenantCampaign(t *testing.T) {
+	c, _ := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
 	mock := newMockDb(t)
-	mock.selectPointValueResult = 3
-	bugType := "threePointBugType"
-	msg := &types.ScoringMessage{BugCounts: map[string]interface{}{bugType: float64(2)}}
-	mock.selectPointValueMsg = msg
-	mock.selectPointValueCampaign = campaign
-	mock.selectPointValueBugType = bugType
+	mock.selectCampaignTenantResult = "myTenant"
 
-	points := scorePoints(msg, campaign)
-	assert.Equal(t, float64(6), points)
+	assertApiError(t, getPublicLeaderboard(c), http.StatusNotFound, "no campaign: campaignName: "+campaign)
 }
 
-func TestScorePointsWithOptMap(t *testing.T) {
+func TestGetPublicLeaderboardTenantError(t *testing.T) {
+	c, _ := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
 	mock := newMockDb(t)
-	mock.assertParameters = false
-	mock.selectPointValueResult = 3
+	forcedError := fmt.Errorf("forced tenant error")
+	mock.selectCampaignTenantErr = forcedError
 
-	// similar to this:
-	// "fixed-bug-types":{"opt":{"semgrep":{"node_password":1,"node_username":1}}}
-	mapSemGroupBugType := map[string]interface{}{"sprintf-host-port": float64(2)}
-	mapSemGrep := map[string]interface{}{"semgrep": mapSemGroupBugType}
-	mapBugTypes := map[string]interface{}{
-		"G104":       float64(1),
-		"ShellCheck": float64(1),
-		"opt":        mapSemGrep,
-	}
-	msg := types.ScoringMessage{
-		BugCounts: mapBugTypes,
+	assert.EqualError(t, getPublicLeaderboard(c), forcedError.Error())
+}
+
+func TestGetPublicLeaderboard(t *testing.T) {
+	c, rec := setupMockContextTelemetry(fmt.Sprintf("limit=%d", defaultLeaderboardLimit))
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
+	mock := newMockDb(t)
+	setupMockAnonymized(mock)
+	mock.leaderboardCampName = campaign
+	mock.leaderboardLimit = defaultLeaderboardLimit
+	mock.leaderboardResult = &types.Leaderboard{
+		CampaignName: campaign,
+		Participants: []types.LeaderboardEntry{{Rank: 1, ScpName: scpName, LoginName: "first", Score: 5}},
 	}
 
-	points := scorePoints(&msg, campaign)
-	assert.Equal(t, float64(12), points)
+	assert.NoError(t, getPublicLeaderboard(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var leaderboard types.Leaderboard
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &leaderboard))
+	assert.Equal(t, []types.LeaderboardEntry{
+		{Rank: 1, ScpName: scpName, LoginName: pseudonym(mock.selectPrivacyResult, scpName, "first"), Score: 5},
+	}, leaderboard.Participants)
 }
 
-func TestScorePointsBonusForNonClassified(t *testing.T) {
-	msg := &types.ScoringMessage{TotalFixed: 1}
-	points := scorePoints(msg, campaign)
-	assert.Equal(t, float64(1), points)
-}
+func TestSetupRoutesPublicApi(t *testing.T) {
+	e := echo.New()
+	setupRoutes(e, config.Defaults(), "")
+	mock := newMockDb(t)
+	mock.leaderboardCampName = campaign
+	mock.leaderboardLimit = defaultLeaderboardLimit
+	mock.leaderboardResult = &types.Leaderboard{CampaignName: campaign, Participants: []types.LeaderboardEntry{}}
 
-func TestProcessScoringMessageInvalidScore_Error(t *testing.T) {
-	msg := types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName}
+	req := httptest.NewRequest(http.MethodGet, pathPublic+Campaign+"/"+campaign+Leaderboard, nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Header().Get(headerCacheControl), "public, max-age="), rec.Header().Get(headerCacheControl))
 
-	mock := newMockDb(t)
-	setupMockDBOrgValid(mock)
-	msgLowerCase := msg
-	msgLowerCase.TriggerUser = strings.ToLower(msgLowerCase.TriggerUser)
-	mock.validOrgParam = &msgLowerCase
-	forcedError := fmt.Errorf("forced validScore error")
-	mock.validOrgErr = forcedError
+	req = httptest.NewRequest(http.MethodGet, pathPublic+Campaign+List, nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
 
-	err := processScoringMessage(mock, now, &msg)
-	assert.EqualError(t, err, forcedError.Error())
+	req = httptest.NewRequest(http.MethodPut, pathPublic+Campaign+List, nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
 }
 
-func TestProcessScoringMessageInvalidScore_NoTriggerUserFound(t *testing.T) {
-	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName}
-
+func TestGetTeamLeaderboardNoTeam(t *testing.T) {
+	c, _ := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName, ParamTeamName)
+	c.SetParamValues(campaign, teamName)
 	mock := newMockDb(t)
-	setupMockDBOrgValid(mock)
-	msgLowerCase := msg
-	msgLowerCase.TriggerUser = strings.ToLower(loginName)
-	mock.validOrgParam = msgLowerCase
-	mock.partiesToScoreMsg = msgLowerCase
-	// caller users Time.now(), so don't assert time parameter
-	mock.partiesToScoreNowSkip = true
+	mock.teamLeaderboardCampName = campaign
+	mock.teamLeaderboardTeamName = teamName
+	mock.teamLeaderboardErr = sql.ErrNoRows
 
-	err := processScoringMessage(mock, now, msg)
-	assert.NoError(t, err)
+	assertApiError(t, getTeamLeaderboard(c), http.StatusNotFound, fmt.Sprintf("no team: campaignName: %s, name: %s", campaign, teamName))
 }
 
-func TestProcessScoringMessageUserCapitalizationMismatch(t *testing.T) {
-	loginNameWithCaps := "MYGithubName"
-	//loginNameLowerCase := strings.ToLower(loginName)
-	repoName := "myRepoName"
-	prId := -5
-	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginNameWithCaps, RepoName: repoName, PullRequest: prId}
-
+func TestGetTeamLeaderboardError(t *testing.T) {
+	c, _ := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName, ParamTeamName)
+	c.SetParamValues(campaign, teamName)
 	mock := newMockDb(t)
-	setupMockDBOrgValid(mock)
-	msgLowerCase := msg
-	msgLowerCase.TriggerUser = strings.ToLower(loginNameWithCaps)
-	mock.validOrgParam = msgLowerCase
-	mock.partiesToScoreMsg = msgLowerCase
-	// caller users Time.now(), so don't assert time parameter
-	mock.partiesToScoreNowSkip = true
+	mock.teamLeaderboardCampName = campaign
+	mock.teamLeaderboardTeamName = teamName
+	forcedError := fmt.Errorf("forced team leaderboard error")
+	mock.teamLeaderboardErr = forcedError
 
-	err := processScoringMessage(mock, now, msg)
-	assert.NoError(t, err)
+	assert.EqualError(t, getTeamLeaderboard(c), forcedError.Error())
 }
 
-func TestProcessScoringMessageOne(t *testing.T) {
-	repoName := "myRepoName"
-	prId := -5
-	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName, RepoName: repoName, PullRequest: prId}
-
+func TestGetTeamLeaderboard(t *testing.T) {
+	c, rec := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName, ParamTeamName)
+	c.SetParamValues(campaign, teamName)
 	mock := newMockDb(t)
-	setupMockDBOrgValid(mock)
-	msgLowerCase := msg
-	msgLowerCase.TriggerUser = strings.ToLower(loginName)
-	mock.validOrgParam = msgLowerCase
-	mock.partiesToScoreMsg = msgLowerCase
-	// caller users Time.now(), so don't assert time parameter
-	mock.partiesToScoreNowSkip = true
+	mock.teamLeaderboardCampName = campaign
+	mock.teamLeaderboardTeamName = teamName
+	refreshedOn := now.UTC()
+	mock.teamLeaderboardResult = &types.TeamLeaderboard{
+		CampaignName: campaign,
+		TeamStanding: types.TeamStanding{TeamName: teamName, MemberPoints: 8, AwardPoints: 2, Points: 10},
+		RefreshedOn:  &refreshedOn,
+		Participants: []types.LeaderboardEntry{
+			{Rank: 1, ScpName: scpName, LoginName: "first", TeamName: teamName, Score: 5},
+			{Rank: 2, ScpName: scpName, LoginName: "second", TeamName: teamName, Score: 3},
+		},
+	}
 
-	err := processScoringMessage(mock, now, msg)
-	assert.NoError(t, err)
+	assert.NoError(t, getTeamLeaderboard(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.True(t, strings.Contains(rec.Body.String(), fmt.Sprintf(`"teamName":"%s","memberPoints":8,"awardPoints":2,"points":10`, teamName)), rec.Body.String())
+	var leaderboard types.TeamLeaderboard
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &leaderboard))
+	assert.Equal(t, *mock.teamLeaderboardResult, leaderboard)
 }
 
-func TestProcessScoringMessageTwoParticipantsToScore(t *testing.T) {
-	repoName := "myRepoName"
-	prId := -5
-	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName,
-		RepoName: repoName, PullRequest: prId, TotalFixed: 2}
-
+func TestGetTeamLeaderboardAnonymized(t *testing.T) {
+	c, rec := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName, ParamTeamName)
+	c.SetParamValues(campaign, teamName)
 	mock := newMockDb(t)
-	setupMockDBOrgValid(mock)
-	msgLowerCase := msg
-	msgLowerCase.TriggerUser = strings.ToLower(loginName)
-	mock.validOrgParam = msgLowerCase
-	mock.partiesToScoreMsg = msgLowerCase
-	// caller users Time.now(), so don't assert time parameter
-	mock.partiesToScoreNowSkip = true
-	mock.partiesToScoreResult = []types.ParticipantStruct{
-		{
-			ID:           "someId",
-			CampaignName: "someCampaign",
-			ScpName:      "someSCP",
-			LoginName:    "someLoginName",
-		},
-		{
-			ID:           "someId2",
-			CampaignName: "someCampaign2",
-			ScpName:      "someSCP2",
-			LoginName:    "someLoginName2",
-		},
+	setupMockAnonymized(mock)
+	mock.teamLeaderboardCampName = campaign
+	mock.teamLeaderboardTeamName = teamName
+	mock.teamLeaderboardResult = &types.TeamLeaderboard{
+		CampaignName: campaign,
+		TeamStanding: types.TeamStanding{TeamName: teamName},
+		Participants: []types.LeaderboardEntry{{Rank: 1, ScpName: scpName, LoginName: "first", TeamName: teamName, Score: 5}},
 	}
-	mock.priorScoreResult = 4
 
-	mock.priorScoreParticipant = &mock.partiesToScoreResult[0]
-	mock.priorScoreMsg = msg
+	assert.NoError(t, getTeamLeaderboard(c))
+	var leaderboard types.TeamLeaderboard
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &leaderboard))
+	assert.Equal(t, []types.LeaderboardEntry{
+		{Rank: 1, ScpName: scpName, LoginName: pseudonym(mock.selectPrivacyResult, scpName, "first"), TeamName: teamName, Score: 5},
+	}, leaderboard.Participants)
+}
 
-	mock.insertScoreEvtPartier = &mock.partiesToScoreResult[0]
-	mock.insertScoreEvtMsg = msg
-	mock.insertScoreEvtNewPoints = 2
+func TestGetFinalLeaderboardNotFinalized(t *testing.T) {
+	c, _ := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
+	mock := newMockDb(t)
+	mock.selectSnapshotCampName = campaign
+	mock.selectSnapshotErr = sql.ErrNoRows
 
-	err := processScoringMessage(mock, now, msg)
-	assert.NoError(t, err)
-	assert.Equal(t, float64(-3), updateScoreLastDelta)
+	assertApiError(t, getFinalLeaderboard(c), http.StatusNotFound, fmt.Sprintf("no final leaderboard: campaignName: %s", campaign))
 }
 
-func TestProcessScoringMessageParticipantPriorScoreError(t *testing.T) {
-	repoName := "myRepoName"
-	prId := -5
-	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName, RepoName: repoName, PullRequest: prId}
-
+func TestGetFinalLeaderboardError(t *testing.T) {
+	c, _ := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
 	mock := newMockDb(t)
-	setupMockDBOrgValid(mock)
-	msgLowerCase := msg
-	msgLowerCase.TriggerUser = strings.ToLower(loginName)
-	mock.validOrgParam = msgLowerCase
-	mock.partiesToScoreMsg = msgLowerCase
-	// caller users Time.now(), so don't assert time parameter
-	mock.partiesToScoreNowSkip = true
-	mock.partiesToScoreResult = []types.ParticipantStruct{
-		{
-			ID:           "someId",
-			CampaignName: "someCampaign",
-			ScpName:      "someSCP",
-			LoginName:    "someLoginName",
-		},
-	}
+	mock.selectSnapshotCampName = campaign
+	forcedError := fmt.Errorf("forced snapshot error")
+	mock.selectSnapshotErr = forcedError
 
-	mock.priorScoreParticipant = &mock.partiesToScoreResult[0]
-	mock.priorScoreMsg = msgLowerCase
+	assert.EqualError(t, getFinalLeaderboard(c), forcedError.Error())
+}
 
-	mock.insertScoreEvtPartier = &mock.partiesToScoreResult[0]
-	mock.insertScoreEvtMsg = msgLowerCase
-	forcedError := fmt.Errorf("forced prior score error")
-	mock.insertScoreEvtErr = forcedError
+func TestGetFinalLeaderboardAnonymized(t *testing.T) {
+	c, rec := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
+	mock := newMockDb(t)
+	setupMockAnonymized(mock)
+	mock.selectSnapshotCampName = campaign
+	mock.selectSnapshotResult = &types.LeaderboardSnapshot{
+		CampaignName: campaign,
+		FinalizedOn:  now.UTC(),
+		Participants: []types.LeaderboardEntry{{Rank: 1, ScpName: scpName, LoginName: loginName, Score: 5}},
+	}
 
-	err := processScoringMessage(mock, now, msg)
-	assert.EqualError(t, err, forcedError.Error())
+	assert.NoError(t, getFinalLeaderboard(c))
+	var snapshot types.LeaderboardSnapshot
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &snapshot))
+	assert.Equal(t, []types.LeaderboardEntry{
+		{Rank: 1, ScpName: scpName, LoginName: pseudonym(mock.selectPrivacyResult, scpName, loginName), Score: 5},
+	}, snapshot.Participants)
 }
 
-func TestProcessScoringMessageParticipantUpdateScoreError(t *testing.T) {
-	repoName := "myRepoName"
-	prId := -5
-	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName, RepoName: repoName, PullRequest: prId}
-
+func TestGetFinalLeaderboard(t *testing.T) {
+	c, rec := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
 	mock := newMockDb(t)
-	setupMockDBOrgValid(mock)
-	msgLowerCase := msg
-	msgLowerCase.TriggerUser = strings.ToLower(loginName)
-	mock.validOrgParam = msgLowerCase
-	mock.partiesToScoreMsg = msgLowerCase
-	// caller users Time.now(), so don't assert time parameter
-	mock.partiesToScoreNowSkip = true
-	mock.partiesToScoreResult = []types.ParticipantStruct{
-		{
-			ID:           "someId",
-			CampaignName: "someCampaign",
-			ScpName:      "someSCP",
-			LoginName:    "someLoginName",
-		},
+	mock.selectSnapshotCampName = campaign
+	mock.selectSnapshotResult = &types.LeaderboardSnapshot{
+		CampaignName:   campaign,
+		TieBreaker:     types.TieBreakerReachedScore,
+		TieBreakerRule: types.TieBreakerRules[types.TieBreakerReachedScore],
+		FinalizedOn:    now.UTC(),
+		Participants:   []types.LeaderboardEntry{{Rank: 1, ScpName: scpName, LoginName: loginName, Score: 5}},
 	}
 
-	mock.priorScoreParticipant = &mock.partiesToScoreResult[0]
-	mock.priorScoreMsg = msgLowerCase
-
-	mock.insertScoreEvtPartier = &mock.partiesToScoreResult[0]
-	mock.insertScoreEvtMsg = msgLowerCase
+	assert.NoError(t, getFinalLeaderboard(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var snapshot types.LeaderboardSnapshot
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &snapshot))
+	assert.Equal(t, *mock.selectSnapshotResult, snapshot)
+}
 
-	mock.updateScoreParticipant = &mock.partiesToScoreResult[0]
-	forcedError := fmt.Errorf("forced update participant score error")
-	mock.updateScoreErr = forcedError
+func TestFinalizeCampaignLeaderboardRefreshError(t *testing.T) {
+	c, _ := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced refresh error")
+	mock.refreshLeaderboardErr = forcedError
+	snapshotted := false
+	mock.insertSnapshotCalled = &snapshotted
 
-	err := processScoringMessage(mock, now, msg)
-	assert.EqualError(t, err, forcedError.Error())
+	assert.EqualError(t, finalizeCampaignLeaderboard(c), forcedError.Error())
+	assert.False(t, snapshotted)
 }
 
-func TestProcessScoringMessageParticipant(t *testing.T) {
-	repoName := "myRepoName"
-	prId := -5
-	msg := &types.ScoringMessage{EventSource: db.TestEventSourceValid, RepoOwner: db.TestOrgValid, TriggerUser: loginName, RepoName: repoName, PullRequest: prId,
-		BugCounts: map[string]interface{}{category: float64(2)}}
-
+func TestFinalizeCampaignLeaderboardNoCampaign(t *testing.T) {
+	c, _ := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
 	mock := newMockDb(t)
-	setupMockDBOrgValid(mock)
-	msgLowerCase := msg
-	msgLowerCase.TriggerUser = strings.ToLower(loginName)
-	mock.validOrgParam = msgLowerCase
-	mock.partiesToScoreMsg = msgLowerCase
-	// caller users Time.now(), so don't assert time parameter
-	mock.partiesToScoreNowSkip = true
-	mock.partiesToScoreResult = []types.ParticipantStruct{
-		{
-			ID:           "someId",
-			CampaignName: campaign,
-			ScpName:      "someSCP",
-			LoginName:    "someLoginName",
-		},
-	}
+	mock.insertSnapshotCampName = campaign
+	mock.selectSnapshotCampName = campaign
+	mock.selectSnapshotErr = sql.ErrNoRows
 
-	mock.selectPointValueMsg = msgLowerCase
-	mock.selectPointValueCampaign = campaign
-	mock.selectPointValueBugType = category
-	mock.selectPointValueResult = 3
+	assertApiError(t, finalizeCampaignLeaderboard(c), http.StatusNotFound, fmt.Sprintf("no campaign: campaignName: %s", campaign))
+}
 
-	mock.priorScoreParticipant = &mock.partiesToScoreResult[0]
-	mock.priorScoreMsg = msgLowerCase
-	mock.priorScoreResult = 2
+func TestFinalizeCampaignLeaderboardAlreadyFinalized(t *testing.T) {
+	c, _ := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
+	mock := newMockDb(t)
+	mock.insertSnapshotCampName = campaign
+	mock.selectSnapshotCampName = campaign
+	mock.selectSnapshotResult = &types.LeaderboardSnapshot{CampaignName: campaign, FinalizedOn: time.Date(2021, 11, 15, 0, 0, 0, 0, time.UTC)}
 
-	mock.insertScoreEvtPartier = &mock.partiesToScoreResult[0]
-	mock.insertScoreEvtMsg = msgLowerCase
-	mock.insertScoreEvtNewPoints = 6
+	assertApiError(t, finalizeCampaignLeaderboard(c), http.StatusConflict, fmt.Sprintf("campaign %s was finalized at 2021-11-15T00:00:00Z", campaign))
+}
 
-	mock.updateScoreParticipant = &mock.partiesToScoreResult[0]
-	mock.updateScoreDelta = 4
+func TestFinalizeCampaignLeaderboard(t *testing.T) {
+	c, rec := setupMockContextTelemetry("")
+	c.SetParamNames(ParamCampaignName)
+	c.SetParamValues(campaign)
+	mock := newMockDb(t)
+	refreshed := false
+	mock.refreshLeaderboardCalled = &refreshed
+	mock.insertSnapshotCampName = campaign
+	mock.insertSnapshotFinalized = true
+	mock.selectSnapshotCampName = campaign
+	mock.selectSnapshotResult = &types.LeaderboardSnapshot{
+		CampaignName: campaign,
+		FinalizedOn:  now.UTC(),
+		Participants: []types.LeaderboardEntry{{Rank: 1, ScpName: scpName, LoginName: loginName, Score: 5}},
+	}
 
-	err := processScoringMessage(mock, now, msg)
-	assert.NoError(t, err)
+	assert.NoError(t, finalizeCampaignLeaderboard(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	assert.True(t, refreshed)
+	var snapshot types.LeaderboardSnapshot
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &snapshot))
+	assert.Equal(t, *mock.selectSnapshotResult, snapshot)
 }
 
-func TestGetSourceControlProvidersQueryError(t *testing.T) {
-	c, rec := setupMockContext()
-
+func TestRefreshLeaderboardStaleError(t *testing.T) {
+	logger = zaptest.NewLogger(t)
 	mock := newMockDb(t)
-	forcedError := fmt.Errorf("forced scp error")
-	mock.getSCPPsErr = forcedError
+	mock.leaderboardStaleErr = fmt.Errorf("forced stale error")
+	refreshed := false
+	mock.refreshLeaderboardCalled = &refreshed
 
-	err := getSourceControlProviders(c)
-	assert.EqualError(t, err, forcedError.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	refreshLeaderboard()
+	assert.False(t, refreshed)
 }
 
-func TestGetSourceControlProviders(t *testing.T) {
-	c, rec := setupMockContext()
+func TestRefreshLeaderboardNotStale(t *testing.T) {
+	logger = zaptest.NewLogger(t)
+	mock := newMockDb(t)
+	refreshed := false
+	mock.refreshLeaderboardCalled = &refreshed
+
+	refreshLeaderboard()
+	assert.False(t, refreshed)
+}
 
+func TestRefreshLeaderboardStale(t *testing.T) {
+	logger = zaptest.NewLogger(t)
 	mock := newMockDb(t)
-	mock.getSCPPs = []types.SourceControlProviderStruct{
-		{
-			ID:      "someId",
-			SCPName: "someSCP",
-			Url:     "someUrl",
-		},
-	}
+	mock.leaderboardStale = true
+	refreshed := false
+	mock.refreshLeaderboardCalled = &refreshed
 
-	err := getSourceControlProviders(c)
-	assert.NoError(t, err)
-	assert.Equal(t, http.StatusOK, c.Response().Status)
-	assert.Equal(t, "[{\"guid\":\"someId\",\"scpName\":\"someSCP\",\"url\":\"someUrl\"}]\n", rec.Body.String())
+	refreshLeaderboard()
+	assert.True(t, refreshed)
 }
 
-func TestGetOrganizationsError(t *testing.T) {
-	c, rec := setupMockContext()
+const tenantName = "myTenantName"
 
-	mock := newMockDb(t)
-	forcedErr := fmt.Errorf("forced org list error")
-	mock.getOrganizationsErr = forcedErr
+func setupMockContextTenant(method, body string) (c echo.Context, rec *httptest.ResponseRecorder) {
+	c, rec = setupMockContextWithBody(method, body)
+	c.Set(ctxTenantName, tenantName)
+	return
+}
 
-	err := getOrganizations(c)
-	assert.EqualError(t, err, forcedErr.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+func hashTenantPassword(t *testing.T, password string) string {
+	// the minimum cost keeps the tests fast
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	assert.NoError(t, err)
+	return string(hash)
 }
 
-func TestGetOrganizations(t *testing.T) {
-	c, rec := setupMockContext()
+func TestTenantValidatorValid(t *testing.T) {
+	logger = zaptest.NewLogger(t)
 
 	mock := newMockDb(t)
-	mock.getOrganizationsResult = []types.OrganizationStruct{
-		{
-			ID:           "someId",
-			SCPName:      "someSCP",
-			Organization: "someOrg",
-		},
-	}
+	mock.selectTenantAdminUsername = "tenantAdmin"
+	mock.selectTenantAdminPasswordHash = hashTenantPassword(t, "tenantPassword")
+	c, _ := setupMockContext()
 
-	err := getOrganizations(c)
+	isValid, err := newTenantValidator(config.Defaults())("tenantAdmin", "tenantPassword", c)
 	assert.NoError(t, err)
-	assert.Equal(t, http.StatusOK, c.Response().Status)
-	assert.Equal(t, "[{\"guid\":\"someId\",\"scpName\":\"someSCP\",\"organization\":\"someOrg\"}]\n", rec.Body.String())
+	assert.True(t, isValid)
 }
 
-func TestAddOrganizationBodyBad(t *testing.T) {
-	c, rec := setupMockContext()
+func TestTenantValidatorInValid(t *testing.T) {
+	logger = zaptest.NewLogger(t)
 
-	err := addOrganization(c)
-	assert.EqualError(t, err, "EOF")
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	mock := newMockDb(t)
+	mock.selectTenantAdminUsername = "tenantAdmin"
+	mock.selectTenantAdminPasswordHash = hashTenantPassword(t, "tenantPassword")
+	c, _ := setupMockContext()
+
+	isValid, err := newTenantValidator(config.Defaults())("tenantAdmin", "Doh!", c)
+	assert.NoError(t, err)
+	assert.False(t, isValid)
 }
 
-func TestAddOrganizationInsertError(t *testing.T) {
-	c, rec := setupMockContextWithBody(http.MethodPut, "{\"organization\":\"myOrganizationName\"}")
+func TestTenantValidatorAdmin(t *testing.T) {
+	logger = zaptest.NewLogger(t)
 
 	mock := newMockDb(t)
-	mock.insertOrganizationParam = &types.OrganizationStruct{
-		Organization: "myOrganizationName",
-	}
-	forcedError := fmt.Errorf("forced org add error")
-	mock.insertOrganizationErr = forcedError
+	mock.selectTenantAdminErr = sql.ErrNoRows
+	c, _ := setupMockContext()
 
-	err := addOrganization(c)
-	assert.EqualError(t, err, forcedError.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	isValid, err := newTenantValidator(&config.Config{AdminUsername: "yadda", AdminPassword: "bing"})("yadda", "bing", c)
+	assert.NoError(t, err)
+	assert.True(t, isValid)
 }
 
-func TestAddOrganization(t *testing.T) {
-	c, rec := setupMockContextWithBody(http.MethodPut, "{\"organization\":\"myOrganizationName\"}")
+func TestTenantValidatorNoTenant(t *testing.T) {
+	logger = zaptest.NewLogger(t)
 
 	mock := newMockDb(t)
-	mock.insertOrganizationParam = &types.OrganizationStruct{
-		Organization: "myOrganizationName",
-	}
-	mock.insertOrganizationGuid = "someId"
+	mock.selectTenantAdminErr = sql.ErrNoRows
+	c, _ := setupMockContext()
 
-	err := addOrganization(c)
+	isValid, err := newTenantValidator(config.Defaults())("tenantAdmin", "tenantPassword", c)
 	assert.NoError(t, err)
-	assert.Equal(t, http.StatusCreated, c.Response().Status)
-	assert.Equal(t, "someId", rec.Body.String())
+	assert.False(t, isValid)
 }
 
-func TestDeleteOrganizationDeleteError(t *testing.T) {
-	c, rec := setupMockContext()
+func TestTenantValidatorError(t *testing.T) {
+	logger = zaptest.NewLogger(t)
 
 	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced tenant admin error")
+	mock.selectTenantAdminErr = forcedError
+	c, _ := setupMockContext()
 
-	forcedError := fmt.Errorf("forced org delete error")
-	mock.deleteOrgErr = forcedError
-
-	err := deleteOrganization(c)
+	isValid, err := newTenantValidator(config.Defaults())("tenantAdmin", "tenantPassword", c)
 	assert.EqualError(t, err, forcedError.Error())
-	assert.Equal(t, 0, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	assert.False(t, isValid)
 }
 
-func TestDeleteOrganizationNotFound(t *testing.T) {
-	c, rec := setupMockContext()
+func TestCheckTenantCampaignAdmin(t *testing.T) {
+	c, _ := setupMockContext()
 
+	// the admin endpoints do not read the tenant of the campaign
 	mock := newMockDb(t)
-	mock.deleteOrgRowsAffected = 0
+	mock.selectCampaignTenantErr = fmt.Errorf("unexpected tenant read")
 
-	err := deleteOrganization(c)
-	assert.NoError(t, err)
-	assert.Equal(t, http.StatusNotFound, c.Response().Status)
-	assert.Equal(t, "\"no organization: scpName: , name: \"\n", rec.Body.String())
+	assert.NoError(t, checkTenantCampaign(c, campaign))
 }
 
-func TestDeleteOrganization(t *testing.T) {
-	c, rec := setupMockContext()
+func TestCheckTenantCampaign(t *testing.T) {
+	c, _ := setupMockContextTenant(http.MethodGet, "")
 
 	mock := newMockDb(t)
-	mock.deleteOrgRowsAffected = 1
+	mock.selectCampaignTenantResult = tenantName
 
-	err := deleteOrganization(c)
-	assert.NoError(t, err)
-	assert.Equal(t, http.StatusNoContent, c.Response().Status)
-	assert.Equal(t, "", rec.Body.String())
+	assert.NoError(t, checkTenantCampaign(c, campaign))
 }
 
-func saveEnvAdminCredentials(t *testing.T) (resetInfoCreds func()) {
-	origInfoUsername := os.Getenv(envAdminUsername)
-	origInfoPassword := os.Getenv(envAdminPassword)
-	resetInfoCreds = func() {
-		resetEnvVariable(t, envAdminUsername, origInfoUsername)
-		resetEnvVariable(t, envAdminUsername, origInfoPassword)
-	}
+func TestCheckTenantCampaignOtherTenant(t *testing.T) {
+	c, _ := setupMockContextTenant(http.MethodGet, "")
 
-	// setup testing logger while we're here
-	logger = zaptest.NewLogger(t)
+	mock := newMockDb(t)
+	mock.selectCampaignTenantResult = "otherTenantName"
 
-	return
+	assertApiError(t, checkTenantCampaign(c, campaign), http.StatusNotFound, "no campaign: campaignName: myCampaignName")
 }
 
-func TestInfoBasicValidatorMissingEnv(t *testing.T) {
-	resetInfoCreds := saveEnvAdminCredentials(t)
-	defer resetInfoCreds()
-	assert.NoError(t, os.Unsetenv(envAdminUsername))
-	assert.NoError(t, os.Unsetenv(envAdminPassword))
+func TestCheckTenantCampaignNoTenant(t *testing.T) {
+	c, _ := setupMockContextTenant(http.MethodGet, "")
 
-	isValid, err := infoBasicValidator("yadda", "bing", nil)
-	assert.NoError(t, err)
-	assert.False(t, isValid)
+	// a campaign of the deployment admin
+	newMockDb(t)
+
+	assertApiError(t, checkTenantCampaign(c, campaign), http.StatusNotFound, "no campaign: campaignName: myCampaignName")
 }
 
-func TestInfoBasicValidatorInValid(t *testing.T) {
-	resetInfoCreds := saveEnvAdminCredentials(t)
-	defer resetInfoCreds()
-	assert.NoError(t, os.Setenv(envAdminUsername, "yadda"))
-	assert.NoError(t, os.Setenv(envAdminPassword, "Doh!"))
+func TestCheckTenantCampaignMissing(t *testing.T) {
+	c, _ := setupMockContextTenant(http.MethodGet, "")
 
-	isValid, err := infoBasicValidator("yadda", "bing", nil)
-	assert.NoError(t, err)
-	assert.False(t, isValid)
+	mock := newMockDb(t)
+	mock.selectCampaignTenantErr = sql.ErrNoRows
+
+	assertApiError(t, checkTenantCampaign(c, campaign), http.StatusNotFound, "no campaign: campaignName: myCampaignName")
 }
 
-func TestInfoBasicValidatorValid(t *testing.T) {
-	resetInfoCreds := saveEnvAdminCredentials(t)
-	defer resetInfoCreds()
-	assert.NoError(t, os.Setenv(envAdminUsername, "yadda"))
-	assert.NoError(t, os.Setenv(envAdminPassword, "bing"))
+func TestCheckTenantCampaignEmpty(t *testing.T) {
+	c, _ := setupMockContextTenant(http.MethodGet, "")
 
-	isValid, err := infoBasicValidator("yadda", "bing", nil)
-	assert.NoError(t, err)
-	assert.True(t, isValid)
+	newMockDb(t)
+
+	assertApiError(t, checkTenantCampaign(c, ""), http.StatusUnprocessableEntity, "missing campaignName: tenantName: myTenantName")
 }
 
-func TestLogTelemetry(t *testing.T) {
-	e := echo.New()
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
-	q := req.URL.Query()
-	q.Add(qpFeature, "testFeature")
-	q.Add(qpCall, "testCaller")
-	req.URL.RawQuery = q.Encode()
+func TestCheckTenantCampaignError(t *testing.T) {
+	c, _ := setupMockContextTenant(http.MethodGet, "")
 
-	rec := httptest.NewRecorder()
-	c := e.NewContext(req, rec)
+	mock := newMockDb(t)
+	forcedError := fmt.Errorf("forced campaign tenant error")
+	mock.selectCampaignTenantErr = forcedError
 
-	logger = zaptest.NewLogger(t)
-	logTelemetry(c)
+	assert.EqualError(t, checkTenantCampaign(c, campaign), forcedError.Error())
 }
 
-func TestLogTelemetryNoQueryParameters(t *testing.T) {
-	e := echo.New()
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
+func TestAddTenantInvalid(t *testing.T) {
+	c, _ := setupMockContextWithBody(http.MethodPut, `{"name": "my/tenant", "adminUsername": "tenantAdmin", "adminPassword": "short"}`)
 
-	rec := httptest.NewRecorder()
-	c := e.NewContext(req, rec)
+	newMockDb(t)
 
-	logger = zaptest.NewLogger(t)
-	logTelemetry(c)
+	err := addTenant(c)
+	assert.Equal(t, http.StatusUnprocessableEntity, toApiError(err).Status)
 }
 
-func TestProcessScoringMessage(t *testing.T) {
-	mock := newMockDb(t)
-	setupMockDBOrgValid(mock)
-
-	mock.assertParameters = false
-	now := time.Now()
-	// caller users Time.now(), so don't assert time parameter
-	//mock.partiesToScoreNowSkip = true
-	mock.partiesToScoreNow = now
-	mock.partiesToScoreResult = []types.ParticipantStruct{
-		{
-			ID:           "someId",
-			CampaignName: "someCampaign",
-			ScpName:      "someSCP",
-			LoginName:    "someLoginName",
-		},
-	}
+func TestAddTenantDuplicate(t *testing.T) {
+	c, _ := setupMockContextWithBody(http.MethodPut, `{"name": "`+tenantName+`", "adminUsername": "tenantAdmin", "adminPassword": "tenantPassword"}`)
 
-	mapSprintf := map[string]interface{}{
-		"sprintf-host-port": float64(2),
-	}
-	mapSemGrep := map[string]interface{}{
-		"semgrep": mapSprintf,
-	}
-	mapBugTypes := map[string]interface{}{
-		"opt":        mapSemGrep,
-		"G104":       float64(1),
-		"ShellCheck": float64(1),
-	}
+	mock := newMockDb(t)
+	mock.insertTenantParam = &types.TenantStruct{Name: tenantName, AdminUsername: "tenantAdmin"}
+	mock.insertTenantErr = &db.DuplicateError{Entity: "tenant", Constraint: "tenant_name_key"}
 
-	msg := &types.ScoringMessage{
-		BugCounts: mapBugTypes,
-	}
-	err := processScoringMessage(mock, now, msg)
-	assert.NoError(t, err)
+	assertApiError(t, addTenant(c), http.StatusConflict, "tenant already exists")
 }
 
-func TestBeginLogPolling(t *testing.T) {
-	logger = zaptest.NewLogger(t)
+func TestAddTenant(t *testing.T) {
+	c, rec := setupMockContextWithBody(http.MethodPut, `{"name": "`+tenantName+`", "adminUsername": "tenantAdmin", "adminPassword": "tenantPassword"}`)
 
-	_, sqlDb, closeDbFunc := db.SetupMockDB(t)
-	defer closeDbFunc()
-	// side effect: set up the postgresDB var
-	scoreDB = sqlDb
+	mock := newMockDb(t)
+	mock.insertTenantParam = &types.TenantStruct{Name: tenantName, AdminUsername: "tenantAdmin"}
+	mock.insertTenantPassword = "tenantPassword"
+	mock.insertTenantGuid = "tenantGuid"
 
-	quit, errChan, err := beginLogPolling()
-	defer func() {
-		//close(quit)
-		//close(errChan)
-	}()
-	assert.NoError(t, err)
-	assert.NotNil(t, quit)
-	assert.NotNil(t, errChan)
+	assert.NoError(t, addTenant(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	assert.NotContains(t, rec.Body.String(), "tenantPassword")
+	var creation creationResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &creation))
+	assert.Equal(t, "tenantGuid", creation.Id)
 }
 
-func closePollIfSet() {
-	if stopPoll != nil {
-		close(stopPoll)
-	}
+func TestGetTenants(t *testing.T) {
+	c, rec := setupMockContext()
+
+	mock := newMockDb(t)
+	mock.selectTenantsResult = []types.TenantStruct{{ID: "tenantGuid", Name: tenantName, AdminUsername: "tenantAdmin"}}
+
+	assert.NoError(t, getTenants(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	var tenants []types.TenantStruct
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tenants))
+	assert.Equal(t, mock.selectTenantsResult[0].Name, tenants[0].Name)
 }
-func TestRestartPolling(t *testing.T) {
-	logger = zaptest.NewLogger(t)
 
-	_, sqlDb, closeDbFunc := db.SetupMockDB(t)
-	defer closeDbFunc()
-	// side effect: set up the postgresDB var
-	scoreDB = sqlDb
+func TestGetCampaignsOfTenant(t *testing.T) {
+	c, rec := setupMockContextTenant(http.MethodGet, "")
 
-	// fake stopPolling chan
-	closePollIfSet()
-	stopPoll = make(chan bool)
+	mock := newMockDb(t)
+	mock.selectTenantCampaignsParam = tenantName
+	mock.selectTenantCampaignsResult = []types.CampaignStruct{{Name: campaign, TenantName: tenantName}}
+	// the campaigns of every tenant are not read
+	mock.getCampaignsErr = fmt.Errorf("unexpected campaigns read")
 
-	err := restartPolling(nil)
-	assert.NoError(t, err)
-	assert.NotNil(t, stopPoll)
+	assert.NoError(t, getCampaigns(c))
+	assert.Equal(t, http.StatusOK, c.Response().Status)
+	assert.Equal(t, "", rec.Header().Get(headerETag))
+	var campaigns []types.CampaignStruct
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &campaigns))
+	assert.Equal(t, mock.selectTenantCampaignsResult, campaigns)
 }
 
-func TestStopPolling(t *testing.T) {
-	// fake stopPolling chan
-	closePollIfSet()
-	stopPoll = make(chan bool)
+func TestAddCampaignOfTenant(t *testing.T) {
+	c, rec, testCampaign := setupMockContextCampaign(campaign)
+	c.Set(ctxTenantName, tenantName)
+
+	mock := newMockDb(t)
+	testCampaign.TenantName = tenantName
+	mock.insertCampaignParam = testCampaign
+	mock.insertCampaignGuid = campaignId
+	// the tenant has no update route here, so that endpoint is left out
+	c.Echo().GET("/tenant/:tenantName/admin/campaign/detail/:campaignName", getCampaign).Name = "tenant-campaign-detail"
+	c.Echo().PUT("/campaign/update/:campaignName", updateCampaign).Name = "campaign-update"
 
-	assert.NoError(t, stopPolling(nil))
-	assert.Nil(t, stopPoll)
-	// allow time for poll channel to finish logging during shutdown
-	time.Sleep(1 * time.Second)
+	assert.NoError(t, addCampaign(c))
+	assert.Equal(t, http.StatusCreated, c.Response().Status)
+	creation := assertCreation(t, rec, campaignId)
+	assert.Equal(t, map[string]endpointDetail{
+		"campaignDetail": {URI: "/tenant/" + tenantName + "/admin/campaign/detail/" + campaign, Verb: http.MethodGet},
+	}, creation.Endpoints)
 }
 
-func TestSetPollDateEmptyBody(t *testing.T) {
-	e := echo.New()
-	req := httptest.NewRequest("", "/", nil)
-	rec := httptest.NewRecorder()
-	c := e.NewContext(req, rec)
+func TestAddCampaignTenantNameIgnored(t *testing.T) {
+	c, _ := setupMockContextCampaignWithBody(campaign, fmt.Sprintf(`{"startOn": "%s", "endOn": "%s", "tenantName": "%s"}`,
+		testStartOn.Format(timeLayout), testEndOn.Format(timeLayout), tenantName))
 
-	assert.EqualError(t, setPollDate(c), "EOF")
+	// only the tenant endpoints add a campaign of a tenant
+	mock := newMockDb(t)
+	mock.insertCampaignParam = &types.CampaignStruct{Name: campaign, StartOn: testStartOn, EndOn: testEndOn}
+	mock.insertCampaignGuid = campaignId
+
+	assert.NoError(t, addCampaign(c))
 }
 
-func setupMockContextPollDate(t *testing.T, poll types.Poll) echo.Context {
-	logger = zaptest.NewLogger(t)
+func TestAddParticipantOtherTenant(t *testing.T) {
+	c, _ := setupMockContextTenant(http.MethodPut, fmt.Sprintf(`{"campaignName":"%s", "scpName": "%s", "loginName": "%s"}`, campaign, scpName, loginName))
 
-	e := echo.New()
+	mock := newMockDb(t)
+	mock.selectCampaignTenantResult = "otherTenantName"
+	mock.insertParticipantErr = fmt.Errorf("unexpected participant insert")
 
-	bodyBytes, err := json.Marshal(poll)
-	assert.NoError(t, err)
-	req := httptest.NewRequest("", "/", strings.NewReader(string(bodyBytes)))
-	rec := httptest.NewRecorder()
-	c := e.NewContext(req, rec)
-	return c
+	assertApiError(t, addParticipant(c), http.StatusNotFound, "no campaign: campaignName: myCampaignName")
 }
 
-func TestSetPollDateSelectError(t *testing.T) {
-	c := setupMockContextPollDate(t, types.Poll{})
-
-	mock, dbFake, closeDbFunc := db.SetupMockDB(t)
-	defer closeDbFunc()
-	// side effect: set up the postgresDB var
-	scoreDB = dbFake
-	pollDB = db.NewDBPoll(scoreDB.GetDb(), logger)
+func TestAddBugOtherTenant(t *testing.T) {
+	c, _ := setupMockContextTenant(http.MethodPut, `{"campaign": "`+campaign+`", "category":"`+category+`"}`)
 
-	forcedError := fmt.Errorf("forced select poll error")
-	db.SetupMockPollSelectForcedError(mock, forcedError, "1")
+	mock := newMockDb(t)
+	mock.selectCampaignTenantResult = "otherTenantName"
+	mock.insertBugErr = fmt.Errorf("unexpected bug insert")
 
-	assert.EqualError(t, setPollDate(c), forcedError.Error())
+	assertApiError(t, addBug(c), http.StatusNotFound, "no campaign: campaignName: myCampaignName")
 }
 
-func TestSetPollDateUpdateError(t *testing.T) {
-	c := setupMockContextPollDate(t, types.Poll{})
-
-	mock, dbFake, closeDbFunc := db.SetupMockDB(t)
-	defer closeDbFunc()
-	// side effect: set up the postgresDB var
-	scoreDB = dbFake
-	pollDB = db.NewDBPoll(scoreDB.GetDb(), logger)
+func TestAddOrganizationOfTenantWithoutCampaign(t *testing.T) {
+	c, _ := setupMockContextTenant(http.MethodPut, `{"scpName": "GitHub", "organization": "myOrg"}`)
 
-	db.SetupMockPollSelectAndUpdate(mock, "1", now, 1)
+	// an organization of every campaign would reach the campaigns of other tenants
+	newMockDb(t)
 
-	err := setPollDate(c)
-	assert.True(t, strings.HasPrefix(err.Error(), "ExecQuery 'UPDATE poll"))
+	assertApiError(t, addOrganization(c), http.StatusUnprocessableEntity, "missing campaignName: tenantName: myTenantName")
 }
 
-func TestSetPollDate(t *testing.T) {
-	c := setupMockContextPollDate(t, types.Poll{})
+func TestSetupRoutesTenant(t *testing.T) {
+	e := echo.New()
 
-	mock, dbFake, closeDbFunc := db.SetupMockDB(t)
-	defer closeDbFunc()
-	// side effect: set up the postgresDB var
-	scoreDB = dbFake
-	pollDB = db.NewDBPoll(scoreDB.GetDb(), logger)
+	logger = zaptest.NewLogger(t)
 
-	db.SetupMockPollSelectAndUpdateAnyUpdateTime(mock, "1", now, 1)
+	setupRoutes(e, config.Defaults(), "myBuildInfoMsg")
 
-	err := setPollDate(c)
-	assert.NoError(t, err)
+	mock := newMockDb(t)
+	mock.selectTenantAdminUsername = "tenantAdmin"
+	mock.selectTenantAdminPasswordHash = hashTenantPassword(t, "tenantPassword")
+	mock.selectCampaignTenantResult = "otherTenantName"
+
+	req := httptest.NewRequest(http.MethodGet, Tenant+"/"+tenantName+pathAdmin+Campaign+Detail+"/"+campaign, nil)
+	req.SetBasicAuth("tenantAdmin", "tenantPassword")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// the tenant admin is not an admin of the deployment
+	req = httptest.NewRequest(http.MethodGet, pathAdmin+Campaign+List, nil)
+	req.SetBasicAuth("tenantAdmin", "tenantPassword")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
 }